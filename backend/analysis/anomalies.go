@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/statistics"
+)
+
+// AnomalySeries is a time series of an attribute aggregate, such as daily dispute counts
+// or sentiment averages, with one point per time bucket
+type AnomalySeries struct {
+	Name   string         `json:"name"`
+	Points []AnomalyPoint `json:"points"`
+}
+
+// AnomalyPoint is the value of a series in the time bucket starting at Time
+type AnomalyPoint struct {
+	Time  string  `json:"time"`
+	Value float64 `json:"value"`
+	// ConversationIDs are the conversations the value was aggregated from
+	ConversationIDs []string `json:"conversation_ids,omitempty"`
+}
+
+// DetectAnomalies flags the spikes and drops of each series with options. The statistics
+// are computed here rather than by the model; explanations are added by ExplainAnomaly.
+// Each series needs more than statistics.MinBaselinePoints points with distinct times,
+// which are put in time order.
+func DetectAnomalies(series []AnomalySeries, options statistics.AnomalyOptions) (*AnomaliesResult, error) {
+	options = options.WithDefaults()
+	if err := statistics.ValidateAnomalyOptions(options); err != nil {
+		return nil, err
+	}
+
+	result := &AnomaliesResult{Options: options, Series: []AnomalySeriesSummary{}, Anomalies: []Anomaly{}}
+	names := make(map[string]bool, len(series))
+	for i, s := range series {
+		name := strings.TrimSpace(s.Name)
+		if name == "" {
+			return nil, fmt.Errorf("series %d has no name", i+1)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("series %s is given more than once", name)
+		}
+		names[name] = true
+		if len(s.Points) <= statistics.MinBaselinePoints {
+			return nil, fmt.Errorf("series %s needs at least %d points", name, statistics.MinBaselinePoints+1)
+		}
+
+		type timedPoint struct {
+			at time.Time
+			AnomalyPoint
+		}
+		points := make([]timedPoint, len(s.Points))
+		for j, point := range s.Points {
+			at, ok := statistics.ParseTime(strings.TrimSpace(point.Time))
+			if !ok {
+				return nil, fmt.Errorf("series %s, point %d: time must be a date or RFC 3339 time", name, j+1)
+			}
+			if math.IsNaN(point.Value) || math.IsInf(point.Value, 0) {
+				return nil, fmt.Errorf("series %s, point %d: value must be a number", name, j+1)
+			}
+			points[j] = timedPoint{at.UTC(), point}
+		}
+		sort.SliceStable(points, func(a, b int) bool { return points[a].at.Before(points[b].at) })
+
+		values := make([]float64, len(points))
+		for j, point := range points {
+			if j > 0 && point.at.Equal(points[j-1].at) {
+				return nil, fmt.Errorf("series %s has two points at %s", name, point.Time)
+			}
+			values[j] = point.Value
+		}
+
+		stats := statistics.DetectAnomalies(values, options)
+		described := statistics.DescribeValues(values)
+		result.Series = append(result.Series, AnomalySeriesSummary{
+			Name:      name,
+			Points:    len(points),
+			Start:     points[0].Time,
+			End:       points[len(points)-1].Time,
+			Mean:      described.Mean,
+			StdDev:    described.StdDev,
+			Anomalies: len(stats),
+		})
+
+		for _, anomaly := range stats {
+			point := points[anomaly.Index]
+			// A bucket ends where the next one starts; the last is as long as the one before it
+			end := point.at.Add(point.at.Sub(points[anomaly.Index-1].at))
+			if anomaly.Index+1 < len(points) {
+				end = points[anomaly.Index+1].at
+			}
+			result.Anomalies = append(result.Anomalies, Anomaly{
+				Series:          name,
+				Time:            point.Time,
+				Value:           point.Value,
+				Anomaly:         anomaly,
+				ConversationIDs: point.ConversationIDs,
+				Start:           point.at,
+				End:             end,
+			})
+		}
+	}
+
+	sort.SliceStable(result.Anomalies, func(i, j int) bool {
+		return math.Abs(result.Anomalies[i].Score) > math.Abs(result.Anomalies[j].Score)
+	})
+	return result, nil
+}
+
+// ExplainAnomaly asks the model what in the conversations of its time bucket explains an
+// anomaly, quoting at most maxExcerpts excerpts from them
+func (f *AnalysisFacade) ExplainAnomaly(ctx context.Context, anomaly Anomaly, conversations []models.ConversationText, maxExcerpts int) (*models.Explanation, error) {
+	return f.ExplanationProcessor.ExplainAnomaly(ctx, anomaly, conversations, maxExcerpts)
+}
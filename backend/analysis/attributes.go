@@ -0,0 +1,215 @@
+package analysis
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/processors"
+)
+
+// DefaultFanOutConcurrency is how many conversations are analyzed at once when fanning out
+const DefaultFanOutConcurrency = 4
+
+// AttributeBatching packs conversations into shared attribute extraction calls. The zero
+// value extracts each conversation in its own call.
+type AttributeBatching struct {
+	// MaxConversations is the most conversations per call; batching is off below 2
+	MaxConversations int
+	// MaxTokens is the prompt token budget of a call
+	MaxTokens int
+}
+
+// ExtractAttributesFromConversations extracts the attributes from each conversation
+// concurrently. A conversation that fails is reported with its error instead of
+// failing the others. Each result reports the calls, tokens and time its conversation
+// took; windowed conversations are condensed before extraction. With batching, the
+// conversations that aren't windowed are packed into shared calls that send the
+// attribute definitions once, and their results report equal shares of the tokens;
+// conversations extracted before on their own are still answered from the LLM response
+// cache, if one is set.
+func (f *AnalysisFacade) ExtractAttributesFromConversations(
+	ctx context.Context,
+	conversations []models.ConversationText,
+	attributes []models.AttributeDefinition,
+	concurrency int,
+	batching AttributeBatching,
+) []models.ConversationAttributes {
+	if concurrency <= 0 {
+		concurrency = DefaultFanOutConcurrency
+	}
+
+	results := make([]models.ConversationAttributes, len(conversations))
+	for i, conversation := range conversations {
+		results[i] = models.ConversationAttributes{ConversationID: conversation.ConversationID}
+	}
+
+	// Each group of conversations is extracted with its own calls. Conversations whose
+	// values are in the LLM response cache aren't packed, so they are answered from it.
+	var groups [][]int
+	var packable []int
+	for i, conversation := range conversations {
+		if batching.MaxConversations > 1 && !conversation.Windowed && !f.TextProcessor.AttributesCached(ctx, conversation.Text, attributes) {
+			packable = append(packable, i)
+		} else {
+			groups = append(groups, []int{i})
+		}
+	}
+	if len(packable) > 0 {
+		texts := make([]string, len(packable))
+		for n, i := range packable {
+			texts[n] = conversations[i].Text
+		}
+		for _, packed := range processors.PackAttributeTexts(ctx, texts, attributes, batching.MaxConversations, batching.MaxTokens) {
+			group := make([]int, len(packed))
+			for n, j := range packed {
+				group[n] = packable[j]
+			}
+			groups = append(groups, group)
+		}
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Add(1)
+		go func(group []int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				for _, i := range group {
+					results[i].Error, results[i].ErrorCode = ctx.Err().Error(), ErrorCode(ctx.Err())
+				}
+				return
+			}
+
+			// Each group counts its own usage, which still adds up in the request's. The
+			// conversations of a packed group share it equally.
+			callCtx, usage := core.WithUsage(ctx)
+			start := time.Now()
+			defer func() {
+				totals := usage.Totals()
+				for _, i := range group {
+					results[i].Processing = &models.ConversationProcessing{
+						Calls:            totals.Calls,
+						PromptTokens:     totals.PromptTokens / len(group),
+						CompletionTokens: totals.CompletionTokens / len(group),
+						Estimated:        totals.Estimated,
+						LatencyMS:        time.Since(start).Milliseconds(),
+						TextLength:       len(conversations[i].Text),
+						Windowed:         conversations[i].Windowed,
+					}
+					if len(group) > 1 {
+						results[i].Processing.Batched = len(group)
+					}
+				}
+			}()
+
+			if len(group) > 1 {
+				texts := make([]string, len(group))
+				for n, i := range group {
+					texts[n] = conversations[i].Text
+				}
+				values, errs := f.TextProcessor.GenerateAttributesPacked(callCtx, texts, attributes)
+				for n, i := range group {
+					if errs[n] != nil {
+						results[i].Error, results[i].ErrorCode = errs[n].Error(), ErrorCode(errs[n])
+						continue
+					}
+					results[i].AttributeValues = values[n]
+				}
+				return
+			}
+
+			i := group[0]
+			text := conversations[i].Text
+			if conversations[i].Windowed {
+				condensed, err := f.TextProcessor.CondenseTranscript(callCtx, text, attributes)
+				if err != nil {
+					results[i].Error, results[i].ErrorCode = err.Error(), ErrorCode(err)
+					return
+				}
+				text = condensed
+			}
+
+			values, err := f.TextProcessor.GenerateAttributes(callCtx, text, attributes)
+			if err != nil {
+				results[i].Error, results[i].ErrorCode = err.Error(), ErrorCode(err)
+				return
+			}
+			results[i].AttributeValues = values
+		}(group)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// SummarizeAttributeValues computes per-attribute statistics over the values extracted
+// from many conversations. Values are grouped case-insensitively and the most common
+// maxTopValues are reported for each attribute.
+func SummarizeAttributeValues(results []models.ConversationAttributes, maxTopValues int) []models.AttributeStatistics {
+	type accumulator struct {
+		stats      models.AttributeStatistics
+		confidence float64
+		counts     map[string]*models.ValueCount
+	}
+
+	var order []string
+	byField := map[string]*accumulator{}
+	for _, result := range results {
+		for _, value := range result.AttributeValues {
+			acc, ok := byField[value.FieldName]
+			if !ok {
+				acc = &accumulator{
+					stats:  models.AttributeStatistics{FieldName: value.FieldName},
+					counts: map[string]*models.ValueCount{},
+				}
+				byField[value.FieldName] = acc
+				order = append(order, value.FieldName)
+			}
+
+			acc.stats.Extracted++
+			acc.confidence += value.Confidence
+
+			key := strings.ToLower(strings.TrimSpace(value.Value))
+			if count, ok := acc.counts[key]; ok {
+				count.Count++
+			} else {
+				acc.counts[key] = &models.ValueCount{Value: strings.TrimSpace(value.Value), Count: 1}
+			}
+		}
+	}
+
+	statistics := make([]models.AttributeStatistics, 0, len(order))
+	for _, field := range order {
+		acc := byField[field]
+		acc.stats.AverageConfidence = acc.confidence / float64(acc.stats.Extracted)
+		acc.stats.DistinctValues = len(acc.counts)
+
+		values := make([]models.ValueCount, 0, len(acc.counts))
+		for _, count := range acc.counts {
+			values = append(values, *count)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+		if maxTopValues > 0 && len(values) > maxTopValues {
+			values = values[:maxTopValues]
+		}
+		acc.stats.TopValues = values
+
+		statistics = append(statistics, acc.stats)
+	}
+	return statistics
+}
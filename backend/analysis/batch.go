@@ -0,0 +1,465 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// Merge strategies for combining per-batch results
+const (
+	// MergeConcat concatenates arrays and merges objects across batches, keeping the first non-empty scalar
+	MergeConcat = "concat"
+	// MergeConsolidate concatenates like MergeConcat, then combines array items that share a key,
+	// summing their counts and collecting their examples
+	MergeConsolidate = "consolidate"
+	// MergeNone returns the per-batch results unmerged
+	MergeNone = "none"
+)
+
+// Defaults for batch processing
+const (
+	DefaultBatchSize        = 20
+	DefaultBatchConcurrency = 2
+)
+
+// Statuses of the items of a batch run
+const (
+	ItemSucceeded = "succeeded"
+	ItemFailed    = "failed"
+	// ItemSkipped items were not analyzed, e.g. because they have no text or the run was
+	// canceled before reaching them
+	ItemSkipped = "skipped"
+)
+
+// consolidateKeys are the fields tried, in order, to identify equivalent items when consolidating
+var consolidateKeys = []string{"pattern_type", "focus_area", "label_name", "label", "field_name", "action", "description"}
+
+// BatchOutput is the result of running an analysis over one batch
+type BatchOutput struct {
+	Results    interface{}
+	Confidence float64
+	// Items, when set, reports each item of the batch in order, for analyses that run
+	// per item and keep going when some items fail. Otherwise every item succeeded.
+	// A batch function may return an output with only Items along with its error.
+	Items []ItemOutcome
+}
+
+// ItemOutcome is how one item of a batch was processed. Code is the error code of items
+// that failed or were skipped.
+type ItemOutcome struct {
+	Status string
+	Error  string
+	Code   string
+}
+
+// BatchFunc runs an analysis over one batch of data items
+type BatchFunc func(ctx context.Context, batch []interface{}) (*BatchOutput, error)
+
+// BatchConfig configures a BatchProcessor
+type BatchConfig struct {
+	BatchSize     int
+	Concurrency   int
+	MergeStrategy string
+	// ConsolidateKey identifies equivalent items under MergeConsolidate; when empty,
+	// the first of a set of common keys present on the items is used
+	ConsolidateKey string
+	// OnProgress, when set, is called after each batch finishes with the number of finished batches
+	OnProgress func(completed, total int)
+	// ItemID, when set, returns the ID reported for an item, or "" if it has none
+	ItemID func(item interface{}) string
+}
+
+// BatchOutcome reports how one batch was processed
+type BatchOutcome struct {
+	Index      int     `json:"index"`
+	Size       int     `json:"size"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	Code       string  `json:"code,omitempty"`
+	Retryable  bool    `json:"retryable,omitempty"`
+
+	results interface{}
+	items   []ItemOutcome
+	skipped bool // The batch was not run
+}
+
+// ItemStatus reports how one data item was processed, so callers can resend only the
+// items that failed or were skipped with a retryable error
+type ItemStatus struct {
+	Index     int    `json:"index"` // Position of the item in the data
+	ID        string `json:"id,omitempty"`
+	Batch     int    `json:"batch"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+// BatchResult is the merged result of a batch run
+type BatchResult struct {
+	Results        interface{}    `json:"results"`
+	Confidence     float64        `json:"confidence,omitempty"`
+	TotalItems     int            `json:"total_items"`
+	FailedBatches  int            `json:"failed_batches"`
+	Batches        []BatchOutcome `json:"batches"`
+	SucceededItems int            `json:"succeeded_items"`
+	FailedItems    int            `json:"failed_items"`
+	SkippedItems   int            `json:"skipped_items"`
+	Items          []ItemStatus   `json:"items"`
+	// Errors describes each item that failed or was skipped
+	Errors []models.AnalysisError `json:"errors,omitempty"`
+}
+
+// BatchProcessor splits large datasets into batches, runs an analysis per batch
+// and merges the results
+type BatchProcessor struct {
+	config BatchConfig
+}
+
+// NewBatchProcessor creates a new BatchProcessor, applying defaults to unset options
+func NewBatchProcessor(config BatchConfig) (*BatchProcessor, error) {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultBatchConcurrency
+	}
+	switch config.MergeStrategy {
+	case "":
+		config.MergeStrategy = MergeConcat
+	case MergeConcat, MergeConsolidate, MergeNone:
+	default:
+		return nil, fmt.Errorf("unknown merge strategy %q", config.MergeStrategy)
+	}
+
+	return &BatchProcessor{config: config}, nil
+}
+
+// Process runs fn over the items in batches and merges the results.
+// Failed batches are reported in the result; an error is returned only if every batch fails.
+func (p *BatchProcessor) Process(ctx context.Context, items []interface{}, fn BatchFunc) (*BatchResult, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no data items to process")
+	}
+
+	batches := splitBatches(items, p.config.BatchSize)
+	outcomes := make([]BatchOutcome, len(batches))
+
+	var wg sync.WaitGroup
+	var progressMu sync.Mutex
+	completed := 0
+	reportProgress := func() {
+		if p.config.OnProgress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		completed++
+		p.config.OnProgress(completed, len(batches))
+	}
+
+	sem := make(chan struct{}, p.config.Concurrency)
+	for i, batch := range batches {
+		outcomes[i] = BatchOutcome{Index: i, Size: len(batch)}
+
+		wg.Add(1)
+		go func(i int, batch []interface{}) {
+			defer wg.Done()
+			defer reportProgress()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				outcomes[i].fail(ctx.Err())
+				outcomes[i].skipped = true
+				return
+			}
+
+			output, err := fn(ctx, batch)
+			if output != nil {
+				outcomes[i].items = output.Items
+			}
+			if err != nil {
+				outcomes[i].fail(err)
+				return
+			}
+			outcomes[i].results = output.Results
+			outcomes[i].Confidence = output.Confidence
+		}(i, batch)
+	}
+	wg.Wait()
+
+	result := &BatchResult{TotalItems: len(items), Batches: outcomes}
+	p.reportItems(result, batches)
+
+	var succeeded []BatchOutcome
+	var failures []string
+	for _, outcome := range outcomes {
+		if outcome.Error != "" {
+			result.FailedBatches++
+			failures = append(failures, fmt.Sprintf("batch %d: %s", outcome.Index, outcome.Error))
+			continue
+		}
+		succeeded = append(succeeded, outcome)
+	}
+	if len(succeeded) == 0 {
+		return nil, fmt.Errorf("all %d batches failed: %s", len(batches), strings.Join(failures, "; "))
+	}
+
+	result.Confidence = weightedConfidence(succeeded)
+	result.Results = p.merge(succeeded)
+	return result, nil
+}
+
+// fail records the error of a batch
+func (o *BatchOutcome) fail(err error) {
+	o.Error = err.Error()
+	o.Code = ErrorCode(err)
+	o.Retryable = Retryable(o.Code)
+}
+
+// merge combines the results of successful batches according to the merge strategy
+func (p *BatchProcessor) merge(outcomes []BatchOutcome) interface{} {
+	if p.config.MergeStrategy == MergeNone {
+		results := make([]interface{}, len(outcomes))
+		for i, outcome := range outcomes {
+			results[i] = outcome.results
+		}
+		return results
+	}
+
+	merged := outcomes[0].results
+	for _, outcome := range outcomes[1:] {
+		merged = mergeValues(merged, outcome.results)
+	}
+
+	if p.config.MergeStrategy == MergeConsolidate {
+		merged = consolidateValue(merged, p.config.ConsolidateKey)
+	}
+	return merged
+}
+
+// reportItems records the status of every item of a run, from the outcome of its batch
+// or the item outcomes the batch reported, and the error of each item that failed or was
+// skipped
+func (p *BatchProcessor) reportItems(result *BatchResult, batches [][]interface{}) {
+	index := 0
+	for i, batch := range batches {
+		outcome := result.Batches[i]
+		for j, item := range batch {
+			status := ItemStatus{Index: index, Batch: i, Status: ItemSucceeded}
+			switch {
+			case outcome.skipped:
+				status.Status, status.Error, status.Code = ItemSkipped, outcome.Error, outcome.Code
+			case j < len(outcome.items) && outcome.items[j].Status != "":
+				status.Status, status.Error, status.Code = outcome.items[j].Status, outcome.items[j].Error, outcome.items[j].Code
+			case outcome.Error != "":
+				status.Status, status.Error, status.Code = ItemFailed, outcome.Error, outcome.Code
+			}
+			if p.config.ItemID != nil {
+				status.ID = p.config.ItemID(item)
+			}
+			if status.Status != ItemSucceeded {
+				if status.Code == "" {
+					status.Code = CodeAnalysisError
+				}
+				status.Retryable = Retryable(status.Code)
+				result.Errors = append(result.Errors, ItemError(status.Index, status.ID, status.Code, status.Error))
+			}
+
+			switch status.Status {
+			case ItemSucceeded:
+				result.SucceededItems++
+			case ItemFailed:
+				result.FailedItems++
+			case ItemSkipped:
+				result.SkippedItems++
+			}
+			result.Items = append(result.Items, status)
+			index++
+		}
+	}
+}
+
+// splitBatches splits items into consecutive batches of at most size items
+func splitBatches(items []interface{}, size int) [][]interface{} {
+	var batches [][]interface{}
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		batches = append(batches, items[start:end])
+	}
+	return batches
+}
+
+// weightedConfidence averages batch confidences weighted by batch size, ignoring batches without one
+func weightedConfidence(outcomes []BatchOutcome) float64 {
+	var total float64
+	var weight int
+	for _, outcome := range outcomes {
+		if outcome.Confidence <= 0 {
+			continue
+		}
+		total += outcome.Confidence * float64(outcome.Size)
+		weight += outcome.Size
+	}
+	if weight == 0 {
+		return 0
+	}
+	return total / float64(weight)
+}
+
+// mergeValues merges two batch results: arrays are concatenated, objects are merged
+// key by key, and for other values the first non-empty one is kept
+func mergeValues(a, b interface{}) interface{} {
+	switch av := a.(type) {
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			merged := make([]interface{}, 0, len(av)+len(bv))
+			return append(append(merged, av...), bv...)
+		}
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			merged := make(map[string]interface{}, len(av))
+			for key, value := range av {
+				merged[key] = value
+			}
+			for key, value := range bv {
+				if existing, ok := merged[key]; ok {
+					merged[key] = mergeValues(existing, value)
+				} else {
+					merged[key] = value
+				}
+			}
+			return merged
+		}
+	case string:
+		if av == "" {
+			return b
+		}
+	case nil:
+		return b
+	}
+	return a
+}
+
+// consolidateValue combines equivalent items in every array of objects within value
+func consolidateValue(value interface{}, key string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		consolidated := make(map[string]interface{}, len(v))
+		for field, fieldValue := range v {
+			consolidated[field] = consolidateValue(fieldValue, key)
+		}
+		return consolidated
+	case []interface{}:
+		return consolidateItems(v, key)
+	}
+	return value
+}
+
+// consolidateItems combines array items that share the same key value. Strings are
+// deduplicated; objects with the same key have their counts summed and their
+// examples combined. Items without the key are kept as they are.
+func consolidateItems(items []interface{}, key string) []interface{} {
+	var result []interface{}
+	seenStrings := make(map[string]bool)
+	groups := make(map[string]map[string]interface{})
+
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			normalized := strings.ToLower(strings.TrimSpace(v))
+			if seenStrings[normalized] {
+				continue
+			}
+			seenStrings[normalized] = true
+			result = append(result, v)
+		case map[string]interface{}:
+			groupKey := itemKey(v, key)
+			if groupKey == "" {
+				result = append(result, v)
+				continue
+			}
+			if group, ok := groups[groupKey]; ok {
+				combineItems(group, v)
+				continue
+			}
+			group := make(map[string]interface{}, len(v))
+			for field, value := range v {
+				group[field] = value
+			}
+			groups[groupKey] = group
+			result = append(result, group)
+		default:
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// itemKey returns the normalized value used to identify equivalent items
+func itemKey(item map[string]interface{}, key string) string {
+	keys := consolidateKeys
+	if key != "" {
+		keys = []string{key}
+	}
+	for _, k := range keys {
+		if value, ok := item[k].(string); ok && strings.TrimSpace(value) != "" {
+			return k + ":" + strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// combineItems folds item into group: counts are summed, lists are concatenated
+// without duplicates, and empty fields are filled in
+func combineItems(group, item map[string]interface{}) {
+	for field, value := range item {
+		existing, ok := group[field]
+		if !ok {
+			group[field] = value
+			continue
+		}
+
+		switch field {
+		case "occurrences", "count", "frequency":
+			a, aOK := numberValue(existing)
+			b, bOK := numberValue(value)
+			if aOK && bOK {
+				group[field] = a + b
+			}
+			continue
+		}
+
+		switch ev := existing.(type) {
+		case []interface{}:
+			if list, ok := value.([]interface{}); ok {
+				group[field] = consolidateItems(append(append([]interface{}{}, ev...), list...), "")
+			}
+		case string:
+			if ev == "" {
+				group[field] = value
+			}
+		}
+	}
+}
+
+// numberValue converts a decoded JSON number to float64
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
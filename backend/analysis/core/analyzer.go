@@ -5,14 +5,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 )
 
 // Analyzer provides methods for analyzing conversation data
 type Analyzer struct {
 	LLMClient *LLMClient
 	Debug     bool
+
+	// chainStep runs the analyses of chain steps
+	chainStep ChainStepFunc
 }
 
+// ChainStepFunc runs one step of a chain: the analysis the step names, such as "trends",
+// with the step's parameters over its input. It returns the results of the step and the
+// confidence the analysis reported, 0 if it reported none.
+type ChainStepFunc func(ctx context.Context, step string, parameters map[string]interface{}, input interface{}) (interface{}, float64, error)
+
 // NewAnalyzer creates a new Analyzer instance
 func NewAnalyzer(apiKey string, debug bool) (*Analyzer, error) {
 	llmClient, err := NewLLMClient(apiKey, debug)
@@ -25,6 +35,12 @@ func NewAnalyzer(apiKey string, debug bool) (*Analyzer, error) {
 	}, nil
 }
 
+// SetChainStepRunner sets how chain steps run their analyses, which live outside of this
+// package. Chains fail until one is set.
+func (a *Analyzer) SetChainStepRunner(run ChainStepFunc) {
+	a.chainStep = run
+}
+
 // ProcessInBatches processes items in batches with parallelism
 func (a *Analyzer) ProcessInBatches(ctx context.Context, items []interface{}, batchSize int, processFunc func(interface{}) (interface{}, error)) ([]interface{}, error) {
 	if len(items) == 0 {
@@ -139,87 +155,142 @@ func (a *Analyzer) TransformForPatterns(data interface{}, patternTypes []string)
 	return result, nil
 }
 
-// ChainAnalysis performs a chain of analyses
+// ChainAnalysis performs a chain of analyses. Steps that do not depend on each
+// other, such as trends and patterns, run concurrently.
 func (a *Analyzer) ChainAnalysis(ctx context.Context, inputData interface{}, config map[string]interface{}) (map[string]interface{}, error) {
 	if a.Debug {
 		log.Printf("Starting chain analysis with config: %+v", config)
 	}
 
 	// Extract steps from config
-	stepsVal, ok := config["steps"]
-	if !ok {
-		return nil, fmt.Errorf("steps configuration is required for chain analysis")
+	steps, err := chainSteps(config)
+	if err != nil {
+		return nil, err
 	}
 
-	stepsArray, ok := stepsVal.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("steps must be an array")
+	dependencies, err := chainDependencies(steps, config)
+	if err != nil {
+		return nil, err
 	}
+	levels := chainLevels(steps, dependencies)
 
-	// Convert steps to strings
-	steps := make([]string, 0, len(stepsArray))
-	for _, step := range stepsArray {
-		if stepStr, ok := step.(string); ok {
-			steps = append(steps, stepStr)
-		} else {
-			return nil, fmt.Errorf("steps must be strings")
-		}
+	// Confidence of each step is bounded by the steps it builds on
+	rule, _ := config["confidence_propagation"].(string)
+	rule, err = ValidatePropagationRule(rule)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(steps) == 0 {
-		return nil, fmt.Errorf("at least one step is required")
+	stepNum := make(map[string]int, len(steps))
+	for i, step := range steps {
+		stepNum[step] = i + 1
 	}
 
-	// Initialize results with the input data
+	// Results and confidence traces keyed by step
 	results := make(map[string]interface{})
-	currentData := inputData
+	traces := make(map[string]*ConfidenceStep, len(steps))
 
-	// Process each step in sequence
-	for i, step := range steps {
-		if a.Debug {
-			log.Printf("Processing step %d: %s", i+1, step)
-		}
+	progress := chainProgressFromContext(ctx)
+	for _, step := range steps {
+		progress(ChainStepEvent{Step: step, StepNum: stepNum[step], Status: StepPending})
+	}
 
-		// Extract step-specific configuration
-		stepConfig := make(map[string]interface{})
-		if stepsConfigVal, ok := config["step_config"]; ok {
-			if stepsConfigMap, ok := stepsConfigVal.(map[string]interface{}); ok {
-				if stepConfigVal, ok := stepsConfigMap[step]; ok {
-					if stepCfg, ok := stepConfigVal.(map[string]interface{}); ok {
-						stepConfig = stepCfg
-					}
+	// Process each level in sequence and the steps within a level concurrently
+	for _, level := range levels {
+		stepResults := make([]interface{}, len(level))
+		stepConfidence := make([]float64, len(level))
+		stepErrors := make([]error, len(level))
+
+		var wg sync.WaitGroup
+		for j, step := range level {
+			// Each step reads only results of earlier levels, which are no longer written
+			input := chainStepInput(inputData, chainStepConfig(config, step), dependencies[step], results)
+
+			wg.Add(1)
+			go func(j int, step string, input interface{}) {
+				defer wg.Done()
+				if a.Debug {
+					log.Printf("Processing step %d: %s", stepNum[step], step)
 				}
-			}
+
+				// Each step counts its own usage, which still adds up in the chain's
+				stepCtx, usage := WithUsage(withChainStep(ctx, step))
+				started := time.Now()
+				progress(ChainStepEvent{Step: step, StepNum: stepNum[step], Status: StepRunning, Started: started})
+				stepResults[j], stepConfidence[j], stepErrors[j] = a.runChainStep(stepCtx, step, chainStepConfig(config, step), input)
+
+				event := ChainStepEvent{Step: step, StepNum: stepNum[step], Status: StepSucceeded, Started: started,
+					Duration: time.Since(started), Usage: usage.Totals(), Err: stepErrors[j]}
+				if stepErrors[j] != nil {
+					event.Status = StepFailed
+				}
+				progress(event)
+			}(j, step, input)
 		}
+		wg.Wait()
 
-		// Include the current data in the step configuration
-		stepConfig["input_data"] = currentData
+		for j, step := range level {
+			if stepErrors[j] != nil {
+				return results, fmt.Errorf("error in step %d (%s): %w", stepNum[step], step, stepErrors[j])
+			}
 
-		// Process the step
-		var stepResult interface{}
-		var err error
+			// Add this step's result to the results map
+			results[step] = stepResults[j]
 
-		// For actual implementation, call the appropriate analysis function here
-		// This is a simplified placeholder
-		stepResult = map[string]interface{}{
-			"step":     step,
-			"step_num": i + 1,
-			"processed_data": fmt.Sprintf("Processed %s with config: %v",
-				step, stepConfig),
+			// Propagate confidence from the steps this one depends on
+			upstream := make([]*ConfidenceStep, 0, len(dependencies[step]))
+			for _, dep := range dependencies[step] {
+				upstream = append(upstream, traces[dep])
+			}
+			trace := propagateStep(rule, step, stepResults[j], stepConfidence[j], upstream)
+			traces[step] = &trace
 		}
+	}
 
-		if err != nil {
-			return results, fmt.Errorf("error in step %d (%s): %w", i+1, step, err)
+	// The chain is as confident as its weakest final step, i.e. one no other step builds on
+	usedAsInput := make(map[string]bool)
+	for _, deps := range dependencies {
+		for _, dep := range deps {
+			usedAsInput[dep] = true
 		}
+	}
+	confidence := 1.0
+	confidenceTrace := make([]ConfidenceStep, 0, len(steps))
+	for _, step := range steps {
+		confidenceTrace = append(confidenceTrace, *traces[step])
+		if !usedAsInput[step] && traces[step].Propagated < confidence {
+			confidence = traces[step].Propagated
+		}
+	}
 
-		// Add this step's result to the results map
-		results[step] = stepResult
+	// Report the propagated confidence alongside the per-step trace and the execution plan
+	results["confidence"] = confidence
+	results["confidence_trace"] = confidenceTrace
+	results["execution_levels"] = levels
 
-		// Update current data for the next step
-		currentData = stepResult
+	return results, nil
+}
+
+// runChainStep runs the analysis of one chain step. The settings of the chain, such as
+// depends_on, are not passed on as parameters of the analysis.
+func (a *Analyzer) runChainStep(ctx context.Context, step string, stepConfig map[string]interface{}, input interface{}) (interface{}, float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := CheckBudget(ctx); err != nil {
+		return nil, 0, err
+	}
+	if a.chainStep == nil {
+		return nil, 0, fmt.Errorf("step %s cannot run: no analysis runs chain steps", step)
 	}
 
-	return results, nil
+	parameters := make(map[string]interface{}, len(stepConfig))
+	for key, value := range stepConfig {
+		if key != "depends_on" && key != "input_mapping" {
+			parameters[key] = value
+		}
+	}
+	return a.chainStep(ctx, step, parameters, input)
 }
 
 // Helper functions for extraction
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AuditRecord is one LLM call as it was sent and answered
+type AuditRecord struct {
+	RequestID string
+	Model     string
+	// Schema names the structured output the call asked for; empty for text replies
+	Schema   string
+	Prompt   string
+	Response string
+	Error    string
+	Duration time.Duration
+	// Token counts are estimated from text length when the model doesn't report them
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// AuditFunc receives every LLM call once it completes or fails. It is called from the
+// goroutines making the calls.
+type AuditFunc func(ctx context.Context, record AuditRecord)
+
+var (
+	auditMu   sync.RWMutex
+	auditFunc AuditFunc
+)
+
+// SetAuditFunc sets the function LLM calls are audited with; nil stops auditing. Calls of
+// dry runs are not audited.
+func SetAuditFunc(fn AuditFunc) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditFunc = fn
+}
+
+type requestIDKey struct{}
+
+type auditSchemaKey struct{}
+
+// WithRequestID returns a context whose LLM calls are audited under a request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID of a context, or "" if it has none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// withAuditSchema returns a context whose LLM calls are audited as asking for schema
+func withAuditSchema(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, auditSchemaKey{}, schema)
+}
+
+// recordAudit passes an LLM call to the audit function, if one is set
+func recordAudit(ctx context.Context, record AuditRecord) {
+	auditMu.RLock()
+	fn := auditFunc
+	auditMu.RUnlock()
+	if fn == nil || IsDryRun(ctx) {
+		return
+	}
+	record.RequestID = RequestID(ctx)
+	if record.Schema == "" {
+		record.Schema, _ = ctx.Value(auditSchemaKey{}).(string)
+	}
+	if record.PromptTokens == 0 && record.CompletionTokens == 0 {
+		record.PromptTokens, record.CompletionTokens = estimateTokens(record.Prompt), estimateTokens(record.Response)
+	}
+	fn(ctx, record)
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// CostFunc estimates the cost of usage in USD
+type CostFunc func(UsageTotals) float64
+
+// BudgetExceededError is returned for LLM calls and chain steps started after the usage
+// of a request has reached its budget
+type BudgetExceededError struct {
+	Budget float64 // USD
+	Spent  float64 // USD, estimated when the calls were
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("budget of $%.4f exceeded: $%.4f spent", e.Budget, e.Spent)
+}
+
+// SetBudget limits the cost of the usage to budget USD, as estimated by cost. Once it is
+// reached, further LLM calls made with the usage's context fail with a
+// *BudgetExceededError. A budget of 0 removes the limit.
+func (u *Usage) SetBudget(budget float64, cost CostFunc) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.budget = budget
+	u.cost = cost
+}
+
+// CheckBudget returns a *BudgetExceededError when the usage tracked by ctx, or by an
+// enclosing context, has reached its budget
+func CheckBudget(ctx context.Context) error {
+	for u := usageFromContext(ctx); u != nil; u = u.parent {
+		u.mu.Lock()
+		budget, cost, totals := u.budget, u.cost, u.totals()
+		u.mu.Unlock()
+		if budget <= 0 || cost == nil {
+			continue
+		}
+		if spent := cost(totals); spent >= budget {
+			return &BudgetExceededError{Budget: budget, Spent: spent}
+		}
+	}
+	return nil
+}
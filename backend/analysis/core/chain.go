@@ -0,0 +1,290 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// chainStepInputs lists the analyses each chain step builds on. Steps that are not
+// listed, or whose inputs are not part of the chain, build on the step before them.
+var chainStepInputs = map[string][]string{
+	"trends":          {},
+	"patterns":        {},
+	"attributes":      {},
+	"intent":          {},
+	"sentiment":       {},
+	"findings":        {"trends", "patterns"},
+	"recommendations": {"findings"},
+	"plan":            {"recommendations"},
+}
+
+// chainSteps reads the ordered step names from a chain configuration
+func chainSteps(config map[string]interface{}) ([]string, error) {
+	stepsVal, ok := config["steps"]
+	if !ok {
+		return nil, fmt.Errorf("steps configuration is required for chain analysis")
+	}
+
+	var steps []string
+	switch v := stepsVal.(type) {
+	case []string:
+		steps = v
+	case []interface{}:
+		steps = make([]string, 0, len(v))
+		for _, step := range v {
+			stepStr, ok := step.(string)
+			if !ok {
+				return nil, fmt.Errorf("steps must be strings")
+			}
+			steps = append(steps, stepStr)
+		}
+	default:
+		return nil, fmt.Errorf("steps must be an array")
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("at least one step is required")
+	}
+
+	seen := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		if seen[step] {
+			return nil, fmt.Errorf("step %s appears more than once", step)
+		}
+		seen[step] = true
+	}
+
+	return steps, nil
+}
+
+// chainStepConfig returns the configuration of one step from the chain's step_config
+func chainStepConfig(config map[string]interface{}, step string) map[string]interface{} {
+	stepConfig := make(map[string]interface{})
+	if stepsConfigMap, ok := config["step_config"].(map[string]interface{}); ok {
+		if stepCfg, ok := stepsConfigMap[step].(map[string]interface{}); ok {
+			for key, value := range stepCfg {
+				stepConfig[key] = value
+			}
+		}
+	}
+	return stepConfig
+}
+
+// chainInputRef is the input_mapping reference to the chain's input data
+const chainInputRef = "input"
+
+// ValidateChainConfig checks a chain configuration without running it
+func ValidateChainConfig(config map[string]interface{}) error {
+	steps, err := chainSteps(config)
+	if err != nil {
+		return err
+	}
+	if _, err := chainDependencies(steps, config); err != nil {
+		return err
+	}
+	rule, _ := config["confidence_propagation"].(string)
+	_, err = ValidatePropagationRule(rule)
+	return err
+}
+
+// chainInputMapping reads a step's "input_mapping", which maps keys of the step input to
+// references: "input" or a step name, optionally followed by a dotted path into it
+func chainInputMapping(stepConfig map[string]interface{}) (map[string]string, error) {
+	raw, ok := stepConfig["input_mapping"]
+	if !ok {
+		return nil, nil
+	}
+
+	mapping := map[string]string{}
+	switch v := raw.(type) {
+	case map[string]string:
+		mapping = v
+	case map[string]interface{}:
+		for key, value := range v {
+			ref, ok := value.(string)
+			if !ok || ref == "" {
+				return nil, fmt.Errorf("input_mapping values must be references such as \"input.text\" or \"trends\"")
+			}
+			mapping[key] = ref
+		}
+	default:
+		return nil, fmt.Errorf("input_mapping must be an object")
+	}
+	return mapping, nil
+}
+
+// chainMappedSteps returns the steps an input mapping references
+func chainMappedSteps(mapping map[string]string) []string {
+	seen := map[string]bool{}
+	var steps []string
+	for _, ref := range mapping {
+		root, _, _ := strings.Cut(ref, ".")
+		if root != chainInputRef && !seen[root] {
+			seen[root] = true
+			steps = append(steps, root)
+		}
+	}
+	sort.Strings(steps)
+	return steps
+}
+
+// chainMappedInput builds a step input from its input mapping. References to missing
+// fields map to nil.
+func chainMappedInput(mapping map[string]string, inputData interface{}, results map[string]interface{}) map[string]interface{} {
+	input := make(map[string]interface{}, len(mapping))
+	for key, ref := range mapping {
+		root, path, _ := strings.Cut(ref, ".")
+		var value interface{}
+		if root == chainInputRef {
+			value = inputData
+		} else {
+			value = results[root]
+		}
+		if path != "" {
+			for _, field := range strings.Split(path, ".") {
+				object, _ := value.(map[string]interface{})
+				value = object[field]
+			}
+		}
+		input[key] = value
+	}
+	return input
+}
+
+// chainDependencies determines the steps each step depends on. A step's "depends_on"
+// configuration overrides the default inputs, and steps referenced by its input_mapping
+// are its dependencies otherwise; dependencies must run earlier in the chain.
+func chainDependencies(steps []string, config map[string]interface{}) (map[string][]string, error) {
+	position := make(map[string]int, len(steps))
+	for i, step := range steps {
+		position[step] = i
+	}
+
+	dependencies := make(map[string][]string, len(steps))
+	for i, step := range steps {
+		stepConfig := chainStepConfig(config, step)
+		mapping, err := chainInputMapping(stepConfig)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", step, err)
+		}
+
+		explicit, ok := stepConfig["depends_on"]
+		if ok || mapping != nil {
+			var deps []string
+			if ok {
+				deps, err = toStringSlice(explicit)
+				if err != nil {
+					return nil, fmt.Errorf("depends_on of step %s: %w", step, err)
+				}
+			}
+			// Every step the mapping reads must run first
+			for _, mapped := range chainMappedSteps(mapping) {
+				if !containsString(deps, mapped) {
+					deps = append(deps, mapped)
+				}
+			}
+			for _, dep := range deps {
+				depPosition, ok := position[dep]
+				if !ok {
+					return nil, fmt.Errorf("step %s depends on %s, which is not part of the chain", step, dep)
+				}
+				if depPosition >= i {
+					return nil, fmt.Errorf("step %s depends on %s, which does not run before it", step, dep)
+				}
+			}
+			dependencies[step] = deps
+			continue
+		}
+
+		inputs, known := chainStepInputs[step]
+		var deps []string
+		for _, input := range inputs {
+			if depPosition, ok := position[input]; ok && depPosition < i {
+				deps = append(deps, input)
+			}
+		}
+
+		// Keep the serial behaviour for unknown steps and for steps whose inputs are missing
+		if i > 0 && len(deps) == 0 && (!known || len(inputs) > 0) {
+			deps = []string{steps[i-1]}
+		}
+		dependencies[step] = deps
+	}
+
+	return dependencies, nil
+}
+
+// chainLevels groups steps into levels that can run concurrently: every step
+// depends only on steps in earlier levels. Steps keep their chain order within a level.
+func chainLevels(steps []string, dependencies map[string][]string) [][]string {
+	level := make(map[string]int, len(steps))
+	var levels [][]string
+	for _, step := range steps {
+		stepLevel := 0
+		for _, dep := range dependencies[step] {
+			if level[dep]+1 > stepLevel {
+				stepLevel = level[dep] + 1
+			}
+		}
+		level[step] = stepLevel
+
+		for len(levels) <= stepLevel {
+			levels = append(levels, nil)
+		}
+		levels[stepLevel] = append(levels[stepLevel], step)
+	}
+	return levels
+}
+
+// chainStepInput builds the input of a step: its input mapping if it has one, else the
+// chain input for steps without dependencies, the result of a single dependency, or the
+// results keyed by step
+func chainStepInput(inputData interface{}, stepConfig map[string]interface{}, deps []string, results map[string]interface{}) interface{} {
+	if mapping, _ := chainInputMapping(stepConfig); mapping != nil {
+		return chainMappedInput(mapping, inputData, results)
+	}
+
+	switch len(deps) {
+	case 0:
+		return inputData
+	case 1:
+		return results[deps[0]]
+	default:
+		input := make(map[string]interface{}, len(deps))
+		for _, dep := range deps {
+			input[dep] = results[dep]
+		}
+		return input
+	}
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// toStringSlice converts a JSON array of strings
+func toStringSlice(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("must be an array of strings")
+			}
+			result = append(result, str)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// Statuses of a chain step
+const (
+	StepPending   = "pending"
+	StepRunning   = "running"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+	// StepSkipped marks steps that never ran because an earlier step failed. Chains
+	// don't report it; whoever records the progress marks the steps left pending.
+	StepSkipped = "skipped"
+)
+
+// ChainStepEvent reports a chain step changing status. Every step is reported pending
+// when the chain starts, running when it starts and succeeded or failed when it ends.
+type ChainStepEvent struct {
+	Step    string
+	StepNum int
+	Status  string
+	Started time.Time
+	// Duration and Usage are set once the step has ended
+	Duration time.Duration
+	Usage    UsageTotals
+	Err      error
+}
+
+// ChainProgressFunc receives the step events of a chain. It is called from the
+// goroutines of concurrent steps.
+type ChainProgressFunc func(event ChainStepEvent)
+
+type chainProgressKey struct{}
+
+// WithChainProgress returns a context that reports the progress of chain analyses to fn
+func WithChainProgress(ctx context.Context, fn ChainProgressFunc) context.Context {
+	return context.WithValue(ctx, chainProgressKey{}, fn)
+}
+
+// chainProgressFromContext returns the progress callback of a context, or a callback
+// that ignores events if none is set
+func chainProgressFromContext(ctx context.Context) ChainProgressFunc {
+	if fn, ok := ctx.Value(chainProgressKey{}).(ChainProgressFunc); ok && fn != nil {
+		return fn
+	}
+	return func(ChainStepEvent) {}
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AttributeDefinitionsKey is the data key holding attribute definitions shared by all
+// items of an analysis, e.g. the definitions of a stored attribute set
+const AttributeDefinitionsKey = "attribute_definitions"
+
+// CompressPrompt strips whitespace that carries no meaning for the model: indentation,
+// trailing spaces, runs of spaces and tabs, and repeated blank lines
+func CompressPrompt(prompt string) string {
+	lines := strings.Split(prompt, "\n")
+	compressed := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if !blank && len(compressed) > 0 {
+				compressed = append(compressed, "")
+			}
+			blank = true
+			continue
+		}
+		compressed = append(compressed, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(compressed, "\n"))
+}
+
+// promptDefinition is an attribute definition hoisted out of prompt data
+type promptDefinition struct {
+	title       string
+	description string
+	count       int
+	shared      bool
+}
+
+// FormatPromptData encodes analysis data for a prompt. Attribute definitions repeated
+// across items (objects with a field_name and the same title and description) and
+// the shared definitions under AttributeDefinitionsKey are listed once, ahead of the
+// data, and referenced by field_name in the items.
+func FormatPromptData(data interface{}) (string, error) {
+	definitions, encoded, err := FormatPromptDataSections(data)
+	if err != nil || definitions == "" {
+		return encoded, err
+	}
+	return definitions + "\n" + encoded, nil
+}
+
+// FormatPromptDataSections encodes analysis data like FormatPromptData, returning the
+// hoisted attribute definitions separately so they can go in a cacheable preamble.
+// definitions is empty when nothing was hoisted.
+func FormatPromptDataSections(data interface{}) (definitions string, encoded string, err error) {
+	// Work on generic JSON values so typed data is compacted the same way
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode prompt data: %w", err)
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return "", "", fmt.Errorf("failed to decode prompt data: %w", err)
+	}
+
+	found := map[string]*promptDefinition{}
+	conflicts := map[string]bool{}
+
+	// Shared definitions are listed whether or not the items repeat them
+	if m, ok := data.(map[string]interface{}); ok {
+		if shared, ok := m[AttributeDefinitionsKey].([]interface{}); ok {
+			for _, item := range shared {
+				collectDefinition(item, found, conflicts, true)
+			}
+			rest := make(map[string]interface{}, len(m))
+			for key, value := range m {
+				if key != AttributeDefinitionsKey {
+					rest[key] = value
+				}
+			}
+			data = rest
+		}
+	}
+	walkDefinitions(data, found, conflicts)
+
+	// Hoist shared definitions, and inline definitions that occur more than once and never differ
+	hoisted := map[string]*promptDefinition{}
+	for field, definition := range found {
+		if definition.shared || (definition.count > 1 && !conflicts[field]) {
+			hoisted[field] = definition
+		}
+	}
+
+	raw, err = json.Marshal(stripDefinitions(data, hoisted))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode prompt data: %w", err)
+	}
+	if len(hoisted) == 0 {
+		return "", string(raw), nil
+	}
+
+	fields := make([]string, 0, len(hoisted))
+	for field := range hoisted {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var sb strings.Builder
+	sb.WriteString("Attribute definitions (items reference them by field_name):\n")
+	for _, field := range fields {
+		definition := hoisted[field]
+		sb.WriteString("- " + field)
+		if definition.title != "" {
+			sb.WriteString(" (" + definition.title + ")")
+		}
+		if definition.description != "" {
+			sb.WriteString(": " + definition.description)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), string(raw), nil
+}
+
+// walkDefinitions counts the attribute definitions found anywhere in data
+func walkDefinitions(data interface{}, definitions map[string]*promptDefinition, conflicts map[string]bool) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		collectDefinition(v, definitions, conflicts, false)
+		for _, value := range v {
+			walkDefinitions(value, definitions, conflicts)
+		}
+	case []interface{}:
+		for _, value := range v {
+			walkDefinitions(value, definitions, conflicts)
+		}
+	}
+}
+
+// collectDefinition counts item as an attribute definition if it has a field_name
+// and a title or description. The first shared definition of a field wins.
+func collectDefinition(item interface{}, definitions map[string]*promptDefinition, conflicts map[string]bool, shared bool) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return
+	}
+	field, _ := m["field_name"].(string)
+	title, _ := m["title"].(string)
+	description, _ := m["description"].(string)
+	if field == "" || (title == "" && description == "") {
+		return
+	}
+
+	definition, ok := definitions[field]
+	if !ok {
+		definitions[field] = &promptDefinition{title: title, description: description, count: 1, shared: shared}
+		return
+	}
+	if definition.title != title || definition.description != description {
+		conflicts[field] = true
+	}
+	definition.count++
+}
+
+// stripDefinitions returns a copy of data without the inline copies of hoisted definitions
+func stripDefinitions(data interface{}, hoisted map[string]*promptDefinition) interface{} {
+	if len(hoisted) == 0 {
+		return data
+	}
+	switch v := data.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		field, _ := v["field_name"].(string)
+		title, _ := v["title"].(string)
+		description, _ := v["description"].(string)
+		definition, isHoisted := hoisted[field]
+		isHoisted = isHoisted && title == definition.title && description == definition.description
+		for key, value := range v {
+			if isHoisted && (key == "title" || key == "description") {
+				continue
+			}
+			result[key] = stripDefinitions(value, hoisted)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, value := range v {
+			result[i] = stripDefinitions(value, hoisted)
+		}
+		return result
+	default:
+		return data
+	}
+}
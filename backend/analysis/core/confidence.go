@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Confidence propagation rules for results derived from earlier results
+const (
+	// PropagateMin caps a result's confidence at the confidence of its weakest input
+	PropagateMin = "min"
+	// PropagateProduct multiplies confidences, so uncertainty compounds through every step
+	PropagateProduct = "product"
+)
+
+// ConfidenceStep records how confidence was propagated through one step of a chain
+type ConfidenceStep struct {
+	Step       string   `json:"step"`
+	Own        float64  `json:"own"`                  // Confidence reported by the step itself
+	HasOwn     bool     `json:"has_own"`              // False when the step reported no confidence
+	Propagated float64  `json:"propagated"`           // Confidence after accounting for upstream steps (1 if none reported any)
+	LimitedBy  string   `json:"limited_by,omitempty"` // Step whose confidence bounds this one, if not this step
+	DependsOn  []string `json:"depends_on,omitempty"` // Steps whose results this step consumed
+}
+
+// ValidatePropagationRule checks that a propagation rule is supported, defaulting to PropagateMin
+func ValidatePropagationRule(rule string) (string, error) {
+	switch rule {
+	case "":
+		return PropagateMin, nil
+	case PropagateMin, PropagateProduct:
+		return rule, nil
+	default:
+		return "", fmt.Errorf("unsupported confidence propagation rule: %s", rule)
+	}
+}
+
+// PropagateConfidence combines a result's own confidence with the confidence of the results it was derived from
+func PropagateConfidence(rule string, own float64, upstream ...float64) float64 {
+	propagated := clampConfidence(own)
+	for _, c := range upstream {
+		c = clampConfidence(c)
+		if rule == PropagateProduct {
+			propagated *= c
+		} else if c < propagated {
+			propagated = c
+		}
+	}
+	return propagated
+}
+
+// ExtractConfidence finds the confidence reported in a step result.
+// It reads a top-level "confidence" field, or averages the confidences of
+// extracted attribute values when the result is a list of them.
+func ExtractConfidence(result interface{}) (float64, bool) {
+	switch v := result.(type) {
+	case map[string]interface{}:
+		if c, ok := toFloat(v["confidence"]); ok {
+			return c, true
+		}
+		if values, ok := v["attribute_values"]; ok {
+			return ExtractConfidence(values)
+		}
+	case []interface{}:
+		var sum float64
+		var count int
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				if c, ok := toFloat(m["confidence"]); ok {
+					sum += c
+					count++
+				}
+			}
+		}
+		if count > 0 {
+			return sum / float64(count), true
+		}
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return ExtractConfidence(items)
+	}
+	return 0, false
+}
+
+// propagateStep computes the confidence trace of a chain step from the traces of the steps it
+// depends on. confidence is the one the step's analysis reported; without one, it is read
+// from the result.
+func propagateStep(rule string, step string, result interface{}, confidence float64, upstream []*ConfidenceStep) ConfidenceStep {
+	own, hasOwn := confidence, confidence > 0
+	if !hasOwn {
+		own, hasOwn = ExtractConfidence(result)
+	}
+	trace := ConfidenceStep{Step: step, Own: own, HasOwn: hasOwn}
+
+	// A step that reports no confidence adds no uncertainty of its own
+	if !hasOwn {
+		own = 1
+	}
+
+	if len(upstream) == 0 {
+		trace.Propagated = clampConfidence(own)
+		return trace
+	}
+
+	// The weakest upstream step is reported as the limiting one
+	weakest := upstream[0]
+	confidences := make([]float64, len(upstream))
+	for i, previous := range upstream {
+		trace.DependsOn = append(trace.DependsOn, previous.Step)
+		confidences[i] = previous.Propagated
+		if previous.Propagated < weakest.Propagated {
+			weakest = previous
+		}
+	}
+
+	trace.Propagated = PropagateConfidence(rule, own, confidences...)
+	if weakest.Propagated < 1 && (rule == PropagateProduct || weakest.Propagated < clampConfidence(own)) {
+		trace.LimitedBy = limitingStep(weakest)
+	}
+
+	return trace
+}
+
+// limitingStep returns the step that bounds the confidence of a trace
+func limitingStep(trace *ConfidenceStep) string {
+	if trace.LimitedBy != "" {
+		return trace.LimitedBy
+	}
+	return trace.Step
+}
+
+// clampConfidence keeps a confidence within [0, 1]
+func clampConfidence(c float64) float64 {
+	if c < 0 {
+		return 0
+	}
+	if c > 1 {
+		return 1
+	}
+	return c
+}
+
+// toFloat converts a JSON number to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// DryRunPrompt is a prompt a dry run built instead of sending it to the model
+type DryRunPrompt struct {
+	// Step is the chain step the prompt belongs to, for chain analyses
+	Step string `json:"step,omitempty"`
+	// Schema names the structured output the prompt asks for; empty for text replies
+	Schema       string `json:"schema,omitempty"`
+	Prompt       string `json:"prompt"`
+	PromptTokens int    `json:"prompt_tokens"`
+	// CompletionTokens is estimated from the mock reply, so it is only a rough guide
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// DryRun collects the prompts of a request that is not sent to the model. LLM calls made
+// with its context are answered by the mock provider, so analyses of several calls build
+// every prompt.
+type DryRun struct {
+	mu      sync.Mutex
+	prompts []DryRunPrompt
+}
+
+type dryRunKey struct{}
+
+type chainStepKey struct{}
+
+// WithDryRun returns a context whose LLM calls are recorded by a dry run instead of being
+// sent to the model
+func WithDryRun(ctx context.Context) (context.Context, *DryRun) {
+	dryRun := &DryRun{}
+	return context.WithValue(ctx, dryRunKey{}, dryRun), dryRun
+}
+
+// IsDryRun reports whether LLM calls made with ctx are recorded by a dry run, so their
+// replies must not be stored
+func IsDryRun(ctx context.Context) bool {
+	return dryRunFromContext(ctx) != nil
+}
+
+// dryRunFromContext returns the dry run of a context, or nil if it has none
+func dryRunFromContext(ctx context.Context) *DryRun {
+	dryRun, _ := ctx.Value(dryRunKey{}).(*DryRun)
+	return dryRun
+}
+
+// withChainStep returns a context whose prompts belong to a chain step
+func withChainStep(ctx context.Context, step string) context.Context {
+	return context.WithValue(ctx, chainStepKey{}, step)
+}
+
+// Prompts returns the prompts recorded so far, in the order they were built
+func (d *DryRun) Prompts() []DryRunPrompt {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DryRunPrompt(nil), d.prompts...)
+}
+
+// recordDryRunPrompt records a prompt in the dry run of ctx, if any, with the mock reply
+// it was answered with
+func recordDryRunPrompt(ctx context.Context, schema, prompt, reply string) {
+	dryRun := dryRunFromContext(ctx)
+	if dryRun == nil {
+		return
+	}
+	step, _ := ctx.Value(chainStepKey{}).(string)
+	dryRun.mu.Lock()
+	defer dryRun.mu.Unlock()
+	dryRun.prompts = append(dryRun.prompts, DryRunPrompt{
+		Step:             step,
+		Schema:           schema,
+		Prompt:           prompt,
+		PromptTokens:     estimateTokens(prompt),
+		CompletionTokens: estimateTokens(reply),
+	})
+}
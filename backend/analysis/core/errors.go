@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors wrapped by analysis failures, so callers can branch with errors.Is
+var (
+	// ErrInvalidAnalysisType is returned for analysis types that do not exist
+	ErrInvalidAnalysisType = errors.New("invalid analysis type")
+	// ErrSchemaValidation is returned when model output does not have the expected structure
+	ErrSchemaValidation = errors.New("model output failed schema validation")
+	// ErrProviderTimeout is returned when the LLM provider does not answer in time
+	ErrProviderTimeout = errors.New("LLM provider timed out")
+	// ErrProviderUnavailable is returned when the LLM provider cannot be reached, is
+	// overloaded or rate limits the request
+	ErrProviderUnavailable = errors.New("LLM provider unavailable")
+	// ErrDataTooLarge is returned when the input exceeds what the request or the model accepts
+	ErrDataTooLarge = errors.New("data too large")
+	// ErrBudgetExceeded is returned when the usage of a request has reached its budget;
+	// errors.As with *BudgetExceededError gives the budget and the amount spent
+	ErrBudgetExceeded = errors.New("budget exceeded")
+)
+
+// Is reports SchemaValidationErrors as ErrSchemaValidation
+func (e *SchemaValidationError) Is(target error) bool {
+	return target == ErrSchemaValidation
+}
+
+// Is reports BudgetExceededErrors as ErrBudgetExceeded
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// transportError wraps the error of an LLM request that got no complete response in
+// ErrProviderTimeout when it was caused by a timeout, or else in ErrProviderUnavailable,
+// unless the request was canceled
+func transportError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return fmt.Errorf("%w: %w", ErrProviderTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+}
+
+// statusError returns the error of a failed LLM request, wrapping ErrProviderTimeout for
+// gateway timeouts, ErrProviderUnavailable for rate limits and server errors, and
+// ErrDataTooLarge for prompts the model cannot take
+func statusError(status int, code, message string) error {
+	err := fmt.Errorf("LLM request failed with status %d", status)
+	if message != "" {
+		err = fmt.Errorf("LLM request failed with status %d: %s", status, message)
+	}
+
+	switch {
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: %w", ErrProviderTimeout, err)
+	case status == http.StatusRequestEntityTooLarge || code == "context_length_exceeded",
+		strings.Contains(strings.ToLower(message), "maximum context length"):
+		return fmt.Errorf("%w: %w", ErrDataTooLarge, err)
+	case status == http.StatusTooManyRequests || status >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	return err
+}
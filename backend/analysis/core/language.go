@@ -0,0 +1,35 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+type conversationLanguageKey struct{}
+
+// WithConversationLanguage returns a context whose LLM calls tell the model the language
+// the conversations are in, e.g. "Spanish (es)", so they are read in that language
+// rather than as if they were English
+func WithConversationLanguage(ctx context.Context, language string) context.Context {
+	if language == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, conversationLanguageKey{}, language)
+}
+
+// applyConversationLanguage adds the conversation language of the context to the
+// preamble of a prompt, so the prompt stays cacheable
+func applyConversationLanguage(ctx context.Context, prompt string) string {
+	language, _ := ctx.Value(conversationLanguageKey{}).(string)
+	if language == "" {
+		return prompt
+	}
+
+	instruction := fmt.Sprintf("The conversations are in %s. Read them in that language, and keep quotes, "+
+		"excerpts and evidence from them in the original language.", language)
+	preamble, variable := splitPrompt(prompt)
+	if preamble == "" {
+		return CacheablePrompt(instruction, variable)
+	}
+	return CacheablePrompt(preamble+"\n\n"+instruction, variable)
+}
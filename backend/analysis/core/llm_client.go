@@ -5,85 +5,134 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"strings"
+	"time"
 )
 
 // LLMClient provides methods for generating text using a language model
 type LLMClient struct {
-	apiKey    string
-	debug     bool
-	modelName string
+	apiKey           string
+	debug            bool
+	modelName        string
+	baseURL          string
+	headers          map[string]string
+	structuredOutput string
+	// maxRepairAttempts bounds re-prompts after structured output fails validation
+	maxRepairAttempts int
+	compressPrompts   bool
+	promptCache       string
+	httpClient        *http.Client
+	mock              *MockProvider
 }
 
-// NewLLMClient creates a new LLMClient instance
+// NewLLMClient creates a new LLMClient instance.
+// An OpenAI-compatible endpoint configured in the environment (see LLMConfigFromEnv) takes precedence.
 func NewLLMClient(apiKey string, debug bool) (*LLMClient, error) {
-	if apiKey == "" {
+	return NewLLMClientWithConfig(LLMConfigFromEnv(apiKey), debug)
+}
+
+// NewLLMClientWithConfig creates a new LLMClient from an explicit configuration
+func NewLLMClientWithConfig(config LLMConfig, debug bool) (*LLMClient, error) {
+	// Gateways may authenticate with custom headers instead of an API key, and the mock
+	// provider needs neither
+	if config.Provider == ProviderMock {
+		config.BaseURL = ""
+	} else if config.APIKey == "" && config.BaseURL == "" {
 		return nil, fmt.Errorf("API key is required")
 	}
 
+	modelName := config.Model
+	if modelName == "" {
+		modelName = defaultModelName
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+
+	structuredOutput := config.StructuredOutput
+	if structuredOutput == "" {
+		structuredOutput = StructuredOutputJSONSchema
+	}
+
+	headers := make(map[string]string, len(config.Headers))
+	for name, value := range config.Headers {
+		headers[name] = value
+	}
+
 	return &LLMClient{
-		apiKey:    apiKey,
-		debug:     debug,
-		modelName: "gemini-pro", // Default model
+		apiKey:            config.APIKey,
+		debug:             debug,
+		modelName:         modelName,
+		baseURL:           strings.TrimRight(config.BaseURL, "/"),
+		headers:           headers,
+		structuredOutput:  structuredOutput,
+		maxRepairAttempts: config.MaxRepairAttempts,
+		compressPrompts:   config.CompressPrompts,
+		promptCache:       config.PromptCache,
+		httpClient:        &http.Client{Timeout: timeout},
+		mock:              NewMockProvider(config.MockFixtures),
 	}, nil
 }
 
 // GenerateContent generates content using the language model
 func (c *LLMClient) GenerateContent(ctx context.Context, prompt string, expectedFormat interface{}) (interface{}, error) {
+	prompt = applyOutputStyle(ctx, applyConversationLanguage(ctx, prompt))
+
 	// Log prompt in debug mode
 	if c.debug {
-		log.Printf("LLM Prompt: %s", prompt)
+		log.Printf("LLM Prompt: %s", plainPrompt(prompt))
 	}
 
-	// In a real implementation, this would call the LLM API
-	// For now, we'll just return a mock response that matches the expected format
+	// Send the prompt to the configured OpenAI-compatible endpoint
+	if !c.useMock(ctx) {
+		return c.generateChatCompletion(ctx, prompt, expectedFormat)
+	}
 
-	// Parse the expected format to determine what to return
-	var result interface{}
+	return c.generateMock(ctx, "", prompt, func() (interface{}, error) {
+		return c.mock.Content(prompt, expectedFormat)
+	})
+}
 
-	// Check if expectedFormat is nil
-	if expectedFormat == nil {
-		// Return the prompt as is if no format is expected
-		return prompt, nil
-	}
+// useMock reports whether a request is served by the mock provider: when no endpoint is
+// configured, or the context asks for mock responses or is a dry run
+func (c *LLMClient) useMock(ctx context.Context) bool {
+	return c.baseURL == "" || mockFromContext(ctx) || IsDryRun(ctx)
+}
 
-	// Use the expectedFormat to guide the response structure
-	switch format := expectedFormat.(type) {
-	case map[string]interface{}:
-		// If we expect a map, create a default map with empty values for each key
-		resultMap := make(map[string]interface{})
-		for k, v := range format {
-			resultMap[k] = v // Use the provided default values
-		}
-		result = resultMap
-	case []interface{}:
-		// If we expect an array, return an empty array
-		result = format
-	case string:
-		// If we expect a string, return a mock string
-		result = "Generated content based on: " + format
-	default:
-		// For other types, try to encode and decode to get the structure
-		encoded, err := json.Marshal(expectedFormat)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal expected format: %w", err)
-		}
+// generateMock returns a response of the mock provider, logging, counting and auditing it
+// like a model response. Dry runs record the prompt, with the schema it asks for.
+func (c *LLMClient) generateMock(ctx context.Context, schema, prompt string, generate func() (interface{}, error)) (interface{}, error) {
+	if err := CheckBudget(ctx); err != nil {
+		return nil, err
+	}
 
-		err = json.Unmarshal(encoded, &result)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal expected format: %w", err)
-		}
+	result, err := generate()
+	if err != nil {
+		return nil, err
 	}
 
-	// Log the result in debug mode
+	resultJSON, _ := json.Marshal(result)
 	if c.debug {
-		resultJSON, _ := json.MarshalIndent(result, "", "  ")
-		log.Printf("LLM Response: %s", string(resultJSON))
+		log.Printf("LLM Response (mock): %s", string(resultJSON))
 	}
+	recordUsage(ctx, ProviderMock, c.compress(plainPrompt(prompt)), string(resultJSON), nil)
+	recordAudit(ctx, AuditRecord{Model: ProviderMock, Schema: schema, Prompt: c.compress(plainPrompt(prompt)), Response: string(resultJSON)})
+	recordDryRunPrompt(ctx, schema, c.compress(plainPrompt(prompt)), string(resultJSON))
 
 	return result, nil
 }
 
+// compress applies prompt compression when it is enabled
+func (c *LLMClient) compress(prompt string) string {
+	if !c.compressPrompts {
+		return prompt
+	}
+	return CompressPrompt(prompt)
+}
+
 // SummarizeText summarizes text using the language model
 func (c *LLMClient) SummarizeText(ctx context.Context, text string, maxLength int) (string, error) {
 	if text == "" {
@@ -173,7 +222,7 @@ func (c *LLMClient) AnalyzeText(ctx context.Context, text string, analysisType s
 	}
 
 	if !validTypes[analysisType] {
-		return nil, fmt.Errorf("invalid analysis type: %s", analysisType)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAnalysisType, analysisType)
 	}
 
 	// Truncate text if too long for the prompt
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/demo"
+)
+
+// Environment variables used to point the LLM client at an OpenAI-compatible endpoint
+const (
+	EnvLLMBaseURL = "LLM_BASE_URL" // e.g. https://llm-gateway.internal/v1 or http://localhost:8000/v1
+	EnvLLMAPIKey  = "LLM_API_KEY"  // Sent as a bearer token; optional for gateways that authenticate by header
+	EnvLLMModel   = "LLM_MODEL"
+	EnvLLMHeaders = "LLM_HEADERS" // JSON object or "Name: value" pairs separated by semicolons or newlines
+	EnvLLMTimeout = "LLM_TIMEOUT_SECONDS"
+	// EnvLLMStructuredOutput selects how structured output is requested: json_schema (default), json_object or prompt
+	EnvLLMStructuredOutput = "LLM_STRUCTURED_OUTPUT"
+	// EnvLLMMaxRepairAttempts bounds re-prompts after structured output fails validation (0 disables repair)
+	EnvLLMMaxRepairAttempts = "LLM_MAX_REPAIR_ATTEMPTS"
+	// EnvLLMPromptCompression set to false sends prompts with their whitespace unchanged
+	EnvLLMPromptCompression = "LLM_PROMPT_COMPRESSION"
+	// EnvLLMPromptCache selects how prompt preambles are cached: auto (default), anthropic or gemini
+	EnvLLMPromptCache = "LLM_PROMPT_CACHE"
+	// EnvLLMProvider set to mock serves every request from the mock provider, without a model
+	EnvLLMProvider = "LLM_PROVIDER"
+	// EnvLLMMockFixtures is a directory of fixture files for the mock provider, e.g. intent.json
+	EnvLLMMockFixtures = "LLM_MOCK_FIXTURES"
+)
+
+// defaultModelName is used when no model is configured
+const defaultModelName = "gemini-pro"
+
+// LLMConfig configures the model and endpoint used by an LLMClient
+type LLMConfig struct {
+	APIKey string
+	// BaseURL of an OpenAI-compatible API (corporate gateway, vLLM, LM Studio).
+	// When empty, the built-in client is used.
+	BaseURL string
+	Model   string
+	// Headers are added to every request, e.g. gateway authentication or routing headers
+	Headers map[string]string
+	Timeout time.Duration
+	// StructuredOutput selects how JSON output is requested from the endpoint.
+	// Defaults to StructuredOutputJSONSchema.
+	StructuredOutput string
+	// MaxRepairAttempts bounds how often invalid structured output is sent back to
+	// the model with the validation error. LLMConfigFromEnv defaults it to 2.
+	MaxRepairAttempts int
+	// CompressPrompts strips meaningless whitespace from prompts before they are sent.
+	// LLMConfigFromEnv enables it unless LLM_PROMPT_COMPRESSION is false.
+	CompressPrompts bool
+	// PromptCache selects how the static preamble of prompts is sent for provider-side
+	// caching. Defaults to PromptCacheAuto.
+	PromptCache string
+	// Provider set to ProviderMock answers requests with the mock provider instead of a model
+	Provider string
+	// MockFixtures is a directory of fixture files for mock responses
+	MockFixtures string
+}
+
+// LLMConfigFromEnv builds a client configuration from the environment.
+// apiKey is used unless LLM_API_KEY overrides it. With LLM_PROVIDER=mock, and always
+// in demo mode, the client returns mock responses instead.
+func LLMConfigFromEnv(apiKey string) LLMConfig {
+	if MockConfigured() {
+		return LLMConfig{
+			Provider:          ProviderMock,
+			MockFixtures:      os.Getenv(EnvLLMMockFixtures),
+			MaxRepairAttempts: defaultMaxRepairAttempts,
+		}
+	}
+
+	config := LLMConfig{
+		APIKey:  apiKey,
+		BaseURL: strings.TrimRight(os.Getenv(EnvLLMBaseURL), "/"),
+		Model:   os.Getenv(EnvLLMModel),
+		Headers: parseHeaders(os.Getenv(EnvLLMHeaders)),
+
+		MaxRepairAttempts: defaultMaxRepairAttempts,
+		CompressPrompts:   true,
+		// Requests may still ask for mock responses through their context
+		MockFixtures: os.Getenv(EnvLLMMockFixtures),
+	}
+
+	if key := os.Getenv(EnvLLMAPIKey); key != "" {
+		config.APIKey = key
+	}
+
+	switch mode := os.Getenv(EnvLLMStructuredOutput); mode {
+	case "":
+	case StructuredOutputJSONSchema, StructuredOutputJSONObject, StructuredOutputPrompt:
+		config.StructuredOutput = mode
+	default:
+		log.Printf("Warning: ignoring invalid %s value %q", EnvLLMStructuredOutput, mode)
+	}
+
+	if attempts := os.Getenv(EnvLLMMaxRepairAttempts); attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 0 {
+			log.Printf("Warning: ignoring invalid %s value %q", EnvLLMMaxRepairAttempts, attempts)
+		} else {
+			config.MaxRepairAttempts = n
+		}
+	}
+
+	if compress := os.Getenv(EnvLLMPromptCompression); compress != "" {
+		enabled, err := strconv.ParseBool(compress)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid %s value %q", EnvLLMPromptCompression, compress)
+		} else {
+			config.CompressPrompts = enabled
+		}
+	}
+
+	switch mode := os.Getenv(EnvLLMPromptCache); mode {
+	case "":
+	case PromptCacheAuto, PromptCacheAnthropic, PromptCacheGemini:
+		config.PromptCache = mode
+	default:
+		log.Printf("Warning: ignoring invalid %s value %q", EnvLLMPromptCache, mode)
+	}
+
+	if timeout := os.Getenv(EnvLLMTimeout); timeout != "" {
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil || seconds <= 0 {
+			log.Printf("Warning: ignoring invalid %s value %q", EnvLLMTimeout, timeout)
+		} else {
+			config.Timeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return config
+}
+
+// GatewayConfigured reports whether an OpenAI-compatible endpoint is configured in the environment
+func GatewayConfigured() bool {
+	return os.Getenv(EnvLLMBaseURL) != "" && !MockConfigured()
+}
+
+// MockConfigured reports whether the environment selects the mock provider, which demo mode
+// always uses
+func MockConfigured() bool {
+	if demo.Enabled() {
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(os.Getenv(EnvLLMProvider)), ProviderMock)
+}
+
+// parseHeaders parses header configuration given either as a JSON object
+// or as "Name: value" pairs separated by semicolons or newlines
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return headers
+	}
+
+	if strings.HasPrefix(value, "{") {
+		if err := json.Unmarshal([]byte(value), &headers); err != nil {
+			log.Printf("Warning: failed to parse %s as JSON: %v", EnvLLMHeaders, err)
+		}
+		return headers
+	}
+
+	for _, pair := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == '\n' }) {
+		name, headerValue, found := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			log.Printf("Warning: ignoring malformed header %q in %s", strings.TrimSpace(pair), EnvLLMHeaders)
+			continue
+		}
+		headers[name] = strings.TrimSpace(headerValue)
+	}
+
+	return headers
+}
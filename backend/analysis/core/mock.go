@@ -0,0 +1,254 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProviderMock serves deterministic responses without calling a model
+const ProviderMock = "mock"
+
+type mockKey struct{}
+
+// WithMock returns a context whose LLM calls are served by the mock provider, even when
+// a model endpoint is configured
+func WithMock(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mockKey{}, true)
+}
+
+// mockFromContext reports whether a context asks for mock responses
+func mockFromContext(ctx context.Context) bool {
+	mock, _ := ctx.Value(mockKey{}).(bool)
+	return mock
+}
+
+// fieldNamePattern finds the attribute field names listed in attribute extraction prompts
+var fieldNamePattern = regexp.MustCompile(`(?m)^Field Name: (\S+)`)
+
+// conversationIDPattern finds the conversation IDs in the data of a prompt
+var conversationIDPattern = regexp.MustCompile(`"conversation_id":\s*"([^"]+)"`)
+
+// transcriptNumberPattern finds the numbered transcripts of prompts that pack several
+// conversations into one call
+var transcriptNumberPattern = regexp.MustCompile(`(?m)^Conversation Transcript (\d+):`)
+
+// MockProvider answers structured requests without a model. Responses only depend on the
+// prompt, so the same request always gets the same response.
+type MockProvider struct {
+	// fixtureDir holds optional fixture files named after schemas, e.g. intent.json
+	fixtureDir string
+}
+
+// NewMockProvider creates a mock provider reading fixtures from fixtureDir, which may be empty
+func NewMockProvider(fixtureDir string) *MockProvider {
+	return &MockProvider{fixtureDir: fixtureDir}
+}
+
+// Structured returns a response in the shape of schema. A fixture file named after the
+// schema is used when there is one: either a single response, or a list of responses
+// of which one is picked by the prompt. Otherwise a response is synthesized from the
+// schema definition.
+func (m *MockProvider) Structured(prompt string, schema Schema) (interface{}, error) {
+	seed := promptSeed(prompt)
+
+	fixture, ok, err := m.fixture(schema.Name, seed)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if err := ValidateSchema(schema.Definition, fixture); err != nil {
+			return nil, fmt.Errorf("mock fixture for %s schema is invalid: %w", schema.Name, err)
+		}
+		return fixture, nil
+	}
+
+	inputs := promptInputs{}
+	for _, match := range fieldNamePattern.FindAllStringSubmatch(prompt, -1) {
+		inputs.fieldNames = append(inputs.fieldNames, match[1])
+	}
+	for _, match := range transcriptNumberPattern.FindAllStringSubmatch(prompt, -1) {
+		number, _ := strconv.Atoi(match[1])
+		inputs.transcripts = append(inputs.transcripts, number)
+	}
+	for _, match := range conversationIDPattern.FindAllStringSubmatch(prompt, -1) {
+		if !slices.Contains(inputs.conversationIDs, match[1]) {
+			inputs.conversationIDs = append(inputs.conversationIDs, match[1])
+		}
+	}
+	return synthesize(schema.Definition, schema.Name, seed, inputs), nil
+}
+
+// promptInputs are the inputs listed in a prompt that a synthesized response answers
+// item by item
+type promptInputs struct {
+	fieldNames      []string // Attribute field names
+	transcripts     []int    // Numbers of packed transcripts
+	conversationIDs []string // IDs of the conversations in the data, which responses cite
+}
+
+// Content returns a response for an unstructured request: the expected format itself,
+// or a string naming it when text is expected
+func (m *MockProvider) Content(prompt string, expectedFormat interface{}) (interface{}, error) {
+	switch format := expectedFormat.(type) {
+	case nil:
+		// Return the prompt as is if no format is expected
+		return prompt, nil
+	case map[string]interface{}:
+		// Copy the provided default values
+		result := make(map[string]interface{}, len(format))
+		for k, v := range format {
+			result[k] = v
+		}
+		return result, nil
+	case []interface{}:
+		return format, nil
+	case string:
+		return "Generated content based on: " + format, nil
+	}
+
+	// For other types, encode and decode to get the structure
+	encoded, err := json.Marshal(expectedFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal expected format: %w", err)
+	}
+	var result interface{}
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal expected format: %w", err)
+	}
+	return result, nil
+}
+
+// fixture reads the fixture of a schema, picking one response by seed when it holds a list
+func (m *MockProvider) fixture(name string, seed uint64) (interface{}, bool, error) {
+	if m.fixtureDir == "" {
+		return nil, false, nil
+	}
+
+	path := filepath.Join(m.fixtureDir, name+".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read mock fixture %s: %w", path, err)
+	}
+
+	var fixture interface{}
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, false, fmt.Errorf("failed to parse mock fixture %s: %w", path, err)
+	}
+	if responses, ok := fixture.([]interface{}); ok {
+		if len(responses) == 0 {
+			return nil, false, fmt.Errorf("mock fixture %s has no responses", path)
+		}
+		fixture = responses[seed%uint64(len(responses))]
+	}
+	return fixture, true, nil
+}
+
+// promptSeed derives the seed of a response from the text of the prompt
+func promptSeed(prompt string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(plainPrompt(prompt)))
+	return h.Sum64()
+}
+
+// childSeed derives the seed of a nested value
+func childSeed(seed uint64, key string) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s", seed, key)
+	return h.Sum64()
+}
+
+// synthesize builds a value with the shape of a schema. Strings name their property and
+// numbers fall in the usual range of their property, both varying with seed. Lists of
+// attribute values get one item per field name from the prompt, and lists answering
+// packed transcripts one item per transcript. Conversation IDs cite a conversation of
+// the prompt's data.
+func synthesize(definition map[string]interface{}, name string, seed uint64, inputs promptInputs) interface{} {
+	switch definition["type"] {
+	case "object":
+		object := make(map[string]interface{})
+		properties, _ := definition["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(properties))
+		for key := range properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if propertySchema, ok := properties[key].(map[string]interface{}); ok {
+				object[key] = synthesize(propertySchema, key, childSeed(seed, key), inputs)
+			}
+		}
+		return object
+	case "array":
+		items, _ := definition["items"].(map[string]interface{})
+		if items == nil {
+			return []interface{}{}
+		}
+		if properties, ok := items["properties"].(map[string]interface{}); ok {
+			if _, ok := properties["field_name"]; ok && len(inputs.fieldNames) > 0 {
+				list := make([]interface{}, 0, len(inputs.fieldNames))
+				for _, fieldName := range inputs.fieldNames {
+					item := synthesize(items, name, childSeed(seed, fieldName), promptInputs{}).(map[string]interface{})
+					item["field_name"] = fieldName
+					list = append(list, item)
+				}
+				return list
+			}
+			if _, ok := properties["index"]; ok && len(inputs.transcripts) > 0 {
+				// Each transcript is answered for the attributes of the prompt
+				perTranscript := promptInputs{fieldNames: inputs.fieldNames}
+				list := make([]interface{}, 0, len(inputs.transcripts))
+				for _, number := range inputs.transcripts {
+					item := synthesize(items, name, childSeed(seed, fmt.Sprint("transcript", number)), perTranscript).(map[string]interface{})
+					item["index"] = number
+					list = append(list, item)
+				}
+				return list
+			}
+		}
+		list := make([]interface{}, 2+seed%2)
+		for i := range list {
+			list[i] = synthesize(items, name, childSeed(seed, fmt.Sprint(i)), inputs)
+		}
+		return list
+	case "number":
+		switch {
+		case strings.Contains(name, "confidence"):
+			return float64(60+seed%36) / 100
+		case strings.Contains(name, "score"):
+			return float64(int(seed%201)-100) / 100
+		case strings.HasSuffix(name, "_estimate"):
+			return float64(5+seed%91) / 100
+		default:
+			return float64(seed%1000) / 10
+		}
+	case "integer":
+		if strings.Contains(name, "priority") {
+			return int(1 + seed%5)
+		}
+		return int(1 + seed%20)
+	case "boolean":
+		return seed%2 == 0
+	default:
+		if options, ok := definition["enum"].([]interface{}); ok && len(options) > 0 {
+			return options[seed%uint64(len(options))]
+		}
+		if name == "conversation_id" && len(inputs.conversationIDs) > 0 {
+			return inputs.conversationIDs[seed%uint64(len(inputs.conversationIDs))]
+		}
+		return fmt.Sprintf("Mock %s %d", strings.ReplaceAll(name, "_", " "), 1+seed%9)
+	}
+}
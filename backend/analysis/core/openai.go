@@ -0,0 +1,278 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// chatMessage is a message in an OpenAI-compatible chat completion request
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatCompletionRequest is the body of an OpenAI-compatible chat completion request
+type chatCompletionRequest struct {
+	Model          string                 `json:"model"`
+	Messages       []chatRequestMessage   `json:"messages"`
+	Stream         bool                   `json:"stream,omitempty"`
+	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+	// ExtraBody carries provider-specific options, e.g. Gemini's cached_content
+	ExtraBody map[string]interface{} `json:"extra_body,omitempty"`
+}
+
+// chatCompletionResponse is the subset of an OpenAI-compatible chat completion response used by the client
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage *tokenUsage `json:"usage,omitempty"`
+	Error *struct {
+		Message string      `json:"message"`
+		Code    interface{} `json:"code"` // A string for OpenAI, a number for some providers
+	} `json:"error,omitempty"`
+}
+
+// chatCompletionChunk is one event of a streamed OpenAI-compatible chat completion
+type chatCompletionChunk struct {
+	Choices []struct {
+		Delta chatMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *tokenUsage `json:"usage,omitempty"`
+}
+
+// tokenUsage is the token count reported with a chat completion
+type tokenUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
+}
+
+// cachedTokens returns the prompt tokens served from the provider's prompt cache
+func (u *tokenUsage) cachedTokens() int {
+	if u.PromptTokensDetails == nil {
+		return 0
+	}
+	return u.PromptTokensDetails.CachedTokens
+}
+
+// generateChatCompletion sends the prompt to the configured OpenAI-compatible endpoint
+// and decodes the reply according to the expected format
+func (c *LLMClient) generateChatCompletion(ctx context.Context, prompt string, expectedFormat interface{}) (interface{}, error) {
+	content := prompt
+	if wantsJSON(expectedFormat) {
+		format, err := json.Marshal(expectedFormat)
+		if err == nil {
+			content = fmt.Sprintf("%s\n\nRespond only with JSON matching this structure:\n%s", prompt, format)
+		}
+	}
+
+	reply, err := c.chatCompletion(ctx, content, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.debug {
+		log.Printf("LLM Response: %s", reply)
+	}
+
+	if !wantsJSON(expectedFormat) {
+		return strings.TrimSpace(reply), nil
+	}
+	return decodeJSONReply(reply)
+}
+
+// chatCompletion sends a single-message chat completion request and returns the reply text.
+// responseFormat is passed through as the request's response_format when set.
+func (c *LLMClient) chatCompletion(ctx context.Context, content string, responseFormat map[string]interface{}) (string, error) {
+	if err := CheckBudget(ctx); err != nil {
+		return "", err
+	}
+	release, err := Scheduler.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	preamble, content := splitPrompt(withInstructions(ctx, content))
+	preamble, content = c.compress(preamble), c.compress(content)
+	model := c.model(ctx)
+	started := time.Now()
+	reply, usage, err := c.sendChatCompletion(ctx, model, preamble, content, responseFormat)
+
+	// Every call is audited, including failed ones
+	record := AuditRecord{Model: model, Prompt: plainPrompt(CacheablePrompt(preamble, content)), Response: reply, Duration: time.Since(started)}
+	if usage != nil {
+		record.PromptTokens, record.CompletionTokens = usage.PromptTokens, usage.CompletionTokens
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	recordAudit(ctx, record)
+	if err != nil {
+		return "", err
+	}
+
+	recordUsage(ctx, model, CacheablePrompt(preamble, content), reply, usage)
+	return reply, nil
+}
+
+// sendChatCompletion sends a chat completion request to model and returns the reply text
+// and the token usage reported with it
+func (c *LLMClient) sendChatCompletion(ctx context.Context, model, preamble, content string, responseFormat map[string]interface{}) (string, *tokenUsage, error) {
+	stream := streamFromContext(ctx)
+	request := chatCompletionRequest{
+		Model:          model,
+		Stream:         stream != nil,
+		ResponseFormat: responseFormat,
+	}
+	c.setMessages(ctx, &request, preamble, content)
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", nil, transportError(fmt.Errorf("LLM request failed: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if stream != nil && resp.StatusCode == http.StatusOK {
+		return readStreamedCompletion(resp.Body, stream)
+	}
+	return readCompletion(resp)
+}
+
+// readCompletion reads the reply of a non-streamed chat completion
+func readCompletion(resp *http.Response) (string, *tokenUsage, error) {
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, transportError(fmt.Errorf("failed to read LLM response: %w", err))
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(respBody, &completion); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", nil, statusError(resp.StatusCode, "", strings.TrimSpace(string(respBody)))
+		}
+		return "", nil, fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if completion.Error != nil {
+			code, _ := completion.Error.Code.(string)
+			return "", nil, statusError(resp.StatusCode, code, completion.Error.Message)
+		}
+		return "", nil, statusError(resp.StatusCode, "", "")
+	}
+	if len(completion.Choices) == 0 {
+		return "", nil, fmt.Errorf("LLM response contained no choices")
+	}
+
+	return completion.Choices[0].Message.Content, completion.Usage, nil
+}
+
+// readStreamedCompletion reads a streamed chat completion, passing each piece of
+// content to stream, and returns the full reply
+func readStreamedCompletion(body io.Reader, stream StreamFunc) (string, *tokenUsage, error) {
+	var reply strings.Builder
+	var usage *tokenUsage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", nil, fmt.Errorf("failed to parse streamed LLM response: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		reply.WriteString(chunk.Choices[0].Delta.Content)
+		stream(chunk.Choices[0].Delta.Content)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, transportError(fmt.Errorf("failed to read streamed LLM response: %w", err))
+	}
+
+	return reply.String(), usage, nil
+}
+
+// wantsJSON reports whether the caller expects structured output rather than plain text
+func wantsJSON(expectedFormat interface{}) bool {
+	if expectedFormat == nil {
+		return false
+	}
+	_, isString := expectedFormat.(string)
+	return !isString
+}
+
+// decodeJSONReply extracts and parses the JSON value in a model reply,
+// tolerating markdown code fences and surrounding prose
+func decodeJSONReply(reply string) (interface{}, error) {
+	text := strings.TrimSpace(reply)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	var result interface{}
+	if err := json.Unmarshal([]byte(text), &result); err == nil {
+		return result, nil
+	}
+
+	// Fall back to the outermost object or array in the reply
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return nil, fmt.Errorf("LLM response did not contain JSON")
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(text, closing)
+	if end <= start {
+		return nil, fmt.Errorf("LLM response did not contain complete JSON")
+	}
+
+	if err := json.Unmarshal([]byte(text[start:end+1]), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON in LLM response: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Detail levels of model output
+const (
+	DetailConcise  = "concise"
+	DetailDetailed = "detailed"
+)
+
+// Audiences output can be written for
+const (
+	AudienceExecutive   = "executive"
+	AudienceOperational = "operational"
+)
+
+// MaxOutputLength is the largest number of words each descriptive text can be limited to
+const MaxOutputLength = 500
+
+// OutputStyle asks the model for output in a language and at a level of detail, and for
+// an audience in a tone with descriptive texts of at most MaxLength words. Empty fields
+// leave the prompts unchanged.
+type OutputStyle struct {
+	Language  string
+	Detail    string
+	Audience  string
+	Tone      string
+	MaxLength int
+}
+
+// ValidateOutputStyle checks the fields of an output style
+func ValidateOutputStyle(style OutputStyle) error {
+	switch style.Detail {
+	case "", DetailConcise, DetailDetailed:
+	default:
+		return fmt.Errorf("detail must be %s or %s", DetailConcise, DetailDetailed)
+	}
+	if len(style.Language) > 50 || strings.ContainsAny(style.Language, "\n\r") {
+		return fmt.Errorf("language must be a language name or code of at most 50 characters")
+	}
+	switch style.Audience {
+	case "", AudienceExecutive, AudienceOperational:
+	default:
+		return fmt.Errorf("audience must be %s or %s", AudienceExecutive, AudienceOperational)
+	}
+	if len(style.Tone) > 50 || strings.ContainsAny(style.Tone, "\n\r") {
+		return fmt.Errorf("tone must be a description of at most 50 characters, e.g. formal or empathetic")
+	}
+	if style.MaxLength < 0 || style.MaxLength > MaxOutputLength {
+		return fmt.Errorf("max_length must be an integer from 1 to %d", MaxOutputLength)
+	}
+	return nil
+}
+
+type outputStyleKey struct{}
+
+// WithOutputStyle returns a context whose LLM calls ask for output in style
+func WithOutputStyle(ctx context.Context, style OutputStyle) context.Context {
+	if style == (OutputStyle{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, outputStyleKey{}, style)
+}
+
+// OutputStyleFromContext returns the output style of a context, which is empty without one
+func OutputStyleFromContext(ctx context.Context) OutputStyle {
+	style, _ := ctx.Value(outputStyleKey{}).(OutputStyle)
+	return style
+}
+
+// applyOutputStyle adds the instructions of the context's output style to the preamble
+// of a prompt, so the prompt stays cacheable
+func applyOutputStyle(ctx context.Context, prompt string) string {
+	style := OutputStyleFromContext(ctx)
+
+	var instructions []string
+	if style.Language != "" {
+		instructions = append(instructions, fmt.Sprintf(
+			"Write all descriptive text values in %s. Keep JSON field names and enumerated values as specified.", style.Language))
+	}
+	switch style.Detail {
+	case DetailConcise:
+		instructions = append(instructions, "Keep descriptions brief: one short sentence each, and list only the most important items.")
+	case DetailDetailed:
+		instructions = append(instructions, "Give thorough descriptions with supporting detail and examples for each item.")
+	}
+	switch style.Audience {
+	case AudienceExecutive:
+		instructions = append(instructions,
+			"Write for executives: lead with the business impact, cost and risk of each item and leave out operational detail.")
+	case AudienceOperational:
+		instructions = append(instructions,
+			"Write for the operational teams that carry the work out: give the concrete steps, roles and processes involved in each item.")
+	}
+	if style.Tone != "" {
+		instructions = append(instructions, fmt.Sprintf("Use a %s tone.", style.Tone))
+	}
+	if style.MaxLength > 0 {
+		instructions = append(instructions, fmt.Sprintf("Keep each descriptive text value to at most %d words.", style.MaxLength))
+	}
+	if len(instructions) == 0 {
+		return prompt
+	}
+
+	preamble, variable := splitPrompt(prompt)
+	if preamble == "" {
+		return CacheablePrompt(strings.Join(instructions, "\n"), variable)
+	}
+	return CacheablePrompt(preamble+"\n\n"+strings.Join(instructions, "\n"), variable)
+}
+
+// LimitWords shortens text to its first maxWords words, marking the cut with an
+// ellipsis. Text is returned unchanged when maxWords is 0 or it is short enough.
+func LimitWords(text string, maxWords int) string {
+	if maxWords <= 0 {
+		return text
+	}
+	words := strings.Fields(text)
+	if len(words) <= maxWords {
+		return text
+	}
+	return strings.TrimRight(strings.Join(words[:maxWords], " "), ".,;:") + "…"
+}
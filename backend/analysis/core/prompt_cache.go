@@ -0,0 +1,232 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prompt caching modes for OpenAI-compatible endpoints
+const (
+	// PromptCacheAuto sends the cacheable preamble at the start of the prompt, where
+	// providers with automatic prefix caching (OpenAI, vLLM) reuse it across requests
+	PromptCacheAuto = "auto"
+	// PromptCacheAnthropic marks the preamble with a cache_control breakpoint, for
+	// Anthropic models behind an OpenAI-compatible gateway
+	PromptCacheAnthropic = "anthropic"
+	// PromptCacheGemini stores the preamble with Gemini context caching and sends only
+	// the rest of the prompt with a reference to the cached content
+	PromptCacheGemini = "gemini"
+)
+
+// promptCacheBreak separates the static preamble of a prompt from its variable part
+const promptCacheBreak = "\n\n<<prompt-cache-break>>\n\n"
+
+// Gemini context cache settings
+const (
+	geminiCacheTTL = time.Hour
+	// geminiCacheRefresh renews cached contents this long before they expire
+	geminiCacheRefresh = 5 * time.Minute
+	// geminiMinCacheTokens is the smallest preamble worth caching; Gemini rejects
+	// smaller cached contents
+	geminiMinCacheTokens = 1024
+)
+
+// CacheablePrompt joins a static preamble, such as instructions, attribute definitions
+// or a taxonomy shared by every batch of a run, with the variable part of a prompt.
+// The preamble is sent so the provider can cache it; see LLMConfig.PromptCache.
+func CacheablePrompt(preamble, variable string) string {
+	if preamble == "" {
+		return variable
+	}
+	return preamble + promptCacheBreak + variable
+}
+
+// splitPrompt returns the cacheable preamble and the variable part of a prompt.
+// Prompts without a preamble are returned whole as the variable part.
+func splitPrompt(prompt string) (string, string) {
+	preamble, variable, ok := strings.Cut(prompt, promptCacheBreak)
+	if !ok {
+		return "", prompt
+	}
+	return preamble, strings.ReplaceAll(variable, promptCacheBreak, "\n\n")
+}
+
+// plainPrompt returns a prompt with its preamble joined to the rest as plain text
+func plainPrompt(prompt string) string {
+	return strings.ReplaceAll(prompt, promptCacheBreak, "\n\n")
+}
+
+// chatRequestMessage is a message of a chat completion request; Content is a string
+// or a list of contentParts
+type chatRequestMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// contentPart is a text part of a message
+type contentPart struct {
+	Type         string        `json:"type"`
+	Text         string        `json:"text"`
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+// cacheControl marks the end of a cacheable prefix for Anthropic models
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+// setMessages sets the messages of a request, sending the preamble the way the
+// configured prompt cache mode expects
+func (c *LLMClient) setMessages(ctx context.Context, request *chatCompletionRequest, preamble, content string) {
+	if preamble != "" {
+		switch c.promptCache {
+		case PromptCacheAnthropic:
+			request.Messages = []chatRequestMessage{{Role: "user", Content: []contentPart{
+				{Type: "text", Text: preamble, CacheControl: &cacheControl{Type: "ephemeral"}},
+				{Type: "text", Text: content},
+			}}}
+			return
+		case PromptCacheGemini:
+			if name := geminiContextCache.lookup(ctx, c, preamble); name != "" {
+				request.ExtraBody = map[string]interface{}{
+					"google": map[string]interface{}{"cached_content": name},
+				}
+				request.Messages = []chatRequestMessage{{Role: "user", Content: content}}
+				return
+			}
+		}
+		content = preamble + "\n\n" + content
+	}
+	request.Messages = []chatRequestMessage{{Role: "user", Content: content}}
+}
+
+// contextCache tracks the Gemini cached contents created for prompt preambles
+type contextCache struct {
+	mu      sync.Mutex
+	entries map[string]*contextCacheEntry
+}
+
+// contextCacheEntry is a cached preamble. name is empty when caching it failed, in
+// which case the preamble is sent inline until the entry expires.
+type contextCacheEntry struct {
+	done    chan struct{}
+	name    string
+	expires time.Time
+}
+
+// geminiContextCache is shared by all clients so preambles are cached once per process
+var geminiContextCache = &contextCache{entries: map[string]*contextCacheEntry{}}
+
+// lookup returns the name of the cached content holding preamble, creating it if
+// needed, or "" when the preamble should be sent inline
+func (cc *contextCache) lookup(ctx context.Context, c *LLMClient, preamble string) string {
+	if estimateTokens(preamble) < geminiMinCacheTokens {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(c.baseURL + "\x00" + c.model(ctx) + "\x00" + preamble))
+	key := hex.EncodeToString(sum[:])
+
+	cc.mu.Lock()
+	entry, ok := cc.entries[key]
+	if ok {
+		select {
+		case <-entry.done:
+			if time.Now().After(entry.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		entry = &contextCacheEntry{done: make(chan struct{})}
+		cc.entries[key] = entry
+		cc.mu.Unlock()
+
+		name, err := c.createCachedContent(ctx, preamble)
+		if err != nil {
+			log.Printf("Prompt caching unavailable, sending preamble inline: %v", err)
+		}
+		entry.name = name
+		entry.expires = time.Now().Add(geminiCacheTTL - geminiCacheRefresh)
+		if err != nil && ctx.Err() != nil {
+			// Retry with the next request instead of sending the preamble inline for the whole TTL
+			entry.expires = time.Now()
+		}
+		close(entry.done)
+		return name
+	}
+	cc.mu.Unlock()
+
+	// Wait for a concurrent request creating the same cached content
+	select {
+	case <-entry.done:
+		return entry.name
+	case <-ctx.Done():
+		return ""
+	}
+}
+
+// createCachedContent stores a preamble with Gemini context caching and returns its name.
+// The native API is addressed next to the OpenAI-compatible base URL (.../v1beta/openai).
+func (c *LLMClient) createCachedContent(ctx context.Context, preamble string) (string, error) {
+	model := c.model(ctx)
+	if !strings.HasPrefix(model, "models/") {
+		model = "models/" + model
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"contents": []map[string]interface{}{
+			{"role": "user", "parts": []map[string]string{{"text": preamble}}},
+		},
+		"ttl": fmt.Sprintf("%ds", int(geminiCacheTTL.Seconds())),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cached content: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(c.baseURL, "/openai") + "/cachedContents"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create cached content request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("x-goog-api-key", c.apiKey)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cached content request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cached content response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cached content request returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var cached struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(respBody, &cached); err != nil || cached.Name == "" {
+		return "", fmt.Errorf("invalid cached content response: %s", string(respBody))
+	}
+	return cached.Name, nil
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// promptSchemas are the schemas of the structured LLM calls; a prompt template replaces
+// the prompt of the calls to the schema it is named after
+var promptSchemas = []Schema{
+	TrendsSchema, PatternsSchema, IntentGroupsSchema, ConsolidatedGroupsSchema, RequiredAttributesSchema,
+	AttributeValueSchema, AttributeValuesSchema, AttributeValuesBatchSchema, IntentSchema, IntentBatchSchema,
+	SentimentSchema, SentimentBatchSchema, ResolutionBatchSchema, CompareSchema, RecommendationsSchema,
+	RetentionStrategySchema, ActionPlanSchema, PrioritizedRecommendationsSchema, ImplementationTimelineSchema,
+	ExplanationSchema, FindingsSchema, TranslationSchema,
+}
+
+// PromptNames returns the names of the prompts templates can replace, in order
+func PromptNames() []string {
+	names := make([]string, len(promptSchemas))
+	for i, schema := range promptSchemas {
+		names[i] = schema.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsPromptName reports whether name is a prompt templates can replace
+func IsPromptName(name string) bool {
+	for _, schema := range promptSchemas {
+		if schema.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PromptData holds the variables a prompt template is rendered with
+type PromptData struct {
+	// Name is the prompt the template replaces, e.g. "sentiment"
+	Name string
+	// Instructions is the built-in preamble of the prompt: the instructions and output
+	// format. It is empty for prompts that mix instructions and input.
+	Instructions string
+	// Input is the part of the prompt that varies per call, such as a transcript or data.
+	// It is the whole prompt when Instructions is empty.
+	Input string
+	// Prompt is the whole built-in prompt
+	Prompt string
+	// Vars are the prompt_variables of the request
+	Vars map[string]interface{}
+}
+
+// ParsePromptTemplate parses the text of a prompt template
+func ParsePromptTemplate(text string) (*template.Template, error) {
+	return template.New("prompt").Option("missingkey=error").Parse(text)
+}
+
+type promptTemplatesKey struct{}
+
+// promptTemplates are the templates and variables of a request
+type promptTemplates struct {
+	byName map[string]*template.Template
+	vars   map[string]interface{}
+}
+
+// WithPromptTemplates returns a context whose LLM calls use the templates, given as
+// template texts by prompt name, with vars as their Vars
+func WithPromptTemplates(ctx context.Context, templates map[string]string, vars map[string]interface{}) (context.Context, error) {
+	if len(templates) == 0 {
+		return ctx, nil
+	}
+	parsed := make(map[string]*template.Template, len(templates))
+	for name, text := range templates {
+		tmpl, err := ParsePromptTemplate(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt template for %s: %w", name, err)
+		}
+		parsed[name] = tmpl
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	return context.WithValue(ctx, promptTemplatesKey{}, promptTemplates{byName: parsed, vars: vars}), nil
+}
+
+// applyPromptTemplate renders the context's template for a prompt, or returns the prompt
+// unchanged when there is none. A rendered prompt that ends with the input keeps the
+// rest as its cacheable preamble.
+func applyPromptTemplate(ctx context.Context, name, prompt string) (string, error) {
+	templates, _ := ctx.Value(promptTemplatesKey{}).(promptTemplates)
+	tmpl, ok := templates.byName[name]
+	if !ok {
+		return prompt, nil
+	}
+
+	instructions, input := splitPrompt(prompt)
+	data := PromptData{
+		Name:         name,
+		Instructions: instructions,
+		Input:        input,
+		Prompt:       plainPrompt(prompt),
+		Vars:         templates.vars,
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template for %s: %w", name, err)
+	}
+
+	text := rendered.String()
+	if preamble, ok := strings.CutSuffix(text, input); ok && input != "" {
+		return CacheablePrompt(strings.TrimRight(preamble, "\n "), input), nil
+	}
+	return text, nil
+}
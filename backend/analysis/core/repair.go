@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// defaultMaxRepairAttempts bounds re-prompts after a schema validation failure
+const defaultMaxRepairAttempts = 2
+
+// maxRepairOutputLength limits how much of an invalid reply is echoed back to the model
+const maxRepairOutputLength = 4000
+
+// SchemaValidationError is returned when structured output still fails validation
+// after all repair attempts
+type SchemaValidationError struct {
+	Schema   string
+	Attempts int
+	Output   string // The last invalid reply
+	Err      error
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("output for %s schema failed validation after %d attempts: %v", e.Schema, e.Attempts, e.Err)
+}
+
+func (e *SchemaValidationError) Unwrap() error {
+	return e.Err
+}
+
+// repairPrompt asks the model to correct a reply that failed validation
+func repairPrompt(prompt string, reply string, validationErr error) string {
+	if len(reply) > maxRepairOutputLength {
+		reply = reply[:maxRepairOutputLength] + "..."
+	}
+	return fmt.Sprintf(`%s
+
+Your previous response did not match the required JSON structure.
+
+Validation error:
+%v
+
+Previous response:
+%s
+
+Respond again with corrected JSON only.`, prompt, validationErr, reply)
+}
+
+// SchemaRepairStats records how often structured output for one schema needed repair
+type SchemaRepairStats struct {
+	Requests       int     `json:"requests"`        // Structured generations completed
+	Repaired       int     `json:"repaired"`        // Valid only after re-prompting
+	Failed         int     `json:"failed"`          // Still invalid after all repair attempts
+	RepairAttempts int     `json:"repair_attempts"` // Re-prompts sent
+	RepairRate     float64 `json:"repair_rate"`     // Share of requests whose first reply failed validation
+}
+
+var (
+	repairStatsMu sync.Mutex
+	repairStats   = make(map[string]*SchemaRepairStats)
+)
+
+// OutputQuality counts the structured outputs generated for a request, and how many of
+// them needed repair or stayed invalid
+type OutputQuality struct {
+	mu       sync.Mutex
+	outputs  int
+	repaired int
+	invalid  int
+}
+
+// OutputQualityTotals is a snapshot of the output quality of a request
+type OutputQualityTotals struct {
+	Outputs  int `json:"outputs"`
+	Repaired int `json:"repaired"`
+	Invalid  int `json:"invalid"`
+}
+
+type qualityKey struct{}
+
+// WithOutputQuality returns a context that counts the structured outputs of calls made with it
+func WithOutputQuality(ctx context.Context) (context.Context, *OutputQuality) {
+	quality := &OutputQuality{}
+	return context.WithValue(ctx, qualityKey{}, quality), quality
+}
+
+// Totals returns the outputs counted so far, or none for a nil OutputQuality
+func (q *OutputQuality) Totals() OutputQualityTotals {
+	if q == nil {
+		return OutputQualityTotals{}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return OutputQualityTotals{Outputs: q.outputs, Repaired: q.repaired, Invalid: q.invalid}
+}
+
+// recordSchemaOutcome updates the repair statistics for a schema, and the output quality
+// tracked by ctx
+func recordSchemaOutcome(ctx context.Context, schema string, repairAttempts int, valid bool) {
+	if quality, ok := ctx.Value(qualityKey{}).(*OutputQuality); ok {
+		quality.mu.Lock()
+		quality.outputs++
+		switch {
+		case !valid:
+			quality.invalid++
+		case repairAttempts > 0:
+			quality.repaired++
+		}
+		quality.mu.Unlock()
+	}
+
+	repairStatsMu.Lock()
+	defer repairStatsMu.Unlock()
+
+	stats, ok := repairStats[schema]
+	if !ok {
+		stats = &SchemaRepairStats{}
+		repairStats[schema] = stats
+	}
+
+	stats.Requests++
+	stats.RepairAttempts += repairAttempts
+	switch {
+	case !valid:
+		stats.Failed++
+	case repairAttempts > 0:
+		stats.Repaired++
+	}
+}
+
+// SchemaRepairMetrics returns a snapshot of the repair statistics for each schema
+func SchemaRepairMetrics() map[string]SchemaRepairStats {
+	repairStatsMu.Lock()
+	defer repairStatsMu.Unlock()
+
+	snapshot := make(map[string]SchemaRepairStats, len(repairStats))
+	for schema, stats := range repairStats {
+		s := *stats
+		if s.Requests > 0 {
+			s.RepairRate = float64(s.Repaired+s.Failed) / float64(s.Requests)
+		}
+		snapshot[schema] = s
+	}
+	return snapshot
+}
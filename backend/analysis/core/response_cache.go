@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// ResponseCache stores the validated replies of structured LLM calls, so a call with the
+// same model, schema and prompt as an earlier one is answered without the model. Its
+// methods are called from the goroutines making the calls.
+type ResponseCache interface {
+	// Get returns the reply stored under key, if there is one
+	Get(ctx context.Context, key string) (json.RawMessage, bool)
+	// Put stores a reply under key
+	Put(ctx context.Context, key string, reply json.RawMessage)
+}
+
+var (
+	responseCacheMu sync.RWMutex
+	responseCache   ResponseCache
+)
+
+// SetResponseCache sets the cache structured replies are stored in; nil stops caching.
+// Mock replies, including those of dry runs, are never cached.
+func SetResponseCache(cache ResponseCache) {
+	responseCacheMu.Lock()
+	defer responseCacheMu.Unlock()
+	responseCache = cache
+}
+
+// currentResponseCache returns the response cache, or nil if none is set
+func currentResponseCache() ResponseCache {
+	responseCacheMu.RLock()
+	defer responseCacheMu.RUnlock()
+	return responseCache
+}
+
+type freshResponsesKey struct{}
+
+// WithFreshResponses returns a context whose LLM calls are sent to the model even when
+// their reply is cached. Their replies still refresh the cache.
+func WithFreshResponses(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshResponsesKey{}, true)
+}
+
+// responseCacheKey hashes everything that determines the reply of a structured call: the
+// model, the schema and the prompt with the instructions of the context's variant
+func (c *LLMClient) responseCacheKey(ctx context.Context, prompt string, schema Schema) string {
+	definition, _ := json.Marshal(schema.Definition)
+	h := sha256.New()
+	for _, part := range []string{c.model(ctx), schema.Name, string(definition), withInstructions(ctx, prompt)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return "llm:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedReply returns the cached reply of a structured call, if one is cached and still
+// conforms to the schema
+func (c *LLMClient) cachedReply(ctx context.Context, key string, schema Schema) (interface{}, bool) {
+	cache := currentResponseCache()
+	if cache == nil {
+		return nil, false
+	}
+	if fresh, _ := ctx.Value(freshResponsesKey{}).(bool); fresh {
+		return nil, false
+	}
+	reply, ok := cache.Get(ctx, key)
+	if !ok {
+		return nil, false
+	}
+	var value interface{}
+	if err := json.Unmarshal(reply, &value); err != nil || ValidateSchema(schema.Definition, value) != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// cacheReply stores the validated reply of a structured call, if a response cache is set
+func (c *LLMClient) cacheReply(ctx context.Context, key string, value interface{}) {
+	cache := currentResponseCache()
+	if cache == nil {
+		return
+	}
+	reply, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Error encoding reply for the response cache: %v", err)
+		return
+	}
+	cache.Put(ctx, key, reply)
+}
+
+// HasCachedReply reports whether GenerateStructured would answer prompt from the response
+// cache without calling the model, so callers can avoid packing it with other prompts
+func (c *LLMClient) HasCachedReply(ctx context.Context, prompt string, schema Schema) bool {
+	if currentResponseCache() == nil || c.useMock(ctx) {
+		return false
+	}
+	prompt, err := c.structuredPrompt(ctx, prompt, schema)
+	if err != nil {
+		return false
+	}
+	_, ok := c.cachedReply(ctx, c.responseCacheKey(ctx, prompt, schema), schema)
+	return ok
+}
@@ -0,0 +1,322 @@
+package core
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Scheduler environment variables
+const (
+	// EnvLLMMaxConcurrentCalls bounds the LLM calls the server makes at once (0 for no limit)
+	EnvLLMMaxConcurrentCalls = "LLM_MAX_CONCURRENT_CALLS"
+	// EnvLLMWorkflowConcurrentCalls bounds the LLM calls of each workflow (0 for no limit)
+	EnvLLMWorkflowConcurrentCalls = "LLM_WORKFLOW_CONCURRENT_CALLS"
+)
+
+// defaultMaxConcurrentCalls is the server-wide LLM call limit when none is configured
+const defaultMaxConcurrentCalls = 16
+
+// SchedulerLimits bound the LLM calls made at once. A limit of 0 means no limit.
+type SchedulerLimits struct {
+	MaxConcurrentCalls      int `json:"max_concurrent_calls"`      // All workflows together
+	WorkflowConcurrentCalls int `json:"workflow_concurrent_calls"` // Each workflow without a limit of its own
+}
+
+// SchedulerLimitsFromEnv reads the scheduler limits from the environment
+func SchedulerLimitsFromEnv() SchedulerLimits {
+	limits := SchedulerLimits{MaxConcurrentCalls: defaultMaxConcurrentCalls}
+	for _, setting := range []struct {
+		name string
+		dest *int
+	}{{EnvLLMMaxConcurrentCalls, &limits.MaxConcurrentCalls}, {EnvLLMWorkflowConcurrentCalls, &limits.WorkflowConcurrentCalls}} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Printf("Warning: ignoring invalid %s value %q", setting.name, value)
+			continue
+		}
+		*setting.dest = n
+	}
+	return limits
+}
+
+// WorkflowCalls reports the LLM calls of one workflow
+type WorkflowCalls struct {
+	WorkflowID string `json:"workflow_id"`
+	Running    int    `json:"running"`
+	Queued     int    `json:"queued"`
+	Limit      int    `json:"limit,omitempty"` // The workflow's own limit, if it has one
+}
+
+// SchedulerStats is a snapshot of the LLM calls running and waiting for a slot
+type SchedulerStats struct {
+	Running   int             `json:"running"`
+	Queued    int             `json:"queued"`
+	Workflows []WorkflowCalls `json:"workflows"`
+}
+
+// CallScheduler dispatches LLM calls within a server-wide and a per-workflow concurrency
+// limit. Calls that cannot start wait in a queue per workflow, and freed slots go to the
+// waiting workflows in turn, so a workflow submitting many calls at once cannot starve
+// the others. Calls are attributed to the workflow set with WithWorkflow; calls without
+// one share a queue.
+type CallScheduler struct {
+	mu             sync.Mutex
+	limits         SchedulerLimits
+	workflowLimits map[string]int
+	running        map[string]int
+	total          int
+	queues         map[string][]*scheduledCall
+	order          []string // Workflows with queued calls, in the order they are served
+	next           int      // Position in order of the workflow served next
+}
+
+// scheduledCall is a call waiting for a slot; ready is closed when it is granted one
+type scheduledCall struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// Scheduler dispatches the LLM calls of the server. It has no limits until they are set.
+var Scheduler = NewCallScheduler(SchedulerLimits{})
+
+// NewCallScheduler creates a scheduler with the given limits
+func NewCallScheduler(limits SchedulerLimits) *CallScheduler {
+	return &CallScheduler{
+		limits:         limits,
+		workflowLimits: map[string]int{},
+		running:        map[string]int{},
+		queues:         map[string][]*scheduledCall{},
+	}
+}
+
+type workflowKey struct{}
+
+// WithWorkflow returns a context whose LLM calls are scheduled as calls of a workflow
+func WithWorkflow(ctx context.Context, workflowID string) context.Context {
+	return context.WithValue(ctx, workflowKey{}, workflowID)
+}
+
+// workflowFromContext returns the workflow LLM calls made with ctx are scheduled under
+func workflowFromContext(ctx context.Context) string {
+	workflowID, _ := ctx.Value(workflowKey{}).(string)
+	return workflowID
+}
+
+// Limits returns the limits of the scheduler
+func (s *CallScheduler) Limits() SchedulerLimits {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limits
+}
+
+// SetLimits replaces the limits of the scheduler. Raised limits start waiting calls at
+// once; lowered limits apply as running calls complete.
+func (s *CallScheduler) SetLimits(limits SchedulerLimits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = limits
+	s.dispatch()
+}
+
+// SetWorkflowLimit sets the concurrency limit of one workflow, replacing the per-workflow
+// limit of the scheduler for it. A limit of 0 removes it.
+func (s *CallScheduler) SetWorkflowLimit(workflowID string, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit > 0 {
+		s.workflowLimits[workflowID] = limit
+	} else {
+		delete(s.workflowLimits, workflowID)
+	}
+	s.dispatch()
+}
+
+// Acquire waits for a slot for an LLM call of the workflow of ctx and returns the function
+// that frees it, which must be called once the call completes. It fails when ctx is done
+// first.
+func (s *CallScheduler) Acquire(ctx context.Context) (func(), error) {
+	workflowID := workflowFromContext(ctx)
+
+	s.mu.Lock()
+	if len(s.queues[workflowID]) == 0 && s.canStart(workflowID) {
+		s.start(workflowID)
+		s.mu.Unlock()
+		return s.releaseFunc(workflowID), nil
+	}
+	call := &scheduledCall{ready: make(chan struct{})}
+	if len(s.queues[workflowID]) == 0 {
+		s.order = append(s.order, workflowID)
+	}
+	s.queues[workflowID] = append(s.queues[workflowID], call)
+	s.mu.Unlock()
+
+	select {
+	case <-call.ready:
+		return s.releaseFunc(workflowID), nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if call.granted {
+		// The slot was granted as the context ended; pass it on
+		s.finish(workflowID)
+		return nil, ctx.Err()
+	}
+	s.dequeue(workflowID, call)
+	return nil, ctx.Err()
+}
+
+// Stats returns the calls running and queued per workflow, busiest first
+func (s *CallScheduler) Stats() SchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byWorkflow := map[string]*WorkflowCalls{}
+	entry := func(workflowID string) *WorkflowCalls {
+		if calls, ok := byWorkflow[workflowID]; ok {
+			return calls
+		}
+		calls := &WorkflowCalls{WorkflowID: workflowID, Limit: s.workflowLimits[workflowID]}
+		byWorkflow[workflowID] = calls
+		return calls
+	}
+	stats := SchedulerStats{Running: s.total, Workflows: []WorkflowCalls{}}
+	for workflowID, n := range s.running {
+		entry(workflowID).Running = n
+	}
+	for workflowID, queue := range s.queues {
+		entry(workflowID).Queued = len(queue)
+		stats.Queued += len(queue)
+	}
+	for _, calls := range byWorkflow {
+		stats.Workflows = append(stats.Workflows, *calls)
+	}
+	sort.Slice(stats.Workflows, func(i, j int) bool {
+		a, b := stats.Workflows[i], stats.Workflows[j]
+		if a.Running+a.Queued != b.Running+b.Queued {
+			return a.Running+a.Queued > b.Running+b.Queued
+		}
+		return a.WorkflowID < b.WorkflowID
+	})
+	return stats
+}
+
+// WorkflowStats returns the calls of one workflow running and queued
+func (s *CallScheduler) WorkflowStats(workflowID string) WorkflowCalls {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return WorkflowCalls{
+		WorkflowID: workflowID,
+		Running:    s.running[workflowID],
+		Queued:     len(s.queues[workflowID]),
+		Limit:      s.workflowLimits[workflowID],
+	}
+}
+
+// releaseFunc returns the function that frees the slot of a call of a workflow once
+func (s *CallScheduler) releaseFunc(workflowID string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.finish(workflowID)
+		})
+	}
+}
+
+// canStart reports whether a call of a workflow may start now. The caller holds s.mu.
+func (s *CallScheduler) canStart(workflowID string) bool {
+	if s.limits.MaxConcurrentCalls > 0 && s.total >= s.limits.MaxConcurrentCalls {
+		return false
+	}
+	limit, ok := s.workflowLimits[workflowID]
+	if !ok {
+		limit = s.limits.WorkflowConcurrentCalls
+	}
+	return limit <= 0 || s.running[workflowID] < limit
+}
+
+// start counts a call of a workflow as running. The caller holds s.mu.
+func (s *CallScheduler) start(workflowID string) {
+	s.running[workflowID]++
+	s.total++
+}
+
+// finish frees the slot of a call of a workflow and hands free slots to waiting calls.
+// The caller holds s.mu.
+func (s *CallScheduler) finish(workflowID string) {
+	if s.running[workflowID]--; s.running[workflowID] <= 0 {
+		delete(s.running, workflowID)
+	}
+	s.total--
+	s.dispatch()
+}
+
+// dispatch grants free slots to waiting calls, taking the workflows with queued calls in
+// turn, oldest call first. The caller holds s.mu.
+func (s *CallScheduler) dispatch() {
+	for {
+		granted := false
+		for i := 0; i < len(s.order); i++ {
+			position := (s.next + i) % len(s.order)
+			workflowID := s.order[position]
+			if !s.canStart(workflowID) {
+				continue
+			}
+			call := s.queues[workflowID][0]
+			s.start(workflowID)
+			call.granted = true
+			close(call.ready)
+			s.next = position + 1
+			s.dequeue(workflowID, call)
+			granted = true
+			break
+		}
+		if !granted {
+			return
+		}
+	}
+}
+
+// dequeue removes a call from the queue of its workflow, and the workflow from the
+// rotation once its queue is empty. The caller holds s.mu.
+func (s *CallScheduler) dequeue(workflowID string, call *scheduledCall) {
+	queue := s.queues[workflowID]
+	for i, queued := range queue {
+		if queued == call {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		s.queues[workflowID] = queue
+		return
+	}
+
+	delete(s.queues, workflowID)
+	for i, queued := range s.order {
+		if queued != workflowID {
+			continue
+		}
+		s.order = append(s.order[:i], s.order[i+1:]...)
+		if i < s.next {
+			s.next--
+		}
+		break
+	}
+	if len(s.order) == 0 {
+		s.next = 0
+	} else {
+		s.next %= len(s.order)
+	}
+}
@@ -0,0 +1,451 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Structured output modes for OpenAI-compatible endpoints
+const (
+	// StructuredOutputJSONSchema constrains the reply to the declared schema (response_format json_schema)
+	StructuredOutputJSONSchema = "json_schema"
+	// StructuredOutputJSONObject only guarantees a JSON object, for endpoints without schema support
+	StructuredOutputJSONObject = "json_object"
+	// StructuredOutputPrompt relies on prompt instructions alone
+	StructuredOutputPrompt = "prompt"
+)
+
+// Schema declares the JSON structure expected from the model for one kind of request
+type Schema struct {
+	Name       string
+	Definition map[string]interface{}
+}
+
+// GenerateStructured generates a JSON object that conforms to schema, using the
+// endpoint's native structured output support where available. Output that fails
+// validation is sent back to the model with the error, up to the client's repair limit.
+// A prompt template for the schema in ctx replaces the prompt; see WithPromptTemplates.
+// The output style and conversation language in ctx are added to the prompt; see
+// WithOutputStyle and WithConversationLanguage. Replies are served from and stored in the
+// response cache, if one is set; see SetResponseCache.
+func (c *LLMClient) GenerateStructured(ctx context.Context, prompt string, schema Schema) (interface{}, error) {
+	prompt, err := c.structuredPrompt(ctx, prompt, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.useMock(ctx) {
+		if c.debug {
+			log.Printf("LLM Prompt (%s schema): %s", schema.Name, plainPrompt(prompt))
+		}
+		return c.generateMock(ctx, schema.Name, prompt, func() (interface{}, error) {
+			return c.mock.Structured(prompt, schema)
+		})
+	}
+
+	key := c.responseCacheKey(ctx, prompt, schema)
+	if value, ok := c.cachedReply(ctx, key, schema); ok {
+		if c.debug {
+			log.Printf("LLM Response (%s schema) served from the response cache", schema.Name)
+		}
+		return value, nil
+	}
+
+	attemptPrompt := prompt
+	for attempt := 0; ; attempt++ {
+		reply, result, err := c.generateStructuredOnce(ctx, attemptPrompt, schema)
+		if err != nil {
+			return nil, err
+		}
+
+		invalid := result.err
+		if invalid == nil {
+			invalid = ValidateSchema(schema.Definition, result.value)
+		}
+		if invalid == nil {
+			recordSchemaOutcome(ctx, schema.Name, attempt, true)
+			c.cacheReply(ctx, key, result.value)
+			return result.value, nil
+		}
+
+		if attempt >= c.maxRepairAttempts {
+			recordSchemaOutcome(ctx, schema.Name, attempt, false)
+			return nil, &SchemaValidationError{Schema: schema.Name, Attempts: attempt + 1, Output: reply, Err: invalid}
+		}
+
+		log.Printf("Structured output for %s schema failed validation (attempt %d): %v", schema.Name, attempt+1, invalid)
+		attemptPrompt = repairPrompt(prompt, reply, invalid)
+	}
+}
+
+// structuredPrompt returns a prompt as it is sent for schema: the prompt template of the
+// schema in ctx replaces it, and the output style and conversation language are added
+func (c *LLMClient) structuredPrompt(ctx context.Context, prompt string, schema Schema) (string, error) {
+	prompt, err := applyPromptTemplate(ctx, schema.Name, prompt)
+	if err != nil {
+		return "", err
+	}
+	return applyOutputStyle(ctx, applyConversationLanguage(ctx, prompt)), nil
+}
+
+// structuredReply is a decoded model reply, or the error that prevented decoding it
+type structuredReply struct {
+	value interface{}
+	err   error
+}
+
+// generateStructuredOnce sends one structured output request and decodes the reply.
+// Transport errors are returned directly; decoding errors are returned in the reply
+// so they can be repaired.
+func (c *LLMClient) generateStructuredOnce(ctx context.Context, prompt string, schema Schema) (string, structuredReply, error) {
+	var responseFormat map[string]interface{}
+	switch c.structuredOutput {
+	case StructuredOutputJSONSchema:
+		responseFormat = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   schema.Name,
+				"schema": schema.Definition,
+				"strict": true,
+			},
+		}
+	case StructuredOutputJSONObject:
+		responseFormat = map[string]interface{}{"type": "json_object"}
+		prompt = withSchemaInstructions(prompt, schema)
+	default:
+		prompt = withSchemaInstructions(prompt, schema)
+	}
+
+	if c.debug {
+		log.Printf("LLM Prompt (%s schema): %s", schema.Name, prompt)
+	}
+
+	reply, err := c.chatCompletion(withAuditSchema(ctx, schema.Name), prompt, responseFormat)
+	if err != nil {
+		return "", structuredReply{}, err
+	}
+	if c.debug {
+		log.Printf("LLM Response: %s", reply)
+	}
+
+	// Prompt-only replies may be wrapped in prose or code fences
+	if responseFormat == nil {
+		value, err := decodeJSONReply(reply)
+		return reply, structuredReply{value: value, err: err}, nil
+	}
+
+	// The endpoint guarantees JSON, so no recovery from partial output is attempted
+	var value interface{}
+	if err := json.Unmarshal([]byte(reply), &value); err != nil {
+		return reply, structuredReply{err: fmt.Errorf("invalid JSON: %w", err)}, nil
+	}
+	return reply, structuredReply{value: value}, nil
+}
+
+// withSchemaInstructions appends the schema to a prompt for endpoints that cannot enforce it
+func withSchemaInstructions(prompt string, schema Schema) string {
+	definition, err := json.Marshal(schema.Definition)
+	if err != nil {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n\nRespond only with a JSON object matching this JSON Schema:\n%s", prompt, definition)
+}
+
+// objectSchema declares an object whose properties are all required, as strict mode demands
+func objectSchema(properties map[string]interface{}) map[string]interface{} {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// arraySchema declares an array of items
+func arraySchema(items map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": items}
+}
+
+// indexedSchema extends an object schema with the "index" of the numbered input an item
+// of a packed reply answers
+func indexedSchema(definition map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{"index": typeSchema("integer")}
+	for name, property := range definition["properties"].(map[string]interface{}) {
+		properties[name] = property
+	}
+	return objectSchema(properties)
+}
+
+// typeSchema declares a primitive JSON type
+func typeSchema(jsonType string) map[string]interface{} {
+	return map[string]interface{}{"type": jsonType}
+}
+
+// Schemas for the structured output of each analysis
+var (
+	TrendsSchema = Schema{Name: "trends", Definition: objectSchema(map[string]interface{}{
+		"trends": arraySchema(objectSchema(map[string]interface{}{
+			"focus_area":      typeSchema("string"),
+			"trend":           typeSchema("string"),
+			"supporting_data": typeSchema("string"),
+			"confidence":      typeSchema("number"),
+		})),
+		"overall_insights": arraySchema(typeSchema("string")),
+		"data_quality": objectSchema(map[string]interface{}{
+			"assessment":  typeSchema("string"),
+			"limitations": arraySchema(typeSchema("string")),
+		}),
+	})}
+
+	PatternsSchema = Schema{Name: "patterns", Definition: objectSchema(map[string]interface{}{
+		"patterns": arraySchema(patternSchema),
+		"unexpected_patterns": arraySchema(objectSchema(map[string]interface{}{
+			"description":      typeSchema("string"),
+			"potential_causes": arraySchema(typeSchema("string")),
+		})),
+	})}
+
+	IntentGroupsSchema = Schema{Name: "intent_groups", Definition: PatternsSchema.Definition}
+
+	ConsolidatedGroupsSchema = Schema{Name: "consolidated_groups", Definition: objectSchema(map[string]interface{}{
+		"consolidated_groups": arraySchema(patternSchema),
+	})}
+
+	RequiredAttributesSchema = Schema{Name: "required_attributes", Definition: objectSchema(map[string]interface{}{
+		"attributes": arraySchema(objectSchema(map[string]interface{}{
+			"field_name":  typeSchema("string"),
+			"title":       typeSchema("string"),
+			"description": typeSchema("string"),
+			"rationale":   typeSchema("string"),
+		})),
+	})}
+
+	AttributeValueSchema = Schema{Name: "attribute_value", Definition: objectSchema(map[string]interface{}{
+		"value":       typeSchema("string"),
+		"confidence":  typeSchema("number"),
+		"explanation": typeSchema("string"),
+	})}
+
+	AttributeValuesSchema = Schema{Name: "attribute_values", Definition: objectSchema(map[string]interface{}{
+		"attribute_values": arraySchema(objectSchema(map[string]interface{}{
+			"field_name":  typeSchema("string"),
+			"value":       typeSchema("string"),
+			"confidence":  typeSchema("number"),
+			"explanation": typeSchema("string"),
+		})),
+	})}
+
+	// AttributeValuesBatchSchema holds the attribute values of several numbered transcripts
+	// sent in one prompt
+	AttributeValuesBatchSchema = Schema{Name: "attribute_values_batch", Definition: objectSchema(map[string]interface{}{
+		"conversations": arraySchema(indexedSchema(AttributeValuesSchema.Definition)),
+	})}
+
+	IntentSchema = Schema{Name: "intent", Definition: objectSchema(map[string]interface{}{
+		"label_name":  typeSchema("string"),
+		"label":       typeSchema("string"),
+		"description": typeSchema("string"),
+	})}
+
+	// IntentBatchSchema holds the intents of several numbered transcripts sent in one prompt
+	IntentBatchSchema = Schema{Name: "intent_batch", Definition: objectSchema(map[string]interface{}{
+		"intents": arraySchema(indexedSchema(IntentSchema.Definition)),
+	})}
+
+	SentimentSchema = Schema{Name: "sentiment", Definition: objectSchema(map[string]interface{}{
+		"overall": sentimentScoreSchema,
+		"speakers": arraySchema(objectSchema(map[string]interface{}{
+			"speaker":    typeSchema("string"),
+			"label":      typeSchema("string"),
+			"score":      typeSchema("number"),
+			"confidence": typeSchema("number"),
+			"evidence":   arraySchema(typeSchema("string")),
+		})),
+		"trajectory": objectSchema(map[string]interface{}{
+			"start":     sentimentScoreSchema,
+			"middle":    sentimentScoreSchema,
+			"end":       sentimentScoreSchema,
+			"direction": typeSchema("string"),
+		}),
+		"summary": typeSchema("string"),
+	})}
+
+	// SentimentBatchSchema holds the sentiment of several numbered transcripts sent in one prompt
+	SentimentBatchSchema = Schema{Name: "sentiment_batch", Definition: objectSchema(map[string]interface{}{
+		"sentiments": arraySchema(indexedSchema(SentimentSchema.Definition)),
+	})}
+
+	// ResolutionBatchSchema holds the resolution outcome of several numbered transcripts
+	ResolutionBatchSchema = Schema{Name: "resolution_batch", Definition: objectSchema(map[string]interface{}{
+		"outcomes": arraySchema(objectSchema(map[string]interface{}{
+			"index":      typeSchema("integer"),
+			"outcome":    map[string]interface{}{"type": "string", "enum": []interface{}{"resolved", "unresolved", "escalated", "follow_up"}},
+			"confidence": typeSchema("number"),
+		})),
+	})}
+
+	CompareSchema = Schema{Name: "compare", Definition: objectSchema(map[string]interface{}{
+		"trend_differences": arraySchema(objectSchema(map[string]interface{}{
+			"focus_area": typeSchema("string"),
+			"cohort_a":   typeSchema("string"),
+			"cohort_b":   typeSchema("string"),
+			"difference": typeSchema("string"),
+			"confidence": typeSchema("number"),
+		})),
+		"summary": typeSchema("string"),
+	})}
+
+	RecommendationsSchema = Schema{Name: "recommendations", Definition: objectSchema(map[string]interface{}{
+		"immediate_actions":    arraySchema(recommendationSchema),
+		"implementation_notes": arraySchema(typeSchema("string")),
+		"success_metrics":      arraySchema(typeSchema("string")),
+	})}
+
+	RetentionStrategySchema = Schema{Name: "retention_strategy", Definition: objectSchema(map[string]interface{}{
+		"target_segment":    typeSchema("string"),
+		"immediate_actions": arraySchema(recommendationSchema),
+		"process_changes":   arraySchema(typeSchema("string")),
+		"training_needs":    arraySchema(typeSchema("string")),
+		"success_metrics":   arraySchema(typeSchema("string")),
+	})}
+
+	ActionPlanSchema = Schema{Name: "action_plan", Definition: objectSchema(map[string]interface{}{
+		"goals":               arraySchema(typeSchema("string")),
+		"immediate_actions":   arraySchema(actionItemSchema),
+		"short_term_actions":  arraySchema(actionItemSchema),
+		"long_term_actions":   arraySchema(actionItemSchema),
+		"responsible_parties": arraySchema(typeSchema("string")),
+		"timeline": arraySchema(objectSchema(map[string]interface{}{
+			"phase":       typeSchema("string"),
+			"description": typeSchema("string"),
+			"duration":    typeSchema("string"),
+			"milestones":  arraySchema(typeSchema("string")),
+		})),
+		"success_metrics": arraySchema(typeSchema("string")),
+		"risks_mitigations": arraySchema(objectSchema(map[string]interface{}{
+			"risk":              typeSchema("string"),
+			"impact":            typeSchema("string"),
+			"probability":       typeSchema("string"),
+			"mitigation_plan":   typeSchema("string"),
+			"contingency_plan":  typeSchema("string"),
+			"responsible_party": typeSchema("string"),
+		})),
+	})}
+
+	PrioritizedRecommendationsSchema = Schema{Name: "prioritized_recommendations", Definition: objectSchema(map[string]interface{}{
+		"recommendations": arraySchema(objectSchema(map[string]interface{}{
+			"action":          typeSchema("string"),
+			"rationale":       typeSchema("string"),
+			"expected_impact": typeSchema("string"),
+			"priority":        typeSchema("integer"),
+			"explanation":     typeSchema("string"),
+		})),
+	})}
+
+	ImplementationTimelineSchema = Schema{Name: "implementation_timeline", Definition: objectSchema(map[string]interface{}{
+		"timeline": arraySchema(objectSchema(map[string]interface{}{
+			"phase":              typeSchema("string"),
+			"description":        typeSchema("string"),
+			"duration":           typeSchema("string"),
+			"duration_days":      typeSchema("integer"),
+			"milestones":         arraySchema(typeSchema("string")),
+			"dependencies":       arraySchema(typeSchema("string")),
+			"resources_required": arraySchema(typeSchema("string")),
+		})),
+	})}
+
+	FindingsSchema = Schema{Name: "findings", Definition: objectSchema(map[string]interface{}{
+		"findings": arraySchema(objectSchema(map[string]interface{}{
+			"question":            typeSchema("string"),
+			"answer":              typeSchema("string"),
+			"supporting_evidence": arraySchema(typeSchema("string")),
+			"confidence":          typeSchema("number"),
+			"coverage":            typeSchema("string"),
+			"missing_attributes":  arraySchema(typeSchema("string")),
+			"citations": arraySchema(objectSchema(map[string]interface{}{
+				"conversation_id": typeSchema("string"),
+				"quote":           typeSchema("string"),
+			})),
+		})),
+		"recommendations": arraySchema(typeSchema("string")),
+	})}
+
+	TranslationSchema = Schema{Name: "translation", Definition: objectSchema(map[string]interface{}{
+		"source_language": typeSchema("string"),
+		"translated_text": typeSchema("string"),
+	})}
+
+	ExplanationSchema = Schema{Name: "explanation", Definition: objectSchema(map[string]interface{}{
+		"explanation": typeSchema("string"),
+		"reasoning":   arraySchema(typeSchema("string")),
+		"supporting_excerpts": arraySchema(objectSchema(map[string]interface{}{
+			"conversation_id": typeSchema("string"),
+			"excerpt":         typeSchema("string"),
+			"relevance":       typeSchema("string"),
+		})),
+		"caveats":    arraySchema(typeSchema("string")),
+		"confidence": typeSchema("number"),
+	})}
+)
+
+// Shared item schemas
+var (
+	patternSchema = objectSchema(map[string]interface{}{
+		"pattern_type":        typeSchema("string"),
+		"pattern_description": typeSchema("string"),
+		"occurrences":         typeSchema("integer"),
+		"examples":            arraySchema(typeSchema("string")),
+		"significance":        typeSchema("string"),
+	})
+
+	sentimentScoreSchema = objectSchema(map[string]interface{}{
+		"label":      typeSchema("string"),
+		"score":      typeSchema("number"),
+		"confidence": typeSchema("number"),
+	})
+
+	recommendationSchema = objectSchema(map[string]interface{}{
+		"action":          typeSchema("string"),
+		"rationale":       typeSchema("string"),
+		"expected_impact": typeSchema("string"),
+		"priority":        typeSchema("integer"),
+		"impact_estimate": typeSchema("number"),
+		"effort":          map[string]interface{}{"type": "string", "enum": []interface{}{"low", "medium", "high"}},
+		"estimated_cost":  typeSchema("number"),
+		"dependencies":    arraySchema(typeSchema("string")),
+	})
+
+	actionItemSchema = objectSchema(map[string]interface{}{
+		"action":           typeSchema("string"),
+		"description":      typeSchema("string"),
+		"priority":         typeSchema("integer"),
+		"estimated_effort": typeSchema("string"),
+		"dependencies":     arraySchema(typeSchema("string")),
+		"responsible_role": typeSchema("string"),
+	})
+)
+
+// analysisSchemas maps each analysis type to the schema of its primary model output
+var analysisSchemas = map[string]Schema{
+	"trends":          TrendsSchema,
+	"patterns":        PatternsSchema,
+	"findings":        FindingsSchema,
+	"attributes":      AttributeValuesSchema,
+	"intent":          IntentSchema,
+	"sentiment":       SentimentSchema,
+	"compare":         CompareSchema,
+	"recommendations": RecommendationsSchema,
+	"plan":            ActionPlanSchema,
+}
+
+// SchemaForAnalysis returns the output schema declared for an analysis type
+func SchemaForAnalysis(analysisType string) (Schema, bool) {
+	schema, ok := analysisSchemas[analysisType]
+	return schema, ok
+}
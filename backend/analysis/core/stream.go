@@ -0,0 +1,20 @@
+package core
+
+import "context"
+
+// StreamFunc receives partial model output as it is generated
+type StreamFunc func(chunk string)
+
+type streamKey struct{}
+
+// WithStream returns a context that asks LLM clients to stream partial output to fn.
+// fn may be called from multiple goroutines when an analysis issues concurrent requests.
+func WithStream(ctx context.Context, fn StreamFunc) context.Context {
+	return context.WithValue(ctx, streamKey{}, fn)
+}
+
+// streamFromContext returns the stream callback of a context, or nil if none is set
+func streamFromContext(ctx context.Context) StreamFunc {
+	fn, _ := ctx.Value(streamKey{}).(StreamFunc)
+	return fn
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// Usage accumulates the LLM calls and tokens of a request. Token counts are estimated
+// from text length when the model doesn't report them.
+type Usage struct {
+	mu               sync.Mutex
+	parent           *Usage
+	promptTokens     int
+	completionTokens int
+	cachedTokens     int
+	estimated        bool
+	calls            []CallUsage
+
+	// budget is the most the usage may cost, as estimated by cost; 0 for no limit
+	budget float64
+	cost   CostFunc
+}
+
+// CallUsage is the usage of one LLM call
+type CallUsage struct {
+	Model              string
+	PromptTokens       int
+	CompletionTokens   int
+	CachedPromptTokens int
+	Estimated          bool
+}
+
+// UsageTotals is a snapshot of the usage of a request
+type UsageTotals struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// CachedPromptTokens are the prompt tokens the provider served from its prompt cache
+	CachedPromptTokens int  `json:"cached_prompt_tokens,omitempty"`
+	Estimated          bool `json:"estimated,omitempty"`
+}
+
+type usageKey struct{}
+
+// WithUsage returns a context that counts the LLM usage of calls made with it.
+// Usage tracked by an enclosing context is counted there as well.
+func WithUsage(ctx context.Context) (context.Context, *Usage) {
+	usage := &Usage{parent: usageFromContext(ctx)}
+	return context.WithValue(ctx, usageKey{}, usage), usage
+}
+
+// usageFromContext returns the usage tracked by a context, or nil if none is set
+func usageFromContext(ctx context.Context) *Usage {
+	usage, _ := ctx.Value(usageKey{}).(*Usage)
+	return usage
+}
+
+// add counts one LLM call
+func (u *Usage) add(call CallUsage) {
+	for ; u != nil; u = u.parent {
+		u.mu.Lock()
+		u.calls = append(u.calls, call)
+		u.promptTokens += call.PromptTokens
+		u.completionTokens += call.CompletionTokens
+		u.cachedTokens += call.CachedPromptTokens
+		u.estimated = u.estimated || call.Estimated
+		u.mu.Unlock()
+	}
+}
+
+// Calls returns the LLM calls counted so far, in the order they completed
+func (u *Usage) Calls() []CallUsage {
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]CallUsage(nil), u.calls...)
+}
+
+// Totals returns the usage counted so far, or zero usage for a nil Usage
+func (u *Usage) Totals() UsageTotals {
+	if u == nil {
+		return UsageTotals{}
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.totals()
+}
+
+// totals returns the usage counted so far; u.mu must be held
+func (u *Usage) totals() UsageTotals {
+	return UsageTotals{
+		Calls:              len(u.calls),
+		PromptTokens:       u.promptTokens,
+		CompletionTokens:   u.completionTokens,
+		CachedPromptTokens: u.cachedTokens,
+		Estimated:          u.estimated,
+	}
+}
+
+// recordUsage counts a call to model in the usage tracked by ctx. Missing token counts
+// are estimated at four characters per token.
+func recordUsage(ctx context.Context, model, prompt, reply string, reported *tokenUsage) {
+	usage := usageFromContext(ctx)
+	if usage == nil {
+		return
+	}
+	if reported != nil && reported.PromptTokens+reported.CompletionTokens > 0 {
+		usage.add(CallUsage{
+			Model:              model,
+			PromptTokens:       reported.PromptTokens,
+			CompletionTokens:   reported.CompletionTokens,
+			CachedPromptTokens: reported.cachedTokens(),
+		})
+		return
+	}
+	usage.add(CallUsage{
+		Model:            model,
+		PromptTokens:     estimateTokens(prompt),
+		CompletionTokens: estimateTokens(reply),
+		Estimated:        true,
+	})
+}
+
+// estimateTokens approximates the number of tokens in a text
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidateSchema checks a decoded JSON value against a schema definition.
+// It supports the subset of JSON Schema used by the analysis schemas:
+// type, properties, required, additionalProperties, items and enum.
+func ValidateSchema(definition map[string]interface{}, value interface{}) error {
+	return validateValue("$", definition, value)
+}
+
+// validateValue validates value at path against definition
+func validateValue(path string, definition map[string]interface{}, value interface{}) error {
+	if enum, ok := definition["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+	}
+
+	switch definition["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %s", path, jsonTypeName(value))
+		}
+		return validateObject(path, definition, object)
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %s", path, jsonTypeName(value))
+		}
+		items, _ := definition["items"].(map[string]interface{})
+		if items == nil {
+			return nil
+		}
+		for i, item := range array {
+			if err := validateValue(fmt.Sprintf("%s[%d]", path, i), items, item); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string, got %s", path, jsonTypeName(value))
+		}
+	case "number":
+		if _, ok := toFloat(value); !ok {
+			return fmt.Errorf("%s: expected number, got %s", path, jsonTypeName(value))
+		}
+	case "integer":
+		number, ok := toFloat(value)
+		if !ok || number != math.Trunc(number) {
+			return fmt.Errorf("%s: expected integer, got %s", path, jsonTypeName(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %s", path, jsonTypeName(value))
+		}
+	}
+	return nil
+}
+
+// validateObject checks required, declared and undeclared properties of an object
+func validateObject(path string, definition map[string]interface{}, object map[string]interface{}) error {
+	properties, _ := definition["properties"].(map[string]interface{})
+
+	var missing []string
+	for _, name := range requiredProperties(definition) {
+		if _, ok := object[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%s: missing required properties %s", path, strings.Join(missing, ", "))
+	}
+
+	if additional, ok := definition["additionalProperties"].(bool); ok && !additional {
+		var unexpected []string
+		for name := range object {
+			if _, declared := properties[name]; !declared {
+				unexpected = append(unexpected, name)
+			}
+		}
+		if len(unexpected) > 0 {
+			sort.Strings(unexpected)
+			return fmt.Errorf("%s: unexpected properties %s", path, strings.Join(unexpected, ", "))
+		}
+	}
+
+	// Check properties in a stable order so errors are reproducible
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		propertyValue, present := object[name]
+		propertySchema, ok := properties[name].(map[string]interface{})
+		if !present || !ok {
+			continue
+		}
+		if err := validateValue(path+"."+name, propertySchema, propertyValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requiredProperties returns the required property names of an object schema
+func requiredProperties(definition map[string]interface{}) []string {
+	switch required := definition["required"].(type) {
+	case []string:
+		return required
+	case []interface{}:
+		names := make([]string, 0, len(required))
+		for _, name := range required {
+			if s, ok := name.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// containsValue reports whether value is one of the allowed enum values
+func containsValue(allowed []interface{}, value interface{}) bool {
+	for _, candidate := range allowed {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName describes the JSON type of a decoded value for error messages
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if _, ok := toFloat(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
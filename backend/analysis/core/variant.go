@@ -0,0 +1,42 @@
+package core
+
+import "context"
+
+// Variant changes how the model is called for a request, so a new model or prompt
+// version can be tried on part of the traffic
+type Variant struct {
+	// Model replaces the configured model when set
+	Model string
+	// Instructions are appended to every prompt when set
+	Instructions string
+}
+
+type variantKey struct{}
+
+// WithVariant returns a context whose LLM calls use variant
+func WithVariant(ctx context.Context, variant Variant) context.Context {
+	return context.WithValue(ctx, variantKey{}, variant)
+}
+
+// variantFromContext returns the variant of a context, or the zero variant if none is set
+func variantFromContext(ctx context.Context) Variant {
+	variant, _ := ctx.Value(variantKey{}).(Variant)
+	return variant
+}
+
+// model returns the model a request is sent to
+func (c *LLMClient) model(ctx context.Context) string {
+	if model := variantFromContext(ctx).Model; model != "" {
+		return model
+	}
+	return c.modelName
+}
+
+// withInstructions appends the instructions of the context's variant to a prompt
+func withInstructions(ctx context.Context, prompt string) string {
+	instructions := variantFromContext(ctx).Instructions
+	if instructions == "" {
+		return prompt
+	}
+	return prompt + "\n\nAdditional instructions:\n" + instructions
+}
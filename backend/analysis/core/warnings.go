@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// Warning codes
+const (
+	// WarningInputTruncated is reported when text was cut to fit a prompt, so the analysis
+	// did not see all of it
+	WarningInputTruncated = "input_truncated"
+)
+
+// Warning notes an analysis that completed with reduced fidelity
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Warnings collects the warnings of a request. The same warning is kept once, however
+// often it is reported.
+type Warnings struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+type warningsKey struct{}
+
+// WithWarnings returns a context that collects the warnings of analyses run with it
+func WithWarnings(ctx context.Context) (context.Context, *Warnings) {
+	warnings := &Warnings{}
+	return context.WithValue(ctx, warningsKey{}, warnings), warnings
+}
+
+// Warn reports a warning to the warnings collected by ctx, if any
+func Warn(ctx context.Context, code, message string) {
+	if warnings, _ := ctx.Value(warningsKey{}).(*Warnings); warnings != nil {
+		warnings.Add(Warning{Code: code, Message: message})
+	}
+}
+
+// Add collects warnings not collected yet
+func (w *Warnings) Add(warnings ...Warning) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, warning := range warnings {
+		if !w.has(warning) {
+			w.warnings = append(w.warnings, warning)
+		}
+	}
+}
+
+// List returns the warnings collected so far, in the order they were first reported
+func (w *Warnings) List() []Warning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Warning(nil), w.warnings...)
+}
+
+// has reports whether a warning was collected. The caller holds w.mu.
+func (w *Warnings) has(warning Warning) bool {
+	for _, collected := range w.warnings {
+		if collected == warning {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+// Package dedup finds duplicate and near-duplicate conversations with word shingling and
+// MinHash, so effectively identical transcripts are analyzed once
+package dedup
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Defaults of Options
+const (
+	DefaultThreshold   = 0.85
+	DefaultShingleSize = 5
+)
+
+// signatureSize is the number of MinHash functions; it is split into lshBands bands of
+// lshRows rows each to find candidate pairs
+const (
+	signatureSize = 128
+	lshBands      = 32
+	lshRows       = signatureSize / lshBands
+)
+
+// Document is a text identified by ID
+type Document struct {
+	ID   string
+	Text string
+}
+
+// Options control how similar two documents must be to count as duplicates
+type Options struct {
+	// Threshold is the Jaccard similarity of word shingles, from 0 to 1, at which two
+	// documents are duplicates
+	Threshold float64
+	// ShingleSize is the number of consecutive words per shingle
+	ShingleSize int
+}
+
+// Cluster is a group of duplicate documents. The first document of the input is kept as
+// its representative; the others are its duplicates.
+type Cluster struct {
+	Representative string   `json:"representative"`
+	Duplicates     []string `json:"duplicates"`
+	// Similarity is the lowest similarity of a duplicate to the representative
+	Similarity float64 `json:"similarity"`
+}
+
+// Result reports the duplicates among a set of documents
+type Result struct {
+	Documents  int       `json:"documents"`
+	Unique     int       `json:"unique"`     // Documents left after dropping duplicates
+	Duplicates int       `json:"duplicates"` // Documents that duplicate another
+	Threshold  float64   `json:"threshold"`
+	Clusters   []Cluster `json:"clusters"`
+}
+
+// Find groups documents whose shingle similarity reaches the threshold. Candidate pairs
+// come from locality-sensitive hashing of MinHash signatures and are confirmed with the
+// exact Jaccard similarity, so near-duplicates are found without comparing every pair.
+func Find(documents []Document, options Options) Result {
+	if options.Threshold <= 0 || options.Threshold > 1 {
+		options.Threshold = DefaultThreshold
+	}
+	if options.ShingleSize <= 0 {
+		options.ShingleSize = DefaultShingleSize
+	}
+
+	shingles := make([]map[uint64]bool, len(documents))
+	signatures := make([][]uint64, len(documents))
+	for i, document := range documents {
+		shingles[i] = shingleSet(document.Text, options.ShingleSize)
+		signatures[i] = minHash(shingles[i])
+	}
+
+	// Documents sharing a band of their signature are candidates
+	parent := make([]int, len(documents))
+	for i := range parent {
+		parent[i] = i
+	}
+	similarity := map[[2]int]float64{}
+	for band := 0; band < lshBands; band++ {
+		buckets := map[uint64][]int{}
+		for i, signature := range signatures {
+			if len(shingles[i]) == 0 {
+				continue
+			}
+			key := bandHash(signature[band*lshRows : (band+1)*lshRows])
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, bucket := range buckets {
+			for a := 0; a < len(bucket); a++ {
+				for b := a + 1; b < len(bucket); b++ {
+					pair := [2]int{bucket[a], bucket[b]}
+					if _, seen := similarity[pair]; seen {
+						continue
+					}
+					similarity[pair] = jaccard(shingles[pair[0]], shingles[pair[1]])
+					if similarity[pair] >= options.Threshold {
+						union(parent, pair[0], pair[1])
+					}
+				}
+			}
+		}
+	}
+
+	// Group documents by cluster, in input order
+	members := map[int][]int{}
+	var roots []int
+	for i := range documents {
+		root := find(parent, i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+
+	result := Result{Documents: len(documents), Threshold: options.Threshold, Clusters: []Cluster{}}
+	for _, root := range roots {
+		group := members[root]
+		result.Unique++
+		if len(group) < 2 {
+			continue
+		}
+		representative := group[0]
+		cluster := Cluster{Representative: documents[representative].ID, Similarity: 1}
+		for _, i := range group[1:] {
+			cluster.Duplicates = append(cluster.Duplicates, documents[i].ID)
+			s, ok := similarity[[2]int{representative, i}]
+			if !ok {
+				s = jaccard(shingles[representative], shingles[i])
+			}
+			cluster.Similarity = math.Min(cluster.Similarity, s)
+		}
+		cluster.Similarity = math.Round(cluster.Similarity*1000) / 1000
+		result.Duplicates += len(cluster.Duplicates)
+		result.Clusters = append(result.Clusters, cluster)
+	}
+
+	sort.SliceStable(result.Clusters, func(i, j int) bool {
+		return len(result.Clusters[i].Duplicates) > len(result.Clusters[j].Duplicates)
+	})
+	return result
+}
+
+// DuplicateIDs returns the IDs of the documents that duplicate a representative
+func (r Result) DuplicateIDs() map[string]bool {
+	ids := map[string]bool{}
+	for _, cluster := range r.Clusters {
+		for _, id := range cluster.Duplicates {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// shingleSet hashes the runs of size consecutive words of a text, ignoring case and
+// punctuation. Texts shorter than size words form a single shingle.
+func shingleSet(text string, size int) map[uint64]bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	set := map[uint64]bool{}
+	if len(words) == 0 {
+		return set
+	}
+	if len(words) < size {
+		size = len(words)
+	}
+	for i := 0; i+size <= len(words); i++ {
+		set[hashString(strings.Join(words[i:i+size], " "))] = true
+	}
+	return set
+}
+
+// minHash computes the MinHash signature of a shingle set, deriving the hash functions
+// from the shingle hashes with fixed odd multipliers
+func minHash(set map[uint64]bool) []uint64 {
+	signature := make([]uint64, signatureSize)
+	for i := range signature {
+		signature[i] = math.MaxUint64
+	}
+	for shingle := range set {
+		for i := range signature {
+			h := mix(shingle ^ (uint64(i+1) * 0x9e3779b97f4a7c15))
+			if h < signature[i] {
+				signature[i] = h
+			}
+		}
+	}
+	return signature
+}
+
+// jaccard returns the Jaccard similarity of two shingle sets
+func jaccard(a, b map[uint64]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for shingle := range a {
+		if b[shingle] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// bandHash hashes one band of a signature
+func bandHash(rows []uint64) uint64 {
+	h := uint64(14695981039346656037)
+	for _, row := range rows {
+		h = mix(h ^ row)
+	}
+	return h
+}
+
+// hashString hashes a shingle
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix is the splitmix64 finalizer
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// find returns the root of a document's cluster
+func find(parent []int, i int) int {
+	for parent[i] != i {
+		parent[i] = parent[parent[i]]
+		i = parent[i]
+	}
+	return i
+}
+
+// union merges the clusters of two documents, keeping the earlier document as root so
+// clusters are represented by their first document
+func union(parent []int, a, b int) {
+	ra, rb := find(parent, a), find(parent, b)
+	if ra == rb {
+		return
+	}
+	if rb < ra {
+		ra, rb = rb, ra
+	}
+	parent[rb] = ra
+}
@@ -0,0 +1,220 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// diffSection describes how the items of a section of a result are compared. Items are
+// matched by their key fields; matched items whose compared fields differ are changed.
+// Sections without key fields are lists of strings, which are only added or removed.
+type diffSection struct {
+	name    string
+	key     []string
+	compare []string
+}
+
+// diffSections are the sections compared per analysis type. Descriptive fields such as
+// supporting data and examples are reworded by every run and are not compared.
+var diffSections = map[string][]diffSection{
+	"trends": {
+		{name: "trends", key: []string{"focus_area"}, compare: []string{"trend", "confidence"}},
+		{name: "overall_insights"},
+	},
+	"patterns": {
+		{name: "patterns", key: []string{"pattern_type", "pattern_description"}, compare: []string{"occurrences", "significance", "intents"}},
+		{name: "unexpected_patterns", key: []string{"description"}, compare: []string{"potential_causes"}},
+	},
+	"recommendations": {
+		{name: "immediate_actions", key: []string{"action"}, compare: []string{"priority", "expected_impact", "impact_estimate", "effort", "estimated_cost"}},
+		{name: "implementation_notes"},
+		{name: "success_metrics"},
+	},
+	"findings": {
+		{name: "findings", key: []string{"question"}, compare: []string{"answer", "confidence", "coverage"}},
+		{name: "recommendations"},
+	},
+}
+
+// ResultDiff is the difference between two stored results of the same analysis type
+type ResultDiff struct {
+	Sections []SectionDiff `json:"sections"`
+	Added    int           `json:"added"`
+	Removed  int           `json:"removed"`
+	Changed  int           `json:"changed"`
+}
+
+// SectionDiff lists the items of a section that were added, removed or changed
+type SectionDiff struct {
+	Section string        `json:"section"`
+	Added   []interface{} `json:"added"`
+	Removed []interface{} `json:"removed"`
+	Changed []ItemChange  `json:"changed"`
+}
+
+// ItemChange is an item found in both results whose compared fields differ
+type ItemChange struct {
+	Key    string        `json:"key"`
+	Fields []FieldChange `json:"fields"`
+	From   interface{}   `json:"from"`
+	To     interface{}   `json:"to"`
+}
+
+// FieldChange is a changed field of an item. Delta is set for numbers.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+	Delta *float64    `json:"delta,omitempty"`
+}
+
+// DiffableTypes returns the analysis types whose results can be diffed
+func DiffableTypes() []string {
+	types := make([]string, 0, len(diffSections))
+	for analysisType := range diffSections {
+		types = append(types, analysisType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// DiffResults compares two decoded results of an analysis type, from an earlier run to a
+// later one. Items are matched by key ignoring case, whitespace and punctuation.
+func DiffResults(analysisType string, from, to map[string]interface{}) (*ResultDiff, error) {
+	sections, ok := diffSections[analysisType]
+	if !ok {
+		return nil, fmt.Errorf("results of type %s can't be diffed; supported types are %s",
+			analysisType, strings.Join(DiffableTypes(), ", "))
+	}
+
+	diff := &ResultDiff{Sections: make([]SectionDiff, 0, len(sections))}
+	for _, section := range sections {
+		sectionDiff := diffSectionItems(section, listValue(from[section.name]), listValue(to[section.name]))
+		diff.Added += len(sectionDiff.Added)
+		diff.Removed += len(sectionDiff.Removed)
+		diff.Changed += len(sectionDiff.Changed)
+		diff.Sections = append(diff.Sections, sectionDiff)
+	}
+	return diff, nil
+}
+
+// diffSectionItems compares the items of a section, keeping the order of the later result
+// for added and changed items and of the earlier one for removed items
+func diffSectionItems(section diffSection, from, to []interface{}) SectionDiff {
+	diff := SectionDiff{
+		Section: section.name,
+		Added:   []interface{}{},
+		Removed: []interface{}{},
+		Changed: []ItemChange{},
+	}
+
+	previous := make(map[string]interface{}, len(from))
+	for _, item := range from {
+		if key := section.itemKey(item); key != "" {
+			if _, seen := previous[key]; !seen {
+				previous[key] = item
+			}
+		}
+	}
+
+	matched := make(map[string]bool, len(to))
+	for _, item := range to {
+		key := section.itemKey(item)
+		if key == "" || matched[key] {
+			continue
+		}
+		matched[key] = true
+		before, ok := previous[key]
+		if !ok {
+			diff.Added = append(diff.Added, item)
+			continue
+		}
+		if fields := section.changedFields(before, item); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, ItemChange{Key: section.displayKey(item), Fields: fields, From: before, To: item})
+		}
+	}
+
+	for _, item := range from {
+		key := section.itemKey(item)
+		if key != "" && !matched[key] {
+			diff.Removed = append(diff.Removed, item)
+			matched[key] = true
+		}
+	}
+	return diff
+}
+
+// itemKey returns the normalized key of an item, or "" for an item without one
+func (s diffSection) itemKey(item interface{}) string {
+	if len(s.key) == 0 {
+		text, _ := item.(string)
+		return normalizeDiffKey(text)
+	}
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	parts := make([]string, 0, len(s.key))
+	for _, field := range s.key {
+		text, _ := fields[field].(string)
+		parts = append(parts, normalizeDiffKey(text))
+	}
+	key := strings.Join(parts, "|")
+	if strings.Trim(key, "|") == "" {
+		return ""
+	}
+	return key
+}
+
+// displayKey returns the key fields of an item as they were written
+func (s diffSection) displayKey(item interface{}) string {
+	fields, _ := item.(map[string]interface{})
+	parts := make([]string, 0, len(s.key))
+	for _, field := range s.key {
+		if text, _ := fields[field].(string); strings.TrimSpace(text) != "" {
+			parts = append(parts, strings.TrimSpace(text))
+		}
+	}
+	return strings.Join(parts, ": ")
+}
+
+// changedFields returns the compared fields that differ between two matched items
+func (s diffSection) changedFields(from, to interface{}) []FieldChange {
+	before, _ := from.(map[string]interface{})
+	after, _ := to.(map[string]interface{})
+	var changes []FieldChange
+	for _, field := range s.compare {
+		oldValue, newValue := before[field], after[field]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		change := FieldChange{Field: field, From: oldValue, To: newValue}
+		oldNumber, oldOK := oldValue.(float64)
+		newNumber, newOK := newValue.(float64)
+		if oldOK && newOK {
+			// Round away the floating point noise of subtracting decimals
+			delta := math.Round((newNumber-oldNumber)*1e6) / 1e6
+			change.Delta = &delta
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// normalizeDiffKey lowercases text and drops punctuation and repeated whitespace
+func normalizeDiffKey(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// listValue returns a decoded JSON array, or nil for anything else
+func listValue(value interface{}) []interface{} {
+	list, _ := value.([]interface{})
+	return list
+}
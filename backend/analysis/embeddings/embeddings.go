@@ -0,0 +1,178 @@
+// Package embeddings turns conversations and intent labels into vectors whose cosine
+// similarity reflects how close their meaning is, for semantic search and grouping
+package embeddings
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/demo"
+)
+
+// Embedding environment variables
+const (
+	EnvEmbeddingProvider = "EMBEDDING_PROVIDER"
+	EnvEmbeddingModel    = "EMBEDDING_MODEL"
+)
+
+// Embedding providers
+const (
+	// ProviderAuto uses the LLM endpoint when one is configured and the local model otherwise
+	ProviderAuto = "auto"
+	// ProviderLLM calls the embeddings endpoint of the OpenAI-compatible LLM endpoint
+	ProviderLLM = "llm"
+	// ProviderLocal hashes words into vectors without calling a model
+	ProviderLocal = "local"
+)
+
+// DefaultModel is the embedding model requested from the LLM endpoint
+const DefaultModel = "text-embedding-3-small"
+
+// Embedder turns texts into vectors
+type Embedder interface {
+	// Model identifies the vectors of the embedder; only vectors of the same model can be
+	// compared
+	Model() string
+	// Embed returns one vector per text
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Config selects the embedding provider
+type Config struct {
+	Provider string
+	Model    string
+}
+
+// ConfigFromEnv reads the embedding settings from the environment
+func ConfigFromEnv() Config {
+	config := Config{
+		Provider: strings.ToLower(strings.TrimSpace(os.Getenv(EnvEmbeddingProvider))),
+		Model:    strings.TrimSpace(os.Getenv(EnvEmbeddingModel)),
+	}
+	if config.Provider == "" {
+		config.Provider = ProviderAuto
+	}
+	if config.Model == "" {
+		config.Model = DefaultModel
+	}
+	return config
+}
+
+// NewEmbedder creates the embedder of a configuration. apiKey authenticates with the LLM
+// endpoint unless LLM_API_KEY overrides it. Demo mode always embeds locally, so no text is
+// sent to an external service.
+func NewEmbedder(config Config, apiKey string) (Embedder, error) {
+	provider := config.Provider
+	if demo.Enabled() {
+		provider = ProviderLocal
+	}
+	switch provider {
+	case ProviderLocal:
+		return NewLocalEmbedder(DefaultLocalDimensions), nil
+	case ProviderAuto:
+		if !core.GatewayConfigured() {
+			return NewLocalEmbedder(DefaultLocalDimensions), nil
+		}
+		fallthrough
+	case ProviderLLM:
+		llm := core.LLMConfigFromEnv(apiKey)
+		if llm.BaseURL == "" {
+			return nil, fmt.Errorf("the %s embedding provider requires %s", ProviderLLM, core.EnvLLMBaseURL)
+		}
+		return NewOpenAIEmbedder(llm.BaseURL, llm.APIKey, config.Model, llm.Headers), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", config.Provider)
+	}
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 when their dimensions differ
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// Match is a stored item similar to a query
+type Match struct {
+	ID         string  `json:"id"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Nearest returns the items most similar to query, most similar first: at most limit
+// items with a similarity of at least minSimilarity
+func Nearest(query []float32, vectors map[string][]float32, limit int, minSimilarity float64) []Match {
+	matches := []Match{}
+	for id, vector := range vectors {
+		similarity := Cosine(query, vector)
+		if similarity >= minSimilarity {
+			matches = append(matches, Match{ID: id, Similarity: math.Round(similarity*1000) / 1000})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Group clusters labels whose vectors reach threshold similarity to the first label of a
+// group, in the order of labels, so frequent labels given first name their groups
+func Group(labels []string, vectors map[string][]float32, threshold float64) [][]string {
+	var groups [][]string
+	for _, label := range labels {
+		placed := false
+		for i, group := range groups {
+			if Cosine(vectors[group[0]], vectors[label]) >= threshold {
+				groups[i] = append(groups[i], label)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			groups = append(groups, []string{label})
+		}
+	}
+	return groups
+}
+
+// Encode packs a vector into little-endian float32 bytes for storage
+func Encode(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return data
+}
+
+// Decode unpacks a vector packed by Encode
+func Decode(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("vector of %d bytes is not a float32 array", len(data))
+	}
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector, nil
+}
@@ -0,0 +1,77 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultLocalDimensions is the size of the vectors of the local model
+const DefaultLocalDimensions = 512
+
+// LocalEmbedder hashes the words and word pairs of a text into a fixed number of
+// dimensions, weighting each by the logarithm of its count. It needs no model and
+// matches texts that share vocabulary, not synonyms.
+type LocalEmbedder struct {
+	dimensions int
+}
+
+// NewLocalEmbedder creates a local embedder of vectors with the given dimensions
+func NewLocalEmbedder(dimensions int) *LocalEmbedder {
+	return &LocalEmbedder{dimensions: dimensions}
+}
+
+// Model identifies the vectors of the local embedder by its dimensions
+func (e *LocalEmbedder) Model() string {
+	return fmt.Sprintf("%s-hash-%d", ProviderLocal, e.dimensions)
+}
+
+// Embed hashes each text into a normalized vector
+func (e *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+// embed hashes one text. Each feature adds to one dimension with a sign taken from its
+// hash, so collisions cancel out rather than accumulate.
+func (e *LocalEmbedder) embed(text string) []float32 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	counts := map[string]int{}
+	for i, word := range words {
+		counts[word]++
+		if i > 0 {
+			counts[words[i-1]+" "+word]++
+		}
+	}
+
+	vector := make([]float32, e.dimensions)
+	var norm float64
+	for feature, count := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		weight := 1 + math.Log(float64(count))
+		if sum&(1<<63) != 0 {
+			weight = -weight
+		}
+		vector[sum%uint64(e.dimensions)] += float32(weight)
+	}
+	for _, value := range vector {
+		norm += float64(value) * float64(value)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
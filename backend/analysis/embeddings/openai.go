@@ -0,0 +1,114 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxEmbeddingBatch is the most texts sent in one embeddings request
+const maxEmbeddingBatch = 64
+
+// OpenAIEmbedder calls the embeddings endpoint of an OpenAI-compatible API, such as the
+// gateway the analyses use
+type OpenAIEmbedder struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// embeddingsResponse is the body of an embeddings response
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// NewOpenAIEmbedder creates an embedder for the API at baseURL
+func NewOpenAIEmbedder(baseURL, apiKey, model string, headers map[string]string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		headers:    headers,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Model identifies the vectors by the provider and model name
+func (e *OpenAIEmbedder) Model() string {
+	return ProviderLLM + ":" + e.model
+}
+
+// Embed sends the texts in batches and returns their vectors in order
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxEmbeddingBatch {
+		batch := texts[start:min(start+maxEmbeddingBatch, len(texts))]
+		embedded, err := e.embedBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, embedded...)
+	}
+	return vectors, nil
+}
+
+// embedBatch sends one embeddings request
+func (e *OpenAIEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result embeddingsResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("response holds %d embeddings for %d texts", len(result.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("response holds an embedding for unknown input %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
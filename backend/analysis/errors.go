@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+)
+
+// Sentinel errors wrapped by analysis failures. Branch on them with errors.Is:
+//
+//	if errors.Is(err, analysis.ErrProviderTimeout) {
+//		// retry later
+//	}
+var (
+	// ErrInvalidAnalysisType is returned for analysis types that do not exist
+	ErrInvalidAnalysisType = core.ErrInvalidAnalysisType
+	// ErrSchemaValidation is returned when model output does not have the expected
+	// structure, also after repair attempts; errors.As with *core.SchemaValidationError
+	// gives the last invalid reply
+	ErrSchemaValidation = core.ErrSchemaValidation
+	// ErrProviderTimeout is returned when the LLM provider does not answer in time
+	ErrProviderTimeout = core.ErrProviderTimeout
+	// ErrProviderUnavailable is returned when the LLM provider cannot be reached, is
+	// overloaded or rate limits the request
+	ErrProviderUnavailable = core.ErrProviderUnavailable
+	// ErrDataTooLarge is returned when the input exceeds what the request or the model accepts
+	ErrDataTooLarge = core.ErrDataTooLarge
+	// ErrBudgetExceeded is returned when the usage of a request has reached its budget
+	ErrBudgetExceeded = core.ErrBudgetExceeded
+)
+
+// Error codes of failed analyses and items, as reported in AnalysisError.Code
+const (
+	CodeInvalidRequest      = "invalid_request"
+	CodeInvalidAnalysisType = "invalid_analysis_type"
+	CodeInvalidModelOutput  = "invalid_model_output"
+	CodeProviderTimeout     = "provider_timeout"
+	CodeProviderUnavailable = "provider_unavailable"
+	CodeDataTooLarge        = "data_too_large"
+	CodeBudgetExceeded      = "budget_exceeded"
+	CodeCanceled            = "canceled"
+	CodeAnalysisError       = "analysis_error"
+)
+
+// retryableCodes are the error codes of failures that may not recur when the same request
+// is sent again: the provider was slow or unavailable, the model answered off-schema, or
+// the run stopped before the item was reached
+var retryableCodes = map[string]bool{
+	CodeInvalidModelOutput:  true,
+	CodeProviderTimeout:     true,
+	CodeProviderUnavailable: true,
+	CodeCanceled:            true,
+}
+
+// ErrorCode returns the error code of an analysis failure. Errors carrying a code, such
+// as those of ResponseError, keep it; others are classified by the sentinel errors they
+// wrap, and the rest are analysis_error.
+func ErrorCode(err error) string {
+	var coded *CodedError
+	switch {
+	case errors.As(err, &coded):
+		return coded.Code
+	case errors.Is(err, ErrSchemaValidation):
+		return CodeInvalidModelOutput
+	case errors.Is(err, ErrProviderTimeout):
+		return CodeProviderTimeout
+	case errors.Is(err, ErrProviderUnavailable):
+		return CodeProviderUnavailable
+	case errors.Is(err, ErrDataTooLarge):
+		return CodeDataTooLarge
+	case errors.Is(err, ErrInvalidAnalysisType):
+		return CodeInvalidAnalysisType
+	case errors.Is(err, ErrBudgetExceeded):
+		return CodeBudgetExceeded
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CodeCanceled
+	}
+	return CodeAnalysisError
+}
+
+// Retryable reports whether failures with an error code may succeed when retried unchanged
+func Retryable(code string) bool {
+	return retryableCodes[code]
+}
+
+// CodedError is an analysis failure with its error code, such as the error of an
+// analysis response
+type CodedError struct {
+	Code    string
+	Message string
+}
+
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+// ResponseError returns the error of an analysis response as an error that keeps its code
+func ResponseError(e *models.AnalysisError) error {
+	return &CodedError{Code: e.Code, Message: e.Message}
+}
+
+// ItemError describes the failure of one item of a batch or bulk analysis. The item is
+// identified by its position in the data and by id, when it has one.
+func ItemError(index int, id, code, message string) models.AnalysisError {
+	return models.AnalysisError{
+		Code:      code,
+		Message:   message,
+		Retryable: Retryable(code),
+		ItemIndex: &index,
+		ItemID:    id,
+	}
+}
@@ -0,0 +1,131 @@
+// Package eval measures the quality of analyses against golden datasets: conversations
+// with the intent and attribute values they are expected to produce. Reports of two
+// runs can be compared, so prompt and model changes are checked for regressions before
+// they are deployed.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// Dataset is a golden dataset: conversations with their expected analysis results
+type Dataset struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Attributes are extracted from every case that expects attribute values
+	Attributes []models.AttributeDefinition `json:"attributes,omitempty"`
+	Cases      []Case                       `json:"cases"`
+}
+
+// Case is one conversation of a dataset with its expected results. A case without an
+// expected intent is not classified, and one without expected attributes not extracted.
+type Case struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+	// ExpectedIntent is the expected intent label; AcceptedIntents are other labels that
+	// count as correct
+	ExpectedIntent  string   `json:"expected_intent,omitempty"`
+	AcceptedIntents []string `json:"accepted_intents,omitempty"`
+	// ExpectedAttributes are the expected values by field name
+	ExpectedAttributes map[string]string `json:"expected_attributes,omitempty"`
+}
+
+// Validate checks that a dataset has cases with unique IDs and text, and that the
+// attributes they expect are defined
+func (d *Dataset) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("dataset has no name")
+	}
+	if len(d.Cases) == 0 {
+		return fmt.Errorf("dataset %s has no cases", d.Name)
+	}
+	defined := make(map[string]bool, len(d.Attributes))
+	for _, attribute := range d.Attributes {
+		if attribute.FieldName == "" {
+			return fmt.Errorf("dataset %s defines an attribute without a field_name", d.Name)
+		}
+		defined[attribute.FieldName] = true
+	}
+	seen := make(map[string]bool, len(d.Cases))
+	for i, c := range d.Cases {
+		if c.ID == "" {
+			return fmt.Errorf("case %d of dataset %s has no id", i+1, d.Name)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("dataset %s has several cases with id %s", d.Name, c.ID)
+		}
+		seen[c.ID] = true
+		if strings.TrimSpace(c.Text) == "" {
+			return fmt.Errorf("case %s of dataset %s has no text", c.ID, d.Name)
+		}
+		if c.ExpectedIntent == "" && len(c.ExpectedAttributes) == 0 {
+			return fmt.Errorf("case %s of dataset %s expects no intent or attributes", c.ID, d.Name)
+		}
+		for field := range c.ExpectedAttributes {
+			if !defined[field] {
+				return fmt.Errorf("case %s of dataset %s expects attribute %s, which the dataset does not define", c.ID, d.Name, field)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadDataset reads and validates a dataset from a JSON file
+func LoadDataset(path string) (*Dataset, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var dataset Dataset
+	if err := json.Unmarshal(content, &dataset); err != nil {
+		return nil, fmt.Errorf("invalid dataset %s: %w", path, err)
+	}
+	if dataset.Name == "" {
+		dataset.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := dataset.Validate(); err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// LoadDatasets reads the dataset of a JSON file, or those of the JSON files of a
+// directory in name order
+func LoadDatasets(path string) ([]*Dataset, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		dataset, err := LoadDataset(path)
+		if err != nil {
+			return nil, err
+		}
+		return []*Dataset{dataset}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no datasets in %s", path)
+	}
+	sort.Strings(files)
+	datasets := make([]*Dataset, 0, len(files))
+	for _, file := range files {
+		dataset, err := LoadDataset(file)
+		if err != nil {
+			return nil, err
+		}
+		datasets = append(datasets, dataset)
+	}
+	return datasets, nil
+}
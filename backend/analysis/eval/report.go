@@ -0,0 +1,234 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// maxMismatches bounds the mismatches listed in a report
+const maxMismatches = 100
+
+// Report measures the results of a dataset against the expected ones
+type Report struct {
+	Dataset   string    `json:"dataset"`
+	Model     string    `json:"model,omitempty"` // The model under evaluation; empty for the configured one
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+	Cases     int       `json:"cases"`
+	Runs      int       `json:"runs"`
+
+	Intent     *TaskMetrics   `json:"intent,omitempty"`
+	Attributes *TaskMetrics   `json:"attributes,omitempty"`
+	Fields     []FieldMetrics `json:"fields,omitempty"`
+
+	// SchemaValidity is the share of answered model calls whose output passed schema
+	// validation, after repairs
+	SchemaValidity float64 `json:"schema_validity"`
+	Calls          int     `json:"calls"`
+	InvalidOutputs int     `json:"invalid_outputs"`
+	Errors         int     `json:"errors"` // Calls that failed for other reasons, e.g. timeouts
+
+	// Mismatches are the results that differ from the expected ones, the first
+	// maxMismatches of them
+	Mismatches []Mismatch `json:"mismatches,omitempty"`
+}
+
+// TaskMetrics measures one task, intent classification or attribute extraction
+type TaskMetrics struct {
+	Cases int `json:"cases"`
+	// Accuracy is the share of results, over all runs, that match the expected ones
+	Accuracy float64 `json:"accuracy"`
+	// Agreement is the share of results of repeated runs that match the first run of the
+	// case; it is only measured with several runs
+	Agreement *float64 `json:"agreement,omitempty"`
+}
+
+// FieldMetrics measures the extraction of one attribute
+type FieldMetrics struct {
+	FieldName string `json:"field_name"`
+	TaskMetrics
+}
+
+// Mismatch is a result that differs from the expected one
+type Mismatch struct {
+	CaseID   string `json:"case_id"`
+	Run      int    `json:"run"`
+	Field    string `json:"field"` // "intent" or the field name of an attribute
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// tally counts matches of results with the expected ones and with the first run
+type tally struct {
+	cases, results, correct int
+	repeated, agreeing      int
+}
+
+// add counts the results of the runs of one case
+func (t *tally) add(results []string, correct func(string) bool) {
+	if len(results) == 0 {
+		return
+	}
+	t.cases++
+	for i, result := range results {
+		t.results++
+		if correct(result) {
+			t.correct++
+		}
+		if i > 0 {
+			t.repeated++
+			if result != "" && normalizeValue(result) == normalizeValue(results[0]) {
+				t.agreeing++
+			}
+		}
+	}
+}
+
+// metrics returns the metrics of the counted results
+func (t tally) metrics() TaskMetrics {
+	metrics := TaskMetrics{Cases: t.cases}
+	if t.results > 0 {
+		metrics.Accuracy = ratio(t.correct, t.results)
+	}
+	if t.repeated > 0 {
+		agreement := ratio(t.agreeing, t.repeated)
+		metrics.Agreement = &agreement
+	}
+	return metrics
+}
+
+// measure computes the metrics of a report from the results of its cases
+func (r *Report) measure(dataset *Dataset, results []caseResults) {
+	var intents, attributes tally
+	fields := map[string]*tally{}
+	mismatch := func(m Mismatch) {
+		if len(r.Mismatches) < maxMismatches {
+			r.Mismatches = append(r.Mismatches, m)
+		}
+	}
+
+	for i, c := range dataset.Cases {
+		intents.add(results[i].intents, c.acceptsIntent)
+		for run, label := range results[i].intents {
+			if !c.acceptsIntent(label) {
+				mismatch(Mismatch{CaseID: c.ID, Run: run + 1, Field: "intent", Expected: c.ExpectedIntent, Actual: label})
+			}
+		}
+
+		names := make([]string, 0, len(c.ExpectedAttributes))
+		for name := range c.ExpectedAttributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			expected := c.ExpectedAttributes[name]
+			values := make([]string, len(results[i].attributes))
+			for run, byField := range results[i].attributes {
+				values[run] = byField[name]
+				if normalizeValue(values[run]) != normalizeValue(expected) {
+					mismatch(Mismatch{CaseID: c.ID, Run: run + 1, Field: name, Expected: expected, Actual: values[run]})
+				}
+			}
+			correct := func(value string) bool { return normalizeValue(value) == normalizeValue(expected) }
+			attributes.add(values, correct)
+			if fields[name] == nil {
+				fields[name] = &tally{}
+			}
+			fields[name].add(values, correct)
+		}
+	}
+
+	if intents.cases > 0 {
+		metrics := intents.metrics()
+		r.Intent = &metrics
+	}
+	if attributes.cases > 0 {
+		metrics := attributes.metrics()
+		r.Attributes = &metrics
+	}
+	for name, t := range fields {
+		r.Fields = append(r.Fields, FieldMetrics{FieldName: name, TaskMetrics: t.metrics()})
+	}
+	sort.Slice(r.Fields, func(i, j int) bool { return r.Fields[i].FieldName < r.Fields[j].FieldName })
+
+	if answered := r.Calls - r.Errors; answered > 0 {
+		r.SchemaValidity = ratio(answered-r.InvalidOutputs, answered)
+	}
+}
+
+// Compare returns the metrics of report that are more than tolerance below those of
+// baseline, e.g. after a prompt change, as readable regressions
+func Compare(baseline, report *Report, tolerance float64) []string {
+	var regressions []string
+	check := func(metric string, before, after float64) {
+		if before-after > tolerance {
+			regressions = append(regressions, fmt.Sprintf("%s: %s dropped from %.3f to %.3f", report.Dataset, metric, before, after))
+		}
+	}
+	checkTask := func(task string, before, after *TaskMetrics) {
+		if before == nil || after == nil {
+			return
+		}
+		check(task+" accuracy", before.Accuracy, after.Accuracy)
+		if before.Agreement != nil && after.Agreement != nil {
+			check(task+" agreement", *before.Agreement, *after.Agreement)
+		}
+	}
+
+	checkTask("intent", baseline.Intent, report.Intent)
+	checkTask("attribute", baseline.Attributes, report.Attributes)
+	for _, before := range baseline.Fields {
+		for _, after := range report.Fields {
+			if after.FieldName == before.FieldName {
+				checkTask(before.FieldName, &before.TaskMetrics, &after.TaskMetrics)
+			}
+		}
+	}
+	check("schema validity", baseline.SchemaValidity, report.SchemaValidity)
+	return regressions
+}
+
+// WriteSummary writes the metrics of reports as a table
+func WriteSummary(w io.Writer, reports []*Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATASET\tMETRIC\tCASES\tACCURACY\tAGREEMENT")
+	row := func(dataset, metric string, metrics TaskMetrics) {
+		agreement := "-"
+		if metrics.Agreement != nil {
+			agreement = fmt.Sprintf("%.3f", *metrics.Agreement)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.3f\t%s\n", dataset, metric, metrics.Cases, metrics.Accuracy, agreement)
+	}
+	for _, report := range reports {
+		if report.Intent != nil {
+			row(report.Dataset, "intent", *report.Intent)
+		}
+		if report.Attributes != nil {
+			row(report.Dataset, "attributes", *report.Attributes)
+		}
+		for _, field := range report.Fields {
+			row(report.Dataset, "  "+field.FieldName, field.TaskMetrics)
+		}
+		fmt.Fprintf(tw, "%s\tschema validity\t\t%.3f\t(%d calls, %d invalid, %d errors)\n",
+			report.Dataset, report.SchemaValidity, report.Calls, report.InvalidOutputs, report.Errors)
+	}
+	return tw.Flush()
+}
+
+// normalizeValue reduces a label or value to its lowercase words, so formatting
+// differences don't count as mismatches
+func normalizeValue(value string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}), " ")
+}
+
+// ratio returns part/whole rounded to three decimals
+func ratio(part, whole int) float64 {
+	return math.Round(float64(part)/float64(whole)*1000) / 1000
+}
@@ -0,0 +1,144 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+)
+
+// Runner analyzes the cases of datasets and measures the results against the expected ones
+type Runner struct {
+	Facade *analysis.AnalysisFacade
+	// Runs is how many times each case is analyzed, 1 by default; agreement between
+	// repeated runs is measured from 2
+	Runs int
+	// Variant is the model and the additional instructions under evaluation, if any
+	Variant core.Variant
+	// Templates are the prompt templates under evaluation by prompt name, if any
+	Templates map[string]string
+	// Mock answers with the mock provider instead of the model
+	Mock bool
+	// Progress, if set, is called after each case
+	Progress func(dataset string, done, total int)
+}
+
+// caseResults are the results of the runs of one case
+type caseResults struct {
+	intents    []string            // Intent labels per run, "" when the run failed
+	attributes []map[string]string // Attribute values per run by field name
+}
+
+// Run analyzes every case of a dataset Runs times and reports how the results compare to
+// the expected ones. Calls that fail are counted in the report rather than ending the run;
+// only a canceled context does.
+func (r *Runner) Run(ctx context.Context, dataset *Dataset) (*Report, error) {
+	if r.Facade == nil {
+		return nil, fmt.Errorf("runner has no analysis facade")
+	}
+	runs := r.Runs
+	if runs <= 0 {
+		runs = 1
+	}
+	if r.Mock {
+		ctx = core.WithMock(ctx)
+	}
+	if r.Variant != (core.Variant{}) {
+		ctx = core.WithVariant(ctx, r.Variant)
+	}
+	ctx, err := core.WithPromptTemplates(ctx, r.Templates, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Dataset:   dataset.Name,
+		Model:     r.Variant.Model,
+		StartedAt: time.Now().UTC(),
+		Cases:     len(dataset.Cases),
+		Runs:      runs,
+	}
+	results := make([]caseResults, len(dataset.Cases))
+	for i, c := range dataset.Cases {
+		for run := 0; run < runs; run++ {
+			if c.ExpectedIntent != "" {
+				intent, err := r.Facade.GenerateIntent(ctx, c.Text)
+				if err := report.countCall(ctx, err); err != nil {
+					return nil, err
+				}
+				label := ""
+				if err == nil && intent != nil {
+					label = intentLabel(c, intent.LabelName, intent.Label)
+				}
+				results[i].intents = append(results[i].intents, label)
+			}
+			if len(c.ExpectedAttributes) > 0 {
+				values, err := r.Facade.GenerateAttributes(ctx, c.Text, dataset.Attributes)
+				if err := report.countCall(ctx, err); err != nil {
+					return nil, err
+				}
+				byField := map[string]string{}
+				for _, value := range values {
+					byField[value.FieldName] = value.Value
+				}
+				results[i].attributes = append(results[i].attributes, byField)
+			}
+		}
+		if r.Progress != nil {
+			r.Progress(dataset.Name, i+1, len(dataset.Cases))
+		}
+	}
+
+	report.measure(dataset, results)
+	report.Duration = time.Since(report.StartedAt).Round(time.Millisecond).String()
+	return report, nil
+}
+
+// countCall counts a model call and how it failed, if it did. It returns the error of a
+// canceled context, which ends the run.
+func (r *Report) countCall(ctx context.Context, err error) error {
+	r.Calls++
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, core.ErrSchemaValidation):
+		r.InvalidOutputs++
+	default:
+		r.Errors++
+	}
+	return nil
+}
+
+// intentLabel returns the label of a classification that matches the expected intents of
+// a case, the label name or the label, or else the label name
+func intentLabel(c Case, labelName, label string) string {
+	for _, candidate := range []string{labelName, label} {
+		if c.acceptsIntent(candidate) {
+			return candidate
+		}
+	}
+	if labelName != "" {
+		return labelName
+	}
+	return label
+}
+
+// acceptsIntent reports whether an intent label is correct for a case
+func (c Case) acceptsIntent(label string) bool {
+	if label == "" {
+		return false
+	}
+	if normalizeValue(label) == normalizeValue(c.ExpectedIntent) {
+		return true
+	}
+	for _, accepted := range c.AcceptedIntents {
+		if normalizeValue(label) == normalizeValue(accepted) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package analysis
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"agenticflows/backend/analysis/core"
@@ -17,6 +18,10 @@ type AnalysisFacade struct {
 	TextProcessor            *processors.TextProcessor
 	RecommendationsProcessor *processors.RecommendationsProcessor
 	PlannerProcessor         *processors.PlannerProcessor
+	ExplanationProcessor     *processors.ExplanationProcessor
+	SentimentProcessor       *processors.SentimentProcessor
+	CompareProcessor         *processors.CompareProcessor
+	FindingsProcessor        *processors.FindingsProcessor
 }
 
 // NewAnalysisFacade creates a new AnalysisFacade
@@ -33,6 +38,10 @@ func NewAnalysisFacade(apiKey string, debug bool) (*AnalysisFacade, error) {
 	textProcessor := processors.NewTextProcessor(analyzer)
 	recommendationsProcessor := processors.NewRecommendationsProcessor(analyzer)
 	plannerProcessor := processors.NewPlannerProcessor(analyzer)
+	explanationProcessor := processors.NewExplanationProcessor(analyzer)
+	sentimentProcessor := processors.NewSentimentProcessor(analyzer)
+	compareProcessor := processors.NewCompareProcessor(analyzer, sentimentProcessor)
+	findingsProcessor := processors.NewFindingsProcessor(analyzer)
 
 	return &AnalysisFacade{
 		Analyzer:                 analyzer,
@@ -41,6 +50,10 @@ func NewAnalysisFacade(apiKey string, debug bool) (*AnalysisFacade, error) {
 		TextProcessor:            textProcessor,
 		RecommendationsProcessor: recommendationsProcessor,
 		PlannerProcessor:         plannerProcessor,
+		ExplanationProcessor:     explanationProcessor,
+		SentimentProcessor:       sentimentProcessor,
+		CompareProcessor:         compareProcessor,
+		FindingsProcessor:        findingsProcessor,
 	}, nil
 }
 
@@ -54,6 +67,29 @@ func (f *AnalysisFacade) IdentifyPatterns(ctx context.Context, req models.Analys
 	return f.PatternsAnalyzer.IdentifyPatterns(ctx, req)
 }
 
+// AnalyzeFindings answers the questions of req from its data and text, with a coverage
+// matrix of the questions the data answers. attributes are the attributes the data has.
+func (f *AnalysisFacade) AnalyzeFindings(ctx context.Context, req models.AnalysisRequest, attributes []string) (*FindingsResult, error) {
+	raw, err := f.FindingsProcessor.AnalyzeFindings(ctx, req, attributes)
+	if err != nil {
+		return nil, err
+	}
+	typed, err := NormalizeResults("findings", raw)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(typed)
+	if err != nil {
+		return nil, err
+	}
+	var result FindingsResult
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		return nil, err
+	}
+	result.Coverage = BuildCoverage(req.Questions, result.Findings)
+	return &result, nil
+}
+
 // GenerateRequiredAttributes generates required attributes for answering questions
 func (f *AnalysisFacade) GenerateRequiredAttributes(ctx context.Context, questions []string, existingAttributes []string) ([]models.AttributeDefinition, error) {
 	return f.TextProcessor.GenerateRequiredAttributes(ctx, questions, existingAttributes)
@@ -64,11 +100,86 @@ func (f *AnalysisFacade) GenerateAttributes(ctx context.Context, text string, at
 	return f.TextProcessor.GenerateAttributes(ctx, text, attributes)
 }
 
+// SummarizeTranscript summarizes a conversation in at most about maxChars characters
+func (f *AnalysisFacade) SummarizeTranscript(ctx context.Context, text string, maxChars int) (string, error) {
+	return f.TextProcessor.SummarizeTranscript(ctx, text, maxChars)
+}
+
 // GenerateIntent generates the intent classification for a conversation
 func (f *AnalysisFacade) GenerateIntent(ctx context.Context, text string) (*models.IntentClassification, error) {
 	return f.TextProcessor.GenerateIntent(ctx, text)
 }
 
+// GenerateIntents generates the intent classification of each of several conversations,
+// with the most common intent at the top level of the result
+func (f *AnalysisFacade) GenerateIntents(ctx context.Context, texts []string) (*IntentResult, error) {
+	intents, err := f.TextProcessor.GenerateIntents(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	result := &IntentResult{
+		IntentClassification: processors.MostCommonIntent(intents),
+		Conversations:        make([]models.ConversationIntent, len(intents)),
+	}
+	for i, intent := range intents {
+		result.Conversations[i].IntentClassification = intent
+	}
+	return result, nil
+}
+
+// GenerateIntentsBulk classifies the intent of each of many conversations, running up to
+// concurrency LLM calls at once. Conversations that fail are reported with their error;
+// the most common intent and the distribution of intents cover the others.
+func (f *AnalysisFacade) GenerateIntentsBulk(ctx context.Context, conversations []models.ConversationText, concurrency int) *IntentResult {
+	if concurrency <= 0 {
+		concurrency = DefaultFanOutConcurrency
+	}
+
+	texts := make([]string, len(conversations))
+	for i, conversation := range conversations {
+		texts[i] = conversation.Text
+	}
+	intents, errs := f.TextProcessor.GenerateIntentsConcurrently(ctx, texts, concurrency)
+
+	result := &IntentResult{Conversations: make([]models.ConversationIntent, len(conversations))}
+	var classified []models.IntentClassification
+	for i, conversation := range conversations {
+		result.Conversations[i].ConversationID = conversation.ConversationID
+		if errs[i] != nil {
+			result.Conversations[i].Error, result.Conversations[i].ErrorCode = errs[i].Error(), ErrorCode(errs[i])
+			result.Failed++
+			continue
+		}
+		result.Conversations[i].IntentClassification = intents[i]
+		classified = append(classified, intents[i])
+	}
+	result.IntentClassification = processors.MostCommonIntent(classified)
+	result.Distribution = processors.IntentDistribution(classified)
+	return result
+}
+
+// AnalyzeSentiment analyzes the overall, per-speaker and start/middle/end sentiment of a conversation
+func (f *AnalysisFacade) AnalyzeSentiment(ctx context.Context, text string, speakers []string) (*models.SentimentAnalysis, error) {
+	return f.SentimentProcessor.AnalyzeSentiment(ctx, text, speakers)
+}
+
+// AnalyzeSentiments analyzes the sentiment of each of several conversations, with their
+// average sentiment at the top level of the result
+func (f *AnalysisFacade) AnalyzeSentiments(ctx context.Context, texts []string, speakers []string) (*SentimentResult, error) {
+	sentiments, err := f.SentimentProcessor.AnalyzeSentiments(ctx, texts, speakers)
+	if err != nil {
+		return nil, err
+	}
+	result := &SentimentResult{
+		SentimentAnalysis: processors.AverageSentiment(sentiments),
+		Conversations:     make([]models.ConversationSentiment, len(sentiments)),
+	}
+	for i, sentiment := range sentiments {
+		result.Conversations[i].SentimentAnalysis = sentiment
+	}
+	return result, nil
+}
+
 // GenerateRecommendations generates recommendations based on analysis results
 func (f *AnalysisFacade) GenerateRecommendations(ctx context.Context, analysisResults map[string]interface{}, focusArea string) (*models.RecommendationResponse, error) {
 	return f.RecommendationsProcessor.GenerateRecommendations(ctx, analysisResults, focusArea)
@@ -89,9 +200,20 @@ func (f *AnalysisFacade) CreateActionPlan(ctx context.Context, recommendations *
 	return f.PlannerProcessor.CreateActionPlan(ctx, recommendations, constraints)
 }
 
-// GenerateTimeline generates an implementation timeline for an action plan
-func (f *AnalysisFacade) GenerateTimeline(ctx context.Context, actionPlan *models.ActionPlan, resources map[string]interface{}) ([]models.TimelineEvent, error) {
-	return f.PlannerProcessor.GenerateTimeline(ctx, actionPlan, resources)
+// GenerateTimeline generates an implementation timeline for an action plan and schedules
+// it on a working calendar
+func (f *AnalysisFacade) GenerateTimeline(ctx context.Context, actionPlan *models.ActionPlan, resources map[string]interface{}, calendar *models.WorkingCalendar) ([]models.TimelineEvent, *models.TimelineSchedule, error) {
+	return f.PlannerProcessor.GenerateTimeline(ctx, actionPlan, resources, calendar)
+}
+
+// CompareCohorts compares the sentiment, resolution outcomes and trends of two cohorts
+func (f *AnalysisFacade) CompareCohorts(ctx context.Context, cohorts []models.Cohort, focusAreas []string, significanceLevel float64) (*models.CohortComparison, error) {
+	return f.CompareProcessor.Compare(ctx, cohorts, focusAreas, significanceLevel)
+}
+
+// ExplainResultItem explains one item of an analysis result using its source conversations
+func (f *AnalysisFacade) ExplainResultItem(ctx context.Context, analysisType string, item interface{}, conversations []models.ConversationText, maxExcerpts int) (*models.Explanation, error) {
+	return f.ExplanationProcessor.ExplainItem(ctx, analysisType, item, conversations, maxExcerpts)
 }
 
 // ChainAnalysis performs a chain of analyses
@@ -99,6 +221,11 @@ func (f *AnalysisFacade) ChainAnalysis(ctx context.Context, inputData interface{
 	return f.Analyzer.ChainAnalysis(ctx, inputData, config)
 }
 
+// SetChainStepRunner sets how the steps of chain analyses run their analyses
+func (f *AnalysisFacade) SetChainStepRunner(run core.ChainStepFunc) {
+	f.Analyzer.SetChainStepRunner(run)
+}
+
 // TransformForTrends prepares data for trend analysis
 func (f *AnalysisFacade) TransformForTrends(data interface{}) (map[string]interface{}, error) {
 	return f.Analyzer.TransformForTrends(data)
@@ -152,8 +279,11 @@ func (a *LegacyAnalyzer) IdentifyPatterns(ctx context.Context, req models.Analys
 
 // AnalyzeFindings analyzes findings from attribute extraction (backward compatibility)
 func (a *LegacyAnalyzer) AnalyzeFindings(ctx context.Context, req models.AnalysisRequest) (*models.AnalysisResponse, error) {
-	// This is a stub that needs to be implemented when migrating the findings analyzer
-	return nil, fmt.Errorf("AnalyzeFindings not implemented in refactored version yet")
+	result, err := a.facade.AnalyzeFindings(ctx, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AnalysisResponse{Results: result, Confidence: result.AverageConfidence()}, nil
 }
 
 // GenerateRequiredAttributes generates required attributes (backward compatibility)
@@ -0,0 +1,186 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// How completely the data answers a question of a findings analysis
+const (
+	CoverageAnswered     = "answered"
+	CoveragePartial      = "partial"
+	CoverageUnanswerable = "unanswerable"
+)
+
+// CoverageMatrix reports which questions of a findings analysis the data answers fully,
+// partially or not at all, and the attributes missing to answer the rest
+type CoverageMatrix struct {
+	Answered     int                `json:"answered"`
+	Partial      int                `json:"partial"`
+	Unanswerable int                `json:"unanswerable"`
+	Questions    []QuestionCoverage `json:"questions"`
+	// MissingAttributes are the attributes the questions need, most needed first
+	MissingAttributes []MissingAttribute `json:"missing_attributes,omitempty"`
+	// NextAttributes define the missing attributes for the next attribute extraction
+	NextAttributes []models.AttributeDefinition `json:"next_attributes,omitempty"`
+}
+
+// QuestionCoverage is the coverage of one question
+type QuestionCoverage struct {
+	Question          string   `json:"question"`
+	Coverage          string   `json:"coverage"`
+	MissingAttributes []string `json:"missing_attributes,omitempty"`
+}
+
+// MissingAttribute is an attribute missing from the data and the questions that need it
+type MissingAttribute struct {
+	FieldName string   `json:"field_name"`
+	Questions []string `json:"questions"`
+}
+
+// BuildCoverage normalizes the coverage of each finding and summarizes it per question,
+// in the order of questions. Questions without a finding are unanswerable.
+func BuildCoverage(questions []string, findings []Finding) *CoverageMatrix {
+	// Match findings to questions by text, falling back to their position for findings
+	// that rephrase their question
+	byQuestion := make(map[string]int, len(findings))
+	for i, finding := range findings {
+		byQuestion[normalizeQuestion(finding.Question)] = i
+	}
+	asked := make(map[string]bool, len(questions))
+	for _, question := range questions {
+		asked[normalizeQuestion(question)] = true
+	}
+
+	matrix := &CoverageMatrix{Questions: make([]QuestionCoverage, 0, len(questions))}
+	needed := map[string][]string{}
+	for i, question := range questions {
+		entry := QuestionCoverage{Question: question, Coverage: CoverageUnanswerable}
+		index, ok := byQuestion[normalizeQuestion(question)]
+		if !ok && i < len(findings) && !asked[normalizeQuestion(findings[i].Question)] {
+			index, ok = i, true
+		}
+		if ok {
+			finding := &findings[index]
+			finding.Coverage = findingCoverage(*finding)
+			if finding.Coverage == CoverageAnswered {
+				finding.MissingAttributes = nil
+			}
+			finding.MissingAttributes = fieldNames(finding.MissingAttributes)
+			entry.Coverage = finding.Coverage
+			entry.MissingAttributes = finding.MissingAttributes
+		}
+
+		switch entry.Coverage {
+		case CoverageAnswered:
+			matrix.Answered++
+		case CoveragePartial:
+			matrix.Partial++
+		default:
+			matrix.Unanswerable++
+		}
+		for _, name := range entry.MissingAttributes {
+			needed[name] = append(needed[name], question)
+		}
+		matrix.Questions = append(matrix.Questions, entry)
+	}
+
+	for name, questions := range needed {
+		matrix.MissingAttributes = append(matrix.MissingAttributes, MissingAttribute{FieldName: name, Questions: questions})
+	}
+	sort.Slice(matrix.MissingAttributes, func(i, j int) bool {
+		a, b := matrix.MissingAttributes[i], matrix.MissingAttributes[j]
+		if len(a.Questions) != len(b.Questions) {
+			return len(a.Questions) > len(b.Questions)
+		}
+		return a.FieldName < b.FieldName
+	})
+	return matrix
+}
+
+// Incomplete returns the questions the data does not fully answer
+func (m *CoverageMatrix) Incomplete() []QuestionCoverage {
+	var incomplete []QuestionCoverage
+	for _, question := range m.Questions {
+		if question.Coverage != CoverageAnswered {
+			incomplete = append(incomplete, question)
+		}
+	}
+	return incomplete
+}
+
+// ValidateCitations drops the citations of conversations that are not among
+// conversationIDs, and of empty quotes. It returns how many were dropped and the questions
+// answered, fully or partially, without a citation left.
+func (r *FindingsResult) ValidateCitations(conversationIDs map[string]bool) (dropped int, uncited []string) {
+	for i := range r.Findings {
+		finding := &r.Findings[i]
+		kept := make([]Citation, 0, len(finding.Citations))
+		for _, citation := range finding.Citations {
+			citation.ConversationID = strings.TrimSpace(citation.ConversationID)
+			if !conversationIDs[citation.ConversationID] || strings.TrimSpace(citation.Quote) == "" {
+				dropped++
+				continue
+			}
+			kept = append(kept, citation)
+		}
+		finding.Citations = kept
+		if len(kept) == 0 && findingCoverage(*finding) != CoverageUnanswerable {
+			uncited = append(uncited, finding.Question)
+		}
+	}
+	return dropped, uncited
+}
+
+// findingCoverage returns the coverage of a finding, judging findings with an unknown
+// coverage by their answer
+func findingCoverage(finding Finding) string {
+	coverage := strings.ToLower(strings.TrimSpace(finding.Coverage))
+	switch coverage {
+	case CoverageAnswered, CoveragePartial, CoverageUnanswerable:
+		return coverage
+	case "unanswered", "not_answered", "none":
+		return CoverageUnanswerable
+	}
+	if strings.TrimSpace(finding.Answer) == "" {
+		return CoverageUnanswerable
+	}
+	return CoveragePartial
+}
+
+// fieldNames normalizes attribute names to unique snake_case field names
+func fieldNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var normalized []string
+	for _, name := range names {
+		name = strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+		}), "_")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized
+}
+
+// normalizeQuestion reduces a question to its words, so rephrased punctuation still matches
+func normalizeQuestion(question string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), " ")
+}
+
+// AverageConfidence averages the confidence of the findings, or is 0 without findings
+func (r *FindingsResult) AverageConfidence() float64 {
+	if len(r.Findings) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, finding := range r.Findings {
+		total += finding.Confidence
+	}
+	return total / float64(len(r.Findings))
+}
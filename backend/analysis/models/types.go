@@ -1,6 +1,13 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"agenticflows/backend/analysis/dedup"
+	"agenticflows/backend/pii"
+)
 
 // AnalysisRequest represents the data needed for various analysis functions
 type AnalysisRequest struct {
@@ -18,10 +25,58 @@ type StandardAnalysisRequest struct {
 	WorkflowID string `json:"workflow_id,omitempty"`
 	Text       string `json:"text,omitempty"`
 
+	// RunID links the stored result to a run of the workflow it was computed for
+	RunID string `json:"run_id,omitempty"`
+
+	// ConversationIDs references stored conversations to analyze instead of inline text
+	ConversationIDs []string `json:"conversation_ids,omitempty"`
+
+	// Language is the language code of the conversations, e.g. "es"; it is detected when
+	// empty or "auto". The language of the output is set by the language parameter.
+	Language string `json:"language,omitempty"`
+
 	// Analysis-specific fields
-	AnalysisType string                 `json:"analysis_type"`  // "trends", "patterns", "findings", "attributes", "intent", "recommendations", "plan"
+	AnalysisType string                 `json:"analysis_type"`  // "trends", "patterns", "findings", "attributes", "intent", "sentiment", "compare", "recommendations", "plan", "dedup", "summarize", "anomalies"
 	Parameters   map[string]interface{} `json:"parameters"`     // Analysis-specific parameters
 	Data         map[string]interface{} `json:"data,omitempty"` // Input data for analysis
+
+	// Sources identifies the conversations and earlier results this analysis was derived from
+	Sources []SourceRef `json:"sources,omitempty"`
+
+	// Stream requests Server-Sent Events with progress and partial output instead of a single JSON response
+	Stream bool `json:"stream,omitempty"`
+
+	// Cache set to false bypasses cached results of identical requests and refreshes them
+	Cache *bool `json:"cache,omitempty"`
+
+	// Tags attribute the LLM usage of the request to cost centers, e.g. {"team": "payments"}
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// LanguageMetadata reports the language the conversations of an analysis were read in
+type LanguageMetadata struct {
+	// Language is the code of the language of the conversations, or of most of them
+	Language string `json:"language"`
+	// Source is how the language was determined: "request", "detected" or "translated"
+	Source string `json:"source"`
+	// Languages counts the conversations per detected language, "unknown" for those
+	// without a recognized language
+	Languages map[string]int `json:"languages,omitempty"`
+	// OutputLanguage is the language requested for the output, if any
+	OutputLanguage string `json:"output_language,omitempty"`
+}
+
+// Sources of the language of analyzed conversations
+const (
+	LanguageSourceRequest    = "request"
+	LanguageSourceDetected   = "detected"
+	LanguageSourceTranslated = "translated"
+)
+
+// SourceRef identifies an input of an analysis: a conversation or a previously stored result
+type SourceRef struct {
+	Type string `json:"type"` // "conversation" or the analysis type of a stored result
+	ID   string `json:"id"`
 }
 
 // AnalysisResponse represents a generic response from analysis methods
@@ -37,6 +92,7 @@ type StandardAnalysisResponse struct {
 	// Common fields
 	AnalysisType string    `json:"analysis_type"`
 	WorkflowID   string    `json:"workflow_id,omitempty"`
+	ResultID     string    `json:"result_id,omitempty"` // ID of the stored result, usable as a source of later analyses
 	Timestamp    time.Time `json:"timestamp"`
 
 	// Results
@@ -49,15 +105,173 @@ type StandardAnalysisResponse struct {
 		Limitations []string `json:"limitations,omitempty"`
 	} `json:"data_quality,omitempty"`
 
+	// Reliability is reported when the analysis was run several times and merged by vote
+	Reliability *SampleAgreement `json:"reliability,omitempty"`
+
+	// Cached is set when the results were served from the cache of identical requests
+	Cached bool `json:"cached,omitempty"`
+
+	// SuppressedGroups counts aggregate buckets removed for covering too few conversations
+	SuppressedGroups int `json:"suppressed_groups,omitempty"`
+
+	// SourceLanguages counts the analyzed conversations per original language when they
+	// were translated before the analysis
+	SourceLanguages map[string]int `json:"source_languages,omitempty"`
+
+	// LanguageMetadata reports the language the conversations were analyzed in
+	LanguageMetadata *LanguageMetadata `json:"language_metadata,omitempty"`
+
+	// Duplicates reports the duplicate conversations dropped before the analysis when it
+	// was run with the dedup parameter
+	Duplicates *dedup.Result `json:"duplicates,omitempty"`
+
+	// PIIRedaction reports the PII replaced with placeholders before the analysis when it
+	// was run with the redact_pii parameter
+	PIIRedaction *pii.Report `json:"pii_redaction,omitempty"`
+
+	// Summarization reports the conversations summarized before the analysis when it was
+	// run with the presummarize parameter
+	Summarization *SummarizationReport `json:"summarization,omitempty"`
+
+	// DryRun lists the prompts the analysis would send and their estimated cost when it
+	// was run with the dry_run parameter; Results are then left out
+	DryRun *DryRunReport `json:"dry_run,omitempty"`
+
 	// Error handling
 	Error *AnalysisError `json:"error,omitempty"`
+
+	// Partial is set when some items of a batch or bulk analysis failed or were skipped;
+	// Results cover the others, and Errors has one entry per missing item
+	Partial bool            `json:"partial,omitempty"`
+	Errors  []AnalysisError `json:"errors,omitempty"`
+
+	// Warnings note what reduced the fidelity of a completed analysis, such as input
+	// truncated to fit a prompt
+	Warnings []AnalysisWarning `json:"warnings,omitempty"`
+}
+
+// DecodeResults decodes Results into v, typically a pointer to one of the typed
+// results in the analysis package, such as *analysis.TrendsResult
+func (r *StandardAnalysisResponse) DecodeResults(v interface{}) error {
+	encoded, err := json.Marshal(r.Results)
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %w", err)
+	}
+	if err := json.Unmarshal(encoded, v); err != nil {
+		return fmt.Errorf("failed to decode %s results: %w", r.AnalysisType, err)
+	}
+	return nil
+}
+
+// DryRunReport describes the model calls of an analysis that was not sent to the model.
+// Token counts are estimated from text length, and completion tokens from mock replies.
+type DryRunReport struct {
+	Prompts          []DryRunPrompt `json:"prompts"`
+	Calls            int            `json:"calls"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+	EstimatedCost    float64        `json:"estimated_cost"`
+	Currency         string         `json:"currency"`
+	// Notes name what the dry run left out or approximated, such as translation
+	Notes []string `json:"notes,omitempty"`
+}
+
+// DryRunPrompt is one prompt of a dry run
+type DryRunPrompt struct {
+	Step             string `json:"step,omitempty"`   // Chain step, for chain analyses
+	Schema           string `json:"schema,omitempty"` // Structured output asked for; empty for text
+	Prompt           string `json:"prompt"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
 }
 
-// AnalysisError represents error information
+// SampleAgreement reports how consistently repeated samples of an analysis agreed
+type SampleAgreement struct {
+	Samples          int     `json:"samples"`           // Samples requested
+	Succeeded        int     `json:"succeeded"`         // Samples that completed and were merged
+	Agreement        float64 `json:"agreement"`         // Inter-sample agreement, 0 to 1
+	SampleConfidence float64 `json:"sample_confidence"` // Mean confidence reported by the samples
+}
+
+// AnalysisError represents error information. Retryable errors, such as provider
+// timeouts, may succeed when the request or item is sent again unchanged; the others
+// fail the same way until it changes. Errors of one item of a batch or bulk analysis
+// name the item by its position in the data and its ID, if it has one.
 type AnalysisError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   string `json:"details,omitempty"`
+	Retryable bool   `json:"retryable"`
+	ItemIndex *int   `json:"item_index,omitempty"`
+	ItemID    string `json:"item_id,omitempty"`
+}
+
+// AnalysisWarning notes an analysis that completed with reduced fidelity
+type AnalysisWarning struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+}
+
+// BatchAnalysisRequest runs one analysis over a large data array, split into batches server-side
+type BatchAnalysisRequest struct {
+	WorkflowID   string                 `json:"workflow_id,omitempty"`
+	AnalysisType string                 `json:"analysis_type"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Items        []interface{}          `json:"data"`
+
+	// DataKey is the key under which each batch is passed in the analysis data (default "conversations")
+	DataKey string `json:"data_key,omitempty"`
+	// TextField holds the text of each item for text analyses such as intent (default "text")
+	TextField string `json:"text_field,omitempty"`
+
+	BatchSize      int    `json:"batch_size,omitempty"`
+	Concurrency    int    `json:"concurrency,omitempty"`
+	MergeStrategy  string `json:"merge_strategy,omitempty"`  // "concat" (default), "consolidate" or "none"
+	ConsolidateKey string `json:"consolidate_key,omitempty"` // Field identifying equivalent items when consolidating
+
+	Sources []SourceRef `json:"sources,omitempty"`
+
+	// Cache set to false bypasses cached results of identical batches
+	Cache *bool `json:"cache,omitempty"`
+
+	// Tags attribute the LLM usage of the batch to cost centers
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// ExplainRequest asks for a deeper explanation of one item of a stored analysis result
+type ExplainRequest struct {
+	ResultID string `json:"result_id"`
+	Item     string `json:"item"` // Path of the item within the results, e.g. "trends[0]"
+
+	// Conversations supplies the text of source conversations; otherwise they are read from DatabasePath
+	Conversations []ConversationText `json:"conversations,omitempty"`
+	DatabasePath  string             `json:"database_path,omitempty"`
+	MaxExcerpts   int                `json:"max_excerpts,omitempty"`
+}
+
+// ConversationText is the text of a conversation used as evidence
+type ConversationText struct {
+	ConversationID string `json:"conversation_id"`
+	Text           string `json:"text"`
+	// Windowed condenses the text window by window before it is analyzed, for transcripts
+	// too long or too expensive to analyze whole
+	Windowed bool `json:"-"`
+}
+
+// Explanation is a deeper explanation of a single result item
+type Explanation struct {
+	Explanation        string              `json:"explanation"`
+	Reasoning          []string            `json:"reasoning"`
+	SupportingExcerpts []SupportingExcerpt `json:"supporting_excerpts"`
+	Caveats            []string            `json:"caveats"`
+	Confidence         float64             `json:"confidence"`
+}
+
+// SupportingExcerpt is a passage from a conversation that supports a result item
+type SupportingExcerpt struct {
+	ConversationID string `json:"conversation_id"`
+	Excerpt        string `json:"excerpt"`
+	Relevance      string `json:"relevance"`
 }
 
 // AttributeDefinition represents a required data attribute
@@ -66,6 +280,53 @@ type AttributeDefinition struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Rationale   string `json:"rationale,omitempty"`
+
+	// Speaker restricts extraction to the turns of one role, "customer" or "agent"
+	Speaker string `json:"speaker,omitempty"`
+
+	// Type is the declared type of the value: string (the default), number, integer,
+	// boolean or date
+	Type string `json:"type,omitempty"`
+	// EnumValues are the values the attribute may take, if it is categorical
+	EnumValues []string `json:"enum_values,omitempty"`
+	// EnumSynonyms map enum values to other words for them, which extracted values are
+	// normalized from
+	EnumSynonyms map[string][]string `json:"enum_synonyms,omitempty"`
+}
+
+// Declared types of attribute values
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeInteger = "integer"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeDate    = "date"
+)
+
+// ValidAttributeType reports whether t is a declared attribute type; empty means string
+func ValidAttributeType(t string) bool {
+	switch t {
+	case "", AttributeTypeString, AttributeTypeNumber, AttributeTypeInteger, AttributeTypeBoolean, AttributeTypeDate:
+		return true
+	}
+	return false
+}
+
+// Speaker roles of conversation turns
+const (
+	RoleCustomer = "customer"
+	RoleAgent    = "agent"
+	RoleSystem   = "system" // IVR prompts, bots and automated messages
+	RoleUnknown  = "unknown"
+)
+
+// Turn is one utterance of a conversation
+type Turn struct {
+	Index     int    `json:"index"` // Position in the conversation, starting at 1
+	Speaker   string `json:"speaker,omitempty"`
+	Role      string `json:"role"`
+	Text      string `json:"text"`
+	Timestamp string `json:"timestamp,omitempty"`
 }
 
 // AttributeValue represents an extracted value for an attribute
@@ -75,6 +336,102 @@ type AttributeValue struct {
 	Confidence  float64 `json:"confidence"`
 	Explanation string  `json:"explanation,omitempty"`
 	Label       string  `json:"label,omitempty"`
+	// RawValue is the value as the model gave it, when it was normalized to Value
+	RawValue string `json:"raw_value,omitempty"`
+	// OutOfEnum marks a value that is not one of its attribute's enum values and could not
+	// be normalized to one
+	OutOfEnum bool `json:"out_of_enum,omitempty"`
+
+	// TypedValue is the value as the declared type of its attribute: a number, integer,
+	// boolean or date (YYYY-MM-DD)
+	TypedValue interface{} `json:"typed_value,omitempty"`
+	// Currency is the ISO 4217 code of the currency of an amount
+	Currency string `json:"currency,omitempty"`
+	// Locale is the locale a number or date was read in
+	Locale string `json:"locale,omitempty"`
+	// TypeError explains why the value could not be read as its declared type
+	TypeError string `json:"type_error,omitempty"`
+}
+
+// AttributeChange is a field-level difference between a stored attribute value and the
+// value extracted again, with the reason the attribute was re-extracted
+type AttributeChange struct {
+	ConversationID     string  `json:"conversation_id"`
+	FieldName          string  `json:"field_name"`
+	PreviousValue      string  `json:"previous_value"`
+	Value              string  `json:"value"`
+	PreviousConfidence float64 `json:"previous_confidence"`
+	Confidence         float64 `json:"confidence"`
+	Reason             string  `json:"reason"`
+	Explanation        string  `json:"explanation,omitempty"`
+}
+
+// ConversationAttributes holds the attribute values extracted from one conversation
+type ConversationAttributes struct {
+	ConversationID  string                  `json:"conversation_id"`
+	AttributeValues []AttributeValue        `json:"attribute_values"`
+	Violations      []RuleViolation         `json:"violations,omitempty"`
+	Error           string                  `json:"error,omitempty"`
+	ErrorCode       string                  `json:"error_code,omitempty"`
+	Processing      *ConversationProcessing `json:"processing,omitempty"`
+}
+
+// ConversationProcessing is what analyzing one conversation took: its LLM calls and
+// tokens, their estimated cost and the time it took
+type ConversationProcessing struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Estimated        bool    `json:"estimated,omitempty"` // Token counts were estimated from text length
+	Cost             float64 `json:"cost"`                // USD, at the configured token prices
+	LatencyMS        int64   `json:"latency_ms"`
+	TextLength       int     `json:"text_length"`
+	// Windowed is set when the text was condensed window by window before extraction
+	Windowed bool `json:"windowed,omitempty"`
+	// Outlier is set when the conversation cost far more than the others of its run; it
+	// is windowed on later runs
+	Outlier bool `json:"outlier,omitempty"`
+	// Batched is the number of conversations that shared their calls; the calls and
+	// latency are then those of the batch and the tokens its equal share
+	Batched int `json:"batched,omitempty"`
+}
+
+// ProcessingSummary sums up the processing of the conversations of a run and names the
+// expensive outliers
+type ProcessingSummary struct {
+	Conversations      int      `json:"conversations"`
+	Cost               float64  `json:"cost"`
+	PromptTokens       int      `json:"prompt_tokens"`
+	CompletionTokens   int      `json:"completion_tokens"`
+	MedianPromptTokens int      `json:"median_prompt_tokens"`
+	MedianLatencyMS    int64    `json:"median_latency_ms"`
+	MaxLatencyMS       int64    `json:"max_latency_ms"`
+	Outliers           []string `json:"outliers,omitempty"`
+	Windowed           []string `json:"windowed,omitempty"`
+}
+
+// RuleViolation is a validation rule that the attribute values of a record break, with
+// the values of the fields involved
+type RuleViolation struct {
+	Rule    string            `json:"rule"`
+	Message string            `json:"message"`
+	Fields  []string          `json:"fields"`
+	Values  map[string]string `json:"values"`
+}
+
+// AttributeStatistics summarizes the values of one attribute across conversations
+type AttributeStatistics struct {
+	FieldName         string       `json:"field_name"`
+	Extracted         int          `json:"extracted"` // Conversations a value was extracted from
+	AverageConfidence float64      `json:"average_confidence"`
+	DistinctValues    int          `json:"distinct_values"`
+	TopValues         []ValueCount `json:"top_values"`
+}
+
+// ValueCount is how many conversations share a value
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
 }
 
 // IntentClassification represents intent classification results
@@ -84,6 +441,135 @@ type IntentClassification struct {
 	Description string `json:"description"`
 }
 
+// ConversationSummary is the summary of one conversation. Length and SummaryLength are
+// the characters of the conversation and of its summary.
+type ConversationSummary struct {
+	ConversationID string `json:"conversation_id,omitempty"`
+	Summary        string `json:"summary"`
+	Length         int    `json:"length"`
+	SummaryLength  int    `json:"summary_length"`
+	Cached         bool   `json:"cached,omitempty"`
+	Error          string `json:"error,omitempty"`
+	ErrorCode      string `json:"error_code,omitempty"`
+}
+
+// SummarizationReport describes the conversations an analysis was given as summaries
+// because they were longer than the presummarize threshold
+type SummarizationReport struct {
+	Threshold       int      `json:"threshold"`                  // Characters above which conversations were summarized
+	Summarized      int      `json:"summarized"`                 // Conversations replaced with their summary
+	Cached          int      `json:"cached"`                     // Summaries served from the summary cache
+	ConversationIDs []string `json:"conversation_ids,omitempty"` // IDs of the summarized conversations that have one
+}
+
+// ConversationIntent is the intent of one conversation of an intent analysis over several
+type ConversationIntent struct {
+	ConversationID string `json:"conversation_id,omitempty"`
+	IntentClassification
+	Error     string `json:"error,omitempty"` // Why the conversation could not be classified, in bulk mode
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// IntentCount is the number of conversations with an intent
+type IntentCount struct {
+	Label     string `json:"label"`
+	LabelName string `json:"label_name"`
+	Count     int    `json:"count"`
+}
+
+// IntentGroup is a set of intents whose names are close in meaning, named after its most
+// common intent
+type IntentGroup struct {
+	Label     string   `json:"label"`
+	LabelName string   `json:"label_name"`
+	Intents   []string `json:"intents"` // Labels of the grouped intents
+	Count     int      `json:"count"`   // Conversations with any of the intents
+}
+
+// SentimentAnalysis is the sentiment of a conversation overall, per speaker and over time
+type SentimentAnalysis struct {
+	Overall    SentimentScore      `json:"overall"`
+	Speakers   []SpeakerSentiment  `json:"speakers"`
+	Trajectory SentimentTrajectory `json:"trajectory"`
+	Summary    string              `json:"summary"`
+}
+
+// ConversationSentiment is the sentiment of one conversation of a sentiment analysis over several
+type ConversationSentiment struct {
+	ConversationID string `json:"conversation_id,omitempty"`
+	SentimentAnalysis
+}
+
+// SentimentScore is a sentiment label with a score from -1 (negative) to 1 (positive)
+type SentimentScore struct {
+	Label      string  `json:"label"` // "positive", "neutral", "negative" or "mixed"
+	Score      float64 `json:"score"`
+	Confidence float64 `json:"confidence"`
+}
+
+// SpeakerSentiment is the sentiment expressed by one participant of a conversation
+type SpeakerSentiment struct {
+	Speaker    string   `json:"speaker"`
+	Label      string   `json:"label"`
+	Score      float64  `json:"score"`
+	Confidence float64  `json:"confidence"`
+	Evidence   []string `json:"evidence"`
+}
+
+// SentimentTrajectory is how sentiment develops from the start to the end of a conversation
+type SentimentTrajectory struct {
+	Start     SentimentScore `json:"start"`
+	Middle    SentimentScore `json:"middle"`
+	End       SentimentScore `json:"end"`
+	Direction string         `json:"direction"` // "improving", "declining" or "stable"
+}
+
+// Cohort is one labeled set of conversations of a comparison
+type Cohort struct {
+	Label         string             `json:"label"`
+	Conversations []ConversationText `json:"conversations"`
+}
+
+// CohortComparison compares two cohorts: their sentiment and resolution outcomes, how
+// their trends differ, and which differences are statistically significant
+type CohortComparison struct {
+	Cohorts           []CohortProfile    `json:"cohorts"`
+	TrendDifferences  []TrendDifference  `json:"trend_differences"`
+	Metrics           []MetricComparison `json:"metrics"`
+	Highlights        []string           `json:"highlights"` // The significant differences, most significant first
+	Summary           string             `json:"summary"`
+	SignificanceLevel float64            `json:"significance_level"`
+}
+
+// CohortProfile is the sentiment and resolution outcome distribution of a cohort
+type CohortProfile struct {
+	Label            string         `json:"label"`
+	Conversations    int            `json:"conversations"`
+	Sentiment        map[string]int `json:"sentiment"` // Conversations per overall sentiment label
+	AverageSentiment float64        `json:"average_sentiment"`
+	Outcomes         map[string]int `json:"outcomes"` // Conversations per resolution outcome
+}
+
+// TrendDifference is how a focus area differs between the two cohorts of a comparison
+type TrendDifference struct {
+	FocusArea  string  `json:"focus_area"`
+	CohortA    string  `json:"cohort_a"` // The trend in the first cohort
+	CohortB    string  `json:"cohort_b"` // The trend in the second cohort
+	Difference string  `json:"difference"`
+	Confidence float64 `json:"confidence"`
+}
+
+// MetricComparison is a metric measured in both cohorts of a comparison, with the
+// p-value of the difference
+type MetricComparison struct {
+	Metric      string  `json:"metric"` // e.g. "sentiment.negative", "outcome.resolved" or "sentiment.average"
+	CohortA     float64 `json:"cohort_a"`
+	CohortB     float64 `json:"cohort_b"`
+	Difference  float64 `json:"difference"` // CohortB minus CohortA
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
 // AnalysisResult represents a persisted analysis result
 type AnalysisResult struct {
 	ID           string    `json:"id"`
@@ -99,6 +585,44 @@ type Recommendation struct {
 	Rationale      string `json:"rationale"`
 	ExpectedImpact string `json:"expected_impact"`
 	Priority       int    `json:"priority"`
+
+	// ImpactEstimate is the estimated share of the problem the action solves, from 0 to 1
+	ImpactEstimate float64 `json:"impact_estimate,omitempty"`
+	// Effort is the effort of implementing the action: low, medium or high
+	Effort        string   `json:"effort,omitempty"`
+	EstimatedCost float64  `json:"estimated_cost,omitempty"`
+	Dependencies  []string `json:"dependencies,omitempty"`
+
+	// Score is the weighted score the recommendations are ranked by, from 0 to 1, with
+	// the score of each criterion it is made of
+	Score           float64                   `json:"score,omitempty"`
+	CriterionScores []RecommendationCriterion `json:"criterion_scores,omitempty"`
+	// WithinBudget is set when the ranking has a budget: false when the cost of the
+	// action and those ranked above it exceeds the budget
+	WithinBudget *bool `json:"within_budget,omitempty"`
+}
+
+// RecommendationCriterion is the score of a recommendation on one criterion of its
+// ranking. Weighted is the score times the normalized weight, so the weighted scores of a
+// recommendation add up to its score.
+type RecommendationCriterion struct {
+	Criterion string  `json:"criterion"`
+	Weight    float64 `json:"weight"`
+	Score     float64 `json:"score"`
+	Weighted  float64 `json:"weighted"`
+	// Basis is the value the score was computed from, e.g. "effort: high"
+	Basis string `json:"basis"`
+}
+
+// RecommendationScoring configures how recommendations are ranked: the weight of each
+// criterion (impact, effort, dependencies, budget and llm_priority) and the budget the
+// estimated costs are checked against. Criteria without a weight use the default weights.
+type RecommendationScoring struct {
+	Weights map[string]float64 `json:"weights,omitempty"`
+	Budget  float64            `json:"budget,omitempty"`
+	// PriorityScale is the highest LLM priority, 5 for generated recommendations and 10
+	// for reprioritized ones
+	PriorityScale int `json:"priority_scale,omitempty"`
 }
 
 // RecommendationResponse represents a full set of recommendations
@@ -106,6 +630,8 @@ type RecommendationResponse struct {
 	ImmediateActions    []Recommendation `json:"immediate_actions"`
 	ImplementationNotes []string         `json:"implementation_notes"`
 	SuccessMetrics      []string         `json:"success_metrics"`
+	// Scoring is the scoring model the immediate actions were ranked with
+	Scoring *RecommendationScoring `json:"scoring,omitempty"`
 }
 
 // CriterionScore represents an evaluation score for a specific criterion
@@ -146,6 +672,9 @@ type ActionPlan struct {
 	Timeline           []TimelineEvent `json:"timeline"`
 	SuccessMetrics     []string        `json:"success_metrics"`
 	RisksMitigations   []RiskItem      `json:"risks_mitigations"`
+
+	// Schedule is the timeline laid out on a working calendar
+	Schedule *TimelineSchedule `json:"schedule,omitempty"`
 }
 
 // ActionItem represents a specific action to be taken
@@ -164,6 +693,49 @@ type TimelineEvent struct {
 	Description string   `json:"description"`
 	Duration    string   `json:"duration"`
 	Milestones  []string `json:"milestones"`
+	// StartDate and EndDate are the YYYY-MM-DD dates of the phase on a working calendar
+	StartDate         string   `json:"start_date,omitempty"`
+	EndDate           string   `json:"end_date,omitempty"`
+	Dependencies      []string `json:"dependencies,omitempty"`
+	ResourcesRequired []string `json:"resources_required,omitempty"`
+	// DurationDays is the duration in working days
+	DurationDays int `json:"duration_days,omitempty"`
+}
+
+// WorkingCalendar is the calendar a timeline is scheduled on
+type WorkingCalendar struct {
+	StartDate string `json:"start_date,omitempty"` // YYYY-MM-DD, today by default
+	// WorkingDays are the weekdays worked, e.g. "monday"; Monday to Friday by default
+	WorkingDays []string `json:"working_days,omitempty"`
+	Holidays    []string `json:"holidays,omitempty"` // YYYY-MM-DD
+	// Capacity is the number of phases each resource can work on at the same time
+	Capacity map[string]int `json:"capacity,omitempty"`
+}
+
+// TimelineSchedule is a timeline laid out on a working calendar
+type TimelineSchedule struct {
+	StartDate      string             `json:"start_date"`
+	EndDate        string             `json:"end_date"`
+	WorkingDays    int                `json:"working_days"`
+	MilestoneDates []MilestoneDate    `json:"milestone_dates"`
+	Conflicts      []ScheduleConflict `json:"conflicts"`
+}
+
+// MilestoneDate is the date a milestone is reached: the end of its phase
+type MilestoneDate struct {
+	Milestone string `json:"milestone"`
+	Phase     string `json:"phase"`
+	Date      string `json:"date"`
+}
+
+// ScheduleConflict is a period in which phases need more of a resource than its capacity
+type ScheduleConflict struct {
+	Resource  string   `json:"resource"`
+	StartDate string   `json:"start_date"`
+	EndDate   string   `json:"end_date"`
+	Phases    []string `json:"phases"`
+	Required  int      `json:"required"`
+	Capacity  int      `json:"capacity"`
 }
 
 // RiskItem represents a risk and its mitigation strategy
@@ -0,0 +1,78 @@
+package processors
+
+import (
+	"context"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/transcript"
+)
+
+// attributesBatchInstructions ask for the values of several numbered transcripts at once
+const attributesBatchInstructions = `
+
+**Several Transcripts:** The input holds several numbered conversation transcripts. Extract the attributes from each one on its own, without letting the others influence it, and return a JSON object with a "conversations" array holding one object per transcript: its number as "index", plus its "attribute_values" as described above.`
+
+// PackAttributeTexts groups the indexes of texts so that each group can share one
+// attribute extraction call: at most maxTexts texts whose transcripts, with the
+// attribute definitions, fit in about maxTokens prompt tokens. Groups keep the order of
+// the texts.
+func PackAttributeTexts(ctx context.Context, texts []string, attributes []models.AttributeDefinition, maxTexts, maxTokens int) [][]int {
+	prompts := make([]string, len(texts))
+	for i, text := range texts {
+		prompts[i] = truncateText(ctx, transcript.ForPrompt(text), MaxAttributeTextChars)
+	}
+	// Tokens are estimated at four characters each, as usage estimates are
+	budget := maxTokens*4 - len(attributesPreamble(attributes)) - len(attributesBatchInstructions)
+	return packTextsWithin(prompts, min(maxTexts, maxPackedTexts), max(budget, 1))
+}
+
+// GenerateAttributesPacked extracts the attributes from several texts in one LLM call,
+// sending the attribute definitions once. The values are mapped back to their texts by
+// transcript number. Texts the reply leaves out or answers with values that can't be read,
+// and all of them when the call fails, are extracted on their own with GenerateAttributes;
+// errs reports the texts that still could not be.
+func (t *TextProcessor) GenerateAttributesPacked(
+	ctx context.Context,
+	texts []string,
+	attributes []models.AttributeDefinition,
+) (values [][]models.AttributeValue, errs []error) {
+	values = make([][]models.AttributeValue, len(texts))
+	errs = make([]error, len(texts))
+
+	// Empty texts are answered without a call
+	var group []int
+	prompts := make([]string, len(texts))
+	for i, text := range texts {
+		if text != "" {
+			group = append(group, i)
+			prompts[i] = truncateText(ctx, transcript.ForPrompt(text), MaxAttributeTextChars)
+		}
+	}
+
+	answered := map[int]map[string]interface{}{}
+	if len(group) > 1 && len(attributes) > 0 {
+		preamble := attributesPreamble(attributes) + attributesBatchInstructions
+		prompt := core.CacheablePrompt(preamble, packedTranscripts(prompts, group))
+		if result, err := t.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.AttributeValuesBatchSchema); err == nil {
+			answered = packedItems(result, "conversations", group)
+		}
+	}
+
+	for i, text := range texts {
+		if item, ok := answered[i]; ok {
+			list, _ := item["attribute_values"].([]interface{})
+			if extracted := attributeValues(list); len(extracted) > 0 {
+				values[i] = t.enforceEnumValues(ctx, text, attributes, extracted)
+				continue
+			}
+		}
+		extracted, err := t.GenerateAttributes(ctx, text, attributes)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		values[i] = extracted
+	}
+	return values, errs
+}
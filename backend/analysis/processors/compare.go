@@ -0,0 +1,357 @@
+package processors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/transcript"
+)
+
+// Resolution outcomes of a conversation
+var resolutionOutcomes = []string{"resolved", "unresolved", "escalated", "follow_up"}
+
+// Limits on the conversations quoted in a comparison prompt
+const (
+	maxCompareConversationChars = 1500
+	maxCompareCohortChars       = 15000
+)
+
+// CompareProcessor compares two cohorts of conversations
+type CompareProcessor struct {
+	analyzer  *core.Analyzer
+	sentiment *SentimentProcessor
+}
+
+// NewCompareProcessor creates a new CompareProcessor
+func NewCompareProcessor(analyzer *core.Analyzer, sentiment *SentimentProcessor) *CompareProcessor {
+	return &CompareProcessor{
+		analyzer:  analyzer,
+		sentiment: sentiment,
+	}
+}
+
+// Compare profiles the sentiment and resolution outcomes of each of two cohorts, tests
+// which differences between them are significant at significanceLevel, and asks the
+// model how their trends differ for the focus areas
+func (c *CompareProcessor) Compare(ctx context.Context, cohorts []models.Cohort, focusAreas []string, significanceLevel float64) (*models.CohortComparison, error) {
+	if len(cohorts) != 2 {
+		return nil, fmt.Errorf("exactly two cohorts are required")
+	}
+	if len(focusAreas) == 0 {
+		return nil, fmt.Errorf("focus areas are required")
+	}
+
+	comparison := &models.CohortComparison{SignificanceLevel: significanceLevel}
+	scores := make([][]float64, len(cohorts))
+	for i, cohort := range cohorts {
+		if len(cohort.Conversations) == 0 {
+			return nil, fmt.Errorf("cohort %s has no conversations", cohort.Label)
+		}
+		texts := make([]string, len(cohort.Conversations))
+		for j, conversation := range cohort.Conversations {
+			texts[j] = conversation.Text
+		}
+
+		sentiments, err := c.sentiment.AnalyzeSentiments(ctx, texts, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cohort %s: %w", cohort.Label, err)
+		}
+		outcomes, err := c.classifyOutcomes(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("cohort %s: %w", cohort.Label, err)
+		}
+
+		profile := models.CohortProfile{
+			Label:         cohort.Label,
+			Conversations: len(texts),
+			Sentiment:     map[string]int{},
+			Outcomes:      map[string]int{},
+		}
+		for _, sentiment := range sentiments {
+			profile.Sentiment[sentiment.Overall.Label]++
+			profile.AverageSentiment += sentiment.Overall.Score / float64(len(texts))
+			scores[i] = append(scores[i], sentiment.Overall.Score)
+		}
+		for _, outcome := range outcomes {
+			profile.Outcomes[outcome]++
+		}
+		comparison.Cohorts = append(comparison.Cohorts, profile)
+	}
+
+	comparison.Metrics = compareMetrics(comparison.Cohorts[0], comparison.Cohorts[1], scores[0], scores[1], significanceLevel)
+	comparison.Highlights = metricHighlights(comparison.Metrics, comparison.Cohorts[0].Label, comparison.Cohorts[1].Label)
+
+	differences, summary, err := c.compareTrends(ctx, cohorts, comparison, focusAreas)
+	if err != nil {
+		return nil, err
+	}
+	comparison.TrendDifferences = differences
+	comparison.Summary = summary
+	return comparison, nil
+}
+
+// classifyOutcomes classifies how each conversation ended, several conversations per LLM
+// call. Conversations a reply leaves out are classified on their own.
+func (c *CompareProcessor) classifyOutcomes(ctx context.Context, texts []string) ([]string, error) {
+	prompts := make([]string, len(texts))
+	for i, text := range texts {
+		prompts[i] = truncateText(ctx, transcript.ForPrompt(text), 8000)
+	}
+
+	outcomes := make([]string, len(texts))
+	for _, group := range packTexts(prompts) {
+		answered, err := c.classifyOutcomeGroup(ctx, prompts, group)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range group {
+			if outcome, ok := answered[i]; ok {
+				outcomes[i] = outcome
+				continue
+			}
+			alone, err := c.classifyOutcomeGroup(ctx, prompts, []int{i})
+			if err != nil {
+				return nil, err
+			}
+			outcomes[i] = alone[i]
+			if outcomes[i] == "" {
+				outcomes[i] = "unresolved"
+			}
+		}
+	}
+	return outcomes, nil
+}
+
+// classifyOutcomeGroup classifies the outcomes of a group of texts in one LLM call
+func (c *CompareProcessor) classifyOutcomeGroup(ctx context.Context, texts []string, group []int) (map[int]string, error) {
+	prompt := core.CacheablePrompt(`Classify how each of the following numbered customer service conversations ended:
+
+- "resolved": the customer's issue was solved during the conversation
+- "unresolved": the conversation ended without solving the issue
+- "escalated": the issue was handed to a supervisor, specialist or another team
+- "follow_up": a solution was promised for later, such as a callback, refund or replacement still to come
+
+Format your response as JSON with one entry per conversation, "index" being its transcript number:
+{
+  "outcomes": [
+    {"index": int, "outcome": str, "confidence": float}
+  ]
+}`, packedTranscripts(texts, group))
+
+	result, err := c.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.ResolutionBatchSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify resolution outcomes: %w", err)
+	}
+
+	outcomes := make(map[int]string, len(group))
+	for i, item := range packedItems(result, "outcomes", group) {
+		outcomes[i] = getString(item, "outcome")
+	}
+	return outcomes, nil
+}
+
+// compareTrends asks the model how the trends of the focus areas differ between the cohorts
+func (c *CompareProcessor) compareTrends(ctx context.Context, cohorts []models.Cohort, comparison *models.CohortComparison, focusAreas []string) ([]models.TrendDifference, string, error) {
+	focusAreasStr, err := json.Marshal(focusAreas)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal focus areas: %w", err)
+	}
+	profilesStr, err := json.MarshalIndent(comparison.Cohorts, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal cohort profiles: %w", err)
+	}
+
+	var data strings.Builder
+	for i, cohort := range cohorts {
+		fmt.Fprintf(&data, "Cohort %s (%q):\n", string(rune('A'+i)), cohort.Label)
+		data.WriteString(cohortExcerpt(ctx, cohort))
+		data.WriteString("\n")
+	}
+
+	preamble := fmt.Sprintf(`Compare two cohorts of customer service conversations, cohort A and cohort B,
+for these focus areas:
+
+Focus Areas:
+%s
+
+The sentiment and resolution outcomes of each cohort have already been counted:
+%s
+
+For each focus area, describe the trend in cohort A, the trend in cohort B, and how they differ.
+Then summarize the most important differences in 2-3 sentences, using the counts above where they apply.
+Do not claim differences the conversations do not support.
+
+Format your response as JSON with these fields:
+{
+  "trend_differences": [
+    {
+      "focus_area": str,
+      "cohort_a": str,
+      "cohort_b": str,
+      "difference": str,
+      "confidence": float
+    }
+  ],
+  "summary": str
+}`, string(focusAreasStr), string(profilesStr))
+
+	result, err := c.analyzer.LLMClient.GenerateStructured(ctx, core.CacheablePrompt(preamble, data.String()), core.CompareSchema)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal comparison: %w", err)
+	}
+	var reply struct {
+		TrendDifferences []models.TrendDifference `json:"trend_differences"`
+		Summary          string                   `json:"summary"`
+	}
+	if err := json.Unmarshal(resultBytes, &reply); err != nil {
+		return nil, "", fmt.Errorf("%w: unexpected comparison format: %w", core.ErrSchemaValidation, err)
+	}
+	if reply.TrendDifferences == nil {
+		reply.TrendDifferences = []models.TrendDifference{}
+	}
+	for i := range reply.TrendDifferences {
+		reply.TrendDifferences[i].Confidence = clamp(reply.TrendDifferences[i].Confidence, 0, 1)
+	}
+	return reply.TrendDifferences, reply.Summary, nil
+}
+
+// cohortExcerpt quotes the conversations of a cohort for a prompt, each shortened and
+// the whole cohort bounded, noting how many conversations were left out
+func cohortExcerpt(ctx context.Context, cohort models.Cohort) string {
+	var b strings.Builder
+	size, quoted := 0, 0
+	for _, conversation := range cohort.Conversations {
+		text := truncateText(ctx, transcript.ForPrompt(conversation.Text), maxCompareConversationChars)
+		if quoted > 0 && size+len(text) > maxCompareCohortChars {
+			break
+		}
+		fmt.Fprintf(&b, "Conversation %d:\n%s\n\n", quoted+1, text)
+		size += len(text)
+		quoted++
+	}
+	if left := len(cohort.Conversations) - quoted; left > 0 {
+		fmt.Fprintf(&b, "(%d more conversations of this cohort are not shown)\n", left)
+		core.Warn(ctx, core.WarningInputTruncated, fmt.Sprintf("only %d of the %d conversations of cohort %q were quoted to the model", quoted, len(cohort.Conversations), cohort.Label))
+	}
+	return b.String()
+}
+
+// compareMetrics compares the share of each sentiment label and resolution outcome with
+// a two-proportion z-test, and the average sentiment score with Welch's test under a
+// normal approximation
+func compareMetrics(a, b models.CohortProfile, scoresA, scoresB []float64, significanceLevel float64) []models.MetricComparison {
+	var metrics []models.MetricComparison
+
+	labels := []string{"positive", "neutral", "negative", "mixed"}
+	for _, distribution := range []struct {
+		prefix string
+		keys   []string
+		a, b   map[string]int
+	}{
+		{"sentiment.", labels, a.Sentiment, b.Sentiment},
+		{"outcome.", resolutionOutcomes, a.Outcomes, b.Outcomes},
+	} {
+		for _, key := range distribution.keys {
+			countA, countB := distribution.a[key], distribution.b[key]
+			if countA+countB == 0 {
+				continue
+			}
+			shareA := float64(countA) / float64(a.Conversations)
+			shareB := float64(countB) / float64(b.Conversations)
+			metrics = append(metrics, metricComparison(distribution.prefix+key, shareA, shareB,
+				twoProportionPValue(countA, a.Conversations, countB, b.Conversations), significanceLevel))
+		}
+	}
+
+	meanA, varA := meanVariance(scoresA)
+	meanB, varB := meanVariance(scoresB)
+	pValue := 1.0
+	if len(scoresA) > 1 && len(scoresB) > 1 {
+		if se := math.Sqrt(varA/float64(len(scoresA)) + varB/float64(len(scoresB))); se > 0 {
+			pValue = normalPValue((meanB - meanA) / se)
+		}
+	}
+	metrics = append(metrics, metricComparison("sentiment.average", meanA, meanB, pValue, significanceLevel))
+
+	sort.SliceStable(metrics, func(i, j int) bool { return metrics[i].PValue < metrics[j].PValue })
+	return metrics
+}
+
+// metricComparison builds the comparison of a metric
+func metricComparison(metric string, a, b, pValue, significanceLevel float64) models.MetricComparison {
+	return models.MetricComparison{
+		Metric:      metric,
+		CohortA:     a,
+		CohortB:     b,
+		Difference:  b - a,
+		PValue:      pValue,
+		Significant: pValue < significanceLevel,
+	}
+}
+
+// metricHighlights describes the significant metric differences, in the order given
+func metricHighlights(metrics []models.MetricComparison, labelA, labelB string) []string {
+	highlights := []string{}
+	for _, metric := range metrics {
+		if !metric.Significant {
+			continue
+		}
+		kind, name, _ := strings.Cut(metric.Metric, ".")
+		if metric.Metric == "sentiment.average" {
+			highlights = append(highlights, fmt.Sprintf("Average sentiment score is %.2f in %s vs %.2f in %s (p=%.3f)",
+				metric.CohortA, labelA, metric.CohortB, labelB, metric.PValue))
+			continue
+		}
+		name = strings.ReplaceAll(name, "_", " ")
+		highlights = append(highlights, fmt.Sprintf("%s%s %s: %.0f%% of %s vs %.0f%% of %s (%+.0f points, p=%.3f)",
+			strings.ToUpper(name[:1]), name[1:], kind, 100*metric.CohortA, labelA, 100*metric.CohortB, labelB,
+			100*metric.Difference, metric.PValue))
+	}
+	return highlights
+}
+
+// twoProportionPValue is the two-sided p-value of the difference between x1 of n1 and x2 of n2
+func twoProportionPValue(x1, n1, x2, n2 int) float64 {
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 1
+	}
+	return normalPValue((float64(x2)/float64(n2) - float64(x1)/float64(n1)) / se)
+}
+
+// normalPValue is the two-sided p-value of a standard normal z statistic
+func normalPValue(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// meanVariance returns the mean and sample variance of values
+func meanVariance(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, squares / float64(len(values)-1)
+}
@@ -0,0 +1,178 @@
+package processors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+)
+
+// maxExcerptSourceChars bounds how much of each conversation is included in an explanation prompt
+const maxExcerptSourceChars = 4000
+
+// ExplanationProcessor explains individual items of analysis results
+type ExplanationProcessor struct {
+	analyzer *core.Analyzer
+}
+
+// NewExplanationProcessor creates a new ExplanationProcessor
+func NewExplanationProcessor(analyzer *core.Analyzer) *ExplanationProcessor {
+	return &ExplanationProcessor{
+		analyzer: analyzer,
+	}
+}
+
+// ExplainItem explains why an item of an analysis result holds, quoting supporting
+// excerpts from the conversations the result was derived from
+func (e *ExplanationProcessor) ExplainItem(
+	ctx context.Context,
+	analysisType string,
+	item interface{},
+	conversations []models.ConversationText,
+	maxExcerpts int,
+) (*models.Explanation, error) {
+	if item == nil {
+		return nil, fmt.Errorf("item is required")
+	}
+
+	itemBytes, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal item: %w", err)
+	}
+
+	conversationsStr := sourceConversationsPrompt(conversations,
+		"No source conversations are available; explain the item from its own content and say so in the caveats.")
+
+	prompt := fmt.Sprintf(`Explain the following item from a %s analysis of customer conversations.
+
+Item:
+%s
+
+Source conversations:
+%s
+Explain in detail what the item means and why the data supports it. Quote at most %d short
+excerpts, copied verbatim from the source conversations, that best support or qualify the item,
+and identify the conversation each one comes from. Note any evidence that contradicts the item.
+
+Format your response as JSON with these fields:
+{
+  "explanation": str,
+  "reasoning": [str],
+  "supporting_excerpts": [
+    {
+      "conversation_id": str,
+      "excerpt": str,
+      "relevance": str
+    }
+  ],
+  "caveats": [str],
+  "confidence": float
+}`, analysisType, string(itemBytes), conversationsStr, maxExcerpts)
+
+	return e.explain(ctx, prompt, conversations, maxExcerpts)
+}
+
+// ExplainAnomaly explains what in the conversations of its time bucket caused an anomaly of
+// a time series, such as a spike in disputes, quoting at most maxExcerpts excerpts from them
+func (e *ExplanationProcessor) ExplainAnomaly(
+	ctx context.Context,
+	anomaly interface{},
+	conversations []models.ConversationText,
+	maxExcerpts int,
+) (*models.Explanation, error) {
+	anomalyBytes, err := json.MarshalIndent(anomaly, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal anomaly: %w", err)
+	}
+
+	conversationsStr := sourceConversationsPrompt(conversations,
+		"No conversations from the time of the anomaly are available; say that the cause cannot be confirmed in the caveats.")
+
+	prompt := fmt.Sprintf(`A time series aggregated from customer conversations deviates from its expected value.
+The anomaly below was detected statistically: "score" is the number of standard deviations the value is
+from the expected value, and "direction" tells whether it is a spike or a drop.
+
+Anomaly:
+%s
+
+Conversations from the time of the anomaly:
+%s
+Explain what most likely caused the anomaly, based on the conversations. List the likely causes as
+reasoning, most likely first. Quote at most %d short excerpts, copied verbatim from the conversations,
+that point to the cause, and identify the conversation each one comes from. Do not claim a cause the
+conversations do not support, and note in the caveats when the anomaly may be noise or a data issue.
+
+Format your response as JSON with these fields:
+{
+  "explanation": str,
+  "reasoning": [str],
+  "supporting_excerpts": [
+    {
+      "conversation_id": str,
+      "excerpt": str,
+      "relevance": str
+    }
+  ],
+  "caveats": [str],
+  "confidence": float
+}`, string(anomalyBytes), conversationsStr, maxExcerpts)
+
+	return e.explain(ctx, prompt, conversations, maxExcerpts)
+}
+
+// sourceConversationsPrompt lists conversations for an explanation prompt, each cut to
+// maxExcerptSourceChars, or returns none when there are no conversations
+func sourceConversationsPrompt(conversations []models.ConversationText, none string) string {
+	if len(conversations) == 0 {
+		return none
+	}
+	var sb strings.Builder
+	for _, conversation := range conversations {
+		text := conversation.Text
+		if len(text) > maxExcerptSourceChars {
+			text = text[:maxExcerptSourceChars] + "..."
+		}
+		fmt.Fprintf(&sb, "Conversation %s:\n%s\n\n", conversation.ConversationID, text)
+	}
+	return sb.String()
+}
+
+// explain generates an explanation and keeps at most maxExcerpts of its excerpts, those
+// attributed to the conversations the prompt quoted
+func (e *ExplanationProcessor) explain(ctx context.Context, prompt string, conversations []models.ConversationText, maxExcerpts int) (*models.Explanation, error) {
+	result, err := e.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.ExplanationSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal explanation: %w", err)
+	}
+	var explanation models.Explanation
+	if err := json.Unmarshal(resultBytes, &explanation); err != nil {
+		return nil, fmt.Errorf("%w: unexpected explanation format: %w", core.ErrSchemaValidation, err)
+	}
+
+	// Keep only excerpts attributed to conversations that were actually provided
+	known := make(map[string]bool, len(conversations))
+	for _, conversation := range conversations {
+		known[conversation.ConversationID] = true
+	}
+	excerpts := make([]models.SupportingExcerpt, 0, len(explanation.SupportingExcerpts))
+	for _, excerpt := range explanation.SupportingExcerpts {
+		if excerpt.Excerpt == "" || !known[excerpt.ConversationID] {
+			continue
+		}
+		excerpts = append(excerpts, excerpt)
+		if len(excerpts) == maxExcerpts {
+			break
+		}
+	}
+	explanation.SupportingExcerpts = excerpts
+
+	return &explanation, nil
+}
@@ -0,0 +1,114 @@
+package processors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+)
+
+// maxFindingsTextChars bounds the conversation text included in a findings prompt
+const maxFindingsTextChars = 20000
+
+// FindingsProcessor answers questions from analysis data
+type FindingsProcessor struct {
+	analyzer *core.Analyzer
+}
+
+// NewFindingsProcessor creates a new FindingsProcessor
+func NewFindingsProcessor(analyzer *core.Analyzer) *FindingsProcessor {
+	return &FindingsProcessor{
+		analyzer: analyzer,
+	}
+}
+
+// AnalyzeFindings answers each question from the data and text of the request, and
+// judges how completely the data covers it. Questions the data cannot fully answer name
+// the attributes that are missing, given the attributes the data already has. Answers
+// cite the conversations of the data that support them.
+func (p *FindingsProcessor) AnalyzeFindings(ctx context.Context, req models.AnalysisRequest, attributes []string) (map[string]interface{}, error) {
+	if len(req.Questions) == 0 {
+		return nil, fmt.Errorf("questions are required")
+	}
+	if req.Text == "" && len(req.AttributeValues) == 0 {
+		return nil, fmt.Errorf("text or data is required")
+	}
+
+	questionsStr, err := json.Marshal(req.Questions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal questions: %w", err)
+	}
+	attributesStr := "None are named; infer them from the data."
+	if len(attributes) > 0 {
+		attributesStr = strings.Join(attributes, ", ")
+	}
+
+	definitions, dataStr := "", "No data provided"
+	if len(req.AttributeValues) > 0 {
+		definitions, dataStr, err = core.FormatPromptDataSections(req.AttributeValues)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal data: %w", err)
+		}
+	}
+	input := "Data:\n" + dataStr
+	if req.Text != "" {
+		input += "\n\nConversations:\n" + truncateText(ctx, req.Text, maxFindingsTextChars)
+	}
+
+	preamble := fmt.Sprintf(`Answer each of these questions using only the data below:
+
+Questions:
+%s
+
+Attributes available in the data: %s
+
+For each question, judge how completely the data answers it:
+- "answered": the data fully answers the question
+- "partial": the data answers some aspects of the question, but not all
+- "unanswerable": the data does not contain what the question needs
+
+For partial and unanswerable questions, name the attributes that are missing from the data
+and would be needed to answer the question fully, as snake_case field names. Do not name
+attributes the data already has. Never guess answers the data does not support.
+
+Cite the conversations that support each answer: give the ID each has in the data, its
+conversation_id or id, and quote the passage that supports the answer verbatim. Cite only conversation
+IDs that appear in the data; an answer without a citation is treated as unsupported.
+
+Format your response as JSON with one finding per question, in the order of the questions:
+{
+  "findings": [
+    {
+      "question": str,
+      "answer": str,
+      "supporting_evidence": [str],
+      "confidence": float,
+      "coverage": "answered" | "partial" | "unanswerable",
+      "missing_attributes": [str],
+      "citations": [
+        {
+          "conversation_id": str,
+          "quote": str
+        }
+      ]
+    }
+  ],
+  "recommendations": [str]
+}
+
+%s`, string(questionsStr), attributesStr, definitions)
+	prompt := core.CacheablePrompt(strings.TrimRight(preamble, "\n"), input)
+
+	result, err := p.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.FindingsSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+	findings, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected findings format", core.ErrSchemaValidation)
+	}
+	return findings, nil
+}
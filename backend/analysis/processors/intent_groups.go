@@ -0,0 +1,484 @@
+package processors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"agenticflows/backend/analysis/core"
+)
+
+// Hierarchical grouping of intents
+const (
+	// intentGroupBatchSize is how many intents one grouping call sees
+	intentGroupBatchSize = 50
+	// intentGroupConcurrency is how many grouping or merge calls run at once
+	intentGroupConcurrency = 4
+	// maxIntentGroupExamples is how many of its intents a group lists as examples
+	maxIntentGroupExamples = 7
+	// otherIntentsGroup collects the intents the model left out of every group
+	otherIntentsGroup = "Other"
+)
+
+// groupedIntent is an intent with the number of conversations classified with it
+type groupedIntent struct {
+	name  string
+	count int
+}
+
+// intentGroup is a group of intents. Its occurrences are counted from its intents rather
+// than taken from the model, so they stay exact however often groups are merged.
+type intentGroup struct {
+	name         string
+	description  string
+	significance string
+	intents      []groupedIntent
+}
+
+// occurrences sums the counts of the group's intents
+func (g intentGroup) occurrences() int {
+	total := 0
+	for _, intent := range g.intents {
+		total += intent.count
+	}
+	return total
+}
+
+// pattern formats the group as an intent_groups pattern. Examples are its most frequent
+// intents; intents lists all of them.
+func (g intentGroup) pattern() map[string]interface{} {
+	intents := append([]groupedIntent(nil), g.intents...)
+	sort.SliceStable(intents, func(i, j int) bool { return intents[i].count > intents[j].count })
+
+	names := make([]string, len(intents))
+	for i, intent := range intents {
+		names[i] = intent.name
+	}
+	return map[string]interface{}{
+		"pattern_type":        g.name,
+		"pattern_description": g.description,
+		"occurrences":         g.occurrences(),
+		"examples":            names[:min(len(names), maxIntentGroupExamples)],
+		"intents":             names,
+		"significance":        g.significance,
+	}
+}
+
+// processIntentsIteratively groups intents map-reduce style. Batches of intents are grouped
+// concurrently, then the group lists are merged pairwise, concurrently within each round,
+// until at most maxGroups groups remain. Every group keeps track of the intents it holds,
+// so occurrences are recomputed from the intents' counts after each merge.
+func (p *PatternsAnalyzer) processIntentsIteratively(
+	ctx context.Context,
+	intents interface{},
+	maxGroups int,
+	minCount int,
+) (interface{}, error) {
+	if maxGroups < 1 {
+		return nil, fmt.Errorf("max_groups must be at least 1")
+	}
+	filteredIntents, err := parseGroupedIntents(intents, minCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(filteredIntents) == 0 {
+		return map[string]interface{}{
+			"patterns":            []interface{}{},
+			"unexpected_patterns": []interface{}{},
+		}, nil
+	}
+
+	if p.analyzer.Debug {
+		log.Printf("Processing %d intents (after filtering by min_count=%d)", len(filteredIntents), minCount)
+	}
+
+	// Map: group each batch of intents
+	var batches [][]groupedIntent
+	for i := 0; i < len(filteredIntents); i += intentGroupBatchSize {
+		batches = append(batches, filteredIntents[i:min(i+intentGroupBatchSize, len(filteredIntents))])
+	}
+	lists := make([][]intentGroup, len(batches))
+	errs := make([]error, len(batches))
+	runIntentGrouping(ctx, len(batches), func(i int) {
+		lists[i], errs[i] = p.groupIntentBatch(ctx, batches[i], maxGroups)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		failed++
+		log.Printf("Error grouping intent batch %d: %v", i+1, err)
+		// The batch's intents stay in the result as groups of their own for the merges to place
+		lists[i] = singletonGroups(batches[i])
+	}
+	if failed == len(batches) {
+		return nil, fmt.Errorf("failed to group intents: %w", errs[0])
+	}
+
+	// Reduce: merge neighbouring lists in rounds until few enough groups remain
+	rounds := 0
+	for len(lists) > 1 && countGroups(lists) > maxGroups {
+		rounds++
+		if p.analyzer.Debug {
+			log.Printf("Merge round %d: %d group lists, %d groups", rounds, len(lists), countGroups(lists))
+		}
+		merged := make([][]intentGroup, (len(lists)+1)/2)
+		runIntentGrouping(ctx, len(merged), func(i int) {
+			if 2*i+1 == len(lists) {
+				merged[i] = lists[2*i]
+				return
+			}
+			merged[i] = p.mergeIntentGroups(ctx, combineIntentGroups(lists[2*i], lists[2*i+1]), maxGroups)
+		})
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lists = merged
+	}
+
+	groups := combineIntentGroups(lists...)
+	if len(groups) > maxGroups {
+		rounds++
+		groups = p.mergeIntentGroups(ctx, groups, maxGroups)
+	}
+	if len(groups) > maxGroups {
+		// The model would not merge far enough, so the smallest groups become one
+		groups = foldSmallestGroups(groups, maxGroups)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].occurrences() > groups[j].occurrences() })
+
+	patterns := make([]interface{}, len(groups))
+	for i, group := range groups {
+		patterns[i] = group.pattern()
+	}
+	return map[string]interface{}{
+		"patterns":            patterns,
+		"unexpected_patterns": []interface{}{},
+		"grouping": map[string]interface{}{
+			"intents":        len(filteredIntents),
+			"batches":        len(batches),
+			"failed_batches": failed,
+			"merge_rounds":   rounds,
+		},
+	}, nil
+}
+
+// groupIntentBatch asks the model to group a batch of intents into at most maxGroups
+// groups. Intents the model leaves out are collected in an "Other" group.
+func (p *PatternsAnalyzer) groupIntentBatch(ctx context.Context, batch []groupedIntent, maxGroups int) ([]intentGroup, error) {
+	listed := make([]map[string]interface{}, len(batch))
+	for i, intent := range batch {
+		listed[i] = map[string]interface{}{"intent": intent.name, "count": intent.count}
+	}
+	intentsList, err := json.Marshal(listed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal intents: %w", err)
+	}
+
+	prompt := fmt.Sprintf(`Group the following intents into semantic categories:
+
+Intents:
+%s
+
+Your task is to group these intents into at most %d semantic categories based on their meaning and purpose.
+For each group:
+1. Assign a descriptive category name
+2. List every intent of the group in examples, copied exactly as it appears in the input
+3. Provide a brief description of the group
+
+Every intent belongs to exactly one group.
+
+Format your response as JSON with these fields:
+{
+  "patterns": [
+    {
+      "pattern_type": str,        // This should be the category/group name
+      "pattern_description": str,  // Description of what this group represents
+      "occurrences": int,         // How many intents belong to this group
+      "examples": [str],          // All intents in this group, exactly as given
+      "significance": str         // Brief explanation of why this grouping is meaningful
+    }
+  ],
+  "unexpected_patterns": []
+}`, string(intentsList), maxGroups)
+
+	result, err := p.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.IntentGroupsSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content for intent groups: %w", err)
+	}
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
+	}
+	patterns, _ := resultMap["patterns"].([]interface{})
+
+	byName := make(map[string]groupedIntent, len(batch))
+	for _, intent := range batch {
+		byName[groupKey(intent.name)] = intent
+	}
+	assigned := map[string]bool{}
+	var groups []intentGroup
+	for _, pattern := range patterns {
+		group, members := groupFromPattern(pattern)
+		for _, member := range members {
+			key := groupKey(member)
+			intent, ok := byName[key]
+			if !ok || assigned[key] {
+				continue
+			}
+			assigned[key] = true
+			group.intents = append(group.intents, intent)
+		}
+		if len(group.intents) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	other := intentGroup{name: otherIntentsGroup, description: "Intents not placed in another group"}
+	for _, intent := range batch {
+		if !assigned[groupKey(intent.name)] {
+			other.intents = append(other.intents, intent)
+		}
+	}
+	if len(other.intents) > 0 {
+		groups = append(groups, other)
+	}
+	return groups, nil
+}
+
+// mergeIntentGroups asks the model to consolidate groups into at most maxGroups
+// higher-level groups, each naming the groups it combines. A merged group holds the
+// intents of the groups it combines; groups the model leaves out are kept as they are.
+// When the model fails, the groups are returned unmerged.
+func (p *PatternsAnalyzer) mergeIntentGroups(ctx context.Context, groups []intentGroup, maxGroups int) []intentGroup {
+	if len(groups) <= maxGroups {
+		return groups
+	}
+
+	groupDescriptions := make([]string, 0, len(groups))
+	for _, group := range groups {
+		pattern := group.pattern()
+		groupDescriptions = append(groupDescriptions, fmt.Sprintf("%s: %s. Examples: %s",
+			group.name, group.description, strings.Join(pattern["examples"].([]string), ", ")))
+	}
+
+	prompt := fmt.Sprintf(`You are a label clustering expert. Your task is to consolidate similar intent groups into higher-level categories.
+
+INPUT GROUPS TO CONSOLIDATE:
+%s
+
+Rules:
+1. Group similar intent categories together under a common, higher-level category
+2. Maintain semantic meaning
+3. Use consistent labeling style (Title Case)
+4. Maximum number of consolidated groups: %d
+5. Every input group belongs to exactly one consolidated group
+
+Format your response as JSON with these fields:
+{
+  "consolidated_groups": [
+    {
+      "pattern_type": str,        // The higher-level category name
+      "pattern_description": str,  // Description of what this group represents
+      "occurrences": int,         // How many original groups belong to this category
+      "examples": [str],          // The names of all input groups in this category, exactly as given
+      "significance": str         // Brief explanation of why this grouping is meaningful
+    }
+  ]
+}`, strings.Join(groupDescriptions, "\n"), maxGroups)
+
+	result, err := p.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.ConsolidatedGroupsSchema)
+	if err != nil {
+		log.Printf("Error merging %d intent groups: %v", len(groups), err)
+		return groups
+	}
+	resultMap, _ := result.(map[string]interface{})
+	consolidated, _ := resultMap["consolidated_groups"].([]interface{})
+
+	byName := make(map[string]int, len(groups))
+	for i, group := range groups {
+		byName[groupKey(group.name)] = i
+	}
+	merged := make([]intentGroup, 0, maxGroups)
+	assigned := make([]bool, len(groups))
+	for _, pattern := range consolidated {
+		group, members := groupFromPattern(pattern)
+		for _, member := range members {
+			i, ok := byName[groupKey(member)]
+			if !ok || assigned[i] {
+				continue
+			}
+			assigned[i] = true
+			group.intents = append(group.intents, groups[i].intents...)
+		}
+		if len(group.intents) > 0 {
+			merged = append(merged, group)
+		}
+	}
+	for i, group := range groups {
+		if !assigned[i] {
+			merged = append(merged, group)
+		}
+	}
+	return combineIntentGroups(merged)
+}
+
+// parseGroupedIntents reads the intents of a request, named by "intent" (or "name" or
+// "label_name") with a "count", keeping those with at least minCount conversations.
+// Repeated intents are counted once, with their counts added up.
+func parseGroupedIntents(intents interface{}, minCount int) ([]groupedIntent, error) {
+	intentsList, ok := intents.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("intents must be an array")
+	}
+
+	var parsed []groupedIntent
+	index := map[string]int{}
+	for _, intentObj := range intentsList {
+		intent, ok := intentObj.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var name string
+		for _, key := range []string{"intent", "name", "label_name"} {
+			if value, ok := intent[key].(string); ok && strings.TrimSpace(value) != "" {
+				name = strings.TrimSpace(value)
+				break
+			}
+		}
+		var count int
+		switch value := intent["count"].(type) {
+		case float64:
+			count = int(value)
+		case int:
+			count = value
+		default:
+			continue
+		}
+		if name == "" {
+			continue
+		}
+
+		if i, seen := index[groupKey(name)]; seen {
+			parsed[i].count += count
+			continue
+		}
+		index[groupKey(name)] = len(parsed)
+		parsed = append(parsed, groupedIntent{name: name, count: count})
+	}
+
+	filtered := parsed[:0]
+	for _, intent := range parsed {
+		if intent.count >= minCount {
+			filtered = append(filtered, intent)
+		}
+	}
+	return filtered, nil
+}
+
+// groupFromPattern reads a group, without intents, and the names of its members from a
+// pattern answered by the model
+func groupFromPattern(pattern interface{}) (intentGroup, []string) {
+	patternMap, _ := pattern.(map[string]interface{})
+	var group intentGroup
+	group.name, _ = patternMap["pattern_type"].(string)
+	group.description, _ = patternMap["pattern_description"].(string)
+	group.significance, _ = patternMap["significance"].(string)
+	if strings.TrimSpace(group.name) == "" {
+		group.name = otherIntentsGroup
+	}
+
+	examples, _ := patternMap["examples"].([]interface{})
+	members := make([]string, 0, len(examples))
+	for _, example := range examples {
+		if member, ok := example.(string); ok {
+			members = append(members, member)
+		}
+	}
+	return group, members
+}
+
+// combineIntentGroups concatenates group lists, joining groups of the same name
+func combineIntentGroups(lists ...[]intentGroup) []intentGroup {
+	var combined []intentGroup
+	index := map[string]int{}
+	for _, list := range lists {
+		for _, group := range list {
+			if i, seen := index[groupKey(group.name)]; seen {
+				combined[i].intents = append(combined[i].intents, group.intents...)
+				continue
+			}
+			index[groupKey(group.name)] = len(combined)
+			group.intents = append([]groupedIntent(nil), group.intents...)
+			combined = append(combined, group)
+		}
+	}
+	return combined
+}
+
+// foldSmallestGroups keeps the maxGroups-1 largest groups and joins the rest into an
+// "Other" group, so no intent is dropped
+func foldSmallestGroups(groups []intentGroup, maxGroups int) []intentGroup {
+	sorted := append([]intentGroup(nil), groups...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].occurrences() > sorted[j].occurrences() })
+
+	keep := max(maxGroups-1, 0)
+	other := intentGroup{name: otherIntentsGroup, description: "Intents not placed in another group"}
+	for _, group := range sorted[keep:] {
+		other.intents = append(other.intents, group.intents...)
+	}
+	return combineIntentGroups(sorted[:keep], []intentGroup{other})
+}
+
+// singletonGroups makes a group of each intent
+func singletonGroups(intents []groupedIntent) []intentGroup {
+	groups := make([]intentGroup, len(intents))
+	for i, intent := range intents {
+		groups[i] = intentGroup{name: intent.name, intents: []groupedIntent{intent}}
+	}
+	return groups
+}
+
+// countGroups counts the groups of all lists
+func countGroups(lists [][]intentGroup) int {
+	total := 0
+	for _, list := range lists {
+		total += len(list)
+	}
+	return total
+}
+
+// groupKey is the case-insensitive key by which intents and groups are matched
+func groupKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// runIntentGrouping runs n grouping or merge calls, intentGroupConcurrency at a time.
+// Calls not yet started when the context ends are skipped.
+func runIntentGrouping(ctx context.Context, n int, call func(i int)) {
+	sem := make(chan struct{}, intentGroupConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			call(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,73 @@
+package processors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits on the conversations packed into one LLM call
+const (
+	maxPackedTexts = 20
+	maxPackedChars = 12000
+)
+
+// packTexts groups the indexes of texts so that each group fits in one LLM call. Groups
+// keep the order of the texts; a text too long to share a call gets a group of its own.
+func packTexts(texts []string) [][]int {
+	return packTextsWithin(texts, maxPackedTexts, maxPackedChars)
+}
+
+// packTextsWithin groups texts like packTexts, with at most maxTexts texts and maxChars
+// characters per group
+func packTextsWithin(texts []string, maxTexts, maxChars int) [][]int {
+	var groups [][]int
+	var group []int
+	size := 0
+	for i, text := range texts {
+		if len(group) > 0 && (len(group) == maxTexts || size+len(text) > maxChars) {
+			groups = append(groups, group)
+			group, size = nil, 0
+		}
+		group = append(group, i)
+		size += len(text)
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// packedTranscripts formats the texts of a group as transcripts numbered from 1, which
+// is the "index" the reply refers to them by
+func packedTranscripts(texts []string, group []int) string {
+	var b strings.Builder
+	for n, i := range group {
+		if n > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "Conversation Transcript %d:\n%s", n+1, texts[i])
+	}
+	return b.String()
+}
+
+// packedItems maps the items of a packed reply to the indexes of the texts they answer.
+// Items with an index outside the group, or answering a text already answered, are dropped.
+func packedItems(result interface{}, key string, group []int) map[int]map[string]interface{} {
+	items := make(map[int]map[string]interface{}, len(group))
+	resultMap, _ := result.(map[string]interface{})
+	list, _ := resultMap[key].([]interface{})
+	for _, raw := range list {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		n := int(getFloat(item, "index"))
+		if n < 1 || n > len(group) {
+			continue
+		}
+		if _, seen := items[group[n-1]]; !seen {
+			items[group[n-1]] = item
+		}
+	}
+	return items
+}
@@ -110,18 +110,7 @@ Format as JSON:
   ]
 }`, string(recsBytes), string(constraintsBytes))
 
-	expectedFormat := map[string]interface{}{
-		"goals":               []interface{}{},
-		"immediate_actions":   []interface{}{},
-		"short_term_actions":  []interface{}{},
-		"long_term_actions":   []interface{}{},
-		"responsible_parties": []interface{}{},
-		"timeline":            []interface{}{},
-		"success_metrics":     []interface{}{},
-		"risks_mitigations":   []interface{}{},
-	}
-
-	result, err := p.analyzer.LLMClient.GenerateContent(ctx, prompt, expectedFormat)
+	result, err := p.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.ActionPlanSchema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
@@ -129,7 +118,7 @@ Format as JSON:
 	// Parse the result into ActionPlan
 	resultMap, ok := result.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("unexpected result format")
+		return nil, fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
 	}
 
 	plan := &models.ActionPlan{}
@@ -211,30 +200,49 @@ Format as JSON:
 		}
 	}
 
+	limitPlanLength(plan, core.OutputStyleFromContext(ctx).MaxLength)
 	return plan, nil
 }
 
-// GenerateTimeline generates an implementation timeline for an action plan
+// GenerateTimeline generates an implementation timeline for an action plan. The model
+// proposes the phases and their durations; the dates are computed from them on the working
+// calendar, the default calendar when it is nil, and returned with the resource conflicts
+// in the schedule.
 func (p *PlannerProcessor) GenerateTimeline(
 	ctx context.Context,
 	actionPlan *models.ActionPlan,
 	resources map[string]interface{},
-) ([]models.TimelineEvent, error) {
+	calendar *models.WorkingCalendar,
+) ([]models.TimelineEvent, *models.TimelineSchedule, error) {
 	// Validate input
 	if actionPlan == nil {
-		return nil, fmt.Errorf("action plan is required")
+		return nil, nil, fmt.Errorf("action plan is required")
+	}
+	if calendar == nil {
+		calendar = &models.WorkingCalendar{}
+	}
+	if err := ValidateWorkingCalendar(*calendar); err != nil {
+		return nil, nil, err
 	}
 
 	// Format action plan for the prompt
 	planBytes, err := json.Marshal(actionPlan)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal action plan: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal action plan: %w", err)
 	}
 
-	// Format resources for the prompt
+	// Format resources for the prompt; the capacities of the calendar are resources too
+	if len(calendar.Capacity) > 0 {
+		merged := make(map[string]interface{}, len(resources)+1)
+		for key, value := range resources {
+			merged[key] = value
+		}
+		merged["capacity"] = calendar.Capacity
+		resources = merged
+	}
 	resourcesBytes, err := json.Marshal(resources)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal resources: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal resources: %w", err)
 	}
 
 	prompt := fmt.Sprintf(`Generate a detailed implementation timeline for this action plan:
@@ -246,67 +254,79 @@ Available Resources:
 %s
 
 Create a realistic implementation timeline considering dependencies between actions and available resources.
-Include key phases, milestones, and estimated durations.
+Include key phases, milestones, and estimated durations in working days. Do not propose dates: they are
+computed from the durations on the working calendar. List as dependencies the names of the earlier phases
+a phase must wait for, and as resources_required the resources a phase needs.
 
 Format as JSON:
-[
-  {
-    "phase": str,
-    "description": str,
-    "duration": str,
-    "milestones": [str],
-    "start_date": str,
-    "end_date": str,
-    "dependencies": [str],
-    "resources_required": [str]
-  }
-]`, string(planBytes), string(resourcesBytes))
-
-	result, err := p.analyzer.LLMClient.GenerateContent(ctx, prompt, []interface{}{})
+{
+  "timeline": [
+    {
+      "phase": str,
+      "description": str,
+      "duration": str,
+      "duration_days": int,
+      "milestones": [str],
+      "dependencies": [str],
+      "resources_required": [str]
+    }
+  ]
+}`, string(planBytes), string(resourcesBytes))
+
+	result, err := p.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.ImplementationTimelineSchema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate content: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 
-	// Parse the result into TimelineEvents
-	resultArray, ok := result.([]interface{})
-	if !ok {
-		resultMap, isMap := result.(map[string]interface{})
-		if !isMap {
-			return nil, fmt.Errorf("unexpected result format")
-		}
-
-		// Check if result is wrapped in a 'timeline' field
-		if timeline, ok := resultMap["timeline"].([]interface{}); ok {
-			resultArray = timeline
-		} else {
-			return nil, fmt.Errorf("unexpected result format, missing timeline array")
-		}
-	}
+	// The schema guarantees a timeline array
+	resultMap, _ := result.(map[string]interface{})
+	resultArray, _ := resultMap["timeline"].([]interface{})
 
 	// Convert to TimelineEvent objects
 	timeline := make([]models.TimelineEvent, 0, len(resultArray))
 	for _, eventRaw := range resultArray {
 		if eventMap, ok := eventRaw.(map[string]interface{}); ok {
 			event := models.TimelineEvent{
-				Phase:       getString(eventMap, "phase"),
-				Description: getString(eventMap, "description"),
-				Duration:    getString(eventMap, "duration"),
+				Phase:             getString(eventMap, "phase"),
+				Description:       getString(eventMap, "description"),
+				Duration:          getString(eventMap, "duration"),
+				DurationDays:      int(getFloat(eventMap, "duration_days")),
+				Milestones:        getStrings(eventMap, "milestones"),
+				Dependencies:      getStrings(eventMap, "dependencies"),
+				ResourcesRequired: getStrings(eventMap, "resources_required"),
 			}
+			timeline = append(timeline, event)
+		}
+	}
+	limitTimelineLength(timeline, core.OutputStyleFromContext(ctx).MaxLength)
 
-			// Extract milestones
-			if milestonesRaw, ok := eventMap["milestones"].([]interface{}); ok {
-				for _, milestoneRaw := range milestonesRaw {
-					if milestone, ok := milestoneRaw.(string); ok && milestone != "" {
-						event.Milestones = append(event.Milestones, milestone)
-					}
-				}
-			}
+	return ScheduleTimeline(timeline, *calendar)
+}
 
-			timeline = append(timeline, event)
+// limitPlanLength shortens the descriptive texts of an action plan the model wrote longer
+// than the max_length of the output style asked for
+func limitPlanLength(plan *models.ActionPlan, maxWords int) {
+	if maxWords <= 0 {
+		return
+	}
+	for _, actions := range [][]models.ActionItem{plan.ImmediateActions, plan.ShortTermActions, plan.LongTermActions} {
+		for i := range actions {
+			actions[i].Description = core.LimitWords(actions[i].Description, maxWords)
 		}
 	}
+	limitTimelineLength(plan.Timeline, maxWords)
+	for i := range plan.RisksMitigations {
+		risk := &plan.RisksMitigations[i]
+		risk.MitigationPlan = core.LimitWords(risk.MitigationPlan, maxWords)
+		risk.ContingencyPlan = core.LimitWords(risk.ContingencyPlan, maxWords)
+	}
+}
 
-	return timeline, nil
+// limitTimelineLength shortens the phase descriptions of a timeline to maxWords words
+func limitTimelineLength(timeline []models.TimelineEvent, maxWords int) {
+	for i := range timeline {
+		timeline[i].Description = core.LimitWords(timeline[i].Description, maxWords)
+	}
 }
 
 // extractActionItems extracts action items from a result map for a given key
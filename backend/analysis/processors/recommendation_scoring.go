@@ -0,0 +1,194 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// Criteria of the recommendation ranking
+const (
+	CriterionImpact       = "impact"
+	CriterionEffort       = "effort"
+	CriterionDependencies = "dependencies"
+	CriterionBudget       = "budget"
+	CriterionLLMPriority  = "llm_priority"
+)
+
+// DefaultRecommendationWeights are the weights of the criteria a scoring model leaves out
+var DefaultRecommendationWeights = map[string]float64{
+	CriterionImpact:       0.35,
+	CriterionEffort:       0.2,
+	CriterionDependencies: 0.1,
+	CriterionBudget:       0.1,
+	CriterionLLMPriority:  0.25,
+}
+
+// defaultPriorityScale is the highest priority of generated recommendations
+const defaultPriorityScale = 5
+
+// effortScores scores the effort levels, cheaper first
+var effortScores = map[string]float64{"low": 1, "medium": 0.5, "high": 0}
+
+// ValidateRecommendationScoring checks that a scoring model only weighs known criteria,
+// with weights that are not negative
+func ValidateRecommendationScoring(scoring models.RecommendationScoring) error {
+	for criterion, weight := range scoring.Weights {
+		if _, ok := DefaultRecommendationWeights[criterion]; !ok {
+			return fmt.Errorf("unknown scoring criterion %q", criterion)
+		}
+		if weight < 0 || math.IsNaN(weight) {
+			return fmt.Errorf("weight of scoring criterion %q must not be negative", criterion)
+		}
+	}
+	if scoring.Budget < 0 {
+		return fmt.Errorf("budget must not be negative")
+	}
+	if scoring.PriorityScale < 0 {
+		return fmt.Errorf("priority_scale must not be negative")
+	}
+	return nil
+}
+
+// ScoreRecommendations ranks recommendations by a weighted score of their structured
+// fields and the priority the LLM gave them. Each recommendation reports its score on
+// every criterion, so the ranking can be checked. The budget is allotted in ranking
+// order: a recommendation whose estimated cost no longer fits in what is left of it
+// scores 0 on the budget criterion and leaves the rest to those ranked below it. Without
+// a budget the criterion is left out. The recommendations are returned best first, with
+// Priority untouched.
+func ScoreRecommendations(recommendations []models.Recommendation, scoring models.RecommendationScoring) []models.Recommendation {
+	weights := RecommendationWeights(scoring)
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	scale := scoring.PriorityScale
+	if scale == 0 {
+		scale = defaultPriorityScale
+	}
+
+	ranked := make([]models.Recommendation, len(recommendations))
+	copy(ranked, recommendations)
+
+	// Rank without the budget first, then allot it in that order
+	for i := range ranked {
+		scoreRecommendation(&ranked[i], weights, total, scale, nil)
+	}
+	sortRecommendations(ranked)
+	if scoring.Budget > 0 {
+		var spent float64
+		for i := range ranked {
+			spent += ranked[i].EstimatedCost
+			within := spent <= scoring.Budget
+			if !within {
+				// A recommendation that doesn't fit leaves the budget to the next ones
+				spent -= ranked[i].EstimatedCost
+			}
+			scoreRecommendation(&ranked[i], weights, total, scale, &within)
+		}
+		sortRecommendations(ranked)
+	}
+	return ranked
+}
+
+// RecommendationWeights returns the weights a scoring model ranks by: its own weights,
+// the default weights of the criteria it leaves out, and no budget criterion without a
+// budget
+func RecommendationWeights(scoring models.RecommendationScoring) map[string]float64 {
+	weights := make(map[string]float64, len(DefaultRecommendationWeights))
+	for criterion, weight := range DefaultRecommendationWeights {
+		weights[criterion] = weight
+	}
+	for criterion, weight := range scoring.Weights {
+		weights[criterion] = weight
+	}
+	if scoring.Budget == 0 {
+		delete(weights, CriterionBudget)
+	}
+	return weights
+}
+
+// scoreRecommendation sets the score of a recommendation and its criterion scores.
+// within is nil until the budget is allotted.
+func scoreRecommendation(rec *models.Recommendation, weights map[string]float64, total float64, scale int, within *bool) {
+	var criteria []models.RecommendationCriterion
+	add := func(criterion string, score float64, basis string) {
+		weight, ok := weights[criterion]
+		if !ok {
+			return
+		}
+		if total > 0 {
+			weight /= total
+		}
+		criteria = append(criteria, models.RecommendationCriterion{
+			Criterion: criterion,
+			Weight:    round2(weight),
+			Score:     round2(score),
+			Weighted:  weight * score,
+			Basis:     basis,
+		})
+	}
+
+	if rec.ImpactEstimate > 0 {
+		add(CriterionImpact, math.Min(rec.ImpactEstimate, 1), fmt.Sprintf("impact estimate: %.2f", rec.ImpactEstimate))
+	} else {
+		add(CriterionImpact, 0.5, "impact not estimated")
+	}
+
+	if score, ok := effortScores[strings.ToLower(rec.Effort)]; ok {
+		add(CriterionEffort, score, "effort: "+strings.ToLower(rec.Effort))
+	} else {
+		add(CriterionEffort, 0.5, "effort not estimated")
+	}
+
+	add(CriterionDependencies, 1/float64(1+len(rec.Dependencies)), fmt.Sprintf("%d dependencies", len(rec.Dependencies)))
+
+	switch {
+	case within == nil:
+		// The budget is not allotted yet; score as if it fit
+		add(CriterionBudget, 1, "")
+	case *within:
+		add(CriterionBudget, 1, fmt.Sprintf("estimated cost %.2f fits the budget", rec.EstimatedCost))
+	default:
+		add(CriterionBudget, 0, fmt.Sprintf("estimated cost %.2f exceeds the remaining budget", rec.EstimatedCost))
+	}
+
+	if rec.Priority > 0 {
+		priority := math.Min(float64(rec.Priority), float64(scale))
+		score := 1.0
+		if scale > 1 {
+			score = (priority - 1) / float64(scale-1)
+		}
+		add(CriterionLLMPriority, score, fmt.Sprintf("LLM priority: %d of %d", rec.Priority, scale))
+	} else {
+		add(CriterionLLMPriority, 0.5, "no LLM priority")
+	}
+
+	var score float64
+	for i := range criteria {
+		score += criteria[i].Weighted
+		criteria[i].Weighted = round2(criteria[i].Weighted)
+	}
+	rec.Score = round2(score)
+	rec.CriterionScores = criteria
+	rec.WithinBudget = within
+}
+
+// sortRecommendations orders recommendations by score, then by LLM priority
+func sortRecommendations(recommendations []models.Recommendation) {
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Priority > recommendations[j].Priority
+	})
+}
+
+// round2 rounds a score to two decimals
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 
 	"agenticflows/backend/analysis/core"
 	"agenticflows/backend/analysis/models"
@@ -36,20 +37,17 @@ func (r *RecommendationsProcessor) GenerateRecommendations(
 	}
 
 	// Format analysis results for the prompt
-	analysisBytes, err := json.Marshal(analysisResults)
+	definitions, analysisData, err := core.FormatPromptDataSections(analysisResults)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal analysis results: %w", err)
 	}
 
-	prompt := fmt.Sprintf(`Based on this analysis focused on %s:
-
-%s
-
-Generate specific, actionable recommendations. Consider:
+	preamble := fmt.Sprintf(`Based on the analysis below, focused on %s, generate specific, actionable recommendations. Consider:
 1. Immediate actions that can be taken
 2. Rationale for each recommendation
 3. Expected impact of implementation
 4. Priority level (1-5, where 5 is highest)
+5. Estimated share of the problem the action solves (0-1), the effort of implementing it (low, medium or high), its estimated cost and the actions or teams it depends on
 
 Format your response as JSON with these fields:
 {
@@ -58,27 +56,21 @@ Format your response as JSON with these fields:
       "action": str,
       "rationale": str,
       "expected_impact": str,
-      "priority": int
+      "priority": int,
+      "impact_estimate": float,
+      "effort": "low" | "medium" | "high",
+      "estimated_cost": float,
+      "dependencies": [str]
     }
   ],
   "implementation_notes": [str],
   "success_metrics": [str]
-}`, focusArea, string(analysisBytes))
-
-	expectedFormat := map[string]interface{}{
-		"immediate_actions": []interface{}{
-			map[string]interface{}{
-				"action":          "",
-				"rationale":       "",
-				"expected_impact": "",
-				"priority":        0,
-			},
-		},
-		"implementation_notes": []interface{}{},
-		"success_metrics":      []interface{}{},
-	}
-
-	result, err := r.analyzer.LLMClient.GenerateContent(ctx, prompt, expectedFormat)
+}
+
+%s`, focusArea, definitions)
+	prompt := core.CacheablePrompt(preamble, "Analysis:\n"+analysisData)
+
+	result, err := r.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.RecommendationsSchema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
@@ -86,7 +78,7 @@ Format your response as JSON with these fields:
 	// Parse the result into RecommendationResponse
 	resultMap, ok := result.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("unexpected result format")
+		return nil, fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
 	}
 
 	// Extract recommendations
@@ -96,13 +88,7 @@ Format your response as JSON with these fields:
 	if actionsRaw, ok := resultMap["immediate_actions"].([]interface{}); ok {
 		for _, actionRaw := range actionsRaw {
 			if actionMap, ok := actionRaw.(map[string]interface{}); ok {
-				rec := models.Recommendation{
-					Action:         getString(actionMap, "action"),
-					Rationale:      getString(actionMap, "rationale"),
-					ExpectedImpact: getString(actionMap, "expected_impact"),
-					Priority:       int(getFloat(actionMap, "priority")),
-				}
-				response.ImmediateActions = append(response.ImmediateActions, rec)
+				response.ImmediateActions = append(response.ImmediateActions, parseRecommendation(actionMap))
 			}
 		}
 	}
@@ -125,10 +111,30 @@ Format your response as JSON with these fields:
 		}
 	}
 
+	limitRecommendationLength(response, core.OutputStyleFromContext(ctx).MaxLength)
 	return response, nil
 }
 
-// PrioritizeRecommendations prioritizes recommendations based on given criteria
+// limitRecommendationLength shortens the descriptive texts of recommendations the model
+// wrote longer than the max_length of the output style asked for
+func limitRecommendationLength(response *models.RecommendationResponse, maxWords int) {
+	if maxWords <= 0 {
+		return
+	}
+	for i := range response.ImmediateActions {
+		action := &response.ImmediateActions[i]
+		action.Rationale = core.LimitWords(action.Rationale, maxWords)
+		action.ExpectedImpact = core.LimitWords(action.ExpectedImpact, maxWords)
+	}
+	for i, note := range response.ImplementationNotes {
+		response.ImplementationNotes[i] = core.LimitWords(note, maxWords)
+	}
+}
+
+// PrioritizeRecommendations prioritizes recommendations based on given criteria. The LLM
+// assigns each a priority from the weighted criteria, which is merged with the scoring
+// model: criteria named after a scoring criterion (impact, effort, dependencies, budget)
+// also weigh it. The recommendations are returned ranked by their score.
 func (r *RecommendationsProcessor) PrioritizeRecommendations(
 	ctx context.Context,
 	recommendations []models.Recommendation,
@@ -165,29 +171,23 @@ Prioritization Criteria (with weights):
 Review each recommendation and re-prioritize them based on the weighted criteria.
 Assign a new priority score (1-10) to each, where 10 is highest priority.
 
-Return the reprioritized recommendations as JSON with the same structure as the input, but with updated priority values.
+Return the reprioritized recommendations as JSON with the same fields as the input, but with updated priority values.
 Include a brief explanation of why each recommendation received its new priority.
-Format as a JSON array.`, string(recsBytes), string(criteriaBytes))
+Format as a JSON object with a "recommendations" array.`, string(recsBytes), string(criteriaBytes))
 
-	result, err := r.analyzer.LLMClient.GenerateContent(ctx, prompt, []interface{}{})
+	result, err := r.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.PrioritizedRecommendationsSchema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 
-	// Parse the result into a list of recommendations
-	resultArray, ok := result.([]interface{})
-	if !ok {
-		resultMap, isMap := result.(map[string]interface{})
-		if !isMap {
-			return nil, fmt.Errorf("unexpected result format")
-		}
+	// The schema guarantees a recommendations array
+	resultMap, _ := result.(map[string]interface{})
+	resultArray, _ := resultMap["recommendations"].([]interface{})
 
-		// Check if result is wrapped in a 'recommendations' field
-		if recs, ok := resultMap["recommendations"].([]interface{}); ok {
-			resultArray = recs
-		} else {
-			return nil, fmt.Errorf("unexpected result format, missing recommendations array")
-		}
+	// The LLM only assigns priorities; the structured fields are kept from the input
+	byAction := make(map[string]models.Recommendation, len(recommendations))
+	for _, rec := range recommendations {
+		byAction[rec.Action] = rec
 	}
 
 	// Convert to Recommendation objects
@@ -200,11 +200,23 @@ Format as a JSON array.`, string(recsBytes), string(criteriaBytes))
 				ExpectedImpact: getString(recMap, "expected_impact"),
 				Priority:       int(getFloat(recMap, "priority")),
 			}
+			if input, ok := byAction[rec.Action]; ok {
+				rec.ImpactEstimate = input.ImpactEstimate
+				rec.Effort = input.Effort
+				rec.EstimatedCost = input.EstimatedCost
+				rec.Dependencies = input.Dependencies
+			}
 			prioritizedRecs = append(prioritizedRecs, rec)
 		}
 	}
 
-	return prioritizedRecs, nil
+	scoring := models.RecommendationScoring{Weights: map[string]float64{}, PriorityScale: 10}
+	for criterion, weight := range criteria {
+		if _, ok := DefaultRecommendationWeights[criterion]; ok && criterion != CriterionLLMPriority {
+			scoring.Weights[criterion] = weight
+		}
+	}
+	return ScoreRecommendations(prioritizedRecs, scoring), nil
 }
 
 // GenerateRetentionStrategies generates retention strategy recommendations
@@ -218,16 +230,12 @@ func (r *RecommendationsProcessor) GenerateRetentionStrategies(
 	}
 
 	// Format analysis results for the prompt
-	analysisBytes, err := json.Marshal(analysisResults)
+	definitions, analysisData, err := core.FormatPromptDataSections(analysisResults)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal analysis results: %w", err)
 	}
 
-	prompt := fmt.Sprintf(`Based on this analysis of customer cancellations and retention efforts:
-
-%s
-
-Recommend specific, actionable steps to improve customer retention. Consider:
+	preamble := fmt.Sprintf(`Based on the analysis below of customer cancellations and retention efforts, recommend specific, actionable steps to improve customer retention. Consider:
 1. Immediate changes to agent behavior
 2. Process improvements
 3. Most effective retention offers
@@ -247,24 +255,12 @@ Format as JSON:
   "process_changes": [str],
   "training_needs": [str],
   "success_metrics": [str]
-}`, string(analysisBytes))
-
-	expectedFormat := map[string]interface{}{
-		"target_segment": "",
-		"immediate_actions": []interface{}{
-			map[string]interface{}{
-				"action":          "",
-				"rationale":       "",
-				"expected_impact": "",
-				"priority":        0,
-			},
-		},
-		"process_changes": []interface{}{},
-		"training_needs":  []interface{}{},
-		"success_metrics": []interface{}{},
-	}
-
-	result, err := r.analyzer.LLMClient.GenerateContent(ctx, prompt, expectedFormat)
+}
+
+%s`, definitions)
+	prompt := core.CacheablePrompt(preamble, "Analysis:\n"+analysisData)
+
+	result, err := r.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.RetentionStrategySchema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
@@ -272,7 +268,7 @@ Format as JSON:
 	// Parse the result into RetentionStrategy
 	resultMap, ok := result.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("unexpected result format")
+		return nil, fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
 	}
 
 	strategy := &models.RetentionStrategy{
@@ -283,13 +279,7 @@ Format as JSON:
 	if actionsRaw, ok := resultMap["immediate_actions"].([]interface{}); ok {
 		for _, actionRaw := range actionsRaw {
 			if actionMap, ok := actionRaw.(map[string]interface{}); ok {
-				rec := models.Recommendation{
-					Action:         getString(actionMap, "action"),
-					Rationale:      getString(actionMap, "rationale"),
-					ExpectedImpact: getString(actionMap, "expected_impact"),
-					Priority:       int(getFloat(actionMap, "priority")),
-				}
-				strategy.ImmediateActions = append(strategy.ImmediateActions, rec)
+				strategy.ImmediateActions = append(strategy.ImmediateActions, parseRecommendation(actionMap))
 			}
 		}
 	}
@@ -324,6 +314,20 @@ Format as JSON:
 	return strategy, nil
 }
 
+// parseRecommendation converts a recommendation of the LLM output
+func parseRecommendation(m map[string]interface{}) models.Recommendation {
+	return models.Recommendation{
+		Action:         getString(m, "action"),
+		Rationale:      getString(m, "rationale"),
+		ExpectedImpact: getString(m, "expected_impact"),
+		Priority:       int(getFloat(m, "priority")),
+		ImpactEstimate: math.Max(0, math.Min(getFloat(m, "impact_estimate"), 1)),
+		Effort:         getString(m, "effort"),
+		EstimatedCost:  math.Max(0, getFloat(m, "estimated_cost")),
+		Dependencies:   getStrings(m, "dependencies"),
+	}
+}
+
 // Helper functions for type conversion
 
 // getString safely extracts a string from a map
@@ -336,6 +340,19 @@ func getString(m map[string]interface{}, key string) string {
 	return ""
 }
 
+// getStrings extracts the non-empty strings of a list in a map
+func getStrings(m map[string]interface{}, key string) []string {
+	var strs []string
+	if list, ok := m[key].([]interface{}); ok {
+		for _, item := range list {
+			if str, ok := item.(string); ok && str != "" {
+				strs = append(strs, str)
+			}
+		}
+	}
+	return strs
+}
+
 // getFloat safely extracts a float from a map
 func getFloat(m map[string]interface{}, key string) float64 {
 	if val, ok := m[key]; ok {
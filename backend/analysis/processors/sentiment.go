@@ -0,0 +1,304 @@
+package processors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/transcript"
+)
+
+// sentimentLabels are the labels a sentiment score may carry
+var sentimentLabels = map[string]bool{
+	"positive": true,
+	"neutral":  true,
+	"negative": true,
+	"mixed":    true,
+}
+
+// SentimentProcessor analyzes the sentiment of conversations
+type SentimentProcessor struct {
+	analyzer *core.Analyzer
+}
+
+// NewSentimentProcessor creates a new SentimentProcessor
+func NewSentimentProcessor(analyzer *core.Analyzer) *SentimentProcessor {
+	return &SentimentProcessor{
+		analyzer: analyzer,
+	}
+}
+
+// AnalyzeSentiment analyzes the overall sentiment of a conversation, the sentiment of
+// each speaker, and how sentiment changes from its start to its end. speakers optionally
+// names the participants to report on; otherwise they are taken from the transcript.
+func (s *SentimentProcessor) AnalyzeSentiment(ctx context.Context, text string, speakers []string) (*models.SentimentAnalysis, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+
+	// Structured transcripts are sent as numbered turns, so the speakers and the thirds
+	// of the conversation follow the turns rather than the model's reading of the text
+	turns := transcript.Parse(text)
+	transcriptStr := truncateText(ctx, text, 8000)
+	thirds := ""
+	if transcript.Structured(turns) {
+		if len(speakers) == 0 {
+			speakers = transcript.Speakers(turns)
+		}
+		transcriptStr = truncateText(ctx, transcript.Format(turns), 8000)
+		if len(turns) >= 3 {
+			thirds = fmt.Sprintf(" The conversation has %d turns: the first third is turns 1-%d, the middle third turns %d-%d and the last third turns %d-%d.",
+				len(turns), len(turns)/3, len(turns)/3+1, 2*len(turns)/3, 2*len(turns)/3+1, len(turns))
+		}
+	}
+
+	speakersStr := "every participant in the transcript (e.g. Customer and Agent)"
+	if len(speakers) > 0 {
+		speakersStr = strings.Join(speakers, ", ")
+	}
+
+	instructions := fmt.Sprintf(`Analyze the sentiment of the following customer service conversation.
+
+Report:
+1. The overall sentiment of the conversation.
+2. The sentiment of each of these speakers: %s. Quote up to 3 short phrases they said as evidence.
+3. The sentiment trajectory: the sentiment in the first, middle and last third of the conversation,
+   and whether it is "improving", "declining" or "stable" overall.%s
+
+Every sentiment has a label ("positive", "neutral", "negative" or "mixed"), a score from -1.0
+(very negative) to 1.0 (very positive), and a confidence from 0.0 to 1.0.
+
+Format your response as JSON with these fields:
+{
+  "overall": {"label": str, "score": float, "confidence": float},
+  "speakers": [
+    {"speaker": str, "label": str, "score": float, "confidence": float, "evidence": [str]}
+  ],
+  "trajectory": {
+    "start": {"label": str, "score": float, "confidence": float},
+    "middle": {"label": str, "score": float, "confidence": float},
+    "end": {"label": str, "score": float, "confidence": float},
+    "direction": str
+  },
+  "summary": str
+}`, speakersStr, thirds)
+	prompt := core.CacheablePrompt(instructions, "Conversation Transcript:\n"+transcriptStr)
+
+	result, err := s.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.SentimentSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sentiment: %w", err)
+	}
+	var sentiment models.SentimentAnalysis
+	if err := json.Unmarshal(resultBytes, &sentiment); err != nil {
+		return nil, fmt.Errorf("%w: unexpected sentiment format: %w", core.ErrSchemaValidation, err)
+	}
+
+	normalizeSentiment(&sentiment)
+	return &sentiment, nil
+}
+
+// AnalyzeSentiments analyzes the sentiment of each of several conversations, reporting
+// on the same speakers as AnalyzeSentiment. Short conversations are packed into one LLM
+// call, up to maxPackedTexts at a time; any the packed reply leaves out are analyzed on
+// their own.
+func (s *SentimentProcessor) AnalyzeSentiments(ctx context.Context, texts []string, speakers []string) ([]models.SentimentAnalysis, error) {
+	prompts := make([]string, len(texts))
+	for i, text := range texts {
+		if text == "" {
+			return nil, fmt.Errorf("text %d is empty", i+1)
+		}
+		prompts[i] = truncateText(ctx, transcript.ForPrompt(text), 8000)
+	}
+
+	speakersStr := "every participant in the transcript (e.g. Customer and Agent)"
+	if len(speakers) > 0 {
+		speakersStr = strings.Join(speakers, ", ")
+	}
+	instructions := fmt.Sprintf(`Analyze the sentiment of each of the following numbered customer service conversations.
+Analyze each conversation on its own, without letting the others influence it.
+
+For each conversation report:
+1. The overall sentiment of the conversation.
+2. The sentiment of each of these speakers: %s. Quote up to 3 short phrases they said as evidence.
+3. The sentiment trajectory: the sentiment in the first, middle and last third of the conversation,
+   and whether it is "improving", "declining" or "stable" overall.
+
+Every sentiment has a label ("positive", "neutral", "negative" or "mixed"), a score from -1.0
+(very negative) to 1.0 (very positive), and a confidence from 0.0 to 1.0.
+
+Format your response as JSON with one entry per conversation, "index" being its transcript number:
+{
+  "sentiments": [
+    {
+      "index": int,
+      "overall": {"label": str, "score": float, "confidence": float},
+      "speakers": [
+        {"speaker": str, "label": str, "score": float, "confidence": float, "evidence": [str]}
+      ],
+      "trajectory": {
+        "start": {"label": str, "score": float, "confidence": float},
+        "middle": {"label": str, "score": float, "confidence": float},
+        "end": {"label": str, "score": float, "confidence": float},
+        "direction": str
+      },
+      "summary": str
+    }
+  ]
+}`, speakersStr)
+
+	sentiments := make([]models.SentimentAnalysis, len(texts))
+	for _, group := range packTexts(prompts) {
+		answered := map[int]map[string]interface{}{}
+		if len(group) > 1 {
+			prompt := core.CacheablePrompt(instructions, packedTranscripts(prompts, group))
+			result, err := s.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.SentimentBatchSchema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate content: %w", err)
+			}
+			answered = packedItems(result, "sentiments", group)
+		}
+
+		for _, i := range group {
+			if item, ok := answered[i]; ok {
+				resultBytes, err := json.Marshal(item)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal sentiment: %w", err)
+				}
+				if err := json.Unmarshal(resultBytes, &sentiments[i]); err != nil {
+					return nil, fmt.Errorf("%w: unexpected sentiment format: %w", core.ErrSchemaValidation, err)
+				}
+				normalizeSentiment(&sentiments[i])
+				continue
+			}
+			sentiment, err := s.AnalyzeSentiment(ctx, texts[i], speakers)
+			if err != nil {
+				return nil, err
+			}
+			sentiments[i] = *sentiment
+		}
+	}
+	return sentiments, nil
+}
+
+// AverageSentiment combines the sentiment of several conversations: scores and
+// confidences are averaged, per speaker over the conversations the speaker appears in,
+// and labels and the trajectory direction follow the averaged scores
+func AverageSentiment(sentiments []models.SentimentAnalysis) models.SentimentAnalysis {
+	average := models.SentimentAnalysis{Speakers: []models.SpeakerSentiment{}}
+	if len(sentiments) == 0 {
+		normalizeSentiment(&average)
+		return average
+	}
+
+	n := float64(len(sentiments))
+	speakerIndex := map[string]int{}
+	speakerCounts := []float64{}
+	for _, sentiment := range sentiments {
+		for _, pair := range []struct{ sum, score *models.SentimentScore }{
+			{&average.Overall, &sentiment.Overall},
+			{&average.Trajectory.Start, &sentiment.Trajectory.Start},
+			{&average.Trajectory.Middle, &sentiment.Trajectory.Middle},
+			{&average.Trajectory.End, &sentiment.Trajectory.End},
+		} {
+			pair.sum.Score += pair.score.Score / n
+			pair.sum.Confidence += pair.score.Confidence / n
+		}
+
+		for _, speaker := range sentiment.Speakers {
+			i, ok := speakerIndex[speaker.Speaker]
+			if !ok {
+				i = len(average.Speakers)
+				speakerIndex[speaker.Speaker] = i
+				average.Speakers = append(average.Speakers, models.SpeakerSentiment{Speaker: speaker.Speaker, Evidence: []string{}})
+				speakerCounts = append(speakerCounts, 0)
+			}
+			average.Speakers[i].Score += speaker.Score
+			average.Speakers[i].Confidence += speaker.Confidence
+			speakerCounts[i]++
+		}
+	}
+	for i := range average.Speakers {
+		average.Speakers[i].Score /= speakerCounts[i]
+		average.Speakers[i].Confidence /= speakerCounts[i]
+	}
+
+	average.Summary = fmt.Sprintf("Average sentiment of %d conversations.", len(sentiments))
+	normalizeSentiment(&average)
+	return average
+}
+
+// normalizeSentiment clamps scores to their ranges and fills in labels and the
+// trajectory direction the model left out or got wrong
+func normalizeSentiment(sentiment *models.SentimentAnalysis) {
+	normalizeSentimentScore(&sentiment.Overall)
+
+	for i := range sentiment.Speakers {
+		speaker := &sentiment.Speakers[i]
+		score := models.SentimentScore{Label: speaker.Label, Score: speaker.Score, Confidence: speaker.Confidence}
+		normalizeSentimentScore(&score)
+		speaker.Label, speaker.Score, speaker.Confidence = score.Label, score.Score, score.Confidence
+		if speaker.Evidence == nil {
+			speaker.Evidence = []string{}
+		}
+	}
+	if sentiment.Speakers == nil {
+		sentiment.Speakers = []models.SpeakerSentiment{}
+	}
+
+	trajectory := &sentiment.Trajectory
+	normalizeSentimentScore(&trajectory.Start)
+	normalizeSentimentScore(&trajectory.Middle)
+	normalizeSentimentScore(&trajectory.End)
+
+	direction := strings.ToLower(strings.TrimSpace(trajectory.Direction))
+	if direction != "improving" && direction != "declining" && direction != "stable" {
+		switch change := trajectory.End.Score - trajectory.Start.Score; {
+		case change > 0.2:
+			direction = "improving"
+		case change < -0.2:
+			direction = "declining"
+		default:
+			direction = "stable"
+		}
+	}
+	trajectory.Direction = direction
+}
+
+// normalizeSentimentScore clamps a score to [-1, 1] and its confidence to [0, 1],
+// deriving the label from the score when it is missing or unknown
+func normalizeSentimentScore(score *models.SentimentScore) {
+	score.Score = clamp(score.Score, -1, 1)
+	score.Confidence = clamp(score.Confidence, 0, 1)
+
+	label := strings.ToLower(strings.TrimSpace(score.Label))
+	if !sentimentLabels[label] {
+		switch {
+		case score.Score >= 0.25:
+			label = "positive"
+		case score.Score <= -0.25:
+			label = "negative"
+		default:
+			label = "neutral"
+		}
+	}
+	score.Label = label
+}
+
+// clamp limits value to [lo, hi]
+func clamp(value, lo, hi float64) float64 {
+	if value < lo {
+		return lo
+	}
+	if value > hi {
+		return hi
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package processors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"agenticflows/backend/analysis/transcript"
+)
+
+// Summary lengths, in characters
+const (
+	// DefaultSummaryChars is the length summaries are written to unless a request asks otherwise
+	DefaultSummaryChars = 1000
+	// MinSummaryChars and MaxSummaryChars bound the summary length a request may ask for
+	MinSummaryChars = 200
+	MaxSummaryChars = 4000
+)
+
+// summaryWindowChars is how much of a conversation one summarization prompt shows the
+// model; longer conversations are summarized window by window
+const summaryWindowChars = 12000
+
+// SummarizeTranscript summarizes a conversation in at most about maxChars characters,
+// keeping what later analyses need: why the customer got in touch, what was done, the
+// outcome, and amounts, dates and identifiers verbatim. Conversations longer than one
+// prompt are split into windows at line breaks, each summarized into its share of
+// maxChars, so nothing is cut off.
+func (t *TextProcessor) SummarizeTranscript(ctx context.Context, text string, maxChars int) (string, error) {
+	if maxChars <= 0 {
+		maxChars = DefaultSummaryChars
+	}
+
+	windows := splitWindows(transcript.ForPrompt(text), summaryWindowChars)
+	budget := maxChars / max(len(windows), 1)
+	summaries := make([]string, len(windows))
+	for i, window := range windows {
+		part := "the customer service conversation"
+		if len(windows) > 1 {
+			part = fmt.Sprintf("part %d of %d of a customer service conversation", i+1, len(windows))
+		}
+		prompt := fmt.Sprintf(`Summarize %s below for an analyst who will not read it.
+
+State why the customer got in touch, what the agent did, and how it ended. Keep amounts, dates, product names and identifiers verbatim, and note the customer's sentiment when it is clear. Do not invent details. Write plain prose in the language of the conversation, in at most %d characters, and return only the summary.
+
+Conversation:
+%s`, part, budget, window)
+
+		result, err := t.analyzer.LLMClient.GenerateContent(ctx, prompt, "")
+		if err != nil {
+			if len(windows) > 1 {
+				return "", fmt.Errorf("failed to summarize part %d of %d: %w", i+1, len(windows), err)
+			}
+			return "", fmt.Errorf("failed to summarize conversation: %w", err)
+		}
+		summary, ok := result.(string)
+		if !ok {
+			return "", fmt.Errorf("unexpected result type: %T", result)
+		}
+		summaries[i] = strings.TrimSpace(summary)
+	}
+	return strings.Join(summaries, "\n"), nil
+}
@@ -3,11 +3,21 @@ package processors
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"agenticflows/backend/analysis/core"
 	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/transcript"
+	"agenticflows/backend/analysis/validation"
 )
 
+// MaxAttributeTextChars is how much of a text attribute extraction shows the model; the
+// rest is cut off unless the text is condensed first
+const MaxAttributeTextChars = 8000
+
 // TextProcessor handles text generation and attribute extraction
 type TextProcessor struct {
 	analyzer *core.Analyzer
@@ -62,11 +72,7 @@ Return a JSON object with this structure:
   ]
 }`, questionsText, existingText)
 
-	expectedFormat := map[string]interface{}{
-		"attributes": []interface{}{},
-	}
-
-	result, err := t.analyzer.LLMClient.GenerateContent(ctx, prompt, expectedFormat)
+	result, err := t.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.RequiredAttributesSchema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
@@ -74,7 +80,7 @@ Return a JSON object with this structure:
 	// Extract attributes from the result
 	resultMap, ok := result.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("unexpected result format")
+		return nil, fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
 	}
 
 	attributesRaw, ok := resultMap["attributes"].([]interface{})
@@ -126,7 +132,7 @@ func (t *TextProcessor) GenerateAttribute(
 
 Attribute: %s
 Description: %s
-
+%s
 Text to analyze:
 %s
 
@@ -138,15 +144,9 @@ Return a JSON object with this structure:
 }
 
 Ensure the response is specific to the attribute definition and supported by the text content.`,
-		attribute.Title, attribute.Description, truncateText(text, 5000))
-
-	expectedFormat := map[string]interface{}{
-		"value":       "",
-		"confidence":  0.0,
-		"explanation": "",
-	}
+		attribute.Title, attribute.Description, speakerInstruction(attribute), truncateText(ctx, transcript.ForPrompt(text), 5000))
 
-	result, err := t.analyzer.LLMClient.GenerateContent(ctx, prompt, expectedFormat)
+	result, err := t.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.AttributeValueSchema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
@@ -154,7 +154,7 @@ Ensure the response is specific to the attribute definition and supported by the
 	// Extract values from the result
 	resultMap, ok := result.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("unexpected result format")
+		return nil, fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
 	}
 
 	// Create attribute value
@@ -192,19 +192,46 @@ func (t *TextProcessor) GenerateAttributes(
 		return []models.AttributeValue{}, nil
 	}
 
-	// Format attributes for the prompt
-	attributesText := ""
-	for _, attr := range attributes {
-		attributesText += fmt.Sprintf("Attribute: %s\nField Name: %s\nDescription: %s\n\n",
-			attr.Title, attr.FieldName, attr.Description)
+	result, err := t.analyzer.LLMClient.GenerateStructured(ctx, attributesPrompt(ctx, text, attributes), core.AttributeValuesSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	// Extract values from the result
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
 	}
 
-	prompt := fmt.Sprintf(`Analyze this text to determine values for the following attributes:
+	attrValuesRaw, ok := resultMap["attribute_values"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("attribute_values field is not an array")
+	}
 
-%s
-Text to analyze:
-%s
+	return t.enforceEnumValues(ctx, text, attributes, attributeValues(attrValuesRaw)), nil
+}
 
+// AttributesCached reports whether the values of attributes in text are in the LLM
+// response cache, so GenerateAttributes answers without calling the model
+func (t *TextProcessor) AttributesCached(ctx context.Context, text string, attributes []models.AttributeDefinition) bool {
+	if text == "" || len(attributes) == 0 {
+		return false
+	}
+	return t.analyzer.LLMClient.HasCachedReply(ctx, attributesPrompt(ctx, text, attributes), core.AttributeValuesSchema)
+}
+
+// attributesPrompt asks for the values of attributes in text. The attribute definitions
+// and instructions are the same for every text of a run, so they form the cacheable
+// preamble.
+func attributesPrompt(ctx context.Context, text string, attributes []models.AttributeDefinition) string {
+	return core.CacheablePrompt(attributesPreamble(attributes), "Text to analyze:\n"+truncateText(ctx, transcript.ForPrompt(text), MaxAttributeTextChars))
+}
+
+// attributesPreamble describes the attributes to extract and the reply expected
+func attributesPreamble(attributes []models.AttributeDefinition) string {
+	return fmt.Sprintf(`Analyze the text below to determine values for the following attributes:
+
+%s
 Return a JSON object with this structure:
 {
   "attribute_values": [
@@ -218,51 +245,153 @@ Return a JSON object with this structure:
 }
 
 Ensure each response is specific to the attribute definition and supported by the text content.
-Include all requested attributes in your response, even if the confidence is low.`,
-		attributesText, truncateText(text, 8000))
+Include all requested attributes in your response, even if the confidence is low.
+When the text is a conversation split into numbered turns, each turn names its speaker and their
+role (customer, agent, system or unknown).`, attributesList(attributes))
+}
 
-	expectedFormat := map[string]interface{}{
-		"attribute_values": []interface{}{},
+// attributesList formats attribute definitions for a prompt
+func attributesList(attributes []models.AttributeDefinition) string {
+	attributesText := ""
+	for _, attr := range attributes {
+		attributesText += fmt.Sprintf("Attribute: %s\nField Name: %s\nDescription: %s\n%s%s\n",
+			attr.Title, attr.FieldName, attr.Description, speakerInstruction(attr), valueInstruction(attr))
 	}
+	return attributesText
+}
 
-	result, err := t.analyzer.LLMClient.GenerateContent(ctx, prompt, expectedFormat)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate content: %w", err)
+// attributeValues converts the attribute_values of a reply to attribute values, skipping
+// entries without a field name
+func attributeValues(list []interface{}) []models.AttributeValue {
+	values := make([]models.AttributeValue, 0, len(list))
+	for _, raw := range list {
+		valMap, ok := raw.(map[string]interface{})
+		if !ok {
+			continue // Skip invalid entries
+		}
+
+		value := models.AttributeValue{
+			FieldName:   getString(valMap, "field_name"),
+			Value:       getString(valMap, "value"),
+			Confidence:  getFloat(valMap, "confidence"),
+			Explanation: getString(valMap, "explanation"),
+		}
+		if value.FieldName != "" {
+			values = append(values, value)
+		}
 	}
+	return values
+}
 
-	// Extract values from the result
-	resultMap, ok := result.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected result format")
+// enforceEnumValues normalizes the values of attributes with enum values to one of them.
+// The attributes whose values can't be normalized are asked for once more, listing their
+// allowed values; values that still match none are marked OutOfEnum.
+func (t *TextProcessor) enforceEnumValues(
+	ctx context.Context,
+	text string,
+	attributes []models.AttributeDefinition,
+	values []models.AttributeValue,
+) []models.AttributeValue {
+	byField := make(map[string]models.AttributeDefinition, len(attributes))
+	for _, attribute := range attributes {
+		byField[attribute.FieldName] = attribute
 	}
 
-	attrValuesRaw, ok := resultMap["attribute_values"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("attribute_values field is not an array")
+	var invalid []int
+	for i := range values {
+		if !normalizeEnumValue(&values[i], byField[values[i].FieldName]) {
+			invalid = append(invalid, i)
+		}
+	}
+	if len(invalid) == 0 {
+		return values
 	}
 
-	// Convert to strongly typed attribute values
-	attrValues := make([]models.AttributeValue, 0, len(attrValuesRaw))
-	for _, valRaw := range attrValuesRaw {
-		valMap, ok := valRaw.(map[string]interface{})
-		if !ok {
-			continue // Skip invalid entries
+	retried := t.reextractEnumValues(ctx, text, byField, values, invalid)
+	for _, i := range invalid {
+		value, ok := retried[values[i].FieldName]
+		if ok && normalizeEnumValue(&value, byField[value.FieldName]) {
+			values[i] = value
+			continue
 		}
+		values[i].OutOfEnum = true
+	}
+	return values
+}
+
+// reextractEnumValues asks again for the values at the given indexes, which are not
+// among the allowed values of their attributes. It returns the new values by field name,
+// or none if the call fails.
+func (t *TextProcessor) reextractEnumValues(
+	ctx context.Context,
+	text string,
+	byField map[string]models.AttributeDefinition,
+	values []models.AttributeValue,
+	indexes []int,
+) map[string]models.AttributeValue {
+	attributesText := ""
+	for _, i := range indexes {
+		attribute := byField[values[i].FieldName]
+		attributesText += fmt.Sprintf("Attribute: %s\nField Name: %s\nDescription: %s\n%sAllowed values: %s\nPrevious value: %s\n\n",
+			attribute.Title, attribute.FieldName, attribute.Description, speakerInstruction(attribute),
+			strings.Join(attribute.EnumValues, ", "), values[i].Value)
+	}
+
+	prompt := fmt.Sprintf(`The values previously extracted from the text below for these attributes are not among their allowed values:
+
+%sChoose for each attribute the one allowed value that best fits the text, written exactly as listed.
+
+Return a JSON object with this structure:
+{
+  "attribute_values": [
+    {
+      "field_name": str,     // Must match one of the field names provided above
+      "value": str,          // One of the allowed values of the attribute
+      "confidence": float,   // Confidence score between 0 and 1
+      "explanation": str     // Explanation of how the value was determined
+    }
+  ]
+}
+
+Text to analyze:
+%s`, attributesText, truncateText(ctx, transcript.ForPrompt(text), MaxAttributeTextChars))
 
-		attrValue := models.AttributeValue{
+	result, err := t.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.AttributeValuesSchema)
+	if err != nil {
+		return nil
+	}
+	resultMap, _ := result.(map[string]interface{})
+	list, _ := resultMap["attribute_values"].([]interface{})
+	retried := make(map[string]models.AttributeValue, len(list))
+	for _, item := range list {
+		valMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value := models.AttributeValue{
 			FieldName:   getString(valMap, "field_name"),
 			Value:       getString(valMap, "value"),
 			Confidence:  getFloat(valMap, "confidence"),
 			Explanation: getString(valMap, "explanation"),
 		}
-
-		// Only add if field_name is valid
-		if attrValue.FieldName != "" {
-			attrValues = append(attrValues, attrValue)
+		if _, ok := byField[value.FieldName]; ok {
+			retried[value.FieldName] = value
 		}
 	}
+	return retried
+}
 
-	return attrValues, nil
+// normalizeEnumValue replaces a value with the enum value of its attribute it stands for,
+// keeping the value as given in RawValue, and reports whether it matched one
+func normalizeEnumValue(value *models.AttributeValue, attribute models.AttributeDefinition) bool {
+	normalized, ok := validation.NormalizeEnum(attribute, value.Value)
+	if !ok {
+		return false
+	}
+	if normalized != value.Value {
+		value.RawValue, value.Value = value.Value, normalized
+	}
+	return true
 }
 
 // GenerateIntent generates the primary intent of a customer service conversation
@@ -276,47 +405,185 @@ func (t *TextProcessor) GenerateIntent(ctx context.Context, text string) (*model
 		}, nil
 	}
 
-	prompt := fmt.Sprintf(`You are a helpful AI assistant specializing in classifying customer service conversations. Your task is to analyze a provided conversation transcript and determine the customer's *primary* intent for contacting customer service. Focus on the *main reason* the customer initiated the interaction, even if other topics are briefly mentioned.
+	result, err := t.analyzer.LLMClient.GenerateStructured(ctx, intentPrompt(intentText(ctx, text)), core.IntentSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
 
-**Input:** You will receive a conversation transcript as text.
+	// Extract values from the result
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
+	}
 
-**Output:** You will return a JSON object with the following *exact* keys and data types:
+	intent := intentFromResult(resultMap)
+	return &intent, nil
+}
 
-* **"label_name"**: (string) A natural language label describing the customer's primary intent. This label should be 2-3 words *maximum*. Use title case (e.g., "Update Address", "Cancel Order").
-* **"label"**: (string) A lowercase version of "label_name", with underscores replacing spaces (e.g., "update_address", "cancel_order"). This should be machine-readable.
-* **"description"**: (string) A concise description (1-2 sentences) of the customer's primary intent. Explain the *specific* problem or request the customer is making.
+// intentText returns a conversation as its intent prompts quote it
+func intentText(ctx context.Context, text string) string {
+	return truncateText(ctx, transcript.ForPrompt(text), 8000)
+}
 
-**Important Instructions and Constraints:**
+// intentPrompt asks for the intent of a conversation as quoted by intentText
+func intentPrompt(quoted string) string {
+	return core.CacheablePrompt(intentInstructions, "Conversation Transcript:\n"+quoted)
+}
 
-1. **Primary Intent Focus:** Identify the *single, most important* reason the customer contacted support. Ignore minor side issues if they are not the core reason for the interaction.
-2. **Conciseness:** Keep the "label_name" to 2-3 words and the "description" brief and to the point.
-3. **JSON Format:** The output *must* be valid JSON. Do not include any extra text, explanations, or apologies outside of the JSON object. Only the JSON object should be returned.
-4. **Specificity:** Be as specific as possible in the description. Don't just say "billing issue." Say "The customer is disputing a charge on their latest bill."
-5. **Do not hallucinate information.** Base the classification solely on the provided transcript. Do not invent details.
-6. **Do not respond in a conversational manner.** Your entire response should be only the requested json.
+// packIntentTexts groups quoted conversations like packTexts, except that those whose
+// intent is in the LLM response cache get groups of their own, so they are answered from
+// it rather than sent to the model with the others
+func (t *TextProcessor) packIntentTexts(ctx context.Context, quoted []string) [][]int {
+	var groups [][]int
+	var packable []int
+	for i, text := range quoted {
+		if t.analyzer.LLMClient.HasCachedReply(ctx, intentPrompt(text), core.IntentSchema) {
+			groups = append(groups, []int{i})
+		} else {
+			packable = append(packable, i)
+		}
+	}
+	texts := make([]string, len(packable))
+	for n, i := range packable {
+		texts[n] = quoted[i]
+	}
+	for _, packed := range packTexts(texts) {
+		group := make([]int, len(packed))
+		for n, j := range packed {
+			group[n] = packable[j]
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// GenerateIntents generates the primary intent of each of several conversations. Short
+// conversations are packed into one LLM call, up to maxPackedTexts at a time; any the
+// packed reply leaves out are classified on their own.
+func (t *TextProcessor) GenerateIntents(ctx context.Context, texts []string) ([]models.IntentClassification, error) {
+	prompts := make([]string, len(texts))
+	for i, text := range texts {
+		prompts[i] = intentText(ctx, text)
+	}
+
+	intents := make([]models.IntentClassification, len(texts))
+	for _, group := range t.packIntentTexts(ctx, prompts) {
+		answered := map[int]map[string]interface{}{}
+		if len(group) > 1 {
+			prompt := core.CacheablePrompt(intentInstructions+intentBatchInstructions, packedTranscripts(prompts, group))
+			result, err := t.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.IntentBatchSchema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate content: %w", err)
+			}
+			answered = packedItems(result, "intents", group)
+		}
+
+		for _, i := range group {
+			if item, ok := answered[i]; ok {
+				intents[i] = intentFromResult(item)
+				continue
+			}
+			intent, err := t.GenerateIntent(ctx, texts[i])
+			if err != nil {
+				return nil, err
+			}
+			intents[i] = *intent
+		}
+	}
+	return intents, nil
+}
 
-Conversation Transcript:
-%s`, truncateText(text, 8000))
+// GenerateIntentsConcurrently classifies like GenerateIntents, running up to concurrency
+// LLM calls at once. A conversation that cannot be classified is reported with its error
+// in errs instead of failing the others; when a packed call fails, its conversations are
+// classified on their own.
+func (t *TextProcessor) GenerateIntentsConcurrently(ctx context.Context, texts []string, concurrency int) ([]models.IntentClassification, []error) {
+	prompts := make([]string, len(texts))
+	for i, text := range texts {
+		prompts[i] = intentText(ctx, text)
+	}
 
-	expectedFormat := map[string]interface{}{
-		"label_name":  "",
-		"label":       "",
-		"description": "",
+	intents := make([]models.IntentClassification, len(texts))
+	errs := make([]error, len(texts))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+
+	for _, group := range t.packIntentTexts(ctx, prompts) {
+		wg.Add(1)
+		go func(group []int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				for _, i := range group {
+					errs[i] = ctx.Err()
+				}
+				return
+			}
+
+			answered := map[int]map[string]interface{}{}
+			if len(group) > 1 {
+				prompt := core.CacheablePrompt(intentInstructions+intentBatchInstructions, packedTranscripts(prompts, group))
+				if result, err := t.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.IntentBatchSchema); err == nil {
+					answered = packedItems(result, "intents", group)
+				}
+			}
+
+			for _, i := range group {
+				if item, ok := answered[i]; ok {
+					intents[i] = intentFromResult(item)
+					continue
+				}
+				intent, err := t.GenerateIntent(ctx, texts[i])
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				intents[i] = *intent
+			}
+		}(group)
 	}
 
-	result, err := t.analyzer.LLMClient.GenerateContent(ctx, prompt, expectedFormat)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate content: %w", err)
+	wg.Wait()
+	return intents, errs
+}
+
+// IntentDistribution counts the conversations of each intent, most common first
+func IntentDistribution(intents []models.IntentClassification) []models.IntentCount {
+	var counts []models.IntentCount
+	byLabel := map[string]int{}
+	for _, intent := range intents {
+		if i, ok := byLabel[intent.Label]; ok {
+			counts[i].Count++
+			continue
+		}
+		byLabel[intent.Label] = len(counts)
+		counts = append(counts, models.IntentCount{Label: intent.Label, LabelName: intent.LabelName, Count: 1})
 	}
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+	return counts
+}
 
-	// Extract values from the result
-	resultMap, ok := result.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected result format")
+// MostCommonIntent returns the intent with the most conversations, the first one found
+// among those tied
+func MostCommonIntent(intents []models.IntentClassification) models.IntentClassification {
+	counts := map[string]int{}
+	var common models.IntentClassification
+	for _, intent := range intents {
+		counts[intent.Label]++
+		if counts[intent.Label] > counts[common.Label] {
+			common = intent
+		}
 	}
+	return common
+}
 
-	// Create intent classification
-	intent := &models.IntentClassification{
+// intentFromResult reads an intent classification from a model reply, falling back to
+// the unclear intent when the labels are missing
+func intentFromResult(resultMap map[string]interface{}) models.IntentClassification {
+	intent := models.IntentClassification{
 		LabelName:   getString(resultMap, "label_name"),
 		Label:       getString(resultMap, "label"),
 		Description: getString(resultMap, "description"),
@@ -330,14 +597,157 @@ Conversation Transcript:
 			intent.Description = "The conversation transcript is unclear or does not contain a discernible customer service request."
 		}
 	}
+	return intent
+}
+
+// speakerInstruction tells the model which turns an attribute is extracted from, if
+// its definition targets one speaker role
+func speakerInstruction(attribute models.AttributeDefinition) string {
+	if attribute.Speaker == "" {
+		return ""
+	}
+	return fmt.Sprintf("Speaker: use only the %s's turns; ignore what other speakers say\n", attribute.Speaker)
+}
+
+// valueInstruction describes the declared type and allowed values of an attribute
+func valueInstruction(attribute models.AttributeDefinition) string {
+	instruction := ""
+	switch attribute.Type {
+	case models.AttributeTypeNumber, models.AttributeTypeInteger:
+		instruction += fmt.Sprintf("Type: %s; give the value as digits without units or currency symbols\n", attribute.Type)
+	case models.AttributeTypeBoolean:
+		instruction += "Type: boolean; give the value as true or false\n"
+	case models.AttributeTypeDate:
+		instruction += "Type: date; give the value as YYYY-MM-DD\n"
+	}
+	if len(attribute.EnumValues) > 0 {
+		instruction += fmt.Sprintf("Allowed values: %s; give exactly one of them as written\n", strings.Join(attribute.EnumValues, ", "))
+	}
+	return instruction
+}
+
+// CondenseTranscript shortens a long transcript for attribute extraction: it is split
+// into windows at line breaks and each window is reduced to the statements bearing on
+// the attributes, so the whole conversation fits the extraction prompt instead of being
+// cut off. Texts that already fit are returned as is.
+func (t *TextProcessor) CondenseTranscript(ctx context.Context, text string, attributes []models.AttributeDefinition) (string, error) {
+	if len(text) <= MaxAttributeTextChars {
+		return text, nil
+	}
+
+	var fields strings.Builder
+	for _, attr := range attributes {
+		fmt.Fprintf(&fields, "- %s: %s\n", attr.Title, attr.Description)
+	}
+
+	windows := splitWindows(text, MaxAttributeTextChars)
+	budget := MaxAttributeTextChars / len(windows)
+	condensed := make([]string, len(windows))
+	for i, window := range windows {
+		prompt := fmt.Sprintf(`This is part %d of %d of a customer service conversation. Condense it to the statements that bear on these attributes:
+
+%s
+Keep speaker labels such as "Customer:" or "Agent:", and keep amounts, dates, identifiers and the outcome of each topic verbatim. Drop greetings, small talk and repetition. Use at most %d characters and return only the condensed lines.
+
+Conversation part:
+%s`, i+1, len(windows), fields.String(), budget, window)
+
+		result, err := t.analyzer.LLMClient.GenerateContent(ctx, prompt, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to condense part %d of %d: %w", i+1, len(windows), err)
+		}
+		part, ok := result.(string)
+		if !ok {
+			return "", fmt.Errorf("unexpected result type: %T", result)
+		}
+		condensed[i] = strings.TrimSpace(part)
+	}
+	return strings.Join(condensed, "\n"), nil
+}
 
-	return intent, nil
+// splitWindows splits text into windows of at most size bytes, at line breaks where
+// possible
+func splitWindows(text string, size int) []string {
+	var windows []string
+	for len(text) > size {
+		cut := strings.LastIndex(text[:size], "\n")
+		if cut <= 0 {
+			cut = size
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		windows = append(windows, text[:cut])
+		text = strings.TrimLeft(text[cut:], "\n")
+	}
+	if text != "" {
+		windows = append(windows, text)
+	}
+	return windows
 }
 
-// truncateText safely truncates text to a maximum length
-func truncateText(text string, maxLength int) string {
+// truncateText safely truncates text to a maximum length, reporting the truncation as a
+// warning of the analysis
+func truncateText(ctx context.Context, text string, maxLength int) string {
 	if len(text) <= maxLength {
 		return text
 	}
+	core.Warn(ctx, core.WarningInputTruncated, fmt.Sprintf("text longer than %d characters was truncated to fit the prompt", maxLength))
 	return text[:maxLength] + "... [text truncated]"
 }
+
+// TranslateText translates a conversation into the target language, keeping speaker
+// labels and line breaks, and returns the source language the model detected as an ISO
+// 639-1 code with the translation
+func (t *TextProcessor) TranslateText(ctx context.Context, text, targetLanguage string) (string, string, error) {
+	if text == "" {
+		return "", "", fmt.Errorf("text is required")
+	}
+
+	prompt := fmt.Sprintf(`Translate the following customer service conversation into the language with ISO 639-1 code %q.
+
+Keep speaker labels such as "Customer:" or "Agent:" (translated into the target language), timestamps, line breaks, amounts and identifiers as they are. Translate faithfully without summarizing, correcting or adding content.
+
+Return a JSON object with:
+- "source_language": the ISO 639-1 code of the language the conversation is written in
+- "translated_text": the translated conversation
+
+Conversation:
+%s`, targetLanguage, text)
+
+	result, err := t.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.TranslationSchema)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate content: %w", err)
+	}
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return "", "", fmt.Errorf("%w: unexpected result format", core.ErrSchemaValidation)
+	}
+	return getString(resultMap, "source_language"), getString(resultMap, "translated_text"), nil
+}
+
+// intentInstructions is the fixed part of the intent classification prompt
+const intentInstructions = `You are a helpful AI assistant specializing in classifying customer service conversations. Your task is to analyze a provided conversation transcript and determine the customer's *primary* intent for contacting customer service. Focus on the *main reason* the customer initiated the interaction, even if other topics are briefly mentioned.
+
+**Input:** You will receive a conversation transcript as text.
+
+**Output:** You will return a JSON object with the following *exact* keys and data types:
+
+* **"label_name"**: (string) A natural language label describing the customer's primary intent. This label should be 2-3 words *maximum*. Use title case (e.g., "Update Address", "Cancel Order").
+* **"label"**: (string) A lowercase version of "label_name", with underscores replacing spaces (e.g., "update_address", "cancel_order"). This should be machine-readable.
+* **"description"**: (string) A concise description (1-2 sentences) of the customer's primary intent. Explain the *specific* problem or request the customer is making.
+
+**Important Instructions and Constraints:**
+
+1. **Primary Intent Focus:** Identify the *single, most important* reason the customer contacted support. Ignore minor side issues if they are not the core reason for the interaction.
+2. **Conciseness:** Keep the "label_name" to 2-3 words and the "description" brief and to the point.
+3. **JSON Format:** The output *must* be valid JSON. Do not include any extra text, explanations, or apologies outside of the JSON object. Only the JSON object should be returned.
+4. **Specificity:** Be as specific as possible in the description. Don't just say "billing issue." Say "The customer is disputing a charge on their latest bill."
+5. **Do not hallucinate information.** Base the classification solely on the provided transcript. Do not invent details.
+6. **Do not respond in a conversational manner.** Your entire response should be only the requested json.
+7. **Speaker Turns:** When the transcript is split into numbered turns labelled with the speaker's role, base the intent on what the customer says. Agent and system turns only provide context.`
+
+// intentBatchInstructions adapt intentInstructions to prompts holding several transcripts
+const intentBatchInstructions = `
+
+**Several Transcripts:** The input holds several numbered conversation transcripts. Classify each one on its own, without letting the others influence it, and return a JSON object with an "intents" array holding one object per transcript: its number as "index", plus "label_name", "label" and "description" as described above.`
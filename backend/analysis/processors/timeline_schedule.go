@@ -0,0 +1,240 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// dateLayout is the layout of calendar dates
+const dateLayout = "2006-01-02"
+
+// defaultPhaseWorkingDays is the duration of a phase whose duration can't be read
+const defaultPhaseWorkingDays = 10
+
+// weekdays maps weekday names to weekdays
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// phaseDurationPattern reads durations such as "10 business days", "3-4 weeks" or "1 month"
+var phaseDurationPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)(?:\s*(?:-|to)\s*(\d+(?:\.\d+)?))?\s*(?:business\s+|working\s+)?(day|week|month|quarter|year)s?`)
+
+// ValidateWorkingCalendar checks the dates, weekdays and capacities of a working calendar
+func ValidateWorkingCalendar(calendar models.WorkingCalendar) error {
+	if calendar.StartDate != "" {
+		if _, err := time.Parse(dateLayout, calendar.StartDate); err != nil {
+			return fmt.Errorf("start_date must be a YYYY-MM-DD date")
+		}
+	}
+	for _, day := range calendar.WorkingDays {
+		if _, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]; !ok {
+			return fmt.Errorf("unknown working day %q", day)
+		}
+	}
+	for _, holiday := range calendar.Holidays {
+		if _, err := time.Parse(dateLayout, holiday); err != nil {
+			return fmt.Errorf("holiday %q must be a YYYY-MM-DD date", holiday)
+		}
+	}
+	for resource, capacity := range calendar.Capacity {
+		if capacity < 0 {
+			return fmt.Errorf("capacity of resource %q must not be negative", resource)
+		}
+	}
+	return nil
+}
+
+// workCalendar answers which days of a working calendar are worked
+type workCalendar struct {
+	weekdays map[time.Weekday]bool
+	holidays map[string]bool
+}
+
+// isWorking reports whether a day is worked
+func (c workCalendar) isWorking(day time.Time) bool {
+	return c.weekdays[day.Weekday()] && !c.holidays[day.Format(dateLayout)]
+}
+
+// next returns the first working day on or after day
+func (c workCalendar) next(day time.Time) time.Time {
+	for !c.isWorking(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// ScheduleTimeline lays a timeline out on a working calendar. The duration of each phase,
+// in working days, is its DurationDays or else read from its duration. A phase starts on
+// the working day after the latest earlier phase it depends on ends, or after the phase
+// before it when it depends on none; the first phase starts on the start date. Weekends
+// and holidays are skipped, and milestones are reached on the last day of their phase.
+// Where phases running at the same time need more of a resource than its capacity, the
+// period is reported as a conflict; resources without a capacity are not limited. The
+// timeline is returned with the dates of its phases.
+func ScheduleTimeline(timeline []models.TimelineEvent, calendar models.WorkingCalendar) ([]models.TimelineEvent, *models.TimelineSchedule, error) {
+	if err := ValidateWorkingCalendar(calendar); err != nil {
+		return nil, nil, err
+	}
+
+	cal := workCalendar{weekdays: map[time.Weekday]bool{}, holidays: map[string]bool{}}
+	for _, day := range calendar.WorkingDays {
+		cal.weekdays[weekdays[strings.ToLower(strings.TrimSpace(day))]] = true
+	}
+	if len(cal.weekdays) == 0 {
+		for day := time.Monday; day <= time.Friday; day++ {
+			cal.weekdays[day] = true
+		}
+	}
+	for _, holiday := range calendar.Holidays {
+		cal.holidays[holiday] = true
+	}
+
+	start := time.Now().UTC()
+	if calendar.StartDate != "" {
+		start, _ = time.Parse(dateLayout, calendar.StartDate)
+	}
+	start = cal.next(time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC))
+
+	scheduled := make([]models.TimelineEvent, len(timeline))
+	copy(scheduled, timeline)
+	starts := make([]time.Time, len(scheduled))
+	ends := make([]time.Time, len(scheduled))
+	phaseIndex := map[string]int{}
+	schedule := &models.TimelineSchedule{
+		StartDate:      start.Format(dateLayout),
+		EndDate:        start.Format(dateLayout),
+		MilestoneDates: []models.MilestoneDate{},
+		Conflicts:      []models.ScheduleConflict{},
+	}
+
+	end := start
+	for i := range scheduled {
+		event := &scheduled[i]
+		days := event.DurationDays
+		if days <= 0 {
+			days = phaseWorkingDays(event.Duration, len(cal.weekdays))
+		}
+
+		// Start after the latest earlier phase the phase depends on, or the one before it
+		after := -1
+		for _, dependency := range event.Dependencies {
+			if j, ok := phaseIndex[normalizeName(dependency)]; ok && (after < 0 || ends[j].After(ends[after])) {
+				after = j
+			}
+		}
+		if after < 0 {
+			after = i - 1
+		}
+		day := start
+		if after >= 0 {
+			day = cal.next(ends[after].AddDate(0, 0, 1))
+		}
+		starts[i] = day
+		for n := 1; n < days; n++ {
+			day = cal.next(day.AddDate(0, 0, 1))
+		}
+		ends[i] = day
+		if day.After(end) {
+			end = day
+		}
+
+		event.StartDate = starts[i].Format(dateLayout)
+		event.EndDate = ends[i].Format(dateLayout)
+		event.DurationDays = days
+		if _, ok := phaseIndex[normalizeName(event.Phase)]; !ok {
+			phaseIndex[normalizeName(event.Phase)] = i
+		}
+		for _, milestone := range event.Milestones {
+			schedule.MilestoneDates = append(schedule.MilestoneDates, models.MilestoneDate{
+				Milestone: milestone,
+				Phase:     event.Phase,
+				Date:      event.EndDate,
+			})
+		}
+	}
+	schedule.EndDate = end.Format(dateLayout)
+
+	// Walk the working days, counting the phases that need each limited resource
+	resources := make([]string, 0, len(calendar.Capacity))
+	capacities := map[string]int{}
+	for resource, capacity := range calendar.Capacity {
+		resources = append(resources, resource)
+		capacities[normalizeName(resource)] = capacity
+	}
+	sort.Strings(resources)
+	// open holds the index of the conflict each resource is in on the previous working day
+	open := map[string]int{}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if !cal.isWorking(day) {
+			continue
+		}
+		schedule.WorkingDays++
+		for _, resource := range resources {
+			var phases []string
+			for i, event := range scheduled {
+				if day.Before(starts[i]) || day.After(ends[i]) {
+					continue
+				}
+				for _, required := range event.ResourcesRequired {
+					if normalizeName(required) == normalizeName(resource) {
+						phases = append(phases, event.Phase)
+						break
+					}
+				}
+			}
+			capacity := capacities[normalizeName(resource)]
+			if len(phases) <= capacity {
+				delete(open, resource)
+				continue
+			}
+			if i, ok := open[resource]; ok && strings.Join(schedule.Conflicts[i].Phases, "\x00") == strings.Join(phases, "\x00") {
+				schedule.Conflicts[i].EndDate = day.Format(dateLayout)
+				continue
+			}
+			schedule.Conflicts = append(schedule.Conflicts, models.ScheduleConflict{
+				Resource:  resource,
+				StartDate: day.Format(dateLayout),
+				EndDate:   day.Format(dateLayout),
+				Phases:    phases,
+				Required:  len(phases),
+				Capacity:  capacity,
+			})
+			open[resource] = len(schedule.Conflicts) - 1
+		}
+	}
+
+	return scheduled, schedule, nil
+}
+
+// phaseWorkingDays returns the number of working days of a phase duration, the upper
+// bound of a range, counting weeks of perWeek working days
+func phaseWorkingDays(duration string, perWeek int) int {
+	match := phaseDurationPattern.FindStringSubmatch(duration)
+	if match == nil {
+		return defaultPhaseWorkingDays
+	}
+	amount, _ := strconv.ParseFloat(match[1], 64)
+	if match[2] != "" {
+		amount, _ = strconv.ParseFloat(match[2], 64)
+	}
+	month := float64(perWeek) * 52 / 12
+	unit := map[string]float64{"day": 1, "week": float64(perWeek), "month": month, "quarter": 3 * month, "year": 12 * month}[strings.ToLower(match[3])]
+	days := int(math.Ceil(amount * unit))
+	if days < 1 {
+		return defaultPhaseWorkingDays
+	}
+	return days
+}
+
+// normalizeName normalizes a phase or resource name for matching
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
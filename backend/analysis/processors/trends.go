@@ -7,8 +7,12 @@ import (
 
 	"agenticflows/backend/analysis/core"
 	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/statistics"
 )
 
+// maxTrendsTextChars bounds the conversation text included in a trends prompt
+const maxTrendsTextChars = 20000
+
 // TrendsAnalyzer handles analysis of trends in conversation data
 type TrendsAnalyzer struct {
 	analyzer *core.Analyzer
@@ -33,24 +37,33 @@ func (t *TrendsAnalyzer) AnalyzeTrends(ctx context.Context, req models.AnalysisR
 		return nil, fmt.Errorf("failed to marshal focus areas: %w", err)
 	}
 
-	// Format data for the prompt
-	dataStr := "No data provided"
+	// Format data for the prompt, with the statistics computed from it so the model
+	// cites real numbers instead of estimating them
+	definitions, dataStr := "", "No data provided"
+	var metrics *statistics.Summary
 	if req.AttributeValues != nil {
-		dataBytes, err := json.Marshal(req.AttributeValues)
+		definitions, dataStr, err = core.FormatPromptDataSections(req.AttributeValues)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal attribute values: %w", err)
 		}
-		dataStr = string(dataBytes)
+		metrics = statistics.Summarize(req.AttributeValues)
+	}
+	if metrics != nil {
+		metricsStr, err := json.Marshal(metrics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal statistics: %w", err)
+		}
+		dataStr = "Statistics computed from the data (exact; use these numbers rather than estimating your own):\n" +
+			string(metricsStr) + "\n\n" + dataStr
 	}
 
-	prompt := fmt.Sprintf(`Analyze trends in the following conversation data for these focus areas:
+	// Instructions and attribute definitions are shared by every batch of a run, so
+	// they form the cacheable preamble and only the data varies
+	preamble := fmt.Sprintf(`Analyze trends in the conversation data below for these focus areas:
 
 Focus Areas:
 %s
 
-Data:
-%s
-
 Identify notable trends, patterns, and insights related to the specified focus areas.
 Format your response as JSON with these fields:
 {
@@ -67,19 +80,24 @@ Format your response as JSON with these fields:
     "assessment": str,
     "limitations": [str]
   }
-}`, string(focusAreasStr), dataStr)
+}
 
-	expectedFormat := map[string]interface{}{
-		"trends":           []interface{}{},
-		"overall_insights": []interface{}{},
-		"data_quality":     map[string]interface{}{},
+%s`, string(focusAreasStr), definitions)
+	input := "Data:\n" + dataStr
+	if req.Text != "" {
+		input += "\n\nConversations:\n" + truncateText(ctx, req.Text, maxTrendsTextChars)
 	}
+	prompt := core.CacheablePrompt(preamble, input)
 
-	result, err := t.analyzer.LLMClient.GenerateContent(ctx, prompt, expectedFormat)
+	result, err := t.analyzer.LLMClient.GenerateStructured(ctx, prompt, core.TrendsSchema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 
+	if results, ok := result.(map[string]interface{}); ok && metrics != nil {
+		results["metrics"] = metrics
+	}
+
 	return &models.AnalysisResponse{
 		Results:    result,
 		Confidence: 0.8, // Default confidence
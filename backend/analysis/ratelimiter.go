@@ -43,6 +43,11 @@ func (r *RateLimiter) Acquire(ctx context.Context) error {
 	}
 }
 
+// Allow reports whether a request may be made now, counting it if so. It never blocks.
+func (r *RateLimiter) Allow() bool {
+	return r.tryAcquire() == nil
+}
+
 // tryAcquire attempts to acquire a rate limit token without blocking
 func (r *RateLimiter) tryAcquire() error {
 	r.mu.Lock()
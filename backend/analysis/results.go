@@ -0,0 +1,278 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"agenticflows/backend/analysis/dedup"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/statistics"
+)
+
+// TrendsResult is the result of a trends analysis
+type TrendsResult struct {
+	Trends          []Trend             `json:"trends"`
+	OverallInsights []string            `json:"overall_insights"`
+	DataQuality     DataQualityResult   `json:"data_quality"`
+	Metrics         *statistics.Summary `json:"metrics,omitempty"` // Computed from the data, not by the model
+}
+
+// Trend is a trend identified for one focus area
+type Trend struct {
+	FocusArea      string  `json:"focus_area"`
+	Trend          string  `json:"trend"`
+	SupportingData string  `json:"supporting_data"`
+	Confidence     float64 `json:"confidence"`
+}
+
+// DataQualityResult is the model's assessment of the analyzed data
+type DataQualityResult struct {
+	Assessment  string   `json:"assessment"`
+	Limitations []string `json:"limitations"`
+}
+
+// PatternsResult is the result of a patterns analysis
+type PatternsResult struct {
+	Patterns           []Pattern           `json:"patterns"`
+	UnexpectedPatterns []UnexpectedPattern `json:"unexpected_patterns"`
+	// Grouping reports how intent_groups were grouped and merged
+	Grouping *IntentGrouping `json:"grouping,omitempty"`
+}
+
+// IntentGrouping reports the hierarchical grouping of intents: the batches grouped
+// concurrently and the rounds of pairwise merges that followed
+type IntentGrouping struct {
+	Intents       int `json:"intents"`
+	Batches       int `json:"batches"`
+	FailedBatches int `json:"failed_batches"`
+	MergeRounds   int `json:"merge_rounds"`
+}
+
+// Pattern is a pattern identified in the data
+type Pattern struct {
+	PatternType        string   `json:"pattern_type"`
+	PatternDescription string   `json:"pattern_description"`
+	Occurrences        int      `json:"occurrences"`
+	Examples           []string `json:"examples"`
+	Significance       string   `json:"significance"`
+	// Intents are all the intents of an intent group, whose occurrences they add up to
+	Intents []string `json:"intents,omitempty"`
+}
+
+// UnexpectedPattern is a pattern outside the requested pattern types
+type UnexpectedPattern struct {
+	Description     string   `json:"description"`
+	PotentialCauses []string `json:"potential_causes"`
+}
+
+// FindingsResult is the result of a findings analysis
+type FindingsResult struct {
+	Findings        []Finding `json:"findings"`
+	Recommendations []string  `json:"recommendations"`
+	// Coverage reports which questions the data answers
+	Coverage *CoverageMatrix `json:"coverage,omitempty"`
+}
+
+// Finding answers one of the questions of a findings analysis
+type Finding struct {
+	Question           string   `json:"question"`
+	Answer             string   `json:"answer"`
+	SupportingEvidence []string `json:"supporting_evidence"`
+	Confidence         float64  `json:"confidence"`
+	// Coverage is how completely the data answers the question; see the Coverage constants
+	Coverage string `json:"coverage,omitempty"`
+	// MissingAttributes are the attributes needed to answer a question the data does not fully answer
+	MissingAttributes []string `json:"missing_attributes,omitempty"`
+	// Citations are the conversations that support the answer
+	Citations []Citation `json:"citations"`
+}
+
+// Citation refers an answer back to a conversation it is based on
+type Citation struct {
+	ConversationID string `json:"conversation_id"`
+	// Quote is a passage of the conversation, quoted verbatim
+	Quote string `json:"quote"`
+}
+
+// AttributesResult is the result of an attribute extraction. Extraction over
+// conversation IDs reports the values per conversation and statistics per attribute.
+type AttributesResult struct {
+	AttributeValues     []models.AttributeValue         `json:"attribute_values"`
+	Attributes          []models.AttributeDefinition    `json:"attributes,omitempty"`
+	Conversations       []models.ConversationAttributes `json:"conversations,omitempty"`
+	Statistics          []models.AttributeStatistics    `json:"statistics,omitempty"`
+	FailedConversations int                             `json:"failed_conversations,omitempty"`
+	Changes             []models.AttributeChange        `json:"changes,omitempty"`
+	// Persisted counts the values saved to conversation_attributes
+	Persisted int `json:"persisted,omitempty"`
+	// Violations are the validation rules broken by the values extracted from text
+	Violations []models.RuleViolation `json:"violations,omitempty"`
+	// Flagged holds the conversations whose values break validation rules. They are left
+	// out of Conversations and Statistics until reviewed.
+	Flagged []models.ConversationAttributes `json:"flagged,omitempty"`
+	// Processing sums up the cost and latency of the conversations and names the outliers
+	Processing *models.ProcessingSummary `json:"processing,omitempty"`
+}
+
+// IntentResult is the result of an intent analysis. An analysis of several conversations
+// reports the intent of each, with the most common intent at the top level.
+type IntentResult struct {
+	models.IntentClassification
+	Conversations []models.ConversationIntent `json:"conversations,omitempty"`
+	Distribution  []models.IntentCount        `json:"distribution,omitempty"` // Intents by number of conversations, in bulk mode
+	Failed        int                         `json:"failed,omitempty"`       // Conversations that could not be classified
+	// Groups merges intents of the distribution with similar names, with the group_intents parameter
+	Groups []models.IntentGroup `json:"groups,omitempty"`
+	// Persisted counts the classifications saved to the intent history of their conversation
+	Persisted int `json:"persisted,omitempty"`
+}
+
+// SummarizeResult is the result of a summarization: the summary of each conversation,
+// in the order they were given
+type SummarizeResult struct {
+	Summaries []models.ConversationSummary `json:"summaries"`
+	Failed    int                          `json:"failed,omitempty"` // Conversations that could not be summarized
+	Cached    int                          `json:"cached,omitempty"` // Summaries served from the summary cache
+}
+
+// AnomaliesResult is the result of an anomalies analysis: the anomalies of all series,
+// most significant first, and a summary of each series
+type AnomaliesResult struct {
+	Options   statistics.AnomalyOptions `json:"options"`
+	Series    []AnomalySeriesSummary    `json:"series"`
+	Anomalies []Anomaly                 `json:"anomalies"`
+	Explained int                       `json:"explained,omitempty"` // Anomalies the model explained
+}
+
+// AnomalySeriesSummary summarizes a series anomalies were detected in
+type AnomalySeriesSummary struct {
+	Name      string  `json:"name"`
+	Points    int     `json:"points"`
+	Start     string  `json:"start"`
+	End       string  `json:"end"`
+	Mean      float64 `json:"mean"`
+	StdDev    float64 `json:"std_dev"`
+	Anomalies int     `json:"anomalies"`
+}
+
+// Anomaly is a point of a series flagged as a spike or drop, with the conversations of its
+// time bucket and, when asked for, the model's explanation of it
+type Anomaly struct {
+	Series string  `json:"series"`
+	Time   string  `json:"time"`
+	Value  float64 `json:"value"`
+	statistics.Anomaly
+	ConversationIDs []string            `json:"conversation_ids,omitempty"`
+	Explanation     *models.Explanation `json:"explanation,omitempty"`
+
+	// Start and End bound the time bucket of the point
+	Start time.Time `json:"-"`
+	End   time.Time `json:"-"`
+}
+
+// SentimentResult is the result of a sentiment analysis. An analysis of several
+// conversations reports the sentiment of each, with their average overall sentiment at
+// the top level.
+type SentimentResult struct {
+	models.SentimentAnalysis
+	Conversations []models.ConversationSentiment `json:"conversations,omitempty"`
+}
+
+// CompareResult is the result of a comparison of two cohorts
+type CompareResult = models.CohortComparison
+
+// RecommendationsResult is the result of a recommendations analysis
+type RecommendationsResult = models.RecommendationResponse
+
+// PlanResult is the result of an action plan analysis
+type PlanResult = models.ActionPlan
+
+// DedupResult is the result of a duplicate detection
+type DedupResult = dedup.Result
+
+// resultTypes creates the typed result of each analysis type
+var resultTypes = map[string]func() interface{}{
+	"trends":          func() interface{} { return &TrendsResult{} },
+	"patterns":        func() interface{} { return &PatternsResult{} },
+	"findings":        func() interface{} { return &FindingsResult{} },
+	"attributes":      func() interface{} { return &AttributesResult{} },
+	"intent":          func() interface{} { return &IntentResult{} },
+	"sentiment":       func() interface{} { return &SentimentResult{} },
+	"compare":         func() interface{} { return &CompareResult{} },
+	"recommendations": func() interface{} { return &RecommendationsResult{} },
+	"plan":            func() interface{} { return &PlanResult{} },
+	"dedup":           func() interface{} { return &DedupResult{} },
+	"summarize":       func() interface{} { return &SummarizeResult{} },
+	"anomalies":       func() interface{} { return &AnomaliesResult{} },
+}
+
+// NewResult returns a pointer to an empty typed result for an analysis type
+func NewResult(analysisType string) (interface{}, bool) {
+	newResult, ok := resultTypes[analysisType]
+	if !ok {
+		return nil, false
+	}
+	return newResult(), true
+}
+
+// NormalizeResults round-trips results through the typed result of the analysis
+// type, so every response carries the same fields with the same JSON types.
+// Results of analysis types without a typed result are returned unchanged.
+func NormalizeResults(analysisType string, results interface{}) (interface{}, error) {
+	typed, ok := NewResult(analysisType)
+	if !ok || results == nil {
+		return results, nil
+	}
+
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s results: %w", analysisType, err)
+	}
+	if err := json.Unmarshal(encoded, typed); err != nil {
+		return nil, fmt.Errorf("%s results do not match the %s result schema: %w", analysisType, analysisType, err)
+	}
+
+	// Re-encode the typed result so results stay generic JSON values for merging and voting
+	encoded, err = json.Marshal(typed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s results: %w", analysisType, err)
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, fmt.Errorf("failed to decode %s results: %w", analysisType, err)
+	}
+	return normalized, nil
+}
+
+// FilterByConfidence drops the items of normalized results whose confidence is below
+// minConfidence, from every list of the results whose items report a confidence. It
+// returns the filtered results and the number of items dropped.
+func FilterByConfidence(results interface{}, minConfidence float64) (interface{}, int) {
+	fields, ok := results.(map[string]interface{})
+	if !ok {
+		return results, 0
+	}
+
+	dropped := 0
+	filtered := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		items, ok := value.([]interface{})
+		if !ok {
+			filtered[key] = value
+			continue
+		}
+		kept := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if fields, ok := item.(map[string]interface{}); ok {
+				if confidence, ok := fields["confidence"].(float64); ok && confidence < minConfidence {
+					dropped++
+					continue
+				}
+			}
+			kept = append(kept, item)
+		}
+		filtered[key] = kept
+	}
+	return filtered, dropped
+}
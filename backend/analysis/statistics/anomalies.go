@@ -0,0 +1,174 @@
+package statistics
+
+import (
+	"fmt"
+	"math"
+)
+
+// Anomaly detection methods
+const (
+	// MethodZScore compares each point to the mean and standard deviation of the window of
+	// points before it
+	MethodZScore = "zscore"
+	// MethodEWMA compares each point to the exponentially weighted moving mean and variance
+	// of the points before it, so recent points weigh more
+	MethodEWMA = "ewma"
+)
+
+// Directions of anomalies
+const (
+	DirectionSpike = "spike"
+	DirectionDrop  = "drop"
+	DirectionBoth  = "both"
+)
+
+// Anomaly detection defaults and limits
+const (
+	DefaultAnomalyThreshold = 3.0
+	DefaultAnomalyWindow    = 7
+	DefaultEWMAAlpha        = 0.3
+	MaxAnomalyWindow        = 365
+
+	// MinBaselinePoints is the number of points before a point that are needed to tell
+	// whether it is anomalous
+	MinBaselinePoints = 3
+)
+
+// AnomalyOptions configures anomaly detection. Zero fields take their defaults.
+type AnomalyOptions struct {
+	Method string `json:"method"`
+	// Threshold is the number of standard deviations from its expected value at which a
+	// point is anomalous
+	Threshold float64 `json:"threshold"`
+	// Window is the number of earlier points the z-score baseline spans
+	Window int `json:"window,omitempty"`
+	// Alpha is the weight of each new point in the EWMA baseline, between 0 and 1
+	Alpha     float64 `json:"alpha,omitempty"`
+	Direction string  `json:"direction"`
+}
+
+// WithDefaults returns the options with their zero fields set to the defaults, and only
+// the fields of their method
+func (o AnomalyOptions) WithDefaults() AnomalyOptions {
+	if o.Method == "" {
+		o.Method = MethodZScore
+	}
+	if o.Threshold == 0 {
+		o.Threshold = DefaultAnomalyThreshold
+	}
+	if o.Direction == "" {
+		o.Direction = DirectionBoth
+	}
+	if o.Method == MethodEWMA {
+		o.Window = 0
+		if o.Alpha == 0 {
+			o.Alpha = DefaultEWMAAlpha
+		}
+	} else {
+		o.Alpha = 0
+		if o.Window == 0 {
+			o.Window = DefaultAnomalyWindow
+		}
+	}
+	return o
+}
+
+// ValidateAnomalyOptions checks anomaly detection options, after their defaults are set
+func ValidateAnomalyOptions(o AnomalyOptions) error {
+	switch o.Method {
+	case MethodZScore:
+		if o.Window < MinBaselinePoints || o.Window > MaxAnomalyWindow {
+			return fmt.Errorf("window must be an integer from %d to %d", MinBaselinePoints, MaxAnomalyWindow)
+		}
+	case MethodEWMA:
+		if o.Alpha <= 0 || o.Alpha >= 1 {
+			return fmt.Errorf("alpha must be a number between 0 and 1")
+		}
+	default:
+		return fmt.Errorf("method must be %s or %s", MethodZScore, MethodEWMA)
+	}
+	if o.Threshold <= 0 {
+		return fmt.Errorf("threshold must be a positive number")
+	}
+	switch o.Direction {
+	case DirectionSpike, DirectionDrop, DirectionBoth:
+	default:
+		return fmt.Errorf("direction must be %s, %s or %s", DirectionSpike, DirectionDrop, DirectionBoth)
+	}
+	return nil
+}
+
+// DescribeValues returns the count, mean, spread and range of values, which must not be
+// empty
+func DescribeValues(values []float64) NumericStats {
+	return numericStats("", values)
+}
+
+// Anomaly is a point of a series that deviates from its expected value by at least the
+// threshold
+type Anomaly struct {
+	Index    int     `json:"-"` // Position of the point in the series
+	Expected float64 `json:"expected"`
+	StdDev   float64 `json:"std_dev"`
+	// Score is the signed number of standard deviations the point is from its expected
+	// value
+	Score     float64 `json:"score"`
+	Direction string  `json:"direction"`
+}
+
+// DetectAnomalies returns the anomalies among the values of a time series, in time order.
+// The first MinBaselinePoints values only form the baseline. A point of a flat baseline,
+// whose standard deviation is 0, is measured against a tenth of its mean instead, so a
+// change from a constant value is still flagged.
+func DetectAnomalies(values []float64, options AnomalyOptions) []Anomaly {
+	options = options.WithDefaults()
+
+	var anomalies []Anomaly
+	flag := func(i int, expected, stdDev float64) {
+		if i < MinBaselinePoints {
+			return
+		}
+		if stdDev == 0 {
+			stdDev = math.Max(math.Abs(expected)/10, 1e-9)
+		}
+		score := (values[i] - expected) / stdDev
+		direction := DirectionSpike
+		if score < 0 {
+			direction = DirectionDrop
+		}
+		if math.Abs(score) < options.Threshold || options.Direction != DirectionBoth && options.Direction != direction {
+			return
+		}
+		anomalies = append(anomalies, Anomaly{
+			Index:     i,
+			Expected:  round(expected),
+			StdDev:    round(stdDev),
+			Score:     round(score),
+			Direction: direction,
+		})
+	}
+
+	switch options.Method {
+	case MethodEWMA:
+		if len(values) == 0 {
+			return nil
+		}
+		mean, variance := values[0], 0.0
+		for i := 1; i < len(values); i++ {
+			flag(i, mean, math.Sqrt(variance))
+			diff := values[i] - mean
+			mean += options.Alpha * diff
+			variance = (1 - options.Alpha) * (variance + options.Alpha*diff*diff)
+		}
+	default:
+		for i := range values {
+			baseline := values[max(0, i-options.Window):i]
+			if len(baseline) < MinBaselinePoints {
+				continue
+			}
+			stats := numericStats("", baseline)
+			flag(i, stats.Mean, stats.StdDev)
+		}
+	}
+	return anomalies
+}
@@ -0,0 +1,399 @@
+// Package statistics computes exact aggregates over analysis data, such as counts,
+// averages, value distributions and time series, so analyses report real numbers
+// instead of asking a model to infer them
+package statistics
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on the fields summarized as distributions
+const (
+	maxDistinctValues     = 50 // More distinct values than this make a field free text
+	maxDistributionValues = 10 // The most common values listed; the rest are counted as other
+	maxCategoryLength     = 60 // Longer average values make a field free text
+)
+
+// Time series intervals
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// timeLayouts are the formats time values are recognized in
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// Summary holds the statistics of each dataset in an analysis payload
+type Summary struct {
+	Datasets []Dataset `json:"datasets"`
+}
+
+// Dataset summarizes a list of records found at Path in the payload, e.g.
+// "attribute_values" or "metadata.disputes"
+type Dataset struct {
+	Path          string         `json:"path"`
+	Records       int            `json:"records"`
+	Numeric       []NumericStats `json:"numeric,omitempty"`
+	Distributions []Distribution `json:"distributions,omitempty"`
+	Series        []Series       `json:"series,omitempty"`
+}
+
+// NumericStats summarizes a numeric field
+type NumericStats struct {
+	Field  string  `json:"field"`
+	Count  int     `json:"count"`
+	Sum    float64 `json:"sum"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	StdDev float64 `json:"std_dev"`
+}
+
+// Distribution counts the values of a categorical field, most common first
+type Distribution struct {
+	Field    string       `json:"field"`
+	Count    int          `json:"count"`
+	Distinct int          `json:"distinct"`
+	Values   []ValueCount `json:"values"`
+	Other    int          `json:"other,omitempty"` // Records with values beyond those listed
+}
+
+// ValueCount is the number and share of records with a value
+type ValueCount struct {
+	Value string  `json:"value"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// Series counts records per interval of a time field, with the averages of the numeric
+// fields in each interval. Intervals without records are included.
+type Series struct {
+	Field    string    `json:"field"`
+	Interval string    `json:"interval"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Buckets  []Bucket  `json:"buckets"`
+}
+
+// Bucket is one interval of a series
+type Bucket struct {
+	Start    time.Time          `json:"start"`
+	Count    int                `json:"count"`
+	Averages map[string]float64 `json:"averages,omitempty"`
+}
+
+// Summarize computes the statistics of every list of records in data: data itself when
+// it is a list, and the lists it holds up to two levels down. Fields of nested objects
+// are named by their path, e.g. "customer.tier". It returns nil when data holds no records.
+func Summarize(data interface{}) *Summary {
+	// Work on generic JSON values so typed data is summarized the same way
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil
+	}
+
+	summary := &Summary{}
+	collectDatasets(decoded, "", 0, summary)
+	if len(summary.Datasets) == 0 {
+		return nil
+	}
+	return summary
+}
+
+// collectDatasets adds the lists of records within value to summary
+func collectDatasets(value interface{}, path string, depth int, summary *Summary) {
+	switch v := value.(type) {
+	case []interface{}:
+		var records []map[string]interface{}
+		for _, item := range v {
+			if record, ok := item.(map[string]interface{}); ok {
+				records = append(records, record)
+			}
+		}
+		if len(records) > 0 {
+			summary.Datasets = append(summary.Datasets, summarizeRecords(path, records))
+		}
+	case map[string]interface{}:
+		if depth >= 2 {
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectDatasets(v[key], joinPath(path, key), depth+1, summary)
+		}
+	}
+}
+
+// summarizeRecords computes the statistics of a list of records
+func summarizeRecords(path string, records []map[string]interface{}) Dataset {
+	numbers := map[string][]float64{}
+	categories := map[string][]string{}
+	times := map[string]int{}
+	unparsedTimes := map[string]bool{}
+	flat := make([]map[string]interface{}, len(records))
+
+	for i, record := range records {
+		flat[i] = map[string]interface{}{}
+		flatten(record, "", flat[i])
+		for field, value := range flat[i] {
+			switch v := value.(type) {
+			case float64:
+				numbers[field] = append(numbers[field], v)
+			case bool:
+				categories[field] = append(categories[field], strconv.FormatBool(v))
+			case string:
+				v = strings.TrimSpace(v)
+				if v == "" {
+					continue
+				}
+				categories[field] = append(categories[field], v)
+				if _, ok := ParseTime(v); ok {
+					times[field]++
+				} else {
+					unparsedTimes[field] = true
+				}
+			}
+		}
+	}
+
+	dataset := Dataset{Path: path, Records: len(records)}
+	for _, field := range sortedKeys(numbers) {
+		dataset.Numeric = append(dataset.Numeric, numericStats(field, numbers[field]))
+	}
+	for _, field := range sortedKeys(categories) {
+		if times[field] >= 2 && !unparsedTimes[field] {
+			dataset.Series = append(dataset.Series, series(field, flat, numbers))
+			continue
+		}
+		if distribution, ok := distribution(field, categories[field]); ok {
+			dataset.Distributions = append(dataset.Distributions, distribution)
+		}
+	}
+	return dataset
+}
+
+// flatten copies the scalar fields of record into flat, naming nested fields by path.
+// Lists are left out.
+func flatten(record map[string]interface{}, prefix string, flat map[string]interface{}) {
+	for key, value := range record {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if prefix == "" {
+				flatten(v, key, flat)
+			}
+		case []interface{}, nil:
+		default:
+			flat[joinPath(prefix, key)] = v
+		}
+	}
+}
+
+// numericStats summarizes the values of a numeric field
+func numericStats(field string, values []float64) NumericStats {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	var stdDev float64
+	if len(sorted) > 1 {
+		var squares float64
+		for _, v := range sorted {
+			squares += (v - mean) * (v - mean)
+		}
+		stdDev = math.Sqrt(squares / float64(len(sorted)-1))
+	}
+
+	return NumericStats{
+		Field:  field,
+		Count:  len(sorted),
+		Sum:    round(sum),
+		Mean:   round(mean),
+		Median: round(median),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		StdDev: round(stdDev),
+	}
+}
+
+// distribution counts the values of a field, or reports false when the field looks like
+// free text or an identifier rather than a category
+func distribution(field string, values []string) (Distribution, bool) {
+	counts := map[string]int{}
+	length := 0
+	for _, v := range values {
+		counts[v]++
+		length += len(v)
+	}
+	if len(counts) > maxDistinctValues || length/len(values) > maxCategoryLength {
+		return Distribution{}, false
+	}
+	if len(values) >= 10 && len(counts) == len(values) {
+		return Distribution{}, false
+	}
+
+	result := Distribution{Field: field, Count: len(values), Distinct: len(counts)}
+	for value, count := range counts {
+		result.Values = append(result.Values, ValueCount{
+			Value: value,
+			Count: count,
+			Share: round(float64(count) / float64(len(values))),
+		})
+	}
+	sort.Slice(result.Values, func(i, j int) bool {
+		if result.Values[i].Count != result.Values[j].Count {
+			return result.Values[i].Count > result.Values[j].Count
+		}
+		return result.Values[i].Value < result.Values[j].Value
+	})
+	if len(result.Values) > maxDistributionValues {
+		for _, value := range result.Values[maxDistributionValues:] {
+			result.Other += value.Count
+		}
+		result.Values = result.Values[:maxDistributionValues]
+	}
+	return result, true
+}
+
+// series buckets the records by a time field, picking the interval from its span
+func series(field string, records []map[string]interface{}, numbers map[string][]float64) Series {
+	type entry struct {
+		at     time.Time
+		record map[string]interface{}
+	}
+	var entries []entry
+	for _, record := range records {
+		if v, ok := record[field].(string); ok {
+			if t, ok := ParseTime(strings.TrimSpace(v)); ok {
+				entries = append(entries, entry{t.UTC(), record})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+
+	start, end := entries[0].at, entries[len(entries)-1].at
+	interval := IntervalYear
+	switch span := end.Sub(start); {
+	case span <= 62*24*time.Hour:
+		interval = IntervalDay
+	case span <= 26*7*24*time.Hour:
+		interval = IntervalWeek
+	case span <= 10*366*24*time.Hour:
+		interval = IntervalMonth
+	}
+
+	result := Series{Field: field, Interval: interval, Start: start, End: end}
+	i := 0
+	for bucketStart := truncate(start, interval); !bucketStart.After(end); bucketStart = next(bucketStart, interval) {
+		bucket := Bucket{Start: bucketStart}
+		sums, counts := map[string]float64{}, map[string]float64{}
+		bucketEnd := next(bucketStart, interval)
+		for ; i < len(entries) && entries[i].at.Before(bucketEnd); i++ {
+			bucket.Count++
+			for numeric := range numbers {
+				if v, ok := entries[i].record[numeric].(float64); ok {
+					sums[numeric] += v
+					counts[numeric]++
+				}
+			}
+		}
+		if len(sums) > 0 {
+			bucket.Averages = make(map[string]float64, len(sums))
+			for numeric, sum := range sums {
+				bucket.Averages[numeric] = round(sum / counts[numeric])
+			}
+		}
+		result.Buckets = append(result.Buckets, bucket)
+	}
+	return result
+}
+
+// truncate returns the start of the interval containing t
+func truncate(t time.Time, interval string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case IntervalWeek:
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// next returns the start of the interval after the one starting at t
+func next(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	case IntervalYear:
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// ParseTime parses a time value in one of the recognized layouts: RFC 3339, or a date
+// with or without a time
+func ParseTime(value string) (time.Time, bool) {
+	if len(value) < 10 || value[4] != '-' {
+		return time.Time{}, false
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// round rounds to 4 decimals so sums and averages do not carry float artifacts
+func round(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// joinPath joins a field path and a key
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
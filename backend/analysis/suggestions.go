@@ -0,0 +1,305 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// Endpoints of the requests a suggestion proposes
+const (
+	SuggestionEndpointAnalysis = "/api/analysis"
+	SuggestionEndpointChain    = "/api/analysis/chain"
+)
+
+// maxSuggestions bounds the suggestions returned for one chain
+const maxSuggestions = 5
+
+// veryNegativeScore is the sentiment score at or below which a conversation is very negative
+const veryNegativeScore = -0.5
+
+// maxGroupValues is the most distinct values a categorical field may have to split a trend by it
+const maxGroupValues = 10
+
+// Suggestion is a proposed next analysis, with a request that is ready to run against
+// its endpoint
+type Suggestion struct {
+	Title     string `json:"title"`
+	Rationale string `json:"rationale"`
+	// Source is the chain step whose result prompted the suggestion
+	Source   string `json:"source"`
+	Endpoint string `json:"endpoint"`
+	// Request is a models.StandardAnalysisRequest for the analysis endpoint, or a chain
+	// request for the chain endpoint
+	Request interface{} `json:"request"`
+}
+
+// ChainContext is the request a chain ran with, which suggestions build on
+type ChainContext struct {
+	WorkflowID string
+	Steps      []string
+	Text       string
+	// Parameters are the chain's parameters keyed by step
+	Parameters map[string]interface{}
+}
+
+// SuggestNextAnalyses proposes logical next steps after a chain: splitting trends by a
+// categorical field, digging into negative or dominant cohorts, explaining unexpected
+// patterns, extracting attributes findings are missing, and extending the chain
+// towards a plan. Suggestions come in order of the chain's steps.
+func SuggestNextAnalyses(chain ChainContext, results map[string]interface{}) []Suggestion {
+	var suggestions []Suggestion
+	for _, step := range chain.Steps {
+		switch step {
+		case "trends":
+			var result TrendsResult
+			if decodeStepResult(results, step, &result) {
+				suggestions = append(suggestions, suggestTrendSplits(chain, result)...)
+			}
+		case "patterns":
+			var result PatternsResult
+			if decodeStepResult(results, step, &result) {
+				suggestions = append(suggestions, suggestPatternCauses(chain, result)...)
+			}
+		case "sentiment":
+			var result SentimentResult
+			if decodeStepResult(results, step, &result) {
+				suggestions = append(suggestions, suggestNegativeCohort(chain, result)...)
+			}
+		case "intent":
+			var result IntentResult
+			if decodeStepResult(results, step, &result) {
+				suggestions = append(suggestions, suggestIntentCohort(chain, result)...)
+			}
+		case "findings":
+			var result FindingsResult
+			if decodeStepResult(results, step, &result) {
+				suggestions = append(suggestions, suggestMissingAttributes(chain, result)...)
+			}
+		}
+	}
+	suggestions = append(suggestions, suggestChainExtension(chain)...)
+
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
+
+// suggestTrendSplits proposes splitting the most confident trend by a categorical field
+// of the computed metrics
+func suggestTrendSplits(chain ChainContext, result TrendsResult) []Suggestion {
+	if len(result.Trends) == 0 || result.Metrics == nil {
+		return nil
+	}
+	top := result.Trends[0]
+	for _, trend := range result.Trends[1:] {
+		if trend.Confidence > top.Confidence {
+			top = trend
+		}
+	}
+
+	for _, dataset := range result.Metrics.Datasets {
+		for _, distribution := range dataset.Distributions {
+			if distribution.Distinct < 2 || distribution.Distinct > maxGroupValues {
+				continue
+			}
+			focusArea := fmt.Sprintf("%s by %s", top.FocusArea, distribution.Field)
+			return []Suggestion{{
+				Title:     fmt.Sprintf("Split the %s trend by %s", top.FocusArea, distribution.Field),
+				Rationale: fmt.Sprintf("%s takes %d distinct values in the data; the trend may differ between them", distribution.Field, distribution.Distinct),
+				Source:    "trends",
+				Endpoint:  SuggestionEndpointAnalysis,
+				Request: chain.analysisRequest("trends", map[string]interface{}{
+					"focus_areas": []string{focusArea},
+				}, nil),
+			}}
+		}
+	}
+	return nil
+}
+
+// suggestPatternCauses proposes findings that explain the unexpected patterns
+func suggestPatternCauses(chain ChainContext, result PatternsResult) []Suggestion {
+	if len(result.UnexpectedPatterns) == 0 {
+		return nil
+	}
+	questions := make([]string, 0, len(result.UnexpectedPatterns))
+	for _, pattern := range result.UnexpectedPatterns {
+		questions = append(questions, fmt.Sprintf("What causes %s?", strings.TrimSuffix(lowerFirst(pattern.Description), ".")))
+	}
+	return []Suggestion{{
+		Title:     "Explain the unexpected patterns",
+		Rationale: fmt.Sprintf("%d patterns fell outside the requested pattern types", len(result.UnexpectedPatterns)),
+		Source:    "patterns",
+		Endpoint:  SuggestionEndpointAnalysis,
+		Request: chain.analysisRequest("findings", map[string]interface{}{
+			"questions": questions,
+		}, nil),
+	}}
+}
+
+// suggestNegativeCohort proposes re-running patterns on the very negative conversations
+func suggestNegativeCohort(chain ChainContext, result SentimentResult) []Suggestion {
+	var ids []string
+	for _, conversation := range result.Conversations {
+		if conversation.ConversationID != "" && conversation.Overall.Score <= veryNegativeScore {
+			ids = append(ids, conversation.ConversationID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return []Suggestion{{
+		Title:     "Re-run patterns on the very negative cohort",
+		Rationale: fmt.Sprintf("%d of %d conversations scored %.1f or below", len(ids), len(result.Conversations), veryNegativeScore),
+		Source:    "sentiment",
+		Endpoint:  SuggestionEndpointAnalysis,
+		Request:   chain.analysisRequest("patterns", chain.stepParameters("patterns"), ids),
+	}}
+}
+
+// suggestIntentCohort proposes a sentiment analysis of the conversations of the most
+// common intent
+func suggestIntentCohort(chain ChainContext, result IntentResult) []Suggestion {
+	if len(result.Distribution) == 0 {
+		return nil
+	}
+	top := result.Distribution[0]
+	var ids []string
+	for _, conversation := range result.Conversations {
+		if conversation.ConversationID != "" && conversation.Label == top.Label {
+			ids = append(ids, conversation.ConversationID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return []Suggestion{{
+		Title:     fmt.Sprintf("Analyze sentiment of %s conversations", top.LabelName),
+		Rationale: fmt.Sprintf("%s is the most common intent, with %d conversations", top.LabelName, top.Count),
+		Source:    "intent",
+		Endpoint:  SuggestionEndpointAnalysis,
+		Request:   chain.analysisRequest("sentiment", chain.stepParameters("sentiment"), ids),
+	}}
+}
+
+// suggestMissingAttributes proposes extracting the attributes findings could not answer
+// their questions without
+func suggestMissingAttributes(chain ChainContext, result FindingsResult) []Suggestion {
+	if result.Coverage == nil || len(result.Coverage.NextAttributes) == 0 {
+		return nil
+	}
+	incomplete := len(result.Coverage.Incomplete())
+	return []Suggestion{{
+		Title:     "Extract the attributes missing to answer the open questions",
+		Rationale: fmt.Sprintf("%d questions are not fully answered by the data", incomplete),
+		Source:    "findings",
+		Endpoint:  SuggestionEndpointAnalysis,
+		Request: chain.analysisRequest("attributes", map[string]interface{}{
+			"attributes": result.Coverage.NextAttributes,
+		}, nil),
+	}}
+}
+
+// nextChainSteps is the step that usually follows the last step of a chain
+var nextChainSteps = map[string]string{
+	"trends":          "findings",
+	"patterns":        "findings",
+	"findings":        "recommendations",
+	"recommendations": "plan",
+}
+
+// suggestChainExtension proposes running the chain again with the step that usually
+// follows its last one
+func suggestChainExtension(chain ChainContext) []Suggestion {
+	if len(chain.Steps) == 0 {
+		return nil
+	}
+	last := chain.Steps[len(chain.Steps)-1]
+	next, ok := nextChainSteps[last]
+	if !ok {
+		return nil
+	}
+	for _, step := range chain.Steps {
+		if step == next {
+			return nil
+		}
+	}
+
+	steps := append(append([]string{}, chain.Steps...), next)
+	request := map[string]interface{}{
+		"workflow_id": chain.WorkflowID,
+		"steps":       steps,
+	}
+	if chain.Text != "" {
+		request["text"] = chain.Text
+	}
+	if len(chain.Parameters) > 0 {
+		request["parameters"] = chain.Parameters
+	}
+	return []Suggestion{{
+		Title:     fmt.Sprintf("Extend the chain with %s", next),
+		Rationale: fmt.Sprintf("%s usually builds on %s", next, last),
+		Source:    last,
+		Endpoint:  SuggestionEndpointChain,
+		Request:   request,
+	}}
+}
+
+// analysisRequest builds an analysis request on the chain's input, or on stored
+// conversations when conversationIDs are given
+func (c ChainContext) analysisRequest(analysisType string, parameters map[string]interface{}, conversationIDs []string) models.StandardAnalysisRequest {
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	request := models.StandardAnalysisRequest{
+		WorkflowID:      c.WorkflowID,
+		AnalysisType:    analysisType,
+		Parameters:      parameters,
+		ConversationIDs: conversationIDs,
+	}
+	if len(conversationIDs) == 0 {
+		request.Text = c.Text
+	}
+	return request
+}
+
+// stepParameters returns a copy of the parameters the chain gave a step, without the
+// chain-only settings
+func (c ChainContext) stepParameters(step string) map[string]interface{} {
+	parameters := map[string]interface{}{}
+	if stepParams, ok := c.Parameters[step].(map[string]interface{}); ok {
+		for key, value := range stepParams {
+			if key == "depends_on" || key == "input_mapping" || key == "input_data" {
+				continue
+			}
+			parameters[key] = value
+		}
+	}
+	return parameters
+}
+
+// decodeStepResult decodes the result of a chain step into a typed result, reporting
+// whether the step has a result of that shape
+func decodeStepResult(results map[string]interface{}, step string, v interface{}) bool {
+	result, ok := results[step]
+	if !ok || result == nil {
+		return false
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(encoded, v) == nil
+}
+
+// lowerFirst lowercases the first letter of a sentence
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,209 @@
+// Package transcript splits raw conversation transcripts into speaker turns.
+package transcript
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// timestampPattern matches the timestamps transcripts put before or after a speaker
+const timestampPattern = `\d{1,2}:\d{2}(?::\d{2})?(?:\.\d+)?|\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2})?(?:\.\d+)?Z?`
+
+// turnLine matches a line starting a turn: an optional timestamp, a speaker label of up
+// to four words, an optional timestamp in parentheses, and a colon or dash
+var turnLine = regexp.MustCompile(`^\s*(?:\[(` + timestampPattern + `)\]\s*|(` + timestampPattern + `)\s+(?:-\s+)?)?` +
+	`\**([A-Za-z][A-Za-z0-9_.'#]*(?: [A-Za-z0-9_.'#]+){0,3})\**\s*(?:\((` + timestampPattern + `)\))?\s*(?::|\s-\s)\**\s*(.*)$`)
+
+// roleKeywords map words found in speaker labels to roles
+var roleKeywords = map[string]string{
+	"customer":       models.RoleCustomer,
+	"caller":         models.RoleCustomer,
+	"client":         models.RoleCustomer,
+	"member":         models.RoleCustomer,
+	"user":           models.RoleCustomer,
+	"cust":           models.RoleCustomer,
+	"agent":          models.RoleAgent,
+	"representative": models.RoleAgent,
+	"rep":            models.RoleAgent,
+	"advisor":        models.RoleAgent,
+	"adviser":        models.RoleAgent,
+	"associate":      models.RoleAgent,
+	"operator":       models.RoleAgent,
+	"support":        models.RoleAgent,
+	"csr":            models.RoleAgent,
+	"specialist":     models.RoleAgent,
+	"bot":            models.RoleSystem,
+	"ivr":            models.RoleSystem,
+	"system":         models.RoleSystem,
+	"assistant":      models.RoleSystem,
+	"automated":      models.RoleSystem,
+}
+
+// Parse splits a transcript into turns. A turn starts at a line with a speaker label
+// such as "Customer:", "[00:01:12] Agent:" or "Agent (10:32) -"; lines without one
+// continue the previous turn. Text without any speaker labels is a single turn of an
+// unknown speaker.
+func Parse(text string) []models.Turn {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	matches := make([][]string, len(lines))
+	for i, line := range lines {
+		matches[i] = turnLine.FindStringSubmatch(line)
+	}
+	speakers := speakerLabels(matches)
+
+	var turns []models.Turn
+	for i, line := range lines {
+		if match := matches[i]; match != nil && speakers[match[3]] {
+			timestamp := match[1]
+			if timestamp == "" {
+				timestamp = match[2]
+			}
+			if timestamp == "" {
+				timestamp = match[4]
+			}
+			turns = append(turns, models.Turn{
+				Index:     len(turns) + 1,
+				Speaker:   match[3],
+				Role:      Role(match[3]),
+				Text:      strings.TrimSpace(match[5]),
+				Timestamp: timestamp,
+			})
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(turns) == 0 {
+			turns = append(turns, models.Turn{Index: 1, Role: models.RoleUnknown})
+		}
+		last := &turns[len(turns)-1]
+		if last.Text == "" {
+			last.Text = line
+		} else {
+			last.Text += "\n" + line
+		}
+	}
+	return turns
+}
+
+// speakerLabels returns the labels of matched lines that name speakers: labels with a
+// known role, and short labels starting at least two lines, so a sentence with a colon
+// is not taken for a speaker
+func speakerLabels(matches [][]string) map[string]bool {
+	counts := map[string]int{}
+	for _, match := range matches {
+		if match != nil {
+			counts[match[3]]++
+		}
+	}
+	speakers := map[string]bool{}
+	for label, count := range counts {
+		if strings.Contains(strings.ToLower(label), "http") {
+			continue
+		}
+		if Role(label) != models.RoleUnknown || (count >= 2 && len(strings.Fields(label)) <= 2) {
+			speakers[label] = true
+		}
+	}
+	return speakers
+}
+
+// Role returns the role of a speaker label: "customer", "agent", "system" or "unknown"
+func Role(speaker string) string {
+	words := strings.FieldsFunc(strings.ToLower(speaker), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	})
+	for _, word := range words {
+		if role, ok := roleKeywords[word]; ok {
+			return role
+		}
+	}
+	return models.RoleUnknown
+}
+
+// Structured reports whether turns came from a transcript with speaker labels
+func Structured(turns []models.Turn) bool {
+	for _, turn := range turns {
+		if turn.Speaker != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Speakers returns the distinct speaker labels of turns in order of appearance
+func Speakers(turns []models.Turn) []string {
+	seen := map[string]bool{}
+	speakers := []string{}
+	for _, turn := range turns {
+		if turn.Speaker != "" && !seen[turn.Speaker] {
+			seen[turn.Speaker] = true
+			speakers = append(speakers, turn.Speaker)
+		}
+	}
+	return speakers
+}
+
+// FilterRole returns the turns of one role, keeping their indexes
+func FilterRole(turns []models.Turn, role string) []models.Turn {
+	filtered := []models.Turn{}
+	for _, turn := range turns {
+		if turn.Role == role {
+			filtered = append(filtered, turn)
+		}
+	}
+	return filtered
+}
+
+// ValidRole reports whether role can be used to select turns
+func ValidRole(role string) bool {
+	return role == models.RoleCustomer || role == models.RoleAgent || role == models.RoleSystem
+}
+
+// Format renders turns for a prompt, one per line with their index and role, e.g.
+// "[3] Agent (agent): ...". Continuation lines are indented under their turn.
+func Format(turns []models.Turn) string {
+	var sb strings.Builder
+	for _, turn := range turns {
+		speaker := turn.Speaker
+		if speaker == "" {
+			speaker = "Unknown"
+		}
+		fmt.Fprintf(&sb, "[%d] %s (%s)", turn.Index, speaker, turn.Role)
+		if turn.Timestamp != "" {
+			fmt.Fprintf(&sb, " %s", turn.Timestamp)
+		}
+		sb.WriteString(": ")
+		sb.WriteString(strings.ReplaceAll(turn.Text, "\n", "\n    "))
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Plain renders turns back into a "Speaker: text" transcript
+func Plain(turns []models.Turn) string {
+	lines := make([]string, 0, len(turns))
+	for _, turn := range turns {
+		if turn.Speaker == "" {
+			lines = append(lines, turn.Text)
+			continue
+		}
+		lines = append(lines, turn.Speaker+": "+turn.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ForPrompt returns the text an analysis prompt shows for a transcript: its numbered
+// turns when it has speaker labels, and the text as is otherwise
+func ForPrompt(text string) string {
+	turns := Parse(text)
+	if !Structured(turns) {
+		return text
+	}
+	return Format(turns)
+}
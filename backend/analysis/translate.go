@@ -0,0 +1,36 @@
+package analysis
+
+import (
+	"context"
+
+	"agenticflows/backend/translate"
+)
+
+// llmTranslator translates with the analysis model, as the llm translation provider
+type llmTranslator struct {
+	facade *AnalysisFacade
+}
+
+// Translator returns a translator running on the analysis model
+func (f *AnalysisFacade) Translator() translate.Translator {
+	return llmTranslator{facade: f}
+}
+
+// Name returns the provider name
+func (t llmTranslator) Name() string {
+	return translate.ProviderLLM
+}
+
+// Translate translates text into the target language
+func (t llmTranslator) Translate(ctx context.Context, text, targetLanguage string) (translate.Translation, error) {
+	sourceLanguage, translated, err := t.facade.TextProcessor.TranslateText(ctx, text, targetLanguage)
+	if err != nil {
+		return translate.Translation{}, err
+	}
+	return translate.Translation{
+		SourceLanguage: sourceLanguage,
+		TargetLanguage: targetLanguage,
+		Text:           translated,
+		Provider:       translate.ProviderLLM,
+	}, nil
+}
@@ -0,0 +1,316 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// DefaultLocale is the locale numbers and dates are read in when a request names none
+const DefaultLocale = "en-US"
+
+// DateLayout is the layout of typed date values
+const DateLayout = "2006-01-02"
+
+// Locale decides how numbers and dates that read differently across countries are read
+type Locale struct {
+	Tag string
+	// DecimalComma means "1,5" is one and a half and "1.500" is fifteen hundred
+	DecimalComma bool
+	// DayFirst means "03/04/2025" is the 3rd of April
+	DayFirst bool
+	// Region is the upper-case country code of the locale, if it has one
+	Region string
+}
+
+// decimalCommaLanguages are the languages that write decimals with a comma
+var decimalCommaLanguages = map[string]bool{
+	"bg": true, "cs": true, "da": true, "de": true, "el": true, "es": true, "et": true,
+	"fi": true, "fr": true, "hr": true, "hu": true, "id": true, "it": true, "lt": true,
+	"lv": true, "nb": true, "nl": true, "nn": true, "no": true, "pl": true, "pt": true,
+	"ro": true, "ru": true, "sk": true, "sl": true, "sr": true, "sv": true, "tr": true,
+	"uk": true, "vi": true,
+}
+
+// monthFirstRegions are the countries that write the month before the day
+var monthFirstRegions = map[string]bool{"US": true, "PH": true, "FM": true, "MH": true}
+
+// ParseLocale reads a locale tag such as "en-US", "de" or "pt_BR". English without a
+// region is read as American English.
+func ParseLocale(tag string) (Locale, error) {
+	parts := strings.FieldsFunc(strings.TrimSpace(tag), func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 || len(parts) > 2 || len(parts[0]) < 2 || len(parts[0]) > 3 || !isLetters(parts[0]) {
+		return Locale{}, fmt.Errorf("invalid locale %q; use a tag such as en-US or de-DE", tag)
+	}
+	language := strings.ToLower(parts[0])
+	region := ""
+	if len(parts) == 2 {
+		if len(parts[1]) != 2 || !isLetters(parts[1]) {
+			return Locale{}, fmt.Errorf("invalid locale %q; use a tag such as en-US or de-DE", tag)
+		}
+		region = strings.ToUpper(parts[1])
+	}
+	if language == "en" && region == "" {
+		region = "US"
+	}
+
+	locale := Locale{Tag: language, Region: region, DecimalComma: decimalCommaLanguages[language], DayFirst: !monthFirstRegions[region]}
+	if region != "" {
+		locale.Tag += "-" + region
+	}
+	return locale, nil
+}
+
+// isLetters reports whether s consists of ASCII letters
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// Coerce reads an extracted value as the declared type of its attribute. The typed value
+// is set in TypedValue and Value is replaced with its canonical text, such as "1200.5"
+// for "$1,200.50" or "2025-04-03" for "April 3, 2025", keeping the value as given in
+// RawValue; amounts also get the code of their currency. Values that can't be read set
+// TypeError instead. String attributes and values meaning nothing was found are left
+// as they are.
+func Coerce(attribute models.AttributeDefinition, value *models.AttributeValue, locale Locale) {
+	if Empty(value.Value) {
+		return
+	}
+
+	var typed interface{}
+	var canonical, currency string
+	var err error
+	switch attribute.Type {
+	case models.AttributeTypeNumber:
+		var n float64
+		if n, currency, err = parseAmount(value.Value, locale); err == nil {
+			typed, canonical = n, strconv.FormatFloat(n, 'f', -1, 64)
+		}
+	case models.AttributeTypeInteger:
+		var n float64
+		if n, currency, err = parseAmount(value.Value, locale); err == nil {
+			if n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+				err = fmt.Errorf("%s is not a whole number", strconv.FormatFloat(n, 'f', -1, 64))
+			} else {
+				typed, canonical = int64(n), strconv.FormatInt(int64(n), 10)
+			}
+		}
+	case models.AttributeTypeBoolean:
+		b, ok := toBool(strings.Trim(value.Value, ".!"))
+		if !ok {
+			err = fmt.Errorf("not a yes or no answer")
+		}
+		typed, canonical = b, strconv.FormatBool(b)
+	case models.AttributeTypeDate:
+		var date time.Time
+		if date, err = parseDate(value.Value, locale); err == nil {
+			canonical = date.Format(DateLayout)
+			typed = canonical
+		}
+	default:
+		return
+	}
+
+	if err != nil {
+		value.TypeError = fmt.Sprintf("%q is not of type %s: %s", value.Value, attribute.Type, err)
+		return
+	}
+	if canonical != value.Value && value.RawValue == "" {
+		value.RawValue = value.Value
+	}
+	value.Value, value.TypedValue, value.Currency = canonical, typed, currency
+	if attribute.Type != models.AttributeTypeBoolean {
+		value.Locale = locale.Tag
+	}
+}
+
+// currencySymbols are the currency signs amounts are written with; $ is the dollar of
+// the locale's country
+var currencySymbols = []struct{ symbol, code string }{
+	{"US$", "USD"}, {"C$", "CAD"}, {"CA$", "CAD"}, {"A$", "AUD"}, {"AU$", "AUD"},
+	{"NZ$", "NZD"}, {"R$", "BRL"}, {"$", ""}, {"€", "EUR"}, {"£", "GBP"}, {"¥", "JPY"},
+	{"₹", "INR"}, {"₩", "KRW"}, {"₽", "RUB"}, {"₺", "TRY"}, {"₪", "ILS"}, {"₱", "PHP"},
+	{"zł", "PLN"},
+}
+
+// currencyWords are currency names written after amounts
+var currencyWords = map[string]string{
+	"dollar": "", "dollars": "", "euro": "EUR", "euros": "EUR", "pound": "GBP",
+	"pounds": "GBP", "yen": "JPY", "rupees": "INR", "francs": "CHF",
+}
+
+// dollarRegions are the countries whose currency is written with a plain $
+var dollarRegions = map[string]string{
+	"US": "USD", "CA": "CAD", "AU": "AUD", "NZ": "NZD", "MX": "MXN", "SG": "SGD",
+	"HK": "HKD", "AR": "ARS", "CL": "CLP", "CO": "COP",
+}
+
+// parseAmount reads a number written in a locale, optionally with a currency and a sign
+// or accounting parentheses, and returns it with its ISO 4217 currency code
+func parseAmount(text string, locale Locale) (float64, string, error) {
+	s := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(text), "."))
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, strings.TrimSpace(s[1:len(s)-1])
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "−") {
+		negative, s = true, strings.TrimSpace(strings.TrimLeft(s, "-−"))
+	}
+
+	// A currency is a sign, a three-letter code or a name before or after the number
+	currency := ""
+	found := false
+	for _, c := range currencySymbols {
+		if strings.HasPrefix(s, c.symbol) {
+			s, currency, found = strings.TrimSpace(strings.TrimPrefix(s, c.symbol)), c.code, true
+			break
+		}
+		if strings.HasSuffix(s, c.symbol) {
+			s, currency, found = strings.TrimSpace(strings.TrimSuffix(s, c.symbol)), c.code, true
+			break
+		}
+	}
+	if !found {
+		if fields := strings.Fields(s); len(fields) == 2 {
+			switch {
+			case isCurrencyCode(fields[0]):
+				s, currency, found = fields[1], fields[0], true
+			case isCurrencyCode(fields[1]):
+				s, currency, found = fields[0], fields[1], true
+			default:
+				if code, ok := currencyWords[strings.ToLower(fields[1])]; ok {
+					s, currency, found = fields[0], code, true
+				}
+			}
+		}
+	}
+	if found && currency == "" {
+		currency = dollarRegions[locale.Region]
+		if currency == "" {
+			currency = "USD"
+		}
+	}
+
+	// The sign may also follow the currency, as in "$-12.50"
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "−") {
+		negative, s = true, strings.TrimLeft(s, "-−")
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+
+	n, err := parseLocaleNumber(s, locale)
+	if err != nil {
+		return 0, "", err
+	}
+	if negative {
+		n = -n
+	}
+	return n, currency, nil
+}
+
+// isCurrencyCode reports whether s looks like an ISO 4217 code such as USD
+func isCurrencyCode(s string) bool {
+	return len(s) == 3 && isLetters(s) && strings.ToUpper(s) == s
+}
+
+// parseLocaleNumber reads digits with grouping and decimal separators. With both a comma
+// and a period the last one is the decimal separator. A lone separator followed by three
+// digits groups thousands unless the locale writes decimals with it; repeated ones always
+// group.
+func parseLocaleNumber(s string, locale Locale) (float64, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == '\'' || r == '’' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return 0, fmt.Errorf("no number found")
+	}
+
+	commas, periods := strings.Count(s, ","), strings.Count(s, ".")
+	switch {
+	case commas > 0 && periods > 0:
+		if strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+			s = strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), ",", ".")
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case commas > 1:
+		s = strings.ReplaceAll(s, ",", "")
+	case commas == 1:
+		if groupsThousands(s, ",") && !locale.DecimalComma {
+			s = strings.ReplaceAll(s, ",", "")
+		} else {
+			s = strings.ReplaceAll(s, ",", ".")
+		}
+	case periods > 1:
+		s = strings.ReplaceAll(s, ".", "")
+	case periods == 1:
+		if groupsThousands(s, ".") && locale.DecimalComma {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("no number found")
+	}
+	return n, nil
+}
+
+// groupsThousands reports whether the only separator in s is followed by exactly three
+// digits
+func groupsThousands(s, separator string) bool {
+	i := strings.Index(s, separator)
+	return i > 0 && len(s)-i-1 == 3
+}
+
+// Date layouts tried in order; numeric dates with the day and month in either order are
+// tried in the locale's order first
+var (
+	isoDateLayouts = []string{
+		DateLayout, time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006/01/02",
+		"January 2, 2006", "Jan 2, 2006", "January 2 2006", "Jan 2 2006", "2 January 2006",
+		"2 Jan 2006", "Monday, January 2, 2006", "Mon, Jan 2, 2006", "2. January 2006",
+	}
+	monthFirstLayouts = []string{"1/2/2006", "1/2/06", "1-2-2006"}
+	dayFirstLayouts   = []string{"2/1/2006", "2/1/06", "2-1-2006", "2.1.2006", "2.1.06"}
+)
+
+// ordinalSuffix matches the suffixes of ordinal days, as in "April 3rd"
+var ordinalSuffix = regexp.MustCompile(`(\d)(st|nd|rd|th)\b`)
+
+// parseDate reads a date written in a common format
+func parseDate(text string, locale Locale) (time.Time, error) {
+	s := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(text), "."))
+	s = ordinalSuffix.ReplaceAllString(s, "$1")
+	for _, layout := range isoDateLayouts {
+		if date, err := time.Parse(layout, s); err == nil {
+			return date, nil
+		}
+	}
+
+	numeric := append(append([]string{}, monthFirstLayouts...), dayFirstLayouts...)
+	if locale.DayFirst {
+		numeric = append(append([]string{}, dayFirstLayouts...), monthFirstLayouts...)
+	}
+	for _, layout := range numeric {
+		if date, err := time.Parse(layout, s); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no date found")
+}
@@ -0,0 +1,130 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// EnumRule names the violations of values that are not one of their attribute's enum
+// values
+const EnumRule = "enum_values"
+
+// negations are words that keep a value from matching an enum value it contains, so "not
+// urgent" is not taken for "urgent"
+var negations = map[string]bool{"not": true, "no": true, "non": true, "never": true}
+
+// Empty reports whether an extracted value means nothing was found, such as "n/a"
+func Empty(value string) bool {
+	return emptyValues[normalize(value)]
+}
+
+// NormalizeEnum maps a value extracted for an attribute to the enum value it stands for.
+// Values match regardless of case, surrounding punctuation and spaces, hyphens and
+// underscores; they also match the attribute's synonyms, numbers match equal numbers and
+// a value containing exactly one enum value or synonym as whole words, such as "very
+// negative", matches it. ok is false when the value matches no enum value. Attributes
+// without enum values and values meaning nothing was found are returned as is.
+func NormalizeEnum(attribute models.AttributeDefinition, value string) (string, bool) {
+	if len(attribute.EnumValues) == 0 || Empty(value) {
+		return value, true
+	}
+
+	key := enumKey(value)
+	number, isNumber := toNumber(value)
+	for _, allowed := range attribute.EnumValues {
+		if enumKey(allowed) == key {
+			return allowed, true
+		}
+		if isNumber {
+			if n, ok := toNumber(allowed); ok && n == number {
+				return allowed, true
+			}
+		}
+		for _, synonym := range attribute.EnumSynonyms[allowed] {
+			if enumKey(synonym) == key {
+				return allowed, true
+			}
+		}
+	}
+
+	// Otherwise the value may qualify one enum value, as in "very negative"
+	words := strings.Split(key, "_")
+	for _, word := range words {
+		if negations[word] {
+			return value, false
+		}
+	}
+	match := ""
+	for _, allowed := range attribute.EnumValues {
+		phrases := append([]string{allowed}, attribute.EnumSynonyms[allowed]...)
+		for _, phrase := range phrases {
+			if containsWords(words, strings.Split(enumKey(phrase), "_")) {
+				if match != "" && match != allowed {
+					return value, false
+				}
+				match = allowed
+			}
+		}
+	}
+	if match == "" {
+		return value, false
+	}
+	return match, true
+}
+
+// enumKey normalizes a value for comparison with enum values
+func enumKey(value string) string {
+	return normalize(strings.Trim(strings.TrimSpace(value), `.,;:!?"'()[]`))
+}
+
+// containsWords reports whether phrase occurs in words as consecutive whole words
+func containsWords(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		found := true
+		for j, word := range phrase {
+			if words[i+j] != word {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckEnums returns a violation of EnumRule for each value marked as out of its
+// attribute's enum values
+func CheckEnums(values []models.AttributeValue, attributes []models.AttributeDefinition) []models.RuleViolation {
+	allowed := make(map[string][]string, len(attributes))
+	for _, attribute := range attributes {
+		allowed[attribute.FieldName] = attribute.EnumValues
+	}
+
+	var violations []models.RuleViolation
+	for _, value := range values {
+		if !value.OutOfEnum {
+			continue
+		}
+		violations = append(violations, models.RuleViolation{
+			Rule:    EnumRule,
+			Message: fmt.Sprintf("%q is not one of the allowed values of %s: %s", value.Value, value.FieldName, strings.Join(allowed[value.FieldName], ", ")),
+			Fields:  []string{value.FieldName},
+			Values:  map[string]string{value.FieldName: value.Value},
+		})
+	}
+	return violations
+}
+
+// HasEnums reports whether any attribute declares enum values
+func HasEnums(attributes []models.AttributeDefinition) bool {
+	for _, attribute := range attributes {
+		if len(attribute.EnumValues) > 0 {
+			return true
+		}
+	}
+	return false
+}
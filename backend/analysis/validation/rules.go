@@ -0,0 +1,308 @@
+// Package validation checks extracted attribute values against cross-field consistency
+// rules, such as "a refunded dispute has a disputed amount"
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// Condition operators
+const (
+	OpEquals       = "eq"
+	OpNotEquals    = "ne"
+	OpIn           = "in"
+	OpNotIn        = "not_in"
+	OpGreater      = "gt"
+	OpGreaterEqual = "gte"
+	OpLess         = "lt"
+	OpLessEqual    = "lte"
+	OpPresent      = "present"
+	OpAbsent       = "absent"
+)
+
+// MaxRules is the maximum number of rules checked per request
+const MaxRules = 100
+
+// Condition tests the value of one attribute
+type Condition struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Rule is a cross-field consistency rule. A record violates it when all When conditions
+// hold and any Require condition doesn't; a rule without Require conditions forbids the
+// combination of its When conditions.
+type Rule struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	When        []Condition `json:"when"`
+	Require     []Condition `json:"require,omitempty"`
+}
+
+// emptyValues are extracted values that mean nothing was found
+var emptyValues = map[string]bool{
+	"":        true,
+	"n/a":     true,
+	"na":      true,
+	"none":    true,
+	"null":    true,
+	"unknown": true,
+}
+
+// ParseRules reads and validates rules from a request parameter or stored JSON
+func ParseRules(param interface{}) ([]Rule, error) {
+	if param == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(param)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(encoded, &rules); err != nil {
+		return nil, fmt.Errorf("validation_rules must be a list of rules: %w", err)
+	}
+	if err := Validate(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Validate checks that rules are well formed
+func Validate(rules []Rule) error {
+	if len(rules) > MaxRules {
+		return fmt.Errorf("at most %d validation rules are allowed", MaxRules)
+	}
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("validation rule %d has no name", i)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("duplicate validation rule %s", rule.Name)
+		}
+		seen[rule.Name] = true
+		if len(rule.When) == 0 {
+			return fmt.Errorf("validation rule %s has no when conditions", rule.Name)
+		}
+		for _, condition := range append(append([]Condition{}, rule.When...), rule.Require...) {
+			if err := condition.validate(); err != nil {
+				return fmt.Errorf("validation rule %s: %w", rule.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks that a condition has a field, a known operator and a value it can compare
+func (c Condition) validate() error {
+	if c.Field == "" {
+		return fmt.Errorf("condition has no field")
+	}
+	switch c.Op {
+	case OpPresent, OpAbsent:
+	case OpEquals, OpNotEquals:
+		if c.Value == nil {
+			return fmt.Errorf("condition on %s needs a value", c.Field)
+		}
+	case OpIn, OpNotIn:
+		if _, ok := c.Value.([]interface{}); !ok {
+			return fmt.Errorf("condition on %s needs a list of values", c.Field)
+		}
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual:
+		if _, ok := toNumber(c.Value); !ok {
+			return fmt.Errorf("condition on %s needs a numeric value", c.Field)
+		}
+	default:
+		return fmt.Errorf("condition on %s has unknown op %q", c.Field, c.Op)
+	}
+	return nil
+}
+
+// Fields returns the attributes the rules refer to
+func Fields(rules []Rule) []string {
+	var fields []string
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		for _, condition := range append(append([]Condition{}, rule.When...), rule.Require...) {
+			if !seen[condition.Field] {
+				seen[condition.Field] = true
+				fields = append(fields, condition.Field)
+			}
+		}
+	}
+	return fields
+}
+
+// Check returns the rules the values of one record violate
+func Check(values []models.AttributeValue, rules []Rule) []models.RuleViolation {
+	byField := make(map[string]string, len(values))
+	for _, value := range values {
+		byField[value.FieldName] = value.Value
+	}
+
+	var violations []models.RuleViolation
+	for _, rule := range rules {
+		if !allHold(rule.When, byField) {
+			continue
+		}
+		failed := -1
+		for i, condition := range rule.Require {
+			if !condition.holds(byField) {
+				failed = i
+				break
+			}
+		}
+		if len(rule.Require) > 0 && failed < 0 {
+			continue
+		}
+
+		violation := models.RuleViolation{Rule: rule.Name, Message: rule.Description, Values: map[string]string{}}
+		conditions := rule.When
+		if failed >= 0 {
+			conditions = append(append([]Condition{}, rule.When...), rule.Require[failed])
+		}
+		for _, condition := range conditions {
+			violation.Fields = append(violation.Fields, condition.Field)
+			violation.Values[condition.Field] = byField[condition.Field]
+		}
+		if violation.Message == "" {
+			violation.Message = describe(rule, failed)
+		}
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+// allHold reports whether every condition holds
+func allHold(conditions []Condition, values map[string]string) bool {
+	for _, condition := range conditions {
+		if !condition.holds(values) {
+			return false
+		}
+	}
+	return true
+}
+
+// holds reports whether a condition holds for the values of a record. Text is compared
+// case-insensitively; ordering conditions fail on values that aren't numbers.
+func (c Condition) holds(values map[string]string) bool {
+	value, ok := values[c.Field]
+	present := ok && !emptyValues[normalize(value)]
+	switch c.Op {
+	case OpPresent:
+		return present
+	case OpAbsent:
+		return !present
+	case OpEquals:
+		return present && equal(value, c.Value)
+	case OpNotEquals:
+		return !present || !equal(value, c.Value)
+	case OpIn, OpNotIn:
+		in := false
+		if present {
+			for _, option := range c.Value.([]interface{}) {
+				if equal(value, option) {
+					in = true
+					break
+				}
+			}
+		}
+		return in == (c.Op == OpIn)
+	}
+
+	if !present {
+		return false
+	}
+	number, ok := toNumber(value)
+	limit, _ := toNumber(c.Value)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case OpGreater:
+		return number > limit
+	case OpGreaterEqual:
+		return number >= limit
+	case OpLess:
+		return number < limit
+	case OpLessEqual:
+		return number <= limit
+	}
+	return false
+}
+
+// equal compares an extracted value with a rule value: as numbers when both are numbers,
+// else as normalized text, so "Yes" equals true and "$40.00" equals 40
+func equal(value string, expected interface{}) bool {
+	if n, ok := toNumber(expected); ok {
+		if m, ok := toNumber(value); ok {
+			return n == m
+		}
+	}
+	if b, ok := expected.(bool); ok {
+		if parsed, ok := toBool(value); ok {
+			return parsed == b
+		}
+		return false
+	}
+	return normalize(value) == normalize(fmt.Sprint(expected))
+}
+
+// normalize lowercases a value and treats spaces, hyphens and underscores alike
+func normalize(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(value)
+}
+
+// toNumber reads a number from a rule value or an extracted value such as "$1,200.50"
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		cleaned := strings.NewReplacer("$", "", "€", "", "£", "", ",", "", " ", "").Replace(strings.TrimSpace(v))
+		n, err := strconv.ParseFloat(cleaned, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// toBool reads a yes/no value
+func toBool(value string) (bool, bool) {
+	switch normalize(value) {
+	case "true", "yes", "y", "1":
+		return true, true
+	case "false", "no", "n", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// describe explains a violation of a rule without a description
+func describe(rule Rule, failed int) string {
+	when := make([]string, len(rule.When))
+	for i, condition := range rule.When {
+		when[i] = condition.String()
+	}
+	if failed < 0 {
+		return fmt.Sprintf("conflicting values: %s", strings.Join(when, " and "))
+	}
+	return fmt.Sprintf("%s requires %s", strings.Join(when, " and "), rule.Require[failed])
+}
+
+// String describes a condition, as in "resolution eq refunded"
+func (c Condition) String() string {
+	if c.Op == OpPresent || c.Op == OpAbsent {
+		return c.Field + " " + c.Op
+	}
+	return fmt.Sprintf("%s %s %v", c.Field, c.Op, c.Value)
+}
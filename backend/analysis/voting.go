@@ -0,0 +1,223 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// VoteResults merges repeated samples of the same analysis. Values are decided by
+// majority vote (numbers by median), and array items are kept when a majority of
+// samples contain them. It returns the merged result and the agreement between
+// samples, from 0 (no two samples agree) to 1 (all samples are identical).
+func VoteResults(samples []interface{}) (interface{}, float64) {
+	switch len(samples) {
+	case 0:
+		return nil, 0
+	case 1:
+		return samples[0], 1
+	}
+	return voteValues(samples)
+}
+
+// voteValues merges values found at the same position in each sample
+func voteValues(values []interface{}) (interface{}, float64) {
+	switch values[0].(type) {
+	case map[string]interface{}:
+		if objects, ok := allObjects(values); ok {
+			return voteObjects(objects)
+		}
+	case []interface{}:
+		if arrays, ok := allArrays(values); ok {
+			return voteArrays(arrays)
+		}
+	}
+
+	if numbers, ok := allNumbers(values); ok {
+		return voteNumbers(numbers)
+	}
+	return voteScalars(values)
+}
+
+// voteObjects keeps fields present in a majority of samples and votes on each of them
+func voteObjects(objects []map[string]interface{}) (interface{}, float64) {
+	counts := make(map[string]int)
+	for _, object := range objects {
+		for field := range object {
+			counts[field]++
+		}
+	}
+	if len(counts) == 0 {
+		return map[string]interface{}{}, 1
+	}
+
+	merged := make(map[string]interface{})
+	var agreement float64
+	for field, count := range counts {
+		presence := float64(count) / float64(len(objects))
+		if !isMajority(count, len(objects)) {
+			agreement += 1 - presence
+			continue
+		}
+
+		values := make([]interface{}, 0, count)
+		for _, object := range objects {
+			if value, ok := object[field]; ok {
+				values = append(values, value)
+			}
+		}
+		value, fieldAgreement := voteValues(values)
+		merged[field] = value
+		agreement += presence * fieldAgreement
+	}
+	return merged, agreement / float64(len(counts))
+}
+
+// voteArrays keeps the items found in a majority of samples, in the order they first appear
+func voteArrays(arrays [][]interface{}) (interface{}, float64) {
+	type candidate struct {
+		occurrences []interface{}
+		samples     map[int]bool
+	}
+
+	var order []string
+	candidates := make(map[string]*candidate)
+	for i, array := range arrays {
+		for _, item := range array {
+			key := voteKey(item)
+			c, ok := candidates[key]
+			if !ok {
+				c = &candidate{samples: make(map[int]bool)}
+				candidates[key] = c
+				order = append(order, key)
+			}
+			c.occurrences = append(c.occurrences, item)
+			c.samples[i] = true
+		}
+	}
+	if len(order) == 0 {
+		return []interface{}{}, 1
+	}
+
+	merged := []interface{}{}
+	var agreement float64
+	for _, key := range order {
+		c := candidates[key]
+		support := float64(len(c.samples)) / float64(len(arrays))
+		if !isMajority(len(c.samples), len(arrays)) {
+			agreement += 1 - support
+			continue
+		}
+
+		value, itemAgreement := voteValues(c.occurrences)
+		merged = append(merged, value)
+		agreement += support * itemAgreement
+	}
+	return merged, agreement / float64(len(order))
+}
+
+// voteNumbers takes the median, with agreement falling as the samples spread out
+func voteNumbers(numbers []float64) (interface{}, float64) {
+	sorted := append([]float64(nil), numbers...)
+	sort.Float64s(sorted)
+
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	spread := sorted[len(sorted)-1] - sorted[0]
+	scale := math.Max(math.Abs(sorted[0]), math.Abs(sorted[len(sorted)-1]))
+	if spread == 0 || scale == 0 {
+		return median, 1
+	}
+	return median, 1 - math.Min(1, spread/scale)
+}
+
+// voteScalars takes the most common value, comparing strings case-insensitively
+func voteScalars(values []interface{}) (interface{}, float64) {
+	counts := make(map[string]int)
+	first := make(map[string]interface{})
+	var order []string
+	for _, value := range values {
+		key := voteKey(value)
+		if _, ok := first[key]; !ok {
+			first[key] = value
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+
+	winner := order[0]
+	for _, key := range order[1:] {
+		if counts[key] > counts[winner] {
+			winner = key
+		}
+	}
+	return first[winner], float64(counts[winner]) / float64(len(values))
+}
+
+// voteKey identifies equivalent values across samples. Objects are matched on a
+// common identifying field when they have one, otherwise on their full content.
+func voteKey(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "s:" + strings.ToLower(strings.TrimSpace(v))
+	case map[string]interface{}:
+		if key := itemKey(v, ""); key != "" {
+			return "k:" + key
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return "j:" + string(encoded)
+}
+
+// isMajority reports whether count is more than half of total
+func isMajority(count, total int) bool {
+	return count*2 > total
+}
+
+// allObjects returns the values as objects if every value is one
+func allObjects(values []interface{}) ([]map[string]interface{}, bool) {
+	objects := make([]map[string]interface{}, len(values))
+	for i, value := range values {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		objects[i] = object
+	}
+	return objects, true
+}
+
+// allArrays returns the values as arrays if every value is one
+func allArrays(values []interface{}) ([][]interface{}, bool) {
+	arrays := make([][]interface{}, len(values))
+	for i, value := range values {
+		array, ok := value.([]interface{})
+		if !ok {
+			return nil, false
+		}
+		arrays[i] = array
+	}
+	return arrays, true
+}
+
+// allNumbers returns the values as numbers if every value is one
+func allNumbers(values []interface{}) ([]float64, bool) {
+	numbers := make([]float64, len(values))
+	for i, value := range values {
+		number, ok := numberValue(value)
+		if !ok {
+			return nil, false
+		}
+		numbers[i] = number
+	}
+	return numbers, true
+}
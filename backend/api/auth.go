@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// authPublicRoutes are open without a key, so clients can learn that one is required
+var authPublicRoutes = map[string]bool{
+	"/api/auth": true,
+	"/api/demo": true,
+}
+
+// widgetDataRoute is the data endpoint of shared widgets, which checks widget tokens
+// itself instead of API keys so embedding pages never hold a key
+var widgetDataRoute = regexp.MustCompile(`^/api/widgets/[^/]+/data$`)
+
+// bootstrapPrincipal authenticates requests made with ADMIN_API_KEY
+var bootstrapPrincipal = &auth.Principal{KeyID: auth.BootstrapKeyID, Name: "admin", Role: auth.RoleAdmin, TenantID: auth.DefaultTenant}
+
+// authMiddleware requires an API key whose role covers the scope of each request and
+// puts the authenticated principal in the request context
+func authMiddleware(next http.Handler) http.Handler {
+	adminKey := strings.TrimSpace(os.Getenv(auth.EnvAdminAPIKey))
+	if adminKey == "" {
+		log.Printf("Warning: %s is not set; only stored API keys are accepted", auth.EnvAdminAPIKey)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && widgetDataRoute.MatchString(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if authPublicRoutes[r.URL.Path] {
+			if principal := authenticate(r, adminKey); principal != nil {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal := authenticate(r, adminKey)
+		if principal == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="agenticflows"`)
+			writeJSONError(w, http.StatusUnauthorized, "A valid API key is required")
+			return
+		}
+
+		required := auth.RequiredRole(r.Method, r.URL.Path)
+		if !auth.Allows(principal.Role, required) {
+			writeJSONError(w, http.StatusForbidden, fmt.Sprintf("This endpoint requires the %s role", required))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// authenticate returns the principal of the API key of a request, or nil when it has no
+// valid key. GET requests may pass the key as the api_key query parameter, for download
+// links and event streams that cannot set headers.
+func authenticate(r *http.Request, adminKey string) *auth.Principal {
+	key := auth.KeyFromHeaders(r.Header.Get("X-API-Key"), r.Header.Get("Authorization"))
+	if key == "" && r.Method == http.MethodGet {
+		key = r.URL.Query().Get("api_key")
+	}
+	return principalForKey(key, adminKey)
+}
+
+// principalForKey returns the principal of an API key, or nil when the key is not valid
+func principalForKey(key, adminKey string) *auth.Principal {
+	if key == "" {
+		return nil
+	}
+
+	if adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+		return bootstrapPrincipal
+	}
+
+	apiKey, err := db.GetAPIKeyByHash(auth.HashKey(key))
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			log.Printf("Error looking up API key: %v", err)
+		}
+		return nil
+	}
+	return &auth.Principal{KeyID: apiKey.ID, Name: apiKey.Name, Role: apiKey.Role, TenantID: apiKey.TenantID}
+}
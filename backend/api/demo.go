@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/demo"
+)
+
+// demoWriteRoutes are the only non-GET endpoints open in demo mode. They run analyses,
+// workflows and node tests against the mock model, and generated workflows are returned
+// without being stored, so stored workflows and components don't change.
+var demoWriteRoutes = []*regexp.Regexp{
+	regexp.MustCompile(`^/api/analysis$`),
+	regexp.MustCompile(`^/api/analysis/chain$`),
+	regexp.MustCompile(`^/api/analysis/explain$`),
+	regexp.MustCompile(`^/api/questions/answer$`),
+	regexp.MustCompile(`^/api/search/(similar|topic)$`),
+	regexp.MustCompile(`^/api/workflows/generate(-dynamic)?$`),
+	regexp.MustCompile(`^/api/workflows/[^/]+/execute$`),
+	regexp.MustCompile(`^/api/workflows/[^/]+/nodes/[^/]+/test$`),
+}
+
+// demoClientIdleTimeout is how long rate limit state is kept for an idle client
+const demoClientIdleTimeout = 10 * time.Minute
+
+// demoClient tracks the request rate of one client
+type demoClient struct {
+	reads    *analysis.RateLimiter
+	writes   *analysis.RateLimiter
+	lastSeen time.Time
+}
+
+// demoLimiter enforces demo mode restrictions and per-client rate limits
+type demoLimiter struct {
+	config  demo.Config
+	mu      sync.Mutex
+	clients map[string]*demoClient
+}
+
+// demoMiddleware restricts the server to read-mostly endpoints and rate limits each client
+func demoMiddleware(config demo.Config, next http.Handler) http.Handler {
+	limiter := &demoLimiter{config: config, clients: make(map[string]*demoClient)}
+	go limiter.evictIdleClients()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write := r.Method != http.MethodGet && r.Method != http.MethodHead
+		if write && !demoWriteAllowed(r.URL.Path) {
+			writeJSONError(w, http.StatusForbidden, "This endpoint is disabled in the public demo")
+			return
+		}
+
+		if !limiter.allow(limiter.clientKey(r), write) {
+			w.Header().Set("Retry-After", "60")
+			writeJSONError(w, http.StatusTooManyRequests, "Demo rate limit exceeded, please try again in a minute")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, demo.MaxRequestBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// demoWriteAllowed reports whether a non-GET request to path is open in demo mode
+func demoWriteAllowed(path string) bool {
+	for _, route := range demoWriteRoutes {
+		if route.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// allow counts a request from a client against its limits
+func (l *demoLimiter) allow(key string, write bool) bool {
+	l.mu.Lock()
+	client, ok := l.clients[key]
+	if !ok {
+		client = &demoClient{
+			reads:  analysis.NewRateLimiter(l.config.RateLimit),
+			writes: analysis.NewRateLimiter(l.config.WriteRateLimit),
+		}
+		l.clients[key] = client
+	}
+	client.lastSeen = time.Now()
+	l.mu.Unlock()
+
+	if write && !client.writes.Allow() {
+		return false
+	}
+	return client.reads.Allow()
+}
+
+// clientKey identifies the client of a request by IP address
+func (l *demoLimiter) clientKey(r *http.Request) string {
+	if l.config.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// evictIdleClients periodically drops the state of clients that stopped sending requests
+func (l *demoLimiter) evictIdleClients() {
+	for range time.Tick(demoClientIdleTimeout) {
+		cutoff := time.Now().Add(-demoClientIdleTimeout)
+		l.mu.Lock()
+		for key, client := range l.clients {
+			if client.lastSeen.Before(cutoff) {
+				delete(l.clients, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// writeJSONError sends a JSON error response
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
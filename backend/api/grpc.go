@@ -0,0 +1,403 @@
+//go:build grpc
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/api/handlers"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/demo"
+	analysisv1 "agenticflows/backend/proto/analysis/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// EnvGRPCAddr is the address the gRPC server listens on
+const EnvGRPCAddr = "GRPC_ADDR"
+
+// defaultGRPCAddr is used when GRPC_ADDR is not set
+const defaultGRPCAddr = ":9090"
+
+// grpcRoutes are the HTTP endpoints whose scopes each RPC shares
+var grpcRoutes = map[string]string{
+	analysisv1.AnalysisService_PerformAnalysis_FullMethodName: "/api/analysis",
+	analysisv1.AnalysisService_StreamAnalysis_FullMethodName:  "/api/analysis",
+	analysisv1.AnalysisService_ChainAnalysis_FullMethodName:   "/api/analysis/chain",
+	analysisv1.AnalysisService_ExecuteWorkflow_FullMethodName: "/api/workflows/{id}/execute",
+}
+
+func init() {
+	serveGRPC = serveGRPCAnalysis
+}
+
+// serveGRPCAnalysis serves the AnalysisService on GRPC_ADDR. It takes the same API keys
+// as the HTTP API, in the x-api-key or authorization metadata.
+func serveGRPCAnalysis(analysisHandler *handlers.AnalysisHandler) {
+	if demo.Enabled() {
+		log.Println("Demo mode enabled: the gRPC server is not started")
+		return
+	}
+	addr := os.Getenv(EnvGRPCAddr)
+	if addr == "" {
+		addr = defaultGRPCAddr
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Error starting gRPC server: %v", err)
+		return
+	}
+
+	authorizer := grpcAuthorizer{enabled: auth.Enabled(), adminKey: strings.TrimSpace(os.Getenv(auth.EnvAdminAPIKey))}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(authorizer.unary),
+		grpc.StreamInterceptor(authorizer.stream),
+	)
+	analysisv1.RegisterAnalysisServiceServer(server, &grpcAnalysisServer{handler: analysisHandler})
+
+	log.Printf("Starting gRPC server on %s", addr)
+	if err := server.Serve(listener); err != nil {
+		log.Printf("gRPC server stopped: %v", err)
+	}
+}
+
+// grpcAuthorizer requires API keys on RPCs when authentication is enabled, with the role
+// the matching HTTP endpoint requires
+type grpcAuthorizer struct {
+	enabled  bool
+	adminKey string
+}
+
+// authorize returns a context with the principal of the API key in the metadata of ctx
+func (a grpcAuthorizer) authorize(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := auth.KeyFromHeaders(firstMetadata(md, "x-api-key"), firstMetadata(md, "authorization"))
+	principal := principalForKey(key, a.adminKey)
+	if principal != nil {
+		ctx = auth.WithPrincipal(ctx, principal)
+	}
+	if !a.enabled {
+		return ctx, nil
+	}
+
+	if principal == nil {
+		return nil, status.Error(codes.Unauthenticated, "A valid API key is required")
+	}
+	required := auth.RequiredRole(http.MethodPost, grpcRoutes[method])
+	if !auth.Allows(principal.Role, required) {
+		return nil, status.Errorf(codes.PermissionDenied, "This method requires the %s role", required)
+	}
+	return ctx, nil
+}
+
+func (a grpcAuthorizer) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a grpcAuthorizer) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, authorizedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorizedStream is a server stream whose context carries the principal
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcAnalysisServer implements the AnalysisService with the handlers of the HTTP API
+type grpcAnalysisServer struct {
+	analysisv1.UnimplementedAnalysisServiceServer
+	handler *handlers.AnalysisHandler
+}
+
+// PerformAnalysis runs one analysis
+func (s *grpcAnalysisServer) PerformAnalysis(ctx context.Context, req *analysisv1.AnalysisRequest) (*analysisv1.AnalysisResponse, error) {
+	if s.handler == nil {
+		return nil, status.Error(codes.Unavailable, "Analysis is not available")
+	}
+	resp, err := s.handler.PerformAnalysis(ctx, grpcActor(ctx), analysisRequest(req), nil)
+	if err != nil {
+		return nil, analysisStatus(ctx, err)
+	}
+	return analysisResponse(ctx, resp)
+}
+
+// StreamAnalysis runs one analysis and streams its partial output before the response
+func (s *grpcAnalysisServer) StreamAnalysis(req *analysisv1.AnalysisRequest, stream analysisv1.AnalysisService_StreamAnalysisServer) error {
+	if s.handler == nil {
+		return status.Error(codes.Unavailable, "Analysis is not available")
+	}
+	ctx := stream.Context()
+
+	// Concurrent LLM calls produce chunks at once, and a stream allows one sender
+	var mu sync.Mutex
+	index := 0
+	send := func(event *analysisv1.AnalysisEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return stream.Send(event)
+	}
+	chunk := func(text string) {
+		mu.Lock()
+		i := index
+		index++
+		mu.Unlock()
+		event := &analysisv1.AnalysisEvent{Event: &analysisv1.AnalysisEvent_Chunk{
+			Chunk: &analysisv1.AnalysisChunk{Index: int32(i), Text: text},
+		}}
+		if err := send(event); err != nil {
+			log.Printf("Error sending chunk event: %v", err)
+		}
+	}
+
+	resp, err := s.handler.PerformAnalysis(ctx, grpcActor(ctx), analysisRequest(req), chunk)
+	if err != nil {
+		return analysisStatus(ctx, err)
+	}
+	result, err := analysisResponse(ctx, resp)
+	if err != nil {
+		return err
+	}
+	return send(&analysisv1.AnalysisEvent{Event: &analysisv1.AnalysisEvent_Result{Result: result}})
+}
+
+// ChainAnalysis runs a chain of analyses
+func (s *grpcAnalysisServer) ChainAnalysis(ctx context.Context, req *analysisv1.ChainRequest) (*analysisv1.ChainResponse, error) {
+	if s.handler == nil {
+		return nil, status.Error(codes.Unavailable, "Analysis is not available")
+	}
+	results, err := s.handler.ChainAnalysis(ctx, grpcActor(ctx), handlers.ChainRequest{
+		WorkflowID:            req.GetWorkflowId(),
+		Steps:                 req.GetSteps(),
+		Text:                  req.GetText(),
+		Parameters:            structMap(req.GetParameters()),
+		ConfidencePropagation: req.GetConfidencePropagation(),
+		Tags:                  req.GetTags(),
+		MaxBudget:             req.GetMaxBudget(),
+	})
+	var overBudget *core.BudgetExceededError
+	if errors.As(err, &overBudget) {
+		return nil, status.Errorf(codes.ResourceExhausted, "Chain analysis aborted: %v", err)
+	}
+	if err != nil {
+		return nil, analysisStatus(ctx, err)
+	}
+
+	encoded, err := toStruct(results)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to encode results: %v", err)
+	}
+	return &analysisv1.ChainResponse{
+		WorkflowId: req.GetWorkflowId(),
+		Timestamp:  timestamppb.Now(),
+		Results:    encoded,
+	}, nil
+}
+
+// ExecuteWorkflow runs a stored workflow
+func (s *grpcAnalysisServer) ExecuteWorkflow(ctx context.Context, req *analysisv1.ExecuteWorkflowRequest) (*analysisv1.ExecuteWorkflowResponse, error) {
+	// Function nodes run their analyses with the handler of the server
+	ctx = context.WithValue(ctx, "analysisHandler", s.handler)
+	resp, err := handlers.ExecuteWorkflow(ctx, grpcActor(ctx), req.GetWorkflowId(), handlers.WorkflowRunRequest{
+		Parameters: structMap(req.GetParameters()),
+		Data:       structMap(req.GetData()),
+		Text:       req.GetText(),
+		Tags:       req.GetTags(),
+	})
+	var busy *handlers.WorkflowBusyError
+	switch {
+	case errors.Is(err, handlers.ErrWorkflowNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.As(err, &busy) && busy.Skipped:
+		return nil, status.Error(codes.Aborted, err.Error())
+	case errors.As(err, &busy):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, analysisStatus(ctx, err)
+	}
+
+	results, err := toStruct(resp.Results)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to encode results: %v", err)
+	}
+	return &analysisv1.ExecuteWorkflowResponse{
+		WorkflowId:   resp.WorkflowID,
+		WorkflowName: resp.WorkflowName,
+		Timestamp:    timestamppb.New(resp.Timestamp),
+		Results:      results,
+	}, nil
+}
+
+// grpcStatusCodes are the gRPC codes of the error codes of the HTTP API
+var grpcStatusCodes = map[string]codes.Code{
+	"invalid_request":       codes.InvalidArgument,
+	"invalid_analysis_type": codes.InvalidArgument,
+	"data_too_large":        codes.InvalidArgument,
+	"invalid_model_output":  codes.Internal,
+	"provider_timeout":      codes.DeadlineExceeded,
+	"provider_unavailable":  codes.Unavailable,
+	"budget_exceeded":       codes.ResourceExhausted,
+	"canceled":              codes.Canceled,
+	"analysis_error":        codes.Internal,
+}
+
+// analysisStatus converts an analysis failure to a status with the gRPC code of its
+// error code. The error code of the HTTP API is sent as the error-code trailer.
+func analysisStatus(ctx context.Context, err error) error {
+	code, _ := handlers.AnalysisFailure(err)
+	return errorCodeStatus(ctx, code, err.Error())
+}
+
+// errorCodeStatus returns the status of an error code of the HTTP API
+func errorCodeStatus(ctx context.Context, code, message string) error {
+	grpc.SetTrailer(ctx, metadata.Pairs("error-code", code))
+	c, ok := grpcStatusCodes[code]
+	if !ok {
+		c = codes.Internal
+	}
+	return status.Error(c, message)
+}
+
+// analysisRequest converts an AnalysisRequest to the request of the HTTP API
+func analysisRequest(req *analysisv1.AnalysisRequest) models.StandardAnalysisRequest {
+	converted := models.StandardAnalysisRequest{
+		AnalysisType:    req.GetAnalysisType(),
+		WorkflowID:      req.GetWorkflowId(),
+		Text:            req.GetText(),
+		ConversationIDs: req.GetConversationIds(),
+		Parameters:      structMap(req.GetParameters()),
+		Data:            structMap(req.GetData()),
+		Tags:            req.GetTags(),
+	}
+	if req.Cache != nil {
+		cache := req.GetCache()
+		converted.Cache = &cache
+	}
+	for _, source := range req.GetSources() {
+		converted.Sources = append(converted.Sources, models.SourceRef{Type: source.GetType(), ID: source.GetId()})
+	}
+	return converted
+}
+
+// analysisResponse converts a response of the HTTP API to an AnalysisResponse. Responses
+// that carry an error are returned as the status of the error.
+func analysisResponse(ctx context.Context, resp *models.StandardAnalysisResponse) (*analysisv1.AnalysisResponse, error) {
+	if resp.Error != nil {
+		return nil, errorCodeStatus(ctx, resp.Error.Code, resp.Error.Message)
+	}
+	results, err := toValue(resp.Results)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to encode results: %v", err)
+	}
+
+	converted := &analysisv1.AnalysisResponse{
+		AnalysisType: resp.AnalysisType,
+		WorkflowId:   resp.WorkflowID,
+		ResultId:     resp.ResultID,
+		Timestamp:    timestamppb.New(resp.Timestamp),
+		Results:      results,
+		Confidence:   resp.Confidence,
+		DataQuality: &analysisv1.DataQuality{
+			Assessment:  resp.DataQuality.Assessment,
+			Limitations: resp.DataQuality.Limitations,
+		},
+		Cached:           resp.Cached,
+		SuppressedGroups: int32(resp.SuppressedGroups),
+	}
+	if r := resp.Reliability; r != nil {
+		converted.Reliability = &analysisv1.SampleAgreement{
+			Samples:          int32(r.Samples),
+			Succeeded:        int32(r.Succeeded),
+			Agreement:        r.Agreement,
+			SampleConfidence: r.SampleConfidence,
+		}
+	}
+	return converted, nil
+}
+
+// grpcActor identifies who made an RPC: the name of its API key, else the x-actor
+// metadata, defaulting to "system"
+func grpcActor(ctx context.Context) string {
+	if principal := auth.FromContext(ctx); principal != nil {
+		return principal.Name
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if actor := strings.TrimSpace(firstMetadata(md, "x-actor")); actor != "" {
+		return actor
+	}
+	return "system"
+}
+
+// firstMetadata returns the first value of a metadata key, or ""
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// structMap returns the fields of a Struct, or nil for a missing one
+func structMap(s *structpb.Struct) map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	return s.AsMap()
+}
+
+// toValue converts results to a Value through their JSON encoding, since typed results
+// are structs rather than the maps structpb accepts
+func toValue(v interface{}) (*structpb.Value, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(decoded)
+}
+
+// toStruct converts a results map to a Struct
+func toStruct(m map[string]interface{}) (*structpb.Struct, error) {
+	if m == nil {
+		return &structpb.Struct{}, nil
+	}
+	value, err := toValue(m)
+	if err != nil {
+		return nil, err
+	}
+	s := value.GetStructValue()
+	if s == nil {
+		return nil, fmt.Errorf("results are not an object")
+	}
+	return s, nil
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// clientActivityTypes lists the activity types that clients may record directly
+var clientActivityTypes = map[string]bool{
+	db.ActivityResultAnnotated:        true,
+	db.ActivityRecommendationAccepted: true,
+}
+
+// HandleActivity handles the /api/activity endpoint
+func HandleActivity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		filter := db.ActivityFilter{
+			TenantID:   auth.TenantID(r.Context()),
+			WorkflowID: query.Get("workflow_id"),
+			Actor:      query.Get("actor"),
+		}
+
+		if types := query.Get("type"); types != "" {
+			filter.Types = strings.Split(types, ",")
+		}
+
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 0 {
+				http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			filter.Limit = n
+		}
+
+		if since := query.Get("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				http.Error(w, "since must be an RFC3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			filter.Since = t
+		}
+
+		activities, err := db.GetActivity(filter)
+		if err != nil {
+			log.Printf("Error getting activity: %v", err)
+			http.Error(w, "Failed to get activity", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(activities)
+
+	case http.MethodPost:
+		// Record a client-side event such as an annotation or an accepted recommendation
+		var req struct {
+			Type       string          `json:"type"`
+			WorkflowID string          `json:"workflow_id"`
+			Actor      string          `json:"actor"`
+			Summary    string          `json:"summary"`
+			Details    json.RawMessage `json:"details"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		if !clientActivityTypes[req.Type] {
+			http.Error(w, fmt.Sprintf("Unsupported activity type: %s", req.Type), http.StatusBadRequest)
+			return
+		}
+		if req.Summary == "" {
+			http.Error(w, "summary is required", http.StatusBadRequest)
+			return
+		}
+
+		actor := req.Actor
+		if actor == "" {
+			actor = actorFromRequest(r)
+		}
+
+		activity := db.Activity{
+			ID:         uuid.New().String(),
+			TenantID:   auth.TenantID(r.Context()),
+			Type:       req.Type,
+			WorkflowID: req.WorkflowID,
+			Actor:      actor,
+			Summary:    req.Summary,
+			Details:    req.Details,
+			CreatedAt:  time.Now(),
+		}
+		if err := db.RecordActivity(activity); err != nil {
+			log.Printf("Error recording activity: %v", err)
+			http.Error(w, "Failed to record activity", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(activity)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// recordActivity records a server-side event in the activity feed.
+// Failures are logged rather than returned so they never break the request being served.
+func recordActivity(r *http.Request, activityType, workflowID, summary string, details interface{}) {
+	recordActivityAs(auth.TenantID(r.Context()), actorFromRequest(r), activityType, workflowID, summary, details)
+}
+
+// recordActivityAs adds an event to the activity feed of a tenant on behalf of actor, for
+// work done outside a request. Server-wide events pass db.AllTenants and are recorded for
+// the default tenant.
+func recordActivityAs(tenantID, actor, activityType, workflowID, summary string, details interface{}) {
+	activity := db.Activity{
+		ID:         uuid.New().String(),
+		TenantID:   tenantID,
+		Type:       activityType,
+		WorkflowID: workflowID,
+		Actor:      actor,
+		Summary:    summary,
+		CreatedAt:  time.Now(),
+	}
+
+	if details != nil {
+		detailsJSON, err := json.Marshal(details)
+		if err != nil {
+			log.Printf("Error marshaling activity details: %v", err)
+		} else {
+			activity.Details = detailsJSON
+		}
+	}
+
+	if err := db.RecordActivity(activity); err != nil {
+		log.Printf("Error recording %s activity: %v", activityType, err)
+	}
+}
+
+// actorFromRequest identifies who made a request: the name of its API key, else the
+// X-Actor header, defaulting to "system"
+func actorFromRequest(r *http.Request) string {
+	if r != nil {
+		if principal := auth.FromContext(r.Context()); principal != nil {
+			return principal.Name
+		}
+		if actor := strings.TrimSpace(r.Header.Get("X-Actor")); actor != "" {
+			return actor
+		}
+	}
+	return "system"
+}
@@ -2,41 +2,221 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
+	"agenticflows/backend/analysis/core"
 	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/processors"
 )
 
-// handleRecommendationsAnalysis handles recommendations analysis requests
+// handleRecommendationsAnalysis handles recommendations analysis requests: it generates
+// recommendations for the objectives parameter from the data of earlier analyses, such
+// as trends or findings, and ranks them with the scoring model of the scoring parameter.
+// They are written for the audience, tone and max_length parameters.
 func (h *AnalysisHandler) handleRecommendationsAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
-	// This is a temporary implementation until recommendations analysis is fully refactored
+	scoring, err := recommendationScoring(req.Parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	ctx, err = withAudience(ctx, req.Parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	analysisResults := req.Data
+	if len(analysisResults) == 0 && req.Text != "" {
+		analysisResults = map[string]interface{}{"text": req.Text}
+	}
+	if len(analysisResults) == 0 {
+		return nil, invalidRequest(fmt.Errorf("data or text is required for recommendations analysis"))
+	}
+
+	focusArea := strings.Join(stringList(req.Parameters["objectives"]), "; ")
+	if focusArea == "" {
+		focusArea = "the most important issues in the analysis"
+	}
+
+	result, err := h.analysisFacade.GenerateRecommendations(ctx, analysisResults, focusArea)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recommendations: %w", err)
+	}
+	result.ImmediateActions = processors.ScoreRecommendations(result.ImmediateActions, scoring)
+	result.Scoring = &models.RecommendationScoring{
+		Weights:       processors.RecommendationWeights(scoring),
+		Budget:        scoring.Budget,
+		PriorityScale: scoring.PriorityScale,
+	}
+
 	return &models.StandardAnalysisResponse{
 		AnalysisType: "recommendations",
 		WorkflowID:   req.WorkflowID,
 		Timestamp:    time.Now(),
-		Results:      map[string]interface{}{"message": "Recommendations analysis has not yet been refactored"},
-		Confidence:   0.0,
-		Error: &models.AnalysisError{
-			Code:    "not_implemented",
-			Message: "Recommendations analysis has not yet been refactored",
-			Details: "Please check back later when the refactoring is complete",
-		},
+		Results:      result,
+		Confidence:   0.8, // The model reports no confidence for recommendations
 	}, nil
 }
 
-// handlePlanAnalysis handles action plan generation requests
+// recommendationScoring reads the scoring parameter of a recommendations analysis: the
+// weights of the scoring criteria and the budget
+func recommendationScoring(parameters map[string]interface{}) (models.RecommendationScoring, error) {
+	scoring := models.RecommendationScoring{}
+	if value, ok := parameters["scoring"]; ok && value != nil {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return scoring, err
+		}
+		if err := json.Unmarshal(encoded, &scoring); err != nil {
+			return scoring, fmt.Errorf("invalid scoring: %w", err)
+		}
+	}
+	// Generated recommendations are prioritized from 1 to 5
+	scoring.PriorityScale = 5
+	if err := processors.ValidateRecommendationScoring(scoring); err != nil {
+		return scoring, fmt.Errorf("invalid scoring: %w", err)
+	}
+	return scoring, nil
+}
+
+// handlePlanAnalysis handles action plan generation requests: it creates an action plan
+// from the recommendations in the data, or, with the generate_timeline parameter, generates
+// a timeline for the action plan in the data. Either way the timeline is scheduled on the
+// working calendar of the calendar parameter, with concrete dates for its phases and
+// milestones and the periods in which resources are overallocated. Like recommendations,
+// plans are written for the audience, tone and max_length parameters.
 func (h *AnalysisHandler) handlePlanAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
-	// This is a temporary implementation until plan analysis is fully refactored
+	calendar, err := workingCalendar(req)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	ctx, err = withAudience(ctx, req.Parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	plan := &models.ActionPlan{}
+	if generate, _ := req.Parameters["generate_timeline"].(bool); generate {
+		value, ok := req.Data["action_plan"]
+		if !ok || value == nil {
+			return nil, invalidRequest(fmt.Errorf("data.action_plan is required to generate a timeline"))
+		}
+		if err := decodeValue(value, plan); err != nil {
+			return nil, invalidRequest(fmt.Errorf("invalid action_plan: %w", err))
+		}
+		resources, _ := req.Data["resources"].(map[string]interface{})
+		plan.Timeline, plan.Schedule, err = h.analysisFacade.GenerateTimeline(ctx, plan, resources, &calendar)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate timeline: %w", err)
+		}
+	} else {
+		recommendations, err := planRecommendations(req.Data)
+		if err != nil {
+			return nil, invalidRequest(err)
+		}
+		constraints := map[string]interface{}{}
+		if value, ok := req.Parameters["constraints"].(map[string]interface{}); ok {
+			for key, constraint := range value {
+				constraints[key] = constraint
+			}
+		}
+		if goals := stringList(req.Parameters["goals"]); len(goals) > 0 {
+			constraints["goals"] = goals
+		}
+
+		plan, err = h.analysisFacade.CreateActionPlan(ctx, recommendations, constraints)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create action plan: %w", err)
+		}
+		if plan.Timeline, plan.Schedule, err = processors.ScheduleTimeline(plan.Timeline, calendar); err != nil {
+			return nil, invalidRequest(err)
+		}
+	}
+
 	return &models.StandardAnalysisResponse{
 		AnalysisType: "plan",
 		WorkflowID:   req.WorkflowID,
 		Timestamp:    time.Now(),
-		Results:      map[string]interface{}{"message": "Plan analysis has not yet been refactored"},
-		Confidence:   0.0,
-		Error: &models.AnalysisError{
-			Code:    "not_implemented",
-			Message: "Plan analysis has not yet been refactored",
-			Details: "Please check back later when the refactoring is complete",
-		},
+		Results:      plan,
+		Confidence:   0.8, // The model reports no confidence for plans
 	}, nil
 }
+
+// withAudience adds the audience, tone and max_length parameters of a recommendations or
+// plan analysis to the output style of its context, so the same findings can be written
+// for executives or for the teams that act on them
+func withAudience(ctx context.Context, parameters map[string]interface{}) (context.Context, error) {
+	style := core.OutputStyleFromContext(ctx)
+	if value, ok := parameters["audience"]; ok {
+		audience, ok := value.(string)
+		if !ok {
+			return ctx, fmt.Errorf("audience must be a string")
+		}
+		style.Audience = strings.ToLower(strings.TrimSpace(audience))
+	}
+	if value, ok := parameters["tone"]; ok {
+		tone, ok := value.(string)
+		if !ok {
+			return ctx, fmt.Errorf("tone must be a string")
+		}
+		style.Tone = strings.TrimSpace(tone)
+	}
+	if value, ok := parameters["max_length"]; ok {
+		length, isNumber := value.(float64)
+		if !isNumber || length != float64(int(length)) || length < 1 || length > core.MaxOutputLength {
+			return ctx, fmt.Errorf("max_length must be an integer from 1 to %d", core.MaxOutputLength)
+		}
+		style.MaxLength = int(length)
+	}
+	if err := core.ValidateOutputStyle(style); err != nil {
+		return ctx, err
+	}
+	return core.WithOutputStyle(ctx, style), nil
+}
+
+// planRecommendations reads the recommendations a plan is created from: the immediate
+// actions of a recommendations analysis, or a list of recommendations
+func planRecommendations(data map[string]interface{}) (*models.RecommendationResponse, error) {
+	recommendations := &models.RecommendationResponse{}
+	if err := decodeValue(data, recommendations); err != nil {
+		return nil, fmt.Errorf("invalid recommendations: %w", err)
+	}
+	if value, ok := data["recommendations"]; ok && len(recommendations.ImmediateActions) == 0 {
+		if err := decodeValue(value, &recommendations.ImmediateActions); err != nil {
+			return nil, fmt.Errorf("invalid recommendations: %w", err)
+		}
+	}
+	if len(recommendations.ImmediateActions) == 0 {
+		return nil, fmt.Errorf("data.recommendations or data.immediate_actions is required for plan analysis")
+	}
+	return recommendations, nil
+}
+
+// workingCalendar reads the calendar parameter of a plan analysis. Without a start date
+// the calendar starts on the start_date of the resources in the data, if any.
+func workingCalendar(req models.StandardAnalysisRequest) (models.WorkingCalendar, error) {
+	calendar := models.WorkingCalendar{}
+	if value, ok := req.Parameters["calendar"]; ok && value != nil {
+		if err := decodeValue(value, &calendar); err != nil {
+			return calendar, fmt.Errorf("invalid calendar: %w", err)
+		}
+	}
+	if calendar.StartDate == "" {
+		resources, _ := req.Data["resources"].(map[string]interface{})
+		calendar.StartDate, _ = resources["start_date"].(string)
+	}
+	if err := processors.ValidateWorkingCalendar(calendar); err != nil {
+		return calendar, fmt.Errorf("invalid calendar: %w", err)
+	}
+	return calendar, nil
+}
+
+// decodeValue decodes a JSON value of a request into target
+func decodeValue(value interface{}, target interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, target)
+}
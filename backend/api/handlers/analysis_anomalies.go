@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/statistics"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/demo"
+	"agenticflows/backend/pii"
+)
+
+// Anomaly detection limits
+const (
+	maxAnomalySeries        = 50
+	maxAnomalySeriesPoints  = 10000
+	defaultAnomalyExplained = 5
+	maxAnomalyExplained     = 20
+	maxAnomalyConversations = 10 // Conversations of its time bucket an explanation quotes from
+	defaultAnomalyExcerpts  = 3
+)
+
+// handleAnomaliesAnalysis flags the spikes and drops of the time series in data.series,
+// such as daily dispute counts or sentiment averages, by z-score or EWMA. No model is
+// called unless the explain parameter is true: then the most significant anomalies, up
+// to max_explanations, are explained from the conversations of their time bucket.
+func (h *AnalysisHandler) handleAnomaliesAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+	options, err := anomalyOptions(req.Parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	var series []analysis.AnomalySeries
+	if err := decodeValue(req.Data["series"], &series); err != nil || len(series) == 0 {
+		return nil, invalidRequest(fmt.Errorf("data.series must be a list of series, each with a name and points of a time and value"))
+	}
+	if len(series) > maxAnomalySeries {
+		return nil, fmt.Errorf("%w: at most %d series are allowed", analysis.ErrDataTooLarge, maxAnomalySeries)
+	}
+	for _, s := range series {
+		if len(s.Points) > maxAnomalySeriesPoints {
+			return nil, fmt.Errorf("%w: at most %d points are allowed per series", analysis.ErrDataTooLarge, maxAnomalySeriesPoints)
+		}
+	}
+
+	explain, _ := req.Parameters["explain"].(bool)
+	explained := defaultAnomalyExplained
+	if value, ok := req.Parameters["max_explanations"]; ok {
+		n, isNumber := value.(float64)
+		if !isNumber || n != float64(int(n)) || n < 1 || n > maxAnomalyExplained {
+			return nil, invalidRequest(fmt.Errorf("max_explanations must be an integer from 1 to %d", maxAnomalyExplained))
+		}
+		explained = int(n)
+	}
+
+	result, err := analysis.DetectAnomalies(series, options)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	resp := &models.StandardAnalysisResponse{
+		AnalysisType: "anomalies",
+		WorkflowID:   req.WorkflowID,
+		Timestamp:    time.Now(),
+		Results:      result,
+		Confidence:   1,
+	}
+	if explain {
+		resp.PIIRedaction, resp.Errors, err = h.explainAnomalies(ctx, req.Parameters, result, explained)
+		if err != nil {
+			return nil, err
+		}
+		resp.Partial = len(resp.Errors) > 0
+	}
+	return resp, nil
+}
+
+// anomalyOptions reads the method, threshold, window, alpha and direction parameters of an
+// anomalies analysis
+func anomalyOptions(parameters map[string]interface{}) (statistics.AnomalyOptions, error) {
+	var options statistics.AnomalyOptions
+	if value, ok := parameters["method"]; ok {
+		if options.Method, ok = value.(string); !ok {
+			return options, fmt.Errorf("method must be a string")
+		}
+	}
+	if value, ok := parameters["direction"]; ok {
+		if options.Direction, ok = value.(string); !ok {
+			return options, fmt.Errorf("direction must be a string")
+		}
+	}
+	if value, ok := parameters["threshold"]; ok {
+		threshold, isNumber := value.(float64)
+		if !isNumber || threshold <= 0 {
+			return options, fmt.Errorf("threshold must be a positive number")
+		}
+		options.Threshold = threshold
+	}
+	if value, ok := parameters["window"]; ok {
+		window, isNumber := value.(float64)
+		if !isNumber || window != float64(int(window)) || window < statistics.MinBaselinePoints || window > statistics.MaxAnomalyWindow {
+			return options, fmt.Errorf("window must be an integer from %d to %d", statistics.MinBaselinePoints, statistics.MaxAnomalyWindow)
+		}
+		options.Window = int(window)
+	}
+	if value, ok := parameters["alpha"]; ok {
+		alpha, isNumber := value.(float64)
+		if !isNumber || alpha <= 0 || alpha >= 1 {
+			return options, fmt.Errorf("alpha must be a number between 0 and 1")
+		}
+		options.Alpha = alpha
+	}
+	options = options.WithDefaults()
+	return options, statistics.ValidateAnomalyOptions(options)
+}
+
+// explainAnomalies asks the model to explain up to limit of the most significant anomalies
+// from the conversations of their time buckets, which are redacted when the redact_pii
+// parameter is set. An anomaly that can't be explained is reported as an error of the
+// partial response rather than failing the analysis.
+func (h *AnalysisHandler) explainAnomalies(ctx context.Context, parameters map[string]interface{}, result *analysis.AnomaliesResult, limit int) (*pii.Report, []models.AnalysisError, error) {
+	anomalies := result.Anomalies[:min(limit, len(result.Anomalies))]
+	conversations := make([][]models.ConversationText, len(anomalies))
+	var all []models.ConversationText
+	for i := range anomalies {
+		found, err := anomalyConversations(auth.TenantID(ctx), anomalies[i])
+		if err != nil {
+			log.Printf("Error reading conversations of anomaly in %s at %s: %v", anomalies[i].Series, anomalies[i].Time, err)
+		}
+		conversations[i] = found
+		all = append(all, found...)
+		if len(anomalies[i].ConversationIDs) == 0 {
+			for _, conversation := range found {
+				anomalies[i].ConversationIDs = append(anomalies[i].ConversationIDs, conversation.ConversationID)
+			}
+		}
+	}
+
+	// Conversations are redacted together, then handed back to their anomalies
+	redaction, err := redactConversations(ctx, parameters, all)
+	if err != nil {
+		return nil, nil, err
+	}
+	offset := 0
+	for i := range conversations {
+		conversations[i] = all[offset : offset+len(conversations[i])]
+		offset += len(conversations[i])
+	}
+
+	var mu sync.Mutex
+	var failures []models.AnalysisError
+	forEachConcurrently(len(anomalies), func(i int) error {
+		explanation, err := h.analysisFacade.ExplainAnomaly(ctx, anomalies[i], conversations[i], defaultAnomalyExcerpts)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failures = append(failures, analysis.ItemError(i, anomalies[i].Series+"@"+anomalies[i].Time, analysis.ErrorCode(err), err.Error()))
+			return nil
+		}
+		anomalies[i].Explanation = explanation
+		result.Explained++
+		return nil
+	})
+	return redaction, failures, nil
+}
+
+// anomalyConversations returns the conversations of the time bucket of an anomaly: those
+// its point was aggregated from, or else the stored conversations of the tenant that took
+// place in it, up to maxAnomalyConversations. Conversations without text, such as those in cold
+// storage, are left out.
+func anomalyConversations(tenantID string, anomaly analysis.Anomaly) ([]models.ConversationText, error) {
+	var conversations []models.ConversationText
+	add := func(id, text string) {
+		if text != "" && len(conversations) < maxAnomalyConversations {
+			conversations = append(conversations, models.ConversationText{ConversationID: id, Text: text})
+		}
+	}
+
+	ids := anomaly.ConversationIDs
+	if len(ids) > maxAnomalyConversations {
+		ids = ids[:maxAnomalyConversations]
+	}
+	switch {
+	case demo.Enabled():
+		// The demo only reads the synthetic dataset
+		for _, conversation := range demo.ConversationsByID(ids) {
+			add(conversation.ID, conversation.Text)
+		}
+	case len(ids) > 0:
+		stored, err := db.GetConversations(tenantID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, conversation := range stored {
+			add(conversation.ID, conversation.Text)
+		}
+	default:
+		stored, _, err := db.ListConversations(db.ConversationFilter{TenantID: tenantID, Since: &anomaly.Start, Until: &anomaly.End, Limit: maxAnomalyConversations})
+		if err != nil {
+			return nil, err
+		}
+		for _, conversation := range stored {
+			add(conversation.ID, conversation.Text)
+		}
+	}
+	return conversations, nil
+}
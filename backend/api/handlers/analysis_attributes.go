@@ -2,24 +2,492 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"agenticflows/backend/analysis"
 	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/transcript"
+	"agenticflows/backend/analysis/validation"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/pii"
 )
 
-// handleAttributesAnalysis handles attribute extraction analysis requests
+// Attribute fan-out limits
+const (
+	maxFanOutConversations = 1000
+	maxFanOutConcurrency   = 16
+	attributeTopValues     = 10
+	maxBatchConversations  = 20
+	defaultBatchMaxTokens  = 6000
+	minBatchMaxTokens      = 500
+)
+
+// defaultRevisionReason explains attribute changes from extractions that give no revision_reason
+const defaultRevisionReason = "re-extraction"
+
+// handleAttributesAnalysis handles attribute extraction analysis requests. Values are
+// extracted from text, or from each stored conversation in conversation_ids.
 func (h *AnalysisHandler) handleAttributesAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
-	// This is a temporary implementation until attributes analysis is fully refactored
+	attributes := attributeDefinitions(req.Parameters["attributes"])
+
+	// Without attribute definitions, generate the ones needed to answer the questions
+	generated := false
+	if len(attributes) == 0 {
+		questions := stringList(req.Parameters["questions"])
+		if len(questions) == 0 {
+			return nil, fmt.Errorf("attributes or questions are required for attributes analysis")
+		}
+		var err error
+		attributes, err = h.analysisFacade.GenerateRequiredAttributes(ctx, questions, stringList(req.Parameters["existing_attributes"]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate required attributes: %w", err)
+		}
+		generated = true
+	}
+
+	rules, err := validation.ParseRules(req.Parameters["validation_rules"])
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRuleFields(rules, attributes); err != nil {
+		return nil, err
+	}
+	locale, err := attributeLocale(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *analysis.AttributesResult
+	var redaction *pii.Report
+	var failures []models.AnalysisError
+	if len(req.ConversationIDs) > 0 {
+		result, redaction, failures, err = h.extractConversationAttributes(ctx, req, attributes, rules, locale)
+	} else {
+		if req.Text == "" {
+			return nil, fmt.Errorf("text or conversation_ids is required for attributes analysis")
+		}
+		text, textErr := speakerTurnsFromRequest(req)
+		if textErr != nil {
+			return nil, textErr
+		}
+		var values []models.AttributeValue
+		values, err = h.analysisFacade.GenerateAttributes(ctx, text, attributes)
+		coerceAttributeValues(values, attributes, locale)
+		violations := append(validation.Check(values, rules), validation.CheckEnums(values, attributes)...)
+		result = &analysis.AttributesResult{AttributeValues: values, Violations: violations}
+		if err == nil {
+			err = persistTextAttributes(ctx, req, attributes, result)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if generated {
+		result.Attributes = attributes
+	}
+
 	return &models.StandardAnalysisResponse{
 		AnalysisType: "attributes",
 		WorkflowID:   req.WorkflowID,
 		Timestamp:    time.Now(),
-		Results:      map[string]interface{}{"message": "Attributes analysis has not yet been refactored"},
-		Confidence:   0.0,
-		Error: &models.AnalysisError{
-			Code:    "not_implemented",
-			Message: "Attributes analysis has not yet been refactored",
-			Details: "Please check back later when the refactoring is complete",
-		},
+		Results:      result,
+		Confidence:   averageAttributeConfidence(result),
+		PIIRedaction: redaction,
+		Partial:      len(failures) > 0,
+		Errors:       failures,
 	}, nil
 }
+
+// extractConversationAttributes fans extraction out over stored conversations, saves the
+// values to conversation_attributes and summarizes them per attribute. Conversations whose
+// values break validation rules are flagged for review and left out of the summary. The
+// PII redacted from the conversations is reported when the request asks for redaction,
+// and the conversations values could not be extracted from are returned as item errors.
+func (h *AnalysisHandler) extractConversationAttributes(ctx context.Context, req models.StandardAnalysisRequest, attributes []models.AttributeDefinition, rules []validation.Rule, locale validation.Locale) (*analysis.AttributesResult, *pii.Report, []models.AnalysisError, error) {
+	if len(req.ConversationIDs) > maxFanOutConversations {
+		return nil, nil, nil, fmt.Errorf("%w: at most %d conversation_ids can be analyzed per request; submit larger sets as an analysis job in several requests", analysis.ErrDataTooLarge, maxFanOutConversations)
+	}
+
+	stored, err := db.GetConversations(auth.TenantID(ctx), req.ConversationIDs)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load conversations: %w", err)
+	}
+	if len(stored) < len(req.ConversationIDs) {
+		return nil, nil, nil, fmt.Errorf("%d of %d conversations were not found", len(req.ConversationIDs)-len(stored), len(req.ConversationIDs))
+	}
+	if err := requireConversationText(stored); err != nil {
+		return nil, nil, nil, err
+	}
+	if stored, err = withTranslations(stored, req.Parameters); err != nil {
+		return nil, nil, nil, err
+	}
+
+	role, err := speakerRole(req.Parameters)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	conversations := make([]models.ConversationText, len(stored))
+	for i, conversation := range stored {
+		text, err := speakerTurnsText(conversation.Text, role)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("conversation %s: %w", conversation.ID, err)
+		}
+		conversations[i] = models.ConversationText{ConversationID: conversation.ID, Text: text}
+	}
+	redaction, err := redactConversations(ctx, req.Parameters, conversations)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	concurrency := analysis.DefaultFanOutConcurrency
+	if n, ok := req.Parameters["concurrency"].(float64); ok && n > 0 {
+		concurrency = min(int(n), maxFanOutConcurrency)
+	}
+
+	// Conversations that were expensive outliers before are condensed window by window
+	factor, err := outlierFactor(req.Parameters)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := routeOutliers("attributes", req.Parameters, conversations); err != nil {
+		return nil, nil, nil, err
+	}
+
+	batching, err := attributeBatching(req.Parameters)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	results := h.analysisFacade.ExtractAttributesFromConversations(ctx, conversations, attributes, concurrency, batching)
+	for _, result := range results {
+		coerceAttributeValues(result.AttributeValues, attributes, locale)
+	}
+	processing := recordProcessing(ctx, "attributes", req.WorkflowID, results, factor)
+
+	// Save the extracted values unless persistence is turned off
+	persist, err := persistAttributes(req.Parameters, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var rows []db.ConversationAttribute
+	var failures []models.AnalysisError
+	for i, result := range results {
+		if result.Error != "" {
+			failures = append(failures, analysis.ItemError(i, result.ConversationID, result.ErrorCode, result.Error))
+			continue
+		}
+		rows = append(rows, attributeRows(result.ConversationID, req.WorkflowID, result.AttributeValues, attributes)...)
+	}
+	if len(failures) == len(results) {
+		return nil, nil, nil, &analysis.CodedError{Code: results[0].ErrorCode, Message: "attribute extraction failed for every conversation: " + results[0].Error}
+	}
+	var revisions []db.ConversationAttributeRevision
+	if persist {
+		revisions, err = db.SaveConversationAttributes(rows, revisionReason(req.Parameters))
+		if err != nil {
+			log.Printf("Error saving conversation attributes: %v", err)
+		}
+	}
+
+	consistent, flagged := validateConversationAttributes(results, attributes, rules, req.WorkflowID)
+
+	result := &analysis.AttributesResult{
+		AttributeValues:     []models.AttributeValue{},
+		Statistics:          analysis.SummarizeAttributeValues(consistent, attributeTopValues),
+		FailedConversations: len(failures),
+		Flagged:             flagged,
+		Changes:             attributeChanges(revisions),
+		Processing:          processing,
+	}
+	if err == nil && persist {
+		result.Persisted = len(rows)
+	}
+	if includeValues, ok := req.Parameters["include_values"].(bool); !ok || includeValues {
+		result.Conversations = consistent
+	}
+	return result, redaction, failures, nil
+}
+
+// persistTextAttributes saves the values extracted from text to conversation_attributes
+// when the request sets persist and names the conversation in the conversation_id parameter.
+// The conversation must belong to the tenant.
+func persistTextAttributes(ctx context.Context, req models.StandardAnalysisRequest, attributes []models.AttributeDefinition, result *analysis.AttributesResult) error {
+	persist, err := persistAttributes(req.Parameters, false)
+	if err != nil || !persist {
+		return err
+	}
+	conversationID, _ := req.Parameters["conversation_id"].(string)
+	if conversationID == "" {
+		return fmt.Errorf("conversation_id is required to persist attributes extracted from text")
+	}
+	if _, err := db.GetConversation(auth.TenantID(ctx), conversationID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return invalidRequest(fmt.Errorf("conversation %s not found", conversationID))
+		}
+		return fmt.Errorf("failed to get conversation %s: %w", conversationID, err)
+	}
+
+	rows := attributeRows(conversationID, req.WorkflowID, result.AttributeValues, attributes)
+	revisions, err := db.SaveConversationAttributes(rows, revisionReason(req.Parameters))
+	if err != nil {
+		return fmt.Errorf("failed to save conversation attributes: %w", err)
+	}
+	result.Changes = attributeChanges(revisions)
+	result.Persisted = len(rows)
+	return nil
+}
+
+// persistAttributes reads the persist parameter of an attributes or intent request
+func persistAttributes(parameters map[string]interface{}, defaultValue bool) (bool, error) {
+	param, ok := parameters["persist"]
+	if !ok {
+		return defaultValue, nil
+	}
+	persist, ok := param.(bool)
+	if !ok {
+		return false, fmt.Errorf("persist must be a boolean")
+	}
+	return persist, nil
+}
+
+// attributeBatching reads how many conversations may share an extraction call from the
+// batch_size parameter and the prompt tokens they may take from batch_max_tokens
+func attributeBatching(parameters map[string]interface{}) (analysis.AttributeBatching, error) {
+	batching := analysis.AttributeBatching{MaxTokens: defaultBatchMaxTokens}
+	if param, ok := parameters["batch_size"]; ok {
+		size, isNumber := param.(float64)
+		if !isNumber || size != float64(int(size)) || size < 1 || size > maxBatchConversations {
+			return batching, fmt.Errorf("batch_size must be an integer from 1 to %d", maxBatchConversations)
+		}
+		batching.MaxConversations = int(size)
+	}
+	if param, ok := parameters["batch_max_tokens"]; ok {
+		tokens, isNumber := param.(float64)
+		if !isNumber || tokens != float64(int(tokens)) || tokens < minBatchMaxTokens {
+			return batching, fmt.Errorf("batch_max_tokens must be an integer of at least %d", minBatchMaxTokens)
+		}
+		batching.MaxTokens = int(tokens)
+	}
+	return batching, nil
+}
+
+// revisionReason returns the reason recorded with changed attribute values
+func revisionReason(parameters map[string]interface{}) string {
+	if reason, _ := parameters["revision_reason"].(string); reason != "" {
+		return reason
+	}
+	return defaultRevisionReason
+}
+
+// attributeRows converts the values extracted from a conversation to conversation_attributes
+// rows. Values out of their attribute's enum values or not of its type are left out, so
+// stored values of categorical attributes are always allowed ones and stored numbers and
+// dates always parse.
+func attributeRows(conversationID, workflowID string, values []models.AttributeValue, attributes []models.AttributeDefinition) []db.ConversationAttribute {
+	descriptions := make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		descriptions[attribute.FieldName] = attribute.Description
+	}
+	rows := make([]db.ConversationAttribute, 0, len(values))
+	for _, value := range values {
+		if value.OutOfEnum || value.TypeError != "" {
+			continue
+		}
+		rows = append(rows, db.ConversationAttribute{
+			ConversationID: conversationID,
+			Type:           db.ConversationAttributeTypeAttribute,
+			Name:           value.FieldName,
+			Value:          value.Value,
+			Description:    descriptions[value.FieldName],
+			Confidence:     value.Confidence,
+			Explanation:    value.Explanation,
+			WorkflowID:     workflowID,
+		})
+	}
+	return rows
+}
+
+// attributeChanges reports the revisions of saved attribute values
+func attributeChanges(revisions []db.ConversationAttributeRevision) []models.AttributeChange {
+	var changes []models.AttributeChange
+	for _, revision := range revisions {
+		changes = append(changes, models.AttributeChange{
+			ConversationID:     revision.ConversationID,
+			FieldName:          revision.Name,
+			PreviousValue:      revision.PreviousValue,
+			Value:              revision.Value,
+			PreviousConfidence: revision.PreviousConfidence,
+			Confidence:         revision.Confidence,
+			Reason:             revision.Reason,
+			Explanation:        revision.Explanation,
+		})
+	}
+	return changes
+}
+
+// validateConversationAttributes checks the values extracted from each conversation
+// against the validation rules and the enum values of the attributes, and records a flag
+// for each violation, replacing the open flags of earlier extractions. It returns the
+// conversations that passed, including failed extractions, and the flagged ones.
+func validateConversationAttributes(results []models.ConversationAttributes, attributes []models.AttributeDefinition, rules []validation.Rule, workflowID string) (consistent, flagged []models.ConversationAttributes) {
+	enums := validation.HasEnums(attributes)
+	if len(rules) == 0 && !enums {
+		return results, nil
+	}
+
+	var checked []string
+	var flags []db.AttributeFlag
+	for _, result := range results {
+		if result.Error != "" {
+			consistent = append(consistent, result)
+			continue
+		}
+		checked = append(checked, result.ConversationID)
+		result.Violations = append(validation.Check(result.AttributeValues, rules), validation.CheckEnums(result.AttributeValues, attributes)...)
+		if len(result.Violations) == 0 {
+			consistent = append(consistent, result)
+			continue
+		}
+		flagged = append(flagged, result)
+		for _, violation := range result.Violations {
+			flags = append(flags, db.AttributeFlag{
+				ConversationID: result.ConversationID,
+				Rule:           violation.Rule,
+				Message:        violation.Message,
+				Fields:         violation.Fields,
+				Values:         violation.Values,
+				WorkflowID:     workflowID,
+			})
+		}
+	}
+
+	ruleNames := make([]string, 0, len(rules)+1)
+	for _, rule := range rules {
+		ruleNames = append(ruleNames, rule.Name)
+	}
+	if enums {
+		ruleNames = append(ruleNames, validation.EnumRule)
+	}
+	if err := db.ReplaceAttributeFlags(checked, ruleNames, flags); err != nil {
+		log.Printf("Error saving attribute flags: %v", err)
+	}
+	return consistent, flagged
+}
+
+// validateRuleFields checks that validation rules only refer to defined attributes
+func validateRuleFields(rules []validation.Rule, attributes []models.AttributeDefinition) error {
+	if err := validation.Validate(rules); err != nil {
+		return err
+	}
+	defined := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		defined[attribute.FieldName] = true
+	}
+	for _, field := range validation.Fields(rules) {
+		if !defined[field] {
+			return fmt.Errorf("validation rules refer to %s, which is not an extracted attribute", field)
+		}
+	}
+	return nil
+}
+
+// attributeLocale reads the locale numbers and dates are extracted in from the locale
+// parameter. Requests without one use the language of their conversations, or
+// validation.DefaultLocale.
+func attributeLocale(req models.StandardAnalysisRequest) (validation.Locale, error) {
+	if value, ok := req.Parameters["locale"]; ok {
+		tag, ok := value.(string)
+		if !ok {
+			return validation.Locale{}, fmt.Errorf("locale must be a string")
+		}
+		return validation.ParseLocale(tag)
+	}
+	if req.Language != "" {
+		if locale, err := validation.ParseLocale(req.Language); err == nil {
+			return locale, nil
+		}
+	}
+	return validation.ParseLocale(validation.DefaultLocale)
+}
+
+// coerceAttributeValues reads the extracted values as the declared types of their
+// attributes
+func coerceAttributeValues(values []models.AttributeValue, attributes []models.AttributeDefinition, locale validation.Locale) {
+	byField := make(map[string]models.AttributeDefinition, len(attributes))
+	for _, attribute := range attributes {
+		byField[attribute.FieldName] = attribute
+	}
+	for i := range values {
+		validation.Coerce(byField[values[i].FieldName], &values[i], locale)
+	}
+}
+
+// attributeDefinitions reads attribute definitions from a request parameter
+func attributeDefinitions(param interface{}) []models.AttributeDefinition {
+	list, _ := param.([]interface{})
+	definitions := make([]models.AttributeDefinition, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		definition := models.AttributeDefinition{}
+		definition.FieldName, _ = m["field_name"].(string)
+		definition.Title, _ = m["title"].(string)
+		definition.Description, _ = m["description"].(string)
+		if speaker, _ := m["speaker"].(string); transcript.ValidRole(strings.ToLower(speaker)) {
+			definition.Speaker = strings.ToLower(speaker)
+		}
+		if valueType, _ := m["type"].(string); models.ValidAttributeType(valueType) {
+			definition.Type = valueType
+		}
+		definition.EnumValues = stringList(m["enum_values"])
+		if synonyms, ok := m["enum_synonyms"].(map[string]interface{}); ok {
+			definition.EnumSynonyms = make(map[string][]string, len(synonyms))
+			for value, list := range synonyms {
+				definition.EnumSynonyms[value] = stringList(list)
+			}
+		}
+		if definition.FieldName == "" {
+			continue
+		}
+		if definition.Title == "" {
+			definition.Title = strings.ReplaceAll(definition.FieldName, "_", " ")
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions
+}
+
+// stringList reads a list of strings from a request parameter
+func stringList(param interface{}) []string {
+	list, _ := param.([]interface{})
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok && s != "" {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// averageAttributeConfidence averages the confidence of the extracted values
+func averageAttributeConfidence(result *analysis.AttributesResult) float64 {
+	total, n := 0.0, 0
+	for _, value := range result.AttributeValues {
+		total += value.Confidence
+		n++
+	}
+	for _, stats := range result.Statistics {
+		total += stats.AverageConfidence * float64(stats.Extracted)
+		n += stats.Extracted
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / float64(n)
+}
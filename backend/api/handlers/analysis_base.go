@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,8 +12,14 @@ import (
 	"time"
 
 	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
 	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
 	"agenticflows/backend/db"
+	"agenticflows/backend/pii"
+	"agenticflows/backend/privacy"
+	"agenticflows/backend/webhooks"
+	"agenticflows/backend/workflow"
 
 	"github.com/google/uuid"
 )
@@ -23,6 +31,8 @@ type AnalysisHandler struct {
 	recommendationEngine *analysis.RecommendationEngine
 	planner              *analysis.Planner
 	apiKey               string
+	cacheTTL             time.Duration
+	jobs                 *jobQueue
 }
 
 // NewAnalysisHandler creates a new handler for analysis endpoints
@@ -32,10 +42,11 @@ func NewAnalysisHandler() (*AnalysisHandler, error) {
 		return nil, fmt.Errorf("failed to initialize analysis table: %w", err)
 	}
 
-	// Get API key from environment
+	// Get API key from environment; an OpenAI-compatible gateway may not need one,
+	// and the mock provider, which demo mode always uses, needs none
 	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY environment variable is required")
+	if apiKey == "" && !core.GatewayConfigured() && !core.MockConfigured() {
+		return nil, fmt.Errorf("GEMINI_API_KEY, LLM_BASE_URL or LLM_PROVIDER=mock environment variable is required")
 	}
 
 	// Create analyzer facade
@@ -61,14 +72,29 @@ func NewAnalysisHandler() (*AnalysisHandler, error) {
 		return nil, fmt.Errorf("failed to create planner: %w", err)
 	}
 
-	// Create and return the analysis handler
-	return &AnalysisHandler{
+	// Create the analysis handler and start the background job workers
+	handler := &AnalysisHandler{
 		analysisFacade:       analysisFacade,
 		textGenerator:        textGenerator,
 		recommendationEngine: recommendationEngine,
 		planner:              planner,
 		apiKey:               apiKey,
-	}, nil
+		cacheTTL:             analysisCacheTTL(),
+	}
+	if n, err := db.PurgeExpiredAnalysisCache(); err != nil {
+		log.Printf("Error purging expired analysis cache entries: %v", err)
+	} else if n > 0 {
+		log.Printf("Purged %d expired analysis cache entries", n)
+	}
+	applySchedulerSettings()
+	configureLLMAudit()
+	configureLLMResponseCache()
+	handler.jobs = handler.startJobQueue()
+
+	// Chain steps run like the analyses of their type
+	analysisFacade.SetChainStepRunner(handler.runChainStep)
+
+	return handler, nil
 }
 
 // HandleAnalysis handles the unified /api/analysis endpoint
@@ -86,63 +112,342 @@ func (h *AnalysisHandler) HandleAnalysis(w http.ResponseWriter, r *http.Request)
 		sendAnalysisError(w, "invalid_request", fmt.Sprintf("Invalid request format: %s", err), http.StatusBadRequest)
 		return
 	}
+	auditAnalysisRequest(r.Context(), actorFromRequest(r), req, "")
+
+	analysisType, runAnalysis, err := h.prepareAnalysis(r.Context(), actorFromRequest(r), &req)
+	if err != nil {
+		sendAnalysisFailure(w, err)
+		return
+	}
+
+	// Stream progress and partial output instead of blocking until completion
+	if req.Stream {
+		h.streamAnalysis(w, r, req, analysisType, runAnalysis)
+		return
+	}
+
+	resp, err := runAnalysis(r.Context(), req)
+	if err != nil {
+		log.Printf("Error processing %s analysis: %v", req.AnalysisType, err)
+		sendAnalysisFailure(w, err)
+		return
+	}
+
+	if err := h.completeAnalysis(auth.TenantID(r.Context()), actorFromRequest(r), req, analysisType, resp); err != nil {
+		sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Return standard response
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// PerformAnalysis runs an analysis request for transports other than HTTP, such as the
+// gRPC server, and stores its result like /api/analysis does. Partial model output is
+// passed to stream, if set, unless it is withheld for privacy (see streamsPartialOutput).
+// AnalysisFailure maps errors to error codes.
+func (h *AnalysisHandler) PerformAnalysis(ctx context.Context, actor string, req models.StandardAnalysisRequest, stream core.StreamFunc) (*models.StandardAnalysisResponse, error) {
+	auditAnalysisRequest(ctx, actor, req, "")
+	analysisType, runAnalysis, err := h.prepareAnalysis(ctx, actor, &req)
+	if err != nil {
+		return nil, err
+	}
+	if stream != nil && streamsPartialOutput() {
+		ctx = core.WithStream(ctx, stream)
+	}
+
+	resp, err := runAnalysis(ctx, req)
+	if err != nil {
+		log.Printf("Error processing %s analysis: %v", req.AnalysisType, err)
+		return nil, err
+	}
+	if err := h.completeAnalysis(auth.TenantID(ctx), actor, req, analysisType, resp); err != nil {
+		return nil, invalidRequest(err)
+	}
+	return resp, nil
+}
+
+// prepareAnalysis validates a request, translates, deduplicates and loads the
+// conversations it references, and returns its normalized analysis type with the function that runs it
+func (h *AnalysisHandler) prepareAnalysis(ctx context.Context, actor string, req *models.StandardAnalysisRequest) (string, analysisFunc, error) {
+	if err := validateCostTags(req.Tags); err != nil {
+		return "", nil, invalidRequest(err)
+	}
+
+	// Fill in the workspace defaults of omitted parameters before the request is cached
+	req.Parameters = withWorkspaceDefaults(req.Parameters, workspaceDefaults(auth.TenantID(ctx)))
+	if err := validateDefaultableParameters(req.Parameters); err != nil {
+		return "", nil, invalidRequest(err)
+	}
+	if _, err := requestLanguage(*req); err != nil {
+		return "", nil, invalidRequest(err)
+	}
+	if err := validateAnalysisRun(auth.TenantID(ctx), req.WorkflowID, req.RunID); err != nil {
+		return "", nil, err
+	}
+
+	dryRun, err := dryRunRequested(req.Parameters)
+	if err != nil {
+		return "", nil, invalidRequest(err)
+	}
+	if dryRun && req.Stream {
+		return "", nil, invalidRequest(fmt.Errorf("dry_run cannot be combined with stream"))
+	}
+
+	// Translate conversations into a common language, drop duplicates, then load those
+	// referenced by ID. Dry runs do not translate, as translations are stored.
+	target, err := translationTarget(req.Parameters)
+	if err != nil {
+		return "", nil, invalidRequest(err)
+	}
+	var languages map[string]int
+	var redaction *pii.Report
+	var notes []string
+	if dryRun && target != "" {
+		notes = append(notes, fmt.Sprintf("conversations were not translated into %s; stored translations were used where they exist", target))
+	} else if languages, redaction, err = h.translateRequest(ctx, req); err != nil {
+		return "", nil, err
+	}
+	duplicates, err := dedupRequest(auth.TenantID(ctx), req)
+	if err != nil {
+		return "", nil, invalidRequest(err)
+	}
+	if err := resolveConversationRefs(auth.TenantID(ctx), req); err != nil {
+		return "", nil, invalidRequest(err)
+	}
 
 	// Log the analysis type for debugging
 	log.Printf("Received analysis request with type: %s", req.AnalysisType)
 
 	// Convert analysis type to lowercase for case-insensitive matching
 	analysisType := strings.ToLower(req.AnalysisType)
-	log.Printf("Using normalized analysis type: %s", analysisType)
 
 	// Route to appropriate analysis function based on type
-	var resp *models.StandardAnalysisResponse
-	var err error
+	runAnalysis := h.analysisRunner(analysisType)
+	if runAnalysis == nil {
+		return "", nil, fmt.Errorf("%w: %s", analysis.ErrInvalidAnalysisType, req.AnalysisType)
+	}
+
+	// Optionally run the analysis several times and merge the samples by vote
+	samples, err := analysisSamples(*req)
+	if err != nil {
+		return "", nil, invalidRequest(err)
+	}
+
+	// Dry runs are neither cached, scheduled nor counted as usage
+	if dryRun {
+		return analysisType, withDuplicates(withDryRun(withWarnings(withSamples(runAnalysis, samples)), notes), duplicates), nil
+	}
+	return analysisType, withDuplicates(withSourceLanguages(withScheduling(withUsage(db.UsageKindAnalysis, actor, analysisType,
+		h.withCache(analysisType, withWarnings(withSamples(withTranslationRedaction(runAnalysis, redaction), samples))))), languages), duplicates), nil
+}
+
+// requestError is a failure caused by the request rather than by the analysis
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// invalidRequest marks err as caused by the request, which is reported as invalid_request
+func invalidRequest(err error) error {
+	return &requestError{err: err}
+}
+
+// analysisFunc runs one type of analysis
+type analysisFunc func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error)
+
+// analysisRunner returns the function for an analysis type, or nil if the type is unknown.
+// Results are normalized to the typed result schema of the analysis type.
+func (h *AnalysisHandler) analysisRunner(analysisType string) analysisFunc {
+	run := h.handlerFor(analysisType)
+	if run == nil {
+		return nil
+	}
+	run = withCanary(analysisType, run)
+
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		req, err := applyAttributeSet(auth.TenantID(ctx), analysisType, req)
+		if err != nil {
+			return nil, err
+		}
+		// PII is replaced before any prompt is built from the conversations, and reported
+		// as found before translation when the request was translated
+		redaction, err := redactRequest(ctx, analysisType, &req)
+		if err != nil {
+			return nil, err
+		}
+		if translated := translationRedaction(ctx); translated != nil {
+			redaction = translated
+		}
+
+		// Requests can ask for deterministic mock output, e.g. in integration tests
+		if useMockData(req.Parameters) {
+			ctx = core.WithMock(ctx)
+		}
+		// Long conversations are summarized before the prompt templates and output style
+		// apply, so summaries keep the language of the conversations
+		summarization, err := h.presummarizeRequest(ctx, analysisType, &req)
+		if err != nil {
+			return nil, err
+		}
+		ctx, err = withPromptTemplates(ctx, req.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		style, err := outputStyle(req.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		ctx = core.WithOutputStyle(ctx, style)
+		minimum, err := minConfidence(req.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		// Tell the model the language of the conversations, so they are not read as English
+		language, err := conversationLanguage(auth.TenantID(ctx), analysisType, req)
+		if err != nil {
+			return nil, err
+		}
+		ctx = core.WithConversationLanguage(ctx, promptLanguage(language))
+		if language != nil {
+			language.OutputLanguage = style.Language
+		}
+
+		resp, err := run(ctx, req)
+		if resp != nil {
+			resp.LanguageMetadata = language
+			resp.Summarization = summarization
+		}
+		if resp != nil && redaction != nil {
+			resp.PIIRedaction = redaction
+		}
+		if resp != nil && resp.PIIRedaction != nil {
+			if summary := redactionSummary(resp.PIIRedaction); summary != "" {
+				resp.DataQuality.Limitations = append(resp.DataQuality.Limitations, summary)
+			}
+		}
+		if err != nil || resp == nil || resp.Error != nil {
+			return resp, err
+		}
 
+		normalized, err := analysis.NormalizeResults(analysisType, resp.Results)
+		if err != nil {
+			log.Printf("Returning unnormalized results: %v", err)
+			return resp, nil
+		}
+		resp.Results = normalized
+
+		// Drop items the model is not confident enough in
+		if minimum > 0 {
+			var dropped int
+			resp.Results, dropped = analysis.FilterByConfidence(resp.Results, minimum)
+			if dropped > 0 {
+				resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+					fmt.Sprintf("%d items with a confidence below %g were omitted", dropped, minimum))
+			}
+		}
+		return resp, nil
+	}
+}
+
+// useMockData reports whether analysis parameters ask for the mock provider
+func useMockData(parameters map[string]interface{}) bool {
+	mock, _ := parameters["use_mock_data"].(bool)
+	return mock
+}
+
+// handlerFor returns the handler of an analysis type, or nil if the type is unknown
+func (h *AnalysisHandler) handlerFor(analysisType string) analysisFunc {
 	switch analysisType {
 	case "trends":
-		resp, err = h.handleTrendsAnalysis(r.Context(), req)
+		return h.handleTrendsAnalysis
 	case "patterns":
-		resp, err = h.handlePatternsAnalysis(r.Context(), req)
+		return h.handlePatternsAnalysis
 	case "findings":
-		resp, err = h.handleFindingsAnalysis(r.Context(), req)
+		return h.handleFindingsAnalysis
 	case "attributes":
-		resp, err = h.handleAttributesAnalysis(r.Context(), req)
+		return h.handleAttributesAnalysis
 	case "intent":
-		resp, err = h.handleIntentAnalysis(r.Context(), req)
+		return h.handleIntentAnalysis
+	case "sentiment":
+		return h.handleSentimentAnalysis
+	case "compare":
+		return h.handleCompareAnalysis
 	case "recommendations":
-		resp, err = h.handleRecommendationsAnalysis(r.Context(), req)
+		return h.handleRecommendationsAnalysis
 	case "plan":
-		resp, err = h.handlePlanAnalysis(r.Context(), req)
+		return h.handlePlanAnalysis
+	case "dedup":
+		return h.handleDedupAnalysis
+	case "summarize":
+		return h.handleSummarizeAnalysis
+	case "anomalies":
+		return h.handleAnomaliesAnalysis
 	default:
-		log.Printf("Invalid analysis type: %s (original: %s)", analysisType, req.AnalysisType)
-		sendAnalysisError(w, "invalid_analysis_type", "Invalid analysis type", http.StatusBadRequest)
-		return
+		return nil
 	}
+}
 
-	if err != nil {
-		log.Printf("Error processing %s analysis: %v", req.AnalysisType, err)
-		sendAnalysisError(w, "analysis_error", err.Error(), http.StatusInternalServerError)
-		return
+// completeAnalysis applies confidence propagation to a finished analysis, stores it for
+// the tenant when a workflow ID is provided, and suppresses small aggregate groups from
+// the response. Errors are returned only for invalid requests.
+func (h *AnalysisHandler) completeAnalysis(tenantID, actor string, req models.StandardAnalysisRequest, analysisType string, resp *models.StandardAnalysisResponse) error {
+	if resp == nil || resp.Error != nil || resp.DryRun != nil {
+		return nil
+	}
+
+	// Bound the confidence of the result by the stored results it was derived from
+	if err := propagateSourceConfidence(tenantID, req, resp); err != nil {
+		return err
 	}
 
 	// Save result to database if workflow ID is provided
-	if req.WorkflowID != "" && resp != nil && resp.Error == nil {
+	if req.WorkflowID != "" {
 		resultID := uuid.New().String()
 		resultsJSON, err := json.Marshal(resp.Results)
 		if err != nil {
 			log.Printf("Error marshaling results for storage: %v", err)
 		} else {
-			if err := db.SaveAnalysisResult(resultID, req.WorkflowID, req.AnalysisType, string(resultsJSON)); err != nil {
+			if err := db.SaveAnalysisResult(tenantID, resultID, req.WorkflowID, req.RunID, req.AnalysisType, string(resultsJSON), resp.Confidence); err != nil {
 				log.Printf("Error saving analysis result: %v", err)
+			} else {
+				recordActivityAs(tenantID, actor, db.ActivityAnalysisCompleted, req.WorkflowID,
+					fmt.Sprintf("%s analysis completed", analysisType),
+					map[string]interface{}{"result_id": resultID, "analysis_type": analysisType})
+				recordLineage(tenantID, req, resultID, analysisType)
+				resp.ResultID = resultID
 			}
 		}
 	}
 
-	// Return standard response
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
+	// Results are stored in full; clients never see buckets small enough to re-identify customers
+	resp.Results, resp.SuppressedGroups = privacy.SuppressSmallGroups(resp.Results, privacy.MinGroupSize())
+
+	// Likewise, identifiers are replaced with pseudonyms when they are enabled
+	resp.Results = pseudonymizeResults(tenantID, resp.Results)
+
+	// Subscribers receive the results as clients do
+	webhooks.Dispatch(webhooks.Event{
+		TenantID:     tenantID,
+		AnalysisType: analysisType,
+		WorkflowID:   req.WorkflowID,
+		ResultID:     resp.ResultID,
+		Confidence:   resp.Confidence,
+		Timestamp:    resp.Timestamp,
+		Results:      resp.Results,
+	})
+
+	return nil
 }
 
 // HandleAnalysisResults handles /api/analysis/results endpoint
@@ -158,13 +463,16 @@ func (h *AnalysisHandler) HandleAnalysisResults(w http.ResponseWriter, r *http.R
 			return
 		}
 
-		results, err := db.GetAnalysisResultsByWorkflow(workflowID)
+		results, err := db.GetAnalysisResultsByWorkflow(auth.TenantID(r.Context()), workflowID)
 		if err != nil {
 			log.Printf("Error getting analysis results: %v", err)
 			http.Error(w, "Failed to get analysis results", http.StatusInternalServerError)
 			return
 		}
 
+		// Suppress small aggregate groups and pseudonymize, as for fresh analysis responses
+		guardStoredResults(auth.TenantID(r.Context()), results)
+
 		if err := json.NewEncoder(w).Encode(results); err != nil {
 			log.Printf("Error encoding response: %v", err)
 			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
@@ -178,7 +486,7 @@ func (h *AnalysisHandler) HandleAnalysisResults(w http.ResponseWriter, r *http.R
 			return
 		}
 
-		if err := db.DeleteAnalysisResult(id); err != nil {
+		if err := db.DeleteAnalysisResult(auth.TenantID(r.Context()), id); err != nil {
 			log.Printf("Error deleting analysis result: %v", err)
 			http.Error(w, "Failed to delete analysis result", http.StatusInternalServerError)
 			return
@@ -200,35 +508,69 @@ func (h *AnalysisHandler) HandleChainAnalysis(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Parse request
-	var req struct {
-		WorkflowID string                 `json:"workflow_id"`
-		Steps      []string               `json:"steps"`
-		Text       string                 `json:"text"`
-		Parameters map[string]interface{} `json:"parameters"`
-	}
-
+	var req ChainRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
 		return
 	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inputData, config := req.chainConfig(auth.TenantID(r.Context()))
+	h.runChain(w, r, req.WorkflowID, req.RunID, req.Tags, req.MaxBudget, inputData, config)
+}
+
+// ChainRequest is the body of a chain analysis request
+type ChainRequest struct {
+	WorkflowID string `json:"workflow_id"`
+	// RunID identifies the run for progress requests; one is generated if empty
+	RunID      string                 `json:"run_id,omitempty"`
+	Steps      []string               `json:"steps"`
+	Text       string                 `json:"text"`
+	Parameters map[string]interface{} `json:"parameters"`
+
+	// ConfidencePropagation selects how confidence carries between steps ("min" or "product")
+	ConfidencePropagation string `json:"confidence_propagation,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"`
 
-	// Validate request
+	// MaxBudget aborts the chain once its LLM calls have cost this much, in USD
+	MaxBudget float64 `json:"max_budget,omitempty"`
+}
+
+// validate checks a chain request before it is run
+func (req ChainRequest) validate() error {
 	if req.WorkflowID == "" {
-		http.Error(w, "workflow_id is required", http.StatusBadRequest)
-		return
+		return fmt.Errorf("workflow_id is required")
 	}
 	if len(req.Steps) == 0 {
-		http.Error(w, "steps are required", http.StatusBadRequest)
-		return
+		return fmt.Errorf("steps are required")
+	}
+	if err := validateRunID(req.RunID); err != nil {
+		return err
+	}
+	if err := validateCostTags(req.Tags); err != nil {
+		return err
+	}
+	if err := validateDefaultableParameters(req.Parameters); err != nil {
+		return err
 	}
+	if _, err := dryRunRequested(req.Parameters); err != nil {
+		return err
+	}
+	return validateMaxBudget(req.MaxBudget)
+}
 
-	// Initialize chain analysis config
+// chainConfig returns the input data and chain configuration of a chain request of a tenant
+func (req ChainRequest) chainConfig(tenantID string) (map[string]interface{}, map[string]interface{}) {
 	config := map[string]interface{}{
-		"steps": req.Steps,
+		"steps":       req.Steps,
+		"step_config": withWorkspaceDefaults(req.Parameters, workspaceDefaults(tenantID)),
 	}
-	if req.Parameters != nil {
-		config["step_config"] = req.Parameters
+	if req.ConfidencePropagation != "" {
+		config["confidence_propagation"] = req.ConfidencePropagation
 	}
 
 	// Create input data with the text if provided
@@ -236,24 +578,194 @@ func (h *AnalysisHandler) HandleChainAnalysis(w http.ResponseWriter, r *http.Req
 	if req.Text != "" {
 		inputData["text"] = req.Text
 	}
+	return inputData, config
+}
+
+// ChainAnalysis runs a chain analysis request for transports other than HTTP, such as
+// the gRPC server. Invalid requests fail with errors AnalysisFailure reports as
+// invalid_request, and chains over budget with a *core.BudgetExceededError.
+func (h *AnalysisHandler) ChainAnalysis(ctx context.Context, actor string, req ChainRequest) (map[string]interface{}, error) {
+	if err := req.validate(); err != nil {
+		return nil, invalidRequest(err)
+	}
+	inputData, config := req.chainConfig(auth.TenantID(ctx))
+	if dryRun, _ := dryRunRequested(req.Parameters); dryRun {
+		report, levels, err := h.dryRunChain(ctx, inputData, config)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"dry_run": report, "execution_levels": levels}, nil
+	}
+	results, _, err := h.chain(ctx, actor, req.WorkflowID, req.RunID, req.Tags, req.MaxBudget, inputData, config)
+	return results, err
+}
 
-	// Perform chain analysis
-	results, err := h.analysisFacade.ChainAnalysis(r.Context(), inputData, config)
+// chain performs a chain analysis and records its usage, SLA run and the progress of
+// each step, which it returns as the step trace
+func (h *AnalysisHandler) chain(ctx context.Context, actor, workflowID, runID string, tags map[string]string, maxBudget float64, inputData, config map[string]interface{}) (map[string]interface{}, *chainProgress, error) {
+	stepConfig, _ := config["step_config"].(map[string]interface{})
+	ctx, err := chainStepContext(ctx, stepConfig)
 	if err != nil {
+		return nil, nil, err
+	}
+	ctx, usage := core.WithUsage(core.WithWorkflow(ctx, workflowID))
+	if maxBudget > 0 {
+		usage.SetBudget(maxBudget, tokenPrices().usageCost)
+	}
+	progress, err := startChainProgress(auth.TenantID(ctx), runID, workflowID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx = core.WithChainProgress(ctx, progress.record)
+	startRun(auth.TenantID(ctx), progress.runID, workflowID, db.RunKindChain, actor, chainRunInputs(inputData, config))
+	started := time.Now()
+	results, err := h.analysisFacade.ChainAnalysis(ctx, inputData, config)
+	progress.finish(err)
+	runStatus := db.RunCompleted
+	if err != nil {
+		runStatus = db.RunFailed
+	}
+	finishRun(progress.runID, runStatus, err, time.Since(started), chainRunNodes(progress.trace(), results), usage)
+	recordSLA(ctx, workflowID, db.UsageKindChain, workflow.RunMeasurement{
+		Runtime:    time.Since(started),
+		Cost:       tokenPrices().usageCost(usage.Totals()),
+		Confidence: workflow.RunConfidence(results),
+		Failed:     err != nil,
+	})
+	saveUsage(db.UsageRecord{
+		TenantID:   auth.TenantID(ctx),
+		Kind:       db.UsageKindChain,
+		WorkflowID: workflowID,
+		Actor:      actor,
+		Tags:       tags,
+	}, usage)
+	if err == nil {
+		h.completeChain(ctx, actor, workflowID, progress.runID, inputData, results)
+	}
+	return results, progress, err
+}
+
+// completeChain completes each step of a finished chain like a single analysis, in the
+// order the steps ran: its result is stored for the tenant of ctx with its lineage, and
+// small groups and identifiers are filtered from the results returned. The IDs of the
+// stored results are added to the results as result_ids.
+func (h *AnalysisHandler) completeChain(ctx context.Context, actor, workflowID, runID string, inputData, results map[string]interface{}) {
+	text, _ := inputData["text"].(string)
+	confidence := map[string]float64{}
+	dependsOn := map[string][]string{}
+	if trace, ok := results["confidence_trace"].([]core.ConfidenceStep); ok {
+		for _, step := range trace {
+			confidence[step.Step] = step.Propagated
+			dependsOn[step.Step] = step.DependsOn
+		}
+	}
+
+	resultIDs := map[string]string{}
+	suppressed := 0
+	levels, _ := results["execution_levels"].([][]string)
+	for _, level := range levels {
+		for _, step := range level {
+			req := models.StandardAnalysisRequest{AnalysisType: step, WorkflowID: workflowID, RunID: runID, Text: text}
+
+			// The stored results of the steps it consumed are its sources. Its confidence
+			// already accounts for theirs, so only the lineage edges are added.
+			for _, dep := range dependsOn[step] {
+				if id, ok := resultIDs[dep]; ok {
+					req.Sources = append(req.Sources, models.SourceRef{Type: dep, ID: id})
+				}
+			}
+			resp := &models.StandardAnalysisResponse{
+				AnalysisType: step,
+				WorkflowID:   workflowID,
+				Timestamp:    time.Now(),
+				Results:      results[step],
+				Confidence:   confidence[step],
+			}
+			if err := h.completeAnalysis(auth.TenantID(ctx), actor, req, step, resp); err != nil {
+				log.Printf("Error completing step %s of chain run %s: %v", step, runID, err)
+				results[step] = nil
+				continue
+			}
+			results[step] = resp.Results
+			suppressed += resp.SuppressedGroups
+			if resp.ResultID != "" {
+				resultIDs[step] = resp.ResultID
+			}
+		}
+	}
+	results["result_ids"] = resultIDs
+	if suppressed > 0 {
+		results["suppressed_groups"] = suppressed
+	}
+}
+
+// chainRunInputs returns the inputs of a chain run as recorded in its history
+func chainRunInputs(inputData, config map[string]interface{}) map[string]interface{} {
+	inputs := map[string]interface{}{"steps": config["steps"], "parameters": config["step_config"]}
+	if text, ok := inputData["text"]; ok {
+		inputs["text"] = text
+	}
+	return inputs
+}
+
+// chainStepContext returns the context chain steps run with: the mock provider, prompt
+// templates and output style of the step configuration
+func chainStepContext(ctx context.Context, stepConfig map[string]interface{}) (context.Context, error) {
+	if useMockData(stepConfig) {
+		ctx = core.WithMock(ctx)
+	}
+	ctx, err := withPromptTemplates(ctx, stepConfig)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	style, err := outputStyle(stepConfig)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	return core.WithOutputStyle(ctx, style), nil
+}
+
+// runChain performs a chain analysis and writes its response. A chain with a max budget
+// is aborted with 402 Payment Required once its LLM calls have cost that much.
+func (h *AnalysisHandler) runChain(w http.ResponseWriter, r *http.Request, workflowID, runID string, tags map[string]string, maxBudget float64, inputData, config map[string]interface{}) {
+	stepConfig, _ := config["step_config"].(map[string]interface{})
+	if dryRun, _ := dryRunRequested(stepConfig); dryRun {
+		h.sendChainDryRun(w, r, workflowID, inputData, config)
+		return
+	}
+
+	results, progress, err := h.chain(r.Context(), actorFromRequest(r), workflowID, runID, tags, maxBudget, inputData, config)
+	var invalid *requestError
+	var overBudget *core.BudgetExceededError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.As(err, &overBudget):
+		log.Printf("Chain analysis for workflow %s aborted: %v", workflowID, err)
+		http.Error(w, fmt.Sprintf("Chain analysis aborted: %v", err), http.StatusPaymentRequired)
+		return
+	case err != nil:
 		log.Printf("Error in chain analysis: %v", err)
 		http.Error(w, fmt.Sprintf("Error in chain analysis: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Return chain analysis response
+	// Return chain analysis response with ready-to-run next steps
 	chainResp := struct {
-		WorkflowID string                 `json:"workflow_id"`
-		Timestamp  time.Time              `json:"timestamp"`
-		Results    map[string]interface{} `json:"results"`
+		WorkflowID  string                 `json:"workflow_id"`
+		RunID       string                 `json:"run_id"`
+		Timestamp   time.Time              `json:"timestamp"`
+		Results     map[string]interface{} `json:"results"`
+		StepTrace   []db.ChainRunStep      `json:"step_trace"`
+		Suggestions []analysis.Suggestion  `json:"suggestions,omitempty"`
 	}{
-		WorkflowID: req.WorkflowID,
-		Timestamp:  time.Now(),
-		Results:    results,
+		WorkflowID:  workflowID,
+		RunID:       progress.runID,
+		Timestamp:   time.Now(),
+		Results:     results,
+		StepTrace:   progress.trace(),
+		Suggestions: analysis.SuggestNextAnalyses(chainContext(workflowID, inputData, config), results),
 	}
 
 	if err := json.NewEncoder(w).Encode(chainResp); err != nil {
@@ -262,6 +774,51 @@ func (h *AnalysisHandler) HandleChainAnalysis(w http.ResponseWriter, r *http.Req
 	}
 }
 
+// sendChainDryRun writes the prompts a chain would send, their estimated cost and the
+// levels its steps would run in
+func (h *AnalysisHandler) sendChainDryRun(w http.ResponseWriter, r *http.Request, workflowID string, inputData, config map[string]interface{}) {
+	report, levels, err := h.dryRunChain(r.Context(), inputData, config)
+	var invalid *requestError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Error in chain analysis dry run: %v", err)
+		http.Error(w, fmt.Sprintf("Error in chain analysis: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	chainResp := struct {
+		WorkflowID      string               `json:"workflow_id"`
+		Timestamp       time.Time            `json:"timestamp"`
+		DryRun          *models.DryRunReport `json:"dry_run"`
+		ExecutionLevels interface{}          `json:"execution_levels,omitempty"`
+	}{
+		WorkflowID:      workflowID,
+		Timestamp:       time.Now(),
+		DryRun:          report,
+		ExecutionLevels: levels,
+	}
+	if err := json.NewEncoder(w).Encode(chainResp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// chainContext describes the request of a chain for its next-step suggestions
+func chainContext(workflowID string, inputData, config map[string]interface{}) analysis.ChainContext {
+	steps, _ := config["steps"].([]string)
+	text, _ := inputData["text"].(string)
+	parameters, _ := config["step_config"].(map[string]interface{})
+	return analysis.ChainContext{
+		WorkflowID: workflowID,
+		Steps:      steps,
+		Text:       text,
+		Parameters: parameters,
+	}
+}
+
 // HandleGetFunctionMetadata handles metadata requests for analysis functions
 func (h *AnalysisHandler) HandleGetFunctionMetadata(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -281,13 +838,48 @@ func (h *AnalysisHandler) HandleGetFunctionMetadata(w http.ResponseWriter, r *ht
 	}
 }
 
+// sendAnalysisFailure sends the error of a failed analysis
+func sendAnalysisFailure(w http.ResponseWriter, err error) {
+	code, status := AnalysisFailure(err)
+	sendAnalysisError(w, code, err.Error(), status)
+}
+
+// analysisFailureStatus is the HTTP status of each analysis error code
+var analysisFailureStatus = map[string]int{
+	analysis.CodeInvalidRequest:      http.StatusBadRequest,
+	analysis.CodeInvalidAnalysisType: http.StatusBadRequest,
+	analysis.CodeInvalidModelOutput:  http.StatusBadGateway,
+	analysis.CodeProviderTimeout:     http.StatusGatewayTimeout,
+	analysis.CodeProviderUnavailable: http.StatusServiceUnavailable,
+	analysis.CodeDataTooLarge:        http.StatusRequestEntityTooLarge,
+	analysis.CodeBudgetExceeded:      http.StatusPaymentRequired,
+	analysis.CodeCanceled:            http.StatusServiceUnavailable,
+}
+
+// AnalysisFailure returns the error code and HTTP status of a failed analysis. Model
+// output that failed its schema after all repair attempts is reported as
+// invalid_model_output, and the other sentinel errors of the analysis package get their
+// own codes; analysis.Retryable tells which codes are worth retrying.
+func AnalysisFailure(err error) (string, int) {
+	var invalid *requestError
+	if errors.As(err, &invalid) {
+		return analysis.CodeInvalidRequest, http.StatusBadRequest
+	}
+	code := analysis.ErrorCode(err)
+	if status, ok := analysisFailureStatus[code]; ok {
+		return code, status
+	}
+	return code, http.StatusInternalServerError
+}
+
 // Helper function to send standardized error responses
 func sendAnalysisError(w http.ResponseWriter, code string, message string, statusCode int) {
 	resp := models.StandardAnalysisResponse{
 		Timestamp: time.Now(),
 		Error: &models.AnalysisError{
-			Code:    code,
-			Message: message,
+			Code:      code,
+			Message:   message,
+			Retryable: analysis.Retryable(code),
 		},
 	}
 
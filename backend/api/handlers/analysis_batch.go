@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/statistics"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// textAnalysisTypes are analyses of a single text, run once per item within each batch
+var textAnalysisTypes = map[string]bool{
+	"intent":    true,
+	"sentiment": true,
+}
+
+// maxRequestConversations is the most conversations a single-text analysis accepts in
+// data.conversations; larger sets go through the batch endpoint
+const maxRequestConversations = 500
+
+// requestConversation is one conversation of a single-text analysis over several
+type requestConversation struct {
+	ID   string
+	Text string
+}
+
+// requestConversations reads data.conversations, which text analyses accept in place of
+// text to analyze many conversations in one request. Items are strings or objects with a
+// text and an optional id or conversation_id. The speaker_role parameter applies to every
+// conversation. It returns nil when the request has no conversations.
+func requestConversations(req models.StandardAnalysisRequest) ([]requestConversation, error) {
+	raw, ok := req.Data["conversations"]
+	if !ok {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("data.conversations must be a non-empty array")
+	}
+	if len(items) > maxRequestConversations {
+		return nil, fmt.Errorf("%w: data.conversations holds %d conversations; at most %d are allowed, use /api/analysis/batch for more", analysis.ErrDataTooLarge, len(items), maxRequestConversations)
+	}
+	if req.Text != "" {
+		return nil, fmt.Errorf("text and data.conversations cannot both be set")
+	}
+
+	role, err := speakerRole(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	conversations := make([]requestConversation, len(items))
+	for i, item := range items {
+		text, id := itemText(item, "text")
+		if text == "" {
+			return nil, fmt.Errorf("conversation %d has no text", i+1)
+		}
+		if text, err = speakerTurnsText(text, role); err != nil {
+			return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+		}
+		conversations[i] = requestConversation{ID: id, Text: text}
+	}
+	return conversations, nil
+}
+
+// conversationTexts returns the texts of conversations
+func conversationTexts(conversations []requestConversation) []string {
+	texts := make([]string, len(conversations))
+	for i, conversation := range conversations {
+		texts[i] = conversation.Text
+	}
+	return texts
+}
+
+// batchAnalysisResponse is the merged response of a batch analysis
+type batchAnalysisResponse struct {
+	models.StandardAnalysisResponse
+	TotalItems     int                     `json:"total_items"`
+	FailedBatches  int                     `json:"failed_batches"`
+	Batches        []analysis.BatchOutcome `json:"batches"`
+	SucceededItems int                     `json:"succeeded_items"`
+	FailedItems    int                     `json:"failed_items"`
+	SkippedItems   int                     `json:"skipped_items"`
+	Items          []analysis.ItemStatus   `json:"items"`
+}
+
+// HandleBatchAnalysis handles POST /api/analysis/batch, running an analysis over a large
+// data array in server-side batches and merging the results
+func (h *AnalysisHandler) HandleBatchAnalysis(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.BatchAnalysisRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendAnalysisError(w, "invalid_request", fmt.Sprintf("Invalid request format: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.validateBatchRequest(req); err != nil {
+		sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.runBatchAnalysis(r.Context(), req, actorFromRequest(r), nil)
+	if err != nil {
+		log.Printf("Error processing batch %s analysis: %v", req.AnalysisType, err)
+		sendAnalysisFailure(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// validateBatchRequest checks a batch request before it is run
+func (h *AnalysisHandler) validateBatchRequest(req models.BatchAnalysisRequest) error {
+	if len(req.Items) == 0 {
+		return fmt.Errorf("data must be a non-empty array")
+	}
+	if h.analysisRunner(strings.ToLower(req.AnalysisType)) == nil {
+		return fmt.Errorf("%w: %s", analysis.ErrInvalidAnalysisType, req.AnalysisType)
+	}
+	if err := validateCostTags(req.Tags); err != nil {
+		return err
+	}
+	if req.BatchSize < 0 {
+		return fmt.Errorf("batch_size must not be negative")
+	}
+	if err := validateDefaultableParameters(req.Parameters); err != nil {
+		return err
+	}
+	if _, err := analysisSamples(models.StandardAnalysisRequest{Parameters: req.Parameters}); err != nil {
+		return err
+	}
+	if _, err := analysis.NewBatchProcessor(analysis.BatchConfig{MergeStrategy: req.MergeStrategy}); err != nil {
+		return err
+	}
+	rule, _ := req.Parameters["confidence_propagation"].(string)
+	if _, err := core.ValidatePropagationRule(rule); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runBatchAnalysis runs a validated batch request, merges the results and stores them.
+// progress, if set, is called as batches finish.
+func (h *AnalysisHandler) runBatchAnalysis(ctx context.Context, req models.BatchAnalysisRequest, actor string, progress func(completed, total int)) (*batchAnalysisResponse, error) {
+	analysisType := strings.ToLower(req.AnalysisType)
+	runAnalysis := h.analysisRunner(analysisType)
+	if runAnalysis == nil {
+		return nil, fmt.Errorf("%w: %s", analysis.ErrInvalidAnalysisType, req.AnalysisType)
+	}
+
+	// Fill in the workspace defaults of omitted parameters and the batch size
+	defaults := workspaceDefaults(auth.TenantID(ctx))
+	req.Parameters = withWorkspaceDefaults(req.Parameters, defaults)
+	if req.BatchSize == 0 {
+		req.BatchSize = defaults.BatchSize
+	}
+
+	samples, err := analysisSamples(models.StandardAnalysisRequest{Parameters: req.Parameters})
+	if err != nil {
+		return nil, err
+	}
+	runAnalysis = h.withCache(analysisType, withWarnings(withSamples(runAnalysis, samples)))
+
+	processor, err := analysis.NewBatchProcessor(analysis.BatchConfig{
+		BatchSize:      req.BatchSize,
+		Concurrency:    req.Concurrency,
+		MergeStrategy:  req.MergeStrategy,
+		ConsolidateKey: req.ConsolidateKey,
+		OnProgress:     progress,
+		ItemID: func(item interface{}) string {
+			_, id := itemText(item, "")
+			return id
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := req.DataKey
+	if dataKey == "" {
+		dataKey = "conversations"
+	}
+
+	log.Printf("Running batch %s analysis over %d items", analysisType, len(req.Items))
+	ctx, usage := core.WithUsage(core.WithWorkflow(ctx, req.WorkflowID))
+	ctx, warnings := core.WithWarnings(ctx)
+	result, err := processor.Process(ctx, req.Items, batchRunner(req, analysisType, dataKey, runAnalysis))
+	saveUsage(db.UsageRecord{
+		TenantID:     auth.TenantID(ctx),
+		Kind:         db.UsageKindBatch,
+		AnalysisType: analysisType,
+		WorkflowID:   req.WorkflowID,
+		Actor:        actor,
+		Tags:         req.Tags,
+	}, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &batchAnalysisResponse{
+		StandardAnalysisResponse: models.StandardAnalysisResponse{
+			AnalysisType: analysisType,
+			WorkflowID:   req.WorkflowID,
+			Timestamp:    time.Now(),
+			Results:      result.Results,
+			Confidence:   result.Confidence,
+			Partial:      len(result.Errors) > 0,
+			Errors:       result.Errors,
+			Warnings:     analysisWarnings(warnings.List()),
+		},
+		TotalItems:     result.TotalItems,
+		FailedBatches:  result.FailedBatches,
+		Batches:        result.Batches,
+		SucceededItems: result.SucceededItems,
+		FailedItems:    result.FailedItems,
+		SkippedItems:   result.SkippedItems,
+		Items:          result.Items,
+	}
+	if result.FailedBatches > 0 {
+		resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+			fmt.Sprintf("%d of %d batches failed and are not included in the results", result.FailedBatches, len(result.Batches)))
+	}
+	if missing := result.FailedItems + result.SkippedItems; missing > 0 {
+		resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+			fmt.Sprintf("%d of %d items failed or were skipped and are not included in the results; see errors", missing, result.TotalItems))
+	}
+
+	// Statistics merged from the batches describe each batch; compute them over the whole dataset
+	if results, ok := resp.Results.(map[string]interface{}); ok && analysisType == "trends" {
+		if metrics := statistics.Summarize(map[string]interface{}{dataKey: req.Items}); metrics != nil {
+			results["metrics"] = metrics
+		}
+	}
+
+	// Store the merged result as a single analysis over the whole dataset
+	merged := models.StandardAnalysisRequest{
+		WorkflowID:   req.WorkflowID,
+		AnalysisType: req.AnalysisType,
+		Parameters:   req.Parameters,
+		Data:         map[string]interface{}{dataKey: req.Items},
+		Sources:      req.Sources,
+	}
+	if err := h.completeAnalysis(auth.TenantID(ctx), actor, merged, analysisType, &resp.StandardAnalysisResponse); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// batchRunner adapts an analysis to the batch processor. Data analyses receive each
+// batch under dataKey; text analyses are run once per item in the batch, reporting the
+// items that fail instead of failing the batch. The warnings of every response are
+// collected in the warnings of ctx, including those of cached responses.
+func batchRunner(req models.BatchAnalysisRequest, analysisType string, dataKey string, runAnalysis analysisFunc) analysis.BatchFunc {
+	return func(ctx context.Context, batch []interface{}) (*analysis.BatchOutput, error) {
+		if !textAnalysisTypes[analysisType] {
+			resp, err := runAnalysis(ctx, models.StandardAnalysisRequest{
+				AnalysisType: analysisType,
+				Parameters:   req.Parameters,
+				Data:         map[string]interface{}{dataKey: batch},
+				Cache:        req.Cache,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if resp.Error != nil {
+				return nil, analysis.ResponseError(resp.Error)
+			}
+			collectWarnings(ctx, resp.Warnings)
+			return &analysis.BatchOutput{Results: resp.Results, Confidence: resp.Confidence}, nil
+		}
+
+		textField := req.TextField
+		if textField == "" {
+			textField = "text"
+		}
+
+		results := make([]interface{}, 0, len(batch))
+		items := make([]analysis.ItemOutcome, len(batch))
+		var confidence float64
+		var lastErr error
+		for i, item := range batch {
+			if err := ctx.Err(); err != nil {
+				items[i] = analysis.ItemOutcome{Status: analysis.ItemSkipped, Error: err.Error(), Code: analysis.ErrorCode(err)}
+				continue
+			}
+			text, id := itemText(item, textField)
+			if text == "" {
+				items[i] = analysis.ItemOutcome{Status: analysis.ItemSkipped, Error: fmt.Sprintf("item has no %q field", textField), Code: analysis.CodeInvalidRequest}
+				continue
+			}
+
+			resp, err := runAnalysis(ctx, models.StandardAnalysisRequest{
+				AnalysisType: analysisType,
+				Parameters:   req.Parameters,
+				Text:         text,
+				Cache:        req.Cache,
+			})
+			if err == nil && resp.Error != nil {
+				err = analysis.ResponseError(resp.Error)
+			}
+			if err != nil {
+				items[i] = analysis.ItemOutcome{Status: analysis.ItemFailed, Error: err.Error(), Code: analysis.ErrorCode(err)}
+				lastErr = err
+				continue
+			}
+			collectWarnings(ctx, resp.Warnings)
+
+			entry := map[string]interface{}{"result": resp.Results}
+			if id != "" {
+				entry["id"] = id
+			}
+			results = append(results, entry)
+			items[i] = analysis.ItemOutcome{Status: analysis.ItemSucceeded}
+			confidence += resp.Confidence
+		}
+
+		// A batch with nothing analyzed fails as a whole, with the error of its last item
+		if len(results) == 0 {
+			if lastErr == nil {
+				lastErr = &analysis.CodedError{Code: items[0].Code, Message: items[0].Error}
+			}
+			return &analysis.BatchOutput{Items: items}, fmt.Errorf("no item of the batch was analyzed: %w", lastErr)
+		}
+		return &analysis.BatchOutput{Results: results, Confidence: confidence / float64(len(results)), Items: items}, nil
+	}
+}
+
+// collectWarnings adds the warnings of a response to the warnings collected by ctx
+func collectWarnings(ctx context.Context, warnings []models.AnalysisWarning) {
+	for _, warning := range warnings {
+		core.Warn(ctx, warning.Code, warning.Message)
+	}
+}
+
+// itemText returns the text and identifier of a batch item, which is either
+// a string or an object with the text in textField
+func itemText(item interface{}, textField string) (string, string) {
+	switch v := item.(type) {
+	case string:
+		return v, ""
+	case map[string]interface{}:
+		text, _ := v[textField].(string)
+		id, _ := v["id"].(string)
+		if id == "" {
+			id, _ = v["conversation_id"].(string)
+		}
+		return text, id
+	}
+	return "", ""
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/prompts"
+)
+
+// Analysis cache settings
+const (
+	envAnalysisCacheTTL     = "ANALYSIS_CACHE_TTL"
+	defaultAnalysisCacheTTL = 24 * time.Hour
+)
+
+// analysisCacheTTL reads how long cached analyses are reused. A zero TTL disables the cache.
+func analysisCacheTTL() time.Duration {
+	value := os.Getenv(envAnalysisCacheTTL)
+	if value == "" {
+		return defaultAnalysisCacheTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		log.Printf("Warning: ignoring invalid %s value %q", envAnalysisCacheTTL, value)
+		return defaultAnalysisCacheTTL
+	}
+	return ttl
+}
+
+// configureLLMResponseCache caches validated LLM replies when LLM_RESPONSE_CACHE_TTL is
+// set, so calls with the same model, schema and prompt, such as those warmed by the
+// precompute command, are answered without the model
+func configureLLMResponseCache() {
+	ttl, err := db.LLMResponseCacheTTL()
+	if err != nil {
+		log.Printf("Warning: ignoring %v", err)
+	}
+	if ttl <= 0 {
+		core.SetResponseCache(nil)
+		return
+	}
+	log.Printf("LLM response cache enabled: replies are reused for %s", ttl)
+	core.SetResponseCache(db.LLMResponseCache{TTL: ttl})
+}
+
+// analysisCacheKey hashes everything that determines the output of an analysis. Tenants
+// never share cached responses, since conversation IDs and attribute sets name their own
+// data.
+func analysisCacheKey(tenantID, analysisType string, req models.StandardAnalysisRequest) (string, error) {
+	// Results change with the prompt templates in use, which may be edited at any time
+	ref, _ := req.Parameters["prompt_template_id"].(string)
+	templates, err := prompts.ForRequest(tenantID, ref)
+	if err != nil {
+		return "", err
+	}
+	// and with the version of the attribute set in use, since updating a set adds a version
+	set, err := resolveAttributeSet(tenantID, req.Parameters)
+	if err != nil {
+		return "", err
+	}
+	var attributeSetVersion int
+	if set != nil {
+		attributeSetVersion = set.Version
+	}
+
+	// Maps are encoded with sorted keys, so equal requests always hash the same
+	encoded, err := json.Marshal(struct {
+		TenantID        string                 `json:"tenant_id"`
+		AnalysisType    string                 `json:"analysis_type"`
+		Text            string                 `json:"text"`
+		ConversationIDs []string               `json:"conversation_ids,omitempty"`
+		Language        string                 `json:"language,omitempty"`
+		Parameters      map[string]interface{} `json:"parameters"`
+		Data            map[string]interface{} `json:"data"`
+		PromptTemplates map[string]string      `json:"prompt_templates,omitempty"`
+		AttributeSet    int                    `json:"attribute_set_version,omitempty"`
+	}{tenantID, analysisType, req.Text, req.ConversationIDs, req.Language, req.Parameters, req.Data, templates, attributeSetVersion})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// withCache returns cached responses for identical requests and caches new ones.
+// Requests with "cache": false skip the lookup but still refresh the cache, and so do
+// their LLM calls with the LLM response cache.
+func (h *AnalysisHandler) withCache(analysisType string, runAnalysis analysisFunc) analysisFunc {
+	runAnalysis = withFreshResponses(runAnalysis)
+	if h.cacheTTL <= 0 {
+		return runAnalysis
+	}
+
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		// Replays call the model to compare its output, so they are neither served nor cached
+		if isReplay(ctx) {
+			return runAnalysis(ctx, req)
+		}
+		key, err := analysisCacheKey(auth.TenantID(ctx), analysisType, req)
+		if err != nil {
+			log.Printf("Error computing cache key, running uncached: %v", err)
+			return runAnalysis(ctx, req)
+		}
+
+		if req.Cache == nil || *req.Cache {
+			cached, ok, err := db.GetCachedAnalysis(key)
+			if err != nil {
+				log.Printf("Error reading analysis cache: %v", err)
+			} else if ok {
+				var resp models.StandardAnalysisResponse
+				if err := json.Unmarshal(cached, &resp); err != nil {
+					log.Printf("Ignoring unreadable cache entry %s: %v", key, err)
+				} else {
+					log.Printf("Serving %s analysis from cache", analysisType)
+					resp.WorkflowID = req.WorkflowID
+					resp.Cached = true
+					return &resp, nil
+				}
+			}
+		}
+
+		resp, err := runAnalysis(ctx, req)
+		if err != nil || resp == nil || resp.Error != nil {
+			return resp, err
+		}
+
+		encoded, err := json.Marshal(resp)
+		if err != nil {
+			log.Printf("Error encoding response for the analysis cache: %v", err)
+		} else if err := db.PutCachedAnalysis(key, analysisType, encoded, h.cacheTTL); err != nil {
+			log.Printf("Error writing analysis cache: %v", err)
+		}
+		return resp, nil
+	}
+}
+
+// withFreshResponses sends the LLM calls of replays and of requests with "cache": false to
+// the model even when their replies are in the LLM response cache
+func withFreshResponses(runAnalysis analysisFunc) analysisFunc {
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		if isReplay(ctx) || req.Cache != nil && !*req.Cache {
+			ctx = core.WithFreshResponses(ctx)
+		}
+		return runAnalysis(ctx, req)
+	}
+}
+
+// HandleAnalysisCache handles /api/analysis/cache: GET reports cache statistics and
+// DELETE clears the cache, optionally for one analysis_type
+func (h *AnalysisHandler) HandleAnalysisCache(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		stats, err := db.GetAnalysisCacheStats()
+		if err != nil {
+			log.Printf("Error reading analysis cache stats: %v", err)
+			http.Error(w, "Failed to read cache statistics", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled":     h.cacheTTL > 0,
+			"ttl_seconds": int64(h.cacheTTL.Seconds()),
+			"entries":     stats.Entries,
+			"expired":     stats.Expired,
+			"hits":        stats.Hits,
+		})
+
+	case http.MethodDelete:
+		removed, err := db.ClearAnalysisCache(r.URL.Query().Get("analysis_type"))
+		if err != nil {
+			log.Printf("Error clearing analysis cache: %v", err)
+			http.Error(w, "Failed to clear cache", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"removed": removed})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/models"
+)
+
+// unratedAnalyses are the analysis types that build on earlier results but whose model
+// reports no confidence, so their responses carry a fixed one
+var unratedAnalyses = map[string]bool{
+	"recommendations": true,
+	"plan":            true,
+}
+
+// runChainStep runs one step of a chain like an analysis of its type, with its attribute
+// sets, PII redaction, prompt templates and normalized results. Nothing is stored here:
+// chains store the results of their steps once the chain has finished.
+func (h *AnalysisHandler) runChainStep(ctx context.Context, step string, parameters map[string]interface{}, input interface{}) (interface{}, float64, error) {
+	runAnalysis := h.analysisRunner(step)
+	if runAnalysis == nil {
+		return nil, 0, fmt.Errorf("%w: %s", analysis.ErrInvalidAnalysisType, step)
+	}
+
+	resp, err := runAnalysis(ctx, chainStepRequest(step, parameters, input))
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.Error != nil {
+		return nil, 0, analysis.ResponseError(resp.Error)
+	}
+
+	// Later steps, suggestions and stored results read the results as decoded JSON
+	var results interface{}
+	if err := decodeValue(resp.Results, &results); err != nil {
+		return nil, 0, fmt.Errorf("failed to encode %s results: %w", step, err)
+	}
+
+	// A fixed confidence would mask that of the steps it builds on, so these steps report
+	// none and take theirs
+	if unratedAnalyses[step] {
+		return results, 0, nil
+	}
+	return results, resp.Confidence, nil
+}
+
+// chainStepRequest builds the analysis request of a chain step. The text of the chain
+// input is analyzed, and the results of earlier steps are passed as data: an object as
+// is, other results as data.results.
+func chainStepRequest(step string, parameters map[string]interface{}, input interface{}) models.StandardAnalysisRequest {
+	req := models.StandardAnalysisRequest{AnalysisType: step, Parameters: parameters}
+	if input == nil {
+		return req
+	}
+
+	var data map[string]interface{}
+	if err := decodeValue(input, &data); err != nil || data == nil {
+		data = map[string]interface{}{"results": input}
+	}
+	if text, ok := data["text"].(string); ok {
+		req.Text = text
+		delete(data, "text")
+	}
+	if len(data) > 0 {
+		req.Data = data
+	}
+	return req
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// defaultSignificanceLevel is the p-value below which a cohort difference is significant
+const defaultSignificanceLevel = 0.05
+
+// handleCompareAnalysis handles comparison requests: data.cohorts holds two labeled
+// cohorts, each given as conversations like data.conversations or as the
+// conversation_ids of stored conversations
+func (h *AnalysisHandler) handleCompareAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+	cohorts, err := requestCohorts(auth.TenantID(ctx), req)
+	if err != nil {
+		return nil, err
+	}
+
+	focusAreas := stringList(req.Parameters["focus_areas"])
+	if len(focusAreas) == 0 {
+		focusAreas = defaultFocusAreas
+	}
+
+	significanceLevel := defaultSignificanceLevel
+	if param, ok := req.Parameters["significance_level"]; ok {
+		level, ok := param.(float64)
+		if !ok || level <= 0 || level >= 1 {
+			return nil, fmt.Errorf("significance_level must be a number between 0 and 1")
+		}
+		significanceLevel = level
+	}
+
+	result, err := h.analysisFacade.CompareCohorts(ctx, cohorts, focusAreas, significanceLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare cohorts: %w", err)
+	}
+
+	// Use the model's confidence in the trend differences when it reports one
+	confidence := 0.8
+	if len(result.TrendDifferences) > 0 {
+		total := 0.0
+		for _, difference := range result.TrendDifferences {
+			total += difference.Confidence
+		}
+		if total > 0 {
+			confidence = total / float64(len(result.TrendDifferences))
+		}
+	}
+
+	return &models.StandardAnalysisResponse{
+		AnalysisType: "compare",
+		WorkflowID:   req.WorkflowID,
+		Timestamp:    time.Now(),
+		Results:      result,
+		Confidence:   confidence,
+	}, nil
+}
+
+// requestCohorts reads the two cohorts of a comparison request, whose stored
+// conversations are those of the tenant
+func requestCohorts(tenantID string, req models.StandardAnalysisRequest) ([]models.Cohort, error) {
+	items, ok := req.Data["cohorts"].([]interface{})
+	if !ok || len(items) != 2 {
+		return nil, fmt.Errorf("data.cohorts must hold exactly two cohorts for compare analysis")
+	}
+
+	role, err := speakerRole(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	cohorts := make([]models.Cohort, len(items))
+	for i, item := range items {
+		fields, _ := item.(map[string]interface{})
+		label, _ := fields["label"].(string)
+		label = strings.TrimSpace(label)
+		if label == "" {
+			return nil, fmt.Errorf("cohort %d has no label", i+1)
+		}
+		if i == 1 && label == cohorts[0].Label {
+			return nil, fmt.Errorf("cohort labels must differ")
+		}
+
+		conversations, err := cohortConversations(tenantID, fields)
+		if err != nil {
+			return nil, fmt.Errorf("cohort %s: %w", label, err)
+		}
+		for j := range conversations {
+			if conversations[j].Text, err = speakerTurnsText(conversations[j].Text, role); err != nil {
+				return nil, fmt.Errorf("cohort %s, conversation %d: %w", label, j+1, err)
+			}
+		}
+		cohorts[i] = models.Cohort{Label: label, Conversations: conversations}
+	}
+	return cohorts, nil
+}
+
+// cohortConversations reads the conversations of a cohort, given as texts or as the IDs
+// of stored conversations of the tenant
+func cohortConversations(tenantID string, fields map[string]interface{}) ([]models.ConversationText, error) {
+	items, _ := fields["conversations"].([]interface{})
+	ids := stringList(fields["conversation_ids"])
+	switch {
+	case len(items) > 0 && len(ids) > 0:
+		return nil, fmt.Errorf("conversations and conversation_ids cannot both be set")
+	case len(items) > maxRequestConversations || len(ids) > maxRequestConversations:
+		return nil, fmt.Errorf("%w: at most %d conversations are allowed per cohort", analysis.ErrDataTooLarge, maxRequestConversations)
+	case len(items) > 0:
+		conversations := make([]models.ConversationText, len(items))
+		for i, item := range items {
+			text, id := itemText(item, "text")
+			if text == "" {
+				return nil, fmt.Errorf("conversation %d has no text", i+1)
+			}
+			conversations[i] = models.ConversationText{ConversationID: id, Text: text}
+		}
+		return conversations, nil
+	case len(ids) > 0:
+		stored, err := db.GetConversations(tenantID, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load conversations: %w", err)
+		}
+		if len(stored) < len(ids) {
+			return nil, fmt.Errorf("%d of %d conversations were not found", len(ids)-len(stored), len(ids))
+		}
+		if err := requireConversationText(stored); err != nil {
+			return nil, err
+		}
+		conversations := make([]models.ConversationText, len(stored))
+		for i, conversation := range stored {
+			conversations[i] = models.ConversationText{ConversationID: conversation.ID, Text: conversation.Text}
+		}
+		return conversations, nil
+	default:
+		return nil, fmt.Errorf("conversations or conversation_ids is required")
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/dedup"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// handleDedupAnalysis reports the clusters of duplicate and near-duplicate conversations
+// among the stored conversations of conversation_ids or the items of data.conversations.
+// The threshold parameter is the similarity, from 0 to 1, at which conversations count
+// as duplicates. No model is called.
+func (h *AnalysisHandler) handleDedupAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+	options, err := dedupOptions(req.Parameters["threshold"], req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []dedup.Document
+	if len(req.ConversationIDs) > 0 {
+		if documents, err = storedDocuments(auth.TenantID(ctx), req.ConversationIDs, req.Parameters); err != nil {
+			return nil, err
+		}
+	} else {
+		conversations, err := requestConversations(req)
+		if err != nil {
+			return nil, err
+		}
+		for i, conversation := range conversations {
+			documents = append(documents, dedup.Document{ID: itemID(conversation.ID, i), Text: conversation.Text})
+		}
+	}
+	if len(documents) < 2 {
+		return nil, fmt.Errorf("dedup analysis needs at least two conversations in conversation_ids or data.conversations")
+	}
+
+	result := dedup.Find(documents, options)
+	return &models.StandardAnalysisResponse{
+		AnalysisType: "dedup",
+		WorkflowID:   req.WorkflowID,
+		Timestamp:    time.Now(),
+		Results:      result,
+		Confidence:   1,
+	}, nil
+}
+
+// dedupOptions reads the similarity threshold of duplicate detection, which is true for
+// the default threshold or a number between 0 and 1, and the shingle_size parameter
+func dedupOptions(threshold interface{}, parameters map[string]interface{}) (dedup.Options, error) {
+	options := dedup.Options{Threshold: dedup.DefaultThreshold, ShingleSize: dedup.DefaultShingleSize}
+	switch value := threshold.(type) {
+	case nil, bool:
+	case float64:
+		if value <= 0 || value > 1 {
+			return options, fmt.Errorf("the duplicate threshold must be a number between 0 and 1")
+		}
+		options.Threshold = value
+	default:
+		return options, fmt.Errorf("the duplicate threshold must be a number between 0 and 1")
+	}
+
+	if value, ok := parameters["shingle_size"]; ok {
+		size, ok := value.(float64)
+		if !ok || size != float64(int(size)) || size < 1 {
+			return options, fmt.Errorf("shingle_size must be a positive integer")
+		}
+		options.ShingleSize = int(size)
+	}
+	return options, nil
+}
+
+// dedupRequest drops duplicate conversations from a request before it is analyzed when
+// its dedup parameter is set, keeping the first conversation of each cluster. Stored
+// conversations are compared in their translation if the request is translated. It
+// returns the duplicates found, or nil when the request is not deduplicated.
+func dedupRequest(tenantID string, req *models.StandardAnalysisRequest) (*dedup.Result, error) {
+	value, ok := req.Parameters["dedup"]
+	if !ok || value == false || strings.EqualFold(req.AnalysisType, "dedup") {
+		return nil, nil
+	}
+	options, err := dedupOptions(value, req.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("dedup must be true or a similarity threshold between 0 and 1")
+	}
+
+	if len(req.ConversationIDs) > 0 {
+		documents, err := storedDocuments(tenantID, req.ConversationIDs, req.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		result := dedup.Find(documents, options)
+		duplicates := result.DuplicateIDs()
+		var kept []string
+		for _, id := range req.ConversationIDs {
+			if !duplicates[id] {
+				kept = append(kept, id)
+			}
+		}
+		req.ConversationIDs = kept
+		return &result, nil
+	}
+
+	items, ok := req.Data["conversations"].([]interface{})
+	if !ok || len(items) < 2 {
+		return nil, nil
+	}
+	documents := make([]dedup.Document, len(items))
+	for i, item := range items {
+		text, id := itemText(item, "text")
+		documents[i] = dedup.Document{ID: itemID(id, i), Text: text}
+	}
+	result := dedup.Find(documents, options)
+	duplicates := result.DuplicateIDs()
+	var kept []interface{}
+	for i, item := range items {
+		if !duplicates[documents[i].ID] {
+			kept = append(kept, item)
+		}
+	}
+	req.Data["conversations"] = kept
+	return &result, nil
+}
+
+// storedDocuments loads stored conversations of a tenant for duplicate detection, in
+// their translation if the request is translated
+func storedDocuments(tenantID string, ids []string, parameters map[string]interface{}) ([]dedup.Document, error) {
+	conversations, err := db.GetConversations(tenantID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversations: %w", err)
+	}
+	if conversations, err = withTranslations(conversations, parameters); err != nil {
+		return nil, err
+	}
+
+	// Keep the order of the request so the first of duplicates is kept
+	byID := make(map[string]string, len(conversations))
+	for _, conversation := range conversations {
+		byID[conversation.ID] = conversation.Text
+	}
+	var documents []dedup.Document
+	for _, id := range ids {
+		if text, ok := byID[id]; ok {
+			documents = append(documents, dedup.Document{ID: id, Text: text})
+		}
+	}
+	return documents, nil
+}
+
+// itemID identifies an item of data.conversations by its id or its 1-based position
+func itemID(id string, index int) string {
+	if id != "" {
+		return id
+	}
+	return strconv.Itoa(index + 1)
+}
+
+// withDuplicates reports the duplicates dropped from a request in the response of its
+// analysis
+func withDuplicates(runAnalysis analysisFunc, duplicates *dedup.Result) analysisFunc {
+	if duplicates == nil {
+		return runAnalysis
+	}
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		resp, err := runAnalysis(ctx, req)
+		if resp != nil {
+			resp.Duplicates = duplicates
+			if duplicates.Duplicates > 0 {
+				resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+					fmt.Sprintf("%d duplicate conversations were excluded from the analysis", duplicates.Duplicates))
+			}
+		}
+		return resp, err
+	}
+}
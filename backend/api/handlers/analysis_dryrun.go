@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+)
+
+// dryRunRequested reads the dry_run parameter, which asks for the prompts of an analysis
+// and their estimated cost instead of its results
+func dryRunRequested(parameters map[string]interface{}) (bool, error) {
+	value, ok := parameters["dry_run"]
+	if !ok {
+		return false, nil
+	}
+	dryRun, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("dry_run must be a boolean")
+	}
+	return dryRun, nil
+}
+
+// withDryRun runs an analysis without sending its prompts to the model. Model calls are
+// answered by the mock provider, so analyses of several calls build every prompt, and
+// the response reports the prompts and their estimated cost in place of results.
+// Nothing the analysis extracts is persisted, so requests are dry run with persist off.
+func withDryRun(runAnalysis analysisFunc, notes []string) analysisFunc {
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		ctx, dryRun := core.WithDryRun(ctx)
+		parameters := make(map[string]interface{}, len(req.Parameters)+1)
+		for key, value := range req.Parameters {
+			parameters[key] = value
+		}
+		parameters["persist"] = false
+		req.Parameters = parameters
+
+		resp, err := runAnalysis(ctx, req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+		resp.Results = nil
+		resp.Confidence = 0
+		resp.Reliability = nil
+		resp.DryRun = dryRunReport(dryRun.Prompts(), notes)
+		return resp, nil
+	}
+}
+
+// dryRunChain runs a chain without sending its prompts to the model and returns the
+// prompts of its steps with their estimated cost, and the levels its steps run in.
+// Neither the run nor its usage is recorded.
+func (h *AnalysisHandler) dryRunChain(ctx context.Context, inputData, config map[string]interface{}) (*models.DryRunReport, interface{}, error) {
+	stepConfig, _ := config["step_config"].(map[string]interface{})
+	ctx, err := chainStepContext(ctx, stepConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, dryRun := core.WithDryRun(ctx)
+	results, err := h.analysisFacade.ChainAnalysis(ctx, inputData, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dryRunReport(dryRun.Prompts(), nil), results["execution_levels"], nil
+}
+
+// dryRunReport totals the prompts of a dry run and prices them
+func dryRunReport(prompts []core.DryRunPrompt, notes []string) *models.DryRunReport {
+	report := &models.DryRunReport{Prompts: []models.DryRunPrompt{}, Currency: "USD", Notes: notes}
+	for _, prompt := range prompts {
+		report.Prompts = append(report.Prompts, models.DryRunPrompt{
+			Step:             prompt.Step,
+			Schema:           prompt.Schema,
+			Prompt:           prompt.Prompt,
+			PromptTokens:     prompt.PromptTokens,
+			CompletionTokens: prompt.CompletionTokens,
+		})
+		report.Calls++
+		report.PromptTokens += prompt.PromptTokens
+		report.CompletionTokens += prompt.CompletionTokens
+	}
+	report.EstimatedCost = tokenPrices().tokensCost(report.PromptTokens, 0, report.CompletionTokens)
+	return report
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/demo"
+	"agenticflows/backend/privacy"
+)
+
+// Explanation limits
+const (
+	defaultExplainExcerpts  = 5
+	maxExplainExcerpts      = 20
+	maxExplainConversations = 20
+)
+
+// explainResponse is the explanation of one item of a stored result
+type explainResponse struct {
+	ResultID     string      `json:"result_id"`
+	WorkflowID   string      `json:"workflow_id"`
+	AnalysisType string      `json:"analysis_type"`
+	Item         string      `json:"item"`
+	ItemValue    interface{} `json:"item_value"`
+	*models.Explanation
+	SourceConversations []string `json:"source_conversations"`
+	DataQuality         struct {
+		Limitations []string `json:"limitations,omitempty"`
+	} `json:"data_quality"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HandleExplain handles POST /api/analysis/explain, explaining one item of a stored
+// result in depth with supporting excerpts from its source conversations
+func (h *AnalysisHandler) HandleExplain(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendAnalysisError(w, "invalid_request", fmt.Sprintf("Invalid request format: %s", err), http.StatusBadRequest)
+		return
+	}
+	if req.ResultID == "" || req.Item == "" {
+		sendAnalysisError(w, "invalid_request", "result_id and item are required", http.StatusBadRequest)
+		return
+	}
+
+	maxExcerpts := req.MaxExcerpts
+	if maxExcerpts <= 0 {
+		maxExcerpts = defaultExplainExcerpts
+	}
+	if maxExcerpts > maxExplainExcerpts {
+		maxExcerpts = maxExplainExcerpts
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	stored, err := db.GetAnalysisResult(tenantID, req.ResultID)
+	if err != nil {
+		sendAnalysisError(w, "not_found", fmt.Sprintf("Analysis result not found: %s", req.ResultID), http.StatusNotFound)
+		return
+	}
+	analysisType, _ := stored["analysis_type"].(string)
+
+	// Resolve the item in the results as clients see them, without suppressed small groups
+	results, _ := privacy.SuppressSmallGroups(stored["results"], privacy.MinGroupSize())
+	item, err := resultItem(results, req.Item)
+	if err != nil {
+		sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := explainResponse{
+		ResultID:     req.ResultID,
+		AnalysisType: analysisType,
+		Item:         req.Item,
+		ItemValue:    item,
+		Timestamp:    time.Now(),
+	}
+	resp.WorkflowID, _ = stored["workflow_id"].(string)
+
+	// Find the conversations the result was derived from
+	edges, err := db.GetLineageUpstream(tenantID, db.LineageRef{Type: strings.ToLower(analysisType), ID: req.ResultID})
+	if err != nil {
+		log.Printf("Error getting lineage of result %s: %v", req.ResultID, err)
+	}
+	resp.SourceConversations = sourceConversations(edges)
+
+	conversations, err := explainConversations(tenantID, req, resp.SourceConversations)
+	if err != nil {
+		log.Printf("Error reading source conversations of result %s: %v", req.ResultID, err)
+		resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+			fmt.Sprintf("Source conversations could not be read: %s", err))
+	} else if len(conversations) == 0 {
+		resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+			"No source conversation text was available, so no excerpts could be quoted")
+	}
+
+	explanation, err := h.analysisFacade.ExplainResultItem(r.Context(), analysisType, item, conversations, maxExcerpts)
+	if err != nil {
+		log.Printf("Error explaining %s of result %s: %v", req.Item, req.ResultID, err)
+		sendAnalysisFailure(w, err)
+		return
+	}
+	resp.Explanation = explanation
+
+	if err := json.NewEncoder(w).Encode(pseudonymizeResponse(auth.TenantID(r.Context()), resp)); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// resultItem returns the value at a path such as "trends[0]" or "findings.2.answer" within results
+func resultItem(results interface{}, path string) (interface{}, error) {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	current := results
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		switch v := current.(type) {
+		case map[string]interface{}:
+			value, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("item %q not found: no field %q", path, key)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("item %q not found: index %q out of range", path, key)
+			}
+			current = v[index]
+		default:
+			return nil, fmt.Errorf("item %q not found: %q is not an object or array", path, key)
+		}
+	}
+	return current, nil
+}
+
+// explainConversations returns the text of the source conversations, taken from the
+// request or read from the conversations database of the tenant, limited to
+// maxExplainConversations
+func explainConversations(tenantID string, req models.ExplainRequest, sourceIDs []string) ([]models.ConversationText, error) {
+	if len(req.Conversations) > 0 {
+		// Prefer the supplied conversations that the result was derived from
+		sources := make(map[string]bool, len(sourceIDs))
+		for _, id := range sourceIDs {
+			sources[id] = true
+		}
+		var matched []models.ConversationText
+		for _, conversation := range req.Conversations {
+			if sources[conversation.ConversationID] && conversation.Text != "" {
+				matched = append(matched, conversation)
+			}
+		}
+		if len(matched) == 0 {
+			matched = req.Conversations
+		}
+		if len(matched) > maxExplainConversations {
+			matched = matched[:maxExplainConversations]
+		}
+		return matched, nil
+	}
+
+	// The demo only reads the synthetic dataset, never a database path from the request
+	if demo.Enabled() {
+		var conversations []models.ConversationText
+		for _, conversation := range demo.ConversationsByID(sourceIDs) {
+			conversations = append(conversations, models.ConversationText{ConversationID: conversation.ID, Text: conversation.Text})
+		}
+		return conversations, nil
+	}
+
+	if len(sourceIDs) == 0 {
+		return nil, nil
+	}
+	if len(sourceIDs) > maxExplainConversations {
+		sourceIDs = sourceIDs[:maxExplainConversations]
+	}
+	if req.DatabasePath != "" {
+		return getConversationTextsFromDB(req.DatabasePath, sourceIDs)
+	}
+
+	// Fall back to the conversations ingested through /api/conversations
+	stored, err := db.GetConversations(tenantID, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	conversations := make([]models.ConversationText, 0, len(stored))
+	for _, conversation := range stored {
+		conversations = append(conversations, models.ConversationText{ConversationID: conversation.ID, Text: conversation.Text})
+	}
+	return conversations, nil
+}
+
+// getConversationTextsFromDB reads the text of the given conversations from a conversations database
+func getConversationTextsFromDB(dbPath string, ids []string) ([]models.ConversationText, error) {
+	sqliteDB, err := db.SharedReader(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %s", err)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := sqliteDB.Query("SELECT conversation_id, text FROM conversations WHERE conversation_id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversations: %s", err)
+	}
+	defer rows.Close()
+
+	var conversations []models.ConversationText
+	for rows.Next() {
+		var conversation models.ConversationText
+		if err := rows.Scan(&conversation.ConversationID, &conversation.Text); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %s", err)
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %s", err)
+	}
+
+	return conversations, nil
+}
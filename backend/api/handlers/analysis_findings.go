@@ -2,26 +2,169 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"agenticflows/backend/analysis/models"
 )
 
-// handleFindingsAnalysis handles findings analysis requests
+// handleFindingsAnalysis handles findings analysis requests: it answers the questions
+// parameter from the data and text, with a coverage matrix of the questions the data
+// answers. Unless generate_missing_attributes is false, attributes are defined for what
+// the data is missing, ready for the next attribute extraction.
 func (h *AnalysisHandler) handleFindingsAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
-	// This is a temporary implementation until findings analysis is fully refactored
-	return &models.StandardAnalysisResponse{
+	questions := stringList(req.Parameters["questions"])
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("questions are required for findings analysis")
+	}
+
+	attributes := dataAttributeNames(req.Data)
+	for _, name := range stringList(req.Parameters["existing_attributes"]) {
+		if !slices.Contains(attributes, name) {
+			attributes = append(attributes, name)
+		}
+	}
+
+	result, err := h.analysisFacade.AnalyzeFindings(ctx, models.AnalysisRequest{
+		Text:            req.Text,
+		Questions:       questions,
+		AttributeValues: req.Data,
+	}, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze findings: %w", err)
+	}
+
+	resp := &models.StandardAnalysisResponse{
 		AnalysisType: "findings",
 		WorkflowID:   req.WorkflowID,
 		Timestamp:    time.Now(),
-		Results:      map[string]interface{}{"message": "Findings analysis has not yet been refactored"},
-		Confidence:   0.0,
-		Error: &models.AnalysisError{
-			Code:    "not_implemented",
-			Message: "Findings analysis has not yet been refactored",
-			Details: "Please check back later when the refactoring is complete",
-		},
-	}, nil
+		Results:      result,
+		Confidence:   result.AverageConfidence(),
+	}
+
+	// Only conversations of the submitted data can be cited
+	dropped, uncited := result.ValidateCitations(citableConversationIDs(req.Data))
+	if dropped > 0 {
+		resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+			fmt.Sprintf("%d citations of conversations that are not in the data were removed", dropped))
+	}
+	if len(uncited) > 0 {
+		resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+			fmt.Sprintf("%d answers cite no conversation of the data: %s", len(uncited), strings.Join(uncited, "; ")))
+	}
+
+	generate, ok := req.Parameters["generate_missing_attributes"].(bool)
+	if incomplete := result.Coverage.Incomplete(); len(incomplete) > 0 && (generate || !ok) {
+		// Name the missing attributes with each question so the definitions match them
+		needs := make([]string, len(incomplete))
+		for i, question := range incomplete {
+			needs[i] = question.Question
+			if len(question.MissingAttributes) > 0 {
+				needs[i] += " (missing: " + strings.Join(question.MissingAttributes, ", ") + ")"
+			}
+		}
+		next, err := h.analysisFacade.GenerateRequiredAttributes(ctx, needs, attributes)
+		if err != nil {
+			log.Printf("Error generating missing attributes: %v", err)
+			resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+				fmt.Sprintf("Attributes for the %d questions the data does not fully answer could not be generated", len(incomplete)))
+		} else {
+			result.Coverage.NextAttributes = next
+		}
+	}
+	return resp, nil
+}
+
+// citableConversationIDs returns the IDs of the conversations in analysis data: the
+// conversation_id fields of its records, their conversation_ids and source_conversations
+// lists, and the IDs of data.conversations
+func citableConversationIDs(data map[string]interface{}) map[string]bool {
+	ids := map[string]bool{}
+	var walk func(value interface{}, depth int)
+	walk = func(value interface{}, depth int) {
+		if depth > 6 {
+			return
+		}
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				walk(item, depth+1)
+			}
+		case map[string]interface{}:
+			for key, field := range v {
+				switch key {
+				case "conversation_id":
+					if id, ok := field.(string); ok && id != "" {
+						ids[id] = true
+					}
+				case "conversation_ids", "source_conversations":
+					for _, id := range stringList(field) {
+						ids[id] = true
+					}
+				default:
+					walk(field, depth+1)
+				}
+			}
+		}
+	}
+	walk(data, 0)
+
+	if conversations, ok := data["conversations"].([]interface{}); ok {
+		for _, item := range conversations {
+			if _, id := itemText(item, "text"); id != "" {
+				ids[id] = true
+			}
+		}
+	}
+	return ids
+}
+
+// dataAttributeNames returns the attributes present in analysis data: the field names of
+// attribute definitions and values, and the fields of records
+func dataAttributeNames(data map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	var walk func(value interface{}, depth int)
+	walk = func(value interface{}, depth int) {
+		if depth > 3 {
+			return
+		}
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				walk(item, depth+1)
+			}
+		case map[string]interface{}:
+			if name, ok := v["field_name"].(string); ok {
+				add(name)
+				return
+			}
+			for key, field := range v {
+				switch field.(type) {
+				case []interface{}, map[string]interface{}:
+					walk(field, depth+1)
+				default:
+					if depth > 0 && key != "id" && key != "conversation_id" {
+						add(key)
+					}
+				}
+			}
+		}
+	}
+	walk(data, 0)
+	sort.Strings(names)
+	return names
 }
 
 // handleIntentAnalysis handles intent analysis requests
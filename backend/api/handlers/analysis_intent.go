@@ -3,38 +3,239 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
+	"agenticflows/backend/analysis"
 	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
 )
 
+// intentModeBulk classifies many conversations with a bounded worker pool, reporting the
+// ones that fail instead of failing the request
+const intentModeBulk = "bulk"
+
+// maxBulkConversations is the most conversations a bulk intent request accepts
+const maxBulkConversations = 5000
+
 // handleIntentAnalysisImpl implements the actual intent analysis logic
 func (h *AnalysisHandler) handleIntentAnalysisImpl(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+	switch mode, _ := req.Parameters["mode"].(string); mode {
+	case "":
+	case intentModeBulk:
+		return h.handleIntentBulk(ctx, req)
+	default:
+		return nil, fmt.Errorf("unknown intent mode %q", mode)
+	}
+
+	conversations, err := requestConversations(req)
+	if err != nil {
+		return nil, err
+	}
+	if conversations != nil {
+		return h.handleIntentConversations(ctx, req, conversations)
+	}
+
 	// Validate request
 	if req.Text == "" {
-		return nil, fmt.Errorf("text is required for intent analysis")
+		return nil, fmt.Errorf("text or data.conversations is required for intent analysis")
+	}
+
+	text, err := speakerTurnsFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The conversation the text is, to persist its intent
+	conversationID, _ := req.Parameters["conversation_id"].(string)
+	if conversationID == "" && len(req.ConversationIDs) == 1 {
+		conversationID = req.ConversationIDs[0]
+	}
+	persist, err := persistAttributes(req.Parameters, conversationID != "")
+	if err != nil {
+		return nil, err
+	}
+	if persist && conversationID == "" {
+		return nil, fmt.Errorf("conversation_id is required to persist the intent of text")
 	}
 
 	// Process the intent generation
-	intent, err := h.textGenerator.GenerateIntent(ctx, req.Text)
+	intent, err := h.textGenerator.GenerateIntent(ctx, text)
 	if err != nil {
 		return nil, err
 	}
 
+	result := &analysis.IntentResult{IntentClassification: *intent}
+	if persist {
+		classified := []models.ConversationIntent{{ConversationID: conversationID, IntentClassification: *intent}}
+		if result.Persisted, err = db.SaveIntentClassifications(auth.TenantID(ctx), intentClassifications(req.WorkflowID, classified)); err != nil {
+			return nil, fmt.Errorf("failed to save intent classification: %w", err)
+		}
+	}
+
 	// Return generated intent in standard response
 	resp := &models.StandardAnalysisResponse{
 		AnalysisType: "intent",
 		WorkflowID:   req.WorkflowID,
 		Timestamp:    time.Now(),
-		Results:      intent,
+		Results:      result,
 		Confidence:   0.85,
 	}
 
 	return resp, nil
 }
 
+// handleIntentConversations classifies the intent of each conversation of a request,
+// several conversations per LLM call
+func (h *AnalysisHandler) handleIntentConversations(ctx context.Context, req models.StandardAnalysisRequest, conversations []requestConversation) (*models.StandardAnalysisResponse, error) {
+	persist, err := persistAttributes(req.Parameters, true)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.analysisFacade.GenerateIntents(ctx, conversationTexts(conversations))
+	if err != nil {
+		return nil, err
+	}
+	for i, conversation := range conversations {
+		result.Conversations[i].ConversationID = conversation.ID
+	}
+	if persist {
+		result.Persisted = saveConversationIntents(auth.TenantID(ctx), req.WorkflowID, result.Conversations)
+	}
+
+	return &models.StandardAnalysisResponse{
+		AnalysisType: "intent",
+		WorkflowID:   req.WorkflowID,
+		Timestamp:    time.Now(),
+		Results:      result,
+		Confidence:   0.85,
+	}, nil
+}
+
 // handleIntentAnalysis is kept for backward compatibility - delegates to the actual implementation
 func (h *AnalysisHandler) handleIntentAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
 	// This method is required to be compatible with the handler framework in analysis_base.go
 	return h.handleIntentAnalysisImpl(ctx, req)
 }
+
+// handleIntentBulk classifies each conversation of data.conversations in bulk mode. Up to
+// the concurrency parameter LLM calls run at once, each packing several conversations.
+// Conversations without text or that fail are reported with their error, and the response
+// is partial; the request only fails if none is classified.
+func (h *AnalysisHandler) handleIntentBulk(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+	items, ok := req.Data["conversations"].([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("data.conversations must be a non-empty array in bulk mode")
+	}
+	if len(items) > maxBulkConversations {
+		return nil, fmt.Errorf("data.conversations holds %d conversations; at most %d are allowed in bulk mode", len(items), maxBulkConversations)
+	}
+	if req.Text != "" {
+		return nil, fmt.Errorf("text cannot be set in bulk mode")
+	}
+	role, err := speakerRole(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	persist, err := persistAttributes(req.Parameters, true)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := analysis.DefaultFanOutConcurrency
+	if n, ok := req.Parameters["concurrency"].(float64); ok && n > 0 {
+		concurrency = min(int(n), maxFanOutConcurrency)
+	}
+
+	// Conversations that cannot be read are reported without being sent to the model
+	conversations := make([]models.ConversationIntent, len(items))
+	var valid []models.ConversationText
+	var positions []int
+	for i, item := range items {
+		text, id := itemText(item, "text")
+		conversations[i].ConversationID = id
+		if text == "" {
+			conversations[i].Error, conversations[i].ErrorCode = "conversation has no text", analysis.CodeInvalidRequest
+			continue
+		}
+		if text, err = speakerTurnsText(text, role); err != nil {
+			conversations[i].Error, conversations[i].ErrorCode = err.Error(), analysis.CodeInvalidRequest
+			continue
+		}
+		valid = append(valid, models.ConversationText{ConversationID: id, Text: text})
+		positions = append(positions, i)
+	}
+
+	result := &analysis.IntentResult{}
+	if len(valid) > 0 {
+		result = h.analysisFacade.GenerateIntentsBulk(ctx, valid, concurrency)
+		for j, i := range positions {
+			conversations[i] = result.Conversations[j]
+		}
+	}
+	result.Conversations = conversations
+	result.Failed = 0
+	var failures []models.AnalysisError
+	for i, conversation := range conversations {
+		if conversation.Error != "" {
+			result.Failed++
+			failures = append(failures, analysis.ItemError(i, conversation.ConversationID, conversation.ErrorCode, conversation.Error))
+		}
+	}
+	if result.Failed == len(conversations) {
+		last := conversations[len(conversations)-1]
+		return nil, &analysis.CodedError{Code: last.ErrorCode, Message: "no conversation could be classified: " + last.Error}
+	}
+	if err := h.withIntentGroups(ctx, req.Parameters, result); err != nil {
+		return nil, err
+	}
+	if persist {
+		result.Persisted = saveConversationIntents(auth.TenantID(ctx), req.WorkflowID, conversations)
+	}
+
+	resp := &models.StandardAnalysisResponse{
+		AnalysisType: "intent",
+		WorkflowID:   req.WorkflowID,
+		Timestamp:    time.Now(),
+		Results:      result,
+		Confidence:   0.85,
+		Partial:      len(failures) > 0,
+		Errors:       failures,
+	}
+	if result.Failed > 0 {
+		resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+			fmt.Sprintf("%d of %d conversations could not be classified and are not included in the distribution; see errors", result.Failed, len(conversations)))
+	}
+	return resp, nil
+}
+
+// saveConversationIntents saves the intents of the classified conversations that have an
+// ID to their intent history, returning how many were saved. Failures are logged, so a
+// classification is not lost to a storage error.
+func saveConversationIntents(tenantID, workflowID string, conversations []models.ConversationIntent) int {
+	saved, err := db.SaveIntentClassifications(tenantID, intentClassifications(workflowID, conversations))
+	if err != nil {
+		log.Printf("Error saving intent classifications: %v", err)
+	}
+	return saved
+}
+
+// intentClassifications converts the intents of classified conversations to history rows,
+// skipping conversations that could not be classified
+func intentClassifications(workflowID string, conversations []models.ConversationIntent) []db.IntentClassification {
+	classifications := make([]db.IntentClassification, 0, len(conversations))
+	for _, conversation := range conversations {
+		if conversation.Error != "" {
+			continue
+		}
+		classifications = append(classifications, db.IntentClassification{
+			ConversationID: conversation.ConversationID,
+			WorkflowID:     workflowID,
+			Label:          conversation.Label,
+			LabelName:      conversation.LabelName,
+			Description:    conversation.Description,
+		})
+	}
+	return classifications
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/cluster"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// Background job settings
+const (
+	envJobWorkers     = "ANALYSIS_JOB_WORKERS"
+	defaultJobWorkers = 2
+	jobPollInterval   = 5 * time.Second
+)
+
+// jobQueue runs queued analysis jobs on a pool of workers. Several server instances may
+// share the queue: each claims jobs under its instance ID and reports in while running
+// them, and jobs of instances that stopped reporting in are requeued.
+type jobQueue struct {
+	handler  *AnalysisHandler
+	instance string
+	leaseTTL time.Duration // How long a running job may go without a heartbeat
+	wake     chan struct{}
+	claimMu  sync.Mutex // Serializes claims so SQLite sees one writer at a time
+}
+
+// startJobQueue requeues jobs interrupted by a restart and starts the worker pool
+func (h *AnalysisHandler) startJobQueue() *jobQueue {
+	config := cluster.ConfigFromEnv()
+	queue := &jobQueue{handler: h, instance: config.InstanceID, leaseTTL: config.LeaseTTL}
+	queue.requeueStale()
+
+	workers := defaultJobWorkers
+	if value := os.Getenv(envJobWorkers); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			workers = n
+		} else {
+			log.Printf("Warning: ignoring invalid %s value %q", envJobWorkers, value)
+		}
+	}
+
+	queue.wake = make(chan struct{}, workers)
+	for i := 0; i < workers; i++ {
+		go queue.work()
+	}
+	go queue.reap()
+	return queue
+}
+
+// requeueStale returns the jobs of this instance interrupted by a restart, and those of
+// instances that stopped, to the queue
+func (q *jobQueue) requeueStale() {
+	if n, err := db.RequeueStaleAnalysisJobs(q.instance, time.Now().Add(-q.leaseTTL)); err != nil {
+		log.Printf("Error requeueing interrupted analysis jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("Requeued %d interrupted analysis jobs", n)
+	}
+}
+
+// reap requeues the jobs of stopped instances until the server exits. Every instance
+// reaps, as requeueing is guarded by the heartbeat of the job.
+func (q *jobQueue) reap() {
+	for {
+		time.Sleep(q.leaseTTL)
+		if n, err := db.RequeueStaleAnalysisJobs("", time.Now().Add(-q.leaseTTL)); err != nil {
+			log.Printf("Error requeueing stale analysis jobs: %v", err)
+		} else if n > 0 {
+			log.Printf("Requeued %d analysis jobs of stopped instances", n)
+			q.notify()
+		}
+	}
+}
+
+// notify wakes an idle worker after a job is queued
+func (q *jobQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work processes jobs until the server exits
+func (q *jobQueue) work() {
+	for {
+		q.claimMu.Lock()
+		job, err := db.ClaimNextAnalysisJob(q.instance)
+		q.claimMu.Unlock()
+
+		if err != nil {
+			log.Printf("Error claiming analysis job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-q.wake:
+			case <-time.After(jobPollInterval):
+			}
+			continue
+		}
+
+		q.run(job)
+	}
+}
+
+// run executes one job and records its outcome. The job is canceled if it was requeued
+// in the meantime, which happens when this instance could not report in for too long.
+func (q *jobQueue) run(job *db.AnalysisJob) {
+	log.Printf("Running %s job %s (%s)", job.Kind, job.ID, job.AnalysisType)
+
+	// The job reads and stores the data of the tenant that submitted it
+	ctx, cancel := context.WithCancel(auth.WithTenant(context.Background(), job.TenantID))
+	defer cancel()
+	go q.heartbeat(ctx, cancel, job.ID)
+
+	result, err := q.execute(ctx, job)
+	if err != nil {
+		log.Printf("Analysis job %s failed: %v", job.ID, err)
+		if err := db.FailAnalysisJob(job.ID, q.instance, err); err != nil {
+			log.Printf("Error recording failure of analysis job %s: %v", job.ID, err)
+		}
+		return
+	}
+
+	if err := db.CompleteAnalysisJob(job.ID, q.instance, result); err != nil {
+		log.Printf("Error storing result of analysis job %s: %v", job.ID, err)
+	}
+}
+
+// heartbeat reports that this instance is still running a job until ctx is done, and
+// cancels the job when it was taken over by another instance
+func (q *jobQueue) heartbeat(ctx context.Context, cancel context.CancelFunc, id string) {
+	ticker := time.NewTicker(q.leaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		held, err := db.HeartbeatAnalysisJob(id, q.instance)
+		if err != nil {
+			log.Printf("Error recording heartbeat of analysis job %s: %v", id, err)
+			continue
+		}
+		if !held {
+			log.Printf("Analysis job %s was requeued; canceling it here", id)
+			cancel()
+			return
+		}
+	}
+}
+
+// execute runs the request stored in a job
+func (q *jobQueue) execute(ctx context.Context, job *db.AnalysisJob) (interface{}, error) {
+	h := q.handler
+	progress := func(completed, total int) {
+		if err := db.UpdateAnalysisJobProgress(job.ID, q.instance, db.JobProgress{Stage: db.JobRunning, Completed: completed, Total: total}); err != nil {
+			log.Printf("Error updating progress of analysis job %s: %v", job.ID, err)
+		}
+	}
+
+	switch job.Kind {
+	case db.JobKindBatch:
+		var req models.BatchAnalysisRequest
+		if err := json.Unmarshal(job.Request, &req); err != nil {
+			return nil, fmt.Errorf("invalid job request: %w", err)
+		}
+		return h.runBatchAnalysis(ctx, req, job.Actor, progress)
+
+	default:
+		var req models.StandardAnalysisRequest
+		if err := json.Unmarshal(job.Request, &req); err != nil {
+			return nil, fmt.Errorf("invalid job request: %w", err)
+		}
+		// LLM calls of a job are audited under its ID
+		ctx = core.WithRequestID(ctx, job.ID)
+		auditAnalysisRequest(ctx, job.Actor, req, "")
+		// Jobs are prepared like /api/analysis requests, as the job's tenant, since the
+		// run or conversations they reference may have changed since they were queued
+		analysisType, runAnalysis, err := h.prepareAnalysis(ctx, job.Actor, &req)
+		if err != nil {
+			return nil, err
+		}
+
+		progress(0, 1)
+		resp, err := runAnalysis(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error != nil {
+			return nil, analysis.ResponseError(resp.Error)
+		}
+		if err := h.completeAnalysis(job.TenantID, job.Actor, req, analysisType, resp); err != nil {
+			return nil, err
+		}
+		progress(1, 1)
+		return resp, nil
+	}
+}
+
+// HandleAnalysisJobs handles /api/analysis/jobs and /api/analysis/jobs/{id}
+func (h *AnalysisHandler) HandleAnalysisJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/analysis/jobs"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		h.submitAnalysisJob(w, r)
+
+	case r.Method == http.MethodGet && id == "":
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		jobs, err := db.ListAnalysisJobs(db.AnalysisJobFilter{
+			TenantID:   auth.TenantID(r.Context()),
+			WorkflowID: r.URL.Query().Get("workflow_id"),
+			Status:     r.URL.Query().Get("status"),
+			Limit:      limit,
+		})
+		if err != nil {
+			log.Printf("Error listing analysis jobs: %v", err)
+			http.Error(w, "Failed to list analysis jobs", http.StatusInternalServerError)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(jobs); err != nil {
+			log.Printf("Error encoding response: %v", err)
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+
+	case r.Method == http.MethodGet:
+		job, err := db.GetAnalysisJob(auth.TenantID(r.Context()), id)
+		if err != nil {
+			http.Error(w, "Analysis job not found", http.StatusNotFound)
+			return
+		}
+		job.Request = nil
+		if err := json.NewEncoder(w).Encode(job); err != nil {
+			log.Printf("Error encoding response: %v", err)
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// submitAnalysisJob validates and queues an analysis or batch request, returning the job ID
+func (h *AnalysisHandler) submitAnalysisJob(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendAnalysisError(w, "invalid_request", "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var envelope struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		sendAnalysisError(w, "invalid_request", fmt.Sprintf("Invalid request format: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	job := db.AnalysisJob{
+		ID:       uuid.New().String(),
+		Kind:     envelope.Kind,
+		Actor:    actorFromRequest(r),
+		TenantID: auth.TenantID(r.Context()),
+		Request:  json.RawMessage(body),
+	}
+
+	switch job.Kind {
+	case db.JobKindBatch:
+		var req models.BatchAnalysisRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			sendAnalysisError(w, "invalid_request", fmt.Sprintf("Invalid request format: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := h.validateBatchRequest(req); err != nil {
+			sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+			return
+		}
+		job.WorkflowID = req.WorkflowID
+		job.AnalysisType = strings.ToLower(req.AnalysisType)
+
+	case "", db.JobKindAnalysis:
+		var req models.StandardAnalysisRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			sendAnalysisError(w, "invalid_request", fmt.Sprintf("Invalid request format: %s", err), http.StatusBadRequest)
+			return
+		}
+		if h.analysisRunner(strings.ToLower(req.AnalysisType)) == nil {
+			sendAnalysisError(w, "invalid_analysis_type", "Invalid analysis type", http.StatusBadRequest)
+			return
+		}
+		if err := validateCostTags(req.Tags); err != nil {
+			sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateDefaultableParameters(req.Parameters); err != nil {
+			sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := analysisSamples(req); err != nil {
+			sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := requestLanguage(req); err != nil {
+			sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateAnalysisRun(job.TenantID, req.WorkflowID, req.RunID); err != nil {
+			sendAnalysisFailure(w, err)
+			return
+		}
+		if dryRun, _ := dryRunRequested(req.Parameters); dryRun {
+			sendAnalysisError(w, "invalid_request", "dry_run is not supported for jobs; send the request to /api/analysis", http.StatusBadRequest)
+			return
+		}
+		rule, _ := req.Parameters["confidence_propagation"].(string)
+		if _, err := core.ValidatePropagationRule(rule); err != nil {
+			sendAnalysisError(w, "invalid_request", err.Error(), http.StatusBadRequest)
+			return
+		}
+		job.Kind = db.JobKindAnalysis
+		job.WorkflowID = req.WorkflowID
+		job.AnalysisType = strings.ToLower(req.AnalysisType)
+
+	default:
+		sendAnalysisError(w, "invalid_request", fmt.Sprintf("Unknown job kind: %s", job.Kind), http.StatusBadRequest)
+		return
+	}
+
+	if err := db.CreateAnalysisJob(job); err != nil {
+		log.Printf("Error creating analysis job: %v", err)
+		sendAnalysisError(w, "internal_error", "Failed to queue analysis job", http.StatusInternalServerError)
+		return
+	}
+	h.jobs.notify()
+
+	created, err := db.GetAnalysisJob(job.TenantID, job.ID)
+	if err != nil {
+		log.Printf("Error reading analysis job %s: %v", job.ID, err)
+		sendAnalysisError(w, "internal_error", "Failed to read analysis job", http.StatusInternalServerError)
+		return
+	}
+	created.Request = nil
+
+	w.Header().Set("Location", "/api/analysis/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -1,8 +1,18 @@
 package handlers
 
-// getFunctionMetadata returns metadata for all available analysis functions
+import "agenticflows/backend/workflow"
+
+// summaryCharsParameter describes the length conversations are summarized to
+var summaryCharsParameter = map[string]interface{}{
+	"type":        "integer",
+	"description": "Length of each summary in characters, from 200 to 4000 (default 1000)",
+	"example":     800,
+}
+
+// getFunctionMetadata returns metadata for all available analysis functions and the
+// node types of the workflow node library
 func getFunctionMetadata() map[string]interface{} {
-	return map[string]interface{}{
+	metadata := map[string]interface{}{
 		"trends": map[string]interface{}{
 			"name":        "Trend Analysis",
 			"description": "Analyze trends in conversation data",
@@ -27,13 +37,17 @@ func getFunctionMetadata() map[string]interface{} {
 		},
 		"findings": map[string]interface{}{
 			"name":        "Findings Analysis",
-			"description": "Analyze findings from data",
+			"description": "Answer questions from data, with a coverage matrix of the questions the data answers fully, partially or not at all",
 			"parameters": map[string]interface{}{
 				"questions": map[string]interface{}{
 					"type":        "array",
 					"description": "Questions to answer based on the data",
 					"example":     []string{"What are the main customer pain points?", "How effective is the support team?"},
 				},
+				"generate_missing_attributes": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Whether to define the attributes missing to answer the questions the data does not fully answer (default true)",
+				},
 			},
 		},
 		"attributes": map[string]interface{}{
@@ -42,17 +56,86 @@ func getFunctionMetadata() map[string]interface{} {
 			"parameters": map[string]interface{}{
 				"attributes": map[string]interface{}{
 					"type":        "array",
-					"description": "Attributes to extract",
+					"description": "Attributes to extract; an attribute with a speaker of customer or agent is extracted from that speaker's turns only",
 				},
 				"generate_required": map[string]interface{}{
 					"type":        "boolean",
 					"description": "Whether to generate required attributes",
 				},
+				"speaker_role": map[string]interface{}{
+					"type":        "string",
+					"description": "Analyze only the turns of this speaker role: customer, agent or system",
+					"example":     "customer",
+				},
 			},
 		},
 		"intent": map[string]interface{}{
 			"name":        "Intent Analysis",
 			"description": "Analyze intents in conversation data",
+			"parameters": map[string]interface{}{
+				"mode": map[string]interface{}{
+					"type":        "string",
+					"description": "bulk classifies up to 5000 conversations in data.conversations with a bounded worker pool, reporting failures per conversation",
+					"example":     "bulk",
+				},
+				"concurrency": map[string]interface{}{
+					"type":        "integer",
+					"description": "LLM calls run at once in bulk mode (default 4, at most 16)",
+					"example":     8,
+				},
+				"persist": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Save the intents of conversations with an ID to their intent history (default true)",
+					"example":     false,
+				},
+				"conversation_id": map[string]interface{}{
+					"type":        "string",
+					"description": "The conversation text is, to save its intent",
+					"example":     "c-101",
+				},
+				"speaker_role": map[string]interface{}{
+					"type":        "string",
+					"description": "Analyze only the turns of this speaker role: customer, agent or system",
+					"example":     "customer",
+				},
+			},
+		},
+		"sentiment": map[string]interface{}{
+			"name":        "Sentiment Analysis",
+			"description": "Analyze overall, per-speaker and start/middle/end sentiment of a conversation",
+			"parameters": map[string]interface{}{
+				"speakers": map[string]interface{}{
+					"type":        "array",
+					"description": "Speaker labels to report sentiment for",
+					"example":     []string{"Customer", "Agent"},
+				},
+				"speaker_role": map[string]interface{}{
+					"type":        "string",
+					"description": "Analyze only the turns of this speaker role: customer, agent or system",
+					"example":     "customer",
+				},
+			},
+		},
+		"compare": map[string]interface{}{
+			"name":        "Cohort Comparison",
+			"description": "Compare trends, sentiment and resolution outcomes of two labeled cohorts of conversations in data.cohorts",
+			"parameters": map[string]interface{}{
+				"focus_areas": map[string]interface{}{
+					"type":        "array",
+					"description": "Areas to compare the trends of",
+					"example":     []string{"Customer Satisfaction", "Service Issues"},
+				},
+				"significance_level": map[string]interface{}{
+					"type":        "number",
+					"description": "P-value below which a difference is reported as significant (default 0.05)",
+					"example":     0.05,
+				},
+				"speaker_role": map[string]interface{}{
+					"type":        "string",
+					"description": "Analyze only the turns of this speaker role: customer, agent or system",
+					"example":     "customer",
+				},
+			},
 		},
 		"recommendations": map[string]interface{}{
 			"name":        "Recommendations",
@@ -63,6 +146,26 @@ func getFunctionMetadata() map[string]interface{} {
 					"description": "Objectives for recommendations",
 					"example":     []string{"Improve customer satisfaction", "Reduce response time"},
 				},
+				"scoring": map[string]interface{}{
+					"type":        "object",
+					"description": "Weights of the ranking criteria (impact, effort, dependencies, budget, llm_priority) and the budget the estimated costs must fit",
+					"example":     map[string]interface{}{"weights": map[string]float64{"impact": 0.5, "effort": 0.3}, "budget": 50000},
+				},
+				"audience": map[string]interface{}{
+					"type":        "string",
+					"description": "Audience the recommendations are written for: executive (business impact, cost and risk) or operational (concrete steps and roles)",
+					"example":     "executive",
+				},
+				"tone": map[string]interface{}{
+					"type":        "string",
+					"description": "Tone of the recommendations, e.g. formal or empathetic",
+					"example":     "formal",
+				},
+				"max_length": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of words of each description in the recommendations (1 to 500)",
+					"example":     40,
+				},
 			},
 		},
 		"plan": map[string]interface{}{
@@ -74,9 +177,127 @@ func getFunctionMetadata() map[string]interface{} {
 					"description": "Goals for the action plan",
 					"example":     []string{"Increase customer retention", "Improve service quality"},
 				},
+				"generate_timeline": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Generate a timeline for the action plan in data.action_plan instead of creating a plan",
+					"example":     true,
+				},
+				"audience": map[string]interface{}{
+					"type":        "string",
+					"description": "Audience the plan are written for: executive (business impact, cost and risk) or operational (concrete steps and roles)",
+					"example":     "executive",
+				},
+				"tone": map[string]interface{}{
+					"type":        "string",
+					"description": "Tone of the plan, e.g. formal or empathetic",
+					"example":     "formal",
+				},
+				"max_length": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of words of each description in the plan (1 to 500)",
+					"example":     40,
+				},
+				"calendar": map[string]interface{}{
+					"type":        "object",
+					"description": "Working calendar the timeline is scheduled on: start date, working days, holidays and the number of phases each resource can work on at once",
+					"example": map[string]interface{}{
+						"start_date": "2024-07-01",
+						"holidays":   []string{"2024-07-04"},
+						"capacity":   map[string]int{"engineering": 1},
+					},
+				},
+			},
+		},
+		"dedup": map[string]interface{}{
+			"name":        "Duplicate Detection",
+			"description": "Find duplicate and near-duplicate conversations",
+			"parameters": map[string]interface{}{
+				"threshold": map[string]interface{}{
+					"type":        "number",
+					"description": "Similarity from 0 to 1 at which conversations are duplicates",
+					"example":     0.85,
+				},
+				"shingle_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of words per shingle",
+					"example":     5,
+				},
+			},
+		},
+		"summarize": map[string]interface{}{
+			"name":        "Conversation Summaries",
+			"description": "Summarize each conversation, keeping amounts, dates and identifiers; summaries are cached",
+			"parameters": map[string]interface{}{
+				"summary_chars": summaryCharsParameter,
+			},
+		},
+		"anomalies": map[string]interface{}{
+			"name":        "Anomaly Detection",
+			"description": "Flag spikes and drops in time series of attribute aggregates in data.series, optionally explained from the conversations of their time",
+			"parameters": map[string]interface{}{
+				"method": map[string]interface{}{
+					"type":        "string",
+					"description": "zscore (against the window of points before each point) or ewma (against their exponentially weighted average)",
+					"example":     "zscore",
+				},
+				"threshold": map[string]interface{}{
+					"type":        "number",
+					"description": "Standard deviations from the expected value at which a point is anomalous (default 3)",
+					"example":     3,
+				},
+				"window": map[string]interface{}{
+					"type":        "integer",
+					"description": "Points before each point the zscore baseline spans (default 7)",
+					"example":     14,
+				},
+				"alpha": map[string]interface{}{
+					"type":        "number",
+					"description": "Weight of each new point in the ewma baseline, between 0 and 1 (default 0.3)",
+					"example":     0.3,
+				},
+				"direction": map[string]interface{}{
+					"type":        "string",
+					"description": "Anomalies to flag: spike, drop or both (default both)",
+					"example":     "spike",
+				},
+				"explain": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Explain the most significant anomalies from the conversations of their time bucket",
+					"example":     true,
+				},
+				"max_explanations": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of anomalies explained, most significant first (default 5, at most 20)",
+					"example":     5,
+				},
 			},
 		},
 	}
+
+	// Analyses over many conversations can summarize long conversations first
+	for analysisType := range presummarizeAnalysisTypes {
+		parameters := metadata[analysisType].(map[string]interface{})["parameters"].(map[string]interface{})
+		parameters["presummarize"] = map[string]interface{}{
+			"type":        "integer",
+			"description": "Summarize conversations longer than this many characters before analyzing them; true summarizes those longer than 4000",
+			"example":     6000,
+		}
+		parameters["summary_chars"] = summaryCharsParameter
+	}
+
+	// Library nodes are described like analysis functions, marked with kind "node" and
+	// their category: source, transform or sink
+	for _, nodeType := range workflow.NodeTypes() {
+		metadata[nodeType.Type] = map[string]interface{}{
+			"kind":        "node",
+			"category":    nodeType.Category,
+			"name":        nodeType.Name,
+			"description": nodeType.Description,
+			"parameters":  nodeType.Parameters,
+			"outputs":     nodeType.Outputs,
+		}
+	}
+	return metadata
 }
 
 // Helper types for function metadata
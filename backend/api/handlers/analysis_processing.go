@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/processors"
+	"agenticflows/backend/db"
+)
+
+// Outlier detection of per-conversation processing
+const (
+	// defaultOutlierFactor is how many times the median prompt tokens of a run make a
+	// conversation an outlier
+	defaultOutlierFactor = 3.0
+	// minOutlierRun is the fewest conversations a run needs for a meaningful median
+	minOutlierRun = 5
+)
+
+// routeOutliers windows the conversations recorded as outliers of earlier runs of an
+// analysis, unless the route_outliers parameter is false
+func routeOutliers(analysisType string, parameters map[string]interface{}, conversations []models.ConversationText) error {
+	if param, ok := parameters["route_outliers"]; ok {
+		route, isBool := param.(bool)
+		if !isBool {
+			return fmt.Errorf("route_outliers must be a boolean")
+		}
+		if !route {
+			return nil
+		}
+	}
+
+	ids := make([]string, len(conversations))
+	for i, conversation := range conversations {
+		ids[i] = conversation.ConversationID
+	}
+	recorded, err := db.GetConversationProcessing(analysisType, ids)
+	if err != nil {
+		// Routing only saves cost, so a failed lookup must not fail the run
+		log.Printf("Error getting conversation processing: %v", err)
+		return nil
+	}
+	for i := range conversations {
+		if recorded[conversations[i].ConversationID].Outlier {
+			conversations[i].Windowed = true
+		}
+	}
+	return nil
+}
+
+// outlierFactor reads the outlier_factor parameter
+func outlierFactor(parameters map[string]interface{}) (float64, error) {
+	param, ok := parameters["outlier_factor"]
+	if !ok {
+		return defaultOutlierFactor, nil
+	}
+	factor, isNumber := param.(float64)
+	if !isNumber || factor <= 1 {
+		return 0, fmt.Errorf("outlier_factor must be a number greater than 1")
+	}
+	return factor, nil
+}
+
+// recordProcessing prices the processing of each conversation of a run, flags the
+// expensive outliers and records both, so later runs window the outliers. A conversation
+// is an outlier when its text is cut off by the extraction prompt, or when it took more
+// than factor times the median prompt tokens of a run of at least minOutlierRun
+// conversations. Windowed conversations stay outliers.
+func recordProcessing(ctx context.Context, analysisType, workflowID string, results []models.ConversationAttributes, factor float64) *models.ProcessingSummary {
+	prices := tokenPrices()
+	summary := &models.ProcessingSummary{}
+
+	var tokens []int
+	var latencies []int64
+	for _, result := range results {
+		processing := result.Processing
+		if processing == nil {
+			continue
+		}
+		processing.Cost = prices.tokensCost(processing.PromptTokens, 0, processing.CompletionTokens)
+		latencies = append(latencies, processing.LatencyMS)
+		if result.Error == "" && !processing.Windowed {
+			tokens = append(tokens, processing.PromptTokens)
+		}
+	}
+	sort.Ints(tokens)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	if len(tokens) > 0 {
+		summary.MedianPromptTokens = tokens[len(tokens)/2]
+	}
+	if len(latencies) > 0 {
+		summary.MedianLatencyMS = latencies[len(latencies)/2]
+		summary.MaxLatencyMS = latencies[len(latencies)-1]
+	}
+
+	var records []db.ConversationProcessing
+	for _, result := range results {
+		processing := result.Processing
+		if processing == nil {
+			continue
+		}
+		processing.Outlier = processing.Windowed || processing.TextLength > processors.MaxAttributeTextChars ||
+			(len(tokens) >= minOutlierRun && float64(processing.PromptTokens) > factor*float64(summary.MedianPromptTokens))
+
+		summary.Conversations++
+		summary.Cost += processing.Cost
+		summary.PromptTokens += processing.PromptTokens
+		summary.CompletionTokens += processing.CompletionTokens
+		if processing.Outlier {
+			summary.Outliers = append(summary.Outliers, result.ConversationID)
+		}
+		if processing.Windowed {
+			summary.Windowed = append(summary.Windowed, result.ConversationID)
+		}
+
+		records = append(records, db.ConversationProcessing{
+			ConversationID:   result.ConversationID,
+			AnalysisType:     analysisType,
+			WorkflowID:       workflowID,
+			Calls:            processing.Calls,
+			PromptTokens:     processing.PromptTokens,
+			CompletionTokens: processing.CompletionTokens,
+			Estimated:        processing.Estimated,
+			Cost:             processing.Cost,
+			LatencyMS:        processing.LatencyMS,
+			TextLength:       processing.TextLength,
+			Windowed:         processing.Windowed,
+			Outlier:          processing.Outlier,
+		})
+	}
+
+	// Mock replies of dry runs say nothing about what conversations cost
+	if core.IsDryRun(ctx) {
+		return summary
+	}
+	if err := db.SaveConversationProcessing(records); err != nil {
+		log.Printf("Error saving conversation processing: %v", err)
+	}
+	return summary
+}
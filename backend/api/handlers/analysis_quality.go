@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"agenticflows/backend/analysis/core"
+)
+
+// HandleQualityMetrics handles GET /api/analysis/quality, reporting how often
+// structured model output needed repair, per output schema
+func (h *AnalysisHandler) HandleQualityMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := map[string]interface{}{
+		"schema_repairs": core.SchemaRepairMetrics(),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
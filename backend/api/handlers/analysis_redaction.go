@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/pii"
+)
+
+// piiRedactionOptions is the object form of the redact_pii parameter. Providers and
+// MinConfidence override the server's PII configuration for the request.
+type piiRedactionOptions struct {
+	PreserveAmounts bool     `json:"preserve_amounts"`
+	Providers       []string `json:"providers"`
+	MinConfidence   *float64 `json:"min_confidence"`
+}
+
+// redactionScanner returns the PII scanner a request asks for with its redact_pii
+// parameter, which is true for the server's PII configuration or an object of
+// piiRedactionOptions, or nil when the request is not redacted
+func redactionScanner(parameters map[string]interface{}) (*pii.Scanner, error) {
+	var options piiRedactionOptions
+	switch value := parameters["redact_pii"].(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !value {
+			return nil, nil
+		}
+	case map[string]interface{}:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(encoded, &options); err != nil {
+			return nil, fmt.Errorf("invalid redact_pii options: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("redact_pii must be true or an object of redaction options")
+	}
+
+	config := pii.ConfigFromEnv()
+	if len(options.Providers) > 0 {
+		config.Providers = options.Providers
+	}
+	if options.MinConfidence != nil {
+		if *options.MinConfidence < 0 || *options.MinConfidence > 1 {
+			return nil, fmt.Errorf("redact_pii.min_confidence must be between 0 and 1")
+		}
+		config.MinConfidence = *options.MinConfidence
+	}
+	if options.PreserveAmounts {
+		config.PreservedTypes = append(config.PreservedTypes, pii.TypeAmount)
+	}
+	return pii.NewScanner(config)
+}
+
+// redactRequest replaces the PII in the text and data.conversations of a request with
+// placeholders such as [EMAIL] before any prompt is built from them, and reports what
+// was redacted. Fan-out types redact the stored conversations they load themselves. It
+// returns nil when the request is not redacted.
+func redactRequest(ctx context.Context, analysisType string, req *models.StandardAnalysisRequest) (*pii.Report, error) {
+	scanner, err := redactionScanner(req.Parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	if scanner == nil || fanOutAnalysisTypes[analysisType] && len(req.ConversationIDs) > 0 {
+		return nil, nil
+	}
+
+	// Each text is redacted and written back where it was read from
+	type pendingText struct {
+		text string
+		set  func(string)
+	}
+	var texts []pendingText
+	if req.Text != "" {
+		texts = append(texts, pendingText{req.Text, func(text string) { req.Text = text }})
+	}
+	if items, ok := req.Data["conversations"].([]interface{}); ok {
+		for i, item := range items {
+			switch v := item.(type) {
+			case string:
+				texts = append(texts, pendingText{v, func(text string) { items[i] = text }})
+			case map[string]interface{}:
+				if text, _ := v["text"].(string); text != "" {
+					texts = append(texts, pendingText{text, func(text string) { v["text"] = text }})
+				}
+			}
+		}
+	}
+
+	language, _ := translationTarget(req.Parameters)
+	report := scanner.NewReport()
+	var mu sync.Mutex
+	err = forEachConcurrently(len(texts), func(i int) error {
+		redacted, entities, err := scanner.Redact(ctx, texts[i].text, language)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		texts[i].set(redacted)
+		report.Add(entities)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to redact PII: %w", err)
+	}
+	return report, nil
+}
+
+// redactConversations redacts the stored conversations a fan-out analysis loaded, when
+// its redact_pii parameter is set, and reports what was redacted
+func redactConversations(ctx context.Context, parameters map[string]interface{}, conversations []models.ConversationText) (*pii.Report, error) {
+	scanner, err := redactionScanner(parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	if scanner == nil {
+		return nil, nil
+	}
+
+	language, _ := translationTarget(parameters)
+	report := scanner.NewReport()
+	var mu sync.Mutex
+	err = forEachConcurrently(len(conversations), func(i int) error {
+		redacted, entities, err := scanner.Redact(ctx, conversations[i].Text, language)
+		if err != nil {
+			return fmt.Errorf("conversation %s: %w", conversations[i].ConversationID, err)
+		}
+		mu.Lock()
+		conversations[i].Text = redacted
+		report.Add(entities)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to redact PII: %w", err)
+	}
+	return report, nil
+}
+
+// translationRedactionKey is the context key of the report of the PII redacted from a
+// request before it was translated
+type translationRedactionKey struct{}
+
+// withTranslationRedaction runs an analysis whose conversations were redacted before
+// they were translated. The translated text is redacted again, but the response reports
+// what was found in the original text.
+func withTranslationRedaction(runAnalysis analysisFunc, report *pii.Report) analysisFunc {
+	if report == nil {
+		return runAnalysis
+	}
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		return runAnalysis(context.WithValue(ctx, translationRedactionKey{}, report), req)
+	}
+}
+
+// translationRedaction returns the report of the PII redacted from the request of ctx
+// before it was translated, or nil
+func translationRedaction(ctx context.Context) *pii.Report {
+	report, _ := ctx.Value(translationRedactionKey{}).(*pii.Report)
+	return report
+}
+
+// redactionSummary describes a redaction report in a sentence for data_quality
+func redactionSummary(report *pii.Report) string {
+	var redacted int
+	types := make([]string, 0, len(report.Entities))
+	for entityType, count := range report.Entities {
+		redacted += count
+		types = append(types, strings.ToLower(entityType))
+	}
+	if redacted == 0 {
+		return ""
+	}
+	sort.Strings(types)
+	return fmt.Sprintf("%d PII spans (%s) in %d of %d texts were redacted before the analysis",
+		redacted, strings.Join(types, ", "), report.RedactedTexts, report.Texts)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/models"
+)
+
+// maxAnalysisSamples bounds the "samples" parameter
+const maxAnalysisSamples = 10
+
+// analysisSamples reads the optional "samples" parameter: how many times to run the analysis
+func analysisSamples(req models.StandardAnalysisRequest) (int, error) {
+	value, ok := req.Parameters["samples"]
+	if !ok {
+		return 1, nil
+	}
+
+	samples, ok := value.(float64)
+	if !ok || samples != float64(int(samples)) || samples < 1 {
+		return 0, fmt.Errorf("samples must be a positive integer")
+	}
+	if samples > maxAnalysisSamples {
+		return 0, fmt.Errorf("samples must be at most %d", maxAnalysisSamples)
+	}
+	return int(samples), nil
+}
+
+// withSamples runs an analysis several times concurrently and merges the samples by
+// vote. The composite confidence is the samples' mean confidence scaled by their agreement.
+func withSamples(runAnalysis analysisFunc, samples int) analysisFunc {
+	if samples <= 1 {
+		return runAnalysis
+	}
+
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		responses := make([]*models.StandardAnalysisResponse, samples)
+		errs := make([]error, samples)
+
+		var wg sync.WaitGroup
+		for i := 0; i < samples; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i], errs[i] = runAnalysis(ctx, req)
+			}(i)
+		}
+		wg.Wait()
+
+		var succeeded []*models.StandardAnalysisResponse
+		var results []interface{}
+		var firstErr error
+		for i, resp := range responses {
+			if errs[i] == nil && resp != nil && resp.Error != nil {
+				errs[i] = analysis.ResponseError(resp.Error)
+			}
+			if errs[i] != nil {
+				log.Printf("Analysis sample %d of %d failed: %v", i+1, samples, errs[i])
+				if firstErr == nil {
+					firstErr = errs[i]
+				}
+				continue
+			}
+
+			normalized, err := normalizeResults(resp.Results)
+			if err != nil {
+				return nil, err
+			}
+			succeeded = append(succeeded, resp)
+			results = append(results, normalized)
+		}
+		if len(succeeded) == 0 {
+			return nil, fmt.Errorf("all %d samples failed: %w", samples, firstErr)
+		}
+
+		merged, agreement := analysis.VoteResults(results)
+
+		var confidence float64
+		for _, resp := range succeeded {
+			confidence += resp.Confidence
+		}
+		confidence /= float64(len(succeeded))
+
+		resp := *succeeded[0]
+		resp.Results = merged
+		resp.Confidence = confidence * agreement
+		resp.Reliability = &models.SampleAgreement{
+			Samples:          samples,
+			Succeeded:        len(succeeded),
+			Agreement:        agreement,
+			SampleConfidence: confidence,
+		}
+		if len(succeeded) < samples {
+			resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+				fmt.Sprintf("%d of %d samples failed; agreement is based on the remaining %d", samples-len(succeeded), samples, len(succeeded)))
+		}
+		return &resp, nil
+	}
+}
+
+// normalizeResults converts typed results to their JSON form so samples can be compared
+func normalizeResults(results interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sample results: %w", err)
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, fmt.Errorf("failed to decode sample results: %w", err)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// handleSentimentAnalysis handles sentiment analysis requests
+func (h *AnalysisHandler) handleSentimentAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+	// Optional speaker labels to report on
+	var speakers []string
+	if speakersParam, ok := req.Parameters["speakers"].([]interface{}); ok {
+		for _, speaker := range speakersParam {
+			if speakerStr, ok := speaker.(string); ok && speakerStr != "" {
+				speakers = append(speakers, speakerStr)
+			}
+		}
+	}
+
+	conversations, err := requestConversations(req)
+	if err != nil {
+		return nil, err
+	}
+	if conversations != nil {
+		return h.handleSentimentConversations(ctx, req, conversations, speakers)
+	}
+
+	// Validate request
+	if req.Text == "" {
+		return nil, fmt.Errorf("text or data.conversations is required for sentiment analysis")
+	}
+
+	text, err := speakerTurnsFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	sentiment, err := h.analysisFacade.AnalyzeSentiment(ctx, text, speakers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze sentiment: %w", err)
+	}
+
+	// Use the model's confidence in the overall sentiment when it reports one
+	confidence := sentiment.Overall.Confidence
+	if confidence == 0 {
+		confidence = 0.85
+	}
+
+	return &models.StandardAnalysisResponse{
+		AnalysisType: "sentiment",
+		WorkflowID:   req.WorkflowID,
+		Timestamp:    time.Now(),
+		Results:      sentiment,
+		Confidence:   confidence,
+	}, nil
+}
+
+// handleSentimentConversations analyzes the sentiment of each conversation of a request,
+// several conversations per LLM call
+func (h *AnalysisHandler) handleSentimentConversations(ctx context.Context, req models.StandardAnalysisRequest, conversations []requestConversation, speakers []string) (*models.StandardAnalysisResponse, error) {
+	result, err := h.analysisFacade.AnalyzeSentiments(ctx, conversationTexts(conversations), speakers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze sentiment: %w", err)
+	}
+	for i, conversation := range conversations {
+		result.Conversations[i].ConversationID = conversation.ID
+	}
+
+	confidence := result.Overall.Confidence
+	if confidence == 0 {
+		confidence = 0.85
+	}
+
+	return &models.StandardAnalysisResponse{
+		AnalysisType: "sentiment",
+		WorkflowID:   req.WorkflowID,
+		Timestamp:    time.Now(),
+		Results:      result,
+		Confidence:   confidence,
+	}, nil
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/privacy"
+)
+
+// Server-Sent Event types emitted by streaming analysis requests
+const (
+	streamEventProgress = "progress" // Analysis stage changed
+	streamEventChunk    = "chunk"    // Partial model output
+	streamEventResult   = "result"   // Final StandardAnalysisResponse
+	streamEventError    = "error"    // Analysis failed; data is a StandardAnalysisResponse with Error set
+)
+
+// streamProgress is the payload of a progress event
+type streamProgress struct {
+	Stage        string    `json:"stage"`
+	AnalysisType string    `json:"analysis_type"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// streamChunk is the payload of a chunk event
+type streamChunk struct {
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+}
+
+// streamsPartialOutput reports whether partial model output may be sent to clients. It
+// is withheld while small groups are suppressed or IDs pseudonymized, as only the final
+// response passes through those filters.
+func streamsPartialOutput() bool {
+	return privacy.MinGroupSize() <= 1 && privacy.PseudonymKey() == nil
+}
+
+// sseWriter writes Server-Sent Events, serializing writes from concurrent LLM calls
+type sseWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// send writes one event and flushes it to the client
+func (s *sseWriter) send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", event, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// streamAnalysis runs an analysis and reports its progress, partial model output
+// and final response as Server-Sent Events
+func (h *AnalysisHandler) streamAnalysis(w http.ResponseWriter, r *http.Request, req models.StandardAnalysisRequest, analysisType string, runAnalysis analysisFunc) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendAnalysisError(w, "streaming_unsupported", "Streaming is not supported by this server", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &sseWriter{w: w, flusher: flusher}
+	progress := func(stage string) {
+		if err := stream.send(streamEventProgress, streamProgress{Stage: stage, AnalysisType: analysisType, Timestamp: time.Now()}); err != nil {
+			log.Printf("Error writing progress event: %v", err)
+		}
+	}
+
+	progress("started")
+
+	// Forward partial model output as it is generated, unless privacy filters apply
+	ctx := r.Context()
+	if streamsPartialOutput() {
+		var chunkMu sync.Mutex
+		chunkIndex := 0
+		ctx = core.WithStream(ctx, func(text string) {
+			chunkMu.Lock()
+			index := chunkIndex
+			chunkIndex++
+			chunkMu.Unlock()
+
+			if err := stream.send(streamEventChunk, streamChunk{Index: index, Text: text}); err != nil {
+				log.Printf("Error writing chunk event: %v", err)
+			}
+		})
+	}
+
+	resp, err := runAnalysis(ctx, req)
+	if err != nil {
+		log.Printf("Error processing %s analysis: %v", req.AnalysisType, err)
+		code, _ := AnalysisFailure(err)
+		sendStreamError(stream, analysisType, code, err.Error())
+		return
+	}
+
+	progress("finalizing")
+	if err := h.completeAnalysis(auth.TenantID(r.Context()), actorFromRequest(r), req, analysisType, resp); err != nil {
+		sendStreamError(stream, analysisType, "invalid_request", err.Error())
+		return
+	}
+
+	event := streamEventResult
+	if resp != nil && resp.Error != nil {
+		event = streamEventError
+	}
+	if err := stream.send(event, resp); err != nil {
+		log.Printf("Error writing result event: %v", err)
+	}
+}
+
+// sendStreamError reports a failed analysis as an error event
+func sendStreamError(stream *sseWriter, analysisType string, code string, message string) {
+	resp := models.StandardAnalysisResponse{
+		AnalysisType: analysisType,
+		Timestamp:    time.Now(),
+		Error: &models.AnalysisError{
+			Code:      code,
+			Message:   message,
+			Retryable: analysis.Retryable(code),
+		},
+	}
+	if err := stream.send(streamEventError, resp); err != nil {
+		log.Printf("Error writing error event: %v", err)
+	}
+}
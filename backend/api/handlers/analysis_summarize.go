@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/processors"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/pii"
+)
+
+// defaultPresummarizeThreshold is the length, in characters, above which conversations are
+// summarized when the presummarize parameter is true
+const defaultPresummarizeThreshold = 4000
+
+// presummarizeAnalysisTypes are the analyses over many conversations that can be given
+// summaries of long conversations instead of their full text
+var presummarizeAnalysisTypes = map[string]bool{
+	"trends":   true,
+	"patterns": true,
+	"findings": true,
+}
+
+// handleSummarizeAnalysis summarizes each conversation of text, data.conversations or
+// conversation_ids. Summaries are cached by the text they summarize. Conversations that
+// cannot be summarized are reported with their error, and the response is partial; the
+// request only fails if none is summarized.
+func (h *AnalysisHandler) handleSummarizeAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+	maxChars, err := summaryChars(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	var conversations []models.ConversationText
+	switch {
+	case len(req.ConversationIDs) > 0:
+		if len(req.ConversationIDs) > maxFanOutConversations {
+			return nil, fmt.Errorf("%w: at most %d conversation_ids can be summarized per request", analysis.ErrDataTooLarge, maxFanOutConversations)
+		}
+		stored, err := db.GetConversations(auth.TenantID(ctx), req.ConversationIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load conversations: %w", err)
+		}
+		if len(stored) < len(req.ConversationIDs) {
+			return nil, fmt.Errorf("%d of %d conversations were not found", len(req.ConversationIDs)-len(stored), len(req.ConversationIDs))
+		}
+		if err := requireConversationText(stored); err != nil {
+			return nil, err
+		}
+		if stored, err = withTranslations(stored, req.Parameters); err != nil {
+			return nil, err
+		}
+		role, err := speakerRole(req.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		for _, conversation := range stored {
+			text, err := speakerTurnsText(conversation.Text, role)
+			if err != nil {
+				return nil, fmt.Errorf("conversation %s: %w", conversation.ID, err)
+			}
+			conversations = append(conversations, models.ConversationText{ConversationID: conversation.ID, Text: text})
+		}
+	case req.Data["conversations"] != nil:
+		items, err := requestConversations(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			conversations = append(conversations, models.ConversationText{ConversationID: item.ID, Text: item.Text})
+		}
+	case req.Text != "":
+		text, err := speakerTurnsFromRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		conversationID, _ := req.Parameters["conversation_id"].(string)
+		conversations = append(conversations, models.ConversationText{ConversationID: conversationID, Text: text})
+	default:
+		return nil, fmt.Errorf("text, data.conversations or conversation_ids is required for summarize analysis")
+	}
+
+	// Stored conversations are redacted here; the others were redacted with the request
+	var redaction *pii.Report
+	if len(req.ConversationIDs) > 0 {
+		if redaction, err = redactConversations(ctx, req.Parameters, conversations); err != nil {
+			return nil, err
+		}
+	}
+
+	summaries := h.summarizeConversations(ctx, conversations, maxChars, useMockData(req.Parameters))
+	result := &analysis.SummarizeResult{Summaries: summaries}
+	var failures []models.AnalysisError
+	for i, summary := range summaries {
+		switch {
+		case summary.Error != "":
+			result.Failed++
+			failures = append(failures, analysis.ItemError(i, summary.ConversationID, summary.ErrorCode, summary.Error))
+		case summary.Cached:
+			result.Cached++
+		}
+	}
+	if result.Failed == len(summaries) {
+		last := summaries[len(summaries)-1]
+		return nil, &analysis.CodedError{Code: last.ErrorCode, Message: "no conversation could be summarized: " + last.Error}
+	}
+
+	return &models.StandardAnalysisResponse{
+		AnalysisType: "summarize",
+		WorkflowID:   req.WorkflowID,
+		Timestamp:    time.Now(),
+		Results:      result,
+		Confidence:   0.9,
+		PIIRedaction: redaction,
+		Partial:      len(failures) > 0,
+		Errors:       failures,
+	}, nil
+}
+
+// presummarizeRequest replaces the conversations of a trends, patterns or findings request
+// that are longer than its presummarize threshold with their summaries, so more of them
+// fit the prompt. Conversation IDs stay with their summaries. It returns nil when the
+// request is not presummarized.
+func (h *AnalysisHandler) presummarizeRequest(ctx context.Context, analysisType string, req *models.StandardAnalysisRequest) (*models.SummarizationReport, error) {
+	threshold, err := presummarizeThreshold(req.Parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	if threshold == 0 {
+		return nil, nil
+	}
+	if !presummarizeAnalysisTypes[analysisType] {
+		return nil, invalidRequest(fmt.Errorf("presummarize applies to trends, patterns and findings analyses"))
+	}
+	maxChars, err := summaryChars(req.Parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	// Each long text is summarized and written back where it was read from
+	type pendingText struct {
+		conversation models.ConversationText
+		set          func(string)
+	}
+	var texts []pendingText
+	if len(req.Text) > threshold {
+		texts = append(texts, pendingText{models.ConversationText{Text: req.Text}, func(text string) { req.Text = text }})
+	}
+	if items, ok := req.Data["conversations"].([]interface{}); ok {
+		for i, item := range items {
+			switch v := item.(type) {
+			case string:
+				if len(v) > threshold {
+					texts = append(texts, pendingText{models.ConversationText{Text: v}, func(text string) { items[i] = text }})
+				}
+			case map[string]interface{}:
+				if text, id := itemText(v, "text"); len(text) > threshold {
+					texts = append(texts, pendingText{models.ConversationText{ConversationID: id, Text: text}, func(text string) { v["text"] = text }})
+				}
+			}
+		}
+	}
+
+	conversations := make([]models.ConversationText, len(texts))
+	for i, text := range texts {
+		conversations[i] = text.conversation
+	}
+	summaries := h.summarizeConversations(ctx, conversations, maxChars, useMockData(req.Parameters))
+
+	report := &models.SummarizationReport{Threshold: threshold}
+	for i, summary := range summaries {
+		if summary.Error != "" {
+			name := "conversation"
+			if summary.ConversationID != "" {
+				name += " " + summary.ConversationID
+			}
+			return nil, fmt.Errorf("failed to summarize %s: %w", name, &analysis.CodedError{Code: summary.ErrorCode, Message: summary.Error})
+		}
+		texts[i].set(summary.Summary)
+		report.Summarized++
+		if summary.Cached {
+			report.Cached++
+		}
+		if summary.ConversationID != "" {
+			report.ConversationIDs = append(report.ConversationIDs, summary.ConversationID)
+		}
+	}
+	return report, nil
+}
+
+// summarizeConversations summarizes conversations in at most about maxChars characters
+// each, serving summaries of texts summarized before from the summary cache. Mock
+// summaries are cached apart from model summaries, and those of dry runs not at all.
+// Conversations that fail are reported with their error.
+func (h *AnalysisHandler) summarizeConversations(ctx context.Context, conversations []models.ConversationText, maxChars int, mock bool) []models.ConversationSummary {
+	summaries := make([]models.ConversationSummary, len(conversations))
+	hashes := make([]string, len(conversations))
+	for i, conversation := range conversations {
+		hashes[i] = summaryTextHash(conversation.Text, mock)
+		summaries[i] = models.ConversationSummary{ConversationID: conversation.ConversationID, Length: len(conversation.Text)}
+	}
+	cached, err := db.GetConversationSummaries(hashes, maxChars)
+	if err != nil {
+		log.Printf("Error reading summary cache, summarizing anew: %v", err)
+		cached = nil
+	}
+
+	forEachConcurrently(len(conversations), func(i int) error {
+		if entry, ok := cached[hashes[i]]; ok {
+			summaries[i].Summary, summaries[i].Cached = entry.Summary, true
+			summaries[i].SummaryLength = len(entry.Summary)
+			return nil
+		}
+		summary, err := h.analysisFacade.SummarizeTranscript(ctx, conversations[i].Text, maxChars)
+		if err != nil {
+			summaries[i].Error, summaries[i].ErrorCode = err.Error(), analysis.ErrorCode(err)
+			return nil
+		}
+		summaries[i].Summary, summaries[i].SummaryLength = summary, len(summary)
+		if core.IsDryRun(ctx) {
+			return nil
+		}
+		if err := db.SaveConversationSummary(db.ConversationSummary{
+			TextHash:       hashes[i],
+			MaxChars:       maxChars,
+			ConversationID: conversations[i].ConversationID,
+			Summary:        summary,
+		}); err != nil {
+			log.Printf("Error caching summary: %v", err)
+		}
+		return nil
+	})
+	return summaries
+}
+
+// summaryTextHash identifies a text in the summary cache
+func summaryTextHash(text string, mock bool) string {
+	if mock {
+		text = "mock\x00" + text
+	}
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// presummarizeThreshold reads the presummarize parameter: true summarizes conversations
+// longer than the default threshold, and a number those longer than that many
+// characters. It returns 0 when conversations are not summarized.
+func presummarizeThreshold(parameters map[string]interface{}) (int, error) {
+	switch value := parameters["presummarize"].(type) {
+	case nil:
+		return 0, nil
+	case bool:
+		if value {
+			return defaultPresummarizeThreshold, nil
+		}
+		return 0, nil
+	case float64:
+		if value >= 1 && value == float64(int(value)) {
+			return int(value), nil
+		}
+	}
+	return 0, fmt.Errorf("presummarize must be true or a positive number of characters")
+}
+
+// summaryChars reads the summary_chars parameter, the length summaries are written to
+func summaryChars(parameters map[string]interface{}) (int, error) {
+	value, ok := parameters["summary_chars"]
+	if !ok {
+		return processors.DefaultSummaryChars, nil
+	}
+	n, ok := value.(float64)
+	if !ok || n != float64(int(n)) || n < processors.MinSummaryChars || n > processors.MaxSummaryChars {
+		return 0, fmt.Errorf("summary_chars must be an integer from %d to %d", processors.MinSummaryChars, processors.MaxSummaryChars)
+	}
+	return int(n), nil
+}
@@ -8,6 +8,14 @@ import (
 	"agenticflows/backend/analysis/models"
 )
 
+// defaultFocusAreas are the focus areas of trend analyses that do not name any
+var defaultFocusAreas = []string{
+	"Customer Satisfaction",
+	"Service Issues",
+	"Product Quality",
+	"Wait Times",
+}
+
 // handleTrendsAnalysis handles trends analysis requests
 func (h *AnalysisHandler) handleTrendsAnalysis(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
 	// Extract focus areas from parameters
@@ -24,12 +32,7 @@ func (h *AnalysisHandler) handleTrendsAnalysis(ctx context.Context, req models.S
 
 	// If no focus areas were specified, use defaults
 	if len(focusAreas) == 0 {
-		focusAreas = []string{
-			"Customer Satisfaction",
-			"Service Issues",
-			"Product Quality",
-			"Wait Times",
-		}
+		focusAreas = defaultFocusAreas
 	}
 
 	// Create a request object for the trends analyzer
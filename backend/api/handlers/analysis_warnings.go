@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+)
+
+// withWarnings reports the warnings raised while an analysis ran, such as input truncated
+// to fit a prompt, in its response. It runs inside the cache, so cached responses keep
+// their warnings.
+func withWarnings(runAnalysis analysisFunc) analysisFunc {
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		ctx, warnings := core.WithWarnings(ctx)
+		resp, err := runAnalysis(ctx, req)
+		if resp != nil {
+			resp.Warnings = append(resp.Warnings, analysisWarnings(warnings.List())...)
+		}
+		return resp, err
+	}
+}
+
+// analysisWarnings converts collected warnings to those of an analysis response
+func analysisWarnings(warnings []core.Warning) []models.AnalysisWarning {
+	converted := make([]models.AnalysisWarning, len(warnings))
+	for i, warning := range warnings {
+		converted[i] = models.AnalysisWarning{Code: warning.Code, Message: warning.Message}
+	}
+	return converted
+}
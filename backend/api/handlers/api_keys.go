@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// apiKeyRequest is the body of a request to issue an API key
+type apiKeyRequest struct {
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// TenantID is the tenant of the key, which defaults to that of the caller. Only the
+	// bootstrap admin key issues keys for other tenants.
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// issuedAPIKey is an issued key with its secret, which is only returned once
+type issuedAPIKey struct {
+	db.APIKey
+	Key string `json:"key"`
+}
+
+// HandleAuthStatus handles GET /api/auth, telling clients whether keys are required and
+// which key, if any, authenticated the request
+func HandleAuthStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := map[string]interface{}{"enabled": auth.Enabled()}
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		status["principal"] = principal
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// managedKeysTenant returns the tenant whose keys a request manages: every tenant for the
+// bootstrap admin key and without auth, and otherwise the tenant of the caller's key
+func managedKeysTenant(r *http.Request) string {
+	principal := auth.FromContext(r.Context())
+	if principal == nil || principal.KeyID == auth.BootstrapKeyID {
+		return db.AllTenants
+	}
+	return principal.TenantID
+}
+
+// HandleAPIKeys handles /api/auth/keys: GET lists the issued keys and POST issues a new one
+func HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := db.ListAPIKeys(managedKeysTenant(r))
+		if err != nil {
+			log.Printf("Error listing API keys: %v", err)
+			http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(keys)
+
+	case http.MethodPost:
+		var req apiKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if !auth.ValidRole(req.Role) {
+			http.Error(w, fmt.Sprintf("role must be one of %s, %s or %s", auth.RoleReader, auth.RoleAnalyst, auth.RoleAdmin), http.StatusBadRequest)
+			return
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+			return
+		}
+		tenantID := auth.TenantID(r.Context())
+		if req.TenantID != "" && req.TenantID != tenantID {
+			if managedKeysTenant(r) != db.AllTenants {
+				http.Error(w, "Only the bootstrap admin key issues keys for other tenants", http.StatusForbidden)
+				return
+			}
+			if !auth.ValidTenantID(req.TenantID) {
+				http.Error(w, "tenant_id must be lowercase letters, digits, dashes and underscores, starting with a letter or digit", http.StatusBadRequest)
+				return
+			}
+			tenantID = req.TenantID
+		}
+
+		secret, err := auth.GenerateKey()
+		if err != nil {
+			log.Printf("Error generating API key: %v", err)
+			http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+			return
+		}
+		key := db.APIKey{
+			ID:        uuid.New().String(),
+			Name:      req.Name,
+			Role:      req.Role,
+			TenantID:  tenantID,
+			Prefix:    secret[:auth.DisplayPrefixLength],
+			CreatedBy: actorFromRequest(r),
+			CreatedAt: time.Now(),
+			ExpiresAt: req.ExpiresAt,
+		}
+		if err := db.CreateAPIKey(key, auth.HashKey(secret)); err != nil {
+			log.Printf("Error creating API key: %v", err)
+			http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+			return
+		}
+
+		recordActivity(r, db.ActivityAPIKeyCreated, "",
+			fmt.Sprintf("API key %s issued with role %s", key.Name, key.Role),
+			map[string]interface{}{"key_id": key.ID, "name": key.Name, "role": key.Role, "prefix": key.Prefix, "tenant_id": key.TenantID})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(issuedAPIKey{APIKey: key, Key: secret})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAPIKey handles /api/auth/keys/{id}: DELETE revokes the key
+func HandleAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/auth/keys/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "API key ID is required", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, err := db.RevokeAPIKey(managedKeysTenant(r), id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error revoking API key %s: %v", id, err)
+		http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+
+	recordActivity(r, db.ActivityAPIKeyRevoked, "",
+		fmt.Sprintf("API key %s revoked", key.Name),
+		map[string]interface{}{"key_id": key.ID, "name": key.Name, "role": key.Role})
+
+	json.NewEncoder(w).Encode(key)
+}
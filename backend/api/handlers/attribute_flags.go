@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// Attribute flag listing limits
+const (
+	defaultAttributeFlagLimit = 100
+	maxAttributeFlagLimit     = 1000
+)
+
+// HandleAttributeFlags handles /api/attribute-flags: GET lists the conversations flagged
+// by validation rules, and POST /api/attribute-flags/{id}/resolve marks a flag as reviewed
+func HandleAttributeFlags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/attribute-flags"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		listAttributeFlags(w, r)
+		return
+	}
+
+	idText, ok := strings.CutSuffix(path, "/resolve")
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid attribute flag ID", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resolveAttributeFlag(w, r, id)
+}
+
+// listAttributeFlags lists attribute flags, open ones by default
+func listAttributeFlags(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.AttributeFlagFilter{
+		TenantID:       auth.TenantID(r.Context()),
+		ConversationID: query.Get("conversation_id"),
+		WorkflowID:     query.Get("workflow_id"),
+		Rule:           query.Get("rule"),
+		Status:         query.Get("status"),
+		Limit:          defaultAttributeFlagLimit,
+	}
+	switch filter.Status {
+	case "":
+		filter.Status = db.AttributeFlagOpen
+	case "all":
+		filter.Status = ""
+	case db.AttributeFlagOpen, db.AttributeFlagResolved:
+	default:
+		http.Error(w, "status must be open, resolved or all", http.StatusBadRequest)
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(n, maxAttributeFlagLimit)
+	}
+
+	flags, err := db.ListAttributeFlags(filter)
+	if err != nil {
+		log.Printf("Error listing attribute flags: %v", err)
+		http.Error(w, "Failed to list attribute flags", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(flags)
+}
+
+// resolveAttributeFlag marks a flag as reviewed, with an optional note
+func resolveAttributeFlag(w http.ResponseWriter, r *http.Request, id int64) {
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	flag, err := db.ResolveAttributeFlag(auth.TenantID(r.Context()), id, actorFromRequest(r), strings.TrimSpace(req.Note))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			http.Error(w, "Attribute flag not found", http.StatusNotFound)
+		case strings.Contains(err.Error(), "already resolved"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Error resolving attribute flag %d: %v", id, err)
+			http.Error(w, "Failed to resolve attribute flag", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	recordActivity(r, db.ActivityAttributeFlagResolved, flag.WorkflowID,
+		fmt.Sprintf("Validation flag %s on conversation %s resolved", flag.Rule, flag.ConversationID),
+		map[string]interface{}{"flag_id": flag.ID, "conversation_id": flag.ConversationID, "rule": flag.Rule, "note": flag.Note})
+
+	json.NewEncoder(w).Encode(flag)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// transcriptUpdatedReason explains re-extractions of re-ingested conversations that
+// give no revision_reason
+const transcriptUpdatedReason = "transcript updated"
+
+// queueAttributeReextraction queues attribute extraction jobs for conversations whose text
+// changed, re-extracting the attributes stored for each. Conversations with the same
+// stored attributes share a job. It returns the queued job IDs.
+func queueAttributeReextraction(r *http.Request, conversationIDs []string, reason string) ([]string, error) {
+	analysisHandler, ok := r.Context().Value("analysisHandler").(*AnalysisHandler)
+	if !ok || analysisHandler == nil {
+		return nil, fmt.Errorf("analysis is not available")
+	}
+	if reason == "" {
+		reason = transcriptUpdatedReason
+	}
+
+	type extraction struct {
+		workflowID      string
+		definitions     []interface{}
+		conversationIDs []string
+	}
+	extractions := map[string]*extraction{}
+	var order []string
+	for _, id := range conversationIDs {
+		attributes, err := db.GetConversationAttributes(id)
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		var definitions []interface{}
+		workflowID := ""
+		for _, attribute := range attributes {
+			if attribute.Type != db.ConversationAttributeTypeAttribute {
+				continue
+			}
+			names = append(names, attribute.Name)
+			definitions = append(definitions, map[string]interface{}{
+				"field_name":  attribute.Name,
+				"description": attribute.Description,
+			})
+			if workflowID == "" {
+				workflowID = attribute.WorkflowID
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		sort.Strings(names)
+		key := workflowID + "\x00" + strings.Join(names, "\x00")
+		if extractions[key] == nil {
+			extractions[key] = &extraction{workflowID: workflowID, definitions: definitions}
+			order = append(order, key)
+		}
+		extractions[key].conversationIDs = append(extractions[key].conversationIDs, id)
+	}
+
+	jobIDs := []string{}
+	noCache := false
+	for _, key := range order {
+		extraction := extractions[key]
+		for start := 0; start < len(extraction.conversationIDs); start += maxFanOutConversations {
+			end := min(start+maxFanOutConversations, len(extraction.conversationIDs))
+			req := models.StandardAnalysisRequest{
+				WorkflowID:      extraction.workflowID,
+				AnalysisType:    "attributes",
+				ConversationIDs: extraction.conversationIDs[start:end],
+				Parameters: map[string]interface{}{
+					"attributes":      extraction.definitions,
+					"revision_reason": reason,
+					"include_values":  false,
+				},
+				Cache: &noCache,
+			}
+			body, err := json.Marshal(req)
+			if err != nil {
+				return jobIDs, err
+			}
+
+			job := db.AnalysisJob{
+				ID:           uuid.New().String(),
+				Kind:         db.JobKindAnalysis,
+				WorkflowID:   extraction.workflowID,
+				AnalysisType: "attributes",
+				Actor:        actorFromRequest(r),
+				TenantID:     auth.TenantID(r.Context()),
+				Request:      body,
+			}
+			if err := db.CreateAnalysisJob(job); err != nil {
+				return jobIDs, err
+			}
+			jobIDs = append(jobIDs, job.ID)
+		}
+	}
+	if len(jobIDs) > 0 {
+		analysisHandler.jobs.notify()
+	}
+	return jobIDs, nil
+}
+
+// getConversationAttributeRevisions sends the field-level changes of a conversation's
+// attribute values and the stored results derived from it before its latest change,
+// which still reflect the previous values. The conversation must belong to the tenant.
+func getConversationAttributeRevisions(w http.ResponseWriter, tenantID, conversationID string) {
+	if _, err := db.GetConversation(tenantID, conversationID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting conversation %s: %v", conversationID, err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	revisions, err := db.GetConversationAttributeRevisions(conversationID)
+	if err != nil {
+		log.Printf("Error getting attribute revisions of conversation %s: %v", conversationID, err)
+		http.Error(w, "Failed to get attribute revisions", http.StatusInternalServerError)
+		return
+	}
+
+	affected := []db.LineageRef{}
+	if len(revisions) > 0 {
+		latest := revisions[len(revisions)-1].CreatedAt
+		edges, err := db.GetLineageDownstream(tenantID, db.LineageRef{Type: db.LineageConversation, ID: conversationID})
+		if err != nil {
+			log.Printf("Error getting lineage of conversation %s: %v", conversationID, err)
+			http.Error(w, "Failed to get attribute revisions", http.StatusInternalServerError)
+			return
+		}
+		seen := map[db.LineageRef]bool{}
+		for _, edge := range edges {
+			if edge.CreatedAt.Before(latest) && !seen[edge.Target] {
+				seen[edge.Target] = true
+				affected = append(affected, edge.Target)
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"conversation_id":  conversationID,
+		"revisions":        revisions,
+		"affected_results": affected,
+	})
+}
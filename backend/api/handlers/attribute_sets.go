@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/transcript"
+	"agenticflows/backend/analysis/validation"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/export"
+
+	"github.com/google/uuid"
+)
+
+// maxAttributeSetSize is the maximum number of attribute definitions in a stored set
+const maxAttributeSetSize = 200
+
+// maxEnumValues is the maximum number of enum values of an attribute
+const maxEnumValues = 100
+
+// maxAttributeSchemaSize is the maximum size in bytes of an imported JSON Schema
+const maxAttributeSchemaSize = 1 << 20
+
+// attributeSetRequest is the body of a request to store an attribute set
+type attributeSetRequest struct {
+	Name        string                       `json:"name"`
+	Description string                       `json:"description,omitempty"`
+	Attributes  []models.AttributeDefinition `json:"attributes"`
+	// ValidationRules are checked against the values extracted with the set
+	ValidationRules []validation.Rule `json:"validation_rules,omitempty"`
+}
+
+// HandleAttributeSets handles /api/attribute-sets: GET lists the latest version of the
+// stored attribute sets, optionally only those named by the name query parameter, and
+// POST stores a new one
+func HandleAttributeSets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		sets, err := db.ListAttributeSets(auth.TenantID(r.Context()), r.URL.Query().Get("name"))
+		if err != nil {
+			log.Printf("Error listing attribute sets: %v", err)
+			http.Error(w, "Failed to list attribute sets", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(sets)
+	case http.MethodPost:
+		createAttributeSet(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAttributeSet handles /api/attribute-sets/{id}: GET returns the latest version of
+// the set, or the one of the version query parameter, PUT stores new definitions as the
+// next version and DELETE removes the set with all its versions. Versions are immutable.
+func HandleAttributeSet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/attribute-sets/")
+	if id == "" {
+		HandleAttributeSets(w, r)
+		return
+	}
+	if id == "import" {
+		importAttributeSet(w, r)
+		return
+	}
+	if strings.HasSuffix(id, "/schema") {
+		exportAttributeSet(w, r, strings.TrimSuffix(id, "/schema"))
+		return
+	}
+	if strings.HasSuffix(id, "/versions") {
+		listAttributeSetVersions(w, r, strings.TrimSuffix(id, "/versions"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		set, ok := getAttributeSet(w, r, id)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(set)
+	case http.MethodPut:
+		updateAttributeSet(w, r, id)
+	case http.MethodDelete:
+		if err := db.DeleteAttributeSet(auth.TenantID(r.Context()), id); err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, "Attribute set not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("Error deleting attribute set %s: %v", id, err)
+			http.Error(w, "Failed to delete attribute set", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getAttributeSet returns the set of the version query parameter, or its latest version,
+// writing the error response when there is none
+func getAttributeSet(w http.ResponseWriter, r *http.Request, id string) (db.AttributeSet, bool) {
+	version := 0
+	if value := r.URL.Query().Get("version"); value != "" {
+		var err error
+		if version, err = strconv.Atoi(value); err != nil || version < 1 {
+			http.Error(w, "version must be a positive integer", http.StatusBadRequest)
+			return db.AttributeSet{}, false
+		}
+	}
+
+	set, err := db.GetAttributeSetVersion(auth.TenantID(r.Context()), id, version)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Attribute set not found", http.StatusNotFound)
+			return db.AttributeSet{}, false
+		}
+		log.Printf("Error getting attribute set %s: %v", id, err)
+		http.Error(w, "Failed to get attribute set", http.StatusInternalServerError)
+		return db.AttributeSet{}, false
+	}
+	return set, true
+}
+
+// listAttributeSetVersions handles GET /api/attribute-sets/{id}/versions, which lists the
+// versions of a set, newest first
+func listAttributeSetVersions(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	versions, err := db.ListAttributeSetVersions(auth.TenantID(r.Context()), id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Attribute set not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error listing versions of attribute set %s: %v", id, err)
+		http.Error(w, "Failed to list attribute set versions", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(versions)
+}
+
+// createAttributeSet validates and stores an attribute set
+func createAttributeSet(w http.ResponseWriter, r *http.Request) {
+	var req attributeSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	storeAttributeSet(w, r, req)
+}
+
+// updateAttributeSet handles PUT /api/attribute-sets/{id}, which validates the definitions
+// and stores them as the next version of the set. The set keeps its name when the
+// request has none.
+func updateAttributeSet(w http.ResponseWriter, r *http.Request, id string) {
+	var req attributeSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	current, err := db.GetAttributeSet(tenantID, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Attribute set not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting attribute set %s: %v", id, err)
+		http.Error(w, "Failed to get attribute set", http.StatusInternalServerError)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		req.Name = current.Name
+	}
+
+	set, err := encodeAttributeSet(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	set.ID = id
+	version, err := db.UpdateAttributeSet(tenantID, set)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Attribute set not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error updating attribute set %s: %v", id, err)
+		http.Error(w, "Failed to update attribute set", http.StatusInternalServerError)
+		return
+	}
+
+	set, err = db.GetAttributeSetVersion(tenantID, id, version)
+	if err != nil {
+		log.Printf("Error getting attribute set %s: %v", id, err)
+		http.Error(w, "Failed to get attribute set", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+// validateAttributeSet checks the definitions and validation rules of an attribute set
+func validateAttributeSet(req attributeSetRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(req.Attributes) == 0 || len(req.Attributes) > maxAttributeSetSize {
+		return fmt.Errorf("attributes must contain 1 to %d definitions", maxAttributeSetSize)
+	}
+	seen := make(map[string]bool, len(req.Attributes))
+	for i, attribute := range req.Attributes {
+		if attribute.FieldName == "" {
+			return fmt.Errorf("attribute %d has no field_name", i)
+		}
+		if seen[attribute.FieldName] {
+			return fmt.Errorf("duplicate field_name %s", attribute.FieldName)
+		}
+		if attribute.Speaker != "" && !transcript.ValidRole(attribute.Speaker) {
+			return fmt.Errorf("attribute %s has an invalid speaker; use customer, agent or system", attribute.FieldName)
+		}
+		if !models.ValidAttributeType(attribute.Type) {
+			return fmt.Errorf("attribute %s has an invalid type; use string, number, integer, boolean or date", attribute.FieldName)
+		}
+		if err := validateEnumValues(attribute); err != nil {
+			return err
+		}
+		seen[attribute.FieldName] = true
+	}
+	return validateRuleFields(req.ValidationRules, req.Attributes)
+}
+
+// validateEnumValues checks the enum values of an attribute and their synonyms: they must
+// be non-empty and distinct regardless of case, since extracted values are matched to
+// them without regard to case, and enum values must be values of the attribute's type
+func validateEnumValues(attribute models.AttributeDefinition) error {
+	if len(attribute.EnumValues) > maxEnumValues {
+		return fmt.Errorf("attribute %s has more than %d enum values", attribute.FieldName, maxEnumValues)
+	}
+	seen := make(map[string]bool, len(attribute.EnumValues))
+	for _, value := range attribute.EnumValues {
+		normalized := strings.ToLower(strings.TrimSpace(value))
+		if normalized == "" {
+			return fmt.Errorf("attribute %s has an empty enum value", attribute.FieldName)
+		}
+		if seen[normalized] {
+			return fmt.Errorf("attribute %s has duplicate enum value %q", attribute.FieldName, value)
+		}
+		seen[normalized] = true
+
+		var err error
+		switch attribute.Type {
+		case models.AttributeTypeNumber:
+			_, err = strconv.ParseFloat(normalized, 64)
+		case models.AttributeTypeInteger:
+			_, err = strconv.Atoi(normalized)
+		case models.AttributeTypeBoolean:
+			_, err = strconv.ParseBool(normalized)
+		case models.AttributeTypeDate:
+			_, err = time.Parse("2006-01-02", normalized)
+		}
+		if err != nil {
+			return fmt.Errorf("attribute %s has enum value %q, which is not a %s", attribute.FieldName, value, attribute.Type)
+		}
+	}
+
+	// Each synonym stands for one enum value
+	for value, synonyms := range attribute.EnumSynonyms {
+		if !slices.Contains(attribute.EnumValues, value) {
+			return fmt.Errorf("attribute %s has synonyms for %q, which is not one of its enum values", attribute.FieldName, value)
+		}
+		for _, synonym := range synonyms {
+			normalized := strings.ToLower(strings.TrimSpace(synonym))
+			if normalized == "" {
+				return fmt.Errorf("attribute %s has an empty synonym of %q", attribute.FieldName, value)
+			}
+			if seen[normalized] {
+				return fmt.Errorf("attribute %s has synonym %q, which is already an enum value or synonym", attribute.FieldName, synonym)
+			}
+			seen[normalized] = true
+		}
+	}
+	return nil
+}
+
+// encodeAttributeSet validates an attribute set request and encodes it for storage
+func encodeAttributeSet(req attributeSetRequest) (db.AttributeSet, error) {
+	if err := validateAttributeSet(req); err != nil {
+		return db.AttributeSet{}, err
+	}
+
+	attributes, err := json.Marshal(req.Attributes)
+	if err != nil {
+		return db.AttributeSet{}, fmt.Errorf("Invalid attributes: %s", err)
+	}
+	var validationRules json.RawMessage
+	if len(req.ValidationRules) > 0 {
+		if validationRules, err = json.Marshal(req.ValidationRules); err != nil {
+			return db.AttributeSet{}, fmt.Errorf("Invalid validation_rules: %s", err)
+		}
+	}
+
+	return db.AttributeSet{
+		Name:            req.Name,
+		Description:     req.Description,
+		Attributes:      attributes,
+		ValidationRules: validationRules,
+	}, nil
+}
+
+// storeAttributeSet validates an attribute set and stores it as a new set of the tenant of
+// the request
+func storeAttributeSet(w http.ResponseWriter, r *http.Request, req attributeSetRequest) {
+	tenantID := auth.TenantID(r.Context())
+	set, err := encodeAttributeSet(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	set.ID = uuid.New().String()
+	if err := db.CreateAttributeSet(tenantID, set); err != nil {
+		log.Printf("Error storing attribute set: %v", err)
+		http.Error(w, "Failed to store attribute set", http.StatusInternalServerError)
+		return
+	}
+
+	set, err = db.GetAttributeSet(tenantID, set.ID)
+	if err != nil {
+		log.Printf("Error getting attribute set %s: %v", set.ID, err)
+		http.Error(w, "Failed to get attribute set", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(set)
+}
+
+// exportAttributeSet handles GET /api/attribute-sets/{id}/schema, which returns the set,
+// or the version of the version query parameter, as a JSON Schema
+func exportAttributeSet(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	set, ok := getAttributeSet(w, r, id)
+	if !ok {
+		return
+	}
+	var attributes []models.AttributeDefinition
+	if err := json.Unmarshal(set.Attributes, &attributes); err != nil {
+		log.Printf("Error decoding attribute set %s: %v", id, err)
+		http.Error(w, "Failed to export attribute set", http.StatusInternalServerError)
+		return
+	}
+
+	schema := export.AttributeSchema{
+		Title:           set.Name,
+		Description:     set.Description,
+		Attributes:      attributes,
+		ValidationRules: set.ValidationRules,
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", set.Name+".schema.json"))
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(schema)
+}
+
+// importAttributeSet handles POST /api/attribute-sets/import, which stores a JSON Schema
+// as a new attribute set. The set is named by the name query parameter or the schema's
+// title.
+func importAttributeSet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAttributeSchemaSize+1))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxAttributeSchemaSize {
+		http.Error(w, "Schema is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	schema, err := export.ParseAttributeSchema(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := attributeSetRequest{Name: schema.Title, Description: schema.Description, Attributes: schema.Attributes}
+	if name := r.URL.Query().Get("name"); name != "" {
+		req.Name = name
+	}
+	if len(schema.ValidationRules) > 0 {
+		if err := json.Unmarshal(schema.ValidationRules, &req.ValidationRules); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid x-validation-rules: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+	storeAttributeSet(w, r, req)
+}
+
+// attributeSetRef reads the attribute set referenced by the attribute_set_id parameter
+// and the version pinned by attribute_set_version, which is 0 for the latest version
+func attributeSetRef(parameters map[string]interface{}) (string, int, error) {
+	id, _ := parameters["attribute_set_id"].(string)
+	if id == "" {
+		return "", 0, nil
+	}
+	value, ok := parameters["attribute_set_version"]
+	if !ok {
+		return id, 0, nil
+	}
+	var version int
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			version = int(v)
+		}
+	case string:
+		version, _ = strconv.Atoi(v)
+	}
+	if version < 1 {
+		return "", 0, fmt.Errorf("attribute_set_version must be a positive integer")
+	}
+	return id, version, nil
+}
+
+// resolveAttributeSet returns the stored attribute set of a tenant a request references,
+// or nil when it references none
+func resolveAttributeSet(tenantID string, parameters map[string]interface{}) (*db.AttributeSet, error) {
+	id, version, err := attributeSetRef(parameters)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	set, err := db.GetAttributeSetVersion(tenantID, id, version)
+	if err != nil {
+		return nil, fmt.Errorf("attribute set %s: %w", id, err)
+	}
+	return &set, nil
+}
+
+// applyAttributeSet expands the stored attribute set referenced by the attribute_set_id
+// parameter, at the version pinned by attribute_set_version or its latest version.
+// Attribute analyses use it as their attribute definitions and validation rules; other
+// analyses receive it under core.AttributeDefinitionsKey so the prompt lists it once. The
+// set must belong to the tenant.
+func applyAttributeSet(tenantID, analysisType string, req models.StandardAnalysisRequest) (models.StandardAnalysisRequest, error) {
+	set, err := resolveAttributeSet(tenantID, req.Parameters)
+	if err != nil || set == nil {
+		return req, err
+	}
+	id := set.ID
+	var definitions []interface{}
+	if err := json.Unmarshal(set.Attributes, &definitions); err != nil {
+		return req, fmt.Errorf("attribute set %s is invalid: %w", id, err)
+	}
+
+	// Copy the maps, which are shared between the batches of a request
+	if analysisType == "attributes" {
+		if _, ok := req.Parameters["attributes"]; ok {
+			return req, nil
+		}
+		parameters := make(map[string]interface{}, len(req.Parameters)+2)
+		for key, value := range req.Parameters {
+			parameters[key] = value
+		}
+		parameters["attributes"] = definitions
+		if _, ok := parameters["validation_rules"]; !ok && len(set.ValidationRules) > 0 {
+			var rules []interface{}
+			if err := json.Unmarshal(set.ValidationRules, &rules); err != nil {
+				return req, fmt.Errorf("attribute set %s has invalid validation rules: %w", id, err)
+			}
+			parameters["validation_rules"] = rules
+		}
+		req.Parameters = parameters
+		return req, nil
+	}
+
+	data := make(map[string]interface{}, len(req.Data)+1)
+	for key, value := range req.Data {
+		data[key] = value
+	}
+	data[core.AttributeDefinitionsKey] = definitions
+	req.Data = data
+	return req, nil
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/pii"
+
+	"github.com/google/uuid"
+)
+
+// Environment variables configuring the LLM audit log
+const (
+	// envLLMAudit stores every LLM call when set to full, redacted or metadata
+	envLLMAudit = "LLM_AUDIT"
+	// envLLMAuditRetentionDays is how many days audited calls are kept
+	envLLMAuditRetentionDays = "LLM_AUDIT_RETENTION_DAYS"
+)
+
+// defaultLLMAuditRetention is how long audited calls are kept by default
+const defaultLLMAuditRetention = 30 * 24 * time.Hour
+
+// llmAuditMode is how LLM calls are audited, or "" when they are not
+var llmAuditMode string
+
+// llmAuditSettings reads how LLM calls are audited and how long they are kept
+func llmAuditSettings() (string, time.Duration) {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv(envLLMAudit)))
+	switch mode {
+	case "", "off":
+		mode = ""
+	case db.AuditFull, db.AuditRedacted, db.AuditMetadata:
+	default:
+		log.Printf("Warning: ignoring invalid %s value %q", envLLMAudit, mode)
+		mode = ""
+	}
+
+	retention := defaultLLMAuditRetention
+	if value := os.Getenv(envLLMAuditRetentionDays); value != "" {
+		if days, err := strconv.Atoi(value); err == nil && days > 0 {
+			retention = time.Duration(days) * 24 * time.Hour
+		} else {
+			log.Printf("Warning: ignoring invalid %s value %q", envLLMAuditRetentionDays, value)
+		}
+	}
+	return mode, retention
+}
+
+// configureLLMAudit starts auditing LLM calls if it is enabled and deletes audited calls
+// older than the retention period
+func configureLLMAudit() {
+	mode, retention := llmAuditSettings()
+	llmAuditMode = mode
+	if mode == "" {
+		core.SetAuditFunc(nil)
+		return
+	}
+	log.Printf("LLM audit enabled: calls are stored %s for %s", mode, retention)
+	core.SetAuditFunc(auditLLMCall)
+
+	if n, err := db.PurgeLLMAudit(time.Now().Add(-retention)); err != nil {
+		log.Printf("Error purging old LLM audit entries: %v", err)
+	} else if n > 0 {
+		log.Printf("Purged %d old LLM audit entries", n)
+	}
+}
+
+// auditLLMCall stores an LLM call in the audit log as the audit mode says
+func auditLLMCall(ctx context.Context, record core.AuditRecord) {
+	entry := db.LLMAuditEntry{
+		ID:               uuid.New().String(),
+		TenantID:         auth.TenantID(ctx),
+		RequestID:        record.RequestID,
+		Model:            record.Model,
+		Schema:           record.Schema,
+		PromptHash:       auditHash(record.Prompt),
+		PromptLength:     len(record.Prompt),
+		ResponseLength:   len(record.Response),
+		Redaction:        llmAuditMode,
+		Error:            record.Error,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+		DurationMS:       record.Duration.Milliseconds(),
+	}
+	if record.Response != "" {
+		entry.ResponseHash = auditHash(record.Response)
+	}
+
+	switch llmAuditMode {
+	case db.AuditFull:
+		entry.Prompt, entry.Response = record.Prompt, record.Response
+	case db.AuditRedacted:
+		entry.Prompt, entry.Response = redactAuditText(ctx, record.Prompt), redactAuditText(ctx, record.Response)
+		entry.Error = redactAuditText(ctx, record.Error)
+	case db.AuditMetadata:
+		entry.Error = ""
+		if record.Error != "" {
+			entry.Error = "call failed"
+		}
+	}
+
+	if err := db.SaveLLMAuditEntry(entry); err != nil {
+		log.Printf("Error saving LLM audit entry: %v", err)
+	}
+}
+
+// auditHash fingerprints the text of an LLM call, so calls can be compared whatever was stored
+func auditHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// redactAuditText replaces the PII the built-in detector finds. It runs locally, so
+// redacting does not send audited text to another service.
+func redactAuditText(ctx context.Context, text string) string {
+	if text == "" {
+		return ""
+	}
+	entities, _ := pii.BuiltinDetector{}.Detect(ctx, text, "")
+	return pii.Redact(text, entities)
+}
+
+// redactAuditValue redacts every string of a decoded JSON value
+func redactAuditValue(ctx context.Context, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redactAuditText(ctx, v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = redactAuditValue(ctx, item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAuditValue(ctx, item)
+		}
+	}
+	return value
+}
+
+// auditAnalysisRequest stores an analysis request under the request ID of ctx, so its LLM
+// calls can be replayed. Requests are not stored when only metadata is audited, and dry
+// runs, which call no model, are not stored at all.
+func auditAnalysisRequest(ctx context.Context, actor string, req models.StandardAnalysisRequest, replayOf string) {
+	requestID := core.RequestID(ctx)
+	if requestID == "" || llmAuditMode == "" || llmAuditMode == db.AuditMetadata {
+		return
+	}
+	if dryRun, _ := dryRunRequested(req.Parameters); dryRun {
+		return
+	}
+
+	encoded, err := json.Marshal(req)
+	if err == nil && llmAuditMode == db.AuditRedacted {
+		var decoded interface{}
+		if err = json.Unmarshal(encoded, &decoded); err == nil {
+			encoded, err = json.Marshal(redactAuditValue(ctx, decoded))
+		}
+	}
+	if err != nil {
+		log.Printf("Error encoding analysis request %s for the audit log: %v", requestID, err)
+		return
+	}
+
+	if err := db.SaveAuditRequest(db.AuditRequest{
+		RequestID: requestID,
+		TenantID:  auth.TenantID(ctx),
+		Kind:      db.AuditKindAnalysis,
+		Actor:     actor,
+		Request:   encoded,
+		ReplayOf:  replayOf,
+	}); err != nil {
+		log.Printf("Error saving analysis request %s to the audit log: %v", requestID, err)
+	}
+}
+
+type replayKey struct{}
+
+// withReplay returns a context whose analysis replays an audited request. Replays skip
+// the analysis cache and canaries, so their LLM calls use the model and prompt asked for.
+func withReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayKey{}, true)
+}
+
+// isReplay reports whether the analysis of ctx replays an audited request
+func isReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayKey{}).(bool)
+	return replay
+}
+
+// auditReplayRequest is the body of a request to replay an audited request. Unset
+// fields keep the configured model and prompts.
+type auditReplayRequest struct {
+	Model            string `json:"model,omitempty"`
+	PromptTemplateID string `json:"prompt_template_id,omitempty"`
+	Instructions     string `json:"instructions,omitempty"`
+}
+
+// auditCallComparison pairs an LLM call of an audited request with the call of its replay
+// that sent the same prompt, else the next call for the same schema
+type auditCallComparison struct {
+	Schema   string            `json:"schema,omitempty"`
+	Original *db.LLMAuditEntry `json:"original,omitempty"`
+	Replay   *db.LLMAuditEntry `json:"replay,omitempty"`
+	// SamePrompt and SameResponse compare the hashes of the calls' text
+	SamePrompt   bool `json:"same_prompt"`
+	SameResponse bool `json:"same_response"`
+}
+
+// HandleAudit handles /api/audit, which lists audited LLM calls, /api/audit/{request_id},
+// which returns an audited request with its calls, and /api/audit/{request_id}/replay
+func (h *AnalysisHandler) HandleAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/audit"), "/")
+	requestID, action, _ := strings.Cut(path, "/")
+	switch {
+	case requestID == "" && r.Method == http.MethodGet:
+		h.listAuditEntries(w, r)
+	case requestID != "" && action == "" && r.Method == http.MethodGet:
+		h.getAuditRequest(w, auth.TenantID(r.Context()), requestID)
+	case requestID != "" && action == "replay" && r.Method == http.MethodPost:
+		h.replayAuditRequest(w, r, requestID)
+	case requestID != "" && action != "" && action != "replay":
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listAuditEntries lists audited LLM calls, filtered by request_id, model, schema, since
+// and until
+func (h *AnalysisHandler) listAuditEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	filter := db.LLMAuditFilter{
+		TenantID:  auth.TenantID(r.Context()),
+		RequestID: query.Get("request_id"),
+		Model:     query.Get("model"),
+		Schema:    query.Get("schema"),
+		Limit:     limit,
+	}
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if value := query.Get(param.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s: must be an RFC3339 timestamp", param.name), http.StatusBadRequest)
+				return
+			}
+			*param.dest = t
+		}
+	}
+
+	entries, err := db.ListLLMAuditEntries(filter)
+	if err != nil {
+		log.Printf("Error listing LLM audit entries: %v", err)
+		http.Error(w, "Failed to list audit entries", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mode":    llmAuditMode,
+		"entries": entries,
+	})
+}
+
+// getAuditRequest returns an audited request of a tenant with its LLM calls. Calls made
+// outside a stored request, e.g. when only metadata is audited, are returned without the
+// request.
+func (h *AnalysisHandler) getAuditRequest(w http.ResponseWriter, tenantID, requestID string) {
+	request, err := db.GetAuditRequest(tenantID, requestID)
+	if err != nil {
+		log.Printf("Error loading audited request %s: %v", requestID, err)
+		http.Error(w, "Failed to load audited request", http.StatusInternalServerError)
+		return
+	}
+	calls, err := db.ListLLMAuditEntries(db.LLMAuditFilter{TenantID: tenantID, RequestID: requestID, Limit: 1000})
+	if err != nil {
+		log.Printf("Error listing LLM audit entries of %s: %v", requestID, err)
+		http.Error(w, "Failed to list audit entries", http.StatusInternalServerError)
+		return
+	}
+	if request == nil && len(calls) == 0 {
+		http.Error(w, "Audited request not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"request_id": requestID,
+		"request":    request,
+		"calls":      calls,
+	})
+}
+
+// replayAuditRequest runs an audited analysis request again, optionally against another
+// model or prompt version, and compares its LLM calls with those of the original. The
+// replay is audited under its own request ID and its results are not stored.
+func (h *AnalysisHandler) replayAuditRequest(w http.ResponseWriter, r *http.Request, requestID string) {
+	if llmAuditMode == "" {
+		sendAnalysisError(w, "invalid_request", fmt.Sprintf("LLM calls are not audited; set %s to replay requests", envLLMAudit), http.StatusConflict)
+		return
+	}
+
+	var body auditReplayRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			sendAnalysisError(w, "invalid_request", fmt.Sprintf("Invalid request format: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	stored, err := db.GetAuditRequest(tenantID, requestID)
+	if err != nil {
+		log.Printf("Error loading audited request %s: %v", requestID, err)
+		http.Error(w, "Failed to load audited request", http.StatusInternalServerError)
+		return
+	}
+	if stored == nil {
+		http.Error(w, "Audited request not found", http.StatusNotFound)
+		return
+	}
+	if stored.Kind != db.AuditKindAnalysis {
+		sendAnalysisError(w, "invalid_request", fmt.Sprintf("%s requests cannot be replayed", stored.Kind), http.StatusConflict)
+		return
+	}
+
+	var req models.StandardAnalysisRequest
+	if err := json.Unmarshal(stored.Request, &req); err != nil {
+		log.Printf("Error decoding audited request %s: %v", requestID, err)
+		http.Error(w, "Failed to decode audited request", http.StatusInternalServerError)
+		return
+	}
+	parameters := make(map[string]interface{}, len(req.Parameters)+2)
+	for key, value := range req.Parameters {
+		parameters[key] = value
+	}
+	// The results of a replay are compared, not stored
+	parameters["persist"] = false
+	if body.PromptTemplateID != "" {
+		parameters["prompt_template_id"] = body.PromptTemplateID
+	}
+	req.Parameters = parameters
+	req.Stream = false
+
+	ctx := r.Context()
+	replayID := core.RequestID(ctx)
+	if replayID == "" || replayID == requestID {
+		replayID = uuid.New().String()
+		ctx = core.WithRequestID(ctx, replayID)
+	}
+	ctx = withReplay(core.WithVariant(ctx, core.Variant{Model: body.Model, Instructions: body.Instructions}))
+	actor := actorFromRequest(r)
+	auditAnalysisRequest(ctx, actor, req, requestID)
+
+	_, runAnalysis, err := h.prepareAnalysis(ctx, actor, &req)
+	if err != nil {
+		sendAnalysisFailure(w, err)
+		return
+	}
+	resp, err := runAnalysis(ctx, req)
+	if err != nil {
+		log.Printf("Error replaying audited request %s: %v", requestID, err)
+		sendAnalysisFailure(w, err)
+		return
+	}
+
+	original, err := db.ListLLMAuditEntries(db.LLMAuditFilter{TenantID: tenantID, RequestID: requestID, Limit: 1000})
+	if err == nil {
+		var replayed []db.LLMAuditEntry
+		if replayed, err = db.ListLLMAuditEntries(db.LLMAuditFilter{TenantID: tenantID, RequestID: replayID, Limit: 1000}); err == nil {
+			comparisons, changed := compareAuditCalls(original, replayed)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"request_id": replayID,
+				"replay_of":  requestID,
+				"calls":      comparisons,
+				"changed":    changed,
+				"response":   resp,
+			})
+			return
+		}
+	}
+	log.Printf("Error listing LLM audit entries to compare with %s: %v", requestID, err)
+	http.Error(w, "Failed to compare audited calls", http.StatusInternalServerError)
+}
+
+// compareAuditCalls pairs the calls of a replay with those of the original request and
+// counts the pairs whose responses differ. Calls run concurrently, so calls are paired by
+// prompt before they are paired by schema in the order they were made.
+func compareAuditCalls(original, replayed []db.LLMAuditEntry) ([]auditCallComparison, int) {
+	used := make([]bool, len(original))
+	match := func(same func(db.LLMAuditEntry) bool) *db.LLMAuditEntry {
+		for i := range original {
+			if !used[i] && same(original[i]) {
+				used[i] = true
+				return &original[i]
+			}
+		}
+		return nil
+	}
+
+	comparisons := make([]auditCallComparison, 0, len(replayed))
+	changed := 0
+	for i := range replayed {
+		replay := &replayed[i]
+		paired := match(func(e db.LLMAuditEntry) bool { return e.Schema == replay.Schema && e.PromptHash == replay.PromptHash })
+		if paired == nil {
+			paired = match(func(e db.LLMAuditEntry) bool { return e.Schema == replay.Schema })
+		}
+		comparison := auditCallComparison{Schema: replay.Schema, Original: paired, Replay: replay}
+		if paired != nil {
+			comparison.SamePrompt = paired.PromptHash == replay.PromptHash
+			comparison.SameResponse = paired.ResponseHash == replay.ResponseHash && paired.Error == "" && replay.Error == ""
+		}
+		if !comparison.SameResponse {
+			changed++
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	// Calls the replay no longer made
+	for i := range original {
+		if !used[i] {
+			comparisons = append(comparisons, auditCallComparison{Schema: original[i].Schema, Original: &original[i]})
+			changed++
+		}
+	}
+	return comparisons, changed
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// canaryRequest is the body of a request to start a canary
+type canaryRequest struct {
+	Name               string   `json:"name"`
+	AnalysisTypes      []string `json:"analysis_types,omitempty"`
+	Percentage         float64  `json:"percentage"`
+	Model              string   `json:"model,omitempty"`
+	PromptInstructions string   `json:"prompt_instructions,omitempty"`
+}
+
+// HandleCanaries handles /api/canaries: GET lists the canaries of the tenant with their
+// metrics and POST starts one
+func HandleCanaries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		canaries, err := db.ListCanaries(auth.TenantID(r.Context()))
+		if err != nil {
+			log.Printf("Error listing canaries: %v", err)
+			http.Error(w, "Failed to list canaries", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(canaries)
+	case http.MethodPost:
+		createCanary(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleCanary handles /api/canaries/{id}: GET returns a canary with its metrics, PUT
+// changes its percentage, and POST /promote or /rollback ends it
+func HandleCanary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/canaries/"), "/")
+	if path == "" {
+		HandleCanaries(w, r)
+		return
+	}
+	id, action, _ := strings.Cut(path, "/")
+	tenantID := auth.TenantID(r.Context())
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeCanary(w, tenantID, id)
+	case action == "" && r.Method == http.MethodPut:
+		var req struct {
+			Percentage float64 `json:"percentage"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if req.Percentage <= 0 || req.Percentage > 100 {
+			http.Error(w, "percentage must be greater than 0 and at most 100", http.StatusBadRequest)
+			return
+		}
+		if err := db.UpdateCanaryPercentage(tenantID, id, req.Percentage); err != nil {
+			writeCanaryError(w, id, err)
+			return
+		}
+		writeCanary(w, tenantID, id)
+	case (action == "promote" || action == "rollback") && r.Method == http.MethodPost:
+		endCanary(w, r, id, action)
+	case action == "" || action == "promote" || action == "rollback":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// createCanary validates and starts a canary
+func createCanary(w http.ResponseWriter, r *http.Request) {
+	var req canaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Model = strings.TrimSpace(req.Model)
+	req.PromptInstructions = strings.TrimSpace(req.PromptInstructions)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if req.Model == "" && req.PromptInstructions == "" {
+		http.Error(w, "model or prompt_instructions is required", http.StatusBadRequest)
+		return
+	}
+	if req.Percentage <= 0 || req.Percentage > 100 {
+		http.Error(w, "percentage must be greater than 0 and at most 100", http.StatusBadRequest)
+		return
+	}
+	for _, analysisType := range req.AnalysisTypes {
+		if _, ok := analysis.NewResult(analysisType); !ok {
+			http.Error(w, fmt.Sprintf("unknown analysis type %s", analysisType), http.StatusBadRequest)
+			return
+		}
+	}
+
+	canary := db.Canary{
+		ID:                 uuid.New().String(),
+		Name:               req.Name,
+		AnalysisTypes:      req.AnalysisTypes,
+		Percentage:         req.Percentage,
+		Model:              req.Model,
+		PromptInstructions: req.PromptInstructions,
+		CreatedBy:          actorFromRequest(r),
+	}
+	tenantID := auth.TenantID(r.Context())
+	if err := db.CreateCanary(tenantID, canary); err != nil {
+		if strings.Contains(err.Error(), "already active") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Error creating canary: %v", err)
+		http.Error(w, "Failed to create canary", http.StatusInternalServerError)
+		return
+	}
+
+	recordActivity(r, db.ActivityCanaryStarted, "",
+		fmt.Sprintf("Canary %s started on %g%% of traffic", canary.Name, canary.Percentage),
+		map[string]interface{}{"canary_id": canary.ID, "model": canary.Model, "analysis_types": canary.AnalysisTypes})
+
+	w.WriteHeader(http.StatusCreated)
+	writeCanary(w, tenantID, canary.ID)
+}
+
+// endCanary promotes or rolls back a canary
+func endCanary(w http.ResponseWriter, r *http.Request, id, action string) {
+	status, activityType := db.CanaryPromoted, db.ActivityCanaryPromoted
+	if action == "rollback" {
+		status, activityType = db.CanaryRolledBack, db.ActivityCanaryRolledBack
+	}
+	tenantID := auth.TenantID(r.Context())
+	if err := db.EndCanary(tenantID, id, status, actorFromRequest(r)); err != nil {
+		writeCanaryError(w, id, err)
+		return
+	}
+
+	canary, err := db.GetCanary(tenantID, id)
+	if err != nil {
+		writeCanaryError(w, id, err)
+		return
+	}
+	recordActivity(r, activityType, "",
+		fmt.Sprintf("Canary %s %s", canary.Name, strings.ReplaceAll(status, "_", " ")),
+		map[string]interface{}{"canary_id": canary.ID, "baseline": canary.Baseline, "canary": canary.Canary})
+	json.NewEncoder(w).Encode(canary)
+}
+
+// writeCanary writes a canary of a tenant with its metrics
+func writeCanary(w http.ResponseWriter, tenantID, id string) {
+	canary, err := db.GetCanary(tenantID, id)
+	if err != nil {
+		writeCanaryError(w, id, err)
+		return
+	}
+	json.NewEncoder(w).Encode(canary)
+}
+
+// writeCanaryError maps a canary storage error to a response
+func writeCanaryError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, "Canary not found", http.StatusNotFound)
+	case strings.Contains(err.Error(), "canary is"):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Error updating canary %s: %v", id, err)
+		http.Error(w, "Failed to update canary", http.StatusInternalServerError)
+	}
+}
+
+// withCanary routes an analysis through the canaries of its type of the tenant of the
+// request. The last promoted canary is the baseline model and prompt; the active canary
+// receives its percentage of the traffic, and the outcome of every analysis run while it
+// is active is recorded for its arm.
+func withCanary(analysisType string, run analysisFunc) analysisFunc {
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		// Mock responses and dry runs say nothing about the quality of a model, and
+		// replays use the model they ask for
+		if useMockData(req.Parameters) || core.IsDryRun(ctx) || isReplay(ctx) {
+			return run(ctx, req)
+		}
+
+		canaries, err := db.ListCanaries(auth.TenantID(ctx), db.CanaryActive, db.CanaryPromoted)
+		if err != nil {
+			log.Printf("Error loading canaries, using the configured model: %v", err)
+			return run(ctx, req)
+		}
+
+		var baseline core.Variant
+		var active *db.Canary
+		var promotedAt time.Time
+		for i, canary := range canaries {
+			if !canary.Covers(analysisType) {
+				continue
+			}
+			switch canary.Status {
+			case db.CanaryActive:
+				active = &canaries[i]
+			case db.CanaryPromoted:
+				if canary.EndedAt != nil && canary.EndedAt.After(promotedAt) {
+					promotedAt = *canary.EndedAt
+					baseline = mergeVariant(core.Variant{}, canary)
+				}
+			}
+		}
+		if active == nil {
+			return run(core.WithVariant(ctx, baseline), req)
+		}
+
+		arm, variant := db.CanaryArmBaseline, baseline
+		if rand.Float64()*100 < active.Percentage {
+			arm, variant = db.CanaryArmCanary, mergeVariant(baseline, *active)
+		}
+
+		ctx, quality := core.WithOutputQuality(core.WithVariant(ctx, variant))
+		start := time.Now()
+		resp, err := run(ctx, req)
+
+		totals := quality.Totals()
+		observation := db.CanaryObservation{
+			Failed:   err != nil || resp == nil || resp.Error != nil,
+			Outputs:  totals.Outputs,
+			Repaired: totals.Repaired,
+			Invalid:  totals.Invalid,
+			Latency:  time.Since(start),
+		}
+		if !observation.Failed {
+			observation.Confidence = resp.Confidence
+		}
+		if recordErr := db.RecordCanaryObservation(active.ID, arm, observation); recordErr != nil {
+			log.Printf("Error recording canary observation: %v", recordErr)
+		}
+		return resp, err
+	}
+}
+
+// mergeVariant applies the model and prompt of a canary over a variant
+func mergeVariant(variant core.Variant, canary db.Canary) core.Variant {
+	if canary.Model != "" {
+		variant.Model = canary.Model
+	}
+	if canary.PromptInstructions != "" {
+		variant.Instructions = canary.PromptInstructions
+	}
+	return variant
+}
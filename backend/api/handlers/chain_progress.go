@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// runIDPattern is the form of a run ID chosen by the client
+var runIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// validateRunID checks a run ID chosen by the client; empty IDs are generated
+func validateRunID(runID string) error {
+	if runID != "" && !runIDPattern.MatchString(runID) {
+		return fmt.Errorf("run_id must be 1 to 64 letters, digits, dots, dashes or underscores")
+	}
+	return nil
+}
+
+// chainProgress records the progress of a chain run as its steps report in, so it can
+// be polled while the chain runs
+type chainProgress struct {
+	runID  string
+	prices usagePrices
+
+	mu    sync.Mutex
+	steps map[string]*db.ChainRunStep
+}
+
+// startChainProgress records the start of a chain run for a tenant. A client may choose
+// the run ID to poll the progress before the response arrives; it must not be in use.
+func startChainProgress(tenantID, runID, workflowID string) (*chainProgress, error) {
+	if runID == "" {
+		runID = uuid.New().String()
+	}
+	if err := db.CreateChainRun(tenantID, runID, workflowID, time.Now()); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return nil, invalidRequest(fmt.Errorf("run_id %s is already in use", runID))
+		}
+		return nil, fmt.Errorf("failed to record chain run: %w", err)
+	}
+	return &chainProgress{runID: runID, prices: tokenPrices(), steps: map[string]*db.ChainRunStep{}}, nil
+}
+
+// record stores a step event. Progress is only reported, so failing to store it is
+// logged rather than failing the chain.
+func (p *chainProgress) record(event core.ChainStepEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	step, ok := p.steps[event.Step]
+	if !ok {
+		step = &db.ChainRunStep{Step: event.Step, StepNum: event.StepNum}
+		p.steps[event.Step] = step
+	}
+	step.Status = event.Status
+	if !event.Started.IsZero() {
+		started := event.Started
+		step.StartedAt = &started
+	}
+	if event.Status == core.StepSucceeded || event.Status == core.StepFailed {
+		step.DurationMS = event.Duration.Milliseconds()
+		step.Calls = event.Usage.Calls
+		step.PromptTokens = event.Usage.PromptTokens
+		step.CompletionTokens = event.Usage.CompletionTokens
+		step.Cost = p.prices.usageCost(event.Usage)
+	}
+	if event.Err != nil {
+		step.Error = event.Err.Error()
+	}
+
+	if err := db.SaveChainRunStep(p.runID, *step); err != nil {
+		log.Printf("Error saving progress of chain run %s: %v", p.runID, err)
+	}
+}
+
+// finish records the end of the chain run. Steps still pending after a failure are
+// marked skipped.
+func (p *chainProgress) finish(chainErr error) {
+	status, message := db.ChainRunSucceeded, ""
+	if chainErr != nil {
+		status, message = db.ChainRunFailed, chainErr.Error()
+
+		p.mu.Lock()
+		for _, step := range p.steps {
+			if step.Status != core.StepPending && step.Status != core.StepRunning {
+				continue
+			}
+			step.Status = core.StepSkipped
+			if err := db.SaveChainRunStep(p.runID, *step); err != nil {
+				log.Printf("Error saving progress of chain run %s: %v", p.runID, err)
+			}
+		}
+		p.mu.Unlock()
+	}
+	if err := db.FinishChainRun(p.runID, status, message); err != nil {
+		log.Printf("Error finishing chain run %s: %v", p.runID, err)
+	}
+}
+
+// trace returns the progress of each step in chain order
+func (p *chainProgress) trace() []db.ChainRunStep {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	trace := make([]db.ChainRunStep, 0, len(p.steps))
+	for _, step := range p.steps {
+		trace = append(trace, *step)
+	}
+	sort.Slice(trace, func(i, j int) bool { return trace[i].StepNum < trace[j].StepNum })
+	return trace
+}
+
+// HandleChainRun handles GET /api/analysis/chain/{run_id}/progress, which reports the
+// status, duration and token usage of each step of a chain run, during and after it
+func (h *AnalysisHandler) HandleChainRun(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	runID, found := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/analysis/chain/"), "/progress")
+	if !found || runID == "" || strings.Contains(runID, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	run, err := db.GetChainRun(auth.TenantID(r.Context()), runID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Chain run not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting chain run %s: %v", runID, err)
+		http.Error(w, "Failed to get chain run", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(run)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/cluster"
+	"agenticflows/backend/db"
+)
+
+// Environment variables configuring the conversation tiering policy
+const (
+	// envTieringAfterMonths moves conversations older than this many months out of the
+	// hot tier; tiering is disabled while it is unset
+	envTieringAfterMonths = "CONVERSATION_TIERING_AFTER_MONTHS"
+	// envTieringTier is the tier old conversations move to: cold (default) or dropped
+	envTieringTier = "CONVERSATION_TIERING_TIER"
+	// envTieringInterval sets how often the policy is applied
+	envTieringInterval = "CONVERSATION_TIERING_INTERVAL"
+)
+
+// Tiering defaults
+const (
+	defaultTieringInterval = 24 * time.Hour
+	tieringBatchSize       = 500
+)
+
+// tieringPolicy moves conversations older than AfterMonths to Tier
+type tieringPolicy struct {
+	AfterMonths int    `json:"after_months"`
+	Tier        string `json:"tier"`
+	Interval    string `json:"interval"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// tieringRequest is the body of a request to apply a tiering policy now; unset fields
+// default to the configured policy
+type tieringRequest struct {
+	AfterMonths int    `json:"after_months,omitempty"`
+	Tier        string `json:"tier,omitempty"`
+	Limit       int    `json:"limit,omitempty"`
+	DryRun      bool   `json:"dry_run,omitempty"`
+}
+
+// tieringPolicyFromEnv reads the configured tiering policy
+func tieringPolicyFromEnv() (tieringPolicy, time.Duration) {
+	policy := tieringPolicy{Tier: db.StorageTierCold}
+
+	if value := os.Getenv(envTieringAfterMonths); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			policy.AfterMonths = n
+			policy.Enabled = true
+		} else {
+			log.Printf("Warning: ignoring invalid %s value %q", envTieringAfterMonths, value)
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv(envTieringTier)); value != "" {
+		if value == db.StorageTierCold || value == db.StorageTierDropped {
+			policy.Tier = value
+		} else {
+			log.Printf("Warning: ignoring invalid %s value %q", envTieringTier, value)
+		}
+	}
+
+	interval := defaultTieringInterval
+	if value := os.Getenv(envTieringInterval); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("Warning: ignoring invalid %s value %q", envTieringInterval, value)
+		}
+	}
+	policy.Interval = interval.String()
+	return policy, interval
+}
+
+// StartConversationTiering applies the configured tiering policy in the background. When
+// several instances share the database, only the elected leader applies it.
+func StartConversationTiering() {
+	policy, interval := tieringPolicyFromEnv()
+	if !policy.Enabled {
+		return
+	}
+	log.Printf("Conversation tiering enabled: conversations older than %d months move to %s storage", policy.AfterMonths, policy.Tier)
+
+	go cluster.RunAsLeader(context.Background(), "conversation-tiering", func(ctx context.Context) {
+		for {
+			result, err := applyTieringPolicy(policy.AfterMonths, policy.Tier, 0, false)
+			if err != nil {
+				log.Printf("Error applying conversation tiering policy: %v", err)
+			} else if result.Moved > 0 {
+				log.Printf("Moved %d conversations to %s storage", result.Moved, result.Tier)
+				recordActivityAs(db.AllTenants, "system", db.ActivityConversationsTiered, "",
+					fmt.Sprintf("%d conversations moved to %s storage", result.Moved, result.Tier), result)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	})
+}
+
+// applyTieringPolicy moves conversations older than afterMonths to tier in batches, at
+// most limit conversations, or all of them when limit is 0
+func applyTieringPolicy(afterMonths int, tier string, limit int, dryRun bool) (db.TieringResult, error) {
+	cutoff := time.Now().AddDate(0, -afterMonths, 0)
+	total := db.TieringResult{Cutoff: cutoff, Tier: tier, DryRun: dryRun}
+	for limit == 0 || total.Moved < limit {
+		batch := tieringBatchSize
+		if limit > 0 {
+			batch = min(batch, limit-total.Moved)
+		}
+		result, err := db.TierConversations(cutoff, tier, batch, dryRun)
+		total.Moved += result.Moved
+		total.Freed += result.Freed
+		if err != nil {
+			return total, err
+		}
+		// A dry run sees the same batch again, and a short batch is the last one
+		if dryRun || result.Moved < batch {
+			break
+		}
+	}
+	return total, nil
+}
+
+// HandleStorageTiering handles /api/storage/tiering: GET returns the tiering policy and
+// the number of conversations per tier, and POST applies a policy now
+func HandleStorageTiering(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	policy, _ := tieringPolicyFromEnv()
+
+	switch r.Method {
+	case http.MethodGet:
+		counts, err := db.CountConversationTiers()
+		if err != nil {
+			log.Printf("Error counting conversation tiers: %v", err)
+			http.Error(w, "Failed to count conversation tiers", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"policy": policy,
+			"tiers":  counts,
+		})
+
+	case http.MethodPost:
+		var req tieringRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if req.AfterMonths == 0 {
+			req.AfterMonths = policy.AfterMonths
+		}
+		if req.Tier == "" {
+			req.Tier = policy.Tier
+		}
+		if req.AfterMonths <= 0 {
+			http.Error(w, "after_months is required when no tiering policy is configured", http.StatusBadRequest)
+			return
+		}
+		if req.Tier != db.StorageTierCold && req.Tier != db.StorageTierDropped {
+			http.Error(w, fmt.Sprintf("tier must be %s or %s", db.StorageTierCold, db.StorageTierDropped), http.StatusBadRequest)
+			return
+		}
+		if req.Limit < 0 {
+			http.Error(w, "limit must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		result, err := applyTieringPolicy(req.AfterMonths, req.Tier, req.Limit, req.DryRun)
+		if err != nil {
+			log.Printf("Error applying conversation tiering: %v", err)
+			http.Error(w, "Failed to apply conversation tiering", http.StatusInternalServerError)
+			return
+		}
+		if !req.DryRun && result.Moved > 0 {
+			recordActivity(r, db.ActivityConversationsTiered, "",
+				fmt.Sprintf("%d conversations moved to %s storage", result.Moved, result.Tier), result)
+		}
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// requireConversationText checks that the text of conversations needed for an analysis
+// was not dropped by the tiering policy
+func requireConversationText(conversations []db.Conversation) error {
+	var dropped []string
+	for _, conversation := range conversations {
+		if conversation.StorageTier == db.StorageTierDropped {
+			dropped = append(dropped, conversation.ID)
+		}
+	}
+	if len(dropped) > 0 {
+		return fmt.Errorf("the text of conversations %s was dropped by the tiering policy; their extracted attributes remain available", strings.Join(dropped, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,879 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/cluster"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// Environment variables configuring conversation uploads
+const (
+	// envConversationUploadDir is the directory uploaded files are kept in until ingested
+	envConversationUploadDir = "CONVERSATION_UPLOAD_DIR"
+	// envConversationUploadMaxBytes bounds the size of an uploaded file
+	envConversationUploadMaxBytes = "CONVERSATION_UPLOAD_MAX_BYTES"
+)
+
+// Conversation upload limits
+const (
+	defaultMaxUploadBytes = 1 << 30
+	maxUploadChunkBytes   = 64 << 20
+	// uploadBatchSize is how many conversations are stored per transaction
+	uploadBatchSize = 500
+	// maxUploadErrors bounds the invalid rows reported; the rest are only counted
+	maxUploadErrors = 100
+	// uploadExpiry is how long an upload may sit unfinished before its file is deleted
+	uploadExpiry         = 24 * time.Hour
+	uploadExpiryInterval = time.Hour
+	// uploadIngestWorkers bounds the uploads an instance ingests at once
+	uploadIngestWorkers = 2
+)
+
+var (
+	// uploadLocks serializes the requests changing an upload. Entries only exist while
+	// requests hold or wait for them, so finished, canceled and expired uploads leave none.
+	uploadLocksMu sync.Mutex
+	uploadLocks   = map[string]*uploadLock{}
+
+	uploadIngestSlots = make(chan struct{}, uploadIngestWorkers)
+)
+
+// conversationUploadRequest is the body starting a chunked upload
+type conversationUploadRequest struct {
+	Format   string `json:"format,omitempty"` // csv or jsonl; taken from the filename when empty
+	Filename string `json:"filename,omitempty"`
+	Source   string `json:"source,omitempty"` // Source of the conversations that don't name one
+}
+
+// conversationUploadListResponse lists the recent uploads
+type conversationUploadListResponse struct {
+	Uploads []db.ConversationUpload `json:"uploads"`
+}
+
+// HandleConversationUploads handles /api/conversations/upload: POST uploads a CSV or JSONL
+// file in one request or starts a chunked upload, GET lists uploads, and for
+// /api/conversations/upload/{id} PUT appends a chunk, POST .../complete starts ingestion,
+// GET reports progress and validation errors and DELETE cancels the upload
+func HandleConversationUploads(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/conversations/upload"), "/")
+	id, action, _ := strings.Cut(path, "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		listConversationUploads(w, r)
+	case id == "" && r.Method == http.MethodPost:
+		startConversationUpload(w, r)
+	case id == "":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case action == "" && r.Method == http.MethodGet:
+		getConversationUpload(w, r, id)
+	case action == "" && r.Method == http.MethodPut:
+		receiveUploadChunk(w, r, id)
+	case action == "" && r.Method == http.MethodDelete:
+		cancelConversationUpload(w, r, id)
+	case action == "complete" && r.Method == http.MethodPost:
+		completeConversationUpload(w, r, id)
+	case action == "" || action == "complete":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// listConversationUploads returns the most recent uploads of the tenant of the request
+func listConversationUploads(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxListConversations)
+	}
+	uploads, err := db.ListConversationUploads(auth.TenantID(r.Context()), limit)
+	if err != nil {
+		log.Printf("Error listing conversation uploads: %v", err)
+		http.Error(w, "Failed to list uploads", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(conversationUploadListResponse{Uploads: uploads})
+}
+
+// getConversationUpload returns the status, progress and validation report of an upload
+func getConversationUpload(w http.ResponseWriter, r *http.Request, id string) {
+	upload, ok := loadConversationUpload(w, r, id)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(upload)
+}
+
+// startConversationUpload starts a chunked upload for a JSON body, and otherwise stores
+// the file of a multipart form or of the raw body and starts ingesting it
+func startConversationUpload(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	query := r.URL.Query()
+	upload := db.ConversationUpload{
+		ID:       uuid.New().String(),
+		Filename: query.Get("filename"),
+		Source:   query.Get("source"),
+		Status:   db.UploadReceiving,
+		Actor:    actorFromRequest(r),
+		TenantID: auth.TenantID(r.Context()),
+		Instance: cluster.InstanceID(),
+	}
+	format := query.Get("format")
+
+	if mediaType == "application/json" {
+		var req conversationUploadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		upload.Filename, upload.Source = cleanUploadFilename(req.Filename), req.Source
+		var err error
+		if upload.Format, err = uploadFormat(req.Format, upload.Filename, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := createUploadFile(upload.ID); err != nil {
+			log.Printf("Error creating upload file: %v", err)
+			http.Error(w, "Failed to start upload", http.StatusInternalServerError)
+			return
+		}
+		if err := db.CreateConversationUpload(upload); err != nil {
+			os.Remove(uploadPath(upload.ID))
+			log.Printf("Error creating conversation upload: %v", err)
+			http.Error(w, "Failed to start upload", http.StatusInternalServerError)
+			return
+		}
+		stored, err := db.GetConversationUpload(upload.TenantID, upload.ID)
+		if err != nil || stored == nil {
+			stored = &upload
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(stored)
+		return
+	}
+
+	// The file comes in this request: stream it to disk before recording the upload
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes())
+	var content io.Reader = r.Body
+	contentType := mediaType
+	if mediaType == "multipart/form-data" {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		part, err := uploadFilePart(reader, &format, &upload.Source)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer part.Close()
+		if name := part.FileName(); name != "" {
+			upload.Filename = name
+		}
+		contentType, _, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+		content = part
+	}
+	upload.Filename = cleanUploadFilename(upload.Filename)
+
+	var err error
+	if upload.Format, err = uploadFormat(format, upload.Filename, contentType); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := createUploadFile(upload.ID); err != nil {
+		log.Printf("Error creating upload file: %v", err)
+		http.Error(w, "Failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	written, err := appendUploadFile(upload.ID, 0, content)
+	if err != nil {
+		os.Remove(uploadPath(upload.ID))
+		writeUploadCopyError(w, err)
+		return
+	}
+	if written == 0 {
+		os.Remove(uploadPath(upload.ID))
+		http.Error(w, "The uploaded file is empty", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.CreateConversationUpload(upload); err == nil {
+		err = db.SetConversationUploadReceived(upload.ID, written)
+	}
+	if err != nil {
+		os.Remove(uploadPath(upload.ID))
+		log.Printf("Error creating conversation upload: %v", err)
+		http.Error(w, "Failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	upload.ReceivedBytes = written
+	beginUploadIngestion(w, &upload)
+}
+
+// receiveUploadChunk appends the body to an upload. A Content-Range header must start
+// at the bytes received so far; a chunk sent again after a lost response is rejected
+// with the received size, from which the client resumes.
+func receiveUploadChunk(w http.ResponseWriter, r *http.Request, id string) {
+	unlock := lockUpload(id)
+	defer unlock()
+
+	upload, ok := loadConversationUpload(w, r, id)
+	if !ok || !uploadHeldHere(w, upload) {
+		return
+	}
+	if upload.Status != db.UploadReceiving {
+		http.Error(w, fmt.Sprintf("upload is %s and accepts no more data", upload.Status), http.StatusConflict)
+		return
+	}
+
+	if value := r.Header.Get("Content-Range"); value != "" {
+		start, err := contentRangeStart(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start != upload.ReceivedBytes {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":          fmt.Sprintf("chunk starts at byte %d but %d bytes were received", start, upload.ReceivedBytes),
+				"received_bytes": upload.ReceivedBytes,
+			})
+			return
+		}
+	}
+
+	remaining := maxUploadBytes() - upload.ReceivedBytes
+	if remaining <= 0 {
+		http.Error(w, "upload exceeds the maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, min(remaining, maxUploadChunkBytes))
+	written, err := appendUploadFile(upload.ID, upload.ReceivedBytes, body)
+	if err != nil {
+		writeUploadCopyError(w, err)
+		return
+	}
+	upload.ReceivedBytes += written
+	if err := db.SetConversationUploadReceived(upload.ID, upload.ReceivedBytes); err != nil {
+		log.Printf("Error saving conversation upload %s: %v", upload.ID, err)
+		http.Error(w, "Failed to store chunk", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(upload)
+}
+
+// completeConversationUpload starts ingesting a chunked upload, or ingesting a failed
+// upload again
+func completeConversationUpload(w http.ResponseWriter, r *http.Request, id string) {
+	unlock := lockUpload(id)
+	defer unlock()
+
+	upload, ok := loadConversationUpload(w, r, id)
+	if !ok || !uploadHeldHere(w, upload) {
+		return
+	}
+	if upload.Status != db.UploadReceiving && upload.Status != db.UploadFailed {
+		http.Error(w, fmt.Sprintf("upload is %s", upload.Status), http.StatusConflict)
+		return
+	}
+	if upload.ReceivedBytes == 0 {
+		http.Error(w, "no data was uploaded", http.StatusBadRequest)
+		return
+	}
+	upload.Actor = actorFromRequest(r)
+	beginUploadIngestion(w, upload)
+}
+
+// cancelConversationUpload deletes the file of an upload that is not being ingested
+func cancelConversationUpload(w http.ResponseWriter, r *http.Request, id string) {
+	unlock := lockUpload(id)
+	defer unlock()
+
+	upload, ok := loadConversationUpload(w, r, id)
+	if !ok || !uploadHeldHere(w, upload) {
+		return
+	}
+	if upload.Status != db.UploadReceiving && upload.Status != db.UploadFailed {
+		http.Error(w, fmt.Sprintf("upload is %s", upload.Status), http.StatusConflict)
+		return
+	}
+	upload.Status = db.UploadCanceled
+	if err := db.UpdateConversationUpload(*upload); err != nil {
+		log.Printf("Error canceling conversation upload %s: %v", upload.ID, err)
+		http.Error(w, "Failed to cancel upload", http.StatusInternalServerError)
+		return
+	}
+	os.Remove(uploadPath(upload.ID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// beginUploadIngestion marks an upload as ingesting, starts ingesting its file in the
+// background and responds with the upload
+func beginUploadIngestion(w http.ResponseWriter, upload *db.ConversationUpload) {
+	upload.Status = db.UploadIngesting
+	upload.Error = ""
+	upload.Rows, upload.Ingested, upload.Created, upload.Updated, upload.Invalid = 0, 0, 0, 0, 0
+	upload.Errors = nil
+	if err := db.UpdateConversationUpload(*upload); err != nil {
+		log.Printf("Error saving conversation upload %s: %v", upload.ID, err)
+		http.Error(w, "Failed to start ingestion", http.StatusInternalServerError)
+		return
+	}
+	go ingestConversationUpload(*upload)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(upload)
+}
+
+// ingestConversationUpload parses the file of an upload and stores its valid rows in
+// batches for the tenant that uploaded it, saving the progress after each batch
+func ingestConversationUpload(upload db.ConversationUpload) {
+	uploadIngestSlots <- struct{}{}
+	defer func() { <-uploadIngestSlots }()
+
+	fail := func(err error) {
+		log.Printf("Error ingesting conversation upload %s: %v", upload.ID, err)
+		upload.Status = db.UploadFailed
+		upload.Error = err.Error()
+		if err := db.UpdateConversationUpload(upload); err != nil {
+			log.Printf("Error saving conversation upload %s: %v", upload.ID, err)
+		}
+	}
+
+	file, err := os.Open(uploadPath(upload.ID))
+	if err != nil {
+		fail(fmt.Errorf("failed to open the uploaded file: %w", err))
+		return
+	}
+	defer file.Close()
+
+	records, err := newUploadRecordReader(upload.Format, file)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	invalid := func(row int, id string, err error) {
+		upload.Invalid++
+		if len(upload.Errors) < maxUploadErrors {
+			upload.Errors = append(upload.Errors, db.UploadRowError{Row: row, ConversationID: id, Error: err.Error()})
+		}
+	}
+
+	seen := map[string]bool{}
+	batch := make([]db.Conversation, 0, uploadBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		created, updated, err := db.IngestConversations(upload.TenantID, batch)
+		if err != nil {
+			return fmt.Errorf("failed to store conversations: %w", err)
+		}
+		upload.Ingested += len(batch)
+		upload.Created += created
+		upload.Updated += updated
+		batch = batch[:0]
+		return db.UpdateConversationUpload(upload)
+	}
+
+	for {
+		record, row, err := records.next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *uploadRowError
+		if errors.As(err, &rowErr) {
+			upload.Rows++
+			invalid(row, "", rowErr.err)
+			continue
+		}
+		if err != nil {
+			fail(fmt.Errorf("failed to read the uploaded file: %w", err))
+			return
+		}
+		upload.Rows++
+
+		conversation, err := uploadConversation(record, upload.Source)
+		if err != nil {
+			invalid(row, conversation.ID, err)
+			continue
+		}
+		if seen[conversation.ID] {
+			invalid(row, conversation.ID, fmt.Errorf("duplicate conversation_id %s", conversation.ID))
+			continue
+		}
+		seen[conversation.ID] = true
+
+		batch = append(batch, conversation)
+		if len(batch) == uploadBatchSize {
+			if err := flush(); err != nil {
+				fail(err)
+				return
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		fail(err)
+		return
+	}
+
+	now := time.Now()
+	upload.Status = db.UploadCompleted
+	upload.CompletedAt = &now
+	if err := db.UpdateConversationUpload(upload); err != nil {
+		log.Printf("Error saving conversation upload %s: %v", upload.ID, err)
+	}
+	file.Close()
+	os.Remove(uploadPath(upload.ID))
+
+	summary := fmt.Sprintf("%d conversations ingested", upload.Ingested)
+	if upload.Filename != "" {
+		summary += " from " + upload.Filename
+	}
+	recordActivityAs(upload.TenantID, upload.Actor, db.ActivityConversationsIngested, "", summary,
+		map[string]interface{}{"upload_id": upload.ID, "created": upload.Created, "updated": upload.Updated, "invalid": upload.Invalid})
+}
+
+// conversationUploadFields are the columns of an uploaded row saved as fields of a
+// conversation rather than as metadata
+var conversationUploadFields = map[string]bool{
+	"conversation_id": true, "customer_id": true, "text": true, "date_time": true, "source": true, "metadata": true,
+}
+
+// uploadConversation converts an uploaded row into a conversation. Columns that are not
+// conversation fields are kept as metadata; in CSV files a metadata column holds a JSON
+// object.
+func uploadConversation(record map[string]interface{}, source string) (db.Conversation, error) {
+	var conversation db.Conversation
+	if id, ok := record["conversation_id"].(string); ok {
+		conversation.ID = id
+	} else if id, ok := record["conversation_id"].(float64); ok {
+		record["conversation_id"] = strconv.FormatFloat(id, 'f', -1, 64)
+		conversation.ID = record["conversation_id"].(string)
+	}
+	if metadata, ok := record["metadata"].(string); ok {
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(metadata), &decoded); err != nil {
+			return conversation, fmt.Errorf("metadata is not a JSON object: %w", err)
+		}
+		record["metadata"] = decoded
+	}
+
+	fields := make(map[string]interface{}, len(conversationUploadFields))
+	for field := range conversationUploadFields {
+		if value, ok := record[field]; ok {
+			fields[field] = value
+		}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return conversation, err
+	}
+	if err := json.Unmarshal(data, &conversation); err != nil {
+		var timeErr *time.ParseError
+		if errors.As(err, &timeErr) {
+			return conversation, fmt.Errorf("date_time must be an RFC3339 timestamp")
+		}
+		return conversation, fmt.Errorf("not a conversation: %w", err)
+	}
+
+	if strings.TrimSpace(conversation.Text) == "" {
+		return conversation, fmt.Errorf("no text")
+	}
+	if conversation.ID == "" {
+		conversation.ID = uuid.New().String()
+	}
+	if conversation.Source == "" {
+		conversation.Source = source
+	}
+	for field, value := range record {
+		if conversationUploadFields[field] {
+			continue
+		}
+		if conversation.Metadata == nil {
+			conversation.Metadata = map[string]interface{}{}
+		}
+		conversation.Metadata[field] = value
+	}
+	return conversation, nil
+}
+
+// uploadRowError is a row of an uploaded file that can't be read; the rows after it are
+type uploadRowError struct {
+	err error
+}
+
+func (e *uploadRowError) Error() string {
+	return e.err.Error()
+}
+
+// uploadRecordReader streams the rows of an uploaded file
+type uploadRecordReader interface {
+	// next returns a row and its line, an *uploadRowError for an unreadable row, or
+	// io.EOF after the last row
+	next() (map[string]interface{}, int, error)
+}
+
+// newUploadRecordReader returns the reader of a file format
+func newUploadRecordReader(format string, r io.Reader) (uploadRecordReader, error) {
+	switch format {
+	case db.UploadFormatCSV:
+		return newCSVRecordReader(r)
+	case db.UploadFormatJSONL:
+		return &jsonlRecordReader{reader: bufio.NewReaderSize(r, 1<<20)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// csvRecordReader reads a CSV file whose header names the columns, one of them text
+type csvRecordReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVRecordReader(r io.Reader) (*csvRecordReader, error) {
+	reader := csv.NewReader(bufio.NewReaderSize(r, 1<<20))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	hasText := false
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[i] = strings.ToLower(strings.TrimSpace(name))
+		hasText = hasText || columns[i] == "text"
+	}
+	if !hasText {
+		return nil, fmt.Errorf("the CSV header has no text column")
+	}
+	return &csvRecordReader{reader: reader, header: columns}, nil
+}
+
+func (c *csvRecordReader) next() (map[string]interface{}, int, error) {
+	fields, err := c.reader.Read()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, parseErr.StartLine, &uploadRowError{err: parseErr.Err}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	line, _ := c.reader.FieldPos(0)
+	if len(fields) > len(c.header) {
+		return nil, line, &uploadRowError{err: fmt.Errorf("row has %d fields but the header has %d", len(fields), len(c.header))}
+	}
+
+	record := make(map[string]interface{}, len(fields))
+	for i, value := range fields {
+		if value != "" && c.header[i] != "" {
+			record[c.header[i]] = value
+		}
+	}
+	return record, line, nil
+}
+
+// jsonlRecordReader reads a file of one JSON object per line, skipping empty lines
+type jsonlRecordReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func (j *jsonlRecordReader) next() (map[string]interface{}, int, error) {
+	for {
+		data, err := j.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		if len(data) == 0 && err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		j.line++
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			if err == io.EOF {
+				return nil, 0, io.EOF
+			}
+			continue
+		}
+
+		var record map[string]interface{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, j.line, &uploadRowError{err: fmt.Errorf("not a JSON object: %w", err)}
+		}
+		return record, j.line, nil
+	}
+}
+
+// StartConversationUploads fails the uploads this instance was ingesting when it stopped,
+// which can be completed again, and deletes the files of abandoned uploads. Uploaded
+// files are kept on the instance that received them, so every instance cleans up its own.
+func StartConversationUploads() {
+	instance := cluster.InstanceID()
+	interrupted, err := db.InterruptConversationUploads(instance, "ingestion was interrupted by a restart; complete the upload again")
+	if err != nil {
+		log.Printf("Error failing interrupted conversation uploads: %v", err)
+	}
+	if interrupted > 0 {
+		log.Printf("Failed %d conversation uploads interrupted by a restart", interrupted)
+	}
+
+	go func() {
+		for {
+			ids, err := db.ExpireConversationUploads(instance, time.Now().Add(-uploadExpiry))
+			if err != nil {
+				log.Printf("Error expiring conversation uploads: %v", err)
+			}
+			for _, id := range ids {
+				os.Remove(uploadPath(id))
+			}
+			if len(ids) > 0 {
+				log.Printf("Deleted %d abandoned conversation uploads", len(ids))
+			}
+			time.Sleep(uploadExpiryInterval)
+		}
+	}()
+}
+
+// loadConversationUpload returns an upload of the tenant of the request, responding with
+// an error when there is none
+func loadConversationUpload(w http.ResponseWriter, r *http.Request, id string) (*db.ConversationUpload, bool) {
+	upload, err := db.GetConversationUpload(auth.TenantID(r.Context()), id)
+	if err != nil {
+		log.Printf("Error loading conversation upload %s: %v", id, err)
+		http.Error(w, "Failed to load upload", http.StatusInternalServerError)
+		return nil, false
+	}
+	if upload == nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return nil, false
+	}
+	return upload, true
+}
+
+// uploadHeldHere reports whether this instance holds the file of an upload, responding
+// with a conflict when another instance does
+func uploadHeldHere(w http.ResponseWriter, upload *db.ConversationUpload) bool {
+	if upload.Instance == cluster.InstanceID() {
+		return true
+	}
+	http.Error(w, fmt.Sprintf("upload is held by instance %s", upload.Instance), http.StatusConflict)
+	return false
+}
+
+// uploadLock is the lock of an upload with the number of requests holding or waiting for it
+type uploadLock struct {
+	sync.Mutex
+	users int
+}
+
+// lockUpload locks an upload against concurrent changes and returns its unlock, which
+// drops the lock once no other request waits for it
+func lockUpload(id string) func() {
+	uploadLocksMu.Lock()
+	lock, ok := uploadLocks[id]
+	if !ok {
+		lock = &uploadLock{}
+		uploadLocks[id] = lock
+	}
+	lock.users++
+	uploadLocksMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		uploadLocksMu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(uploadLocks, id)
+		}
+		uploadLocksMu.Unlock()
+	}
+}
+
+// uploadFormat returns the format named, or else the one of the filename extension or
+// content type
+func uploadFormat(format, filename, contentType string) (string, error) {
+	switch strings.ToLower(format) {
+	case db.UploadFormatCSV:
+		return db.UploadFormatCSV, nil
+	case db.UploadFormatJSONL, "ndjson":
+		return db.UploadFormatJSONL, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid format %q: must be csv or jsonl", format)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return db.UploadFormatCSV, nil
+	case ".jsonl", ".ndjson":
+		return db.UploadFormatJSONL, nil
+	}
+	switch contentType {
+	case "text/csv":
+		return db.UploadFormatCSV, nil
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return db.UploadFormatJSONL, nil
+	}
+	return "", fmt.Errorf("format is required: csv or jsonl")
+}
+
+// uploadFilePart returns the file part of a multipart upload, reading the format and
+// source fields that precede it
+func uploadFilePart(reader *multipart.Reader, format, source *string) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the form has no file field")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+		switch part.FormName() {
+		case "file":
+			return part, nil
+		case "format", "source":
+			value, err := io.ReadAll(io.LimitReader(part, 1024))
+			part.Close()
+			if err != nil {
+				return nil, fmt.Errorf("invalid multipart body: %w", err)
+			}
+			if part.FormName() == "format" {
+				*format = strings.TrimSpace(string(value))
+			} else {
+				*source = strings.TrimSpace(string(value))
+			}
+		default:
+			part.Close()
+		}
+	}
+}
+
+// contentRangeStart returns the first byte of a Content-Range header such as
+// "bytes 0-1048575/*"
+func contentRangeStart(value string) (int64, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if ok {
+		var first string
+		first, _, ok = strings.Cut(spec, "-")
+		if start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64); ok && err == nil && start >= 0 {
+			return start, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid Content-Range %q", value)
+}
+
+// createUploadFile creates the empty file of an upload
+func createUploadFile(id string) error {
+	if err := os.MkdirAll(uploadDir(), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(uploadPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// appendUploadFile appends content to the file of an upload holding size bytes and
+// returns how many bytes it appended. When the copy fails the file is cut back to size,
+// so a chunk is stored whole or not at all.
+func appendUploadFile(id string, size int64, content io.Reader) (int64, error) {
+	file, err := os.OpenFile(uploadPath(id), os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, content)
+	if err != nil {
+		file.Truncate(size)
+		return 0, err
+	}
+	return written, file.Sync()
+}
+
+// writeUploadCopyError responds to a failure storing uploaded data
+func writeUploadCopyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("upload exceeds the maximum size of %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	log.Printf("Error storing uploaded data: %v", err)
+	http.Error(w, "Failed to store uploaded data", http.StatusInternalServerError)
+}
+
+// cleanUploadFilename keeps the base name of an uploaded file
+func cleanUploadFilename(name string) string {
+	if name == "" {
+		return ""
+	}
+	return filepath.Base(filepath.Clean(name))
+}
+
+// uploadDir returns the directory uploaded files are kept in
+func uploadDir() string {
+	if dir := os.Getenv(envConversationUploadDir); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "agenticflows-uploads")
+}
+
+// uploadPath returns the file of an upload
+func uploadPath(id string) string {
+	return filepath.Join(uploadDir(), id+".upload")
+}
+
+// maxUploadBytes returns the maximum size of an uploaded file
+var maxUploadBytes = sync.OnceValue(func() int64 {
+	if value := os.Getenv(envConversationUploadMaxBytes); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("Warning: ignoring invalid %s value %q", envConversationUploadMaxBytes, value)
+	}
+	return defaultMaxUploadBytes
+})
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// Conversation ingestion limits
+const (
+	maxIngestConversations = 5000
+	maxListConversations   = 500
+)
+
+// conversationIngestRequest is the body of a bulk conversation ingest
+type conversationIngestRequest struct {
+	Conversations []db.Conversation `json:"conversations"`
+
+	// RevisionReason explains why transcripts were updated, e.g. "asr_rerun"; it is
+	// recorded with the attribute changes their re-extraction produces
+	RevisionReason string `json:"revision_reason,omitempty"`
+
+	// Reextract set to false keeps the stored attributes of conversations whose text changed
+	Reextract *bool `json:"reextract,omitempty"`
+}
+
+// conversationIngestResponse reports the outcome of a bulk conversation ingest
+type conversationIngestResponse struct {
+	Created         int      `json:"created"`
+	Updated         int      `json:"updated"`
+	ConversationIDs []string `json:"conversation_ids"`
+
+	// TextChanged lists the replaced conversations whose text changed
+	TextChanged []string `json:"text_changed,omitempty"`
+	// ReextractionJobs are the analysis jobs re-extracting their stored attributes
+	ReextractionJobs []string `json:"reextraction_jobs,omitempty"`
+}
+
+// conversationListResponse is a page of stored conversations
+type conversationListResponse struct {
+	Conversations []db.Conversation `json:"conversations"`
+	Total         int               `json:"total"`
+	Limit         int               `json:"limit"`
+	Offset        int               `json:"offset"`
+}
+
+// HandleConversations handles /api/conversations: GET lists stored conversations and
+// POST ingests a batch of conversations
+func HandleConversations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		listConversations(w, r)
+	case http.MethodPost:
+		ingestConversations(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleConversation handles GET /api/conversations/{id}, GET /api/conversations/{id}/turns,
+// GET /api/conversations/{id}/attributes, GET /api/conversations/{id}/attributes/revisions
+// and GET /api/conversations/{id}/processing
+func HandleConversation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/conversations/")
+	if id == "" {
+		listConversations(w, r)
+		return
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	if conversationID, ok := strings.CutSuffix(id, "/turns"); ok {
+		getConversationTurns(w, r, conversationID)
+		return
+	}
+	if conversationID, ok := strings.CutSuffix(id, "/attributes/revisions"); ok {
+		getConversationAttributeRevisions(w, tenantID, conversationID)
+		return
+	}
+	if conversationID, ok := strings.CutSuffix(id, "/attributes"); ok {
+		getConversationAttributes(w, tenantID, conversationID)
+		return
+	}
+	if conversationID, ok := strings.CutSuffix(id, "/processing"); ok {
+		if hideConversation(w, tenantID, conversationID) {
+			return
+		}
+		getConversationProcessing(w, tenantID, conversationID)
+		return
+	}
+	if conversationID, ok := strings.CutSuffix(id, "/intents"); ok {
+		if hideConversation(w, tenantID, conversationID) {
+			return
+		}
+		getConversationIntents(w, tenantID, conversationID, r.URL.Query().Get("workflow_id"))
+		return
+	}
+
+	conversation, err := db.GetConversation(tenantID, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting conversation %s: %v", id, err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// hideConversation answers 404 when a conversation is stored by another tenant than the
+// one of the request, and reports whether it did. Records derived from conversations
+// that aren't stored, such as those of data.conversations, are shown to the tenant whose
+// workflows recorded them.
+func hideConversation(w http.ResponseWriter, tenantID, conversationID string) bool {
+	hidden, err := db.ConversationOfOtherTenant(tenantID, conversationID)
+	if err != nil {
+		log.Printf("Error getting conversation %s: %v", conversationID, err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return true
+	}
+	if hidden {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+	}
+	return hidden
+}
+
+// ingestConversations stores a batch of conversations of the tenant of the request,
+// replacing those with known IDs
+func ingestConversations(w http.ResponseWriter, r *http.Request) {
+	var req conversationIngestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Conversations) == 0 {
+		http.Error(w, "conversations are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Conversations) > maxIngestConversations {
+		http.Error(w, fmt.Sprintf("at most %d conversations can be ingested per request", maxIngestConversations), http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]string, len(req.Conversations))
+	seen := make(map[string]bool, len(req.Conversations))
+	for i := range req.Conversations {
+		conversation := &req.Conversations[i]
+		if strings.TrimSpace(conversation.Text) == "" {
+			http.Error(w, fmt.Sprintf("conversation %d has no text", i), http.StatusBadRequest)
+			return
+		}
+		if conversation.ID == "" {
+			conversation.ID = uuid.New().String()
+		}
+		if seen[conversation.ID] {
+			http.Error(w, fmt.Sprintf("duplicate conversation_id %s", conversation.ID), http.StatusBadRequest)
+			return
+		}
+		seen[conversation.ID] = true
+		ids[i] = conversation.ID
+	}
+
+	// Remember the stored transcripts to find the ones that changed
+	tenantID := auth.TenantID(r.Context())
+	previous, err := db.GetConversations(tenantID, ids)
+	if err != nil {
+		log.Printf("Error loading stored conversations: %v", err)
+		http.Error(w, "Failed to ingest conversations", http.StatusInternalServerError)
+		return
+	}
+	previousText := make(map[string]string, len(previous))
+	for _, conversation := range previous {
+		previousText[conversation.ID] = conversation.Text
+	}
+
+	created, updated, err := db.IngestConversations(tenantID, req.Conversations)
+	if errors.Is(err, db.ErrConversationIDTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error ingesting conversations: %v", err)
+		http.Error(w, "Failed to ingest conversations", http.StatusInternalServerError)
+		return
+	}
+
+	resp := conversationIngestResponse{Created: created, Updated: updated, ConversationIDs: ids}
+	for _, conversation := range req.Conversations {
+		if text, ok := previousText[conversation.ID]; ok && text != conversation.Text {
+			resp.TextChanged = append(resp.TextChanged, conversation.ID)
+		}
+	}
+	if len(resp.TextChanged) > 0 && (req.Reextract == nil || *req.Reextract) {
+		resp.ReextractionJobs, err = queueAttributeReextraction(r, resp.TextChanged, req.RevisionReason)
+		if err != nil {
+			log.Printf("Error queueing attribute re-extraction: %v", err)
+		}
+	}
+
+	recordActivity(r, db.ActivityConversationsIngested, "",
+		fmt.Sprintf("%d conversations ingested", len(ids)),
+		map[string]interface{}{"created": created, "updated": updated, "text_changed": len(resp.TextChanged)})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// listConversations returns a page of conversations matching the query filters
+func listConversations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.ConversationFilter{
+		TenantID:   auth.TenantID(r.Context()),
+		Source:     query.Get("source"),
+		CustomerID: query.Get("customer_id"),
+		Search:     query.Get("q"),
+		Limit:      50,
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(n, maxListConversations)
+	}
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		filter.Offset = n
+	}
+
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			http.Error(w, "since must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.Since = &t
+	}
+	if until := query.Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			http.Error(w, "until must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.Until = &t
+	}
+
+	conversations, total, err := db.ListConversations(filter)
+	if err != nil {
+		log.Printf("Error listing conversations: %v", err)
+		http.Error(w, "Failed to list conversations", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(conversationListResponse{
+		Conversations: conversations,
+		Total:         total,
+		Limit:         filter.Limit,
+		Offset:        filter.Offset,
+	})
+}
+
+// getConversationAttributes sends the attributes extracted from a stored conversation of
+// a tenant
+func getConversationAttributes(w http.ResponseWriter, tenantID, conversationID string) {
+	if _, err := db.GetConversation(tenantID, conversationID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting conversation %s: %v", conversationID, err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+
+	attributes, err := db.GetConversationAttributes(conversationID)
+	if err != nil {
+		log.Printf("Error getting attributes of conversation %s: %v", conversationID, err)
+		http.Error(w, "Failed to get conversation attributes", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"conversation_id": conversationID,
+		"attributes":      attributes,
+	})
+}
+
+// getConversationProcessing returns the cost and latency recorded for a conversation by
+// the last run of each analysis type of the tenant
+func getConversationProcessing(w http.ResponseWriter, tenantID, conversationID string) {
+	records, err := db.GetProcessingOfConversation(tenantID, conversationID)
+	if err != nil {
+		log.Printf("Error getting processing of conversation %s: %v", conversationID, err)
+		http.Error(w, "Failed to get conversation processing", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"conversation_id": conversationID,
+		"processing":      records,
+	})
+}
+
+// getConversationIntents returns the intent classifications of a conversation, newest
+// first, optionally only those of one workflow. Conversations classified from request
+// data need not be stored; their intents are those the tenant's workflows classified.
+func getConversationIntents(w http.ResponseWriter, tenantID, conversationID, workflowID string) {
+	intents, err := db.GetConversationIntents(tenantID, conversationID, workflowID)
+	if err != nil {
+		log.Printf("Error getting intents of conversation %s: %v", conversationID, err)
+		http.Error(w, "Failed to get conversation intents", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"conversation_id": conversationID,
+		"intents":         intents,
+	})
+}
+
+// fanOutAnalysisTypes run once per referenced conversation instead of over their joined text
+var fanOutAnalysisTypes = map[string]bool{
+	"attributes": true,
+	"dedup":      true,
+	"summarize":  true,
+}
+
+// resolveConversationRefs loads the stored conversations of a tenant a request references
+// by ID into its text and records them as sources of the analysis
+func resolveConversationRefs(tenantID string, req *models.StandardAnalysisRequest) error {
+	if len(req.ConversationIDs) == 0 {
+		return nil
+	}
+	if req.Text != "" {
+		return fmt.Errorf("text and conversation_ids cannot both be set")
+	}
+
+	conversations, err := db.GetConversations(tenantID, req.ConversationIDs)
+	if err != nil {
+		return fmt.Errorf("failed to load conversations: %w", err)
+	}
+	if len(conversations) < len(req.ConversationIDs) {
+		found := make(map[string]bool, len(conversations))
+		for _, conversation := range conversations {
+			found[conversation.ID] = true
+		}
+		var missing []string
+		for _, id := range req.ConversationIDs {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+		return fmt.Errorf("conversations not found: %s", strings.Join(missing, ", "))
+	}
+	if err := requireConversationText(conversations); err != nil {
+		return err
+	}
+	if conversations, err = withTranslations(conversations, req.Parameters); err != nil {
+		return err
+	}
+
+	// Fan-out types load each conversation themselves. Conversations to be summarized are
+	// kept apart with their IDs in data.conversations. Otherwise a single conversation is
+	// analyzed as is, and several are labeled so the model can tell them apart.
+	analysisType := strings.ToLower(req.AnalysisType)
+	if threshold, _ := presummarizeThreshold(req.Parameters); threshold > 0 && presummarizeAnalysisTypes[analysisType] {
+		items, _ := req.Data["conversations"].([]interface{})
+		for _, conversation := range conversations {
+			items = append(items, map[string]interface{}{"conversation_id": conversation.ID, "text": conversation.Text})
+		}
+		if req.Data == nil {
+			req.Data = map[string]interface{}{}
+		}
+		req.Data["conversations"] = items
+	} else if !fanOutAnalysisTypes[analysisType] {
+		if len(conversations) == 1 {
+			req.Text = conversations[0].Text
+		} else {
+			var sb strings.Builder
+			for _, conversation := range conversations {
+				fmt.Fprintf(&sb, "Conversation %s:\n%s\n\n", conversation.ID, conversation.Text)
+			}
+			req.Text = strings.TrimSpace(sb.String())
+		}
+	}
+
+	known := make(map[models.SourceRef]bool, len(req.Sources))
+	for _, source := range req.Sources {
+		known[source] = true
+	}
+	for _, conversation := range conversations {
+		source := models.SourceRef{Type: db.LineageConversation, ID: conversation.ID}
+		if !known[source] {
+			req.Sources = append(req.Sources, source)
+		}
+	}
+	return nil
+}
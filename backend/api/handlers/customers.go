@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// HandleCustomerData handles /api/customers/{id}/data: DELETE erases the customer's
+// conversations of the tenant and everything derived from them, and GET reports what a deletion would
+// remove without changing anything
+func HandleCustomerData(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	customerID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/customers/"), "/data")
+	if !ok || customerID == "" || strings.Contains(customerID, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	var dryRun bool
+	switch r.Method {
+	case http.MethodGet:
+		dryRun = true
+	case http.MethodDelete:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	deletion, err := db.DeleteCustomerData(auth.TenantID(r.Context()), customerID, dryRun)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "No conversations found for customer", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error deleting data of customer %s: %v", customerID, err)
+		http.Error(w, "Failed to delete customer data", http.StatusInternalServerError)
+		return
+	}
+
+	if !dryRun {
+		// The feed keeps the record of the deletion, not the deleted data
+		recordActivity(r, db.ActivityCustomerDataDeleted, "",
+			fmt.Sprintf("Data of customer %s deleted: %d conversations, %d results flagged", customerID, len(deletion.Conversations), len(deletion.FlaggedResults)),
+			map[string]interface{}{
+				"customer_id":     customerID,
+				"conversations":   len(deletion.Conversations),
+				"attributes":      deletion.Attributes,
+				"flagged_results": len(deletion.FlaggedResults),
+			})
+	}
+
+	json.NewEncoder(w).Encode(deletion)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agenticflows/backend/demo"
+)
+
+// HandleDemoStatus handles GET /api/demo, telling clients whether the server is a public demo
+func HandleDemoStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := map[string]interface{}{"enabled": demo.Enabled()}
+	if demo.Enabled() {
+		status["limits"] = demo.ConfigFromEnv()
+		status["conversations"] = len(demo.Conversations())
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// intentDistributionResponse is the intent distribution per period
+type intentDistributionResponse struct {
+	WorkflowID string            `json:"workflow_id,omitempty"`
+	Period     string            `json:"period"`
+	Since      *time.Time        `json:"since,omitempty"`
+	Until      *time.Time        `json:"until,omitempty"`
+	Periods    []db.IntentPeriod `json:"periods"`
+}
+
+// HandleIntentDistribution handles GET /api/intents/distribution: how many conversations
+// were classified with each intent per day, week or month, from the stored intent
+// classifications
+func HandleIntentDistribution(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := db.IntentDistributionFilter{
+		TenantID:   auth.TenantID(r.Context()),
+		WorkflowID: query.Get("workflow_id"),
+		Period:     query.Get("period"),
+	}
+	switch filter.Period {
+	case "":
+		filter.Period = db.IntentPeriodDay
+	case db.IntentPeriodDay, db.IntentPeriodWeek, db.IntentPeriodMonth:
+	default:
+		http.Error(w, "period must be day, week or month", http.StatusBadRequest)
+		return
+	}
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if value := query.Get(param.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s: must be an RFC3339 timestamp", param.name), http.StatusBadRequest)
+				return
+			}
+			*param.dest = t
+		}
+	}
+
+	periods, err := db.IntentDistribution(filter)
+	if err != nil {
+		log.Printf("Error getting intent distribution: %v", err)
+		http.Error(w, "Failed to get intent distribution", http.StatusInternalServerError)
+		return
+	}
+
+	resp := intentDistributionResponse{WorkflowID: filter.WorkflowID, Period: filter.Period, Periods: periods}
+	if !filter.Since.IsZero() {
+		resp.Since = &filter.Since
+	}
+	if !filter.Until.IsZero() {
+		resp.Until = &filter.Until
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/db"
+	"agenticflows/backend/translate"
+)
+
+// autoLanguage asks for the language of the conversations to be detected
+const autoLanguage = "auto"
+
+// requestLanguage reads and normalizes the language of a request's conversations. It
+// returns "" when the language is to be detected.
+func requestLanguage(req models.StandardAnalysisRequest) (string, error) {
+	language := translate.NormalizeLanguage(req.Language)
+	if language == "" || language == autoLanguage {
+		return "", nil
+	}
+	if len(language) < 2 || len(language) > 8 || strings.IndexFunc(language, func(r rune) bool { return r < 'a' || r > 'z' }) >= 0 {
+		return "", fmt.Errorf("language must be a language code such as \"es\", or \"auto\"")
+	}
+	return language, nil
+}
+
+// conversationLanguage determines the language the conversations of a request are
+// analyzed in: the target language of translated requests, the language of the request,
+// or else the language detected in most of its conversations. It returns nil when the
+// request has no conversations to detect a language in. Stored conversations are those
+// of the tenant.
+func conversationLanguage(tenantID, analysisType string, req models.StandardAnalysisRequest) (*models.LanguageMetadata, error) {
+	target, err := translationTarget(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if target != "" {
+		return &models.LanguageMetadata{Language: target, Source: models.LanguageSourceTranslated}, nil
+	}
+	language, err := requestLanguage(req)
+	if err != nil {
+		return nil, err
+	}
+	if language != "" {
+		return &models.LanguageMetadata{Language: language, Source: models.LanguageSourceRequest}, nil
+	}
+
+	texts, err := requestTexts(tenantID, analysisType, req)
+	if err != nil || len(texts) == 0 {
+		return nil, err
+	}
+	languages := map[string]int{}
+	for _, text := range texts {
+		detected := translate.DetectLanguage(text)
+		if detected == "" {
+			detected = unknownLanguage
+		}
+		languages[detected]++
+	}
+
+	// The most common recognized language wins, ties going to the first code
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		if code != unknownLanguage {
+			codes = append(codes, code)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if languages[codes[i]] != languages[codes[j]] {
+			return languages[codes[i]] > languages[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	metadata := &models.LanguageMetadata{Language: unknownLanguage, Source: models.LanguageSourceDetected, Languages: languages}
+	if len(codes) > 0 {
+		metadata.Language = codes[0]
+	}
+	return metadata, nil
+}
+
+// requestTexts returns the texts of the conversations of a request: its text, the texts of
+// data.conversations, and those of the stored conversations fan-out analyses load
+// themselves from those of the tenant
+func requestTexts(tenantID, analysisType string, req models.StandardAnalysisRequest) ([]string, error) {
+	var texts []string
+	if req.Text != "" {
+		texts = append(texts, req.Text)
+	}
+	if items, ok := req.Data["conversations"].([]interface{}); ok {
+		for _, item := range items {
+			if text, _ := itemText(item, "text"); text != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	if fanOutAnalysisTypes[analysisType] && len(req.ConversationIDs) > 0 && len(req.ConversationIDs) <= maxFanOutConversations {
+		stored, err := db.GetConversations(tenantID, req.ConversationIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load conversations: %w", err)
+		}
+		for _, conversation := range stored {
+			if conversation.Text != "" {
+				texts = append(texts, conversation.Text)
+			}
+		}
+	}
+	return texts, nil
+}
+
+// promptLanguage returns the language the model is told the conversations are in, e.g.
+// "Spanish (es)", or "" when they are in English or their language is unknown
+func promptLanguage(metadata *models.LanguageMetadata) string {
+	if metadata == nil || metadata.Language == unknownLanguage || metadata.Language == translate.DefaultTargetLanguage {
+		return ""
+	}
+	if name := translate.LanguageName(metadata.Language); name != metadata.Language {
+		return fmt.Sprintf("%s (%s)", name, metadata.Language)
+	}
+	return metadata.Language
+}
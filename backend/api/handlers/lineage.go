@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// lineageResponse describes how a node in the lineage graph relates to the rest of the chain
+type lineageResponse struct {
+	Node          db.LineageRef    `json:"node"`
+	Direction     string           `json:"direction"`
+	Edges         []db.LineageEdge `json:"edges"`
+	Conversations []string         `json:"conversations,omitempty"`
+}
+
+// HandleLineage handles /api/lineage endpoint
+func HandleLineage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID := auth.TenantID(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		// Trace a node through the lineage graph of the tenant
+		query := r.URL.Query()
+		node := db.LineageRef{Type: query.Get("type"), ID: query.Get("id")}
+		if node.Type == "" || node.ID == "" {
+			http.Error(w, "type and id are required", http.StatusBadRequest)
+			return
+		}
+
+		direction := query.Get("direction")
+		if direction == "" {
+			direction = "upstream"
+		}
+
+		var edges []db.LineageEdge
+		var err error
+		switch direction {
+		case "upstream":
+			edges, err = db.GetLineageUpstream(tenantID, node)
+		case "downstream":
+			edges, err = db.GetLineageDownstream(tenantID, node)
+		default:
+			http.Error(w, "direction must be upstream or downstream", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting lineage: %v", err)
+			http.Error(w, "Failed to get lineage", http.StatusInternalServerError)
+			return
+		}
+
+		response := lineageResponse{Node: node, Direction: direction, Edges: edges}
+		if direction == "upstream" {
+			response.Conversations = sourceConversations(edges)
+		}
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodPost:
+		// Record lineage for results derived outside the analysis endpoint
+		var req struct {
+			WorkflowID string          `json:"workflow_id"`
+			Target     db.LineageRef   `json:"target"`
+			Sources    []db.LineageRef `json:"sources"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if req.Target.Type == "" || req.Target.ID == "" || len(req.Sources) == 0 {
+			http.Error(w, "target and sources are required", http.StatusBadRequest)
+			return
+		}
+		for _, source := range req.Sources {
+			if source.Type == "" || source.ID == "" {
+				http.Error(w, "every source requires a type and id", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if req.WorkflowID != "" {
+			if _, err := db.GetWorkflow(tenantID, req.WorkflowID); err != nil {
+				http.Error(w, "Workflow not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		if err := db.RecordLineage(tenantID, req.WorkflowID, req.Target, req.Sources); err != nil {
+			log.Printf("Error recording lineage: %v", err)
+			http.Error(w, "Failed to record lineage", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// recordLineage links a stored analysis result of a tenant to the sources it was derived
+// from. Failures are logged rather than failing the analysis request.
+func recordLineage(tenantID string, req models.StandardAnalysisRequest, resultID string, analysisType string) {
+	sources := make([]db.LineageRef, 0, len(req.Sources))
+	for _, source := range req.Sources {
+		if source.Type != "" && source.ID != "" {
+			sources = append(sources, db.LineageRef{Type: source.Type, ID: source.ID})
+		}
+	}
+	for _, id := range conversationIDs(req.Data) {
+		sources = append(sources, db.LineageRef{Type: db.LineageConversation, ID: id})
+	}
+
+	target := db.LineageRef{Type: analysisType, ID: resultID}
+	if err := db.RecordLineage(tenantID, req.WorkflowID, target, sources); err != nil {
+		log.Printf("Error recording lineage for result %s: %v", resultID, err)
+	}
+}
+
+// propagateSourceConfidence lowers the confidence of a response to account for the
+// confidence of the stored results of the tenant listed as its sources
+func propagateSourceConfidence(tenantID string, req models.StandardAnalysisRequest, resp *models.StandardAnalysisResponse) error {
+	rule, _ := req.Parameters["confidence_propagation"].(string)
+	rule, err := core.ValidatePropagationRule(rule)
+	if err != nil {
+		return err
+	}
+
+	var upstream []float64
+	for _, source := range req.Sources {
+		if source.Type == db.LineageConversation || source.ID == "" {
+			continue
+		}
+		confidence, ok, err := db.GetAnalysisResultConfidence(tenantID, source.ID)
+		if err != nil {
+			log.Printf("Error getting confidence of source result %s: %v", source.ID, err)
+			continue
+		}
+		if ok {
+			upstream = append(upstream, confidence)
+		}
+	}
+	if len(upstream) == 0 {
+		return nil
+	}
+
+	// A result that reports no confidence inherits the confidence of its sources
+	own := resp.Confidence
+	if own <= 0 {
+		resp.Confidence = core.PropagateConfidence(rule, 1, upstream...)
+		return nil
+	}
+
+	resp.Confidence = core.PropagateConfidence(rule, own, upstream...)
+	if resp.Confidence < own {
+		resp.DataQuality.Limitations = append(resp.DataQuality.Limitations,
+			fmt.Sprintf("Confidence reduced from %.2f to %.2f by the confidence of the results this analysis builds on", own, resp.Confidence))
+	}
+
+	return nil
+}
+
+// conversationIDs collects the IDs of source conversations included in the request data
+func conversationIDs(data map[string]interface{}) []string {
+	var ids []string
+
+	if id, ok := data["conversation_id"].(string); ok && id != "" {
+		ids = append(ids, id)
+	}
+	if list, ok := data["conversation_ids"].([]interface{}); ok {
+		for _, item := range list {
+			if id, ok := item.(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if conversations, ok := data["conversations"].([]interface{}); ok {
+		for _, item := range conversations {
+			conversation, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if id, ok := conversation["conversation_id"].(string); ok && id != "" {
+				ids = append(ids, id)
+			} else if id, ok := conversation["id"].(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// sourceConversations returns the distinct conversations at the root of a set of lineage edges
+func sourceConversations(edges []db.LineageEdge) []string {
+	seen := make(map[string]bool)
+	var conversations []string
+	for _, edge := range edges {
+		if edge.Source.Type == db.LineageConversation && !seen[edge.Source.ID] {
+			seen[edge.Source.ID] = true
+			conversations = append(conversations, edge.Source.ID)
+		}
+	}
+	return conversations
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agenticflows/backend/openapi"
+)
+
+// HandleOpenAPI handles GET /api/openapi.json, the OpenAPI 3 document of the API with the
+// server URL the request was made to
+func HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	doc, err := openapi.JSON(scheme + "://" + r.Host)
+	if err != nil {
+		http.Error(w, "Failed to generate OpenAPI document", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
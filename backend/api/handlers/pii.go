@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"agenticflows/backend/pii"
+)
+
+// piiRedactRequest is the body of a redaction request. Providers and MinConfidence
+// override the server's PII configuration for this request.
+type piiRedactRequest struct {
+	Text          string   `json:"text"`
+	Language      string   `json:"language,omitempty"`
+	Providers     []string `json:"providers,omitempty"`
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+}
+
+// piiRedactResponse is the redacted text and the entities that were removed
+type piiRedactResponse struct {
+	RedactedText string       `json:"redacted_text"`
+	Entities     []pii.Entity `json:"entities"`
+	Providers    []string     `json:"providers"`
+}
+
+// HandlePIIRedact handles POST /api/pii/redact
+func HandlePIIRedact(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req piiRedactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Text == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+
+	config := pii.ConfigFromEnv()
+	if len(req.Providers) > 0 {
+		config.Providers = req.Providers
+	}
+	if req.MinConfidence != nil {
+		if *req.MinConfidence < 0 || *req.MinConfidence > 1 {
+			http.Error(w, "min_confidence must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		config.MinConfidence = *req.MinConfidence
+	}
+
+	scanner, err := pii.NewScanner(config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	redacted, entities, err := scanner.Redact(r.Context(), req.Text, req.Language)
+	if err != nil {
+		log.Printf("Error redacting PII: %v", err)
+		http.Error(w, "PII detection failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if entities == nil {
+		entities = []pii.Entity{}
+	}
+
+	json.NewEncoder(w).Encode(piiRedactResponse{
+		RedactedText: redacted,
+		Entities:     entities,
+		Providers:    scanner.Providers(),
+	})
+}
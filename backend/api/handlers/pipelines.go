@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// maxPipelineSteps is the maximum number of steps in a stored pipeline
+const maxPipelineSteps = 20
+
+// pipelineRequest is the body of a request to store or replace a pipeline
+type pipelineRequest struct {
+	Name                  string            `json:"name"`
+	Description           string            `json:"description,omitempty"`
+	Steps                 []db.PipelineStep `json:"steps"`
+	ConfidencePropagation string            `json:"confidence_propagation,omitempty"`
+}
+
+// pipelineExecuteRequest is the body of a request to execute a stored pipeline
+type pipelineExecuteRequest struct {
+	WorkflowID string                 `json:"workflow_id"`
+	RunID      string                 `json:"run_id,omitempty"`
+	Text       string                 `json:"text,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Tags       map[string]string      `json:"tags,omitempty"`
+	MaxBudget  float64                `json:"max_budget,omitempty"` // USD
+}
+
+// HandlePipelines handles /api/pipelines: GET lists stored pipelines and POST stores a
+// new one
+func (h *AnalysisHandler) HandlePipelines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		pipelines, err := db.ListPipelines(auth.TenantID(r.Context()))
+		if err != nil {
+			log.Printf("Error listing pipelines: %v", err)
+			http.Error(w, "Failed to list pipelines", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(pipelines)
+	case http.MethodPost:
+		req, ok := decodePipelineRequest(w, r)
+		if !ok {
+			return
+		}
+		pipeline := req.pipeline(uuid.New().String())
+		tenantID := auth.TenantID(r.Context())
+		if err := db.CreatePipeline(tenantID, pipeline); err != nil {
+			sendPipelineError(w, pipeline.ID, err)
+			return
+		}
+		writePipeline(w, tenantID, pipeline.ID, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandlePipeline handles /api/pipelines/{id}: GET returns the pipeline, PUT replaces it
+// and DELETE removes it. POST /api/pipelines/{id}/execute runs it against new input.
+func (h *AnalysisHandler) HandlePipeline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/pipelines/")
+	if path == "" {
+		h.HandlePipelines(w, r)
+		return
+	}
+
+	if id, ok := strings.CutSuffix(path, "/execute"); ok {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.executePipeline(w, r, id)
+		return
+	}
+
+	id := path
+	tenantID := auth.TenantID(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		writePipeline(w, tenantID, id, http.StatusOK)
+	case http.MethodPut:
+		req, ok := decodePipelineRequest(w, r)
+		if !ok {
+			return
+		}
+		if err := db.UpdatePipeline(tenantID, req.pipeline(id)); err != nil {
+			sendPipelineError(w, id, err)
+			return
+		}
+		writePipeline(w, tenantID, id, http.StatusOK)
+	case http.MethodDelete:
+		if err := db.DeletePipeline(tenantID, id); err != nil {
+			sendPipelineError(w, id, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// executePipeline runs a stored pipeline like a chain analysis. The input text and data
+// form the chain input, which input mappings reference as "input".
+func (h *AnalysisHandler) executePipeline(w http.ResponseWriter, r *http.Request, id string) {
+	var req pipelineExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if req.WorkflowID == "" {
+		http.Error(w, "workflow_id is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateRunID(req.RunID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateCostTags(req.Tags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateMaxBudget(req.MaxBudget); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pipeline, err := db.GetPipeline(auth.TenantID(r.Context()), id)
+	if err != nil {
+		sendPipelineError(w, id, err)
+		return
+	}
+
+	inputData := make(map[string]interface{}, len(req.Data)+1)
+	for key, value := range req.Data {
+		inputData[key] = value
+	}
+	if req.Text != "" {
+		inputData["text"] = req.Text
+	}
+
+	h.runChain(w, r, req.WorkflowID, req.RunID, req.Tags, req.MaxBudget, inputData, pipelineChainConfig(pipeline))
+}
+
+// decodePipelineRequest reads and validates a pipeline request, writing the error
+// response if it is invalid
+func decodePipelineRequest(w http.ResponseWriter, r *http.Request) (pipelineRequest, bool) {
+	var req pipelineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return req, false
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return req, false
+	}
+	if len(req.Steps) == 0 || len(req.Steps) > maxPipelineSteps {
+		http.Error(w, fmt.Sprintf("steps must contain 1 to %d steps", maxPipelineSteps), http.StatusBadRequest)
+		return req, false
+	}
+	for i, step := range req.Steps {
+		if step.Analysis == "" {
+			http.Error(w, fmt.Sprintf("step %d has no analysis", i), http.StatusBadRequest)
+			return req, false
+		}
+	}
+
+	// Check the steps, dependencies and mappings the way the chain will run them
+	if err := core.ValidateChainConfig(pipelineChainConfig(req.pipeline(""))); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid pipeline: %s", err), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// pipeline returns the pipeline a request describes
+func (req pipelineRequest) pipeline(id string) db.Pipeline {
+	return db.Pipeline{
+		ID:                    id,
+		Name:                  strings.TrimSpace(req.Name),
+		Description:           req.Description,
+		Steps:                 req.Steps,
+		ConfidencePropagation: req.ConfidencePropagation,
+	}
+}
+
+// pipelineChainConfig converts a pipeline to a chain analysis configuration
+func pipelineChainConfig(pipeline db.Pipeline) map[string]interface{} {
+	steps := make([]string, len(pipeline.Steps))
+	stepConfig := make(map[string]interface{}, len(pipeline.Steps))
+	for i, step := range pipeline.Steps {
+		steps[i] = step.Analysis
+
+		config := make(map[string]interface{}, len(step.Parameters)+2)
+		for key, value := range step.Parameters {
+			config[key] = value
+		}
+		if step.DependsOn != nil {
+			config["depends_on"] = step.DependsOn
+		}
+		if step.InputMapping != nil {
+			config["input_mapping"] = step.InputMapping
+		}
+		stepConfig[step.Analysis] = config
+	}
+
+	config := map[string]interface{}{
+		"steps":       steps,
+		"step_config": stepConfig,
+	}
+	if pipeline.ConfidencePropagation != "" {
+		config["confidence_propagation"] = pipeline.ConfidencePropagation
+	}
+	return config
+}
+
+// writePipeline writes a stored pipeline of a tenant with the given status
+func writePipeline(w http.ResponseWriter, tenantID, id string, status int) {
+	pipeline, err := db.GetPipeline(tenantID, id)
+	if err != nil {
+		sendPipelineError(w, id, err)
+		return
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(pipeline)
+}
+
+// sendPipelineError maps a pipeline storage error to a response
+func sendPipelineError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, "Pipeline not found", http.StatusNotFound)
+	case strings.Contains(err.Error(), "already exists"):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Error accessing pipeline %s: %v", id, err)
+		http.Error(w, "Failed to access pipeline", http.StatusInternalServerError)
+	}
+}
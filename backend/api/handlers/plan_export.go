@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/export"
+)
+
+// planExportRequest is the body of an action plan export. Timeline, e.g. the result of a
+// timeline generation, replaces the timeline of the plan.
+type planExportRequest struct {
+	Format    string                 `json:"format"`
+	Name      string                 `json:"name,omitempty"`
+	StartDate string                 `json:"start_date,omitempty"`
+	Plan      *models.ActionPlan     `json:"plan"`
+	Timeline  []models.TimelineEvent `json:"timeline,omitempty"`
+}
+
+// HandlePlanExport handles POST /api/analysis/plan/export, converting an action plan to
+// a Jira CSV import, Microsoft Project XML or iCalendar milestones, scheduled from
+// start_date (today by default)
+func HandlePlanExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req planExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	format := strings.ToLower(req.Format)
+	if !export.ValidPlanFormat(format) {
+		http.Error(w, "format must be jira, msproject or ical", http.StatusBadRequest)
+		return
+	}
+	if req.Plan == nil {
+		http.Error(w, "plan is required", http.StatusBadRequest)
+		return
+	}
+	start := time.Now()
+	if req.StartDate != "" {
+		var err error
+		if start, err = time.Parse("2006-01-02", req.StartDate); err != nil {
+			http.Error(w, "start_date must be a date such as 2026-01-05", http.StatusBadRequest)
+			return
+		}
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Action plan"
+	}
+
+	schedule := export.SchedulePlan(name, *req.Plan, req.Timeline, start)
+	if len(schedule.Phases) == 0 {
+		http.Error(w, "plan has no actions or timeline to export", http.StatusBadRequest)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := export.WritePlan(&body, format, schedule); err != nil {
+		log.Printf("Error exporting action plan: %v", err)
+		http.Error(w, "Failed to export action plan", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.PlanContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, exportFileName(strings.ToLower(name)), export.PlanExtension(format)))
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/prompts"
+)
+
+// HandlePromptTemplates handles /api/prompt-templates: GET lists the templates, of one
+// analysis type with ?analysis_type=, and POST stores a new version
+func HandlePromptTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID := auth.TenantID(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		templates, err := prompts.List(tenantID, r.URL.Query().Get("analysis_type"))
+		if err != nil {
+			log.Printf("Error listing prompt templates: %v", err)
+			http.Error(w, "Failed to list prompt templates", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(templates)
+	case http.MethodPost:
+		var req struct {
+			AnalysisType string `json:"analysis_type"`
+			Template     string `json:"template"`
+			Description  string `json:"description,omitempty"`
+			Activate     bool   `json:"activate,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := prompts.Validate(req.AnalysisType, req.Template); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t, err := prompts.Create(tenantID, req.AnalysisType, req.Template, req.Description)
+		if err == nil && req.Activate {
+			t, err = prompts.Activate(tenantID, t.ID)
+		}
+		if err != nil {
+			sendPromptTemplateError(w, "", err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(t)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandlePromptTemplate handles /api/prompt-templates/{ref}, where ref is a template ID or
+// <analysis_type>@<version>: GET returns the template and DELETE removes it. POST
+// /api/prompt-templates/{ref}/activate makes it the default of its analysis type and
+// DELETE on the same path restores the built-in prompt.
+func HandlePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ref := strings.TrimPrefix(r.URL.Path, "/api/prompt-templates/")
+	if ref == "" {
+		HandlePromptTemplates(w, r)
+		return
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	if ref, ok := strings.CutSuffix(ref, "/activate"); ok {
+		var t interface{}
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			t, err = prompts.Activate(tenantID, ref)
+		case http.MethodDelete:
+			t, err = prompts.Deactivate(tenantID, ref)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			sendPromptTemplateError(w, ref, err)
+			return
+		}
+		json.NewEncoder(w).Encode(t)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		t, err := prompts.Get(tenantID, ref)
+		if err != nil {
+			sendPromptTemplateError(w, ref, err)
+			return
+		}
+		json.NewEncoder(w).Encode(t)
+	case http.MethodDelete:
+		if err := prompts.Delete(tenantID, ref); err != nil {
+			sendPromptTemplateError(w, ref, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// sendPromptTemplateError maps a prompt template error to a response
+func sendPromptTemplateError(w http.ResponseWriter, ref string, err error) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, "Prompt template not found", http.StatusNotFound)
+	case strings.Contains(err.Error(), "cannot be deleted"), strings.Contains(err.Error(), "invalid prompt template version"):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error accessing prompt template %s: %v", ref, err)
+		http.Error(w, "Failed to access prompt template", http.StatusInternalServerError)
+	}
+}
+
+// withPromptTemplates returns a context whose LLM calls use the active prompt templates of
+// the tenant of ctx and the one the prompt_template_id parameter picks, rendered with
+// prompt_variables
+func withPromptTemplates(ctx context.Context, parameters map[string]interface{}) (context.Context, error) {
+	ref, _ := parameters["prompt_template_id"].(string)
+	vars, _ := parameters["prompt_variables"].(map[string]interface{})
+	if _, ok := parameters["prompt_variables"]; ok && vars == nil {
+		return nil, fmt.Errorf("prompt_variables must be an object")
+	}
+
+	templates, err := prompts.ForRequest(auth.TenantID(ctx), ref)
+	if err != nil {
+		return nil, err
+	}
+	return core.WithPromptTemplates(ctx, templates, vars)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/privacy"
+)
+
+// envPseudonymResolveToken is the bearer token investigators present to resolve pseudonyms.
+// Resolution is disabled while it is unset.
+const envPseudonymResolveToken = "PRIVACY_RESOLVE_TOKEN"
+
+// maxResolveTokens is the maximum number of tokens resolved per request
+const maxResolveTokens = 500
+
+// pseudonymizeResults replaces conversation and customer IDs in results with stable tokens
+// when pseudonymization is enabled, recording the tokens so the tenant can resolve them
+func pseudonymizeResults(tenantID string, results interface{}) interface{} {
+	key := privacy.PseudonymKey()
+	if key == nil {
+		return results
+	}
+
+	pseudonymized, used := privacy.Pseudonymize(results, key)
+	records := make([]db.Pseudonym, len(used))
+	for i, pseudonym := range used {
+		records[i] = db.Pseudonym{Token: pseudonym.Token, Kind: pseudonym.Kind, ID: pseudonym.ID}
+	}
+	if err := db.SavePseudonyms(tenantID, records); err != nil {
+		log.Printf("Error saving pseudonyms: %v", err)
+	}
+	return pseudonymized
+}
+
+// pseudonymizeResponse pseudonymizes a whole response, such as an explanation, when
+// pseudonymization is enabled
+func pseudonymizeResponse(tenantID string, resp interface{}) interface{} {
+	if privacy.PseudonymKey() == nil {
+		return resp
+	}
+
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		return resp
+	}
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return resp
+	}
+	return pseudonymizeResults(tenantID, generic)
+}
+
+// resolveRequest is the body of a pseudonym resolution request
+type resolveRequest struct {
+	Tokens []string `json:"tokens"`
+	Reason string   `json:"reason"`
+}
+
+// HandlePseudonymResolve handles POST /api/pseudonyms/resolve, mapping tokens back to
+// the identifiers they replace for authorized investigators. Only the tokens recorded for
+// the tenant of the request resolve. Every resolution is recorded in the activity feed.
+func HandlePseudonymResolve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	secret := os.Getenv(envPseudonymResolveToken)
+	if secret == "" {
+		http.Error(w, "Pseudonym resolution is disabled", http.StatusForbidden)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// With API keys, the investigator is the key's principal, which callers can't claim to
+	// be another; X-Actor only names them when authentication is disabled
+	if auth.FromContext(r.Context()) == nil && strings.TrimSpace(r.Header.Get("X-Actor")) == "" {
+		http.Error(w, "X-Actor header is required to resolve pseudonyms", http.StatusBadRequest)
+		return
+	}
+	actor := actorFromRequest(r)
+
+	var req resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Tokens) == 0 || len(req.Tokens) > maxResolveTokens {
+		http.Error(w, fmt.Sprintf("tokens must contain 1 to %d tokens", maxResolveTokens), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	pseudonyms, err := db.ResolvePseudonyms(tenantID, req.Tokens)
+	if err != nil {
+		log.Printf("Error resolving pseudonyms: %v", err)
+		http.Error(w, "Failed to resolve pseudonyms", http.StatusInternalServerError)
+		return
+	}
+
+	resolved := make(map[string]bool, len(pseudonyms))
+	for _, pseudonym := range pseudonyms {
+		resolved[pseudonym.Token] = true
+	}
+	unknown := []string{}
+	for _, token := range req.Tokens {
+		if !resolved[token] {
+			unknown = append(unknown, token)
+		}
+	}
+
+	// The feed records who resolved which tokens and why, never the identifiers
+	recordActivityAs(tenantID, actor, db.ActivityPseudonymsResolved, "",
+		fmt.Sprintf("%d pseudonyms resolved", len(pseudonyms)),
+		map[string]interface{}{"tokens": req.Tokens, "reason": req.Reason})
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pseudonyms": pseudonyms,
+		"unknown":    unknown,
+	})
+}
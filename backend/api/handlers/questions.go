@@ -2,14 +2,16 @@ package handlers
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"agenticflows/backend/analysis"
 	"agenticflows/backend/analysis/models"      // Analysis models
 	apimodels "agenticflows/backend/api/models" // API models with alias
+	"agenticflows/backend/db"
+	"agenticflows/backend/demo"
 )
 
 // HandleAnswerQuestions processes questions about the banking conversations
@@ -45,7 +47,10 @@ func HandleAnswerQuestions(w http.ResponseWriter, r *http.Request) {
 
 	// Initialize context data if not provided
 	contextData := req.Context
-	if contextData == "" {
+	if contextData == "" && demo.Enabled() {
+		// The demo only answers from the synthetic dataset
+		contextData = demo.ConversationsText()
+	} else if contextData == "" {
 		// Fetch sample conversations from the database
 		var err error
 		contextData, err = getSampleConversationsFromDB(dbPath)
@@ -70,7 +75,7 @@ func HandleAnswerQuestions(w http.ResponseWriter, r *http.Request) {
 			AnalysisType: "findings",
 			Text:         contextData,
 			Parameters: map[string]interface{}{
-				"questions": []string{question},
+				"questions": []interface{}{question},
 			},
 		}
 
@@ -115,11 +120,10 @@ func HandleAnswerQuestions(w http.ResponseWriter, r *http.Request) {
 // Helper function to get sample conversations from database
 func getSampleConversationsFromDB(dbPath string) (string, error) {
 	// Open the database
-	sqliteDB, err := sql.Open("sqlite3", dbPath)
+	sqliteDB, err := db.SharedReader(dbPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to open database: %s", err)
 	}
-	defer sqliteDB.Close()
 
 	// Query for sample conversations
 	query := `SELECT text FROM conversations LIMIT 10`
@@ -152,6 +156,15 @@ func getSampleConversationsFromDB(dbPath string) (string, error) {
 
 // Helper to extract findings from a response
 func extractFindingsFromResponse(results interface{}) ([]string, bool) {
+	// Findings analyses answer each question in a finding
+	if typed, ok := results.(*analysis.FindingsResult); ok {
+		answers := make([]string, 0, len(typed.Findings))
+		for _, finding := range typed.Findings {
+			answers = append(answers, finding.Answer)
+		}
+		return answers, true
+	}
+
 	// Try to cast directly to map
 	resultsMap, ok := results.(map[string]interface{})
 	if !ok {
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/report"
+)
+
+// HandleReport handles GET /api/reports/{workflow_id}?format=md|html|pdf, rendering the
+// latest stored trends, patterns, findings, recommendations and action plan of a workflow
+// as an executive report. title optionally replaces the default title.
+func HandleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workflowID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/reports"), "/")
+	if workflowID == "" || strings.Contains(workflowID, "/") {
+		http.Error(w, "workflow_id is required", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	format := strings.ToLower(query.Get("format"))
+	if format == "" || format == "markdown" {
+		format = report.FormatMarkdown
+	}
+	if !report.ValidFormat(format) {
+		http.Error(w, "format must be md, html or pdf", http.StatusBadRequest)
+		return
+	}
+
+	results, err := db.GetAnalysisResultsByWorkflow(auth.TenantID(r.Context()), workflowID)
+	if err != nil {
+		log.Printf("Error getting analysis results: %v", err)
+		http.Error(w, "Failed to get analysis results", http.StatusInternalServerError)
+		return
+	}
+	guardStoredResults(auth.TenantID(r.Context()), results)
+
+	doc, err := report.FromResults(query.Get("title"), workflowID, results)
+	if err != nil {
+		log.Printf("Error building report of workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to build report", http.StatusInternalServerError)
+		return
+	}
+	if doc.Empty() {
+		http.Error(w, fmt.Sprintf("workflow %s has no stored trends, patterns, findings, recommendations or plans", workflowID), http.StatusNotFound)
+		return
+	}
+
+	// Render to a buffer first so a failure can still be reported with an error status
+	var body bytes.Buffer
+	if err := report.Write(&body, format, doc); err != nil {
+		log.Printf("Error rendering report of workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to render report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s-report-%s.%s"`,
+		exportFileName(workflowID), time.Now().UTC().Format("2006-01-02"), format))
+	w.Write(body.Bytes())
+}
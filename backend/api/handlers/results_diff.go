@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// resultRunRef identifies a stored result compared by a diff
+type resultRunRef struct {
+	ID        string      `json:"id"`
+	CreatedAt interface{} `json:"created_at"`
+}
+
+// resultsDiffResponse is the difference between two stored runs of a workflow
+type resultsDiffResponse struct {
+	WorkflowID   string       `json:"workflow_id"`
+	AnalysisType string       `json:"analysis_type"`
+	From         resultRunRef `json:"from"`
+	To           resultRunRef `json:"to"`
+	*analysis.ResultDiff
+}
+
+// HandleResultsDiff handles GET /api/analysis/results/diff?workflow_id=...&from=...&to=...,
+// comparing two stored results of a workflow of the same analysis type. Without from and
+// to, analysis_type selects the two most recent results of that type.
+func (h *AnalysisHandler) HandleResultsDiff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	workflowID := query.Get("workflow_id")
+	if workflowID == "" {
+		http.Error(w, "workflow_id is required", http.StatusBadRequest)
+		return
+	}
+	fromID, toID, analysisType := query.Get("from"), query.Get("to"), query.Get("analysis_type")
+	if (fromID == "") != (toID == "") {
+		http.Error(w, "from and to must be given together", http.StatusBadRequest)
+		return
+	}
+	if fromID == "" && analysisType == "" {
+		http.Error(w, "from and to, or analysis_type, are required", http.StatusBadRequest)
+		return
+	}
+
+	results, err := db.GetAnalysisResultsByWorkflow(auth.TenantID(r.Context()), workflowID)
+	if err != nil {
+		log.Printf("Error getting analysis results: %v", err)
+		http.Error(w, "Failed to get analysis results", http.StatusInternalServerError)
+		return
+	}
+
+	var from, to map[string]interface{}
+	if fromID != "" {
+		for _, result := range results {
+			switch result["id"] {
+			case fromID:
+				from = result
+			case toID:
+				to = result
+			}
+		}
+		for id, result := range map[string]map[string]interface{}{fromID: from, toID: to} {
+			if result == nil {
+				http.Error(w, fmt.Sprintf("result %s not found in workflow %s", id, workflowID), http.StatusNotFound)
+				return
+			}
+		}
+		if from["analysis_type"] != to["analysis_type"] {
+			http.Error(w, fmt.Sprintf("cannot diff a %v result with a %v result", from["analysis_type"], to["analysis_type"]), http.StatusBadRequest)
+			return
+		}
+		if analysisType != "" && from["analysis_type"] != analysisType {
+			http.Error(w, fmt.Sprintf("the results are of type %v, not %s", from["analysis_type"], analysisType), http.StatusBadRequest)
+			return
+		}
+	} else {
+		// Results are listed newest first
+		for _, result := range results {
+			if result["analysis_type"] != analysisType {
+				continue
+			}
+			if to == nil {
+				to = result
+			} else {
+				from = result
+				break
+			}
+		}
+		if from == nil {
+			http.Error(w, fmt.Sprintf("workflow %s has fewer than two %s results", workflowID, analysisType), http.StatusNotFound)
+			return
+		}
+	}
+
+	// Compare what clients are allowed to see of each result
+	guardStoredResults(auth.TenantID(r.Context()), []map[string]interface{}{from, to})
+
+	resultType, _ := to["analysis_type"].(string)
+	fromResults, _ := from["results"].(map[string]interface{})
+	toResults, _ := to["results"].(map[string]interface{})
+	diff, err := analysis.DiffResults(resultType, fromResults, toResults)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(resultsDiffResponse{
+		WorkflowID:   workflowID,
+		AnalysisType: resultType,
+		From:         resultRunRef{ID: from["id"].(string), CreatedAt: from["created_at"]},
+		To:           resultRunRef{ID: to["id"].(string), CreatedAt: to["created_at"]},
+		ResultDiff:   diff,
+	})
+}
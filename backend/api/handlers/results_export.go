@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/export"
+	"agenticflows/backend/privacy"
+)
+
+// guardStoredResults applies the privacy settings to stored results before they leave
+// the server: small aggregate groups are suppressed and identifiers pseudonymized for the
+// tenant the results are shown to
+func guardStoredResults(tenantID string, results []map[string]interface{}) {
+	if k := privacy.MinGroupSize(); k > 1 {
+		for _, result := range results {
+			var suppressed int
+			result["results"], suppressed = privacy.SuppressSmallGroups(result["results"], k)
+			if suppressed > 0 {
+				result["suppressed_groups"] = suppressed
+			}
+		}
+	}
+	for _, result := range results {
+		result["results"] = pseudonymizeResults(tenantID, result["results"])
+	}
+}
+
+// HandleResultsExport handles GET /api/analysis/results/export?workflow_id=...&format=csv|xlsx|parquet,
+// flattening the stored results of a workflow into a table file. analysis_type optionally
+// selects one type of result.
+func (h *AnalysisHandler) HandleResultsExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	workflowID := query.Get("workflow_id")
+	if workflowID == "" {
+		http.Error(w, "workflow_id is required", http.StatusBadRequest)
+		return
+	}
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if !export.ValidFormat(format) {
+		http.Error(w, "format must be csv, xlsx or parquet", http.StatusBadRequest)
+		return
+	}
+
+	results, err := db.GetAnalysisResultsByWorkflow(auth.TenantID(r.Context()), workflowID)
+	if err != nil {
+		log.Printf("Error getting analysis results: %v", err)
+		http.Error(w, "Failed to get analysis results", http.StatusInternalServerError)
+		return
+	}
+	if analysisType := query.Get("analysis_type"); analysisType != "" {
+		filtered := results[:0]
+		for _, result := range results {
+			if result["analysis_type"] == analysisType {
+				filtered = append(filtered, result)
+			}
+		}
+		results = filtered
+	}
+	guardStoredResults(auth.TenantID(r.Context()), results)
+
+	// Write to a buffer first so a failure can still be reported with an error status
+	var body bytes.Buffer
+	if err := export.Write(&body, format, export.Flatten(results)); err != nil {
+		log.Printf("Error exporting analysis results: %v", err)
+		http.Error(w, "Failed to export analysis results", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-results-%s.%s"`,
+		exportFileName(workflowID), time.Now().UTC().Format("2006-01-02"), format))
+	w.Write(body.Bytes())
+}
+
+// exportFileName keeps the characters of a workflow ID that are safe in a file name
+func exportFileName(workflowID string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, workflowID)
+}
+
+// HandleResultsGraph handles GET /api/analysis/results/graph?workflow_id=...&format=graphml|dot|json,
+// exporting the intent taxonomy and pattern relationships of a workflow's stored results
+func (h *AnalysisHandler) HandleResultsGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	workflowID := query.Get("workflow_id")
+	if workflowID == "" {
+		http.Error(w, "workflow_id is required", http.StatusBadRequest)
+		return
+	}
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = export.GraphFormatGraphML
+	}
+	if !export.ValidGraphFormat(format) {
+		http.Error(w, "format must be graphml, dot or json", http.StatusBadRequest)
+		return
+	}
+
+	results, err := db.GetAnalysisResultsByWorkflow(auth.TenantID(r.Context()), workflowID)
+	if err != nil {
+		log.Printf("Error getting analysis results: %v", err)
+		http.Error(w, "Failed to get analysis results", http.StatusInternalServerError)
+		return
+	}
+	guardStoredResults(auth.TenantID(r.Context()), results)
+
+	var body bytes.Buffer
+	if err := export.WriteGraph(&body, format, export.TaxonomyGraph(results)); err != nil {
+		log.Printf("Error exporting taxonomy graph: %v", err)
+		http.Error(w, "Failed to export taxonomy graph", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.GraphContentType(format))
+	if format != export.GraphFormatJSON {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-taxonomy.%s"`, exportFileName(workflowID), format))
+	}
+	w.Write(body.Bytes())
+}
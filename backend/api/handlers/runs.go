@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/workflow"
+)
+
+// Run listing limits
+const (
+	defaultRunLimit = 50
+	maxRunLimit     = 500
+)
+
+// runStatuses are the run states runs can be listed by
+var runStatuses = map[string]bool{
+	db.RunRunning: true, db.RunAwaitingReview: true, db.RunCompleted: true, db.RunFailed: true, db.RunRejected: true,
+}
+
+// HandleRun handles GET /api/runs/{run_id}, which returns a run with the outputs of its
+// nodes and the analysis results saved as part of it
+func HandleRun(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	runID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/runs/"), "/")
+	if runID == "" || strings.Contains(runID, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	run, err := db.GetRun(auth.TenantID(r.Context()), runID)
+	if err != nil {
+		if err.Error() == "run not found" {
+			http.Error(w, fmt.Sprintf("Run %s not found", runID), http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting run %s: %v", runID, err)
+		http.Error(w, "Failed to get run", http.StatusInternalServerError)
+		return
+	}
+	guardRun(auth.TenantID(r.Context()), run)
+	if err := json.NewEncoder(w).Encode(run); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// handleWorkflowRuns handles GET /api/workflows/{id}/runs, which lists the runs of a
+// workflow newest first
+func handleWorkflowRuns(w http.ResponseWriter, r *http.Request, workflowID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	if _, err := db.GetWorkflow(tenantID, workflowID); err != nil {
+		http.Error(w, "Workflow not found", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := db.RunFilter{TenantID: tenantID, WorkflowID: workflowID, Status: query.Get("status"), Limit: defaultRunLimit}
+	if filter.Status != "" && !runStatuses[filter.Status] {
+		http.Error(w, "status must be running, awaiting_review, completed, failed or rejected", http.StatusBadRequest)
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(n, maxRunLimit)
+	}
+
+	runs, err := db.ListRuns(filter)
+	if err != nil {
+		log.Printf("Error listing runs of workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to list runs", http.StatusInternalServerError)
+		return
+	}
+	for i := range runs {
+		guardRun(tenantID, &runs[i])
+	}
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// guardRun applies the privacy settings to the inputs and node outputs of a run before
+// they leave the server, as for stored results
+func guardRun(tenantID string, run *db.Run) {
+	run.Inputs = guardRunValue(tenantID, run.Inputs)
+	for i := range run.Nodes {
+		run.Nodes[i].Output = guardRunValue(tenantID, run.Nodes[i].Output)
+	}
+}
+
+// guardRunValue applies guardStoredResults to an encoded value of a run. A value that
+// can't be decoded is left out rather than returned unfiltered.
+func guardRunValue(tenantID string, encoded json.RawMessage) json.RawMessage {
+	if len(encoded) == 0 {
+		return encoded
+	}
+	var value interface{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		log.Printf("Error decoding run value: %v", err)
+		return nil
+	}
+	results := []map[string]interface{}{{"results": value}}
+	guardStoredResults(tenantID, results)
+	guarded, err := json.Marshal(results[0]["results"])
+	if err != nil {
+		log.Printf("Error encoding run value: %v", err)
+		return nil
+	}
+	return guarded
+}
+
+// startRun records the start of a run with its inputs. Run history is only reported, so
+// failing to record it is logged rather than failing the run.
+func startRun(tenantID, runID, workflowID, kind, actor string, inputs interface{}) {
+	encoded, err := json.Marshal(inputs)
+	if err != nil {
+		log.Printf("Error encoding inputs of run %s: %v", runID, err)
+		encoded = nil
+	}
+	run := db.Run{RunID: runID, TenantID: tenantID, WorkflowID: workflowID, Kind: kind, Actor: actor, Inputs: encoded}
+	if err := db.CreateRun(run); err != nil {
+		log.Printf("Error recording run %s: %v", runID, err)
+	}
+}
+
+// finishRun records the nodes a run executed, the models it called and how it stopped
+func finishRun(runID, status string, runErr error, duration time.Duration, nodes []db.RunNode, usage *core.Usage) {
+	if err := db.SaveRunNodes(runID, nodes); err != nil {
+		log.Printf("Error saving nodes of run %s: %v", runID, err)
+	}
+	recordRunModels(runID, usage)
+	message := ""
+	if runErr != nil {
+		message = runErr.Error()
+	}
+	if err := db.FinishRun(runID, status, message, duration); err != nil {
+		log.Printf("Error finishing run %s: %v", runID, err)
+	}
+}
+
+// recordRunModels records the models of the LLM calls counted by usage as called by a run
+func recordRunModels(runID string, usage *core.Usage) {
+	var models []string
+	for _, call := range usage.Calls() {
+		if call.Model != "" {
+			models = append(models, call.Model)
+		}
+	}
+	if err := db.AddRunModels(runID, models); err != nil {
+		log.Printf("Error recording models of run %s: %v", runID, err)
+	}
+}
+
+// workflowRunNodes converts the nodes an executor ran to run history
+func workflowRunNodes(nodeRuns []workflow.NodeRun) []db.RunNode {
+	nodes := make([]db.RunNode, 0, len(nodeRuns))
+	for _, nodeRun := range nodeRuns {
+		node := db.RunNode{
+			NodeID:     nodeRun.NodeID,
+			NodeType:   nodeRun.NodeType,
+			Status:     nodeRun.Status,
+			StartedAt:  nodeRun.Started,
+			DurationMS: nodeRun.Duration.Milliseconds(),
+			Output:     runNodeOutput(nodeRun.Output),
+		}
+		if nodeRun.Err != nil {
+			node.Error = nodeRun.Err.Error()
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// chainRunNodes converts the steps of a chain run and their results to run history
+func chainRunNodes(steps []db.ChainRunStep, results map[string]interface{}) []db.RunNode {
+	nodes := make([]db.RunNode, 0, len(steps))
+	for _, step := range steps {
+		node := db.RunNode{
+			NodeID:     step.Step,
+			NodeType:   "analysis",
+			Status:     step.Status,
+			DurationMS: step.DurationMS,
+			Output:     runNodeOutput(results[step.Step]),
+			Error:      step.Error,
+		}
+		if step.StartedAt != nil {
+			node.StartedAt = *step.StartedAt
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// runNodeOutput encodes the output of a node, or returns nil for none or one that can't
+// be encoded
+func runNodeOutput(output interface{}) json.RawMessage {
+	if output == nil {
+		return nil
+	}
+	if object, ok := output.(map[string]interface{}); ok && object == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		log.Printf("Error encoding run node output: %v", err)
+		return nil
+	}
+	return encoded
+}
+
+// validateAnalysisRun checks that the run an analysis result is saved as part of exists and
+// belongs to the workflow of the request and to the tenant
+func validateAnalysisRun(tenantID, workflowID, runID string) error {
+	if runID == "" {
+		return nil
+	}
+	if workflowID == "" {
+		return invalidRequest(fmt.Errorf("workflow_id is required with run_id"))
+	}
+	runWorkflowID, err := db.GetRunWorkflowID(tenantID, runID)
+	if err != nil {
+		if err.Error() == "run not found" {
+			return invalidRequest(fmt.Errorf("run %s not found", runID))
+		}
+		return fmt.Errorf("failed to get run: %w", err)
+	}
+	if runWorkflowID != workflowID {
+		return invalidRequest(fmt.Errorf("run %s belongs to workflow %s, not %s", runID, runWorkflowID, workflowID))
+	}
+	return nil
+}
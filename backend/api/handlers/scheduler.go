@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/db"
+)
+
+// schedulerResponse reports the LLM call limits in effect, where they come from and the
+// calls running and queued per workflow
+type schedulerResponse struct {
+	core.SchedulerLimits
+	Source    string     `json:"source"` // "api" when set through the API, else "environment"
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	core.SchedulerStats
+}
+
+// applySchedulerSettings sets the LLM call limits of the scheduler: those set through the
+// API, or else those of the environment, and the limits of each workflow
+func applySchedulerSettings() {
+	limits := core.SchedulerLimitsFromEnv()
+	settings, err := db.GetSchedulerSettings()
+	if err != nil {
+		log.Printf("Error getting scheduler settings, using the environment: %v", err)
+	} else if settings != nil {
+		limits = core.SchedulerLimits{MaxConcurrentCalls: settings.MaxConcurrentCalls, WorkflowConcurrentCalls: settings.WorkflowConcurrentCalls}
+	}
+	core.Scheduler.SetLimits(limits)
+
+	workflowLimits, err := db.WorkflowCallLimits()
+	if err != nil {
+		log.Printf("Error getting the LLM call limits of workflows: %v", err)
+		return
+	}
+	for workflowID, limit := range workflowLimits {
+		core.Scheduler.SetWorkflowLimit(workflowID, limit)
+	}
+}
+
+// withScheduling schedules the LLM calls of an analysis as calls of its workflow, so
+// workflows share the LLM call slots fairly
+func withScheduling(runAnalysis analysisFunc) analysisFunc {
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		return runAnalysis(core.WithWorkflow(ctx, req.WorkflowID), req)
+	}
+}
+
+// HandleScheduler handles /api/scheduler: GET returns the LLM call limits with the calls
+// running and queued per workflow, PUT replaces the limits and DELETE reverts to those of
+// the environment. The scheduler is shared by every tenant, so only the bootstrap admin
+// key manages it.
+func HandleScheduler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if managedKeysTenant(r) != db.AllTenants {
+		http.Error(w, "Only the bootstrap admin key manages the LLM call scheduler", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req core.SchedulerLimits
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if req.MaxConcurrentCalls < 0 || req.WorkflowConcurrentCalls < 0 {
+			http.Error(w, "max_concurrent_calls and workflow_concurrent_calls must not be negative", http.StatusBadRequest)
+			return
+		}
+		settings := db.SchedulerSettings{MaxConcurrentCalls: req.MaxConcurrentCalls, WorkflowConcurrentCalls: req.WorkflowConcurrentCalls}
+		if err := db.SaveSchedulerSettings(settings, actorFromRequest(r)); err != nil {
+			log.Printf("Error saving scheduler settings: %v", err)
+			http.Error(w, "Failed to save scheduler settings", http.StatusInternalServerError)
+			return
+		}
+		core.Scheduler.SetLimits(req)
+		recordActivity(r, db.ActivitySchedulerSettingsSet, "", "LLM call scheduler limits updated", req)
+	case http.MethodDelete:
+		if err := db.DeleteSchedulerSettings(); err != nil {
+			log.Printf("Error clearing scheduler settings: %v", err)
+			http.Error(w, "Failed to clear scheduler settings", http.StatusInternalServerError)
+			return
+		}
+		core.Scheduler.SetLimits(core.SchedulerLimitsFromEnv())
+		recordActivity(r, db.ActivitySchedulerSettingsSet, "", "LLM call scheduler limits reverted to the environment", nil)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	settings, err := db.GetSchedulerSettings()
+	if err != nil {
+		log.Printf("Error getting scheduler settings: %v", err)
+		http.Error(w, "Failed to get scheduler settings", http.StatusInternalServerError)
+		return
+	}
+	resp := schedulerResponse{
+		SchedulerLimits: core.Scheduler.Limits(),
+		Source:          "environment",
+		SchedulerStats:  core.Scheduler.Stats(),
+	}
+	if settings != nil {
+		resp.Source = "api"
+		resp.UpdatedBy = settings.UpdatedBy
+		resp.UpdatedAt = settings.UpdatedAt
+	}
+	json.NewEncoder(w).Encode(resp)
+}
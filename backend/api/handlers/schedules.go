@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/workflow"
+)
+
+// HandleScheduleHealth handles GET /api/schedules/health, reporting scheduled workflows
+// that are healthy, stale, failing or have an invalid schedule. ?status= narrows the
+// list to one status.
+func HandleScheduleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	health, err := workflow.CheckSchedules(auth.TenantID(r.Context()), now)
+	if err != nil {
+		log.Printf("Error checking schedule health: %v", err)
+		http.Error(w, "Failed to check schedule health", http.StatusInternalServerError)
+		return
+	}
+
+	summary := map[string]int{
+		workflow.ScheduleHealthy: 0,
+		workflow.ScheduleStale:   0,
+		workflow.ScheduleFailing: 0,
+		workflow.ScheduleInvalid: 0,
+	}
+	filter := r.URL.Query().Get("status")
+	schedules := []workflow.ScheduleHealth{}
+	for _, status := range health {
+		summary[status.Status]++
+		if filter == "" || status.Status == filter {
+			schedules = append(schedules, status)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"checked_at": now,
+		"summary":    summary,
+		"schedules":  schedules,
+	})
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/cluster"
+	"agenticflows/backend/db"
+	"agenticflows/backend/workflow"
+
+	"github.com/google/uuid"
+)
+
+// Scratch session lifetimes
+const (
+	defaultScratchTTL = 24 * time.Hour
+	maxScratchTTL     = 7 * 24 * time.Hour
+	// scratchExpiryInterval is how often expired sessions are deleted
+	scratchExpiryInterval = 10 * time.Minute
+)
+
+// scratchSessionPrefix starts the ID of every scratch session, so it never clashes with
+// a saved workflow
+const scratchSessionPrefix = "scratch-"
+
+// scratchSessionRequest is the body of a request to start a scratch session
+type scratchSessionRequest struct {
+	Name     string  `json:"name,omitempty"`
+	TTLHours float64 `json:"ttl_hours,omitempty"`
+}
+
+// scratchRunRequest is the body of an ad-hoc workflow run: the nodes and edges of a
+// workflow that is never saved, and the input of the run
+type scratchRunRequest struct {
+	Nodes      json.RawMessage        `json:"nodes"`
+	Edges      json.RawMessage        `json:"edges"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Text       string                 `json:"text,omitempty"`
+	Tags       map[string]string      `json:"tags,omitempty"`
+}
+
+// scratchSessionResponse is a scratch session with its runs and the analysis results
+// stored under its ID
+type scratchSessionResponse struct {
+	db.ScratchSession
+	Runs    []db.ScratchRun          `json:"runs"`
+	Results []map[string]interface{} `json:"results"`
+}
+
+// HandleScratchSessions handles /api/scratch: GET lists the active scratch sessions and
+// POST starts a new one
+func HandleScratchSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		sessions, err := db.ListScratchSessions(auth.TenantID(r.Context()))
+		if err != nil {
+			log.Printf("Error listing scratch sessions: %v", err)
+			http.Error(w, "Failed to list scratch sessions", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(sessions)
+	case http.MethodPost:
+		createScratchSession(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleScratchSession handles /api/scratch/{id}: GET returns the session with its runs
+// and results, DELETE removes it with everything stored under it, and POST
+// /api/scratch/{id}/execute runs an ad-hoc workflow in it
+func HandleScratchSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/scratch/")
+	if path == "" {
+		HandleScratchSessions(w, r)
+		return
+	}
+	if id, ok := strings.CutSuffix(path, "/execute"); ok {
+		executeScratchRun(w, r, id)
+		return
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		session, err := db.GetScratchSession(tenantID, path)
+		if err != nil {
+			sendScratchError(w, path, err)
+			return
+		}
+		resp := scratchSessionResponse{ScratchSession: session, Runs: []db.ScratchRun{}, Results: []map[string]interface{}{}}
+		if resp.Runs, err = db.ListScratchRuns(session.ID); err != nil {
+			log.Printf("Error listing runs of scratch session %s: %v", session.ID, err)
+			http.Error(w, "Failed to get scratch session", http.StatusInternalServerError)
+			return
+		}
+		results, err := db.GetAnalysisResultsByWorkflow(tenantID, session.ID)
+		if err != nil {
+			log.Printf("Error getting results of scratch session %s: %v", session.ID, err)
+			http.Error(w, "Failed to get scratch session", http.StatusInternalServerError)
+			return
+		}
+		guardStoredResults(tenantID, results)
+		resp.Results = append(resp.Results, results...)
+		json.NewEncoder(w).Encode(resp)
+	case http.MethodDelete:
+		if err := db.DeleteScratchSession(tenantID, path); err != nil {
+			sendScratchError(w, path, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// createScratchSession starts a scratch session
+func createScratchSession(w http.ResponseWriter, r *http.Request) {
+	var req scratchSessionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ttl := defaultScratchTTL
+	if req.TTLHours != 0 {
+		ttl = time.Duration(req.TTLHours * float64(time.Hour))
+		if ttl < time.Minute || ttl > maxScratchTTL {
+			http.Error(w, fmt.Sprintf("ttl_hours must be between 1/60 and %d", int(maxScratchTTL.Hours())), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	session := db.ScratchSession{
+		ID:         scratchSessionPrefix + uuid.New().String(),
+		Name:       strings.TrimSpace(req.Name),
+		Actor:      actorFromRequest(r),
+		TTLSeconds: int64(ttl / time.Second),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+		TenantID:   auth.TenantID(r.Context()),
+	}
+	if err := db.CreateScratchSession(session); err != nil {
+		log.Printf("Error creating scratch session: %v", err)
+		http.Error(w, "Failed to create scratch session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// executeScratchRun runs an ad-hoc workflow in a scratch session and stores the run. The
+// workflow is not saved, so it never appears in the workflow list, and the session's
+// expiry is pushed back.
+func executeScratchRun(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, err := db.GetScratchSession(auth.TenantID(r.Context()), sessionID)
+	if err != nil {
+		sendScratchError(w, sessionID, err)
+		return
+	}
+
+	var req scratchRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if !json.Valid(req.Nodes) || !strings.HasPrefix(strings.TrimSpace(string(req.Nodes)), "[") {
+		http.Error(w, "nodes must be a list of workflow nodes", http.StatusBadRequest)
+		return
+	}
+	if len(req.Edges) == 0 {
+		req.Edges = json.RawMessage("[]")
+	}
+	if !json.Valid(req.Edges) || !strings.HasPrefix(strings.TrimSpace(string(req.Edges)), "[") {
+		http.Error(w, "edges must be a list of workflow edges", http.StatusBadRequest)
+		return
+	}
+	if err := validateCostTags(req.Tags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := session.Name
+	if name == "" {
+		name = session.ID
+	}
+	executor := workflow.NewExecutor(db.Workflow{ID: session.ID, Name: name, Nodes: req.Nodes, Edges: req.Edges, TenantID: session.TenantID})
+	executor.SetFunctionRunner(workflowFunctionRunner(r.Context(), actorFromRequest(r), "", ""))
+	results, runErr := executor.Execute(req.Text, req.Data, req.Parameters)
+
+	// Function nodes record the usage of their analyses, so runs are counted without tokens
+	saveUsage(db.UsageRecord{
+		TenantID:   auth.TenantID(r.Context()),
+		Kind:       db.UsageKindWorkflowExecution,
+		WorkflowID: session.ID,
+		Actor:      actorFromRequest(r),
+		Tags:       req.Tags,
+	}, nil)
+
+	run := db.ScratchRun{ID: uuid.New().String(), SessionID: session.ID, Nodes: req.Nodes, Edges: req.Edges, CreatedAt: time.Now()}
+	if runErr != nil {
+		run.Error = runErr.Error()
+	} else if run.Results, err = json.Marshal(results); err != nil {
+		log.Printf("Error encoding results of scratch run: %v", err)
+		http.Error(w, "Failed to store scratch run", http.StatusInternalServerError)
+		return
+	}
+	if err := db.SaveScratchRun(run); err != nil {
+		log.Printf("Error storing scratch run: %v", err)
+		http.Error(w, "Failed to store scratch run", http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.TouchScratchSession(session.ID); err != nil {
+		log.Printf("Error extending scratch session %s: %v", session.ID, err)
+	}
+
+	if runErr != nil {
+		_, status := AnalysisFailure(runErr)
+		http.Error(w, fmt.Sprintf("Failed to execute workflow: %s", runErr), status)
+		return
+	}
+	json.NewEncoder(w).Encode(run)
+}
+
+// sendScratchError reports a scratch session that was not found or could not be read
+func sendScratchError(w http.ResponseWriter, id string, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		http.Error(w, "Scratch session not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Error with scratch session %s: %v", id, err)
+	http.Error(w, "Failed to access scratch session", http.StatusInternalServerError)
+}
+
+// StartScratchExpiry deletes expired scratch sessions in the background. When several
+// instances share the database, only the elected leader deletes them.
+func StartScratchExpiry() {
+	go cluster.RunAsLeader(context.Background(), "scratch-expiry", func(ctx context.Context) {
+		for {
+			deleted, err := db.DeleteExpiredScratchSessions(time.Now())
+			if err != nil {
+				log.Printf("Error deleting expired scratch sessions: %v", err)
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired scratch sessions", deleted)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(scratchExpiryInterval):
+			}
+		}
+	})
+}
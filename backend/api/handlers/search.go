@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/embeddings"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// Similarity search limits
+const (
+	defaultSimilarResults = 10
+	maxSimilarResults     = 100
+	// maxIndexPerSearch bounds the conversations a search embeds before it runs; the rest
+	// are embedded through /api/search/index
+	maxIndexPerSearch = 200
+	defaultIndexLimit = 1000
+	maxIndexLimit     = 10000
+	// indexBatchSize is how many conversations are embedded and saved at once
+	indexBatchSize = 100
+	// similarSnippetLength is the length of the conversation text returned with a match
+	similarSnippetLength = 300
+)
+
+// Kinds of items a similarity search finds
+const (
+	searchKindConversations = "conversations"
+	searchKindIntents       = "intents"
+)
+
+// defaultIntentGroupThreshold is the similarity at which intent labels are grouped
+const defaultIntentGroupThreshold = 0.8
+
+// similarSearchRequest is the body of a similarity search
+type similarSearchRequest struct {
+	Text          string  `json:"text"`
+	Kind          string  `json:"kind,omitempty"` // conversations (default) or intents
+	Limit         int     `json:"limit,omitempty"`
+	MinSimilarity float64 `json:"min_similarity,omitempty"`
+
+	// Filters of conversation searches
+	Source     string     `json:"source,omitempty"`
+	CustomerID string     `json:"customer_id,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+
+	// UseMockData embeds with the local model instead of calling the LLM endpoint
+	UseMockData bool `json:"use_mock_data,omitempty"`
+}
+
+// similarItem is a stored conversation or intent similar to the query
+type similarItem struct {
+	ID         string     `json:"id"`
+	Similarity float64    `json:"similarity"`
+	Text       string     `json:"text,omitempty"` // The start of the conversation, or the intent label
+	Source     string     `json:"source,omitempty"`
+	DateTime   *time.Time `json:"date_time,omitempty"`
+}
+
+// similarSearchResponse lists the items most similar to the query, most similar first
+type similarSearchResponse struct {
+	Kind    string        `json:"kind"`
+	Model   string        `json:"model"`
+	Results []similarItem `json:"results"`
+	// Indexed counts the conversations embedded for this search, and Unindexed those still
+	// without an embedding, which the search did not cover
+	Indexed   int `json:"indexed,omitempty"`
+	Unindexed int `json:"unindexed,omitempty"`
+}
+
+// indexRequest is the body of a request to embed stored conversations
+type indexRequest struct {
+	Limit       int  `json:"limit,omitempty"`
+	UseMockData bool `json:"use_mock_data,omitempty"`
+}
+
+// indexResponse reports how many conversations were embedded
+type indexResponse struct {
+	Model     string `json:"model"`
+	Indexed   int    `json:"indexed"`
+	Remaining int    `json:"remaining"`
+}
+
+// HandleSimilarSearch handles POST /api/search/similar, which finds the stored
+// conversations or intent labels closest in meaning to a query text
+func (h *AnalysisHandler) HandleSimilarSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req similarSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Kind == "" {
+		req.Kind = searchKindConversations
+	}
+	switch {
+	case req.Text == "":
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	case req.Kind != searchKindConversations && req.Kind != searchKindIntents:
+		http.Error(w, "kind must be conversations or intents", http.StatusBadRequest)
+		return
+	case req.Limit < 0 || req.Limit > maxSimilarResults:
+		http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxSimilarResults), http.StatusBadRequest)
+		return
+	case req.MinSimilarity < -1 || req.MinSimilarity > 1:
+		http.Error(w, "min_similarity must be between -1 and 1", http.StatusBadRequest)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultSimilarResults
+	}
+
+	embedder, err := h.embedder(req.UseMockData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	resp, err := searchSimilar(r.Context(), embedder, req)
+	if err != nil {
+		log.Printf("Error searching similar %s: %v", req.Kind, err)
+		http.Error(w, "Failed to search similar "+req.Kind, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleSearchIndex handles POST /api/search/index, which embeds the stored conversations
+// that have no current embedding so later searches cover them
+func (h *AnalysisHandler) HandleSearchIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req indexRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Limit < 0 || req.Limit > maxIndexLimit {
+		http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxIndexLimit), http.StatusBadRequest)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultIndexLimit
+	}
+
+	embedder, err := h.embedder(req.UseMockData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	indexed, remaining, err := indexConversations(r.Context(), embedder, req.Limit)
+	if err != nil {
+		log.Printf("Error embedding conversations: %v", err)
+		http.Error(w, "Failed to embed conversations", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(indexResponse{Model: embedder.Model(), Indexed: indexed, Remaining: remaining})
+}
+
+// embedder returns the configured embedder, or the local model for mock requests
+func (h *AnalysisHandler) embedder(mock bool) (embeddings.Embedder, error) {
+	if mock {
+		return embeddings.NewLocalEmbedder(embeddings.DefaultLocalDimensions), nil
+	}
+	return embeddings.NewEmbedder(embeddings.ConfigFromEnv(), h.apiKey)
+}
+
+// searchSimilar embeds the query and ranks the stored items of its kind by similarity.
+// Conversation searches first embed conversations added or changed since the last
+// search, up to maxIndexPerSearch.
+func searchSimilar(ctx context.Context, embedder embeddings.Embedder, req similarSearchRequest) (*similarSearchResponse, error) {
+	resp := &similarSearchResponse{Kind: req.Kind, Model: embedder.Model(), Results: []similarItem{}}
+
+	query, err := embedder.Embed(ctx, []string{req.Text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	var stored map[string]db.Embedding
+	if req.Kind == searchKindIntents {
+		stored, err = db.GetEmbeddings(auth.TenantID(ctx), db.EmbeddingIntent, embedder.Model(), nil)
+	} else {
+		if resp.Indexed, resp.Unindexed, err = indexConversations(ctx, embedder, maxIndexPerSearch); err != nil {
+			return nil, err
+		}
+		stored, err = db.GetConversationEmbeddings(embedder.Model(), db.ConversationFilter{
+			TenantID:   auth.TenantID(ctx),
+			Source:     req.Source,
+			CustomerID: req.CustomerID,
+			Since:      req.Since,
+			Until:      req.Until,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embeddings: %w", err)
+	}
+
+	vectors, err := decodeEmbeddings(stored)
+	if err != nil {
+		return nil, err
+	}
+	matches := embeddings.Nearest(query[0], vectors, req.Limit, req.MinSimilarity)
+
+	if req.Kind == searchKindIntents {
+		for _, match := range matches {
+			resp.Results = append(resp.Results, similarItem{ID: match.ID, Similarity: match.Similarity, Text: stored[match.ID].Text})
+		}
+		return resp, nil
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	conversations, err := db.GetConversations(auth.TenantID(ctx), ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversations: %w", err)
+	}
+	byID := make(map[string]db.Conversation, len(conversations))
+	for _, conversation := range conversations {
+		byID[conversation.ID] = conversation
+	}
+	for _, match := range matches {
+		conversation := byID[match.ID]
+		resp.Results = append(resp.Results, similarItem{
+			ID:         match.ID,
+			Similarity: match.Similarity,
+			Text:       snippet(conversation.Text, similarSnippetLength),
+			Source:     conversation.Source,
+			DateTime:   conversation.DateTime,
+		})
+	}
+	return resp, nil
+}
+
+// indexConversations embeds up to limit stored conversations of the tenant of ctx without
+// a current embedding and returns how many were embedded and how many remain
+func indexConversations(ctx context.Context, embedder embeddings.Embedder, limit int) (int, int, error) {
+	tenantID := auth.TenantID(ctx)
+	ids, total, err := db.ListUnembeddedConversations(tenantID, embedder.Model(), limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list conversations to embed: %w", err)
+	}
+
+	indexed := 0
+	for start := 0; start < len(ids); start += indexBatchSize {
+		conversations, err := db.GetConversations(tenantID, ids[start:min(start+indexBatchSize, len(ids))])
+		if err != nil {
+			return indexed, total - indexed, fmt.Errorf("failed to load conversations: %w", err)
+		}
+		var texts []string
+		var embedded []db.Conversation
+		for _, conversation := range conversations {
+			if strings.TrimSpace(conversation.Text) != "" {
+				texts = append(texts, conversation.Text)
+				embedded = append(embedded, conversation)
+			}
+		}
+		if len(texts) == 0 {
+			continue
+		}
+
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return indexed, total - indexed, fmt.Errorf("failed to embed conversations: %w", err)
+		}
+		records := make([]db.Embedding, len(vectors))
+		for i, vector := range vectors {
+			records[i] = db.Embedding{
+				Kind:   db.EmbeddingConversation,
+				ItemID: embedded[i].ID,
+				Model:  embedder.Model(),
+				Vector: embeddings.Encode(vector),
+			}
+		}
+		if err := db.SaveEmbeddings(tenantID, records); err != nil {
+			return indexed, total - indexed, fmt.Errorf("failed to save embeddings: %w", err)
+		}
+		indexed += len(records)
+	}
+	return indexed, max(total-indexed, 0), nil
+}
+
+// embedIntents returns the vectors of intent names, embedding and saving those the tenant
+// of ctx has not embedded before. Names are stored by their lowercase form.
+func embedIntents(ctx context.Context, embedder embeddings.Embedder, names []string) (map[string][]float32, error) {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+	stored, err := db.GetEmbeddings(auth.TenantID(ctx), db.EmbeddingIntent, embedder.Model(), keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load intent embeddings: %w", err)
+	}
+	vectors, err := decodeEmbeddings(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing, missingKeys []string
+	for i, key := range keys {
+		if _, ok := vectors[key]; !ok {
+			missing = append(missing, names[i])
+			missingKeys = append(missingKeys, key)
+			vectors[key] = nil
+		}
+	}
+	if len(missing) > 0 {
+		embedded, err := embedder.Embed(ctx, missing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed intents: %w", err)
+		}
+		records := make([]db.Embedding, len(embedded))
+		for i, vector := range embedded {
+			vectors[missingKeys[i]] = vector
+			records[i] = db.Embedding{
+				Kind:   db.EmbeddingIntent,
+				ItemID: missingKeys[i],
+				Model:  embedder.Model(),
+				Vector: embeddings.Encode(vector),
+				Text:   missing[i],
+			}
+		}
+		if err := db.SaveEmbeddings(auth.TenantID(ctx), records); err != nil {
+			log.Printf("Error saving intent embeddings: %v", err)
+		}
+	}
+
+	byName := make(map[string][]float32, len(names))
+	for i, name := range names {
+		byName[name] = vectors[keys[i]]
+	}
+	return byName, nil
+}
+
+// withIntentGroups groups the intents of a distribution whose labels are close in meaning
+// when the group_intents parameter is true or a similarity threshold
+func (h *AnalysisHandler) withIntentGroups(ctx context.Context, parameters map[string]interface{}, result *analysis.IntentResult) error {
+	threshold := defaultIntentGroupThreshold
+	switch value := parameters["group_intents"].(type) {
+	case nil:
+		return nil
+	case bool:
+		if !value {
+			return nil
+		}
+	case float64:
+		if value <= 0 || value > 1 {
+			return fmt.Errorf("group_intents must be true or a similarity threshold between 0 and 1")
+		}
+		threshold = value
+	default:
+		return fmt.Errorf("group_intents must be true or a similarity threshold between 0 and 1")
+	}
+	if len(result.Distribution) == 0 {
+		return nil
+	}
+
+	embedder, err := h.embedder(useMockData(parameters))
+	if err != nil {
+		return err
+	}
+
+	// The distribution is ordered by count, so the most common intent names its group
+	labels := make([]string, len(result.Distribution))
+	names := make([]string, len(result.Distribution))
+	counts := make(map[string]models.IntentCount, len(result.Distribution))
+	for i, intent := range result.Distribution {
+		labels[i], names[i] = intent.Label, intentText(intent)
+		counts[intent.Label] = intent
+	}
+	byName, err := embedIntents(ctx, embedder, names)
+	if err != nil {
+		return err
+	}
+	vectors := make(map[string][]float32, len(labels))
+	for i, label := range labels {
+		vectors[label] = byName[names[i]]
+	}
+
+	for _, group := range embeddings.Group(labels, vectors, threshold) {
+		first := counts[group[0]]
+		intentGroup := models.IntentGroup{Label: first.Label, LabelName: first.LabelName}
+		for _, label := range group {
+			intentGroup.Intents = append(intentGroup.Intents, counts[label].Label)
+			intentGroup.Count += counts[label].Count
+		}
+		result.Groups = append(result.Groups, intentGroup)
+	}
+	return nil
+}
+
+// intentText is the text of an intent that is embedded: its name, or its label
+func intentText(intent models.IntentCount) string {
+	if intent.LabelName != "" {
+		return intent.LabelName
+	}
+	return intent.Label
+}
+
+// decodeEmbeddings unpacks stored vectors by item ID
+func decodeEmbeddings(stored map[string]db.Embedding) (map[string][]float32, error) {
+	vectors := make(map[string][]float32, len(stored))
+	for id, embedding := range stored {
+		vector, err := embeddings.Decode(embedding.Vector)
+		if err != nil {
+			return nil, fmt.Errorf("embedding of %s: %w", id, err)
+		}
+		vectors[id] = vector
+	}
+	return vectors, nil
+}
+
+// snippet returns the start of a text, cut at a word boundary
+func snippet(text string, length int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	runes := []rune(text)[:length]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > length/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
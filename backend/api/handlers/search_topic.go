@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/embeddings"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// Topic search limits
+const (
+	defaultTopicResults = 100
+	maxTopicResults     = 1000
+)
+
+// Topic search modes, from fewest false matches to fewest missed conversations
+const (
+	topicModePrecision = "precision"
+	topicModeBalanced  = "balanced"
+	topicModeRecall    = "recall"
+)
+
+// topicThresholds decide which conversations match a topic
+type topicThresholds struct {
+	// intentSimilarity is the similarity a stored intent needs to the topic for its
+	// conversations to match
+	intentSimilarity float64
+	// similarity is the similarity a conversation needs to the topic to match
+	similarity float64
+	// requireBoth matches only conversations passing both thresholds
+	requireBoth bool
+}
+
+// topicModes are the thresholds of each mode
+var topicModes = map[string]topicThresholds{
+	topicModePrecision: {intentSimilarity: 0.85, similarity: 0.5, requireBoth: true},
+	topicModeBalanced:  {intentSimilarity: 0.75, similarity: 0.45},
+	topicModeRecall:    {intentSimilarity: 0.6, similarity: 0.3},
+}
+
+// topicSearchRequest is the body of a topic search. The similarity thresholds and
+// require_both override the defaults of the mode.
+type topicSearchRequest struct {
+	Topic string `json:"topic"`
+	// Intents are stored intents whose conversations match whatever their similarity
+	Intents             []string `json:"intents,omitempty"`
+	Mode                string   `json:"mode,omitempty"`
+	MinIntentSimilarity *float64 `json:"min_intent_similarity,omitempty"`
+	MinSimilarity       *float64 `json:"min_similarity,omitempty"`
+	RequireBoth         *bool    `json:"require_both,omitempty"`
+	Limit               int      `json:"limit,omitempty"`
+
+	Source     string     `json:"source,omitempty"`
+	CustomerID string     `json:"customer_id,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+
+	UseMockData bool `json:"use_mock_data,omitempty"`
+}
+
+// topicIntent is a stored intent matching the topic
+type topicIntent struct {
+	Intent        string  `json:"intent"`
+	Similarity    float64 `json:"similarity"`
+	Conversations int     `json:"conversations"`
+}
+
+// topicMatch is a conversation about the topic. MatchedBy names the signals that passed
+// their threshold: "intent" and "embedding".
+type topicMatch struct {
+	ConversationID   string     `json:"conversation_id"`
+	Score            float64    `json:"score"`
+	Intent           string     `json:"intent,omitempty"`
+	IntentSimilarity float64    `json:"intent_similarity,omitempty"`
+	Similarity       float64    `json:"similarity"`
+	MatchedBy        []string   `json:"matched_by"`
+	Text             string     `json:"text,omitempty"`
+	Source           string     `json:"source,omitempty"`
+	DateTime         *time.Time `json:"date_time,omitempty"`
+}
+
+// topicSearchResponse lists the conversations about a topic, best match first
+type topicSearchResponse struct {
+	Topic               string        `json:"topic"`
+	Mode                string        `json:"mode"`
+	Model               string        `json:"model"`
+	MinIntentSimilarity float64       `json:"min_intent_similarity"`
+	MinSimilarity       float64       `json:"min_similarity"`
+	RequireBoth         bool          `json:"require_both"`
+	MatchedIntents      []topicIntent `json:"matched_intents"`
+	Results             []topicMatch  `json:"results"`
+	// Total counts the matching conversations before the limit
+	Total     int `json:"total"`
+	Indexed   int `json:"indexed,omitempty"`
+	Unindexed int `json:"unindexed,omitempty"`
+}
+
+// HandleTopicSearch handles POST /api/search/topic, which finds the stored conversations
+// about a topic such as "fee dispute" by combining their stored intent classifications
+// with the similarity of their embeddings
+func (h *AnalysisHandler) HandleTopicSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req topicSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	thresholds, err := req.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	embedder, err := h.embedder(req.UseMockData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	resp, err := searchTopic(r.Context(), embedder, req, thresholds)
+	if err != nil {
+		log.Printf("Error searching topic %q: %v", req.Topic, err)
+		http.Error(w, "Failed to search conversations", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// validate checks a topic search and returns the thresholds it runs with
+func (req *topicSearchRequest) validate() (topicThresholds, error) {
+	req.Topic = strings.TrimSpace(req.Topic)
+	if req.Topic == "" {
+		return topicThresholds{}, fmt.Errorf("topic is required")
+	}
+	if req.Mode == "" {
+		req.Mode = topicModeBalanced
+	}
+	thresholds, ok := topicModes[req.Mode]
+	if !ok {
+		return topicThresholds{}, fmt.Errorf("mode must be precision, balanced or recall")
+	}
+	if req.Limit < 0 || req.Limit > maxTopicResults {
+		return topicThresholds{}, fmt.Errorf("limit must be between 1 and %d", maxTopicResults)
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultTopicResults
+	}
+
+	if req.MinIntentSimilarity != nil {
+		if *req.MinIntentSimilarity < -1 || *req.MinIntentSimilarity > 1 {
+			return topicThresholds{}, fmt.Errorf("min_intent_similarity must be between -1 and 1")
+		}
+		thresholds.intentSimilarity = *req.MinIntentSimilarity
+	}
+	if req.MinSimilarity != nil {
+		if *req.MinSimilarity < -1 || *req.MinSimilarity > 1 {
+			return topicThresholds{}, fmt.Errorf("min_similarity must be between -1 and 1")
+		}
+		thresholds.similarity = *req.MinSimilarity
+	}
+	if req.RequireBoth != nil {
+		thresholds.requireBoth = *req.RequireBoth
+	}
+	return thresholds, nil
+}
+
+// searchTopic matches the stored intents to the topic, then scores the conversations
+// classified with a matching intent and those whose embedding is similar to the topic.
+// A conversation's score averages its intent similarity and its own similarity, so
+// conversations matched by both signals rank first.
+func searchTopic(ctx context.Context, embedder embeddings.Embedder, req topicSearchRequest, thresholds topicThresholds) (*topicSearchResponse, error) {
+	resp := &topicSearchResponse{
+		Topic:               req.Topic,
+		Mode:                req.Mode,
+		Model:               embedder.Model(),
+		MinIntentSimilarity: thresholds.intentSimilarity,
+		MinSimilarity:       thresholds.similarity,
+		RequireBoth:         thresholds.requireBoth,
+		MatchedIntents:      []topicIntent{},
+		Results:             []topicMatch{},
+	}
+
+	query, err := embedder.Embed(ctx, []string{req.Topic})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed topic: %w", err)
+	}
+
+	// Intent signal: stored intents close to the topic, or named by the request
+	stored, err := db.ListStoredIntents(auth.TenantID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list intents: %w", err)
+	}
+	named := make(map[string]bool, len(req.Intents))
+	for _, intent := range req.Intents {
+		named[strings.ToLower(strings.TrimSpace(intent))] = true
+	}
+	names := make([]string, len(stored))
+	for i, intent := range stored {
+		names[i] = intent.Intent
+	}
+	intentVectors, err := embedIntents(ctx, embedder, names)
+	if err != nil {
+		return nil, err
+	}
+	intentSimilarity := map[string]float64{}
+	var matchedNames []string
+	for _, intent := range stored {
+		similarity := embeddings.Cosine(query[0], intentVectors[intent.Intent])
+		if named[strings.ToLower(intent.Intent)] {
+			similarity = 1
+		}
+		if similarity < thresholds.intentSimilarity {
+			continue
+		}
+		similarity = roundSimilarity(similarity)
+		intentSimilarity[strings.ToLower(intent.Intent)] = similarity
+		matchedNames = append(matchedNames, intent.Intent)
+		resp.MatchedIntents = append(resp.MatchedIntents, topicIntent{Intent: intent.Intent, Similarity: similarity, Conversations: intent.Conversations})
+	}
+	sort.SliceStable(resp.MatchedIntents, func(i, j int) bool {
+		return resp.MatchedIntents[i].Similarity > resp.MatchedIntents[j].Similarity
+	})
+
+	filter := db.ConversationFilter{TenantID: auth.TenantID(ctx), Source: req.Source, CustomerID: req.CustomerID, Since: req.Since, Until: req.Until}
+	byIntent, err := db.GetConversationsByIntent(matchedNames, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversations by intent: %w", err)
+	}
+
+	// Embedding signal: the similarity of every conversation matching the filter
+	if resp.Indexed, resp.Unindexed, err = indexConversations(ctx, embedder, maxIndexPerSearch); err != nil {
+		return nil, err
+	}
+	conversationEmbeddings, err := db.GetConversationEmbeddings(embedder.Model(), filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embeddings: %w", err)
+	}
+	vectors, err := decodeEmbeddings(conversationEmbeddings)
+	if err != nil {
+		return nil, err
+	}
+	similarity := make(map[string]float64, len(vectors))
+	for _, match := range embeddings.Nearest(query[0], vectors, 0, -1) {
+		similarity[match.ID] = match.Similarity
+	}
+
+	candidates := make(map[string]bool, len(byIntent))
+	for id := range byIntent {
+		candidates[id] = true
+	}
+	for id, value := range similarity {
+		if value >= thresholds.similarity {
+			candidates[id] = true
+		}
+	}
+
+	var matches []topicMatch
+	for id := range candidates {
+		match := topicMatch{ConversationID: id, Similarity: similarity[id], MatchedBy: []string{}}
+		intentMatched := false
+		if intent, ok := byIntent[id]; ok {
+			match.Intent = intent
+			match.IntentSimilarity = intentSimilarity[strings.ToLower(intent)]
+			match.MatchedBy = append(match.MatchedBy, "intent")
+			intentMatched = true
+		}
+		_, embedded := similarity[id]
+		embeddingMatched := embedded && match.Similarity >= thresholds.similarity
+		if embeddingMatched {
+			match.MatchedBy = append(match.MatchedBy, "embedding")
+		}
+		if thresholds.requireBoth && !(intentMatched && embeddingMatched) {
+			continue
+		}
+		match.Score = roundSimilarity((match.IntentSimilarity + match.Similarity) / 2)
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ConversationID < matches[j].ConversationID
+	})
+	resp.Total = len(matches)
+	if len(matches) > req.Limit {
+		matches = matches[:req.Limit]
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ConversationID
+	}
+	conversations, err := db.GetConversations(auth.TenantID(ctx), ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversations: %w", err)
+	}
+	byID := make(map[string]db.Conversation, len(conversations))
+	for _, conversation := range conversations {
+		byID[conversation.ID] = conversation
+	}
+	for i := range matches {
+		conversation := byID[matches[i].ConversationID]
+		matches[i].Text = snippet(conversation.Text, similarSnippetLength)
+		matches[i].Source = conversation.Source
+		matches[i].DateTime = conversation.DateTime
+	}
+	resp.Results = append(resp.Results, matches...)
+	return resp, nil
+}
+
+// roundSimilarity rounds a similarity to three decimals, as embeddings.Nearest does
+func roundSimilarity(similarity float64) float64 {
+	return math.Round(similarity*1000) / 1000
+}
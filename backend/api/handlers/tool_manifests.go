@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"agenticflows/backend/db"
+)
+
+// manifestNamePattern requires a namespace, e.g. "acme/sentiment-scorer"
+var manifestNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*/[a-z0-9][a-z0-9._-]*$`)
+
+// manifestVersionPattern accepts semantic versions such as "1.2.0" or "2.0.0-beta.1"
+var manifestVersionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+// manifestAuthTypes are the supported endpoint authentication schemes
+var manifestAuthTypes = map[string]bool{
+	"none":    true,
+	"bearer":  true,
+	"api_key": true,
+	"basic":   true,
+}
+
+// HandleToolManifests handles /api/tools/install and /api/tools/manifests[/{namespace}/{name}]
+func HandleToolManifests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/tools/"), "/")
+
+	switch {
+	case path == "install":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		installToolManifest(w, r)
+
+	case path == "manifests":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		manifests, err := db.ListToolManifests()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(manifests)
+
+	case strings.HasPrefix(path, "manifests/"):
+		name := strings.TrimPrefix(path, "manifests/")
+		switch r.Method {
+		case http.MethodGet:
+			manifest, err := db.GetToolManifest(name)
+			if err != nil {
+				http.Error(w, "Tool manifest not found", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(manifest)
+
+		case http.MethodDelete:
+			if err := db.UninstallToolManifest(name); err != nil {
+				http.Error(w, "Tool manifest not found", http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// installToolManifest validates a manifest and registers its component
+func installToolManifest(w http.ResponseWriter, r *http.Request) {
+	var manifest db.ToolManifest
+	if err := json.NewDecoder(r.Body).Decode(&manifest); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid manifest: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := normalizeToolManifest(&manifest); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid manifest: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	created, err := db.InstallToolManifest(manifest)
+	if err != nil {
+		log.Printf("Error installing tool manifest %s: %v", manifest.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	installed, err := db.GetToolManifest(manifest.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(installed)
+}
+
+// normalizeToolManifest applies defaults to a manifest and checks that it is complete
+func normalizeToolManifest(m *db.ToolManifest) error {
+	m.Name = strings.ToLower(strings.TrimSpace(m.Name))
+	if !manifestNamePattern.MatchString(m.Name) {
+		return fmt.Errorf("name must be namespaced as \"namespace/name\" using lowercase letters, digits, '.', '_' and '-'")
+	}
+	if !manifestVersionPattern.MatchString(m.Version) {
+		return fmt.Errorf("version must be a semantic version such as 1.0.0")
+	}
+
+	if m.Kind == "" {
+		m.Kind = db.ManifestKindTool
+	}
+	if m.Kind != db.ManifestKindTool && m.Kind != db.ManifestKindAgent {
+		return fmt.Errorf("kind must be %q or %q", db.ManifestKindTool, db.ManifestKindAgent)
+	}
+	if m.Label == "" {
+		m.Label = m.Name
+	}
+
+	if err := checkManifestSchema("inputs", m.Inputs); err != nil {
+		return err
+	}
+	if err := checkManifestSchema("outputs", m.Outputs); err != nil {
+		return err
+	}
+
+	endpoint, err := url.Parse(m.Endpoint.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("endpoint.url must be an absolute http or https URL")
+	}
+	if endpoint.User != nil {
+		return fmt.Errorf("endpoint.url must not contain credentials; use auth.secret_env")
+	}
+	m.Endpoint.Method = strings.ToUpper(m.Endpoint.Method)
+	if m.Endpoint.Method == "" {
+		m.Endpoint.Method = http.MethodPost
+	}
+	if m.Endpoint.Method != http.MethodPost && m.Endpoint.Method != http.MethodGet && m.Endpoint.Method != http.MethodPut {
+		return fmt.Errorf("endpoint.method must be GET, POST or PUT")
+	}
+	if m.Endpoint.TimeoutSeconds < 0 {
+		return fmt.Errorf("endpoint.timeout_seconds must not be negative")
+	}
+
+	if m.Auth.Type == "" {
+		m.Auth.Type = "none"
+	}
+	if !manifestAuthTypes[m.Auth.Type] {
+		return fmt.Errorf("auth.type must be none, bearer, api_key or basic")
+	}
+	if m.Auth.Type != "none" && m.Auth.SecretEnv == "" {
+		return fmt.Errorf("auth.secret_env is required for %s authentication", m.Auth.Type)
+	}
+	if m.Auth.Type == "api_key" && m.Auth.Header == "" {
+		m.Auth.Header = "X-API-Key"
+	}
+
+	return nil
+}
+
+// checkManifestSchema checks that a manifest declares a JSON Schema object for a field
+func checkManifestSchema(field string, schema map[string]interface{}) error {
+	if len(schema) == 0 {
+		return fmt.Errorf("%s schema is required", field)
+	}
+	if schemaType, ok := schema["type"].(string); !ok || schemaType == "" {
+		return fmt.Errorf("%s schema must declare a type", field)
+	}
+	return nil
+}
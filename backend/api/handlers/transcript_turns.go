@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/transcript"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// speakerRole reads the speaker_role parameter, which restricts an analysis to the
+// turns of one speaker role
+func speakerRole(parameters map[string]interface{}) (string, error) {
+	role, _ := parameters["speaker_role"].(string)
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		return "", nil
+	}
+	if !transcript.ValidRole(role) {
+		return "", fmt.Errorf("speaker_role must be customer, agent or system")
+	}
+	return role, nil
+}
+
+// speakerTurnsText returns the turns of one speaker role in text as a transcript, or
+// text unchanged when role is empty
+func speakerTurnsText(text, role string) (string, error) {
+	if role == "" {
+		return text, nil
+	}
+	turns := transcript.Parse(text)
+	if !transcript.Structured(turns) {
+		return "", fmt.Errorf("speaker_role requires a transcript with speaker labels")
+	}
+	filtered := transcript.FilterRole(turns, role)
+	if len(filtered) == 0 {
+		return "", fmt.Errorf("the transcript has no %s turns", role)
+	}
+	return transcript.Plain(filtered), nil
+}
+
+// speakerTurnsFromRequest returns the text of a request restricted to the turns of its
+// speaker_role parameter
+func speakerTurnsFromRequest(req models.StandardAnalysisRequest) (string, error) {
+	role, err := speakerRole(req.Parameters)
+	if err != nil {
+		return "", err
+	}
+	return speakerTurnsText(req.Text, role)
+}
+
+// getConversationTurns sends the turns of a stored conversation, optionally only those
+// of the role in the role query parameter
+func getConversationTurns(w http.ResponseWriter, r *http.Request, conversationID string) {
+	role := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("role")))
+	if role != "" && !transcript.ValidRole(role) {
+		http.Error(w, "role must be customer, agent or system", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := db.GetConversation(auth.TenantID(r.Context()), conversationID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error getting conversation %s: %v", conversationID, err)
+		http.Error(w, "Failed to get conversation", http.StatusInternalServerError)
+		return
+	}
+	if err := requireConversationText([]db.Conversation{*conversation}); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	turns := transcript.Parse(conversation.Text)
+	structured := transcript.Structured(turns)
+	if role != "" {
+		turns = transcript.FilterRole(turns, role)
+	}
+	if turns == nil {
+		turns = []models.Turn{}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"conversation_id": conversationID,
+		"structured":      structured,
+		"speakers":        transcript.Speakers(turns),
+		"turns":           turns,
+	})
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/pii"
+	"agenticflows/backend/translate"
+)
+
+// unknownLanguage counts conversations whose source language was not reported
+const unknownLanguage = "unknown"
+
+// redactedTranslation is appended to the target language of the saved translations of
+// redacted conversations, which are kept apart from the translations of the full text
+const redactedTranslation = "+redacted"
+
+// translationTarget reads the translate parameter of an analysis: true translates into
+// the configured target language, and a language code into that language. It returns
+// "" when the request is not translated.
+func translationTarget(parameters map[string]interface{}) (string, error) {
+	switch value := parameters["translate"].(type) {
+	case nil:
+		return "", nil
+	case bool:
+		if !value {
+			return "", nil
+		}
+		return translate.ConfigFromEnv().TargetLanguage, nil
+	case string:
+		language := translate.NormalizeLanguage(value)
+		if language == "" {
+			return "", fmt.Errorf("translate must be true or a language code such as \"en\"")
+		}
+		return language, nil
+	default:
+		return "", fmt.Errorf("translate must be true or a language code such as \"en\"")
+	}
+}
+
+// translateRequest translates the conversations of a request into the language of its
+// translate parameter before they are analyzed. Stored conversations are translated once
+// and their translations saved next to the original text; inline text and
+// data.conversations are translated in place. When the request is redacted, PII is
+// replaced before the text reaches the translator. It returns the number of
+// conversations per source language and what was redacted, or nil when the request is
+// not translated.
+func (h *AnalysisHandler) translateRequest(ctx context.Context, req *models.StandardAnalysisRequest) (map[string]int, *pii.Report, error) {
+	target, err := translationTarget(req.Parameters)
+	if err != nil || target == "" {
+		return nil, nil, err
+	}
+	scanner, err := redactionScanner(req.Parameters)
+	if err != nil {
+		return nil, nil, invalidRequest(err)
+	}
+	translator, err := translate.NewTranslator(translate.ConfigFromEnv(), h.analysisFacade.Translator())
+	if err != nil {
+		return nil, nil, err
+	}
+	if useMockData(req.Parameters) {
+		ctx = core.WithMock(ctx)
+	}
+
+	if len(req.ConversationIDs) > 0 {
+		return translateStoredConversations(ctx, translator, scanner, req.ConversationIDs, target)
+	}
+
+	// Each text is translated and written back where it was read from
+	type pendingText struct {
+		text string
+		set  func(string)
+	}
+	var texts []pendingText
+	if req.Text != "" {
+		texts = append(texts, pendingText{req.Text, func(text string) { req.Text = text }})
+	}
+	if items, ok := req.Data["conversations"].([]interface{}); ok {
+		for i, item := range items {
+			switch v := item.(type) {
+			case string:
+				texts = append(texts, pendingText{v, func(text string) { items[i] = text }})
+			case map[string]interface{}:
+				if text, _ := v["text"].(string); text != "" {
+					texts = append(texts, pendingText{text, func(text string) { v["text"] = text }})
+				}
+			}
+		}
+	}
+
+	languages := map[string]int{}
+	report := newRedactionReport(scanner)
+	var mu sync.Mutex
+	err = forEachConcurrently(len(texts), func(i int) error {
+		text, err := redactForTranslation(ctx, scanner, report, &mu, texts[i].text)
+		if err != nil {
+			return err
+		}
+		translation, err := translate.Text(ctx, translator, text, target)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		texts[i].set(translation.Text)
+		languages[sourceLanguage(translation)]++
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return languages, report, nil
+}
+
+// newRedactionReport starts the report of a scanner, or returns nil without one
+func newRedactionReport(scanner *pii.Scanner) *pii.Report {
+	if scanner == nil {
+		return nil
+	}
+	return scanner.NewReport()
+}
+
+// redactForTranslation replaces the PII in a text before it is translated and adds it to
+// the report. The language of the text is not known yet. Without a scanner the text is
+// returned as is.
+func redactForTranslation(ctx context.Context, scanner *pii.Scanner, report *pii.Report, mu *sync.Mutex, text string) (string, error) {
+	if scanner == nil {
+		return text, nil
+	}
+	redacted, entities, err := scanner.Redact(ctx, text, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to redact PII: %w", err)
+	}
+	mu.Lock()
+	report.Add(entities)
+	mu.Unlock()
+	return redacted, nil
+}
+
+// translateStoredConversations translates the stored conversations of the tenant of ctx
+// that have no translation into the target language yet and saves the translations.
+// With a scanner, each conversation is redacted, and only the redacted text is
+// translated and saved.
+func translateStoredConversations(ctx context.Context, translator translate.Translator, scanner *pii.Scanner, ids []string, target string) (map[string]int, *pii.Report, error) {
+	conversations, err := db.GetConversations(auth.TenantID(ctx), ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load conversations: %w", err)
+	}
+	key := translationKey(target, scanner != nil)
+	stored, err := db.GetConversationTranslations(ids, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load translations: %w", err)
+	}
+
+	var withText []db.Conversation
+	for _, conversation := range conversations {
+		if conversation.Text != "" {
+			withText = append(withText, conversation)
+		}
+	}
+
+	// Conversations translated before are still scanned, so the report covers them all
+	languages := map[string]int{}
+	report := newRedactionReport(scanner)
+	var mu sync.Mutex
+	err = forEachConcurrently(len(withText), func(i int) error {
+		text, err := redactForTranslation(ctx, scanner, report, &mu, withText[i].Text)
+		if err != nil {
+			return fmt.Errorf("conversation %s: %w", withText[i].ID, err)
+		}
+		if translation, ok := stored[withText[i].ID]; ok {
+			mu.Lock()
+			languages[sourceLanguage(translate.Translation{SourceLanguage: translation.SourceLanguage})]++
+			mu.Unlock()
+			return nil
+		}
+
+		translation, err := translate.Text(ctx, translator, text, target)
+		if err != nil {
+			return fmt.Errorf("conversation %s: %w", withText[i].ID, err)
+		}
+		if err := db.SaveConversationTranslation(db.ConversationTranslation{
+			ConversationID: withText[i].ID,
+			SourceLanguage: translation.SourceLanguage,
+			TargetLanguage: key,
+			Text:           translation.Text,
+			Provider:       translation.Provider,
+		}); err != nil {
+			log.Printf("Error saving translation of conversation %s: %v", withText[i].ID, err)
+		}
+		mu.Lock()
+		languages[sourceLanguage(translation)]++
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return languages, report, nil
+}
+
+// translationKey returns the language under which translations into target are saved:
+// translations of redacted conversations are saved apart from those of the full text
+func translationKey(target string, redacted bool) string {
+	if redacted {
+		return target + redactedTranslation
+	}
+	return target
+}
+
+// withTranslations replaces the text of conversations with their saved translation into
+// the language of the translate parameter, if the request is translated. Redacted
+// requests read the translations of the redacted text.
+func withTranslations(conversations []db.Conversation, parameters map[string]interface{}) ([]db.Conversation, error) {
+	target, err := translationTarget(parameters)
+	if err != nil || target == "" {
+		return conversations, err
+	}
+	scanner, err := redactionScanner(parameters)
+	if err != nil {
+		return nil, invalidRequest(err)
+	}
+	target = translationKey(target, scanner != nil)
+
+	ids := make([]string, len(conversations))
+	for i, conversation := range conversations {
+		ids[i] = conversation.ID
+	}
+	translations, err := db.GetConversationTranslations(ids, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load translations: %w", err)
+	}
+
+	translated := make([]db.Conversation, len(conversations))
+	for i, conversation := range conversations {
+		if translation, ok := translations[conversation.ID]; ok {
+			conversation.Text = translation.Text
+		}
+		translated[i] = conversation
+	}
+	return translated, nil
+}
+
+// withSourceLanguages reports the source languages of translated conversations in the
+// response of an analysis
+func withSourceLanguages(runAnalysis analysisFunc, languages map[string]int) analysisFunc {
+	if len(languages) == 0 {
+		return runAnalysis
+	}
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		resp, err := runAnalysis(ctx, req)
+		if resp != nil {
+			resp.SourceLanguages = languages
+		}
+		return resp, err
+	}
+}
+
+// sourceLanguage returns the source language of a translation for the language counts
+func sourceLanguage(translation translate.Translation) string {
+	if language := translate.NormalizeLanguage(translation.SourceLanguage); language != "" {
+		return language
+	}
+	return unknownLanguage
+}
+
+// forEachConcurrently calls fn for 0..n-1, up to analysis.DefaultFanOutConcurrency at
+// once, and returns the first error
+func forEachConcurrently(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, analysis.DefaultFanOutConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// LLM prices used to estimate the cost of usage, in USD per million tokens
+const (
+	envPromptTokenPrice     = "LLM_PROMPT_TOKEN_PRICE"
+	envCompletionTokenPrice = "LLM_COMPLETION_TOKEN_PRICE"
+	// envCachedPromptTokenPrice prices prompt tokens served from the provider's prompt
+	// cache; defaults to the prompt token price
+	envCachedPromptTokenPrice = "LLM_CACHED_PROMPT_TOKEN_PRICE"
+)
+
+// Cost tag limits
+const (
+	maxCostTags          = 16
+	maxCostTagValueBytes = 128
+)
+
+// costTagKeyPattern matches valid cost tag keys such as "team" or "cost_center"
+var costTagKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+
+// validateCostTags checks the cost attribution tags of a request
+func validateCostTags(tags map[string]string) error {
+	if len(tags) > maxCostTags {
+		return fmt.Errorf("at most %d tags are allowed", maxCostTags)
+	}
+	for key, value := range tags {
+		if !costTagKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid tag %q: keys are lowercase letters, digits, '_', '.' or '-', starting with a letter", key)
+		}
+		if db.IsUsageDimension(key) {
+			return fmt.Errorf("invalid tag %q: the name is reserved for grouping usage", key)
+		}
+		if value == "" || len(value) > maxCostTagValueBytes {
+			return fmt.Errorf("invalid tag %q: values must be 1 to %d bytes", key, maxCostTagValueBytes)
+		}
+	}
+	return nil
+}
+
+// withUsage records the LLM usage of each analysis under the cost tags of its request
+func withUsage(kind, actor, analysisType string, runAnalysis analysisFunc) analysisFunc {
+	return func(ctx context.Context, req models.StandardAnalysisRequest) (*models.StandardAnalysisResponse, error) {
+		ctx, usage := core.WithUsage(ctx)
+		resp, err := runAnalysis(ctx, req)
+		saveUsage(db.UsageRecord{
+			TenantID:     auth.TenantID(ctx),
+			Kind:         kind,
+			AnalysisType: analysisType,
+			WorkflowID:   req.WorkflowID,
+			Actor:        actor,
+			Tags:         req.Tags,
+		}, usage)
+		if req.RunID != "" {
+			recordRunModels(req.RunID, usage)
+		}
+		return resp, err
+	}
+}
+
+// saveUsage stores the usage counted for a request and the estimated cost of each of its
+// calls. Failures are logged, never returned.
+func saveUsage(record db.UsageRecord, usage *core.Usage) {
+	totals := usage.Totals()
+	prices := tokenPrices()
+	for _, call := range usage.Calls() {
+		record.LLMCalls = append(record.LLMCalls, db.LLMCall{
+			Model:              call.Model,
+			PromptTokens:       call.PromptTokens,
+			CompletionTokens:   call.CompletionTokens,
+			CachedPromptTokens: call.CachedPromptTokens,
+			Estimated:          call.Estimated,
+			Cost:               prices.tokensCost(call.PromptTokens, call.CachedPromptTokens, call.CompletionTokens),
+		})
+	}
+	record.ID = uuid.New().String()
+	record.Calls = totals.Calls
+	record.PromptTokens = totals.PromptTokens
+	record.CompletionTokens = totals.CompletionTokens
+	record.CachedPromptTokens = totals.CachedPromptTokens
+	record.Estimated = totals.Estimated
+	if err := db.RecordUsage(record); err != nil {
+		log.Printf("Error recording usage: %v", err)
+	}
+}
+
+// usageGroupCost is a usage group with its estimated cost
+type usageGroupCost struct {
+	db.UsageGroup
+	Cost float64 `json:"cost"`
+}
+
+// usageResponse is the usage summary returned by the usage endpoint
+type usageResponse struct {
+	GroupBy   []string         `json:"group_by"`
+	Groups    []usageGroupCost `json:"groups"`
+	Total     usageGroupCost   `json:"total"`
+	Currency  string           `json:"currency"`
+	Since     *time.Time       `json:"since,omitempty"`
+	Until     *time.Time       `json:"until,omitempty"`
+	Estimated bool             `json:"estimated,omitempty"`
+}
+
+// HandleUsage handles GET /api/usage: LLM usage totals grouped by cost tags or record
+// fields, as JSON or, with format=csv, as a CSV export
+func HandleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := db.UsageFilter{
+		TenantID:     auth.TenantID(r.Context()),
+		Kind:         query.Get("kind"),
+		AnalysisType: query.Get("analysis_type"),
+		WorkflowID:   query.Get("workflow_id"),
+		Tags:         map[string]string{},
+	}
+
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if value := query.Get(param.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s: must be an RFC3339 timestamp", param.name), http.StatusBadRequest)
+				return
+			}
+			*param.dest = t
+		}
+	}
+
+	// Filter by tags with tag=key:value, repeated for several tags
+	for _, tag := range query["tag"] {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok || key == "" {
+			http.Error(w, fmt.Sprintf("Invalid tag filter %q: expected key:value", tag), http.StatusBadRequest)
+			return
+		}
+		filter.Tags[key] = value
+	}
+
+	for _, dimension := range strings.Split(query.Get("group_by"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			filter.GroupBy = append(filter.GroupBy, dimension)
+		}
+	}
+
+	summary, err := db.GetUsageSummary(filter)
+	if err != nil {
+		log.Printf("Error getting usage: %v", err)
+		http.Error(w, "Failed to get usage", http.StatusInternalServerError)
+		return
+	}
+
+	prices := tokenPrices()
+	resp := usageResponse{
+		GroupBy:  filter.GroupBy,
+		Groups:   make([]usageGroupCost, len(summary)),
+		Total:    usageGroupCost{UsageGroup: db.UsageGroup{Group: map[string]string{}}},
+		Currency: "USD",
+	}
+	if resp.GroupBy == nil {
+		resp.GroupBy = []string{}
+	}
+	if !filter.Since.IsZero() {
+		resp.Since = &filter.Since
+	}
+	if !filter.Until.IsZero() {
+		resp.Until = &filter.Until
+	}
+	for i, group := range summary {
+		resp.Groups[i] = usageGroupCost{
+			UsageGroup: group,
+			Cost:       prices.cost(group),
+		}
+		resp.Total.Requests += group.Requests
+		resp.Total.Calls += group.Calls
+		resp.Total.PromptTokens += group.PromptTokens
+		resp.Total.CompletionTokens += group.CompletionTokens
+		resp.Total.CachedPromptTokens += group.CachedPromptTokens
+		resp.Total.TotalTokens += group.TotalTokens
+		resp.Total.Estimated = resp.Total.Estimated || group.Estimated
+	}
+	resp.Total.Cost = prices.cost(resp.Total.UsageGroup)
+	resp.Estimated = resp.Total.Estimated
+
+	if query.Get("format") == "csv" {
+		writeUsageCSV(w, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// writeUsageCSV writes a usage summary as CSV, one row per group
+func writeUsageCSV(w http.ResponseWriter, resp usageResponse) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="usage-%s.csv"`, time.Now().UTC().Format("2006-01-02")))
+
+	writer := csv.NewWriter(w)
+	header := append(append([]string{}, resp.GroupBy...),
+		"requests", "calls", "prompt_tokens", "completion_tokens", "cached_prompt_tokens", "total_tokens", "cost_"+strings.ToLower(resp.Currency), "estimated")
+	writer.Write(header)
+
+	for _, group := range resp.Groups {
+		row := make([]string, 0, len(header))
+		for _, dimension := range resp.GroupBy {
+			row = append(row, group.Group[dimension])
+		}
+		row = append(row,
+			strconv.Itoa(group.Requests),
+			strconv.Itoa(group.Calls),
+			strconv.Itoa(group.PromptTokens),
+			strconv.Itoa(group.CompletionTokens),
+			strconv.Itoa(group.CachedPromptTokens),
+			strconv.Itoa(group.TotalTokens),
+			strconv.FormatFloat(group.Cost, 'f', 6, 64),
+			strconv.FormatBool(group.Estimated),
+		)
+		writer.Write(row)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing usage CSV: %v", err)
+	}
+}
+
+// usagePrices are token prices in USD per million tokens
+type usagePrices struct {
+	prompt       float64
+	cachedPrompt float64
+	completion   float64
+}
+
+// tokenPrices reads the configured token prices
+func tokenPrices() usagePrices {
+	prices := usagePrices{
+		prompt:     tokenPrice(envPromptTokenPrice),
+		completion: tokenPrice(envCompletionTokenPrice),
+	}
+	prices.cachedPrompt = prices.prompt
+	if os.Getenv(envCachedPromptTokenPrice) != "" {
+		prices.cachedPrompt = tokenPrice(envCachedPromptTokenPrice)
+	}
+	return prices
+}
+
+// tokenPrice reads a token price from the environment, 0 when unset or invalid
+func tokenPrice(env string) float64 {
+	value := os.Getenv(env)
+	if value == "" {
+		return 0
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 {
+		log.Printf("Warning: ignoring invalid %s value %q", env, value)
+		return 0
+	}
+	return price
+}
+
+// cost estimates the cost of a usage group
+func (p usagePrices) cost(group db.UsageGroup) float64 {
+	return p.tokensCost(group.PromptTokens, group.CachedPromptTokens, group.CompletionTokens)
+}
+
+// usageCost estimates the cost of the usage of a request so far
+func (p usagePrices) usageCost(totals core.UsageTotals) float64 {
+	return p.tokensCost(totals.PromptTokens, totals.CachedPromptTokens, totals.CompletionTokens)
+}
+
+// tokensCost estimates the cost of token counts; cached tokens are part of the prompt tokens
+func (p usagePrices) tokensCost(promptTokens, cachedPromptTokens, completionTokens int) float64 {
+	uncached := promptTokens - cachedPromptTokens
+	return (float64(uncached)*p.prompt + float64(cachedPromptTokens)*p.cachedPrompt +
+		float64(completionTokens)*p.completion) / 1e6
+}
+
+// configured reports whether any token price is set, without which costs are all zero
+func (p usagePrices) configured() bool {
+	return p.prompt > 0 || p.cachedPrompt > 0 || p.completion > 0
+}
+
+// handleWorkflowCosts handles GET /api/workflows/{id}/costs: the estimated cost of the LLM
+// calls the tenant made for a workflow
+func handleWorkflowCosts(w http.ResponseWriter, r *http.Request, workflowID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	if _, err := db.GetWorkflow(tenantID, workflowID); err != nil {
+		http.Error(w, "Workflow not found", http.StatusNotFound)
+		return
+	}
+	costs, err := db.GetWorkflowCosts(tenantID, workflowID)
+	if err != nil {
+		log.Printf("Error getting costs of workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to get workflow costs", http.StatusInternalServerError)
+		return
+	}
+
+	resp := struct {
+		*db.WorkflowCosts
+		Currency string `json:"currency"`
+	}{costs, "USD"}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// validateMaxBudget checks the max_budget of a chain request, which can only be enforced
+// when token prices are configured
+func validateMaxBudget(maxBudget float64) error {
+	if maxBudget < 0 {
+		return fmt.Errorf("max_budget must not be negative")
+	}
+	if maxBudget > 0 && !tokenPrices().configured() {
+		return fmt.Errorf("max_budget requires token prices: set %s and %s", envPromptTokenPrice, envCompletionTokenPrice)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/webhooks"
+
+	"github.com/google/uuid"
+)
+
+// webhookRequest is the body of a request to create or replace a webhook subscription
+type webhookRequest struct {
+	Name          string               `json:"name"`
+	URL           string               `json:"url"`
+	Secret        string               `json:"secret,omitempty"`
+	AnalysisTypes []string             `json:"analysis_types,omitempty"`
+	WorkflowID    string               `json:"workflow_id,omitempty"`
+	Conditions    []webhooks.Condition `json:"conditions,omitempty"`
+	Match         string               `json:"match,omitempty"`
+	Enabled       *bool                `json:"enabled,omitempty"`
+}
+
+// HandleWebhooks handles /api/webhooks: GET lists the subscriptions of the tenant and POST
+// creates one
+func HandleWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		subs, err := db.ListWebhookSubscriptions(auth.TenantID(r.Context()), false)
+		if err != nil {
+			log.Printf("Error listing webhook subscriptions: %v", err)
+			http.Error(w, "Failed to list webhook subscriptions", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(subs)
+	case http.MethodPost:
+		saveWebhook(w, r, nil)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleWebhook handles /api/webhooks/{id}: GET returns the subscription, PUT replaces
+// its settings and DELETE removes it
+func HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/webhooks/")
+	if id == "" {
+		HandleWebhooks(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPut:
+		sub, err := db.GetWebhookSubscription(auth.TenantID(r.Context()), id)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, "Webhook subscription not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("Error getting webhook subscription %s: %v", id, err)
+			http.Error(w, "Failed to get webhook subscription", http.StatusInternalServerError)
+			return
+		}
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(sub)
+			return
+		}
+		saveWebhook(w, r, sub)
+	case http.MethodDelete:
+		if err := db.DeleteWebhookSubscription(auth.TenantID(r.Context()), id); err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, "Webhook subscription not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("Error deleting webhook subscription %s: %v", id, err)
+			http.Error(w, "Failed to delete webhook subscription", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveWebhook validates and stores a new subscription, or replaces the settings of an
+// existing one
+func saveWebhook(w http.ResponseWriter, r *http.Request, existing *db.WebhookSubscription) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if parsed, err := url.Parse(req.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		http.Error(w, "url must be an http or https URL", http.StatusBadRequest)
+		return
+	}
+	for _, analysisType := range req.AnalysisTypes {
+		if _, ok := analysis.NewResult(analysisType); !ok {
+			http.Error(w, fmt.Sprintf("unknown analysis type %s", analysisType), http.StatusBadRequest)
+			return
+		}
+	}
+	switch req.Match {
+	case "":
+		req.Match = webhooks.MatchAll
+	case webhooks.MatchAll, webhooks.MatchAny:
+	default:
+		http.Error(w, "match must be all or any", http.StatusBadRequest)
+		return
+	}
+	if err := webhooks.ValidateConditions(req.Conditions); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conditions := req.Conditions
+	if conditions == nil {
+		conditions = []webhooks.Condition{}
+	}
+	encoded, err := json.Marshal(conditions)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid conditions: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	sub := db.WebhookSubscription{
+		ID:            uuid.New().String(),
+		Name:          req.Name,
+		URL:           req.URL,
+		Secret:        req.Secret,
+		AnalysisTypes: req.AnalysisTypes,
+		WorkflowID:    req.WorkflowID,
+		Conditions:    encoded,
+		Match:         req.Match,
+		Enabled:       req.Enabled == nil || *req.Enabled,
+	}
+	if existing != nil {
+		sub.ID = existing.ID
+		sub.CreatedAt = existing.CreatedAt
+	}
+	tenantID := auth.TenantID(r.Context())
+	if err := db.SaveWebhookSubscription(tenantID, sub); err != nil {
+		log.Printf("Error saving webhook subscription: %v", err)
+		http.Error(w, "Failed to save webhook subscription", http.StatusInternalServerError)
+		return
+	}
+
+	saved, err := db.GetWebhookSubscription(tenantID, sub.ID)
+	if err != nil {
+		log.Printf("Error getting webhook subscription %s: %v", sub.ID, err)
+		http.Error(w, "Failed to get webhook subscription", http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(saved)
+}
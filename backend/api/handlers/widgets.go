@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// widgetRequest is the body of a request to share a widget
+type widgetRequest struct {
+	Name         string `json:"name"`
+	WorkflowID   string `json:"workflow_id"`
+	AnalysisType string `json:"analysis_type"`
+	Field        string `json:"field,omitempty"`
+	Period       string `json:"period,omitempty"`
+}
+
+// sharedWidget is a widget with its token and data URL, which are only returned when the
+// token is issued
+type sharedWidget struct {
+	db.Widget
+	Token   string `json:"token"`
+	DataURL string `json:"data_url"`
+}
+
+// widgetData is the response of the data endpoint of a widget
+type widgetData struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	AnalysisType string      `json:"analysis_type"`
+	Field        string      `json:"field,omitempty"`
+	Period       string      `json:"period"`
+	ResultID     string      `json:"result_id"`
+	UpdatedAt    interface{} `json:"updated_at"` // When the result was stored
+	Data         interface{} `json:"data"`
+}
+
+// HandleWidgets handles /api/widgets: GET lists the widgets, of one workflow with
+// ?workflow_id=, and POST shares a new one
+func HandleWidgets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		widgets, err := db.ListWidgets(auth.TenantID(r.Context()), r.URL.Query().Get("workflow_id"))
+		if err != nil {
+			log.Printf("Error listing widgets: %v", err)
+			http.Error(w, "Failed to list widgets", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(widgets)
+
+	case http.MethodPost:
+		var req widgetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		widget, err := req.widget()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		exists, err := db.WorkflowExists(auth.TenantID(r.Context()), widget.WorkflowID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Workflow not found", http.StatusNotFound)
+			return
+		}
+
+		token, err := auth.GenerateWidgetToken()
+		if err != nil {
+			log.Printf("Error generating widget token: %v", err)
+			http.Error(w, "Failed to create widget", http.StatusInternalServerError)
+			return
+		}
+		widget.ID = uuid.New().String()
+		widget.TokenPrefix = token[:auth.DisplayPrefixLength]
+		widget.CreatedBy = actorFromRequest(r)
+		widget.CreatedAt = time.Now()
+		if err := db.CreateWidget(widget, auth.HashKey(token)); err != nil {
+			log.Printf("Error creating widget: %v", err)
+			http.Error(w, "Failed to create widget", http.StatusInternalServerError)
+			return
+		}
+
+		recordActivity(r, db.ActivityWidgetShared, widget.WorkflowID,
+			fmt.Sprintf("Widget %s shared", widget.Name),
+			map[string]interface{}{"widget_id": widget.ID, "analysis_type": widget.AnalysisType, "field": widget.Field})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sharedWidget{Widget: widget, Token: token, DataURL: widgetDataURL(widget.ID, token)})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// widget validates a widget request
+func (req widgetRequest) widget() (db.Widget, error) {
+	widget := db.Widget{
+		Name:         strings.TrimSpace(req.Name),
+		WorkflowID:   req.WorkflowID,
+		AnalysisType: req.AnalysisType,
+		Field:        strings.Trim(req.Field, "."),
+		Period:       req.Period,
+	}
+	if widget.Period == "" {
+		widget.Period = db.WidgetPeriodLatest
+	}
+	switch {
+	case widget.Name == "":
+		return db.Widget{}, fmt.Errorf("name is required")
+	case widget.WorkflowID == "":
+		return db.Widget{}, fmt.Errorf("workflow_id is required")
+	case widget.AnalysisType == "":
+		return db.Widget{}, fmt.Errorf("analysis_type is required")
+	case strings.Contains(widget.Field, ".."):
+		return db.Widget{}, fmt.Errorf("field must be a dotted path such as distribution or metrics.datasets")
+	}
+	switch widget.Period {
+	case db.WidgetPeriodLatest, db.WidgetPeriodDay, db.WidgetPeriodWeek, db.WidgetPeriodMonth:
+	default:
+		return db.Widget{}, fmt.Errorf("period must be %s, %s, %s or %s",
+			db.WidgetPeriodLatest, db.WidgetPeriodDay, db.WidgetPeriodWeek, db.WidgetPeriodMonth)
+	}
+	return widget, nil
+}
+
+// HandleWidget handles /api/widgets/{id}: GET returns the widget and DELETE revokes it.
+// POST /api/widgets/{id}/rotate issues a new token, and GET /api/widgets/{id}/data
+// serves the data of the widget to holders of its token.
+func HandleWidget(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/widgets/")
+	if path == "" {
+		HandleWidgets(w, r)
+		return
+	}
+	if id, ok := strings.CutSuffix(path, "/data"); ok {
+		handleWidgetData(w, r, id)
+		return
+	}
+	if id, ok := strings.CutSuffix(path, "/rotate"); ok {
+		handleWidgetRotate(w, r, id)
+		return
+	}
+
+	id := path
+	tenantID := auth.TenantID(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		widget, err := db.GetWidget(tenantID, id)
+		if err != nil {
+			sendWidgetError(w, id, err)
+			return
+		}
+		json.NewEncoder(w).Encode(widget)
+	case http.MethodDelete:
+		widget, err := db.GetWidget(tenantID, id)
+		if err == nil {
+			err = db.DeleteWidget(tenantID, id)
+		}
+		if err != nil {
+			sendWidgetError(w, id, err)
+			return
+		}
+		recordActivity(r, db.ActivityWidgetRevoked, widget.WorkflowID, fmt.Sprintf("Widget %s revoked", widget.Name),
+			map[string]interface{}{"widget_id": widget.ID})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWidgetRotate issues a new token for a widget; the old token stops working
+func handleWidgetRotate(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, err := auth.GenerateWidgetToken()
+	if err != nil {
+		log.Printf("Error generating widget token: %v", err)
+		http.Error(w, "Failed to rotate widget token", http.StatusInternalServerError)
+		return
+	}
+	tenantID := auth.TenantID(r.Context())
+	if err := db.RotateWidgetToken(tenantID, id, auth.HashKey(token), token[:auth.DisplayPrefixLength]); err != nil {
+		sendWidgetError(w, id, err)
+		return
+	}
+	widget, err := db.GetWidget(tenantID, id)
+	if err != nil {
+		sendWidgetError(w, id, err)
+		return
+	}
+	json.NewEncoder(w).Encode(sharedWidget{Widget: *widget, Token: token, DataURL: widgetDataURL(widget.ID, token)})
+}
+
+// handleWidgetData serves the data of a widget: the field of the latest stored result of
+// its analysis type within its period. It needs the widget token, as the token query
+// parameter or a bearer token, rather than an API key.
+func handleWidgetData(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := auth.KeyFromHeaders("", r.Header.Get("Authorization"))
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if !strings.HasPrefix(token, auth.WidgetTokenPrefix) {
+		http.Error(w, "A valid widget token is required", http.StatusUnauthorized)
+		return
+	}
+	widget, err := db.GetWidgetByToken(id, auth.HashKey(token))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "A valid widget token is required", http.StatusUnauthorized)
+			return
+		}
+		sendWidgetError(w, id, err)
+		return
+	}
+
+	// Widgets show the results stored by the tenant of their workflow
+	owner, err := db.GetWorkflow(db.AllTenants, widget.WorkflowID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("No %s result for this %s yet", widget.AnalysisType, widget.Period), http.StatusNotFound)
+		return
+	}
+	result, err := db.GetLatestAnalysisResult(owner.TenantID, widget.WorkflowID, widget.AnalysisType, widgetPeriodStart(widget.Period, time.Now()))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, fmt.Sprintf("No %s result for this %s yet", widget.AnalysisType, widget.Period), http.StatusNotFound)
+			return
+		}
+		sendWidgetError(w, id, err)
+		return
+	}
+	guardStoredResults(owner.TenantID, []map[string]interface{}{result})
+
+	data, ok := fieldAt(result["results"], widget.Field)
+	if !ok {
+		http.Error(w, fmt.Sprintf("The latest %s result has no %s field", widget.AnalysisType, widget.Field), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	json.NewEncoder(w).Encode(widgetData{
+		ID:           widget.ID,
+		Name:         widget.Name,
+		AnalysisType: widget.AnalysisType,
+		Field:        widget.Field,
+		Period:       widget.Period,
+		ResultID:     fmt.Sprint(result["id"]),
+		UpdatedAt:    result["created_at"],
+		Data:         data,
+	})
+}
+
+// widgetPeriodStart returns the start of the current period of a widget in UTC, or the
+// zero time for the latest result
+func widgetPeriodStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case db.WidgetPeriodDay:
+		return today
+	case db.WidgetPeriodWeek:
+		// Weeks start on Monday
+		return today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	case db.WidgetPeriodMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Time{}
+}
+
+// fieldAt returns the value at a dotted path in results, or the results for an empty path
+func fieldAt(results interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return results, true
+	}
+	value := results
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// widgetDataURL is the path embedding pages fetch the data of a widget from
+func widgetDataURL(id, token string) string {
+	return fmt.Sprintf("/api/widgets/%s/data?token=%s", id, token)
+}
+
+// sendWidgetError maps a widget storage error to a response
+func sendWidgetError(w http.ResponseWriter, id string, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		http.Error(w, "Widget not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Error accessing widget %s: %v", id, err)
+	http.Error(w, "Failed to access widget", http.StatusInternalServerError)
+}
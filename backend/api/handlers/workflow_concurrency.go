@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/workflow"
+)
+
+// workflowConcurrencyResponse reports the concurrency settings of a workflow with its
+// executing runs and its LLM calls running and waiting for a slot
+type workflowConcurrencyResponse struct {
+	db.WorkflowConcurrency
+	Running      int `json:"running"`
+	RunningCalls int `json:"running_calls"`
+	QueuedCalls  int `json:"queued_calls"`
+}
+
+// handleWorkflowConcurrency handles /api/workflows/{id}/concurrency: GET returns the
+// concurrency settings and PUT replaces them, applying the LLM call limit at once
+func handleWorkflowConcurrency(w http.ResponseWriter, r *http.Request, workflowID string) {
+	exists, err := db.WorkflowExists(auth.TenantID(r.Context()), workflowID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Workflow not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req db.WorkflowConcurrency
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		switch req.Mode {
+		case "", db.ConcurrencyModeLimit:
+			req.Mode = db.ConcurrencyModeLimit
+			if req.MaxConcurrentRuns < 0 {
+				http.Error(w, "max_concurrent_runs must not be negative", http.StatusBadRequest)
+				return
+			}
+		case db.ConcurrencyModeMutex:
+			req.MaxConcurrentRuns = 1
+		default:
+			http.Error(w, fmt.Sprintf("mode must be %s or %s", db.ConcurrencyModeLimit, db.ConcurrencyModeMutex), http.StatusBadRequest)
+			return
+		}
+		if req.MaxConcurrentCalls < 0 {
+			http.Error(w, "max_concurrent_calls must not be negative", http.StatusBadRequest)
+			return
+		}
+		req.WorkflowID = workflowID
+		if err := db.SaveWorkflowConcurrency(req); err != nil {
+			log.Printf("Error saving concurrency of workflow %s: %v", workflowID, err)
+			http.Error(w, "Failed to save concurrency settings", http.StatusInternalServerError)
+			return
+		}
+		core.Scheduler.SetWorkflowLimit(workflowID, req.MaxConcurrentCalls)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	settings, err := db.GetWorkflowConcurrency(workflowID)
+	if err != nil {
+		log.Printf("Error getting concurrency of workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to get concurrency settings", http.StatusInternalServerError)
+		return
+	}
+	calls := core.Scheduler.WorkflowStats(workflowID)
+	json.NewEncoder(w).Encode(workflowConcurrencyResponse{
+		WorkflowConcurrency: settings,
+		Running:             workflow.Runs.Running(workflowID),
+		RunningCalls:        calls.Running,
+		QueuedCalls:         calls.Queued,
+	})
+}
+
+// WorkflowBusyError is returned for runs of a workflow that already has as many runs
+// executing as its concurrency settings allow
+type WorkflowBusyError struct {
+	WorkflowID string
+	MaxRuns    int
+	Skipped    bool // The workflow is in mutex mode, so the run is skipped rather than rejected
+}
+
+func (e *WorkflowBusyError) Error() string {
+	if e.Skipped {
+		return fmt.Sprintf("run of workflow %s skipped while the previous run is executing", e.WorkflowID)
+	}
+	return fmt.Sprintf("workflow %s already has %d runs executing", e.WorkflowID, e.MaxRuns)
+}
+
+// tryStartWorkflowRun starts a run of a workflow within its concurrency settings and
+// returns the function that ends it. Runs that may not start fail with a
+// *WorkflowBusyError; skipped runs are recorded in the activity feed.
+func tryStartWorkflowRun(actor string, workflowObj db.Workflow) (func(), error) {
+	settings, err := db.GetWorkflowConcurrency(workflowObj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get concurrency settings: %w", err)
+	}
+
+	max := settings.MaxConcurrentRuns
+	if settings.Mode == db.ConcurrencyModeMutex {
+		max = 1
+	}
+	release, ok := workflow.Runs.TryStart(workflowObj.ID, max)
+	if ok {
+		return release, nil
+	}
+
+	if settings.Mode == db.ConcurrencyModeMutex {
+		recordActivityAs(workflowObj.TenantID, actor, db.ActivityWorkflowRunSkipped, workflowObj.ID,
+			fmt.Sprintf("Workflow \"%s\" run skipped while the previous run is executing", workflowObj.Name), nil)
+		return nil, &WorkflowBusyError{WorkflowID: workflowObj.ID, MaxRuns: max, Skipped: true}
+	}
+	return nil, &WorkflowBusyError{WorkflowID: workflowObj.ID, MaxRuns: max}
+}
+
+// sendWorkflowBusy writes the response of a run that may not start: skipped in mutex mode
+// and rejected in limit mode
+func sendWorkflowBusy(w http.ResponseWriter, busy *WorkflowBusyError) {
+	if busy.Skipped {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"workflow_id": busy.WorkflowID,
+			"status":      "skipped",
+			"reason":      "previous run is still executing",
+		})
+		return
+	}
+
+	w.Header().Set("Retry-After", "60")
+	http.Error(w, fmt.Sprintf("Workflow %s already has %d runs executing", busy.WorkflowID, busy.MaxRuns), http.StatusTooManyRequests)
+}
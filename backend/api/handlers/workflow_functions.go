@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/workflow"
+)
+
+// workflowFunctionRunner returns how the function nodes of a workflow run their analyses:
+// through the analysis handler of ctx, as /api/analysis requests of the tenant of ctx.
+// With a workflow ID, results are stored for the workflow and run; node tests and scratch
+// runs pass none, so they store nothing.
+func workflowFunctionRunner(ctx context.Context, actor, workflowID, runID string) workflow.FunctionRunner {
+	return func(analysisType string, parameters, inputs map[string]interface{}, upstream []map[string]interface{}) (map[string]interface{}, error) {
+		analysisHandler, ok := ctx.Value("analysisHandler").(*AnalysisHandler)
+		if !ok || analysisHandler == nil {
+			return nil, fmt.Errorf("analysis is not available")
+		}
+
+		resp, err := analysisHandler.PerformAnalysis(ctx, actor, functionNodeRequest(analysisType, workflowID, runID, parameters, inputs, upstream), nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error != nil {
+			return nil, analysis.ResponseError(resp.Error)
+		}
+
+		// Downstream nodes map the fields of the response, such as results and confidence
+		encoded, err := json.Marshal(resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s response: %w", analysisType, err)
+		}
+		var output map[string]interface{}
+		if err := json.Unmarshal(encoded, &output); err != nil {
+			return nil, fmt.Errorf("failed to decode %s response: %w", analysisType, err)
+		}
+		return output, nil
+	}
+}
+
+// functionNodeRequest builds the analysis request of a function node. The text input is
+// analyzed, conversation_ids reference stored conversations, and the other inputs, such
+// as the outputs of upstream nodes, are passed as data. The stored results of upstream
+// function nodes are its sources, so their lineage and confidence carry over.
+func functionNodeRequest(analysisType, workflowID, runID string, parameters, inputs map[string]interface{}, upstream []map[string]interface{}) models.StandardAnalysisRequest {
+	req := models.StandardAnalysisRequest{
+		AnalysisType: analysisType,
+		WorkflowID:   workflowID,
+		RunID:        runID,
+		Parameters:   map[string]interface{}{},
+		Data:         map[string]interface{}{},
+	}
+	for k, v := range parameters {
+		req.Parameters[k] = v
+	}
+	for k, v := range inputs {
+		switch k {
+		case "text":
+			req.Text, _ = v.(string)
+		case "conversation_ids":
+			ids, _ := v.([]interface{})
+			for _, id := range ids {
+				if id, ok := id.(string); ok {
+					req.ConversationIDs = append(req.ConversationIDs, id)
+				}
+			}
+		default:
+			if _, isParameter := parameters[k]; !isParameter {
+				req.Data[k] = v
+			}
+		}
+	}
+	for _, output := range upstream {
+		resultID, _ := output["result_id"].(string)
+		sourceType, _ := output["analysis_type"].(string)
+		if resultID != "" && sourceType != "" {
+			req.Sources = append(req.Sources, models.SourceRef{Type: sourceType, ID: resultID})
+		}
+	}
+	// Referenced conversations are analyzed instead of the text
+	if len(req.ConversationIDs) > 0 {
+		req.Text = ""
+	}
+	return req
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/workflow"
+)
+
+// workflowEditResponse is returned after a successful granular edit, undo or redo
+type workflowEditResponse struct {
+	Version  int         `json:"version"`
+	Workflow db.Workflow `json:"workflow"`
+}
+
+// handleWorkflowOperations handles /api/workflows/{id}/operations endpoint
+func handleWorkflowOperations(w http.ResponseWriter, r *http.Request, workflowId string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Return the operation log
+		tenantID := auth.TenantID(r.Context())
+		if _, err := db.GetWorkflow(tenantID, workflowId); err != nil {
+			http.Error(w, "Workflow not found", http.StatusNotFound)
+			return
+		}
+		operations, err := db.GetWorkflowOperations(tenantID, workflowId)
+		if err != nil {
+			log.Printf("Error getting workflow operations: %v", err)
+			http.Error(w, "Failed to get workflow operations", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(operations)
+
+	case http.MethodPost:
+		// Apply a group of edits based on a known version
+		var req struct {
+			BaseVersion *int                 `json:"base_version"`
+			Operations  []workflow.Operation `json:"operations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if req.BaseVersion == nil {
+			http.Error(w, "base_version is required", http.StatusBadRequest)
+			return
+		}
+
+		workflowObj, ok := loadWorkflowAtVersion(w, r, workflowId, *req.BaseVersion)
+		if !ok {
+			return
+		}
+
+		updated, err := workflow.ApplyOperations(workflowObj, req.Operations, actorFromRequest(r))
+		if err != nil {
+			writeWorkflowEditError(w, r, workflowId, err)
+			return
+		}
+
+		recordActivity(r, db.ActivityWorkflowUpdated, workflowId,
+			fmt.Sprintf("Workflow \"%s\" edited (%d operations)", updated.Name, len(req.Operations)), nil)
+		json.NewEncoder(w).Encode(workflowEditResponse{Version: updated.Version, Workflow: updated})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWorkflowUndoRedo handles /api/workflows/{id}/undo and /api/workflows/{id}/redo endpoints
+func handleWorkflowUndoRedo(w http.ResponseWriter, r *http.Request, workflowId string, redo bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		BaseVersion *int `json:"base_version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if req.BaseVersion == nil {
+		http.Error(w, "base_version is required", http.StatusBadRequest)
+		return
+	}
+
+	workflowObj, ok := loadWorkflowAtVersion(w, r, workflowId, *req.BaseVersion)
+	if !ok {
+		return
+	}
+
+	var updated db.Workflow
+	var err error
+	if redo {
+		updated, err = workflow.Redo(workflowObj)
+	} else {
+		updated, err = workflow.Undo(workflowObj)
+	}
+	if err != nil {
+		writeWorkflowEditError(w, r, workflowId, err)
+		return
+	}
+
+	action := "undo"
+	if redo {
+		action = "redo"
+	}
+	recordActivity(r, db.ActivityWorkflowUpdated, workflowId,
+		fmt.Sprintf("Workflow \"%s\" edited (%s)", updated.Name, action), nil)
+	json.NewEncoder(w).Encode(workflowEditResponse{Version: updated.Version, Workflow: updated})
+}
+
+// loadWorkflowAtVersion fetches a workflow of the tenant of a request and checks it is still at the version the client edited.
+// It writes the error response and returns false if the workflow is missing or has moved on.
+func loadWorkflowAtVersion(w http.ResponseWriter, r *http.Request, workflowId string, baseVersion int) (db.Workflow, bool) {
+	workflowObj, err := db.GetWorkflow(auth.TenantID(r.Context()), workflowId)
+	if err != nil {
+		http.Error(w, "Workflow not found", http.StatusNotFound)
+		return db.Workflow{}, false
+	}
+
+	if workflowObj.Version != baseVersion {
+		writeVersionConflict(w, workflowObj.Version)
+		return db.Workflow{}, false
+	}
+
+	return workflowObj, true
+}
+
+// writeWorkflowEditError maps errors from granular edits to HTTP responses
+func writeWorkflowEditError(w http.ResponseWriter, r *http.Request, workflowId string, err error) {
+	switch {
+	case errors.Is(err, db.ErrVersionConflict):
+		// Another edit was saved between reading and writing the workflow
+		current, getErr := db.GetWorkflow(auth.TenantID(r.Context()), workflowId)
+		if getErr != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeVersionConflict(w, current.Version)
+	case errors.Is(err, workflow.ErrNothingToUndo), errors.Is(err, workflow.ErrNothingToRedo):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, workflow.ErrInvalidOperation):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		log.Printf("Error editing workflow %s: %v", workflowId, err)
+		http.Error(w, fmt.Sprintf("Failed to edit workflow: %s", err), http.StatusInternalServerError)
+	}
+}
+
+// writeVersionConflict reports that an edit was based on a stale workflow version
+func writeVersionConflict(w http.ResponseWriter, currentVersion int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":           db.ErrVersionConflict.Error(),
+		"current_version": currentVersion,
+	})
+}
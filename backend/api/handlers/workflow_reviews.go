@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"agenticflows/backend/api/models"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/workflow"
+
+	"github.com/google/uuid"
+)
+
+// Workflow review listing limits
+const (
+	defaultReviewLimit = 100
+	maxReviewLimit     = 1000
+)
+
+// Review decisions, the actions of POST /api/reviews/{id}/{decision}
+var reviewDecisions = map[string]string{
+	"approve": db.ReviewApproved,
+	"edit":    db.ReviewEdited,
+	"reject":  db.ReviewRejected,
+}
+
+// reviewDecisionRequest is the body of a review decision
+type reviewDecisionRequest struct {
+	// Result replaces the item of the review; it is required to edit
+	Result  map[string]interface{} `json:"result,omitempty"`
+	Comment string                 `json:"comment,omitempty"`
+}
+
+// reviewDecisionResponse is a decided review with the outcome of the resumed run, which
+// is missing for rejected reviews. RunError is set when the resumed run failed.
+type reviewDecisionResponse struct {
+	Review   db.WorkflowReview                 `json:"review"`
+	Run      *models.WorkflowExecutionResponse `json:"run,omitempty"`
+	RunError string                            `json:"run_error,omitempty"`
+}
+
+// reviewRunState is what a run paused at a review node needs to resume
+type reviewRunState struct {
+	Results   map[string]interface{} `json:"results"`
+	Completed []string               `json:"completed"`
+	Tags      map[string]string      `json:"tags,omitempty"`
+	// RunID is the run the review pauses; reviews saved before runs were recorded have none
+	RunID string `json:"run_id,omitempty"`
+}
+
+// HandleReviews handles /api/reviews: GET lists the reviews of paused workflow runs
+func HandleReviews(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	listReviews(w, r)
+}
+
+// HandleReview handles /api/reviews/{id}: GET returns a review, and POST /approve, /edit
+// or /reject decides on it, resuming or ending its run
+func HandleReview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/reviews/"), "/")
+	if path == "" {
+		HandleReviews(w, r)
+		return
+	}
+	id, action, _ := strings.Cut(path, "/")
+	_, isDecision := reviewDecisions[action]
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		review, err := db.GetWorkflowReview(auth.TenantID(r.Context()), id)
+		if err != nil {
+			writeReviewError(w, id, err)
+			return
+		}
+		json.NewEncoder(w).Encode(review)
+	case isDecision && r.Method == http.MethodPost:
+		decideReview(w, r, id, action)
+	case action == "" || isDecision:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// listReviews lists workflow reviews, pending ones by default
+func listReviews(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.WorkflowReviewFilter{
+		TenantID:   auth.TenantID(r.Context()),
+		WorkflowID: query.Get("workflow_id"),
+		Status:     query.Get("status"),
+		Limit:      defaultReviewLimit,
+	}
+	switch filter.Status {
+	case "":
+		filter.Status = db.ReviewPending
+	case "all":
+		filter.Status = ""
+	case db.ReviewPending, db.ReviewApproved, db.ReviewEdited, db.ReviewRejected:
+	default:
+		http.Error(w, "status must be pending, approved, edited, rejected or all", http.StatusBadRequest)
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(n, maxReviewLimit)
+	}
+
+	reviews, err := db.ListWorkflowReviews(filter)
+	if err != nil {
+		log.Printf("Error listing workflow reviews: %v", err)
+		http.Error(w, "Failed to list reviews", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(reviews)
+}
+
+// decideReview approves, edits or rejects a pending review. Approved and edited reviews
+// resume their run, which may pause again at a later review node.
+func decideReview(w http.ResponseWriter, r *http.Request, id, action string) {
+	var req reviewDecisionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if action == "edit" && req.Result == nil {
+		http.Error(w, "result is required to edit a review", http.StatusBadRequest)
+		return
+	}
+
+	response, err := applyReviewDecision(r.Context(), actorFromRequest(r), id, reviewDecisions[action], req.Result, strings.TrimSpace(req.Comment))
+	var busy *WorkflowBusyError
+	switch {
+	case errors.As(err, &busy):
+		sendWorkflowBusy(w, busy)
+		return
+	case errors.Is(err, ErrWorkflowNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		writeReviewError(w, id, err)
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// applyReviewDecision records the decision on a pending review and resumes its run unless
+// it was rejected. The edited result replaces the item of edited reviews. Runs resume
+// within the concurrency settings of their workflow, and a run that may not resume leaves
+// the review pending.
+func applyReviewDecision(ctx context.Context, actor, id, status string, edited map[string]interface{}, comment string) (*reviewDecisionResponse, error) {
+	review, err := db.GetWorkflowReview(auth.TenantID(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if review.Status != db.ReviewPending {
+		return nil, fmt.Errorf("workflow review %s is already %s", id, review.Status)
+	}
+
+	var state reviewRunState
+	stateErr := json.Unmarshal(review.RunState, &state)
+
+	if status == db.ReviewRejected {
+		decided, err := db.DecideWorkflowReview(id, status, nil, actor, comment)
+		if err != nil {
+			return nil, err
+		}
+		recordReviewDecision(auth.TenantID(ctx), actor, decided)
+		if stateErr == nil && state.RunID != "" {
+			finishRun(state.RunID, db.RunRejected, nil, 0, nil, nil)
+		}
+		return &reviewDecisionResponse{Review: *decided}, nil
+	}
+	if stateErr != nil {
+		return nil, fmt.Errorf("invalid run state of review %s: %w", id, stateErr)
+	}
+	reviewed := edited
+	if status == db.ReviewApproved {
+		if err := json.Unmarshal(review.Item, &reviewed); err != nil {
+			return nil, fmt.Errorf("invalid item of review %s: %w", id, err)
+		}
+	}
+	result, err := json.Marshal(reviewed)
+	if err != nil {
+		return nil, invalidRequest(fmt.Errorf("result is not serializable: %w", err))
+	}
+
+	// The run resumes on the graph it started with, within the current concurrency settings
+	workflowObj, err := db.GetWorkflow(auth.TenantID(ctx), review.WorkflowID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, review.WorkflowID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow: %w", err)
+	}
+	release, err := tryStartWorkflowRun(actor, workflowObj)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	decided, err := db.DecideWorkflowReview(id, status, result, actor, comment)
+	if err != nil {
+		return nil, err
+	}
+	recordReviewDecision(auth.TenantID(ctx), actor, decided)
+
+	// The run continues in its history; runs paused before runs were recorded start one
+	runID := state.RunID
+	if runID == "" {
+		runID = uuid.New().String()
+		startRun(workflowObj.TenantID, runID, review.WorkflowID, db.RunKindWorkflow, actor, nil)
+	} else if err := db.ResumeRun(runID); err != nil {
+		log.Printf("Error resuming run %s: %v", runID, err)
+	}
+
+	snapshot := db.Workflow{ID: review.WorkflowID, Name: workflowObj.Name, Nodes: review.Nodes, Edges: review.Edges, TenantID: workflowObj.TenantID}
+	run, err := runWorkflow(ctx, actor, snapshot, state.Tags, runID, func(executor *workflow.Executor) (map[string]interface{}, error) {
+		return executor.Resume(state.Results, state.Completed, review.NodeID, reviewed)
+	})
+	if err != nil {
+		// The decision stands; the run failed after it
+		return &reviewDecisionResponse{Review: *decided, RunError: err.Error()}, nil
+	}
+	return &reviewDecisionResponse{Review: *decided, Run: run}, nil
+}
+
+// saveReviewPause stores the pending review of a run paused at a review node
+func saveReviewPause(actor string, workflowObj db.Workflow, executor *workflow.Executor, pause *workflow.ReviewPause, tags map[string]string, runID string) (*db.WorkflowReview, error) {
+	item, err := json.Marshal(pause.Item)
+	if err != nil {
+		return nil, fmt.Errorf("review item is not serializable: %w", err)
+	}
+	state, err := json.Marshal(reviewRunState{Results: pause.Results, Completed: pause.Completed, Tags: tags, RunID: runID})
+	if err != nil {
+		return nil, fmt.Errorf("run state is not serializable: %w", err)
+	}
+
+	label, instructions := executor.ReviewSettings(pause.NodeID)
+	review := db.WorkflowReview{
+		ID:           uuid.New().String(),
+		WorkflowID:   workflowObj.ID,
+		WorkflowName: workflowObj.Name,
+		NodeID:       pause.NodeID,
+		Label:        label,
+		Instructions: instructions,
+		Item:         item,
+		Status:       db.ReviewPending,
+		RequestedBy:  actor,
+		Nodes:        workflowObj.Nodes,
+		Edges:        workflowObj.Edges,
+		RunState:     state,
+	}
+	if err := db.CreateWorkflowReview(review); err != nil {
+		return nil, fmt.Errorf("failed to save review: %w", err)
+	}
+	return &review, nil
+}
+
+// recordReviewDecision adds a review decision to the activity feed of a tenant
+func recordReviewDecision(tenantID, actor string, review *db.WorkflowReview) {
+	recordActivityAs(tenantID, actor, db.ActivityWorkflowReviewDecided, review.WorkflowID,
+		fmt.Sprintf("Review of workflow \"%s\" at node %s %s", review.WorkflowName, review.NodeID, review.Status),
+		map[string]interface{}{"review_id": review.ID, "node_id": review.NodeID, "status": review.Status, "comment": review.Comment})
+}
+
+// writeReviewError writes the error of a review lookup or decision
+func writeReviewError(w http.ResponseWriter, id string, err error) {
+	var invalid *requestError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case strings.Contains(err.Error(), "not found"):
+		http.Error(w, "Review not found", http.StatusNotFound)
+	case strings.Contains(err.Error(), "is already"):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Error handling workflow review %s: %v", id, err)
+		http.Error(w, "Failed to handle review", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/notify"
+	"agenticflows/backend/workflow"
+)
+
+// maxSLARuns caps the runs listed by /api/workflows/{id}/sla/runs
+const maxSLARuns = 500
+
+// slaRecorder records the SLA compliance of workflow runs
+var slaRecorder = workflow.NewSLARecorder(nil)
+
+// SetSLANotifier sets where SLA breach alerts are sent
+func SetSLANotifier(notifier notify.Notifier) {
+	slaRecorder = workflow.NewSLARecorder(notifier)
+}
+
+// recordSLA checks a run of a workflow against its SLA
+func recordSLA(ctx context.Context, workflowID, kind string, m workflow.RunMeasurement) {
+	if workflowID == "" {
+		return
+	}
+	slaRecorder.Record(ctx, workflowID, kind, m)
+}
+
+// handleWorkflowSLA handles /api/workflows/{id}/sla: GET returns the SLA of the workflow
+// with its compliance, PUT replaces the SLA and DELETE removes it with its run history
+func handleWorkflowSLA(w http.ResponseWriter, r *http.Request, workflowID string) {
+	exists, err := db.WorkflowExists(auth.TenantID(r.Context()), workflowID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Workflow not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req db.WorkflowSLA
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateSLA(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.WorkflowID = workflowID
+		if err := db.SaveWorkflowSLA(req); err != nil {
+			log.Printf("Error saving SLA of workflow %s: %v", workflowID, err)
+			http.Error(w, "Failed to save SLA", http.StatusInternalServerError)
+			return
+		}
+	case http.MethodDelete:
+		if err := db.DeleteWorkflowSLA(workflowID); err != nil {
+			log.Printf("Error deleting SLA of workflow %s: %v", workflowID, err)
+			http.Error(w, "Failed to delete SLA", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	compliance, ok, err := db.GetSLACompliance(workflowID, time.Time{})
+	if err != nil {
+		log.Printf("Error getting SLA compliance of workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to get SLA", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Workflow has no SLA", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(compliance)
+}
+
+// validateSLA checks that an SLA sets at least one limit and that its limits are in range
+func validateSLA(sla db.WorkflowSLA) error {
+	switch {
+	case sla.MaxRuntimeSeconds < 0:
+		return fmt.Errorf("max_runtime_seconds must not be negative")
+	case sla.MaxCost < 0:
+		return fmt.Errorf("max_cost must not be negative")
+	case sla.MinConfidence < 0 || sla.MinConfidence > 1:
+		return fmt.Errorf("min_confidence must be between 0 and 1")
+	case sla.MaxRuntimeSeconds == 0 && sla.MaxCost == 0 && sla.MinConfidence == 0:
+		return fmt.Errorf("at least one of max_runtime_seconds, max_cost or min_confidence is required")
+	}
+	return nil
+}
+
+// handleWorkflowSLARuns handles GET /api/workflows/{id}/sla/runs: the recorded runs of the
+// workflow, newest first. breached=true lists only the runs that breached the SLA.
+func handleWorkflowSLARuns(w http.ResponseWriter, r *http.Request, workflowID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	breachedOnly := query.Get("breached") == "true"
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxSLARuns {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxSLARuns), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	since, ok := sinceParam(w, r)
+	if !ok {
+		return
+	}
+
+	runs, err := db.ListSLARuns(workflowID, since, breachedOnly, limit)
+	if err != nil {
+		log.Printf("Error listing SLA runs of workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to list SLA runs", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(runs)
+}
+
+// HandleSLAs handles GET /api/slas: the SLA compliance of every workflow with an SLA,
+// least compliant first, over the runs since the optional since timestamp
+func HandleSLAs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	since, ok := sinceParam(w, r)
+	if !ok {
+		return
+	}
+	compliance, err := db.ListSLACompliance(auth.TenantID(r.Context()), since)
+	if err != nil {
+		log.Printf("Error listing SLA compliance: %v", err)
+		http.Error(w, "Failed to list SLA compliance", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(compliance)
+}
+
+// sinceParam reads the optional since query parameter. When it is invalid it writes the
+// response and returns false.
+func sinceParam(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	value := r.URL.Query().Get("since")
+	if value == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		http.Error(w, "since must be an RFC3339 timestamp", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -1,16 +1,24 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
+	"agenticflows/backend/analysis/core"
 	"agenticflows/backend/api/models"
+	"agenticflows/backend/auth"
 	"agenticflows/backend/db"
+	"agenticflows/backend/demo"
 	"agenticflows/backend/workflow"
+
+	"github.com/google/uuid"
 )
 
 // HandleWorkflows handles /api/workflows endpoint
@@ -19,8 +27,8 @@ func HandleWorkflows(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "GET":
-		// Return all workflows
-		workflows, err := db.GetAllWorkflows()
+		// Return all workflows of the tenant
+		workflows, err := db.GetAllWorkflows(auth.TenantID(r.Context()))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -46,10 +54,15 @@ func HandleWorkflows(w http.ResponseWriter, r *http.Request) {
 			workflow.Date = time.Now().Format("2006-01-02")
 		}
 
-		if err := db.CreateWorkflow(workflow); err != nil {
+		if err := db.CreateWorkflow(auth.TenantID(r.Context()), workflow); err != nil {
+			if errors.Is(err, db.ErrWorkflowIDTaken) {
+				http.Error(w, fmt.Sprintf("Workflow ID %s is not available", workflow.ID), http.StatusConflict)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		recordActivity(r, db.ActivityWorkflowCreated, workflow.ID, fmt.Sprintf("Workflow \"%s\" created", workflow.Name), nil)
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(workflow)
 
@@ -75,6 +88,18 @@ func HandleWorkflow(w http.ResponseWriter, r *http.Request) {
 	// Check if it's a request for execution config or execution
 	if len(pathParts) >= 1 && pathParts[0] != "" {
 		id := pathParts[0]
+		tenantID := auth.TenantID(r.Context())
+
+		// Workflows of other tenants, and everything stored for them, are not found
+		exists, err := db.WorkflowExists(tenantID, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Workflow not found", http.StatusNotFound)
+			return
+		}
 
 		// Check if it's a request for execution config
 		if len(pathParts) > 1 && pathParts[1] == "execution-config" {
@@ -83,6 +108,56 @@ func HandleWorkflow(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Check if it's a request for granular edits, undo or redo
+		if len(pathParts) > 1 && pathParts[1] == "operations" {
+			handleWorkflowOperations(w, r, id)
+			return
+		}
+		if len(pathParts) > 1 && (pathParts[1] == "undo" || pathParts[1] == "redo") {
+			handleWorkflowUndoRedo(w, r, id, pathParts[1] == "redo")
+			return
+		}
+
+		// Check if it's a request for the concurrency settings
+		if len(pathParts) > 1 && pathParts[1] == "concurrency" {
+			handleWorkflowConcurrency(w, r, id)
+			return
+		}
+
+		// Check if it's a request for the SLA of the workflow or its compliance
+		if len(pathParts) > 2 && pathParts[1] == "sla" && pathParts[2] == "runs" {
+			handleWorkflowSLARuns(w, r, id)
+			return
+		}
+		if len(pathParts) > 1 && pathParts[1] == "sla" {
+			handleWorkflowSLA(w, r, id)
+			return
+		}
+
+		// Check if it's a request for the LLM costs of the workflow
+		if len(pathParts) > 1 && pathParts[1] == "costs" {
+			handleWorkflowCosts(w, r, id)
+			return
+		}
+
+		// Check if it's a request to fork the workflow
+		if len(pathParts) > 1 && pathParts[1] == "clone" {
+			handleWorkflowClone(w, r, id)
+			return
+		}
+
+		// Check if it's a request to test a single node
+		if len(pathParts) > 3 && pathParts[1] == "nodes" && pathParts[3] == "test" {
+			handleWorkflowNodeTest(w, r, id, pathParts[2])
+			return
+		}
+
+		// Check if it's a request for the run history of the workflow
+		if len(pathParts) > 1 && pathParts[1] == "runs" {
+			handleWorkflowRuns(w, r, id)
+			return
+		}
+
 		// Check if it's a request to execute the workflow
 		if len(pathParts) > 1 && pathParts[1] == "execute" {
 			log.Printf("DEBUG: Handling execute request for workflow: %s", id)
@@ -95,12 +170,12 @@ func HandleWorkflow(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "GET":
 			// Get a specific workflow
-			workflow, err := db.GetWorkflow(id)
+			workflow, err := db.GetWorkflow(tenantID, id)
 			if err != nil {
 				log.Printf("DEBUG: Error in GetWorkflow: %v (type: %T)", err, err)
 
 				// Check if the workflow exists with direct database query
-				exists, checkErr := db.WorkflowExists(id)
+				exists, checkErr := db.WorkflowExists(tenantID, id)
 				if checkErr != nil {
 					log.Printf("DEBUG: Error in WorkflowExists check: %v", checkErr)
 				} else {
@@ -108,7 +183,7 @@ func HandleWorkflow(w http.ResponseWriter, r *http.Request) {
 				}
 
 				// List all workflows in the database for debugging
-				allWorkflows, listErr := db.GetAllWorkflows()
+				allWorkflows, listErr := db.GetAllWorkflows(tenantID)
 				if listErr != nil {
 					log.Printf("DEBUG: Error listing all workflows: %v", listErr)
 				} else {
@@ -132,12 +207,8 @@ func HandleWorkflow(w http.ResponseWriter, r *http.Request) {
 			}
 
 			// Check if workflow exists
-			exists, err := db.WorkflowExists(id)
+			current, err := db.GetWorkflow(tenantID, id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if !exists {
 				http.Error(w, "Workflow not found", http.StatusNotFound)
 				return
 			}
@@ -150,28 +221,32 @@ func HandleWorkflow(w http.ResponseWriter, r *http.Request) {
 			// Ensure ID consistency
 			updatedWorkflow.ID = id
 
-			if err := db.UpdateWorkflow(id, updatedWorkflow); err != nil {
+			// Clients that send a version only overwrite the workflow if nobody else edited it since
+			baseVersion := current.Version
+			if updatedWorkflow.Version > 0 {
+				baseVersion = updatedWorkflow.Version
+			}
+
+			if err := db.SaveWorkflowIfVersion(updatedWorkflow, baseVersion); err != nil {
+				if errors.Is(err, db.ErrVersionConflict) {
+					writeVersionConflict(w, current.Version)
+					return
+				}
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			updatedWorkflow.Version = baseVersion + 1
+			recordActivity(r, db.ActivityWorkflowUpdated, id, fmt.Sprintf("Workflow \"%s\" edited", updatedWorkflow.Name), nil)
 			json.NewEncoder(w).Encode(updatedWorkflow)
 
 		case "DELETE":
 			// Delete a workflow
-			exists, err := db.WorkflowExists(id)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if !exists {
-				http.Error(w, "Workflow not found", http.StatusNotFound)
-				return
-			}
-
-			if err := db.DeleteWorkflow(id); err != nil {
+			if err := db.DeleteWorkflow(tenantID, id); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			core.Scheduler.SetWorkflowLimit(id, 0)
+			recordActivity(r, db.ActivityWorkflowDeleted, id, fmt.Sprintf("Workflow %s deleted", id), nil)
 			w.WriteHeader(http.StatusNoContent)
 
 		default:
@@ -192,7 +267,7 @@ func handleWorkflowExecutionConfig(w http.ResponseWriter, r *http.Request, workf
 	log.Printf("DEBUG: Handling execution config for workflow ID: %s", workflowId)
 
 	// Get the workflow to analyze its nodes and edges
-	workflowObj, err := db.GetWorkflow(workflowId)
+	workflowObj, err := db.GetWorkflow(auth.TenantID(r.Context()), workflowId)
 	if err != nil {
 		log.Printf("DEBUG: Error fetching workflow for execution config: %v", err)
 		http.Error(w, "Workflow not found", http.StatusNotFound)
@@ -221,39 +296,268 @@ func handleWorkflowExecute(w http.ResponseWriter, r *http.Request, workflowId st
 		return
 	}
 
-	// Parse request body
-	var req struct {
-		Parameters map[string]interface{} `json:"parameters"`
-		Data       map[string]interface{} `json:"data"`
-		Text       string                 `json:"text"`
+	var req WorkflowRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	response, err := ExecuteWorkflow(r.Context(), actorFromRequest(r), workflowId, req)
+	var invalid *requestError
+	var busy *WorkflowBusyError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.As(err, &busy):
+		sendWorkflowBusy(w, busy)
+		return
+	case errors.Is(err, ErrWorkflowNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		// Failed analyses of function nodes keep their status, such as 402 over budget
+		_, status := AnalysisFailure(err)
+		http.Error(w, fmt.Sprintf("Failed to execute workflow: %s", err), status)
+		return
+	}
+
+	if response.Status == models.WorkflowRunAwaitingReview {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// ErrWorkflowNotFound is returned for runs of workflows that do not exist
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// WorkflowRunRequest is the body of a workflow execution request
+type WorkflowRunRequest struct {
+	Parameters map[string]interface{} `json:"parameters"`
+	Data       map[string]interface{} `json:"data"`
+	Text       string                 `json:"text"`
+	Tags       map[string]string      `json:"tags,omitempty"`
+}
+
+// ExecuteWorkflow runs a stored workflow of the tenant of ctx within its concurrency
+// settings and records the run, for the HTTP API and other transports such as the gRPC
+// server. Runs that may not start fail with a *WorkflowBusyError.
+func ExecuteWorkflow(ctx context.Context, actor, workflowID string, req WorkflowRunRequest) (*models.WorkflowExecutionResponse, error) {
+	if err := validateCostTags(req.Tags); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	// Get the workflow
+	workflowObj, err := db.GetWorkflow(auth.TenantID(ctx), workflowID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow: %w", err)
+	}
+
+	// Execute the workflow within its concurrency settings
+	release, err := tryStartWorkflowRun(actor, workflowObj)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	runID := uuid.New().String()
+	startRun(workflowObj.TenantID, runID, workflowID, db.RunKindWorkflow, actor, req)
+	return runWorkflow(ctx, actor, workflowObj, req.Tags, runID, func(executor *workflow.Executor) (map[string]interface{}, error) {
+		return executor.Execute(req.Text, req.Data, req.Parameters)
+	})
+}
+
+// runWorkflow runs a workflow, started or resumed by run, and records the run and the
+// history of its nodes under runID. A run that pauses at a review node is stored as a
+// pending review and reported as awaiting review.
+func runWorkflow(ctx context.Context, actor string, workflowObj db.Workflow, tags map[string]string, runID string, run func(*workflow.Executor) (map[string]interface{}, error)) (*models.WorkflowExecutionResponse, error) {
+	workflowID := workflowObj.ID
+	executor := workflow.NewExecutor(workflowObj)
+	executor.SetFunctionRunner(workflowFunctionRunner(ctx, actor, workflowID, runID))
+	started := time.Now()
+	results, err := run(executor)
+	var pause *workflow.ReviewPause
+	paused := errors.As(err, &pause)
+	status := db.RunCompleted
+	switch {
+	case paused:
+		status = db.RunAwaitingReview
+	case err != nil:
+		status = db.RunFailed
+	}
+	var runErr error
+	if !paused {
+		runErr = err
+	}
+	finishRun(runID, status, runErr, time.Since(started), workflowRunNodes(executor.NodeRuns()), nil)
+	recordSLA(ctx, workflowID, db.UsageKindWorkflowExecution, workflow.RunMeasurement{
+		Runtime:    time.Since(started),
+		Confidence: workflow.RunConfidence(results),
+		Failed:     err != nil && !paused,
+	})
+
+	// Function nodes record the usage of their analyses, so runs are counted without tokens
+	saveUsage(db.UsageRecord{
+		TenantID:   auth.TenantID(ctx),
+		Kind:       db.UsageKindWorkflowExecution,
+		WorkflowID: workflowID,
+		Actor:      actor,
+		Tags:       tags,
+	}, nil)
+
+	response := &models.WorkflowExecutionResponse{
+		WorkflowID:   workflowID,
+		WorkflowName: workflowObj.Name,
+		Timestamp:    time.Now(),
+		Results:      results,
+		Status:       models.WorkflowRunCompleted,
+		RunID:        runID,
+	}
+	switch {
+	case paused:
+		review, err := saveReviewPause(actor, workflowObj, executor, pause, tags, runID)
+		if err != nil {
+			return nil, err
+		}
+		recordActivityAs(workflowObj.TenantID, actor, db.ActivityWorkflowRunPaused, workflowID, fmt.Sprintf("Workflow \"%s\" run paused for review", workflowObj.Name),
+			map[string]interface{}{"review_id": review.ID, "node_id": review.NodeID})
+		response.Status = models.WorkflowRunAwaitingReview
+		response.ReviewID = review.ID
+	case err != nil:
+		recordActivityAs(workflowObj.TenantID, actor, db.ActivityWorkflowRunFailed, workflowID, fmt.Sprintf("Workflow \"%s\" run failed", workflowObj.Name),
+			map[string]interface{}{"error": err.Error()})
+		return nil, err
+	default:
+		recordActivityAs(workflowObj.TenantID, actor, db.ActivityWorkflowRunCompleted, workflowID, fmt.Sprintf("Workflow \"%s\" run completed", workflowObj.Name), nil)
+	}
+	return response, nil
+}
+
+// workflowCloneResponse is a cloned workflow and the components left out of it
+type workflowCloneResponse struct {
+	SourceID string         `json:"source_id"`
+	Workflow db.Workflow    `json:"workflow"`
+	Excluded map[string]int `json:"excluded"`
+}
+
+// handleWorkflowClone handles /api/workflows/{id}/clone endpoint
+func handleWorkflowClone(w http.ResponseWriter, r *http.Request, workflowId string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.WorkflowCloneRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID := auth.TenantID(r.Context())
+	source, err := db.GetWorkflow(tenantID, workflowId)
+	if err != nil {
+		http.Error(w, "Workflow not found", http.StatusNotFound)
+		return
+	}
+
+	if req.ID != "" {
+		exists, err := db.WorkflowExists(tenantID, req.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			http.Error(w, fmt.Sprintf("Workflow %s already exists", req.ID), http.StatusConflict)
+			return
+		}
+	}
+
+	clone, excluded, err := workflow.Clone(source, workflow.CloneOptions{
+		ID:                    req.ID,
+		Name:                  req.Name,
+		CopySchedules:         req.CopySchedules != nil && *req.CopySchedules,
+		CopyPromptOverrides:   req.CopyPromptOverrides == nil || *req.CopyPromptOverrides,
+		CopyAttributeBindings: req.CopyAttributeBindings == nil || *req.CopyAttributeBindings,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to clone workflow: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := db.CreateWorkflow(tenantID, clone); err != nil {
+		if errors.Is(err, db.ErrWorkflowIDTaken) {
+			http.Error(w, fmt.Sprintf("Workflow ID %s is not available", clone.ID), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordActivity(r, db.ActivityWorkflowCreated, clone.ID,
+		fmt.Sprintf("Workflow \"%s\" cloned from \"%s\"", clone.Name, source.Name),
+		map[string]interface{}{"cloned_from": source.ID, "excluded": excluded})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(workflowCloneResponse{
+		SourceID: source.ID,
+		Workflow: clone,
+		Excluded: excluded,
+	})
+}
+
+// handleWorkflowNodeTest handles /api/workflows/{id}/nodes/{nodeId}/test endpoint
+func handleWorkflowNodeTest(w http.ResponseWriter, r *http.Request, workflowId string, nodeId string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	var req models.NodeTestRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
 		return
 	}
 
 	// Get the workflow
-	workflowObj, err := db.GetWorkflow(workflowId)
+	workflowObj, err := db.GetWorkflow(auth.TenantID(r.Context()), workflowId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get workflow: %s", err), http.StatusNotFound)
 		return
 	}
 
-	// Execute the workflow
+	// Execute only the requested node
+	start := time.Now()
 	executor := workflow.NewExecutor(workflowObj)
-	results, err := executor.Execute(req.Text, req.Data, req.Parameters)
+	executor.SetFunctionRunner(workflowFunctionRunner(r.Context(), actorFromRequest(r), "", ""))
+	output, err := executor.ExecuteNode(nodeId, req.Text, req.Input, req.Parameters)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to execute workflow: %s", err), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, workflow.ErrNodeNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, workflow.ErrNodeNotExecutable):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			_, status := AnalysisFailure(err)
+			http.Error(w, fmt.Sprintf("Failed to execute node: %s", err), status)
+		}
 		return
 	}
 
-	// Return the results
-	response := models.WorkflowExecutionResponse{
-		WorkflowID:   workflowId,
-		WorkflowName: workflowObj.Name,
-		Timestamp:    time.Now(),
-		Results:      results,
+	response := models.NodeTestResponse{
+		WorkflowID: workflowId,
+		NodeID:     nodeId,
+		Timestamp:  start,
+		DurationMs: time.Since(start).Milliseconds(),
+		Output:     output,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -273,8 +577,9 @@ func HandleGenerateWorkflow(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string            `json:"name"`
+		Description string            `json:"description"`
+		Tags        map[string]string `json:"tags,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -287,20 +592,38 @@ func HandleGenerateWorkflow(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Name and description are required", http.StatusBadRequest)
 		return
 	}
+	if err := validateCostTags(req.Tags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Generate workflow
 	generator := workflow.NewGenerator()
-	newWorkflow, err := generator.GenerateFromDescription(req.Name, req.Description)
+	ctx, usage := core.WithUsage(r.Context())
+	newWorkflow, err := generator.GenerateFromDescription(ctx, req.Name, req.Description)
+	saveUsage(db.UsageRecord{
+		TenantID: auth.TenantID(ctx),
+		Kind:     db.UsageKindWorkflowGeneration,
+		Actor:    actorFromRequest(r),
+		Tags:     req.Tags,
+	}, usage)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to generate workflow: %s", err), http.StatusInternalServerError)
 		return
 	}
 
+	// The public demo returns generated workflows without storing them
+	if demo.Enabled() {
+		json.NewEncoder(w).Encode(newWorkflow)
+		return
+	}
+
 	// Save the generated workflow to the database
-	if err := db.CreateWorkflow(newWorkflow); err != nil {
+	if err := db.CreateWorkflow(auth.TenantID(r.Context()), newWorkflow); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to save workflow: %s", err), http.StatusInternalServerError)
 		return
 	}
+	recordActivity(r, db.ActivityWorkflowCreated, newWorkflow.ID, fmt.Sprintf("Workflow \"%s\" generated from description", newWorkflow.Name), nil)
 
 	// Return the generated workflow
 	json.NewEncoder(w).Encode(newWorkflow)
@@ -317,8 +640,9 @@ func HandleGenerateDynamicWorkflow(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string            `json:"name"`
+		Description string            `json:"description"`
+		Tags        map[string]string `json:"tags,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -331,20 +655,38 @@ func HandleGenerateDynamicWorkflow(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Name and description are required", http.StatusBadRequest)
 		return
 	}
+	if err := validateCostTags(req.Tags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Generate dynamic workflow
 	generator := workflow.NewGenerator()
-	newWorkflow, err := generator.GenerateDynamic(req.Name, req.Description)
+	ctx, usage := core.WithUsage(r.Context())
+	newWorkflow, err := generator.GenerateDynamic(ctx, req.Name, req.Description)
+	saveUsage(db.UsageRecord{
+		TenantID: auth.TenantID(ctx),
+		Kind:     db.UsageKindWorkflowGeneration,
+		Actor:    actorFromRequest(r),
+		Tags:     req.Tags,
+	}, usage)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to generate dynamic workflow: %s", err), http.StatusInternalServerError)
 		return
 	}
 
+	// The public demo returns generated workflows without storing them
+	if demo.Enabled() {
+		json.NewEncoder(w).Encode(newWorkflow)
+		return
+	}
+
 	// Save the generated workflow to the database
-	if err := db.CreateWorkflow(newWorkflow); err != nil {
+	if err := db.CreateWorkflow(auth.TenantID(r.Context()), newWorkflow); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to save workflow: %s", err), http.StatusInternalServerError)
 		return
 	}
+	recordActivity(r, db.ActivityWorkflowCreated, newWorkflow.ID, fmt.Sprintf("Workflow \"%s\" generated from description", newWorkflow.Name), nil)
 
 	// Return the generated workflow
 	json.NewEncoder(w).Encode(newWorkflow)
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+)
+
+// HandleWorkspaceDefaults handles /api/workspace/defaults: GET returns the parameters
+// applied to the tenant's analysis requests that omit them, PUT replaces them and DELETE
+// clears them
+func HandleWorkspaceDefaults(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tenantID := auth.TenantID(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req db.WorkspaceDefaults
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateWorkspaceDefaults(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.SaveWorkspaceDefaults(tenantID, req, actorFromRequest(r)); err != nil {
+			log.Printf("Error saving workspace defaults: %v", err)
+			http.Error(w, "Failed to save workspace defaults", http.StatusInternalServerError)
+			return
+		}
+		recordActivity(r, db.ActivityWorkspaceDefaultsSet, "", "Workspace defaults updated", req)
+	case http.MethodDelete:
+		if err := db.DeleteWorkspaceDefaults(tenantID); err != nil {
+			log.Printf("Error clearing workspace defaults: %v", err)
+			http.Error(w, "Failed to clear workspace defaults", http.StatusInternalServerError)
+			return
+		}
+		recordActivity(r, db.ActivityWorkspaceDefaultsSet, "", "Workspace defaults cleared", nil)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	defaults, err := db.GetWorkspaceDefaults(tenantID)
+	if err != nil {
+		log.Printf("Error getting workspace defaults: %v", err)
+		http.Error(w, "Failed to get workspace defaults", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(defaults); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// validateWorkspaceDefaults checks and normalizes workspace defaults
+func validateWorkspaceDefaults(defaults *db.WorkspaceDefaults) error {
+	if defaults.BatchSize < 0 {
+		return fmt.Errorf("batch_size must not be negative")
+	}
+	if c := defaults.MinConfidence; c != nil && (*c < 0 || *c > 1) {
+		return fmt.Errorf("min_confidence must be between 0 and 1")
+	}
+	defaults.Language = strings.TrimSpace(defaults.Language)
+	defaults.Detail = strings.ToLower(strings.TrimSpace(defaults.Detail))
+	if err := core.ValidateOutputStyle(core.OutputStyle{Language: defaults.Language, Detail: defaults.Detail}); err != nil {
+		return err
+	}
+
+	areas := defaults.FocusAreas[:0]
+	for _, area := range defaults.FocusAreas {
+		if area = strings.TrimSpace(area); area != "" {
+			areas = append(areas, area)
+		}
+	}
+	defaults.FocusAreas = areas
+	return nil
+}
+
+// workspaceDefaults returns the workspace defaults of a tenant. Requests run without
+// defaults when they cannot be read.
+func workspaceDefaults(tenantID string) db.WorkspaceDefaults {
+	defaults, err := db.GetWorkspaceDefaults(tenantID)
+	if err != nil {
+		log.Printf("Error getting workspace defaults, running without them: %v", err)
+	}
+	return defaults
+}
+
+// withWorkspaceDefaults returns analysis parameters with the workspace defaults of the
+// parameters they omit. The parameters are copied, never modified.
+func withWorkspaceDefaults(parameters map[string]interface{}, defaults db.WorkspaceDefaults) map[string]interface{} {
+	merged := make(map[string]interface{}, len(parameters)+4)
+	for key, value := range parameters {
+		merged[key] = value
+	}
+	setDefault := func(key string, value interface{}) {
+		if _, ok := merged[key]; !ok {
+			merged[key] = value
+		}
+	}
+	if defaults.MinConfidence != nil {
+		setDefault("min_confidence", *defaults.MinConfidence)
+	}
+	if defaults.Language != "" {
+		setDefault("language", defaults.Language)
+	}
+	if defaults.Detail != "" {
+		setDefault("detail", defaults.Detail)
+	}
+	if len(defaults.FocusAreas) > 0 {
+		areas := make([]interface{}, len(defaults.FocusAreas))
+		for i, area := range defaults.FocusAreas {
+			areas[i] = area
+		}
+		setDefault("focus_areas", areas)
+	}
+	return merged
+}
+
+// outputStyle reads the language and detail parameters of an analysis
+func outputStyle(parameters map[string]interface{}) (core.OutputStyle, error) {
+	var style core.OutputStyle
+	if value, ok := parameters["language"]; ok {
+		language, ok := value.(string)
+		if !ok {
+			return style, fmt.Errorf("language must be a string")
+		}
+		style.Language = strings.TrimSpace(language)
+	}
+	if value, ok := parameters["detail"]; ok {
+		detail, ok := value.(string)
+		if !ok {
+			return style, fmt.Errorf("detail must be a string")
+		}
+		style.Detail = strings.ToLower(strings.TrimSpace(detail))
+	}
+	return style, core.ValidateOutputStyle(style)
+}
+
+// minConfidence reads the min_confidence parameter of an analysis, which is 0 when unset
+func minConfidence(parameters map[string]interface{}) (float64, error) {
+	value, ok := parameters["min_confidence"]
+	if !ok {
+		return 0, nil
+	}
+	confidence, ok := value.(float64)
+	if !ok || confidence < 0 || confidence > 1 {
+		return 0, fmt.Errorf("min_confidence must be a number between 0 and 1")
+	}
+	return confidence, nil
+}
+
+// validateDefaultableParameters checks the analysis parameters workspace defaults can set
+func validateDefaultableParameters(parameters map[string]interface{}) error {
+	if _, err := outputStyle(parameters); err != nil {
+		return err
+	}
+	_, err := minConfidence(parameters)
+	return err
+}
@@ -2,15 +2,32 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"agenticflows/backend/api/handlers"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/cluster"
 	"agenticflows/backend/db"
+	"agenticflows/backend/demo"
+	"agenticflows/backend/doctor"
+	"agenticflows/backend/notify"
+	"agenticflows/backend/workflow"
 )
 
+// serveGRPC serves the gRPC interface of the analysis API. It is set when the server is
+// built with -tags grpc.
+var serveGRPC func(analysisHandler *handlers.AnalysisHandler)
+
 // Main entry point for the API server
 func main() {
+	// "api doctor" checks the deployment without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor())
+	}
+
 	// Initialize database
 	if err := db.Initialize(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -21,14 +38,63 @@ func main() {
 	analysisHandler, err := handlers.NewAnalysisHandler()
 	if err != nil {
 		log.Printf("Warning: Failed to initialize analysis handler: %v", err)
-		log.Println("Analysis endpoints will not be available")
+		log.Println("Analysis endpoints will not be available; run the doctor command for the steps to fix this")
+	}
+
+	// Report deployment problems with the steps to fix them
+	for _, check := range doctor.Run(context.Background()).Problems() {
+		log.Printf("Doctor: %s %s: %s", check.Status, check.Name, check.Message)
+		if check.Remedy != "" {
+			log.Printf("Doctor: -> %s", check.Remedy)
+		}
 	}
 
 	// Set up API routes
 	setupRoutes(analysisHandler)
 
+	// Move old conversation text out of the hot database
+	handlers.StartConversationTiering()
+
+	// Resume the conversation uploads a restart interrupted and delete abandoned ones
+	handlers.StartConversationUploads()
+
+	// Delete scratch sessions, with their runs and results, once they expire
+	handlers.StartScratchExpiry()
+
+	// Alert when scheduled workflows go stale or start failing, and when runs breach their
+	// SLA. Schedules are monitored by one instance of the deployment.
+	notifier := notify.FromEnv()
+	handlers.SetSLANotifier(notifier)
+	monitor := workflow.NewScheduleMonitor(notifier)
+	go cluster.RunAsLeader(context.Background(), "schedule-monitor", func(ctx context.Context) {
+		monitor.Run(ctx, workflow.ScheduleCheckInterval())
+	})
+
+	// Typed, streaming access to analyses for internal services
+	if serveGRPC != nil {
+		go serveGRPC(analysisHandler)
+	}
+
+	var handler http.Handler = http.DefaultServeMux
+
+	// API keys with role scopes
+	if auth.Enabled() {
+		log.Println("Authentication enabled: requests require an API key")
+		handler = authMiddleware(handler)
+	}
+
+	// Public demo: mock model, synthetic data, read-mostly endpoints and rate limits
+	if demo.Enabled() {
+		config := demo.ConfigFromEnv()
+		log.Printf("Demo mode enabled: %d requests and %d writes per minute per client", config.RateLimit, config.WriteRateLimit)
+		handler = demoMiddleware(config, handler)
+	}
+
+	// Request IDs, under which LLM calls are audited
+	handler = requestIDMiddleware(handler)
+
 	// CORS middleware for development
-	handler := corsMiddleware(http.DefaultServeMux)
+	handler = corsMiddleware(handler)
 
 	// Start server
 	log.Println("Starting server on :8080")
@@ -40,7 +106,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Actor, X-API-Key, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -51,13 +118,68 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// withAnalysisHandler passes the analysis handler to handlers that run analyses, such as
+// the function nodes of workflows
+func withAnalysisHandler(analysisHandler *handlers.AnalysisHandler, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), "analysisHandler", analysisHandler)
+		next(w, r.WithContext(ctx))
+	}
+}
+
 // setupRoutes configures all API routes
 func setupRoutes(analysisHandler *handlers.AnalysisHandler) {
 	// Basic API routes
 	http.HandleFunc("/api/agents", handlers.HandleAgents)
 	http.HandleFunc("/api/tools", handlers.HandleTools)
+	http.HandleFunc("/api/tools/", handlers.HandleToolManifests)
 	http.HandleFunc("/api/workflows", handlers.HandleWorkflows)
-	http.HandleFunc("/api/workflows/", handlers.HandleWorkflow)
+	http.HandleFunc("/api/workflows/", withAnalysisHandler(analysisHandler, handlers.HandleWorkflow))
+
+	// Scratch sessions for ad-hoc workflow runs that are never saved
+	http.HandleFunc("/api/scratch", handlers.HandleScratchSessions)
+	http.HandleFunc("/api/scratch/", withAnalysisHandler(analysisHandler, handlers.HandleScratchSession))
+	http.HandleFunc("/api/activity", handlers.HandleActivity)
+	http.HandleFunc("/api/reports/", handlers.HandleReport)
+	http.HandleFunc("/api/lineage", handlers.HandleLineage)
+	http.HandleFunc("/api/runs/", handlers.HandleRun)
+	http.HandleFunc("/api/demo", handlers.HandleDemoStatus)
+	http.HandleFunc("/api/openapi.json", handlers.HandleOpenAPI)
+	http.HandleFunc("/api/pii/redact", handlers.HandlePIIRedact)
+	http.HandleFunc("/api/pseudonyms/resolve", handlers.HandlePseudonymResolve)
+	// Ingestion re-extracts the attributes of updated conversations with the analysis handler
+	http.HandleFunc("/api/conversations", func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), "analysisHandler", analysisHandler)
+		handlers.HandleConversations(w, r.WithContext(ctx))
+	})
+	http.HandleFunc("/api/conversations/", handlers.HandleConversation)
+	http.HandleFunc("/api/conversations/upload", handlers.HandleConversationUploads)
+	http.HandleFunc("/api/conversations/upload/", handlers.HandleConversationUploads)
+	http.HandleFunc("/api/intents/distribution", handlers.HandleIntentDistribution)
+	http.HandleFunc("/api/scheduler", handlers.HandleScheduler)
+	http.HandleFunc("/api/attribute-sets", handlers.HandleAttributeSets)
+	http.HandleFunc("/api/attribute-sets/", handlers.HandleAttributeSet)
+	http.HandleFunc("/api/attribute-flags", handlers.HandleAttributeFlags)
+	http.HandleFunc("/api/attribute-flags/", handlers.HandleAttributeFlags)
+	http.HandleFunc("/api/usage", handlers.HandleUsage)
+	http.HandleFunc("/api/workspace/defaults", handlers.HandleWorkspaceDefaults)
+	http.HandleFunc("/api/slas", handlers.HandleSLAs)
+	http.HandleFunc("/api/prompt-templates", handlers.HandlePromptTemplates)
+	http.HandleFunc("/api/prompt-templates/", handlers.HandlePromptTemplate)
+	http.HandleFunc("/api/widgets", handlers.HandleWidgets)
+	http.HandleFunc("/api/widgets/", handlers.HandleWidget)
+	http.HandleFunc("/api/schedules/health", handlers.HandleScheduleHealth)
+	http.HandleFunc("/api/storage/tiering", handlers.HandleStorageTiering)
+	http.HandleFunc("/api/customers/", handlers.HandleCustomerData)
+	http.HandleFunc("/api/webhooks", handlers.HandleWebhooks)
+	http.HandleFunc("/api/webhooks/", handlers.HandleWebhook)
+	http.HandleFunc("/api/canaries", handlers.HandleCanaries)
+	http.HandleFunc("/api/canaries/", handlers.HandleCanary)
+	http.HandleFunc("/api/reviews", handlers.HandleReviews)
+	http.HandleFunc("/api/reviews/", withAnalysisHandler(analysisHandler, handlers.HandleReview))
+	http.HandleFunc("/api/auth", handlers.HandleAuthStatus)
+	http.HandleFunc("/api/auth/keys", handlers.HandleAPIKeys)
+	http.HandleFunc("/api/auth/keys/", handlers.HandleAPIKey)
 
 	// Workflow generation endpoints
 	http.HandleFunc("/api/workflows/generate", handlers.HandleGenerateWorkflow)
@@ -78,11 +200,63 @@ func setupRoutes(analysisHandler *handlers.AnalysisHandler) {
 
 		// Chain analysis endpoint for workflows
 		http.HandleFunc("/api/analysis/chain", analysisHandler.HandleChainAnalysis)
+		http.HandleFunc("/api/analysis/chain/", analysisHandler.HandleChainRun)
+
+		// Stored chain pipelines, executed by ID
+		http.HandleFunc("/api/pipelines", analysisHandler.HandlePipelines)
+		http.HandleFunc("/api/pipelines/", analysisHandler.HandlePipeline)
+
+		// Server-side batching for large datasets
+		http.HandleFunc("/api/analysis/batch", analysisHandler.HandleBatchAnalysis)
+
+		// On-demand explanation of a single result item
+		http.HandleFunc("/api/analysis/explain", analysisHandler.HandleExplain)
+
+		// Asynchronous analysis jobs
+		http.HandleFunc("/api/analysis/jobs", analysisHandler.HandleAnalysisJobs)
+		http.HandleFunc("/api/analysis/jobs/", analysisHandler.HandleAnalysisJobs)
 
 		// Function metadata endpoint
 		http.HandleFunc("/api/analysis/metadata", analysisHandler.HandleGetFunctionMetadata)
 
+		// Cache of identical analysis requests
+		http.HandleFunc("/api/analysis/cache", analysisHandler.HandleAnalysisCache)
+
+		// Structured output quality metrics
+		http.HandleFunc("/api/analysis/quality", analysisHandler.HandleQualityMetrics)
+
 		// Enable debugging for analysis requests
 		http.HandleFunc("/api/analysis/results", analysisHandler.HandleAnalysisResults)
+
+		// Semantic search over stored conversations and intents
+		http.HandleFunc("/api/search/similar", analysisHandler.HandleSimilarSearch)
+		http.HandleFunc("/api/search/index", analysisHandler.HandleSearchIndex)
+		http.HandleFunc("/api/search/topic", analysisHandler.HandleTopicSearch)
+
+		// Tabular exports of stored results
+		http.HandleFunc("/api/analysis/results/export", analysisHandler.HandleResultsExport)
+		http.HandleFunc("/api/analysis/results/graph", analysisHandler.HandleResultsGraph)
+
+		// Differences between two stored runs of a workflow
+		http.HandleFunc("/api/analysis/results/diff", analysisHandler.HandleResultsDiff)
+		http.HandleFunc("/api/analysis/plan/export", handlers.HandlePlanExport)
+
+		// Audit log of LLM calls, with replay against other models and prompts
+		http.HandleFunc("/api/audit", analysisHandler.HandleAudit)
+		http.HandleFunc("/api/audit/", analysisHandler.HandleAudit)
 	}
-} 
\ No newline at end of file
+} 
+
+// runDoctor prints the deployment checks and returns the exit code: 1 when a check failed
+func runDoctor() int {
+	report := doctor.Run(context.Background())
+	defer db.Close()
+
+	report.Print(os.Stdout)
+	if !report.OK() {
+		fmt.Println("\nSome checks failed; follow the steps above and run doctor again.")
+		return 1
+	}
+	fmt.Println("\nAll checks passed.")
+	return 0
+}
@@ -22,12 +22,52 @@ type WorkflowExecutionConfig struct {
 	Parameters  json.RawMessage `json:"parameters"`
 }
 
+// Workflow run statuses
+const (
+	WorkflowRunCompleted      = "completed"
+	WorkflowRunAwaitingReview = "awaiting_review"
+	WorkflowRunRejected       = "rejected"
+)
+
 // WorkflowExecutionResponse represents the response from a workflow execution
 type WorkflowExecutionResponse struct {
 	WorkflowID   string                 `json:"workflow_id"`
 	WorkflowName string                 `json:"workflow_name"`
 	Timestamp    time.Time              `json:"timestamp"`
 	Results      map[string]interface{} `json:"results"`
+
+	// Status is awaiting_review when the run paused at a review node, with the ID of the
+	// review that resumes it; Results then hold the outputs of the nodes that ran
+	Status   string `json:"status,omitempty"`
+	ReviewID string `json:"review_id,omitempty"`
+	// RunID identifies the run in the run history of the workflow
+	RunID string `json:"run_id,omitempty"`
+}
+
+// NodeTestRequest represents a request to execute a single workflow node with sample input
+type NodeTestRequest struct {
+	Text       string                 `json:"text,omitempty"`
+	Input      map[string]interface{} `json:"input,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// NodeTestResponse represents the output of a single node test run
+type NodeTestResponse struct {
+	WorkflowID string                 `json:"workflow_id"`
+	NodeID     string                 `json:"node_id"`
+	Timestamp  time.Time              `json:"timestamp"`
+	DurationMs int64                  `json:"duration_ms"`
+	Output     map[string]interface{} `json:"output"`
+}
+
+// WorkflowCloneRequest selects what a cloned workflow copies from its source.
+// Schedules are left out unless requested; prompt overrides and attribute bindings are copied unless disabled.
+type WorkflowCloneRequest struct {
+	ID                    string `json:"id,omitempty"`
+	Name                  string `json:"name,omitempty"`
+	CopySchedules         *bool  `json:"copy_schedules,omitempty"`
+	CopyPromptOverrides   *bool  `json:"copy_prompt_overrides,omitempty"`
+	CopyAttributeBindings *bool  `json:"copy_attribute_bindings,omitempty"`
 }
 
 // QuestionRequest represents a request to answer questions
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+
+	"agenticflows/backend/analysis/core"
+
+	"github.com/google/uuid"
+)
+
+// requestIDHeader carries the ID a request is logged and audited under
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID matches the request IDs clients may choose themselves
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestIDMiddleware gives every request an ID, the X-Request-ID header of the request if
+// it is valid and a new UUID otherwise. The ID is returned in the response header, and
+// LLM calls made for the request are audited under it.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(core.WithRequestID(r.Context(), requestID)))
+	})
+}
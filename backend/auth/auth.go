@@ -0,0 +1,164 @@
+// Package auth holds the API key roles, the scopes of the API routes and the key
+// format used when the server requires authentication
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Auth environment variables
+const (
+	EnvAuthEnabled = "AUTH_ENABLED"
+	EnvAdminAPIKey = "ADMIN_API_KEY" // Bootstrap admin key, accepted in addition to stored keys
+)
+
+// Roles of API keys, from least to most privileged. Each role holds the scopes of the
+// ones before it.
+const (
+	// RoleReader reads workflows, conversations and stored results
+	RoleReader = "reader"
+	// RoleAnalyst also executes analyses, workflows and pipelines and ingests conversations
+	RoleAnalyst = "analyst"
+	// RoleAdmin also changes workflows, components and settings and manages API keys
+	RoleAdmin = "admin"
+)
+
+// roleRanks orders the roles
+var roleRanks = map[string]int{
+	RoleReader:  1,
+	RoleAnalyst: 2,
+	RoleAdmin:   3,
+}
+
+// KeyPrefix starts every issued API key, so leaked keys are easy to recognize
+const KeyPrefix = "afk_"
+
+// DisplayPrefixLength is how many characters of a key are kept to identify it in listings
+const DisplayPrefixLength = len(KeyPrefix) + 8
+
+// Enabled reports whether requests must carry an API key
+func Enabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnvAuthEnabled))
+	return enabled
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// Allows reports whether a key of role granted may use an endpoint requiring role required
+func Allows(granted, required string) bool {
+	return roleRanks[granted] > 0 && roleRanks[granted] >= roleRanks[required]
+}
+
+// WidgetTokenPrefix starts every widget token. Widget tokens only read the data of their
+// widget and are not accepted as API keys.
+const WidgetTokenPrefix = "afw_"
+
+// GenerateKey returns a new random API key
+func GenerateKey() (string, error) {
+	return generateSecret(KeyPrefix)
+}
+
+// GenerateWidgetToken returns a new random widget token
+func GenerateWidgetToken() (string, error) {
+	return generateSecret(WidgetTokenPrefix)
+}
+
+// generateSecret returns a new random secret starting with prefix
+func generateSecret(prefix string) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// HashKey returns the hash under which a key is stored; keys themselves are never stored
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// DefaultTenant owns the data stored without authentication, before tenants existed and
+// by the bootstrap admin key
+const DefaultTenant = "default"
+
+// BootstrapKeyID is the key ID of the bootstrap admin key, which manages the keys of every
+// tenant
+const BootstrapKeyID = "bootstrap"
+
+// tenantIDPattern is the form of tenant IDs
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ValidTenantID reports whether id is a valid tenant ID: lowercase letters, digits,
+// dashes and underscores, starting with a letter or digit
+func ValidTenantID(id string) bool {
+	return tenantIDPattern.MatchString(id)
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	KeyID string `json:"key_id"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	// TenantID is the tenant whose workflows, conversations and results the key reaches
+	TenantID string `json:"tenant_id"`
+}
+
+// principalKey is the context key of the authenticated principal
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the authenticated principal of a request, or nil without auth
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// tenantKey is the context key of the tenant of background work
+type tenantKey struct{}
+
+// WithTenant returns a context acting for a tenant, for work that runs outside of the
+// request that started it, such as queued jobs and scheduled workflows
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantID returns the tenant a context acts for: that of its principal, else the one set
+// with WithTenant, else DefaultTenant
+func TenantID(ctx context.Context) string {
+	if principal := FromContext(ctx); principal != nil && principal.TenantID != "" {
+		return principal.TenantID
+	}
+	if tenantID, _ := ctx.Value(tenantKey{}).(string); tenantID != "" {
+		return tenantID
+	}
+	return DefaultTenant
+}
+
+// KeyFromHeaders returns the API key of a request from X-API-Key, else from
+// "Authorization: Bearer <key>". Endpoints with their own bearer secret, such as pseudonym
+// resolution, take the key in X-API-Key.
+func KeyFromHeaders(apiKey, authorization string) string {
+	if key := strings.TrimSpace(apiKey); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(strings.TrimSpace(authorization), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// adminRoutes need an admin key for every method: they manage keys, reveal identities,
+// delete customer data or expose audited prompts
+var adminRoutes = []*regexp.Regexp{
+	regexp.MustCompile(`^/api/auth/keys(/.*)?$`),
+	regexp.MustCompile(`^/api/customers/`),
+	regexp.MustCompile(`^/api/pseudonyms/resolve$`),
+	regexp.MustCompile(`^/api/audit(/.*)?$`),
+}
+
+// analystRoutes are the non-GET endpoints open to analysts. They run analyses or record
+// their inputs, outputs and reviews; all other writes change workflows, components or
+// settings and need an admin key.
+var analystRoutes = []*regexp.Regexp{
+	regexp.MustCompile(`^/api/analysis$`),
+	regexp.MustCompile(`^/api/analysis/(chain|batch|explain|plan/export)$`),
+	regexp.MustCompile(`^/api/analysis/jobs(/.*)?$`),
+	regexp.MustCompile(`^/api/questions/answer$`),
+	regexp.MustCompile(`^/api/workflows/generate(-dynamic)?$`),
+	regexp.MustCompile(`^/api/workflows/[^/]+/execute$`),
+	regexp.MustCompile(`^/api/workflows/[^/]+/nodes/[^/]+/test$`),
+	regexp.MustCompile(`^/api/pipelines/[^/]+/execute$`),
+	regexp.MustCompile(`^/api/scratch(/[^/]+(/execute)?)?$`),
+	regexp.MustCompile(`^/api/conversations$`),
+	regexp.MustCompile(`^/api/conversations/upload(/.*)?$`),
+	regexp.MustCompile(`^/api/search/(similar|index|topic)$`),
+	regexp.MustCompile(`^/api/pii/redact$`),
+	regexp.MustCompile(`^/api/activity$`),
+	regexp.MustCompile(`^/api/lineage$`),
+	regexp.MustCompile(`^/api/attribute-flags/[^/]+/resolve$`),
+	regexp.MustCompile(`^/api/reviews/[^/]+/(approve|edit|reject)$`),
+}
+
+// RequiredRole returns the least privileged role allowed to make a request: reads need a
+// reader key, analysis writes an analyst key and everything else an admin key
+func RequiredRole(method, path string) string {
+	for _, route := range adminRoutes {
+		if route.MatchString(path) {
+			return RoleAdmin
+		}
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return RoleReader
+	}
+	for _, route := range analystRoutes {
+		if route.MatchString(path) {
+			return RoleAnalyst
+		}
+	}
+	return RoleAdmin
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// StandardAnalysisRequest is the request of /api/analysis
+type StandardAnalysisRequest = models.StandardAnalysisRequest
+
+// StandardAnalysisResponse is the response of /api/analysis. DecodeResults decodes its
+// results into one of the typed results of the analysis package, such as
+// *analysis.TrendsResult.
+type StandardAnalysisResponse = models.StandardAnalysisResponse
+
+// PerformAnalysis runs an analysis. The client's workflow ID is used when the request
+// names none. Streaming is not supported; Stream must be false.
+func (c *Client) PerformAnalysis(ctx context.Context, req StandardAnalysisRequest) (*StandardAnalysisResponse, error) {
+	if req.Stream {
+		return nil, fmt.Errorf("streaming analyses are not supported by the client")
+	}
+	if req.WorkflowID == "" {
+		req.WorkflowID = c.workflowID
+	}
+	if req.Parameters == nil {
+		req.Parameters = map[string]interface{}{}
+	}
+
+	var resp StandardAnalysisResponse
+	if err := c.do(ctx, http.MethodPost, "/api/analysis", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, &APIError{StatusCode: http.StatusOK, Code: resp.Error.Code, Message: resp.Error.Message, Retryable: resp.Error.Retryable}
+	}
+	return &resp, nil
+}
+
+// AnalyzeSentiment analyzes the overall, per-speaker and start/middle/end sentiment of
+// a text and returns the results
+func (c *Client) AnalyzeSentiment(ctx context.Context, text string) (map[string]interface{}, error) {
+	resp, err := c.PerformAnalysis(ctx, StandardAnalysisRequest{AnalysisType: "sentiment", Text: text})
+	if err != nil {
+		return nil, err
+	}
+	var results map[string]interface{}
+	if err := resp.DecodeResults(&results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ChainRequest runs several analyses in sequence, each receiving the results of the
+// steps before it
+type ChainRequest struct {
+	WorkflowID string `json:"workflow_id"`
+	// RunID identifies the run for ChainProgress; the server generates one if it is empty
+	RunID      string                 `json:"run_id,omitempty"`
+	Steps      []string               `json:"steps"`
+	Text       string                 `json:"text"`
+	Parameters map[string]interface{} `json:"parameters"`
+
+	// ConfidencePropagation selects how confidence carries between steps ("min" or "product")
+	ConfidencePropagation string `json:"confidence_propagation,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// MaxBudget aborts the chain once its LLM calls have cost this much, in USD
+	MaxBudget float64 `json:"max_budget,omitempty"`
+}
+
+// ChainResponse is the results of a chain by step, with the progress of each step and
+// suggested next analyses
+type ChainResponse struct {
+	WorkflowID  string                   `json:"workflow_id"`
+	RunID       string                   `json:"run_id"`
+	Timestamp   time.Time                `json:"timestamp"`
+	Results     map[string]interface{}   `json:"results"`
+	StepTrace   []ChainStep              `json:"step_trace"`
+	Suggestions []map[string]interface{} `json:"suggestions,omitempty"`
+	// DryRun replaces the results of chains run with the dry_run parameter
+	DryRun *models.DryRunReport `json:"dry_run,omitempty"`
+}
+
+// ChainRun is the progress of a chain run, during and after it
+type ChainRun struct {
+	RunID      string      `json:"run_id"`
+	WorkflowID string      `json:"workflow_id"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Steps      []ChainStep `json:"steps"`
+}
+
+// ChainStep is the progress of one step of a chain run: pending, running, succeeded,
+// failed, or skipped when an earlier step failed
+type ChainStep struct {
+	Step             string     `json:"step"`
+	StepNum          int        `json:"step_num"`
+	Status           string     `json:"status"`
+	StartedAt        *time.Time `json:"started_at,omitempty"`
+	DurationMS       int64      `json:"duration_ms"`
+	Calls            int        `json:"calls"`
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+	Cost             float64    `json:"cost"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// Chain runs a chain analysis. The client's workflow ID is used when the request names
+// none.
+func (c *Client) Chain(ctx context.Context, req ChainRequest) (*ChainResponse, error) {
+	if req.WorkflowID == "" {
+		req.WorkflowID = c.workflowID
+	}
+	var resp ChainResponse
+	if err := c.do(ctx, http.MethodPost, "/api/analysis/chain", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ChainProgress returns the progress of a chain run, which can be polled while Chain
+// runs when the request chose its RunID
+func (c *Client) ChainProgress(ctx context.Context, runID string) (*ChainRun, error) {
+	var run ChainRun
+	if err := c.do(ctx, http.MethodGet, "/api/analysis/chain/"+url.PathEscape(runID)+"/progress", nil, nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Results returns the analysis results stored under a workflow
+func (c *Client) Results(ctx context.Context, workflowID string) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	if err := c.do(ctx, http.MethodGet, "/api/analysis/results", url.Values{"workflow_id": {workflowID}}, nil, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// FunctionMetadata describes the parameters of each analysis type the server offers
+func (c *Client) FunctionMetadata(ctx context.Context) (map[string]interface{}, error) {
+	var metadata map[string]interface{}
+	if err := c.do(ctx, http.MethodGet, "/api/analysis/metadata", nil, nil, &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
@@ -0,0 +1,169 @@
+// Package client is a typed Go client for the agenticflows API. Analysis requests and
+// responses are the server's own models, so the client cannot drift from the server; the
+// types of the other endpoints are the ones the OpenAPI document describes.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds each request unless the HTTP client is replaced
+const DefaultTimeout = 120 * time.Second
+
+// Client calls the agenticflows API of one server
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	workflowID string
+	apiKey     string
+	debug      bool
+}
+
+// NewClient creates a client for the server at baseURL, e.g. http://localhost:8080.
+// Analyses that don't name a workflow are stored under workflowID when it is set, and
+// debug prints every request and response.
+func NewClient(baseURL string, workflowID string, debug bool) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		workflowID: workflowID,
+		debug:      debug,
+	}
+}
+
+// SetAPIKey sets the key sent in X-API-Key when the server requires authentication
+func (c *Client) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+// SetHTTPClient replaces the HTTP client, e.g. to change the timeout or transport
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// EnableDebug enables debug mode for the client
+func (c *Client) EnableDebug() {
+	c.debug = true
+}
+
+// DisableDebug disables debug mode for the client
+func (c *Client) DisableDebug() {
+	c.debug = false
+}
+
+// APIError is a response with an error status. Code is the error code of analysis
+// errors, such as invalid_request, and Retryable tells whether sending the same request
+// again may succeed; other endpoints report a plain message.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Retryable  bool
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("API error %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends a request with body encoded as JSON, if it is not nil, and decodes a JSON
+// response into out, if it is not nil. Statuses of 400 and above are returned as an
+// *APIError.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("error marshaling request: %w", err)
+		}
+	}
+
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	if c.debug {
+		fmt.Printf("\n=== API REQUEST ===\n")
+		fmt.Printf("%s %s\n", method, endpoint)
+		if body != nil {
+			fmt.Printf("Request Payload:\n%s\n", prettyJSON(reqBody))
+		}
+		fmt.Printf("==================\n\n")
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+
+	if c.debug {
+		fmt.Printf("\n=== API RESPONSE ===\n")
+		fmt.Printf("Status: %s\n", resp.Status)
+		fmt.Printf("Response Payload:\n%s\n", prettyJSON(respBody))
+		fmt.Printf("===================\n\n")
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseError(resp.StatusCode, respBody)
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("error parsing response: %w", err)
+	}
+	return nil
+}
+
+// responseError builds the error of a failed response from the error object of analysis
+// endpoints, or the plain text the other endpoints write
+func responseError(statusCode int, body []byte) *APIError {
+	var analysisErr struct {
+		Error *struct {
+			Code      string `json:"code"`
+			Message   string `json:"message"`
+			Retryable bool   `json:"retryable"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &analysisErr) == nil && analysisErr.Error != nil {
+		return &APIError{StatusCode: statusCode, Code: analysisErr.Error.Code, Message: analysisErr.Error.Message, Retryable: analysisErr.Error.Retryable}
+	}
+	return &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+}
+
+// prettyJSON formats a JSON byte array for better readability
+func prettyJSON(data []byte) string {
+	var out bytes.Buffer
+	err := json.Indent(&out, data, "", "  ")
+	if err != nil {
+		return string(data) // Return raw data if prettifying fails
+	}
+	return out.String()
+}
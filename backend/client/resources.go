@@ -0,0 +1,322 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/pii"
+)
+
+// Conversation is a conversation stored on the server
+type Conversation struct {
+	ID         string                 `json:"conversation_id"`
+	CustomerID string                 `json:"customer_id,omitempty"`
+	Text       string                 `json:"text"`
+	DateTime   *time.Time             `json:"date_time,omitempty"`
+	Source     string                 `json:"source,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ConversationIngestRequest stores a batch of conversations, replacing those with the
+// same IDs
+type ConversationIngestRequest struct {
+	Conversations []Conversation `json:"conversations"`
+	// RevisionReason explains why transcripts were updated, e.g. "asr_rerun"
+	RevisionReason string `json:"revision_reason,omitempty"`
+	// Reextract set to false keeps the stored attributes of conversations whose text changed
+	Reextract *bool `json:"reextract,omitempty"`
+}
+
+// ConversationIngestResponse reports the outcome of a conversation ingest
+type ConversationIngestResponse struct {
+	Created          int      `json:"created"`
+	Updated          int      `json:"updated"`
+	ConversationIDs  []string `json:"conversation_ids"`
+	TextChanged      []string `json:"text_changed,omitempty"`
+	ReextractionJobs []string `json:"reextraction_jobs,omitempty"`
+}
+
+// IngestConversations stores conversations on the server so analyses can reference them
+// by ID, and returns their IDs
+func (c *Client) IngestConversations(ctx context.Context, conversations []Conversation) ([]string, error) {
+	var resp ConversationIngestResponse
+	if err := c.do(ctx, http.MethodPost, "/api/conversations", nil, ConversationIngestRequest{Conversations: conversations}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.ConversationIDs, nil
+}
+
+// TopicSearchRequest finds the stored conversations about a topic from their intent
+// classifications and embeddings. Mode is precision, balanced or recall.
+type TopicSearchRequest struct {
+	Topic   string   `json:"topic"`
+	Intents []string `json:"intents,omitempty"`
+	Mode    string   `json:"mode,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+}
+
+// TopicMatch is a stored conversation the server found about a topic
+type TopicMatch struct {
+	ConversationID string   `json:"conversation_id"`
+	Score          float64  `json:"score"`
+	Intent         string   `json:"intent,omitempty"`
+	Similarity     float64  `json:"similarity"`
+	MatchedBy      []string `json:"matched_by"`
+}
+
+// TopicSearchResponse is the conversations found about a topic, best first
+type TopicSearchResponse struct {
+	Topic   string       `json:"topic"`
+	Mode    string       `json:"mode"`
+	Results []TopicMatch `json:"results"`
+	// Total counts the matching conversations before the limit
+	Total int `json:"total"`
+}
+
+// SearchTopic finds the stored conversations about a topic, such as "fee dispute"
+func (c *Client) SearchTopic(ctx context.Context, topic, mode string, limit int) ([]TopicMatch, error) {
+	var resp TopicSearchResponse
+	req := TopicSearchRequest{Topic: topic, Mode: mode, Limit: limit}
+	if err := c.do(ctx, http.MethodPost, "/api/search/topic", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// AttributeSetRequest stores attribute definitions that requests reference as the
+// attribute_set_id parameter
+type AttributeSetRequest struct {
+	Name        string                       `json:"name"`
+	Description string                       `json:"description,omitempty"`
+	Attributes  []models.AttributeDefinition `json:"attributes"`
+	// ValidationRules are the cross-field rules checked after the attributes are extracted
+	ValidationRules json.RawMessage `json:"validation_rules,omitempty"`
+}
+
+// AttributeSet is a version of a stored set of attribute definitions
+type AttributeSet struct {
+	ID              string                       `json:"id"`
+	Name            string                       `json:"name"`
+	Description     string                       `json:"description,omitempty"`
+	Version         int                          `json:"version"`
+	Attributes      []models.AttributeDefinition `json:"attributes"`
+	ValidationRules json.RawMessage              `json:"validation_rules,omitempty"`
+	CreatedAt       time.Time                    `json:"created_at"`
+	UpdatedAt       time.Time                    `json:"updated_at"`
+}
+
+// CreateAttributeSet stores attribute definitions on the server and returns the ID that
+// requests reference as the attribute_set_id parameter
+func (c *Client) CreateAttributeSet(ctx context.Context, name string, attributes []models.AttributeDefinition) (string, error) {
+	var set AttributeSet
+	if err := c.do(ctx, http.MethodPost, "/api/attribute-sets", nil, AttributeSetRequest{Name: name, Attributes: attributes}, &set); err != nil {
+		return "", err
+	}
+	return set.ID, nil
+}
+
+// ListAttributeSets returns the latest version of the stored attribute sets, only those
+// of the given name unless it is empty
+func (c *Client) ListAttributeSets(ctx context.Context, name string) ([]AttributeSet, error) {
+	var query url.Values
+	if name != "" {
+		query = url.Values{"name": {name}}
+	}
+	var sets []AttributeSet
+	if err := c.do(ctx, http.MethodGet, "/api/attribute-sets", query, nil, &sets); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// UpdateAttributeSet stores new definitions as the next version of an attribute set
+func (c *Client) UpdateAttributeSet(ctx context.Context, id string, req AttributeSetRequest) (*AttributeSet, error) {
+	var set AttributeSet
+	if err := c.do(ctx, http.MethodPut, "/api/attribute-sets/"+url.PathEscape(id), nil, req, &set); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// EnsureAttributeSet returns the ID of the attribute set of the given name, storing the
+// definitions as a new set when there is none and as its next version when they differ
+// from its latest version, so programs can keep their sets in the library up to date
+func (c *Client) EnsureAttributeSet(ctx context.Context, req AttributeSetRequest) (string, error) {
+	sets, err := c.ListAttributeSets(ctx, req.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(sets) == 0 {
+		var set AttributeSet
+		if err := c.do(ctx, http.MethodPost, "/api/attribute-sets", nil, req, &set); err != nil {
+			return "", err
+		}
+		return set.ID, nil
+	}
+
+	// Sets are listed most recent first
+	set := sets[0]
+	stored, _ := json.Marshal(AttributeSetRequest{set.Name, set.Description, set.Attributes, set.ValidationRules})
+	wanted, _ := json.Marshal(req)
+	if string(stored) != string(wanted) {
+		if _, err := c.UpdateAttributeSet(ctx, set.ID, req); err != nil {
+			return "", err
+		}
+	}
+	return set.ID, nil
+}
+
+// ExportAttributeSet returns an attribute set as a JSON Schema document
+func (c *Client) ExportAttributeSet(ctx context.Context, id string) (json.RawMessage, error) {
+	var schema json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/attribute-sets/"+url.PathEscape(id)+"/schema", nil, nil, &schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// ImportAttributeSet stores the properties of a JSON Schema document as an attribute set,
+// named after its title unless name is set
+func (c *Client) ImportAttributeSet(ctx context.Context, schema json.RawMessage, name string) (*AttributeSet, error) {
+	var query url.Values
+	if name != "" {
+		query = url.Values{"name": {name}}
+	}
+	var set AttributeSet
+	if err := c.do(ctx, http.MethodPost, "/api/attribute-sets/import", query, schema, &set); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// Workflow is a saved workflow of nodes connected by edges
+type Workflow struct {
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name"`
+	Date  string          `json:"date,omitempty"`
+	Nodes json.RawMessage `json:"nodes"`
+	Edges json.RawMessage `json:"edges"`
+	// Version increases with every change and is used to detect conflicting edits
+	Version int `json:"version,omitempty"`
+}
+
+// WorkflowRunRequest is the input of a workflow execution
+type WorkflowRunRequest struct {
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Text       string                 `json:"text,omitempty"`
+	Tags       map[string]string      `json:"tags,omitempty"`
+}
+
+// CreateWorkflow saves a workflow and returns it with its ID
+func (c *Client) CreateWorkflow(ctx context.Context, workflow Workflow) (*Workflow, error) {
+	var created Workflow
+	if err := c.do(ctx, http.MethodPost, "/api/workflows", nil, workflow, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ExecuteWorkflow runs a saved workflow and returns its results
+func (c *Client) ExecuteWorkflow(ctx context.Context, id string, req WorkflowRunRequest) (map[string]interface{}, error) {
+	var results map[string]interface{}
+	if err := c.do(ctx, http.MethodPost, "/api/workflows/"+url.PathEscape(id)+"/execute", nil, req, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Run is the history of one execution of a workflow or chain analysis. Nodes and Results
+// are only returned by Run.
+type Run struct {
+	RunID      string          `json:"run_id"`
+	WorkflowID string          `json:"workflow_id"`
+	Kind       string          `json:"kind"` // workflow or chain
+	Actor      string          `json:"actor,omitempty"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Inputs     json.RawMessage `json:"inputs,omitempty"`
+	Models     []string        `json:"models"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+	Nodes      []RunNode       `json:"nodes,omitempty"`
+	Results    []RunResult     `json:"results,omitempty"`
+}
+
+// RunNode is the execution of one node, or chain step, of a run
+type RunNode struct {
+	NodeID     string          `json:"node_id"`
+	NodeType   string          `json:"node_type"`
+	Position   int             `json:"position"`
+	Status     string          `json:"status"`
+	StartedAt  time.Time       `json:"started_at"`
+	DurationMS int64           `json:"duration_ms"`
+	Output     json.RawMessage `json:"output,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// RunResult is an analysis result saved as part of a run
+type RunResult struct {
+	ResultID     string    `json:"result_id"`
+	AnalysisType string    `json:"analysis_type"`
+	Confidence   *float64  `json:"confidence,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WorkflowRuns lists the runs of a workflow, newest first. An empty status lists runs in
+// any state, and a zero limit uses the server's default.
+func (c *Client) WorkflowRuns(ctx context.Context, workflowID, status string, limit int) ([]Run, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var runs []Run
+	if err := c.do(ctx, http.MethodGet, "/api/workflows/"+url.PathEscape(workflowID)+"/runs", query, nil, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// Run returns a run with the outputs of its nodes and the analysis results saved as part
+// of it
+func (c *Client) Run(ctx context.Context, runID string) (*Run, error) {
+	var run Run
+	if err := c.do(ctx, http.MethodGet, "/api/runs/"+url.PathEscape(runID), nil, nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// PIIRedactRequest redacts personally identifiable information from a text. Providers and
+// MinConfidence override the server's PII configuration.
+type PIIRedactRequest struct {
+	Text          string   `json:"text"`
+	Language      string   `json:"language,omitempty"`
+	Providers     []string `json:"providers,omitempty"`
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+}
+
+// PIIRedactResponse is the redacted text and the entities that were removed
+type PIIRedactResponse struct {
+	RedactedText string       `json:"redacted_text"`
+	Entities     []pii.Entity `json:"entities"`
+	Providers    []string     `json:"providers"`
+}
+
+// RedactPII replaces the PII in a text with type placeholders such as [EMAIL]
+func (c *Client) RedactPII(ctx context.Context, req PIIRedactRequest) (*PIIRedactResponse, error) {
+	var resp PIIRedactResponse
+	if err := c.do(ctx, http.MethodPost, "/api/pii/redact", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
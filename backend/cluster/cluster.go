@@ -0,0 +1,112 @@
+// Package cluster lets several server instances share one PostgreSQL database: it
+// identifies each instance and elects a leader for the background tasks that must run
+// once per deployment rather than once per instance
+package cluster
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"agenticflows/backend/db"
+)
+
+// Cluster environment variables
+const (
+	EnvInstanceID = "INSTANCE_ID"
+	EnvLeaseTTL   = "LEADER_LEASE_TTL"
+)
+
+// DefaultLeaseTTL is how long a leader keeps its lease without renewing it, and so how
+// long its tasks stop after it dies before another instance takes over
+const DefaultLeaseTTL = 30 * time.Second
+
+// Config identifies a server instance
+type Config struct {
+	// InstanceID is unique per instance sharing a database. It defaults to the host name,
+	// which is unique per container, so a restarted instance recognizes its own jobs.
+	InstanceID string
+	LeaseTTL   time.Duration
+}
+
+// ConfigFromEnv reads the instance settings from the environment
+func ConfigFromEnv() Config {
+	config := Config{InstanceID: strings.TrimSpace(os.Getenv(EnvInstanceID)), LeaseTTL: DefaultLeaseTTL}
+	if config.InstanceID == "" {
+		if hostname, err := os.Hostname(); err == nil && hostname != "" {
+			config.InstanceID = hostname
+		} else {
+			config.InstanceID = "localhost"
+		}
+	}
+	if value := os.Getenv(EnvLeaseTTL); value != "" {
+		if ttl, err := time.ParseDuration(value); err == nil && ttl > 0 {
+			config.LeaseTTL = ttl
+		} else {
+			log.Printf("Warning: ignoring invalid %s value %q", EnvLeaseTTL, value)
+		}
+	}
+	return config
+}
+
+// InstanceID returns the ID of this server instance
+func InstanceID() string {
+	return ConfigFromEnv().InstanceID
+}
+
+// RunAsLeader runs task while this instance holds the lease of name, which at most one
+// instance holds at a time. The lease is renewed every third of its TTL; when it is lost
+// the context of task is canceled, and task is started again once the lease is regained.
+// RunAsLeader returns when ctx is done.
+func RunAsLeader(ctx context.Context, name string, task func(ctx context.Context)) {
+	config := ConfigFromEnv()
+	ticker := time.NewTicker(config.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	var cancel context.CancelFunc
+	var done chan struct{}
+	stop := func() {
+		if cancel != nil {
+			cancel()
+			<-done
+			cancel = nil
+		}
+	}
+	defer func() {
+		stop()
+		if err := db.ReleaseLease(name, config.InstanceID); err != nil {
+			log.Printf("Error releasing %s lease: %v", name, err)
+		}
+	}()
+
+	for {
+		leader, err := db.AcquireLease(name, config.InstanceID, config.LeaseTTL)
+		if err != nil {
+			log.Printf("Error renewing %s lease: %v", name, err)
+		}
+
+		switch {
+		case leader && cancel == nil:
+			log.Printf("Instance %s is the %s leader", config.InstanceID, name)
+			taskCtx, taskCancel := context.WithCancel(ctx)
+			cancel, done = taskCancel, make(chan struct{})
+			go func() {
+				defer close(done)
+				task(taskCtx)
+			}()
+		case !leader && cancel != nil:
+			// A failed renewal also stops the task, as another instance may take over
+			// once the lease expires
+			log.Printf("Instance %s lost the %s lease", config.InstanceID, name)
+			stop()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
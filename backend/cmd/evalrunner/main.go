@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/eval"
+)
+
+func main() {
+	// Command line flags
+	datasetFlag := flag.String("dataset", "analysis/eval/datasets", "Golden dataset file, or directory of dataset files")
+	runsFlag := flag.Int("runs", 1, "Times each case is analyzed; agreement is measured from 2")
+	outFlag := flag.String("out", "", "File to write the JSON reports to, usable as a later -baseline")
+	baselineFlag := flag.String("baseline", "", "JSON reports of an earlier run to check for regressions")
+	toleranceFlag := flag.Float64("tolerance", 0.02, "How far a metric may drop below the baseline")
+	minAccuracyFlag := flag.Float64("min-accuracy", 0, "Accuracy every task must reach")
+	modelFlag := flag.String("model", "", "Model to evaluate instead of the configured one")
+	instructionsFlag := flag.String("instructions", "", "Instructions to append to every prompt")
+	templatesFlag := flag.String("templates", "", "JSON file of prompt templates to evaluate, by prompt name")
+	mockFlag := flag.Bool("mock", false, "Answer with the mock LLM provider")
+	flag.Parse()
+
+	datasets, err := eval.LoadDatasets(*datasetFlag)
+	if err != nil {
+		fmt.Printf("Error loading datasets: %v\n", err)
+		os.Exit(1)
+	}
+	var templates map[string]string
+	if *templatesFlag != "" {
+		if err := readJSON(*templatesFlag, &templates); err != nil {
+			fmt.Printf("Error reading prompt templates: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var baseline []*eval.Report
+	if *baselineFlag != "" {
+		if err := readJSON(*baselineFlag, &baseline); err != nil {
+			fmt.Printf("Error reading baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// The mock provider needs no API key
+	if *mockFlag {
+		os.Setenv(core.EnvLLMProvider, core.ProviderMock)
+	}
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" && !core.GatewayConfigured() && !core.MockConfigured() {
+		fmt.Println("GEMINI_API_KEY, LLM_BASE_URL or LLM_PROVIDER=mock environment variable is required, or run with -mock")
+		os.Exit(1)
+	}
+	facade, err := analysis.NewAnalysisFacade(apiKey, false)
+	if err != nil {
+		fmt.Printf("Error creating analysis facade: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	runner := &eval.Runner{
+		Facade:    facade,
+		Runs:      *runsFlag,
+		Variant:   core.Variant{Model: *modelFlag, Instructions: *instructionsFlag},
+		Templates: templates,
+		Mock:      *mockFlag,
+		Progress: func(dataset string, done, total int) {
+			fmt.Fprintf(os.Stderr, "\r%s: %d/%d cases", dataset, done, total)
+			if done == total {
+				fmt.Fprintln(os.Stderr)
+			}
+		},
+	}
+
+	reports := make([]*eval.Report, 0, len(datasets))
+	for _, dataset := range datasets {
+		report, err := runner.Run(ctx, dataset)
+		if err != nil {
+			fmt.Printf("Error evaluating %s: %v\n", dataset.Name, err)
+			os.Exit(1)
+		}
+		reports = append(reports, report)
+	}
+
+	eval.WriteSummary(os.Stdout, reports)
+	if *outFlag != "" {
+		encoded, err := json.MarshalIndent(reports, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*outFlag, append(encoded, '\n'), 0644)
+		}
+		if err != nil {
+			fmt.Printf("Error writing reports: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if failures := check(reports, baseline, *toleranceFlag, *minAccuracyFlag); len(failures) > 0 {
+		fmt.Println()
+		for _, failure := range failures {
+			fmt.Println("FAIL", failure)
+		}
+		os.Exit(1)
+	}
+}
+
+// check returns the regressions of reports against the baseline reports of the same
+// datasets, and the tasks below the minimum accuracy
+func check(reports, baseline []*eval.Report, tolerance, minAccuracy float64) []string {
+	var failures []string
+	for _, report := range reports {
+		for _, before := range baseline {
+			if before.Dataset == report.Dataset {
+				failures = append(failures, eval.Compare(before, report, tolerance)...)
+			}
+		}
+		for _, task := range []struct {
+			name    string
+			metrics *eval.TaskMetrics
+		}{{"intent", report.Intent}, {"attribute", report.Attributes}} {
+			if task.metrics != nil && task.metrics.Accuracy < minAccuracy {
+				failures = append(failures, fmt.Sprintf("%s: %s accuracy %.3f is below %.3f", report.Dataset, task.name, task.metrics.Accuracy, minAccuracy))
+			}
+		}
+	}
+	return failures
+}
+
+// readJSON decodes a JSON file into target
+func readJSON(path string, target interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, target)
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
-	"agenticflows/backend/cmd/examples/client"
-
-	_ "github.com/mattn/go-sqlite3"
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/statistics"
+	"agenticflows/backend/client"
+	"agenticflows/backend/cmd/examples/store"
+	"agenticflows/backend/cmd/examples/utils"
+	"agenticflows/backend/report"
 )
 
 // Dispute represents a fee dispute record
@@ -40,6 +45,9 @@ func main() {
 	batchSize := flag.Int("batch", 10, "Batch size for processing disputes")
 	debug := flag.Bool("debug", false, "Enable debug mode")
 	workflowID := flag.String("workflow", "", "Workflow ID for persisting results")
+	mode := flag.String("mode", "balanced", "Dispute retrieval mode: precision, balanced or recall")
+	reportFormat := flag.String("report", "md", "Report format: md, html or pdf")
+	output := flag.String("output", "", "File to write the report to (default standard output)")
 	flag.Parse()
 
 	// Validate required flags
@@ -54,12 +62,16 @@ func main() {
 
 	// Step 1: Fetch fee disputes
 	fmt.Println("Fetching fee disputes from database...")
-	disputes, err := fetchDisputes(*dbPath, *maxDisputes, apiClient)
+	disputes, err := fetchDisputes(*dbPath, *maxDisputes, *mode, apiClient)
 	if err != nil {
 		fmt.Printf("Error fetching disputes: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Printf("Found %d fee disputes\n", len(disputes))
+	if len(disputes) == 0 {
+		fmt.Println("No fee disputes found. Ingest the conversations into the server and classify their intents first.")
+		os.Exit(1)
+	}
 
 	// Step 2: Fetch example conversations
 	fmt.Println("Fetching example conversations...")
@@ -70,12 +82,13 @@ func main() {
 	}
 	fmt.Printf("Found %d example conversations\n", len(conversations))
 
-	// Step 3: Prepare structured data for the API
+	// Step 3: Prepare structured data for the API, with exact statistics over all disputes
 	disputeData := prepareDisputeData(disputes)
+	metadata := disputeMetadata(disputeData)
 
 	// Step 4: Analyze trends in batches
 	fmt.Println("\nAnalyzing trends in fee disputes...")
-	trends, err := processBatchedTrends(apiClient, disputeData, conversations, *batchSize)
+	trends, err := processBatchedTrends(apiClient, disputeData, conversations, metadata, *batchSize)
 	if err != nil {
 		fmt.Printf("Warning: Error analyzing trends: %v\n", err)
 		fmt.Println("Continuing with partial or default trends...")
@@ -91,35 +104,33 @@ func main() {
 
 	// Step 6: Generate findings and recommendations
 	fmt.Println("\nGenerating findings and recommendations...")
-	findings, recommendations, err := processBatchedFindings(apiClient, disputeData, conversations, trends, patterns, *batchSize)
+	findings, recommendations, err := processBatchedFindings(apiClient, disputeData, conversations, metadata, trends, patterns, *batchSize)
 	if err != nil {
 		fmt.Printf("Warning: Error generating findings: %v\n", err)
 		fmt.Println("Continuing with partial or default findings...")
 	}
 
-	// Step 7: Print results
-	fmt.Println("\n=== Results ===")
-	fmt.Println("\nTrends:")
-	for _, trend := range trends.TrendDescriptions {
-		fmt.Printf("- %s\n", trend)
-	}
-
-	fmt.Println("\nPatterns:")
-	for _, pattern := range patterns {
-		fmt.Printf("- %s\n", pattern)
-	}
-
-	fmt.Println("\nFindings:")
-	for _, finding := range findings {
-		fmt.Printf("- %s\n", finding)
+	// Step 7: Report the results, with the statistics as the executive summary
+	doc := report.New("Fee Dispute Analysis", *workflowID)
+	doc.Highlights = statisticsHighlights(metadata)
+	doc.AddSection(textSection(report.SectionTrends, trends.TrendDescriptions))
+	doc.AddSection(textSection(report.SectionPatterns, patterns))
+	doc.AddSection(textSection(report.SectionFindings, findings))
+	doc.AddSection(textSection(report.SectionRecommendations, recommendations))
+	fmt.Println()
+	if err := utils.WriteReport(doc, *reportFormat, *output); err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		os.Exit(1)
 	}
+}
 
-	fmt.Println("\nRecommendations:")
-	for _, rec := range recommendations {
-		fmt.Printf("- %s\n", rec)
+// textSection returns a report section listing lines of text
+func textSection(title string, lines []string) report.Section {
+	section := report.Section{Title: title}
+	for _, line := range lines {
+		section.Items = append(section.Items, report.Item{Text: line})
 	}
-
-	fmt.Println("\nAnalysis complete!")
+	return section
 }
 
 // prepareDisputeData converts disputes to a structured format for the API
@@ -144,8 +155,27 @@ type Analysis struct {
 	RecommendedActions []string `json:"recommended_actions"`
 }
 
-// processBatchedTrends processes disputes in batches for trend analysis
-func processBatchedTrends(apiClient *client.Client, disputeData []map[string]interface{}, conversations []map[string]interface{}, batchSize int) (Analysis, error) {
+// maxAttempts is how often a batch is sent when it fails with a retryable error
+const maxAttempts = 3
+
+// performWithRetry runs an analysis, sending it again after failures the server reports
+// as retryable, such as provider timeouts
+func performWithRetry(apiClient *client.Client, req client.StandardAnalysisRequest) (*client.StandardAnalysisResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := apiClient.PerformAnalysis(context.Background(), req)
+		var apiErr *client.APIError
+		if err == nil || !errors.As(err, &apiErr) || !apiErr.Retryable || attempt == maxAttempts {
+			return resp, err
+		}
+		fmt.Printf("Retrying after %s error (attempt %d of %d)\n", apiErr.Code, attempt+1, maxAttempts)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+// processBatchedTrends processes disputes in batches for trend analysis. Batches that
+// still fail after retries are left out and reported in the error along with the trends
+// of the others.
+func processBatchedTrends(apiClient *client.Client, disputeData []map[string]interface{}, conversations []map[string]interface{}, metadata map[string]interface{}, batchSize int) (Analysis, error) {
 	// Define default trends in case of failure
 	defaultAnalysis := Analysis{
 		TrendDescriptions:  []string{"No trends identified due to processing error"},
@@ -164,6 +194,7 @@ func processBatchedTrends(apiClient *client.Client, disputeData []map[string]int
 	// Combine results from all batches
 	var allTrends []string
 	var allActions []string
+	var failures []string
 
 	// Process each batch
 	for i := 0; i < len(disputeData); i += batchSize {
@@ -196,60 +227,49 @@ func processBatchedTrends(apiClient *client.Client, disputeData []map[string]int
 			Data: map[string]interface{}{
 				"attribute_values": batch,
 				"conversations":    getLimitedConversations(conversations, 2),
-				"metadata": map[string]interface{}{
-					"avg_amount":       calculateAverageAmount(disputeData),
-					"total_disputes":   len(disputeData),
-					"dispute_timespan": "3 months",
-				},
+				"metadata":         metadata,
 			},
 		}
 
 		// Make API request
-		resp, err := apiClient.PerformAnalysis(req)
+		resp, err := performWithRetry(apiClient, req)
 		if err != nil {
 			fmt.Printf("Error analyzing trends in batch %d: %v\n", (i/batchSize)+1, err)
+			failures = append(failures, fmt.Sprintf("batch %d: %v", (i/batchSize)+1, err))
 			continue
 		}
+		for _, warning := range resp.Warnings {
+			fmt.Printf("Warning in batch %d (%s): %s\n", (i/batchSize)+1, warning.Code, warning.Message)
+		}
 
-		// Extract trends from response
-		if results, ok := resp.Results.(map[string]interface{}); ok {
-			// Try to extract trends
-			extractedTrends := extractArrayFromMap(results, []string{
-				"trend_descriptions", "trends",
-			})
-
-			for _, t := range extractedTrends {
-				if trend, ok := t.(string); ok {
-					allTrends = append(allTrends, trend)
-				} else if trendMap, ok := t.(map[string]interface{}); ok {
-					if desc, ok := trendMap["trend"].(string); ok {
-						allTrends = append(allTrends, desc)
-					}
-				}
-			}
-
-			// Try to extract insights/recommended actions
-			extractedActions := extractArrayFromMap(results, []string{
-				"recommended_actions", "overall_insights",
-			})
-
-			for _, a := range extractedActions {
-				if action, ok := a.(string); ok {
-					allActions = append(allActions, action)
-				}
-			}
+		// Extract trends and insights from the typed results
+		var results analysis.TrendsResult
+		if err := resp.DecodeResults(&results); err != nil {
+			failures = append(failures, fmt.Sprintf("batch %d: %v", (i/batchSize)+1, err))
+			continue
+		}
+		for _, trend := range results.Trends {
+			allTrends = append(allTrends, trend.Trend)
 		}
+		allActions = append(allActions, results.OverallInsights...)
 	}
 
 	// If we didn't get any trends, return the default
 	if len(allTrends) == 0 {
+		if len(failures) > 0 {
+			return defaultAnalysis, fmt.Errorf("no trends extracted: %s", strings.Join(failures, "; "))
+		}
 		return defaultAnalysis, fmt.Errorf("no trends extracted from analysis results")
 	}
 
-	return Analysis{
+	trends := Analysis{
 		TrendDescriptions:  allTrends,
 		RecommendedActions: allActions,
-	}, nil
+	}
+	if len(failures) > 0 {
+		return trends, fmt.Errorf("%d of %d batches failed: %s", len(failures), batchCount, strings.Join(failures, "; "))
+	}
+	return trends, nil
 }
 
 // processBatchedPatterns processes disputes in batches for pattern analysis
@@ -301,7 +321,7 @@ func processBatchedPatterns(apiClient *client.Client, disputeData []map[string]i
 		}
 
 		// Make API request
-		resp, err := apiClient.PerformAnalysis(req)
+		resp, err := apiClient.PerformAnalysis(context.Background(), req)
 		if err != nil {
 			fmt.Printf("Error identifying patterns in batch %d: %v\n", (i/batchSize)+1, err)
 			continue
@@ -341,7 +361,7 @@ func processBatchedPatterns(apiClient *client.Client, disputeData []map[string]i
 
 // processBatchedFindings generates findings and recommendations from the analysis results
 func processBatchedFindings(apiClient *client.Client, disputeData []map[string]interface{}, conversations []map[string]interface{},
-	analysis Analysis, patterns []string, batchSize int) ([]string, []string, error) {
+	metadata map[string]interface{}, analysis Analysis, patterns []string, batchSize int) ([]string, []string, error) {
 
 	// If there are no disputes, return empty
 	if len(disputeData) == 0 {
@@ -403,15 +423,12 @@ func processBatchedFindings(apiClient *client.Client, disputeData []map[string]i
 				"conversations":    getLimitedConversations(conversations, 2),
 				"trends_data":      trendsData,
 				"patterns_data":    patterns,
-				"metadata": map[string]interface{}{
-					"avg_amount":     calculateAverageAmount(disputeData),
-					"total_disputes": len(disputeData),
-				},
+				"metadata":         metadata,
 			},
 		}
 
 		// Make API request
-		resp, err := apiClient.PerformAnalysis(req)
+		resp, err := apiClient.PerformAnalysis(context.Background(), req)
 		if err != nil {
 			fmt.Printf("Error generating findings in batch %d: %v\n", (i/findingsBatchSize)+1, err)
 			continue
@@ -459,20 +476,65 @@ func getLimitedConversations(conversations []map[string]interface{}, limit int)
 	return conversations[:limit]
 }
 
-// Helper function to calculate the average amount of disputes
-func calculateAverageAmount(disputes []map[string]interface{}) float64 {
-	total := 0.0
-	count := 0
-	for _, dispute := range disputes {
-		if amt, ok := dispute["amount"].(float64); ok {
-			total += amt
-			count++
+// disputeMetadata computes exact statistics over all disputes, so batches of the trends
+// and findings analyses see the totals rather than the numbers of their own batch
+func disputeMetadata(disputeData []map[string]interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"total_disputes": len(disputeData),
+	}
+
+	stats := statistics.Summarize(disputeData)
+	if stats == nil {
+		return metadata
+	}
+	dataset := stats.Datasets[0]
+	for _, numeric := range dataset.Numeric {
+		if numeric.Field == "amount" {
+			metadata["avg_amount"] = numeric.Mean
+			metadata["median_amount"] = numeric.Median
+			metadata["total_amount"] = numeric.Sum
 		}
 	}
-	if count == 0 {
-		return 0
+	for _, series := range dataset.Series {
+		if series.Field == "created_at" {
+			metadata["dispute_timespan"] = fmt.Sprintf("%s to %s", series.Start.Format("2006-01-02"), series.End.Format("2006-01-02"))
+		}
 	}
-	return total / float64(count)
+	metadata["statistics"] = dataset
+	return metadata
+}
+
+// statisticsHighlights describes the statistics computed over all disputes
+func statisticsHighlights(metadata map[string]interface{}) []string {
+	highlights := []string{fmt.Sprintf("Disputes: %d", metadata["total_disputes"])}
+	if avg, ok := metadata["avg_amount"].(float64); ok {
+		highlights = append(highlights, fmt.Sprintf("Amount: average %.2f, median %.2f, total %.2f", avg, metadata["median_amount"], metadata["total_amount"]))
+	}
+	if timespan, ok := metadata["dispute_timespan"].(string); ok {
+		highlights = append(highlights, "Timespan: "+timespan)
+	}
+
+	dataset, ok := metadata["statistics"].(statistics.Dataset)
+	if !ok {
+		return highlights
+	}
+	for _, distribution := range dataset.Distributions {
+		if distribution.Field != "sentiment" {
+			continue
+		}
+		for _, value := range distribution.Values {
+			highlights = append(highlights, fmt.Sprintf("Sentiment %s: %d (%.0f%%)", value.Value, value.Count, 100*value.Share))
+		}
+	}
+	for _, series := range dataset.Series {
+		if series.Field != "created_at" {
+			continue
+		}
+		for _, bucket := range series.Buckets {
+			highlights = append(highlights, fmt.Sprintf("%s of %s: %d disputes", series.Interval, bucket.Start.Format("2006-01-02"), bucket.Count))
+		}
+	}
+	return highlights
 }
 
 // Helper function to find the minimum of two integers
@@ -483,85 +545,64 @@ func min(a, b int) int {
 	return b
 }
 
-// fetchDisputes fetches fee disputes from the database
-func fetchDisputes(dbPath string, limit int, apiClient *client.Client) ([]Dispute, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
+// fetchDisputes fetches fee disputes from the database. The server picks them by their
+// intent classifications and embeddings, so conversations that mention fees in passing
+// are left out and disputes that never say "fee" are found.
+func fetchDisputes(dbPath string, limit int, mode string, apiClient *client.Client) ([]Dispute, error) {
+	matches, err := apiClient.SearchTopic(context.Background(), "fee dispute", mode, limit)
 	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for fee disputes
-	query := `
-	SELECT 
-		conversation_id,
-		text,
-		COALESCE(date_time, CURRENT_TIMESTAMP) as date_time
-	FROM conversations
-	WHERE text IS NOT NULL 
-	AND LENGTH(text) > 100
-	AND (
-		text LIKE '%fee%'
-		OR text LIKE '%charge%'
-		OR text LIKE '%billing%'
-		OR text LIKE '%refund%'
-		OR text LIKE '%dispute%'
-	)
-	ORDER BY RANDOM()
-	LIMIT ?
-	`
-
-	rows, err := db.Query(query, limit)
+		return nil, fmt.Errorf("error searching fee disputes: %w", err)
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	// Load the text of the matching conversations
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ConversationID
+	}
+	conversations, err := store.ConversationsByID(dbPath, ids)
 	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
+
+	// Reference the attribute definitions of the server's library by ID in every request
+	attributeParams := utils.AttributeParameters(apiClient, utils.FeeDisputeAmount)
 
 	// Format disputes as objects
 	disputes := make([]Dispute, 0)
-	for rows.Next() {
-		var dispute Dispute
-		var createdAtStr string
-		if err := rows.Scan(&dispute.ID, &dispute.Text, &createdAtStr); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-
-		// Parse created_at timestamp
-		dispute.CreatedAt, _ = time.Parse("2006-01-02T15:04:05-07:00", createdAtStr)
+	for _, conv := range conversations {
+		dispute := Dispute{ID: conv.ID, Text: conv.Text, CreatedAt: conv.CreatedAt}
 
 		// Extract amount from text using the API
 		req := client.StandardAnalysisRequest{
 			AnalysisType: "attributes",
 			Text:         dispute.Text,
-			Parameters: map[string]interface{}{
-				"attributes": []map[string]string{
-					{
-						"field_name":  "amount",
-						"title":       "Disputed Amount",
-						"description": "The amount of money being disputed",
-					},
-				},
-			},
+			Parameters:   attributeParams,
 		}
 
-		resp, err := apiClient.PerformAnalysis(req)
-		if err == nil {
-			if results, ok := resp.Results.(map[string]interface{}); ok {
-				if attrValues, ok := results["attribute_values"].(map[string]interface{}); ok {
-					if amountStr, ok := attrValues["amount"].(string); ok {
-						// Try to parse the amount from the value
-						fmt.Sscanf(amountStr, "$%f", &dispute.Amount)
-					}
+		// The server reads the amount as a number, whatever currency or format it is
+		// written in
+		resp, err := apiClient.PerformAnalysis(context.Background(), req)
+		var result analysis.AttributesResult
+		if err == nil && resp.DecodeResults(&result) == nil {
+			for _, value := range result.AttributeValues {
+				if amount, ok := value.TypedValue.(float64); ok && value.FieldName == "amount" {
+					dispute.Amount = amount
 				}
 			}
 		}
 
-		disputes = append(disputes, dispute)
-	}
+		// Classify the customer's sentiment
+		if sentiment, err := apiClient.AnalyzeSentiment(context.Background(), dispute.Text); err == nil {
+			if overall, ok := sentiment["overall"].(map[string]interface{}); ok {
+				dispute.Sentiment, _ = overall["label"].(string)
+			}
+			dispute.SentimentDesc, _ = sentiment["summary"].(string)
+		}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		disputes = append(disputes, dispute)
 	}
 
 	return disputes, nil
@@ -569,64 +610,20 @@ func fetchDisputes(dbPath string, limit int, apiClient *client.Client) ([]Disput
 
 // fetchConversations fetches example conversations from the database
 func fetchConversations(dbPath string, limit int) ([]map[string]interface{}, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
+	stored, err := store.SampleConversations(dbPath, 200, limit)
 	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for representative conversations
-	query := `
-	SELECT 
-		conversation_id,
-		text,
-		COALESCE(date_time, CURRENT_TIMESTAMP) as date_time
-	FROM conversations
-	WHERE text IS NOT NULL 
-	AND LENGTH(text) > 200
-	ORDER BY RANDOM()
-	LIMIT ?
-	`
-
-	rows, err := db.Query(query, limit)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
 	// Format conversations as objects
-	conversations := make([]map[string]interface{}, 0)
-	for rows.Next() {
-		var id, text, createdAtStr string
-		if err := rows.Scan(&id, &text, &createdAtStr); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-
-		// Parse created_at timestamp
-		createdAt, _ := time.Parse("2006-01-02T15:04:05-07:00", createdAtStr)
-
+	conversations := make([]map[string]interface{}, 0, len(stored))
+	for _, conv := range stored {
 		conversations = append(conversations, map[string]interface{}{
-			"id":         id,
-			"text":       text,
-			"created_at": createdAt.Format(time.RFC3339),
+			"id":         conv.ID,
+			"text":       conv.Text,
+			"created_at": conv.CreatedAt.Format(time.RFC3339),
 			"type":       "customer_service",
 		})
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
 	return conversations, nil
 }
-
-// Helper function to extract arrays from map using multiple possible field names
-func extractArrayFromMap(data map[string]interface{}, possibleFields []string) []interface{} {
-	for _, field := range possibleFields {
-		if arr, ok := data[field].([]interface{}); ok && len(arr) > 0 {
-			return arr
-		}
-	}
-	return []interface{}{}
-}
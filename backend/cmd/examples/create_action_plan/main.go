@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"time"
 
-	"agenticflows/backend/cmd/examples/client"
+	"agenticflows/backend/client"
 	"agenticflows/backend/cmd/examples/utils"
+	"agenticflows/backend/report"
 )
 
 // Main function
@@ -20,6 +22,8 @@ func main() {
 	timespan := flag.String("timespan", "6 months", "Timespan for implementation")
 	debug := flag.Bool("debug", false, "Enable debug mode")
 	workflowID := flag.String("workflow", "", "Workflow ID for persisting results")
+	reportFormat := flag.String("report", "md", "Report format: md, html or pdf")
+	output := flag.String("output", "", "File to write the report to (default standard output)")
 	// Adding mock flag for consistency, though this script already uses sample data
 	_ = flag.Bool("mock", false, "Use mock data (this script always uses sample data)")
 	flag.Parse()
@@ -48,16 +52,25 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Step 3: Print results
-	fmt.Println("\n=== Results ===")
-
-	if *genTimeline {
-		printTimeline(result.(TimelineResult))
-	} else {
-		printActionPlan(result.(ActionPlanResult))
+	// Step 3: Report the plan; a timeline is reported as the timeline of a plan
+	doc := report.New("Action Plan", *workflowID)
+	if timeline, ok := result.(TimelineResult); ok {
+		doc.Title = "Implementation Timeline"
+		doc.Highlights = []string{
+			"Start date: " + timeline.StartDate,
+			"End date: " + timeline.EndDate,
+			"Total duration: " + timeline.TotalDuration,
+		}
+	}
+	if err := doc.AddResult("plan", result); err != nil {
+		fmt.Printf("Error building report: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println()
+	if err := utils.WriteReport(doc, *reportFormat, *output); err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		os.Exit(1)
 	}
-
-	fmt.Println("\nAction Plan Generation complete!")
 }
 
 // RecommendationAction represents a recommended action
@@ -184,7 +197,7 @@ func createActionPlan(apiClient *client.Client, recommendations RecommendationDa
 		},
 	}
 
-	resp, err := apiClient.PerformAnalysis(req)
+	resp, err := apiClient.PerformAnalysis(context.Background(), req)
 	if err != nil {
 		return emptyResult, fmt.Errorf("error creating action plan: %w", err)
 	}
@@ -262,8 +275,7 @@ func generateTimeline(apiClient *client.Client, recommendations RecommendationDa
 
 	// Define resources for timeline generation
 	resources := map[string]interface{}{
-		"staff":      5,
-		"start_date": time.Now().Format("2006-01-02"),
+		"staff": 5,
 	}
 
 	// Request timeline
@@ -272,6 +284,9 @@ func generateTimeline(apiClient *client.Client, recommendations RecommendationDa
 		AnalysisType: "plan",
 		Parameters: map[string]interface{}{
 			"generate_timeline": true,
+			"calendar": map[string]interface{}{
+				"start_date": time.Now().Format("2006-01-02"),
+			},
 		},
 		Data: map[string]interface{}{
 			"action_plan": map[string]interface{}{
@@ -282,7 +297,7 @@ func generateTimeline(apiClient *client.Client, recommendations RecommendationDa
 		},
 	}
 
-	resp, err := apiClient.PerformAnalysis(req)
+	resp, err := apiClient.PerformAnalysis(context.Background(), req)
 	if err != nil {
 		return emptyResult, fmt.Errorf("error generating timeline: %w", err)
 	}
@@ -293,14 +308,18 @@ func generateTimeline(apiClient *client.Client, recommendations RecommendationDa
 		// Extract timeline
 		result.Timeline = extractTimelinePhases(results)
 
-		// Extract other timeline-specific details
-		result.StartDate = utils.GetString(results, "start_date")
-		result.EndDate = utils.GetString(results, "end_date")
-		result.TotalDuration = utils.GetString(results, "total_duration")
+		// Extract the dates the timeline was scheduled on
+		if schedule, ok := results["schedule"].(map[string]interface{}); ok {
+			result.StartDate = utils.GetString(schedule, "start_date")
+			result.EndDate = utils.GetString(schedule, "end_date")
+			if days, ok := schedule["working_days"].(float64); ok {
+				result.TotalDuration = fmt.Sprintf("%d working days", int(days))
+			}
 
-		// Extract milestone dates
-		if milestoneDates, ok := results["milestone_dates"].([]interface{}); ok {
-			result.MilestoneDates = milestoneDates
+			// Extract milestone dates
+			if milestoneDates, ok := schedule["milestone_dates"].([]interface{}); ok {
+				result.MilestoneDates = milestoneDates
+			}
 		}
 	}
 
@@ -374,81 +393,3 @@ func extractTimelinePhases(results map[string]interface{}) []TimelinePhase {
 
 	return phases
 }
-
-// printActionPlan prints the action plan in a readable format
-func printActionPlan(plan ActionPlanResult) {
-	fmt.Println("\nGoals:")
-	for i, goal := range plan.Goals {
-		fmt.Printf("%d. %s\n", i+1, goal)
-	}
-
-	fmt.Println("\nImmediate Actions:")
-	for i, action := range plan.ImmediateActions {
-		fmt.Printf("%d. %s\n", i+1, action.Action)
-		fmt.Printf("   Description: %s\n", action.Description)
-		fmt.Printf("   Priority: %d\n", action.Priority)
-		fmt.Printf("   Effort: %s\n", action.EstimatedEffort)
-		fmt.Printf("   Role: %s\n", action.ResponsibleRole)
-
-		if len(action.Dependencies) > 0 {
-			fmt.Printf("   Dependencies: %v\n", action.Dependencies)
-		}
-		fmt.Println()
-	}
-
-	if len(plan.ShortTermActions) > 0 {
-		fmt.Println("\nShort-Term Actions:")
-		for i, action := range plan.ShortTermActions {
-			fmt.Printf("%d. %s\n", i+1, action.Action)
-		}
-	}
-
-	if len(plan.LongTermActions) > 0 {
-		fmt.Println("\nLong-Term Actions:")
-		for i, action := range plan.LongTermActions {
-			fmt.Printf("%d. %s\n", i+1, action.Action)
-		}
-	}
-
-	fmt.Println("\nImplementation Timeline:")
-	for i, phase := range plan.Timeline {
-		fmt.Printf("Phase %d: %s (%s)\n", i+1, phase.Phase, phase.Duration)
-		fmt.Printf("   %s\n", phase.Description)
-		fmt.Printf("   Milestones:\n")
-		for _, milestone := range phase.Milestones {
-			fmt.Printf("   - %s\n", milestone)
-		}
-		fmt.Println()
-	}
-
-	fmt.Println("\nKey Risks and Mitigations:")
-	for i, risk := range plan.RisksMitigations {
-		fmt.Printf("%d. Risk: %s\n", i+1, risk.Risk)
-		fmt.Printf("   Impact: %s, Probability: %s\n", risk.Impact, risk.Probability)
-		fmt.Printf("   Mitigation: %s\n", risk.MitigationPlan)
-		fmt.Println()
-	}
-
-	fmt.Println("\nSuccess Metrics:")
-	for i, metric := range plan.SuccessMetrics {
-		fmt.Printf("%d. %s\n", i+1, metric)
-	}
-}
-
-// printTimeline prints the timeline in a readable format
-func printTimeline(timeline TimelineResult) {
-	fmt.Printf("\nImplementation Timeline:\n")
-	fmt.Printf("Start Date: %s\n", timeline.StartDate)
-	fmt.Printf("End Date: %s\n", timeline.EndDate)
-	fmt.Printf("Total Duration: %s\n\n", timeline.TotalDuration)
-
-	for i, phase := range timeline.Timeline {
-		fmt.Printf("Phase %d: %s (%s)\n", i+1, phase.Phase, phase.Duration)
-		fmt.Printf("   %s\n", phase.Description)
-		fmt.Printf("   Milestones:\n")
-		for _, milestone := range phase.Milestones {
-			fmt.Printf("   - %s\n", milestone)
-		}
-		fmt.Println()
-	}
-}
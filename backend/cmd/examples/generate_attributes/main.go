@@ -1,30 +1,17 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"time"
 
-	"agenticflows/backend/cmd/examples/client"
+	"agenticflows/backend/client"
+	"agenticflows/backend/cmd/examples/store"
 	"agenticflows/backend/cmd/examples/utils"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
-// Attribute represents a database attribute
-type Attribute struct {
-	Name        string
-	Type        string
-	Value       string
-	Description string
-	Count       int
-}
-
-// We don't need to redeclare Conversation since it's in utils.go
-// Keeping the comment for clarity
-
 func main() {
 	// Define command-line flags
 	dbPath := flag.String("db", "", "Path to the SQLite database")
@@ -82,7 +69,7 @@ func main() {
 		},
 	}
 
-	resp, err := apiClient.PerformAnalysis(req)
+	resp, err := apiClient.PerformAnalysis(context.Background(), req)
 	if err != nil {
 		fmt.Printf("Error generating required attributes: %v\n", err)
 		os.Exit(1)
@@ -132,7 +119,7 @@ func main() {
 	}
 
 	// Step 2: Fetch existing attributes from database
-	existingAttributes, err := fetchExistingAttributes(*dbPath, *minCount)
+	existingAttributes, err := store.Attributes(*dbPath, *minCount)
 	if err != nil {
 		fmt.Printf("Error fetching existing attributes: %v\n", err)
 		os.Exit(1)
@@ -199,7 +186,7 @@ func main() {
 		conversations := make([]utils.Conversation, 0)
 		if len(matchingIntents) == 0 {
 			fmt.Printf("No intents matching '%s' were found. Using random conversations instead.\n", *targetClass)
-			conversations, err = fetchSampleConversations(*dbPath, *limit)
+			conversations, err = store.SampleConversations(*dbPath, 100, *limit)
 			if err != nil {
 				fmt.Printf("Error fetching sample conversations: %v\n", err)
 				os.Exit(1)
@@ -207,7 +194,7 @@ func main() {
 		} else {
 			// Step 5: Fetch conversations with matching intents
 			fmt.Printf("\nFetching %d conversations with '%s' intents...\n", *limit, *targetClass)
-			conversations, err = fetchConversationsByIntents(*dbPath, matchingIntents, *limit)
+			conversations, err = store.ConversationsByIntents(*dbPath, matchingIntents, 100, *limit)
 			if err != nil {
 				fmt.Printf("Error fetching conversations by intents: %v\n", err)
 				os.Exit(1)
@@ -215,7 +202,7 @@ func main() {
 
 			if len(conversations) == 0 {
 				fmt.Println("No conversations with matching intents found. Using random conversations instead.")
-				conversations, err = fetchSampleConversations(*dbPath, *limit)
+				conversations, err = store.SampleConversations(*dbPath, 100, *limit)
 				if err != nil {
 					fmt.Printf("Error fetching sample conversations: %v\n", err)
 					os.Exit(1)
@@ -244,7 +231,7 @@ func main() {
 				},
 			}
 
-			resp, err := apiClient.PerformAnalysis(req)
+			resp, err := apiClient.PerformAnalysis(context.Background(), req)
 			if err != nil {
 				fmt.Printf("Error generating attributes: %v\n", err)
 				continue
@@ -280,105 +267,13 @@ func main() {
 	utils.PrintTimeTaken(startTime, "Generate attributes")
 }
 
-// fetchExistingAttributes fetches attributes from the database
-func fetchExistingAttributes(dbPath string, minCount int) ([]Attribute, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for unique attributes that appear at least minCount times
-	query := `
-	SELECT name, type, COUNT(*) as count
-	FROM conversation_attributes
-	WHERE type = 'attribute'
-	GROUP BY name
-	HAVING COUNT(*) >= ?
-	`
-
-	rows, err := db.Query(query, minCount)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
-	}
-	defer rows.Close()
-
-	// Format attributes as objects
-	attributes := make([]Attribute, 0)
-	for rows.Next() {
-		var attr Attribute
-		if err := rows.Scan(&attr.Name, &attr.Type, &attr.Count); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-
-		// For each unique attribute name, get a sample value
-		valueQuery := `
-		SELECT value, description
-		FROM conversation_attributes
-		WHERE name = ? AND type = 'attribute'
-		LIMIT 1
-		`
-		var description sql.NullString
-		var value sql.NullString
-		if err := db.QueryRow(valueQuery, attr.Name).Scan(&value, &description); err != nil {
-			if err != sql.ErrNoRows {
-				return nil, fmt.Errorf("error querying value: %w", err)
-			}
-		}
-
-		attr.Value = value.String
-		attr.Description = description.String
-		attributes = append(attributes, attr)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	return attributes, nil
-}
-
 // findMatchingIntents finds intents matching the target class
 func findMatchingIntents(dbPath, targetClass string, minCount int) ([]string, error) {
 	// For this simplified version, we'll just return intents containing the target class
 	// In a real implementation, this would use the API to classify intents
-
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for intents that contain the target class
-	query := `
-	SELECT value as intent_text
-	FROM conversation_attributes
-	WHERE type = 'intent'
-	AND lower(value) LIKE ?
-	GROUP BY value
-	HAVING COUNT(*) >= ?
-	`
-
-	rows, err := db.Query(query, "%"+targetClass+"%", minCount)
+	intents, err := store.IntentsContaining(dbPath, targetClass, minCount)
 	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
-	}
-	defer rows.Close()
-
-	// Format intents as strings
-	intents := make([]string, 0)
-	for rows.Next() {
-		var intent string
-		if err := rows.Scan(&intent); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-		intents = append(intents, intent)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		return nil, err
 	}
 
 	fmt.Printf("Found %d intents matching '%s'\n", len(intents), targetClass)
@@ -394,104 +289,3 @@ func findMatchingIntents(dbPath, targetClass string, minCount int) ([]string, er
 
 	return intents, nil
 }
-
-// fetchConversationsByIntents fetches conversations with matching intents
-func fetchConversationsByIntents(dbPath string, matchingIntents []string, limit int) ([]utils.Conversation, error) {
-	if len(matchingIntents) == 0 {
-		return nil, nil
-	}
-
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Create placeholders for SQL IN clause
-	placeholders := "?"
-	for i := 1; i < len(matchingIntents); i++ {
-		placeholders += ",?"
-	}
-
-	// Query for conversations with matching intents
-	query := fmt.Sprintf(`
-	SELECT c.conversation_id, c.text
-	FROM conversations c
-	JOIN conversation_attributes ca ON c.conversation_id = ca.conversation_id
-	WHERE ca.type = 'intent' AND ca.value IN (%s)
-	AND c.text IS NOT NULL AND LENGTH(c.text) > 100
-	ORDER BY RANDOM()
-	LIMIT ?
-	`, placeholders)
-
-	// Create args slice
-	args := make([]interface{}, len(matchingIntents)+1)
-	for i, intent := range matchingIntents {
-		args[i] = intent
-	}
-	args[len(matchingIntents)] = limit
-
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
-	}
-	defer rows.Close()
-
-	// Format conversations as objects
-	conversations := make([]utils.Conversation, 0)
-	for rows.Next() {
-		var conv utils.Conversation
-		if err := rows.Scan(&conv.ID, &conv.Text); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-		conversations = append(conversations, conv)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	return conversations, nil
-}
-
-// fetchSampleConversations fetches random sample conversations
-func fetchSampleConversations(dbPath string, limit int) ([]utils.Conversation, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for random conversations
-	query := `
-	SELECT conversation_id, text
-	FROM conversations
-	WHERE text IS NOT NULL AND LENGTH(text) > 100
-	ORDER BY RANDOM()
-	LIMIT ?
-	`
-
-	rows, err := db.Query(query, limit)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
-	}
-	defer rows.Close()
-
-	// Format conversations as objects
-	conversations := make([]utils.Conversation, 0)
-	for rows.Next() {
-		var conv utils.Conversation
-		if err := rows.Scan(&conv.ID, &conv.Text); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-		conversations = append(conversations, conv)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	return conversations, nil
-}
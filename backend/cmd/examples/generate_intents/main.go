@@ -1,16 +1,16 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"time"
 
-	"agenticflows/backend/cmd/examples/client"
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/client"
+	"agenticflows/backend/cmd/examples/store"
 	"agenticflows/backend/cmd/examples/utils"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
@@ -20,6 +20,8 @@ func main() {
 	debugFlag := flag.Bool("debug", false, "Enable debug mode")
 	workflowID := flag.String("workflow", "", "Workflow ID for persisting results")
 	mockFlag := flag.Bool("mock", false, "Use mock data instead of database")
+	chunkSize := flag.Int("chunk", 1000, "Number of conversations sent per request")
+	concurrency := flag.Int("concurrency", 8, "LLM calls the server runs at once for each request")
 	flag.Parse()
 
 	// Validate required flags
@@ -28,6 +30,10 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if *chunkSize < 1 {
+		fmt.Println("Error: --chunk must be at least 1")
+		os.Exit(1)
+	}
 
 	startTime := time.Now()
 
@@ -48,7 +54,7 @@ func main() {
 		conversations = createMockConversations(*limit)
 	} else {
 		fmt.Printf("Fetching %d sample conversations from database...\n", *limit)
-		conversations, err = fetchSampleConversations(*dbPath, *limit)
+		conversations, err = store.SampleConversations(*dbPath, 100, *limit)
 		if err != nil {
 			fmt.Printf("Error fetching conversations: %v\n", err)
 			os.Exit(1)
@@ -57,35 +63,53 @@ func main() {
 
 	fmt.Printf("Found %d conversations\n", len(conversations))
 
-	// Step 2: Generate intents for each conversation
+	// Step 2: Generate intents in bulk mode, sending the conversations in chunks that the
+	// server classifies with several conversations per LLM call and several calls at once
 	fmt.Println("\nGenerating intents for conversations...")
 	results := make([]map[string]interface{}, 0)
+	persisted := 0
 
-	for _, conv := range conversations {
-		fmt.Printf("\nAnalyzing conversation %s...\n", conv.ID)
+	for start := 0; start < len(conversations); start += *chunkSize {
+		chunk := conversations[start:min(start+*chunkSize, len(conversations))]
+		fmt.Printf("\nAnalyzing conversations %d-%d...\n", start+1, start+len(chunk))
+
+		items := make([]interface{}, len(chunk))
+		for i, conv := range chunk {
+			items[i] = map[string]interface{}{"conversation_id": conv.ID, "text": conv.Text}
+		}
 
-		// Use standardized API to generate intent
+		// Use standardized API to generate the intents of the chunk. The server saves them
+		// to the intent history of each conversation, except for mock data.
 		req := client.StandardAnalysisRequest{
 			AnalysisType: "intent",
-			Text:         conv.Text,
-			Parameters:   map[string]interface{}{},
+			Parameters:   map[string]interface{}{"mode": "bulk", "concurrency": *concurrency, "persist": !*mockFlag},
+			Data:         map[string]interface{}{"conversations": items},
 		}
 
-		resp, err := apiClient.PerformAnalysis(req)
+		resp, err := apiClient.PerformAnalysis(context.Background(), req)
 		if err != nil {
-			fmt.Printf("Error generating intent: %v\n", err)
+			fmt.Printf("Error generating intents: %v\n", err)
 			continue
 		}
 
-		// Extract intent from response
-		if intentResults, ok := resp.Results.(map[string]interface{}); ok {
-			result := map[string]interface{}{
-				"conversation_id": conv.ID,
-				"intent":          intentResults["label_name"],
-				"confidence":      resp.Confidence,
-				"explanation":     intentResults["description"],
+		// Extract the intent of each conversation from the response
+		var intents analysis.IntentResult
+		if err := resp.DecodeResults(&intents); err != nil {
+			fmt.Printf("Error decoding intents: %v\n", err)
+			continue
+		}
+		persisted += intents.Persisted
+		for _, intent := range intents.Conversations {
+			if intent.Error != "" {
+				fmt.Printf("Error classifying conversation %s: %s\n", intent.ConversationID, intent.Error)
+				continue
 			}
-			results = append(results, result)
+			results = append(results, map[string]interface{}{
+				"conversation_id": intent.ConversationID,
+				"intent":          intent.LabelName,
+				"confidence":      resp.Confidence,
+				"explanation":     intent.Description,
+			})
 		}
 	}
 
@@ -97,6 +121,9 @@ func main() {
 		fmt.Printf("Confidence: %.2f\n", result["confidence"])
 		fmt.Printf("Explanation: %s\n", result["explanation"])
 	}
+	if persisted > 0 {
+		fmt.Printf("\nSaved %d intents; see GET /api/conversations/{id}/intents and /api/intents/distribution\n", persisted)
+	}
 
 	utils.PrintTimeTaken(startTime, "Generate intents")
 }
@@ -190,44 +217,3 @@ Customer: Thank you so much for your help!`,
 
 	return result
 }
-
-// fetchSampleConversations fetches random sample conversations
-func fetchSampleConversations(dbPath string, limit int) ([]utils.Conversation, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for random conversations
-	query := `
-	SELECT conversation_id, text
-	FROM conversations
-	WHERE text IS NOT NULL AND LENGTH(text) > 100
-	ORDER BY RANDOM()
-	LIMIT ?
-	`
-
-	rows, err := db.Query(query, limit)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
-	}
-	defer rows.Close()
-
-	// Format conversations as objects
-	conversations := make([]utils.Conversation, 0)
-	for rows.Next() {
-		var conv utils.Conversation
-		if err := rows.Scan(&conv.ID, &conv.Text); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-		conversations = append(conversations, conv)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	return conversations, nil
-}
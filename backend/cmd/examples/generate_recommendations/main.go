@@ -1,16 +1,16 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"time"
 
-	"agenticflows/backend/cmd/examples/client"
+	"agenticflows/backend/client"
+	"agenticflows/backend/cmd/examples/store"
 	"agenticflows/backend/cmd/examples/utils"
-
-	_ "github.com/mattn/go-sqlite3"
+	"agenticflows/backend/report"
 )
 
 // Conversation represents a conversation record from the database
@@ -28,6 +28,8 @@ func main() {
 	focusArea := flag.String("focus", "customer_retention", "Focus area for recommendations")
 	debug := flag.Bool("debug", false, "Enable debug mode")
 	workflowID := flag.String("workflow", "", "Workflow ID for persisting results")
+	reportFormat := flag.String("report", "md", "Report format: md, html or pdf")
+	output := flag.String("output", "", "File to write the report to (default standard output)")
 	flag.Parse()
 
 	// Validate required flags
@@ -65,27 +67,17 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Step 4: Print results
-	fmt.Println("\n=== Results ===")
-	fmt.Println("\nImmediate Actions:")
-	for i, action := range recommendations.ImmediateActions {
-		fmt.Printf("%d. %s\n", i+1, action.Action)
-		fmt.Printf("   Rationale: %s\n", action.Rationale)
-		fmt.Printf("   Expected Impact: %s\n", action.ExpectedImpact)
-		fmt.Printf("   Priority: %d\n\n", action.Priority)
-	}
-
-	fmt.Println("\nImplementation Notes:")
-	for _, note := range recommendations.ImplementationNotes {
-		fmt.Printf("- %s\n", note)
+	// Step 4: Report the recommendations
+	doc := report.New("Recommendations: "+*focusArea, *workflowID)
+	if err := doc.AddResult("recommendations", recommendations); err != nil {
+		fmt.Printf("Error building report: %v\n", err)
+		os.Exit(1)
 	}
-
-	fmt.Println("\nSuccess Metrics:")
-	for _, metric := range recommendations.SuccessMetrics {
-		fmt.Printf("- %s\n", metric)
+	fmt.Println()
+	if err := utils.WriteReport(doc, *reportFormat, *output); err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		os.Exit(1)
 	}
-
-	fmt.Println("\nRecommendation Generation complete!")
 }
 
 // TrendResult represents the trend analysis results
@@ -117,50 +109,14 @@ type RecommendationResult struct {
 
 // fetchConversations fetches conversations from the database
 func fetchConversations(dbPath string, limit int) ([]Conversation, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
+	stored, err := store.SampleConversations(dbPath, 200, limit)
 	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
+		return nil, err
 	}
-	defer db.Close()
-
-	// Query for conversations
-	query := `
-	SELECT 
-		conversation_id,
-		text,
-		COALESCE(date_time, CURRENT_TIMESTAMP) as date_time
-	FROM conversations
-	WHERE text IS NOT NULL 
-	AND LENGTH(text) > 200
-	ORDER BY RANDOM()
-	LIMIT ?
-	`
-
-	rows, err := db.Query(query, limit)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
-	}
-	defer rows.Close()
-
-	// Parse results into Conversation objects
-	conversations := make([]Conversation, 0)
-	for rows.Next() {
-		var conv Conversation
-		var createdAtStr string
-		if err := rows.Scan(&conv.ID, &conv.Text, &createdAtStr); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-
-		// Parse created_at timestamp
-		conv.CreatedAt, _ = time.Parse("2006-01-02T15:04:05-07:00", createdAtStr)
-		conversations = append(conversations, conv)
+	conversations := make([]Conversation, 0, len(stored))
+	for _, conv := range stored {
+		conversations = append(conversations, Conversation{ID: conv.ID, Text: conv.Text, CreatedAt: conv.CreatedAt})
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
 	return conversations, nil
 }
 
@@ -199,7 +155,7 @@ func analyzeConversations(apiClient *client.Client, conversations []Conversation
 		},
 	}
 
-	trendResp, err := apiClient.PerformAnalysis(trendReq)
+	trendResp, err := apiClient.PerformAnalysis(context.Background(), trendReq)
 	if err != nil {
 		return emptyTrends, emptyPatterns, fmt.Errorf("error analyzing trends: %w", err)
 	}
@@ -244,7 +200,7 @@ func analyzeConversations(apiClient *client.Client, conversations []Conversation
 		},
 	}
 
-	patternResp, err := apiClient.PerformAnalysis(patternReq)
+	patternResp, err := apiClient.PerformAnalysis(context.Background(), patternReq)
 	if err != nil {
 		return trendResult, emptyPatterns, fmt.Errorf("error identifying patterns: %w", err)
 	}
@@ -307,7 +263,7 @@ func generateRecommendations(apiClient *client.Client, conversations []Conversat
 		Data: analysisData,
 	}
 
-	resp, err := apiClient.PerformAnalysis(req)
+	resp, err := apiClient.PerformAnalysis(context.Background(), req)
 	if err != nil {
 		return emptyResult, fmt.Errorf("error generating recommendations: %w", err)
 	}
@@ -1,19 +1,16 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"regexp"
-	"strings"
 	"time"
 
-	"agenticflows/backend/cmd/examples/client"
+	"agenticflows/backend/client"
+	"agenticflows/backend/cmd/examples/store"
 	"agenticflows/backend/cmd/examples/utils"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // IntentGroup represents a group of similar intents
@@ -24,92 +21,6 @@ type IntentGroup struct {
 	Count       int      `json:"count"`
 }
 
-// Custom handling for potentially truncated JSON responses
-func extractPatternsFromPartialJSON(jsonStr string) ([]map[string]interface{}, error) {
-	// Extract pattern objects from the JSON string
-	var patterns []map[string]interface{}
-
-	// Simple extraction of intent groups - less strict regex to match the pattern structure we're seeing
-	patternTypeRegex := regexp.MustCompile(`"pattern_type":\s*"([^"]+)"`)
-	patternDescRegex := regexp.MustCompile(`"pattern_description":\s*"([^"]+)"`)
-	occurrencesRegex := regexp.MustCompile(`"occurrences":\s*(\d+)`)
-	examplesRegex := regexp.MustCompile(`"examples":\s*\[(.*?)\]`)
-	significanceRegex := regexp.MustCompile(`"significance":\s*"([^"]+)"`)
-
-	// Find all pattern objects by splitting on the pattern delimiter
-	patternDelimiter := "\"pattern_type\":"
-	patternBlocks := strings.Split(jsonStr, patternDelimiter)
-
-	// Skip the first element which is just the JSON opening
-	for i := 1; i < len(patternBlocks); i++ {
-		patternBlock := patternDelimiter + patternBlocks[i]
-
-		// Extract pattern type
-		typeMatch := patternTypeRegex.FindStringSubmatch(patternBlock)
-		if len(typeMatch) < 2 {
-			continue
-		}
-		patternType := typeMatch[1]
-
-		// Extract pattern description
-		descMatch := patternDescRegex.FindStringSubmatch(patternBlock)
-		var patternDescription string
-		if len(descMatch) >= 2 {
-			patternDescription = descMatch[1]
-		} else {
-			patternDescription = "Description not available"
-		}
-
-		// Extract occurrences
-		occMatch := occurrencesRegex.FindStringSubmatch(patternBlock)
-		var occurrences string
-		if len(occMatch) >= 2 {
-			occurrences = occMatch[1]
-		} else {
-			occurrences = "0"
-		}
-
-		// Extract examples
-		examplesMatch := examplesRegex.FindStringSubmatch(patternBlock)
-		var examples []string
-		if len(examplesMatch) >= 2 {
-			examplesStr := examplesMatch[1]
-			exampleMatches := regexp.MustCompile(`"([^"]+)"`).FindAllStringSubmatch(examplesStr, -1)
-			for _, example := range exampleMatches {
-				if len(example) > 1 {
-					examples = append(examples, example[1])
-				}
-			}
-		}
-
-		// Extract significance
-		sigMatch := significanceRegex.FindStringSubmatch(patternBlock)
-		var significance string
-		if len(sigMatch) >= 2 {
-			significance = sigMatch[1]
-		} else {
-			significance = "Significance not available"
-		}
-
-		// Create a pattern object with the extracted data
-		pattern := map[string]interface{}{
-			"pattern_type":        patternType,
-			"pattern_description": patternDescription,
-			"occurrences":         occurrences,
-			"examples":            examples,
-			"significance":        significance,
-		}
-
-		patterns = append(patterns, pattern)
-	}
-
-	if len(patterns) == 0 {
-		return nil, fmt.Errorf("could not extract pattern objects from JSON")
-	}
-
-	return patterns, nil
-}
-
 // Process intents in batches and consolidate results
 func processBatchedIntents(apiClient *client.Client, intentsList []map[string]interface{}, conversations []map[string]interface{}, maxGroups int, minCount int, debugFlag bool) ([]IntentGroup, error) {
 	// Configuration
@@ -153,7 +64,7 @@ func processBatchedIntents(apiClient *client.Client, intentsList []map[string]in
 			},
 		}
 
-		resp, err := apiClient.PerformAnalysis(req)
+		resp, err := apiClient.PerformAnalysis(context.Background(), req)
 		if err != nil {
 			fmt.Printf("Error processing batch %d: %v\n", i+1, err)
 			continue
@@ -230,7 +141,7 @@ func main() {
 
 	// Step 1: Fetch intents from database
 	fmt.Println("Fetching intents from database...")
-	intents, err := fetchIntents(*dbPath, *minCount)
+	intents, err := store.IntentCounts(*dbPath, *minCount)
 	if err != nil {
 		fmt.Printf("Error fetching intents: %v\n", err)
 		os.Exit(1)
@@ -311,46 +222,3 @@ Agent: Thank you for explaining. Let me help you resolve this.
 
 	utils.PrintTimeTaken(startTime, "Group intents")
 }
-
-// fetchIntents fetches intents from the database with their counts
-func fetchIntents(dbPath string, minCount int) (map[string]int, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for intents with their counts
-	query := `
-	SELECT value, COUNT(*) as count
-	FROM conversation_attributes
-	WHERE type = 'intent'
-	GROUP BY value
-	HAVING COUNT(*) >= ?
-	ORDER BY count DESC
-	`
-
-	rows, err := db.Query(query, minCount)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
-	}
-	defer rows.Close()
-
-	// Format intents as map
-	intents := make(map[string]int)
-	for rows.Next() {
-		var intent string
-		var count int
-		if err := rows.Scan(&intent, &count); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-		intents[intent] = count
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	return intents, nil
-}
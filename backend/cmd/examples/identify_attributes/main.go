@@ -1,16 +1,16 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"time"
 
-	"agenticflows/backend/cmd/examples/client"
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/client"
+	"agenticflows/backend/cmd/examples/store"
 	"agenticflows/backend/cmd/examples/utils"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
@@ -49,7 +49,7 @@ func main() {
 
 	// Step 2: Fetch sample conversations from database
 	fmt.Printf("Fetching %d sample conversations...\n", *limit)
-	conversations, err := fetchSampleConversations(*dbPath, *limit)
+	conversations, err := store.SampleConversations(*dbPath, 100, *limit)
 	if err != nil {
 		fmt.Printf("Error fetching conversations: %v\n", err)
 		os.Exit(1)
@@ -57,125 +57,71 @@ func main() {
 
 	fmt.Printf("Found %d conversations\n", len(conversations))
 
-	// Step 3: Identify attributes for each conversation
+	// Step 3: Store the conversations on the server so they can be analyzed by ID
+	stored := make([]client.Conversation, len(conversations))
+	for i, conv := range conversations {
+		stored[i] = client.Conversation{ID: conv.ID, Text: conv.Text}
+	}
+	conversationIDs, err := apiClient.IngestConversations(context.Background(), stored)
+	if err != nil {
+		fmt.Printf("Error storing conversations: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Step 4: Identify attributes in all conversations with a single request.
+	// The server extracts them from each conversation concurrently, using the
+	// definitions kept in its attribute set library.
 	fmt.Println("\nIdentifying attributes in conversations...")
-	results := make([]map[string]interface{}, 0)
-
-	for _, conv := range conversations {
-		fmt.Printf("\nAnalyzing conversation %s...\n", conv.ID)
-
-		// Use standardized API to identify attributes
-		req := client.StandardAnalysisRequest{
-			AnalysisType: "attributes",
-			Text:         conv.Text,
-			Parameters: map[string]interface{}{
-				"questions": questions,
-				"attributes": []map[string]string{
-					{
-						"field_name":  "sentiment",
-						"title":       "Customer Sentiment",
-						"description": "The sentiment expressed by the customer",
-					},
-					{
-						"field_name":  "issue",
-						"title":       "Main Issue",
-						"description": "The primary issue or concern raised by the customer",
-					},
-					{
-						"field_name":  "urgency",
-						"title":       "Request Urgency",
-						"description": "How urgent the customer's request is",
-					},
-					{
-						"field_name":  "product",
-						"title":       "Product/Service",
-						"description": "The specific product or service being discussed",
-					},
-					{
-						"field_name":  "resolution",
-						"title":       "Resolution",
-						"description": "The resolution or solution provided to the customer",
-					},
-				},
-			},
-		}
+	parameters := utils.AttributeParameters(apiClient, utils.ConversationAttributes)
+	parameters["questions"] = questions
+	req := client.StandardAnalysisRequest{
+		AnalysisType:    "attributes",
+		ConversationIDs: conversationIDs,
+		Parameters:      parameters,
+	}
 
-		resp, err := apiClient.PerformAnalysis(req)
-		if err != nil {
-			fmt.Printf("Error identifying attributes: %v\n", err)
-			continue
-		}
+	resp, err := apiClient.PerformAnalysis(context.Background(), req)
+	if err != nil {
+		fmt.Printf("Error identifying attributes: %v\n", err)
+		os.Exit(1)
+	}
 
-		// Extract attributes from response
-		if attrResults, ok := resp.Results.(map[string]interface{}); ok {
-			result := map[string]interface{}{
-				"conversation_id":  conv.ID,
-				"attribute_values": attrResults["attribute_values"],
-				"confidence":       resp.Confidence,
-			}
-			results = append(results, result)
-		}
+	var result analysis.AttributesResult
+	if err := resp.DecodeResults(&result); err != nil {
+		fmt.Printf("Error decoding attributes: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Print results
 	fmt.Println("\n=== Identified Attributes ===")
-	for _, result := range results {
-		fmt.Printf("\nConversation ID: %s\n", result["conversation_id"])
-		fmt.Printf("Confidence: %.2f\n", result["confidence"])
-		if attrs, ok := result["attribute_values"].([]interface{}); ok {
-			for _, attr := range attrs {
-				if attrMap, ok := attr.(map[string]interface{}); ok {
-					fmt.Printf("\n  Field: %s\n", utils.GetString(attrMap, "field_name"))
-					fmt.Printf("  Value: %s\n", utils.GetString(attrMap, "value"))
-					fmt.Printf("  Confidence: %.2f\n", utils.GetFloat64(attrMap, "confidence"))
-					if explanation := utils.GetString(attrMap, "explanation"); explanation != "" {
-						fmt.Printf("  Explanation: %s\n", explanation)
-					}
-				}
+	for _, conv := range result.Conversations {
+		fmt.Printf("\nConversation ID: %s\n", conv.ConversationID)
+		if conv.Error != "" {
+			fmt.Printf("  Error: %s\n", conv.Error)
+			continue
+		}
+		for _, attr := range conv.AttributeValues {
+			fmt.Printf("\n  Field: %s\n", attr.FieldName)
+			fmt.Printf("  Value: %s\n", attr.Value)
+			fmt.Printf("  Confidence: %.2f\n", attr.Confidence)
+			if attr.Explanation != "" {
+				fmt.Printf("  Explanation: %s\n", attr.Explanation)
 			}
 		}
 	}
 
-	utils.PrintTimeTaken(startTime, "Identify attributes")
-}
-
-// fetchSampleConversations fetches random sample conversations
-func fetchSampleConversations(dbPath string, limit int) ([]utils.Conversation, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for random conversations
-	query := `
-	SELECT conversation_id, text
-	FROM conversations
-	WHERE text IS NOT NULL AND LENGTH(text) > 100
-	ORDER BY RANDOM()
-	LIMIT ?
-	`
-
-	rows, err := db.Query(query, limit)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
+	fmt.Println("\n=== Attribute Statistics ===")
+	fmt.Printf("Confidence: %.2f\n", resp.Confidence)
+	if result.FailedConversations > 0 {
+		fmt.Printf("Failed conversations: %d\n", result.FailedConversations)
 	}
-	defer rows.Close()
-
-	// Format conversations as objects
-	conversations := make([]utils.Conversation, 0)
-	for rows.Next() {
-		var conv utils.Conversation
-		if err := rows.Scan(&conv.ID, &conv.Text); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
+	for _, stats := range result.Statistics {
+		fmt.Printf("\n%s: extracted from %d conversations, %d distinct values, average confidence %.2f\n",
+			stats.FieldName, stats.Extracted, stats.DistinctValues, stats.AverageConfidence)
+		for _, value := range stats.TopValues {
+			fmt.Printf("  %s (%d)\n", value.Value, value.Count)
 		}
-		conversations = append(conversations, conv)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	return conversations, nil
+	utils.PrintTimeTaken(startTime, "Identify attributes")
 }
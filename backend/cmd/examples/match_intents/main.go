@@ -1,17 +1,16 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
-	"agenticflows/backend/cmd/examples/client"
+	"agenticflows/backend/client"
+	"agenticflows/backend/cmd/examples/store"
 	"agenticflows/backend/cmd/examples/utils"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
@@ -47,7 +46,7 @@ func main() {
 
 	// Step 2: Fetch sample conversations from database
 	fmt.Printf("Fetching %d sample conversations...\n", *limit)
-	conversations, err := fetchSampleConversations(*dbPath, *limit)
+	conversations, err := store.SampleConversations(*dbPath, 100, *limit)
 	if err != nil {
 		fmt.Printf("Error fetching conversations: %v\n", err)
 		os.Exit(1)
@@ -72,7 +71,7 @@ func main() {
 			},
 		}
 
-		resp, err := apiClient.PerformAnalysis(req)
+		resp, err := apiClient.PerformAnalysis(context.Background(), req)
 		if err != nil {
 			fmt.Printf("Error matching intents: %v\n", err)
 			continue
@@ -103,44 +102,3 @@ func main() {
 
 	utils.PrintTimeTaken(startTime, "Match intents")
 }
-
-// fetchSampleConversations fetches random sample conversations
-func fetchSampleConversations(dbPath string, limit int) ([]utils.Conversation, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %w", err)
-	}
-	defer db.Close()
-
-	// Query for random conversations
-	query := `
-	SELECT conversation_id, text
-	FROM conversations
-	WHERE text IS NOT NULL AND LENGTH(text) > 100
-	ORDER BY RANDOM()
-	LIMIT ?
-	`
-
-	rows, err := db.Query(query, limit)
-	if err != nil {
-		return nil, fmt.Errorf("error querying database: %w", err)
-	}
-	defer rows.Close()
-
-	// Format conversations as objects
-	conversations := make([]utils.Conversation, 0)
-	for rows.Next() {
-		var conv utils.Conversation
-		if err := rows.Scan(&conv.ID, &conv.Text); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-		conversations = append(conversations, conv)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-
-	return conversations, nil
-}
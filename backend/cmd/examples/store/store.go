@@ -0,0 +1,241 @@
+// Package store reads the conversation databases of the example scripts. Every database
+// is opened once per process through a shared, read-only connection pool, and the
+// statements the scripts repeat are prepared once.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"agenticflows/backend/cmd/examples/utils"
+	"agenticflows/backend/db"
+)
+
+// dateTimeLayout is how the conversation databases store date_time
+const dateTimeLayout = "2006-01-02T15:04:05-07:00"
+
+// Attribute is an attribute found in conversations, with a sample value
+type Attribute struct {
+	Name        string
+	Type        string
+	Value       string
+	Description string
+	Count       int
+}
+
+// open returns the shared connection pool of a database
+func open(dbPath string) (*db.Manager, error) {
+	if dbPath == "" {
+		return nil, fmt.Errorf("no database path given")
+	}
+	manager, err := db.SharedReader(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	return manager, nil
+}
+
+// SampleConversations returns random conversations whose text is longer than minLength
+func SampleConversations(dbPath string, minLength, limit int) ([]utils.Conversation, error) {
+	manager, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := manager.Query(`
+	SELECT conversation_id, text, COALESCE(date_time, '') AS date_time
+	FROM conversations
+	WHERE text IS NOT NULL AND LENGTH(text) > ?
+	ORDER BY RANDOM()
+	LIMIT ?
+	`, minLength, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	return scanConversations(rows)
+}
+
+// ConversationsByID returns the conversations with the given IDs that have text
+func ConversationsByID(dbPath string, ids []string) ([]utils.Conversation, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	manager, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := manager.Query(`
+	SELECT conversation_id, text, COALESCE(date_time, '') AS date_time
+	FROM conversations
+	WHERE text IS NOT NULL AND conversation_id IN (`+placeholders(len(ids))+`)
+	`, stringArgs(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	return scanConversations(rows)
+}
+
+// ConversationsByIntents returns random conversations classified with one of the
+// intents whose text is longer than minLength
+func ConversationsByIntents(dbPath string, intents []string, minLength, limit int) ([]utils.Conversation, error) {
+	if len(intents) == 0 {
+		return nil, nil
+	}
+	manager, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	args := append(stringArgs(intents), minLength, limit)
+	rows, err := manager.Query(`
+	SELECT c.conversation_id, c.text, COALESCE(c.date_time, '') AS date_time
+	FROM conversations c
+	JOIN conversation_attributes ca ON c.conversation_id = ca.conversation_id
+	WHERE ca.type = 'intent' AND ca.value IN (`+placeholders(len(intents))+`)
+	AND c.text IS NOT NULL AND LENGTH(c.text) > ?
+	ORDER BY RANDOM()
+	LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	return scanConversations(rows)
+}
+
+// IntentCounts returns the intents classified at least minCount times with their counts
+func IntentCounts(dbPath string, minCount int) (map[string]int, error) {
+	manager, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := manager.Query(`
+	SELECT value, COUNT(*) AS count
+	FROM conversation_attributes
+	WHERE type = 'intent'
+	GROUP BY value
+	HAVING COUNT(*) >= ?
+	`, minCount)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	intents := make(map[string]int)
+	for rows.Next() {
+		var intent string
+		var count int
+		if err := rows.Scan(&intent, &count); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		intents[intent] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return intents, nil
+}
+
+// IntentsContaining returns the intents classified at least minCount times whose text
+// contains a phrase, ignoring case
+func IntentsContaining(dbPath, phrase string, minCount int) ([]string, error) {
+	manager, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := manager.Query(`
+	SELECT value
+	FROM conversation_attributes
+	WHERE type = 'intent' AND lower(value) LIKE ?
+	GROUP BY value
+	HAVING COUNT(*) >= ?
+	`, "%"+strings.ToLower(phrase)+"%", minCount)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	intents := make([]string, 0)
+	for rows.Next() {
+		var intent string
+		if err := rows.Scan(&intent); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		intents = append(intents, intent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return intents, nil
+}
+
+// Attributes returns the attributes found at least minCount times, each with a sample
+// value and description
+func Attributes(dbPath string, minCount int) ([]Attribute, error) {
+	manager, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := manager.Query(`
+	SELECT a.name, a.type, COUNT(*) AS count,
+		(SELECT value FROM conversation_attributes s WHERE s.name = a.name AND s.type = 'attribute' LIMIT 1),
+		(SELECT description FROM conversation_attributes s WHERE s.name = a.name AND s.type = 'attribute' LIMIT 1)
+	FROM conversation_attributes a
+	WHERE a.type = 'attribute'
+	GROUP BY a.name
+	HAVING COUNT(*) >= ?
+	`, minCount)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	attributes := make([]Attribute, 0)
+	for rows.Next() {
+		var attr Attribute
+		var value, description sql.NullString
+		if err := rows.Scan(&attr.Name, &attr.Type, &attr.Count, &value, &description); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		attr.Value = value.String
+		attr.Description = description.String
+		attributes = append(attributes, attr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return attributes, nil
+}
+
+// scanConversations reads and closes rows of conversation_id, text and date_time
+func scanConversations(rows *sql.Rows) ([]utils.Conversation, error) {
+	defer rows.Close()
+
+	conversations := make([]utils.Conversation, 0)
+	for rows.Next() {
+		var conv utils.Conversation
+		var dateTime string
+		if err := rows.Scan(&conv.ID, &conv.Text, &dateTime); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		conv.CreatedAt, _ = time.Parse(dateTimeLayout, dateTime)
+		conversations = append(conversations, conv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return conversations, nil
+}
+
+// placeholders returns n comma-separated parameter placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// stringArgs converts strings into statement arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
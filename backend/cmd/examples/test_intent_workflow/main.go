@@ -1,12 +1,13 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"time"
+
+	"agenticflows/backend/client"
 )
 
 // Sample intents for testing
@@ -19,27 +20,34 @@ var sampleIntents = []string{
 }
 
 func main() {
+	ctx := context.Background()
+	apiClient := client.NewClient("http://localhost:8080", "", false)
+
 	// Create a workflow with an intent generation node
-	workflow := createIntentWorkflow()
+	workflow, err := createIntentWorkflow()
+	if err != nil {
+		log.Fatalf("Failed to build workflow: %v", err)
+	}
 
 	// Create the workflow via API
-	workflowID, err := createWorkflow(workflow)
+	created, err := apiClient.CreateWorkflow(ctx, workflow)
 	if err != nil {
 		log.Fatalf("Failed to create workflow: %v", err)
 	}
+	workflowID := created.ID
 	log.Printf("Created workflow with ID: %s", workflowID)
 
 	// Prepare input data for workflow execution
-	inputData := map[string]interface{}{
-		"text": sampleIntents[0], // Start with first intent
-		"parameters": map[string]interface{}{
+	inputData := client.WorkflowRunRequest{
+		Text: sampleIntents[0], // Start with first intent
+		Parameters: map[string]interface{}{
 			"intent_types":         []string{"primary", "secondary"},
 			"confidence_threshold": 0.7,
 		},
 	}
 
 	// Execute the workflow
-	results, err := executeWorkflow(workflowID, inputData)
+	results, err := apiClient.ExecuteWorkflow(ctx, workflowID, inputData)
 	if err != nil {
 		log.Fatalf("Failed to execute workflow: %v", err)
 	}
@@ -60,10 +68,10 @@ func main() {
 		fmt.Printf("\nProcessing intent: %s\n", intent)
 
 		// Update input data with new intent
-		inputData["text"] = intent
+		inputData.Text = intent
 
 		// Execute workflow again
-		results, err := executeWorkflow(workflowID, inputData)
+		results, err := apiClient.ExecuteWorkflow(ctx, workflowID, inputData)
 		if err != nil {
 			log.Printf("Failed to execute workflow for intent '%s': %v", intent, err)
 			continue
@@ -76,111 +84,41 @@ func main() {
 	}
 }
 
-func createIntentWorkflow() map[string]interface{} {
+func createIntentWorkflow() (client.Workflow, error) {
 	// Create a workflow with a single intent generation node
-	return map[string]interface{}{
-		"id":   fmt.Sprintf("intent-workflow-%d", time.Now().Unix()),
-		"name": "Intent Generation Workflow",
-		"nodes": []map[string]interface{}{
-			{
-				"id":   "intent-node-1",
-				"type": "default",
-				"position": map[string]interface{}{
-					"x": 250,
-					"y": 100,
-				},
-				"data": map[string]interface{}{
-					"label":      "Generate Intent",
-					"nodeType":   "function",
-					"functionId": "analysis-intent",
-				},
-				"style": map[string]interface{}{
-					"background":   "rgba(16, 185, 129, 0.1)",
-					"borderColor":  "#10B981",
-					"borderWidth":  "2px",
-					"padding":      "10px",
-					"borderRadius": "8px",
-					"color":        "#065F46",
-					"fontWeight":   500,
-				},
-				"sourcePosition": "right",
-				"targetPosition": "left",
+	nodes, err := json.Marshal([]map[string]interface{}{
+		{
+			"id":   "intent-node-1",
+			"type": "default",
+			"position": map[string]interface{}{
+				"x": 250,
+				"y": 100,
+			},
+			"data": map[string]interface{}{
+				"label":      "Generate Intent",
+				"nodeType":   "function",
+				"functionId": "analysis-intent",
 			},
+			"style": map[string]interface{}{
+				"background":   "rgba(16, 185, 129, 0.1)",
+				"borderColor":  "#10B981",
+				"borderWidth":  "2px",
+				"padding":      "10px",
+				"borderRadius": "8px",
+				"color":        "#065F46",
+				"fontWeight":   500,
+			},
+			"sourcePosition": "right",
+			"targetPosition": "left",
 		},
-		"edges": []map[string]interface{}{}, // No edges needed for single node
-	}
-}
-
-func createWorkflow(workflow map[string]interface{}) (string, error) {
-	// Convert workflow to JSON
-	jsonData, err := json.Marshal(workflow)
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal workflow: %v", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", "http://localhost:8080/api/workflows", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response
-	if resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	// Parse response
-	var result struct {
-		ID string `json:"id"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	return result.ID, nil
-}
-
-func executeWorkflow(workflowID string, inputData map[string]interface{}) (map[string]interface{}, error) {
-	// Convert input data to JSON
-	jsonData, err := json.Marshal(inputData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal input data: %v", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", fmt.Sprintf("http://localhost:8080/api/workflows/%s/execute", workflowID), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	// Parse response
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	return result, nil
+		return client.Workflow{}, err
+	}
+	return client.Workflow{
+		ID:    fmt.Sprintf("intent-workflow-%d", time.Now().Unix()),
+		Name:  "Intent Generation Workflow",
+		Nodes: nodes,
+		Edges: json.RawMessage("[]"), // No edges needed for single node
+	}, nil
 }
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/client"
+)
+
+// ConversationAttributes are the attributes identify_attributes extracts from each
+// conversation
+var ConversationAttributes = client.AttributeSetRequest{
+	Name:        "conversation-basics",
+	Description: "Sentiment, issue, urgency, product and resolution of a customer conversation",
+	Attributes: []models.AttributeDefinition{
+		{
+			FieldName:   "sentiment",
+			Title:       "Customer Sentiment",
+			Description: "The sentiment expressed by the customer",
+			EnumValues:  []string{"positive", "neutral", "negative"},
+		},
+		{
+			FieldName:   "issue",
+			Title:       "Main Issue",
+			Description: "The primary issue or concern raised by the customer",
+		},
+		{
+			FieldName:   "urgency",
+			Title:       "Request Urgency",
+			Description: "How urgent the customer's request is",
+			EnumValues:  []string{"low", "medium", "high"},
+		},
+		{
+			FieldName:   "product",
+			Title:       "Product/Service",
+			Description: "The specific product or service being discussed",
+		},
+		{
+			FieldName:   "resolution",
+			Title:       "Resolution",
+			Description: "The resolution or solution provided to the customer",
+		},
+	},
+}
+
+// FeeDisputeAmount is the attribute analyze_fee_disputes extracts from each dispute
+var FeeDisputeAmount = client.AttributeSetRequest{
+	Name:        "fee-dispute-amount",
+	Description: "The amount of money a customer disputes",
+	Attributes: []models.AttributeDefinition{
+		{
+			FieldName:   "amount",
+			Title:       "Disputed Amount",
+			Description: "The amount of money being disputed",
+			Type:        models.AttributeTypeNumber,
+		},
+	},
+}
+
+// AttributeParameters returns the parameters of an attributes analysis that references the
+// set in the server's attribute set library, storing it there first if needed. When the
+// set can't be stored, the definitions are sent inline.
+func AttributeParameters(apiClient *client.Client, set client.AttributeSetRequest) map[string]interface{} {
+	id, err := apiClient.EnsureAttributeSet(context.Background(), set)
+	if err != nil {
+		fmt.Printf("Warning: could not store attribute set %s, sending definitions inline: %v\n", set.Name, err)
+		return map[string]interface{}{"attributes": set.Attributes}
+	}
+	return map[string]interface{}{"attribute_set_id": id}
+}
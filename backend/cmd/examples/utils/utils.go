@@ -2,13 +2,17 @@ package utils
 
 import (
 	"fmt"
+	"os"
 	"time"
+
+	"agenticflows/backend/report"
 )
 
 // Conversation represents a conversation record from the database
 type Conversation struct {
-	ID   string
-	Text string
+	ID        string
+	Text      string
+	CreatedAt time.Time
 }
 
 // GetString safely extracts a string value from a map[string]interface{}
@@ -81,3 +85,27 @@ func GetMapArray(m map[string]interface{}, key string) []map[string]interface{}
 	}
 	return nil
 }
+
+// WriteReport renders a report in a format, md, html or pdf, to a file, or to standard
+// output when path is empty
+func WriteReport(doc *report.Report, format, path string) error {
+	if !report.ValidFormat(format) {
+		return fmt.Errorf("report format must be md, html or pdf, not %q", format)
+	}
+	if path == "" {
+		return report.Write(os.Stdout, format, doc)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(file, format, doc); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Report written to %s\n", path)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+
+	"agenticflows/backend/openapi"
+)
+
+func main() {
+	// Command line flags
+	serverFlag := flag.String("server", "http://localhost:8080", "Server URL written into the document")
+	outFlag := flag.String("out", "", "File to write the document to (default stdout)")
+	checkFlag := flag.Bool("check", false, "Check that the document covers every route of the server instead of printing it")
+	routesFlag := flag.String("routes", "api/main.go", "Go file that registers the server's routes, for -check")
+	flag.Parse()
+
+	if *checkFlag {
+		os.Exit(check(*routesFlag))
+	}
+
+	doc, err := openapi.JSON(*serverFlag)
+	if err != nil {
+		fmt.Printf("Error generating document: %v\n", err)
+		os.Exit(1)
+	}
+	if *outFlag == "" {
+		fmt.Println(string(doc))
+		return
+	}
+	if err := os.WriteFile(*outFlag, append(doc, '\n'), 0644); err != nil {
+		fmt.Printf("Error writing document: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// check compares the document with the routes registered in a Go file and returns the
+// exit status: 0 when they match, 1 otherwise
+func check(routesFile string) int {
+	patterns, err := routePatterns(routesFile)
+	if err != nil {
+		fmt.Printf("Error reading routes: %v\n", err)
+		return 1
+	}
+
+	undocumented, unrouted := openapi.Check(patterns)
+	for _, pattern := range undocumented {
+		fmt.Printf("route %s is not documented\n", pattern)
+	}
+	for _, path := range unrouted {
+		fmt.Printf("documented path %s is not routed\n", path)
+	}
+	if len(undocumented) > 0 || len(unrouted) > 0 {
+		return 1
+	}
+	fmt.Printf("OK: %d routes, %d documented operations\n", len(patterns), len(openapi.Operations))
+	return 0
+}
+
+// routePatterns returns the patterns of the http.HandleFunc and http.Handle calls in a Go
+// file that register a route with a string literal
+func routePatterns(file string) ([]string, error) {
+	parsed, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	ast.Inspect(parsed, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "HandleFunc" && sel.Sel.Name != "Handle") {
+			return true
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "http" {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		if pattern, err := strconv.Unquote(lit.Value); err == nil {
+			patterns = append(patterns, pattern)
+		}
+		return true
+	})
+	return patterns, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// checkpointHeader starts a checkpoint file, followed by the fingerprint of the settings
+// it was written with
+const checkpointHeader = "# precompute "
+
+// checkpoint records the conversations whose results are saved, one ID per line, so a
+// run that stops resumes after them
+type checkpoint struct {
+	file *os.File
+	done map[string]bool
+}
+
+// openCheckpoint opens the checkpoint at path, reading the conversations it records as
+// done. A checkpoint written with other settings can't be resumed; restart starts over.
+func openCheckpoint(path, fingerprint string, restart bool) (*checkpoint, error) {
+	c := &checkpoint{done: map[string]bool{}}
+	if !restart {
+		content, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if len(content) > 0 {
+			lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+			if lines[0] != checkpointHeader+fingerprint {
+				return nil, fmt.Errorf("%s was written with other settings; run with -restart to start over", path)
+			}
+			for _, id := range lines[1:] {
+				if id != "" {
+					c.done[id] = true
+				}
+			}
+		}
+	}
+
+	var err error
+	if len(c.done) > 0 {
+		c.file, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	} else {
+		c.file, err = os.Create(path)
+		if err == nil {
+			_, err = fmt.Fprintln(c.file, checkpointHeader+fingerprint)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Done reports whether the results of a conversation were saved
+func (c *checkpoint) Done(id string) bool {
+	return c.done[id]
+}
+
+// Len returns how many conversations are done
+func (c *checkpoint) Len() int {
+	return len(c.done)
+}
+
+// Add records that the results of a conversation are saved
+func (c *checkpoint) Add(id string) error {
+	if _, err := fmt.Fprintln(c.file, id); err != nil {
+		return err
+	}
+	c.done[id] = true
+	return c.file.Sync()
+}
+
+// Close closes the checkpoint file
+func (c *checkpoint) Close() error {
+	return c.file.Close()
+}
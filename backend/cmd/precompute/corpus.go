@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"agenticflows/backend/db"
+)
+
+// ingestCorpus stores the conversations of a corpus file that the checkpoint doesn't
+// record as done for a tenant, and returns their IDs in corpus order. The file holds a
+// JSON array of conversations or one conversation per line, each with a conversation_id
+// and text.
+func ingestCorpus(tenantID, path, source string, checkpoint *checkpoint) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	conversations, err := parseCorpus(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var ids []string
+	var pending []db.Conversation
+	seen := make(map[string]bool, len(conversations))
+	for i, conversation := range conversations {
+		conversation.ID = strings.TrimSpace(conversation.ID)
+		if conversation.ID == "" || strings.TrimSpace(conversation.Text) == "" {
+			return nil, fmt.Errorf("%s: conversation %d needs a conversation_id and text", path, i+1)
+		}
+		if seen[conversation.ID] {
+			return nil, fmt.Errorf("%s: conversation %s is given more than once", path, conversation.ID)
+		}
+		seen[conversation.ID] = true
+		if checkpoint.Done(conversation.ID) {
+			continue
+		}
+		if conversation.Source == "" {
+			conversation.Source = source
+		}
+		ids = append(ids, conversation.ID)
+		pending = append(pending, conversation)
+	}
+
+	for start := 0; start < len(pending); start += ingestBatchSize {
+		batch := pending[start:min(start+ingestBatchSize, len(pending))]
+		if _, _, err := db.IngestConversations(tenantID, batch); err != nil {
+			return nil, fmt.Errorf("failed to store conversations: %w", err)
+		}
+	}
+	return ids, nil
+}
+
+// parseCorpus decodes a JSON array of conversations, or JSON lines of them
+func parseCorpus(content []byte) ([]db.Conversation, error) {
+	content = bytes.TrimSpace(content)
+	var conversations []db.Conversation
+	if bytes.HasPrefix(content, []byte("[")) {
+		if err := json.Unmarshal(content, &conversations); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return conversations, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var conversation db.Conversation
+		if err := json.Unmarshal(text, &conversation); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", line, err)
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, scanner.Err()
+}
+
+// storedConversationIDs returns the IDs of the stored conversations of a tenant, of one
+// source if it is set, that the checkpoint doesn't record as done, most recent first
+func storedConversationIDs(tenantID, source string, checkpoint *checkpoint) ([]string, error) {
+	const pageSize = 1000
+	var ids []string
+	for offset := 0; ; offset += pageSize {
+		page, total, err := db.ListConversations(db.ConversationFilter{TenantID: tenantID, Source: source, Limit: pageSize, Offset: offset})
+		if err != nil {
+			return nil, err
+		}
+		for _, conversation := range page {
+			if !checkpoint.Done(conversation.ID) {
+				ids = append(ids, conversation.ID)
+			}
+		}
+		if len(page) < pageSize || offset+len(page) >= total {
+			return ids, nil
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/analysis/transcript"
+	"agenticflows/backend/analysis/validation"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/prompts"
+	"agenticflows/backend/translate"
+)
+
+// defaultCacheTTL is how long warmed replies are kept when LLM_RESPONSE_CACHE_TTL is unset
+const defaultCacheTTL = 7 * 24 * time.Hour
+
+// revisionReason is recorded with attribute values that change from earlier extractions
+const revisionReason = "precompute"
+
+// ingestBatchSize is how many conversations of the corpus are stored per transaction
+const ingestBatchSize = 500
+
+// outcome is what was precomputed for one conversation
+type outcome struct {
+	id     string
+	intent *models.IntentClassification
+	values []models.AttributeValue
+	err    error
+}
+
+func main() {
+	// Command line flags
+	inputFlag := flag.String("input", "", "Corpus of conversations, as a JSON array or JSON lines (default: the stored conversations)")
+	tenantFlag := flag.String("tenant", auth.DefaultTenant, "Tenant whose conversations and attribute sets are read and stored")
+	sourceFlag := flag.String("source", "", "Source of the stored conversations to precompute, or recorded with input conversations that name none")
+	attributesFlag := flag.String("attributes", "", "JSON file of the attribute definitions to extract")
+	attributeSetFlag := flag.String("attribute-set", "", "ID of a stored attribute set to extract, at its latest version")
+	intentsFlag := flag.Bool("intents", true, "Classify the intent of each conversation")
+	workersFlag := flag.Int("workers", 8, "Conversations analyzed at once")
+	workflowFlag := flag.String("workflow", "", "Workflow ID recorded with the intents and attribute values")
+	languageFlag := flag.String("language", "", "Language code of the conversations, as interactive requests give it")
+	localeFlag := flag.String("locale", validation.DefaultLocale, "Locale attribute values are read in")
+	checkpointFlag := flag.String("checkpoint", "precompute.checkpoint", "File recording the conversations done, so an interrupted run resumes")
+	restartFlag := flag.Bool("restart", false, "Start over, ignoring the checkpoint")
+	cacheTTLFlag := flag.Duration("cache-ttl", 0, "How long warmed LLM replies are kept (default LLM_RESPONSE_CACHE_TTL, or 168h)")
+	mockFlag := flag.Bool("mock", false, "Answer with the mock LLM provider; nothing is cached")
+	flag.Parse()
+
+	if *workersFlag < 1 {
+		fmt.Println("Error: -workers must be at least 1")
+		os.Exit(1)
+	}
+	if !auth.ValidTenantID(*tenantFlag) {
+		fmt.Printf("Error: invalid tenant %q\n", *tenantFlag)
+		os.Exit(1)
+	}
+	if *attributesFlag != "" && *attributeSetFlag != "" {
+		fmt.Println("Error: -attributes and -attribute-set cannot be used together")
+		os.Exit(1)
+	}
+	locale, err := validation.ParseLocale(*localeFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := db.Initialize(); err != nil {
+		fmt.Printf("Error initializing database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if *workflowFlag != "" {
+		exists, err := db.WorkflowExists(*tenantFlag, *workflowFlag)
+		if err != nil {
+			fmt.Printf("Error getting workflow: %v\n", err)
+			os.Exit(1)
+		}
+		if !exists {
+			fmt.Printf("Error: workflow %s not found for tenant %s\n", *workflowFlag, *tenantFlag)
+			os.Exit(1)
+		}
+	}
+
+	attributes, err := loadAttributes(*tenantFlag, *attributesFlag, *attributeSetFlag)
+	if err != nil {
+		fmt.Printf("Error loading attributes: %v\n", err)
+		os.Exit(1)
+	}
+	if len(attributes) == 0 && !*intentsFlag {
+		fmt.Println("Error: nothing to precompute; give -attributes or -attribute-set, or keep -intents")
+		os.Exit(1)
+	}
+
+	// The mock provider needs no API key
+	if *mockFlag {
+		os.Setenv(core.EnvLLMProvider, core.ProviderMock)
+	}
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" && !core.GatewayConfigured() && !core.MockConfigured() {
+		fmt.Println("GEMINI_API_KEY, LLM_BASE_URL or LLM_PROVIDER=mock environment variable is required, or run with -mock")
+		os.Exit(1)
+	}
+	facade, err := analysis.NewAnalysisFacade(apiKey, false)
+	if err != nil {
+		fmt.Printf("Error creating analysis facade: %v\n", err)
+		os.Exit(1)
+	}
+
+	ttl := *cacheTTLFlag
+	if ttl == 0 {
+		if ttl, err = db.LLMResponseCacheTTL(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if ttl == 0 {
+			ttl = defaultCacheTTL
+		}
+	}
+	core.SetResponseCache(db.LLMResponseCache{TTL: ttl})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// Prompts are built as interactive requests without parameters build them, so their
+	// replies are the ones those requests look up
+	templates, err := prompts.ForRequest(*tenantFlag, "")
+	if err != nil {
+		fmt.Printf("Error loading prompt templates: %v\n", err)
+		os.Exit(1)
+	}
+	if ctx, err = core.WithPromptTemplates(ctx, templates, nil); err != nil {
+		fmt.Printf("Error applying prompt templates: %v\n", err)
+		os.Exit(1)
+	}
+	ctx = core.WithConversationLanguage(ctx, promptLanguage(*languageFlag))
+	ctx = auth.WithTenant(ctx, *tenantFlag)
+
+	fingerprint, err := settingsFingerprint(*tenantFlag, attributes, *intentsFlag, *workflowFlag, *languageFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	checkpoint, err := openCheckpoint(*checkpointFlag, fingerprint, *restartFlag)
+	if err != nil {
+		fmt.Printf("Error opening checkpoint: %v\n", err)
+		os.Exit(1)
+	}
+	defer checkpoint.Close()
+	if n := checkpoint.Len(); n > 0 {
+		fmt.Printf("Resuming: %d conversations were precomputed before\n", n)
+	}
+
+	var ids []string
+	if *inputFlag != "" {
+		ids, err = ingestCorpus(*tenantFlag, *inputFlag, *sourceFlag, checkpoint)
+	} else {
+		ids, err = storedConversationIDs(*tenantFlag, *sourceFlag, checkpoint)
+	}
+	if err != nil {
+		fmt.Printf("Error reading conversations: %v\n", err)
+		os.Exit(1)
+	}
+	if len(ids) == 0 {
+		fmt.Println("Every conversation is precomputed")
+		return
+	}
+
+	started := time.Now()
+	jobs := make(chan string)
+	outcomes := make(chan outcome)
+	var wg sync.WaitGroup
+	for w := 0; w < *workersFlag; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				outcomes <- precompute(ctx, facade, id, *intentsFlag, attributes, locale)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, id := range ids {
+			select {
+			case jobs <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Results are saved one conversation at a time, so the checkpoint never records a
+	// conversation whose results are lost
+	done, failed := 0, 0
+	for result := range outcomes {
+		if result.err != nil {
+			failed++
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "\nConversation %s failed: %v\n", result.id, result.err)
+			}
+		} else if err := save(result, *tenantFlag, *workflowFlag, attributes); err != nil {
+			fmt.Printf("\nError saving conversation %s: %v\n", result.id, err)
+			os.Exit(1)
+		} else if err := checkpoint.Add(result.id); err != nil {
+			fmt.Printf("\nError writing checkpoint: %v\n", err)
+			os.Exit(1)
+		} else {
+			done++
+		}
+		fmt.Fprintf(os.Stderr, "\r%d/%d conversations precomputed, %d failed", done, len(ids), failed)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted; run again with the same flags to resume")
+		os.Exit(1)
+	}
+	fmt.Printf("Precomputed %d conversations in %s; replies are cached for %s\n", done, time.Since(started).Round(time.Second), ttl)
+	if failed > 0 {
+		fmt.Printf("%d conversations failed; run again to retry them\n", failed)
+		os.Exit(1)
+	}
+}
+
+// precompute classifies the intent of a stored conversation of the tenant of ctx and
+// extracts attributes from it, as interactive requests over its ID do
+func precompute(ctx context.Context, facade *analysis.AnalysisFacade, id string, intents bool, attributes []models.AttributeDefinition, locale validation.Locale) outcome {
+	result := outcome{id: id}
+	if result.err = ctx.Err(); result.err != nil {
+		return result
+	}
+	stored, err := db.GetConversations(auth.TenantID(ctx), []string{id})
+	if err != nil || len(stored) == 0 {
+		result.err = fmt.Errorf("failed to load conversation: %v", err)
+		return result
+	}
+	text := stored[0].Text
+	if text == "" {
+		result.err = fmt.Errorf("conversation has no text")
+		return result
+	}
+
+	if intents {
+		if result.intent, result.err = facade.GenerateIntent(ctx, text); result.err != nil {
+			return result
+		}
+	}
+	if len(attributes) > 0 {
+		if result.values, result.err = facade.GenerateAttributes(ctx, text, attributes); result.err != nil {
+			return result
+		}
+		byField := make(map[string]models.AttributeDefinition, len(attributes))
+		for _, attribute := range attributes {
+			byField[attribute.FieldName] = attribute
+		}
+		for i := range result.values {
+			validation.Coerce(byField[result.values[i].FieldName], &result.values[i], locale)
+		}
+	}
+	return result
+}
+
+// save stores the intent and attribute values precomputed for a conversation. Values out
+// of their attribute's enum values or not of its type are left out, as interactive
+// extraction leaves them out.
+func save(result outcome, tenantID, workflowID string, attributes []models.AttributeDefinition) error {
+	if result.intent != nil {
+		_, err := db.SaveIntentClassifications(tenantID, []db.IntentClassification{{
+			ConversationID: result.id,
+			WorkflowID:     workflowID,
+			Label:          result.intent.Label,
+			LabelName:      result.intent.LabelName,
+			Description:    result.intent.Description,
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	if len(result.values) == 0 {
+		return nil
+	}
+
+	descriptions := make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		descriptions[attribute.FieldName] = attribute.Description
+	}
+	rows := make([]db.ConversationAttribute, 0, len(result.values))
+	for _, value := range result.values {
+		if value.OutOfEnum || value.TypeError != "" {
+			continue
+		}
+		rows = append(rows, db.ConversationAttribute{
+			ConversationID: result.id,
+			Type:           db.ConversationAttributeTypeAttribute,
+			Name:           value.FieldName,
+			Value:          value.Value,
+			Description:    descriptions[value.FieldName],
+			Confidence:     value.Confidence,
+			Explanation:    value.Explanation,
+			WorkflowID:     workflowID,
+		})
+	}
+	_, err := db.SaveConversationAttributes(rows, revisionReason)
+	return err
+}
+
+// loadAttributes reads attribute definitions from a JSON file or a stored attribute set of
+// a tenant, completing them as the attributes analysis does so their prompts match
+func loadAttributes(tenantID, path, setID string) ([]models.AttributeDefinition, error) {
+	var definitions []models.AttributeDefinition
+	switch {
+	case path != "":
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &definitions); err != nil {
+			return nil, fmt.Errorf("%s must hold a list of attribute definitions: %w", path, err)
+		}
+	case setID != "":
+		set, err := db.GetAttributeSet(tenantID, setID)
+		if err != nil {
+			return nil, fmt.Errorf("attribute set %s: %w", setID, err)
+		}
+		if err := json.Unmarshal(set.Attributes, &definitions); err != nil {
+			return nil, fmt.Errorf("attribute set %s is invalid: %w", setID, err)
+		}
+	default:
+		return nil, nil
+	}
+
+	attributes := make([]models.AttributeDefinition, 0, len(definitions))
+	for _, definition := range definitions {
+		if definition.FieldName == "" {
+			continue
+		}
+		if definition.Title == "" {
+			definition.Title = strings.ReplaceAll(definition.FieldName, "_", " ")
+		}
+		if definition.Speaker = strings.ToLower(definition.Speaker); !transcript.ValidRole(definition.Speaker) {
+			definition.Speaker = ""
+		}
+		if !models.ValidAttributeType(definition.Type) {
+			definition.Type = ""
+		}
+		attributes = append(attributes, definition)
+	}
+	if len(definitions) > 0 && len(attributes) == 0 {
+		return nil, fmt.Errorf("no attribute definition has a field_name")
+	}
+	return attributes, nil
+}
+
+// promptLanguage returns the language of the conversations as prompts name it, which is
+// empty for English, as interactive requests name it
+func promptLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" || language == translate.DefaultTargetLanguage {
+		return ""
+	}
+	if name := translate.LanguageName(language); name != language {
+		return fmt.Sprintf("%s (%s)", name, language)
+	}
+	return language
+}
+
+// settingsFingerprint hashes the settings that determine what is precomputed, so a
+// checkpoint is only resumed with the settings it was written with
+func settingsFingerprint(tenantID string, attributes []models.AttributeDefinition, intents bool, workflowID, language string) (string, error) {
+	encoded, err := json.Marshal(struct {
+		TenantID   string                       `json:"tenant_id"`
+		Attributes []models.AttributeDefinition `json:"attributes"`
+		Intents    bool                         `json:"intents"`
+		WorkflowID string                       `json:"workflow_id"`
+		Language   string                       `json:"language"`
+	}{tenantID, attributes, intents, workflowID, language})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8]), nil
+}
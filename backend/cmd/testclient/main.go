@@ -1,13 +1,13 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
+
+	"agenticflows/backend/client"
 )
 
 func main() {
@@ -93,102 +93,51 @@ func buildParameters(analysisType string, text string) map[string]interface{} {
 
 // callStandardAPI calls the standardized /api/analysis endpoint
 func callStandardAPI(analysisType string, text string, workflowID string, parameters map[string]interface{}) {
-	// Prepare request for the standardized API
-	reqBody := map[string]interface{}{
-		"analysis_type": analysisType,
-		"text":          text,
-		"workflow_id":   workflowID,
-		"parameters":    parameters,
+	req := client.StandardAnalysisRequest{
+		AnalysisType: analysisType,
+		Text:         text,
+		WorkflowID:   workflowID,
+		Parameters:   parameters,
 	}
 
 	// For findings analysis, we need a data field with attribute values
 	if analysisType == "findings" {
-		reqBody["data"] = map[string]interface{}{
+		req.Data = map[string]interface{}{
 			"attribute_values": map[string]interface{}{
 				"sample_data": "This is a placeholder. Normally you would include actual attribute values here.",
 			},
 		}
 	}
 
-	// Marshal to JSON
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		fmt.Printf("Error marshaling request: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Create request
-	url := "http://localhost:8080/api/analysis"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Printf("Error creating request: %v\n", err)
-		os.Exit(1)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// Set workflow ID header if provided
-	if workflowID != "" {
-		req.Header.Set("X-Workflow-ID", workflowID)
-	}
-
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := newClient().PerformAnalysis(context.Background(), req)
 	if err != nil {
-		fmt.Printf("Error making request: %v\n", err)
+		fmt.Printf("Error performing analysis: %v\n", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("Error reading response: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Pretty print response
-	var prettyJSON bytes.Buffer
-	if err := json.Indent(&prettyJSON, body, "", "  "); err != nil {
-		fmt.Printf("Error formatting JSON: %v\n", err)
-		fmt.Println(string(body))
-	} else {
-		fmt.Println(prettyJSON.String())
-	}
+	printJSON(resp)
 }
 
 // fetchResults fetches analysis results for a workflow
 func fetchResults(workflowID string) {
-	// Create request
-	url := "http://localhost:8080/api/analysis/results?workflow_id=" + workflowID
-	req, err := http.NewRequest("GET", url, nil)
+	results, err := newClient().Results(context.Background(), workflowID)
 	if err != nil {
-		fmt.Printf("Error creating request: %v\n", err)
+		fmt.Printf("Error fetching results: %v\n", err)
 		os.Exit(1)
 	}
+	printJSON(results)
+}
 
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("Error making request: %v\n", err)
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
+// newClient creates a client for the local server
+func newClient() *client.Client {
+	return client.NewClient("http://localhost:8080", "", false)
+}
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+// printJSON pretty prints a response
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		fmt.Printf("Error reading response: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Pretty print response
-	var prettyJSON bytes.Buffer
-	if err := json.Indent(&prettyJSON, body, "", "  "); err != nil {
 		fmt.Printf("Error formatting JSON: %v\n", err)
-		fmt.Println(string(body))
-	} else {
-		fmt.Println(prettyJSON.String())
+		return
 	}
+	fmt.Println(string(data))
 }
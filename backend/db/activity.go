@@ -0,0 +1,192 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Activity types recorded in the workspace activity feed
+const (
+	ActivityWorkflowCreated        = "workflow_created"
+	ActivityWorkflowUpdated        = "workflow_updated"
+	ActivityWorkflowDeleted        = "workflow_deleted"
+	ActivityWorkflowRunCompleted   = "workflow_run_completed"
+	ActivityWorkflowRunSkipped     = "workflow_run_skipped"
+	ActivityWorkflowRunFailed      = "workflow_run_failed"
+	ActivityWorkflowRunPaused      = "workflow_run_paused"
+	ActivityWorkflowReviewDecided  = "workflow_review_decided"
+	ActivityScheduleAlert          = "schedule_alert"
+	ActivitySLABreached            = "sla_breached"
+	ActivityAnalysisCompleted      = "analysis_completed"
+	ActivityResultAnnotated        = "result_annotated"
+	ActivityRecommendationAccepted = "recommendation_accepted"
+	ActivityConversationsIngested  = "conversations_ingested"
+	ActivityConversationsTiered    = "conversations_tiered"
+	ActivityPseudonymsResolved     = "pseudonyms_resolved"
+	ActivityCustomerDataDeleted    = "customer_data_deleted"
+	ActivityAPIKeyCreated          = "api_key_created"
+	ActivityAPIKeyRevoked          = "api_key_revoked"
+	ActivityWidgetShared           = "widget_shared"
+	ActivityWidgetRevoked          = "widget_revoked"
+	ActivityAttributeFlagResolved  = "attribute_flag_resolved"
+	ActivityCanaryStarted          = "canary_started"
+	ActivityCanaryPromoted         = "canary_promoted"
+	ActivityCanaryRolledBack       = "canary_rolled_back"
+	ActivityWorkspaceDefaultsSet   = "workspace_defaults_set"
+	ActivitySchedulerSettingsSet   = "scheduler_settings_set"
+)
+
+// Activity represents a single event in the workspace activity feed
+type Activity struct {
+	ID         string          `json:"id"`
+	TenantID   string          `json:"-"` // Defaults to the tenant of the workflow, else DefaultTenantID
+	Type       string          `json:"type"`
+	WorkflowID string          `json:"workflow_id,omitempty"`
+	Actor      string          `json:"actor"`
+	Summary    string          `json:"summary"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// ActivityFilter narrows down the activity returned by GetActivity
+type ActivityFilter struct {
+	TenantID   string // The tenant whose activity is returned; AllTenants returns that of every tenant
+	WorkflowID string
+	Types      []string
+	Actor      string
+	Since      time.Time
+	Limit      int
+}
+
+// createActivityTable creates the activity table if it doesn't exist
+func createActivityTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS activity (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			workflow_id TEXT,
+			actor TEXT NOT NULL,
+			summary TEXT NOT NULL,
+			details TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_activity_created_at ON activity (created_at)")
+	return err
+}
+
+// RecordActivity inserts a new event into the activity feed
+func RecordActivity(activity Activity) error {
+	if activity.CreatedAt.IsZero() {
+		activity.CreatedAt = time.Now()
+	}
+
+	var details interface{}
+	if len(activity.Details) > 0 {
+		details = string(activity.Details)
+	}
+
+	_, err := DB.Exec(
+		`INSERT INTO activity (id, tenant_id, type, workflow_id, actor, summary, details, created_at)
+		VALUES (?, COALESCE(NULLIF(?, ''), (SELECT tenant_id FROM workflows WHERE id = ?), ?), ?, ?, ?, ?, ?, ?)`,
+		activity.ID,
+		activity.TenantID, activity.WorkflowID, DefaultTenantID,
+		activity.Type,
+		activity.WorkflowID,
+		activity.Actor,
+		activity.Summary,
+		details,
+		activity.CreatedAt,
+	)
+
+	return err
+}
+
+// GetActivity returns activity matching the filter, newest first
+func GetActivity(filter ActivityFilter) ([]Activity, error) {
+	query := "SELECT id, type, workflow_id, actor, summary, details, created_at FROM activity"
+	conditions := []string{}
+	args := []interface{}{}
+
+	if filter.TenantID != AllTenants {
+		conditions = append(conditions, "tenant_id = ?")
+		args = append(args, filter.TenantID)
+	}
+	if filter.WorkflowID != "" {
+		conditions = append(conditions, "workflow_id = ?")
+		args = append(args, filter.WorkflowID)
+	}
+	if len(filter.Types) > 0 {
+		placeholders := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			placeholders[i] = "?"
+			args = append(args, t)
+		}
+		conditions = append(conditions, "type IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at > ?")
+		args = append(args, filter.Since)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC"
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query += " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := Replica.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []Activity{}
+	for rows.Next() {
+		var activity Activity
+		var workflowID, details *string
+
+		err := rows.Scan(
+			&activity.ID,
+			&activity.Type,
+			&workflowID,
+			&activity.Actor,
+			&activity.Summary,
+			&details,
+			&activity.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if workflowID != nil {
+			activity.WorkflowID = *workflowID
+		}
+		if details != nil && *details != "" {
+			activity.Details = json.RawMessage(*details)
+		}
+
+		activities = append(activities, activity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return activities, nil
+}
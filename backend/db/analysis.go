@@ -19,40 +19,78 @@ func AddTableForAnalysis() error {
 			FOREIGN KEY (workflow_id) REFERENCES workflows(id)
 		)
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Confidence is stored so results derived from this one can account for it
+	if err := addColumnIfMissing("analysis_results", "confidence", "REAL"); err != nil {
+		return err
+	}
+
+	// Results are flagged when the data they were computed from is deleted
+	if err := addColumnIfMissing("analysis_results", "flagged_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("analysis_results", "flag_reason", "TEXT"); err != nil {
+		return err
+	}
+
+	// Results saved as part of a run link to it, so their numbers trace back to the run
+	if err := addColumnIfMissing("analysis_results", "run_id", "TEXT"); err != nil {
+		return err
+	}
+
+	// Results belong to the tenant that saved them
+	return addTenantColumn("analysis_results")
 }
 
-// SaveAnalysisResult saves an analysis result to the database
-func SaveAnalysisResult(id, workflowID, analysisType string, results interface{}) error {
+// SaveAnalysisResult saves an analysis result of a tenant to the database, linked to the
+// run it was part of unless runID is empty
+func SaveAnalysisResult(tenantID, id, workflowID, runID, analysisType string, results interface{}, confidence float64) error {
 	// Convert results to JSON
 	resultBytes, err := json.Marshal(results)
 	if err != nil {
 		return fmt.Errorf("failed to marshal results: %w", err)
 	}
 
+	// A zero confidence means none was reported, so it is stored as NULL
+	var storedConfidence interface{}
+	if confidence > 0 {
+		storedConfidence = confidence
+	}
+
 	// Insert into database
 	_, err = DB.Exec(
-		"INSERT INTO analysis_results (id, workflow_id, analysis_type, results, created_at) VALUES (?, ?, ?, ?, ?)",
-		id, workflowID, analysisType, string(resultBytes), time.Now(),
+		"INSERT INTO analysis_results (id, workflow_id, run_id, analysis_type, results, confidence, created_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		id, workflowID, nullString(runID), analysisType, string(resultBytes), storedConfidence, time.Now(), tenantID,
 	)
 
 	return err
 }
 
-// GetAnalysisResult retrieves an analysis result by ID
-func GetAnalysisResult(id string) (map[string]interface{}, error) {
+// GetAnalysisResult retrieves an analysis result of a tenant by ID
+func GetAnalysisResult(tenantID, id string) (map[string]interface{}, error) {
 	var result AnalysisResult
 	var resultsStr string
+	var confidence sql.NullFloat64
+	var flaggedAt sql.NullTime
+	var flagReason, runID sql.NullString
 
+	condition, args := tenantCondition("tenant_id", tenantID)
 	err := DB.QueryRow(
-		"SELECT id, workflow_id, analysis_type, results, created_at FROM analysis_results WHERE id = ?",
-		id,
+		"SELECT id, workflow_id, analysis_type, results, confidence, created_at, flagged_at, flag_reason, run_id FROM analysis_results WHERE id = ?"+condition,
+		append([]interface{}{id}, args...)...,
 	).Scan(
 		&result.ID,
 		&result.WorkflowID,
 		&result.AnalysisType,
 		&resultsStr,
+		&confidence,
 		&result.CreatedAt,
+		&flaggedAt,
+		&flagReason,
+		&runID,
 	)
 
 	if err != nil {
@@ -63,9 +101,9 @@ func GetAnalysisResult(id string) (map[string]interface{}, error) {
 	}
 
 	// Parse results JSON
-	var resultsMap map[string]interface{}
-	if err := json.Unmarshal([]byte(resultsStr), &resultsMap); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+	resultsMap, err := decodeStoredResults(resultsStr)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create a map with all the result data
@@ -76,15 +114,23 @@ func GetAnalysisResult(id string) (map[string]interface{}, error) {
 		"results":       resultsMap,
 		"created_at":    result.CreatedAt.Format(time.RFC3339),
 	}
+	if confidence.Valid {
+		response["confidence"] = confidence.Float64
+	}
+	if runID.Valid {
+		response["run_id"] = runID.String
+	}
+	addResultFlag(response, flaggedAt, flagReason)
 
 	return response, nil
 }
 
-// GetAnalysisResultsByWorkflow retrieves all analysis results for a workflow
-func GetAnalysisResultsByWorkflow(workflowID string) ([]map[string]interface{}, error) {
+// GetAnalysisResultsByWorkflow retrieves all analysis results of a tenant for a workflow
+func GetAnalysisResultsByWorkflow(tenantID, workflowID string) ([]map[string]interface{}, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
 	rows, err := DB.Query(
-		"SELECT id, workflow_id, analysis_type, results, created_at FROM analysis_results WHERE workflow_id = ? ORDER BY created_at DESC",
-		workflowID,
+		"SELECT id, workflow_id, analysis_type, results, confidence, created_at, flagged_at, flag_reason, run_id FROM analysis_results WHERE workflow_id = ?"+condition+" ORDER BY created_at DESC",
+		append([]interface{}{workflowID}, args...)...,
 	)
 	if err != nil {
 		return nil, err
@@ -95,22 +141,29 @@ func GetAnalysisResultsByWorkflow(workflowID string) ([]map[string]interface{},
 	for rows.Next() {
 		var result AnalysisResult
 		var resultsStr string
+		var confidence sql.NullFloat64
+		var flaggedAt sql.NullTime
+		var flagReason, runID sql.NullString
 
 		err := rows.Scan(
 			&result.ID,
 			&result.WorkflowID,
 			&result.AnalysisType,
 			&resultsStr,
+			&confidence,
 			&result.CreatedAt,
+			&flaggedAt,
+			&flagReason,
+			&runID,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		// Parse results JSON
-		var resultsMap map[string]interface{}
-		if err := json.Unmarshal([]byte(resultsStr), &resultsMap); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+		resultsMap, err := decodeStoredResults(resultsStr)
+		if err != nil {
+			return nil, err
 		}
 
 		// Create a map with all the result data
@@ -121,6 +174,13 @@ func GetAnalysisResultsByWorkflow(workflowID string) ([]map[string]interface{},
 			"results":       resultsMap,
 			"created_at":    result.CreatedAt.Format(time.RFC3339),
 		}
+		if confidence.Valid {
+			resultMap["confidence"] = confidence.Float64
+		}
+		if runID.Valid {
+			resultMap["run_id"] = runID.String
+		}
+		addResultFlag(resultMap, flaggedAt, flagReason)
 
 		results = append(results, resultMap)
 	}
@@ -132,9 +192,72 @@ func GetAnalysisResultsByWorkflow(workflowID string) ([]map[string]interface{},
 	return results, nil
 }
 
-// DeleteAnalysisResult deletes an analysis result
-func DeleteAnalysisResult(id string) error {
-	_, err := DB.Exec("DELETE FROM analysis_results WHERE id = ?", id)
+// decodeStoredResults parses stored results. Results saved as an already encoded
+// JSON string are decoded a second time.
+func decodeStoredResults(resultsStr string) (map[string]interface{}, error) {
+	var stored interface{}
+	if err := json.Unmarshal([]byte(resultsStr), &stored); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+	}
+	if encoded, ok := stored.(string); ok {
+		if err := json.Unmarshal([]byte(encoded), &stored); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+		}
+	}
+
+	resultsMap, ok := stored.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("stored results are not a JSON object")
+	}
+	return resultsMap, nil
+}
+
+// addResultFlag adds when and why a result was flagged to its map, if it was
+func addResultFlag(result map[string]interface{}, flaggedAt sql.NullTime, flagReason sql.NullString) {
+	if flaggedAt.Valid {
+		result["flagged_at"] = flaggedAt.Time.Format(time.RFC3339)
+		result["flag_reason"] = flagReason.String
+	}
+}
+
+// GetLatestAnalysisResult returns the most recent result of an analysis type stored by a
+// tenant for a workflow since a time, in the form of GetAnalysisResult. A zero since is
+// not applied.
+func GetLatestAnalysisResult(tenantID, workflowID, analysisType string, since time.Time) (map[string]interface{}, error) {
+	var id string
+	condition, args := tenantCondition("tenant_id", tenantID)
+	err := DB.QueryRow(
+		"SELECT id FROM analysis_results WHERE workflow_id = ? AND analysis_type = ? AND created_at >= ?"+condition+" ORDER BY created_at DESC LIMIT 1",
+		append([]interface{}{workflowID, analysisType, since}, args...)...,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("analysis result not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetAnalysisResult(tenantID, id)
+}
+
+// GetAnalysisResultConfidence returns the stored confidence of an analysis result of a
+// tenant. The boolean is false if the result does not exist or has no recorded confidence.
+func GetAnalysisResultConfidence(tenantID, id string) (float64, bool, error) {
+	var confidence sql.NullFloat64
+	condition, args := tenantCondition("tenant_id", tenantID)
+	err := DB.QueryRow("SELECT confidence FROM analysis_results WHERE id = ?"+condition, append([]interface{}{id}, args...)...).Scan(&confidence)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return confidence.Float64, confidence.Valid, nil
+}
+
+// DeleteAnalysisResult deletes an analysis result of a tenant
+func DeleteAnalysisResult(tenantID, id string) error {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	_, err := DB.Exec("DELETE FROM analysis_results WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
 	return err
 }
 
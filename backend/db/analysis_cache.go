@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// AnalysisCacheStats summarizes the contents of the analysis cache
+type AnalysisCacheStats struct {
+	Entries int   `json:"entries"`
+	Expired int   `json:"expired"`
+	Hits    int64 `json:"hits"`
+}
+
+// createAnalysisCacheTable creates the analysis response cache table if it doesn't exist
+func createAnalysisCacheTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS analysis_cache (
+			key TEXT PRIMARY KEY,
+			analysis_type TEXT NOT NULL,
+			response TEXT NOT NULL,
+			hits INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL
+		)
+	`)
+	return err
+}
+
+// GetCachedAnalysis returns the cached response stored under key, if it has not expired
+func GetCachedAnalysis(key string) (json.RawMessage, bool, error) {
+	var response string
+	err := DB.QueryRow(
+		"SELECT response FROM analysis_cache WHERE key = ? AND expires_at > ?",
+		key, time.Now(),
+	).Scan(&response)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := DB.Exec("UPDATE analysis_cache SET hits = hits + 1 WHERE key = ?", key); err != nil {
+		return nil, false, err
+	}
+	return json.RawMessage(response), true, nil
+}
+
+// PutCachedAnalysis stores a response under key for ttl, replacing any previous entry
+func PutCachedAnalysis(key, analysisType string, response json.RawMessage, ttl time.Duration) error {
+	now := time.Now()
+	_, err := DB.Exec(
+		`INSERT INTO analysis_cache (key, analysis_type, response, hits, created_at, expires_at) VALUES (?, ?, ?, 0, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET analysis_type = excluded.analysis_type, response = excluded.response,
+			hits = 0, created_at = excluded.created_at, expires_at = excluded.expires_at`,
+		key, analysisType, string(response), now, now.Add(ttl),
+	)
+	return err
+}
+
+// PurgeExpiredAnalysisCache deletes expired cache entries
+func PurgeExpiredAnalysisCache() (int64, error) {
+	result, err := DB.Exec("DELETE FROM analysis_cache WHERE expires_at <= ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ClearAnalysisCache deletes all cache entries, or only those of one analysis type
+func ClearAnalysisCache(analysisType string) (int64, error) {
+	query := "DELETE FROM analysis_cache"
+	args := []interface{}{}
+	if analysisType != "" {
+		query += " WHERE analysis_type = ?"
+		args = append(args, analysisType)
+	}
+
+	result, err := DB.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetAnalysisCacheStats counts cache entries and hits
+func GetAnalysisCacheStats() (AnalysisCacheStats, error) {
+	var stats AnalysisCacheStats
+	err := DB.QueryRow(
+		"SELECT COUNT(*), COALESCE(SUM(CASE WHEN expires_at <= ? THEN 1 ELSE 0 END), 0), COALESCE(SUM(hits), 0) FROM analysis_cache",
+		time.Now(),
+	).Scan(&stats.Entries, &stats.Expired, &stats.Hits)
+	return stats, err
+}
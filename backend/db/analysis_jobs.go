@@ -0,0 +1,327 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Analysis job states
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Analysis job kinds
+const (
+	JobKindAnalysis = "analysis" // A single /api/analysis request
+	JobKindBatch    = "batch"    // A /api/analysis/batch request
+)
+
+// AnalysisJob is an analysis request queued for background processing
+type AnalysisJob struct {
+	ID           string          `json:"id"`
+	Kind         string          `json:"kind"`
+	WorkflowID   string          `json:"workflow_id,omitempty"`
+	AnalysisType string          `json:"analysis_type"`
+	Actor        string          `json:"actor"`
+	TenantID     string          `json:"-"` // The tenant the job runs for
+	Status       string          `json:"status"`
+	Request      json.RawMessage `json:"request,omitempty"`
+	Progress     JobProgress     `json:"progress"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	ClaimedBy    string          `json:"claimed_by,omitempty"` // Server instance running the job
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	CompletedAt  *time.Time      `json:"completed_at,omitempty"`
+}
+
+// JobProgress reports how far a job has got
+type JobProgress struct {
+	Stage     string `json:"stage"`
+	Completed int    `json:"completed"` // Units of work done, e.g. batches
+	Total     int    `json:"total"`
+}
+
+// AnalysisJobFilter narrows down the jobs returned by ListAnalysisJobs
+type AnalysisJobFilter struct {
+	TenantID   string // The tenant whose jobs are returned; AllTenants returns those of every tenant
+	WorkflowID string
+	Status     string
+	Limit      int
+}
+
+// analysisJobColumns are the columns read by scanAnalysisJob
+const analysisJobColumns = "id, kind, workflow_id, analysis_type, actor, tenant_id, status, request, progress_stage, progress_completed, progress_total, result, error, claimed_by, created_at, started_at, completed_at"
+
+// claimCandidates is how many queued jobs a claim tries when other instances claim the
+// same jobs concurrently
+const claimCandidates = 5
+
+// createAnalysisJobsTable creates the analysis job queue table if it doesn't exist
+func createAnalysisJobsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS analysis_jobs (
+			id TEXT PRIMARY KEY,
+			kind TEXT NOT NULL,
+			workflow_id TEXT,
+			analysis_type TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			status TEXT NOT NULL,
+			request TEXT NOT NULL,
+			progress_stage TEXT NOT NULL DEFAULT '',
+			progress_completed INTEGER NOT NULL DEFAULT 0,
+			progress_total INTEGER NOT NULL DEFAULT 0,
+			result TEXT,
+			error TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			started_at TIMESTAMP,
+			completed_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_analysis_jobs_status ON analysis_jobs (status, created_at)")
+	if err != nil {
+		return err
+	}
+
+	// Running jobs record the instance running them and when it last reported in
+	if err := addColumnIfMissing("analysis_jobs", "claimed_by", "TEXT"); err != nil {
+		return err
+	}
+	return addColumnIfMissing("analysis_jobs", "heartbeat_at", "TIMESTAMP")
+}
+
+// CreateAnalysisJob queues a new job
+func CreateAnalysisJob(job AnalysisJob) error {
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+
+	_, err := DB.Exec(
+		"INSERT INTO analysis_jobs (id, kind, workflow_id, analysis_type, actor, tenant_id, status, request, progress_stage, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		job.ID, job.Kind, job.WorkflowID, job.AnalysisType, job.Actor, job.TenantID, JobQueued, string(job.Request), JobQueued, job.CreatedAt,
+	)
+	return err
+}
+
+// ClaimNextAnalysisJob marks the oldest queued job as running on worker, the ID of a
+// server instance, and returns it. It returns nil if no job is queued. Instances sharing
+// the database never claim the same job: a claim only succeeds on a job still queued,
+// and a job lost to another instance is skipped for the next one.
+func ClaimNextAnalysisJob(worker string) (*AnalysisJob, error) {
+	var job *AnalysisJob
+	err := withTx(func(tx *Tx) error {
+		rows, err := tx.Query("SELECT id FROM analysis_jobs WHERE status = ? ORDER BY created_at LIMIT ?", JobQueued, claimCandidates)
+		if err != nil {
+			return err
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, id := range ids {
+			// Guard on the status so a job is never claimed twice
+			result, err := tx.Exec(
+				"UPDATE analysis_jobs SET status = ?, progress_stage = ?, claimed_by = ?, started_at = ?, heartbeat_at = ? WHERE id = ? AND status = ?",
+				JobRunning, JobRunning, worker, now, now, id, JobQueued,
+			)
+			if err != nil {
+				return err
+			}
+			if n, err := result.RowsAffected(); err != nil {
+				return err
+			} else if n == 0 {
+				continue
+			}
+
+			job, err = scanAnalysisJob(tx.QueryRow("SELECT "+analysisJobColumns+" FROM analysis_jobs WHERE id = ?", id))
+			return err
+		}
+		return nil
+	})
+	return job, err
+}
+
+// HeartbeatAnalysisJob records that worker is still running a job. It reports false when
+// the job is no longer the worker's, e.g. after it was requeued as stale.
+func HeartbeatAnalysisJob(id, worker string) (bool, error) {
+	result, err := DB.Exec(
+		"UPDATE analysis_jobs SET heartbeat_at = ? WHERE id = ? AND status = ? AND claimed_by = ?",
+		time.Now().UTC(), id, JobRunning, worker,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UpdateAnalysisJobProgress records the progress of a job running on worker
+func UpdateAnalysisJobProgress(id, worker string, progress JobProgress) error {
+	_, err := DB.Exec(
+		"UPDATE analysis_jobs SET progress_stage = ?, progress_completed = ?, progress_total = ?, heartbeat_at = ? WHERE id = ? AND status = ? AND claimed_by = ?",
+		progress.Stage, progress.Completed, progress.Total, time.Now().UTC(), id, JobRunning, worker,
+	)
+	return err
+}
+
+// CompleteAnalysisJob stores the result of a job finished by worker. Only the worker
+// holding the job records its outcome, so a job requeued and run again elsewhere is not
+// overwritten by the first run.
+func CompleteAnalysisJob(id, worker string, result interface{}) error {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job result: %w", err)
+	}
+
+	_, err = DB.Exec(
+		"UPDATE analysis_jobs SET status = ?, progress_stage = ?, result = ?, completed_at = ? WHERE id = ? AND status = ? AND claimed_by = ?",
+		JobCompleted, JobCompleted, string(resultJSON), time.Now(), id, JobRunning, worker,
+	)
+	return err
+}
+
+// FailAnalysisJob records why a job run by worker failed
+func FailAnalysisJob(id, worker string, jobErr error) error {
+	_, err := DB.Exec(
+		"UPDATE analysis_jobs SET status = ?, progress_stage = ?, error = ?, completed_at = ? WHERE id = ? AND status = ? AND claimed_by = ?",
+		JobFailed, JobFailed, jobErr.Error(), time.Now(), id, JobRunning, worker,
+	)
+	return err
+}
+
+// RequeueStaleAnalysisJobs returns running jobs to the queue when the instance running
+// them stopped: those claimed by worker, which is restarting, if set, and those of any
+// instance that has not reported in since staleBefore
+func RequeueStaleAnalysisJobs(worker string, staleBefore time.Time) (int64, error) {
+	result, err := DB.Exec(
+		"UPDATE analysis_jobs SET status = ?, progress_stage = ?, progress_completed = 0, claimed_by = NULL, started_at = NULL, heartbeat_at = NULL WHERE status = ? AND (claimed_by = ? OR claimed_by IS NULL OR heartbeat_at IS NULL OR heartbeat_at < ?)",
+		JobQueued, JobQueued, JobRunning, worker, staleBefore.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetAnalysisJob retrieves a job of a tenant by ID
+func GetAnalysisJob(tenantID, id string) (*AnalysisJob, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	job, err := scanAnalysisJob(DB.QueryRow("SELECT "+analysisJobColumns+" FROM analysis_jobs WHERE id = ?"+condition, append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("analysis job not found")
+	}
+	return job, err
+}
+
+// ListAnalysisJobs returns jobs matching the filter, newest first, without their requests and results
+func ListAnalysisJobs(filter AnalysisJobFilter) ([]AnalysisJob, error) {
+	condition, args := tenantCondition("tenant_id", filter.TenantID)
+	query := "SELECT " + analysisJobColumns + " FROM analysis_jobs WHERE 1 = 1" + condition
+
+	if filter.WorkflowID != "" {
+		query += " AND workflow_id = ?"
+		args = append(args, filter.WorkflowID)
+	}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query += " ORDER BY created_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []AnalysisJob{}
+	for rows.Next() {
+		job, err := scanAnalysisJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		job.Request = nil
+		job.Result = nil
+		jobs = append(jobs, *job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// scanAnalysisJob reads a job selected with analysisJobColumns
+func scanAnalysisJob(row rowScanner) (*AnalysisJob, error) {
+	var job AnalysisJob
+	var workflowID, request, result, jobError, claimedBy sql.NullString
+	var startedAt, completedAt sql.NullTime
+
+	err := row.Scan(
+		&job.ID,
+		&job.Kind,
+		&workflowID,
+		&job.AnalysisType,
+		&job.Actor,
+		&job.TenantID,
+		&job.Status,
+		&request,
+		&job.Progress.Stage,
+		&job.Progress.Completed,
+		&job.Progress.Total,
+		&result,
+		&jobError,
+		&claimedBy,
+		&job.CreatedAt,
+		&startedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.WorkflowID = workflowID.String
+	job.Error = jobError.String
+	job.ClaimedBy = claimedBy.String
+	if request.Valid && request.String != "" {
+		job.Request = json.RawMessage(request.String)
+	}
+	if result.Valid && result.String != "" {
+		job.Result = json.RawMessage(result.String)
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+
+	return &job, nil
+}
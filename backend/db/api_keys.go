@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// APIKey is an issued API key. Only the hash of the key is stored; its prefix identifies
+// it in listings.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	TenantID   string     `json:"tenant_id"` // The tenant whose data the key reaches
+	Prefix     string     `json:"prefix"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// apiKeyColumns are the columns read into an APIKey, in scan order
+const apiKeyColumns = "id, name, role, tenant_id, prefix, created_by, created_at, expires_at, last_used_at, revoked_at"
+
+// apiKeyUseInterval limits how often last_used_at is written for a busy key
+const apiKeyUseInterval = time.Minute
+
+// createAPIKeysTable creates the api_keys table if it doesn't exist
+func createAPIKeysTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			role TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			prefix TEXT NOT NULL,
+			created_by TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP,
+			last_used_at TIMESTAMP,
+			revoked_at TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// CreateAPIKey stores a new API key under the hash of its secret. Keys without a tenant
+// belong to the default tenant.
+func CreateAPIKey(key APIKey, keyHash string) error {
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	if key.TenantID == "" {
+		key.TenantID = DefaultTenantID
+	}
+	_, err := DB.Exec(
+		"INSERT INTO api_keys (id, name, role, tenant_id, key_hash, prefix, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		key.ID, key.Name, key.Role, key.TenantID, keyHash, key.Prefix, nullString(key.CreatedBy), key.CreatedAt, key.ExpiresAt,
+	)
+	return err
+}
+
+// GetAPIKeyByHash returns the active key with a hash, recording its use. Revoked and
+// expired keys are not found.
+func GetAPIKeyByHash(keyHash string) (*APIKey, error) {
+	row := DB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL", keyHash)
+	key, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("API key not found")
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyUseInterval {
+		if _, err := DB.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", now, key.ID); err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns all keys of a tenant, or of every tenant for AllTenants, including
+// revoked ones, most recent first
+func ListAPIKeys(tenantID string) ([]APIKey, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE 1 = 1"+condition+" ORDER BY created_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes a key of a tenant; revoking it again keeps the first revocation time
+func RevokeAPIKey(tenantID, id string) (*APIKey, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	result, err := DB.Exec("UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?"+condition, append([]interface{}{time.Now(), id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, fmt.Errorf("API key not found")
+	}
+
+	key, err := scanAPIKey(DB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// scanAPIKey reads an APIKey from a row
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	var createdBy sql.NullString
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.Name, &key.Role, &key.TenantID, &key.Prefix, &createdBy, &key.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.CreatedBy = createdBy.String
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
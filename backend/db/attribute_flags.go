@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Attribute flag statuses
+const (
+	AttributeFlagOpen     = "open"
+	AttributeFlagResolved = "resolved"
+)
+
+// AttributeFlag marks a conversation whose extracted attribute values break a validation
+// rule, for review
+type AttributeFlag struct {
+	ID             int64             `json:"id"`
+	ConversationID string            `json:"conversation_id"`
+	Rule           string            `json:"rule"`
+	Message        string            `json:"message"`
+	Fields         []string          `json:"fields"`
+	Values         map[string]string `json:"values"`
+	WorkflowID     string            `json:"workflow_id,omitempty"`
+	Status         string            `json:"status"`
+	CreatedAt      time.Time         `json:"created_at"`
+	ResolvedAt     *time.Time        `json:"resolved_at,omitempty"`
+	ResolvedBy     string            `json:"resolved_by,omitempty"`
+	Note           string            `json:"note,omitempty"`
+}
+
+// AttributeFlagFilter selects attribute flags
+type AttributeFlagFilter struct {
+	TenantID       string // The tenant whose conversations' flags are returned; AllTenants returns every flag
+	ConversationID string
+	WorkflowID     string
+	Rule           string
+	Status         string // open, resolved or empty for all
+	Limit          int
+}
+
+// attributeFlagColumns are the columns read into an AttributeFlag, in scan order
+const attributeFlagColumns = "id, conversation_id, rule, message, fields, field_values, workflow_id, created_at, resolved_at, resolved_by, note"
+
+// createAttributeFlagsTable creates the attribute_flags table if it doesn't exist
+func createAttributeFlagsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS attribute_flags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id TEXT NOT NULL,
+			rule TEXT NOT NULL,
+			message TEXT,
+			fields TEXT,
+			field_values TEXT,
+			workflow_id TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			resolved_at TIMESTAMP,
+			resolved_by TEXT,
+			note TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_attribute_flags_conversation ON attribute_flags (conversation_id, resolved_at)")
+	return err
+}
+
+// ReplaceAttributeFlags records the rule violations found when the attributes of
+// conversations were validated. Open flags of the same conversations and rules are
+// replaced, so values that were corrected by re-extraction are no longer flagged.
+func ReplaceAttributeFlags(conversationIDs, rules []string, flags []AttributeFlag) error {
+	if len(conversationIDs) == 0 || len(rules) == 0 {
+		return nil
+	}
+
+	return withTx(func(tx *Tx) error {
+		args := make([]interface{}, 0, len(conversationIDs)+len(rules))
+		for _, id := range conversationIDs {
+			args = append(args, id)
+		}
+		for _, rule := range rules {
+			args = append(args, rule)
+		}
+		_, err := tx.Exec(
+			"DELETE FROM attribute_flags WHERE resolved_at IS NULL AND conversation_id IN (?"+strings.Repeat(", ?", len(conversationIDs)-1)+
+				") AND rule IN (?"+strings.Repeat(", ?", len(rules)-1)+")",
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+
+		stmt, err := tx.Prepare("INSERT INTO attribute_flags (conversation_id, rule, message, fields, field_values, workflow_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		now := time.Now()
+		for _, flag := range flags {
+			fields, err := json.Marshal(flag.Fields)
+			if err != nil {
+				return err
+			}
+			values, err := json.Marshal(flag.Values)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.Exec(flag.ConversationID, flag.Rule, flag.Message, string(fields), string(values), flag.WorkflowID, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListAttributeFlags returns attribute flags, most recent first
+func ListAttributeFlags(filter AttributeFlagFilter) ([]AttributeFlag, error) {
+	condition, args := conversationTenantCondition("conversation_id", filter.TenantID)
+	query := "SELECT " + attributeFlagColumns + " FROM attribute_flags WHERE 1 = 1" + condition
+	if filter.ConversationID != "" {
+		query += " AND conversation_id = ?"
+		args = append(args, filter.ConversationID)
+	}
+	if filter.WorkflowID != "" {
+		query += " AND workflow_id = ?"
+		args = append(args, filter.WorkflowID)
+	}
+	if filter.Rule != "" {
+		query += " AND rule = ?"
+		args = append(args, filter.Rule)
+	}
+	switch filter.Status {
+	case AttributeFlagOpen:
+		query += " AND resolved_at IS NULL"
+	case AttributeFlagResolved:
+		query += " AND resolved_at IS NOT NULL"
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []AttributeFlag{}
+	for rows.Next() {
+		flag, err := scanAttributeFlag(rows)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, *flag)
+	}
+	return flags, rows.Err()
+}
+
+// ResolveAttributeFlag marks a flag on a conversation of a tenant as reviewed
+func ResolveAttributeFlag(tenantID string, id int64, resolvedBy, note string) (*AttributeFlag, error) {
+	condition, args := conversationTenantCondition("conversation_id", tenantID)
+	result, err := DB.Exec(
+		"UPDATE attribute_flags SET resolved_at = ?, resolved_by = ?, note = ? WHERE id = ? AND resolved_at IS NULL"+condition,
+		append([]interface{}{time.Now(), resolvedBy, note, id}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	flag, err := scanAttributeFlag(DB.QueryRow("SELECT "+attributeFlagColumns+" FROM attribute_flags WHERE id = ?"+condition, append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("attribute flag not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("attribute flag %d is already resolved", id)
+	}
+	return flag, nil
+}
+
+// scanAttributeFlag reads an AttributeFlag from a row
+func scanAttributeFlag(row rowScanner) (*AttributeFlag, error) {
+	var flag AttributeFlag
+	var message, fields, values, workflowID, resolvedBy, note sql.NullString
+	var resolvedAt sql.NullTime
+	err := row.Scan(&flag.ID, &flag.ConversationID, &flag.Rule, &message, &fields, &values, &workflowID,
+		&flag.CreatedAt, &resolvedAt, &resolvedBy, &note)
+	if err != nil {
+		return nil, err
+	}
+	flag.Message = message.String
+	flag.WorkflowID = workflowID.String
+	flag.ResolvedBy = resolvedBy.String
+	flag.Note = note.String
+	flag.Status = AttributeFlagOpen
+	if resolvedAt.Valid {
+		flag.ResolvedAt = &resolvedAt.Time
+		flag.Status = AttributeFlagResolved
+	}
+	flag.Fields = []string{}
+	if fields.String != "" {
+		if err := json.Unmarshal([]byte(fields.String), &flag.Fields); err != nil {
+			return nil, err
+		}
+	}
+	flag.Values = map[string]string{}
+	if values.String != "" {
+		if err := json.Unmarshal([]byte(values.String), &flag.Values); err != nil {
+			return nil, err
+		}
+	}
+	return &flag, nil
+}
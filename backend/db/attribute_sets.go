@@ -0,0 +1,231 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AttributeSet is a stored set of attribute definitions that requests reference by ID
+// instead of resending the definitions. Updating a set stores a new version; earlier
+// versions stay readable so requests can pin them.
+type AttributeSet struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Version     int             `json:"version"`
+	Attributes  json.RawMessage `json:"attributes"`
+	// ValidationRules are the cross-field rules checked after the attributes are extracted
+	ValidationRules json.RawMessage `json:"validation_rules,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	// UpdatedAt is when the version was stored
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// createAttributeSetsTable creates the attribute_sets table, which holds the latest
+// version of each set, and the attribute_set_versions table if they don't exist
+func createAttributeSetsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS attribute_sets (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			description TEXT,
+			attributes TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("attribute_sets", "validation_rules", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("attribute_sets", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS attribute_set_versions (
+			set_id TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT,
+			attributes TEXT NOT NULL,
+			validation_rules TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (set_id, version)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Sets stored before versioning become their first version
+	_, err = DB.Exec(`
+		INSERT INTO attribute_set_versions (set_id, version, name, description, attributes, validation_rules, created_at)
+		SELECT id, version, name, description, attributes, validation_rules, created_at FROM attribute_sets
+		WHERE NOT EXISTS (SELECT 1 FROM attribute_set_versions v WHERE v.set_id = attribute_sets.id)
+	`)
+	return err
+}
+
+// CreateAttributeSet stores a new attribute set of a tenant as its first version
+func CreateAttributeSet(tenantID string, set AttributeSet) error {
+	if set.CreatedAt.IsZero() {
+		set.CreatedAt = time.Now()
+	}
+	return withTx(func(tx *Tx) error {
+		if _, err := tx.Exec(
+			"INSERT INTO attribute_sets (id, name, description, version, attributes, validation_rules, created_at, tenant_id) VALUES (?, ?, ?, 1, ?, ?, ?, ?)",
+			set.ID, set.Name, set.Description, string(set.Attributes), nullString(string(set.ValidationRules)), set.CreatedAt, tenantID,
+		); err != nil {
+			return err
+		}
+		return insertAttributeSetVersion(tx, set, 1, set.CreatedAt)
+	})
+}
+
+// UpdateAttributeSet stores new definitions as the next version of a set of a tenant and
+// returns the version
+func UpdateAttributeSet(tenantID string, set AttributeSet) (int, error) {
+	var version int
+	condition, args := tenantCondition("tenant_id", tenantID)
+	err := withTx(func(tx *Tx) error {
+		if err := tx.QueryRow("SELECT version FROM attribute_sets WHERE id = ?"+condition, append([]interface{}{set.ID}, args...)...).Scan(&version); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("attribute set not found")
+			}
+			return err
+		}
+		version++
+		if _, err := tx.Exec(
+			"UPDATE attribute_sets SET name = ?, description = ?, version = ?, attributes = ?, validation_rules = ? WHERE id = ?",
+			set.Name, set.Description, version, string(set.Attributes), nullString(string(set.ValidationRules)), set.ID,
+		); err != nil {
+			return err
+		}
+		return insertAttributeSetVersion(tx, set, version, time.Now())
+	})
+	return version, err
+}
+
+// insertAttributeSetVersion records a version of a set
+func insertAttributeSetVersion(tx *Tx, set AttributeSet, version int, storedAt time.Time) error {
+	_, err := tx.Exec(
+		"INSERT INTO attribute_set_versions (set_id, version, name, description, attributes, validation_rules, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		set.ID, version, set.Name, set.Description, string(set.Attributes), nullString(string(set.ValidationRules)), storedAt,
+	)
+	return err
+}
+
+// GetAttributeSet returns the latest version of an attribute set of a tenant by ID
+func GetAttributeSet(tenantID, id string) (AttributeSet, error) {
+	return GetAttributeSetVersion(tenantID, id, 0)
+}
+
+// GetAttributeSetVersion returns a version of an attribute set of a tenant, or the latest
+// version when version is 0
+func GetAttributeSetVersion(tenantID, id string, version int) (AttributeSet, error) {
+	condition, tenantArgs := tenantCondition("s.tenant_id", tenantID)
+	query := attributeSetVersionQuery + " WHERE s.id = ? AND v.version = s.version" + condition
+	args := append([]interface{}{id}, tenantArgs...)
+	if version > 0 {
+		query = attributeSetVersionQuery + " WHERE s.id = ? AND v.version = ?" + condition
+		args = append([]interface{}{id, version}, tenantArgs...)
+	}
+	set, err := scanAttributeSet(DB.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		if version > 0 {
+			return AttributeSet{}, fmt.Errorf("attribute set version not found")
+		}
+		return AttributeSet{}, fmt.Errorf("attribute set not found")
+	}
+	return set, err
+}
+
+// attributeSetVersionQuery selects versions of attribute sets joined with their sets
+const attributeSetVersionQuery = `SELECT s.id, v.name, v.description, v.version, v.attributes, v.validation_rules, s.created_at, v.created_at
+	FROM attribute_sets s JOIN attribute_set_versions v ON v.set_id = s.id`
+
+// ListAttributeSets returns the latest version of all attribute sets of a tenant, most
+// recent first. A non-empty name returns only the sets of that name.
+func ListAttributeSets(tenantID, name string) ([]AttributeSet, error) {
+	condition, args := tenantCondition("s.tenant_id", tenantID)
+	query := attributeSetVersionQuery + " WHERE v.version = s.version" + condition
+	if name != "" {
+		query += " AND s.name = ?"
+		args = append(args, name)
+	}
+	return queryAttributeSets(query+" ORDER BY s.created_at DESC", args...)
+}
+
+// ListAttributeSetVersions returns the versions of an attribute set of a tenant, newest first
+func ListAttributeSetVersions(tenantID, id string) ([]AttributeSet, error) {
+	condition, args := tenantCondition("s.tenant_id", tenantID)
+	sets, err := queryAttributeSets(attributeSetVersionQuery+" WHERE s.id = ?"+condition+" ORDER BY v.version DESC", append([]interface{}{id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("attribute set not found")
+	}
+	return sets, nil
+}
+
+// queryAttributeSets runs a query of attributeSetVersionQuery
+func queryAttributeSets(query string, args ...interface{}) ([]AttributeSet, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []AttributeSet{}
+	for rows.Next() {
+		set, err := scanAttributeSet(rows)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sets, nil
+}
+
+// DeleteAttributeSet deletes an attribute set of a tenant with all its versions
+func DeleteAttributeSet(tenantID, id string) error {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	return withTx(func(tx *Tx) error {
+		result, err := tx.Exec("DELETE FROM attribute_sets WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("attribute set not found")
+		}
+		_, err = tx.Exec("DELETE FROM attribute_set_versions WHERE set_id = ?", id)
+		return err
+	})
+}
+
+// scanAttributeSet reads a version of an attribute set from a row
+func scanAttributeSet(row rowScanner) (AttributeSet, error) {
+	var set AttributeSet
+	var description sql.NullString
+	var attributes string
+	var validationRules sql.NullString
+	if err := row.Scan(&set.ID, &set.Name, &description, &set.Version, &attributes, &validationRules, &set.CreatedAt, &set.UpdatedAt); err != nil {
+		return AttributeSet{}, err
+	}
+	set.Description = description.String
+	set.Attributes = json.RawMessage(attributes)
+	if validationRules.String != "" {
+		set.ValidationRules = json.RawMessage(validationRules.String)
+	}
+	return set, nil
+}
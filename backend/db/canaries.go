@@ -0,0 +1,343 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Canary statuses
+const (
+	CanaryActive     = "active"
+	CanaryPromoted   = "promoted"
+	CanaryRolledBack = "rolled_back"
+)
+
+// Canary arms: the traffic left on the baseline and the traffic routed to the canary
+const (
+	CanaryArmBaseline = "baseline"
+	CanaryArmCanary   = "canary"
+)
+
+// Canary routes a percentage of analysis traffic to a new model or prompt version, so its
+// output quality can be compared with the baseline before it is promoted. A promoted
+// canary becomes the baseline of its analysis types until it is rolled back. Canaries
+// belong to a tenant and route only the analyses of their tenant.
+type Canary struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	AnalysisTypes []string `json:"analysis_types"` // Empty for all types
+	Percentage    float64  `json:"percentage"`
+	Model         string   `json:"model,omitempty"`
+	// PromptInstructions are appended to every prompt of the canary
+	PromptInstructions string           `json:"prompt_instructions,omitempty"`
+	Status             string           `json:"status"`
+	CreatedBy          string           `json:"created_by,omitempty"`
+	CreatedAt          time.Time        `json:"created_at"`
+	EndedAt            *time.Time       `json:"ended_at,omitempty"` // When it was promoted or rolled back
+	EndedBy            string           `json:"ended_by,omitempty"`
+	Baseline           CanaryStats      `json:"baseline"`
+	Canary             CanaryStats      `json:"canary"`
+	Comparison         CanaryComparison `json:"comparison"`
+}
+
+// CanaryStats are the quality metrics of the analyses of one arm of a canary
+type CanaryStats struct {
+	Requests          int     `json:"requests"`
+	Failures          int     `json:"failures"`
+	Outputs           int     `json:"structured_outputs"`
+	Repaired          int     `json:"repaired_outputs"` // Valid only after re-prompting
+	Invalid           int     `json:"invalid_outputs"`  // Still invalid after all repair attempts
+	FailureRate       float64 `json:"failure_rate"`
+	RepairRate        float64 `json:"repair_rate"` // Share of structured outputs that needed repair or stayed invalid
+	AverageConfidence float64 `json:"average_confidence"`
+	AverageLatencyMs  float64 `json:"average_latency_ms"`
+}
+
+// CanaryComparison is the difference between the metrics of the canary and the baseline;
+// negative rate deltas mean the canary does better
+type CanaryComparison struct {
+	FailureRateDelta       float64 `json:"failure_rate_delta"`
+	RepairRateDelta        float64 `json:"repair_rate_delta"`
+	AverageConfidenceDelta float64 `json:"average_confidence_delta"`
+	AverageLatencyMsDelta  float64 `json:"average_latency_ms_delta"`
+}
+
+// CanaryObservation is the outcome of one analysis routed through a canary
+type CanaryObservation struct {
+	Failed     bool
+	Outputs    int
+	Repaired   int
+	Invalid    int
+	Confidence float64
+	Latency    time.Duration
+}
+
+// Covers reports whether a canary applies to an analysis type
+func (c Canary) Covers(analysisType string) bool {
+	if len(c.AnalysisTypes) == 0 {
+		return true
+	}
+	for _, t := range c.AnalysisTypes {
+		if t == analysisType {
+			return true
+		}
+	}
+	return false
+}
+
+// canaryColumns are the columns read into a Canary, in scan order
+const canaryColumns = "id, name, analysis_types, percentage, model, prompt_instructions, status, created_by, created_at, ended_at, ended_by"
+
+// createCanariesTable creates the canaries and canary_metrics tables if they don't exist
+func createCanariesTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS canaries (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			analysis_types TEXT,
+			percentage REAL NOT NULL,
+			model TEXT,
+			prompt_instructions TEXT,
+			status TEXT NOT NULL,
+			created_by TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			ended_at TIMESTAMP,
+			ended_by TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS canary_metrics (
+			canary_id TEXT NOT NULL,
+			arm TEXT NOT NULL,
+			requests INTEGER NOT NULL DEFAULT 0,
+			failures INTEGER NOT NULL DEFAULT 0,
+			outputs INTEGER NOT NULL DEFAULT 0,
+			repaired INTEGER NOT NULL DEFAULT 0,
+			invalid INTEGER NOT NULL DEFAULT 0,
+			confidence_sum REAL NOT NULL DEFAULT 0,
+			latency_ms_sum INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (canary_id, arm)
+		)
+	`)
+	return err
+}
+
+// CreateCanary stores a new active canary of a tenant. Only one canary of a tenant can be
+// active at a time.
+func CreateCanary(tenantID string, canary Canary) error {
+	analysisTypes, err := json.Marshal(canary.AnalysisTypes)
+	if err != nil {
+		return err
+	}
+
+	return withTx(func(tx *Tx) error {
+		var activeID string
+		err := tx.QueryRow("SELECT id FROM canaries WHERE status = ? AND tenant_id = ?", CanaryActive, tenantID).Scan(&activeID)
+		if err == nil {
+			return fmt.Errorf("canary %s is already active", activeID)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO canaries (id, name, analysis_types, percentage, model, prompt_instructions, status, created_by, created_at, tenant_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			canary.ID, canary.Name, string(analysisTypes), canary.Percentage, nullString(canary.Model),
+			nullString(canary.PromptInstructions), CanaryActive, nullString(canary.CreatedBy), time.Now(), tenantID,
+		)
+		return err
+	})
+}
+
+// GetCanary returns a canary of a tenant with its metrics
+func GetCanary(tenantID, id string) (*Canary, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	canary, err := scanCanary(DB.QueryRow("SELECT "+canaryColumns+" FROM canaries WHERE id = ?"+condition, append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("canary not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := loadCanaryStats(canary); err != nil {
+		return nil, err
+	}
+	return canary, nil
+}
+
+// ListCanaries returns the canaries of a tenant with the given statuses, or all of them,
+// newest first
+func ListCanaries(tenantID string, statuses ...string) ([]Canary, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	query := "SELECT " + canaryColumns + " FROM canaries WHERE 1 = 1" + condition
+	if len(statuses) > 0 {
+		query += " AND status IN (?" + strings.Repeat(", ?", len(statuses)-1) + ")"
+		for _, status := range statuses {
+			args = append(args, status)
+		}
+	}
+	rows, err := DB.Query(query+" ORDER BY created_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	canaries := []Canary{}
+	for rows.Next() {
+		canary, err := scanCanary(rows)
+		if err != nil {
+			return nil, err
+		}
+		canaries = append(canaries, *canary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range canaries {
+		if err := loadCanaryStats(&canaries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return canaries, nil
+}
+
+// UpdateCanaryPercentage changes the share of traffic routed to an active canary of a tenant
+func UpdateCanaryPercentage(tenantID, id string, percentage float64) error {
+	canary, err := GetCanary(tenantID, id)
+	if err != nil {
+		return err
+	}
+	if canary.Status != CanaryActive {
+		return fmt.Errorf("canary is %s, not active", canary.Status)
+	}
+	_, err = DB.Exec("UPDATE canaries SET percentage = ? WHERE id = ?", percentage, id)
+	return err
+}
+
+// EndCanary promotes an active canary of a tenant or rolls back an active or promoted one
+func EndCanary(tenantID, id, status, actor string) error {
+	canary, err := GetCanary(tenantID, id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status == CanaryPromoted && canary.Status != CanaryActive:
+		return fmt.Errorf("canary is %s, only active canaries can be promoted", canary.Status)
+	case status == CanaryRolledBack && canary.Status == CanaryRolledBack:
+		return fmt.Errorf("canary is already rolled_back")
+	}
+
+	_, err = DB.Exec("UPDATE canaries SET status = ?, ended_at = ?, ended_by = ? WHERE id = ?",
+		status, time.Now(), nullString(actor), id)
+	return err
+}
+
+// RecordCanaryObservation adds the outcome of an analysis to the metrics of a canary arm
+func RecordCanaryObservation(id, arm string, observation CanaryObservation) error {
+	failed, confidence := 0, observation.Confidence
+	if observation.Failed {
+		failed, confidence = 1, 0
+	}
+	_, err := DB.Exec(`
+		INSERT INTO canary_metrics (canary_id, arm, requests, failures, outputs, repaired, invalid, confidence_sum, latency_ms_sum)
+		VALUES (?, ?, 1, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(canary_id, arm) DO UPDATE SET
+			requests = canary_metrics.requests + 1,
+			failures = canary_metrics.failures + excluded.failures,
+			outputs = canary_metrics.outputs + excluded.outputs,
+			repaired = canary_metrics.repaired + excluded.repaired,
+			invalid = canary_metrics.invalid + excluded.invalid,
+			confidence_sum = canary_metrics.confidence_sum + excluded.confidence_sum,
+			latency_ms_sum = canary_metrics.latency_ms_sum + excluded.latency_ms_sum`,
+		id, arm, failed, observation.Outputs, observation.Repaired, observation.Invalid,
+		confidence, observation.Latency.Milliseconds(),
+	)
+	return err
+}
+
+// loadCanaryStats reads the metrics of both arms of a canary and compares them
+func loadCanaryStats(canary *Canary) error {
+	rows, err := DB.Query(`
+		SELECT arm, requests, failures, outputs, repaired, invalid, confidence_sum, latency_ms_sum
+		FROM canary_metrics WHERE canary_id = ?`, canary.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var arm string
+		var stats CanaryStats
+		var confidenceSum float64
+		var latencySum int64
+		if err := rows.Scan(&arm, &stats.Requests, &stats.Failures, &stats.Outputs, &stats.Repaired,
+			&stats.Invalid, &confidenceSum, &latencySum); err != nil {
+			return err
+		}
+		if stats.Requests > 0 {
+			stats.FailureRate = float64(stats.Failures) / float64(stats.Requests)
+			stats.AverageLatencyMs = float64(latencySum) / float64(stats.Requests)
+		}
+		if succeeded := stats.Requests - stats.Failures; succeeded > 0 {
+			stats.AverageConfidence = confidenceSum / float64(succeeded)
+		}
+		if stats.Outputs > 0 {
+			stats.RepairRate = float64(stats.Repaired+stats.Invalid) / float64(stats.Outputs)
+		}
+
+		switch arm {
+		case CanaryArmBaseline:
+			canary.Baseline = stats
+		case CanaryArmCanary:
+			canary.Canary = stats
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	canary.Comparison = CanaryComparison{
+		FailureRateDelta:       canary.Canary.FailureRate - canary.Baseline.FailureRate,
+		RepairRateDelta:        canary.Canary.RepairRate - canary.Baseline.RepairRate,
+		AverageConfidenceDelta: canary.Canary.AverageConfidence - canary.Baseline.AverageConfidence,
+		AverageLatencyMsDelta:  canary.Canary.AverageLatencyMs - canary.Baseline.AverageLatencyMs,
+	}
+	return nil
+}
+
+// scanCanary reads a Canary from a row
+func scanCanary(row rowScanner) (*Canary, error) {
+	var canary Canary
+	var analysisTypes, model, instructions, createdBy, endedBy sql.NullString
+	var endedAt sql.NullTime
+	err := row.Scan(&canary.ID, &canary.Name, &analysisTypes, &canary.Percentage, &model, &instructions,
+		&canary.Status, &createdBy, &canary.CreatedAt, &endedAt, &endedBy)
+	if err != nil {
+		return nil, err
+	}
+	canary.Model = model.String
+	canary.PromptInstructions = instructions.String
+	canary.CreatedBy = createdBy.String
+	canary.EndedBy = endedBy.String
+	if endedAt.Valid {
+		canary.EndedAt = &endedAt.Time
+	}
+	if analysisTypes.String != "" {
+		if err := json.Unmarshal([]byte(analysisTypes.String), &canary.AnalysisTypes); err != nil {
+			return nil, err
+		}
+	}
+	if canary.AnalysisTypes == nil {
+		canary.AnalysisTypes = []string{}
+	}
+	return &canary, nil
+}
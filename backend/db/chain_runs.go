@@ -0,0 +1,169 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Chain run states
+const (
+	ChainRunRunning   = "running"
+	ChainRunSucceeded = "succeeded"
+	ChainRunFailed    = "failed"
+)
+
+// ChainRun is the progress of a chain analysis, recorded as its steps run
+type ChainRun struct {
+	RunID      string         `json:"run_id"`
+	WorkflowID string         `json:"workflow_id"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Steps      []ChainRunStep `json:"steps"`
+}
+
+// ChainRunStep is the progress of one step of a chain run: pending, running, succeeded,
+// failed, or skipped when an earlier step failed
+type ChainRunStep struct {
+	Step             string     `json:"step"`
+	StepNum          int        `json:"step_num"`
+	Status           string     `json:"status"`
+	StartedAt        *time.Time `json:"started_at,omitempty"`
+	DurationMS       int64      `json:"duration_ms"`
+	Calls            int        `json:"calls"`
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+	Cost             float64    `json:"cost"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// createChainRunsTables creates the chain_runs and chain_run_steps tables if they don't exist
+func createChainRunsTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS chain_runs (
+			run_id TEXT PRIMARY KEY,
+			workflow_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT,
+			started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS chain_run_steps (
+			run_id TEXT NOT NULL,
+			step TEXT NOT NULL,
+			step_num INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			started_at TIMESTAMP,
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			calls INTEGER NOT NULL DEFAULT 0,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			cost REAL NOT NULL DEFAULT 0,
+			error TEXT,
+			PRIMARY KEY (run_id, step)
+		)
+	`)
+	return err
+}
+
+// CreateChainRun records the start of a chain run for a tenant. It fails if the run ID
+// is taken.
+func CreateChainRun(tenantID, runID, workflowID string, startedAt time.Time) error {
+	if tenantID == "" {
+		tenantID = DefaultTenantID
+	}
+	_, err := DB.Exec(
+		"INSERT INTO chain_runs (run_id, tenant_id, workflow_id, status, started_at) VALUES (?, ?, ?, ?, ?)",
+		runID, tenantID, workflowID, ChainRunRunning, startedAt,
+	)
+	if err != nil && isUniqueViolation(err) {
+		return fmt.Errorf("chain run %s already exists", runID)
+	}
+	return err
+}
+
+// SaveChainRunStep records the progress of a step of a chain run
+func SaveChainRunStep(runID string, step ChainRunStep) error {
+	_, err := DB.Exec(`
+		INSERT INTO chain_run_steps (run_id, step, step_num, status, started_at, duration_ms, calls,
+			prompt_tokens, completion_tokens, cost, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(run_id, step) DO UPDATE SET step_num = excluded.step_num, status = excluded.status,
+			started_at = excluded.started_at, duration_ms = excluded.duration_ms, calls = excluded.calls,
+			prompt_tokens = excluded.prompt_tokens, completion_tokens = excluded.completion_tokens,
+			cost = excluded.cost, error = excluded.error`,
+		runID, step.Step, step.StepNum, step.Status, step.StartedAt, step.DurationMS, step.Calls,
+		step.PromptTokens, step.CompletionTokens, step.Cost, nullString(step.Error),
+	)
+	return err
+}
+
+// FinishChainRun records the end of a chain run
+func FinishChainRun(runID, status, runError string) error {
+	_, err := DB.Exec(
+		"UPDATE chain_runs SET status = ?, error = ?, finished_at = ? WHERE run_id = ?",
+		status, nullString(runError), time.Now(), runID,
+	)
+	return err
+}
+
+// GetChainRun returns a chain run of a tenant with its steps in chain order
+func GetChainRun(tenantID, runID string) (*ChainRun, error) {
+	run := ChainRun{Steps: []ChainRunStep{}}
+	var runError sql.NullString
+	var finishedAt sql.NullTime
+	condition, args := tenantCondition("tenant_id", tenantID)
+	err := DB.QueryRow(
+		"SELECT run_id, workflow_id, status, error, started_at, finished_at FROM chain_runs WHERE run_id = ?"+condition,
+		append([]interface{}{runID}, args...)...,
+	).Scan(&run.RunID, &run.WorkflowID, &run.Status, &runError, &run.StartedAt, &finishedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("chain run not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.Error = runError.String
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+
+	rows, err := DB.Query(`
+		SELECT step, step_num, status, started_at, duration_ms, calls, prompt_tokens, completion_tokens, cost, error
+		FROM chain_run_steps WHERE run_id = ? ORDER BY step_num`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var step ChainRunStep
+		var startedAt sql.NullTime
+		var stepError sql.NullString
+		if err := rows.Scan(&step.Step, &step.StepNum, &step.Status, &startedAt, &step.DurationMS, &step.Calls,
+			&step.PromptTokens, &step.CompletionTokens, &step.Cost, &stepError); err != nil {
+			return nil, err
+		}
+		if startedAt.Valid {
+			step.StartedAt = &startedAt.Time
+		}
+		step.Error = stepError.String
+		run.Steps = append(run.Steps, step)
+	}
+	return &run, rows.Err()
+}
+
+// isUniqueViolation reports whether an error is a primary key or unique constraint violation
+func isUniqueViolation(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "unique") || strings.Contains(message, "duplicate key")
+}
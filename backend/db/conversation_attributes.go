@@ -0,0 +1,373 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Conversation attribute types, matching the conversation databases the examples read
+const (
+	ConversationAttributeTypeAttribute = "attribute"
+	ConversationAttributeTypeIntent    = "intent"
+)
+
+// ConversationAttribute is a value extracted from a conversation
+type ConversationAttribute struct {
+	ConversationID string    `json:"conversation_id"`
+	Type           string    `json:"type"`
+	Name           string    `json:"name"`
+	Value          string    `json:"value"`
+	Description    string    `json:"description,omitempty"`
+	Confidence     float64   `json:"confidence"`
+	Explanation    string    `json:"explanation,omitempty"`
+	WorkflowID     string    `json:"workflow_id,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// createConversationAttributesTable creates the conversation_attributes table if it doesn't exist
+func createConversationAttributesTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS conversation_attributes (
+			conversation_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			name TEXT NOT NULL,
+			value TEXT,
+			description TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Conversation databases created by other tools may only have the core columns
+	for _, column := range [][2]string{
+		{"confidence", "REAL"},
+		{"explanation", "TEXT"},
+		{"workflow_id", "TEXT"},
+		{"updated_at", "TIMESTAMP"},
+	} {
+		if err := addColumnIfMissing("conversation_attributes", column[0], column[1]); err != nil {
+			return err
+		}
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_conversation_attributes_lookup ON conversation_attributes (conversation_id, type, name)")
+	return err
+}
+
+// ConversationAttributeRevision records a stored value that changed when the attribute
+// was extracted again, for example after the transcript was corrected
+type ConversationAttributeRevision struct {
+	ID                 int64     `json:"id"`
+	ConversationID     string    `json:"conversation_id"`
+	Type               string    `json:"type"`
+	Name               string    `json:"name"`
+	PreviousValue      string    `json:"previous_value"`
+	Value              string    `json:"value"`
+	PreviousConfidence float64   `json:"previous_confidence"`
+	Confidence         float64   `json:"confidence"`
+	Reason             string    `json:"reason"`
+	Explanation        string    `json:"explanation,omitempty"`
+	WorkflowID         string    `json:"workflow_id,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// createConversationAttributeRevisionsTable creates the conversation_attribute_revisions table if it doesn't exist
+func createConversationAttributeRevisionsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS conversation_attribute_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			name TEXT NOT NULL,
+			previous_value TEXT,
+			value TEXT,
+			previous_confidence REAL,
+			confidence REAL,
+			reason TEXT,
+			explanation TEXT,
+			workflow_id TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_conversation_attribute_revisions_conversation ON conversation_attribute_revisions (conversation_id, created_at)")
+	return err
+}
+
+// NormalizeConversationAttributeName turns an attribute name such as "Fee Type" into the
+// snake_case name SQL over conversation_attributes matches on, e.g. "fee_type"
+func NormalizeConversationAttributeName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), "_")
+}
+
+// NormalizeConversationAttributeType lowercases a value type, defaulting to
+// ConversationAttributeTypeAttribute and reading plurals such as "intents" as their type
+func NormalizeConversationAttributeType(attributeType string) string {
+	attributeType = strings.ToLower(strings.TrimSpace(attributeType))
+	switch attributeType {
+	case "", "attributes":
+		return ConversationAttributeTypeAttribute
+	case "intents":
+		return ConversationAttributeTypeIntent
+	}
+	return attributeType
+}
+
+// normalizeConversationAttributes normalizes the type and name of each value and keeps
+// the last value of each attribute of a conversation. Values without a name are dropped.
+func normalizeConversationAttributes(attributes []ConversationAttribute) []ConversationAttribute {
+	type key struct{ conversationID, attributeType, name string }
+	position := make(map[key]int, len(attributes))
+	normalized := make([]ConversationAttribute, 0, len(attributes))
+	for _, attribute := range attributes {
+		attribute.Type = NormalizeConversationAttributeType(attribute.Type)
+		attribute.Name = NormalizeConversationAttributeName(attribute.Name)
+		if attribute.ConversationID == "" || attribute.Name == "" {
+			continue
+		}
+		k := key{attribute.ConversationID, attribute.Type, attribute.Name}
+		if i, ok := position[k]; ok {
+			normalized[i] = attribute
+			continue
+		}
+		position[k] = len(normalized)
+		normalized = append(normalized, attribute)
+	}
+	return normalized
+}
+
+// SaveConversationAttributes upserts extracted values in a single transaction: types and
+// names are normalized, and earlier values of the same attribute for the same
+// conversation are updated in place. Replaced values that changed are recorded as
+// revisions with the reason, and the revisions are returned.
+func SaveConversationAttributes(attributes []ConversationAttribute, reason string) ([]ConversationAttributeRevision, error) {
+	revisions := []ConversationAttributeRevision{}
+	err := withTx(func(tx *Tx) error {
+		now := time.Now()
+		for _, attribute := range normalizeConversationAttributes(attributes) {
+			var previousValue sql.NullString
+			var previousConfidence sql.NullFloat64
+			err := tx.QueryRow(
+				"SELECT value, confidence FROM conversation_attributes WHERE conversation_id = ? AND type = ? AND name = ? LIMIT 1",
+				attribute.ConversationID, attribute.Type, attribute.Name,
+			).Scan(&previousValue, &previousConfidence)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			exists := err == nil
+			if exists && previousValue.String != attribute.Value {
+				revision := ConversationAttributeRevision{
+					ConversationID:     attribute.ConversationID,
+					Type:               attribute.Type,
+					Name:               attribute.Name,
+					PreviousValue:      previousValue.String,
+					Value:              attribute.Value,
+					PreviousConfidence: previousConfidence.Float64,
+					Confidence:         attribute.Confidence,
+					Reason:             reason,
+					Explanation:        attribute.Explanation,
+					WorkflowID:         attribute.WorkflowID,
+					CreatedAt:          now,
+				}
+				err := tx.QueryRow(
+					`INSERT INTO conversation_attribute_revisions
+					(conversation_id, type, name, previous_value, value, previous_confidence, confidence, reason, explanation, workflow_id, created_at)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+					revision.ConversationID, revision.Type, revision.Name, revision.PreviousValue, revision.Value,
+					revision.PreviousConfidence, revision.Confidence, revision.Reason, revision.Explanation, revision.WorkflowID, now,
+				).Scan(&revision.ID)
+				if err != nil {
+					return err
+				}
+				revisions = append(revisions, revision)
+			}
+
+			if exists {
+				_, err = tx.Exec(
+					`UPDATE conversation_attributes
+					SET value = ?, description = ?, confidence = ?, explanation = ?, workflow_id = ?, updated_at = ?
+					WHERE conversation_id = ? AND type = ? AND name = ?`,
+					attribute.Value, attribute.Description, attribute.Confidence, attribute.Explanation, attribute.WorkflowID, now,
+					attribute.ConversationID, attribute.Type, attribute.Name,
+				)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			_, err = tx.Exec(
+				`INSERT INTO conversation_attributes
+				(conversation_id, type, name, value, description, confidence, explanation, workflow_id, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				attribute.ConversationID, attribute.Type, attribute.Name, attribute.Value, attribute.Description,
+				attribute.Confidence, attribute.Explanation, attribute.WorkflowID, now,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetConversationAttributeRevisions returns the revisions of a conversation's attribute
+// values, oldest first
+func GetConversationAttributeRevisions(conversationID string) ([]ConversationAttributeRevision, error) {
+	rows, err := DB.Query(
+		`SELECT id, conversation_id, type, name, previous_value, value, previous_confidence, confidence, reason, explanation, workflow_id, created_at
+		FROM conversation_attribute_revisions WHERE conversation_id = ? ORDER BY created_at, id`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []ConversationAttributeRevision{}
+	for rows.Next() {
+		var revision ConversationAttributeRevision
+		var previousValue, value, reason, explanation, workflowID sql.NullString
+		var previousConfidence, confidence sql.NullFloat64
+		if err := rows.Scan(
+			&revision.ID, &revision.ConversationID, &revision.Type, &revision.Name, &previousValue, &value,
+			&previousConfidence, &confidence, &reason, &explanation, &workflowID, &revision.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		revision.PreviousValue = previousValue.String
+		revision.Value = value.String
+		revision.PreviousConfidence = previousConfidence.Float64
+		revision.Confidence = confidence.Float64
+		revision.Reason = reason.String
+		revision.Explanation = explanation.String
+		revision.WorkflowID = workflowID.String
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetConversationAttributes returns the values extracted from a conversation
+func GetConversationAttributes(conversationID string) ([]ConversationAttribute, error) {
+	rows, err := DB.Query(
+		`SELECT conversation_id, type, name, value, description, confidence, explanation, workflow_id, updated_at
+		FROM conversation_attributes WHERE conversation_id = ? ORDER BY type, name`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributes := []ConversationAttribute{}
+	for rows.Next() {
+		var attribute ConversationAttribute
+		var value, description, explanation, workflowID sql.NullString
+		var confidence sql.NullFloat64
+		var updatedAt sql.NullTime
+		if err := rows.Scan(
+			&attribute.ConversationID, &attribute.Type, &attribute.Name, &value, &description,
+			&confidence, &explanation, &workflowID, &updatedAt,
+		); err != nil {
+			return nil, err
+		}
+		attribute.Value = value.String
+		attribute.Description = description.String
+		attribute.Confidence = confidence.Float64
+		attribute.Explanation = explanation.String
+		attribute.WorkflowID = workflowID.String
+		attribute.UpdatedAt = updatedAt.Time
+		attributes = append(attributes, attribute)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attributes, nil
+}
+
+// StoredIntent is an intent conversations are classified with and how many are
+type StoredIntent struct {
+	Intent        string `json:"intent"`
+	Conversations int    `json:"conversations"`
+}
+
+// ListStoredIntents returns the distinct intents stored in conversation_attributes for the
+// conversations of a tenant, most common first
+func ListStoredIntents(tenantID string) ([]StoredIntent, error) {
+	condition, args := conversationTenantCondition("conversation_id", tenantID)
+	rows, err := DB.Query(`
+		SELECT value, COUNT(DISTINCT conversation_id) AS conversations
+		FROM conversation_attributes
+		WHERE type = ? AND value IS NOT NULL AND value <> ''`+condition+`
+		GROUP BY value ORDER BY conversations DESC, value`, append([]interface{}{ConversationAttributeTypeIntent}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	intents := []StoredIntent{}
+	for rows.Next() {
+		var intent StoredIntent
+		if err := rows.Scan(&intent.Intent, &intent.Conversations); err != nil {
+			return nil, err
+		}
+		intents = append(intents, intent)
+	}
+	return intents, rows.Err()
+}
+
+// GetConversationsByIntent returns the conversations matching the filter that are
+// classified with one of the intents, mapped to their intent. Intents are compared
+// case-insensitively. Limit and Offset are ignored.
+func GetConversationsByIntent(intents []string, filter ConversationFilter) (map[string]string, error) {
+	byConversation := map[string]string{}
+	if len(intents) == 0 {
+		return byConversation, nil
+	}
+
+	where, filterArgs := conversationWhere(filter)
+	args := []interface{}{ConversationAttributeTypeIntent}
+	for _, intent := range intents {
+		args = append(args, strings.ToLower(intent))
+	}
+	args = append(args, filterArgs...)
+
+	rows, err := DB.Query(`
+		SELECT conversation_id, value FROM conversation_attributes
+		WHERE type = ? AND LOWER(value) IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(intents)), ", ")+`)
+			AND conversation_id IN (SELECT conversation_id FROM conversations`+where+`)
+		ORDER BY conversation_id, name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conversationID, intent string
+		if err := rows.Scan(&conversationID, &intent); err != nil {
+			return nil, err
+		}
+		if _, ok := byConversation[conversationID]; !ok {
+			byConversation[conversationID] = intent
+		}
+	}
+	return byConversation, rows.Err()
+}
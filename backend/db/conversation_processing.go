@@ -0,0 +1,147 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// ConversationProcessing is what the last analysis of a type took for a conversation.
+// Outliers are windowed on later runs of the analysis.
+type ConversationProcessing struct {
+	ConversationID   string    `json:"conversation_id"`
+	AnalysisType     string    `json:"analysis_type"`
+	WorkflowID       string    `json:"workflow_id,omitempty"`
+	Calls            int       `json:"calls"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated,omitempty"`
+	Cost             float64   `json:"cost"`
+	LatencyMS        int64     `json:"latency_ms"`
+	TextLength       int       `json:"text_length"`
+	Windowed         bool      `json:"windowed,omitempty"`
+	Outlier          bool      `json:"outlier,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// conversationProcessingColumns are the columns read into a ConversationProcessing, in scan order
+const conversationProcessingColumns = `p.conversation_id, p.analysis_type, COALESCE(p.workflow_id, ''), p.calls,
+	p.prompt_tokens, p.completion_tokens, p.estimated, p.cost, p.latency_ms, p.text_length, p.windowed, p.outlier, p.updated_at`
+
+// createConversationProcessingTable creates the conversation_processing table if it doesn't exist
+func createConversationProcessingTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS conversation_processing (
+			conversation_id TEXT NOT NULL,
+			analysis_type TEXT NOT NULL,
+			workflow_id TEXT,
+			calls INTEGER NOT NULL DEFAULT 0,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			estimated BOOLEAN NOT NULL DEFAULT FALSE,
+			cost REAL NOT NULL DEFAULT 0,
+			latency_ms INTEGER NOT NULL DEFAULT 0,
+			text_length INTEGER NOT NULL DEFAULT 0,
+			windowed BOOLEAN NOT NULL DEFAULT FALSE,
+			outlier BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (conversation_id, analysis_type)
+		)
+	`)
+	return err
+}
+
+// SaveConversationProcessing stores the processing of conversations, replacing what
+// earlier runs of the same analysis recorded
+func SaveConversationProcessing(records []ConversationProcessing) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return withTx(func(tx *Tx) error {
+		stmt, err := tx.Prepare(`
+			INSERT INTO conversation_processing (conversation_id, analysis_type, workflow_id, calls, prompt_tokens,
+				completion_tokens, estimated, cost, latency_ms, text_length, windowed, outlier, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(conversation_id, analysis_type) DO UPDATE SET workflow_id = excluded.workflow_id,
+				calls = excluded.calls, prompt_tokens = excluded.prompt_tokens, completion_tokens = excluded.completion_tokens,
+				estimated = excluded.estimated, cost = excluded.cost, latency_ms = excluded.latency_ms,
+				text_length = excluded.text_length, windowed = excluded.windowed, outlier = excluded.outlier,
+				updated_at = excluded.updated_at`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		now := time.Now()
+		for _, record := range records {
+			if _, err := stmt.Exec(
+				record.ConversationID, record.AnalysisType, record.WorkflowID, record.Calls, record.PromptTokens,
+				record.CompletionTokens, record.Estimated, record.Cost, record.LatencyMS, record.TextLength,
+				record.Windowed, record.Outlier, now,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetConversationProcessing returns the recorded processing of conversations by an
+// analysis type, keyed by conversation ID. Records older than the conversation's last
+// update are left out, since its text changed since.
+func GetConversationProcessing(analysisType string, ids []string) (map[string]ConversationProcessing, error) {
+	byID := make(map[string]ConversationProcessing, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, analysisType)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	records, err := queryConversationProcessing(`
+		SELECT `+conversationProcessingColumns+`
+		FROM conversation_processing p JOIN conversations c ON c.conversation_id = p.conversation_id
+		WHERE p.analysis_type = ? AND p.conversation_id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")+`)
+		AND (c.updated_at IS NULL OR p.updated_at >= c.updated_at)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		byID[record.ConversationID] = record
+	}
+	return byID, nil
+}
+
+// GetProcessingOfConversation returns the recorded processing of a conversation by each
+// analysis type, as recorded by the tenant's workflows or for its stored conversation
+func GetProcessingOfConversation(tenantID, conversationID string) ([]ConversationProcessing, error) {
+	condition, args := conversationRecordCondition("p.workflow_id", "p.conversation_id", tenantID)
+	return queryConversationProcessing(`
+		SELECT `+conversationProcessingColumns+`
+		FROM conversation_processing p WHERE p.conversation_id = ?`+condition+` ORDER BY p.analysis_type`,
+		append([]interface{}{conversationID}, args...)...)
+}
+
+// queryConversationProcessing runs a processing query
+func queryConversationProcessing(query string, args ...interface{}) ([]ConversationProcessing, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []ConversationProcessing{}
+	for rows.Next() {
+		var record ConversationProcessing
+		if err := rows.Scan(
+			&record.ConversationID, &record.AnalysisType, &record.WorkflowID, &record.Calls,
+			&record.PromptTokens, &record.CompletionTokens, &record.Estimated, &record.Cost,
+			&record.LatencyMS, &record.TextLength, &record.Windowed, &record.Outlier, &record.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
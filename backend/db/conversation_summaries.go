@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// ConversationSummary is a cached summary of a conversation text. Summaries are keyed by
+// a hash of the text they summarize and the length they were written to, so a changed
+// conversation is summarized again; ConversationID traces the summary back to its
+// conversation when it has one.
+type ConversationSummary struct {
+	TextHash       string    `json:"text_hash"`
+	MaxChars       int       `json:"max_chars"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Summary        string    `json:"summary"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// createConversationSummariesTable creates the conversation_summaries table if it doesn't exist
+func createConversationSummariesTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS conversation_summaries (
+			text_hash TEXT NOT NULL,
+			max_chars INTEGER NOT NULL,
+			conversation_id TEXT,
+			summary TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (text_hash, max_chars)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_conversation_summaries_conversation ON conversation_summaries (conversation_id)")
+	return err
+}
+
+// SaveConversationSummary caches a summary, replacing an earlier summary of the same text
+// and length
+func SaveConversationSummary(summary ConversationSummary) error {
+	_, err := DB.Exec(
+		`INSERT INTO conversation_summaries (text_hash, max_chars, conversation_id, summary, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(text_hash, max_chars) DO UPDATE SET conversation_id = COALESCE(excluded.conversation_id, conversation_summaries.conversation_id),
+		summary = excluded.summary, created_at = excluded.created_at`,
+		summary.TextHash, summary.MaxChars, nullString(summary.ConversationID), summary.Summary, time.Now(),
+	)
+	return err
+}
+
+// GetConversationSummaries returns the cached summaries of texts written to a length,
+// keyed by text hash
+func GetConversationSummaries(textHashes []string, maxChars int) (map[string]ConversationSummary, error) {
+	summaries := make(map[string]ConversationSummary, len(textHashes))
+	if len(textHashes) == 0 {
+		return summaries, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(textHashes)), ", ")
+	args := make([]interface{}, 0, len(textHashes)+1)
+	args = append(args, maxChars)
+	for _, hash := range textHashes {
+		args = append(args, hash)
+	}
+
+	rows, err := DB.Query(
+		`SELECT text_hash, max_chars, conversation_id, summary, created_at FROM conversation_summaries
+		WHERE max_chars = ? AND text_hash IN (`+placeholders+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var summary ConversationSummary
+		var conversationID sql.NullString
+		if err := rows.Scan(&summary.TextHash, &summary.MaxChars, &conversationID, &summary.Summary, &summary.CreatedAt); err != nil {
+			return nil, err
+		}
+		summary.ConversationID = conversationID.String
+		summaries[summary.TextHash] = summary
+	}
+	return summaries, rows.Err()
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage tiers of conversation text
+const (
+	StorageTierHot = "hot"
+	// StorageTierCold keeps the text compressed in cold storage, outside the database
+	StorageTierCold = "cold"
+	// StorageTierDropped has discarded the text; attributes and results derived from it remain
+	StorageTierDropped = "dropped"
+)
+
+// envColdStorageDir sets where cold conversation text is stored
+const envColdStorageDir = "CONVERSATION_COLD_STORAGE_DIR"
+
+// defaultColdStorageDir is next to the database
+const defaultColdStorageDir = "data/cold"
+
+// TieringResult reports the conversations a tiering run moved out of the hot tier
+type TieringResult struct {
+	Moved  int       `json:"moved"`
+	Freed  int64     `json:"freed_bytes"` // Text removed from the database
+	Cutoff time.Time `json:"cutoff"`
+	Tier   string    `json:"tier"`
+	DryRun bool      `json:"dry_run,omitempty"`
+}
+
+// TierCounts is the number of conversations in each storage tier
+type TierCounts map[string]int
+
+// addConversationTierColumns adds the storage tier columns to the conversations table
+func addConversationTierColumns() error {
+	if err := addColumnIfMissing("conversations", "storage_tier", "TEXT NOT NULL DEFAULT 'hot'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("conversations", "archived_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+	_, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_conversations_storage_tier ON conversations (storage_tier)")
+	return err
+}
+
+// ColdStorageDir returns the directory holding cold conversation text
+func ColdStorageDir() string {
+	if dir := strings.TrimSpace(os.Getenv(envColdStorageDir)); dir != "" {
+		return dir
+	}
+	return defaultColdStorageDir
+}
+
+// coldStoragePath returns the file holding the cold text of a conversation. Files are
+// spread over subdirectories by a hash of the ID, which may contain any characters.
+func coldStoragePath(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(ColdStorageDir(), name[:2], name+".txt.gz")
+}
+
+// writeColdText stores conversation text compressed in cold storage
+func writeColdText(id, text string) error {
+	path := coldStoragePath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a failed write never replaces good text
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cold-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	zw.Name = id
+	if _, err := io.WriteString(zw, text); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readColdText loads conversation text from cold storage
+func readColdText(id string) (string, error) {
+	file, err := os.Open(coldStoragePath(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to open cold text of conversation %s: %w", id, err)
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cold text of conversation %s: %w", id, err)
+	}
+	defer zr.Close()
+
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cold text of conversation %s: %w", id, err)
+	}
+	return string(text), nil
+}
+
+// removeColdText deletes the cold text of a conversation, if any
+func removeColdText(id string) error {
+	err := os.Remove(coldStoragePath(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// rehydrate loads the text of a conversation in cold storage
+func (c *Conversation) rehydrate() error {
+	if c.StorageTier != StorageTierCold {
+		return nil
+	}
+	text, err := readColdText(c.ID)
+	if err != nil {
+		return err
+	}
+	c.Text = text
+	return nil
+}
+
+// TierConversations moves the text of hot conversations that took place before cutoff to
+// tier, at most limit conversations per call. Cold text is written before the database
+// row is cleared, so an interrupted run never loses text.
+func TierConversations(cutoff time.Time, tier string, limit int, dryRun bool) (TieringResult, error) {
+	result := TieringResult{Cutoff: cutoff, Tier: tier, DryRun: dryRun}
+	if tier != StorageTierCold && tier != StorageTierDropped {
+		return result, fmt.Errorf("unsupported storage tier: %s", tier)
+	}
+
+	rows, err := DB.Query(
+		`SELECT conversation_id, text FROM conversations
+		WHERE storage_tier = ? AND COALESCE(date_time, created_at) < ?
+		ORDER BY COALESCE(date_time, created_at) LIMIT ?`,
+		StorageTierHot, cutoff, limit,
+	)
+	if err != nil {
+		return result, err
+	}
+	type candidate struct{ id, text string }
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.text); err != nil {
+			rows.Close()
+			return result, err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	for _, c := range candidates {
+		if !dryRun {
+			if tier == StorageTierCold {
+				if err := writeColdText(c.id, c.text); err != nil {
+					return result, fmt.Errorf("failed to archive conversation %s: %w", c.id, err)
+				}
+			}
+			// Only clear rows whose text is unchanged since it was archived
+			res, err := DB.Exec(
+				"UPDATE conversations SET text = '', storage_tier = ?, archived_at = ? WHERE conversation_id = ? AND storage_tier = ? AND text = ?",
+				tier, now, c.id, StorageTierHot, c.text,
+			)
+			if err != nil {
+				return result, err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				// The conversation was re-ingested meanwhile and stays hot
+				if tier == StorageTierCold {
+					removeColdText(c.id)
+				}
+				continue
+			}
+		}
+		result.Moved++
+		result.Freed += int64(len(c.text))
+	}
+	return result, nil
+}
+
+// CountConversationTiers returns the number of conversations in each storage tier
+func CountConversationTiers() (TierCounts, error) {
+	rows, err := DB.Query("SELECT storage_tier, COUNT(*) FROM conversations GROUP BY storage_tier")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := TierCounts{StorageTierHot: 0, StorageTierCold: 0, StorageTierDropped: 0}
+	for rows.Next() {
+		var tier string
+		var n int
+		if err := rows.Scan(&tier, &n); err != nil {
+			return nil, err
+		}
+		counts[tier] = n
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// ConversationTranslation is the text of a stored conversation translated into another
+// language. The original text stays in the conversations table.
+type ConversationTranslation struct {
+	ConversationID string    `json:"conversation_id"`
+	SourceLanguage string    `json:"source_language"`
+	TargetLanguage string    `json:"target_language"`
+	Text           string    `json:"text"`
+	Provider       string    `json:"provider,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// createConversationTranslationsTable creates the conversation_translations table if it doesn't exist
+func createConversationTranslationsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS conversation_translations (
+			conversation_id TEXT NOT NULL,
+			target_language TEXT NOT NULL,
+			source_language TEXT,
+			text TEXT NOT NULL,
+			provider TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (conversation_id, target_language)
+		)
+	`)
+	return err
+}
+
+// SaveConversationTranslation stores a translation, replacing an earlier translation of
+// the conversation into the same language
+func SaveConversationTranslation(translation ConversationTranslation) error {
+	_, err := DB.Exec(
+		`INSERT INTO conversation_translations (conversation_id, target_language, source_language, text, provider, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(conversation_id, target_language) DO UPDATE SET source_language = excluded.source_language,
+		text = excluded.text, provider = excluded.provider, created_at = excluded.created_at`,
+		translation.ConversationID, translation.TargetLanguage, translation.SourceLanguage,
+		translation.Text, translation.Provider, time.Now(),
+	)
+	return err
+}
+
+// GetConversationTranslations returns the translations of conversations into a language,
+// keyed by conversation ID. Translations older than the conversation's last update are
+// left out, since they no longer match its text.
+func GetConversationTranslations(ids []string, targetLanguage string) (map[string]ConversationTranslation, error) {
+	translations := make(map[string]ConversationTranslation, len(ids))
+	if len(ids) == 0 {
+		return translations, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, targetLanguage)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := DB.Query(
+		`SELECT t.conversation_id, t.source_language, t.target_language, t.text, t.provider, t.created_at
+		FROM conversation_translations t JOIN conversations c ON c.conversation_id = t.conversation_id
+		WHERE t.target_language = ? AND t.conversation_id IN (`+placeholders+`)
+		AND (c.updated_at IS NULL OR t.created_at >= c.updated_at)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var translation ConversationTranslation
+		var sourceLanguage, provider sql.NullString
+		if err := rows.Scan(
+			&translation.ConversationID, &sourceLanguage, &translation.TargetLanguage,
+			&translation.Text, &provider, &translation.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		translation.SourceLanguage = sourceLanguage.String
+		translation.Provider = provider.String
+		translations[translation.ConversationID] = translation
+	}
+	return translations, rows.Err()
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Conversation upload states
+const (
+	UploadReceiving = "receiving" // Chunks are being uploaded
+	UploadIngesting = "ingesting" // The file is parsed and stored in the background
+	UploadCompleted = "completed"
+	UploadFailed    = "failed"  // Ingestion stopped; completing the upload again retries it
+	UploadExpired   = "expired" // The upload was abandoned and its file deleted
+	UploadCanceled  = "canceled"
+)
+
+// Conversation upload formats
+const (
+	UploadFormatCSV   = "csv"
+	UploadFormatJSONL = "jsonl"
+)
+
+// ConversationUpload is a file of conversations uploaded for ingestion. Rows counts
+// the records read so far; each is ingested or invalid.
+type ConversationUpload struct {
+	ID            string           `json:"id"`
+	Filename      string           `json:"filename,omitempty"`
+	Format        string           `json:"format"`
+	Source        string           `json:"source,omitempty"` // Source of the conversations that don't name one
+	Status        string           `json:"status"`
+	ReceivedBytes int64            `json:"received_bytes"`
+	Rows          int              `json:"rows"`
+	Ingested      int              `json:"ingested"`
+	Created       int              `json:"created"`
+	Updated       int              `json:"updated"`
+	Invalid       int              `json:"invalid"`
+	Errors        []UploadRowError `json:"errors,omitempty"` // The first invalid rows
+	Error         string           `json:"error,omitempty"`  // Why ingestion failed
+	Actor         string           `json:"actor,omitempty"`
+	TenantID      string           `json:"-"`
+	Instance      string           `json:"instance"` // Server instance holding the file
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	CompletedAt   *time.Time       `json:"completed_at,omitempty"`
+}
+
+// UploadRowError is a row of an upload that was not ingested
+type UploadRowError struct {
+	Row            int    `json:"row"` // Line of the row in the file, counting from 1
+	ConversationID string `json:"conversation_id,omitempty"`
+	Error          string `json:"error"`
+}
+
+// conversationUploadColumns are the columns read by scanConversationUpload
+const conversationUploadColumns = "id, filename, format, source, status, received_bytes, rows_read, ingested, created, updated, invalid, errors, error, actor, tenant_id, instance, created_at, updated_at, completed_at"
+
+// createConversationUploadsTable creates the conversation uploads table if it doesn't exist
+func createConversationUploadsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS conversation_uploads (
+			id TEXT PRIMARY KEY,
+			filename TEXT,
+			format TEXT NOT NULL,
+			source TEXT,
+			status TEXT NOT NULL,
+			received_bytes INTEGER NOT NULL DEFAULT 0,
+			rows_read INTEGER NOT NULL DEFAULT 0,
+			ingested INTEGER NOT NULL DEFAULT 0,
+			created INTEGER NOT NULL DEFAULT 0,
+			updated INTEGER NOT NULL DEFAULT 0,
+			invalid INTEGER NOT NULL DEFAULT 0,
+			errors TEXT,
+			error TEXT,
+			actor TEXT,
+			instance TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			completed_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_conversation_uploads_status ON conversation_uploads (status, updated_at)")
+	return err
+}
+
+// CreateConversationUpload stores a new upload
+func CreateConversationUpload(upload ConversationUpload) error {
+	now := time.Now()
+	_, err := DB.Exec(
+		`INSERT INTO conversation_uploads (id, filename, format, source, status, actor, tenant_id, instance, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		upload.ID, nullString(upload.Filename), upload.Format, nullString(upload.Source), upload.Status,
+		nullString(upload.Actor), upload.TenantID, upload.Instance, now, now,
+	)
+	return err
+}
+
+// GetConversationUpload returns an upload of a tenant, or nil if it doesn't exist
+func GetConversationUpload(tenantID, id string) (*ConversationUpload, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	upload, err := scanConversationUpload(DB.QueryRow("SELECT "+conversationUploadColumns+" FROM conversation_uploads WHERE id = ?"+condition, append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return upload, err
+}
+
+// ListConversationUploads returns the most recent uploads of a tenant, newest first
+func ListConversationUploads(tenantID string, limit int) ([]ConversationUpload, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	condition, args := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query("SELECT "+conversationUploadColumns+" FROM conversation_uploads WHERE 1 = 1"+condition+" ORDER BY created_at DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uploads := []ConversationUpload{}
+	for rows.Next() {
+		upload, err := scanConversationUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, *upload)
+	}
+	return uploads, rows.Err()
+}
+
+// SetConversationUploadReceived records how many bytes of an upload were received
+func SetConversationUploadReceived(id string, receivedBytes int64) error {
+	_, err := DB.Exec("UPDATE conversation_uploads SET received_bytes = ?, updated_at = ? WHERE id = ?", receivedBytes, time.Now(), id)
+	return err
+}
+
+// UpdateConversationUpload saves the status, progress and validation report of an upload
+func UpdateConversationUpload(upload ConversationUpload) error {
+	errors, err := json.Marshal(upload.Errors)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(
+		`UPDATE conversation_uploads SET status = ?, rows_read = ?, ingested = ?, created = ?, updated = ?, invalid = ?,
+			errors = ?, error = ?, instance = ?, updated_at = ?, completed_at = ?
+		WHERE id = ?`,
+		upload.Status, upload.Rows, upload.Ingested, upload.Created, upload.Updated, upload.Invalid,
+		string(errors), nullString(upload.Error), upload.Instance, time.Now(), upload.CompletedAt, upload.ID,
+	)
+	return err
+}
+
+// InterruptConversationUploads fails the uploads an instance was ingesting when it
+// stopped and returns how many there were
+func InterruptConversationUploads(instance, reason string) (int64, error) {
+	result, err := DB.Exec(
+		"UPDATE conversation_uploads SET status = ?, error = ?, updated_at = ? WHERE status = ? AND instance = ?",
+		UploadFailed, reason, time.Now(), UploadIngesting, instance,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ExpireConversationUploads marks the uploads of an instance that were abandoned while
+// receiving or after failing before a time as expired and returns their IDs, so their
+// files can be deleted
+func ExpireConversationUploads(instance string, before time.Time) ([]string, error) {
+	var ids []string
+	err := withTx(func(tx *Tx) error {
+		rows, err := tx.Query(
+			"SELECT id FROM conversation_uploads WHERE status IN (?, ?) AND instance = ? AND updated_at < ?",
+			UploadReceiving, UploadFailed, instance, before,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if _, err := tx.Exec("UPDATE conversation_uploads SET status = ?, updated_at = ? WHERE id = ?", UploadExpired, time.Now(), id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// scanConversationUpload reads an upload selected with conversationUploadColumns
+func scanConversationUpload(row rowScanner) (*ConversationUpload, error) {
+	var upload ConversationUpload
+	var filename, source, errors, errorText, actor sql.NullString
+	var completedAt sql.NullTime
+	err := row.Scan(&upload.ID, &filename, &upload.Format, &source, &upload.Status, &upload.ReceivedBytes,
+		&upload.Rows, &upload.Ingested, &upload.Created, &upload.Updated, &upload.Invalid, &errors, &errorText,
+		&actor, &upload.TenantID, &upload.Instance, &upload.CreatedAt, &upload.UpdatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	upload.Filename = filename.String
+	upload.Source = source.String
+	upload.Error = errorText.String
+	upload.Actor = actor.String
+	if errors.Valid && errors.String != "" {
+		if err := json.Unmarshal([]byte(errors.String), &upload.Errors); err != nil {
+			return nil, err
+		}
+	}
+	if completedAt.Valid {
+		upload.CompletedAt = &completedAt.Time
+	}
+	return &upload, nil
+}
@@ -0,0 +1,361 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Conversation is a customer conversation stored for analysis
+type Conversation struct {
+	ID         string                 `json:"conversation_id"`
+	CustomerID string                 `json:"customer_id,omitempty"` // The customer the conversation is with; metadata.customer_id when not set
+	Text       string                 `json:"text"`
+	DateTime   *time.Time             `json:"date_time,omitempty"` // When the conversation took place
+	Source     string                 `json:"source,omitempty"`    // Where the conversation was imported from, e.g. a contact center export
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+
+	// StorageTier is where the text is kept; cold text is loaded when a conversation is
+	// retrieved by ID, and dropped text is gone
+	StorageTier string `json:"storage_tier,omitempty"`
+}
+
+// ErrConversationIDTaken is returned when a conversation ID is used by another tenant
+var ErrConversationIDTaken = errors.New("conversation ID is not available")
+
+// ConversationFilter narrows down the conversations returned by ListConversations
+type ConversationFilter struct {
+	TenantID   string // The tenant whose conversations are returned; AllTenants returns those of every tenant
+	Source     string
+	CustomerID string
+	Search     string // Substring of the conversation text
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+	Offset     int
+}
+
+// conversationColumns are the columns read by scanConversation
+const conversationColumns = "conversation_id, customer_id, text, date_time, source, metadata, created_at, updated_at, storage_tier"
+
+// createConversationsTable creates the conversations table if it doesn't exist. Its core
+// columns match the conversation databases the examples read.
+func createConversationsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS conversations (
+			conversation_id TEXT PRIMARY KEY,
+			text TEXT NOT NULL,
+			date_time TIMESTAMP,
+			source TEXT,
+			metadata TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Conversation databases created by other tools may only have the core columns
+	for _, column := range [][2]string{
+		{"date_time", "TIMESTAMP"},
+		{"source", "TEXT"},
+		{"metadata", "TEXT"},
+		{"created_at", "TIMESTAMP"},
+		{"updated_at", "TIMESTAMP"},
+	} {
+		if err := addColumnIfMissing("conversations", column[0], column[1]); err != nil {
+			return err
+		}
+	}
+
+	if err := addConversationTierColumns(); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("conversations", "customer_id", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_conversations_customer_id ON conversations (customer_id)"); err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_conversations_date_time ON conversations (date_time)")
+	return err
+}
+
+// IngestConversations inserts conversations of a tenant, replacing its stored conversations
+// with the same ID, in a single transaction. It returns how many were created and how many
+// replaced. Replaced conversations return to the hot tier. IDs taken by conversations of
+// other tenants are rejected without revealing that they are.
+func IngestConversations(tenantID string, conversations []Conversation) (created int, updated int, err error) {
+	var replaced []string
+	err = withTx(func(tx *Tx) error {
+		now := time.Now()
+		for _, conversation := range conversations {
+			metadata, err := json.Marshal(conversation.Metadata)
+			if err != nil {
+				return fmt.Errorf("failed to marshal metadata of conversation %s: %w", conversation.ID, err)
+			}
+
+			customerID := conversation.CustomerID
+			if customerID == "" {
+				customerID, _ = conversation.Metadata["customer_id"].(string)
+			}
+
+			result, err := tx.Exec(
+				`UPDATE conversations SET customer_id = ?, text = ?, date_time = ?, source = ?, metadata = ?, updated_at = ?,
+				storage_tier = ?, archived_at = NULL
+				WHERE conversation_id = ? AND tenant_id = ?`,
+				nullString(customerID), conversation.Text, conversation.DateTime, conversation.Source, string(metadata), now,
+				StorageTierHot, conversation.ID, tenantID,
+			)
+			if err != nil {
+				return err
+			}
+			if n, err := result.RowsAffected(); err != nil {
+				return err
+			} else if n > 0 {
+				updated++
+				replaced = append(replaced, conversation.ID)
+				continue
+			}
+
+			var taken bool
+			if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM conversations WHERE conversation_id = ?)", conversation.ID).Scan(&taken); err != nil {
+				return err
+			}
+			if taken {
+				return fmt.Errorf("%w: %s", ErrConversationIDTaken, conversation.ID)
+			}
+
+			_, err = tx.Exec(
+				`INSERT INTO conversations (conversation_id, customer_id, text, date_time, source, metadata, created_at, updated_at, tenant_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				conversation.ID, nullString(customerID), conversation.Text, conversation.DateTime, conversation.Source, string(metadata), now, now,
+				tenantID,
+			)
+			if err != nil {
+				return err
+			}
+			created++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, id := range replaced {
+		if err := removeColdText(id); err != nil {
+			return created, updated, fmt.Errorf("failed to remove cold text of conversation %s: %w", id, err)
+		}
+	}
+	return created, updated, nil
+}
+
+// ConversationOfOtherTenant reports whether a conversation ID is stored by a tenant other
+// than tenantID, whose records derived from it must not be shown
+func ConversationOfOtherTenant(tenantID, id string) (bool, error) {
+	if tenantID == AllTenants {
+		return false, nil
+	}
+	var taken bool
+	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM conversations WHERE conversation_id = ? AND tenant_id <> ?)", id, tenantID).Scan(&taken)
+	return taken, err
+}
+
+// GetConversation retrieves a conversation of a tenant by ID, loading its text from cold
+// storage
+func GetConversation(tenantID, id string) (*Conversation, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	conversation, err := scanConversation(DB.QueryRow(
+		"SELECT "+conversationColumns+" FROM conversations WHERE conversation_id = ?"+condition,
+		append([]interface{}{id}, args...)...,
+	))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("conversation not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conversation.rehydrate(); err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+// GetConversations retrieves the conversations of a tenant with the given IDs in the order
+// of ids, skipping IDs that are not stored for the tenant. Text in cold storage is loaded.
+func GetConversations(tenantID string, ids []string) ([]Conversation, error) {
+	if len(ids) == 0 {
+		return []Conversation{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	condition, tenantArgs := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query("SELECT "+conversationColumns+" FROM conversations WHERE conversation_id IN ("+placeholders+")"+condition, append(args, tenantArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[string]Conversation, len(ids))
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		if err := conversation.rehydrate(); err != nil {
+			return nil, err
+		}
+		byID[conversation.ID] = *conversation
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	conversations := make([]Conversation, 0, len(byID))
+	for _, id := range ids {
+		if conversation, ok := byID[id]; ok {
+			conversations = append(conversations, conversation)
+			delete(byID, id)
+		}
+	}
+	return conversations, nil
+}
+
+// ListConversations returns conversations matching the filter, most recent first, and
+// the total number of matching conversations. Text in cold storage is not loaded, and
+// Search only matches hot text.
+func ListConversations(filter ConversationFilter) ([]Conversation, int, error) {
+	where, args := conversationWhere(filter)
+
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM conversations"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query := "SELECT " + conversationColumns + " FROM conversations" + where +
+		" ORDER BY COALESCE(date_time, created_at) DESC, conversation_id LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		conversations = append(conversations, *conversation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return conversations, total, nil
+}
+
+// conversationWhere returns the WHERE clause over the conversations table selecting the
+// conversations matching a filter, and its arguments. Limit and Offset are not applied.
+func conversationWhere(filter ConversationFilter) (string, []interface{}) {
+	where, args := tenantCondition("tenant_id", filter.TenantID)
+	where = " WHERE 1 = 1" + where
+
+	if filter.Source != "" {
+		where += " AND source = ?"
+		args = append(args, filter.Source)
+	}
+	if filter.CustomerID != "" {
+		where += " AND " + customerCondition()
+		args = append(args, filter.CustomerID, filter.CustomerID)
+	}
+	if filter.Search != "" {
+		where += " AND LOWER(text) LIKE LOWER(?)"
+		args = append(args, "%"+filter.Search+"%")
+	}
+	if filter.Since != nil {
+		where += " AND date_time >= ?"
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		where += " AND date_time < ?"
+		args = append(args, *filter.Until)
+	}
+	return where, args
+}
+
+// scanConversation reads a conversation selected with conversationColumns
+func scanConversation(row rowScanner) (*Conversation, error) {
+	var conversation Conversation
+	var dateTime interface{}
+	var customerID, source, metadata, storageTier sql.NullString
+	var createdAt, updatedAt sql.NullTime
+
+	if err := row.Scan(&conversation.ID, &customerID, &conversation.Text, &dateTime, &source, &metadata, &createdAt, &updatedAt, &storageTier); err != nil {
+		return nil, err
+	}
+	conversation.StorageTier = storageTier.String
+
+	conversation.DateTime = parseConversationTime(dateTime)
+	conversation.CustomerID = customerID.String
+	conversation.Source = source.String
+	conversation.CreatedAt = createdAt.Time
+	conversation.UpdatedAt = updatedAt.Time
+	if metadata.Valid && metadata.String != "" && metadata.String != "null" {
+		if err := json.Unmarshal([]byte(metadata.String), &conversation.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to parse metadata of conversation %s: %w", conversation.ID, err)
+		}
+	}
+
+	return &conversation, nil
+}
+
+// conversationTimeLayouts are the date_time formats found in imported conversation databases
+var conversationTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseConversationTime reads a date_time value, which imported databases may store as text
+func parseConversationTime(value interface{}) *time.Time {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		return &v
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return nil
+	}
+
+	for _, layout := range conversationTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
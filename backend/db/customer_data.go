@@ -0,0 +1,592 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FlagReasonCustomerDeleted is the flag reason of results computed from deleted customer data
+const FlagReasonCustomerDeleted = "customer data deleted"
+
+// customerCondition matches the conversations of a customer, given the customer ID twice.
+// Conversations ingested before customer_id was a column carry it in their metadata.
+func customerCondition() string {
+	return "(customer_id = ? OR (customer_id IS NULL AND " + DB.dialect.jsonText("metadata", "customer_id") + " = ?))"
+}
+
+// citationFields are the result fields that cite conversations or customers
+var citationFields = map[string]bool{
+	"conversation_id":      true,
+	"conversation_ids":     true,
+	"source_conversations": true,
+	"customer_id":          true,
+	"customer_ids":         true,
+}
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// CustomerDataDeletion reports what was, or in a dry run would be, deleted for a customer
+type CustomerDataDeletion struct {
+	CustomerID     string          `json:"customer_id"`
+	DryRun         bool            `json:"dry_run,omitempty"`
+	Conversations  []string        `json:"conversations"`
+	Attributes     int64           `json:"attributes"`
+	Revisions      int64           `json:"attribute_revisions"`
+	Intents        int64           `json:"intent_classifications"`
+	LineageEdges   int64           `json:"lineage_edges"`
+	Pseudonyms     int64           `json:"pseudonyms"`
+	CacheEntries   int64           `json:"cache_entries"`
+	AuditRequests  int64           `json:"audit_requests"` // Audited requests citing the customer, deleted with their LLM calls
+	AuditCalls     int64           `json:"audit_calls"`
+	Jobs           int             `json:"jobs"`      // Analysis jobs whose request or result cited the customer
+	RunNodes       int             `json:"run_nodes"` // Workflow run inputs and node outputs that cited the customer
+	FlaggedResults []FlaggedResult `json:"flagged_results"`
+}
+
+// FlaggedResult is a stored result computed from deleted data. Its citations of the data
+// are removed and it is flagged to be re-run, since its aggregates still count the data.
+type FlaggedResult struct {
+	ResultID         string `json:"result_id"`
+	WorkflowID       string `json:"workflow_id"`
+	AnalysisType     string `json:"analysis_type"`
+	RemovedCitations int    `json:"removed_citations"`
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// CustomerConversationIDs returns the IDs of a customer's conversations of a tenant
+func CustomerConversationIDs(tenantID, customerID string) ([]string, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query("SELECT conversation_id FROM conversations WHERE "+customerCondition()+condition+" ORDER BY conversation_id",
+		append([]interface{}{customerID, customerID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteCustomerData deletes a customer's conversations with their cold text, extracted
+// attributes, attribute revisions and flags, intent classifications, lineage and
+// pseudonyms, cached analyses citing them, audited requests citing them with their LLM
+// calls, and cached LLM replies, which can't be traced to the conversations in their
+// prompts. Stored results, analysis jobs and workflow runs citing them have the citations
+// removed, and results computed from them are flagged. With dryRun, nothing is changed.
+// Only the customer's conversations of the tenant are deleted.
+func DeleteCustomerData(tenantID, customerID string, dryRun bool) (*CustomerDataDeletion, error) {
+	ids, err := CustomerConversationIDs(tenantID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("customer %s not found", customerID)
+	}
+
+	deletion := &CustomerDataDeletion{CustomerID: customerID, DryRun: dryRun, Conversations: ids, FlaggedResults: []FlaggedResult{}}
+	cited := make(map[string]bool, len(ids)+1)
+	cited[customerID] = true
+	for _, id := range ids {
+		cited[id] = true
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	err = withTx(func(tx *Tx) error {
+		// Results derived from the conversations, directly or through other results
+		derived, err := derivedResultIDs(tx, tenantID, ids)
+		if err != nil {
+			return err
+		}
+
+		if err := flagCitingResults(tx, deletion, cited, derived); err != nil {
+			return err
+		}
+		if deletion.Jobs, err = scrubCitingJobs(tx, cited); err != nil {
+			return err
+		}
+		if deletion.RunNodes, err = scrubCitingRuns(tx, tenantID, cited); err != nil {
+			return err
+		}
+		if deletion.AuditRequests, deletion.AuditCalls, err = deleteCitingAudit(tx, tenantID, cited); err != nil {
+			return err
+		}
+
+		pseudonymCondition, pseudonymArgs := tenantCondition("tenant_id", tenantID)
+		deletes := []struct {
+			count *int64
+			query string
+			args  []interface{}
+		}{
+			{&deletion.Attributes, "DELETE FROM conversation_attributes WHERE conversation_id IN (" + placeholders + ")", args},
+			{&deletion.Revisions, "DELETE FROM conversation_attribute_revisions WHERE conversation_id IN (" + placeholders + ")", args},
+			{&deletion.Intents, "DELETE FROM intent_classifications WHERE conversation_id IN (" + placeholders + ")", args},
+			{nil, "DELETE FROM attribute_flags WHERE conversation_id IN (" + placeholders + ")", args},
+			{nil, "DELETE FROM conversation_translations WHERE conversation_id IN (" + placeholders + ")", args},
+			{nil, "DELETE FROM conversation_summaries WHERE conversation_id IN (" + placeholders + ")", args},
+			{nil, "DELETE FROM conversation_processing WHERE conversation_id IN (" + placeholders + ")", args},
+			{nil, "DELETE FROM embeddings WHERE kind = '" + EmbeddingConversation + "' AND item_id IN (" + placeholders + ")", args},
+			{&deletion.LineageEdges, "DELETE FROM lineage_edges WHERE source_type = '" + LineageConversation + "' AND source_id IN (" + placeholders + ")", args},
+			{&deletion.Pseudonyms, "DELETE FROM pseudonyms WHERE original_id IN (" + placeholders + ", ?)" + pseudonymCondition, append(append(append([]interface{}{}, args...), customerID), pseudonymArgs...)},
+			{nil, "DELETE FROM conversations WHERE conversation_id IN (" + placeholders + ")", args},
+		}
+		for _, del := range deletes {
+			result, err := tx.Exec(del.query, del.args...)
+			if err != nil {
+				return err
+			}
+			if del.count != nil {
+				if *del.count, err = result.RowsAffected(); err != nil {
+					return err
+				}
+			}
+		}
+
+		for id := range cited {
+			result, err := tx.Exec("DELETE FROM analysis_cache WHERE response LIKE ? ESCAPE '\\'", "%"+likeEscape(id)+"%")
+			if err != nil {
+				return err
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			deletion.CacheEntries += n
+		}
+		result, err := tx.Exec("DELETE FROM analysis_cache WHERE analysis_type = ?", LLMResponseCacheType)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deletion.CacheEntries += n
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+
+	if !dryRun {
+		for _, id := range ids {
+			if err := removeColdText(id); err != nil {
+				log.Printf("Error removing cold text of deleted conversation %s: %v", id, err)
+			}
+		}
+	}
+	return deletion, nil
+}
+
+// derivedResultIDs returns the IDs of the results of a tenant derived from conversations,
+// following lineage through intermediate results
+func derivedResultIDs(tx *Tx, tenantID string, conversationIDs []string) (map[string]bool, error) {
+	condition, tenantArgs := tenantCondition("tenant_id", tenantID)
+	derived := map[string]bool{}
+	type node struct{ kind, id string }
+	queue := make([]node, len(conversationIDs))
+	for i, id := range conversationIDs {
+		queue[i] = node{LineageConversation, id}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		rows, err := tx.Query("SELECT target_type, target_id FROM lineage_edges WHERE source_type = ? AND source_id = ?"+condition,
+			append([]interface{}{current.kind, current.id}, tenantArgs...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var next node
+			if err := rows.Scan(&next.kind, &next.id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !derived[next.id] {
+				derived[next.id] = true
+				queue = append(queue, next)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return derived, nil
+}
+
+// flagCitingResults removes the citations of deleted data from stored results and flags
+// those results, and the ones derived from the data, to be re-run
+func flagCitingResults(tx *Tx, deletion *CustomerDataDeletion, cited, derived map[string]bool) error {
+	candidates := make(map[string]bool, len(derived))
+	for id := range derived {
+		candidates[id] = true
+	}
+	for id := range cited {
+		rows, err := tx.Query("SELECT id FROM analysis_results WHERE results LIKE ? ESCAPE '\\'", "%"+likeEscape(id)+"%")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var resultID string
+			if err := rows.Scan(&resultID); err != nil {
+				rows.Close()
+				return err
+			}
+			candidates[resultID] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for resultID := range candidates {
+		var flagged FlaggedResult
+		var resultsStr string
+		err := tx.QueryRow("SELECT id, workflow_id, analysis_type, results FROM analysis_results WHERE id = ?", resultID).
+			Scan(&flagged.ResultID, &flagged.WorkflowID, &flagged.AnalysisType, &resultsStr)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		results, err := decodeStoredResults(resultsStr)
+		if err != nil {
+			return fmt.Errorf("result %s: %w", resultID, err)
+		}
+		scrubbed, removed := scrubCitations(results, "", cited)
+		flagged.RemovedCitations = removed
+		if removed == 0 && !derived[resultID] {
+			continue
+		}
+
+		encoded, err := json.Marshal(scrubbed)
+		if err != nil {
+			return fmt.Errorf("failed to marshal result %s: %w", resultID, err)
+		}
+		_, err = tx.Exec("UPDATE analysis_results SET results = ?, flagged_at = ?, flag_reason = ? WHERE id = ?",
+			string(encoded), now, FlagReasonCustomerDeleted, resultID)
+		if err != nil {
+			return err
+		}
+		deletion.FlaggedResults = append(deletion.FlaggedResults, flagged)
+	}
+	sort.Slice(deletion.FlaggedResults, func(i, j int) bool {
+		return deletion.FlaggedResults[i].ResultID < deletion.FlaggedResults[j].ResultID
+	})
+	return nil
+}
+
+// scrubCitingRuns removes the citations of deleted data from the inputs and node outputs
+// of the workflow runs of a tenant and returns how many of them cited it
+func scrubCitingRuns(tx *Tx, tenantID string, cited map[string]bool) (int, error) {
+	condition, tenantArgs := tenantCondition("r.tenant_id", tenantID)
+	type node struct{ runID, nodeID string }
+	nodes := map[node]bool{}
+	for id := range cited {
+		pattern := "%" + likeEscape(id) + "%"
+		rows, err := tx.Query(`
+			SELECT r.run_id, '' FROM runs r WHERE r.inputs LIKE ? ESCAPE '\'`+condition+`
+			UNION SELECT n.run_id, n.node_id FROM run_nodes n JOIN runs r ON r.run_id = n.run_id
+			WHERE n.output LIKE ? ESCAPE '\'`+condition,
+			append(append(append([]interface{}{pattern}, tenantArgs...), pattern), tenantArgs...)...)
+		if err != nil {
+			return 0, err
+		}
+		for rows.Next() {
+			var n node
+			if err := rows.Scan(&n.runID, &n.nodeID); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			nodes[n] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	scrubbedNodes := 0
+	for n := range nodes {
+		// A run's inputs are scrubbed as its node with an empty ID
+		read, write := "SELECT inputs FROM runs WHERE run_id = ?", "UPDATE runs SET inputs = ? WHERE run_id = ?"
+		args := []interface{}{n.runID}
+		if n.nodeID != "" {
+			read, write = "SELECT output FROM run_nodes WHERE run_id = ? AND node_id = ?", "UPDATE run_nodes SET output = ? WHERE run_id = ? AND node_id = ?"
+			args = append(args, n.nodeID)
+		}
+		var encoded sql.NullString
+		if err := tx.QueryRow(read, args...).Scan(&encoded); err != nil {
+			return 0, err
+		}
+		scrubbed, removed, err := scrubJSON(encoded.String, cited)
+		if err != nil || stillCites(scrubbed, cited) {
+			// Workflows pass data under any name, so values that can't be scrubbed, or still
+			// cite the data outside of citation fields, are removed
+			scrubbed, removed = "", 1
+		}
+		if removed == 0 {
+			continue
+		}
+		if _, err := tx.Exec(write, append([]interface{}{nullString(scrubbed)}, args...)...); err != nil {
+			return 0, err
+		}
+		scrubbedNodes++
+	}
+	return scrubbedNodes, nil
+}
+
+// stillCites reports whether encoded JSON holds a cited ID as a string
+func stillCites(encoded string, cited map[string]bool) bool {
+	for id := range cited {
+		quoted, err := json.Marshal(id)
+		if err == nil && strings.Contains(encoded, string(quoted)) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteCitingAudit deletes the audited requests of a tenant that cite deleted data, and
+// their replays, with the LLM calls they made, as well as other audited calls whose prompt
+// or response cites the data. It returns the numbers of requests and calls deleted.
+func deleteCitingAudit(tx *Tx, tenantID string, cited map[string]bool) (int64, int64, error) {
+	condition, tenantArgs := tenantCondition("tenant_id", tenantID)
+	requests := map[string]bool{}
+	var queue []string
+	collect := func(query string, args ...interface{}) error {
+		rows, err := tx.Query(query+condition, append(args, tenantArgs...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var requestID string
+			if err := rows.Scan(&requestID); err != nil {
+				return err
+			}
+			if !requests[requestID] {
+				requests[requestID] = true
+				queue = append(queue, requestID)
+			}
+		}
+		return rows.Err()
+	}
+	for id := range cited {
+		pattern := "%" + likeEscape(id) + "%"
+		if err := collect("SELECT request_id FROM audit_requests WHERE request LIKE ? ESCAPE '\\'", pattern); err != nil {
+			return 0, 0, err
+		}
+		if err := collect(`SELECT DISTINCT request_id FROM llm_audit WHERE request_id IS NOT NULL
+			AND (prompt LIKE ? ESCAPE '\' OR response LIKE ? ESCAPE '\')`, pattern, pattern); err != nil {
+			return 0, 0, err
+		}
+	}
+	// Replays sent the prompts of the requests they replayed
+	for len(queue) > 0 {
+		requestID := queue[0]
+		queue = queue[1:]
+		if err := collect("SELECT request_id FROM audit_requests WHERE replay_of = ?", requestID); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var deletedRequests, deletedCalls int64
+	for requestID := range requests {
+		for _, del := range []struct {
+			count *int64
+			query string
+		}{
+			{&deletedCalls, "DELETE FROM llm_audit WHERE request_id = ?"},
+			{&deletedRequests, "DELETE FROM audit_requests WHERE request_id = ?"},
+		} {
+			result, err := tx.Exec(del.query+condition, append([]interface{}{requestID}, tenantArgs...)...)
+			if err != nil {
+				return 0, 0, err
+			}
+			n, err := result.RowsAffected()
+			if err != nil {
+				return 0, 0, err
+			}
+			*del.count += n
+		}
+	}
+
+	// Calls made outside of audited requests
+	for id := range cited {
+		pattern := "%" + likeEscape(id) + "%"
+		result, err := tx.Exec("DELETE FROM llm_audit WHERE (prompt LIKE ? ESCAPE '\\' OR response LIKE ? ESCAPE '\\')"+condition,
+			append([]interface{}{pattern, pattern}, tenantArgs...)...)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, 0, err
+		}
+		deletedCalls += n
+	}
+	return deletedRequests, deletedCalls, nil
+}
+
+// scrubCitingJobs removes the citations of deleted data from the requests and results of
+// analysis jobs and returns how many jobs cited it
+func scrubCitingJobs(tx *Tx, cited map[string]bool) (int, error) {
+	jobs := map[string]bool{}
+	for id := range cited {
+		pattern := "%" + likeEscape(id) + "%"
+		rows, err := tx.Query("SELECT id FROM analysis_jobs WHERE request LIKE ? ESCAPE '\\' OR result LIKE ? ESCAPE '\\'", pattern, pattern)
+		if err != nil {
+			return 0, err
+		}
+		for rows.Next() {
+			var jobID string
+			if err := rows.Scan(&jobID); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			jobs[jobID] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	scrubbedJobs := 0
+	for jobID := range jobs {
+		var request string
+		var result sql.NullString
+		if err := tx.QueryRow("SELECT request, result FROM analysis_jobs WHERE id = ?", jobID).Scan(&request, &result); err != nil {
+			return 0, err
+		}
+		scrubbedRequest, removedRequest, err := scrubJSON(request, cited)
+		if err != nil {
+			return 0, fmt.Errorf("job %s request: %w", jobID, err)
+		}
+		scrubbedResult, removedResult := result.String, 0
+		if result.Valid && result.String != "" {
+			if scrubbedResult, removedResult, err = scrubJSON(result.String, cited); err != nil {
+				return 0, fmt.Errorf("job %s result: %w", jobID, err)
+			}
+		}
+		if removedRequest+removedResult == 0 {
+			continue
+		}
+		_, err = tx.Exec("UPDATE analysis_jobs SET request = ?, result = ? WHERE id = ?",
+			scrubbedRequest, nullString(scrubbedResult), jobID)
+		if err != nil {
+			return 0, err
+		}
+		scrubbedJobs++
+	}
+	return scrubbedJobs, nil
+}
+
+// scrubJSON removes citations from encoded JSON
+func scrubJSON(encoded string, cited map[string]bool) (string, int, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+		return "", 0, err
+	}
+	scrubbed, removed := scrubCitations(value, "", cited)
+	if removed == 0 {
+		return encoded, 0, nil
+	}
+	out, err := json.Marshal(scrubbed)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(out), removed, nil
+}
+
+// scrubCitations removes cited IDs found under field from a value: they are dropped from
+// lists of IDs, items of lists that belong to a cited conversation or customer are
+// dropped, and other citing fields are emptied. It returns the scrubbed value and the
+// number of citations removed; containers holding citations are copies.
+func scrubCitations(value interface{}, field string, cited map[string]bool) (interface{}, int) {
+	switch v := value.(type) {
+	case string:
+		if citationFields[field] && cited[v] {
+			return "", 1
+		}
+		return v, 0
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		removed := 0
+		for _, item := range v {
+			if id, ok := item.(string); ok && citationFields[field] && cited[id] {
+				removed++
+				continue
+			}
+			if object, ok := item.(map[string]interface{}); ok && citesItem(object, cited) {
+				removed++
+				continue
+			}
+			item, n := scrubCitations(item, field, cited)
+			removed += n
+			list = append(list, item)
+		}
+		return list, removed
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		removed := 0
+		for name, fieldValue := range v {
+			var n int
+			object[name], n = scrubCitations(fieldValue, name, cited)
+			removed += n
+		}
+		return object, removed
+	default:
+		return value, 0
+	}
+}
+
+// citesItem reports whether a list item belongs to a cited conversation or customer
+func citesItem(object map[string]interface{}, cited map[string]bool) bool {
+	for _, field := range []string{"conversation_id", "customer_id"} {
+		if id, ok := object[field].(string); ok && cited[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// likeEscape escapes the wildcards of a LIKE pattern, for use with ESCAPE '\'
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
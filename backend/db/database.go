@@ -1,24 +1,41 @@
 package db
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
-
-	_ "github.com/mattn/go-sqlite3"
+	"strings"
 )
 
 const (
 	// Database file path - relative to the current directory
 	dbName = "data/agenticflows.db"
+
+	// envDatabaseURL selects the database: a postgres:// URL, or the path of a SQLite file
+	envDatabaseURL = "DATABASE_URL"
+
+	// envDatabaseReadURL is an optional postgres:// URL of a read replica of DATABASE_URL
+	envDatabaseReadURL = "DATABASE_READ_URL"
+
+	// schemaLockID is the PostgreSQL advisory lock instances hold while creating tables,
+	// so instances starting together do not race on the schema
+	schemaLockID = 7302915
 )
 
 var (
 	// Single database connection instance
-	DB *sql.DB
+	DB *Store
+
+	// Replica serves reports that tolerate replication lag, like usage and the activity
+	// feed. It is DB unless DATABASE_READ_URL sets a read replica.
+	Replica *Store
+
+	// location describes the open database, and filePath is its file when it is SQLite
+	location, filePath string
 )
 
 // Agent represents an agent component
@@ -42,12 +59,35 @@ type Workflow struct {
 	Date  string          `json:"date"`
 	Nodes json.RawMessage `json:"nodes"`
 	Edges json.RawMessage `json:"edges"`
+
+	// Version increases with every change and is used to detect conflicting edits
+	Version int `json:"version"`
+
+	// TenantID is the tenant the workflow belongs to
+	TenantID string `json:"-"`
 }
 
 // Initialize sets up the database connection and creates tables if they don't exist
 func Initialize() error {
+	if err := Open(); err != nil {
+		return err
+	}
+	return initializeSchema()
+}
+
+// Open connects to the database selected by DATABASE_URL without creating its tables, so
+// the database can be inspected as it is
+func Open() error {
+	dsn := strings.TrimSpace(os.Getenv(envDatabaseURL))
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return openPostgres(dsn)
+	}
+
 	// Ensure the database file exists
 	dbPath := dbName
+	if dsn != "" {
+		dbPath = dsn
+	}
 	
 	// Get the absolute path for logging
 	absPath, err := filepath.Abs(dbPath)
@@ -55,6 +95,7 @@ func Initialize() error {
 		absPath = dbPath
 	}
 	log.Printf("Using database at path: %s", absPath)
+	location, filePath = absPath, absPath
 	
 	_, err = os.Stat(dbPath)
 	if os.IsNotExist(err) {
@@ -72,12 +113,58 @@ func Initialize() error {
 		file.Close()
 	}
 
-	// Open database connection
-	DB, err = sql.Open("sqlite3", dbPath)
+	// Open the connection pools: writes go through DB and reads that tolerate a
+	// concurrent write through Replica
+	manager, err := OpenManager(dbPath, ManagerOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	DB, Replica = manager.Writer(), manager.Reader()
+	return nil
+}
+
+// openPostgres connects to a PostgreSQL database, which several server instances
+// can share
+func openPostgres(dsn string) error {
+	redacted := "PostgreSQL"
+	if u, err := url.Parse(dsn); err == nil {
+		redacted = u.Redacted()
+	}
+	log.Printf("Using database at: %s", redacted)
+	location, filePath = redacted, ""
+
+	var err error
+	DB, err = openStore(newPostgresDialect(), dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
+	Replica = DB
+	if replicaDSN := strings.TrimSpace(os.Getenv(envDatabaseReadURL)); replicaDSN != "" {
+		if Replica, err = openStore(newPostgresDialect(), replicaDSN); err != nil {
+			return fmt.Errorf("failed to open read replica: %w", err)
+		}
+		log.Println("Serving reports from the read replica")
+	}
+	return nil
+}
+
+// initializeSchema creates missing tables and the initial data
+func initializeSchema() error {
+	// Instances sharing a PostgreSQL database create its tables one at a time
+	if _, ok := DB.dialect.(postgresDialect); ok {
+		ctx := context.Background()
+		conn, err := DB.db.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to open database: %w", err)
+		}
+		defer conn.Close()
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", schemaLockID); err != nil {
+			return fmt.Errorf("failed to lock the schema: %w", err)
+		}
+		defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", schemaLockID)
+	}
+
 	// Create tables if they don't exist
 	if err := createTables(); err != nil {
 		return fmt.Errorf("failed to create tables: %w", err)
@@ -88,6 +175,10 @@ func Initialize() error {
 		return fmt.Errorf("failed to initialize component data: %w", err)
 	}
 
+	if err := recordSchemaVersion(); err != nil {
+		return fmt.Errorf("failed to record the schema version: %w", err)
+	}
+
 	log.Println("Database initialized successfully")
 	return nil
 }
@@ -132,9 +223,234 @@ func createTables() error {
 		return err
 	}
 
+	// Add columns introduced after the initial schema
+	if err := addColumnIfMissing("workflows", "version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Create workflow operation log table
+	if err := createWorkflowOperationsTable(); err != nil {
+		return err
+	}
+
+	// Create lineage graph table
+	if err := createLineageTable(); err != nil {
+		return err
+	}
+
+	// Create activity feed table
+	if err := createActivityTable(); err != nil {
+		return err
+	}
+
+	// Create background analysis job queue table
+	if err := createAnalysisJobsTable(); err != nil {
+		return err
+	}
+
+	// Create analysis response cache table
+	if err := createAnalysisCacheTable(); err != nil {
+		return err
+	}
+
+	// Create installed tool manifest table
+	if err := createToolManifestsTable(); err != nil {
+		return err
+	}
+
+	// Create ingested conversations table
+	if err := createConversationsTable(); err != nil {
+		return err
+	}
+
+	// Create extracted conversation attributes table
+	if err := createConversationAttributesTable(); err != nil {
+		return err
+	}
+
+	// Create LLM usage table
+	if err := createUsageTable(); err != nil {
+		return err
+	}
+
+	// Create stored attribute sets table
+	if err := createAttributeSetsTable(); err != nil {
+		return err
+	}
+
+	// Create pseudonym mapping table
+	if err := createPseudonymsTable(); err != nil {
+		return err
+	}
+
+	// Create stored pipelines table
+	if err := createPipelinesTable(); err != nil {
+		return err
+	}
+
+	// Create workflow concurrency settings table
+	if err := createWorkflowConcurrencyTable(); err != nil {
+		return err
+	}
+
+	// Create conversation attribute revisions table
+	if err := createConversationAttributeRevisionsTable(); err != nil {
+		return err
+	}
+
+	// Create attribute validation flags table
+	if err := createAttributeFlagsTable(); err != nil {
+		return err
+	}
+
+	// Create result webhook subscriptions table
+	if err := createWebhooksTable(); err != nil {
+		return err
+	}
+
+	// Create API keys table
+	if err := createAPIKeysTable(); err != nil {
+		return err
+	}
+
+	// Create canary rollout tables
+	if err := createCanariesTable(); err != nil {
+		return err
+	}
+
+	// Create workflow SLA tables
+	if err := createWorkflowSLATables(); err != nil {
+		return err
+	}
+
+	// Create prompt template tables
+	if err := createPromptTemplatesTables(); err != nil {
+		return err
+	}
+
+	// Create embeddable widgets table
+	if err := createWidgetsTable(); err != nil {
+		return err
+	}
+
+	// Create workspace defaults table
+	if err := createWorkspaceDefaultsTable(); err != nil {
+		return err
+	}
+
+	// Create conversation translations table
+	if err := createConversationTranslationsTable(); err != nil {
+		return err
+	}
+
+	// Create leader election leases table
+	if err := createLeasesTable(); err != nil {
+		return err
+	}
+
+	// Create item embeddings table
+	if err := createEmbeddingsTable(); err != nil {
+		return err
+	}
+
+	// Create per-conversation processing table
+	if err := createConversationProcessingTable(); err != nil {
+		return err
+	}
+
+	// Create chain run progress tables
+	if err := createChainRunsTables(); err != nil {
+		return err
+	}
+
+	// Create run history tables
+	if err := createRunsTables(); err != nil {
+		return err
+	}
+
+	// Create scratch session tables
+	if err := createScratchTables(); err != nil {
+		return err
+	}
+
+	// Create workflow review table
+	if err := createWorkflowReviewsTable(); err != nil {
+		return err
+	}
+
+	// Create intent classification history table
+	if err := createIntentClassificationsTable(); err != nil {
+		return err
+	}
+
+	// Create LLM call scheduler settings table
+	if err := createSchedulerSettingsTable(); err != nil {
+		return err
+	}
+
+	// Create conversation summary cache table
+	if err := createConversationSummariesTable(); err != nil {
+		return err
+	}
+
+	// Create LLM call audit tables
+	if err := createLLMAuditTables(); err != nil {
+		return err
+	}
+
+	// Create conversation upload table
+	if err := createConversationUploadsTable(); err != nil {
+		return err
+	}
+
+	// Scope workflows, scratch sessions, conversations, attribute sets, API keys, jobs, runs,
+	// uploads and activity to tenants
+	if err := addTenantColumns(); err != nil {
+		return err
+	}
+
+	// Create schema information table
+	if err := createSchemaInfoTable(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// addColumnIfMissing adds a column to an existing table when older databases lack it
+func addColumnIfMissing(table, column, definition string) error {
+	var count int
+	if err := DB.QueryRow(DB.dialect.columnExistsQuery(), table, column).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// withTx runs fn inside a transaction, committing on success and rolling back on error
+func withTx(fn func(tx *Tx) error) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // initializeComponentData inserts the initial agents and tools data
 func initializeComponentData() error {
 	// Get count of agents to check if we need to insert initial data
@@ -202,6 +518,9 @@ func initializeComponentData() error {
 
 // Close closes the database connection
 func Close() error {
+	if Replica != nil && Replica != DB {
+		Replica.Close()
+	}
 	if DB != nil {
 		return DB.Close()
 	}
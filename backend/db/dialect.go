@@ -0,0 +1,151 @@
+package db
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// dialect adapts the SQLite statements of this package to a storage backend
+type dialect interface {
+	// driverName is the database/sql driver of the backend
+	driverName() string
+	// rebind rewrites a statement for the backend
+	rebind(query string) string
+	// args converts the arguments of a statement for the backend
+	args(args []interface{}) []interface{}
+	// columnExistsQuery counts the columns of a table with a name, given both as arguments
+	columnExistsQuery() string
+	// tableExistsQuery counts the tables with a name, given as argument
+	tableExistsQuery() string
+	// jsonText is the expression of a top-level field of a JSON text column, as text
+	jsonText(column, field string) string
+}
+
+// sqliteDialect is the dialect the statements are written in
+type sqliteDialect struct{}
+
+func (sqliteDialect) driverName() string { return "sqlite3" }
+
+func (sqliteDialect) rebind(query string) string { return query }
+
+func (sqliteDialect) args(args []interface{}) []interface{} { return args }
+
+func (sqliteDialect) columnExistsQuery() string {
+	return "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?"
+}
+
+func (sqliteDialect) tableExistsQuery() string {
+	return "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+}
+
+func (sqliteDialect) jsonText(column, field string) string {
+	return "json_extract(" + column + ", '$." + field + "')"
+}
+
+// postgresDialect rewrites statements for PostgreSQL. Rewritten statements are cached,
+// as the package runs the same few statements over and over.
+type postgresDialect struct {
+	rebound *sync.Map
+}
+
+func newPostgresDialect() postgresDialect {
+	return postgresDialect{rebound: &sync.Map{}}
+}
+
+func (postgresDialect) driverName() string { return "postgres" }
+
+// postgresDDL rewrites SQLite table definitions for PostgreSQL. INTEGER and REAL widen
+// to 64 bits, as SQLite stores them. Foreign keys are dropped, as SQLite does not enforce
+// them either and results may name workflows that were never saved.
+var postgresDDL = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\bINTEGER PRIMARY KEY AUTOINCREMENT\b`), "BIGSERIAL PRIMARY KEY"},
+	{regexp.MustCompile(`\bINTEGER\b`), "BIGINT"},
+	{regexp.MustCompile(`\bREAL\b`), "DOUBLE PRECISION"},
+	{regexp.MustCompile(`\bTIMESTAMP\b`), "TIMESTAMPTZ"},
+	{regexp.MustCompile(`\bBLOB\b`), "BYTEA"},
+	{regexp.MustCompile(`,\s*FOREIGN KEY\s*\([^)]*\)\s*REFERENCES\s+\w+\s*\([^)]*\)`), ""},
+}
+
+func (d postgresDialect) rebind(query string) string {
+	if rebound, ok := d.rebound.Load(query); ok {
+		return rebound.(string)
+	}
+
+	rebound := query
+	trimmed := strings.ToUpper(strings.TrimSpace(query))
+	if strings.HasPrefix(trimmed, "CREATE TABLE") || strings.HasPrefix(trimmed, "ALTER TABLE") {
+		for _, t := range postgresDDL {
+			rebound = t.pattern.ReplaceAllString(rebound, t.replacement)
+		}
+	}
+
+	// Number the ? placeholders outside of quoted literals and identifiers
+	var b strings.Builder
+	n := 0
+	var quote rune
+	for _, r := range rebound {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	rebound = b.String()
+
+	d.rebound.Store(query, rebound)
+	return rebound
+}
+
+// args passes booleans as 1 and 0, which PostgreSQL accepts for both the BOOLEAN and
+// the INTEGER columns SQLite stores booleans in
+func (postgresDialect) args(args []interface{}) []interface{} {
+	var converted []interface{}
+	for i, arg := range args {
+		if b, ok := arg.(bool); ok {
+			if converted == nil {
+				converted = append([]interface{}(nil), args...)
+			}
+			converted[i] = boolInt(b)
+		}
+	}
+	if converted == nil {
+		return args
+	}
+	return converted
+}
+
+func (postgresDialect) columnExistsQuery() string {
+	return "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?"
+}
+
+func (postgresDialect) tableExistsQuery() string {
+	return "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?"
+}
+
+func (postgresDialect) jsonText(column, field string) string {
+	return "(CAST(NULLIF(" + column + ", '') AS JSONB) ->> '" + field + "')"
+}
+
+// boolInt is 1 for true and 0 for false
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
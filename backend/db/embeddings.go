@@ -0,0 +1,150 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// Kinds of embedded items
+const (
+	EmbeddingConversation = "conversation"
+	EmbeddingIntent       = "intent"
+)
+
+// Embedding is the vector of an item of a tenant computed by an embedding model. Vectors
+// are packed by the embeddings package.
+type Embedding struct {
+	Kind      string    `json:"kind"`
+	ItemID    string    `json:"item_id"`
+	Model     string    `json:"model"`
+	Vector    []byte    `json:"-"`
+	Text      string    `json:"text,omitempty"` // The embedded text of short items like intent labels
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// createEmbeddingsTable creates the table of item embeddings if it doesn't exist
+func createEmbeddingsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS embeddings (
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			kind TEXT NOT NULL,
+			item_id TEXT NOT NULL,
+			model TEXT NOT NULL,
+			vector BLOB NOT NULL,
+			text TEXT,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (tenant_id, kind, item_id, model)
+		)
+	`)
+	return err
+}
+
+// SaveEmbeddings stores embeddings of a tenant, replacing those of the same items and model
+func SaveEmbeddings(tenantID string, embeddings []Embedding) error {
+	if len(embeddings) == 0 {
+		return nil
+	}
+	if tenantID == "" {
+		tenantID = DefaultTenantID
+	}
+	return withTx(func(tx *Tx) error {
+		stmt, err := tx.Prepare(`
+			INSERT INTO embeddings (tenant_id, kind, item_id, model, vector, text, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(tenant_id, kind, item_id, model) DO UPDATE SET vector = excluded.vector, text = excluded.text, updated_at = excluded.updated_at`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		now := time.Now()
+		for _, embedding := range embeddings {
+			if _, err := stmt.Exec(tenantID, embedding.Kind, embedding.ItemID, embedding.Model, embedding.Vector, embedding.Text, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetEmbeddings returns the embeddings of a tenant of a kind computed by a model, by item
+// ID. ids restricts them to the given items; nil returns all.
+func GetEmbeddings(tenantID, kind, model string, ids []string) (map[string]Embedding, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	query := "SELECT kind, item_id, model, vector, COALESCE(text, ''), updated_at FROM embeddings WHERE kind = ? AND model = ?" + condition
+	args = append([]interface{}{kind, model}, args...)
+	if ids != nil {
+		if len(ids) == 0 {
+			return map[string]Embedding{}, nil
+		}
+		query += " AND item_id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	return queryEmbeddings(query, args...)
+}
+
+// GetConversationEmbeddings returns the current embeddings of the conversations matching
+// the filter, by conversation ID. Embeddings older than the last update of their
+// conversation are left out. Limit and Offset are ignored.
+func GetConversationEmbeddings(model string, filter ConversationFilter) (map[string]Embedding, error) {
+	where, filterArgs := conversationWhere(filter)
+	args := append([]interface{}{EmbeddingConversation, model}, filterArgs...)
+
+	return queryEmbeddings(`
+		SELECT e.kind, e.item_id, e.model, e.vector, COALESCE(e.text, ''), e.updated_at
+		FROM embeddings e JOIN conversations c ON c.conversation_id = e.item_id
+		WHERE e.kind = ? AND e.model = ? AND (c.updated_at IS NULL OR e.updated_at >= c.updated_at)
+			AND e.item_id IN (SELECT conversation_id FROM conversations`+where+`)`, args...)
+}
+
+// ListUnembeddedConversations returns the IDs of up to limit conversations of a tenant with
+// text that have no current embedding of a model, oldest first, and how many there are in
+// total
+func ListUnembeddedConversations(tenantID, model string, limit int) ([]string, int, error) {
+	condition, tenantArgs := tenantCondition("c.tenant_id", tenantID)
+	where := ` FROM conversations c
+		LEFT JOIN embeddings e ON e.kind = ? AND e.item_id = c.conversation_id AND e.model = ?
+		WHERE COALESCE(c.storage_tier, '') <> ? AND (e.item_id IS NULL OR e.updated_at < c.updated_at)` + condition
+	args := append([]interface{}{EmbeddingConversation, model, StorageTierDropped}, tenantArgs...)
+
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*)"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := DB.Query("SELECT c.conversation_id"+where+" ORDER BY c.created_at, c.conversation_id LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, total, rows.Err()
+}
+
+// queryEmbeddings runs an embeddings query and returns the embeddings by item ID
+func queryEmbeddings(query string, args ...interface{}) (map[string]Embedding, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	embeddings := map[string]Embedding{}
+	for rows.Next() {
+		var embedding Embedding
+		if err := rows.Scan(&embedding.Kind, &embedding.ItemID, &embedding.Model, &embedding.Vector, &embedding.Text, &embedding.UpdatedAt); err != nil {
+			return nil, err
+		}
+		embeddings[embedding.ItemID] = embedding
+	}
+	return embeddings, rows.Err()
+}
@@ -0,0 +1,293 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Intent distribution periods
+const (
+	IntentPeriodDay   = "day"
+	IntentPeriodWeek  = "week"
+	IntentPeriodMonth = "month"
+)
+
+// intentAttributeName is the name under which the latest intent of a conversation is kept
+// in conversation_attributes
+const intentAttributeName = "intent"
+
+// IntentClassification is the intent a workflow classified a conversation with. Every
+// classification is kept, so repeated runs show how the intent of a conversation changed.
+type IntentClassification struct {
+	ID             int64     `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	WorkflowID     string    `json:"workflow_id,omitempty"`
+	Label          string    `json:"label"`
+	LabelName      string    `json:"label_name"`
+	Description    string    `json:"description,omitempty"`
+	ClassifiedAt   time.Time `json:"classified_at"`
+}
+
+// IntentDistributionFilter selects the classifications counted by IntentDistribution
+type IntentDistributionFilter struct {
+	// TenantID restricts the distribution to the classifications of the tenant's workflows
+	// and stored conversations; AllTenants counts those of every tenant
+	TenantID   string
+	WorkflowID string
+	Since      time.Time
+	Until      time.Time
+	Period     string // day, week or month; day by default
+}
+
+// IntentCount is the number of conversations classified with an intent in a period
+type IntentCount struct {
+	Label         string `json:"label"`
+	LabelName     string `json:"label_name"`
+	Conversations int    `json:"conversations"`
+}
+
+// IntentPeriod is the intent distribution of one period, most common intent first
+type IntentPeriod struct {
+	Period        string        `json:"period"` // Start of the period: 2006-01-02 for days and weeks, 2006-01 for months
+	Conversations int           `json:"conversations"`
+	Intents       []IntentCount `json:"intents"`
+}
+
+// intentClassificationColumns are the columns read into an IntentClassification, in scan order
+const intentClassificationColumns = "id, conversation_id, workflow_id, label, label_name, description, classified_at"
+
+// createIntentClassificationsTable creates the intent_classifications table if it doesn't exist
+func createIntentClassificationsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS intent_classifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id TEXT NOT NULL,
+			workflow_id TEXT,
+			label TEXT NOT NULL,
+			label_name TEXT,
+			description TEXT,
+			classified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_intent_classifications_conversation ON intent_classifications (conversation_id, classified_at)",
+		"CREATE INDEX IF NOT EXISTS idx_intent_classifications_workflow ON intent_classifications (workflow_id, classified_at)",
+	} {
+		if _, err := DB.Exec(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveIntentClassifications records classifications and keeps the latest intent of each
+// conversation in conversation_attributes, where topic search and the conversation
+// databases of the examples read it. Classifications without a conversation ID or a
+// label, and those of conversations stored by a tenant other than tenantID, are skipped;
+// the number saved is returned.
+func SaveIntentClassifications(tenantID string, classifications []IntentClassification) (int, error) {
+	foreign := map[string]bool{}
+	for _, classification := range classifications {
+		id := classification.ConversationID
+		if _, seen := foreign[id]; seen || id == "" {
+			continue
+		}
+		taken, err := ConversationOfOtherTenant(tenantID, id)
+		if err != nil {
+			return 0, err
+		}
+		foreign[id] = taken
+	}
+
+	var latest []ConversationAttribute
+	err := withTx(func(tx *Tx) error {
+		now := time.Now()
+		for _, classification := range classifications {
+			if classification.ConversationID == "" || classification.Label == "" || foreign[classification.ConversationID] {
+				continue
+			}
+			if classification.ClassifiedAt.IsZero() {
+				classification.ClassifiedAt = now
+			}
+			_, err := tx.Exec(
+				`INSERT INTO intent_classifications (conversation_id, workflow_id, label, label_name, description, classified_at)
+				VALUES (?, ?, ?, ?, ?, ?)`,
+				classification.ConversationID, nullString(classification.WorkflowID), classification.Label,
+				nullString(classification.LabelName), nullString(classification.Description), classification.ClassifiedAt,
+			)
+			if err != nil {
+				return err
+			}
+			value := classification.LabelName
+			if value == "" {
+				value = classification.Label
+			}
+			latest = append(latest, ConversationAttribute{
+				ConversationID: classification.ConversationID,
+				Type:           ConversationAttributeTypeIntent,
+				Name:           intentAttributeName,
+				Value:          value,
+				Description:    classification.Description,
+				WorkflowID:     classification.WorkflowID,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(latest) == 0 {
+		return 0, nil
+	}
+	if _, err := SaveConversationAttributes(latest, "intent reclassified"); err != nil {
+		return 0, fmt.Errorf("failed to save latest intents: %w", err)
+	}
+	return len(latest), nil
+}
+
+// GetConversationIntents returns the intent classifications of a conversation a tenant
+// made with its workflows or of its stored conversation, newest first, optionally only
+// those of one workflow
+func GetConversationIntents(tenantID, conversationID, workflowID string) ([]IntentClassification, error) {
+	condition, args := conversationRecordCondition("workflow_id", "conversation_id", tenantID)
+	query := "SELECT " + intentClassificationColumns + " FROM intent_classifications WHERE conversation_id = ?" + condition
+	args = append([]interface{}{conversationID}, args...)
+	if workflowID != "" {
+		query += " AND workflow_id = ?"
+		args = append(args, workflowID)
+	}
+	query += " ORDER BY classified_at DESC, id DESC"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classifications := []IntentClassification{}
+	for rows.Next() {
+		classification, err := scanIntentClassification(rows)
+		if err != nil {
+			return nil, err
+		}
+		classifications = append(classifications, *classification)
+	}
+	return classifications, rows.Err()
+}
+
+// IntentDistribution counts the conversations classified with each intent per period,
+// oldest period first. A conversation classified several times in a period counts once,
+// with its latest intent in that period.
+func IntentDistribution(filter IntentDistributionFilter) ([]IntentPeriod, error) {
+	period, err := intentPeriodFunc(filter.Period)
+	if err != nil {
+		return nil, err
+	}
+
+	condition, args := conversationRecordCondition("workflow_id", "conversation_id", filter.TenantID)
+	query := "SELECT " + intentClassificationColumns + " FROM intent_classifications WHERE 1 = 1" + condition
+	if filter.WorkflowID != "" {
+		query += " AND workflow_id = ?"
+		args = append(args, filter.WorkflowID)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND classified_at >= ?"
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query += " AND classified_at < ?"
+		args = append(args, filter.Until)
+	}
+	query += " ORDER BY classified_at, id"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Later classifications replace earlier ones of the same conversation and period
+	type key struct{ period, conversationID string }
+	latest := make(map[key]IntentClassification)
+	for rows.Next() {
+		classification, err := scanIntentClassification(rows)
+		if err != nil {
+			return nil, err
+		}
+		latest[key{period(classification.ClassifiedAt), classification.ConversationID}] = *classification
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	byPeriod := make(map[string]map[string]*IntentCount)
+	for k, classification := range latest {
+		counts, ok := byPeriod[k.period]
+		if !ok {
+			counts = make(map[string]*IntentCount)
+			byPeriod[k.period] = counts
+		}
+		count, ok := counts[classification.Label]
+		if !ok {
+			count = &IntentCount{Label: classification.Label, LabelName: classification.LabelName}
+			counts[classification.Label] = count
+		}
+		count.Conversations++
+	}
+
+	periods := make([]IntentPeriod, 0, len(byPeriod))
+	for name, counts := range byPeriod {
+		entry := IntentPeriod{Period: name, Intents: make([]IntentCount, 0, len(counts))}
+		for _, count := range counts {
+			entry.Intents = append(entry.Intents, *count)
+			entry.Conversations += count.Conversations
+		}
+		sort.Slice(entry.Intents, func(i, j int) bool {
+			if entry.Intents[i].Conversations != entry.Intents[j].Conversations {
+				return entry.Intents[i].Conversations > entry.Intents[j].Conversations
+			}
+			return entry.Intents[i].Label < entry.Intents[j].Label
+		})
+		periods = append(periods, entry)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Period < periods[j].Period })
+	return periods, nil
+}
+
+// intentPeriodFunc returns the function naming the period a time falls in, in UTC
+func intentPeriodFunc(period string) (func(time.Time) string, error) {
+	switch period {
+	case "", IntentPeriodDay:
+		return func(t time.Time) string { return t.UTC().Format("2006-01-02") }, nil
+	case IntentPeriodWeek:
+		// Weeks start on Monday
+		return func(t time.Time) string {
+			t = t.UTC()
+			return t.AddDate(0, 0, -(int(t.Weekday())+6)%7).Format("2006-01-02")
+		}, nil
+	case IntentPeriodMonth:
+		return func(t time.Time) string { return t.UTC().Format("2006-01") }, nil
+	}
+	return nil, fmt.Errorf("invalid period %q: must be day, week or month", period)
+}
+
+// scanIntentClassification reads an IntentClassification from a row
+func scanIntentClassification(row rowScanner) (*IntentClassification, error) {
+	var classification IntentClassification
+	var workflowID, labelName, description sql.NullString
+	err := row.Scan(&classification.ID, &classification.ConversationID, &workflowID, &classification.Label,
+		&labelName, &description, &classification.ClassifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	classification.WorkflowID = workflowID.String
+	classification.LabelName = labelName.String
+	classification.Description = description.String
+	return &classification, nil
+}
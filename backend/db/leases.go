@@ -0,0 +1,50 @@
+package db
+
+import (
+	"time"
+)
+
+// createLeasesTable creates the table of named leases server instances hold to elect a
+// leader for background tasks
+func createLeasesTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS leases (
+			name TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)
+	`)
+	return err
+}
+
+// AcquireLease takes or renews the lease of a name for holder until ttl from now. It
+// reports whether holder holds the lease, which fails while another holder's lease has
+// not expired.
+func AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	var current string
+	err := withTx(func(tx *Tx) error {
+		// Only the holder or anyone after expiry may take the lease
+		_, err := tx.Exec(`
+			INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+			WHERE leases.holder = excluded.holder OR leases.expires_at < ?`,
+			name, holder, now.Add(ttl), now,
+		)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow("SELECT holder FROM leases WHERE name = ?", name).Scan(&current)
+	})
+	if err != nil {
+		return false, err
+	}
+	return current == holder, nil
+}
+
+// ReleaseLease gives up the lease of a name if holder holds it, so another instance can
+// take it without waiting for it to expire
+func ReleaseLease(name, holder string) error {
+	_, err := DB.Exec("DELETE FROM leases WHERE name = ? AND holder = ?", name, holder)
+	return err
+}
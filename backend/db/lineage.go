@@ -0,0 +1,161 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// Lineage node types, in the order results are usually derived from each other
+const (
+	LineageConversation    = "conversation"
+	LineageAttributes      = "attributes"
+	LineageTrends          = "trends"
+	LineagePatterns        = "patterns"
+	LineageFindings        = "findings"
+	LineageIntent          = "intent"
+	LineageRecommendations = "recommendations"
+	LineagePlan            = "plan"
+)
+
+// LineageRef identifies a node in the lineage graph: a source conversation or a derived result
+type LineageRef struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// LineageEdge records that the target was derived from the source
+type LineageEdge struct {
+	Source     LineageRef `json:"source"`
+	Target     LineageRef `json:"target"`
+	WorkflowID string     `json:"workflow_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// createLineageTable creates the lineage_edges table if it doesn't exist
+func createLineageTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS lineage_edges (
+			source_type TEXT NOT NULL,
+			source_id TEXT NOT NULL,
+			target_type TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			workflow_id TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (source_type, source_id, target_type, target_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_lineage_edges_target ON lineage_edges (target_type, target_id)")
+	return err
+}
+
+// RecordLineage records for a tenant that target was derived from each of the sources.
+// Edges that already exist are left unchanged.
+func RecordLineage(tenantID, workflowID string, target LineageRef, sources []LineageRef) error {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	return withTx(func(tx *Tx) error {
+		for _, source := range sources {
+			_, err := tx.Exec(
+				"INSERT INTO lineage_edges (source_type, source_id, target_type, target_id, workflow_id, created_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+				source.Type, source.ID, target.Type, target.ID, workflowID, now, tenantID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetLineageUpstream returns every edge of a tenant on the paths leading to a node, back
+// to the source conversations
+func GetLineageUpstream(tenantID string, node LineageRef) ([]LineageEdge, error) {
+	return queryLineage(tenantID, `
+		WITH RECURSIVE upstream(source_type, source_id, target_type, target_id) AS (
+			SELECT source_type, source_id, target_type, target_id
+			FROM lineage_edges e WHERE target_type = ? AND target_id = ?{{tenant}}
+			UNION
+			SELECT e.source_type, e.source_id, e.target_type, e.target_id
+			FROM lineage_edges e
+			JOIN upstream u ON e.target_type = u.source_type AND e.target_id = u.source_id
+			WHERE 1 = 1{{tenant}}
+		)
+		SELECT e.source_type, e.source_id, e.target_type, e.target_id, COALESCE(e.workflow_id, ''), e.created_at
+		FROM lineage_edges e
+		JOIN upstream u ON e.source_type = u.source_type AND e.source_id = u.source_id
+			AND e.target_type = u.target_type AND e.target_id = u.target_id
+		WHERE 1 = 1{{tenant}}
+		ORDER BY e.created_at
+	`, node.Type, node.ID)
+}
+
+// GetLineageDownstream returns every edge of a tenant on the paths leading from a node to
+// the results derived from it
+func GetLineageDownstream(tenantID string, node LineageRef) ([]LineageEdge, error) {
+	return queryLineage(tenantID, `
+		WITH RECURSIVE downstream(source_type, source_id, target_type, target_id) AS (
+			SELECT source_type, source_id, target_type, target_id
+			FROM lineage_edges e WHERE source_type = ? AND source_id = ?{{tenant}}
+			UNION
+			SELECT e.source_type, e.source_id, e.target_type, e.target_id
+			FROM lineage_edges e
+			JOIN downstream d ON e.source_type = d.target_type AND e.source_id = d.target_id
+			WHERE 1 = 1{{tenant}}
+		)
+		SELECT e.source_type, e.source_id, e.target_type, e.target_id, COALESCE(e.workflow_id, ''), e.created_at
+		FROM lineage_edges e
+		JOIN downstream d ON e.source_type = d.source_type AND e.source_id = d.source_id
+			AND e.target_type = d.target_type AND e.target_id = d.target_id
+		WHERE 1 = 1{{tenant}}
+		ORDER BY e.created_at
+	`, node.Type, node.ID)
+}
+
+// queryLineage runs a lineage query restricted to the edges of a tenant and scans the
+// resulting edges. Each {{tenant}} in the query is replaced with the tenant condition on
+// e.tenant_id, and the node arguments are followed by the argument of each condition.
+func queryLineage(tenantID, query string, nodeType, nodeID string) ([]LineageEdge, error) {
+	condition, tenantArgs := tenantCondition("e.tenant_id", tenantID)
+	args := []interface{}{nodeType, nodeID}
+	parts := strings.Split(query, "{{tenant}}")
+	query = parts[0]
+	for _, part := range parts[1:] {
+		query += condition + part
+		args = append(args, tenantArgs...)
+	}
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edges := []LineageEdge{}
+	for rows.Next() {
+		var edge LineageEdge
+		err := rows.Scan(
+			&edge.Source.Type,
+			&edge.Source.ID,
+			&edge.Target.Type,
+			&edge.Target.ID,
+			&edge.WorkflowID,
+			&edge.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, edge)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return edges, nil
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// How the text of audited LLM calls is stored
+const (
+	AuditFull     = "full"     // Prompts and responses as sent and received
+	AuditRedacted = "redacted" // PII replaced with placeholders
+	AuditMetadata = "metadata" // No text, only hashes and lengths
+)
+
+// LLMAuditEntry is one audited LLM call. Prompt and Response are stored as Redaction
+// says; their hashes and lengths are those of the original text, so calls can be
+// compared whatever was stored.
+type LLMAuditEntry struct {
+	ID               string    `json:"id"`
+	TenantID         string    `json:"-"` // The tenant the call was made for; defaults to DefaultTenantID
+	RequestID        string    `json:"request_id,omitempty"`
+	Sequence         int       `json:"sequence"` // Position of the call among those of its request
+	Model            string    `json:"model"`
+	Schema           string    `json:"schema,omitempty"`
+	Prompt           string    `json:"prompt,omitempty"`
+	Response         string    `json:"response,omitempty"`
+	PromptHash       string    `json:"prompt_hash"`
+	ResponseHash     string    `json:"response_hash,omitempty"`
+	PromptLength     int       `json:"prompt_length"`
+	ResponseLength   int       `json:"response_length"`
+	Redaction        string    `json:"redaction"`
+	Error            string    `json:"error,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	DurationMS       int64     `json:"duration_ms"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// AuditKindAnalysis is the kind of audited /api/analysis requests, the only kind that is
+// replayed
+const AuditKindAnalysis = "analysis"
+
+// AuditRequest is an audited API request whose LLM calls can be replayed
+type AuditRequest struct {
+	RequestID string          `json:"request_id"`
+	TenantID  string          `json:"-"`    // The tenant that made the request; defaults to DefaultTenantID
+	Kind      string          `json:"kind"` // Request kind, e.g. analysis
+	Actor     string          `json:"actor,omitempty"`
+	Request   json.RawMessage `json:"request"`
+	ReplayOf  string          `json:"replay_of,omitempty"` // Request ID this request replayed
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// LLMAuditFilter narrows down the entries returned by ListLLMAuditEntries
+type LLMAuditFilter struct {
+	TenantID  string // The tenant whose calls are returned; AllTenants returns those of every tenant
+	RequestID string
+	Model     string
+	Schema    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// llmAuditColumns are the columns read by scanLLMAuditEntry
+const llmAuditColumns = "id, request_id, sequence, model, schema_name, prompt, response, prompt_hash, response_hash, prompt_length, response_length, redaction, error, prompt_tokens, completion_tokens, duration_ms, created_at"
+
+// createLLMAuditTables creates the LLM audit tables if they don't exist
+func createLLMAuditTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS llm_audit (
+			id TEXT PRIMARY KEY,
+			request_id TEXT,
+			sequence INTEGER NOT NULL DEFAULT 0,
+			model TEXT NOT NULL,
+			schema_name TEXT,
+			prompt TEXT,
+			response TEXT,
+			prompt_hash TEXT NOT NULL,
+			response_hash TEXT,
+			prompt_length INTEGER NOT NULL DEFAULT 0,
+			response_length INTEGER NOT NULL DEFAULT 0,
+			redaction TEXT NOT NULL,
+			error TEXT,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if _, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_llm_audit_request ON llm_audit (request_id, sequence)"); err != nil {
+		return err
+	}
+	if _, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_llm_audit_created ON llm_audit (created_at)"); err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_requests (
+			request_id TEXT PRIMARY KEY,
+			kind TEXT NOT NULL,
+			actor TEXT,
+			request TEXT NOT NULL,
+			replay_of TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// SaveLLMAuditEntry stores an audited LLM call. Calls of a request are numbered in the
+// order they are saved.
+func SaveLLMAuditEntry(entry LLMAuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if entry.TenantID == "" {
+		entry.TenantID = DefaultTenantID
+	}
+	_, err := DB.Exec(
+		`INSERT INTO llm_audit (id, request_id, sequence, model, schema_name, prompt, response, prompt_hash, response_hash,
+			prompt_length, response_length, redaction, error, prompt_tokens, completion_tokens, duration_ms, created_at, tenant_id)
+		VALUES (?, ?, (SELECT COUNT(*) + 1 FROM llm_audit WHERE request_id = ?), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID, nullString(entry.RequestID), entry.RequestID, entry.Model, nullString(entry.Schema),
+		nullString(entry.Prompt), nullString(entry.Response), entry.PromptHash, nullString(entry.ResponseHash),
+		entry.PromptLength, entry.ResponseLength, entry.Redaction, nullString(entry.Error),
+		entry.PromptTokens, entry.CompletionTokens, entry.DurationMS, entry.CreatedAt, entry.TenantID,
+	)
+	return err
+}
+
+// ListLLMAuditEntries returns audited LLM calls, newest first, or the calls of one
+// request in the order they were made
+func ListLLMAuditEntries(filter LLMAuditFilter) ([]LLMAuditEntry, error) {
+	condition, args := tenantCondition("tenant_id", filter.TenantID)
+	query := "SELECT " + llmAuditColumns + " FROM llm_audit WHERE 1 = 1" + condition
+
+	if filter.RequestID != "" {
+		query += " AND request_id = ?"
+		args = append(args, filter.RequestID)
+	}
+	if filter.Model != "" {
+		query += " AND model = ?"
+		args = append(args, filter.Model)
+	}
+	if filter.Schema != "" {
+		query += " AND schema_name = ?"
+		args = append(args, filter.Schema)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, filter.Until)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if filter.RequestID != "" {
+		query += " ORDER BY sequence LIMIT ?"
+	} else {
+		query += " ORDER BY created_at DESC LIMIT ?"
+	}
+	args = append(args, limit)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LLMAuditEntry{}
+	for rows.Next() {
+		entry, err := scanLLMAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// scanLLMAuditEntry reads an entry selected with llmAuditColumns
+func scanLLMAuditEntry(row rowScanner) (*LLMAuditEntry, error) {
+	var entry LLMAuditEntry
+	var requestID, schema, prompt, response, responseHash, errorText sql.NullString
+	err := row.Scan(&entry.ID, &requestID, &entry.Sequence, &entry.Model, &schema, &prompt, &response,
+		&entry.PromptHash, &responseHash, &entry.PromptLength, &entry.ResponseLength, &entry.Redaction, &errorText,
+		&entry.PromptTokens, &entry.CompletionTokens, &entry.DurationMS, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	entry.RequestID = requestID.String
+	entry.Schema = schema.String
+	entry.Prompt = prompt.String
+	entry.Response = response.String
+	entry.ResponseHash = responseHash.String
+	entry.Error = errorText.String
+	return &entry, nil
+}
+
+// SaveAuditRequest stores an API request so its LLM calls can be replayed
+func SaveAuditRequest(request AuditRequest) error {
+	if request.CreatedAt.IsZero() {
+		request.CreatedAt = time.Now()
+	}
+	if request.TenantID == "" {
+		request.TenantID = DefaultTenantID
+	}
+	_, err := DB.Exec(
+		"INSERT INTO audit_requests (request_id, kind, actor, request, replay_of, created_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		request.RequestID, request.Kind, nullString(request.Actor), string(request.Request), nullString(request.ReplayOf), request.CreatedAt,
+		request.TenantID,
+	)
+	return err
+}
+
+// GetAuditRequest returns a stored API request of a tenant, or nil if the tenant stored
+// none under the ID
+func GetAuditRequest(tenantID, requestID string) (*AuditRequest, error) {
+	var request AuditRequest
+	var actor, replayOf sql.NullString
+	var body string
+	condition, args := tenantCondition("tenant_id", tenantID)
+	err := DB.QueryRow(
+		"SELECT request_id, kind, actor, request, replay_of, created_at, tenant_id FROM audit_requests WHERE request_id = ?"+condition,
+		append([]interface{}{requestID}, args...)...,
+	).Scan(&request.RequestID, &request.Kind, &actor, &body, &replayOf, &request.CreatedAt, &request.TenantID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	request.Actor = actor.String
+	request.ReplayOf = replayOf.String
+	request.Request = json.RawMessage(body)
+	return &request, nil
+}
+
+// PurgeLLMAudit deletes the audited calls and requests older than before and returns how
+// many calls were deleted
+func PurgeLLMAudit(before time.Time) (int64, error) {
+	result, err := DB.Exec("DELETE FROM llm_audit WHERE created_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := DB.Exec("DELETE FROM audit_requests WHERE created_at < ?", before); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
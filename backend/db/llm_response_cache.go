@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// envLLMResponseCacheTTL is how long validated LLM replies are reused by calls with the
+// same model, schema and prompt; the response cache is off when it is unset or 0
+const envLLMResponseCacheTTL = "LLM_RESPONSE_CACHE_TTL"
+
+// LLMResponseCacheType is the analysis type LLM replies are stored under in the analysis
+// cache, which DELETE /api/analysis/cache?analysis_type=llm_response clears
+const LLMResponseCacheType = "llm_response"
+
+// LLMResponseCache stores validated LLM replies in the analysis cache for TTL. It is the
+// response cache of the analysis core; see core.SetResponseCache.
+type LLMResponseCache struct {
+	TTL time.Duration
+}
+
+// LLMResponseCacheTTL reads how long LLM replies are cached, which is 0 when the response
+// cache is off
+func LLMResponseCacheTTL() (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv(envLLMResponseCacheTTL))
+	if value == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid %s value %q", envLLMResponseCacheTTL, value)
+	}
+	return ttl, nil
+}
+
+// Get returns the reply cached under key, if it has not expired
+func (c LLMResponseCache) Get(ctx context.Context, key string) (json.RawMessage, bool) {
+	reply, ok, err := GetCachedAnalysis(key)
+	if err != nil {
+		log.Printf("Error reading LLM response cache: %v", err)
+		return nil, false
+	}
+	return reply, ok
+}
+
+// Put caches a reply under key for the cache's TTL
+func (c LLMResponseCache) Put(ctx context.Context, key string, reply json.RawMessage) {
+	if err := PutCachedAnalysis(key, LLMResponseCacheType, reply, c.TTL); err != nil {
+		log.Printf("Error writing LLM response cache: %v", err)
+	}
+}
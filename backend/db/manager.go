@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLite connection pool settings
+const (
+	// sqliteBusyTimeout is how long a connection waits for the lock another holds
+	sqliteBusyTimeout = 5 * time.Second
+	// maxSQLiteReaders bounds the read connections of a manager; WAL mode lets them read
+	// while a writer writes
+	maxSQLiteReaders = 8
+	// maxCachedStatements bounds the prepared statements a store keeps, as some statements
+	// are built for their arguments and never repeat
+	maxCachedStatements = 256
+)
+
+// ErrReadOnly is returned when a read-only manager is asked to write
+var ErrReadOnly = errors.New("database is opened read-only")
+
+// Manager pools the connections to a SQLite file. Writes go through the writer pool,
+// whose transactions take the write lock when they begin, and reads through a pool of
+// query-only connections. The file is switched to WAL mode, so reads don't wait for
+// writes, and prepared statements are cached per pool.
+type Manager struct {
+	path   string
+	writer *Store
+	reader *Store
+}
+
+// ManagerOptions configure a manager
+type ManagerOptions struct {
+	// ReadOnly opens the file without a writer pool and leaves its journal mode as it is,
+	// for databases the process only reads, such as the example conversation databases
+	ReadOnly bool
+	// MaxReaders bounds the read connections; maxSQLiteReaders when zero
+	MaxReaders int
+}
+
+// OpenManager opens the connection pools of a SQLite file
+func OpenManager(path string, options ManagerOptions) (*Manager, error) {
+	m := &Manager{path: path}
+	busy := fmt.Sprintf("_busy_timeout=%d", sqliteBusyTimeout.Milliseconds())
+
+	var err error
+	readerDSN := sqliteDSN(path, busy, "_query_only=true")
+	if options.ReadOnly {
+		readerDSN = sqliteDSN("file:"+path, "mode=ro", busy)
+	} else {
+		m.writer, err = openStore(sqliteDialect{}, sqliteDSN(path, busy, "_journal_mode=WAL", "_synchronous=NORMAL", "_txlock=immediate"))
+		if err != nil {
+			return nil, err
+		}
+		m.writer.stmts = newStmtCache()
+	}
+
+	if m.reader, err = openStore(sqliteDialect{}, readerDSN); err != nil {
+		m.Close()
+		return nil, err
+	}
+	readers := options.MaxReaders
+	if readers <= 0 {
+		readers = maxSQLiteReaders
+	}
+	m.reader.db.SetMaxOpenConns(readers)
+	m.reader.db.SetMaxIdleConns(readers)
+	m.reader.stmts = newStmtCache()
+	return m, nil
+}
+
+// sqliteDSN adds connection parameters to the path of a SQLite file
+func sqliteDSN(path string, params ...string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + strings.Join(params, "&")
+}
+
+var (
+	sharedMu      sync.Mutex
+	sharedReaders = map[string]*Manager{}
+)
+
+// SharedReader returns the read-only manager of a SQLite file, opening it on first use.
+// Managers are shared by the whole process and stay open, so callers reading the same
+// file many times reuse its connections and prepared statements instead of opening the
+// file for every read. Callers must not close them.
+func SharedReader(path string) (*Manager, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if m, ok := sharedReaders[path]; ok {
+		return m, nil
+	}
+	m, err := OpenManager(path, ManagerOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	sharedReaders[path] = m
+	return m, nil
+}
+
+// Path returns the file of the manager
+func (m *Manager) Path() string {
+	return m.path
+}
+
+// Writer returns the store writes go through; nil for read-only managers
+func (m *Manager) Writer() *Store {
+	return m.writer
+}
+
+// Reader returns the store reads go through
+func (m *Manager) Reader() *Store {
+	return m.reader
+}
+
+// Exec executes a statement without returning rows on the writer pool
+func (m *Manager) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if m.writer == nil {
+		return nil, ErrReadOnly
+	}
+	return m.writer.Exec(query, args...)
+}
+
+// Begin starts a write transaction
+func (m *Manager) Begin() (*Tx, error) {
+	if m.writer == nil {
+		return nil, ErrReadOnly
+	}
+	return m.writer.Begin()
+}
+
+// Query executes a statement returning rows on the reader pool
+func (m *Manager) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return m.reader.Query(query, args...)
+}
+
+// QueryContext executes a statement returning rows on the reader pool until ctx is done
+func (m *Manager) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return m.reader.QueryContext(ctx, query, args...)
+}
+
+// QueryRow executes a statement returning at most one row on the reader pool
+func (m *Manager) QueryRow(query string, args ...interface{}) *sql.Row {
+	return m.reader.QueryRow(query, args...)
+}
+
+// Close closes both pools
+func (m *Manager) Close() error {
+	var err error
+	if m.reader != nil {
+		err = m.reader.Close()
+	}
+	if m.writer != nil {
+		if werr := m.writer.Close(); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// stmtCache keeps the prepared statements of a store by query
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStmtCache() *stmtCache {
+	return &stmtCache{stmts: map[string]*sql.Stmt{}}
+}
+
+// cacheable reports whether a statement is worth preparing: statements that read or
+// change rows repeat, schema changes run once
+func cacheable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "WITH":
+		return true
+	}
+	return false
+}
+
+// get returns the prepared statement of a query, preparing it on first use. It returns
+// nil when the query isn't cached, so the caller runs it unprepared and gets any error
+// preparing it would have returned.
+func (c *stmtCache) get(conn *sql.DB, query string) *sql.Stmt {
+	if !cacheable(query) {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt
+	}
+	if len(c.stmts) >= maxCachedStatements {
+		return nil
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return nil
+	}
+	c.stmts[query] = stmt
+	return stmt
+}
+
+// close closes the cached statements
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for query, stmt := range c.stmts {
+		stmt.Close()
+		delete(c.stmts, query)
+	}
+}
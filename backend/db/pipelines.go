@@ -0,0 +1,158 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PipelineStep is one step of a pipeline
+type PipelineStep struct {
+	// Analysis is the analysis type the step runs; it also names the step
+	Analysis   string                 `json:"analysis"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	DependsOn  []string               `json:"depends_on,omitempty"`
+	// InputMapping maps keys of the step input to "input" or a step name, optionally
+	// followed by a dotted path into it
+	InputMapping map[string]string `json:"input_mapping,omitempty"`
+}
+
+// Pipeline is a stored chain analysis configuration that can be executed by ID
+type Pipeline struct {
+	ID                    string         `json:"id"`
+	Name                  string         `json:"name"`
+	Description           string         `json:"description,omitempty"`
+	Steps                 []PipelineStep `json:"steps"`
+	ConfidencePropagation string         `json:"confidence_propagation,omitempty"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+}
+
+// createPipelinesTable creates the pipelines table if it doesn't exist
+func createPipelinesTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS pipelines (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			description TEXT,
+			steps TEXT NOT NULL,
+			confidence_propagation TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			UNIQUE(tenant_id, name)
+		)
+	`)
+	return err
+}
+
+// pipelineColumns are the columns read into a Pipeline, in scan order
+const pipelineColumns = "id, name, description, steps, confidence_propagation, created_at, updated_at"
+
+// CreatePipeline stores a new pipeline of a tenant
+func CreatePipeline(tenantID string, pipeline Pipeline) error {
+	steps, err := json.Marshal(pipeline.Steps)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = DB.Exec(
+		"INSERT INTO pipelines (id, name, description, steps, confidence_propagation, created_at, updated_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		pipeline.ID, pipeline.Name, pipeline.Description, string(steps), pipeline.ConfidencePropagation, now, now, tenantID,
+	)
+	return pipelineNameError(pipeline.Name, err)
+}
+
+// GetPipeline returns a pipeline of a tenant by ID
+func GetPipeline(tenantID, id string) (Pipeline, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	row := DB.QueryRow("SELECT "+pipelineColumns+" FROM pipelines WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
+	pipeline, err := scanPipeline(row)
+	if err == sql.ErrNoRows {
+		return Pipeline{}, fmt.Errorf("pipeline not found")
+	}
+	return pipeline, err
+}
+
+// ListPipelines returns all pipelines of a tenant ordered by name
+func ListPipelines(tenantID string) ([]Pipeline, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query("SELECT "+pipelineColumns+" FROM pipelines WHERE 1 = 1"+condition+" ORDER BY name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pipelines := []Pipeline{}
+	for rows.Next() {
+		pipeline, err := scanPipeline(rows)
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pipelines, nil
+}
+
+// UpdatePipeline replaces the name, description and steps of a pipeline of a tenant
+func UpdatePipeline(tenantID string, pipeline Pipeline) error {
+	steps, err := json.Marshal(pipeline.Steps)
+	if err != nil {
+		return err
+	}
+	condition, args := tenantCondition("tenant_id", tenantID)
+	result, err := DB.Exec(
+		"UPDATE pipelines SET name = ?, description = ?, steps = ?, confidence_propagation = ?, updated_at = ? WHERE id = ?"+condition,
+		append([]interface{}{pipeline.Name, pipeline.Description, string(steps), pipeline.ConfidencePropagation, time.Now(), pipeline.ID}, args...)...,
+	)
+	if err != nil {
+		return pipelineNameError(pipeline.Name, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("pipeline not found")
+	}
+	return nil
+}
+
+// DeletePipeline deletes a pipeline of a tenant
+func DeletePipeline(tenantID, id string) error {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	result, err := DB.Exec("DELETE FROM pipelines WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("pipeline not found")
+	}
+	return nil
+}
+
+// pipelineNameError reports a pipeline name taken by another pipeline of the tenant
+func pipelineNameError(name string, err error) error {
+	if err != nil && isUniqueViolation(err) {
+		return fmt.Errorf("pipeline %s already exists", name)
+	}
+	return err
+}
+
+// scanPipeline reads a pipeline from a row
+func scanPipeline(row rowScanner) (Pipeline, error) {
+	var pipeline Pipeline
+	var description, propagation sql.NullString
+	var steps string
+	if err := row.Scan(&pipeline.ID, &pipeline.Name, &description, &steps, &propagation, &pipeline.CreatedAt, &pipeline.UpdatedAt); err != nil {
+		return Pipeline{}, err
+	}
+	pipeline.Description = description.String
+	pipeline.ConfidencePropagation = propagation.String
+	if err := json.Unmarshal([]byte(steps), &pipeline.Steps); err != nil {
+		return Pipeline{}, fmt.Errorf("invalid steps of pipeline %s: %w", pipeline.ID, err)
+	}
+	return pipeline, nil
+}
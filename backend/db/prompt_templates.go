@@ -0,0 +1,181 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Sources of prompt templates
+const (
+	PromptTemplateSourceDB   = "db"
+	PromptTemplateSourceFile = "file"
+)
+
+// PromptTemplate replaces the built-in prompt of an analysis with a Go template
+type PromptTemplate struct {
+	ID string `json:"id"`
+	// AnalysisType names the prompt the template replaces, e.g. "sentiment" or "intent_batch"
+	AnalysisType string    `json:"analysis_type"`
+	Version      int       `json:"version"`
+	Template     string    `json:"template"`
+	Description  string    `json:"description,omitempty"`
+	Source       string    `json:"source"`
+	Active       bool      `json:"active"` // Used by every request that doesn't pick another template
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// createPromptTemplatesTables creates the prompt_templates and active_prompt_templates
+// tables if they don't exist
+func createPromptTemplatesTables() error {
+	if _, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS prompt_templates (
+			id TEXT PRIMARY KEY,
+			analysis_type TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			template TEXT NOT NULL,
+			description TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			UNIQUE(tenant_id, analysis_type, version)
+		)
+	`); err != nil {
+		return err
+	}
+
+	// The template of each analysis type a tenant uses by default, which may be a file template
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS active_prompt_templates (
+			analysis_type TEXT NOT NULL,
+			template_id TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			PRIMARY KEY (tenant_id, analysis_type)
+		)
+	`)
+	return err
+}
+
+// CreatePromptTemplate stores a new template of a tenant as the tenant's next version of
+// its analysis type, at least minVersion, and returns the version
+func CreatePromptTemplate(tenantID string, t PromptTemplate, minVersion int) (int, error) {
+	var version int
+	err := withTx(func(tx *Tx) error {
+		if err := tx.QueryRow(
+			"SELECT COALESCE(MAX(version), 0) + 1 FROM prompt_templates WHERE analysis_type = ? AND tenant_id = ?", t.AnalysisType, tenantID,
+		).Scan(&version); err != nil {
+			return err
+		}
+		version = max(version, minVersion)
+		_, err := tx.Exec(
+			"INSERT INTO prompt_templates (id, analysis_type, version, template, description, created_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			t.ID, t.AnalysisType, version, t.Template, t.Description, time.Now(), tenantID,
+		)
+		return err
+	})
+	return version, err
+}
+
+// promptTemplateColumns are the columns read into a PromptTemplate, in scan order
+const promptTemplateColumns = "id, analysis_type, version, template, description, created_at"
+
+// GetPromptTemplate returns a stored template of a tenant by ID
+func GetPromptTemplate(tenantID, id string) (PromptTemplate, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	row := DB.QueryRow("SELECT "+promptTemplateColumns+" FROM prompt_templates WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
+	t, err := scanPromptTemplate(row)
+	if err == sql.ErrNoRows {
+		return PromptTemplate{}, fmt.Errorf("prompt template not found")
+	}
+	return t, err
+}
+
+// ListPromptTemplates returns the stored templates of a tenant, of one analysis type if
+// set, ordered by analysis type and version
+func ListPromptTemplates(tenantID, analysisType string) ([]PromptTemplate, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	query := "SELECT " + promptTemplateColumns + " FROM prompt_templates WHERE 1 = 1" + condition
+	if analysisType != "" {
+		query += " AND analysis_type = ?"
+		args = append(args, analysisType)
+	}
+	rows, err := DB.Query(query+" ORDER BY analysis_type, version", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []PromptTemplate{}
+	for rows.Next() {
+		t, err := scanPromptTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// DeletePromptTemplate deletes a stored template of a tenant, deactivating it if it was
+// active
+func DeletePromptTemplate(tenantID, id string) error {
+	return withTx(func(tx *Tx) error {
+		result, err := tx.Exec("DELETE FROM prompt_templates WHERE id = ? AND tenant_id = ?", id, tenantID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("prompt template not found")
+		}
+		_, err = tx.Exec("DELETE FROM active_prompt_templates WHERE template_id = ? AND tenant_id = ?", id, tenantID)
+		return err
+	})
+}
+
+// scanPromptTemplate scans a stored template
+func scanPromptTemplate(row rowScanner) (PromptTemplate, error) {
+	var t PromptTemplate
+	var description sql.NullString
+	if err := row.Scan(&t.ID, &t.AnalysisType, &t.Version, &t.Template, &description, &t.CreatedAt); err != nil {
+		return PromptTemplate{}, err
+	}
+	t.Description = description.String
+	t.Source = PromptTemplateSourceDB
+	return t, nil
+}
+
+// GetActivePromptTemplates returns the ID of the active template of a tenant for each
+// analysis type
+func GetActivePromptTemplates(tenantID string) (map[string]string, error) {
+	rows, err := DB.Query("SELECT analysis_type, template_id FROM active_prompt_templates WHERE tenant_id = ?", tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	active := map[string]string{}
+	for rows.Next() {
+		var analysisType, templateID string
+		if err := rows.Scan(&analysisType, &templateID); err != nil {
+			return nil, err
+		}
+		active[analysisType] = templateID
+	}
+	return active, rows.Err()
+}
+
+// SetActivePromptTemplate makes a template the default of a tenant for its analysis type
+func SetActivePromptTemplate(tenantID, analysisType, templateID string) error {
+	_, err := DB.Exec(
+		`INSERT INTO active_prompt_templates (analysis_type, template_id, updated_at, tenant_id) VALUES (?, ?, ?, ?)
+		ON CONFLICT(tenant_id, analysis_type) DO UPDATE SET template_id = excluded.template_id, updated_at = excluded.updated_at`,
+		analysisType, templateID, time.Now(), tenantID,
+	)
+	return err
+}
+
+// ClearActivePromptTemplate restores the built-in prompt of an analysis type for a tenant
+func ClearActivePromptTemplate(tenantID, analysisType string) error {
+	_, err := DB.Exec("DELETE FROM active_prompt_templates WHERE analysis_type = ? AND tenant_id = ?", analysisType, tenantID)
+	return err
+}
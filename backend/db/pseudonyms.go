@@ -0,0 +1,90 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// Pseudonym maps an opaque token used in exports and reports to the identifier it replaces
+type Pseudonym struct {
+	Token     string    `json:"token"`
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createPseudonymsTable creates the pseudonyms table if it doesn't exist
+func createPseudonymsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS pseudonyms (
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			token TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			original_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (tenant_id, token)
+		)
+	`)
+	return err
+}
+
+// SavePseudonyms records the identifiers behind tokens a tenant was shown. Tokens already
+// recorded for the tenant are kept.
+func SavePseudonyms(tenantID string, pseudonyms []Pseudonym) error {
+	if len(pseudonyms) == 0 {
+		return nil
+	}
+
+	return withTx(func(tx *Tx) error {
+		stmt, err := tx.Prepare("INSERT INTO pseudonyms (tenant_id, token, kind, original_id, created_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		now := time.Now()
+		for _, pseudonym := range pseudonyms {
+			if _, err := stmt.Exec(tenantID, pseudonym.Token, pseudonym.Kind, pseudonym.ID, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ResolvePseudonyms returns the identifiers of tokens recorded for a tenant. Unknown tokens
+// are left out.
+func ResolvePseudonyms(tenantID string, tokens []string) ([]Pseudonym, error) {
+	pseudonyms := []Pseudonym{}
+	if len(tokens) == 0 {
+		return pseudonyms, nil
+	}
+
+	args := make([]interface{}, len(tokens), len(tokens)+1)
+	for i, token := range tokens {
+		args[i] = token
+	}
+	condition, tenantArgs := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query(
+		"SELECT token, kind, original_id, created_at FROM pseudonyms WHERE token IN (?"+strings.Repeat(", ?", len(tokens)-1)+")"+condition,
+		append(args, tenantArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pseudonym Pseudonym
+		if err := rows.Scan(&pseudonym.Token, &pseudonym.Kind, &pseudonym.ID, &pseudonym.CreatedAt); err != nil {
+			return nil, err
+		}
+		pseudonyms = append(pseudonyms, pseudonym)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pseudonyms, nil
+}
@@ -0,0 +1,370 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Run kinds
+const (
+	RunKindWorkflow = "workflow"
+	RunKindChain    = "chain"
+)
+
+// Run states; a workflow run paused at a review node awaits review until it resumes or
+// its review is rejected
+const (
+	RunRunning        = "running"
+	RunAwaitingReview = "awaiting_review"
+	RunCompleted      = "completed"
+	RunFailed         = "failed"
+	RunRejected       = "rejected"
+)
+
+// Run is the history of one execution of a workflow or chain analysis: what it was given,
+// what each of its nodes produced, the models it called and the analysis results saved as
+// part of it
+type Run struct {
+	RunID      string          `json:"run_id"`
+	TenantID   string          `json:"-"`
+	WorkflowID string          `json:"workflow_id"`
+	Kind       string          `json:"kind"`
+	Actor      string          `json:"actor,omitempty"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Inputs     json.RawMessage `json:"inputs,omitempty"`
+	Models     []string        `json:"models"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	// DurationMS is the time the run executed, without the time it awaited review
+	DurationMS int64 `json:"duration_ms"`
+
+	// Nodes and Results are only loaded with a single run
+	Nodes   []RunNode   `json:"nodes,omitempty"`
+	Results []RunResult `json:"results,omitempty"`
+}
+
+// RunNode is the execution of one node, or chain step, of a run
+type RunNode struct {
+	NodeID     string          `json:"node_id"`
+	NodeType   string          `json:"node_type"`
+	Position   int             `json:"position"`
+	Status     string          `json:"status"`
+	StartedAt  time.Time       `json:"started_at"`
+	DurationMS int64           `json:"duration_ms"`
+	Output     json.RawMessage `json:"output,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// RunResult is an analysis result saved as part of a run
+type RunResult struct {
+	ResultID     string    `json:"result_id"`
+	AnalysisType string    `json:"analysis_type"`
+	Confidence   *float64  `json:"confidence,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RunFilter selects the runs of a workflow
+type RunFilter struct {
+	TenantID   string // The tenant whose runs are returned; AllTenants returns those of every tenant
+	WorkflowID string
+	Status     string // A run state, or empty for all
+	Limit      int
+}
+
+// runColumns are the columns read into a Run, in scan order
+const runColumns = "run_id, workflow_id, kind, actor, status, error, inputs, models, started_at, finished_at, duration_ms"
+
+// createRunsTables creates the runs and run_nodes tables if they don't exist
+func createRunsTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS runs (
+			run_id TEXT PRIMARY KEY,
+			workflow_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			actor TEXT,
+			status TEXT NOT NULL,
+			error TEXT,
+			inputs TEXT,
+			models TEXT,
+			started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMP,
+			duration_ms INTEGER NOT NULL DEFAULT 0
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_runs_workflow ON runs (workflow_id, started_at)")
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS run_nodes (
+			run_id TEXT NOT NULL,
+			node_id TEXT NOT NULL,
+			node_type TEXT,
+			position INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			started_at TIMESTAMP,
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			output TEXT,
+			error TEXT,
+			PRIMARY KEY (run_id, node_id)
+		)
+	`)
+	return err
+}
+
+// CreateRun records the start of a run. It fails if the run ID is taken.
+func CreateRun(run Run) error {
+	if run.StartedAt.IsZero() {
+		run.StartedAt = time.Now()
+	}
+	_, err := DB.Exec(
+		"INSERT INTO runs (run_id, tenant_id, workflow_id, kind, actor, status, inputs, models, started_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		run.RunID, run.TenantID, run.WorkflowID, run.Kind, nullString(run.Actor), RunRunning, nullString(string(run.Inputs)), "[]", run.StartedAt,
+	)
+	if err != nil && isUniqueViolation(err) {
+		return fmt.Errorf("run %s already exists", run.RunID)
+	}
+	return err
+}
+
+// ResumeRun marks a run that awaited review as running again
+func ResumeRun(runID string) error {
+	_, err := DB.Exec("UPDATE runs SET status = ?, finished_at = NULL WHERE run_id = ?", RunRunning, runID)
+	return err
+}
+
+// FinishRun records that a run stopped with a status after executing for duration, which
+// adds to the time it executed before. Runs awaiting review are not finished yet.
+func FinishRun(runID, status, runError string, duration time.Duration) error {
+	var finishedAt interface{}
+	if status != RunAwaitingReview {
+		finishedAt = time.Now()
+	}
+	_, err := DB.Exec(
+		"UPDATE runs SET status = ?, error = ?, finished_at = ?, duration_ms = duration_ms + ? WHERE run_id = ?",
+		status, nullString(runError), finishedAt, duration.Milliseconds(), runID,
+	)
+	return err
+}
+
+// SaveRunNodes records the execution of nodes of a run. A node that runs again, such as
+// a review node once it is reviewed, replaces its earlier record and keeps its position.
+func SaveRunNodes(runID string, nodes []RunNode) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return withTx(func(tx *Tx) error {
+		for _, node := range nodes {
+			_, err := tx.Exec(`
+				INSERT INTO run_nodes (run_id, node_id, node_type, position, status, started_at, duration_ms, output, error)
+				VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM run_nodes WHERE run_id = ?), ?, ?, ?, ?, ?)
+				ON CONFLICT(run_id, node_id) DO UPDATE SET node_type = excluded.node_type, status = excluded.status,
+					started_at = excluded.started_at, duration_ms = excluded.duration_ms, output = excluded.output,
+					error = excluded.error`,
+				runID, node.NodeID, nullString(node.NodeType), runID, node.Status, node.StartedAt, node.DurationMS,
+				nullString(string(node.Output)), nullString(node.Error),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddRunModels records that a run called models, in addition to those it called before
+func AddRunModels(runID string, models []string) error {
+	if len(models) == 0 {
+		return nil
+	}
+	return withTx(func(tx *Tx) error {
+		var stored sql.NullString
+		err := tx.QueryRow("SELECT models FROM runs WHERE run_id = ?", runID).Scan(&stored)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("run not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		merged := decodeRunModels(stored.String)
+		for _, model := range models {
+			if !slices.Contains(merged, model) {
+				merged = append(merged, model)
+			}
+		}
+		sort.Strings(merged)
+		encoded, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE runs SET models = ? WHERE run_id = ?", string(encoded), runID)
+		return err
+	})
+}
+
+// GetRunWorkflowID returns the ID of the workflow a run of a tenant belongs to
+func GetRunWorkflowID(tenantID, runID string) (string, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	var workflowID string
+	err := DB.QueryRow("SELECT workflow_id FROM runs WHERE run_id = ?"+condition, append([]interface{}{runID}, args...)...).Scan(&workflowID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("run not found")
+	}
+	return workflowID, err
+}
+
+// GetRun returns a run of a tenant with its nodes in the order they ran and the analysis
+// results saved as part of it, oldest first
+func GetRun(tenantID, runID string) (*Run, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	run, err := scanRun(DB.QueryRow("SELECT "+runColumns+" FROM runs WHERE run_id = ?"+condition, append([]interface{}{runID}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("run not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT node_id, node_type, position, status, started_at, duration_ms, output, error
+		FROM run_nodes WHERE run_id = ? ORDER BY position`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	run.Nodes = []RunNode{}
+	for rows.Next() {
+		var node RunNode
+		var nodeType, output, nodeError sql.NullString
+		var startedAt sql.NullTime
+		if err := rows.Scan(&node.NodeID, &nodeType, &node.Position, &node.Status, &startedAt, &node.DurationMS, &output, &nodeError); err != nil {
+			return nil, err
+		}
+		node.NodeType = nodeType.String
+		if startedAt.Valid {
+			node.StartedAt = startedAt.Time
+		}
+		if output.String != "" {
+			node.Output = json.RawMessage(output.String)
+		}
+		node.Error = nodeError.String
+		run.Nodes = append(run.Nodes, node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	run.Results, err = getRunResults(runID)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// getRunResults returns the analysis results saved as part of a run, oldest first
+func getRunResults(runID string) ([]RunResult, error) {
+	results := []RunResult{}
+	exists, err := tableExists("analysis_results")
+	if err != nil || !exists {
+		return results, err
+	}
+
+	rows, err := DB.Query(
+		"SELECT id, analysis_type, confidence, created_at FROM analysis_results WHERE run_id = ? ORDER BY created_at", runID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result RunResult
+		var confidence sql.NullFloat64
+		if err := rows.Scan(&result.ResultID, &result.AnalysisType, &confidence, &result.CreatedAt); err != nil {
+			return nil, err
+		}
+		if confidence.Valid {
+			result.Confidence = &confidence.Float64
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ListRuns returns the runs of a workflow matching a filter, newest first, without their
+// nodes and results
+func ListRuns(filter RunFilter) ([]Run, error) {
+	condition, tenantArgs := tenantCondition("tenant_id", filter.TenantID)
+	query := "SELECT " + runColumns + " FROM runs WHERE workflow_id = ?" + condition
+	args := append([]interface{}{filter.WorkflowID}, tenantArgs...)
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	query += " ORDER BY started_at DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// scanRun reads a Run from a row
+func scanRun(row rowScanner) (*Run, error) {
+	var run Run
+	var actor, runError, inputs, models sql.NullString
+	var finishedAt sql.NullTime
+	err := row.Scan(&run.RunID, &run.WorkflowID, &run.Kind, &actor, &run.Status, &runError, &inputs, &models,
+		&run.StartedAt, &finishedAt, &run.DurationMS)
+	if err != nil {
+		return nil, err
+	}
+	run.Actor = actor.String
+	run.Error = runError.String
+	if inputs.String != "" {
+		run.Inputs = json.RawMessage(inputs.String)
+	}
+	run.Models = decodeRunModels(models.String)
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
+
+// decodeRunModels parses the stored models of a run
+func decodeRunModels(stored string) []string {
+	models := []string{}
+	if stored != "" {
+		if err := json.Unmarshal([]byte(stored), &models); err != nil || models == nil {
+			return []string{}
+		}
+	}
+	return models
+}
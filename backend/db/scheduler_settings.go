@@ -0,0 +1,65 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SchedulerSettings are the LLM call limits set through the API, which replace the
+// limits configured in the environment. A limit of 0 means no limit.
+type SchedulerSettings struct {
+	MaxConcurrentCalls      int `json:"max_concurrent_calls"`
+	WorkflowConcurrentCalls int `json:"workflow_concurrent_calls"`
+
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// createSchedulerSettingsTable creates the scheduler_settings table if it doesn't exist.
+// It holds at most one row.
+func createSchedulerSettingsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS scheduler_settings (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			max_concurrent_calls INTEGER NOT NULL DEFAULT 0,
+			workflow_concurrent_calls INTEGER NOT NULL DEFAULT 0,
+			updated_by TEXT NOT NULL DEFAULT '',
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// GetSchedulerSettings returns the scheduler settings, or nil until they are set
+func GetSchedulerSettings() (*SchedulerSettings, error) {
+	var settings SchedulerSettings
+	var updatedAt time.Time
+	err := DB.QueryRow("SELECT max_concurrent_calls, workflow_concurrent_calls, updated_by, updated_at FROM scheduler_settings WHERE id = 1").
+		Scan(&settings.MaxConcurrentCalls, &settings.WorkflowConcurrentCalls, &settings.UpdatedBy, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduler settings: %w", err)
+	}
+	settings.UpdatedAt = &updatedAt
+	return &settings, nil
+}
+
+// SaveSchedulerSettings replaces the scheduler settings
+func SaveSchedulerSettings(settings SchedulerSettings, actor string) error {
+	_, err := DB.Exec(
+		`INSERT INTO scheduler_settings (id, max_concurrent_calls, workflow_concurrent_calls, updated_by, updated_at) VALUES (1, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET max_concurrent_calls = excluded.max_concurrent_calls,
+			workflow_concurrent_calls = excluded.workflow_concurrent_calls, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+		settings.MaxConcurrentCalls, settings.WorkflowConcurrentCalls, actor, time.Now(),
+	)
+	return err
+}
+
+// DeleteSchedulerSettings clears the scheduler settings, so the environment applies again
+func DeleteSchedulerSettings() error {
+	_, err := DB.Exec("DELETE FROM scheduler_settings")
+	return err
+}
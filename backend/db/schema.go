@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// SchemaVersion is the version of the schema this build creates. It increases whenever a
+// release adds tables or columns, so an older binary can tell it runs against a newer
+// database.
+const SchemaVersion = 7
+
+// schemaVersionKey is the schema_info entry holding the schema version
+const schemaVersionKey = "schema_version"
+
+// requiredTables are the tables the server needs. analysis_results is created when the
+// analysis endpoints start.
+var requiredTables = []string{
+	"active_prompt_templates", "activity", "agents", "analysis_cache", "analysis_jobs",
+	"analysis_results", "api_keys", "attribute_flags", "attribute_set_versions",
+	"attribute_sets", "canaries", "canary_metrics", "chain_run_steps", "chain_runs",
+	"conversation_attribute_revisions", "conversation_attributes", "conversation_processing",
+	"conversation_translations",
+	"conversations", "embeddings", "leases", "lineage_edges", "pipelines",
+	"prompt_templates", "pseudonyms", "run_nodes", "runs", "schema_info", "scratch_runs", "scratch_sessions",
+	"sla_runs", "tool_manifests", "tools", "usage_calls", "usage_records",
+	"webhook_subscriptions", "widgets", "workflow_concurrency", "workflow_operations",
+	"workflow_slas", "workflows", "workspace_defaults",
+}
+
+// createSchemaInfoTable creates the table of schema settings if it doesn't exist
+func createSchemaInfoTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_info (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// recordSchemaVersion records the schema version of this build, unless a newer build
+// already upgraded the database
+func recordSchemaVersion() error {
+	version, err := GetSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version >= SchemaVersion {
+		return nil
+	}
+	_, err = DB.Exec(`
+		INSERT INTO schema_info (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		schemaVersionKey, strconv.Itoa(SchemaVersion), time.Now())
+	return err
+}
+
+// GetSchemaVersion returns the schema version recorded in the database, or 0 when the
+// database predates versioning or was never initialized
+func GetSchemaVersion() (int, error) {
+	exists, err := tableExists("schema_info")
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var value string
+	err = DB.QueryRow("SELECT value FROM schema_info WHERE key = ?", schemaVersionKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// MissingTables returns the required tables the database lacks
+func MissingTables() ([]string, error) {
+	var missing []string
+	for _, table := range requiredTables {
+		exists, err := tableExists(table)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, table)
+		}
+	}
+	return missing, nil
+}
+
+// Ping checks that the database answers
+func Ping() error {
+	return DB.db.Ping()
+}
+
+// Location describes the database for messages: the path of the SQLite file, or the
+// PostgreSQL URL without its password
+func Location() string {
+	return location
+}
+
+// FilePath returns the path of the SQLite database file, or "" for PostgreSQL
+func FilePath() string {
+	return filePath
+}
+
+// tableExists reports whether the database has a table
+func tableExists(table string) (bool, error) {
+	var count int
+	if err := DB.QueryRow(DB.dialect.tableExistsQuery(), table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
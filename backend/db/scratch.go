@@ -0,0 +1,246 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ScratchSession groups ad-hoc workflow runs and analyses under a session ID instead of
+// a saved workflow. Sessions expire after TTL without use, and everything stored under
+// them is deleted with them.
+type ScratchSession struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name,omitempty"`
+	Actor      string    `json:"actor"`
+	TTLSeconds int64     `json:"ttl_seconds"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	TenantID   string    `json:"-"` // The tenant the session belongs to
+}
+
+// ScratchRun is an ad-hoc workflow run of a scratch session
+type ScratchRun struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"session_id"`
+	Nodes     json.RawMessage `json:"nodes"`
+	Edges     json.RawMessage `json:"edges"`
+	Results   json.RawMessage `json:"results,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// createScratchTables creates the scratch_sessions and scratch_runs tables if they don't exist
+func createScratchTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS scratch_sessions (
+			id TEXT PRIMARY KEY,
+			name TEXT,
+			actor TEXT NOT NULL,
+			ttl_seconds INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS scratch_runs (
+			id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			nodes TEXT NOT NULL,
+			edges TEXT NOT NULL,
+			results TEXT,
+			error TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_scratch_runs_session ON scratch_runs (session_id, created_at)")
+	return err
+}
+
+// CreateScratchSession stores a new scratch session
+func CreateScratchSession(session ScratchSession) error {
+	_, err := DB.Exec(
+		"INSERT INTO scratch_sessions (id, name, actor, ttl_seconds, created_at, expires_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		session.ID, nullString(session.Name), session.Actor, session.TTLSeconds, session.CreatedAt, session.ExpiresAt, session.TenantID,
+	)
+	return err
+}
+
+// scratchSessionColumns are the columns read by scanScratchSession
+const scratchSessionColumns = "id, name, actor, ttl_seconds, created_at, expires_at, tenant_id"
+
+// GetScratchSession returns a scratch session of a tenant that has not expired
+func GetScratchSession(tenantID, id string) (ScratchSession, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	session, err := scanScratchSession(DB.QueryRow(
+		"SELECT "+scratchSessionColumns+" FROM scratch_sessions WHERE id = ? AND expires_at > ?"+condition,
+		append([]interface{}{id, time.Now()}, args...)...,
+	))
+	if err == sql.ErrNoRows {
+		return ScratchSession{}, fmt.Errorf("scratch session not found")
+	}
+	return session, err
+}
+
+// ListScratchSessions returns the scratch sessions of a tenant that have not expired,
+// newest first
+func ListScratchSessions(tenantID string) ([]ScratchSession, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query(
+		"SELECT "+scratchSessionColumns+" FROM scratch_sessions WHERE expires_at > ?"+condition+" ORDER BY created_at DESC",
+		append([]interface{}{time.Now()}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []ScratchSession{}
+	for rows.Next() {
+		session, err := scanScratchSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchScratchSession pushes the expiry of a session back to its TTL from now
+func TouchScratchSession(id string) (time.Time, error) {
+	var ttl int64
+	if err := DB.QueryRow("SELECT ttl_seconds FROM scratch_sessions WHERE id = ?", id).Scan(&ttl); err != nil {
+		return time.Time{}, err
+	}
+	expiresAt := time.Now().Add(time.Duration(ttl) * time.Second)
+	_, err := DB.Exec("UPDATE scratch_sessions SET expires_at = ? WHERE id = ?", expiresAt, id)
+	return expiresAt, err
+}
+
+// SaveScratchRun stores a run of a scratch session
+func SaveScratchRun(run ScratchRun) error {
+	if run.CreatedAt.IsZero() {
+		run.CreatedAt = time.Now()
+	}
+	_, err := DB.Exec(
+		"INSERT INTO scratch_runs (id, session_id, nodes, edges, results, error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		run.ID, run.SessionID, string(run.Nodes), string(run.Edges), nullString(string(run.Results)), nullString(run.Error), run.CreatedAt,
+	)
+	return err
+}
+
+// ListScratchRuns returns the runs of a scratch session, oldest first
+func ListScratchRuns(sessionID string) ([]ScratchRun, error) {
+	rows, err := DB.Query(
+		"SELECT id, session_id, nodes, edges, results, error, created_at FROM scratch_runs WHERE session_id = ? ORDER BY created_at",
+		sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []ScratchRun{}
+	for rows.Next() {
+		var run ScratchRun
+		var nodes, edges string
+		var results, runError sql.NullString
+		if err := rows.Scan(&run.ID, &run.SessionID, &nodes, &edges, &results, &runError, &run.CreatedAt); err != nil {
+			return nil, err
+		}
+		run.Nodes = json.RawMessage(nodes)
+		run.Edges = json.RawMessage(edges)
+		if results.String != "" {
+			run.Results = json.RawMessage(results.String)
+		}
+		run.Error = runError.String
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// DeleteScratchSession deletes a scratch session of a tenant with its runs and everything
+// stored under its ID: analysis results, their lineage and chain runs
+func DeleteScratchSession(tenantID, id string) error {
+	hasResults, err := tableExists("analysis_results")
+	if err != nil {
+		return err
+	}
+	return withTx(func(tx *Tx) error {
+		condition, args := tenantCondition("tenant_id", tenantID)
+		result, err := tx.Exec("DELETE FROM scratch_sessions WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("scratch session not found")
+		}
+
+		statements := []string{
+			"DELETE FROM scratch_runs WHERE session_id = ?",
+			"DELETE FROM lineage_edges WHERE workflow_id = ?",
+			"DELETE FROM chain_run_steps WHERE run_id IN (SELECT run_id FROM chain_runs WHERE workflow_id = ?)",
+			"DELETE FROM chain_runs WHERE workflow_id = ?",
+			"DELETE FROM run_nodes WHERE run_id IN (SELECT run_id FROM runs WHERE workflow_id = ?)",
+			"DELETE FROM runs WHERE workflow_id = ?",
+		}
+		if hasResults {
+			statements = append(statements, "DELETE FROM analysis_results WHERE workflow_id = ?")
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteExpiredScratchSessions deletes the sessions that expired before now, with
+// everything stored under them, and returns how many were deleted
+func DeleteExpiredScratchSessions(now time.Time) (int, error) {
+	rows, err := DB.Query("SELECT id FROM scratch_sessions WHERE expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		if err := DeleteScratchSession(AllTenants, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// scanScratchSession reads a scratch session from a row
+func scanScratchSession(row rowScanner) (ScratchSession, error) {
+	var session ScratchSession
+	var name sql.NullString
+	if err := row.Scan(&session.ID, &name, &session.Actor, &session.TTLSeconds, &session.CreatedAt, &session.ExpiresAt, &session.TenantID); err != nil {
+		return ScratchSession{}, err
+	}
+	session.Name = name.String
+	return session, nil
+}
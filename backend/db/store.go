@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Store is the connection to the storage backend. The statements of this package are
+// written for SQLite; the store rewrites them for the dialect of the backend.
+type Store struct {
+	db      *sql.DB
+	dialect dialect
+	// stmts caches prepared statements; nil when statements are not cached
+	stmts *stmtCache
+}
+
+// openStore opens a connection to a backend of the dialect
+func openStore(d dialect, dsn string) (*Store, error) {
+	conn, err := sql.Open(d.driverName(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Store{db: conn, dialect: d}, nil
+}
+
+// prepared returns the cached prepared statement of a rebound query, or nil if the
+// store doesn't cache it
+func (s *Store) prepared(query string) *sql.Stmt {
+	if s.stmts == nil {
+		return nil
+	}
+	return s.stmts.get(s.db, query)
+}
+
+// Exec executes a statement without returning rows
+func (s *Store) Exec(query string, args ...interface{}) (sql.Result, error) {
+	query = s.dialect.rebind(query)
+	if stmt := s.prepared(query); stmt != nil {
+		return stmt.Exec(s.dialect.args(args)...)
+	}
+	return s.db.Exec(query, s.dialect.args(args)...)
+}
+
+// Query executes a statement returning rows
+func (s *Store) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a statement returning rows until ctx is done
+func (s *Store) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query = s.dialect.rebind(query)
+	if stmt := s.prepared(query); stmt != nil {
+		return stmt.QueryContext(ctx, s.dialect.args(args)...)
+	}
+	return s.db.QueryContext(ctx, query, s.dialect.args(args)...)
+}
+
+// QueryRow executes a statement returning at most one row
+func (s *Store) QueryRow(query string, args ...interface{}) *sql.Row {
+	query = s.dialect.rebind(query)
+	if stmt := s.prepared(query); stmt != nil {
+		return stmt.QueryRow(s.dialect.args(args)...)
+	}
+	return s.db.QueryRow(query, s.dialect.args(args)...)
+}
+
+// Begin starts a transaction
+func (s *Store) Begin() (*Tx, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, dialect: s.dialect}, nil
+}
+
+// Close closes the connection
+func (s *Store) Close() error {
+	if s.stmts != nil {
+		s.stmts.close()
+	}
+	return s.db.Close()
+}
+
+// Tx is a transaction of a Store
+type Tx struct {
+	tx      *sql.Tx
+	dialect dialect
+}
+
+// Exec executes a statement without returning rows
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.rebind(query), t.dialect.args(args)...)
+}
+
+// Query executes a statement returning rows
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.Query(t.dialect.rebind(query), t.dialect.args(args)...)
+}
+
+// QueryRow executes a statement returning at most one row
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRow(t.dialect.rebind(query), t.dialect.args(args)...)
+}
+
+// Prepare creates a prepared statement for use within the transaction
+func (t *Tx) Prepare(query string) (*Stmt, error) {
+	stmt, err := t.tx.Prepare(t.dialect.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{stmt: stmt, dialect: t.dialect}, nil
+}
+
+// Commit commits the transaction
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback aborts the transaction
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// Stmt is a prepared statement of a transaction
+type Stmt struct {
+	stmt    *sql.Stmt
+	dialect dialect
+}
+
+// Exec executes the statement with the given arguments
+func (s *Stmt) Exec(args ...interface{}) (sql.Result, error) {
+	return s.stmt.Exec(s.dialect.args(args)...)
+}
+
+// Close closes the statement
+func (s *Stmt) Close() error {
+	return s.stmt.Close()
+}
@@ -0,0 +1,128 @@
+package db
+
+import "fmt"
+
+// DefaultTenantID owns the rows stored before tenants existed and those stored without
+// authentication. It matches auth.DefaultTenant.
+const DefaultTenantID = "default"
+
+// AllTenants is passed as the tenant of system work, such as the scheduler, that reads
+// the data of every tenant. Requests always act for a single tenant.
+const AllTenants = ""
+
+// tenantTables are the tables whose rows belong to a tenant, apart from analysis_results,
+// which AddTableForAnalysis creates
+var tenantTables = []string{"workflows", "scratch_sessions", "conversations", "attribute_sets", "api_keys", "analysis_jobs", "runs", "conversation_uploads", "activity", "webhook_subscriptions", "llm_audit", "audit_requests", "lineage_edges", "pipelines", "prompt_templates", "active_prompt_templates", "canaries", "usage_records", "usage_calls", "chain_runs", "workflow_operations"}
+
+// tenantKeyedTables are the tenant tables whose keys include the tenant, so that names
+// and versions are only unique within a tenant, with the columns they had before tenants
+// existed. Their create functions define the tenant_id column.
+var tenantKeyedTables = []struct {
+	table   string
+	columns string
+	create  func() error
+}{
+	{"pipelines", "id, name, description, steps, confidence_propagation, created_at, updated_at", createPipelinesTable},
+	{"prompt_templates", "id, analysis_type, version, template, description, created_at", createPromptTemplatesTables},
+	{"active_prompt_templates", "analysis_type, template_id, updated_at", createPromptTemplatesTables},
+	{"embeddings", "kind, item_id, model, vector, text, updated_at", createEmbeddingsTable},
+	{"workspace_defaults", "defaults, updated_by, updated_at", createWorkspaceDefaultsTable},
+	{"pseudonyms", "token, kind, original_id, created_at", createPseudonymsTable},
+}
+
+// addTenantColumns adds the tenant_id column to the tables holding tenant data. Existing
+// rows belong to the default tenant.
+func addTenantColumns() error {
+	for _, keyed := range tenantKeyedTables {
+		if err := rebuildWithTenantKey(keyed.table, keyed.columns, keyed.create); err != nil {
+			return fmt.Errorf("failed to add tenants to %s: %w", keyed.table, err)
+		}
+	}
+	for _, table := range tenantTables {
+		if err := addTenantColumn(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildWithTenantKey recreates a table created before tenants existed, whose keys a
+// column cannot be added to, and copies its rows to the default tenant
+func rebuildWithTenantKey(table, columns string, create func() error) error {
+	var count int
+	if err := DB.QueryRow(DB.dialect.columnExistsQuery(), table, "tenant_id").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	previous := table + "_before_tenants"
+	statements := []func() error{
+		func() error { _, err := DB.Exec("ALTER TABLE " + table + " RENAME TO " + previous); return err },
+		create,
+		func() error {
+			_, err := DB.Exec("INSERT INTO " + table + " (" + columns + ") SELECT " + columns + " FROM " + previous)
+			return err
+		},
+		func() error { _, err := DB.Exec("DROP TABLE " + previous); return err },
+	}
+	for _, statement := range statements {
+		if err := statement(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTenantColumn adds the tenant_id column and its index to a table
+func addTenantColumn(table string) error {
+	if err := addColumnIfMissing(table, "tenant_id", "TEXT NOT NULL DEFAULT '"+DefaultTenantID+"'"); err != nil {
+		return err
+	}
+	_, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_" + table + "_tenant_id ON " + table + " (tenant_id)")
+	return err
+}
+
+// workflowTenantCondition returns the condition restricting a query to the rows whose
+// workflow, named by the workflow ID in column, belongs to a tenant, starting with AND,
+// and its argument. AllTenants adds no condition.
+func workflowTenantCondition(column, tenantID string) (string, []interface{}) {
+	if tenantID == AllTenants {
+		return "", nil
+	}
+	return " AND " + column + " IN (SELECT id FROM workflows WHERE tenant_id = ?)", []interface{}{tenantID}
+}
+
+// conversationTenantCondition returns the condition restricting a query to the rows whose
+// stored conversation, named by the conversation ID in column, belongs to a tenant,
+// starting with AND, and its argument. AllTenants adds no condition.
+func conversationTenantCondition(column, tenantID string) (string, []interface{}) {
+	if tenantID == AllTenants {
+		return "", nil
+	}
+	return " AND " + column + " IN (SELECT conversation_id FROM conversations WHERE tenant_id = ?)", []interface{}{tenantID}
+}
+
+// conversationRecordCondition returns the condition restricting a query to the records
+// derived from conversations that belong to a tenant, starting with AND, and its
+// arguments: those recorded by the tenant's workflows, named by the workflow ID in
+// workflowColumn, or of the tenant's stored conversations, named by the conversation ID in
+// conversationColumn. AllTenants adds no condition.
+func conversationRecordCondition(workflowColumn, conversationColumn, tenantID string) (string, []interface{}) {
+	if tenantID == AllTenants {
+		return "", nil
+	}
+	return ` AND (` + workflowColumn + ` IN (SELECT id FROM workflows WHERE tenant_id = ?)
+		OR ` + conversationColumn + ` IN (SELECT conversation_id FROM conversations WHERE tenant_id = ?))`,
+		[]interface{}{tenantID, tenantID}
+}
+
+// tenantCondition returns the condition restricting a query to the rows of a tenant in
+// column, starting with AND, and its argument. AllTenants adds no condition.
+func tenantCondition(column, tenantID string) (string, []interface{}) {
+	if tenantID == AllTenants {
+		return "", nil
+	}
+	return " AND " + column + " = ?", []interface{}{tenantID}
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Component kinds a manifest can register
+const (
+	ManifestKindTool  = "tool"
+	ManifestKindAgent = "agent"
+)
+
+// ToolManifest describes a third-party tool or agent that can be installed as a workflow component
+type ToolManifest struct {
+	Name        string                 `json:"name"` // Namespaced, e.g. "acme/sentiment"
+	Version     string                 `json:"version"`
+	Kind        string                 `json:"kind,omitempty"` // "tool" (default) or "agent"
+	Label       string                 `json:"label,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Inputs      map[string]interface{} `json:"inputs"`  // JSON Schema of the request body
+	Outputs     map[string]interface{} `json:"outputs"` // JSON Schema of the response body
+	Endpoint    ManifestEndpoint       `json:"endpoint"`
+	Auth        ManifestAuth           `json:"auth"`
+}
+
+// ManifestEndpoint is where an installed component is invoked
+type ManifestEndpoint struct {
+	URL            string `json:"url"`
+	Method         string `json:"method,omitempty"` // Defaults to POST
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// ManifestAuth declares how to authenticate to the endpoint. Credentials are never part
+// of a manifest; SecretEnv names the server environment variable that holds them.
+type ManifestAuth struct {
+	Type      string `json:"type"` // "none", "bearer", "api_key" or "basic"
+	Header    string `json:"header,omitempty"`
+	SecretEnv string `json:"secret_env,omitempty"`
+}
+
+// InstalledManifest is a manifest registered in the component catalog
+type InstalledManifest struct {
+	ToolManifest
+	InstalledAt time.Time `json:"installed_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// createToolManifestsTable creates the installed manifest table if it doesn't exist
+func createToolManifestsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS tool_manifests (
+			name TEXT PRIMARY KEY,
+			version TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			manifest TEXT NOT NULL,
+			installed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// InstallToolManifest stores a manifest and registers its component as a tool or agent.
+// Installing a name that already exists replaces it. It reports whether the name was new.
+func InstallToolManifest(manifest ToolManifest) (bool, error) {
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	created := false
+	err = withTx(func(tx *Tx) error {
+		var existing string
+		err := tx.QueryRow("SELECT name FROM tool_manifests WHERE name = ?", manifest.Name).Scan(&existing)
+		switch {
+		case err == sql.ErrNoRows:
+			created = true
+		case err != nil:
+			return err
+		}
+
+		now := time.Now()
+		if created {
+			_, err = tx.Exec(
+				"INSERT INTO tool_manifests (name, version, kind, manifest, installed_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+				manifest.Name, manifest.Version, manifest.Kind, string(manifestJSON), now, now,
+			)
+		} else {
+			_, err = tx.Exec(
+				"UPDATE tool_manifests SET version = ?, kind = ?, manifest = ?, updated_at = ? WHERE name = ?",
+				manifest.Version, manifest.Kind, string(manifestJSON), now, manifest.Name,
+			)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Re-register the component, which may have changed kind between versions
+		if err := unregisterManifestComponent(tx, manifest.Name); err != nil {
+			return err
+		}
+		table := "tools"
+		if manifest.Kind == ManifestKindAgent {
+			table = "agents"
+		}
+		_, err = tx.Exec(
+			"INSERT INTO "+table+" (id, type, label) VALUES (?, ?, ?)",
+			manifest.Name, manifest.Kind, manifest.Label,
+		)
+		return err
+	})
+	return created, err
+}
+
+// UninstallToolManifest removes a manifest and its registered component
+func UninstallToolManifest(name string) error {
+	return withTx(func(tx *Tx) error {
+		result, err := tx.Exec("DELETE FROM tool_manifests WHERE name = ?", name)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("tool manifest not found")
+		}
+		return unregisterManifestComponent(tx, name)
+	})
+}
+
+// unregisterManifestComponent removes the tool or agent registered for a manifest
+func unregisterManifestComponent(tx *Tx, name string) error {
+	if _, err := tx.Exec("DELETE FROM tools WHERE id = ?", name); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM agents WHERE id = ?", name)
+	return err
+}
+
+// GetToolManifest retrieves an installed manifest by name
+func GetToolManifest(name string) (*InstalledManifest, error) {
+	manifest, err := scanToolManifest(DB.QueryRow("SELECT manifest, installed_at, updated_at FROM tool_manifests WHERE name = ?", name))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("tool manifest not found")
+	}
+	return manifest, err
+}
+
+// ListToolManifests returns all installed manifests ordered by name
+func ListToolManifests() ([]InstalledManifest, error) {
+	rows, err := DB.Query("SELECT manifest, installed_at, updated_at FROM tool_manifests ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	manifests := []InstalledManifest{}
+	for rows.Next() {
+		manifest, err := scanToolManifest(rows)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, *manifest)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return manifests, nil
+}
+
+// scanToolManifest reads an installed manifest row
+func scanToolManifest(row rowScanner) (*InstalledManifest, error) {
+	var manifest InstalledManifest
+	var manifestJSON string
+	if err := row.Scan(&manifestJSON, &manifest.InstalledAt, &manifest.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(manifestJSON), &manifest.ToolManifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	return &manifest, nil
+}
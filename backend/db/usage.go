@@ -0,0 +1,410 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of requests whose LLM usage is recorded
+const (
+	UsageKindAnalysis           = "analysis"
+	UsageKindBatch              = "batch"
+	UsageKindChain              = "chain"
+	UsageKindWorkflowExecution  = "workflow_execution"
+	UsageKindWorkflowGeneration = "workflow_generation"
+)
+
+// usageDimensions are the record fields usage can be grouped by besides cost tags
+var usageDimensions = map[string]func(UsageRecord) string{
+	"kind":          func(r UsageRecord) string { return r.Kind },
+	"analysis_type": func(r UsageRecord) string { return r.AnalysisType },
+	"workflow_id":   func(r UsageRecord) string { return r.WorkflowID },
+	"actor":         func(r UsageRecord) string { return r.Actor },
+	"day":           func(r UsageRecord) string { return r.CreatedAt.UTC().Format("2006-01-02") },
+	"month":         func(r UsageRecord) string { return r.CreatedAt.UTC().Format("2006-01") },
+}
+
+// UsageRecord is the LLM usage of one request and the cost tags it was made with
+type UsageRecord struct {
+	ID               string `json:"id"`
+	TenantID         string `json:"-"` // The tenant that made the request; defaults to DefaultTenantID
+	Kind             string `json:"kind"`
+	AnalysisType     string `json:"analysis_type,omitempty"`
+	WorkflowID       string `json:"workflow_id,omitempty"`
+	Actor            string `json:"actor,omitempty"`
+	Calls            int    `json:"calls"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	// CachedPromptTokens are the prompt tokens served from the provider's prompt cache
+	CachedPromptTokens int               `json:"cached_prompt_tokens,omitempty"`
+	Estimated          bool              `json:"estimated,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	CreatedAt          time.Time         `json:"created_at"`
+	// LLMCalls are the individual calls of the request, stored for cost accounting
+	LLMCalls []LLMCall `json:"llm_calls,omitempty"`
+}
+
+// LLMCall is the usage of one LLM call and its estimated cost
+type LLMCall struct {
+	Model              string  `json:"model,omitempty"`
+	PromptTokens       int     `json:"prompt_tokens"`
+	CompletionTokens   int     `json:"completion_tokens"`
+	CachedPromptTokens int     `json:"cached_prompt_tokens,omitempty"`
+	Estimated          bool    `json:"estimated,omitempty"`
+	Cost               float64 `json:"cost"` // USD, at the token prices configured when the call was made
+}
+
+// CostTotal is the usage and cost of a set of LLM calls
+type CostTotal struct {
+	Key                string  `json:"key,omitempty"`
+	Requests           int     `json:"requests"`
+	Calls              int     `json:"calls"`
+	PromptTokens       int     `json:"prompt_tokens"`
+	CompletionTokens   int     `json:"completion_tokens"`
+	CachedPromptTokens int     `json:"cached_prompt_tokens"`
+	TotalTokens        int     `json:"total_tokens"`
+	Cost               float64 `json:"cost"`
+	Estimated          bool    `json:"estimated,omitempty"`
+}
+
+// WorkflowCosts is the cost of the LLM calls made for a workflow, in total and broken
+// down by request kind, analysis type and model
+type WorkflowCosts struct {
+	WorkflowID     string      `json:"workflow_id"`
+	Total          CostTotal   `json:"total"`
+	ByKind         []CostTotal `json:"by_kind"`
+	ByAnalysisType []CostTotal `json:"by_analysis_type"`
+	ByModel        []CostTotal `json:"by_model"`
+	FirstCallAt    *time.Time  `json:"first_call_at,omitempty"`
+	LastCallAt     *time.Time  `json:"last_call_at,omitempty"`
+}
+
+// UsageFilter selects the usage records aggregated by GetUsageSummary
+type UsageFilter struct {
+	TenantID     string // The tenant whose usage is totaled; AllTenants totals that of every tenant
+	Since        time.Time
+	Until        time.Time
+	Kind         string
+	AnalysisType string
+	WorkflowID   string
+	Tags         map[string]string // Records must carry all of these tags
+	GroupBy      []string          // Record dimensions or cost tag keys
+}
+
+// UsageGroup is the total usage of the records sharing the same group values
+type UsageGroup struct {
+	Group              map[string]string `json:"group"`
+	Requests           int               `json:"requests"`
+	Calls              int               `json:"calls"`
+	PromptTokens       int               `json:"prompt_tokens"`
+	CompletionTokens   int               `json:"completion_tokens"`
+	CachedPromptTokens int               `json:"cached_prompt_tokens"`
+	TotalTokens        int               `json:"total_tokens"`
+	Estimated          bool              `json:"estimated,omitempty"`
+}
+
+// createUsageTable creates the usage table if it doesn't exist
+func createUsageTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS usage_records (
+			id TEXT PRIMARY KEY,
+			kind TEXT NOT NULL,
+			analysis_type TEXT,
+			workflow_id TEXT,
+			actor TEXT,
+			calls INTEGER NOT NULL DEFAULT 0,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			cached_prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			estimated INTEGER NOT NULL DEFAULT 0,
+			tags TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing("usage_records", "cached_prompt_tokens", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_usage_records_created_at ON usage_records (created_at)")
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS usage_calls (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			usage_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			analysis_type TEXT,
+			workflow_id TEXT,
+			model TEXT,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			cached_prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			estimated INTEGER NOT NULL DEFAULT 0,
+			cost REAL NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_usage_calls_workflow_id ON usage_calls (workflow_id)")
+	return err
+}
+
+// RecordUsage stores the LLM usage of a request and its individual calls
+func RecordUsage(record UsageRecord) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	if record.TenantID == "" {
+		record.TenantID = DefaultTenantID
+	}
+
+	var tags interface{}
+	if len(record.Tags) > 0 {
+		encoded, err := json.Marshal(record.Tags)
+		if err != nil {
+			return err
+		}
+		tags = string(encoded)
+	}
+
+	return withTx(func(tx *Tx) error {
+		_, err := tx.Exec(
+			`INSERT INTO usage_records
+			(id, kind, analysis_type, workflow_id, actor, calls, prompt_tokens, completion_tokens, cached_prompt_tokens, estimated, tags, created_at, tenant_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			record.ID, record.Kind, record.AnalysisType, record.WorkflowID, record.Actor,
+			record.Calls, record.PromptTokens, record.CompletionTokens, record.CachedPromptTokens, record.Estimated, tags, record.CreatedAt,
+			record.TenantID,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, call := range record.LLMCalls {
+			_, err := tx.Exec(
+				`INSERT INTO usage_calls
+				(usage_id, kind, analysis_type, workflow_id, model, prompt_tokens, completion_tokens, cached_prompt_tokens, estimated, cost, created_at, tenant_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				record.ID, record.Kind, nullString(record.AnalysisType), nullString(record.WorkflowID), nullString(call.Model),
+				call.PromptTokens, call.CompletionTokens, call.CachedPromptTokens, call.Estimated, call.Cost, record.CreatedAt, record.TenantID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetWorkflowCosts totals the cost of the LLM calls a tenant made for a workflow.
+// Breakdowns are ordered by cost, largest first.
+func GetWorkflowCosts(tenantID, workflowID string) (*WorkflowCosts, error) {
+	costs := &WorkflowCosts{WorkflowID: workflowID}
+	condition, tenantArgs := tenantCondition("tenant_id", tenantID)
+	args := append([]interface{}{workflowID}, tenantArgs...)
+
+	totals, err := workflowCostTotals(condition, args, "''")
+	if err != nil {
+		return nil, err
+	}
+	if len(totals) > 0 {
+		costs.Total = totals[0]
+	}
+	for _, breakdown := range []struct {
+		column string
+		dest   *[]CostTotal
+	}{
+		{"kind", &costs.ByKind},
+		{"analysis_type", &costs.ByAnalysisType},
+		{"model", &costs.ByModel},
+	} {
+		if *breakdown.dest, err = workflowCostTotals(condition, args, breakdown.column); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, bound := range []struct {
+		order string
+		dest  **time.Time
+	}{{"ASC", &costs.FirstCallAt}, {"DESC", &costs.LastCallAt}} {
+		var at time.Time
+		err := Replica.QueryRow("SELECT created_at FROM usage_calls WHERE workflow_id = ?"+condition+" ORDER BY created_at "+bound.order+" LIMIT 1",
+			args...).Scan(&at)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		*bound.dest = &at
+	}
+	return costs, nil
+}
+
+// workflowCostTotals totals the LLM calls of a workflow per value of a usage_calls
+// column. args are the workflow ID followed by those of the tenant condition.
+func workflowCostTotals(condition string, args []interface{}, column string) ([]CostTotal, error) {
+	rows, err := Replica.Query(`
+		SELECT COALESCE(`+column+`, ''), COUNT(DISTINCT usage_id), COUNT(*), SUM(prompt_tokens), SUM(completion_tokens),
+			SUM(cached_prompt_tokens), SUM(cost), MAX(estimated)
+		FROM usage_calls WHERE workflow_id = ?`+condition+`
+		GROUP BY 1 ORDER BY SUM(cost) DESC, COUNT(*) DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []CostTotal{}
+	for rows.Next() {
+		var total CostTotal
+		if err := rows.Scan(&total.Key, &total.Requests, &total.Calls, &total.PromptTokens, &total.CompletionTokens,
+			&total.CachedPromptTokens, &total.Cost, &total.Estimated); err != nil {
+			return nil, err
+		}
+		total.TotalTokens = total.PromptTokens + total.CompletionTokens
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+// GetUsageSummary totals the usage records matching the filter per group, largest first.
+// Records without a tag used for grouping are totaled under an empty value.
+func GetUsageSummary(filter UsageFilter) ([]UsageGroup, error) {
+	query := `SELECT id, kind, analysis_type, workflow_id, actor, calls, prompt_tokens, completion_tokens, cached_prompt_tokens, estimated, tags, created_at
+		FROM usage_records`
+	conditions := []string{}
+	args := []interface{}{}
+
+	if filter.TenantID != AllTenants {
+		conditions = append(conditions, "tenant_id = ?")
+		args = append(args, filter.TenantID)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until)
+	}
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, filter.Kind)
+	}
+	if filter.AnalysisType != "" {
+		conditions = append(conditions, "analysis_type = ?")
+		args = append(args, filter.AnalysisType)
+	}
+	if filter.WorkflowID != "" {
+		conditions = append(conditions, "workflow_id = ?")
+		args = append(args, filter.WorkflowID)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := Replica.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := map[string]*UsageGroup{}
+	for rows.Next() {
+		var record UsageRecord
+		var analysisType, workflowID, actor, tags *string
+		if err := rows.Scan(
+			&record.ID, &record.Kind, &analysisType, &workflowID, &actor, &record.Calls,
+			&record.PromptTokens, &record.CompletionTokens, &record.CachedPromptTokens, &record.Estimated, &tags, &record.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if analysisType != nil {
+			record.AnalysisType = *analysisType
+		}
+		if workflowID != nil {
+			record.WorkflowID = *workflowID
+		}
+		if actor != nil {
+			record.Actor = *actor
+		}
+		if tags != nil && *tags != "" {
+			if err := json.Unmarshal([]byte(*tags), &record.Tags); err != nil {
+				return nil, err
+			}
+		}
+
+		if !hasTags(record.Tags, filter.Tags) {
+			continue
+		}
+
+		group := make(map[string]string, len(filter.GroupBy))
+		keyParts := make([]string, len(filter.GroupBy))
+		for i, dimension := range filter.GroupBy {
+			value := record.Tags[dimension]
+			if field, ok := usageDimensions[dimension]; ok {
+				value = field(record)
+			}
+			group[dimension] = value
+			keyParts[i] = value
+		}
+		key := strings.Join(keyParts, "\x00")
+
+		total, ok := groups[key]
+		if !ok {
+			total = &UsageGroup{Group: group}
+			groups[key] = total
+		}
+		total.Requests++
+		total.Calls += record.Calls
+		total.PromptTokens += record.PromptTokens
+		total.CompletionTokens += record.CompletionTokens
+		total.CachedPromptTokens += record.CachedPromptTokens
+		total.TotalTokens += record.PromptTokens + record.CompletionTokens
+		total.Estimated = total.Estimated || record.Estimated
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summary := make([]UsageGroup, 0, len(groups))
+	for _, group := range groups {
+		summary = append(summary, *group)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].TotalTokens != summary[j].TotalTokens {
+			return summary[i].TotalTokens > summary[j].TotalTokens
+		}
+		return summary[i].Requests > summary[j].Requests
+	})
+	return summary, nil
+}
+
+// IsUsageDimension reports whether usage can be grouped by a record field of this name
+func IsUsageDimension(name string) bool {
+	_, ok := usageDimensions[name]
+	return ok
+}
+
+// hasTags reports whether tags contains every tag in want
+func hasTags(tags, want map[string]string) bool {
+	for key, value := range want {
+		if tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
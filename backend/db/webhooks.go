@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// WebhookSubscription sends completed analysis results that pass its filters to a URL
+type WebhookSubscription struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Secret        string   `json:"-"`
+	Signed        bool     `json:"signed"`
+	AnalysisTypes []string `json:"analysis_types"`
+	WorkflowID    string   `json:"workflow_id,omitempty"`
+	// Conditions are webhooks.Condition values, kept encoded here
+	Conditions     json.RawMessage `json:"conditions"`
+	Match          string          `json:"match"`
+	Enabled        bool            `json:"enabled"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	Deliveries     int             `json:"deliveries"`
+	Failures       int             `json:"failures"`
+	LastDeliveryAt *time.Time      `json:"last_delivery_at,omitempty"`
+	LastStatus     int             `json:"last_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+}
+
+// webhookColumns are the columns read into a WebhookSubscription, in scan order
+const webhookColumns = `id, name, url, secret, analysis_types, workflow_id, conditions, match_mode, enabled, created_at, updated_at,
+	deliveries, failures, last_delivery_at, last_status, last_error`
+
+// createWebhooksTable creates the webhook_subscriptions table if it doesn't exist
+func createWebhooksTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT,
+			analysis_types TEXT,
+			workflow_id TEXT,
+			conditions TEXT,
+			match_mode TEXT NOT NULL DEFAULT 'all',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deliveries INTEGER NOT NULL DEFAULT 0,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_delivery_at TIMESTAMP,
+			last_status INTEGER,
+			last_error TEXT
+		)
+	`)
+	return err
+}
+
+// SaveWebhookSubscription creates a subscription of a tenant or replaces the settings of
+// an existing one, keeping its delivery statistics. An empty secret keeps the stored secret.
+func SaveWebhookSubscription(tenantID string, sub WebhookSubscription) error {
+	analysisTypes, err := json.Marshal(sub.AnalysisTypes)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = now
+	}
+	result, err := DB.Exec(`
+		INSERT INTO webhook_subscriptions (id, name, url, secret, analysis_types, workflow_id, conditions, match_mode, enabled, created_at, updated_at, tenant_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			url = excluded.url,
+			secret = COALESCE(excluded.secret, webhook_subscriptions.secret),
+			analysis_types = excluded.analysis_types,
+			workflow_id = excluded.workflow_id,
+			conditions = excluded.conditions,
+			match_mode = excluded.match_mode,
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at
+		WHERE webhook_subscriptions.tenant_id = excluded.tenant_id`,
+		sub.ID, sub.Name, sub.URL, nullString(sub.Secret), string(analysisTypes), nullString(sub.WorkflowID),
+		string(sub.Conditions), sub.Match, sub.Enabled, sub.CreatedAt, now, tenantID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("webhook subscription not found")
+	}
+	return nil
+}
+
+// GetWebhookSubscription returns a subscription of a tenant by ID
+func GetWebhookSubscription(tenantID, id string) (*WebhookSubscription, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	sub, err := scanWebhookSubscription(DB.QueryRow("SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = ?"+condition,
+		append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook subscription not found")
+	}
+	return sub, err
+}
+
+// ListWebhookSubscriptions returns all subscriptions of a tenant, or only the enabled ones,
+// oldest first
+func ListWebhookSubscriptions(tenantID string, enabledOnly bool) ([]WebhookSubscription, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	query := "SELECT " + webhookColumns + " FROM webhook_subscriptions WHERE 1 = 1" + condition
+	if enabledOnly {
+		query += " AND enabled"
+	}
+	rows, err := DB.Query(query+" ORDER BY created_at", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+// DeleteWebhookSubscription deletes a subscription of a tenant
+func DeleteWebhookSubscription(tenantID, id string) error {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	result, err := DB.Exec("DELETE FROM webhook_subscriptions WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("webhook subscription not found")
+	}
+	return nil
+}
+
+// RecordWebhookDelivery records the outcome of a delivery to a subscription
+func RecordWebhookDelivery(id string, status int, deliveryErr error) error {
+	errText, failed := "", 0
+	if deliveryErr != nil {
+		errText, failed = deliveryErr.Error(), 1
+	}
+	_, err := DB.Exec(
+		`UPDATE webhook_subscriptions SET deliveries = deliveries + 1, failures = failures + ?,
+		last_delivery_at = ?, last_status = ?, last_error = ? WHERE id = ?`,
+		failed, time.Now(), status, nullString(errText), id,
+	)
+	return err
+}
+
+// scanWebhookSubscription reads a WebhookSubscription from a row
+func scanWebhookSubscription(row rowScanner) (*WebhookSubscription, error) {
+	var sub WebhookSubscription
+	var secret, analysisTypes, workflowID, conditions, lastError sql.NullString
+	var lastDeliveryAt sql.NullTime
+	var lastStatus sql.NullInt64
+	err := row.Scan(&sub.ID, &sub.Name, &sub.URL, &secret, &analysisTypes, &workflowID, &conditions, &sub.Match,
+		&sub.Enabled, &sub.CreatedAt, &sub.UpdatedAt, &sub.Deliveries, &sub.Failures, &lastDeliveryAt, &lastStatus, &lastError)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = secret.String
+	sub.Signed = secret.String != ""
+	sub.WorkflowID = workflowID.String
+	sub.LastError = lastError.String
+	sub.LastStatus = int(lastStatus.Int64)
+	if lastDeliveryAt.Valid {
+		sub.LastDeliveryAt = &lastDeliveryAt.Time
+	}
+	if analysisTypes.String != "" {
+		if err := json.Unmarshal([]byte(analysisTypes.String), &sub.AnalysisTypes); err != nil {
+			return nil, err
+		}
+	}
+	if sub.AnalysisTypes == nil {
+		sub.AnalysisTypes = []string{}
+	}
+	sub.Conditions = json.RawMessage("[]")
+	if conditions.String != "" {
+		sub.Conditions = json.RawMessage(conditions.String)
+	}
+	return &sub, nil
+}
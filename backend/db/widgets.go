@@ -0,0 +1,146 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Periods a widget's result must fall in
+const (
+	WidgetPeriodLatest = "latest" // The most recent result, however old
+	WidgetPeriodDay    = "day"
+	WidgetPeriodWeek   = "week"
+	WidgetPeriodMonth  = "month"
+)
+
+// Widget shares the data of one chart, a field of the latest stored result of an
+// analysis type, with anyone holding its token. Only the hash of the token is stored.
+type Widget struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	WorkflowID   string    `json:"workflow_id"`
+	AnalysisType string    `json:"analysis_type"`
+	Field        string    `json:"field,omitempty"` // Dotted path into the results, e.g. "distribution"
+	Period       string    `json:"period"`
+	TokenPrefix  string    `json:"token_prefix"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// widgetColumns are the columns read into a Widget, in scan order
+const widgetColumns = "id, name, workflow_id, analysis_type, field, period, token_prefix, created_by, created_at"
+
+// createWidgetsTable creates the widgets table if it doesn't exist
+func createWidgetsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS widgets (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			workflow_id TEXT NOT NULL,
+			analysis_type TEXT NOT NULL,
+			field TEXT,
+			period TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			token_prefix TEXT NOT NULL,
+			created_by TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// CreateWidget stores a new widget under the hash of its token
+func CreateWidget(widget Widget, tokenHash string) error {
+	_, err := DB.Exec(
+		"INSERT INTO widgets (id, name, workflow_id, analysis_type, field, period, token_hash, token_prefix, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		widget.ID, widget.Name, widget.WorkflowID, widget.AnalysisType, nullString(widget.Field), widget.Period,
+		tokenHash, widget.TokenPrefix, nullString(widget.CreatedBy), widget.CreatedAt,
+	)
+	return err
+}
+
+// GetWidget returns a widget of a workflow of a tenant by ID
+func GetWidget(tenantID, id string) (*Widget, error) {
+	condition, args := workflowTenantCondition("workflow_id", tenantID)
+	widget, err := scanWidget(DB.QueryRow("SELECT "+widgetColumns+" FROM widgets WHERE id = ?"+condition, append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("widget not found")
+	}
+	return widget, err
+}
+
+// GetWidgetByToken returns a widget by ID if tokenHash is the hash of its token
+func GetWidgetByToken(id, tokenHash string) (*Widget, error) {
+	widget, err := scanWidget(DB.QueryRow("SELECT "+widgetColumns+" FROM widgets WHERE id = ? AND token_hash = ?", id, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("widget not found")
+	}
+	return widget, err
+}
+
+// ListWidgets returns all widgets of the workflows of a tenant, of one workflow if set,
+// most recent first
+func ListWidgets(tenantID, workflowID string) ([]Widget, error) {
+	condition, args := workflowTenantCondition("workflow_id", tenantID)
+	query := "SELECT " + widgetColumns + " FROM widgets WHERE 1 = 1" + condition
+	if workflowID != "" {
+		query += " AND workflow_id = ?"
+		args = append(args, workflowID)
+	}
+	rows, err := DB.Query(query+" ORDER BY created_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	widgets := []Widget{}
+	for rows.Next() {
+		widget, err := scanWidget(rows)
+		if err != nil {
+			return nil, err
+		}
+		widgets = append(widgets, *widget)
+	}
+	return widgets, rows.Err()
+}
+
+// RotateWidgetToken replaces the token of a widget of a tenant, so the old one stops working
+func RotateWidgetToken(tenantID, id, tokenHash, tokenPrefix string) error {
+	condition, args := workflowTenantCondition("workflow_id", tenantID)
+	result, err := DB.Exec("UPDATE widgets SET token_hash = ?, token_prefix = ? WHERE id = ?"+condition, append([]interface{}{tokenHash, tokenPrefix, id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("widget not found")
+	}
+	return nil
+}
+
+// DeleteWidget deletes a widget of a tenant, revoking its token
+func DeleteWidget(tenantID, id string) error {
+	condition, args := workflowTenantCondition("workflow_id", tenantID)
+	result, err := DB.Exec("DELETE FROM widgets WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("widget not found")
+	}
+	return nil
+}
+
+// scanWidget reads a Widget from a row
+func scanWidget(row rowScanner) (*Widget, error) {
+	var widget Widget
+	var field, createdBy sql.NullString
+	err := row.Scan(&widget.ID, &widget.Name, &widget.WorkflowID, &widget.AnalysisType, &field, &widget.Period,
+		&widget.TokenPrefix, &createdBy, &widget.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	widget.Field = field.String
+	widget.CreatedBy = createdBy.String
+	return &widget, nil
+}
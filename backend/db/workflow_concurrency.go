@@ -0,0 +1,100 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Workflow concurrency modes
+const (
+	// ConcurrencyModeLimit rejects runs beyond MaxConcurrentRuns
+	ConcurrencyModeLimit = "limit"
+	// ConcurrencyModeMutex allows one run at a time and skips runs started while the
+	// previous one is still executing
+	ConcurrencyModeMutex = "mutex"
+)
+
+// WorkflowConcurrency controls how many runs of a workflow may execute at once, and how
+// many LLM calls its analyses may make at once. MaxConcurrentRuns of 0 in limit mode
+// allows any number of runs; MaxConcurrentCalls of 0 applies the scheduler's per-workflow
+// limit.
+type WorkflowConcurrency struct {
+	WorkflowID         string     `json:"workflow_id"`
+	Mode               string     `json:"mode"`
+	MaxConcurrentRuns  int        `json:"max_concurrent_runs"`
+	MaxConcurrentCalls int        `json:"max_concurrent_calls"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+}
+
+// createWorkflowConcurrencyTable creates the workflow_concurrency table if it doesn't exist
+func createWorkflowConcurrencyTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS workflow_concurrency (
+			workflow_id TEXT PRIMARY KEY,
+			mode TEXT NOT NULL,
+			max_concurrent_runs INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	return addColumnIfMissing("workflow_concurrency", "max_concurrent_calls", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// GetWorkflowConcurrency returns the concurrency settings of a workflow, which default
+// to unlimited runs
+func GetWorkflowConcurrency(workflowID string) (WorkflowConcurrency, error) {
+	settings := WorkflowConcurrency{WorkflowID: workflowID, Mode: ConcurrencyModeLimit}
+	var updatedAt time.Time
+	err := DB.QueryRow(
+		"SELECT mode, max_concurrent_runs, max_concurrent_calls, updated_at FROM workflow_concurrency WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&settings.Mode, &settings.MaxConcurrentRuns, &settings.MaxConcurrentCalls, &updatedAt)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return WorkflowConcurrency{}, fmt.Errorf("failed to get concurrency of workflow %s: %w", workflowID, err)
+	}
+	settings.UpdatedAt = &updatedAt
+	return settings, nil
+}
+
+// SaveWorkflowConcurrency stores the concurrency settings of a workflow
+func SaveWorkflowConcurrency(settings WorkflowConcurrency) error {
+	_, err := DB.Exec(
+		`INSERT INTO workflow_concurrency (workflow_id, mode, max_concurrent_runs, max_concurrent_calls, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(workflow_id) DO UPDATE SET mode = excluded.mode, max_concurrent_runs = excluded.max_concurrent_runs,
+			max_concurrent_calls = excluded.max_concurrent_calls, updated_at = excluded.updated_at`,
+		settings.WorkflowID, settings.Mode, settings.MaxConcurrentRuns, settings.MaxConcurrentCalls, time.Now(),
+	)
+	return err
+}
+
+// WorkflowCallLimits returns the LLM call limits of the workflows that have one
+func WorkflowCallLimits() (map[string]int, error) {
+	rows, err := DB.Query("SELECT workflow_id, max_concurrent_calls FROM workflow_concurrency WHERE max_concurrent_calls > 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := map[string]int{}
+	for rows.Next() {
+		var workflowID string
+		var limit int
+		if err := rows.Scan(&workflowID, &limit); err != nil {
+			return nil, err
+		}
+		limits[workflowID] = limit
+	}
+	return limits, rows.Err()
+}
+
+// DeleteWorkflowConcurrency removes the concurrency settings of a workflow
+func DeleteWorkflowConcurrency(workflowID string) error {
+	_, err := DB.Exec("DELETE FROM workflow_concurrency WHERE workflow_id = ?", workflowID)
+	return err
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrVersionConflict is returned when an edit is based on a workflow version that is no longer current
+var ErrVersionConflict = errors.New("workflow has been modified by another edit")
+
+// States of an entry in the workflow operation log
+const (
+	OperationApplied   = "applied"
+	OperationUndone    = "undone"
+	OperationDiscarded = "discarded"
+)
+
+// WorkflowOperation is one entry in a workflow's operation log: a group of
+// edits submitted together, plus the edits that reverse them
+type WorkflowOperation struct {
+	ID         string          `json:"id"`
+	WorkflowID string          `json:"workflow_id"`
+	Seq        int             `json:"seq"`
+	Version    int             `json:"version"`
+	Operations json.RawMessage `json:"operations"`
+	Inverse    json.RawMessage `json:"inverse"`
+	State      string          `json:"state"`
+	Actor      string          `json:"actor"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// createWorkflowOperationsTable creates the workflow_operations table if it doesn't exist
+func createWorkflowOperationsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS workflow_operations (
+			id TEXT PRIMARY KEY,
+			workflow_id TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			operations TEXT NOT NULL,
+			inverse TEXT NOT NULL,
+			state TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (workflow_id) REFERENCES workflows(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_workflow_operations_workflow ON workflow_operations (workflow_id, seq)")
+	return err
+}
+
+// SaveWorkflowIfVersion saves a whole-document update when the workflow is still at baseVersion.
+// Because the stored operations can no longer be reversed reliably, the undo history is discarded.
+func SaveWorkflowIfVersion(workflow Workflow, baseVersion int) error {
+	return withTx(func(tx *Tx) error {
+		if err := updateWorkflowVersion(tx, workflow, baseVersion); err != nil {
+			return err
+		}
+		_, err := tx.Exec(
+			"UPDATE workflow_operations SET state = ? WHERE workflow_id = ? AND state != ?",
+			OperationDiscarded, workflow.ID, OperationDiscarded,
+		)
+		return err
+	})
+}
+
+// RecordWorkflowOperation saves an edited workflow and appends the edit to its operation log.
+// Any undone operations are discarded, since they can no longer be redone.
+func RecordWorkflowOperation(workflow Workflow, baseVersion int, op WorkflowOperation) error {
+	return withTx(func(tx *Tx) error {
+		if err := updateWorkflowVersion(tx, workflow, baseVersion); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(
+			"UPDATE workflow_operations SET state = ? WHERE workflow_id = ? AND state = ?",
+			OperationDiscarded, workflow.ID, OperationUndone,
+		)
+		if err != nil {
+			return err
+		}
+
+		var seq int
+		err = tx.QueryRow(
+			"SELECT COALESCE(MAX(seq), 0) + 1 FROM workflow_operations WHERE workflow_id = ?",
+			workflow.ID,
+		).Scan(&seq)
+		if err != nil {
+			return err
+		}
+
+		tenantID := workflow.TenantID
+		if tenantID == "" {
+			tenantID = DefaultTenantID
+		}
+		_, err = tx.Exec(
+			"INSERT INTO workflow_operations (id, tenant_id, workflow_id, seq, version, operations, inverse, state, actor, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			op.ID,
+			tenantID,
+			workflow.ID,
+			seq,
+			baseVersion+1,
+			string(op.Operations),
+			string(op.Inverse),
+			OperationApplied,
+			op.Actor,
+			time.Now(),
+		)
+		return err
+	})
+}
+
+// SetWorkflowOperationState saves a workflow changed by undoing or redoing an operation
+// and moves that operation to the given state
+func SetWorkflowOperationState(workflow Workflow, baseVersion int, operationID string, state string) error {
+	return withTx(func(tx *Tx) error {
+		if err := updateWorkflowVersion(tx, workflow, baseVersion); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(
+			"UPDATE workflow_operations SET state = ? WHERE id = ?",
+			state, operationID,
+		)
+		return err
+	})
+}
+
+// GetWorkflowOperations returns the operation log of a workflow of a tenant, oldest first
+func GetWorkflowOperations(tenantID, workflowID string) ([]WorkflowOperation, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query(
+		"SELECT id, workflow_id, seq, version, operations, inverse, state, actor, created_at FROM workflow_operations WHERE workflow_id = ?"+condition+" ORDER BY seq",
+		append([]interface{}{workflowID}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	operations := []WorkflowOperation{}
+	for rows.Next() {
+		op, err := scanWorkflowOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, op)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return operations, nil
+}
+
+// GetUndoOperation returns the most recent applied operation of a workflow of a tenant,
+// or nil if there is nothing to undo
+func GetUndoOperation(tenantID, workflowID string) (*WorkflowOperation, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	return getWorkflowOperation(
+		"SELECT id, workflow_id, seq, version, operations, inverse, state, actor, created_at FROM workflow_operations WHERE workflow_id = ? AND state = ?"+condition+" ORDER BY seq DESC LIMIT 1",
+		append([]interface{}{workflowID, OperationApplied}, args...)...,
+	)
+}
+
+// GetRedoOperation returns the most recently undone operation of a workflow of a tenant,
+// or nil if there is nothing to redo. Undo walks backwards through the log, so this is
+// the undone operation with the lowest sequence number.
+func GetRedoOperation(tenantID, workflowID string) (*WorkflowOperation, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	return getWorkflowOperation(
+		"SELECT id, workflow_id, seq, version, operations, inverse, state, actor, created_at FROM workflow_operations WHERE workflow_id = ? AND state = ?"+condition+" ORDER BY seq ASC LIMIT 1",
+		append([]interface{}{workflowID, OperationUndone}, args...)...,
+	)
+}
+
+// getWorkflowOperation runs a query returning at most one operation
+func getWorkflowOperation(query string, args ...interface{}) (*WorkflowOperation, error) {
+	op, err := scanWorkflowOperation(DB.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &op, nil
+}
+
+// scanWorkflowOperation scans a workflow_operations row
+func scanWorkflowOperation(row rowScanner) (WorkflowOperation, error) {
+	var op WorkflowOperation
+	var operationsStr, inverseStr string
+
+	err := row.Scan(
+		&op.ID,
+		&op.WorkflowID,
+		&op.Seq,
+		&op.Version,
+		&operationsStr,
+		&inverseStr,
+		&op.State,
+		&op.Actor,
+		&op.CreatedAt,
+	)
+	if err != nil {
+		return WorkflowOperation{}, err
+	}
+
+	op.Operations = json.RawMessage(operationsStr)
+	op.Inverse = json.RawMessage(inverseStr)
+	return op, nil
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Workflow review statuses
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewEdited   = "edited"
+	ReviewRejected = "rejected"
+)
+
+// WorkflowReview is the intermediate result of a workflow run paused at a review node,
+// awaiting a human decision. Approved and edited reviews resume the run with the item or
+// the edited result; rejected reviews end it.
+type WorkflowReview struct {
+	ID           string          `json:"id"`
+	WorkflowID   string          `json:"workflow_id"`
+	WorkflowName string          `json:"workflow_name"`
+	NodeID       string          `json:"node_id"`
+	Label        string          `json:"label,omitempty"`
+	Instructions string          `json:"instructions,omitempty"` // What reviewers should check, from the review node
+	Item         json.RawMessage `json:"item"`
+	Status       string          `json:"status"`
+	RequestedBy  string          `json:"requested_by,omitempty"` // Who ran the workflow
+	CreatedAt    time.Time       `json:"created_at"`
+	DecidedAt    *time.Time      `json:"decided_at,omitempty"`
+	DecidedBy    string          `json:"decided_by,omitempty"`
+	Comment      string          `json:"comment,omitempty"`
+	// Result is what the run resumed with: the item when approved, the edit when edited
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Nodes and Edges are the graph the run started with, so it resumes the same way
+	// after the workflow is edited, and RunState is what the run needs to resume
+	Nodes    json.RawMessage `json:"-"`
+	Edges    json.RawMessage `json:"-"`
+	RunState json.RawMessage `json:"-"`
+}
+
+// WorkflowReviewFilter selects workflow reviews
+type WorkflowReviewFilter struct {
+	TenantID   string // The tenant whose reviews are returned; AllTenants returns those of every tenant
+	WorkflowID string
+	Status     string // pending, approved, edited, rejected or empty for all
+	Limit      int
+}
+
+// workflowReviewColumns are the columns read into a WorkflowReview, in scan order
+const workflowReviewColumns = "id, workflow_id, workflow_name, node_id, label, instructions, item, status, requested_by, created_at, decided_at, decided_by, comment, result, nodes, edges, run_state"
+
+// createWorkflowReviewsTable creates the workflow_reviews table if it doesn't exist
+func createWorkflowReviewsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS workflow_reviews (
+			id TEXT PRIMARY KEY,
+			workflow_id TEXT NOT NULL,
+			workflow_name TEXT,
+			node_id TEXT NOT NULL,
+			label TEXT,
+			instructions TEXT,
+			item TEXT NOT NULL,
+			status TEXT NOT NULL,
+			requested_by TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			decided_at TIMESTAMP,
+			decided_by TEXT,
+			comment TEXT,
+			result TEXT,
+			nodes TEXT NOT NULL,
+			edges TEXT NOT NULL,
+			run_state TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_workflow_reviews_status ON workflow_reviews (status, created_at)")
+	return err
+}
+
+// CreateWorkflowReview stores a pending review
+func CreateWorkflowReview(review WorkflowReview) error {
+	if review.CreatedAt.IsZero() {
+		review.CreatedAt = time.Now()
+	}
+	_, err := DB.Exec(`
+		INSERT INTO workflow_reviews (id, workflow_id, workflow_name, node_id, label, instructions, item, status, requested_by, created_at, nodes, edges, run_state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		review.ID, review.WorkflowID, nullString(review.WorkflowName), review.NodeID, nullString(review.Label),
+		nullString(review.Instructions), string(review.Item), ReviewPending, nullString(review.RequestedBy), review.CreatedAt,
+		string(review.Nodes), string(review.Edges), string(review.RunState),
+	)
+	return err
+}
+
+// GetWorkflowReview returns a review of a workflow of a tenant with its run state
+func GetWorkflowReview(tenantID, id string) (*WorkflowReview, error) {
+	condition, args := workflowTenantCondition("workflow_id", tenantID)
+	review, err := scanWorkflowReview(DB.QueryRow("SELECT "+workflowReviewColumns+" FROM workflow_reviews WHERE id = ?"+condition, append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("workflow review not found")
+	}
+	return review, err
+}
+
+// ListWorkflowReviews returns the reviews matching a filter, oldest first so pending
+// reviews are worked through in the order runs paused
+func ListWorkflowReviews(filter WorkflowReviewFilter) ([]WorkflowReview, error) {
+	condition, args := workflowTenantCondition("workflow_id", filter.TenantID)
+	query := "SELECT " + workflowReviewColumns + " FROM workflow_reviews WHERE 1 = 1" + condition
+	if filter.WorkflowID != "" {
+		query += " AND workflow_id = ?"
+		args = append(args, filter.WorkflowID)
+	}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	query += " ORDER BY created_at"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []WorkflowReview{}
+	for rows.Next() {
+		review, err := scanWorkflowReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *review)
+	}
+	return reviews, rows.Err()
+}
+
+// DecideWorkflowReview records the decision on a pending review. Only one decision is
+// recorded per review, so concurrent reviewers cannot resume a run twice.
+func DecideWorkflowReview(id, status string, result json.RawMessage, decidedBy, comment string) (*WorkflowReview, error) {
+	if status != ReviewApproved && status != ReviewEdited && status != ReviewRejected {
+		return nil, fmt.Errorf("invalid review decision %s", status)
+	}
+	res, err := DB.Exec(
+		"UPDATE workflow_reviews SET status = ?, result = ?, decided_at = ?, decided_by = ?, comment = ? WHERE id = ? AND status = ?",
+		status, nullString(string(result)), time.Now(), nullString(decidedBy), nullString(comment), id, ReviewPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	review, err := GetWorkflowReview(AllTenants, id)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("workflow review %s is already %s", id, review.Status)
+	}
+	return review, nil
+}
+
+// scanWorkflowReview reads a WorkflowReview from a row
+func scanWorkflowReview(row rowScanner) (*WorkflowReview, error) {
+	var review WorkflowReview
+	var workflowName, label, instructions, requestedBy, decidedBy, comment, result sql.NullString
+	var item, nodes, edges, runState string
+	var decidedAt sql.NullTime
+	err := row.Scan(&review.ID, &review.WorkflowID, &workflowName, &review.NodeID, &label, &instructions, &item,
+		&review.Status, &requestedBy, &review.CreatedAt, &decidedAt, &decidedBy, &comment, &result, &nodes, &edges, &runState)
+	if err != nil {
+		return nil, err
+	}
+	review.WorkflowName = workflowName.String
+	review.Label = label.String
+	review.Instructions = instructions.String
+	review.Item = json.RawMessage(item)
+	review.RequestedBy = requestedBy.String
+	review.DecidedBy = decidedBy.String
+	review.Comment = comment.String
+	if decidedAt.Valid {
+		review.DecidedAt = &decidedAt.Time
+	}
+	if result.String != "" {
+		review.Result = json.RawMessage(result.String)
+	}
+	review.Nodes = json.RawMessage(nodes)
+	review.Edges = json.RawMessage(edges)
+	review.RunState = json.RawMessage(runState)
+	return &review, nil
+}
@@ -0,0 +1,285 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SLA metrics a run can breach
+const (
+	SLAMetricRuntime    = "runtime"
+	SLAMetricCost       = "cost"
+	SLAMetricConfidence = "confidence"
+	SLAMetricFailed     = "failed" // Every failed run breaches the SLA
+)
+
+// WorkflowSLA is the service level a workflow's runs are held to. A zero limit is not checked.
+type WorkflowSLA struct {
+	WorkflowID        string     `json:"workflow_id"`
+	MaxRuntimeSeconds float64    `json:"max_runtime_seconds,omitempty"`
+	MaxCost           float64    `json:"max_cost,omitempty"` // In USD, priced like /api/usage
+	MinConfidence     float64    `json:"min_confidence,omitempty"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
+
+// SLABreach is a limit of an SLA that a run exceeded
+type SLABreach struct {
+	Metric string  `json:"metric"`
+	Limit  float64 `json:"limit,omitempty"`
+	Actual float64 `json:"actual,omitempty"`
+}
+
+// SLARun records how a workflow run measured against the SLA of the workflow
+type SLARun struct {
+	ID             string      `json:"id"`
+	WorkflowID     string      `json:"workflow_id"`
+	Kind           string      `json:"kind"` // The usage kind of the run, e.g. workflow_execution or chain
+	RuntimeSeconds float64     `json:"runtime_seconds"`
+	Cost           float64     `json:"cost"`
+	Confidence     *float64    `json:"confidence,omitempty"` // Unset when the run reports none
+	Failed         bool        `json:"failed,omitempty"`
+	Compliant      bool        `json:"compliant"`
+	Breaches       []SLABreach `json:"breaches"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// SLACompliance summarizes the runs of a workflow against its SLA
+type SLACompliance struct {
+	WorkflowID       string         `json:"workflow_id"`
+	WorkflowName     string         `json:"workflow_name"`
+	SLA              WorkflowSLA    `json:"sla"`
+	Runs             int            `json:"runs"`
+	BreachedRuns     int            `json:"breached_runs"`
+	ComplianceRate   float64        `json:"compliance_rate"` // 1 when there are no runs
+	BreachesByMetric map[string]int `json:"breaches_by_metric"`
+	LastBreachAt     *time.Time     `json:"last_breach_at,omitempty"`
+}
+
+// createWorkflowSLATables creates the workflow_slas and sla_runs tables if they don't exist
+func createWorkflowSLATables() error {
+	if _, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS workflow_slas (
+			workflow_id TEXT PRIMARY KEY,
+			max_runtime_seconds REAL NOT NULL DEFAULT 0,
+			max_cost REAL NOT NULL DEFAULT 0,
+			min_confidence REAL NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS sla_runs (
+			id TEXT PRIMARY KEY,
+			workflow_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			runtime_seconds REAL NOT NULL,
+			cost REAL NOT NULL DEFAULT 0,
+			confidence REAL,
+			failed BOOLEAN NOT NULL DEFAULT FALSE,
+			compliant BOOLEAN NOT NULL,
+			breaches TEXT NOT NULL DEFAULT '[]',
+			created_at TIMESTAMP NOT NULL
+		)
+	`); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_sla_runs_workflow_id ON sla_runs(workflow_id, created_at)")
+	return err
+}
+
+// GetWorkflowSLA returns the SLA of a workflow, or false if it has none
+func GetWorkflowSLA(workflowID string) (WorkflowSLA, bool, error) {
+	sla := WorkflowSLA{WorkflowID: workflowID}
+	var updatedAt time.Time
+	err := DB.QueryRow(
+		"SELECT max_runtime_seconds, max_cost, min_confidence, updated_at FROM workflow_slas WHERE workflow_id = ?",
+		workflowID,
+	).Scan(&sla.MaxRuntimeSeconds, &sla.MaxCost, &sla.MinConfidence, &updatedAt)
+	if err == sql.ErrNoRows {
+		return sla, false, nil
+	}
+	if err != nil {
+		return WorkflowSLA{}, false, fmt.Errorf("failed to get SLA of workflow %s: %w", workflowID, err)
+	}
+	sla.UpdatedAt = &updatedAt
+	return sla, true, nil
+}
+
+// SaveWorkflowSLA stores the SLA of a workflow
+func SaveWorkflowSLA(sla WorkflowSLA) error {
+	_, err := DB.Exec(
+		`INSERT INTO workflow_slas (workflow_id, max_runtime_seconds, max_cost, min_confidence, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(workflow_id) DO UPDATE SET max_runtime_seconds = excluded.max_runtime_seconds, max_cost = excluded.max_cost,
+			min_confidence = excluded.min_confidence, updated_at = excluded.updated_at`,
+		sla.WorkflowID, sla.MaxRuntimeSeconds, sla.MaxCost, sla.MinConfidence, time.Now(),
+	)
+	return err
+}
+
+// DeleteWorkflowSLA removes the SLA of a workflow and the compliance of its runs
+func DeleteWorkflowSLA(workflowID string) error {
+	return withTx(func(tx *Tx) error {
+		if _, err := tx.Exec("DELETE FROM workflow_slas WHERE workflow_id = ?", workflowID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM sla_runs WHERE workflow_id = ?", workflowID)
+		return err
+	})
+}
+
+// RecordSLARun stores the SLA compliance of a run
+func RecordSLARun(run SLARun) error {
+	breaches := run.Breaches
+	if breaches == nil {
+		breaches = []SLABreach{}
+	}
+	encoded, err := json.Marshal(breaches)
+	if err != nil {
+		return fmt.Errorf("failed to encode SLA breaches: %w", err)
+	}
+
+	_, err = DB.Exec(
+		`INSERT INTO sla_runs (id, workflow_id, kind, runtime_seconds, cost, confidence, failed, compliant, breaches, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, run.WorkflowID, run.Kind, run.RuntimeSeconds, run.Cost, run.Confidence, run.Failed, run.Compliant,
+		string(encoded), run.CreatedAt,
+	)
+	return err
+}
+
+// ListSLARuns returns the recorded runs of a workflow since a time, newest first, only
+// those that breached the SLA if breachedOnly is set. A zero since or limit is not applied.
+func ListSLARuns(workflowID string, since time.Time, breachedOnly bool, limit int) ([]SLARun, error) {
+	conditions := []string{"workflow_id = ?"}
+	args := []interface{}{workflowID}
+	if !since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, since)
+	}
+	if breachedOnly {
+		conditions = append(conditions, "NOT compliant")
+	}
+	query := `SELECT id, workflow_id, kind, runtime_seconds, cost, confidence, failed, compliant, breaches, created_at
+		FROM sla_runs WHERE ` + strings.Join(conditions, " AND ") + " ORDER BY created_at DESC"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []SLARun{}
+	for rows.Next() {
+		var run SLARun
+		var confidence sql.NullFloat64
+		var breaches string
+		if err := rows.Scan(&run.ID, &run.WorkflowID, &run.Kind, &run.RuntimeSeconds, &run.Cost, &confidence,
+			&run.Failed, &run.Compliant, &breaches, &run.CreatedAt); err != nil {
+			return nil, err
+		}
+		if confidence.Valid {
+			run.Confidence = &confidence.Float64
+		}
+		if err := json.Unmarshal([]byte(breaches), &run.Breaches); err != nil {
+			return nil, fmt.Errorf("failed to decode SLA breaches of run %s: %w", run.ID, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// ListSLACompliance summarizes the runs since a time of every workflow of a tenant with
+// an SLA, least compliant first. A zero since covers every run.
+func ListSLACompliance(tenantID string, since time.Time) ([]SLACompliance, error) {
+	condition, args := tenantCondition("w.tenant_id", tenantID)
+	rows, err := DB.Query(`
+		SELECT s.workflow_id, COALESCE(w.name, ''), s.max_runtime_seconds, s.max_cost, s.min_confidence, s.updated_at
+		FROM workflow_slas s LEFT JOIN workflows w ON w.id = s.workflow_id
+		WHERE 1 = 1`+condition+`
+		ORDER BY s.workflow_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var compliance []SLACompliance
+	for rows.Next() {
+		var c SLACompliance
+		var updatedAt time.Time
+		if err := rows.Scan(&c.WorkflowID, &c.WorkflowName, &c.SLA.MaxRuntimeSeconds, &c.SLA.MaxCost,
+			&c.SLA.MinConfidence, &updatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		c.SLA.WorkflowID = c.WorkflowID
+		c.SLA.UpdatedAt = &updatedAt
+		compliance = append(compliance, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]SLACompliance, 0, len(compliance))
+	for _, c := range compliance {
+		runs, err := ListSLARuns(c.WorkflowID, since, false, 0)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, summarizeSLARuns(c, runs))
+	}
+
+	// Least compliant first, keeping workflows with equal rates ordered by ID
+	sort.SliceStable(result, func(i, j int) bool { return result[i].ComplianceRate < result[j].ComplianceRate })
+	return result, nil
+}
+
+// summarizeSLARuns counts the runs and breaches of a workflow
+func summarizeSLARuns(c SLACompliance, runs []SLARun) SLACompliance {
+	c.Runs = len(runs)
+	c.BreachesByMetric = map[string]int{}
+	c.ComplianceRate = 1
+	for _, run := range runs {
+		if run.Compliant {
+			continue
+		}
+		c.BreachedRuns++
+		if c.LastBreachAt == nil || run.CreatedAt.After(*c.LastBreachAt) {
+			createdAt := run.CreatedAt
+			c.LastBreachAt = &createdAt
+		}
+		for _, breach := range run.Breaches {
+			c.BreachesByMetric[breach.Metric]++
+		}
+	}
+	if c.Runs > 0 {
+		c.ComplianceRate = float64(c.Runs-c.BreachedRuns) / float64(c.Runs)
+	}
+	return c
+}
+
+// GetSLACompliance summarizes the runs since a time of a workflow with an SLA, or
+// returns false if it has none
+func GetSLACompliance(workflowID string, since time.Time) (SLACompliance, bool, error) {
+	sla, ok, err := GetWorkflowSLA(workflowID)
+	if err != nil || !ok {
+		return SLACompliance{}, ok, err
+	}
+	c := SLACompliance{WorkflowID: workflowID, SLA: sla}
+	if workflow, err := GetWorkflow(AllTenants, workflowID); err == nil {
+		c.WorkflowName = workflow.Name
+	}
+	runs, err := ListSLARuns(workflowID, since, false, 0)
+	if err != nil {
+		return SLACompliance{}, false, err
+	}
+	return summarizeSLARuns(c, runs), true, nil
+}
@@ -2,12 +2,18 @@ package db
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 )
 
-// GetAllWorkflows returns all workflows from the database
-func GetAllWorkflows() ([]Workflow, error) {
-	rows, err := DB.Query("SELECT id, name, date, nodes, edges FROM workflows")
+// workflowColumns are the columns read by scanWorkflow
+const workflowColumns = "id, name, date, nodes, edges, version, tenant_id"
+
+// GetAllWorkflows returns all workflows of a tenant from the database, or those of every
+// tenant for AllTenants
+func GetAllWorkflows(tenantID string) ([]Workflow, error) {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	rows, err := DB.Query("SELECT "+workflowColumns+" FROM workflows WHERE 1 = 1"+condition, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -15,23 +21,11 @@ func GetAllWorkflows() ([]Workflow, error) {
 
 	var workflows []Workflow
 	for rows.Next() {
-		var workflow Workflow
-		var nodesStr, edgesStr string
-
-		err := rows.Scan(
-			&workflow.ID,
-			&workflow.Name,
-			&workflow.Date,
-			&nodesStr,
-			&edgesStr,
-		)
+		workflow, err := scanWorkflow(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		workflow.Nodes = json.RawMessage(nodesStr)
-		workflow.Edges = json.RawMessage(edgesStr)
-
 		workflows = append(workflows, workflow)
 	}
 
@@ -42,74 +36,145 @@ func GetAllWorkflows() ([]Workflow, error) {
 	return workflows, nil
 }
 
-// GetWorkflow retrieves a workflow by ID
-func GetWorkflow(id string) (Workflow, error) {
+// GetWorkflow retrieves a workflow of a tenant by ID
+func GetWorkflow(tenantID, id string) (Workflow, error) {
+	log.Printf("DEBUG: Attempting to get workflow with ID: %s", id)
+
+	condition, args := tenantCondition("tenant_id", tenantID)
+	workflow, err := scanWorkflow(DB.QueryRow(
+		"SELECT "+workflowColumns+" FROM workflows WHERE LOWER(id) = LOWER(?)"+condition,
+		append([]interface{}{id}, args...)...,
+	))
+
+	if err != nil {
+		log.Printf("DEBUG: Error retrieving workflow: %v", err)
+		return Workflow{}, err
+	}
+
+	log.Printf("DEBUG: Successfully found workflow: %s", workflow.Name)
+
+	return workflow, nil
+}
+
+// scanWorkflow reads a workflow selected with workflowColumns
+func scanWorkflow(row rowScanner) (Workflow, error) {
 	var workflow Workflow
 	var nodesStr, edgesStr string
 
-	log.Printf("DEBUG: Attempting to get workflow with ID: %s", id)
-
-	err := DB.QueryRow(
-		"SELECT id, name, date, nodes, edges FROM workflows WHERE id = ? COLLATE NOCASE",
-		id,
-	).Scan(
+	err := row.Scan(
 		&workflow.ID,
 		&workflow.Name,
 		&workflow.Date,
 		&nodesStr,
 		&edgesStr,
+		&workflow.Version,
+		&workflow.TenantID,
 	)
-
 	if err != nil {
-		log.Printf("DEBUG: Error retrieving workflow: %v", err)
 		return Workflow{}, err
 	}
 
-	log.Printf("DEBUG: Successfully found workflow: %s", workflow.Name)
-
 	workflow.Nodes = json.RawMessage(nodesStr)
 	workflow.Edges = json.RawMessage(edgesStr)
 
 	return workflow, nil
 }
 
-// CreateWorkflow inserts a new workflow into the database
-func CreateWorkflow(workflow Workflow) error {
+// ErrWorkflowIDTaken is returned when a new workflow has the ID of a stored workflow, of
+// any tenant
+var ErrWorkflowIDTaken = errors.New("workflow ID is not available")
+
+// CreateWorkflow inserts a new workflow of a tenant into the database
+func CreateWorkflow(tenantID string, workflow Workflow) error {
+	if taken, err := WorkflowExists(AllTenants, workflow.ID); err != nil {
+		return err
+	} else if taken {
+		return ErrWorkflowIDTaken
+	}
+
 	_, err := DB.Exec(
-		"INSERT INTO workflows (id, name, date, nodes, edges) VALUES (?, ?, ?, ?, ?)",
+		"INSERT INTO workflows (id, name, date, nodes, edges, tenant_id) VALUES (?, ?, ?, ?, ?, ?)",
 		workflow.ID,
 		workflow.Name,
 		workflow.Date,
 		string(workflow.Nodes),
 		string(workflow.Edges),
+		tenantID,
 	)
 
 	return err
 }
 
-// UpdateWorkflow updates an existing workflow
-func UpdateWorkflow(id string, workflow Workflow) error {
+// UpdateWorkflow updates an existing workflow of a tenant
+func UpdateWorkflow(tenantID, id string, workflow Workflow) error {
+	condition, args := tenantCondition("tenant_id", tenantID)
 	_, err := DB.Exec(
-		"UPDATE workflows SET name = ?, date = ?, nodes = ?, edges = ? WHERE id = ?",
+		"UPDATE workflows SET name = ?, date = ?, nodes = ?, edges = ?, version = version + 1 WHERE id = ?"+condition,
+		append([]interface{}{
+			workflow.Name,
+			workflow.Date,
+			string(workflow.Nodes),
+			string(workflow.Edges),
+			id,
+		}, args...)...,
+	)
+
+	return err
+}
+
+// updateWorkflowVersion updates a workflow only if it is still at baseVersion.
+// It returns ErrVersionConflict if another edit has been saved in the meantime.
+func updateWorkflowVersion(tx *Tx, workflow Workflow, baseVersion int) error {
+	result, err := tx.Exec(
+		"UPDATE workflows SET name = ?, date = ?, nodes = ?, edges = ?, version = version + 1 WHERE id = ? AND version = ?",
 		workflow.Name,
 		workflow.Date,
 		string(workflow.Nodes),
 		string(workflow.Edges),
-		id,
+		workflow.ID,
+		baseVersion,
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrVersionConflict
+	}
+
+	return nil
 }
 
-// DeleteWorkflow removes a workflow from the database
-func DeleteWorkflow(id string) error {
-	_, err := DB.Exec("DELETE FROM workflows WHERE id = ?", id)
-	return err
+// DeleteWorkflow removes a workflow of a tenant from the database
+func DeleteWorkflow(tenantID, id string) error {
+	condition, args := tenantCondition("tenant_id", tenantID)
+	result, err := DB.Exec("DELETE FROM workflows WHERE id = ?"+condition, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		// The settings of a workflow of another tenant are left alone
+		return nil
+	}
+	if err := DeleteWorkflowConcurrency(id); err != nil {
+		return err
+	}
+	return DeleteWorkflowSLA(id)
 }
 
-// WorkflowExists checks if a workflow with the given ID exists
-func WorkflowExists(id string) (bool, error) {
+// WorkflowExists checks if a workflow with the given ID exists in a tenant
+func WorkflowExists(tenantID, id string) (bool, error) {
 	var exists bool
-	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM workflows WHERE id = ? COLLATE NOCASE)", id).Scan(&exists)
+	condition, args := tenantCondition("tenant_id", tenantID)
+	err := DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM workflows WHERE LOWER(id) = LOWER(?)"+condition+")",
+		append([]interface{}{id}, args...)...,
+	).Scan(&exists)
 	return exists, err
 }
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// WorkspaceDefaults are parameters applied to analysis requests that omit them
+type WorkspaceDefaults struct {
+	BatchSize     int      `json:"batch_size,omitempty"`
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+	Language      string   `json:"language,omitempty"`
+	Detail        string   `json:"detail,omitempty"` // "concise" or "detailed"
+	FocusAreas    []string `json:"focus_areas,omitempty"`
+
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// createWorkspaceDefaultsTable creates the workspace_defaults table if it doesn't exist.
+// It holds at most one row per tenant.
+func createWorkspaceDefaultsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS workspace_defaults (
+			tenant_id TEXT PRIMARY KEY DEFAULT 'default',
+			defaults TEXT NOT NULL,
+			updated_by TEXT NOT NULL DEFAULT '',
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// GetWorkspaceDefaults returns the workspace defaults of a tenant, which are empty until set
+func GetWorkspaceDefaults(tenantID string) (WorkspaceDefaults, error) {
+	var encoded, updatedBy string
+	var updatedAt time.Time
+	err := DB.QueryRow("SELECT defaults, updated_by, updated_at FROM workspace_defaults WHERE tenant_id = ?", tenantID).
+		Scan(&encoded, &updatedBy, &updatedAt)
+	if err == sql.ErrNoRows {
+		return WorkspaceDefaults{}, nil
+	}
+	if err != nil {
+		return WorkspaceDefaults{}, fmt.Errorf("failed to get workspace defaults: %w", err)
+	}
+
+	var defaults WorkspaceDefaults
+	if err := json.Unmarshal([]byte(encoded), &defaults); err != nil {
+		return WorkspaceDefaults{}, fmt.Errorf("failed to decode workspace defaults: %w", err)
+	}
+	defaults.UpdatedBy = updatedBy
+	defaults.UpdatedAt = &updatedAt
+	return defaults, nil
+}
+
+// SaveWorkspaceDefaults replaces the workspace defaults of a tenant
+func SaveWorkspaceDefaults(tenantID string, defaults WorkspaceDefaults, actor string) error {
+	defaults.UpdatedBy, defaults.UpdatedAt = "", nil
+	encoded, err := json.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(
+		`INSERT INTO workspace_defaults (tenant_id, defaults, updated_by, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(tenant_id) DO UPDATE SET defaults = excluded.defaults, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+		tenantID, string(encoded), actor, time.Now(),
+	)
+	return err
+}
+
+// DeleteWorkspaceDefaults clears the workspace defaults of a tenant
+func DeleteWorkspaceDefaults(tenantID string) error {
+	_, err := DB.Exec("DELETE FROM workspace_defaults WHERE tenant_id = ?", tenantID)
+	return err
+}
@@ -0,0 +1,118 @@
+package demo
+
+import "strings"
+
+// Conversation is a synthetic customer service conversation
+type Conversation struct {
+	ID   string `json:"conversation_id"`
+	Text string `json:"text"`
+}
+
+// conversations is the synthetic dataset served in demo mode. It contains no real customer data.
+var conversations = []Conversation{
+	{
+		ID: "demo-conv-001",
+		Text: `Customer: I was charged a $35 overdraft fee yesterday but my paycheck was deposited the same morning.
+Agent: I'm sorry about that. I can see the deposit posted at 9:14 and the fee was assessed overnight before it cleared.
+Customer: That doesn't seem fair. I've been a customer for eight years.
+Agent: I understand. As a courtesy I've refunded the fee. You can also turn on low balance alerts in the app.
+Customer: Thanks, I'll do that.`,
+	},
+	{
+		ID: "demo-conv-002",
+		Text: `Customer: My card was declined at the grocery store even though I have money in my account.
+Agent: Let me check. It looks like our fraud system flagged a purchase in another state this morning.
+Customer: That wasn't me! I've been home all day.
+Agent: I've blocked the card and will send a replacement within five business days. I've also opened a dispute for the charge.
+Customer: Five days? I need to buy food.
+Agent: I can enable a temporary virtual card in the app right now that you can use with your phone wallet.`,
+	},
+	{
+		ID: "demo-conv-003",
+		Text: `Customer: I'd like to close my savings account.
+Agent: I can help with that. May I ask why you're closing it?
+Customer: The interest rate is much lower than what the online banks offer.
+Agent: I understand. We do have a high-yield savings option at 4.1% for balances over $10,000.
+Customer: My balance is under that. I'll go ahead and close it.
+Agent: I've started the closure. The funds will be transferred to your checking account within two business days.`,
+	},
+	{
+		ID: "demo-conv-004",
+		Text: `Customer: I'm trying to set up my new phone for mobile banking but I never receive the verification code.
+Agent: Let me look. The phone number on file ends in 4471. Is that still correct?
+Customer: No, I changed numbers last month.
+Agent: For security, I'll need to verify your identity before updating it. Can you confirm the last four digits of your card and your date of birth?
+Customer: Sure, 8812 and March 3rd 1985.
+Agent: Thank you. I've updated your number. Please request a new code now.
+Customer: Got it, I'm in. Thanks!`,
+	},
+	{
+		ID: "demo-conv-005",
+		Text: `Customer: Why was I charged a monthly maintenance fee? I thought my account was free.
+Agent: Your account is free when you keep a $1,500 minimum balance or have a direct deposit. Last month your balance dropped below the minimum.
+Customer: Nobody told me about a minimum balance.
+Agent: I'm sorry for the confusion. I've refunded this month's fee, and I can switch you to our no-minimum account if you'd like.
+Customer: Yes, please switch me.`,
+	},
+	{
+		ID: "demo-conv-006",
+		Text: `Customer: I sent a wire transfer three days ago and the recipient still hasn't received it.
+Agent: I see the wire was sent on Monday. International wires can take three to five business days.
+Customer: It's for a house deposit and the deadline is tomorrow.
+Agent: I understand the urgency. I've requested a trace from our correspondent bank and marked it as priority. You'll get an update within 24 hours.
+Customer: Please call me as soon as you hear anything.`,
+	},
+	{
+		ID: "demo-conv-007",
+		Text: `Customer: I want to dispute a charge from an online store. I returned the item but never got my refund.
+Agent: I'm sorry to hear that. How long ago did you return it?
+Customer: About six weeks ago. I have the tracking number showing it was delivered.
+Agent: Thank you. I've opened a dispute and issued a provisional credit of $89.99 while we investigate. Please upload the tracking receipt in the app.
+Customer: Great, that was easier than I expected.`,
+	},
+	{
+		ID: "demo-conv-008",
+		Text: `Customer: I'd like to increase the credit limit on my card.
+Agent: I can submit that request. What limit are you looking for?
+Customer: From $3,000 to $6,000.
+Agent: Based on your payment history you're pre-approved for $5,000 without a credit check. Would you like that, or should I submit the full request, which requires a hard inquiry?
+Customer: $5,000 is fine, no credit check please.
+Agent: Done. Your new limit is available immediately.`,
+	},
+}
+
+// Conversations returns the synthetic dataset
+func Conversations() []Conversation {
+	result := make([]Conversation, len(conversations))
+	copy(result, conversations)
+	return result
+}
+
+// ConversationsByID returns the synthetic conversations with the given IDs, or all of
+// them if none of the IDs belong to the dataset
+func ConversationsByID(ids []string) []Conversation {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var matched []Conversation
+	for _, conversation := range conversations {
+		if wanted[conversation.ID] {
+			matched = append(matched, conversation)
+		}
+	}
+	if len(matched) == 0 {
+		return Conversations()
+	}
+	return matched
+}
+
+// ConversationsText joins the synthetic conversations into a single context text
+func ConversationsText() string {
+	texts := make([]string, len(conversations))
+	for i, conversation := range conversations {
+		texts[i] = conversation.Text
+	}
+	return strings.Join(texts, "\n\n---\n\n")
+}
@@ -0,0 +1,56 @@
+// Package demo holds the settings and synthetic dataset of the public demo mode
+package demo
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Demo mode environment variables
+const (
+	EnvDemoMode           = "DEMO_MODE"
+	EnvDemoRateLimit      = "DEMO_RATE_LIMIT"       // Requests per minute per client
+	EnvDemoWriteRateLimit = "DEMO_WRITE_RATE_LIMIT" // Non-GET requests per minute per client
+	EnvDemoTrustProxy     = "DEMO_TRUST_PROXY"      // Identify clients by X-Forwarded-For
+)
+
+// Default demo limits
+const (
+	DefaultRateLimit      = 30
+	DefaultWriteRateLimit = 6
+	MaxRequestBodyBytes   = 256 * 1024
+)
+
+// Enabled reports whether the server runs as a public demo: with the mock LLM, the
+// synthetic dataset, read-mostly endpoints and per-client rate limits
+func Enabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnvDemoMode))
+	return enabled
+}
+
+// Config holds the demo rate limits
+type Config struct {
+	RateLimit      int  `json:"rate_limit_per_minute"`
+	WriteRateLimit int  `json:"write_rate_limit_per_minute"`
+	TrustProxy     bool `json:"-"`
+}
+
+// ConfigFromEnv reads the demo limits from the environment
+func ConfigFromEnv() Config {
+	config := Config{
+		RateLimit:      positiveInt(os.Getenv(EnvDemoRateLimit), DefaultRateLimit),
+		WriteRateLimit: positiveInt(os.Getenv(EnvDemoWriteRateLimit), DefaultWriteRateLimit),
+	}
+	config.TrustProxy, _ = strconv.ParseBool(os.Getenv(EnvDemoTrustProxy))
+	return config
+}
+
+// positiveInt parses a positive integer, falling back to def
+func positiveInt(value string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
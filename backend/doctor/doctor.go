@@ -0,0 +1,345 @@
+// Package doctor checks the deployment of the server: the database and its schema, the
+// model provider and its key, optional providers, authentication and free disk space.
+// Each failed check says how to fix it.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/analysis/embeddings"
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/translate"
+)
+
+// Statuses of a check
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusError   = "error"
+)
+
+// Free disk space thresholds
+const (
+	lowDiskSpace      = 1 << 30   // Warn below 1 GiB
+	criticalDiskSpace = 100 << 20 // Fail below 100 MiB
+)
+
+// providerTimeout bounds the request checking the model provider
+const providerTimeout = 10 * time.Second
+
+// Check is the outcome of one check. Remedy says how to fix a warning or error.
+type Check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Remedy  string `json:"remedy,omitempty"`
+}
+
+// Report holds the outcome of all checks
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// OK reports whether no check failed. Warnings do not fail the report.
+func (r Report) OK() bool {
+	for _, check := range r.Checks {
+		if check.Status == StatusError {
+			return false
+		}
+	}
+	return true
+}
+
+// Problems returns the checks that warned or failed
+func (r Report) Problems() []Check {
+	var problems []Check
+	for _, check := range r.Checks {
+		if check.Status != StatusOK {
+			problems = append(problems, check)
+		}
+	}
+	return problems
+}
+
+// Print writes the report, one check per line followed by the remedy of failed checks
+func (r Report) Print(w io.Writer) {
+	for _, check := range r.Checks {
+		fmt.Fprintf(w, "[%s] %s: %s\n", strings.ToUpper(check.Status), check.Name, check.Message)
+		if check.Remedy != "" {
+			fmt.Fprintf(w, "        -> %s\n", check.Remedy)
+		}
+	}
+}
+
+// Run runs all checks. It opens the database, without creating tables, unless it is
+// already open.
+func Run(ctx context.Context) Report {
+	var report Report
+	report.Checks = append(report.Checks, checkDatabase()...)
+	report.Checks = append(report.Checks, checkProvider(ctx))
+	report.Checks = append(report.Checks, checkEmbeddings(), checkTranslation())
+	if db.DB != nil {
+		report.Checks = append(report.Checks, checkAuth())
+	}
+	report.Checks = append(report.Checks, checkDiskSpace()...)
+	return report
+}
+
+// checkDatabase checks the connection, the schema version and the tables
+func checkDatabase() []Check {
+	if db.DB == nil {
+		if err := db.Open(); err != nil {
+			return []Check{{
+				Name:    "database",
+				Status:  StatusError,
+				Message: fmt.Sprintf("cannot open the database: %v", err),
+				Remedy:  "Check DATABASE_URL: it must be a postgres:// URL the server can reach or the path of a writable SQLite file",
+			}}
+		}
+	}
+	if err := db.Ping(); err != nil {
+		return []Check{{
+			Name:    "database",
+			Status:  StatusError,
+			Message: fmt.Sprintf("%s does not answer: %v", db.Location(), err),
+			Remedy:  "Check that the database server is running and that DATABASE_URL holds the right host and credentials",
+		}}
+	}
+	checks := []Check{{Name: "database", Status: StatusOK, Message: "connected to " + db.Location()}}
+
+	schema := Check{Name: "schema version"}
+	version, err := db.GetSchemaVersion()
+	switch {
+	case err != nil:
+		schema.Status, schema.Message = StatusError, fmt.Sprintf("cannot read the schema version: %v", err)
+		schema.Remedy = "Check that the database user may read the schema_info table"
+	case version == 0:
+		schema.Status, schema.Message = StatusWarning, "the database has no recorded schema version"
+		schema.Remedy = "Start the server once; it creates missing tables and records the schema version"
+	case version > db.SchemaVersion:
+		schema.Status = StatusError
+		schema.Message = fmt.Sprintf("the database has schema version %d, newer than version %d of this build", version, db.SchemaVersion)
+		schema.Remedy = "Upgrade this server to the release that last started against the database"
+	case version < db.SchemaVersion:
+		schema.Status = StatusWarning
+		schema.Message = fmt.Sprintf("the database has schema version %d, older than version %d of this build", version, db.SchemaVersion)
+		schema.Remedy = "Start the server once; it adds the missing tables and columns"
+	default:
+		schema.Status, schema.Message = StatusOK, fmt.Sprintf("version %d", version)
+	}
+	checks = append(checks, schema)
+
+	tables := Check{Name: "tables"}
+	missing, err := db.MissingTables()
+	switch {
+	case err != nil:
+		tables.Status, tables.Message = StatusError, fmt.Sprintf("cannot list the tables: %v", err)
+		tables.Remedy = "Check that the database user may read the schema catalog"
+	case len(missing) > 0:
+		tables.Status, tables.Message = StatusError, "missing tables: "+strings.Join(missing, ", ")
+		tables.Remedy = "Start the server once with a database user allowed to create tables; analysis_results is created when the analysis endpoints start"
+	default:
+		tables.Status, tables.Message = StatusOK, "all required tables exist"
+	}
+	return append(checks, tables)
+}
+
+// checkProvider checks that analyses are served by a model, and that its endpoint
+// answers and accepts the key
+func checkProvider(ctx context.Context) Check {
+	check := Check{Name: "model provider"}
+	apiKey := os.Getenv("GEMINI_API_KEY")
+
+	if core.MockConfigured() {
+		check.Status, check.Message = StatusWarning, "analyses return mock output (DEMO_MODE or LLM_PROVIDER=mock)"
+		check.Remedy = fmt.Sprintf("Unset DEMO_MODE and %s and set %s to analyze with a model", core.EnvLLMProvider, core.EnvLLMBaseURL)
+		return check
+	}
+
+	config := core.LLMConfigFromEnv(apiKey)
+	if config.BaseURL == "" {
+		if apiKey == "" {
+			check.Status, check.Message = StatusError, "no model is configured, so all analysis endpoints are disabled"
+			check.Remedy = fmt.Sprintf("Set %s to an OpenAI-compatible endpoint, with %s if it needs a key, or set %s=mock to try the API", core.EnvLLMBaseURL, core.EnvLLMAPIKey, core.EnvLLMProvider)
+			return check
+		}
+		check.Status, check.Message = StatusWarning, fmt.Sprintf("GEMINI_API_KEY is set without %s, so analyses return mock output", core.EnvLLMBaseURL)
+		check.Remedy = fmt.Sprintf("Set %s to an OpenAI-compatible endpoint, e.g. https://generativelanguage.googleapis.com/v1beta/openai for Gemini", core.EnvLLMBaseURL)
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.BaseURL+"/models", nil)
+	if err != nil {
+		check.Status, check.Message = StatusError, fmt.Sprintf("invalid %s: %v", core.EnvLLMBaseURL, err)
+		check.Remedy = fmt.Sprintf("Set %s to the base URL of the API, e.g. http://localhost:8000/v1", core.EnvLLMBaseURL)
+		return check
+	}
+	if config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		check.Status, check.Message = StatusError, fmt.Sprintf("cannot reach %s: %v", config.BaseURL, err)
+		check.Remedy = fmt.Sprintf("Check %s and that the endpoint is reachable from this host (proxy, firewall, DNS)", core.EnvLLMBaseURL)
+		return check
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		check.Status, check.Message = StatusError, fmt.Sprintf("%s rejected the key with status %d", config.BaseURL, resp.StatusCode)
+		check.Remedy = fmt.Sprintf("Set a valid key in %s or GEMINI_API_KEY, or the authentication headers of the gateway in %s", core.EnvLLMAPIKey, core.EnvLLMHeaders)
+	case resp.StatusCode >= 500:
+		check.Status, check.Message = StatusWarning, fmt.Sprintf("%s answered with status %d", config.BaseURL, resp.StatusCode)
+		check.Remedy = "The endpoint is reachable but failing; check the status of the provider or gateway"
+	default:
+		// Endpoints that do not list models answer 404, which still proves they are reachable
+		check.Status, check.Message = StatusOK, "reachable at "+config.BaseURL
+	}
+	return check
+}
+
+// checkEmbeddings checks the embedding provider configuration
+func checkEmbeddings() Check {
+	check := Check{Name: "embeddings"}
+	embedder, err := embeddings.NewEmbedder(embeddings.ConfigFromEnv(), os.Getenv("GEMINI_API_KEY"))
+	if err != nil {
+		check.Status, check.Message = StatusError, err.Error()
+		check.Remedy = fmt.Sprintf("Set %s to %s, %s or %s and configure the provider", embeddings.EnvEmbeddingProvider, embeddings.ProviderAuto, embeddings.ProviderLLM, embeddings.ProviderLocal)
+		return check
+	}
+	check.Status, check.Message = StatusOK, "model "+embedder.Model()
+	return check
+}
+
+// checkTranslation checks the translation provider configuration. The llm provider runs
+// on the analysis model, which checkProvider covers.
+func checkTranslation() Check {
+	check := Check{Name: "translation"}
+	config := translate.ConfigFromEnv()
+	if config.Provider == translate.ProviderLLM {
+		check.Status, check.Message = StatusOK, "translated by the analysis model"
+		return check
+	}
+	if _, err := translate.NewTranslator(config, nil); err != nil {
+		check.Status, check.Message = StatusError, err.Error()
+		check.Remedy = fmt.Sprintf("Set %s to %s or %s and the settings of the provider", translate.EnvTranslationProvider, translate.ProviderLLM, translate.ProviderDeepL)
+		return check
+	}
+	check.Status, check.Message = StatusOK, "translated by "+config.Provider
+	return check
+}
+
+// checkAuth checks that some key can authenticate when authentication is required
+func checkAuth() Check {
+	check := Check{Name: "authentication"}
+	if !auth.Enabled() {
+		check.Status, check.Message = StatusOK, "disabled; every client has full access"
+		return check
+	}
+	if os.Getenv(auth.EnvAdminAPIKey) != "" {
+		check.Status, check.Message = StatusOK, "enabled with a bootstrap admin key"
+		return check
+	}
+
+	keys, err := db.ListAPIKeys(db.AllTenants)
+	if err != nil {
+		check.Status, check.Message = StatusError, fmt.Sprintf("cannot list API keys: %v", err)
+		check.Remedy = "Check the database checks above"
+		return check
+	}
+	now := time.Now()
+	for _, key := range keys {
+		if key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(now)) {
+			check.Status, check.Message = StatusOK, "enabled"
+			return check
+		}
+	}
+	check.Status, check.Message = StatusError, "authentication is enabled but no valid API key exists, so every request is rejected"
+	check.Remedy = fmt.Sprintf("Set %s to a bootstrap admin key and issue keys with POST /api/auth/keys", auth.EnvAdminAPIKey)
+	return check
+}
+
+// checkDiskSpace checks the free space where the server writes: the SQLite database, cold
+// conversation text and the data directory
+func checkDiskSpace() []Check {
+	dirs := []string{}
+	if path := db.FilePath(); path != "" {
+		dirs = append(dirs, filepath.Dir(path))
+	}
+	dirs = append(dirs, db.ColdStorageDir(), "data")
+
+	var checks []Check
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			abs = dir
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		check := Check{Name: "disk space " + abs}
+		free, err := freeSpace(existingAncestor(abs))
+		switch {
+		case err != nil:
+			check.Status, check.Message = StatusWarning, fmt.Sprintf("cannot read the free space: %v", err)
+		case free < criticalDiskSpace:
+			check.Status, check.Message = StatusError, formatBytes(free)+" free"
+			check.Remedy = "Free disk space or move the directory to a larger volume; writes fail when the disk is full"
+		case free < lowDiskSpace:
+			check.Status, check.Message = StatusWarning, formatBytes(free)+" free"
+			check.Remedy = "Free disk space, or archive old conversations to cold storage"
+		default:
+			check.Status, check.Message = StatusOK, formatBytes(free)+" free"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// existingAncestor returns the closest directory of path that exists, as directories the
+// server creates on demand may not exist yet
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// formatBytes formats a byte count in binary units
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
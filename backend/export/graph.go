@@ -0,0 +1,318 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Graph formats supported by WriteGraph
+const (
+	GraphFormatGraphML = "graphml"
+	GraphFormatDOT     = "dot"
+	GraphFormatJSON    = "json"
+)
+
+// Node kinds of a taxonomy graph
+const (
+	NodeIntent      = "intent"
+	NodeIntentGroup = "intent_group"
+	NodePattern     = "pattern"
+)
+
+// Edge relations of a taxonomy graph
+const (
+	// RelationContains links an intent group to the intents it groups
+	RelationContains = "contains"
+	// RelationCites links a pattern to an intent its description or examples mention
+	RelationCites = "cites"
+)
+
+// GraphNode is an intent, intent group or pattern
+type GraphNode struct {
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	// Count is how many intent results classified the intent, or a pattern's occurrences
+	Count    int    `json:"count"`
+	ResultID string `json:"result_id,omitempty"`
+}
+
+// GraphEdge is a relationship between two nodes
+type GraphEdge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Relation string `json:"relation"`
+}
+
+// Graph is the intent taxonomy and pattern structure of a set of analysis results
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// minCitedNameLength keeps short intent names from being found inside unrelated words
+const minCitedNameLength = 4
+
+// TaxonomyGraph builds the graph of the intents, intent groups and patterns in stored
+// analysis results. Intents come from intent results. A pattern whose examples name
+// intents, as intent_groups patterns do, is an intent group containing them, and its
+// other examples become intents too; other patterns cite the intents their
+// description, significance or examples mention.
+func TaxonomyGraph(results []map[string]interface{}) *Graph {
+	g := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	intents := map[string]int{} // normalized intent name -> node index
+
+	addIntent := func(label, name, description, resultID string) int {
+		key := normalizeName(label)
+		if key == "" {
+			key = normalizeName(name)
+		}
+		if index, ok := intents[key]; ok {
+			return index
+		}
+		if name == "" {
+			name = label
+		}
+		if label == "" {
+			label = strings.ReplaceAll(key, " ", "_")
+		}
+		g.Nodes = append(g.Nodes, GraphNode{
+			ID:          "intent:" + label,
+			Kind:        NodeIntent,
+			Label:       name,
+			Description: description,
+			ResultID:    resultID,
+		})
+		index := len(g.Nodes) - 1
+		intents[key] = index
+		if alias := normalizeName(name); alias != "" {
+			if _, ok := intents[alias]; !ok {
+				intents[alias] = index
+			}
+		}
+		return index
+	}
+
+	for _, result := range results {
+		if result["analysis_type"] != "intent" {
+			continue
+		}
+		body, _ := result["results"].(map[string]interface{})
+		label, _ := body["label"].(string)
+		name, _ := body["label_name"].(string)
+		if normalizeName(label) == "" && normalizeName(name) == "" {
+			continue
+		}
+		description, _ := body["description"].(string)
+		resultID, _ := result["id"].(string)
+		g.Nodes[addIntent(label, name, description, resultID)].Count++
+	}
+
+	type patternItem struct {
+		resultID string
+		pattern  map[string]interface{}
+	}
+	var patterns []patternItem
+	for _, result := range results {
+		if result["analysis_type"] != "patterns" {
+			continue
+		}
+		body, _ := result["results"].(map[string]interface{})
+		list, _ := body["patterns"].([]interface{})
+		resultID, _ := result["id"].(string)
+		for _, item := range list {
+			if pattern, ok := item.(map[string]interface{}); ok {
+				patterns = append(patterns, patternItem{resultID: resultID, pattern: pattern})
+			}
+		}
+	}
+
+	for i, item := range patterns {
+		name, _ := item.pattern["pattern_type"].(string)
+		description, _ := item.pattern["pattern_description"].(string)
+		significance, _ := item.pattern["significance"].(string)
+		occurrences, _ := item.pattern["occurrences"].(float64)
+		var examples []string
+		for _, example := range toList(item.pattern["examples"]) {
+			if text, ok := example.(string); ok && strings.TrimSpace(text) != "" {
+				examples = append(examples, text)
+			}
+		}
+
+		node := GraphNode{
+			ID:          "pattern:" + strconv.Itoa(i+1),
+			Kind:        NodePattern,
+			Label:       name,
+			Description: description,
+			Count:       int(occurrences),
+			ResultID:    item.resultID,
+		}
+
+		// Examples naming known intents make the pattern an intent group
+		grouped := false
+		for _, example := range examples {
+			if _, ok := intents[normalizeName(example)]; ok {
+				grouped = true
+				break
+			}
+		}
+
+		related := map[int]bool{}
+		if grouped {
+			node.Kind = NodeIntentGroup
+			node.ID = "group:" + strconv.Itoa(i+1)
+			for _, example := range examples {
+				index := addIntent("", example, "", "")
+				if !related[index] {
+					related[index] = true
+					g.Edges = append(g.Edges, GraphEdge{Source: node.ID, Target: g.Nodes[index].ID, Relation: RelationContains})
+				}
+			}
+		}
+
+		text := " " + normalizeName(strings.Join(append([]string{description, significance}, examples...), " ")) + " "
+		cited := []int{}
+		for key, index := range intents {
+			if related[index] || len(key) < minCitedNameLength {
+				continue
+			}
+			if strings.Contains(text, " "+key+" ") {
+				related[index] = true
+				cited = append(cited, index)
+			}
+		}
+		sort.Ints(cited)
+		for _, index := range cited {
+			g.Edges = append(g.Edges, GraphEdge{Source: node.ID, Target: g.Nodes[index].ID, Relation: RelationCites})
+		}
+
+		g.Nodes = append(g.Nodes, node)
+	}
+	return g
+}
+
+// normalizeName lowercases a name and replaces runs of other characters than letters
+// and digits with single spaces, so "Cancel Order" and "cancel_order" match
+func normalizeName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), " ")
+}
+
+// toList returns value as a list, or nil if it is not one
+func toList(value interface{}) []interface{} {
+	list, _ := value.([]interface{})
+	return list
+}
+
+// ValidGraphFormat reports whether format is a supported graph format
+func ValidGraphFormat(format string) bool {
+	return format == GraphFormatGraphML || format == GraphFormatDOT || format == GraphFormatJSON
+}
+
+// GraphContentType returns the MIME type of a graph format
+func GraphContentType(format string) string {
+	switch format {
+	case GraphFormatDOT:
+		return "text/vnd.graphviz"
+	case GraphFormatJSON:
+		return "application/json"
+	default:
+		return "application/graphml+xml"
+	}
+}
+
+// WriteGraph writes a graph in the given format
+func WriteGraph(w io.Writer, format string, g *Graph) error {
+	switch format {
+	case GraphFormatGraphML:
+		return writeGraphML(w, g)
+	case GraphFormatDOT:
+		return writeDOT(w, g)
+	case GraphFormatJSON:
+		return json.NewEncoder(w).Encode(g)
+	default:
+		return fmt.Errorf("unsupported graph format %q", format)
+	}
+}
+
+// writeGraphML writes a graph as GraphML with the node and edge fields as data keys
+func writeGraphML(w io.Writer, g *Graph) error {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	sb.WriteString(`  <key id="kind" for="node" attr.name="kind" attr.type="string"/>` + "\n")
+	sb.WriteString(`  <key id="label" for="node" attr.name="label" attr.type="string"/>` + "\n")
+	sb.WriteString(`  <key id="description" for="node" attr.name="description" attr.type="string"/>` + "\n")
+	sb.WriteString(`  <key id="count" for="node" attr.name="count" attr.type="int"/>` + "\n")
+	sb.WriteString(`  <key id="result_id" for="node" attr.name="result_id" attr.type="string"/>` + "\n")
+	sb.WriteString(`  <key id="relation" for="edge" attr.name="relation" attr.type="string"/>` + "\n")
+	sb.WriteString(`  <graph id="taxonomy" edgedefault="directed">` + "\n")
+
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&sb, `    <node id="%s">`, xmlEscape(node.ID))
+		fmt.Fprintf(&sb, `<data key="kind">%s</data><data key="label">%s</data>`, node.Kind, xmlEscape(node.Label))
+		if node.Description != "" {
+			fmt.Fprintf(&sb, `<data key="description">%s</data>`, xmlEscape(node.Description))
+		}
+		fmt.Fprintf(&sb, `<data key="count">%d</data>`, node.Count)
+		if node.ResultID != "" {
+			fmt.Fprintf(&sb, `<data key="result_id">%s</data>`, xmlEscape(node.ResultID))
+		}
+		sb.WriteString("</node>\n")
+	}
+	for i, edge := range g.Edges {
+		fmt.Fprintf(&sb, `    <edge id="e%d" source="%s" target="%s"><data key="relation">%s</data></edge>`+"\n",
+			i+1, xmlEscape(edge.Source), xmlEscape(edge.Target), edge.Relation)
+	}
+
+	sb.WriteString("  </graph>\n</graphml>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotShapes distinguish the node kinds in DOT output
+var dotShapes = map[string]string{
+	NodeIntent:      "ellipse",
+	NodeIntentGroup: "box",
+	NodePattern:     "note",
+}
+
+// writeDOT writes a graph in the Graphviz DOT language
+func writeDOT(w io.Writer, g *Graph) error {
+	var sb strings.Builder
+	sb.WriteString("digraph taxonomy {\n  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&sb, "  %s [label=%s, shape=%s, kind=%s, count=%d];\n",
+			dotQuote(node.ID), dotQuote(node.Label), dotShapes[node.Kind], dotQuote(node.Kind), node.Count)
+	}
+	for _, edge := range g.Edges {
+		style := "solid"
+		if edge.Relation == RelationCites {
+			style = "dashed"
+		}
+		fmt.Fprintf(&sb, "  %s -> %s [label=%s, style=%s];\n",
+			dotQuote(edge.Source), dotQuote(edge.Target), dotQuote(edge.Relation), style)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotQuote quotes a DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// xmlEscape escapes text for XML content and attributes
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,259 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect of exported attribute schemas
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaKeyValidationRules is the extension keyword holding the validation rules of a set.
+// Properties keep speakers, rationales and enum synonyms in x-speaker, x-rationale and
+// x-enum-synonyms.
+const schemaKeyValidationRules = "x-validation-rules"
+
+// AttributeSchema describes a set of attribute definitions as a JSON Schema: an object
+// with a property per attribute, in definition order, carrying its title, description,
+// type and enum. Dates are strings of format date. Speaker restrictions, rationales, enum
+// synonyms and the set's validation rules are kept in x- keywords so an import restores
+// them.
+type AttributeSchema struct {
+	Title       string
+	Description string
+	Attributes  []models.AttributeDefinition
+	// ValidationRules are the set's validation rules as stored, or nil
+	ValidationRules json.RawMessage
+}
+
+// MarshalJSON writes the schema with its properties in definition order
+func (s AttributeSchema) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"$schema":`)
+	writeJSONValue(&buf, JSONSchemaDraft)
+	if s.Title != "" {
+		buf.WriteString(`,"title":`)
+		writeJSONValue(&buf, s.Title)
+	}
+	if s.Description != "" {
+		buf.WriteString(`,"description":`)
+		writeJSONValue(&buf, s.Description)
+	}
+	buf.WriteString(`,"type":"object","properties":{`)
+	for i, attribute := range s.Attributes {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONValue(&buf, attribute.FieldName)
+		buf.WriteByte(':')
+		property, err := json.Marshal(attributeProperty(attribute))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(property)
+	}
+	buf.WriteString(`},"additionalProperties":false`)
+	if len(s.ValidationRules) > 0 {
+		buf.WriteString(`,"` + schemaKeyValidationRules + `":`)
+		buf.Write(s.ValidationRules)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// schemaProperty is the JSON Schema of one attribute
+type schemaProperty struct {
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type"`
+	Format      string   `json:"format,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Speaker     string   `json:"x-speaker,omitempty"`
+	Rationale   string   `json:"x-rationale,omitempty"`
+
+	EnumSynonyms map[string][]string `json:"x-enum-synonyms,omitempty"`
+}
+
+// attributeProperty converts an attribute definition to its JSON Schema property
+func attributeProperty(attribute models.AttributeDefinition) schemaProperty {
+	property := schemaProperty{
+		Title:       attribute.Title,
+		Description: attribute.Description,
+		Type:        attribute.Type,
+		Enum:        attribute.EnumValues,
+		Speaker:     attribute.Speaker,
+		Rationale:   attribute.Rationale,
+
+		EnumSynonyms: attribute.EnumSynonyms,
+	}
+	switch attribute.Type {
+	case "":
+		property.Type = models.AttributeTypeString
+	case models.AttributeTypeDate:
+		property.Type, property.Format = models.AttributeTypeString, "date"
+	}
+	return property
+}
+
+// ParseAttributeSchema reads attribute definitions from a JSON Schema of an object, such
+// as one exported with AttributeSchema or derived from a warehouse table. Each property
+// becomes an attribute in the order the schema lists them. Properties must be strings,
+// numbers, integers or booleans, optionally nullable; strings of format date or
+// date-time become dates.
+func ParseAttributeSchema(data []byte) (AttributeSchema, error) {
+	var document struct {
+		Title       string          `json:"title"`
+		Description string          `json:"description"`
+		Type        interface{}     `json:"type"`
+		Properties  json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return AttributeSchema{}, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	if document.Type != nil && document.Type != "object" {
+		return AttributeSchema{}, fmt.Errorf("the schema must describe an object")
+	}
+	if len(document.Properties) == 0 {
+		return AttributeSchema{}, fmt.Errorf("the schema has no properties")
+	}
+
+	names, err := objectKeys(document.Properties)
+	if err != nil {
+		return AttributeSchema{}, fmt.Errorf("invalid properties: %w", err)
+	}
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal(document.Properties, &properties); err != nil {
+		return AttributeSchema{}, fmt.Errorf("invalid properties: %w", err)
+	}
+
+	var extensions map[string]json.RawMessage
+	if err := json.Unmarshal(data, &extensions); err != nil {
+		return AttributeSchema{}, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	schema := AttributeSchema{Title: document.Title, Description: document.Description}
+	if rules := extensions[schemaKeyValidationRules]; len(rules) > 0 && string(rules) != "null" {
+		schema.ValidationRules = rules
+	}
+	for _, name := range names {
+		attribute, err := parseSchemaProperty(name, properties[name])
+		if err != nil {
+			return AttributeSchema{}, fmt.Errorf("property %s: %w", name, err)
+		}
+		schema.Attributes = append(schema.Attributes, attribute)
+	}
+	return schema, nil
+}
+
+// parseSchemaProperty converts the JSON Schema of a property to an attribute definition
+func parseSchemaProperty(name string, data json.RawMessage) (models.AttributeDefinition, error) {
+	var property struct {
+		Title       string        `json:"title"`
+		Description string        `json:"description"`
+		Type        interface{}   `json:"type"`
+		Format      string        `json:"format"`
+		Enum        []interface{} `json:"enum"`
+		Speaker     string        `json:"x-speaker"`
+		Rationale   string        `json:"x-rationale"`
+
+		EnumSynonyms map[string][]string `json:"x-enum-synonyms"`
+	}
+	if err := json.Unmarshal(data, &property); err != nil {
+		return models.AttributeDefinition{}, err
+	}
+
+	attribute := models.AttributeDefinition{
+		FieldName:   name,
+		Title:       property.Title,
+		Description: property.Description,
+		Speaker:     property.Speaker,
+		Rationale:   property.Rationale,
+
+		EnumSynonyms: property.EnumSynonyms,
+	}
+	if attribute.Title == "" {
+		attribute.Title = strings.ReplaceAll(name, "_", " ")
+	}
+	if attribute.Description == "" {
+		attribute.Description = attribute.Title
+	}
+
+	valueType, err := propertyType(property.Type)
+	if err != nil {
+		return models.AttributeDefinition{}, err
+	}
+	switch valueType {
+	case "", models.AttributeTypeString:
+		if property.Format == "date" || property.Format == "date-time" {
+			attribute.Type = models.AttributeTypeDate
+		}
+	case models.AttributeTypeNumber, models.AttributeTypeInteger, models.AttributeTypeBoolean:
+		attribute.Type = valueType
+	default:
+		return models.AttributeDefinition{}, fmt.Errorf("type %s is not supported; use string, number, integer or boolean", valueType)
+	}
+
+	for _, value := range property.Enum {
+		if value == nil {
+			continue
+		}
+		attribute.EnumValues = append(attribute.EnumValues, fmt.Sprint(value))
+	}
+	return attribute, nil
+}
+
+// propertyType reads the type keyword of a property, which is a type name or a list of
+// them of which "null" is ignored
+func propertyType(keyword interface{}) (string, error) {
+	switch value := keyword.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case []interface{}:
+		var types []string
+		for _, item := range value {
+			if name, ok := item.(string); ok && name != "null" {
+				types = append(types, name)
+			}
+		}
+		if len(types) > 1 {
+			return "", fmt.Errorf("union types are not supported")
+		}
+		if len(types) == 1 {
+			return types[0], nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid type")
+}
+
+// objectKeys returns the keys of a JSON object in the order they appear
+func objectKeys(data json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object")
+	}
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// writeJSONValue writes a value encoded as JSON
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	encoded, _ := json.Marshal(value)
+	buf.Write(encoded)
+}
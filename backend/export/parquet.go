@@ -0,0 +1,266 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Parquet constants used by the writer; see the parquet-format Thrift definitions
+const (
+	parquetMagic = "PAR1"
+
+	parquetTypeDouble    = 5
+	parquetTypeByteArray = 6
+
+	parquetRepetitionOptional = 1
+	parquetConvertedUTF8      = 0
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetCodecNone     = 0
+	parquetPageData      = 0
+)
+
+// Thrift compact protocol field types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// writeParquet writes a table as an uncompressed Parquet file with one row group. Columns
+// holding only numbers are DOUBLE; all others are UTF-8 strings. Every column is optional,
+// so empty cells are nulls.
+func writeParquet(w io.Writer, t *Table) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	type columnChunk struct {
+		column     string
+		kind       int32
+		offset     int64
+		size       int64
+		valueCount int64
+	}
+	chunks := make([]columnChunk, len(t.Columns))
+
+	for i, column := range t.Columns {
+		kind := int32(parquetTypeByteArray)
+		if t.numericColumn(column) {
+			kind = parquetTypeDouble
+		}
+
+		// Definition levels are 1 for values and 0 for nulls; only values are stored
+		levels := make([]int, len(t.Rows))
+		var values bytes.Buffer
+		for r, row := range t.Rows {
+			value := row[column]
+			if kind == parquetTypeDouble {
+				number, ok := value.(float64)
+				if !ok {
+					continue
+				}
+				binary.Write(&values, binary.LittleEndian, math.Float64bits(number))
+			} else {
+				if value == nil {
+					continue
+				}
+				text := CellString(value)
+				binary.Write(&values, binary.LittleEndian, uint32(len(text)))
+				values.WriteString(text)
+			}
+			levels[r] = 1
+		}
+
+		encodedLevels := rleBitWidth1(levels)
+		var page bytes.Buffer
+		binary.Write(&page, binary.LittleEndian, uint32(len(encodedLevels)))
+		page.Write(encodedLevels)
+		page.Write(values.Bytes())
+
+		header := &thriftWriter{}
+		header.i32Field(1, parquetPageData)
+		header.i32Field(2, int32(page.Len()))
+		header.i32Field(3, int32(page.Len()))
+		header.structField(5, func() {
+			header.i32Field(1, int32(len(t.Rows)))
+			header.i32Field(2, parquetEncodingPlain)
+			header.i32Field(3, parquetEncodingRLE)
+			header.i32Field(4, parquetEncodingRLE)
+		})
+		header.stop()
+
+		chunks[i] = columnChunk{
+			column:     column,
+			kind:       kind,
+			offset:     int64(file.Len()),
+			size:       int64(header.buf.Len() + page.Len()),
+			valueCount: int64(len(t.Rows)),
+		}
+		file.Write(header.buf.Bytes())
+		file.Write(page.Bytes())
+	}
+
+	var totalSize int64
+	for _, chunk := range chunks {
+		totalSize += chunk.size
+	}
+
+	meta := &thriftWriter{}
+	meta.i32Field(1, 1)
+	meta.listField(2, thriftStruct, len(t.Columns)+1)
+	meta.structElement(func() {
+		meta.stringField(4, "schema")
+		meta.i32Field(5, int32(len(t.Columns)))
+	})
+	for _, chunk := range chunks {
+		chunk := chunk
+		meta.structElement(func() {
+			meta.i32Field(1, chunk.kind)
+			meta.i32Field(3, parquetRepetitionOptional)
+			meta.stringField(4, chunk.column)
+			if chunk.kind == parquetTypeByteArray {
+				meta.i32Field(6, parquetConvertedUTF8)
+			}
+		})
+	}
+	meta.i64Field(3, int64(len(t.Rows)))
+	meta.listField(4, thriftStruct, 1)
+	meta.structElement(func() {
+		meta.listField(1, thriftStruct, len(chunks))
+		for _, chunk := range chunks {
+			chunk := chunk
+			meta.structElement(func() {
+				meta.i64Field(2, chunk.offset)
+				meta.structField(3, func() {
+					meta.i32Field(1, chunk.kind)
+					meta.listField(2, thriftI32, 2)
+					meta.varint(zigzag(parquetEncodingPlain))
+					meta.varint(zigzag(parquetEncodingRLE))
+					meta.listField(3, thriftBinary, 1)
+					meta.binary(chunk.column)
+					meta.i32Field(4, parquetCodecNone)
+					meta.i64Field(5, chunk.valueCount)
+					meta.i64Field(6, chunk.size)
+					meta.i64Field(7, chunk.size)
+					meta.i64Field(9, chunk.offset)
+				})
+			})
+		}
+		meta.i64Field(2, totalSize)
+		meta.i64Field(3, int64(len(t.Rows)))
+	})
+	meta.stringField(6, "agenticflows")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// rleBitWidth1 encodes definition levels of bit width 1 as RLE runs
+func rleBitWidth1(levels []int) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		var header [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(header[:], uint64(j-i)<<1)
+		out.Write(header[:n])
+		out.WriteByte(byte(levels[i]))
+		i = j
+	}
+	return out.Bytes()
+}
+
+// thriftWriter encodes structs with the Thrift compact protocol
+type thriftWriter struct {
+	buf bytes.Buffer
+	// last holds the last field ID of each open struct
+	last []int16
+}
+
+// fieldHeader writes the header of a field, as a delta from the previous field ID when it fits
+func (tw *thriftWriter) fieldHeader(id int16, kind byte) {
+	if len(tw.last) == 0 {
+		tw.last = append(tw.last, 0)
+	}
+	last := &tw.last[len(tw.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		tw.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		tw.buf.WriteByte(kind)
+		tw.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (tw *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	tw.buf.Write(b[:n])
+}
+
+func (tw *thriftWriter) binary(s string) {
+	tw.varint(uint64(len(s)))
+	tw.buf.WriteString(s)
+}
+
+func (tw *thriftWriter) i32Field(id int16, v int32) {
+	tw.fieldHeader(id, thriftI32)
+	tw.varint(zigzag(int64(v)))
+}
+
+func (tw *thriftWriter) i64Field(id int16, v int64) {
+	tw.fieldHeader(id, thriftI64)
+	tw.varint(zigzag(v))
+}
+
+func (tw *thriftWriter) stringField(id int16, s string) {
+	tw.fieldHeader(id, thriftBinary)
+	tw.binary(s)
+}
+
+// listField writes the header of a list field; its elements follow
+func (tw *thriftWriter) listField(id int16, elementType byte, size int) {
+	tw.fieldHeader(id, thriftList)
+	if size < 15 {
+		tw.buf.WriteByte(byte(size)<<4 | elementType)
+		return
+	}
+	tw.buf.WriteByte(0xF0 | elementType)
+	tw.varint(uint64(size))
+}
+
+// structField writes a struct field whose fields are written by body
+func (tw *thriftWriter) structField(id int16, body func()) {
+	tw.fieldHeader(id, thriftStruct)
+	tw.structElement(body)
+}
+
+// structElement writes a struct as a list element
+func (tw *thriftWriter) structElement(body func()) {
+	tw.last = append(tw.last, 0)
+	body()
+	tw.buf.WriteByte(0)
+	tw.last = tw.last[:len(tw.last)-1]
+}
+
+// stop ends the top-level struct
+func (tw *thriftWriter) stop() {
+	tw.buf.WriteByte(0)
+	tw.last = tw.last[:0]
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
@@ -0,0 +1,459 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"agenticflows/backend/analysis/models"
+)
+
+// Plan formats supported by WritePlan
+const (
+	PlanFormatJira      = "jira"
+	PlanFormatMSProject = "msproject"
+	PlanFormatICal      = "ical"
+)
+
+// Action horizons of an action plan
+const (
+	HorizonImmediate = "immediate"
+	HorizonShortTerm = "short_term"
+	HorizonLongTerm  = "long_term"
+)
+
+// defaultPhaseDays is the length of a phase whose duration can't be read
+const defaultPhaseDays = 14
+
+// ValidPlanFormat reports whether format is a supported plan format
+func ValidPlanFormat(format string) bool {
+	return format == PlanFormatJira || format == PlanFormatMSProject || format == PlanFormatICal
+}
+
+// PlanContentType returns the MIME type of a plan format
+func PlanContentType(format string) string {
+	switch format {
+	case PlanFormatMSProject:
+		return "application/xml"
+	case PlanFormatICal:
+		return "text/calendar"
+	default:
+		return "text/csv"
+	}
+}
+
+// PlanExtension returns the file extension of a plan format
+func PlanExtension(format string) string {
+	switch format {
+	case PlanFormatMSProject:
+		return "xml"
+	case PlanFormatICal:
+		return "ics"
+	default:
+		return "csv"
+	}
+}
+
+// ScheduledPhase is a phase of the timeline with dates
+type ScheduledPhase struct {
+	Name        string
+	Description string
+	Start       time.Time
+	// End is the last day of the phase
+	End          time.Time
+	Milestones   []string
+	Dependencies []string
+	Resources    []string
+}
+
+// ScheduledAction is an action of the plan, scheduled in the phase of its horizon
+type ScheduledAction struct {
+	models.ActionItem
+	Horizon string
+	// Phase is the index of the phase the action belongs to
+	Phase int
+	Start time.Time
+	Due   time.Time
+}
+
+// PlanSchedule is an action plan laid out on the calendar
+type PlanSchedule struct {
+	Name    string
+	Goals   []string
+	Start   time.Time
+	Phases  []ScheduledPhase
+	Actions []ScheduledAction
+}
+
+// durationPattern reads durations such as "2 weeks", "3-4 weeks" or "1 month"
+var durationPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)(?:\s*(?:-|to)\s*(\d+(?:\.\d+)?))?\s*(day|week|month|quarter|year)s?`)
+
+// phaseDays returns the number of days of a phase duration, the upper bound of a range,
+// or 0 when it can't be read
+func phaseDays(duration string) int {
+	match := durationPattern.FindStringSubmatch(duration)
+	if match == nil {
+		return 0
+	}
+	amount, _ := strconv.ParseFloat(match[1], 64)
+	if match[2] != "" {
+		amount, _ = strconv.ParseFloat(match[2], 64)
+	}
+	unit := map[string]float64{"day": 1, "week": 7, "month": 30, "quarter": 91, "year": 365}[strings.ToLower(match[3])]
+	return int(math.Ceil(amount * unit))
+}
+
+// SchedulePlan lays an action plan out on the calendar from start. Its phases follow
+// each other, taking their dates from the timeline when it has them and their duration
+// otherwise. A timeline, e.g. a generated one, replaces the plan's own. Immediate actions
+// are scheduled in the first phase, short-term actions in the second and long-term
+// actions in the last; a plan without a timeline gets one phase per horizon.
+func SchedulePlan(name string, plan models.ActionPlan, timeline []models.TimelineEvent, start time.Time) *PlanSchedule {
+	if len(timeline) == 0 {
+		timeline = plan.Timeline
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	schedule := &PlanSchedule{Name: name, Goals: plan.Goals, Start: start}
+
+	if len(timeline) == 0 {
+		for _, horizon := range []struct {
+			name  string
+			items []models.ActionItem
+		}{
+			{"Immediate actions", plan.ImmediateActions},
+			{"Short-term actions", plan.ShortTermActions},
+			{"Long-term actions", plan.LongTermActions},
+		} {
+			if len(horizon.items) > 0 {
+				timeline = append(timeline, models.TimelineEvent{Phase: horizon.name})
+			}
+		}
+	}
+
+	next := start
+	for _, event := range timeline {
+		phase := ScheduledPhase{
+			Name:         event.Phase,
+			Description:  event.Description,
+			Start:        next,
+			Milestones:   event.Milestones,
+			Dependencies: event.Dependencies,
+			Resources:    event.ResourcesRequired,
+		}
+		if date, err := time.Parse("2006-01-02", event.StartDate); err == nil {
+			phase.Start = date
+		}
+		days := phaseDays(event.Duration)
+		if days == 0 {
+			days = defaultPhaseDays
+		}
+		phase.End = phase.Start.AddDate(0, 0, days-1)
+		if date, err := time.Parse("2006-01-02", event.EndDate); err == nil && !date.Before(phase.Start) {
+			phase.End = date
+		}
+		schedule.Phases = append(schedule.Phases, phase)
+		next = phase.End.AddDate(0, 0, 1)
+	}
+
+	horizonPhase := func(horizon string) int {
+		switch last := len(schedule.Phases) - 1; {
+		case horizon == HorizonImmediate || last <= 0:
+			return 0
+		case horizon == HorizonShortTerm:
+			return 1
+		default:
+			return last
+		}
+	}
+	for _, horizon := range []struct {
+		name  string
+		items []models.ActionItem
+	}{
+		{HorizonImmediate, plan.ImmediateActions},
+		{HorizonShortTerm, plan.ShortTermActions},
+		{HorizonLongTerm, plan.LongTermActions},
+	} {
+		for _, item := range horizon.items {
+			action := ScheduledAction{ActionItem: item, Horizon: horizon.name, Phase: horizonPhase(horizon.name)}
+			if len(schedule.Phases) > 0 {
+				action.Start = schedule.Phases[action.Phase].Start
+				action.Due = schedule.Phases[action.Phase].End
+			}
+			schedule.Actions = append(schedule.Actions, action)
+		}
+	}
+	return schedule
+}
+
+// WritePlan writes a scheduled plan in the given format
+func WritePlan(w io.Writer, format string, schedule *PlanSchedule) error {
+	switch format {
+	case PlanFormatJira:
+		return writeJiraCSV(w, schedule)
+	case PlanFormatMSProject:
+		return writeMSProject(w, schedule)
+	case PlanFormatICal:
+		return writeICal(w, schedule)
+	default:
+		return fmt.Errorf("unsupported plan format %q", format)
+	}
+}
+
+// jiraPriority maps the priority of an action, where 5 is highest, to a Jira priority
+func jiraPriority(priority int) string {
+	switch {
+	case priority >= 5:
+		return "Highest"
+	case priority == 4:
+		return "High"
+	case priority == 3:
+		return "Medium"
+	case priority == 2:
+		return "Low"
+	case priority == 1:
+		return "Lowest"
+	default:
+		return ""
+	}
+}
+
+// actionDescription describes an action with its effort, owner and dependencies
+func actionDescription(action ScheduledAction) string {
+	parts := []string{action.Description}
+	if action.EstimatedEffort != "" {
+		parts = append(parts, "Estimated effort: "+action.EstimatedEffort)
+	}
+	if action.ResponsibleRole != "" {
+		parts = append(parts, "Responsible: "+action.ResponsibleRole)
+	}
+	if len(action.Dependencies) > 0 {
+		parts = append(parts, "Depends on: "+strings.Join(action.Dependencies, "; "))
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
+
+// jiraColumns are the columns of the Jira CSV import. Issue Id and Parent Id link the
+// actions and milestones to the epic of their phase.
+var jiraColumns = []string{"Issue Id", "Parent Id", "Issue Type", "Summary", "Epic Name", "Description", "Priority", "Labels", "Start Date", "Due Date"}
+
+// writeJiraCSV writes a plan as a CSV Jira imports: an epic per phase with its actions
+// and milestones as tasks
+func writeJiraCSV(w io.Writer, schedule *PlanSchedule) error {
+	t := &Table{Columns: jiraColumns}
+	phaseIDs := make([]string, len(schedule.Phases))
+	for i, phase := range schedule.Phases {
+		phaseIDs[i] = strconv.Itoa(i + 1)
+		t.Rows = append(t.Rows, map[string]interface{}{
+			"Issue Id":    phaseIDs[i],
+			"Issue Type":  "Epic",
+			"Summary":     phase.Name,
+			"Epic Name":   phase.Name,
+			"Description": phase.Description,
+			"Start Date":  phase.Start.Format("2006-01-02"),
+			"Due Date":    phase.End.Format("2006-01-02"),
+		})
+	}
+
+	id := len(schedule.Phases)
+	for _, action := range schedule.Actions {
+		id++
+		row := map[string]interface{}{
+			"Issue Id":    strconv.Itoa(id),
+			"Issue Type":  "Task",
+			"Summary":     action.Action,
+			"Description": actionDescription(action),
+			"Priority":    jiraPriority(action.Priority),
+			"Labels":      action.Horizon,
+		}
+		if len(schedule.Phases) > 0 {
+			row["Parent Id"] = phaseIDs[action.Phase]
+			row["Start Date"] = action.Start.Format("2006-01-02")
+			row["Due Date"] = action.Due.Format("2006-01-02")
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	for i, phase := range schedule.Phases {
+		for _, milestone := range phase.Milestones {
+			id++
+			t.Rows = append(t.Rows, map[string]interface{}{
+				"Issue Id":   strconv.Itoa(id),
+				"Parent Id":  phaseIDs[i],
+				"Issue Type": "Task",
+				"Summary":    milestone,
+				"Labels":     "milestone",
+				"Due Date":   phase.End.Format("2006-01-02"),
+			})
+		}
+	}
+	return writeCSV(w, t)
+}
+
+// msProjectTime formats a time of day on a date as Microsoft Project does
+func msProjectTime(date time.Time, hour int) string {
+	return date.Add(time.Duration(hour) * time.Hour).Format("2006-01-02T15:04:05")
+}
+
+// workingDays counts the weekdays from start to end, both included
+func workingDays(start, end time.Time) int {
+	days := 0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			days++
+		}
+	}
+	if days == 0 {
+		days = 1
+	}
+	return days
+}
+
+// writeMSProject writes a plan as Microsoft Project XML: a summary task per phase with
+// its actions and milestones below it. Dependencies that name another phase or action
+// become finish-to-start links.
+func writeMSProject(w io.Writer, schedule *PlanSchedule) error {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sb.WriteString(`<Project xmlns="http://schemas.microsoft.com/project">` + "\n")
+	fmt.Fprintf(&sb, "  <Name>%s</Name>\n  <Title>%s</Title>\n", xmlEscape(schedule.Name), xmlEscape(schedule.Name))
+	fmt.Fprintf(&sb, "  <StartDate>%s</StartDate>\n", msProjectTime(schedule.Start, 8))
+	sb.WriteString("  <Tasks>\n")
+
+	// Tasks are numbered phase by phase, so every name can be linked before writing
+	type task struct {
+		name, notes  string
+		outline      string
+		level        int
+		start, end   time.Time
+		summary      bool
+		milestone    bool
+		priority     int
+		dependencies []string
+	}
+	var tasks []task
+	for i, phase := range schedule.Phases {
+		tasks = append(tasks, task{
+			name: phase.Name, notes: phase.Description, outline: strconv.Itoa(i + 1), level: 1,
+			start: phase.Start, end: phase.End, summary: true, dependencies: phase.Dependencies,
+		})
+		child := 0
+		for _, action := range schedule.Actions {
+			if action.Phase != i {
+				continue
+			}
+			child++
+			tasks = append(tasks, task{
+				name: action.Action, notes: actionDescription(action), outline: fmt.Sprintf("%d.%d", i+1, child), level: 2,
+				start: action.Start, end: action.Due, priority: action.Priority, dependencies: action.Dependencies,
+			})
+		}
+		for _, milestone := range phase.Milestones {
+			child++
+			tasks = append(tasks, task{
+				name: milestone, outline: fmt.Sprintf("%d.%d", i+1, child), level: 2,
+				start: phase.End, end: phase.End, milestone: true,
+			})
+		}
+	}
+	uids := map[string]int{}
+	for i, t := range tasks {
+		if key := normalizeName(t.name); key != "" {
+			if _, ok := uids[key]; !ok {
+				uids[key] = i + 1
+			}
+		}
+	}
+
+	for i, t := range tasks {
+		uid := i + 1
+		sb.WriteString("    <Task>\n")
+		fmt.Fprintf(&sb, "      <UID>%d</UID>\n      <ID>%d</ID>\n      <Name>%s</Name>\n", uid, uid, xmlEscape(t.name))
+		fmt.Fprintf(&sb, "      <OutlineNumber>%s</OutlineNumber>\n      <OutlineLevel>%d</OutlineLevel>\n", t.outline, t.level)
+		if t.milestone {
+			fmt.Fprintf(&sb, "      <Start>%s</Start>\n      <Finish>%s</Finish>\n", msProjectTime(t.end, 17), msProjectTime(t.end, 17))
+			sb.WriteString("      <Duration>PT0H0M0S</Duration>\n      <Milestone>1</Milestone>\n")
+		} else {
+			fmt.Fprintf(&sb, "      <Start>%s</Start>\n      <Finish>%s</Finish>\n", msProjectTime(t.start, 8), msProjectTime(t.end, 17))
+			fmt.Fprintf(&sb, "      <Duration>PT%dH0M0S</Duration>\n      <Milestone>0</Milestone>\n", 8*workingDays(t.start, t.end))
+		}
+		sb.WriteString("      <DurationFormat>7</DurationFormat>\n")
+		if t.summary {
+			sb.WriteString("      <Summary>1</Summary>\n")
+		} else {
+			sb.WriteString("      <Summary>0</Summary>\n")
+		}
+		if t.priority > 0 {
+			// Project priorities run from 0 to 1000 with 500 as normal
+			fmt.Fprintf(&sb, "      <Priority>%d</Priority>\n", min(max(t.priority, 1), 5)*200-100)
+		}
+		if t.notes != "" {
+			fmt.Fprintf(&sb, "      <Notes>%s</Notes>\n", xmlEscape(t.notes))
+		}
+		for _, dependency := range t.dependencies {
+			if predecessor, ok := uids[normalizeName(dependency)]; ok && predecessor != uid {
+				fmt.Fprintf(&sb, "      <PredecessorLink>\n        <PredecessorUID>%d</PredecessorUID>\n        <Type>1</Type>\n      </PredecessorLink>\n", predecessor)
+			}
+		}
+		sb.WriteString("    </Task>\n")
+	}
+
+	sb.WriteString("  </Tasks>\n</Project>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeICal writes the milestones of a plan as all-day iCalendar events on the last day
+// of their phase. A phase without milestones is marked by its completion.
+func writeICal(w io.Writer, schedule *PlanSchedule) error {
+	var sb strings.Builder
+	line := func(content string) {
+		// Lines longer than 75 octets are folded with a leading space
+		for len(content) > 75 {
+			cut := 75
+			for cut > 0 && !utf8.RuneStart(content[cut]) {
+				cut--
+			}
+			sb.WriteString(content[:cut] + "\r\n")
+			content = " " + content[cut:]
+		}
+		sb.WriteString(content + "\r\n")
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//agenticflows//Action Plan//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + icalEscape(schedule.Name))
+	for i, phase := range schedule.Phases {
+		milestones := phase.Milestones
+		if len(milestones) == 0 {
+			milestones = []string{phase.Name + " complete"}
+		}
+		for j, milestone := range milestones {
+			line("BEGIN:VEVENT")
+			line(fmt.Sprintf("UID:%s-%d-%d@agenticflows", schedule.Start.Format("20060102"), i+1, j+1))
+			line("DTSTAMP:" + stamp)
+			line("DTSTART;VALUE=DATE:" + phase.End.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + phase.End.AddDate(0, 0, 1).Format("20060102"))
+			line("SUMMARY:" + icalEscape(milestone))
+			line("DESCRIPTION:" + icalEscape("Phase: "+phase.Name))
+			line("CATEGORIES:Milestone")
+			line("TRANSP:TRANSPARENT")
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// icalEscape escapes text for an iCalendar property value
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,228 @@
+// Package export flattens stored analysis results into tables and writes them as CSV,
+// Excel (xlsx) and Parquet files
+package export
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats supported by Write
+const (
+	FormatCSV     = "csv"
+	FormatXLSX    = "xlsx"
+	FormatParquet = "parquet"
+)
+
+// baseColumns identify the stored result every row comes from
+var baseColumns = []string{"result_id", "workflow_id", "analysis_type", "created_at", "result_confidence", "section"}
+
+// Table is a flattened set of analysis results. Cells hold strings, float64s, bools,
+// lists of scalars or nil.
+type Table struct {
+	Columns []string
+	Rows    []map[string]interface{}
+
+	seen map[string]bool
+}
+
+// Flatten turns stored analysis results, as returned by db.GetAnalysisResultsByWorkflow,
+// into a table. Every list of objects in a result, such as trends, patterns or attribute
+// values, becomes one row per item, with its section named by the path of the list
+// ("trends", "conversations.attribute_values"); the remaining fields of the result form
+// a row of section "result". Nested objects become dotted columns ("trend.magnitude"),
+// and rows of nested lists repeat the identifying fields of their parent item, so
+// attribute values keep their conversation_id.
+func Flatten(results []map[string]interface{}) *Table {
+	t := &Table{seen: map[string]bool{}}
+	for _, column := range baseColumns {
+		t.addColumn(column)
+	}
+
+	for _, result := range results {
+		context := map[string]interface{}{
+			"result_id":         result["id"],
+			"workflow_id":       result["workflow_id"],
+			"analysis_type":     result["analysis_type"],
+			"created_at":        result["created_at"],
+			"result_confidence": result["confidence"],
+		}
+		if suppressed, ok := result["suppressed_groups"].(int); ok {
+			context["suppressed_groups"] = float64(suppressed)
+			t.addColumn("suppressed_groups")
+		}
+		if reason, ok := result["flag_reason"].(string); ok {
+			context["flag_reason"] = reason
+			t.addColumn("flag_reason")
+		}
+		body, _ := result["results"].(map[string]interface{})
+		t.addObject(context, "", body)
+	}
+	return t
+}
+
+// childList is a list of objects found in an object, exported as rows of its own section
+type childList struct {
+	path  string
+	items []interface{}
+}
+
+// addObject adds the row of an object and the rows of the lists of objects it holds
+func (t *Table) addObject(context map[string]interface{}, section string, object map[string]interface{}) {
+	row := make(map[string]interface{}, len(context)+len(object)+1)
+	for column, value := range context {
+		row[column] = value
+	}
+	row["section"] = section
+	if section == "" {
+		row["section"] = "result"
+	}
+
+	own := map[string]interface{}{}
+	var children []childList
+	flattenFields(own, &children, "", object)
+
+	if len(own) > 0 || len(children) == 0 {
+		for _, column := range sortedKeys(own) {
+			row[column] = own[column]
+			t.addColumn(column)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	// Rows of nested lists repeat the fields identifying the item they belong to
+	childContext := context
+	if section != "" {
+		childContext = make(map[string]interface{}, len(context)+1)
+		for column, value := range context {
+			childContext[column] = value
+		}
+		for column, value := range own {
+			if identifyingField(column) {
+				childContext[column] = value
+			}
+		}
+	}
+	for _, child := range children {
+		path := child.path
+		if section != "" {
+			path = section + "." + child.path
+		}
+		for _, item := range child.items {
+			t.addObject(childContext, path, item.(map[string]interface{}))
+		}
+	}
+}
+
+// flattenFields copies the fields of an object into row, naming nested fields with dotted
+// paths, and collects its lists of objects
+func flattenFields(row map[string]interface{}, children *[]childList, prefix string, object map[string]interface{}) {
+	for _, key := range sortedKeys(object) {
+		column := key
+		if prefix != "" {
+			column = prefix + "." + key
+		}
+		switch value := object[key].(type) {
+		case map[string]interface{}:
+			flattenFields(row, children, column, value)
+		case []interface{}:
+			if isObjectList(value) {
+				*children = append(*children, childList{path: column, items: value})
+			} else if len(value) > 0 {
+				row[column] = value
+			}
+		default:
+			row[column] = value
+		}
+	}
+}
+
+// identifyingField reports whether a field identifies the item holding it, such as
+// conversation_id or field_name
+func identifyingField(column string) bool {
+	switch column {
+	case "id", "name", "label", "field_name":
+		return true
+	}
+	return strings.HasSuffix(column, "_id")
+}
+
+// isObjectList reports whether list is a non-empty list of objects
+func isObjectList(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// addColumn adds a column the first time it is seen
+func (t *Table) addColumn(column string) {
+	if !t.seen[column] {
+		t.seen[column] = true
+		t.Columns = append(t.Columns, column)
+	}
+}
+
+// sortedKeys returns the keys of a map in order, so exports have a stable column order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CellString formats a cell as text: numbers without trailing zeros, lists of scalars
+// joined with "; " and anything else as JSON
+func CellString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				encoded, _ := json.Marshal(v)
+				return string(encoded)
+			}
+			parts[i] = CellString(item)
+		}
+		return strings.Join(parts, "; ")
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+}
+
+// numericColumn reports whether every non-empty cell of a column is a number
+func (t *Table) numericColumn(column string) bool {
+	numeric := false
+	for _, row := range t.Rows {
+		switch row[column].(type) {
+		case nil:
+		case float64:
+			numeric = true
+		default:
+			return false
+		}
+	}
+	return numeric
+}
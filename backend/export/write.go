@@ -0,0 +1,150 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxXLSXCellLength is the longest text an Excel cell holds
+const maxXLSXCellLength = 32767
+
+// ValidFormat reports whether format is supported
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX || format == FormatParquet
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv"
+	}
+}
+
+// Write writes a table in the given format
+func Write(w io.Writer, format string, t *Table) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, t)
+	case FormatXLSX:
+		return writeXLSX(w, t)
+	case FormatParquet:
+		return writeParquet(w, t)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// writeCSV writes a table as CSV with a header row
+func writeCSV(w io.Writer, t *Table) error {
+	writer := csv.NewWriter(w)
+	writer.Write(t.Columns)
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, column := range t.Columns {
+			record[i] = CellString(row[column])
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// xlsxParts are the static parts of a workbook with one worksheet
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Results" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+// writeXLSX writes a table as an Excel workbook with a single "Results" sheet. Numbers
+// and booleans keep their cell types; everything else is stored as text.
+func writeXLSX(w io.Writer, t *Table) error {
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make(map[string]interface{}, len(t.Columns))
+	for _, column := range t.Columns {
+		header[column] = column
+	}
+	rows := append([]map[string]interface{}{header}, t.Rows...)
+	for r, row := range rows {
+		fmt.Fprintf(&sb, `<row r="%d">`, r+1)
+		for c, column := range t.Columns {
+			ref := xlsxColumnName(c) + strconv.Itoa(r+1)
+			switch value := row[column].(type) {
+			case nil:
+			case float64:
+				fmt.Fprintf(&sb, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(value, 'g', -1, 64))
+			case bool:
+				b := 0
+				if value {
+					b = 1
+				}
+				fmt.Fprintf(&sb, `<c r="%s" t="b"><v>%d</v></c>`, ref, b)
+			default:
+				text := CellString(value)
+				if len(text) > maxXLSXCellLength {
+					text = strings.ToValidUTF8(text[:maxXLSXCellLength], "")
+				}
+				fmt.Fprintf(&sb, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+				xml.EscapeText(&sb, []byte(text))
+				sb.WriteString(`</t></is></c>`)
+			}
+		}
+		sb.WriteString(`</row>`)
+
+		// Flush every few rows so large exports are not built in memory
+		if sb.Len() > 1<<16 {
+			if _, err := io.WriteString(sheet, sb.String()); err != nil {
+				return err
+			}
+			sb.Reset()
+		}
+	}
+	sb.WriteString(`</sheetData></worksheet>`)
+	if _, err := io.WriteString(sheet, sb.String()); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// xlsxColumnName returns the letters of a zero-based column index: A, B, ..., Z, AA, ...
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Environment variables configuring the notification integrations
+const (
+	// EnvWebhookURL receives alerts as JSON
+	EnvWebhookURL = "ALERT_WEBHOOK_URL"
+	// EnvSlackWebhookURL is a Slack incoming webhook receiving alerts as messages
+	EnvSlackWebhookURL = "ALERT_SLACK_WEBHOOK_URL"
+)
+
+// Alert severities
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+	SeverityResolved = "resolved"
+)
+
+// Alert is a notification about something that needs attention
+type Alert struct {
+	Title      string                 `json:"title"`
+	Message    string                 `json:"message"`
+	Severity   string                 `json:"severity"`
+	WorkflowID string                 `json:"workflow_id,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	Time       time.Time              `json:"time"`
+}
+
+// Notifier delivers alerts to a notification integration
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// FromEnv returns the notifiers configured in the environment, or nil if none are
+func FromEnv() Notifier {
+	var notifiers Multi
+	if url := strings.TrimSpace(os.Getenv(EnvWebhookURL)); url != "" {
+		notifiers = append(notifiers, &Webhook{URL: url})
+	}
+	if url := strings.TrimSpace(os.Getenv(EnvSlackWebhookURL)); url != "" {
+		notifiers = append(notifiers, &Slack{URL: url})
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notifiers
+}
+
+// Multi delivers alerts to several notifiers
+type Multi []Notifier
+
+// Notify delivers an alert to every notifier, returning their combined errors
+func (m Multi) Notify(ctx context.Context, alert Alert) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Webhook posts alerts as JSON to a URL
+type Webhook struct {
+	URL string
+}
+
+// Notify posts the alert
+func (n *Webhook) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.URL, alert)
+}
+
+// Slack posts alerts to a Slack incoming webhook
+type Slack struct {
+	URL string
+}
+
+// slackIcons prefix the messages of each severity
+var slackIcons = map[string]string{
+	SeverityWarning:  ":warning:",
+	SeverityCritical: ":rotating_light:",
+	SeverityResolved: ":white_check_mark:",
+}
+
+// Notify posts the alert as a message
+func (n *Slack) Notify(ctx context.Context, alert Alert) error {
+	text := fmt.Sprintf("*%s*\n%s", alert.Title, alert.Message)
+	if icon := slackIcons[alert.Severity]; icon != "" {
+		text = icon + " " + text
+	}
+	return postJSON(ctx, n.URL, map[string]string{"text": text})
+}
+
+// postJSON posts a JSON body and checks for a successful status
+func postJSON(ctx context.Context, url string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notification request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("notification returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
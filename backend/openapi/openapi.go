@@ -0,0 +1,346 @@
+// Package openapi describes the agenticflows API as an OpenAPI 3 document. The schemas
+// are generated from the Go types the handlers and the client package exchange, so the
+// document, the client and the server share one definition of every request and
+// response; Check compares the documented paths with the routes the server registers.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/models"
+	"agenticflows/backend/client"
+	"agenticflows/backend/db"
+)
+
+// Version is the version of the API the document describes
+const Version = "1.0.0"
+
+// Operation is one method on one path of the API. Request and Response are values of the
+// Go types of the JSON bodies, or nil when the body is not described.
+type Operation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Query    []string
+	Request  interface{}
+	Response interface{}
+	// Status is the status of a successful response, 200 when it is zero
+	Status int
+}
+
+// Operations lists every documented operation of the API
+var Operations = []Operation{
+	// Analysis
+	{Method: http.MethodPost, Path: "/api/analysis", Tag: "analysis", Summary: "Run an analysis", Request: models.StandardAnalysisRequest{}, Response: models.StandardAnalysisResponse{}},
+	{Method: http.MethodPost, Path: "/api/analysis/chain", Tag: "analysis", Summary: "Run analyses in sequence", Request: client.ChainRequest{}, Response: client.ChainResponse{}},
+	{Method: http.MethodGet, Path: "/api/analysis/chain/{run_id}/progress", Tag: "analysis", Summary: "Get the progress of a chain run", Response: client.ChainRun{}},
+	{Method: http.MethodPost, Path: "/api/analysis/batch", Tag: "analysis", Summary: "Run an analysis over many conversations", Request: models.BatchAnalysisRequest{}},
+	{Method: http.MethodPost, Path: "/api/analysis/explain", Tag: "analysis", Summary: "Explain a stored result", Request: models.ExplainRequest{}, Response: models.Explanation{}},
+	{Method: http.MethodGet, Path: "/api/analysis/jobs", Tag: "analysis", Summary: "List analysis jobs", Query: []string{"status", "workflow_id"}, Response: []db.AnalysisJob{}},
+	{Method: http.MethodPost, Path: "/api/analysis/jobs", Tag: "analysis", Summary: "Queue an analysis job", Request: models.StandardAnalysisRequest{}, Response: db.AnalysisJob{}, Status: http.StatusAccepted},
+	{Method: http.MethodGet, Path: "/api/analysis/jobs/{id}", Tag: "analysis", Summary: "Get an analysis job", Response: db.AnalysisJob{}},
+	{Method: http.MethodGet, Path: "/api/analysis/metadata", Tag: "analysis", Summary: "Describe the analysis types"},
+	{Method: http.MethodGet, Path: "/api/analysis/cache", Tag: "analysis", Summary: "Get analysis cache statistics", Response: db.AnalysisCacheStats{}},
+	{Method: http.MethodDelete, Path: "/api/analysis/cache", Tag: "analysis", Summary: "Clear the analysis cache"},
+	{Method: http.MethodGet, Path: "/api/analysis/quality", Tag: "analysis", Summary: "Get result quality flags"},
+	{Method: http.MethodGet, Path: "/api/analysis/results", Tag: "analysis", Summary: "List the results of a workflow", Query: []string{"workflow_id"}, Response: []map[string]interface{}{}},
+	{Method: http.MethodGet, Path: "/api/analysis/results/export", Tag: "analysis", Summary: "Export results", Query: []string{"workflow_id", "format"}},
+	{Method: http.MethodGet, Path: "/api/analysis/results/diff", Tag: "analysis", Summary: "Compare two stored results of a workflow", Query: []string{"workflow_id", "from", "to", "analysis_type"}, Response: analysis.ResultDiff{}},
+	{Method: http.MethodGet, Path: "/api/analysis/results/graph", Tag: "analysis", Summary: "Get the lineage graph of results", Query: []string{"workflow_id"}},
+	{Method: http.MethodPost, Path: "/api/analysis/plan/export", Tag: "analysis", Summary: "Export an action plan to Jira, Microsoft Project or iCalendar"},
+	{Method: http.MethodPost, Path: "/api/questions/answer", Tag: "analysis", Summary: "Answer a question from stored results"},
+
+	// Executive reports
+	{Method: http.MethodGet, Path: "/api/reports/{workflow_id}", Tag: "reports", Summary: "Render the stored results of a workflow as a Markdown, HTML or PDF report", Query: []string{"format", "title"}},
+
+	// Audit log of LLM calls
+	{Method: http.MethodGet, Path: "/api/audit", Tag: "audit", Summary: "List audited LLM calls", Query: []string{"request_id", "model", "schema", "since", "until", "limit"}},
+	{Method: http.MethodGet, Path: "/api/audit/{request_id}", Tag: "audit", Summary: "Get an audited request with its LLM calls"},
+	{Method: http.MethodPost, Path: "/api/audit/{request_id}/replay", Tag: "audit", Summary: "Replay an audited request and compare its LLM calls"},
+
+	// Search
+	{Method: http.MethodPost, Path: "/api/search/similar", Tag: "search", Summary: "Find similar conversations"},
+	{Method: http.MethodPost, Path: "/api/search/index", Tag: "search", Summary: "Index conversations for search"},
+	{Method: http.MethodPost, Path: "/api/search/topic", Tag: "search", Summary: "Find the conversations about a topic", Request: client.TopicSearchRequest{}, Response: client.TopicSearchResponse{}},
+
+	// Conversations
+	{Method: http.MethodGet, Path: "/api/conversations", Tag: "conversations", Summary: "List conversations", Query: []string{"customer_id", "source", "limit", "offset"}},
+	{Method: http.MethodPost, Path: "/api/conversations", Tag: "conversations", Summary: "Store conversations", Request: client.ConversationIngestRequest{}, Response: client.ConversationIngestResponse{}},
+	{Method: http.MethodPost, Path: "/api/conversations/upload", Tag: "conversations", Summary: "Upload a CSV or JSONL file of conversations, or start a chunked upload", Query: []string{"format", "filename", "source"}, Response: db.ConversationUpload{}, Status: http.StatusAccepted},
+	{Method: http.MethodGet, Path: "/api/conversations/upload", Tag: "conversations", Summary: "List conversation uploads", Query: []string{"limit"}},
+	{Method: http.MethodGet, Path: "/api/conversations/upload/{id}", Tag: "conversations", Summary: "Get the progress and validation report of an upload", Response: db.ConversationUpload{}},
+	{Method: http.MethodPut, Path: "/api/conversations/upload/{id}", Tag: "conversations", Summary: "Append a chunk to an upload", Response: db.ConversationUpload{}},
+	{Method: http.MethodPost, Path: "/api/conversations/upload/{id}/complete", Tag: "conversations", Summary: "Ingest a chunked upload", Response: db.ConversationUpload{}, Status: http.StatusAccepted},
+	{Method: http.MethodDelete, Path: "/api/conversations/upload/{id}", Tag: "conversations", Summary: "Cancel an upload", Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/conversations/{id}", Tag: "conversations", Summary: "Get a conversation", Response: db.Conversation{}},
+	{Method: http.MethodGet, Path: "/api/conversations/{id}/turns", Tag: "conversations", Summary: "Get the speaker turns of a conversation", Response: []models.Turn{}},
+	{Method: http.MethodGet, Path: "/api/conversations/{id}/attributes", Tag: "conversations", Summary: "Get the attributes of a conversation", Response: models.ConversationAttributes{}},
+	{Method: http.MethodGet, Path: "/api/conversations/{id}/attributes/revisions", Tag: "conversations", Summary: "Get the attribute revisions of a conversation", Response: []db.ConversationAttributeRevision{}},
+	{Method: http.MethodGet, Path: "/api/conversations/{id}/intents", Tag: "conversations", Summary: "Get the intent classifications of a conversation", Query: []string{"workflow_id"}, Response: []db.IntentClassification{}},
+	{Method: http.MethodGet, Path: "/api/intents/distribution", Tag: "conversations", Summary: "Get the distribution of stored intents over time", Query: []string{"workflow_id", "since", "until", "period"}, Response: []db.IntentPeriod{}},
+	{Method: http.MethodGet, Path: "/api/conversations/{id}/processing", Tag: "conversations", Summary: "Get the processing state of a conversation", Response: models.ConversationProcessing{}},
+	{Method: http.MethodGet, Path: "/api/customers/{id}/data", Tag: "conversations", Summary: "Export the data stored about a customer"},
+	{Method: http.MethodDelete, Path: "/api/customers/{id}/data", Tag: "conversations", Summary: "Delete the data stored about a customer", Response: db.CustomerDataDeletion{}},
+	{Method: http.MethodGet, Path: "/api/storage/tiering", Tag: "conversations", Summary: "Get the storage tiers of conversations"},
+	{Method: http.MethodPost, Path: "/api/storage/tiering", Tag: "conversations", Summary: "Move conversations between storage tiers", Response: db.TieringResult{}},
+
+	// Attributes
+	{Method: http.MethodGet, Path: "/api/attribute-sets", Tag: "attributes", Summary: "List attribute sets", Query: []string{"name"}, Response: []client.AttributeSet{}},
+	{Method: http.MethodPost, Path: "/api/attribute-sets", Tag: "attributes", Summary: "Create an attribute set", Request: client.AttributeSetRequest{}, Response: client.AttributeSet{}, Status: http.StatusCreated},
+	{Method: http.MethodPost, Path: "/api/attribute-sets/import", Tag: "attributes", Summary: "Import a JSON Schema as an attribute set", Query: []string{"name"}, Response: client.AttributeSet{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/attribute-sets/{id}", Tag: "attributes", Summary: "Get an attribute set", Query: []string{"version"}, Response: client.AttributeSet{}},
+	{Method: http.MethodPut, Path: "/api/attribute-sets/{id}", Tag: "attributes", Summary: "Store a new version of an attribute set", Request: client.AttributeSetRequest{}, Response: client.AttributeSet{}},
+	{Method: http.MethodDelete, Path: "/api/attribute-sets/{id}", Tag: "attributes", Summary: "Delete an attribute set", Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/attribute-sets/{id}/schema", Tag: "attributes", Summary: "Export an attribute set as JSON Schema", Query: []string{"version"}},
+	{Method: http.MethodGet, Path: "/api/attribute-sets/{id}/versions", Tag: "attributes", Summary: "List the versions of an attribute set", Response: []client.AttributeSet{}},
+	{Method: http.MethodGet, Path: "/api/attribute-flags", Tag: "attributes", Summary: "List flagged attribute values", Query: []string{"status"}, Response: []db.AttributeFlag{}},
+	{Method: http.MethodPost, Path: "/api/attribute-flags/{id}/resolve", Tag: "attributes", Summary: "Resolve a flagged attribute value", Response: db.AttributeFlag{}},
+
+	// Workflows
+	{Method: http.MethodGet, Path: "/api/workflows", Tag: "workflows", Summary: "List workflows", Response: []db.Workflow{}},
+	{Method: http.MethodPost, Path: "/api/workflows", Tag: "workflows", Summary: "Create a workflow", Request: client.Workflow{}, Response: db.Workflow{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/workflows/{id}", Tag: "workflows", Summary: "Get a workflow", Response: db.Workflow{}},
+	{Method: http.MethodPut, Path: "/api/workflows/{id}", Tag: "workflows", Summary: "Update a workflow", Request: client.Workflow{}, Response: db.Workflow{}},
+	{Method: http.MethodDelete, Path: "/api/workflows/{id}", Tag: "workflows", Summary: "Delete a workflow", Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/workflows/{id}/execute", Tag: "workflows", Summary: "Run a workflow", Request: client.WorkflowRunRequest{}, Response: map[string]interface{}{}},
+	{Method: http.MethodPost, Path: "/api/workflows/{id}/clone", Tag: "workflows", Summary: "Clone a workflow", Status: http.StatusCreated},
+	{Method: http.MethodPost, Path: "/api/workflows/{id}/undo", Tag: "workflows", Summary: "Undo the last change to a workflow", Response: db.Workflow{}},
+	{Method: http.MethodPost, Path: "/api/workflows/{id}/redo", Tag: "workflows", Summary: "Redo an undone change to a workflow", Response: db.Workflow{}},
+	{Method: http.MethodGet, Path: "/api/workflows/{id}/operations", Tag: "workflows", Summary: "List the changes to a workflow", Response: []db.WorkflowOperation{}},
+	{Method: http.MethodPost, Path: "/api/workflows/{id}/operations", Tag: "workflows", Summary: "Apply changes to a workflow", Response: db.Workflow{}},
+	{Method: http.MethodGet, Path: "/api/workflows/{id}/concurrency", Tag: "workflows", Summary: "Get the concurrency limits of a workflow", Response: db.WorkflowConcurrency{}},
+	{Method: http.MethodPut, Path: "/api/workflows/{id}/concurrency", Tag: "workflows", Summary: "Set the concurrency limits of a workflow", Request: db.WorkflowConcurrency{}, Response: db.WorkflowConcurrency{}},
+	{Method: http.MethodGet, Path: "/api/workflows/{id}/costs", Tag: "workflows", Summary: "Get the costs of a workflow", Response: db.WorkflowCosts{}},
+	{Method: http.MethodGet, Path: "/api/workflows/{id}/execution-config", Tag: "workflows", Summary: "Get the execution configuration of a workflow"},
+	{Method: http.MethodGet, Path: "/api/workflows/{id}/sla", Tag: "workflows", Summary: "Get the SLA of a workflow", Response: db.WorkflowSLA{}},
+	{Method: http.MethodPut, Path: "/api/workflows/{id}/sla", Tag: "workflows", Summary: "Set the SLA of a workflow", Request: db.WorkflowSLA{}, Response: db.WorkflowSLA{}},
+	{Method: http.MethodDelete, Path: "/api/workflows/{id}/sla", Tag: "workflows", Summary: "Remove the SLA of a workflow", Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/workflows/{id}/runs", Tag: "workflows", Summary: "List the runs of a workflow", Query: []string{"status", "limit"}, Response: []db.Run{}},
+	{Method: http.MethodGet, Path: "/api/runs/{run_id}", Tag: "workflows", Summary: "Get a run with its node outputs and results", Response: db.Run{}},
+	{Method: http.MethodGet, Path: "/api/workflows/{id}/sla/runs", Tag: "workflows", Summary: "List the SLA runs of a workflow", Response: []db.SLARun{}},
+	{Method: http.MethodPost, Path: "/api/workflows/{id}/nodes/{nodeId}/test", Tag: "workflows", Summary: "Test one node of a workflow"},
+	{Method: http.MethodPost, Path: "/api/workflows/generate", Tag: "workflows", Summary: "Generate a workflow from a description"},
+	{Method: http.MethodPost, Path: "/api/workflows/generate-dynamic", Tag: "workflows", Summary: "Generate a workflow from the available tools"},
+	{Method: http.MethodGet, Path: "/api/scratch", Tag: "workflows", Summary: "List scratch sessions", Response: []db.ScratchSession{}},
+	{Method: http.MethodPost, Path: "/api/scratch", Tag: "workflows", Summary: "Start a scratch session", Response: db.ScratchSession{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/scratch/{id}", Tag: "workflows", Summary: "Get a scratch session with its runs and results"},
+	{Method: http.MethodDelete, Path: "/api/scratch/{id}", Tag: "workflows", Summary: "Delete a scratch session", Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/scratch/{id}/execute", Tag: "workflows", Summary: "Run an ad-hoc workflow in a scratch session", Response: db.ScratchRun{}},
+	{Method: http.MethodGet, Path: "/api/pipelines", Tag: "workflows", Summary: "List pipelines", Response: []db.Pipeline{}},
+	{Method: http.MethodPost, Path: "/api/pipelines", Tag: "workflows", Summary: "Create a pipeline", Request: db.Pipeline{}, Response: db.Pipeline{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/pipelines/{id}", Tag: "workflows", Summary: "Get a pipeline", Response: db.Pipeline{}},
+	{Method: http.MethodPut, Path: "/api/pipelines/{id}", Tag: "workflows", Summary: "Update a pipeline", Request: db.Pipeline{}, Response: db.Pipeline{}},
+	{Method: http.MethodDelete, Path: "/api/pipelines/{id}", Tag: "workflows", Summary: "Delete a pipeline", Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/pipelines/{id}/execute", Tag: "workflows", Summary: "Run a pipeline"},
+	{Method: http.MethodGet, Path: "/api/canaries", Tag: "workflows", Summary: "List canaries", Response: []db.Canary{}},
+	{Method: http.MethodPost, Path: "/api/canaries", Tag: "workflows", Summary: "Start a canary", Request: db.Canary{}, Response: db.Canary{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/canaries/{id}", Tag: "workflows", Summary: "Get a canary", Response: db.Canary{}},
+	{Method: http.MethodPut, Path: "/api/canaries/{id}", Tag: "workflows", Summary: "Update a canary", Request: db.Canary{}, Response: db.Canary{}},
+	{Method: http.MethodPost, Path: "/api/canaries/{id}/promote", Tag: "workflows", Summary: "Promote a canary", Response: db.Canary{}},
+	{Method: http.MethodPost, Path: "/api/canaries/{id}/rollback", Tag: "workflows", Summary: "Roll back a canary", Response: db.Canary{}},
+	{Method: http.MethodGet, Path: "/api/reviews", Tag: "workflows", Summary: "List the reviews of paused workflow runs", Query: []string{"status", "workflow_id", "limit"}, Response: []db.WorkflowReview{}},
+	{Method: http.MethodGet, Path: "/api/reviews/{id}", Tag: "workflows", Summary: "Get a review", Response: db.WorkflowReview{}},
+	{Method: http.MethodPost, Path: "/api/reviews/{id}/approve", Tag: "workflows", Summary: "Approve a review and resume its run"},
+	{Method: http.MethodPost, Path: "/api/reviews/{id}/edit", Tag: "workflows", Summary: "Edit the result of a review and resume its run"},
+	{Method: http.MethodPost, Path: "/api/reviews/{id}/reject", Tag: "workflows", Summary: "Reject a review and end its run"},
+	{Method: http.MethodGet, Path: "/api/schedules/health", Tag: "workflows", Summary: "Get the health of scheduled runs"},
+	{Method: http.MethodGet, Path: "/api/slas", Tag: "workflows", Summary: "Get the SLA compliance of all workflows", Response: []db.SLACompliance{}},
+
+	// Prompt templates
+	{Method: http.MethodGet, Path: "/api/prompt-templates", Tag: "prompt-templates", Summary: "List prompt templates", Response: []db.PromptTemplate{}},
+	{Method: http.MethodPost, Path: "/api/prompt-templates", Tag: "prompt-templates", Summary: "Create a prompt template version", Request: db.PromptTemplate{}, Response: db.PromptTemplate{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/prompt-templates/{ref}", Tag: "prompt-templates", Summary: "Get a prompt template", Response: db.PromptTemplate{}},
+	{Method: http.MethodDelete, Path: "/api/prompt-templates/{ref}", Tag: "prompt-templates", Summary: "Delete a prompt template", Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/prompt-templates/{ref}/activate", Tag: "prompt-templates", Summary: "Activate a prompt template version", Response: db.PromptTemplate{}},
+	{Method: http.MethodDelete, Path: "/api/prompt-templates/{ref}/activate", Tag: "prompt-templates", Summary: "Deactivate a prompt template", Status: http.StatusNoContent},
+
+	// Integrations
+	{Method: http.MethodGet, Path: "/api/webhooks", Tag: "integrations", Summary: "List webhook subscriptions", Response: []db.WebhookSubscription{}},
+	{Method: http.MethodPost, Path: "/api/webhooks", Tag: "integrations", Summary: "Subscribe a webhook", Request: db.WebhookSubscription{}, Response: db.WebhookSubscription{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/webhooks/{id}", Tag: "integrations", Summary: "Get a webhook subscription", Response: db.WebhookSubscription{}},
+	{Method: http.MethodPut, Path: "/api/webhooks/{id}", Tag: "integrations", Summary: "Update a webhook subscription", Request: db.WebhookSubscription{}, Response: db.WebhookSubscription{}},
+	{Method: http.MethodDelete, Path: "/api/webhooks/{id}", Tag: "integrations", Summary: "Delete a webhook subscription", Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/widgets", Tag: "integrations", Summary: "List widgets", Response: []db.Widget{}},
+	{Method: http.MethodPost, Path: "/api/widgets", Tag: "integrations", Summary: "Share a widget", Request: db.Widget{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/widgets/{id}", Tag: "integrations", Summary: "Get a widget", Response: db.Widget{}},
+	{Method: http.MethodDelete, Path: "/api/widgets/{id}", Tag: "integrations", Summary: "Delete a widget", Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/widgets/{id}/rotate", Tag: "integrations", Summary: "Rotate the token of a widget"},
+	{Method: http.MethodGet, Path: "/api/widgets/{id}/data", Tag: "integrations", Summary: "Get the data of a shared widget", Query: []string{"token"}},
+	{Method: http.MethodGet, Path: "/api/agents", Tag: "integrations", Summary: "List agents", Response: []db.Agent{}},
+	{Method: http.MethodGet, Path: "/api/tools", Tag: "integrations", Summary: "List tools", Response: []db.Tool{}},
+	{Method: http.MethodPost, Path: "/api/tools/install", Tag: "integrations", Summary: "Install a tool manifest", Request: db.ToolManifest{}, Response: db.InstalledManifest{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/tools/manifests", Tag: "integrations", Summary: "List installed tool manifests", Response: []db.InstalledManifest{}},
+	{Method: http.MethodGet, Path: "/api/tools/manifests/{namespace}/{name}", Tag: "integrations", Summary: "Get an installed tool manifest", Response: db.InstalledManifest{}},
+	{Method: http.MethodDelete, Path: "/api/tools/manifests/{namespace}/{name}", Tag: "integrations", Summary: "Uninstall a tool manifest", Status: http.StatusNoContent},
+
+	// Privacy
+	{Method: http.MethodPost, Path: "/api/pii/redact", Tag: "privacy", Summary: "Redact PII from a text", Request: client.PIIRedactRequest{}, Response: client.PIIRedactResponse{}},
+	{Method: http.MethodPost, Path: "/api/pseudonyms/resolve", Tag: "privacy", Summary: "Resolve pseudonyms to the original values"},
+
+	// Workspace
+	{Method: http.MethodGet, Path: "/api/auth", Tag: "workspace", Summary: "Get the authentication status"},
+	{Method: http.MethodGet, Path: "/api/auth/keys", Tag: "workspace", Summary: "List API keys", Response: []db.APIKey{}},
+	{Method: http.MethodPost, Path: "/api/auth/keys", Tag: "workspace", Summary: "Issue an API key", Status: http.StatusCreated},
+	{Method: http.MethodDelete, Path: "/api/auth/keys/{id}", Tag: "workspace", Summary: "Revoke an API key", Response: db.APIKey{}},
+	{Method: http.MethodGet, Path: "/api/workspace/defaults", Tag: "workspace", Summary: "Get the workspace defaults", Response: db.WorkspaceDefaults{}},
+	{Method: http.MethodPut, Path: "/api/workspace/defaults", Tag: "workspace", Summary: "Set the workspace defaults", Request: db.WorkspaceDefaults{}, Response: db.WorkspaceDefaults{}},
+	{Method: http.MethodDelete, Path: "/api/workspace/defaults", Tag: "workspace", Summary: "Reset the workspace defaults", Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/scheduler", Tag: "workspace", Summary: "Get the LLM call limits with the calls running and queued per workflow", Response: db.SchedulerSettings{}},
+	{Method: http.MethodPut, Path: "/api/scheduler", Tag: "workspace", Summary: "Set the LLM call limits", Request: db.SchedulerSettings{}, Response: db.SchedulerSettings{}},
+	{Method: http.MethodDelete, Path: "/api/scheduler", Tag: "workspace", Summary: "Revert the LLM call limits to the environment", Response: db.SchedulerSettings{}},
+	{Method: http.MethodGet, Path: "/api/usage", Tag: "workspace", Summary: "Get LLM usage and costs", Query: []string{"group_by", "from", "to"}},
+	{Method: http.MethodGet, Path: "/api/activity", Tag: "workspace", Summary: "List activity", Response: []db.Activity{}},
+	{Method: http.MethodPost, Path: "/api/activity", Tag: "workspace", Summary: "Record activity", Request: db.Activity{}, Response: db.Activity{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/lineage", Tag: "workspace", Summary: "Get the lineage of a result", Response: []db.LineageEdge{}},
+	{Method: http.MethodPost, Path: "/api/lineage", Tag: "workspace", Summary: "Record lineage", Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/demo", Tag: "workspace", Summary: "Get the demo data"},
+	{Method: http.MethodGet, Path: "/api/openapi.json", Tag: "workspace", Summary: "Get this document"},
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Document returns the OpenAPI 3 document of the API served at serverURL
+func Document(serverURL string) map[string]interface{} {
+	schemas := newSchemaSet()
+	paths := map[string]interface{}{}
+	tags := map[string]bool{}
+
+	for _, op := range Operations {
+		tags[op.Tag] = true
+		var parameters []interface{}
+		for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, name := range op.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "query", "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := map[string]interface{}{"description": http.StatusText(status)}
+		if schema := schemas.schemaOf(op.Response); schema != nil {
+			response["content"] = jsonContent(schema)
+		}
+		operation := map[string]interface{}{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"responses": map[string]interface{}{
+				strconv.Itoa(status): response,
+				"default":            map[string]interface{}{"description": "Error"},
+			},
+		}
+		if parameters != nil {
+			operation["parameters"] = parameters
+		}
+		if schema := schemas.schemaOf(op.Request); schema != nil {
+			operation["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(schema)}
+		}
+
+		item, _ := paths[op.Path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	tagNames := make([]string, 0, len(tags))
+	for tag := range tags {
+		tagNames = append(tagNames, tag)
+	}
+	sort.Strings(tagNames)
+	tagList := make([]interface{}, len(tagNames))
+	for i, tag := range tagNames {
+		tagList[i] = map[string]interface{}{"name": tag}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "agenticflows API",
+			"description": "Conversation analysis and workflow API. Requests authenticate with an API key in X-API-Key when authentication is enabled.",
+			"version":     Version,
+		},
+		"servers": []interface{}{map[string]interface{}{"url": serverURL}},
+		"tags":    tagList,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"apiKey": []string{}}},
+	}
+}
+
+// JSON returns the document encoded as indented JSON
+func JSON(serverURL string) ([]byte, error) {
+	return json.MarshalIndent(Document(serverURL), "", "  ")
+}
+
+// Check compares the documented paths with the route patterns a server registers on an
+// http.ServeMux. It returns the patterns no operation documents and the documented paths
+// no pattern routes.
+func Check(patterns []string) (undocumented, unrouted []string) {
+	routed := map[string]bool{}
+	for _, pattern := range patterns {
+		documented := false
+		for _, op := range Operations {
+			if routes(pattern, op.Path) {
+				documented = true
+				routed[op.Path] = true
+			}
+		}
+		if !documented {
+			undocumented = append(undocumented, pattern)
+		}
+	}
+	for _, op := range Operations {
+		if !routed[op.Path] {
+			unrouted = append(unrouted, op.Path)
+			routed[op.Path] = true
+		}
+	}
+	return undocumented, unrouted
+}
+
+// routes reports whether a ServeMux pattern routes a documented path: patterns ending in
+// a slash match every path below them, others only themselves. A pattern that shadows a
+// longer pattern still counts, since only routing is checked.
+func routes(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern)
+	}
+	return path == pattern
+}
+
+// operationID names an operation from its method and path, e.g. getApiWorkflowsId
+func operationID(op Operation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '_' || r == '{' || r == '}'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
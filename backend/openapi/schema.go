@@ -0,0 +1,131 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaSet builds the JSON schemas of Go types, collecting named structs as components
+// that the schemas reference
+type schemaSet struct {
+	components map[string]interface{}
+	// names maps each struct type to its component name, so two packages can define a
+	// type of the same name
+	names map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{components: map[string]interface{}{}, names: map[reflect.Type]string{}}
+}
+
+// schemaOf returns the schema of the type of value, or nil when value is nil
+func (s *schemaSet) schemaOf(value interface{}) map[string]interface{} {
+	if value == nil {
+		return nil
+	}
+	return s.schema(reflect.TypeOf(value))
+}
+
+// schema returns the schema of a type the way encoding/json marshals it
+func (s *schemaSet) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + s.component(t)}
+	}
+	// interface{} and anything else JSON can't describe accept any value
+	return map[string]interface{}{}
+}
+
+// component registers a named struct as a component and returns its name
+func (s *schemaSet) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.components[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	s.names[t] = name
+	// Reserve the name before building the schema, so recursive types terminate
+	s.components[name] = nil
+	s.components[name] = s.object(t)
+	return name
+}
+
+// object returns the schema of a struct, with the fields of embedded structs inlined
+func (s *schemaSet) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	s.addFields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func (s *schemaSet) addFields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.addFields(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+	}
+}
@@ -0,0 +1,105 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// EnvAllowedHosts lists the internal hosts requests may still reach, such as an internal
+// API a workflow fetches records from: comma-separated host names, IP addresses or CIDR
+// ranges, e.g. "crm.internal,10.20.0.0/16"
+const EnvAllowedHosts = "OUTBOUND_ALLOWED_HOSTS"
+
+// ErrInternalAddress is returned for connections to internal addresses that are not allowed
+var ErrInternalAddress = errors.New("address is internal")
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not routed on the internet
+var sharedAddressSpace = mustParseCIDR("100.64.0.0/10")
+
+// Client sends requests to the URLs users configure, such as those of workflow HTTP nodes
+// and webhooks. It refuses to connect to loopback, private, link-local and other internal
+// addresses, such as the cloud metadata service at 169.254.169.254, unless EnvAllowedHosts
+// allows them. Addresses are checked once the host is resolved, for every connection, so
+// redirects and host names resolving to internal addresses are refused as well.
+var Client = &http.Client{Transport: newTransport()}
+
+// newTransport returns the default transport with connections checked by checkAddress
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on our behalf, past the address check
+	transport.Proxy = nil
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	checked := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: checkAddress}
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(address); err == nil && allowedHost(host) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return checked.DialContext(ctx, network, address)
+	}
+	return transport
+}
+
+// checkAddress rejects a connection to an internal address that is not allowed. It runs
+// with the resolved address, so it also covers host names.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s is not an IP address", ErrInternalAddress, host)
+	}
+	if isInternal(ip) && !allowedHost(ip.String()) {
+		return fmt.Errorf("%w: %s (set %s to allow it)", ErrInternalAddress, ip, EnvAllowedHosts)
+	}
+	return nil
+}
+
+// isInternal reports whether ip is an address of the host or of a private network
+func isInternal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		sharedAddressSpace.Contains(ip) || (ip.To4() != nil && ip.To4()[0] == 0)
+}
+
+// allowedHost reports whether EnvAllowedHosts names host, or a range holding it
+func allowedHost(host string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(os.Getenv(EnvAllowedHosts), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.EqualFold(entry, host) {
+			return true
+		}
+		if ip == nil {
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustParseCIDR parses a CIDR range known to be valid
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
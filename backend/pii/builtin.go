@@ -0,0 +1,76 @@
+package pii
+
+import (
+	"context"
+	"regexp"
+)
+
+// builtinPattern is a regular expression for one entity type with the score its matches get
+type builtinPattern struct {
+	entityType string
+	pattern    *regexp.Regexp
+	score      float64
+	validate   func(string) bool
+	// group is the submatch holding the entity when the pattern matches its context too
+	group int
+}
+
+// builtinPatterns are the entity types the built-in detector recognizes
+var builtinPatterns = []builtinPattern{
+	{entityType: "EMAIL", pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), score: 0.95},
+	{entityType: "SSN", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), score: 0.85},
+	{entityType: "CREDIT_CARD", pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), score: 0.9, validate: luhnValid},
+	{entityType: "PHONE", pattern: regexp.MustCompile(`(?:\+?1[ .-]?)?\(?\b\d{3}\)?[ .-]?\d{3}[ .-]?\d{4}\b`), score: 0.7},
+	{entityType: "IP_ADDRESS", pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), score: 0.6},
+	{entityType: "ACCOUNT_NUMBER", pattern: regexp.MustCompile(`(?i)\b(?:account|acct)(?:\s+(?:number|no\.?|num|#))?\s*(?:is|:|#)?\s*([A-Z0-9][A-Z0-9-]{3,}[0-9])\b`), score: 0.8, group: 1},
+	{entityType: "NAME", pattern: regexp.MustCompile(`\b(?:Mr|Mrs|Ms|Miss|Dr)\.?\s+([A-Z][a-z]+(?:\s+[A-Z][a-z]+)?)`), score: 0.7, group: 1},
+	{entityType: "NAME", pattern: regexp.MustCompile(`\b(?:[Mm]y name is|[Nn]ame's)\s+([A-Z][a-z]+(?:\s+[A-Z][a-z]+)?)`), score: 0.6, group: 1},
+	{entityType: "NAME", pattern: regexp.MustCompile(`\b[Tt]his is\s+([A-Z][a-z]+(?:\s+[A-Z][a-z]+)?)\s+(?:from|with|speaking|here)\b`), score: 0.6, group: 1},
+	{entityType: TypeAmount, pattern: regexp.MustCompile(`(?:[$€£]\s?\d+(?:,\d{3})*(?:\.\d{1,2})?|\b\d+(?:\.\d{1,2})?\s?(?:dollars|USD|EUR|euros|GBP|pounds)\b)`), score: 0.9},
+}
+
+// BuiltinDetector finds structured PII such as emails, phone numbers, card and account
+// numbers and amounts with regular expressions. It runs locally; names are only found
+// where a speaker introduces or addresses someone, and addresses are not detected.
+type BuiltinDetector struct{}
+
+// Name returns the provider name
+func (BuiltinDetector) Name() string {
+	return ProviderBuiltin
+}
+
+// Detect returns the pattern matches in text
+func (BuiltinDetector) Detect(ctx context.Context, text, language string) ([]Entity, error) {
+	var entities []Entity
+	for _, p := range builtinPatterns {
+		for _, match := range p.pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := match[2*p.group], match[2*p.group+1]
+			if start < 0 || p.validate != nil && !p.validate(text[start:end]) {
+				continue
+			}
+			entities = append(entities, Entity{Type: p.entityType, Start: start, End: end, Score: p.score})
+		}
+	}
+	return entities, nil
+}
+
+// luhnValid reports whether the digits of a candidate card number pass the Luhn check
+func luhnValid(candidate string) bool {
+	sum, digits := 0, 0
+	for i := len(candidate) - 1; i >= 0; i-- {
+		c := candidate[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
@@ -0,0 +1,203 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// comprehendMaxBytes is the largest text DetectPiiEntities accepts in one request
+const comprehendMaxBytes = 100 * 1000
+
+// ComprehendDetector calls the DetectPiiEntities API of AWS Comprehend. Requests are
+// signed with the credentials in AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and the
+// optional AWS_SESSION_TOKEN.
+type ComprehendDetector struct {
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	httpClient   *http.Client
+}
+
+// comprehendResponse is the DetectPiiEntities response
+type comprehendResponse struct {
+	Entities []struct {
+		Type        string  `json:"Type"`
+		Score       float64 `json:"Score"`
+		BeginOffset int     `json:"BeginOffset"`
+		EndOffset   int     `json:"EndOffset"`
+	} `json:"Entities"`
+	Message string `json:"message"`
+}
+
+// NewComprehendDetector creates a detector for Comprehend in region
+func NewComprehendDetector(region string) (*ComprehendDetector, error) {
+	if region == "" {
+		return nil, fmt.Errorf("%s or AWS_REGION must be set to use the comprehend provider", EnvComprehendRegion)
+	}
+
+	d := &ComprehendDetector{
+		region:       region,
+		endpoint:     fmt.Sprintf("https://comprehend.%s.amazonaws.com/", region),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+	if d.accessKey == "" || d.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to use the comprehend provider")
+	}
+	return d, nil
+}
+
+// Name returns the provider name
+func (d *ComprehendDetector) Name() string {
+	return ProviderComprehend
+}
+
+// Detect sends text to Comprehend, split into chunks under the request size limit,
+// and returns the entities it found
+func (d *ComprehendDetector) Detect(ctx context.Context, text, language string) ([]Entity, error) {
+	var entities []Entity
+	for _, chunk := range splitText(text, comprehendMaxBytes) {
+		found, err := d.detectChunk(ctx, text[chunk[0]:chunk[1]], language)
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range found {
+			entity.Start += chunk[0]
+			entity.End += chunk[0]
+			entities = append(entities, entity)
+		}
+	}
+	return entities, nil
+}
+
+// detectChunk calls DetectPiiEntities for a text within the size limit
+func (d *ComprehendDetector) detectChunk(ctx context.Context, text, language string) ([]Entity, error) {
+	body, err := json.Marshal(map[string]string{"Text": text, "LanguageCode": language})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Comprehend_20171127.DetectPiiEntities")
+	d.sign(req, body, time.Now().UTC())
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result comprehendResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, result.Message)
+	}
+
+	entities := make([]Entity, 0, len(result.Entities))
+	for _, found := range result.Entities {
+		start, end := byteOffsets(text, found.BeginOffset, found.EndOffset)
+		entities = append(entities, Entity{Type: found.Type, Start: start, End: end, Score: found.Score})
+	}
+	return entities, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req
+func (d *ComprehendDetector) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if d.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", d.sessionToken)
+	}
+
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         req.URL.Host,
+		"x-amz-date":   amzDate,
+		"x-amz-target": req.Header.Get("X-Amz-Target"),
+	}
+	names := []string{"content-type", "host", "x-amz-date"}
+	if d.sessionToken != "" {
+		headers["x-amz-security-token"] = d.sessionToken
+		names = append(names, "x-amz-security-token")
+	}
+	names = append(names, "x-amz-target")
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method, "/", "", canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + d.region + "/comprehend/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+d.secretKey), date)
+	key = hmacSHA256(key, d.region)
+	key = hmacSHA256(key, "comprehend")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		d.accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// splitText splits text into byte ranges of at most maxBytes, breaking at line ends
+// where possible so entities are not cut in half
+func splitText(text string, maxBytes int) [][2]int {
+	var chunks [][2]int
+	start := 0
+	for len(text)-start > maxBytes {
+		end := start + maxBytes
+		if newline := strings.LastIndexByte(text[start:end], '\n'); newline > 0 {
+			end = start + newline + 1
+		} else {
+			// Don't split a multi-byte character
+			for end > start && end < len(text) && text[end]&0xC0 == 0x80 {
+				end--
+			}
+		}
+		chunks = append(chunks, [2]int{start, end})
+		start = end
+	}
+	return append(chunks, [2]int{start, len(text)})
+}
@@ -0,0 +1,335 @@
+// Package pii detects and redacts personally identifiable information in conversation
+// text using pluggable detection providers
+package pii
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"agenticflows/backend/demo"
+)
+
+// Environment variables that configure PII detection
+const (
+	EnvPIIProviders        = "PII_PROVIDERS"         // Comma-separated providers, e.g. "presidio,builtin"
+	EnvPIIMinConfidence    = "PII_MIN_CONFIDENCE"    // Default score an entity needs to be redacted
+	EnvPIIEntityThresholds = "PII_ENTITY_THRESHOLDS" // Per-type overrides, e.g. "NAME=0.8,ADDRESS=0.6"
+	EnvPresidioURL         = "PRESIDIO_ANALYZER_URL" // e.g. http://localhost:5002
+	EnvComprehendRegion    = "COMPREHEND_REGION"     // Falls back to AWS_REGION
+)
+
+// Provider names
+const (
+	ProviderBuiltin    = "builtin"
+	ProviderPresidio   = "presidio"
+	ProviderComprehend = "comprehend"
+)
+
+// TypeAmount is the entity type of monetary amounts, which analyses of disputes and fees
+// often need to keep
+const TypeAmount = "AMOUNT"
+
+// DefaultMinConfidence is the score an entity needs when no threshold is configured
+const DefaultMinConfidence = 0.5
+
+// Entity is a span of PII in a text. Start and End are byte offsets.
+type Entity struct {
+	Type     string  `json:"type"`
+	Start    int     `json:"start"`
+	End      int     `json:"end"`
+	Text     string  `json:"text"`
+	Score    float64 `json:"score"`
+	Provider string  `json:"provider"`
+}
+
+// Detector finds PII entities in text. Implementations return every entity they find
+// with its score; thresholds are applied by the caller.
+type Detector interface {
+	Name() string
+	Detect(ctx context.Context, text, language string) ([]Entity, error)
+}
+
+// Config selects the detection providers and the confidence they must reach
+type Config struct {
+	Providers        []string
+	MinConfidence    float64
+	EntityThresholds map[string]float64
+	// PreservedTypes are entity types that are detected but left in the text
+	PreservedTypes   []string
+	PresidioURL      string
+	ComprehendRegion string
+}
+
+// ConfigFromEnv reads the PII configuration from the environment. Without
+// PII_PROVIDERS only the built-in detector is used.
+func ConfigFromEnv() Config {
+	config := Config{
+		Providers:        []string{ProviderBuiltin},
+		MinConfidence:    DefaultMinConfidence,
+		EntityThresholds: map[string]float64{},
+		PresidioURL:      strings.TrimRight(os.Getenv(EnvPresidioURL), "/"),
+		ComprehendRegion: os.Getenv(EnvComprehendRegion),
+	}
+	if config.ComprehendRegion == "" {
+		config.ComprehendRegion = os.Getenv("AWS_REGION")
+	}
+
+	if providers := os.Getenv(EnvPIIProviders); providers != "" {
+		config.Providers = nil
+		for _, provider := range strings.Split(providers, ",") {
+			if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+				config.Providers = append(config.Providers, provider)
+			}
+		}
+	}
+
+	if value, err := strconv.ParseFloat(os.Getenv(EnvPIIMinConfidence), 64); err == nil && value >= 0 && value <= 1 {
+		config.MinConfidence = value
+	}
+
+	for _, pair := range strings.Split(os.Getenv(EnvPIIEntityThresholds), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if threshold, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			config.EntityThresholds[NormalizeType(name)] = threshold
+		}
+	}
+
+	return config
+}
+
+// Threshold returns the score an entity of the given type needs to be reported
+func (c Config) Threshold(entityType string) float64 {
+	if threshold, ok := c.EntityThresholds[entityType]; ok {
+		return threshold
+	}
+	return c.MinConfidence
+}
+
+// Preserved reports whether entities of the given type are left in the text
+func (c Config) Preserved(entityType string) bool {
+	for _, preserved := range c.PreservedTypes {
+		if NormalizeType(preserved) == entityType {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDetector builds the detector for a provider name. Demo mode always uses the built-in
+// detector, so no text is sent to an external service.
+func NewDetector(provider string, config Config) (Detector, error) {
+	if demo.Enabled() {
+		provider = ProviderBuiltin
+	}
+	switch provider {
+	case ProviderBuiltin:
+		return BuiltinDetector{}, nil
+	case ProviderPresidio:
+		if config.PresidioURL == "" {
+			return nil, fmt.Errorf("%s must be set to use the presidio provider", EnvPresidioURL)
+		}
+		return NewPresidioDetector(config.PresidioURL), nil
+	case ProviderComprehend:
+		return NewComprehendDetector(config.ComprehendRegion)
+	default:
+		return nil, fmt.Errorf("unknown PII provider %q", provider)
+	}
+}
+
+// Scanner runs the configured detectors and applies confidence thresholds
+type Scanner struct {
+	config    Config
+	detectors []Detector
+}
+
+// NewScanner creates a scanner for the providers in config
+func NewScanner(config Config) (*Scanner, error) {
+	if len(config.Providers) == 0 {
+		return nil, fmt.Errorf("no PII providers configured")
+	}
+	if demo.Enabled() {
+		config.Providers = []string{ProviderBuiltin}
+	}
+
+	scanner := &Scanner{config: config}
+	for _, provider := range config.Providers {
+		detector, err := NewDetector(provider, config)
+		if err != nil {
+			return nil, err
+		}
+		scanner.detectors = append(scanner.detectors, detector)
+	}
+	return scanner, nil
+}
+
+// Providers returns the names of the scanner's detectors
+func (s *Scanner) Providers() []string {
+	names := make([]string, len(s.detectors))
+	for i, detector := range s.detectors {
+		names[i] = detector.Name()
+	}
+	return names
+}
+
+// Detect returns the entities found by any detector that reach their type's threshold,
+// except those of preserved types
+func (s *Scanner) Detect(ctx context.Context, text, language string) ([]Entity, error) {
+	if language == "" {
+		language = "en"
+	}
+
+	var entities []Entity
+	for _, detector := range s.detectors {
+		found, err := detector.Detect(ctx, text, language)
+		if err != nil {
+			return nil, fmt.Errorf("%s PII detection failed: %w", detector.Name(), err)
+		}
+		for _, entity := range found {
+			if entity.Start < 0 || entity.End > len(text) || entity.Start >= entity.End {
+				continue
+			}
+			entity.Type = NormalizeType(entity.Type)
+			if entity.Score < s.config.Threshold(entity.Type) || s.config.Preserved(entity.Type) {
+				continue
+			}
+			entity.Text = text[entity.Start:entity.End]
+			entity.Provider = detector.Name()
+			entities = append(entities, entity)
+		}
+	}
+
+	sort.Slice(entities, func(i, j int) bool {
+		if entities[i].Start != entities[j].Start {
+			return entities[i].Start < entities[j].Start
+		}
+		return entities[i].Score > entities[j].Score
+	})
+	return entities, nil
+}
+
+// Redact detects PII in text and replaces it with type placeholders
+func (s *Scanner) Redact(ctx context.Context, text, language string) (string, []Entity, error) {
+	entities, err := s.Detect(ctx, text, language)
+	if err != nil {
+		return "", nil, err
+	}
+	return Redact(text, entities), entities, nil
+}
+
+// Redact replaces each entity span with a placeholder such as [EMAIL]. Overlapping
+// spans are merged and take the type of the highest scoring entity.
+func Redact(text string, entities []Entity) string {
+	var redacted strings.Builder
+	pos := 0
+	for _, span := range mergeSpans(entities) {
+		redacted.WriteString(text[pos:span.Start])
+		redacted.WriteString("[" + span.Type + "]")
+		pos = span.End
+	}
+	redacted.WriteString(text[pos:])
+	return redacted.String()
+}
+
+// mergeSpans sorts entities by position and merges overlapping ones into the type of the
+// highest scoring entity
+func mergeSpans(entities []Entity) []Entity {
+	spans := make([]Entity, len(entities))
+	copy(spans, entities)
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	var merged []Entity
+	for _, span := range spans {
+		last := len(merged) - 1
+		if last >= 0 && span.Start < merged[last].End {
+			if span.End > merged[last].End {
+				merged[last].End = span.End
+			}
+			if span.Score > merged[last].Score {
+				merged[last].Type = span.Type
+				merged[last].Score = span.Score
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// Report summarizes the PII redacted from a set of texts by type, without repeating it
+type Report struct {
+	Providers     []string       `json:"providers"`
+	Texts         int            `json:"texts"`
+	RedactedTexts int            `json:"redacted_texts"`
+	Entities      map[string]int `json:"entities"`
+	Preserved     []string       `json:"preserved_types,omitempty"`
+}
+
+// NewReport starts a report of the texts redacted by the scanner
+func (s *Scanner) NewReport() *Report {
+	report := &Report{Providers: s.Providers(), Entities: map[string]int{}}
+	for _, preserved := range s.config.PreservedTypes {
+		report.Preserved = append(report.Preserved, NormalizeType(preserved))
+	}
+	return report
+}
+
+// Add counts the redactions of one text. Overlapping entities count once, as they are
+// replaced by a single placeholder.
+func (r *Report) Add(entities []Entity) {
+	r.Texts++
+	if len(entities) == 0 {
+		return
+	}
+	r.RedactedTexts++
+	for _, span := range mergeSpans(entities) {
+		r.Entities[span.Type]++
+	}
+}
+
+// typeAliases maps provider-specific entity types to common names
+var typeAliases = map[string]string{
+	"EMAIL_ADDRESS":       "EMAIL",
+	"PHONE_NUMBER":        "PHONE",
+	"US_SSN":              "SSN",
+	"CREDIT_DEBIT_NUMBER": "CREDIT_CARD",
+	"PERSON":              "NAME",
+	"LOCATION":            "ADDRESS",
+	"US_BANK_NUMBER":      "BANK_ACCOUNT_NUMBER",
+	"IBAN_CODE":           "IBAN",
+}
+
+// NormalizeType maps a provider's entity type to the common type name
+func NormalizeType(entityType string) string {
+	entityType = strings.ToUpper(strings.TrimSpace(entityType))
+	if alias, ok := typeAliases[entityType]; ok {
+		return alias
+	}
+	return entityType
+}
+
+// byteOffsets converts character offsets, as returned by Presidio and Comprehend,
+// to byte offsets into text
+func byteOffsets(text string, start, end int) (int, int) {
+	byteStart, byteEnd := -1, -1
+	char := 0
+	for i := range text {
+		if char == start {
+			byteStart = i
+		}
+		if char == end {
+			byteEnd = i
+		}
+		char++
+	}
+	if char == end {
+		byteEnd = len(text)
+	}
+	return byteStart, byteEnd
+}
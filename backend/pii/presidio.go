@@ -0,0 +1,79 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PresidioDetector calls the analyze endpoint of a Microsoft Presidio analyzer service
+type PresidioDetector struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// presidioResult is one entity in a Presidio analyze response
+type presidioResult struct {
+	EntityType string  `json:"entity_type"`
+	Start      int     `json:"start"`
+	End        int     `json:"end"`
+	Score      float64 `json:"score"`
+}
+
+// NewPresidioDetector creates a detector for the analyzer at baseURL
+func NewPresidioDetector(baseURL string) *PresidioDetector {
+	return &PresidioDetector{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (d *PresidioDetector) Name() string {
+	return ProviderPresidio
+}
+
+// Detect sends text to the analyzer and returns the entities it found
+func (d *PresidioDetector) Detect(ctx context.Context, text, language string) ([]Entity, error) {
+	body, err := json.Marshal(map[string]interface{}{"text": text, "language": language})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/analyze", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var results []presidioResult
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	entities := make([]Entity, 0, len(results))
+	for _, result := range results {
+		start, end := byteOffsets(text, result.Start, result.End)
+		entities = append(entities, Entity{Type: result.EntityType, Start: start, End: end, Score: result.Score})
+	}
+	return entities, nil
+}
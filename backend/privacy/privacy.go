@@ -0,0 +1,119 @@
+// Package privacy keeps aggregates from exposing segments small enough to re-identify
+// individual customers
+package privacy
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvMinGroupSize sets k, the number of conversations an aggregate bucket must cover to be
+// shown. 0 or unset disables suppression.
+const EnvMinGroupSize = "PRIVACY_MIN_GROUP_SIZE"
+
+// groupSizeFields are the fields that hold how many conversations a bucket covers
+var groupSizeFields = []string{"occurrences", "count", "frequency", "conversation_count"}
+
+// MinGroupSize returns the configured k
+func MinGroupSize() int {
+	k, err := strconv.Atoi(strings.TrimSpace(os.Getenv(EnvMinGroupSize)))
+	if err != nil || k < 0 {
+		return 0
+	}
+	return k
+}
+
+// SuppressSmallGroups removes aggregate buckets covering fewer than k conversations from
+// results and returns the filtered results with the number of buckets removed.
+//
+// A bucket is an object in a list with a count field such as "occurrences" or "count",
+// or an entry of a "distribution" or "*_counts" object mapping labels to counts. The
+// results are not modified; filtered containers are copies.
+func SuppressSmallGroups(results interface{}, k int) (interface{}, int) {
+	if k <= 1 {
+		return results, 0
+	}
+	return suppress(results, "", k)
+}
+
+// IsSmallGroup reports whether item is a bucket covering fewer than k conversations
+func IsSmallGroup(item interface{}, k int) bool {
+	object, ok := item.(map[string]interface{})
+	if !ok || k <= 1 {
+		return false
+	}
+	size, ok := groupSize(object)
+	return ok && size < float64(k)
+}
+
+// suppress filters one value found under key
+func suppress(value interface{}, key string, k int) (interface{}, int) {
+	switch v := value.(type) {
+	case []interface{}:
+		filtered := make([]interface{}, 0, len(v))
+		suppressed := 0
+		for _, item := range v {
+			if IsSmallGroup(item, k) {
+				suppressed++
+				continue
+			}
+			item, n := suppress(item, "", k)
+			suppressed += n
+			filtered = append(filtered, item)
+		}
+		return filtered, suppressed
+
+	case map[string]interface{}:
+		if isDistribution(key, v) {
+			filtered := make(map[string]interface{}, len(v))
+			suppressed := 0
+			for label, count := range v {
+				if n, _ := count.(float64); n < float64(k) {
+					suppressed++
+					continue
+				}
+				filtered[label] = count
+			}
+			return filtered, suppressed
+		}
+
+		filtered := make(map[string]interface{}, len(v))
+		suppressed := 0
+		for field, fieldValue := range v {
+			fieldValue, n := suppress(fieldValue, field, k)
+			suppressed += n
+			filtered[field] = fieldValue
+		}
+		return filtered, suppressed
+
+	default:
+		return value, 0
+	}
+}
+
+// groupSize reads the count field of a bucket
+func groupSize(object map[string]interface{}) (float64, bool) {
+	for _, field := range groupSizeFields {
+		switch n := object[field].(type) {
+		case float64:
+			return n, true
+		case int:
+			return float64(n), true
+		}
+	}
+	return 0, false
+}
+
+// isDistribution reports whether an object maps labels to counts
+func isDistribution(key string, object map[string]interface{}) bool {
+	if key != "distribution" && !strings.HasSuffix(key, "_distribution") && !strings.HasSuffix(key, "_counts") {
+		return false
+	}
+	for _, value := range object {
+		if _, ok := value.(float64); !ok {
+			return false
+		}
+	}
+	return len(object) > 0
+}
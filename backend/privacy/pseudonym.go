@@ -0,0 +1,120 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+)
+
+// EnvPseudonymKey is the secret used to derive pseudonyms. When set, conversation and
+// customer IDs in analysis results are replaced with stable opaque tokens.
+const EnvPseudonymKey = "PRIVACY_PSEUDONYM_KEY"
+
+// Kinds of identifiers that are pseudonymized
+const (
+	PseudonymConversation = "conversation"
+	PseudonymCustomer     = "customer"
+)
+
+// pseudonymPrefixes start the tokens of each kind, so tokens show what they stand for
+var pseudonymPrefixes = map[string]string{
+	PseudonymConversation: "conv_",
+	PseudonymCustomer:     "cust_",
+}
+
+// pseudonymFields are the result fields holding an identifier, or a list of them
+var pseudonymFields = map[string]string{
+	"conversation_id":      PseudonymConversation,
+	"conversation_ids":     PseudonymConversation,
+	"source_conversations": PseudonymConversation,
+	"customer_id":          PseudonymCustomer,
+	"customer_ids":         PseudonymCustomer,
+}
+
+// Pseudonym maps a token to the identifier it replaces
+type Pseudonym struct {
+	Token string `json:"token"`
+	Kind  string `json:"kind"`
+	ID    string `json:"id"`
+}
+
+// PseudonymKey returns the configured pseudonym key, or nil if pseudonymization is disabled
+func PseudonymKey() []byte {
+	key := strings.TrimSpace(os.Getenv(EnvPseudonymKey))
+	if key == "" {
+		return nil
+	}
+	return []byte(key)
+}
+
+// PseudonymToken derives the token of an identifier. The same key, kind and identifier
+// always give the same token, so tokens can be joined across exports.
+func PseudonymToken(key []byte, kind, id string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(kind + ":" + id))
+	return pseudonymPrefixes[kind] + hex.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// IsPseudonymToken reports whether value has the form of a token
+func IsPseudonymToken(value string) bool {
+	for _, prefix := range pseudonymPrefixes {
+		if strings.HasPrefix(value, prefix) && len(value) == len(prefix)+24 {
+			return true
+		}
+	}
+	return false
+}
+
+// Pseudonymize replaces the conversation and customer IDs in results with tokens and
+// returns the pseudonymized results with the pseudonyms used. The results are not
+// modified; containers holding identifiers are copies.
+func Pseudonymize(results interface{}, key []byte) (interface{}, []Pseudonym) {
+	if len(key) == 0 {
+		return results, nil
+	}
+	used := map[string]Pseudonym{}
+	pseudonymized := pseudonymize(results, "", key, used)
+
+	pseudonyms := make([]Pseudonym, 0, len(used))
+	for _, pseudonym := range used {
+		pseudonyms = append(pseudonyms, pseudonym)
+	}
+	return pseudonymized, pseudonyms
+}
+
+// pseudonymize replaces the identifiers in one value found under field
+func pseudonymize(value interface{}, field string, key []byte, used map[string]Pseudonym) interface{} {
+	kind := pseudonymFields[field]
+	switch v := value.(type) {
+	case string:
+		if kind == "" || v == "" || IsPseudonymToken(v) {
+			return v
+		}
+		token := PseudonymToken(key, kind, v)
+		used[token] = Pseudonym{Token: token, Kind: kind, ID: v}
+		return token
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return pseudonymize(list, field, key, used)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			// Lists of identifiers keep their field's kind; objects in lists are walked
+			list[i] = pseudonymize(item, field, key, used)
+		}
+		return list
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for name, fieldValue := range v {
+			object[name] = pseudonymize(fieldValue, name, key, used)
+		}
+		return object
+	default:
+		return value
+	}
+}
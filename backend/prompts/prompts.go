@@ -0,0 +1,257 @@
+// Package prompts is the registry of prompt templates, which replace the built-in
+// prompts of analyses so teams can tune prompts without recompiling. Templates are
+// read from files in a directory or stored in the database, and are addressed by ID or
+// by analysis type and version. File templates are shared by all tenants; stored
+// templates, their versions and the active template of each analysis type belong to a
+// tenant.
+package prompts
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"agenticflows/backend/analysis/core"
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// EnvTemplateDir is the directory of file templates, named <analysis_type>.v<version>.tmpl,
+// e.g. sentiment.v2.tmpl. Files are read on every lookup, so edits apply immediately.
+const EnvTemplateDir = "PROMPT_TEMPLATE_DIR"
+
+// fileNamePattern matches the names of template files
+var fileNamePattern = regexp.MustCompile(`^([a-z_]+)\.v(\d+)\.tmpl$`)
+
+// Validate checks that a template replaces a known prompt and parses
+func Validate(analysisType, text string) error {
+	if !core.IsPromptName(analysisType) {
+		return fmt.Errorf("unknown analysis_type %q; templates can replace %s", analysisType, strings.Join(core.PromptNames(), ", "))
+	}
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("template is required")
+	}
+	if _, err := core.ParsePromptTemplate(text); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	return nil
+}
+
+// List returns the file templates and the stored templates of a tenant, of one analysis
+// type if set, ordered by analysis type and version
+func List(tenantID, analysisType string) ([]db.PromptTemplate, error) {
+	templates, err := fileTemplates()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := db.ListPromptTemplates(tenantID, analysisType)
+	if err != nil {
+		return nil, err
+	}
+	active, err := db.GetActivePromptTemplates(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	all := stored
+	for _, t := range templates {
+		if analysisType == "" || t.AnalysisType == analysisType {
+			all = append(all, t)
+		}
+	}
+	for i := range all {
+		all[i].Active = active[all[i].AnalysisType] == all[i].ID
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].AnalysisType != all[j].AnalysisType {
+			return all[i].AnalysisType < all[j].AnalysisType
+		}
+		return all[i].Version < all[j].Version
+	})
+	return all, nil
+}
+
+// Get returns a file template or a stored template of a tenant by ID, or by a reference
+// of the form <analysis_type>@<version>
+func Get(tenantID, ref string) (db.PromptTemplate, error) {
+	if analysisType, version, ok := strings.Cut(ref, "@"); ok {
+		n, err := strconv.Atoi(version)
+		if err != nil {
+			return db.PromptTemplate{}, fmt.Errorf("invalid prompt template version %q", version)
+		}
+		templates, err := List(tenantID, analysisType)
+		if err != nil {
+			return db.PromptTemplate{}, err
+		}
+		for _, t := range templates {
+			if t.Version == n {
+				return t, nil
+			}
+		}
+		return db.PromptTemplate{}, fmt.Errorf("prompt template not found")
+	}
+
+	templates, err := fileTemplates()
+	if err != nil {
+		return db.PromptTemplate{}, err
+	}
+	for _, t := range templates {
+		if t.ID == ref {
+			return withActive(tenantID, t)
+		}
+	}
+	t, err := db.GetPromptTemplate(tenantID, ref)
+	if err != nil {
+		return db.PromptTemplate{}, err
+	}
+	return withActive(tenantID, t)
+}
+
+// withActive sets whether a template is the active one of a tenant for its analysis type
+func withActive(tenantID string, t db.PromptTemplate) (db.PromptTemplate, error) {
+	active, err := db.GetActivePromptTemplates(tenantID)
+	if err != nil {
+		return db.PromptTemplate{}, err
+	}
+	t.Active = active[t.AnalysisType] == t.ID
+	return t, nil
+}
+
+// Create stores a template of a tenant as the next version of its analysis type, after
+// the tenant's stored versions and the file versions
+func Create(tenantID, analysisType, text, description string) (db.PromptTemplate, error) {
+	if err := Validate(analysisType, text); err != nil {
+		return db.PromptTemplate{}, err
+	}
+	templates, err := fileTemplates()
+	if err != nil {
+		return db.PromptTemplate{}, err
+	}
+	minVersion := 1
+	for _, t := range templates {
+		if t.AnalysisType == analysisType {
+			minVersion = max(minVersion, t.Version+1)
+		}
+	}
+
+	t := db.PromptTemplate{ID: uuid.New().String(), AnalysisType: analysisType, Template: text, Description: description}
+	if _, err := db.CreatePromptTemplate(tenantID, t, minVersion); err != nil {
+		return db.PromptTemplate{}, err
+	}
+	return Get(tenantID, t.ID)
+}
+
+// Delete deletes a stored template of a tenant. File templates are removed by deleting
+// their file.
+func Delete(tenantID, id string) error {
+	t, err := Get(tenantID, id)
+	if err != nil {
+		return err
+	}
+	if t.Source == db.PromptTemplateSourceFile {
+		return fmt.Errorf("file templates cannot be deleted through the API")
+	}
+	return db.DeletePromptTemplate(tenantID, t.ID)
+}
+
+// Activate makes a template the default of a tenant for its analysis type
+func Activate(tenantID, ref string) (db.PromptTemplate, error) {
+	t, err := Get(tenantID, ref)
+	if err != nil {
+		return db.PromptTemplate{}, err
+	}
+	if err := db.SetActivePromptTemplate(tenantID, t.AnalysisType, t.ID); err != nil {
+		return db.PromptTemplate{}, err
+	}
+	t.Active = true
+	return t, nil
+}
+
+// Deactivate restores the built-in prompt of the analysis type of a template for a
+// tenant, if the template is the tenant's active one
+func Deactivate(tenantID, ref string) (db.PromptTemplate, error) {
+	t, err := Get(tenantID, ref)
+	if err != nil {
+		return db.PromptTemplate{}, err
+	}
+	if t.Active {
+		if err := db.ClearActivePromptTemplate(tenantID, t.AnalysisType); err != nil {
+			return db.PromptTemplate{}, err
+		}
+		t.Active = false
+	}
+	return t, nil
+}
+
+// ForRequest returns the template texts a request of a tenant uses, by analysis type:
+// the active templates of the tenant, with the template ref picks, if set, replacing the
+// one of its type. Active templates that no longer exist are skipped.
+func ForRequest(tenantID, ref string) (map[string]string, error) {
+	active, err := db.GetActivePromptTemplates(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	texts := make(map[string]string, len(active)+1)
+	for analysisType, id := range active {
+		t, err := Get(tenantID, id)
+		if err != nil {
+			log.Printf("Warning: skipping active %s prompt template %s: %v", analysisType, id, err)
+			continue
+		}
+		texts[analysisType] = t.Template
+	}
+
+	if ref != "" {
+		t, err := Get(tenantID, ref)
+		if err != nil {
+			return nil, fmt.Errorf("prompt template %s: %w", ref, err)
+		}
+		texts[t.AnalysisType] = t.Template
+	}
+	return texts, nil
+}
+
+// fileTemplates reads the templates of the template directory. Files with other names
+// or for unknown prompts are skipped.
+func fileTemplates() ([]db.PromptTemplate, error) {
+	dir := os.Getenv(EnvTemplateDir)
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt template directory: %w", err)
+	}
+
+	var templates []db.PromptTemplate
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil || !core.IsPromptName(match[1]) {
+			continue
+		}
+		text, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %s: %w", entry.Name(), err)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		version, _ := strconv.Atoi(match[2])
+		templates = append(templates, db.PromptTemplate{
+			ID:           strings.TrimSuffix(entry.Name(), ".tmpl"),
+			AnalysisType: match[1],
+			Version:      version,
+			Template:     string(text),
+			Source:       db.PromptTemplateSourceFile,
+			CreatedAt:    info.ModTime(),
+		})
+	}
+	return templates, nil
+}
@@ -0,0 +1,1065 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: analysis.proto
+
+// The gRPC interface of the analysis API. It mirrors /api/analysis,
+// /api/analysis/chain and /api/workflows/{id}/execute with typed messages.
+// Results keep the JSON structure of the HTTP API as a Struct.
+
+package analysisv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SourceRef identifies a conversation or a stored result an analysis was derived from
+type SourceRef struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "conversation" or the analysis type of a stored result
+	Type          string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Id            string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SourceRef) Reset() {
+	*x = SourceRef{}
+	mi := &file_analysis_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SourceRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SourceRef) ProtoMessage() {}
+
+func (x *SourceRef) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SourceRef.ProtoReflect.Descriptor instead.
+func (*SourceRef) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SourceRef) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *SourceRef) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type AnalysisRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// trends, patterns, findings, attributes, intent, sentiment, compare,
+	// recommendations or plan
+	AnalysisType string `protobuf:"bytes,1,opt,name=analysis_type,json=analysisType,proto3" json:"analysis_type,omitempty"`
+	WorkflowId   string `protobuf:"bytes,2,opt,name=workflow_id,json=workflowId,proto3" json:"workflow_id,omitempty"`
+	Text         string `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	// Stored conversations to analyze instead of text
+	ConversationIds []string         `protobuf:"bytes,4,rep,name=conversation_ids,json=conversationIds,proto3" json:"conversation_ids,omitempty"`
+	Parameters      *structpb.Struct `protobuf:"bytes,5,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	Data            *structpb.Struct `protobuf:"bytes,6,opt,name=data,proto3" json:"data,omitempty"`
+	Sources         []*SourceRef     `protobuf:"bytes,7,rep,name=sources,proto3" json:"sources,omitempty"`
+	// Set to false to bypass cached results of identical requests
+	Cache *bool `protobuf:"varint,8,opt,name=cache,proto3,oneof" json:"cache,omitempty"`
+	// Attribute the LLM usage of the request to cost centers
+	Tags          map[string]string `protobuf:"bytes,9,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalysisRequest) Reset() {
+	*x = AnalysisRequest{}
+	mi := &file_analysis_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalysisRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalysisRequest) ProtoMessage() {}
+
+func (x *AnalysisRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalysisRequest.ProtoReflect.Descriptor instead.
+func (*AnalysisRequest) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AnalysisRequest) GetAnalysisType() string {
+	if x != nil {
+		return x.AnalysisType
+	}
+	return ""
+}
+
+func (x *AnalysisRequest) GetWorkflowId() string {
+	if x != nil {
+		return x.WorkflowId
+	}
+	return ""
+}
+
+func (x *AnalysisRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *AnalysisRequest) GetConversationIds() []string {
+	if x != nil {
+		return x.ConversationIds
+	}
+	return nil
+}
+
+func (x *AnalysisRequest) GetParameters() *structpb.Struct {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *AnalysisRequest) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *AnalysisRequest) GetSources() []*SourceRef {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *AnalysisRequest) GetCache() bool {
+	if x != nil && x.Cache != nil {
+		return *x.Cache
+	}
+	return false
+}
+
+func (x *AnalysisRequest) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+// SampleAgreement reports how consistently repeated samples of an analysis agreed
+type SampleAgreement struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Samples          int32                  `protobuf:"varint,1,opt,name=samples,proto3" json:"samples,omitempty"`
+	Succeeded        int32                  `protobuf:"varint,2,opt,name=succeeded,proto3" json:"succeeded,omitempty"`
+	Agreement        float64                `protobuf:"fixed64,3,opt,name=agreement,proto3" json:"agreement,omitempty"`
+	SampleConfidence float64                `protobuf:"fixed64,4,opt,name=sample_confidence,json=sampleConfidence,proto3" json:"sample_confidence,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *SampleAgreement) Reset() {
+	*x = SampleAgreement{}
+	mi := &file_analysis_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SampleAgreement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SampleAgreement) ProtoMessage() {}
+
+func (x *SampleAgreement) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SampleAgreement.ProtoReflect.Descriptor instead.
+func (*SampleAgreement) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SampleAgreement) GetSamples() int32 {
+	if x != nil {
+		return x.Samples
+	}
+	return 0
+}
+
+func (x *SampleAgreement) GetSucceeded() int32 {
+	if x != nil {
+		return x.Succeeded
+	}
+	return 0
+}
+
+func (x *SampleAgreement) GetAgreement() float64 {
+	if x != nil {
+		return x.Agreement
+	}
+	return 0
+}
+
+func (x *SampleAgreement) GetSampleConfidence() float64 {
+	if x != nil {
+		return x.SampleConfidence
+	}
+	return 0
+}
+
+type DataQuality struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Assessment    string                 `protobuf:"bytes,1,opt,name=assessment,proto3" json:"assessment,omitempty"`
+	Limitations   []string               `protobuf:"bytes,2,rep,name=limitations,proto3" json:"limitations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataQuality) Reset() {
+	*x = DataQuality{}
+	mi := &file_analysis_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataQuality) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataQuality) ProtoMessage() {}
+
+func (x *DataQuality) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataQuality.ProtoReflect.Descriptor instead.
+func (*DataQuality) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DataQuality) GetAssessment() string {
+	if x != nil {
+		return x.Assessment
+	}
+	return ""
+}
+
+func (x *DataQuality) GetLimitations() []string {
+	if x != nil {
+		return x.Limitations
+	}
+	return nil
+}
+
+type AnalysisResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	AnalysisType string                 `protobuf:"bytes,1,opt,name=analysis_type,json=analysisType,proto3" json:"analysis_type,omitempty"`
+	WorkflowId   string                 `protobuf:"bytes,2,opt,name=workflow_id,json=workflowId,proto3" json:"workflow_id,omitempty"`
+	// ID of the stored result, set when workflow_id is
+	ResultId  string                 `protobuf:"bytes,3,opt,name=result_id,json=resultId,proto3" json:"result_id,omitempty"`
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// The typed results of the analysis type, as in the HTTP API
+	Results          *structpb.Value  `protobuf:"bytes,5,opt,name=results,proto3" json:"results,omitempty"`
+	Confidence       float64          `protobuf:"fixed64,6,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	DataQuality      *DataQuality     `protobuf:"bytes,7,opt,name=data_quality,json=dataQuality,proto3" json:"data_quality,omitempty"`
+	Reliability      *SampleAgreement `protobuf:"bytes,8,opt,name=reliability,proto3" json:"reliability,omitempty"`
+	Cached           bool             `protobuf:"varint,9,opt,name=cached,proto3" json:"cached,omitempty"`
+	SuppressedGroups int32            `protobuf:"varint,10,opt,name=suppressed_groups,json=suppressedGroups,proto3" json:"suppressed_groups,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *AnalysisResponse) Reset() {
+	*x = AnalysisResponse{}
+	mi := &file_analysis_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalysisResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalysisResponse) ProtoMessage() {}
+
+func (x *AnalysisResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalysisResponse.ProtoReflect.Descriptor instead.
+func (*AnalysisResponse) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AnalysisResponse) GetAnalysisType() string {
+	if x != nil {
+		return x.AnalysisType
+	}
+	return ""
+}
+
+func (x *AnalysisResponse) GetWorkflowId() string {
+	if x != nil {
+		return x.WorkflowId
+	}
+	return ""
+}
+
+func (x *AnalysisResponse) GetResultId() string {
+	if x != nil {
+		return x.ResultId
+	}
+	return ""
+}
+
+func (x *AnalysisResponse) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *AnalysisResponse) GetResults() *structpb.Value {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *AnalysisResponse) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *AnalysisResponse) GetDataQuality() *DataQuality {
+	if x != nil {
+		return x.DataQuality
+	}
+	return nil
+}
+
+func (x *AnalysisResponse) GetReliability() *SampleAgreement {
+	if x != nil {
+		return x.Reliability
+	}
+	return nil
+}
+
+func (x *AnalysisResponse) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+func (x *AnalysisResponse) GetSuppressedGroups() int32 {
+	if x != nil {
+		return x.SuppressedGroups
+	}
+	return 0
+}
+
+// AnalysisChunk is a piece of partial model output
+type AnalysisChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalysisChunk) Reset() {
+	*x = AnalysisChunk{}
+	mi := &file_analysis_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalysisChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalysisChunk) ProtoMessage() {}
+
+func (x *AnalysisChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalysisChunk.ProtoReflect.Descriptor instead.
+func (*AnalysisChunk) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AnalysisChunk) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *AnalysisChunk) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+// AnalysisEvent is a chunk of partial output or, last, the response
+type AnalysisEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*AnalysisEvent_Chunk
+	//	*AnalysisEvent_Result
+	Event         isAnalysisEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalysisEvent) Reset() {
+	*x = AnalysisEvent{}
+	mi := &file_analysis_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalysisEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalysisEvent) ProtoMessage() {}
+
+func (x *AnalysisEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalysisEvent.ProtoReflect.Descriptor instead.
+func (*AnalysisEvent) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AnalysisEvent) GetEvent() isAnalysisEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *AnalysisEvent) GetChunk() *AnalysisChunk {
+	if x != nil {
+		if x, ok := x.Event.(*AnalysisEvent_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+func (x *AnalysisEvent) GetResult() *AnalysisResponse {
+	if x != nil {
+		if x, ok := x.Event.(*AnalysisEvent_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isAnalysisEvent_Event interface {
+	isAnalysisEvent_Event()
+}
+
+type AnalysisEvent_Chunk struct {
+	Chunk *AnalysisChunk `protobuf:"bytes,1,opt,name=chunk,proto3,oneof"`
+}
+
+type AnalysisEvent_Result struct {
+	Result *AnalysisResponse `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*AnalysisEvent_Chunk) isAnalysisEvent_Event() {}
+
+func (*AnalysisEvent_Result) isAnalysisEvent_Event() {}
+
+type ChainRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	WorkflowId string                 `protobuf:"bytes,1,opt,name=workflow_id,json=workflowId,proto3" json:"workflow_id,omitempty"`
+	Steps      []string               `protobuf:"bytes,2,rep,name=steps,proto3" json:"steps,omitempty"`
+	Text       string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	Parameters *structpb.Struct       `protobuf:"bytes,4,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	// "min" or "product"
+	ConfidencePropagation string            `protobuf:"bytes,5,opt,name=confidence_propagation,json=confidencePropagation,proto3" json:"confidence_propagation,omitempty"`
+	Tags                  map[string]string `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Abort the chain once its LLM calls have cost this much, in USD
+	MaxBudget     float64 `protobuf:"fixed64,7,opt,name=max_budget,json=maxBudget,proto3" json:"max_budget,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChainRequest) Reset() {
+	*x = ChainRequest{}
+	mi := &file_analysis_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChainRequest) ProtoMessage() {}
+
+func (x *ChainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChainRequest.ProtoReflect.Descriptor instead.
+func (*ChainRequest) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ChainRequest) GetWorkflowId() string {
+	if x != nil {
+		return x.WorkflowId
+	}
+	return ""
+}
+
+func (x *ChainRequest) GetSteps() []string {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *ChainRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *ChainRequest) GetParameters() *structpb.Struct {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *ChainRequest) GetConfidencePropagation() string {
+	if x != nil {
+		return x.ConfidencePropagation
+	}
+	return ""
+}
+
+func (x *ChainRequest) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ChainRequest) GetMaxBudget() float64 {
+	if x != nil {
+		return x.MaxBudget
+	}
+	return 0
+}
+
+type ChainResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkflowId    string                 `protobuf:"bytes,1,opt,name=workflow_id,json=workflowId,proto3" json:"workflow_id,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Results       *structpb.Struct       `protobuf:"bytes,3,opt,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChainResponse) Reset() {
+	*x = ChainResponse{}
+	mi := &file_analysis_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChainResponse) ProtoMessage() {}
+
+func (x *ChainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChainResponse.ProtoReflect.Descriptor instead.
+func (*ChainResponse) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ChainResponse) GetWorkflowId() string {
+	if x != nil {
+		return x.WorkflowId
+	}
+	return ""
+}
+
+func (x *ChainResponse) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *ChainResponse) GetResults() *structpb.Struct {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type ExecuteWorkflowRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkflowId    string                 `protobuf:"bytes,1,opt,name=workflow_id,json=workflowId,proto3" json:"workflow_id,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Parameters    *structpb.Struct       `protobuf:"bytes,3,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	Data          *structpb.Struct       `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	Tags          map[string]string      `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteWorkflowRequest) Reset() {
+	*x = ExecuteWorkflowRequest{}
+	mi := &file_analysis_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteWorkflowRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteWorkflowRequest) ProtoMessage() {}
+
+func (x *ExecuteWorkflowRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteWorkflowRequest.ProtoReflect.Descriptor instead.
+func (*ExecuteWorkflowRequest) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExecuteWorkflowRequest) GetWorkflowId() string {
+	if x != nil {
+		return x.WorkflowId
+	}
+	return ""
+}
+
+func (x *ExecuteWorkflowRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *ExecuteWorkflowRequest) GetParameters() *structpb.Struct {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *ExecuteWorkflowRequest) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ExecuteWorkflowRequest) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type ExecuteWorkflowResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkflowId    string                 `protobuf:"bytes,1,opt,name=workflow_id,json=workflowId,proto3" json:"workflow_id,omitempty"`
+	WorkflowName  string                 `protobuf:"bytes,2,opt,name=workflow_name,json=workflowName,proto3" json:"workflow_name,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Results       *structpb.Struct       `protobuf:"bytes,4,opt,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteWorkflowResponse) Reset() {
+	*x = ExecuteWorkflowResponse{}
+	mi := &file_analysis_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteWorkflowResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteWorkflowResponse) ProtoMessage() {}
+
+func (x *ExecuteWorkflowResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteWorkflowResponse.ProtoReflect.Descriptor instead.
+func (*ExecuteWorkflowResponse) Descriptor() ([]byte, []int) {
+	return file_analysis_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ExecuteWorkflowResponse) GetWorkflowId() string {
+	if x != nil {
+		return x.WorkflowId
+	}
+	return ""
+}
+
+func (x *ExecuteWorkflowResponse) GetWorkflowName() string {
+	if x != nil {
+		return x.WorkflowName
+	}
+	return ""
+}
+
+func (x *ExecuteWorkflowResponse) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *ExecuteWorkflowResponse) GetResults() *structpb.Struct {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+var File_analysis_proto protoreflect.FileDescriptor
+
+const file_analysis_proto_rawDesc = "" +
+	"\n" +
+	"\x0eanalysis.proto\x12\x18agenticflows.analysis.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"/\n" +
+	"\tSourceRef\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\"\xe2\x03\n" +
+	"\x0fAnalysisRequest\x12#\n" +
+	"\ranalysis_type\x18\x01 \x01(\tR\fanalysisType\x12\x1f\n" +
+	"\vworkflow_id\x18\x02 \x01(\tR\n" +
+	"workflowId\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\x12)\n" +
+	"\x10conversation_ids\x18\x04 \x03(\tR\x0fconversationIds\x127\n" +
+	"\n" +
+	"parameters\x18\x05 \x01(\v2\x17.google.protobuf.StructR\n" +
+	"parameters\x12+\n" +
+	"\x04data\x18\x06 \x01(\v2\x17.google.protobuf.StructR\x04data\x12=\n" +
+	"\asources\x18\a \x03(\v2#.agenticflows.analysis.v1.SourceRefR\asources\x12\x19\n" +
+	"\x05cache\x18\b \x01(\bH\x00R\x05cache\x88\x01\x01\x12G\n" +
+	"\x04tags\x18\t \x03(\v23.agenticflows.analysis.v1.AnalysisRequest.TagsEntryR\x04tags\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\b\n" +
+	"\x06_cache\"\x94\x01\n" +
+	"\x0fSampleAgreement\x12\x18\n" +
+	"\asamples\x18\x01 \x01(\x05R\asamples\x12\x1c\n" +
+	"\tsucceeded\x18\x02 \x01(\x05R\tsucceeded\x12\x1c\n" +
+	"\tagreement\x18\x03 \x01(\x01R\tagreement\x12+\n" +
+	"\x11sample_confidence\x18\x04 \x01(\x01R\x10sampleConfidence\"O\n" +
+	"\vDataQuality\x12\x1e\n" +
+	"\n" +
+	"assessment\x18\x01 \x01(\tR\n" +
+	"assessment\x12 \n" +
+	"\vlimitations\x18\x02 \x03(\tR\vlimitations\"\xdd\x03\n" +
+	"\x10AnalysisResponse\x12#\n" +
+	"\ranalysis_type\x18\x01 \x01(\tR\fanalysisType\x12\x1f\n" +
+	"\vworkflow_id\x18\x02 \x01(\tR\n" +
+	"workflowId\x12\x1b\n" +
+	"\tresult_id\x18\x03 \x01(\tR\bresultId\x128\n" +
+	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x120\n" +
+	"\aresults\x18\x05 \x01(\v2\x16.google.protobuf.ValueR\aresults\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x06 \x01(\x01R\n" +
+	"confidence\x12H\n" +
+	"\fdata_quality\x18\a \x01(\v2%.agenticflows.analysis.v1.DataQualityR\vdataQuality\x12K\n" +
+	"\vreliability\x18\b \x01(\v2).agenticflows.analysis.v1.SampleAgreementR\vreliability\x12\x16\n" +
+	"\x06cached\x18\t \x01(\bR\x06cached\x12+\n" +
+	"\x11suppressed_groups\x18\n" +
+	" \x01(\x05R\x10suppressedGroups\"9\n" +
+	"\rAnalysisChunk\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\"\x9f\x01\n" +
+	"\rAnalysisEvent\x12?\n" +
+	"\x05chunk\x18\x01 \x01(\v2'.agenticflows.analysis.v1.AnalysisChunkH\x00R\x05chunk\x12D\n" +
+	"\x06result\x18\x02 \x01(\v2*.agenticflows.analysis.v1.AnalysisResponseH\x00R\x06resultB\a\n" +
+	"\x05event\"\xe7\x02\n" +
+	"\fChainRequest\x12\x1f\n" +
+	"\vworkflow_id\x18\x01 \x01(\tR\n" +
+	"workflowId\x12\x14\n" +
+	"\x05steps\x18\x02 \x03(\tR\x05steps\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\x127\n" +
+	"\n" +
+	"parameters\x18\x04 \x01(\v2\x17.google.protobuf.StructR\n" +
+	"parameters\x125\n" +
+	"\x16confidence_propagation\x18\x05 \x01(\tR\x15confidencePropagation\x12D\n" +
+	"\x04tags\x18\x06 \x03(\v20.agenticflows.analysis.v1.ChainRequest.TagsEntryR\x04tags\x12\x1d\n" +
+	"\n" +
+	"max_budget\x18\a \x01(\x01R\tmaxBudget\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x9d\x01\n" +
+	"\rChainResponse\x12\x1f\n" +
+	"\vworkflow_id\x18\x01 \x01(\tR\n" +
+	"workflowId\x128\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x121\n" +
+	"\aresults\x18\x03 \x01(\v2\x17.google.protobuf.StructR\aresults\"\xbc\x02\n" +
+	"\x16ExecuteWorkflowRequest\x12\x1f\n" +
+	"\vworkflow_id\x18\x01 \x01(\tR\n" +
+	"workflowId\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x127\n" +
+	"\n" +
+	"parameters\x18\x03 \x01(\v2\x17.google.protobuf.StructR\n" +
+	"parameters\x12+\n" +
+	"\x04data\x18\x04 \x01(\v2\x17.google.protobuf.StructR\x04data\x12N\n" +
+	"\x04tags\x18\x05 \x03(\v2:.agenticflows.analysis.v1.ExecuteWorkflowRequest.TagsEntryR\x04tags\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xcc\x01\n" +
+	"\x17ExecuteWorkflowResponse\x12\x1f\n" +
+	"\vworkflow_id\x18\x01 \x01(\tR\n" +
+	"workflowId\x12#\n" +
+	"\rworkflow_name\x18\x02 \x01(\tR\fworkflowName\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x121\n" +
+	"\aresults\x18\x04 \x01(\v2\x17.google.protobuf.StructR\aresults2\xbd\x03\n" +
+	"\x0fAnalysisService\x12h\n" +
+	"\x0fPerformAnalysis\x12).agenticflows.analysis.v1.AnalysisRequest\x1a*.agenticflows.analysis.v1.AnalysisResponse\x12f\n" +
+	"\x0eStreamAnalysis\x12).agenticflows.analysis.v1.AnalysisRequest\x1a'.agenticflows.analysis.v1.AnalysisEvent0\x01\x12`\n" +
+	"\rChainAnalysis\x12&.agenticflows.analysis.v1.ChainRequest\x1a'.agenticflows.analysis.v1.ChainResponse\x12v\n" +
+	"\x0fExecuteWorkflow\x120.agenticflows.analysis.v1.ExecuteWorkflowRequest\x1a1.agenticflows.analysis.v1.ExecuteWorkflowResponseB3Z1agenticflows/backend/proto/analysis/v1;analysisv1b\x06proto3"
+
+var (
+	file_analysis_proto_rawDescOnce sync.Once
+	file_analysis_proto_rawDescData []byte
+)
+
+func file_analysis_proto_rawDescGZIP() []byte {
+	file_analysis_proto_rawDescOnce.Do(func() {
+		file_analysis_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_analysis_proto_rawDesc), len(file_analysis_proto_rawDesc)))
+	})
+	return file_analysis_proto_rawDescData
+}
+
+var file_analysis_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_analysis_proto_goTypes = []any{
+	(*SourceRef)(nil),               // 0: agenticflows.analysis.v1.SourceRef
+	(*AnalysisRequest)(nil),         // 1: agenticflows.analysis.v1.AnalysisRequest
+	(*SampleAgreement)(nil),         // 2: agenticflows.analysis.v1.SampleAgreement
+	(*DataQuality)(nil),             // 3: agenticflows.analysis.v1.DataQuality
+	(*AnalysisResponse)(nil),        // 4: agenticflows.analysis.v1.AnalysisResponse
+	(*AnalysisChunk)(nil),           // 5: agenticflows.analysis.v1.AnalysisChunk
+	(*AnalysisEvent)(nil),           // 6: agenticflows.analysis.v1.AnalysisEvent
+	(*ChainRequest)(nil),            // 7: agenticflows.analysis.v1.ChainRequest
+	(*ChainResponse)(nil),           // 8: agenticflows.analysis.v1.ChainResponse
+	(*ExecuteWorkflowRequest)(nil),  // 9: agenticflows.analysis.v1.ExecuteWorkflowRequest
+	(*ExecuteWorkflowResponse)(nil), // 10: agenticflows.analysis.v1.ExecuteWorkflowResponse
+	nil,                             // 11: agenticflows.analysis.v1.AnalysisRequest.TagsEntry
+	nil,                             // 12: agenticflows.analysis.v1.ChainRequest.TagsEntry
+	nil,                             // 13: agenticflows.analysis.v1.ExecuteWorkflowRequest.TagsEntry
+	(*structpb.Struct)(nil),         // 14: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil),   // 15: google.protobuf.Timestamp
+	(*structpb.Value)(nil),          // 16: google.protobuf.Value
+}
+var file_analysis_proto_depIdxs = []int32{
+	14, // 0: agenticflows.analysis.v1.AnalysisRequest.parameters:type_name -> google.protobuf.Struct
+	14, // 1: agenticflows.analysis.v1.AnalysisRequest.data:type_name -> google.protobuf.Struct
+	0,  // 2: agenticflows.analysis.v1.AnalysisRequest.sources:type_name -> agenticflows.analysis.v1.SourceRef
+	11, // 3: agenticflows.analysis.v1.AnalysisRequest.tags:type_name -> agenticflows.analysis.v1.AnalysisRequest.TagsEntry
+	15, // 4: agenticflows.analysis.v1.AnalysisResponse.timestamp:type_name -> google.protobuf.Timestamp
+	16, // 5: agenticflows.analysis.v1.AnalysisResponse.results:type_name -> google.protobuf.Value
+	3,  // 6: agenticflows.analysis.v1.AnalysisResponse.data_quality:type_name -> agenticflows.analysis.v1.DataQuality
+	2,  // 7: agenticflows.analysis.v1.AnalysisResponse.reliability:type_name -> agenticflows.analysis.v1.SampleAgreement
+	5,  // 8: agenticflows.analysis.v1.AnalysisEvent.chunk:type_name -> agenticflows.analysis.v1.AnalysisChunk
+	4,  // 9: agenticflows.analysis.v1.AnalysisEvent.result:type_name -> agenticflows.analysis.v1.AnalysisResponse
+	14, // 10: agenticflows.analysis.v1.ChainRequest.parameters:type_name -> google.protobuf.Struct
+	12, // 11: agenticflows.analysis.v1.ChainRequest.tags:type_name -> agenticflows.analysis.v1.ChainRequest.TagsEntry
+	15, // 12: agenticflows.analysis.v1.ChainResponse.timestamp:type_name -> google.protobuf.Timestamp
+	14, // 13: agenticflows.analysis.v1.ChainResponse.results:type_name -> google.protobuf.Struct
+	14, // 14: agenticflows.analysis.v1.ExecuteWorkflowRequest.parameters:type_name -> google.protobuf.Struct
+	14, // 15: agenticflows.analysis.v1.ExecuteWorkflowRequest.data:type_name -> google.protobuf.Struct
+	13, // 16: agenticflows.analysis.v1.ExecuteWorkflowRequest.tags:type_name -> agenticflows.analysis.v1.ExecuteWorkflowRequest.TagsEntry
+	15, // 17: agenticflows.analysis.v1.ExecuteWorkflowResponse.timestamp:type_name -> google.protobuf.Timestamp
+	14, // 18: agenticflows.analysis.v1.ExecuteWorkflowResponse.results:type_name -> google.protobuf.Struct
+	1,  // 19: agenticflows.analysis.v1.AnalysisService.PerformAnalysis:input_type -> agenticflows.analysis.v1.AnalysisRequest
+	1,  // 20: agenticflows.analysis.v1.AnalysisService.StreamAnalysis:input_type -> agenticflows.analysis.v1.AnalysisRequest
+	7,  // 21: agenticflows.analysis.v1.AnalysisService.ChainAnalysis:input_type -> agenticflows.analysis.v1.ChainRequest
+	9,  // 22: agenticflows.analysis.v1.AnalysisService.ExecuteWorkflow:input_type -> agenticflows.analysis.v1.ExecuteWorkflowRequest
+	4,  // 23: agenticflows.analysis.v1.AnalysisService.PerformAnalysis:output_type -> agenticflows.analysis.v1.AnalysisResponse
+	6,  // 24: agenticflows.analysis.v1.AnalysisService.StreamAnalysis:output_type -> agenticflows.analysis.v1.AnalysisEvent
+	8,  // 25: agenticflows.analysis.v1.AnalysisService.ChainAnalysis:output_type -> agenticflows.analysis.v1.ChainResponse
+	10, // 26: agenticflows.analysis.v1.AnalysisService.ExecuteWorkflow:output_type -> agenticflows.analysis.v1.ExecuteWorkflowResponse
+	23, // [23:27] is the sub-list for method output_type
+	19, // [19:23] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
+}
+
+func init() { file_analysis_proto_init() }
+func file_analysis_proto_init() {
+	if File_analysis_proto != nil {
+		return
+	}
+	file_analysis_proto_msgTypes[1].OneofWrappers = []any{}
+	file_analysis_proto_msgTypes[6].OneofWrappers = []any{
+		(*AnalysisEvent_Chunk)(nil),
+		(*AnalysisEvent_Result)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_analysis_proto_rawDesc), len(file_analysis_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_analysis_proto_goTypes,
+		DependencyIndexes: file_analysis_proto_depIdxs,
+		MessageInfos:      file_analysis_proto_msgTypes,
+	}.Build()
+	File_analysis_proto = out.File
+	file_analysis_proto_goTypes = nil
+	file_analysis_proto_depIdxs = nil
+}
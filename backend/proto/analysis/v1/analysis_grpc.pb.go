@@ -0,0 +1,253 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.1
+// - protoc             (unknown)
+// source: analysis.proto
+
+// The gRPC interface of the analysis API. It mirrors /api/analysis,
+// /api/analysis/chain and /api/workflows/{id}/execute with typed messages.
+// Results keep the JSON structure of the HTTP API as a Struct.
+
+package analysisv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AnalysisService_PerformAnalysis_FullMethodName = "/agenticflows.analysis.v1.AnalysisService/PerformAnalysis"
+	AnalysisService_StreamAnalysis_FullMethodName  = "/agenticflows.analysis.v1.AnalysisService/StreamAnalysis"
+	AnalysisService_ChainAnalysis_FullMethodName   = "/agenticflows.analysis.v1.AnalysisService/ChainAnalysis"
+	AnalysisService_ExecuteWorkflow_FullMethodName = "/agenticflows.analysis.v1.AnalysisService/ExecuteWorkflow"
+)
+
+// AnalysisServiceClient is the client API for AnalysisService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AnalysisServiceClient interface {
+	// PerformAnalysis runs one analysis, like POST /api/analysis
+	PerformAnalysis(ctx context.Context, in *AnalysisRequest, opts ...grpc.CallOption) (*AnalysisResponse, error)
+	// StreamAnalysis runs one analysis and streams the partial model output
+	// before the response, like POST /api/analysis with stream set
+	StreamAnalysis(ctx context.Context, in *AnalysisRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AnalysisEvent], error)
+	// ChainAnalysis runs a chain of analyses, like POST /api/analysis/chain
+	ChainAnalysis(ctx context.Context, in *ChainRequest, opts ...grpc.CallOption) (*ChainResponse, error)
+	// ExecuteWorkflow runs a stored workflow, like POST /api/workflows/{id}/execute
+	ExecuteWorkflow(ctx context.Context, in *ExecuteWorkflowRequest, opts ...grpc.CallOption) (*ExecuteWorkflowResponse, error)
+}
+
+type analysisServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAnalysisServiceClient(cc grpc.ClientConnInterface) AnalysisServiceClient {
+	return &analysisServiceClient{cc}
+}
+
+func (c *analysisServiceClient) PerformAnalysis(ctx context.Context, in *AnalysisRequest, opts ...grpc.CallOption) (*AnalysisResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnalysisResponse)
+	err := c.cc.Invoke(ctx, AnalysisService_PerformAnalysis_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *analysisServiceClient) StreamAnalysis(ctx context.Context, in *AnalysisRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AnalysisEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AnalysisService_ServiceDesc.Streams[0], AnalysisService_StreamAnalysis_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AnalysisRequest, AnalysisEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AnalysisService_StreamAnalysisClient = grpc.ServerStreamingClient[AnalysisEvent]
+
+func (c *analysisServiceClient) ChainAnalysis(ctx context.Context, in *ChainRequest, opts ...grpc.CallOption) (*ChainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChainResponse)
+	err := c.cc.Invoke(ctx, AnalysisService_ChainAnalysis_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *analysisServiceClient) ExecuteWorkflow(ctx context.Context, in *ExecuteWorkflowRequest, opts ...grpc.CallOption) (*ExecuteWorkflowResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExecuteWorkflowResponse)
+	err := c.cc.Invoke(ctx, AnalysisService_ExecuteWorkflow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AnalysisServiceServer is the server API for AnalysisService service.
+// All implementations must embed UnimplementedAnalysisServiceServer
+// for forward compatibility.
+type AnalysisServiceServer interface {
+	// PerformAnalysis runs one analysis, like POST /api/analysis
+	PerformAnalysis(context.Context, *AnalysisRequest) (*AnalysisResponse, error)
+	// StreamAnalysis runs one analysis and streams the partial model output
+	// before the response, like POST /api/analysis with stream set
+	StreamAnalysis(*AnalysisRequest, grpc.ServerStreamingServer[AnalysisEvent]) error
+	// ChainAnalysis runs a chain of analyses, like POST /api/analysis/chain
+	ChainAnalysis(context.Context, *ChainRequest) (*ChainResponse, error)
+	// ExecuteWorkflow runs a stored workflow, like POST /api/workflows/{id}/execute
+	ExecuteWorkflow(context.Context, *ExecuteWorkflowRequest) (*ExecuteWorkflowResponse, error)
+	mustEmbedUnimplementedAnalysisServiceServer()
+}
+
+// UnimplementedAnalysisServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAnalysisServiceServer struct{}
+
+func (UnimplementedAnalysisServiceServer) PerformAnalysis(context.Context, *AnalysisRequest) (*AnalysisResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method PerformAnalysis not implemented")
+}
+func (UnimplementedAnalysisServiceServer) StreamAnalysis(*AnalysisRequest, grpc.ServerStreamingServer[AnalysisEvent]) error {
+	return status.Error(codes.Unimplemented, "method StreamAnalysis not implemented")
+}
+func (UnimplementedAnalysisServiceServer) ChainAnalysis(context.Context, *ChainRequest) (*ChainResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ChainAnalysis not implemented")
+}
+func (UnimplementedAnalysisServiceServer) ExecuteWorkflow(context.Context, *ExecuteWorkflowRequest) (*ExecuteWorkflowResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ExecuteWorkflow not implemented")
+}
+func (UnimplementedAnalysisServiceServer) mustEmbedUnimplementedAnalysisServiceServer() {}
+func (UnimplementedAnalysisServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeAnalysisServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AnalysisServiceServer will
+// result in compilation errors.
+type UnsafeAnalysisServiceServer interface {
+	mustEmbedUnimplementedAnalysisServiceServer()
+}
+
+func RegisterAnalysisServiceServer(s grpc.ServiceRegistrar, srv AnalysisServiceServer) {
+	// If the following call panics, it indicates UnimplementedAnalysisServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AnalysisService_ServiceDesc, srv)
+}
+
+func _AnalysisService_PerformAnalysis_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnalysisRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalysisServiceServer).PerformAnalysis(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalysisService_PerformAnalysis_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalysisServiceServer).PerformAnalysis(ctx, req.(*AnalysisRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnalysisService_StreamAnalysis_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AnalysisRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AnalysisServiceServer).StreamAnalysis(m, &grpc.GenericServerStream[AnalysisRequest, AnalysisEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AnalysisService_StreamAnalysisServer = grpc.ServerStreamingServer[AnalysisEvent]
+
+func _AnalysisService_ChainAnalysis_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalysisServiceServer).ChainAnalysis(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalysisService_ChainAnalysis_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalysisServiceServer).ChainAnalysis(ctx, req.(*ChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnalysisService_ExecuteWorkflow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecuteWorkflowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalysisServiceServer).ExecuteWorkflow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalysisService_ExecuteWorkflow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalysisServiceServer).ExecuteWorkflow(ctx, req.(*ExecuteWorkflowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AnalysisService_ServiceDesc is the grpc.ServiceDesc for AnalysisService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AnalysisService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "agenticflows.analysis.v1.AnalysisService",
+	HandlerType: (*AnalysisServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PerformAnalysis",
+			Handler:    _AnalysisService_PerformAnalysis_Handler,
+		},
+		{
+			MethodName: "ChainAnalysis",
+			Handler:    _AnalysisService_ChainAnalysis_Handler,
+		},
+		{
+			MethodName: "ExecuteWorkflow",
+			Handler:    _AnalysisService_ExecuteWorkflow_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamAnalysis",
+			Handler:       _AnalysisService_StreamAnalysis_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "analysis.proto",
+}
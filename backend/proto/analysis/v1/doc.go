@@ -0,0 +1,8 @@
+// Package analysisv1 holds the generated protobuf messages and gRPC service of
+// analysis.proto. The generated code is checked in; after changing analysis.proto,
+// regenerate it with protoc, protoc-gen-go and protoc-gen-go-grpc installed:
+//
+//	go generate ./proto/...
+package analysisv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative analysis.proto
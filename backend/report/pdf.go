@@ -0,0 +1,261 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PDF page layout, in points on an A4 page
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 56
+	pdfBullet     = 12 // Indent of the text of a bullet past the bullet
+)
+
+// PDF fonts: the standard Helvetica faces, which every viewer has
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+	fontItalic  = "F3"
+)
+
+var pdfFonts = []struct{ name, base string }{
+	{fontRegular, "Helvetica"},
+	{fontBold, "Helvetica-Bold"},
+	{fontItalic, "Helvetica-Oblique"},
+}
+
+// helveticaWidths are the widths of the printable ASCII characters in Helvetica, in
+// thousandths of the font size, from ' ' to '~'
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// pdfBlock is a paragraph of a PDF report in one font, wrapped to the page width
+type pdfBlock struct {
+	font        string
+	size        float64
+	gray        float64 // 0 is black
+	indent      float64
+	bullet      string // Drawn in the indent before the first line
+	spaceBefore float64
+}
+
+// pdfLayout lays out blocks of text on pages
+type pdfLayout struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// writePDF renders a report as a PDF document of text
+func writePDF(w io.Writer, r *Report) error {
+	layout := &pdfLayout{}
+	layout.newPage()
+
+	layout.add(pdfBlock{font: fontBold, size: 18}, r.Title)
+	meta := "Generated " + formatDate(r.GeneratedAt)
+	if r.WorkflowID != "" {
+		meta = "Workflow " + r.WorkflowID + ", generated " + formatDate(r.GeneratedAt)
+	}
+	layout.add(pdfBlock{font: fontRegular, size: 9, gray: 0.4, spaceBefore: 4}, meta)
+
+	if len(r.Highlights) > 0 {
+		layout.add(pdfBlock{font: fontBold, size: 14, spaceBefore: 18}, "Executive Summary")
+		for _, highlight := range r.Highlights {
+			layout.add(pdfBlock{font: fontRegular, size: 10, indent: pdfBullet, bullet: "•", spaceBefore: 4}, highlight)
+		}
+	}
+
+	for _, section := range r.Sections {
+		layout.add(pdfBlock{font: fontBold, size: 14, spaceBefore: 18}, section.Title)
+		if section.ResultID != "" {
+			source := "From result " + section.ResultID
+			if section.CreatedAt != "" {
+				source += " of " + section.CreatedAt
+			}
+			layout.add(pdfBlock{font: fontItalic, size: 9, gray: 0.4, spaceBefore: 2}, source)
+		}
+		for _, item := range section.Items {
+			if item.Title != "" {
+				layout.add(pdfBlock{font: fontBold, size: 10, indent: pdfBullet, bullet: "•", spaceBefore: 6}, item.Title)
+				if item.Text != "" {
+					layout.add(pdfBlock{font: fontRegular, size: 10, indent: pdfBullet, spaceBefore: 1}, item.Text)
+				}
+			} else {
+				layout.add(pdfBlock{font: fontRegular, size: 10, indent: pdfBullet, bullet: "•", spaceBefore: 6}, item.Text)
+			}
+			for _, detail := range item.Details {
+				layout.add(pdfBlock{font: fontRegular, size: 9, gray: 0.25, indent: 2 * pdfBullet, bullet: "–", spaceBefore: 1}, detail)
+			}
+		}
+		for _, note := range section.Notes {
+			layout.add(pdfBlock{font: fontRegular, size: 10, spaceBefore: 8}, note)
+		}
+		for _, list := range section.Lists {
+			layout.add(pdfBlock{font: fontBold, size: 11, spaceBefore: 10}, list.Title)
+			for _, entry := range list.Entries {
+				layout.add(pdfBlock{font: fontRegular, size: 10, indent: pdfBullet, bullet: "•", spaceBefore: 3}, entry)
+			}
+		}
+	}
+	return layout.write(w)
+}
+
+// newPage starts a page
+func (l *pdfLayout) newPage() {
+	l.pages = append(l.pages, &bytes.Buffer{})
+	l.y = pdfPageHeight - pdfMargin
+}
+
+// add lays out a block, wrapping its text and starting pages as needed
+func (l *pdfLayout) add(block pdfBlock, text string) {
+	leading := block.size * 1.3
+	width := pdfPageWidth - 2*pdfMargin - block.indent
+	l.y -= block.spaceBefore
+	for i, line := range wrapText(text, block.font, block.size, width) {
+		if l.y-leading < pdfMargin {
+			l.newPage()
+		}
+		l.y -= leading
+		page := l.pages[len(l.pages)-1]
+		fmt.Fprintf(page, "%.2f g\n", block.gray)
+		if i == 0 && block.bullet != "" {
+			fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+				fontRegular, block.size, pdfMargin+block.indent-pdfBullet+2, l.y, pdfString(block.bullet))
+		}
+		fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+			block.font, block.size, pdfMargin+block.indent, l.y, pdfString(line))
+	}
+}
+
+// write writes the document: a catalog, the page tree, the fonts and each page with its
+// content stream, followed by the cross-reference table
+func (l *pdfLayout) write(w io.Writer) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	firstPage := 3 + len(pdfFonts)
+	kids := make([]string, len(l.pages))
+	for i := range l.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	fonts := make([]string, len(pdfFonts))
+	for i, font := range pdfFonts {
+		fonts[i] = fmt.Sprintf("/%s %d 0 R", font.name, 3+i)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(l.pages)))
+	for _, font := range pdfFonts {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", font.base))
+	}
+	for i, page := range l.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, strings.Join(fonts, " "), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// wrapText breaks text into lines no wider than width, splitting words longer than a line
+func wrapText(text, font string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if textWidth(candidate, font, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = ""
+			for textWidth(word, font, size) > width {
+				cut := len([]rune(word)) - 1
+				for cut > 1 && textWidth(string([]rune(word)[:cut]), font, size) > width {
+					cut--
+				}
+				lines = append(lines, string([]rune(word)[:cut]))
+				word = string([]rune(word)[cut:])
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// textWidth estimates the width of text in points. Bold characters are about a tenth
+// wider than regular ones; characters outside ASCII count as the width of a digit.
+func textWidth(text, font string, size float64) float64 {
+	total := 0
+	for _, r := range text {
+		if r >= ' ' && r <= '~' {
+			total += helveticaWidths[r-' ']
+		} else {
+			total += 556
+		}
+	}
+	width := float64(total) * size / 1000
+	if font == fontBold {
+		width *= 1.1
+	}
+	return width
+}
+
+// winAnsiSpecials are the characters of WinAnsiEncoding outside Latin-1
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// pdfString encodes text as the contents of a PDF string in WinAnsiEncoding, replacing
+// the characters it can't encode with question marks
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		var c byte
+		switch special, ok := winAnsiSpecials[r]; {
+		case ok:
+			c = special
+		case r == '\t':
+			c = ' '
+		case r >= ' ' && r <= '~' || r >= 0xa0 && r <= 0xff:
+			c = byte(r)
+		default:
+			c = '?'
+		}
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
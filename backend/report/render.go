@@ -0,0 +1,85 @@
+package report
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// Report formats supported by Write
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatPDF      = "pdf"
+)
+
+// envTemplateDir is the directory of templates replacing the default ones: report.md.tmpl
+// for Markdown and report.html.tmpl for HTML reports
+const envTemplateDir = "REPORT_TEMPLATE_DIR"
+
+// templateFuncs are the functions available to report templates
+var templateFuncs = map[string]interface{}{
+	"date": formatDate,
+}
+
+// formatDate formats when a report was generated
+func formatDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 MST")
+}
+
+// ValidFormat reports whether format is supported
+func ValidFormat(format string) bool {
+	return format == FormatMarkdown || format == FormatHTML || format == FormatPDF
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// Write renders a report in the given format
+func Write(w io.Writer, format string, r *Report) error {
+	switch format {
+	case FormatMarkdown:
+		tmpl, err := template.New("report").Funcs(templateFuncs).Parse(templateSource("report.md.tmpl", markdownTemplate))
+		if err != nil {
+			return fmt.Errorf("invalid Markdown report template: %w", err)
+		}
+		return tmpl.Execute(w, r)
+	case FormatHTML:
+		tmpl, err := htmltemplate.New("report").Funcs(templateFuncs).Parse(templateSource("report.html.tmpl", htmlTemplate))
+		if err != nil {
+			return fmt.Errorf("invalid HTML report template: %w", err)
+		}
+		return tmpl.Execute(w, r)
+	case FormatPDF:
+		return writePDF(w, r)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// templateSource returns the template of REPORT_TEMPLATE_DIR with the given name, or the
+// default template when there is none
+func templateSource(name, defaultSource string) string {
+	dir := os.Getenv(envTemplateDir)
+	if dir == "" {
+		return defaultSource
+	}
+	source, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return defaultSource
+	}
+	return string(source)
+}
@@ -0,0 +1,377 @@
+// Package report renders the stored analysis results of a workflow as an executive
+// report in Markdown, HTML or PDF. A report has a section per kind of result, in the
+// order of sectionOrder, and an executive summary of the highlights.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/models"
+)
+
+// Section titles of a report, in the order they appear
+const (
+	SectionTrends          = "Trends"
+	SectionPatterns        = "Patterns"
+	SectionFindings        = "Findings"
+	SectionRecommendations = "Recommendations"
+	SectionActionPlan      = "Action Plan"
+)
+
+var sectionOrder = []string{SectionTrends, SectionPatterns, SectionFindings, SectionRecommendations, SectionActionPlan}
+
+// resultSections are the analysis types a report has a section for
+var resultSections = map[string]string{
+	"trends":          SectionTrends,
+	"patterns":        SectionPatterns,
+	"findings":        SectionFindings,
+	"recommendations": SectionRecommendations,
+	"plan":            SectionActionPlan,
+}
+
+// Report is an executive report over the results of a workflow
+type Report struct {
+	Title       string
+	WorkflowID  string
+	GeneratedAt time.Time
+	// Highlights are the points of the executive summary
+	Highlights []string
+	Sections   []Section
+}
+
+// Section is a section of a report, such as the trends of a workflow: its items, then its
+// notes and lists
+type Section struct {
+	Title string
+	// ResultID and CreatedAt identify the stored result the section was made from
+	ResultID  string
+	CreatedAt string
+	Items     []Item
+	Lists     []List
+	Notes     []string
+}
+
+// Item is an entry of a section, such as a trend or a recommended action
+type Item struct {
+	Title   string
+	Text    string
+	Details []string
+}
+
+// List is a titled list of a section, such as the success metrics of recommendations
+type List struct {
+	Title   string
+	Entries []string
+}
+
+// New returns an empty report
+func New(title, workflowID string) *Report {
+	if title == "" {
+		title = "Analysis Report"
+		if workflowID != "" {
+			title = "Analysis Report: " + workflowID
+		}
+	}
+	return &Report{Title: title, WorkflowID: workflowID, GeneratedAt: time.Now()}
+}
+
+// FromResults builds the report of a workflow from its stored results, as returned by
+// db.GetAnalysisResultsByWorkflow, newest first. Each section is made from the most
+// recent result of its type; results of other types are left out.
+func FromResults(title, workflowID string, stored []map[string]interface{}) (*Report, error) {
+	report := New(title, workflowID)
+	used := map[string]bool{}
+	for _, result := range stored {
+		analysisType, _ := result["analysis_type"].(string)
+		if _, ok := resultSections[analysisType]; !ok || used[analysisType] {
+			continue
+		}
+		used[analysisType] = true
+
+		id, _ := result["id"].(string)
+		createdAt, _ := result["created_at"].(string)
+		if err := report.addResult(analysisType, result["results"], id, createdAt); err != nil {
+			return nil, fmt.Errorf("result %s: %w", id, err)
+		}
+	}
+	return report, nil
+}
+
+// AddResult adds the results of an analysis, as returned by the API, to the report:
+// trends, patterns, findings, recommendations or plan
+func (r *Report) AddResult(analysisType string, results interface{}) error {
+	return r.addResult(analysisType, results, "", "")
+}
+
+// AddSection adds a section to the report. Sections of the same title are merged.
+func (r *Report) AddSection(section Section) {
+	for i := range r.Sections {
+		if r.Sections[i].Title == section.Title {
+			existing := &r.Sections[i]
+			existing.Items = append(existing.Items, section.Items...)
+			existing.Lists = append(existing.Lists, section.Lists...)
+			existing.Notes = append(existing.Notes, section.Notes...)
+			if existing.ResultID == "" {
+				existing.ResultID, existing.CreatedAt = section.ResultID, section.CreatedAt
+			}
+			return
+		}
+	}
+	r.Sections = append(r.Sections, section)
+
+	// Keep the known sections in order, and the others after them in the order added
+	rank := func(title string) int {
+		for i, known := range sectionOrder {
+			if known == title {
+				return i
+			}
+		}
+		return len(sectionOrder)
+	}
+	sort.SliceStable(r.Sections, func(i, j int) bool {
+		return rank(r.Sections[i].Title) < rank(r.Sections[j].Title)
+	})
+}
+
+// Empty reports whether the report has nothing to show
+func (r *Report) Empty() bool {
+	return len(r.Highlights) == 0 && len(r.Sections) == 0
+}
+
+// addResult decodes results into the type of an analysis and adds its section
+func (r *Report) addResult(analysisType string, results interface{}, resultID, createdAt string) error {
+	section := Section{ResultID: resultID, CreatedAt: createdAt}
+	switch analysisType {
+	case "trends":
+		var trends analysis.TrendsResult
+		if err := decodeResult(results, &trends); err != nil {
+			return err
+		}
+		r.Highlights = append(r.Highlights, trends.OverallInsights...)
+		section = trendsSection(section, trends)
+	case "patterns":
+		var patterns analysis.PatternsResult
+		if err := decodeResult(results, &patterns); err != nil {
+			return err
+		}
+		section = patternsSection(section, patterns)
+	case "findings":
+		var findings analysis.FindingsResult
+		if err := decodeResult(results, &findings); err != nil {
+			return err
+		}
+		section = findingsSection(section, findings)
+		if len(findings.Recommendations) > 0 {
+			r.AddSection(Section{Title: SectionRecommendations, Lists: []List{{Title: "From the findings", Entries: findings.Recommendations}}})
+		}
+	case "recommendations":
+		var recommendations models.RecommendationResponse
+		if err := decodeResult(results, &recommendations); err != nil {
+			return err
+		}
+		if len(recommendations.ImmediateActions) > 0 {
+			r.Highlights = append(r.Highlights, "Top recommendation: "+recommendations.ImmediateActions[0].Action)
+		}
+		section = recommendationsSection(section, recommendations)
+	case "plan":
+		var plan models.ActionPlan
+		if err := decodeResult(results, &plan); err != nil {
+			return err
+		}
+		section = planSection(section, plan)
+	default:
+		return fmt.Errorf("reports have no section for %s results", analysisType)
+	}
+	if len(section.Items) > 0 || len(section.Lists) > 0 || len(section.Notes) > 0 {
+		r.AddSection(section)
+	}
+	return nil
+}
+
+// decodeResult converts results, decoded JSON or a struct of the same shape, into target
+func decodeResult(results interface{}, target interface{}) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("unexpected results: %w", err)
+	}
+	return nil
+}
+
+func trendsSection(section Section, trends analysis.TrendsResult) Section {
+	section.Title = SectionTrends
+	for _, trend := range trends.Trends {
+		item := Item{Title: trend.FocusArea, Text: trend.Trend}
+		if trend.Confidence > 0 {
+			item.Details = append(item.Details, fmt.Sprintf("Confidence: %.0f%%", 100*trend.Confidence))
+		}
+		if trend.SupportingData != "" {
+			item.Details = append(item.Details, "Supporting data: "+trend.SupportingData)
+		}
+		section.Items = append(section.Items, item)
+	}
+	if trends.DataQuality.Assessment != "" {
+		section.Notes = append(section.Notes, "Data quality: "+trends.DataQuality.Assessment)
+	}
+	if len(trends.DataQuality.Limitations) > 0 {
+		section.Lists = append(section.Lists, List{Title: "Limitations", Entries: trends.DataQuality.Limitations})
+	}
+	return section
+}
+
+func patternsSection(section Section, patterns analysis.PatternsResult) Section {
+	section.Title = SectionPatterns
+	for _, pattern := range patterns.Patterns {
+		item := Item{Title: pattern.PatternDescription, Text: pattern.Significance}
+		if pattern.PatternType != "" {
+			item.Details = append(item.Details, "Type: "+pattern.PatternType)
+		}
+		if pattern.Occurrences > 0 {
+			item.Details = append(item.Details, fmt.Sprintf("Occurrences: %d", pattern.Occurrences))
+		}
+		if len(pattern.Examples) > 0 {
+			item.Details = append(item.Details, "Examples: "+strings.Join(pattern.Examples, "; "))
+		}
+		section.Items = append(section.Items, item)
+	}
+	for _, pattern := range patterns.UnexpectedPatterns {
+		item := Item{Title: "Unexpected: " + pattern.Description}
+		if len(pattern.PotentialCauses) > 0 {
+			item.Details = append(item.Details, "Potential causes: "+strings.Join(pattern.PotentialCauses, "; "))
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+func findingsSection(section Section, findings analysis.FindingsResult) Section {
+	section.Title = SectionFindings
+	for _, finding := range findings.Findings {
+		item := Item{Title: finding.Question, Text: finding.Answer}
+		if finding.Confidence > 0 {
+			item.Details = append(item.Details, fmt.Sprintf("Confidence: %.0f%%", 100*finding.Confidence))
+		}
+		if finding.Coverage != "" {
+			item.Details = append(item.Details, "Coverage: "+finding.Coverage)
+		}
+		for _, evidence := range finding.SupportingEvidence {
+			item.Details = append(item.Details, "Evidence: "+evidence)
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+func recommendationsSection(section Section, recommendations models.RecommendationResponse) Section {
+	section.Title = SectionRecommendations
+	for _, action := range recommendations.ImmediateActions {
+		item := Item{Title: action.Action, Text: action.Rationale}
+		if action.Priority > 0 {
+			item.Details = append(item.Details, fmt.Sprintf("Priority: %d", action.Priority))
+		}
+		if action.ExpectedImpact != "" {
+			item.Details = append(item.Details, "Expected impact: "+action.ExpectedImpact)
+		}
+		if action.Effort != "" {
+			item.Details = append(item.Details, "Effort: "+action.Effort)
+		}
+		section.Items = append(section.Items, item)
+	}
+	if len(recommendations.ImplementationNotes) > 0 {
+		section.Lists = append(section.Lists, List{Title: "Implementation notes", Entries: recommendations.ImplementationNotes})
+	}
+	if len(recommendations.SuccessMetrics) > 0 {
+		section.Lists = append(section.Lists, List{Title: "Success metrics", Entries: recommendations.SuccessMetrics})
+	}
+	return section
+}
+
+func planSection(section Section, plan models.ActionPlan) Section {
+	section.Title = SectionActionPlan
+	if len(plan.Goals) > 0 {
+		section.Lists = append(section.Lists, List{Title: "Goals", Entries: plan.Goals})
+	}
+	for _, horizon := range []struct {
+		name    string
+		actions []models.ActionItem
+	}{
+		{"Immediate", plan.ImmediateActions},
+		{"Short term", plan.ShortTermActions},
+		{"Long term", plan.LongTermActions},
+	} {
+		for _, action := range horizon.actions {
+			item := Item{Title: action.Action, Text: action.Description, Details: []string{"Horizon: " + horizon.name}}
+			if action.Priority > 0 {
+				item.Details = append(item.Details, fmt.Sprintf("Priority: %d", action.Priority))
+			}
+			if action.EstimatedEffort != "" {
+				item.Details = append(item.Details, "Effort: "+action.EstimatedEffort)
+			}
+			if action.ResponsibleRole != "" {
+				item.Details = append(item.Details, "Owner: "+action.ResponsibleRole)
+			}
+			if len(action.Dependencies) > 0 {
+				item.Details = append(item.Details, "Depends on: "+strings.Join(action.Dependencies, ", "))
+			}
+			section.Items = append(section.Items, item)
+		}
+	}
+
+	var timeline []string
+	for _, phase := range plan.Timeline {
+		entry := phase.Phase
+		var when []string
+		if phase.Duration != "" {
+			when = append(when, phase.Duration)
+		}
+		if phase.StartDate != "" && phase.EndDate != "" {
+			when = append(when, phase.StartDate+" to "+phase.EndDate)
+		}
+		if len(when) > 0 {
+			entry += " (" + strings.Join(when, ", ") + ")"
+		}
+		if phase.Description != "" {
+			entry += ": " + phase.Description
+		}
+		if len(phase.Milestones) > 0 {
+			entry += ". Milestones: " + strings.Join(phase.Milestones, "; ")
+		}
+		timeline = append(timeline, entry)
+	}
+	if len(timeline) > 0 {
+		section.Lists = append(section.Lists, List{Title: "Timeline", Entries: timeline})
+	}
+
+	var risks []string
+	for _, risk := range plan.RisksMitigations {
+		entry := risk.Risk
+		if risk.Impact != "" || risk.Probability != "" {
+			entry += fmt.Sprintf(" (impact %s, probability %s)", orUnknown(risk.Impact), orUnknown(risk.Probability))
+		}
+		if risk.MitigationPlan != "" {
+			entry += ": " + risk.MitigationPlan
+		}
+		risks = append(risks, entry)
+	}
+	if len(risks) > 0 {
+		section.Lists = append(section.Lists, List{Title: "Risks and mitigations", Entries: risks})
+	}
+	if len(plan.SuccessMetrics) > 0 {
+		section.Lists = append(section.Lists, List{Title: "Success metrics", Entries: plan.SuccessMetrics})
+	}
+	return section
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
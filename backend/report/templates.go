@@ -0,0 +1,108 @@
+package report
+
+// markdownTemplate is the default template of Markdown reports
+const markdownTemplate = `# {{.Title}}
+
+{{if .WorkflowID}}Workflow ` + "`{{.WorkflowID}}`" + `, generated {{date .GeneratedAt}}{{else}}Generated {{date .GeneratedAt}}{{end}}
+{{- if .Highlights}}
+
+## Executive Summary
+{{range .Highlights}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- range .Sections}}
+
+## {{.Title}}
+{{- if .ResultID}}
+
+_From result {{.ResultID}}{{if .CreatedAt}} of {{.CreatedAt}}{{end}}_
+{{- end}}
+{{- if .Items}}
+{{range .Items}}
+- {{if .Title}}**{{.Title}}**{{if .Text}}: {{end}}{{end}}{{.Text}}
+{{- range .Details}}
+  - {{.}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- range .Notes}}
+
+{{.}}
+{{- end}}
+{{- range .Lists}}
+
+### {{.Title}}
+{{range .Entries}}
+- {{.}}
+{{- end}}
+{{- end}}
+{{- end}}
+`
+
+// htmlTemplate is the default template of HTML reports
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; line-height: 1.5; }
+h1 { border-bottom: 2px solid #d0d7de; padding-bottom: .3rem; }
+h2 { margin-top: 2rem; border-bottom: 1px solid #d0d7de; padding-bottom: .2rem; }
+.meta, .source { color: #656d76; font-size: .9rem; }
+.summary { background: #f6f8fa; border-left: 4px solid #0969da; padding: .5rem 1rem; }
+li { margin: .3rem 0; }
+ul.details { color: #424a53; font-size: .92rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{if .WorkflowID}}Workflow <code>{{.WorkflowID}}</code>, generated {{date .GeneratedAt}}{{else}}Generated {{date .GeneratedAt}}{{end}}</p>
+{{- if .Highlights}}
+<section class="summary">
+<h2>Executive Summary</h2>
+<ul>
+{{- range .Highlights}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+</section>
+{{- end}}
+{{- range .Sections}}
+<section>
+<h2>{{.Title}}</h2>
+{{- if .ResultID}}
+<p class="source">From result {{.ResultID}}{{if .CreatedAt}} of {{.CreatedAt}}{{end}}</p>
+{{- end}}
+{{- if .Items}}
+<ul>
+{{- range .Items}}
+<li>{{if .Title}}<strong>{{.Title}}</strong>{{if .Text}}: {{end}}{{end}}{{.Text}}
+{{- if .Details}}
+<ul class="details">
+{{- range .Details}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .Notes}}
+<p>{{.}}</p>
+{{- end}}
+{{- range .Lists}}
+<h3>{{.Title}}</h3>
+<ul>
+{{- range .Entries}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`
@@ -0,0 +1,89 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DeepLTranslator calls the translate endpoint of the DeepL API
+type DeepLTranslator struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// deeplResponse is the body of a DeepL translate response
+type deeplResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+// NewDeepLTranslator creates a translator for the DeepL API at baseURL
+func NewDeepLTranslator(baseURL, apiKey string) *DeepLTranslator {
+	return &DeepLTranslator{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (t *DeepLTranslator) Name() string {
+	return ProviderDeepL
+}
+
+// Translate sends text to DeepL, which detects its source language
+func (t *DeepLTranslator) Translate(ctx context.Context, text, targetLanguage string) (Translation, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"text":                []string{text},
+		"target_lang":         strings.ToUpper(targetLanguage),
+		"preserve_formatting": true,
+	})
+	if err != nil {
+		return Translation{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/v2/translate", bytes.NewReader(body))
+	if err != nil {
+		return Translation{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+t.apiKey)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return Translation{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Translation{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Translation{}, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result deeplResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return Translation{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(result.Translations) == 0 {
+		return Translation{}, fmt.Errorf("response holds no translation")
+	}
+
+	return Translation{
+		SourceLanguage: result.Translations[0].DetectedSourceLanguage,
+		TargetLanguage: targetLanguage,
+		Text:           result.Translations[0].Text,
+		Provider:       ProviderDeepL,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package translate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minDetectionWords is the fewest common words a language needs to be detected
+const minDetectionWords = 3
+
+// commonWords are frequent function words of the languages the detector recognizes
+var commonWords = map[string][]string{
+	"en": {"the", "and", "is", "you", "to", "my", "i", "it", "was", "for", "with", "that", "this", "have", "not", "your", "can", "are"},
+	"es": {"el", "la", "de", "que", "y", "en", "los", "es", "por", "mi", "no", "una", "con", "para", "las", "del", "su", "pero"},
+	"fr": {"le", "la", "les", "de", "et", "est", "je", "vous", "une", "pas", "que", "pour", "mon", "des", "avec", "sur", "ce", "mais"},
+	"de": {"der", "die", "das", "und", "ist", "ich", "nicht", "sie", "mit", "ein", "eine", "mein", "zu", "auf", "den", "für", "aber", "wir"},
+	"pt": {"o", "a", "de", "que", "e", "não", "uma", "com", "para", "os", "meu", "minha", "é", "em", "do", "da", "mas", "você"},
+	"it": {"il", "di", "che", "e", "non", "la", "per", "una", "sono", "mi", "con", "ho", "del", "della", "ma", "lo", "gli", "è"},
+	"nl": {"de", "het", "een", "en", "van", "ik", "is", "niet", "dat", "op", "mijn", "met", "voor", "maar", "je", "u", "zijn", "wij"},
+}
+
+// languageNames are the English names of the languages the detector recognizes
+var languageNames = map[string]string{
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"pt": "Portuguese",
+	"it": "Italian",
+	"nl": "Dutch",
+}
+
+// LanguageName returns the English name of a language code, or the code itself when the
+// language is not one the detector recognizes
+func LanguageName(language string) string {
+	if name, ok := languageNames[NormalizeLanguage(language)]; ok {
+		return name
+	}
+	return language
+}
+
+// DetectLanguage guesses the language of a text from its most common words, returning ""
+// when no recognized language stands out. It serves to skip translating text that is
+// already in the target language, and to tell the model which language untranslated
+// conversations are in; translators report the source language themselves.
+func DetectLanguage(text string) string {
+	counts := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		counts[word]++
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for language, words := range commonWords {
+		score := 0
+		for _, word := range words {
+			score += counts[word]
+		}
+		switch {
+		case score > bestScore:
+			best, bestScore, runnerUp = language, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+
+	// Languages share many short words, so the winner has to lead clearly
+	if bestScore < minDetectionWords || bestScore < 2*runnerUp {
+		return ""
+	}
+	return best
+}
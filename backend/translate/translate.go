@@ -0,0 +1,131 @@
+// Package translate translates conversation text into a common language before analysis
+// using pluggable translation providers
+package translate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"agenticflows/backend/demo"
+)
+
+// Environment variables that configure translation
+const (
+	EnvTranslationProvider = "TRANSLATION_PROVIDER"        // "llm" (default) or "deepl"
+	EnvTranslationTarget   = "TRANSLATION_TARGET_LANGUAGE" // Language analyses run in, default "en"
+	EnvDeepLAPIKey         = "DEEPL_API_KEY"
+	EnvDeepLURL            = "DEEPL_API_URL" // Default https://api-free.deepl.com
+)
+
+// Provider names
+const (
+	ProviderLLM   = "llm"
+	ProviderDeepL = "deepl"
+)
+
+// DefaultTargetLanguage is the language conversations are translated into by default
+const DefaultTargetLanguage = "en"
+
+// defaultDeepLURL is the endpoint of the free DeepL API
+const defaultDeepLURL = "https://api-free.deepl.com"
+
+// Translation is a text translated into the target language. Provider is empty when the
+// text already was in the target language and was left as is.
+type Translation struct {
+	SourceLanguage string `json:"source_language"`
+	TargetLanguage string `json:"target_language"`
+	Text           string `json:"text"`
+	Provider       string `json:"provider,omitempty"`
+}
+
+// Translator translates text into a target language, reporting the language it detected
+// in the text
+type Translator interface {
+	Name() string
+	Translate(ctx context.Context, text, targetLanguage string) (Translation, error)
+}
+
+// Config selects the translation provider and the language analyses run in
+type Config struct {
+	Provider       string
+	TargetLanguage string
+	DeepLAPIKey    string
+	DeepLURL       string
+}
+
+// ConfigFromEnv reads the translation configuration from the environment. Without
+// TRANSLATION_PROVIDER the analysis model translates.
+func ConfigFromEnv() Config {
+	config := Config{
+		Provider:       strings.ToLower(strings.TrimSpace(os.Getenv(EnvTranslationProvider))),
+		TargetLanguage: NormalizeLanguage(os.Getenv(EnvTranslationTarget)),
+		DeepLAPIKey:    os.Getenv(EnvDeepLAPIKey),
+		DeepLURL:       strings.TrimRight(os.Getenv(EnvDeepLURL), "/"),
+	}
+	if config.Provider == "" {
+		config.Provider = ProviderLLM
+	}
+	if config.TargetLanguage == "" {
+		config.TargetLanguage = DefaultTargetLanguage
+	}
+	if config.DeepLURL == "" {
+		config.DeepLURL = defaultDeepLURL
+	}
+	return config
+}
+
+// NewTranslator builds the translator of the configured provider. The llm translator is
+// supplied by the caller, since it runs on the analysis model. Demo mode always uses it,
+// as it runs on the mock model there, so no text is sent to an external service.
+func NewTranslator(config Config, llm Translator) (Translator, error) {
+	provider := config.Provider
+	if demo.Enabled() {
+		provider = ProviderLLM
+	}
+	switch provider {
+	case ProviderLLM:
+		if llm == nil {
+			return nil, fmt.Errorf("no model is configured for the llm translation provider")
+		}
+		return llm, nil
+	case ProviderDeepL:
+		if config.DeepLAPIKey == "" {
+			return nil, fmt.Errorf("%s must be set to use the deepl translation provider", EnvDeepLAPIKey)
+		}
+		return NewDeepLTranslator(config.DeepLURL, config.DeepLAPIKey), nil
+	default:
+		return nil, fmt.Errorf("unknown translation provider %q", config.Provider)
+	}
+}
+
+// Text translates text into the target language. Text recognized as already being in
+// that language is returned as is, without calling the translator.
+func Text(ctx context.Context, translator Translator, text, targetLanguage string) (Translation, error) {
+	targetLanguage = NormalizeLanguage(targetLanguage)
+	if language := DetectLanguage(text); language == targetLanguage {
+		return Translation{SourceLanguage: language, TargetLanguage: targetLanguage, Text: text}, nil
+	}
+
+	translation, err := translator.Translate(ctx, text, targetLanguage)
+	if err != nil {
+		return Translation{}, fmt.Errorf("%s translation failed: %w", translator.Name(), err)
+	}
+	translation.SourceLanguage = NormalizeLanguage(translation.SourceLanguage)
+	translation.TargetLanguage = targetLanguage
+	if translation.Provider == "" {
+		translation.Provider = translator.Name()
+	}
+	return translation, nil
+}
+
+// NormalizeLanguage reduces a language code such as "EN-US" or "pt_BR" to its lowercase
+// primary subtag, e.g. "en" or "pt"
+func NormalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if primary, _, ok := strings.Cut(strings.ReplaceAll(language, "_", "-"), "-"); ok {
+		return primary
+	}
+	return language
+}
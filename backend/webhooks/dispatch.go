@@ -0,0 +1,202 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"agenticflows/backend/db"
+	"agenticflows/backend/outbound"
+
+	"github.com/google/uuid"
+)
+
+// EventAnalysisCompleted is the event sent when an analysis completes
+const EventAnalysisCompleted = "analysis.completed"
+
+// Delivery headers
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" and the hex HMAC-SHA256 of the body
+)
+
+// Delivery settings
+const (
+	deliveryTimeout  = 10 * time.Second
+	deliveryAttempts = 3
+	// retryDelay is the wait before the second attempt; it doubles for each later attempt
+	retryDelay = 2 * time.Second
+)
+
+// Event is a completed analysis, as clients receive it
+type Event struct {
+	TenantID     string      `json:"-"` // Only subscriptions of this tenant receive the event
+	AnalysisType string      `json:"analysis_type"`
+	WorkflowID   string      `json:"workflow_id,omitempty"`
+	ResultID     string      `json:"result_id,omitempty"`
+	Confidence   float64     `json:"confidence"`
+	Timestamp    time.Time   `json:"timestamp"`
+	Results      interface{} `json:"results"`
+}
+
+// Payload is the body posted to a subscription
+type Payload struct {
+	Event          string  `json:"event"`
+	DeliveryID     string  `json:"delivery_id"`
+	SubscriptionID string  `json:"subscription_id"`
+	Matches        []Match `json:"matches"`
+	Analysis       Event   `json:"analysis"`
+}
+
+// Dispatch sends an event to every enabled subscription of its tenant whose filters it
+// passes. Deliveries run in the background and are retried; their outcome is recorded on
+// the subscription.
+func Dispatch(event Event) {
+	// An event without a tenant never reaches the subscriptions of every tenant
+	tenantID := event.TenantID
+	if tenantID == db.AllTenants {
+		tenantID = db.DefaultTenantID
+	}
+	subs, err := db.ListWebhookSubscriptions(tenantID, true)
+	if err != nil {
+		log.Printf("Error loading webhook subscriptions: %v", err)
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	// Conditions test the results as clients see them in JSON
+	encoded, err := json.Marshal(event.Results)
+	if err != nil {
+		log.Printf("Error encoding results for webhooks: %v", err)
+		return
+	}
+	var results interface{}
+	if err := json.Unmarshal(encoded, &results); err != nil {
+		log.Printf("Error decoding results for webhooks: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		ok, matches, err := Matches(sub, event.AnalysisType, event.WorkflowID, results)
+		if err != nil {
+			log.Printf("Skipping webhook %s: %v", sub.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		payload := Payload{
+			Event:          EventAnalysisCompleted,
+			DeliveryID:     uuid.New().String(),
+			SubscriptionID: sub.ID,
+			Matches:        matches,
+			Analysis:       event,
+		}
+		go deliver(sub, payload)
+	}
+}
+
+// Matches reports whether an analysis passes the filters of a subscription and returns
+// the values that satisfied its conditions
+func Matches(sub db.WebhookSubscription, analysisType, workflowID string, results interface{}) (bool, []Match, error) {
+	if len(sub.AnalysisTypes) > 0 && !contains(sub.AnalysisTypes, analysisType) {
+		return false, nil, nil
+	}
+	if sub.WorkflowID != "" && sub.WorkflowID != workflowID {
+		return false, nil, nil
+	}
+
+	var conditions []Condition
+	if err := json.Unmarshal(sub.Conditions, &conditions); err != nil {
+		return false, nil, fmt.Errorf("invalid conditions: %w", err)
+	}
+	ok, matches := Evaluate(results, conditions, sub.Match)
+	return ok, matches, nil
+}
+
+// deliver posts a payload to a subscription, retrying failures with backoff
+func deliver(sub db.WebhookSubscription, payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding webhook payload for %s: %v", sub.ID, err)
+		return
+	}
+
+	var status int
+	delay := retryDelay
+	for attempt := 1; attempt <= deliveryAttempts; attempt++ {
+		status, err = post(sub, payload.DeliveryID, body)
+		if err == nil {
+			break
+		}
+		// Client errors other than rate limiting won't succeed on retry
+		if status >= 400 && status < 500 && status != http.StatusTooManyRequests {
+			break
+		}
+		if attempt < deliveryAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	if err != nil {
+		log.Printf("Webhook %s delivery %s failed: %v", sub.ID, payload.DeliveryID, err)
+	}
+	if recordErr := db.RecordWebhookDelivery(sub.ID, status, err); recordErr != nil {
+		log.Printf("Error recording webhook delivery for %s: %v", sub.ID, recordErr)
+	}
+}
+
+// post sends one delivery attempt and returns the response status
+func post(sub db.WebhookSubscription, deliveryID string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, EventAnalysisCompleted)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	if sub.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(sub.Secret, body))
+	}
+
+	resp, err := outbound.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	// The body is not recorded: the error is shown to the tenant as last_error, and
+	// the body could be that of any service the URL reaches
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of a body: "sha256=" and its hex HMAC-SHA256
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+// Package webhooks delivers completed analysis results to subscribed URLs, filtered by
+// analysis type, workflow and conditions on the content of the results
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Condition operators
+const (
+	OpContains     = "contains"
+	OpEquals       = "eq"
+	OpNotEquals    = "ne"
+	OpIn           = "in"
+	OpGreater      = "gt"
+	OpGreaterEqual = "gte"
+	OpLess         = "lt"
+	OpLessEqual    = "lte"
+	OpExists       = "exists"
+)
+
+// Match modes of a subscription's conditions
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
+
+// MaxConditions is the maximum number of conditions of a subscription
+const MaxConditions = 20
+
+// Condition tests the values found at a path of a result. The path names fields separated
+// by dots, and lists along it are searched item by item, so "findings.description"
+// tests the description of every finding. An empty path is the whole result.
+type Condition struct {
+	Path  string      `json:"path"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Match is a value that satisfied a condition, with its location in the result
+type Match struct {
+	Condition int         `json:"condition"`
+	Path      string      `json:"path"`
+	Value     interface{} `json:"value"`
+}
+
+// located is a value found in a result, with its path
+type located struct {
+	path  string
+	value interface{}
+}
+
+// ValidateConditions checks that conditions are well formed
+func ValidateConditions(conditions []Condition) error {
+	if len(conditions) > MaxConditions {
+		return fmt.Errorf("at most %d conditions are allowed", MaxConditions)
+	}
+	for i, condition := range conditions {
+		switch condition.Op {
+		case OpExists:
+		case OpContains:
+			if text, ok := condition.Value.(string); !ok || text == "" {
+				return fmt.Errorf("condition %d needs a text value", i)
+			}
+		case OpEquals, OpNotEquals:
+			if condition.Value == nil {
+				return fmt.Errorf("condition %d needs a value", i)
+			}
+		case OpIn:
+			if _, ok := condition.Value.([]interface{}); !ok {
+				return fmt.Errorf("condition %d needs a list of values", i)
+			}
+		case OpGreater, OpGreaterEqual, OpLess, OpLessEqual:
+			if _, ok := condition.Value.(float64); !ok {
+				return fmt.Errorf("condition %d needs a numeric value", i)
+			}
+		default:
+			return fmt.Errorf("condition %d has unknown op %q", i, condition.Op)
+		}
+	}
+	return nil
+}
+
+// Evaluate tests conditions against a result, decoded from JSON. With MatchAll every
+// condition must hold, each possibly on different items; with MatchAny one is enough.
+// Without conditions every result matches. It returns the values that satisfied them.
+func Evaluate(result interface{}, conditions []Condition, mode string) (bool, []Match) {
+	matches := []Match{}
+	if len(conditions) == 0 {
+		return true, matches
+	}
+
+	held := 0
+	for i, condition := range conditions {
+		found := collect(result, splitPath(condition.Path), false, "")
+		ok, hits := condition.test(found)
+		if !ok {
+			continue
+		}
+		held++
+		for _, hit := range hits {
+			matches = append(matches, Match{Condition: i, Path: hit.path, Value: hit.value})
+		}
+	}
+	if mode == MatchAny {
+		return held > 0, matches
+	}
+	return held == len(conditions), matches
+}
+
+// splitPath splits a dotted path into field names
+func splitPath(path string) []string {
+	if strings.TrimSpace(path) == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// collect returns the values at a path, searching lists item by item. With deep, objects
+// at the end of the path are replaced by the values nested anywhere in them.
+func collect(value interface{}, path []string, deep bool, prefix string) []located {
+	if list, ok := value.([]interface{}); ok {
+		var found []located
+		for i, item := range list {
+			found = append(found, collect(item, path, deep, fmt.Sprintf("%s[%d]", prefix, i))...)
+		}
+		return found
+	}
+	if len(path) == 0 {
+		if object, ok := value.(map[string]interface{}); ok && deep {
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			var found []located
+			for _, key := range keys {
+				found = append(found, collect(object[key], nil, true, joinPath(prefix, key))...)
+			}
+			return found
+		}
+		return []located{{path: prefix, value: value}}
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	field, ok := object[path[0]]
+	if !ok {
+		return nil
+	}
+	return collect(field, path[1:], deep, joinPath(prefix, path[0]))
+}
+
+// joinPath appends a field name to a path
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// test reports whether a condition holds for the values found at its path, and which
+// values satisfied it
+func (c Condition) test(found []located) (bool, []located) {
+	if c.Op == OpNotEquals {
+		// ne holds when no value equals the condition value
+		for _, item := range found {
+			if equal(item.value, c.Value) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	if c.Op == OpContains {
+		// Objects under the path are searched for the text in any of their fields
+		var deep []located
+		for _, item := range found {
+			deep = append(deep, collect(item.value, nil, true, item.path)...)
+		}
+		found = deep
+	}
+
+	var hits []located
+	for _, item := range found {
+		if c.holds(item.value) {
+			hits = append(hits, item)
+		}
+	}
+	return len(hits) > 0, hits
+}
+
+// holds reports whether a single value satisfies a condition
+func (c Condition) holds(value interface{}) bool {
+	switch c.Op {
+	case OpExists:
+		return value != nil && value != ""
+	case OpContains:
+		text, ok := value.(string)
+		return ok && strings.Contains(strings.ToLower(text), strings.ToLower(c.Value.(string)))
+	case OpEquals:
+		return equal(value, c.Value)
+	case OpIn:
+		for _, option := range c.Value.([]interface{}) {
+			if equal(value, option) {
+				return true
+			}
+		}
+		return false
+	}
+
+	number, ok := toNumber(value)
+	limit, _ := c.Value.(float64)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case OpGreater:
+		return number > limit
+	case OpGreaterEqual:
+		return number >= limit
+	case OpLess:
+		return number < limit
+	case OpLessEqual:
+		return number <= limit
+	}
+	return false
+}
+
+// equal compares a result value with a condition value: as numbers when both are, else
+// as case-insensitive text
+func equal(value, expected interface{}) bool {
+	if n, ok := toNumber(expected); ok {
+		if m, ok := toNumber(value); ok {
+			return n == m
+		}
+	}
+	if value == nil {
+		return expected == nil
+	}
+	return strings.EqualFold(fmt.Sprint(value), fmt.Sprint(expected))
+}
+
+// toNumber reads a number from a JSON number or numeric text
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// Node data keys holding components that can be left out when a workflow is cloned
+const (
+	DataSchedule          = "schedule"          // When the node's pipeline runs
+	DataPromptOverride    = "promptOverride"    // Custom prompt replacing the function's default
+	DataAttributeBindings = "attributeBindings" // Attributes the node reads or extracts
+)
+
+// CloneOptions selects what a cloned workflow keeps from its source
+type CloneOptions struct {
+	ID   string // Generated if empty
+	Name string // Defaults to the source name with a "(copy)" suffix
+
+	CopySchedules         bool
+	CopyPromptOverrides   bool
+	CopyAttributeBindings bool
+}
+
+// Clone copies a workflow's nodes and edges into a new workflow, leaving out the
+// components not selected in opts. It returns the new workflow and how many of
+// each component were left out, keyed by node data key.
+func Clone(source db.Workflow, opts CloneOptions) (db.Workflow, map[string]int, error) {
+	doc, err := NewDocument(source)
+	if err != nil {
+		return db.Workflow{}, nil, err
+	}
+
+	var excluded []string
+	if !opts.CopySchedules {
+		excluded = append(excluded, DataSchedule)
+	}
+	if !opts.CopyPromptOverrides {
+		excluded = append(excluded, DataPromptOverride)
+	}
+	if !opts.CopyAttributeBindings {
+		excluded = append(excluded, DataAttributeBindings)
+	}
+
+	removed := make(map[string]int)
+	for _, node := range doc.Nodes {
+		data, ok := node["data"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range excluded {
+			if _, ok := data[key]; ok {
+				delete(data, key)
+				removed[key]++
+			}
+		}
+	}
+
+	clone := db.Workflow{
+		ID:   opts.ID,
+		Name: opts.Name,
+		Date: time.Now().Format("2006-01-02"),
+	}
+	if clone.ID == "" {
+		clone.ID = uuid.New().String()
+	}
+	if clone.Name == "" {
+		clone.Name = fmt.Sprintf("%s (copy)", source.Name)
+	}
+	if err := doc.ApplyTo(&clone); err != nil {
+		return db.Workflow{}, nil, err
+	}
+
+	return clone, removed, nil
+}
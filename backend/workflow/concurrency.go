@@ -0,0 +1,47 @@
+package workflow
+
+import "sync"
+
+// RunTracker counts the runs of each workflow executing in this process
+type RunTracker struct {
+	mu      sync.Mutex
+	running map[string]int
+}
+
+// Runs tracks the workflow runs of the server
+var Runs = NewRunTracker()
+
+// NewRunTracker creates an empty run tracker
+func NewRunTracker() *RunTracker {
+	return &RunTracker{running: map[string]int{}}
+}
+
+// TryStart starts a run of a workflow unless max runs are already executing; a max
+// of 0 means no limit. The returned function ends the run and must be called once.
+func (t *RunTracker) TryStart(workflowID string, max int) (func(), bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if max > 0 && t.running[workflowID] >= max {
+		return nil, false
+	}
+	t.running[workflowID]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.running[workflowID]--; t.running[workflowID] <= 0 {
+				delete(t.running, workflowID)
+			}
+		})
+	}, true
+}
+
+// Running returns the number of executing runs of a workflow
+func (t *RunTracker) Running(workflowID string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.running[workflowID]
+}
@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -11,11 +12,47 @@ import (
 	"agenticflows/backend/db"
 )
 
+// ErrNodeNotFound and ErrNodeNotExecutable are returned when a single node cannot be tested
+var (
+	ErrNodeNotFound      = errors.New("node not found")
+	ErrNodeNotExecutable = errors.New("node cannot be executed")
+)
+
+// Node run states
+const (
+	NodeSucceeded      = "succeeded"
+	NodeFailed         = "failed"
+	NodeSkipped        = "skipped"
+	NodeAwaitingReview = "awaiting_review"
+)
+
+// NodeRun is the execution of one node during Execute or Resume
+type NodeRun struct {
+	NodeID   string
+	NodeType string
+	Status   string
+	Started  time.Time
+	Duration time.Duration
+	Output   map[string]interface{}
+	Err      error
+}
+
+// FunctionRunner runs the analysis a function node names, such as "trends" for the
+// function ID "analysis-trends", with the node's parameters over its inputs and returns
+// the node's output. upstream holds the outputs of the nodes connected to the node.
+type FunctionRunner func(analysisType string, parameters, inputs map[string]interface{}, upstream []map[string]interface{}) (map[string]interface{}, error)
+
 // Executor handles workflow execution
 type Executor struct {
 	workflow db.Workflow
 	nodes    []map[string]interface{}
 	edges    []map[string]interface{}
+
+	// runFunction runs the analyses of function nodes
+	runFunction FunctionRunner
+
+	// nodeRuns are the nodes executed so far, in order
+	nodeRuns []NodeRun
 }
 
 // NewExecutor creates a workflow executor for a specific workflow
@@ -41,11 +78,64 @@ func NewExecutor(w db.Workflow) *Executor {
 	}
 }
 
-// Execute runs the workflow with the given inputs
+// SetFunctionRunner sets how the executor runs the analyses of function nodes, which
+// fail without one
+func (e *Executor) SetFunctionRunner(run FunctionRunner) {
+	e.runFunction = run
+}
+
+// Execute runs the workflow with the given inputs. A run that reaches a review node stops
+// there and returns the results so far with a *ReviewPause error; Resume continues it.
 func (e *Executor) Execute(text string, data map[string]interface{}, parameters map[string]interface{}) (map[string]interface{}, error) {
 	log.Printf("Executing workflow '%s' with %d nodes and %d edges", e.workflow.Name, len(e.nodes), len(e.edges))
 
-	// Find all function nodes
+	// Initialize results storage
+	results := make(map[string]interface{})
+
+	// Add initial data to results
+	if data != nil {
+		for k, v := range data {
+			results[k] = v
+		}
+	}
+
+	// If text was provided, add it to results
+	if text != "" {
+		results["text"] = text
+	}
+
+	// Add any additional parameters
+	if parameters != nil {
+		for k, v := range parameters {
+			results[k] = v
+		}
+	}
+
+	return e.run(results, nil)
+}
+
+// NodeRuns returns the nodes the executor ran, in the order they ran, with their outputs
+// and durations. A run paused for review ends with its review node awaiting review.
+func (e *Executor) NodeRuns() []NodeRun {
+	return append([]NodeRun(nil), e.nodeRuns...)
+}
+
+// recordNode records the execution of a node that started at started
+func (e *Executor) recordNode(nodeID, nodeType string, started time.Time, output map[string]interface{}, err error) {
+	run := NodeRun{NodeID: nodeID, NodeType: nodeType, Status: NodeSucceeded, Started: started, Duration: time.Since(started), Output: output, Err: err}
+	switch {
+	case err != nil:
+		run.Status = NodeFailed
+	case output == nil:
+		run.Status = NodeSkipped
+	}
+	e.nodeRuns = append(e.nodeRuns, run)
+}
+
+// run executes the nodes of the workflow in dependency order, skipping the completed ones,
+// and adds their outputs to results
+func (e *Executor) run(results map[string]interface{}, completed []string) (map[string]interface{}, error) {
+	// Find all function, transform, review and node library nodes
 	functionNodes := make([]map[string]interface{}, 0)
 	for _, node := range e.nodes {
 		data, ok := node["data"].(map[string]interface{})
@@ -54,7 +144,7 @@ func (e *Executor) Execute(text string, data map[string]interface{}, parameters
 		}
 
 		nodeType, _ := data["nodeType"].(string)
-		if nodeType == "function" {
+		if nodeType == "function" || nodeType == NodeTypeTransform || nodeType == NodeTypeReview || isLibraryNode(nodeType) {
 			functionNodes = append(functionNodes, node)
 		}
 	}
@@ -65,110 +155,252 @@ func (e *Executor) Execute(text string, data map[string]interface{}, parameters
 		return nil, fmt.Errorf("failed to determine execution order: %s", err)
 	}
 
-	// Initialize results storage
-	results := make(map[string]interface{})
+	done := make(map[string]bool, len(completed))
+	for _, nodeID := range completed {
+		done[nodeID] = true
+	}
 
-	// Add initial data to results
-	if data != nil {
-		for k, v := range data {
-			results[k] = v
+	// Execute each node in order
+	for _, node := range sortedNodes {
+		nodeID, _ := node["id"].(string)
+		nodeData, _ := node["data"].(map[string]interface{})
+		nodeType, _ := nodeData["nodeType"].(string)
+		if done[nodeID] {
+			continue
+		}
+
+		// Review nodes pause the run until a reviewer decides on their item
+		if nodeType == NodeTypeReview {
+			e.nodeRuns = append(e.nodeRuns, NodeRun{NodeID: nodeID, NodeType: nodeType, Status: NodeAwaitingReview, Started: time.Now()})
+			return results, &ReviewPause{
+				NodeID:    nodeID,
+				Item:      e.reviewItem(nodeID, results),
+				Results:   results,
+				Completed: completed,
+			}
+		}
+		completed = append(completed, nodeID)
+
+		// Get input data from connected nodes
+		nodeInputs := e.mappedInputs(nodeID, results)
+
+		// Library nodes without mapped records read those of the node connected to them
+		if _, mapped := nodeInputs[recordsKey]; isLibraryNode(nodeType) && !mapped {
+			if records, ok := e.upstreamRecords(nodeID, results); ok {
+				nodeInputs[recordsKey] = records
+			}
+		}
+
+		// Merge with global data
+		for k, v := range results {
+			if _, exists := nodeInputs[k]; !exists {
+				nodeInputs[k] = v
+			}
+		}
+
+		// Transform nodes reshape data with their script; a failing script stops the run
+		started := time.Now()
+		if nodeType == NodeTypeTransform {
+			nodeResult, err := runTransform(node, nodeInputs)
+			e.recordNode(nodeID, nodeType, started, nodeResult, err)
+			if err != nil {
+				return nil, fmt.Errorf("transform node %s: %w", nodeID, err)
+			}
+			results[nodeID] = nodeResult
+			continue
+		}
+
+		// Library nodes load, reshape and deliver records; a failing node stops the run
+		if isLibraryNode(nodeType) {
+			nodeResult, err := runLibraryNode(e.workflow.TenantID, node, nodeInputs)
+			e.recordNode(nodeID, nodeType, started, nodeResult, err)
+			if err != nil {
+				return nil, fmt.Errorf("%s node %s: %w", nodeType, nodeID, err)
+			}
+			results[nodeID] = nodeResult
+			continue
 		}
+
+		// Function nodes run their analysis; a failing analysis stops the run
+		parameters, _ := nodeData["parameters"].(map[string]interface{})
+		nodeResult, ok, err := e.runNode(node, parameters, nodeInputs, e.upstreamOutputs(nodeID, results))
+		e.recordNode(nodeID, nodeType, started, nodeResult, err)
+		if err != nil {
+			return nil, fmt.Errorf("function node %s: %w", nodeID, err)
+		}
+		if !ok {
+			continue
+		}
+
+		// Store results
+		results[nodeID] = nodeResult
 	}
 
-	// If text was provided, add it to results
+	return results, nil
+}
+
+// ExecuteNode runs a single function, transform or library node with sample input, ignoring the rest of the graph.
+// The node's configured parameters are used unless overridden by parameters. Sink nodes are not run,
+// so testing a node has no side effects.
+func (e *Executor) ExecuteNode(nodeID string, text string, input map[string]interface{}, parameters map[string]interface{}) (map[string]interface{}, error) {
+	var node map[string]interface{}
+	for _, n := range e.nodes {
+		if id, _ := n["id"].(string); id == nodeID {
+			node = n
+			break
+		}
+	}
+	if node == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeID)
+	}
+
+	data, _ := node["data"].(map[string]interface{})
+	nodeType, _ := data["nodeType"].(string)
+	if library, ok := nodeLibrary[nodeType]; ok && library.Category == NodeCategorySink {
+		return nil, fmt.Errorf("%w: node %s is a sink node, which is not run by tests", ErrNodeNotExecutable, nodeID)
+	}
+	if nodeType != "function" && nodeType != NodeTypeTransform && !isLibraryNode(nodeType) {
+		return nil, fmt.Errorf("%w: node %s is not a function, transform or library node", ErrNodeNotExecutable, nodeID)
+	}
+
+	log.Printf("Testing node '%s' of workflow '%s'", nodeID, e.workflow.Name)
+
+	nodeInputs := make(map[string]interface{})
+	for k, v := range input {
+		nodeInputs[k] = v
+	}
 	if text != "" {
-		results["text"] = text
+		nodeInputs["text"] = text
+	}
+	nodeParameters := make(map[string]interface{})
+	if configured, ok := data["parameters"].(map[string]interface{}); ok {
+		for k, v := range configured {
+			nodeParameters[k] = v
+		}
+	}
+	for k, v := range parameters {
+		nodeParameters[k] = v
+	}
+	for k, v := range nodeParameters {
+		nodeInputs[k] = v
 	}
 
-	// Add any additional parameters
-	if parameters != nil {
+	if nodeType == NodeTypeTransform {
+		return runTransform(node, nodeInputs)
+	}
+	if isLibraryNode(nodeType) {
+		// Library nodes read their settings from the node data, which parameters override
+		settings := make(map[string]interface{}, len(data)+len(parameters))
+		for k, v := range data {
+			settings[k] = v
+		}
 		for k, v := range parameters {
-			results[k] = v
+			settings[k] = v
 		}
+		settings["nodeType"] = nodeType
+		return runLibraryNode(e.workflow.TenantID, map[string]interface{}{"id": nodeID, "data": settings}, nodeInputs)
 	}
 
-	// Execute each node in order
-	for _, node := range sortedNodes {
-		nodeID, _ := node["id"].(string)
-		data, _ := node["data"].(map[string]interface{})
-		functionId, _ := data["functionId"].(string)
+	result, ok, err := e.runNode(node, nodeParameters, nodeInputs, nil)
+	if !ok {
+		return nil, fmt.Errorf("%w: node %s has no valid function configured", ErrNodeNotExecutable, nodeID)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		// Skip if no function ID
-		if functionId == "" {
-			continue
-		}
+	return result, nil
+}
 
-		// Parse the function type from the ID (e.g., "analysis-trends" -> "trends")
-		parts := strings.Split(functionId, "-")
-		if len(parts) < 2 {
+// upstreamOutputs returns the outputs of the nodes connected to nodeID
+func (e *Executor) upstreamOutputs(nodeID string, results map[string]interface{}) []map[string]interface{} {
+	var outputs []map[string]interface{}
+	for _, edge := range e.edges {
+		if target, _ := edge["target"].(string); target != nodeID {
 			continue
 		}
+		source, _ := edge["source"].(string)
+		if output, ok := results[source].(map[string]interface{}); ok {
+			outputs = append(outputs, output)
+		}
+	}
+	return outputs
+}
 
-		// Get input data from connected nodes
-		nodeInputs := make(map[string]interface{})
+// mappedInputs collects the inputs of a node from the results of its source nodes
+// using the data mappings defined on incoming edges
+func (e *Executor) mappedInputs(nodeID string, results map[string]interface{}) map[string]interface{} {
+	nodeInputs := make(map[string]interface{})
 
-		// Find incoming edges to this node
-		for _, edge := range e.edges {
-			target, _ := edge["target"].(string)
-			if target != nodeID {
-				continue
-			}
+	// Find incoming edges to this node
+	for _, edge := range e.edges {
+		target, _ := edge["target"].(string)
+		if target != nodeID {
+			continue
+		}
 
-			source, _ := edge["source"].(string)
-			edgeData, hasData := edge["data"].(map[string]interface{})
+		source, _ := edge["source"].(string)
+		edgeData, hasData := edge["data"].(map[string]interface{})
+
+		// Apply data mappings if defined
+		if hasData && edgeData != nil {
+			mappings, hasMappings := edgeData["mappings"].([]interface{})
+			if hasMappings && mappings != nil {
+				// Get source node results
+				sourceResults, exists := results[source].(map[string]interface{})
+				if !exists {
+					continue
+				}
 
-			// Apply data mappings if defined
-			if hasData && edgeData != nil {
-				mappings, hasMappings := edgeData["mappings"].([]interface{})
-				if hasMappings && mappings != nil {
-					// Get source node results
-					sourceResults, exists := results[source].(map[string]interface{})
-					if !exists {
+				// Apply each mapping
+				for _, mappingObj := range mappings {
+					mapping, isMap := mappingObj.(map[string]interface{})
+					if !isMap {
 						continue
 					}
 
-					// Apply each mapping
-					for _, mappingObj := range mappings {
-						mapping, isMap := mappingObj.(map[string]interface{})
-						if !isMap {
-							continue
-						}
+					sourceOutput, _ := mapping["sourceOutput"].(string)
+					targetInput, _ := mapping["targetInput"].(string)
 
-						sourceOutput, _ := mapping["sourceOutput"].(string)
-						targetInput, _ := mapping["targetInput"].(string)
-
-						if sourceOutput != "" && targetInput != "" {
-							// Get the source value from results
-							if sourceValue, exists := sourceResults[sourceOutput]; exists {
-								nodeInputs[targetInput] = sourceValue
-							}
+					if sourceOutput != "" && targetInput != "" {
+						// Get the source value from results
+						if sourceValue, exists := sourceResults[sourceOutput]; exists {
+							nodeInputs[targetInput] = sourceValue
 						}
 					}
 				}
 			}
 		}
+	}
 
-		// Merge with global data
-		for k, v := range results {
-			if _, exists := nodeInputs[k]; !exists {
-				nodeInputs[k] = v
-			}
-		}
+	return nodeInputs
+}
 
-		// Create a placeholder for node results - in a real implementation,
-		// we would delegate to specific function handlers
-		nodeResult := map[string]interface{}{
-			"status":         "executed",
-			"function_id":    functionId,
-			"execution_time": time.Now().Format(time.RFC3339),
-			"inputs":         nodeInputs,
-		}
+// runNode runs the analysis of a function node with its parameters over the given inputs.
+// It returns false if the node has no valid function ID.
+func (e *Executor) runNode(node map[string]interface{}, parameters, nodeInputs map[string]interface{}, upstream []map[string]interface{}) (map[string]interface{}, bool, error) {
+	data, _ := node["data"].(map[string]interface{})
+	functionId, _ := data["functionId"].(string)
 
-		// Store results
-		results[nodeID] = nodeResult
+	// Skip if no function ID
+	if functionId == "" {
+		return nil, false, nil
 	}
 
-	return results, nil
+	// Parse the function type from the ID (e.g., "analysis-trends" -> "trends")
+	_, analysisType, found := strings.Cut(functionId, "-")
+	if !found || analysisType == "" {
+		return nil, false, nil
+	}
+
+	if e.runFunction == nil {
+		return nil, true, fmt.Errorf("function %s cannot run: analysis is not available", functionId)
+	}
+	output, err := e.runFunction(analysisType, parameters, nodeInputs, upstream)
+	if err != nil {
+		return nil, true, err
+	}
+	return output, true, nil
 }
 
 // getExecutionOrder sorts nodes by dependencies to allow for proper execution order
@@ -9,26 +9,26 @@ import (
 	"strings"
 	"time"
 
-	"agenticflows/backend/analysis"
+	"agenticflows/backend/analysis/core"
 	"agenticflows/backend/db"
 )
 
 // Generator handles workflow generation
 type Generator struct {
-	llmClient *analysis.LLMClient
+	llmClient *core.LLMClient
 }
 
 // NewGenerator creates a new workflow generator
 func NewGenerator() *Generator {
 	// Get the API key from environment
 	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
+	if apiKey == "" && !core.GatewayConfigured() && !core.MockConfigured() {
 		log.Println("Warning: GEMINI_API_KEY environment variable not set")
 		return &Generator{}
 	}
 
 	// Create LLM client
-	llmClient, err := analysis.NewLLMClient(apiKey, false)
+	llmClient, err := core.NewLLMClient(apiKey, false)
 	if err != nil {
 		log.Printf("Warning: failed to create LLM client: %s", err)
 		return &Generator{}
@@ -40,7 +40,7 @@ func NewGenerator() *Generator {
 }
 
 // GenerateFromDescription uses LLM to generate a workflow based on the description
-func (g *Generator) GenerateFromDescription(name, description string) (db.Workflow, error) {
+func (g *Generator) GenerateFromDescription(ctx context.Context, name, description string) (db.Workflow, error) {
 	if g.llmClient == nil {
 		return db.Workflow{}, fmt.Errorf("LLM client not initialized")
 	}
@@ -55,7 +55,7 @@ func (g *Generator) GenerateFromDescription(name, description string) (db.Workfl
 	prompt := createWorkflowGenerationPrompt(name, description, functionMetadata)
 
 	// Call the LLM API
-	result, err := g.llmClient.GenerateContent(context.Background(), prompt, map[string]interface{}{
+	result, err := g.llmClient.GenerateContent(ctx, prompt, map[string]interface{}{
 		"nodes": []interface{}{},
 		"edges": []interface{}{},
 	})
@@ -126,7 +126,7 @@ func (g *Generator) GenerateFromDescription(name, description string) (db.Workfl
 }
 
 // GenerateDynamic uses LLM to generate a dynamic workflow with custom functions
-func (g *Generator) GenerateDynamic(name, description string) (db.Workflow, error) {
+func (g *Generator) GenerateDynamic(ctx context.Context, name, description string) (db.Workflow, error) {
 	if g.llmClient == nil {
 		return db.Workflow{}, fmt.Errorf("LLM client not initialized")
 	}
@@ -158,7 +158,7 @@ func (g *Generator) GenerateDynamic(name, description string) (db.Workflow, erro
 		"edges": []interface{}{},
 	}
 
-	result, err := g.llmClient.GenerateContent(context.Background(), prompt, defaultTemplate)
+	result, err := g.llmClient.GenerateContent(ctx, prompt, defaultTemplate)
 	if err != nil {
 		return db.Workflow{}, fmt.Errorf("failed to generate dynamic workflow from LLM: %s", err)
 	}
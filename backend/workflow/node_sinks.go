@@ -0,0 +1,128 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"agenticflows/backend/auth"
+	"agenticflows/backend/db"
+	"agenticflows/backend/outbound"
+	"agenticflows/backend/webhooks"
+
+	"github.com/google/uuid"
+)
+
+// defaultRecordSource is the source of conversations saved by workflows
+const defaultRecordSource = "workflow"
+
+// conversationFields are the record fields saved as fields of a conversation rather than
+// as metadata
+var conversationFields = map[string]bool{
+	"conversation_id": true, "customer_id": true, "text": true, "date_time": true, "source": true, "metadata": true,
+}
+
+// runSaveToDB stores records as conversations, replacing stored ones with the same ID
+func runSaveToDB(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error) {
+	records, err := inputRecords(inputs)
+	if err != nil {
+		return nil, err
+	}
+	source := stringSetting(data, "source")
+	if source == "" {
+		source = defaultRecordSource
+	}
+
+	conversations := make([]db.Conversation, 0, len(records))
+	ids := make([]string, 0, len(records))
+	seen := make(map[string]bool, len(records))
+	for i, record := range records {
+		var conversation db.Conversation
+		if err := decodeSetting(map[string]interface{}{"record": record}, "record", &conversation); err != nil {
+			return nil, fmt.Errorf("record %d is not a conversation: %w", i, err)
+		}
+		if strings.TrimSpace(conversation.Text) == "" {
+			return nil, fmt.Errorf("record %d has no text", i)
+		}
+		if conversation.ID == "" {
+			conversation.ID = uuid.New().String()
+		}
+		if seen[conversation.ID] {
+			return nil, fmt.Errorf("duplicate conversation_id %s", conversation.ID)
+		}
+		seen[conversation.ID] = true
+		if conversation.Source == "" {
+			conversation.Source = source
+		}
+		for field, value := range record {
+			if conversationFields[field] {
+				continue
+			}
+			if conversation.Metadata == nil {
+				conversation.Metadata = map[string]interface{}{}
+			}
+			conversation.Metadata[field] = value
+		}
+		conversations = append(conversations, conversation)
+		ids = append(ids, conversation.ID)
+	}
+
+	created, updated := 0, 0
+	if len(conversations) > 0 {
+		if created, updated, err = db.IngestConversations(auth.TenantID(ctx), conversations); err != nil {
+			return nil, fmt.Errorf("failed to save conversations: %w", err)
+		}
+	}
+	return map[string]interface{}{
+		"saved":            len(conversations),
+		"created":          created,
+		"updated":          updated,
+		"conversation_ids": ids,
+	}, nil
+}
+
+// runWebhookSink posts records to a URL, signing the body when the node has a secret
+func runWebhookSink(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error) {
+	records, err := inputRecords(inputs)
+	if err != nil {
+		return nil, err
+	}
+	target := stringSetting(data, "url")
+	if parsed, err := url.Parse(target); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"records": records,
+		"count":   len(records),
+		"sent_at": time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode records: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret, _ := data["secret"].(string); secret != "" {
+		req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(secret, body))
+	}
+
+	resp, err := outbound.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return map[string]interface{}{"status": resp.StatusCode, "count": len(records)}, nil
+}
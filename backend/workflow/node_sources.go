@@ -0,0 +1,336 @@
+package workflow
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+
+	"agenticflows/backend/db"
+	"agenticflows/backend/outbound"
+)
+
+// maxFetchSize bounds the response body an HTTP fetch node reads
+const maxFetchSize = 5 * 1024 * 1024
+
+// runSQLiteQuery loads the rows of a SELECT query on a SQLite file, opened read-only
+func runSQLiteQuery(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error) {
+	path, err := dataFile(ctx, stringSetting(data, "database"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("database %s not found", stringSetting(data, "database"))
+	}
+	query := stringSetting(data, "query")
+	if err := checkSelectQuery(query); err != nil {
+		return nil, err
+	}
+	maxRows, err := maxRowsSetting(data)
+	if err != nil {
+		return nil, err
+	}
+	params, _ := data["params"].([]interface{})
+
+	// Runs share the read-only connections of the file
+	conn, err := db.SharedReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	rows, err := conn.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	var records []map[string]interface{}
+	truncated := false
+	for rows.Next() {
+		if len(records) == maxRows {
+			truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if raw, ok := values[i].([]byte); ok {
+				values[i] = string(raw)
+			}
+			record[column] = values[i]
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	output, err := recordsOutput(records)
+	if err != nil {
+		return nil, err
+	}
+	output["truncated"] = truncated
+	return output, nil
+}
+
+// checkSelectQuery accepts a single SELECT statement, which may start with a WITH clause.
+// The driver runs every statement of a query, so anything after the first one, such as
+// an ATTACH of another file, is rejected.
+func checkSelectQuery(query string) error {
+	// Literals, quoted identifiers and comments are blanked so only SQL keywords remain
+	var code strings.Builder
+	for i := 0; i < len(query); i++ {
+		end := -1
+		switch {
+		case query[i] == '\'' || query[i] == '"' || query[i] == '`':
+			end = closingQuote(query, i+1, query[i])
+		case query[i] == '[':
+			end = strings.IndexByte(query[i:], ']') + i
+		case strings.HasPrefix(query[i:], "--"):
+			if end = strings.IndexByte(query[i:], '\n') + i; end < i {
+				end = len(query) - 1
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end = strings.Index(query[i+2:], "*/") + i + 3; end < i+3 {
+				end = len(query) - 1
+			}
+		default:
+			code.WriteByte(query[i])
+			continue
+		}
+		if end < i {
+			return fmt.Errorf("query has an unterminated quote")
+		}
+		code.WriteByte(' ')
+		i = end
+	}
+
+	statement := strings.TrimSuffix(strings.TrimSpace(code.String()), ";")
+	if strings.Contains(statement, ";") {
+		return fmt.Errorf("query must be a single SELECT statement")
+	}
+
+	// The statement is named by the first keyword outside of parentheses other than the
+	// names and options of WITH clauses
+	var words []string
+	var word strings.Builder
+	depth := 0
+	for _, r := range strings.ToLower(statement) + " " {
+		switch {
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			if depth == 0 {
+				word.WriteRune(r)
+			}
+			continue
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		}
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	if len(words) > 0 && (words[0] == "select" || words[0] == "with") {
+		for _, word := range words {
+			switch word {
+			case "select":
+				return nil
+			case "values", "insert", "update", "delete", "replace":
+				return fmt.Errorf("query must be a single SELECT statement")
+			}
+		}
+	}
+	return fmt.Errorf("query must be a single SELECT statement")
+}
+
+// closingQuote returns the position of the quote closing a literal opened before start,
+// where a doubled quote stands for the quote itself; -1 if it is not closed
+func closingQuote(query string, start int, quote byte) int {
+	for i := start; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// runCSVFile loads the rows of a CSV file, naming their fields after the header row
+func runCSVFile(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error) {
+	path, err := dataFile(ctx, stringSetting(data, "path"))
+	if err != nil {
+		return nil, err
+	}
+	maxRows, err := maxRowsSetting(data)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("file %s not found", stringSetting(data, "path"))
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	if delimiter, _ := data["delimiter"].(string); delimiter != "" {
+		runes := []rune(delimiter)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("delimiter must be a single character")
+		}
+		reader.Comma = runes[0]
+	}
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("file %s is empty", stringSetting(data, "path"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	var records []map[string]interface{}
+	truncated := false
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(records) == maxRows {
+			truncated = true
+			break
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		record := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+
+	output, err := recordsOutput(records)
+	if err != nil {
+		return nil, err
+	}
+	output["columns"] = header
+	output["truncated"] = truncated
+	return output, nil
+}
+
+// runHTTPFetch loads records from the JSON response of a GET request. A list of objects
+// becomes the records, and an object a single record.
+func runHTTPFetch(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error) {
+	target := stringSetting(data, "url")
+	if parsed, err := url.Parse(target); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+	maxRows, err := maxRowsSetting(data)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if headers, ok := data["headers"].(map[string]interface{}); ok {
+		for name, value := range headers {
+			if text, ok := value.(string); ok {
+				req.Header.Set(name, text)
+			}
+		}
+	}
+	resp, err := outbound.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("request returned status %d", resp.StatusCode)
+	}
+	if len(body) > maxFetchSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxFetchSize)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, fmt.Errorf("response is not JSON: %w", err)
+	}
+	if path := stringSetting(data, "recordsPath"); path != "" {
+		for _, field := range strings.Split(path, ".") {
+			object, ok := decoded.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("response has no %s", path)
+			}
+			if decoded, ok = object[field]; !ok {
+				return nil, fmt.Errorf("response has no %s", path)
+			}
+		}
+	}
+
+	var records []map[string]interface{}
+	truncated := false
+	switch value := decoded.(type) {
+	case map[string]interface{}:
+		records = append(records, value)
+	case []interface{}:
+		for i, item := range value {
+			if len(records) == maxRows {
+				truncated = true
+				break
+			}
+			record, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %d of the response is not an object", i)
+			}
+			records = append(records, record)
+		}
+	default:
+		return nil, errors.New("response holds no object or list of objects")
+	}
+
+	output, err := recordsOutput(records)
+	if err != nil {
+		return nil, err
+	}
+	output["status"] = resp.StatusCode
+	output["truncated"] = truncated
+	return output, nil
+}
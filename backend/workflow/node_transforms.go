@@ -0,0 +1,159 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"agenticflows/backend/webhooks"
+)
+
+// runFilter keeps the records that pass the node's conditions
+func runFilter(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error) {
+	records, err := inputRecords(inputs)
+	if err != nil {
+		return nil, err
+	}
+	var conditions []webhooks.Condition
+	if err := decodeSetting(data, "conditions", &conditions); err != nil {
+		return nil, err
+	}
+	if err := webhooks.ValidateConditions(conditions); err != nil {
+		return nil, err
+	}
+	match := stringSetting(data, "match")
+	if match != "" && match != webhooks.MatchAll && match != webhooks.MatchAny {
+		return nil, fmt.Errorf("match must be %s or %s", webhooks.MatchAll, webhooks.MatchAny)
+	}
+
+	kept := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		if ok, _ := webhooks.Evaluate(record, conditions, match); ok {
+			kept = append(kept, record)
+		}
+	}
+	output, err := recordsOutput(kept)
+	if err != nil {
+		return nil, err
+	}
+	output["dropped"] = len(records) - len(kept)
+	return output, nil
+}
+
+// runMapFields renames and selects the fields of records
+func runMapFields(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error) {
+	records, err := inputRecords(inputs)
+	if err != nil {
+		return nil, err
+	}
+	var mappings map[string]string
+	if err := decodeSetting(data, "mappings", &mappings); err != nil {
+		return nil, err
+	}
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("mappings must map at least one field")
+	}
+	keepUnmapped, _ := data["keepUnmapped"].(bool)
+
+	// Top-level fields read by a mapping are not kept as unmapped fields
+	mapped := make(map[string]bool, len(mappings))
+	for _, path := range mappings {
+		mapped[strings.SplitN(path, ".", 2)[0]] = true
+	}
+
+	for i, record := range records {
+		result := make(map[string]interface{}, len(mappings))
+		if keepUnmapped {
+			for field, value := range record {
+				if !mapped[field] {
+					result[field] = value
+				}
+			}
+		}
+		for target, path := range mappings {
+			if value, ok := fieldValue(record, path); ok {
+				result[target] = value
+			}
+		}
+		records[i] = result
+	}
+	return recordsOutput(records)
+}
+
+// runSample keeps size records, picked at random in their original order or the first ones
+func runSample(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error) {
+	records, err := inputRecords(inputs)
+	if err != nil {
+		return nil, err
+	}
+	size, err := intSetting(data, "size", 0)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be at least 1")
+	}
+	seed, err := intSetting(data, "seed", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sampled := records
+	if size < len(records) {
+		switch method := stringSetting(data, "method"); method {
+		case "first":
+			sampled = records[:size]
+		case "", "random":
+			source := rand.NewSource(time.Now().UnixNano())
+			if _, set := data["seed"]; set {
+				source = rand.NewSource(int64(seed))
+			}
+			picked := rand.New(source).Perm(len(records))[:size]
+			sort.Ints(picked)
+			sampled = make([]map[string]interface{}, size)
+			for i, index := range picked {
+				sampled[i] = records[index]
+			}
+		default:
+			return nil, fmt.Errorf("method must be random or first")
+		}
+	}
+
+	output, err := recordsOutput(sampled)
+	if err != nil {
+		return nil, err
+	}
+	output["total"] = len(records)
+	return output, nil
+}
+
+// fieldValue returns the value at a dotted path of a record
+func fieldValue(record map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = record
+	for _, field := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[field]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// decodeSetting decodes a structured setting of a node into target
+func decodeSetting(data map[string]interface{}, name string, target interface{}) error {
+	encoded, err := json.Marshal(data[name])
+	if err == nil {
+		err = json.Unmarshal(encoded, target)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
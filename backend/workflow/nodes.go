@@ -0,0 +1,335 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agenticflows/backend/auth"
+)
+
+// Node types of the node library. Data sources load records, transforms reshape them and
+// sinks deliver them; records are JSON objects passed between nodes as the "records"
+// output and input.
+const (
+	NodeTypeSQLiteQuery = "sqlite_query"
+	NodeTypeCSVFile     = "csv_file"
+	NodeTypeHTTPFetch   = "http_fetch"
+	NodeTypeFilter      = "filter"
+	NodeTypeMapFields   = "map_fields"
+	NodeTypeSample      = "sample"
+	NodeTypeSaveToDB    = "save_to_db"
+	NodeTypeWebhook     = "webhook"
+)
+
+// Node library categories
+const (
+	NodeCategorySource    = "source"
+	NodeCategoryTransform = "transform"
+	NodeCategorySink      = "sink"
+)
+
+// EnvDataDir is the directory file data sources read from, "data" by default. Each tenant
+// has its own subdirectory, named after its ID; nodes name files relative to it and
+// cannot reach outside of it.
+const EnvDataDir = "WORKFLOW_DATA_DIR"
+
+// Node library limits
+const (
+	defaultDataDir    = "data"
+	recordsKey        = "records"
+	defaultMaxRecords = 1000
+	maxRecords        = 10000
+	nodeTimeout       = 30 * time.Second
+)
+
+// NodeParameter describes a setting of a library node, read from the node's data
+type NodeParameter struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Required    bool        `json:"required,omitempty"`
+	Example     interface{} `json:"example,omitempty"`
+}
+
+// NodeType describes a node type of the library
+type NodeType struct {
+	Type        string                   `json:"type"`
+	Category    string                   `json:"category"`
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Parameters  map[string]NodeParameter `json:"parameters"`
+	Outputs     []string                 `json:"outputs"`
+}
+
+// nodeRunner runs a library node with its data and its inputs
+type nodeRunner func(ctx context.Context, data, inputs map[string]interface{}) (map[string]interface{}, error)
+
+// libraryNode is a node type of the library and how it runs
+type libraryNode struct {
+	NodeType
+	run nodeRunner
+}
+
+// recordsInput describes the records input transforms and sinks read
+var recordsInput = NodeParameter{
+	Type:        "array",
+	Description: "Records to process; by default the records of the node connected to this one",
+}
+
+// nodeLibrary holds the node types of the library by type
+var nodeLibrary = map[string]libraryNode{
+	NodeTypeSQLiteQuery: {NodeType{
+		Type:        NodeTypeSQLiteQuery,
+		Category:    NodeCategorySource,
+		Name:        "SQLite Query",
+		Description: "Load records with a read-only SELECT query on a SQLite database file in the data directory",
+		Parameters: map[string]NodeParameter{
+			"database": {Type: "string", Description: "Database file, relative to the data directory", Required: true, Example: "calls.db"},
+			"query":    {Type: "string", Description: "SELECT query whose rows become the records", Required: true, Example: "SELECT id AS conversation_id, transcript AS text FROM calls WHERE day = ?"},
+			"params":   {Type: "array", Description: "Values of the query's ? placeholders", Example: []string{"2024-06-01"}},
+			"maxRows":  {Type: "integer", Description: fmt.Sprintf("Rows to load at most (default %d, at most %d)", defaultMaxRecords, maxRecords)},
+		},
+		Outputs: []string{"records", "count", "truncated"},
+	}, runSQLiteQuery},
+	NodeTypeCSVFile: {NodeType{
+		Type:        NodeTypeCSVFile,
+		Category:    NodeCategorySource,
+		Name:        "CSV File",
+		Description: "Load records from a CSV file in the data directory; the header row names the fields",
+		Parameters: map[string]NodeParameter{
+			"path":      {Type: "string", Description: "CSV file, relative to the data directory", Required: true, Example: "exports/june.csv"},
+			"delimiter": {Type: "string", Description: "Field delimiter (default \",\")", Example: ";"},
+			"maxRows":   {Type: "integer", Description: fmt.Sprintf("Rows to load at most (default %d, at most %d)", defaultMaxRecords, maxRecords)},
+		},
+		Outputs: []string{"records", "count", "columns", "truncated"},
+	}, runCSVFile},
+	NodeTypeHTTPFetch: {NodeType{
+		Type:        NodeTypeHTTPFetch,
+		Category:    NodeCategorySource,
+		Name:        "HTTP Fetch",
+		Description: "Load records from a JSON response of an HTTP GET request",
+		Parameters: map[string]NodeParameter{
+			"url":         {Type: "string", Description: "http or https URL to fetch", Required: true, Example: "https://crm.example.com/api/tickets"},
+			"headers":     {Type: "object", Description: "Request headers", Example: map[string]string{"Authorization": "Bearer ..."}},
+			"recordsPath": {Type: "string", Description: "Dotted path of the list of records in the response; by default the response itself", Example: "data.items"},
+			"maxRows":     {Type: "integer", Description: fmt.Sprintf("Records to load at most (default %d, at most %d)", defaultMaxRecords, maxRecords)},
+		},
+		Outputs: []string{"records", "count", "status", "truncated"},
+	}, runHTTPFetch},
+	NodeTypeFilter: {NodeType{
+		Type:        NodeTypeFilter,
+		Category:    NodeCategoryTransform,
+		Name:        "Filter",
+		Description: "Keep the records that pass conditions, written like webhook conditions",
+		Parameters: map[string]NodeParameter{
+			"conditions": {Type: "array", Description: "Conditions with a path, an op (eq, ne, in, contains, gt, gte, lt, lte or exists) and a value", Required: true, Example: []map[string]interface{}{{"path": "channel", "op": "eq", "value": "phone"}}},
+			"match":      {Type: "string", Description: "all (default) keeps records passing every condition, any those passing one", Example: "any"},
+			"records":    recordsInput,
+		},
+		Outputs: []string{"records", "count", "dropped"},
+	}, runFilter},
+	NodeTypeMapFields: {NodeType{
+		Type:        NodeTypeMapFields,
+		Category:    NodeCategoryTransform,
+		Name:        "Map Fields",
+		Description: "Rename and select the fields of records",
+		Parameters: map[string]NodeParameter{
+			"mappings":     {Type: "object", Description: "Output field names with the dotted paths of the fields they are read from", Required: true, Example: map[string]string{"conversation_id": "id", "text": "transcript.body"}},
+			"keepUnmapped": {Type: "boolean", Description: "Keep the fields no mapping reads from (default false)"},
+			"records":      recordsInput,
+		},
+		Outputs: []string{"records", "count"},
+	}, runMapFields},
+	NodeTypeSample: {NodeType{
+		Type:        NodeTypeSample,
+		Category:    NodeCategoryTransform,
+		Name:        "Sample",
+		Description: "Keep N of the records, picked at random or the first ones",
+		Parameters: map[string]NodeParameter{
+			"size":    {Type: "integer", Description: "Records to keep", Required: true, Example: 50},
+			"method":  {Type: "string", Description: "random (default) or first", Example: "first"},
+			"seed":    {Type: "integer", Description: "Seed of random samples, so runs pick the same records", Example: 42},
+			"records": recordsInput,
+		},
+		Outputs: []string{"records", "count", "total"},
+	}, runSample},
+	NodeTypeSaveToDB: {NodeType{
+		Type:        NodeTypeSaveToDB,
+		Category:    NodeCategorySink,
+		Name:        "Save to Database",
+		Description: "Store records as conversations; records need a text field, and fields other than those of conversations are kept as metadata",
+		Parameters: map[string]NodeParameter{
+			"source":  {Type: "string", Description: "Source of the stored conversations when records have none (default \"workflow\")", Example: "crm_export"},
+			"records": recordsInput,
+		},
+		Outputs: []string{"saved", "created", "updated", "conversation_ids"},
+	}, runSaveToDB},
+	NodeTypeWebhook: {NodeType{
+		Type:        NodeTypeWebhook,
+		Category:    NodeCategorySink,
+		Name:        "Webhook",
+		Description: "POST records as JSON to a URL, signed like webhook deliveries when a secret is set",
+		Parameters: map[string]NodeParameter{
+			"url":     {Type: "string", Description: "http or https URL to post to", Required: true, Example: "https://hooks.example.com/disputes"},
+			"secret":  {Type: "string", Description: "Secret of the X-Webhook-Signature header"},
+			"records": recordsInput,
+		},
+		Outputs: []string{"status", "count"},
+	}, runWebhookSink},
+}
+
+// NodeTypes returns the node types of the library, sources first, then transforms and sinks
+func NodeTypes() []NodeType {
+	order := map[string]int{NodeCategorySource: 0, NodeCategoryTransform: 1, NodeCategorySink: 2}
+	types := make([]NodeType, 0, len(nodeLibrary))
+	for _, node := range nodeLibrary {
+		types = append(types, node.NodeType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if order[types[i].Category] != order[types[j].Category] {
+			return order[types[i].Category] < order[types[j].Category]
+		}
+		return types[i].Type < types[j].Type
+	})
+	return types
+}
+
+// isLibraryNode reports whether a node type is one of the node library
+func isLibraryNode(nodeType string) bool {
+	_, ok := nodeLibrary[nodeType]
+	return ok
+}
+
+// runLibraryNode runs a node of the library for a tenant over its inputs, after checking
+// that its required settings are set
+func runLibraryNode(tenantID string, node map[string]interface{}, nodeInputs map[string]interface{}) (map[string]interface{}, error) {
+	data, _ := node["data"].(map[string]interface{})
+	nodeType, _ := data["nodeType"].(string)
+	library, ok := nodeLibrary[nodeType]
+	if !ok {
+		return nil, fmt.Errorf("unknown node type %q", nodeType)
+	}
+	for name, parameter := range library.Parameters {
+		if value, set := data[name]; parameter.Required && (!set || value == nil || value == "") {
+			return nil, fmt.Errorf("%s is required", name)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(auth.WithTenant(context.Background(), tenantID), nodeTimeout)
+	defer cancel()
+	result, err := library.run(ctx, data, nodeInputs)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("node exceeded its %s time limit", nodeTimeout)
+	}
+	return result, err
+}
+
+// upstreamRecords returns the records output by a node connected to nodeID, so records
+// flow along edges without mappings
+func (e *Executor) upstreamRecords(nodeID string, results map[string]interface{}) (interface{}, bool) {
+	for _, edge := range e.edges {
+		if target, _ := edge["target"].(string); target != nodeID {
+			continue
+		}
+		source, _ := edge["source"].(string)
+		if output, ok := results[source].(map[string]interface{}); ok {
+			if records, ok := output[recordsKey]; ok {
+				return records, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// inputRecords returns a copy of the records input of a node
+func inputRecords(inputs map[string]interface{}) ([]map[string]interface{}, error) {
+	value, ok := inputs[recordsKey]
+	if !ok {
+		return nil, fmt.Errorf("no records input; connect a data source node or map records to this node")
+	}
+	copied, err := copyJSON(value)
+	if err != nil {
+		return nil, fmt.Errorf("records are not serializable: %w", err)
+	}
+	items, ok := copied.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("records must be a list of objects")
+	}
+	if len(items) > maxRecords {
+		return nil, fmt.Errorf("at most %d records can be processed, got %d", maxRecords, len(items))
+	}
+	records := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		record, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("record %d is not an object", i)
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+// recordsOutput returns the output of a node that produced records, with their values
+// decoded as JSON so later nodes see the same types wherever records come from
+func recordsOutput(records []map[string]interface{}) (map[string]interface{}, error) {
+	copied, err := copyJSON(records)
+	if err != nil {
+		return nil, fmt.Errorf("records are not serializable: %w", err)
+	}
+	if records == nil {
+		copied = []interface{}{}
+	}
+	return map[string]interface{}{recordsKey: copied, "count": len(records)}, nil
+}
+
+// stringSetting returns a string setting of a node
+func stringSetting(data map[string]interface{}, name string) string {
+	value, _ := data[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// intSetting returns an integer setting of a node, or def when it is not set
+func intSetting(data map[string]interface{}, name string, def int) (int, error) {
+	value, ok := data[name]
+	if !ok || value == nil {
+		return def, nil
+	}
+	number, ok := value.(float64)
+	if !ok || number != float64(int(number)) {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	return int(number), nil
+}
+
+// maxRowsSetting returns how many records a source loads at most
+func maxRowsSetting(data map[string]interface{}) (int, error) {
+	rows, err := intSetting(data, "maxRows", defaultMaxRecords)
+	if err != nil {
+		return 0, err
+	}
+	if rows <= 0 || rows > maxRecords {
+		return 0, fmt.Errorf("maxRows must be between 1 and %d", maxRecords)
+	}
+	return rows, nil
+}
+
+// dataFile returns the path of a file in the data directory of the tenant of ctx,
+// rejecting names that are absolute or lead outside of it
+func dataFile(ctx context.Context, name string) (string, error) {
+	if name == "" || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%q must be a path relative to the data directory", name)
+	}
+	tenantID := auth.TenantID(ctx)
+	if !filepath.IsLocal(tenantID) || filepath.Base(tenantID) != tenantID {
+		return "", fmt.Errorf("tenant %q has no data directory", tenantID)
+	}
+	dir := os.Getenv(EnvDataDir)
+	if dir == "" {
+		dir = defaultDataDir
+	}
+	return filepath.Join(dir, tenantID, name), nil
+}
@@ -0,0 +1,358 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"agenticflows/backend/db"
+
+	"github.com/google/uuid"
+)
+
+// Supported workflow edit operation types
+const (
+	OpAddNode    = "add_node"
+	OpDeleteNode = "delete_node"
+	OpUpdateNode = "update_node"
+	OpAddEdge    = "add_edge"
+	OpDeleteEdge = "delete_edge"
+	OpUpdateEdge = "update_edge"
+)
+
+// ErrNothingToUndo and ErrNothingToRedo are returned when the operation log has no entry to reverse or reapply
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// ErrInvalidOperation wraps errors caused by operations that cannot be applied to the workflow
+var ErrInvalidOperation = errors.New("invalid operation")
+
+// Operation is a single granular edit to a workflow graph.
+// For update operations, Data keys are merged into the element's data and
+// a null value removes the key.
+type Operation struct {
+	Type     string                 `json:"type"`
+	NodeID   string                 `json:"node_id,omitempty"`
+	EdgeID   string                 `json:"edge_id,omitempty"`
+	Node     map[string]interface{} `json:"node,omitempty"`
+	Edge     map[string]interface{} `json:"edge,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	Position map[string]interface{} `json:"position,omitempty"`
+
+	// Index places an added element at a specific position, used to restore deleted elements on undo
+	Index *int `json:"index,omitempty"`
+}
+
+// Document is an editable, parsed copy of a workflow graph
+type Document struct {
+	Nodes []map[string]interface{}
+	Edges []map[string]interface{}
+}
+
+// NewDocument parses the nodes and edges of a workflow into an editable document
+func NewDocument(w db.Workflow) (*Document, error) {
+	doc := &Document{
+		Nodes: []map[string]interface{}{},
+		Edges: []map[string]interface{}{},
+	}
+
+	if len(w.Nodes) > 0 {
+		if err := json.Unmarshal(w.Nodes, &doc.Nodes); err != nil {
+			return nil, fmt.Errorf("error parsing workflow nodes: %v", err)
+		}
+	}
+	if len(w.Edges) > 0 {
+		if err := json.Unmarshal(w.Edges, &doc.Edges); err != nil {
+			return nil, fmt.Errorf("error parsing workflow edges: %v", err)
+		}
+	}
+
+	return doc, nil
+}
+
+// ApplyTo writes the document's nodes and edges back into the workflow
+func (d *Document) ApplyTo(w *db.Workflow) error {
+	nodesJSON, err := json.Marshal(d.Nodes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal nodes: %v", err)
+	}
+	edgesJSON, err := json.Marshal(d.Edges)
+	if err != nil {
+		return fmt.Errorf("failed to marshal edges: %v", err)
+	}
+
+	w.Nodes = nodesJSON
+	w.Edges = edgesJSON
+	return nil
+}
+
+// Apply applies operations in order and returns the operations that undo them.
+// If any operation fails the document is left partially modified, so callers
+// should discard it rather than persist it.
+func (d *Document) Apply(ops []Operation) ([]Operation, error) {
+	inverses := make([][]Operation, 0, len(ops))
+
+	for i, op := range ops {
+		inverse, err := d.apply(op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i+1, op.Type, err)
+		}
+		inverses = append(inverses, inverse)
+	}
+
+	// Undo runs the inverse operations in reverse order
+	undo := make([]Operation, 0, len(ops))
+	for i := len(inverses) - 1; i >= 0; i-- {
+		undo = append(undo, inverses[i]...)
+	}
+
+	return undo, nil
+}
+
+// apply applies a single operation and returns its inverse
+func (d *Document) apply(op Operation) ([]Operation, error) {
+	switch op.Type {
+	case OpAddNode:
+		id, _ := op.Node["id"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("node id is required")
+		}
+		if indexOf(d.Nodes, id) >= 0 {
+			return nil, fmt.Errorf("node %s already exists", id)
+		}
+		d.Nodes = insertAt(d.Nodes, op.Node, op.Index)
+		return []Operation{{Type: OpDeleteNode, NodeID: id}}, nil
+
+	case OpDeleteNode:
+		idx := indexOf(d.Nodes, op.NodeID)
+		if idx < 0 {
+			return nil, fmt.Errorf("node %s not found", op.NodeID)
+		}
+		node := d.Nodes[idx]
+		d.Nodes = append(d.Nodes[:idx], d.Nodes[idx+1:]...)
+
+		nodeIndex := idx
+		inverse := []Operation{{Type: OpAddNode, Node: node, Index: &nodeIndex}}
+
+		// Remove edges connected to the deleted node, remembering their positions
+		remaining := make([]map[string]interface{}, 0, len(d.Edges))
+		for i, edge := range d.Edges {
+			source, _ := edge["source"].(string)
+			target, _ := edge["target"].(string)
+			if source == op.NodeID || target == op.NodeID {
+				edgeIndex := i
+				inverse = append(inverse, Operation{Type: OpAddEdge, Edge: edge, Index: &edgeIndex})
+				continue
+			}
+			remaining = append(remaining, edge)
+		}
+		d.Edges = remaining
+
+		return inverse, nil
+
+	case OpUpdateNode:
+		idx := indexOf(d.Nodes, op.NodeID)
+		if idx < 0 {
+			return nil, fmt.Errorf("node %s not found", op.NodeID)
+		}
+		inverse := updateElement(d.Nodes[idx], op)
+		inverse.Type = OpUpdateNode
+		inverse.NodeID = op.NodeID
+		return []Operation{inverse}, nil
+
+	case OpAddEdge:
+		id, _ := op.Edge["id"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("edge id is required")
+		}
+		if indexOf(d.Edges, id) >= 0 {
+			return nil, fmt.Errorf("edge %s already exists", id)
+		}
+		source, _ := op.Edge["source"].(string)
+		target, _ := op.Edge["target"].(string)
+		if indexOf(d.Nodes, source) < 0 || indexOf(d.Nodes, target) < 0 {
+			return nil, fmt.Errorf("edge %s must connect existing nodes", id)
+		}
+		d.Edges = insertAt(d.Edges, op.Edge, op.Index)
+		return []Operation{{Type: OpDeleteEdge, EdgeID: id}}, nil
+
+	case OpDeleteEdge:
+		idx := indexOf(d.Edges, op.EdgeID)
+		if idx < 0 {
+			return nil, fmt.Errorf("edge %s not found", op.EdgeID)
+		}
+		edge := d.Edges[idx]
+		d.Edges = append(d.Edges[:idx], d.Edges[idx+1:]...)
+		return []Operation{{Type: OpAddEdge, Edge: edge, Index: &idx}}, nil
+
+	case OpUpdateEdge:
+		idx := indexOf(d.Edges, op.EdgeID)
+		if idx < 0 {
+			return nil, fmt.Errorf("edge %s not found", op.EdgeID)
+		}
+		inverse := updateElement(d.Edges[idx], op)
+		inverse.Type = OpUpdateEdge
+		inverse.EdgeID = op.EdgeID
+		return []Operation{inverse}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported operation type: %s", op.Type)
+	}
+}
+
+// updateElement merges the operation's data and position into a node or edge
+// and returns an operation carrying the previous values
+func updateElement(element map[string]interface{}, op Operation) Operation {
+	inverse := Operation{}
+
+	if len(op.Data) > 0 {
+		data, _ := element["data"].(map[string]interface{})
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+
+		inverse.Data = make(map[string]interface{}, len(op.Data))
+		for k, v := range op.Data {
+			// A nil previous value tells the inverse to remove the key again
+			inverse.Data[k] = data[k]
+			if v == nil {
+				delete(data, k)
+			} else {
+				data[k] = v
+			}
+		}
+		element["data"] = data
+	}
+
+	if op.Position != nil {
+		previous, _ := element["position"].(map[string]interface{})
+		inverse.Position = previous
+		element["position"] = op.Position
+	}
+
+	return inverse
+}
+
+// indexOf returns the index of the element with the given ID, or -1
+func indexOf(elements []map[string]interface{}, id string) int {
+	for i, element := range elements {
+		if elementID, _ := element["id"].(string); elementID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertAt inserts an element at index, or appends it when index is nil or out of range
+func insertAt(elements []map[string]interface{}, element map[string]interface{}, index *int) []map[string]interface{} {
+	if index == nil || *index < 0 || *index >= len(elements) {
+		return append(elements, element)
+	}
+
+	elements = append(elements, nil)
+	copy(elements[*index+1:], elements[*index:])
+	elements[*index] = element
+	return elements
+}
+
+// ApplyOperations applies a group of edits to a workflow at its current version,
+// persists the result and records the edits in the operation log
+func ApplyOperations(w db.Workflow, ops []Operation, actor string) (db.Workflow, error) {
+	if len(ops) == 0 {
+		return db.Workflow{}, fmt.Errorf("%w: at least one operation is required", ErrInvalidOperation)
+	}
+
+	doc, err := NewDocument(w)
+	if err != nil {
+		return db.Workflow{}, err
+	}
+
+	inverse, err := doc.Apply(ops)
+	if err != nil {
+		return db.Workflow{}, fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+	}
+
+	updated := w
+	if err := doc.ApplyTo(&updated); err != nil {
+		return db.Workflow{}, err
+	}
+
+	opsJSON, err := json.Marshal(ops)
+	if err != nil {
+		return db.Workflow{}, fmt.Errorf("failed to marshal operations: %v", err)
+	}
+	inverseJSON, err := json.Marshal(inverse)
+	if err != nil {
+		return db.Workflow{}, fmt.Errorf("failed to marshal inverse operations: %v", err)
+	}
+
+	entry := db.WorkflowOperation{
+		ID:         uuid.New().String(),
+		WorkflowID: w.ID,
+		Operations: opsJSON,
+		Inverse:    inverseJSON,
+		Actor:      actor,
+	}
+	if err := db.RecordWorkflowOperation(updated, w.Version, entry); err != nil {
+		return db.Workflow{}, err
+	}
+
+	updated.Version = w.Version + 1
+	return updated, nil
+}
+
+// Undo reverses the most recent applied entry in the workflow's operation log
+func Undo(w db.Workflow) (db.Workflow, error) {
+	entry, err := db.GetUndoOperation(w.TenantID, w.ID)
+	if err != nil {
+		return db.Workflow{}, err
+	}
+	if entry == nil {
+		return db.Workflow{}, ErrNothingToUndo
+	}
+
+	return replayEntry(w, entry, entry.Inverse, db.OperationUndone)
+}
+
+// Redo reapplies the most recently undone entry in the workflow's operation log
+func Redo(w db.Workflow) (db.Workflow, error) {
+	entry, err := db.GetRedoOperation(w.TenantID, w.ID)
+	if err != nil {
+		return db.Workflow{}, err
+	}
+	if entry == nil {
+		return db.Workflow{}, ErrNothingToRedo
+	}
+
+	return replayEntry(w, entry, entry.Operations, db.OperationApplied)
+}
+
+// replayEntry applies the stored operations of a log entry and moves the entry to a new state
+func replayEntry(w db.Workflow, entry *db.WorkflowOperation, opsJSON json.RawMessage, state string) (db.Workflow, error) {
+	var ops []Operation
+	if err := json.Unmarshal(opsJSON, &ops); err != nil {
+		return db.Workflow{}, fmt.Errorf("failed to parse stored operations: %v", err)
+	}
+
+	doc, err := NewDocument(w)
+	if err != nil {
+		return db.Workflow{}, err
+	}
+	if _, err := doc.Apply(ops); err != nil {
+		return db.Workflow{}, fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+	}
+
+	updated := w
+	if err := doc.ApplyTo(&updated); err != nil {
+		return db.Workflow{}, err
+	}
+
+	if err := db.SetWorkflowOperationState(updated, w.Version, entry.ID, state); err != nil {
+		return db.Workflow{}, err
+	}
+
+	updated.Version = w.Version + 1
+	return updated, nil
+}
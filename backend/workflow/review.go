@@ -0,0 +1,95 @@
+package workflow
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// NodeTypeReview marks a node where a run pauses until a human approves, edits or rejects
+// the intermediate result that reaches it
+const NodeTypeReview = "review"
+
+// ReviewPause is returned by Execute and Resume when a run reaches a review node. The run
+// stops there: Results hold the data and the outputs of the nodes that ran, Completed
+// lists those nodes, and Item is the intermediate result to review.
+type ReviewPause struct {
+	NodeID    string
+	Item      map[string]interface{}
+	Results   map[string]interface{}
+	Completed []string
+}
+
+// Error describes the pause
+func (p *ReviewPause) Error() string {
+	return fmt.Sprintf("run paused for review at node %s", p.NodeID)
+}
+
+// Resume continues a paused run with the reviewed item as the output of its review node.
+// Results and completed are those of the ReviewPause; nodes that completed are not run
+// again. The run may pause again at a later review node.
+func (e *Executor) Resume(results map[string]interface{}, completed []string, nodeID string, reviewed map[string]interface{}) (map[string]interface{}, error) {
+	node := e.node(nodeID)
+	if node == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeID)
+	}
+	if data, _ := node["data"].(map[string]interface{}); data["nodeType"] != NodeTypeReview {
+		return nil, fmt.Errorf("node %s is not a review node", nodeID)
+	}
+
+	log.Printf("Resuming workflow '%s' after review at node '%s'", e.workflow.Name, nodeID)
+	if results == nil {
+		results = make(map[string]interface{})
+	}
+	results[nodeID] = reviewed
+	e.nodeRuns = append(e.nodeRuns, NodeRun{NodeID: nodeID, NodeType: NodeTypeReview, Status: NodeSucceeded, Started: time.Now(), Output: reviewed})
+	return e.run(results, append(append([]string(nil), completed...), nodeID))
+}
+
+// ReviewSettings returns the label and the reviewer instructions of a review node
+func (e *Executor) ReviewSettings(nodeID string) (label, instructions string) {
+	if node := e.node(nodeID); node != nil {
+		data, _ := node["data"].(map[string]interface{})
+		label, _ = data["label"].(string)
+		instructions, _ = data["instructions"].(string)
+	}
+	return label, instructions
+}
+
+// reviewItem returns what a review node receives: the inputs mapped by its incoming edges,
+// or else the output of the node connected to it, or the outputs of several connected
+// nodes by node ID
+func (e *Executor) reviewItem(nodeID string, results map[string]interface{}) map[string]interface{} {
+	if mapped := e.mappedInputs(nodeID, results); len(mapped) > 0 {
+		return mapped
+	}
+
+	outputs := make(map[string]interface{})
+	for _, edge := range e.edges {
+		if target, _ := edge["target"].(string); target != nodeID {
+			continue
+		}
+		source, _ := edge["source"].(string)
+		if output, ok := results[source]; ok {
+			outputs[source] = output
+		}
+	}
+	if len(outputs) == 1 {
+		for _, output := range outputs {
+			if object, ok := output.(map[string]interface{}); ok {
+				return object
+			}
+		}
+	}
+	return outputs
+}
+
+// node returns the node with an ID, or nil
+func (e *Executor) node(nodeID string) map[string]interface{} {
+	for _, n := range e.nodes {
+		if id, _ := n["id"].(string); id == nodeID {
+			return n
+		}
+	}
+	return nil
+}
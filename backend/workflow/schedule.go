@@ -0,0 +1,196 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"agenticflows/backend/db"
+)
+
+// Schedule health statuses
+const (
+	ScheduleHealthy = "healthy"
+	// ScheduleStale means no run succeeded within the expected window
+	ScheduleStale = "stale"
+	// ScheduleFailing means the latest run failed
+	ScheduleFailing = "failing"
+	// ScheduleInvalid means the schedule could not be understood
+	ScheduleInvalid = "invalid"
+)
+
+// scheduleIntervals are the named schedules
+var scheduleIntervals = map[string]time.Duration{
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// Schedule is how often a workflow is expected to run successfully
+type Schedule struct {
+	Interval time.Duration
+	// Grace is how late a run may be before the schedule is stale
+	Grace time.Duration
+}
+
+// Window returns how long may pass between successful runs
+func (s Schedule) Window() time.Duration {
+	return s.Interval + s.Grace
+}
+
+// ParseSchedule reads the schedule of a node: a named schedule ("hourly", "daily",
+// "weekly"), an interval such as "6h", or an object with "interval" and an optional
+// "grace". Grace defaults to a tenth of the interval.
+func ParseSchedule(value interface{}) (Schedule, error) {
+	var interval, grace string
+	switch v := value.(type) {
+	case string:
+		interval = v
+	case map[string]interface{}:
+		interval, _ = v["interval"].(string)
+		grace, _ = v["grace"].(string)
+	default:
+		return Schedule{}, fmt.Errorf("schedule must be a string or an object with an interval")
+	}
+
+	var schedule Schedule
+	interval = strings.ToLower(strings.TrimSpace(interval))
+	if named, ok := scheduleIntervals[interval]; ok {
+		schedule.Interval = named
+	} else {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed <= 0 {
+			return Schedule{}, fmt.Errorf("unsupported schedule interval %q; use hourly, daily, weekly or a duration such as \"6h\"", interval)
+		}
+		schedule.Interval = parsed
+	}
+
+	schedule.Grace = schedule.Interval / 10
+	if grace != "" {
+		parsed, err := time.ParseDuration(grace)
+		if err != nil || parsed < 0 {
+			return Schedule{}, fmt.Errorf("invalid schedule grace %q", grace)
+		}
+		schedule.Grace = parsed
+	}
+	return schedule, nil
+}
+
+// ScheduleHealth reports whether a scheduled workflow is running as expected
+type ScheduleHealth struct {
+	WorkflowID    string     `json:"workflow_id"`
+	WorkflowName  string     `json:"workflow_name"`
+	Status        string     `json:"status"`
+	Reason        string     `json:"reason"`
+	Interval      string     `json:"interval,omitempty"`
+	Window        string     `json:"window,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	// DueBy is when the next successful run is expected at the latest
+	DueBy *time.Time `json:"due_by,omitempty"`
+}
+
+// workflowSchedule returns the shortest schedule of a workflow's nodes, since a
+// workflow run covers all of them. ok is false for workflows without schedules.
+func workflowSchedule(w db.Workflow) (schedule Schedule, ok bool, err error) {
+	doc, err := NewDocument(w)
+	if err != nil {
+		return Schedule{}, true, err
+	}
+	for _, node := range doc.Nodes {
+		data, _ := node["data"].(map[string]interface{})
+		value, found := data[DataSchedule]
+		if !found || value == nil {
+			continue
+		}
+		parsed, err := ParseSchedule(value)
+		if err != nil {
+			return Schedule{}, true, fmt.Errorf("node %v: %w", node["id"], err)
+		}
+		if !ok || parsed.Window() < schedule.Window() {
+			schedule = parsed
+		}
+		ok = true
+	}
+	return schedule, ok, nil
+}
+
+// CheckSchedules reports the health of every scheduled workflow of a tenant, or of every
+// tenant for db.AllTenants. Runs are read from the workflow_run_completed and
+// workflow_run_failed activity.
+func CheckSchedules(tenantID string, now time.Time) ([]ScheduleHealth, error) {
+	workflows, err := db.GetAllWorkflows(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	health := []ScheduleHealth{}
+	for _, w := range workflows {
+		schedule, ok, err := workflowSchedule(w)
+		if !ok {
+			continue
+		}
+		status := ScheduleHealth{WorkflowID: w.ID, WorkflowName: w.Name}
+		if err != nil {
+			status.Status = ScheduleInvalid
+			status.Reason = err.Error()
+			health = append(health, status)
+			continue
+		}
+		status.Interval = schedule.Interval.String()
+		status.Window = schedule.Window().String()
+
+		if status.LastSuccessAt, err = lastRun(w.ID, db.ActivityWorkflowRunCompleted); err != nil {
+			return nil, err
+		}
+		if status.LastFailureAt, err = lastRun(w.ID, db.ActivityWorkflowRunFailed); err != nil {
+			return nil, err
+		}
+
+		// Workflows that never succeeded are measured from the day they were created
+		since := status.LastSuccessAt
+		if since == nil {
+			if created, err := time.ParseInLocation("2006-01-02", w.Date, now.Location()); err == nil {
+				since = &created
+			}
+		}
+		if since != nil {
+			dueBy := since.Add(schedule.Window())
+			status.DueBy = &dueBy
+		}
+
+		switch {
+		case status.LastFailureAt != nil && (status.LastSuccessAt == nil || status.LastFailureAt.After(*status.LastSuccessAt)):
+			status.Status = ScheduleFailing
+			status.Reason = fmt.Sprintf("latest run failed at %s", status.LastFailureAt.Format(time.RFC3339))
+		case status.DueBy != nil && now.After(*status.DueBy):
+			status.Status = ScheduleStale
+			if status.LastSuccessAt == nil {
+				status.Reason = fmt.Sprintf("no successful run since the workflow was created; expected every %s", status.Interval)
+			} else {
+				status.Reason = fmt.Sprintf("no successful run for %s; expected every %s", now.Sub(*status.LastSuccessAt).Round(time.Minute), status.Interval)
+			}
+		case status.LastSuccessAt == nil:
+			status.Status = ScheduleHealthy
+			status.Reason = "first run is not due yet"
+		default:
+			status.Status = ScheduleHealthy
+			status.Reason = "last successful run is within the expected window"
+		}
+		health = append(health, status)
+	}
+	return health, nil
+}
+
+// lastRun returns when a workflow last recorded a run activity of the given type
+func lastRun(workflowID, activityType string) (*time.Time, error) {
+	activities, err := db.GetActivity(db.ActivityFilter{
+		WorkflowID: workflowID,
+		Types:      []string{activityType},
+		Limit:      1,
+	})
+	if err != nil || len(activities) == 0 {
+		return nil, err
+	}
+	return &activities[0].CreatedAt, nil
+}
@@ -0,0 +1,134 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"agenticflows/backend/db"
+	"agenticflows/backend/notify"
+
+	"github.com/google/uuid"
+)
+
+// envScheduleCheckInterval sets how often schedule health is checked for alerts
+const envScheduleCheckInterval = "SCHEDULE_HEALTH_CHECK_INTERVAL"
+
+// defaultScheduleCheckInterval is used when envScheduleCheckInterval is unset
+const defaultScheduleCheckInterval = 5 * time.Minute
+
+// ScheduleCheckInterval returns how often the schedule monitor checks schedule health
+func ScheduleCheckInterval() time.Duration {
+	value := os.Getenv(envScheduleCheckInterval)
+	if value == "" {
+		return defaultScheduleCheckInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		log.Printf("Warning: ignoring invalid %s value %q", envScheduleCheckInterval, value)
+		return defaultScheduleCheckInterval
+	}
+	return interval
+}
+
+// ScheduleMonitor alerts when scheduled workflows become stale or start failing, and
+// again when they recover
+type ScheduleMonitor struct {
+	notifier notify.Notifier
+	// alerted holds the unhealthy status last alerted for each workflow
+	alerted map[string]string
+}
+
+// NewScheduleMonitor creates a schedule monitor sending alerts to notifier
+func NewScheduleMonitor(notifier notify.Notifier) *ScheduleMonitor {
+	return &ScheduleMonitor{notifier: notifier, alerted: map[string]string{}}
+}
+
+// Run checks schedule health every interval until ctx is done
+func (m *ScheduleMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check alerts for the workflows whose schedule health changed since the last check.
+// Each unhealthy status is alerted once.
+func (m *ScheduleMonitor) Check(ctx context.Context) {
+	health, err := CheckSchedules(db.AllTenants, time.Now())
+	if err != nil {
+		log.Printf("Error checking schedule health: %v", err)
+		return
+	}
+
+	seen := make(map[string]bool, len(health))
+	for _, status := range health {
+		seen[status.WorkflowID] = true
+		previous := m.alerted[status.WorkflowID]
+
+		switch status.Status {
+		case ScheduleHealthy:
+			if previous != "" {
+				delete(m.alerted, status.WorkflowID)
+				m.alert(ctx, status, notify.SeverityResolved,
+					fmt.Sprintf("Workflow \"%s\" schedule recovered", status.WorkflowName))
+			}
+		case previous:
+		default:
+			m.alerted[status.WorkflowID] = status.Status
+			severity := notify.SeverityWarning
+			if status.Status == ScheduleFailing {
+				severity = notify.SeverityCritical
+			}
+			m.alert(ctx, status, severity,
+				fmt.Sprintf("Workflow \"%s\" schedule is %s", status.WorkflowName, status.Status))
+		}
+	}
+
+	// Forget workflows that were deleted or are no longer scheduled
+	for workflowID := range m.alerted {
+		if !seen[workflowID] {
+			delete(m.alerted, workflowID)
+		}
+	}
+}
+
+// alert records a schedule alert in the activity feed and sends it to the notifier
+func (m *ScheduleMonitor) alert(ctx context.Context, status ScheduleHealth, severity, title string) {
+	alert := notify.Alert{
+		Title:      title,
+		Message:    status.Reason,
+		Severity:   severity,
+		WorkflowID: status.WorkflowID,
+		Details:    map[string]interface{}{"status": status.Status, "last_success_at": status.LastSuccessAt, "due_by": status.DueBy},
+		Time:       time.Now(),
+	}
+
+	details, _ := json.Marshal(alert)
+	if err := db.RecordActivity(db.Activity{
+		ID:         uuid.New().String(),
+		Type:       db.ActivityScheduleAlert,
+		WorkflowID: status.WorkflowID,
+		Actor:      "system",
+		Summary:    title,
+		Details:    details,
+	}); err != nil {
+		log.Printf("Error recording schedule alert: %v", err)
+	}
+
+	if m.notifier == nil {
+		return
+	}
+	if err := m.notifier.Notify(ctx, alert); err != nil {
+		log.Printf("Error sending schedule alert for workflow %s: %v", status.WorkflowID, err)
+	}
+}
@@ -0,0 +1,93 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// heapMetric is the size of the live and not yet collected objects on the heap
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// scriptMemoryCheckInterval is how often a running script's memory use is checked
+const scriptMemoryCheckInterval = 10 * time.Millisecond
+
+// gojaEngine runs JavaScript transforms in an isolated goja runtime. goja provides no
+// require, console, timers, or I/O, so scripts can only compute over their input.
+type gojaEngine struct{}
+
+func init() {
+	RegisterScriptEngine("javascript", gojaEngine{})
+	RegisterScriptEngine("js", gojaEngine{})
+}
+
+// Run evaluates the script in a fresh runtime and calls its transform function
+func (gojaEngine) Run(ctx context.Context, script string, input map[string]interface{}) (interface{}, error) {
+	vm := goja.New()
+	// Deep recursion stops the script instead of growing the stack
+	vm.SetMaxCallStackSize(maxScriptCallStack)
+
+	// Stop the script when the deadline passes or the heap grows past the limit. goja
+	// can't measure a runtime's memory, so the process heap is watched while it runs.
+	done := make(chan struct{})
+	defer close(done)
+	baseline := heapBytes()
+	go func() {
+		ticker := time.NewTicker(scriptMemoryCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				vm.Interrupt(ctx.Err())
+				return
+			case <-ticker.C:
+				if heap := heapBytes(); heap > baseline && heap-baseline > maxScriptHeapGrowth {
+					vm.Interrupt(fmt.Errorf("script used more than %d MB of memory", maxScriptHeapGrowth/(1024*1024)))
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if _, err := vm.RunString(script); err != nil {
+		return nil, gojaError(err)
+	}
+
+	transform, ok := goja.AssertFunction(vm.Get("transform"))
+	if !ok {
+		return nil, fmt.Errorf("script must define a transform(input) function")
+	}
+
+	result, err := transform(goja.Undefined(), vm.ToValue(input))
+	if err != nil {
+		return nil, gojaError(err)
+	}
+	return result.Export(), nil
+}
+
+// gojaError names the limit a script ran into, which goja reports without a message
+func gojaError(err error) error {
+	var overflow *goja.StackOverflowError
+	if errors.As(err, &overflow) {
+		return fmt.Errorf("script exceeded %d nested calls: %s", maxScriptCallStack, strings.TrimSpace(err.Error()))
+	}
+	return err
+}
+
+// heapBytes returns the bytes of objects on the heap, without stopping the world like
+// runtime.ReadMemStats
+func heapBytes() uint64 {
+	samples := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return samples[0].Value.Uint64()
+}
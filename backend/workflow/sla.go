@@ -0,0 +1,160 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"agenticflows/backend/db"
+	"agenticflows/backend/notify"
+
+	"github.com/google/uuid"
+)
+
+// RunMeasurement is what a workflow run is checked against its SLA with
+type RunMeasurement struct {
+	Runtime    time.Duration
+	Cost       float64
+	Confidence *float64 // Unset when the run reports none, which is not checked
+	Failed     bool
+}
+
+// RunConfidence returns the confidence a run reports: the overall confidence of its
+// results when set, otherwise the lowest confidence of its node results
+func RunConfidence(results map[string]interface{}) *float64 {
+	if confidence, ok := results["confidence"].(float64); ok {
+		return &confidence
+	}
+	var lowest *float64
+	for _, value := range results {
+		nodeResults, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if confidence, ok := nodeResults["confidence"].(float64); ok && (lowest == nil || confidence < *lowest) {
+			lowest = &confidence
+		}
+	}
+	return lowest
+}
+
+// CheckSLA returns the limits of an SLA that a run breached
+func CheckSLA(sla db.WorkflowSLA, m RunMeasurement) []db.SLABreach {
+	breaches := []db.SLABreach{}
+	if m.Failed {
+		breaches = append(breaches, db.SLABreach{Metric: db.SLAMetricFailed})
+	}
+	if runtime := m.Runtime.Seconds(); sla.MaxRuntimeSeconds > 0 && runtime > sla.MaxRuntimeSeconds {
+		breaches = append(breaches, db.SLABreach{Metric: db.SLAMetricRuntime, Limit: sla.MaxRuntimeSeconds, Actual: runtime})
+	}
+	if sla.MaxCost > 0 && m.Cost > sla.MaxCost {
+		breaches = append(breaches, db.SLABreach{Metric: db.SLAMetricCost, Limit: sla.MaxCost, Actual: m.Cost})
+	}
+	if sla.MinConfidence > 0 && m.Confidence != nil && *m.Confidence < sla.MinConfidence {
+		breaches = append(breaches, db.SLABreach{Metric: db.SLAMetricConfidence, Limit: sla.MinConfidence, Actual: *m.Confidence})
+	}
+	return breaches
+}
+
+// SLARecorder records the SLA compliance of workflow runs and alerts on breaches
+type SLARecorder struct {
+	notifier notify.Notifier
+}
+
+// NewSLARecorder creates an SLA recorder sending breach alerts to notifier, which may be nil
+func NewSLARecorder(notifier notify.Notifier) *SLARecorder {
+	return &SLARecorder{notifier: notifier}
+}
+
+// Record checks a run of a workflow against its SLA and stores the result. Runs of
+// workflows without an SLA are not recorded. Failures are logged, never returned.
+func (s *SLARecorder) Record(ctx context.Context, workflowID, kind string, m RunMeasurement) {
+	sla, ok, err := db.GetWorkflowSLA(workflowID)
+	if err != nil {
+		log.Printf("Error checking SLA of workflow %s: %v", workflowID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	breaches := CheckSLA(sla, m)
+	run := db.SLARun{
+		ID:             uuid.New().String(),
+		WorkflowID:     workflowID,
+		Kind:           kind,
+		RuntimeSeconds: m.Runtime.Seconds(),
+		Cost:           m.Cost,
+		Confidence:     m.Confidence,
+		Failed:         m.Failed,
+		Compliant:      len(breaches) == 0,
+		Breaches:       breaches,
+		CreatedAt:      time.Now(),
+	}
+	if err := db.RecordSLARun(run); err != nil {
+		log.Printf("Error recording SLA compliance of workflow %s: %v", workflowID, err)
+	}
+	if !run.Compliant {
+		// Alert in the background so a slow webhook does not hold up the response of the run
+		go s.alert(context.WithoutCancel(ctx), run)
+	}
+}
+
+// alert records an SLA breach in the activity feed and sends it to the notifier
+func (s *SLARecorder) alert(ctx context.Context, run db.SLARun) {
+	name := run.WorkflowID
+	if w, err := db.GetWorkflow(db.AllTenants, run.WorkflowID); err == nil {
+		name = w.Name
+	}
+	title := fmt.Sprintf("Workflow \"%s\" breached its SLA", name)
+
+	reasons := make([]string, len(run.Breaches))
+	for i, breach := range run.Breaches {
+		reasons[i] = breachReason(breach)
+	}
+	alert := notify.Alert{
+		Title:      title,
+		Message:    strings.Join(reasons, "; "),
+		Severity:   notify.SeverityWarning,
+		WorkflowID: run.WorkflowID,
+		Details:    map[string]interface{}{"run_id": run.ID, "kind": run.Kind, "breaches": run.Breaches},
+		Time:       run.CreatedAt,
+	}
+
+	details, _ := json.Marshal(alert)
+	if err := db.RecordActivity(db.Activity{
+		ID:         uuid.New().String(),
+		Type:       db.ActivitySLABreached,
+		WorkflowID: run.WorkflowID,
+		Actor:      "system",
+		Summary:    title,
+		Details:    details,
+	}); err != nil {
+		log.Printf("Error recording SLA breach: %v", err)
+	}
+
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.Notify(ctx, alert); err != nil {
+		log.Printf("Error sending SLA alert for workflow %s: %v", run.WorkflowID, err)
+	}
+}
+
+// breachReason describes a breach for an alert message
+func breachReason(breach db.SLABreach) string {
+	switch breach.Metric {
+	case db.SLAMetricFailed:
+		return "the run failed"
+	case db.SLAMetricRuntime:
+		return fmt.Sprintf("runtime %.3gs exceeded %.3gs", breach.Actual, breach.Limit)
+	case db.SLAMetricCost:
+		return fmt.Sprintf("cost $%.4f exceeded $%.4f", breach.Actual, breach.Limit)
+	case db.SLAMetricConfidence:
+		return fmt.Sprintf("confidence %.2f is below %.2f", breach.Actual, breach.Limit)
+	}
+	return breach.Metric
+}
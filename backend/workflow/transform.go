@@ -0,0 +1,144 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NodeTypeTransform marks a node that reshapes data with a sandboxed script
+const NodeTypeTransform = "transform"
+
+// Transform script limits
+const (
+	defaultScriptTimeout = time.Second
+	maxScriptTimeout     = 10 * time.Second
+	maxScriptSize        = 64 * 1024
+	maxTransformInput    = 4 * 1024 * 1024
+	maxTransformOutput   = 1024 * 1024
+	maxScriptCallStack   = 1024
+	maxScriptHeapGrowth  = 256 * 1024 * 1024
+)
+
+// ScriptEngine runs transform scripts written in one language. Engines must not give
+// scripts access to the file system, network, or process, and must stop when ctx is done.
+// A script defines a function transform(input) whose return value is the node's output.
+type ScriptEngine interface {
+	Run(ctx context.Context, script string, input map[string]interface{}) (interface{}, error)
+}
+
+var (
+	scriptEnginesMu sync.RWMutex
+	scriptEngines   = map[string]ScriptEngine{}
+)
+
+// RegisterScriptEngine makes a script language available to transform nodes
+func RegisterScriptEngine(language string, engine ScriptEngine) {
+	scriptEnginesMu.Lock()
+	defer scriptEnginesMu.Unlock()
+	scriptEngines[strings.ToLower(language)] = engine
+}
+
+// ScriptLanguages returns the languages transform nodes can be written in
+func ScriptLanguages() []string {
+	scriptEnginesMu.RLock()
+	defer scriptEnginesMu.RUnlock()
+
+	languages := make([]string, 0, len(scriptEngines))
+	for language := range scriptEngines {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// runTransform runs the script of a transform node over its inputs. Node data holds the
+// script, its language (default "javascript") and an optional timeoutMs.
+func runTransform(node map[string]interface{}, nodeInputs map[string]interface{}) (map[string]interface{}, error) {
+	data, _ := node["data"].(map[string]interface{})
+
+	script, _ := data["script"].(string)
+	if strings.TrimSpace(script) == "" {
+		return nil, fmt.Errorf("transform node has no script")
+	}
+	if len(script) > maxScriptSize {
+		return nil, fmt.Errorf("script is larger than %d bytes", maxScriptSize)
+	}
+
+	language, _ := data["language"].(string)
+	if language == "" {
+		language = "javascript"
+	}
+	scriptEnginesMu.RLock()
+	engine, ok := scriptEngines[strings.ToLower(language)]
+	scriptEnginesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("script language %q is not available in this build (available: %v)", language, ScriptLanguages())
+	}
+
+	timeout := defaultScriptTimeout
+	if ms, ok := data["timeoutMs"].(float64); ok && ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	if timeout > maxScriptTimeout {
+		timeout = maxScriptTimeout
+	}
+
+	// Scripts get a copy of the inputs so they cannot modify the results of other nodes
+	encodedInput, err := json.Marshal(nodeInputs)
+	if err != nil {
+		return nil, fmt.Errorf("inputs are not serializable: %w", err)
+	}
+	if len(encodedInput) > maxTransformInput {
+		return nil, fmt.Errorf("script input is larger than %d bytes", maxTransformInput)
+	}
+	var input map[string]interface{}
+	if err := json.Unmarshal(encodedInput, &input); err != nil || input == nil {
+		input = map[string]interface{}{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := engine.Run(ctx, script, input)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("script exceeded its %s time limit", timeout)
+		}
+		return nil, fmt.Errorf("script failed: %w", err)
+	}
+
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		return nil, fmt.Errorf("script output is not serializable: %w", err)
+	}
+	if len(encoded) > maxTransformOutput {
+		return nil, fmt.Errorf("script output is larger than %d bytes", maxTransformOutput)
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		return nil, fmt.Errorf("script output is not serializable: %w", err)
+	}
+	if object, ok := result.(map[string]interface{}); ok {
+		return object, nil
+	}
+	return map[string]interface{}{"value": result}, nil
+}
+
+// copyJSON deep-copies a value through its JSON encoding
+func copyJSON(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var copied interface{}
+	if err := json.Unmarshal(encoded, &copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
@@ -0,0 +1,20 @@
+"use client";
+
+import ActivityFeed from "@/components/ActivityFeed";
+
+export default function ActivityPage() {
+  return (
+    <div className="container mx-auto max-w-3xl py-8">
+      <div className="space-y-8">
+        <div>
+          <h1 className="text-2xl font-bold mb-2">Workspace Activity</h1>
+          <p className="text-muted-foreground">
+            Workflows created and edited, runs completed, results annotated and recommendations accepted across the workspace.
+          </p>
+        </div>
+
+        <ActivityFeed />
+      </div>
+    </div>
+  );
+}
@@ -4,8 +4,9 @@ import { useRef, useState } from "react";
 import { Button } from "@/components/ui/button";
 import { Card, CardContent } from "@/components/ui/card";
 import FlowEditor, { FlowEditorHandle } from "@/components/FlowEditor";
-import { Save, Sparkles, Wand2 } from "lucide-react";
+import { Activity, Save, Sparkles, Wand2 } from "lucide-react";
 import dynamic from "next/dynamic";
+import Link from "next/link";
 
 // Dynamically import the WorkflowGeneratorModal
 const WorkflowGeneratorModal = dynamic(
@@ -71,6 +72,14 @@ export default function Home() {
               Generate Dynamic Workflow
             </Button>
           </div>
+          <div className="flex gap-2">
+            <Button variant="outline" size="sm" asChild>
+              <Link href="/activity">
+                <Activity className="h-4 w-4 mr-2" />
+                Activity
+              </Link>
+            </Button>
+          </div>
         </div>
       </div>
 
@@ -0,0 +1,98 @@
+import { useState, useEffect, useCallback } from 'react';
+import { Button } from '@/components/ui/button';
+import { Badge } from '@/components/ui/badge';
+import { RefreshCw } from 'lucide-react';
+import { api, ActivityItem } from '@/services/api';
+
+interface ActivityFeedProps {
+  workflowId?: string;
+  limit?: number;
+}
+
+// Human-readable labels for activity types
+const activityLabels: Record<string, string> = {
+  workflow_created: 'Workflow created',
+  workflow_updated: 'Workflow edited',
+  workflow_deleted: 'Workflow deleted',
+  workflow_run_completed: 'Run completed',
+  workflow_run_failed: 'Run failed',
+  workflow_run_skipped: 'Run skipped',
+  schedule_alert: 'Schedule alert',
+  analysis_completed: 'Analysis completed',
+  result_annotated: 'Result annotated',
+  recommendation_accepted: 'Recommendation accepted',
+  customer_data_deleted: 'Customer data deleted',
+  api_key_created: 'API key issued',
+  api_key_revoked: 'API key revoked',
+  attribute_flag_resolved: 'Validation flag resolved',
+  canary_started: 'Canary started',
+  canary_promoted: 'Canary promoted',
+  canary_rolled_back: 'Canary rolled back',
+};
+
+export default function ActivityFeed({ workflowId, limit = 50 }: ActivityFeedProps) {
+  const [activity, setActivity] = useState<ActivityItem[]>([]);
+  const [isLoading, setIsLoading] = useState(false);
+  const [error, setError] = useState('');
+
+  const loadActivity = useCallback(async () => {
+    setIsLoading(true);
+    setError('');
+
+    try {
+      const items = await api.getActivity({ workflowId, limit });
+      setActivity(items);
+    } catch (err: any) {
+      console.error('Error loading activity:', err);
+      setError(err.message || 'Failed to load activity');
+    } finally {
+      setIsLoading(false);
+    }
+  }, [workflowId, limit]);
+
+  useEffect(() => {
+    loadActivity();
+  }, [loadActivity]);
+
+  return (
+    <div className="space-y-4">
+      <div className="flex items-center justify-between">
+        <h2 className="text-xl font-bold">Recent Activity</h2>
+        <Button variant="outline" size="sm" onClick={loadActivity} disabled={isLoading}>
+          <RefreshCw className="h-4 w-4 mr-2" />
+          Refresh
+        </Button>
+      </div>
+
+      {error && (
+        <div className="bg-destructive/10 text-destructive p-4 rounded-md">
+          {error}
+        </div>
+      )}
+
+      {!error && activity.length === 0 && !isLoading && (
+        <p className="text-muted-foreground">No activity yet.</p>
+      )}
+
+      <ul className="space-y-2">
+        {activity.map((item) => (
+          <li key={item.id} className="border rounded-lg p-4 flex items-start justify-between gap-4">
+            <div className="space-y-1">
+              <div className="flex items-center gap-2">
+                <Badge variant="secondary">{activityLabels[item.type] || item.type}</Badge>
+                <span className="text-sm text-muted-foreground">by {item.actor}</span>
+              </div>
+              <div>{item.summary}</div>
+              {item.workflow_id && (
+                <div className="text-xs text-muted-foreground">Workflow: {item.workflow_id}</div>
+              )}
+            </div>
+            <time className="text-sm text-muted-foreground whitespace-nowrap" dateTime={item.created_at}>
+              {new Date(item.created_at).toLocaleString()}
+            </time>
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+}
@@ -30,7 +30,8 @@ const functionMetadata: Record<string, {
     outputs: [
       { name: 'trends', type: 'array', description: 'List of identified trends with focus area, trend, supporting data, and confidence' },
       { name: 'overall_insights', type: 'string[]', description: 'General insights derived from the data' },
-      { name: 'data_quality', type: 'object', description: 'Assessment of data quality and limitations' }
+      { name: 'data_quality', type: 'object', description: 'Assessment of data quality and limitations' },
+      { name: 'metrics', type: 'object', description: 'Counts, averages, distributions and time series computed from the data' }
     ],
     example: `{
   "focus_areas": ["customer satisfaction", "response time", "issue resolution"],
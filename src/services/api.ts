@@ -9,6 +9,117 @@ export interface FlowData {
 
 export interface WorkflowData extends FlowData {
   date: string;
+  version?: number;
+}
+
+// A single granular edit to a workflow graph
+export interface WorkflowOperation {
+  type: 'add_node' | 'delete_node' | 'update_node' | 'add_edge' | 'delete_edge' | 'update_edge';
+  node_id?: string;
+  edge_id?: string;
+  node?: Record<string, any>;
+  edge?: Record<string, any>;
+  data?: Record<string, any>;
+  position?: { x: number; y: number };
+}
+
+// An entry in a workflow's operation log
+export interface WorkflowOperationLogEntry {
+  id: string;
+  workflow_id: string;
+  seq: number;
+  version: number;
+  operations: WorkflowOperation[];
+  inverse: WorkflowOperation[];
+  state: 'applied' | 'undone' | 'discarded';
+  actor: string;
+  created_at: string;
+}
+
+// A node in the lineage graph: a source conversation or a derived result
+export interface LineageRef {
+  type: string;
+  id: string;
+}
+
+export interface LineageEdge {
+  source: LineageRef;
+  target: LineageRef;
+  workflow_id?: string;
+  created_at: string;
+}
+
+export interface LineageTrace {
+  node: LineageRef;
+  direction: 'upstream' | 'downstream';
+  edges: LineageEdge[];
+  conversations?: string[];
+}
+
+// Callbacks for streamed analysis events
+export interface AnalysisStreamHandlers {
+  onProgress?: (progress: { stage: string; analysis_type: string; timestamp: string }) => void;
+  onChunk?: (text: string, index: number) => void;
+}
+
+// Output of a single-node test run
+export interface NodeTestResult {
+  workflow_id: string;
+  node_id: string;
+  timestamp: string;
+  duration_ms: number;
+  output: Record<string, any>;
+}
+
+export interface WorkflowEditResult {
+  version: number;
+  workflow: WorkflowData;
+}
+
+// Thrown when an edit is based on a stale workflow version
+export class WorkflowVersionConflictError extends Error {
+  constructor(public currentVersion: number) {
+    super(`Workflow has been modified by another edit (current version ${currentVersion})`);
+  }
+}
+
+export interface ToolManifest {
+  name: string;
+  version: string;
+  kind?: 'tool' | 'agent';
+  label?: string;
+  description?: string;
+  inputs: Record<string, any>;
+  outputs: Record<string, any>;
+  endpoint: {
+    url: string;
+    method?: 'GET' | 'POST' | 'PUT';
+    timeout_seconds?: number;
+  };
+  auth: {
+    type: 'none' | 'bearer' | 'api_key' | 'basic';
+    header?: string;
+    secret_env?: string;
+  };
+}
+
+export interface InstalledToolManifest extends ToolManifest {
+  installed_at: string;
+  updated_at: string;
+}
+
+export interface WorkflowCloneOptions {
+  id?: string;
+  name?: string;
+  copySchedules?: boolean;
+  copyPromptOverrides?: boolean;
+  copyAttributeBindings?: boolean;
+}
+
+export interface WorkflowCloneResult {
+  source_id: string;
+  workflow: WorkflowData;
+  excluded: Record<string, number>;
 }
 
 export interface ComponentItem {
@@ -120,6 +231,591 @@ export interface FunctionMetadata {
   example?: Record<string, any>;
 }
 
+export interface SchemaRepairStats {
+  requests: number;
+  repaired: number;
+  failed: number;
+  repair_attempts: number;
+  repair_rate: number;
+}
+
+export interface AnalysisQualityMetrics {
+  schema_repairs: Record<string, SchemaRepairStats>;
+}
+
+export interface AnalysisCacheStats {
+  enabled: boolean;
+  ttl_seconds: number;
+  entries: number;
+  expired: number;
+  hits: number;
+}
+
+export interface PIIEntity {
+  type: string;
+  start: number;
+  end: number;
+  text: string;
+  score: number;
+  provider: string;
+}
+
+export interface UsageQuery {
+  group_by?: string[];
+  tags?: Record<string, string>;
+  since?: string;
+  until?: string;
+  kind?: string;
+  analysis_type?: string;
+  workflow_id?: string;
+}
+
+export interface UsageGroup {
+  group: Record<string, string>;
+  requests: number;
+  calls: number;
+  prompt_tokens: number;
+  completion_tokens: number;
+  cached_prompt_tokens: number;
+  total_tokens: number;
+  cost: number;
+  estimated?: boolean;
+}
+
+export interface UsageSummary {
+  group_by: string[];
+  groups: UsageGroup[];
+  total: UsageGroup;
+  currency: string;
+  since?: string;
+  until?: string;
+  estimated?: boolean;
+}
+
+export interface CostTotal {
+  key?: string;
+  requests: number;
+  calls: number;
+  prompt_tokens: number;
+  completion_tokens: number;
+  cached_prompt_tokens: number;
+  total_tokens: number;
+  cost: number;
+  estimated?: boolean;
+}
+
+export interface WorkflowCosts {
+  workflow_id: string;
+  total: CostTotal;
+  by_kind: CostTotal[];
+  by_analysis_type: CostTotal[];
+  by_model: CostTotal[];
+  first_call_at?: string;
+  last_call_at?: string;
+  currency: string;
+}
+
+export interface PIIRedactOptions {
+  language?: string;
+  providers?: string[];
+  min_confidence?: number;
+}
+
+export interface PIIRedactResult {
+  redacted_text: string;
+  entities: PIIEntity[];
+  providers: string[];
+}
+
+export interface DemoStatus {
+  enabled: boolean;
+  limits?: {
+    rate_limit_per_minute: number;
+    write_rate_limit_per_minute: number;
+  };
+  conversations?: number;
+}
+
+export type APIKeyRole = 'reader' | 'analyst' | 'admin';
+
+export interface AuthStatus {
+  enabled: boolean;
+  principal?: {
+    key_id: string;
+    name: string;
+    role: APIKeyRole;
+  };
+}
+
+export interface APIKey {
+  id: string;
+  name: string;
+  role: APIKeyRole;
+  prefix: string;
+  created_by?: string;
+  created_at: string;
+  expires_at?: string;
+  last_used_at?: string;
+  revoked_at?: string;
+  key?: string; // Only returned when the key is issued
+}
+
+export interface WebhookCondition {
+  path: string;
+  op: 'contains' | 'eq' | 'ne' | 'in' | 'gt' | 'gte' | 'lt' | 'lte' | 'exists';
+  value?: string | number | boolean | (string | number | boolean)[];
+}
+
+export interface WebhookSubscriptionInput {
+  name: string;
+  url: string;
+  secret?: string; // Omit on update to keep the stored secret
+  analysis_types?: string[];
+  workflow_id?: string;
+  conditions?: WebhookCondition[];
+  match?: 'all' | 'any';
+  enabled?: boolean;
+}
+
+export interface WebhookSubscription {
+  id: string;
+  name: string;
+  url: string;
+  signed: boolean;
+  analysis_types: string[];
+  workflow_id?: string;
+  conditions: WebhookCondition[];
+  match: 'all' | 'any';
+  enabled: boolean;
+  created_at: string;
+  updated_at: string;
+  deliveries: number;
+  failures: number;
+  last_delivery_at?: string;
+  last_status?: number;
+  last_error?: string;
+}
+
+export interface CanaryStats {
+  requests: number;
+  failures: number;
+  structured_outputs: number;
+  repaired_outputs: number;
+  invalid_outputs: number;
+  failure_rate: number;
+  repair_rate: number;
+  average_confidence: number;
+  average_latency_ms: number;
+}
+
+export interface CanaryInput {
+  name: string;
+  analysis_types?: string[];
+  percentage: number;
+  model?: string;
+  prompt_instructions?: string;
+}
+
+export interface Canary {
+  id: string;
+  name: string;
+  analysis_types: string[];
+  percentage: number;
+  model?: string;
+  prompt_instructions?: string;
+  status: 'active' | 'promoted' | 'rolled_back';
+  created_by?: string;
+  created_at: string;
+  ended_at?: string;
+  ended_by?: string;
+  baseline: CanaryStats;
+  canary: CanaryStats;
+  comparison: {
+    failure_rate_delta: number;
+    repair_rate_delta: number;
+    average_confidence_delta: number;
+    average_latency_ms_delta: number;
+  };
+}
+
+export interface BatchAnalysisOptions {
+  workflowId?: string;
+  dataKey?: string;
+  textField?: string;
+  batchSize?: number;
+  concurrency?: number;
+  mergeStrategy?: 'concat' | 'consolidate' | 'none';
+  consolidateKey?: string;
+  cache?: boolean;
+}
+
+export interface BatchOutcome {
+  index: number;
+  size: number;
+  confidence?: number;
+  error?: string;
+}
+
+export interface BatchItemStatus {
+  index: number;
+  id?: string;
+  batch: number;
+  status: 'succeeded' | 'failed' | 'skipped';
+  error?: string;
+}
+
+export interface SupportingExcerpt {
+  conversation_id: string;
+  excerpt: string;
+  relevance: string;
+}
+
+export interface ExplainOptions {
+  conversations?: { conversation_id: string; text: string }[];
+  databasePath?: string;
+  maxExcerpts?: number;
+}
+
+export interface ResultItemExplanation {
+  result_id: string;
+  workflow_id: string;
+  analysis_type: string;
+  item: string;
+  item_value: any;
+  explanation: string;
+  reasoning: string[];
+  supporting_excerpts: SupportingExcerpt[];
+  caveats: string[];
+  confidence: number;
+  source_conversations: string[] | null;
+  data_quality: {
+    limitations?: string[];
+  };
+  timestamp: string;
+}
+
+export type AnalysisJobStatus = 'queued' | 'running' | 'completed' | 'failed';
+
+export interface AnalysisJob {
+  id: string;
+  kind: 'analysis' | 'batch';
+  workflow_id?: string;
+  analysis_type: string;
+  actor: string;
+  status: AnalysisJobStatus;
+  progress: {
+    stage: string;
+    completed: number;
+    total: number;
+  };
+  result?: any;
+  error?: string;
+  created_at: string;
+  started_at?: string;
+  completed_at?: string;
+}
+
+export interface Conversation {
+  conversation_id: string;
+  customer_id?: string;
+  text: string;
+  date_time?: string;
+  source?: string;
+  metadata?: Record<string, any>;
+  created_at: string;
+  updated_at: string;
+  storage_tier?: 'hot' | 'cold' | 'dropped';
+}
+
+export interface ConversationTurn {
+  index: number;
+  speaker?: string;
+  role: 'customer' | 'agent' | 'system' | 'unknown';
+  text: string;
+  timestamp?: string;
+}
+
+export interface ConversationTurns {
+  conversation_id: string;
+  structured: boolean;
+  speakers: string[];
+  turns: ConversationTurn[];
+}
+
+export interface ConversationInput {
+  conversation_id?: string;
+  customer_id?: string;
+  text: string;
+  date_time?: string;
+  source?: string;
+  metadata?: Record<string, any>;
+}
+
+export interface ConversationFilter {
+  source?: string;
+  customer_id?: string;
+  q?: string;
+  since?: string;
+  until?: string;
+  limit?: number;
+  offset?: number;
+}
+
+export interface ConversationList {
+  conversations: Conversation[];
+  total: number;
+  limit: number;
+  offset: number;
+}
+
+export interface ConversationAttribute {
+  conversation_id: string;
+  type: string;
+  name: string;
+  value: string;
+  description?: string;
+  confidence: number;
+  explanation?: string;
+  workflow_id?: string;
+  updated_at: string;
+}
+
+export interface ConversationAttributeRevision {
+  id: number;
+  conversation_id: string;
+  type: string;
+  name: string;
+  previous_value: string;
+  value: string;
+  previous_confidence: number;
+  confidence: number;
+  reason: string;
+  explanation?: string;
+  workflow_id?: string;
+  created_at: string;
+}
+
+export interface ConversationAttributeRevisions {
+  conversation_id: string;
+  revisions: ConversationAttributeRevision[];
+  affected_results: { type: string; id: string }[];
+}
+
+export interface AttributeSetDefinition {
+  field_name: string;
+  title: string;
+  description: string;
+  speaker?: 'customer' | 'agent' | 'system';
+}
+
+export interface ValidationCondition {
+  field: string;
+  op: 'eq' | 'ne' | 'in' | 'not_in' | 'gt' | 'gte' | 'lt' | 'lte' | 'present' | 'absent';
+  value?: string | number | boolean | (string | number | boolean)[];
+}
+
+export interface ValidationRule {
+  name: string;
+  description?: string;
+  when: ValidationCondition[];
+  require?: ValidationCondition[];
+}
+
+export interface RuleViolation {
+  rule: string;
+  message: string;
+  fields: string[];
+  values: Record<string, string>;
+}
+
+export interface AttributeFlag extends RuleViolation {
+  id: number;
+  conversation_id: string;
+  workflow_id?: string;
+  status: 'open' | 'resolved';
+  created_at: string;
+  resolved_at?: string;
+  resolved_by?: string;
+  note?: string;
+}
+
+export interface AttributeSet {
+  id: string;
+  name: string;
+  description?: string;
+  attributes: AttributeSetDefinition[];
+  validation_rules?: ValidationRule[];
+  created_at: string;
+}
+
+export interface PipelineStep {
+  analysis: string;
+  parameters?: Record<string, any>;
+  depends_on?: string[];
+  input_mapping?: Record<string, string>;
+}
+
+export interface Pipeline {
+  id: string;
+  name: string;
+  description?: string;
+  steps: PipelineStep[];
+  confidence_propagation?: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface WorkflowConcurrency {
+  workflow_id: string;
+  mode: 'limit' | 'mutex';
+  max_concurrent_runs: number;
+  updated_at?: string;
+  running: number;
+}
+
+export interface PromptTemplate {
+  id: string;
+  analysis_type: string;
+  version: number;
+  template: string;
+  description?: string;
+  source: 'db' | 'file';
+  active: boolean;
+  created_at: string;
+}
+
+export interface Widget {
+  id: string;
+  name: string;
+  workflow_id: string;
+  analysis_type: string;
+  field?: string;
+  period: 'latest' | 'day' | 'week' | 'month';
+  token_prefix: string;
+  created_by?: string;
+  created_at: string;
+}
+
+// A widget with its token, only returned when the token is issued
+export interface SharedWidget extends Widget {
+  token: string;
+  data_url: string;
+}
+
+export interface WorkspaceDefaults {
+  batch_size?: number;
+  min_confidence?: number;
+  language?: string;
+  detail?: 'concise' | 'detailed';
+  focus_areas?: string[];
+  updated_by?: string;
+  updated_at?: string;
+}
+
+export interface WorkflowSLA {
+  workflow_id: string;
+  max_runtime_seconds?: number;
+  max_cost?: number;
+  min_confidence?: number;
+  updated_at?: string;
+}
+
+export interface SLABreach {
+  metric: 'runtime' | 'cost' | 'confidence' | 'failed';
+  limit?: number;
+  actual?: number;
+}
+
+export interface SLARun {
+  id: string;
+  workflow_id: string;
+  kind: string;
+  runtime_seconds: number;
+  cost: number;
+  confidence?: number;
+  failed?: boolean;
+  compliant: boolean;
+  breaches: SLABreach[];
+  created_at: string;
+}
+
+export interface SLACompliance {
+  workflow_id: string;
+  workflow_name: string;
+  sla: WorkflowSLA;
+  runs: number;
+  breached_runs: number;
+  compliance_rate: number;
+  breaches_by_metric: Record<string, number>;
+  last_breach_at?: string;
+}
+
+export interface ScheduleHealth {
+  workflow_id: string;
+  workflow_name: string;
+  status: 'healthy' | 'stale' | 'failing' | 'invalid';
+  reason: string;
+  interval?: string;
+  window?: string;
+  last_success_at?: string;
+  last_failure_at?: string;
+  due_by?: string;
+}
+
+export interface ScheduleHealthReport {
+  checked_at: string;
+  summary: Record<string, number>;
+  schedules: ScheduleHealth[];
+}
+
+export interface CustomerDataDeletion {
+  customer_id: string;
+  dry_run?: boolean;
+  conversations: string[];
+  attributes: number;
+  attribute_revisions: number;
+  lineage_edges: number;
+  pseudonyms: number;
+  cache_entries: number;
+  audit_requests: number;
+  audit_calls: number;
+  jobs: number;
+  run_nodes: number;
+  flagged_results: { result_id: string; workflow_id: string; analysis_type: string; removed_citations: number }[];
+}
+
+export interface TaxonomyGraphNode {
+  id: string;
+  kind: 'intent' | 'intent_group' | 'pattern';
+  label: string;
+  description?: string;
+  count: number;
+  result_id?: string;
+}
+
+export interface TaxonomyGraph {
+  nodes: TaxonomyGraphNode[];
+  edges: { source: string; target: string; relation: 'contains' | 'cites' }[];
+}
+
+export interface ActivityItem {
+  id: string;
+  type: string;
+  workflow_id?: string;
+  actor: string;
+  summary: string;
+  details?: Record<string, any>;
+  created_at: string;
+}
+
+export interface ActivityQuery {
+  workflowId?: string;
+  types?: string[];
+  actor?: string;
+  since?: string;
+  limit?: number;
+}
+
 // Initialize cache as empty object instead of null
 let functionMetadataCache: Record<string, FunctionMetadata> = {};
 let workflowConfigCache: Record<string, WorkflowInputConfig> = {};
@@ -190,6 +886,22 @@ export const api = {
         description: 'Generate intent from text',
         analysisType: 'intent'
       },
+      {
+        id: 'analysis-sentiment',
+        type: 'function',
+        label: 'Analyze Sentiment',
+        endpoint: '/api/analysis',
+        description: 'Analyze overall, per-speaker and start/middle/end sentiment of a conversation',
+        analysisType: 'sentiment'
+      },
+      {
+        id: 'analysis-compare',
+        type: 'function',
+        label: 'Compare Cohorts',
+        endpoint: '/api/analysis',
+        description: 'Compare trends, sentiment and resolution outcomes of two labeled cohorts of conversations',
+        analysisType: 'compare'
+      },
       {
         id: 'analysis-recommendations',
         type: 'function',
@@ -240,6 +952,46 @@ export const api = {
     return response.json();
   },
   
+  // Install or upgrade a third-party tool or agent from its manifest
+  installToolManifest: async (manifest: ToolManifest): Promise<InstalledToolManifest> => {
+    const response = await fetch(`${API_URL}/tools/install`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(manifest),
+    });
+
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(`Failed to install tool manifest: ${errorText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get all installed tool and agent manifests
+  getToolManifests: async (): Promise<InstalledToolManifest[]> => {
+    const response = await fetch(`${API_URL}/tools/manifests`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch tool manifests: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Uninstall a tool or agent installed from a manifest
+  uninstallToolManifest: async (name: string): Promise<void> => {
+    const response = await fetch(`${API_URL}/tools/manifests/${name}`, {
+      method: 'DELETE',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to uninstall tool manifest ${name}: ${response.statusText}`);
+    }
+  },
+
   // Add a new agent
   addAgent: async (agent: ComponentItem): Promise<ComponentItem> => {
     const response = await fetch(`${API_URL}/agents`, {
@@ -330,6 +1082,56 @@ export const api = {
     return response.json();
   },
   
+  // Apply granular edits to a workflow at a known version
+  applyWorkflowOperations: async (id: string, baseVersion: number, operations: WorkflowOperation[]): Promise<WorkflowEditResult> => {
+    return postWorkflowEdit(`${API_URL}/workflows/${id}/operations`, { base_version: baseVersion, operations });
+  },
+
+  // Undo the most recent edit of a workflow
+  undoWorkflow: async (id: string, baseVersion: number): Promise<WorkflowEditResult> => {
+    return postWorkflowEdit(`${API_URL}/workflows/${id}/undo`, { base_version: baseVersion });
+  },
+
+  // Redo the most recently undone edit of a workflow
+  redoWorkflow: async (id: string, baseVersion: number): Promise<WorkflowEditResult> => {
+    return postWorkflowEdit(`${API_URL}/workflows/${id}/redo`, { base_version: baseVersion });
+  },
+
+  // Fork a workflow, choosing which node components to copy
+  cloneWorkflow: async (id: string, options: WorkflowCloneOptions = {}): Promise<WorkflowCloneResult> => {
+    const response = await fetch(`${API_URL}/workflows/${id}/clone`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({
+        id: options.id,
+        name: options.name,
+        copy_schedules: options.copySchedules,
+        copy_prompt_overrides: options.copyPromptOverrides,
+        copy_attribute_bindings: options.copyAttributeBindings,
+      }),
+    });
+
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(`Failed to clone workflow ${id}: ${errorText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the operation log of a workflow
+  getWorkflowOperations: async (id: string): Promise<WorkflowOperationLogEntry[]> => {
+    const response = await fetch(`${API_URL}/workflows/${id}/operations`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch operations for workflow ${id}: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
   // Delete a workflow
   deleteWorkflow: async (id: string): Promise<void> => {
     const response = await fetch(`${API_URL}/workflows/${id}`, {
@@ -392,6 +1194,206 @@ export const api = {
     }
   },
 
+  // Perform an analysis with streamed progress and partial output (Server-Sent Events)
+  streamAnalysis: async (
+    request: Record<string, any>,
+    handlers: AnalysisStreamHandlers = {}
+  ): Promise<any> => {
+    const response = await fetch(`${API_URL}/analysis`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'Accept': 'text/event-stream',
+      },
+      body: JSON.stringify({ ...request, stream: true }),
+    });
+
+    if (!response.ok || !response.body) {
+      throw new Error(`Analysis failed: ${response.statusText}`);
+    }
+
+    const reader = response.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    let result: any = null;
+
+    while (true) {
+      const { done, value } = await reader.read();
+      if (done) break;
+      buffer += decoder.decode(value, { stream: true });
+
+      // Events are separated by a blank line
+      let boundary = buffer.indexOf('\n\n');
+      while (boundary >= 0) {
+        const rawEvent = buffer.slice(0, boundary);
+        buffer = buffer.slice(boundary + 2);
+        boundary = buffer.indexOf('\n\n');
+
+        let event = 'message';
+        let data = '';
+        for (const line of rawEvent.split('\n')) {
+          if (line.startsWith('event:')) event = line.slice(6).trim();
+          else if (line.startsWith('data:')) data += line.slice(5).trim();
+        }
+        if (!data) continue;
+
+        const payload = JSON.parse(data);
+        switch (event) {
+          case 'progress':
+            handlers.onProgress?.(payload);
+            break;
+          case 'chunk':
+            handlers.onChunk?.(payload.text, payload.index);
+            break;
+          case 'result':
+            result = payload;
+            break;
+          case 'error':
+            throw new Error(payload.error?.message || 'Analysis failed');
+        }
+      }
+    }
+
+    if (!result) {
+      throw new Error('Analysis stream ended without a result');
+    }
+    return result;
+  },
+
+  // Run an analysis over a large data array, batched and merged server-side
+  performBatchAnalysis: async (
+    analysisType: string,
+    parameters: Record<string, any>,
+    data: any[],
+    options: BatchAnalysisOptions = {}
+  ): Promise<any> => {
+    const response = await fetch(`${API_URL}/analysis/batch`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({
+        analysis_type: analysisType,
+        workflow_id: options.workflowId,
+        parameters,
+        data,
+        data_key: options.dataKey,
+        text_field: options.textField,
+        batch_size: options.batchSize,
+        concurrency: options.concurrency,
+        merge_strategy: options.mergeStrategy,
+        consolidate_key: options.consolidateKey,
+        cache: options.cache,
+      }),
+    });
+
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(`Batch analysis failed: ${errorText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the URL of a CSV, Excel or Parquet export of a workflow's stored results
+  getResultsExportURL: (workflowId: string, format: 'csv' | 'xlsx' | 'parquet' = 'csv', analysisType?: string): string => {
+    const params = new URLSearchParams({ workflow_id: workflowId, format });
+    if (analysisType) params.set('analysis_type', analysisType);
+    return `${API_URL}/analysis/results/export?${params}`;
+  },
+
+  // Get the URL of a GraphML or DOT export of a workflow's intent taxonomy and pattern relationships
+  getTaxonomyGraphURL: (workflowId: string, format: 'graphml' | 'dot' | 'json' = 'graphml'): string => {
+    const params = new URLSearchParams({ workflow_id: workflowId, format });
+    return `${API_URL}/analysis/results/graph?${params}`;
+  },
+
+  // Get a workflow's intent taxonomy and pattern relationships as nodes and edges
+  getTaxonomyGraph: async (workflowId: string): Promise<TaxonomyGraph> => {
+    const response = await fetch(api.getTaxonomyGraphURL(workflowId, 'json'));
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch taxonomy graph: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Explain one item of a stored analysis result, e.g. "trends[0]"
+  explainResultItem: async (
+    resultId: string,
+    item: string,
+    options: ExplainOptions = {}
+  ): Promise<ResultItemExplanation> => {
+    const response = await fetch(`${API_URL}/analysis/explain`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({
+        result_id: resultId,
+        item,
+        conversations: options.conversations,
+        database_path: options.databasePath,
+        max_excerpts: options.maxExcerpts,
+      }),
+    });
+
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(`Failed to explain result item: ${errorText}`);
+    }
+
+    return response.json();
+  },
+
+  // Queue an analysis or batch analysis request to run in the background
+  submitAnalysisJob: async (
+    request: Record<string, any>,
+    kind: 'analysis' | 'batch' = 'analysis'
+  ): Promise<AnalysisJob> => {
+    const response = await fetch(`${API_URL}/analysis/jobs`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ ...request, kind }),
+    });
+
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(`Failed to queue analysis job: ${errorText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the status, progress and result of an analysis job
+  getAnalysisJob: async (jobId: string): Promise<AnalysisJob> => {
+    const response = await fetch(`${API_URL}/analysis/jobs/${encodeURIComponent(jobId)}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch analysis job: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // List recent analysis jobs, optionally for one workflow or status
+  listAnalysisJobs: async (workflowId?: string, status?: AnalysisJobStatus): Promise<AnalysisJob[]> => {
+    const params = new URLSearchParams();
+    if (workflowId) params.set('workflow_id', workflowId);
+    if (status) params.set('status', status);
+
+    const response = await fetch(`${API_URL}/analysis/jobs?${params.toString()}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to list analysis jobs: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
   // Perform chain analysis using the dedicated endpoint
   performChainAnalysis: async (workflowId: string, inputData: any, config: any): Promise<any> => {
     try {
@@ -418,50 +1420,793 @@ export const api = {
     }
   },
 
-  // Execute a workflow of connected functions
-  executeWorkflow: async (workflowId: string, inputData: Record<string, any> = {}): Promise<any> => {
-    try {
-      console.log(`Executing workflow ${workflowId} with input data:`, inputData);
-      
-      // Call the backend API to execute the workflow
-      const response = await fetch(`${API_URL}/workflows/${workflowId}/execute`, {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-        },
-        body: JSON.stringify({
-          data: inputData,
-          text: inputData.text,
-          parameters: inputData.parameters
-        }),
-      });
-      
-      if (!response.ok) {
-        throw new Error(`Failed to execute workflow: ${response.statusText}`);
-      }
-      
-      return response.json();
-    } catch (error) {
-      console.error('Error executing workflow:', error);
-      throw error;
+  // Execute a workflow of connected functions
+  executeWorkflow: async (workflowId: string, inputData: Record<string, any> = {}): Promise<any> => {
+    try {
+      console.log(`Executing workflow ${workflowId} with input data:`, inputData);
+      
+      // Call the backend API to execute the workflow
+      const response = await fetch(`${API_URL}/workflows/${workflowId}/execute`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({
+          data: inputData,
+          text: inputData.text,
+          parameters: inputData.parameters
+        }),
+      });
+      
+      if (!response.ok) {
+        throw new Error(`Failed to execute workflow: ${response.statusText}`);
+      }
+      
+      return response.json();
+    } catch (error) {
+      console.error('Error executing workflow:', error);
+      throw error;
+    }
+  },
+
+  // Execute a single workflow node with sample input, without running the rest of the graph
+  testWorkflowNode: async (
+    workflowId: string,
+    nodeId: string,
+    sample: { text?: string; input?: Record<string, any>; parameters?: Record<string, any> } = {}
+  ): Promise<NodeTestResult> => {
+    const response = await fetch(`${API_URL}/workflows/${workflowId}/nodes/${nodeId}/test`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(sample),
+    });
+
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(`Failed to test node ${nodeId}: ${text.trim() || response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+
+  // Get metadata for all analysis functions
+  getFunctionMetadata: async (): Promise<Record<string, FunctionMetadata>> => {
+    if (Object.keys(functionMetadataCache).length > 0) {
+      return functionMetadataCache;
+    }
+
+    const response = await fetch(`${API_URL}/analysis/metadata`);
+    
+    if (!response.ok) {
+      throw new Error(`Failed to fetch function metadata: ${response.statusText}`);
+    }
+    
+    const metadata = await response.json();
+    functionMetadataCache = metadata;
+    return metadata;
+  },
+
+  // Get statistics of the cache of identical analysis requests
+  getAnalysisCacheStats: async (): Promise<AnalysisCacheStats> => {
+    const response = await fetch(`${API_URL}/analysis/cache`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch analysis cache stats: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Clear cached analyses, optionally only those of one analysis type
+  clearAnalysisCache: async (analysisType?: string): Promise<{ removed: number }> => {
+    const query = analysisType ? `?analysis_type=${encodeURIComponent(analysisType)}` : '';
+    const response = await fetch(`${API_URL}/analysis/cache${query}`, {
+      method: 'DELETE',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to clear analysis cache: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Store conversations so analyses can reference them by ID
+  ingestConversations: async (
+    conversations: ConversationInput[],
+    options: { revisionReason?: string; reextract?: boolean } = {}
+  ): Promise<{ created: number; updated: number; conversation_ids: string[]; text_changed?: string[]; reextraction_jobs?: string[] }> => {
+    const response = await fetch(`${API_URL}/conversations`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ conversations, revision_reason: options.revisionReason, reextract: options.reextract }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to ingest conversations: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // List stored conversations
+  listConversations: async (filter: ConversationFilter = {}): Promise<ConversationList> => {
+    const params = new URLSearchParams();
+    Object.entries(filter).forEach(([key, value]) => {
+      if (value !== undefined && value !== '') {
+        params.set(key, String(value));
+      }
+    });
+    const query = params.toString() ? `?${params.toString()}` : '';
+    const response = await fetch(`${API_URL}/conversations${query}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to list conversations: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get a stored conversation by ID
+  getConversation: async (id: string): Promise<Conversation> => {
+    const response = await fetch(`${API_URL}/conversations/${encodeURIComponent(id)}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch conversation: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the speaker turns of a stored conversation, optionally only those of one role
+  getConversationTurns: async (id: string, role?: 'customer' | 'agent' | 'system'): Promise<ConversationTurns> => {
+    const query = role ? `?role=${role}` : '';
+    const response = await fetch(`${API_URL}/conversations/${encodeURIComponent(id)}/turns${query}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch conversation turns: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the attribute values extracted from a stored conversation
+  getConversationAttributes: async (id: string): Promise<ConversationAttribute[]> => {
+    const response = await fetch(`${API_URL}/conversations/${encodeURIComponent(id)}/attributes`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch conversation attributes: ${response.statusText}`);
+    }
+
+    const data = await response.json();
+    return data.attributes;
+  },
+
+  // Get the changes of a conversation's attribute values and the results still based on earlier values
+  getConversationAttributeRevisions: async (id: string): Promise<ConversationAttributeRevisions> => {
+    const response = await fetch(`${API_URL}/conversations/${encodeURIComponent(id)}/attributes/revisions`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch attribute revisions: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Delete a customer's conversations and their derived data, or report what would be deleted
+  deleteCustomerData: async (customerId: string, dryRun = false): Promise<CustomerDataDeletion> => {
+    const response = await fetch(`${API_URL}/customers/${encodeURIComponent(customerId)}/data`, {
+      method: dryRun ? 'GET' : 'DELETE',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to delete customer data: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Store a set of attribute definitions that analyses reference by attribute_set_id
+  createAttributeSet: async (name: string, attributes: AttributeSetDefinition[], description?: string, validationRules?: ValidationRule[]): Promise<AttributeSet> => {
+    const response = await fetch(`${API_URL}/attribute-sets`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ name, description, attributes, validation_rules: validationRules }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to create attribute set: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the records flagged by attribute validation rules
+  getAttributeFlags: async (filter: { conversation_id?: string; workflow_id?: string; rule?: string; status?: 'open' | 'resolved' | 'all'; limit?: number } = {}): Promise<AttributeFlag[]> => {
+    const params = new URLSearchParams();
+    Object.entries(filter).forEach(([key, value]) => {
+      if (value !== undefined && value !== '') {
+        params.set(key, String(value));
+      }
+    });
+    const query = params.toString() ? `?${params.toString()}` : '';
+    const response = await fetch(`${API_URL}/attribute-flags${query}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch attribute flags: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Mark an attribute flag as reviewed
+  resolveAttributeFlag: async (id: number, note?: string): Promise<AttributeFlag> => {
+    const response = await fetch(`${API_URL}/attribute-flags/${id}/resolve`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ note }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to resolve attribute flag: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the stored attribute sets
+  getAttributeSets: async (): Promise<AttributeSet[]> => {
+    const response = await fetch(`${API_URL}/attribute-sets`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch attribute sets: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the concurrency settings of a workflow
+  getWorkflowConcurrency: async (workflowId: string): Promise<WorkflowConcurrency> => {
+    const response = await fetch(`${API_URL}/workflows/${encodeURIComponent(workflowId)}/concurrency`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch workflow concurrency: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Limit how many runs of a workflow execute at once
+  setWorkflowConcurrency: async (workflowId: string, mode: 'limit' | 'mutex', maxConcurrentRuns = 0): Promise<WorkflowConcurrency> => {
+    const response = await fetch(`${API_URL}/workflows/${encodeURIComponent(workflowId)}/concurrency`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ mode, max_concurrent_runs: maxConcurrentRuns }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to update workflow concurrency: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // List prompt templates, optionally of one analysis type
+  getPromptTemplates: async (analysisType?: string): Promise<PromptTemplate[]> => {
+    const query = analysisType ? `?analysis_type=${encodeURIComponent(analysisType)}` : '';
+    const response = await fetch(`${API_URL}/prompt-templates${query}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch prompt templates: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Store a prompt template as the next version of its analysis type
+  createPromptTemplate: async (analysisType: string, template: string, description = '', activate = false): Promise<PromptTemplate> => {
+    const response = await fetch(`${API_URL}/prompt-templates`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ analysis_type: analysisType, template, description, activate }),
+    });
+
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(`Failed to create prompt template: ${errorText}`);
+    }
+
+    return response.json();
+  },
+
+  // Make a prompt template the default of its analysis type, or restore the built-in prompt
+  setPromptTemplateActive: async (ref: string, active: boolean): Promise<PromptTemplate> => {
+    const response = await fetch(`${API_URL}/prompt-templates/${encodeURIComponent(ref)}/activate`, {
+      method: active ? 'POST' : 'DELETE',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to update prompt template: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Delete a stored prompt template
+  deletePromptTemplate: async (ref: string): Promise<void> => {
+    const response = await fetch(`${API_URL}/prompt-templates/${encodeURIComponent(ref)}`, {
+      method: 'DELETE',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to delete prompt template: ${response.statusText}`);
+    }
+  },
+
+  // List shared widgets, optionally of one workflow
+  getWidgets: async (workflowId?: string): Promise<Widget[]> => {
+    const query = workflowId ? `?workflow_id=${encodeURIComponent(workflowId)}` : '';
+    const response = await fetch(`${API_URL}/widgets${query}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch widgets: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Share one field of the latest result of an analysis type as an embeddable widget
+  createWidget: async (widget: Pick<Widget, 'name' | 'workflow_id' | 'analysis_type' | 'field' | 'period'>): Promise<SharedWidget> => {
+    const response = await fetch(`${API_URL}/widgets`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(widget),
+    });
+
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(`Failed to create widget: ${errorText}`);
+    }
+
+    return response.json();
+  },
+
+  // Issue a new token for a widget, invalidating the old one
+  rotateWidgetToken: async (id: string): Promise<SharedWidget> => {
+    const response = await fetch(`${API_URL}/widgets/${encodeURIComponent(id)}/rotate`, {
+      method: 'POST',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to rotate widget token: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Revoke a widget
+  deleteWidget: async (id: string): Promise<void> => {
+    const response = await fetch(`${API_URL}/widgets/${encodeURIComponent(id)}`, {
+      method: 'DELETE',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to delete widget: ${response.statusText}`);
+    }
+  },
+
+  // Get the parameters applied to analysis requests that omit them
+  getWorkspaceDefaults: async (): Promise<WorkspaceDefaults> => {
+    const response = await fetch(`${API_URL}/workspace/defaults`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch workspace defaults: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Replace the workspace defaults
+  saveWorkspaceDefaults: async (defaults: WorkspaceDefaults): Promise<WorkspaceDefaults> => {
+    const response = await fetch(`${API_URL}/workspace/defaults`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(defaults),
+    });
+
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(`Failed to save workspace defaults: ${errorText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the SLA of a workflow with the compliance of its runs
+  getWorkflowSLA: async (workflowId: string): Promise<SLACompliance> => {
+    const response = await fetch(`${API_URL}/workflows/${encodeURIComponent(workflowId)}/sla`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch workflow SLA: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Set the SLA of a workflow
+  setWorkflowSLA: async (workflowId: string, sla: Omit<WorkflowSLA, 'workflow_id' | 'updated_at'>): Promise<SLACompliance> => {
+    const response = await fetch(`${API_URL}/workflows/${encodeURIComponent(workflowId)}/sla`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(sla),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to update workflow SLA: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Remove the SLA of a workflow and its run history
+  deleteWorkflowSLA: async (workflowId: string): Promise<void> => {
+    const response = await fetch(`${API_URL}/workflows/${encodeURIComponent(workflowId)}/sla`, {
+      method: 'DELETE',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to delete workflow SLA: ${response.statusText}`);
+    }
+  },
+
+  // List the runs checked against the SLA of a workflow, newest first
+  getWorkflowSLARuns: async (workflowId: string, breachedOnly = false, limit = 100): Promise<SLARun[]> => {
+    const params = new URLSearchParams({ limit: String(limit) });
+    if (breachedOnly) {
+      params.set('breached', 'true');
+    }
+    const response = await fetch(`${API_URL}/workflows/${encodeURIComponent(workflowId)}/sla/runs?${params}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch workflow SLA runs: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the SLA compliance of every workflow with an SLA, least compliant first
+  getSLACompliance: async (since?: string): Promise<SLACompliance[]> => {
+    const query = since ? `?since=${encodeURIComponent(since)}` : '';
+    const response = await fetch(`${API_URL}/slas${query}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch SLA compliance: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the health of scheduled workflows, optionally only those with one status
+  getScheduleHealth: async (status?: ScheduleHealth['status']): Promise<ScheduleHealthReport> => {
+    const query = status ? `?status=${encodeURIComponent(status)}` : '';
+    const response = await fetch(`${API_URL}/schedules/health${query}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch schedule health: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Store a named chain analysis pipeline
+  createPipeline: async (pipeline: Omit<Pipeline, 'id' | 'created_at' | 'updated_at'>): Promise<Pipeline> => {
+    const response = await fetch(`${API_URL}/pipelines`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(pipeline),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to create pipeline: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the stored pipelines
+  getPipelines: async (): Promise<Pipeline[]> => {
+    const response = await fetch(`${API_URL}/pipelines`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch pipelines: ${response.statusText}`);
     }
+
+    return response.json();
   },
 
-  // Get metadata for all analysis functions
-  getFunctionMetadata: async (): Promise<Record<string, FunctionMetadata>> => {
-    if (Object.keys(functionMetadataCache).length > 0) {
-      return functionMetadataCache;
+  // Execute a stored pipeline against new input
+  executePipeline: async (id: string, workflowId: string, input: { text?: string; data?: Record<string, any> }): Promise<any> => {
+    const response = await fetch(`${API_URL}/pipelines/${encodeURIComponent(id)}/execute`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ workflow_id: workflowId, ...input }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Pipeline execution failed: ${response.statusText}`);
     }
 
-    const response = await fetch(`${API_URL}/analysis/metadata`);
-    
+    return response.json();
+  },
+
+  // Build the query string of a usage request
+  usageQuery: (query: UsageQuery, format?: string): string => {
+    const params = new URLSearchParams();
+    if (query.group_by?.length) params.set('group_by', query.group_by.join(','));
+    Object.entries(query.tags || {}).forEach(([key, value]) => params.append('tag', `${key}:${value}`));
+    (['since', 'until', 'kind', 'analysis_type', 'workflow_id'] as const).forEach((key) => {
+      if (query[key]) params.set(key, query[key] as string);
+    });
+    if (format) params.set('format', format);
+    return params.toString() ? `?${params.toString()}` : '';
+  },
+
+  // Get LLM usage totals grouped by cost tags
+  getUsage: async (query: UsageQuery = {}): Promise<UsageSummary> => {
+    const response = await fetch(`${API_URL}/usage${api.usageQuery(query)}`);
+
     if (!response.ok) {
-      throw new Error(`Failed to fetch function metadata: ${response.statusText}`);
+      throw new Error(`Failed to fetch usage: ${response.statusText}`);
     }
-    
-    const metadata = await response.json();
-    functionMetadataCache = metadata;
-    return metadata;
+
+    return response.json();
+  },
+
+  // Get the URL of the CSV export of LLM usage
+  getUsageExportURL: (query: UsageQuery = {}): string => {
+    return `${API_URL}/usage${api.usageQuery(query, 'csv')}`;
+  },
+
+  // Get the estimated LLM cost of a workflow
+  getWorkflowCosts: async (workflowId: string): Promise<WorkflowCosts> => {
+    const response = await fetch(`${API_URL}/workflows/${workflowId}/costs`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch workflow costs: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Replace PII in a text with type placeholders
+  redactPII: async (text: string, options: PIIRedactOptions = {}): Promise<PIIRedactResult> => {
+    const response = await fetch(`${API_URL}/pii/redact`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ text, ...options }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to redact PII: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Check whether the server runs as a rate-limited public demo
+  getDemoStatus: async (): Promise<DemoStatus> => {
+    const response = await fetch(`${API_URL}/demo`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch demo status: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get whether API keys are required and which key authenticated the request
+  getAuthStatus: async (apiKey?: string): Promise<AuthStatus> => {
+    const response = await fetch(`${API_URL}/auth`, {
+      headers: apiKey ? { 'X-API-Key': apiKey } : {},
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch auth status: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the issued API keys
+  getAPIKeys: async (apiKey: string): Promise<APIKey[]> => {
+    const response = await fetch(`${API_URL}/auth/keys`, {
+      headers: { 'X-API-Key': apiKey },
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch API keys: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Issue an API key; the returned key is only shown once
+  createAPIKey: async (apiKey: string, name: string, role: APIKeyRole, expiresAt?: string): Promise<APIKey> => {
+    const response = await fetch(`${API_URL}/auth/keys`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json', 'X-API-Key': apiKey },
+      body: JSON.stringify({ name, role, expires_at: expiresAt }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to create API key: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Revoke an API key
+  revokeAPIKey: async (apiKey: string, id: string): Promise<APIKey> => {
+    const response = await fetch(`${API_URL}/auth/keys/${id}`, {
+      method: 'DELETE',
+      headers: { 'X-API-Key': apiKey },
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to revoke API key: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get the webhook subscriptions for completed analyses
+  getWebhooks: async (): Promise<WebhookSubscription[]> => {
+    const response = await fetch(`${API_URL}/webhooks`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch webhooks: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Subscribe a URL to completed analyses that pass the given filters
+  createWebhook: async (webhook: WebhookSubscriptionInput): Promise<WebhookSubscription> => {
+    const response = await fetch(`${API_URL}/webhooks`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(webhook),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to create webhook: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Replace the settings of a webhook subscription
+  updateWebhook: async (id: string, webhook: WebhookSubscriptionInput): Promise<WebhookSubscription> => {
+    const response = await fetch(`${API_URL}/webhooks/${encodeURIComponent(id)}`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(webhook),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to update webhook: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Delete a webhook subscription
+  deleteWebhook: async (id: string): Promise<void> => {
+    const response = await fetch(`${API_URL}/webhooks/${encodeURIComponent(id)}`, {
+      method: 'DELETE',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to delete webhook: ${response.statusText}`);
+    }
+  },
+
+  // Get the canary rollouts with their quality metrics
+  getCanaries: async (): Promise<Canary[]> => {
+    const response = await fetch(`${API_URL}/canaries`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch canaries: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Route a share of analysis traffic to a new model or prompt version
+  createCanary: async (canary: CanaryInput): Promise<Canary> => {
+    const response = await fetch(`${API_URL}/canaries`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(canary),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to create canary: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Change the share of traffic routed to an active canary
+  updateCanaryPercentage: async (id: string, percentage: number): Promise<Canary> => {
+    const response = await fetch(`${API_URL}/canaries/${encodeURIComponent(id)}`, {
+      method: 'PUT',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ percentage }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to update canary: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Promote a canary to the baseline, or roll it back
+  endCanary: async (id: string, action: 'promote' | 'rollback'): Promise<Canary> => {
+    const response = await fetch(`${API_URL}/canaries/${encodeURIComponent(id)}/${action}`, {
+      method: 'POST',
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to ${action} canary: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Get structured output repair statistics per output schema
+  getQualityMetrics: async (): Promise<AnalysisQualityMetrics> => {
+    const response = await fetch(`${API_URL}/analysis/quality`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch quality metrics: ${response.statusText}`);
+    }
+
+    return response.json();
   },
 
   // Get metadata for a specific function
@@ -555,6 +2300,74 @@ export const api = {
     }
   },
 
+  // Get the workspace activity feed
+  getActivity: async (query: ActivityQuery = {}): Promise<ActivityItem[]> => {
+    const params = new URLSearchParams();
+    if (query.workflowId) params.set('workflow_id', query.workflowId);
+    if (query.types && query.types.length > 0) params.set('type', query.types.join(','));
+    if (query.actor) params.set('actor', query.actor);
+    if (query.since) params.set('since', query.since);
+    if (query.limit) params.set('limit', String(query.limit));
+
+    const response = await fetch(`${API_URL}/activity?${params.toString()}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch activity: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Record a client-side activity event (e.g. an annotation or an accepted recommendation)
+  recordActivity: async (activity: {
+    type: 'result_annotated' | 'recommendation_accepted';
+    summary: string;
+    workflow_id?: string;
+    actor?: string;
+    details?: Record<string, any>;
+  }): Promise<ActivityItem> => {
+    const response = await fetch(`${API_URL}/activity`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(activity),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to record activity: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Trace a result back to its sources, or forward to the results derived from it
+  getLineage: async (node: LineageRef, direction: 'upstream' | 'downstream' = 'upstream'): Promise<LineageTrace> => {
+    const params = new URLSearchParams({ type: node.type, id: node.id, direction });
+    const response = await fetch(`${API_URL}/lineage?${params.toString()}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch lineage: ${response.statusText}`);
+    }
+
+    return response.json();
+  },
+
+  // Record that a result was derived from the given sources
+  recordLineage: async (target: LineageRef, sources: LineageRef[], workflowId?: string): Promise<void> => {
+    const response = await fetch(`${API_URL}/lineage`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ workflow_id: workflowId, target, sources }),
+    });
+
+    if (!response.ok) {
+      throw new Error(`Failed to record lineage: ${response.statusText}`);
+    }
+  },
+
   // Answer questions about banking data
   answerQuestions: async (
     questions: string[], 
@@ -650,6 +2463,37 @@ function getExecutionOrder(nodes: WorkflowNode[], edges: WorkflowEdge[]): Workfl
 }
 
 // Helper function to generate workflow configuration based on workflow nodes and edges
+// Posts a versioned workflow edit, surfacing version conflicts as WorkflowVersionConflictError
+async function postWorkflowEdit(url: string, body: Record<string, any>): Promise<WorkflowEditResult> {
+  const response = await fetch(url, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(body),
+  });
+
+  if (response.status === 409) {
+    const text = await response.text();
+    try {
+      const conflict = JSON.parse(text);
+      if (typeof conflict.current_version === 'number') {
+        throw new WorkflowVersionConflictError(conflict.current_version);
+      }
+    } catch (error) {
+      if (error instanceof WorkflowVersionConflictError) throw error;
+    }
+    throw new Error(text.trim() || response.statusText);
+  }
+
+  if (!response.ok) {
+    const text = await response.text();
+    throw new Error(`Failed to edit workflow: ${text.trim() || response.statusText}`);
+  }
+
+  return response.json();
+}
+
 async function generateWorkflowConfig(workflow: WorkflowData): Promise<WorkflowInputConfig> {
   const nodes = workflow.nodes;
   const edges = workflow.edges;